@@ -0,0 +1,17 @@
+// Package main is the entry point for the plexd-cni binary, the CNI plugin
+// that attaches containers to the mesh. The container runtime runs it with
+// the CNI_* environment and the network configuration on stdin; it obtains
+// addresses from the local plexd agent.
+package main
+
+import (
+	"os"
+
+	"github.com/plexsphere/plexd/internal/cni"
+)
+
+func main() {
+	if err := cni.RunPlugin(cni.ArgsFromEnv(os.Getenv), os.Stdin, os.Stdout, cni.NewContainerNetworker()); err != nil {
+		os.Exit(1)
+	}
+}
@@ -34,8 +34,7 @@ func runActions(cmd *cobra.Command, _ []string) error {
 	if err != nil {
 		return fmt.Errorf("plexd actions: %w", err)
 	}
-	fmt.Fprintln(cmd.OutOrStdout(), "action listing not yet available")
-	return nil
+	return writeNotAvailable(cmd, "action listing")
 }
 
 func runActionsRun(cmd *cobra.Command, args []string) error {
@@ -45,6 +44,5 @@ func runActionsRun(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("plexd actions run: %w", err)
 	}
 	// Action dispatch endpoint will be added in a future iteration.
-	fmt.Fprintf(cmd.OutOrStdout(), "action dispatch for %q not yet available\n", name)
-	return nil
+	return writeNotAvailable(cmd, fmt.Sprintf("action dispatch for %q", name))
 }
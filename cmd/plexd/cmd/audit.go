@@ -22,6 +22,5 @@ func runAudit(cmd *cobra.Command, _ []string) error {
 	if err != nil {
 		return fmt.Errorf("plexd audit: %w", err)
 	}
-	fmt.Fprintln(cmd.OutOrStdout(), "audit collection status not yet available")
-	return nil
+	return writeNotAvailable(cmd, "audit collection status")
 }
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/plexsphere/plexd/internal/packaging"
+)
+
+// initSystems are the values of the --init-system flags.
+var initSystems = []string{
+	packaging.InitSystemAuto,
+	packaging.InitSystemSystemd,
+	packaging.InitSystemOpenRC,
+	packaging.InitSystemSysV,
+	packaging.InitSystemSCM,
+	packaging.InitSystemLaunchd,
+}
+
+// registerFlagValues makes shell completion offer values for the flag of
+// cmd. The flag must already be defined; registering an unknown flag is a
+// programming error and panics at startup.
+func registerFlagValues(cmd *cobra.Command, flag string, values ...string) {
+	if err := cmd.RegisterFlagCompletionFunc(flag, cobra.FixedCompletions(values, cobra.ShellCompDirectiveNoFileComp)); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/plexsphere/plexd/internal/agent"
+	"github.com/plexsphere/plexd/internal/fsutil"
+)
+
+var (
+	configPrintRedact   bool
+	configMigrateDryRun bool
+)
+
+// configValidateResult is the JSON result of plexd config validate.
+type configValidateResult struct {
+	File   string   `json:"file"`
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors"`
+}
+
+// configMigrateResult is the JSON result of plexd config migrate. Content
+// holds the migrated file with --dry-run.
+type configMigrateResult struct {
+	File     string   `json:"file"`
+	From     int      `json:"from"`
+	To       int      `json:"to"`
+	Migrated bool     `json:"migrated"`
+	Backup   string   `json:"backup,omitempty"`
+	Changes  []string `json:"changes"`
+	Content  string   `json:"content,omitempty"`
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the agent configuration",
+	Long:  "Validate or print the agent configuration file without starting the agent.",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the configuration file",
+	Long: "Parse and validate the configuration file. Unknown keys are reported with\n" +
+		"their line numbers and every validation error is listed. Exits non-zero\n" +
+		"if the file is invalid.",
+	Args: cobra.NoArgs,
+	RunE: runConfigValidate,
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print the effective configuration",
+	Long: "Print the configuration plexd up would run with: the file with environment,\n" +
+		"flag overrides, and defaults applied. Use --redact to mask secrets.",
+	Args: cobra.NoArgs,
+	RunE: runConfigPrint,
+}
+
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the configuration file to the current layout",
+	Long: "Rewrite the configuration file in place in the current config_version\n" +
+		"layout. Comments and unrelated keys are kept; the original file is saved\n" +
+		"next to it with a .bak suffix. Use --dry-run to print the result instead.",
+	Args: cobra.NoArgs,
+	RunE: runConfigMigrate,
+}
+
+func init() {
+	configPrintCmd.Flags().BoolVar(&configPrintRedact, "redact", false, "mask secret values")
+	configMigrateCmd.Flags().BoolVar(&configMigrateDryRun, "dry-run", false, "print the migrated file instead of writing it")
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configPrintCmd)
+	configCmd.AddCommand(configMigrateCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func runConfigValidate(cmd *cobra.Command, _ []string) error {
+	res := configValidateResult{File: cfgFile, Valid: true, Errors: []string{}}
+	if _, err := agent.CheckConfig(cfgFile, configOverrides(cmd)); err != nil {
+		errs := []error{err}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			errs = joined.Unwrap()
+		}
+		res.Valid = false
+		for _, e := range errs {
+			res.Errors = append(res.Errors, e.Error())
+		}
+		writeResult(cmd, res, func(io.Writer) {
+			for _, e := range res.Errors {
+				fmt.Fprintln(cmd.ErrOrStderr(), e)
+			}
+		})
+		return withExitCode(ExitConfig, fmt.Errorf("plexd config validate: %s: %d error(s)", cfgFile, len(errs)))
+	}
+	writeResult(cmd, res, func(w io.Writer) { fmt.Fprintf(w, "%s: OK\n", cfgFile) })
+	return nil
+}
+
+func runConfigPrint(cmd *cobra.Command, _ []string) error {
+	cfg, err := agent.LoadConfig(cfgFile, configOverrides(cmd))
+	if err != nil {
+		return withExitCode(ExitConfig, fmt.Errorf("plexd config print: %w", err))
+	}
+	applyDerivedConfig(cfg)
+
+	out, err := agent.MarshalConfig(cfg, configPrintRedact)
+	if err != nil {
+		return fmt.Errorf("plexd config print: %w", err)
+	}
+	if !jsonOutput() {
+		_, err = cmd.OutOrStdout().Write(out)
+		return err
+	}
+	// The JSON result mirrors the YAML keys of the config file.
+	var doc map[string]any
+	if err := yaml.Unmarshal(out, &doc); err != nil {
+		return fmt.Errorf("plexd config print: %w", err)
+	}
+	writeResult(cmd, doc, nil)
+	return nil
+}
+
+func runConfigMigrate(cmd *cobra.Command, _ []string) error {
+	data, err := os.ReadFile(cfgFile)
+	if err != nil {
+		return fmt.Errorf("plexd config migrate: %w", err)
+	}
+	out, res, err := agent.MigrateConfig(data)
+	if err != nil {
+		return withExitCode(ExitConfig, fmt.Errorf("plexd config migrate: %s: %w", cfgFile, err))
+	}
+	result := configMigrateResult{
+		File:     cfgFile,
+		From:     res.From,
+		To:       res.To,
+		Migrated: res.Migrated(),
+		Changes:  append([]string{}, res.Changes...),
+	}
+
+	if configMigrateDryRun {
+		result.Content = string(out)
+		writeResult(cmd, result, func(w io.Writer) { w.Write(out) })
+		return nil
+	}
+	if !res.Migrated() {
+		writeResult(cmd, result, func(w io.Writer) {
+			fmt.Fprintf(w, "%s: already at config_version %d\n", cfgFile, res.To)
+		})
+		return nil
+	}
+
+	info, err := os.Stat(cfgFile)
+	if err != nil {
+		return fmt.Errorf("plexd config migrate: %w", err)
+	}
+	backup := cfgFile + ".bak"
+	if err := os.WriteFile(backup, data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("plexd config migrate: write backup: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(filepath.Dir(cfgFile), filepath.Base(cfgFile), out, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("plexd config migrate: %w", err)
+	}
+
+	result.Backup = backup
+	writeResult(cmd, result, func(w io.Writer) {
+		fmt.Fprintf(w, "%s: migrated from config_version %d to %d (backup: %s)\n", cfgFile, res.From, res.To, backup)
+		for _, change := range res.Changes {
+			fmt.Fprintf(w, "  %s\n", change)
+		}
+	})
+	return nil
+}
+
+// configOverrides returns the overrides applied on top of the config file:
+// PLEXD_* environment variables, then --set assignments, then the dedicated
+// --api, --mode, --log-level, and --container flags. --log-level and
+// --container only count when passed explicitly, so that their defaults do
+// not mask the file.
+func configOverrides(cmd *cobra.Command) agent.ConfigOverrides {
+	set := append([]string(nil), setFlags...)
+	if apiURL != "" {
+		set = append(set, "api.baseurl="+apiURL)
+	}
+	if mode != "" {
+		set = append(set, "mode="+mode)
+	}
+	if cmd.Flags().Changed("log-level") {
+		set = append(set, "log_level="+logLevel)
+	}
+	if f := cmd.Flags().Lookup("container"); f != nil && f.Changed {
+		set = append(set, "container="+f.Value.String())
+	}
+	return agent.ConfigOverrides{Env: os.Environ(), Set: set}
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testConfigYAML = `
+api:
+  baseurl: "https://example.com"
+registration:
+  datadir: /tmp/plexd
+  tokenvalue: plx_bootstrap_secret
+node_api:
+  datadir: /tmp/plexd
+heartbeat:
+  nodeid: "node-1"
+`
+
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigValidateCommand_Valid(t *testing.T) {
+	path := writeTestConfig(t, testConfigYAML)
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"config", "validate", "--config", path})
+
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), path+": OK") {
+		t.Errorf("output = %q, want OK line", buf.String())
+	}
+}
+
+func TestConfigValidateCommand_Invalid(t *testing.T) {
+	path := writeTestConfig(t, "mode: edge\nlog_levle: debug\n")
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"config", "validate", "--config", path})
+
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatal("expected error for invalid config")
+	}
+	if !strings.Contains(err.Error(), "plexd config validate") {
+		t.Errorf("error should mention 'plexd config validate', got: %v", err)
+	}
+	for _, want := range []string{"line 2", "log_levle", "invalid mode"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestConfigPrintCommand_Redact(t *testing.T) {
+	path := writeTestConfig(t, testConfigYAML)
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"config", "print", "--redact", "--config", path})
+	t.Cleanup(func() { configPrintRedact = false })
+
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "plx_bootstrap_secret") {
+		t.Errorf("redacted output leaks token:\n%s", out)
+	}
+	for _, want := range []string{"tokenvalue: REDACTED", "mode: node", "baseurl: https://example.com"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestConfigCommand_Help(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"config", "--help"})
+
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, sub := range []string{"validate", "print"} {
+		if !strings.Contains(buf.String(), sub) {
+			t.Errorf("help output missing %q subcommand", sub)
+		}
+	}
+}
+
+func TestConfigPrintCommand_EnvAndSetOverrides(t *testing.T) {
+	path := writeTestConfig(t, testConfigYAML+"log_level: error\n")
+	t.Setenv("PLEXD_LOG_LEVEL", "warn")
+	t.Setenv("PLEXD_HEARTBEAT_INTERVAL", "45s")
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"config", "print", "--config", path, "--set", "log_level=debug", "--set", "bridge.ingressenabled=true"})
+	t.Cleanup(func() { setFlags = nil })
+
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"log_level: debug", "interval: 45s", "ingressenabled: true"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestConfigValidateCommand_InvalidOverride(t *testing.T) {
+	path := writeTestConfig(t, testConfigYAML)
+	t.Setenv("PLEXD_HEARTBEAT_INTERVAL", "often")
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"config", "validate", "--config", path})
+
+	if err := rootCmd.Execute(); err == nil {
+		t.Fatal("expected error for invalid environment override")
+	}
+	if !strings.Contains(buf.String(), "PLEXD_HEARTBEAT_INTERVAL") {
+		t.Errorf("output should name the environment variable, got:\n%s", buf.String())
+	}
+}
+
+func TestConfigMigrateCommand(t *testing.T) {
+	legacy := "# my config\napi_url: https://example.com\ntoken_file: /etc/plexd/bootstrap-token\n"
+	path := writeTestConfig(t, legacy)
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"config", "migrate", "--config", path})
+
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "migrated from config_version 0 to 1") {
+		t.Errorf("output = %q, want migration summary", buf.String())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# my config", "config_version: 1", "  baseurl: https://example.com", "  tokenfile: /etc/plexd/bootstrap-token"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("migrated file missing %q:\n%s", want, data)
+		}
+	}
+	backup, err := os.ReadFile(path + ".bak")
+	if err != nil || string(backup) != legacy {
+		t.Errorf("backup = %q, %v; want original content", backup, err)
+	}
+
+	buf.Reset()
+	rootCmd.SetArgs([]string{"config", "migrate", "--config", path})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("second migrate: %v", err)
+	}
+	if !strings.Contains(buf.String(), "already at config_version 1") {
+		t.Errorf("second migrate output = %q", buf.String())
+	}
+}
+
+func TestConfigMigrateCommand_DryRun(t *testing.T) {
+	legacy := "api_url: https://example.com\n"
+	path := writeTestConfig(t, legacy)
+
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"config", "migrate", "--dry-run", "--config", path})
+	t.Cleanup(func() { configMigrateDryRun = false })
+
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "baseurl: https://example.com") {
+		t.Errorf("dry-run output = %q, want migrated document", buf.String())
+	}
+	if data, _ := os.ReadFile(path); string(data) != legacy {
+		t.Errorf("dry run modified the file:\n%s", data)
+	}
+}
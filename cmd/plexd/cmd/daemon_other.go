@@ -0,0 +1,33 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyReload calls fn on every SIGHUP until ctx is cancelled.
+func notifyReload(ctx context.Context, fn func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ch:
+				fn()
+			}
+		}
+	}()
+}
+
+// daemonContext returns a context that is cancelled on SIGTERM or SIGINT.
+func daemonContext(_ *slog.Logger) (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+}
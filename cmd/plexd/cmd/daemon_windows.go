@@ -0,0 +1,77 @@
+//go:build windows
+
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/windows/svc"
+
+	"github.com/plexsphere/plexd/internal/packaging"
+)
+
+// notifyReload is a no-op on Windows, which has no SIGHUP. Reloads are
+// triggered through the node API.
+func notifyReload(_ context.Context, _ func()) {}
+
+// daemonContext returns a context that is cancelled when the Service Control
+// Manager stops the service or, when run interactively, on Ctrl+C.
+// The returned cancel function must be called once shutdown has finished so
+// that the service reports SERVICE_STOPPED.
+func daemonContext(logger *slog.Logger) (context.Context, context.CancelFunc) {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		logger.Warn("detect windows service", "error", err)
+	}
+	if !isService {
+		return signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		if err := svc.Run(packaging.DefaultServiceName, &serviceHandler{cancel: cancel, done: done}); err != nil {
+			logger.Error("windows service failed", "error", err)
+			cancel()
+		}
+	}()
+
+	var once sync.Once
+	return ctx, func() {
+		cancel()
+		once.Do(func() { close(done) })
+	}
+}
+
+// serviceHandler implements svc.Handler for the plexd service.
+type serviceHandler struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Execute reports the service as running and cancels the daemon context on
+// stop or shutdown requests. It returns once the daemon has shut down.
+func (h *serviceHandler) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	const accepted = svc.AcceptStop | svc.AcceptShutdown
+	status <- svc.Status{State: svc.StartPending}
+	status <- svc.Status{State: svc.Running, Accepts: accepted}
+
+	for {
+		select {
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				h.cancel()
+			}
+		case <-h.done:
+			return false, 0
+		}
+	}
+}
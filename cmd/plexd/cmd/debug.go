@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/plexsphere/plexd/internal/logging"
+	"github.com/plexsphere/plexd/internal/nodeapi"
+)
+
+var debugCmd = &cobra.Command{
+	Use:   "debug",
+	Short: "Debug the running agent",
+}
+
+var debugLogLevelCmd = &cobra.Command{
+	Use:   "loglevel [level]",
+	Short: "Show or change log levels without a restart",
+	Long: `Show the log levels of the local agent, or set the level (debug, info, warn,
+error) of one component with --component, or the default level without it.
+--reset makes a component log at the default level again. Changes last until
+the next config reload that changes log_level or log_levels, or the next
+restart.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runDebugLogLevel,
+}
+
+var debugLogsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show the log lines captured by the agent",
+	Long:  "Show the recent log lines the local agent keeps in memory, oldest first. Requires log_capture in the agent config.",
+	Args:  cobra.NoArgs,
+	RunE:  runDebugLogs,
+}
+
+func init() {
+	debugLogLevelCmd.Flags().String("component", "", "Component to change, e.g. api, reconcile, bridge, nodeapi, actions")
+	debugLogLevelCmd.Flags().Bool("reset", false, "Reset --component to the default level")
+	debugLogsCmd.Flags().String("component", "", "Show only the lines of this component")
+	debugLogsCmd.Flags().Int("lines", 0, "Show at most this many of the most recent lines (0 for all)")
+	debugCmd.AddCommand(debugLogLevelCmd)
+	debugCmd.AddCommand(debugLogsCmd)
+	rootCmd.AddCommand(debugCmd)
+}
+
+func runDebugLogLevel(cmd *cobra.Command, args []string) error {
+	component, _ := cmd.Flags().GetString("component")
+	reset, _ := cmd.Flags().GetBool("reset")
+
+	var body []byte
+	var err error
+	switch {
+	case reset && (component == "" || len(args) > 0):
+		return fmt.Errorf("plexd debug loglevel: --reset requires --component and no level")
+	case reset:
+		body, err = socketRequestJSON(defaultSocketPath(), http.MethodPut, "/v1/debug/loglevel", nodeapi.LogLevelRequest{Component: component})
+	case len(args) == 1:
+		if _, perr := logging.ParseLevel(args[0]); perr != nil {
+			return fmt.Errorf("plexd debug loglevel: %w", perr)
+		}
+		body, err = socketRequestJSON(defaultSocketPath(), http.MethodPut, "/v1/debug/loglevel", nodeapi.LogLevelRequest{Component: component, Level: args[0]})
+	default:
+		body, err = socketRequest(defaultSocketPath(), http.MethodGet, "/v1/debug/loglevel")
+	}
+	if err != nil {
+		return fmt.Errorf("plexd debug loglevel: %w", err)
+	}
+
+	var levels logging.LevelSnapshot
+	if err := json.Unmarshal(body, &levels); err != nil {
+		return fmt.Errorf("plexd debug loglevel: parse response: %w", err)
+	}
+	writeResult(cmd, levels, func(w io.Writer) { writeLogLevels(w, levels) })
+	return nil
+}
+
+func runDebugLogs(cmd *cobra.Command, _ []string) error {
+	component, _ := cmd.Flags().GetString("component")
+	lines, _ := cmd.Flags().GetInt("lines")
+
+	logs, err := fetchCapturedLogs(defaultSocketPath(), component, lines)
+	if err != nil {
+		return fmt.Errorf("plexd debug logs: %w", err)
+	}
+	writeResult(cmd, logs, func(w io.Writer) {
+		for _, line := range logs.Lines {
+			fmt.Fprintln(w, line)
+		}
+	})
+	return nil
+}
+
+// fetchCapturedLogs reads the captured log lines of component (all if
+// empty), at most lines of them if lines is positive.
+func fetchCapturedLogs(socketPath, component string, lines int) (nodeapi.CapturedLogs, error) {
+	q := url.Values{}
+	if component != "" {
+		q.Set("component", component)
+	}
+	if lines > 0 {
+		q.Set("lines", strconv.Itoa(lines))
+	}
+	path := "/v1/debug/logs"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	body, err := socketRequest(socketPath, http.MethodGet, path)
+	if err != nil {
+		return nodeapi.CapturedLogs{}, err
+	}
+	var logs nodeapi.CapturedLogs
+	if err := json.Unmarshal(body, &logs); err != nil {
+		return nodeapi.CapturedLogs{}, fmt.Errorf("parse response: %w", err)
+	}
+	return logs, nil
+}
+
+// writeLogLevels prints the default level and one line per component level.
+func writeLogLevels(w io.Writer, levels logging.LevelSnapshot) {
+	fmt.Fprintf(w, "default: %s\n", levels.Default)
+	for _, c := range slices.Sorted(maps.Keys(levels.Components)) {
+		fmt.Fprintf(w, "%s: %s\n", c, levels.Components[c])
+	}
+}
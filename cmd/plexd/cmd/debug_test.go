@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/logging"
+	"github.com/plexsphere/plexd/internal/nodeapi"
+)
+
+// startFakeDebugAgent serves logs at /v1/debug/logs on a Unix socket and
+// records the last query.
+func startFakeDebugAgent(t *testing.T, logs nodeapi.CapturedLogs, query *url.Values) string {
+	t.Helper()
+	socketPath := filepath.Join(t.TempDir(), "api.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/debug/logs", func(w http.ResponseWriter, r *http.Request) {
+		*query = r.URL.Query()
+		_ = json.NewEncoder(w).Encode(logs)
+	})
+	srv := &http.Server{Handler: mux}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { srv.Close() })
+	return socketPath
+}
+
+func TestFetchCapturedLogs(t *testing.T) {
+	var query url.Values
+	socketPath := startFakeDebugAgent(t, nodeapi.CapturedLogs{Size: 100, Lines: []string{"a", "b"}}, &query)
+
+	logs, err := fetchCapturedLogs(socketPath, "bridge", 20)
+	if err != nil {
+		t.Fatalf("fetchCapturedLogs: %v", err)
+	}
+	if logs.Size != 100 || len(logs.Lines) != 2 {
+		t.Errorf("logs = %+v", logs)
+	}
+	if query.Get("component") != "bridge" || query.Get("lines") != "20" {
+		t.Errorf("query = %v", query)
+	}
+}
+
+func TestWriteLogLevels(t *testing.T) {
+	buf := new(bytes.Buffer)
+	writeLogLevels(buf, logging.LevelSnapshot{
+		Default:    "info",
+		Components: map[string]string{"reconcile": "warn", "bridge": "debug"},
+	})
+	if want := "default: info\nbridge: debug\nreconcile: warn\n"; buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 
@@ -10,34 +12,65 @@ import (
 
 	"github.com/plexsphere/plexd/internal/agent"
 	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/nodeapi"
+	"github.com/plexsphere/plexd/internal/packaging"
 	"github.com/plexsphere/plexd/internal/registration"
 )
 
-var deregisterPurge bool
+var (
+	deregisterPurge     bool
+	deregisterForce     bool
+	deregisterUninstall bool
+	deregisterInitSys   string
+)
+
+// deregisterResult is the JSON result of plexd deregister.
+type deregisterResult struct {
+	NodeID      string `json:"node_id"`
+	Uninstalled bool   `json:"uninstalled"`
+	Purged      bool   `json:"purged"`
+}
 
 var deregisterCmd = &cobra.Command{
 	Use:   "deregister",
-	Short: "Deregister this node from the control plane",
-	Long:  "Deregister this node from the control plane.\nWith --purge, also removes local data and config.",
-	RunE:  runDeregister,
+	Short: "Deregister and decommission this node",
+	Long: "Deregister this node from the control plane and decommission it: delete its\n" +
+		"interfaces, routes, ip rules, and nftables tables, and securely wipe its\n" +
+		"identity, cached state and secrets, and bootstrap token.\n" +
+		"The agent must be stopped first.\n" +
+		"With --uninstall, also removes the plexd service and binary.\n" +
+		"With --purge, also removes data_dir (and config dir with --uninstall).",
+	RunE: runDeregister,
 }
 
 func init() {
-	deregisterCmd.Flags().BoolVar(&deregisterPurge, "purge", false, "remove data_dir, token file, and disable systemd unit")
+	deregisterCmd.Flags().BoolVar(&deregisterPurge, "purge", false, "also remove data_dir (and the config dir with --uninstall)")
+	deregisterCmd.Flags().BoolVar(&deregisterForce, "force", false, "decommission locally even if the control plane cannot be reached")
+	deregisterCmd.Flags().BoolVar(&deregisterUninstall, "uninstall", false, "also remove the plexd system service and binary")
+	deregisterCmd.Flags().StringVar(&deregisterInitSys, "init-system", packaging.InitSystemAuto, "init system for --uninstall: auto, systemd, openrc, sysv, scm, or launchd")
+	registerFlagValues(deregisterCmd, "init-system", initSystems...)
 	rootCmd.AddCommand(deregisterCmd)
 }
 
 func runDeregister(cmd *cobra.Command, _ []string) error {
-	cfg, err := agent.ParseConfig(cfgFile)
+	cfg, err := agent.ParseConfig(cfgFile, configOverrides(cmd))
 	if err != nil {
-		return fmt.Errorf("plexd deregister: %w", err)
-	}
-	if apiURL != "" {
-		cfg.API.BaseURL = apiURL
+		return withExitCode(ExitConfig, fmt.Errorf("plexd deregister: %w", err))
 	}
 
 	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
 
+	// Tearing down the network and wiping state under a running agent would
+	// race with its reconcile loop.
+	socketPath := cfg.NodeAPI.SocketPath
+	if socketPath == "" {
+		socketPath = defaultSocketPath()
+	}
+	if conn, err := nodeapi.DialLocal(socketPath); err == nil {
+		conn.Close()
+		return errors.New("plexd deregister: agent is running; stop the plexd service first")
+	}
+
 	// Load identity from disk.
 	identity, err := registration.LoadIdentity(cfg.DataDir)
 	if err != nil {
@@ -51,23 +84,38 @@ func runDeregister(cmd *cobra.Command, _ []string) error {
 	}
 	client.SetAuthToken(identity.NodeSecretKey)
 
-	// Deregister from control plane.
-	if err := client.Deregister(context.Background(), identity.NodeID); err != nil {
+	// Deregister and tear down local state.
+	dec := agent.NewDecommissioner(cfg, client, logger)
+	if err := dec.Decommission(context.Background(), identity.NodeID, agent.DecommissionOptions{Force: deregisterForce}); err != nil {
 		return fmt.Errorf("plexd deregister: %w", err)
 	}
 
-	fmt.Fprintf(cmd.OutOrStdout(), "node %s deregistered\n", identity.NodeID)
+	res := &deregisterResult{NodeID: identity.NodeID}
+	writeResult(cmd, res, func(w io.Writer) {
+		fmt.Fprintf(w, "node %s deregistered and decommissioned\n", identity.NodeID)
+	})
+
+	if deregisterUninstall {
+		initSys, err := packaging.NewInitSystem(deregisterInitSys)
+		if err != nil {
+			return fmt.Errorf("plexd deregister: %w", err)
+		}
+		installer := packaging.NewInstaller(packaging.InstallConfig{DataDir: cfg.DataDir}, initSys, packaging.NewRootChecker(), logger)
+		if err := installer.Uninstall(deregisterPurge); err != nil {
+			return fmt.Errorf("plexd deregister: %w", err)
+		}
+		res.Uninstalled, res.Purged = true, deregisterPurge
+		writeResult(cmd, res, func(w io.Writer) { fmt.Fprintln(w, "plexd uninstalled") })
+		return nil
+	}
 
 	// Purge local data if requested.
 	if deregisterPurge {
 		if err := os.RemoveAll(cfg.DataDir); err != nil {
 			logger.Warn("failed to remove data directory", "path", cfg.DataDir, "error", err)
 		}
-		tokenPath := cfg.Registration.TokenFile
-		if tokenPath != "" {
-			os.Remove(tokenPath)
-		}
-		fmt.Fprintln(cmd.OutOrStdout(), "local data purged")
+		res.Purged = true
+		writeResult(cmd, res, func(w io.Writer) { fmt.Fprintln(w, "local data purged") })
 	}
 
 	return nil
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Inspect and control the control plane event stream",
+}
+
+var eventsStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the state of the event stream",
+	Long:  "Connect to the local agent via Unix socket and show whether the control plane event stream is connected, when the last event arrived, and how often it reconnected.",
+	Args:  cobra.NoArgs,
+	RunE:  runEventsStatus,
+}
+
+var eventsReconnectCmd = &cobra.Command{
+	Use:   "reconnect",
+	Short: "Force the event stream to reconnect",
+	Long: `Ask the local agent to close its event stream connection and open a new one
+at once, resuming from the last event ID. Use it when the stream is connected
+but events stopped arriving. Fails while the stream is not connected.`,
+	Args: cobra.NoArgs,
+	RunE: runEventsReconnect,
+}
+
+func init() {
+	eventsCmd.AddCommand(eventsStatusCmd)
+	eventsCmd.AddCommand(eventsReconnectCmd)
+	rootCmd.AddCommand(eventsCmd)
+}
+
+func runEventsStatus(cmd *cobra.Command, _ []string) error {
+	body, err := socketRequest(defaultSocketPath(), http.MethodGet, "/v1/events/status")
+	if err != nil {
+		return fmt.Errorf("plexd events status: %w", err)
+	}
+	var status api.SSEStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return fmt.Errorf("plexd events status: parse response: %w", err)
+	}
+	writeResult(cmd, status, func(w io.Writer) { writeEventsStatus(w, status) })
+	return nil
+}
+
+func runEventsReconnect(cmd *cobra.Command, _ []string) error {
+	if _, err := socketRequest(defaultSocketPath(), http.MethodPost, "/v1/events/reconnect"); err != nil {
+		return fmt.Errorf("plexd events reconnect: %w", err)
+	}
+	writeResult(cmd, nil, func(w io.Writer) { fmt.Fprintln(w, "event stream reconnecting") })
+	return nil
+}
+
+// writeEventsStatus prints the event stream state.
+func writeEventsStatus(w io.Writer, status api.SSEStatus) {
+	state := "stopped"
+	switch {
+	case status.Connected:
+		state = "connected"
+	case status.Running:
+		state = "reconnecting"
+	}
+	fmt.Fprintf(w, "State:           %s\n", state)
+	if status.ConnectedSince != nil {
+		fmt.Fprintf(w, "Connected since: %s\n", status.ConnectedSince.Local().Format(time.DateTime))
+	}
+	lastEvent := "never"
+	if status.LastEventAgeNano >= 0 {
+		lastEvent = time.Duration(status.LastEventAgeNano).Round(time.Second).String() + " ago"
+	}
+	fmt.Fprintf(w, "Last event:      %s\n", lastEvent)
+	if status.LastEventID != "" {
+		fmt.Fprintf(w, "Last event ID:   %s\n", status.LastEventID)
+	}
+	fmt.Fprintf(w, "Events received: %d\n", status.EventsReceived)
+	fmt.Fprintf(w, "Reconnects:      %d\n", status.Reconnects)
+	if status.Transport != "" {
+		fmt.Fprintf(w, "Transport:       %s\n", status.Transport)
+	}
+	if status.UnexpectedEvents > 0 {
+		fmt.Fprintf(w, "Unexpected:      %d events without handler\n", status.UnexpectedEvents)
+	}
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+func TestWriteEventsStatus(t *testing.T) {
+	since := time.Now().Add(-time.Hour)
+	buf := new(bytes.Buffer)
+	writeEventsStatus(buf, api.SSEStatus{
+		Running:          true,
+		Connected:        true,
+		ConnectedSince:   &since,
+		LastEventAgeNano: int64(90 * time.Second),
+		LastEventID:      "evt-42",
+		EventsReceived:   42,
+		Reconnects:       3,
+		Transport:        api.EventTransportWebSocket,
+	})
+	out := buf.String()
+	for _, want := range []string{"connected", "1m30s ago", "evt-42", "Events received: 42", "Reconnects:      3", "Transport:       websocket"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	writeEventsStatus(buf, api.SSEStatus{Running: true, LastEventAgeNano: -1})
+	out = buf.String()
+	if !strings.Contains(out, "reconnecting") || !strings.Contains(out, "Last event:      never") {
+		t.Errorf("output = %q, want reconnecting with no event", out)
+	}
+}
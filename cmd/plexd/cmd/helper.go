@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/plexsphere/plexd/internal/privhelper"
+	"github.com/plexsphere/plexd/internal/wireguard"
+)
+
+var (
+	helperSocket          string
+	helperInterfacePrefix string
+	helperGroup           string
+)
+
+var helperCmd = &cobra.Command{
+	Use:   "helper",
+	Short: "Run the privileged helper for a rootless agent",
+	Long: "Run the privileged helper that performs WireGuard interface operations\n" +
+		"on behalf of an agent running without root. The helper must run as root\n" +
+		"(or with CAP_NET_ADMIN) and is normally started through systemd socket\n" +
+		"activation by the units written by 'plexd install --rootless'.",
+	Annotations: map[string]string{textOnlyAnnotation: ""},
+	RunE:        runHelper,
+}
+
+func init() {
+	helperCmd.Flags().StringVar(&helperSocket, "socket", privhelper.DefaultSocketPath, "Unix socket path (ignored under socket activation)")
+	helperCmd.Flags().StringVar(&helperInterfacePrefix, "interface-prefix", privhelper.DefaultInterfacePrefix, "only manage interfaces with this name prefix")
+	helperCmd.Flags().StringVar(&helperGroup, "group", privhelper.DefaultGroup, "group granted access to the socket")
+	rootCmd.AddCommand(helperCmd)
+}
+
+func runHelper(_ *cobra.Command, _ []string) error {
+	logger := setupLogger(logLevel)
+
+	cfg := privhelper.Config{
+		Mode:            privhelper.ModeDirect,
+		SocketPath:      helperSocket,
+		InterfacePrefix: helperInterfacePrefix,
+		Group:           helperGroup,
+	}
+	cfg.ApplyDefaults()
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("plexd helper: %w", err)
+	}
+
+	if !privhelper.HasNetAdmin() {
+		return errors.New("plexd helper: requires root or CAP_NET_ADMIN")
+	}
+
+	ctrl, err := wireguard.NewDefaultController(logger)
+	if err != nil {
+		return fmt.Errorf("plexd helper: %w", err)
+	}
+
+	ln, err := privhelper.Listen(cfg)
+	if err != nil {
+		return fmt.Errorf("plexd helper: %w", err)
+	}
+
+	ctx, stop := daemonContext(logger)
+	defer stop()
+
+	if err := privhelper.NewServer(ctrl, cfg, logger).Serve(ctx, ln); err != nil {
+		return fmt.Errorf("plexd helper: %w", err)
+	}
+	return nil
+}
@@ -45,8 +45,7 @@ func runHooksList(cmd *cobra.Command, _ []string) error {
 	if err != nil {
 		return fmt.Errorf("plexd hooks list: %w", err)
 	}
-	fmt.Fprintln(cmd.OutOrStdout(), "hook listing not yet available")
-	return nil
+	return writeNotAvailable(cmd, "hook listing")
 }
 
 func runHooksVerify(cmd *cobra.Command, _ []string) error {
@@ -54,8 +53,7 @@ func runHooksVerify(cmd *cobra.Command, _ []string) error {
 	if err != nil {
 		return fmt.Errorf("plexd hooks verify: %w", err)
 	}
-	fmt.Fprintln(cmd.OutOrStdout(), "hook verification not yet available")
-	return nil
+	return writeNotAvailable(cmd, "hook verification")
 }
 
 func runHooksReload(cmd *cobra.Command, _ []string) error {
@@ -63,6 +61,5 @@ func runHooksReload(cmd *cobra.Command, _ []string) error {
 	if err != nil {
 		return fmt.Errorf("plexd hooks reload: %w", err)
 	}
-	fmt.Fprintln(cmd.OutOrStdout(), "hook reload not yet available")
-	return nil
+	return writeNotAvailable(cmd, "hook reload")
 }
@@ -1,24 +1,52 @@
 package cmd
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/plexsphere/plexd/internal/agent"
 	"github.com/plexsphere/plexd/internal/packaging"
 )
 
 var (
-	installAPIURL    string
-	installToken     string
-	installTokenFile string
+	installAPIURL        string
+	installToken         string
+	installTokenFile     string
+	installTokenSource   string
+	installInitSys       string
+	installRootless      bool
+	installUser          string
+	installHostname      string
+	installMetadata      []string
+	installIngress       []int
+	installDryRun        bool
+	installSkipPreflight bool
+	installSkipSecurity  bool
+	installOffline       bool
+	installBundle        string
+	installBundleKey     string
 )
 
+// installResult is the JSON result of plexd install. Plan is set with
+// --dry-run; Installed is true once the service is installed.
+type installResult struct {
+	DryRun    bool                      `json:"dry_run"`
+	Preflight packaging.PreflightReport `json:"preflight"`
+	Plan      []packaging.PlannedChange `json:"plan,omitempty"`
+	Installed bool                      `json:"installed"`
+}
+
 var installCmd = &cobra.Command{
 	Use:   "install",
-	Short: "Install plexd as a systemd service",
+	Short: "Install plexd as a system service (systemd, OpenRC, or SysV init)",
 	RunE:  runInstall,
 }
 
@@ -26,24 +54,144 @@ func init() {
 	installCmd.Flags().StringVar(&installAPIURL, "api-url", "", "control plane API URL")
 	installCmd.Flags().StringVar(&installToken, "token", "", "bootstrap token value")
 	installCmd.Flags().StringVar(&installTokenFile, "token-file", "", "path to bootstrap token file")
+	installCmd.Flags().StringVar(&installTokenSource, "token-source", "", "secret store reference of the bootstrap token, e.g. vault:secret/plexd")
+	installCmd.Flags().StringVar(&installInitSys, "init-system", packaging.InitSystemAuto, "init system: auto, systemd, openrc, sysv, scm, or launchd")
+	registerFlagValues(installCmd, "init-system", initSystems...)
+	installCmd.Flags().BoolVar(&installRootless, "rootless", false, "run the agent as an unprivileged user with a privileged helper (systemd only)")
+	installCmd.Flags().StringVar(&installUser, "user", packaging.DefaultUser, "service user for --rootless (must exist)")
+	installCmd.Flags().StringVar(&installHostname, "hostname-override", "", "hostname to register with instead of the system hostname")
+	installCmd.Flags().StringArrayVar(&installMetadata, "metadata", nil, "registration metadata as key=value (repeatable)")
+	installCmd.Flags().IntSliceVar(&installIngress, "ingress-port", nil, "public TCP port for ingress rules to check in preflight (repeatable)")
+	installCmd.Flags().BoolVar(&installDryRun, "dry-run", false, "run preflight checks and show planned changes without applying them")
+	installCmd.Flags().BoolVar(&installSkipPreflight, "skip-preflight", false, "skip preflight checks")
+	installCmd.Flags().BoolVar(&installSkipSecurity, "skip-security-policy", false, "do not install the SELinux policy module or AppArmor profile")
+	installCmd.Flags().BoolVar(&installOffline, "offline", false, "install from --bundle without network access")
+	installCmd.Flags().StringVar(&installBundle, "bundle", "", "path to an offline install bundle (tar)")
+	installCmd.Flags().StringVar(&installBundleKey, "bundle-key", "", "base64 Ed25519 public key the bundle is signed with")
 	rootCmd.AddCommand(installCmd)
 }
 
+// checkNotInContainer refuses to install a service inside a container
+// without an init system, unless --init-system names one explicitly.
+func checkNotInContainer(cmd *cobra.Command) error {
+	if cmd.Flags().Changed("init-system") || !agent.RunningInContainer() {
+		return nil
+	}
+	return errors.New("running in a container without an init system; run 'plexd up --container' as the container entrypoint instead")
+}
+
 func runInstall(cmd *cobra.Command, _ []string) error {
+	if err := checkNotInContainer(cmd); err != nil {
+		return fmt.Errorf("plexd install: %w", err)
+	}
 	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
 
+	metadata, err := parseMetadata(installMetadata)
+	if err != nil {
+		return fmt.Errorf("plexd install: %w", err)
+	}
+
 	cfg := packaging.InstallConfig{
-		APIBaseURL: installAPIURL,
-		TokenValue: installToken,
-		TokenFile:  installTokenFile,
+		APIBaseURL:   installAPIURL,
+		TokenValue:   installToken,
+		TokenFile:    installTokenFile,
+		TokenSource:  installTokenSource,
+		Rootless:     installRootless,
+		User:         installUser,
+		Hostname:     installHostname,
+		Metadata:     metadata,
+		IngressPorts: installIngress,
+	}
+
+	initSys, err := packaging.NewInitSystem(installInitSys)
+	if err != nil {
+		return fmt.Errorf("plexd install: %w", err)
+	}
+
+	installer := packaging.NewInstaller(cfg, initSys, packaging.NewRootChecker(), logger)
+	if !jsonOutput() {
+		installer.SetReportWriter(cmd.OutOrStdout())
+	}
+	if installOffline || installBundle != "" {
+		bundle, err := openInstallBundle(installOffline, installBundle, installBundleKey)
+		if err != nil {
+			return fmt.Errorf("plexd install: %w", err)
+		}
+		installer.SetBundle(bundle)
+	}
+	if installSkipPreflight {
+		installer.SetPreflightChecks(nil)
+	}
+	if installSkipSecurity {
+		installer.SetSecurityModules(nil)
 	}
 
-	installer := packaging.NewInstaller(cfg, packaging.NewSystemdController(), packaging.NewRootChecker(), logger)
+	res := installResult{DryRun: installDryRun}
+	if installDryRun {
+		err = installer.DryRun()
+		res.Preflight = installer.Preflight()
+		if jsonOutput() && (err == nil || errors.Is(err, packaging.ErrPreflightFailed)) {
+			res.Plan, _ = installer.Plan()
+		}
+		// The text report was written by DryRun.
+		writeResult(cmd, res, func(io.Writer) {})
+		return installError(err)
+	}
+
+	err = installer.Install()
+	res.Preflight = installer.Preflight()
+	res.Installed = err == nil
+	writeResult(cmd, res, func(w io.Writer) {
+		if res.Installed {
+			fmt.Fprintln(w, "plexd installed successfully")
+		}
+	})
+	return installError(err)
+}
 
-	if err := installer.Install(); err != nil {
+// installError wraps an error of Install or DryRun, with exit code
+// ExitPreflight if a preflight check failed.
+func installError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, packaging.ErrPreflightFailed):
+		return withExitCode(ExitPreflight, fmt.Errorf("plexd install: %w", err))
+	default:
 		return fmt.Errorf("plexd install: %w", err)
 	}
+}
 
-	fmt.Fprintln(cmd.OutOrStdout(), "plexd installed successfully")
-	return nil
+// openInstallBundle opens and verifies the offline install bundle.
+func openInstallBundle(offline bool, path, key string) (*packaging.Bundle, error) {
+	switch {
+	case !offline:
+		return nil, errors.New("--bundle requires --offline")
+	case path == "":
+		return nil, errors.New("--offline requires --bundle")
+	case key == "":
+		return nil, errors.New("--offline requires --bundle-key")
+	}
+	pub, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --bundle-key: %w", err)
+	}
+	return packaging.OpenBundle(path, ed25519.PublicKey(pub))
+}
+
+// parseMetadata parses key=value pairs. The value may contain "="; a later
+// pair overrides an earlier one with the same key.
+func parseMetadata(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	metadata := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --metadata %q: want key=value", pair)
+		}
+		metadata[key] = value
+	}
+	return metadata, nil
 }
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestParseMetadata(t *testing.T) {
+	got, err := parseMetadata([]string{"env=prod", "query=a=b", "env=staging", "empty="})
+	if err != nil {
+		t.Fatalf("parseMetadata: %v", err)
+	}
+	want := map[string]string{"env": "staging", "query": "a=b", "empty": ""}
+	if !maps.Equal(got, want) {
+		t.Errorf("parseMetadata() = %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{"env", "=prod"} {
+		if _, err := parseMetadata([]string{bad}); err == nil {
+			t.Errorf("parseMetadata(%q) = nil error, want error", bad)
+		}
+	}
+}
+
+func TestOpenInstallBundle_FlagErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		offline bool
+		path    string
+		key     string
+		want    string
+	}{
+		{"bundle without offline", false, "b.tar", "k", "--bundle requires --offline"},
+		{"offline without bundle", true, "", "k", "--offline requires --bundle"},
+		{"offline without key", true, "b.tar", "", "--offline requires --bundle-key"},
+		{"bad key", true, "b.tar", "not base64!", "invalid --bundle-key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := openInstallBundle(tt.offline, tt.path, tt.key)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("openInstallBundle() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 
@@ -13,7 +14,16 @@ import (
 	"github.com/plexsphere/plexd/internal/registration"
 )
 
-var joinTokenFile string
+var (
+	joinTokenFile   string
+	joinTokenSource string
+)
+
+// joinResult is the JSON result of plexd join.
+type joinResult struct {
+	NodeID string `json:"node_id"`
+	MeshIP string `json:"mesh_ip"`
+}
 
 var joinCmd = &cobra.Command{
 	Use:   "join",
@@ -24,16 +34,14 @@ var joinCmd = &cobra.Command{
 
 func init() {
 	joinCmd.Flags().StringVar(&joinTokenFile, "token-file", "", "path to bootstrap token file")
+	joinCmd.Flags().StringVar(&joinTokenSource, "token-source", "", "secret store reference of the bootstrap token, e.g. vault:secret/plexd")
 	rootCmd.AddCommand(joinCmd)
 }
 
 func runJoin(cmd *cobra.Command, _ []string) error {
-	cfg, err := agent.ParseConfig(cfgFile)
+	cfg, err := agent.ParseConfig(cfgFile, configOverrides(cmd))
 	if err != nil {
-		return fmt.Errorf("plexd join: %w", err)
-	}
-	if apiURL != "" {
-		cfg.API.BaseURL = apiURL
+		return withExitCode(ExitConfig, fmt.Errorf("plexd join: %w", err))
 	}
 
 	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
@@ -48,14 +56,20 @@ func runJoin(cmd *cobra.Command, _ []string) error {
 	if joinTokenFile != "" {
 		regCfg.TokenFile = joinTokenFile
 	}
+	if joinTokenSource != "" {
+		regCfg.TokenSource = joinTokenSource
+	}
 
 	registrar := registration.NewRegistrar(client, regCfg, logger)
 
 	identity, err := registrar.Register(context.Background())
 	if err != nil {
-		return fmt.Errorf("plexd join: registration: %w", err)
+		return withExitCode(ExitRegistration, fmt.Errorf("plexd join: registration: %w", err))
 	}
 
-	fmt.Fprintf(cmd.OutOrStdout(), "node_id: %s\nmesh_ip: %s\n", identity.NodeID, identity.MeshIP)
+	res := joinResult{NodeID: identity.NodeID, MeshIP: identity.MeshIP}
+	writeResult(cmd, res, func(w io.Writer) {
+		fmt.Fprintf(w, "node_id: %s\nmesh_ip: %s\n", res.NodeID, res.MeshIP)
+	})
 	return nil
 }
@@ -11,10 +11,11 @@ import (
 var logsFollow bool
 
 var logsCmd = &cobra.Command{
-	Use:   "logs",
-	Short: "Stream agent logs",
-	Long:  "Stream plexd agent logs from journald. Falls back to a helpful message if journald is unavailable.",
-	RunE:  runLogs,
+	Use:         "logs",
+	Short:       "Stream agent logs",
+	Long:        "Stream plexd agent logs from journald. Falls back to a helpful message if journald is unavailable.",
+	Annotations: map[string]string{textOnlyAnnotation: ""},
+	RunE:        runLogs,
 }
 
 var logStatusCmd = &cobra.Command{
@@ -57,6 +58,5 @@ func runLogStatus(cmd *cobra.Command, _ []string) error {
 	if err != nil {
 		return fmt.Errorf("plexd log-status: %w", err)
 	}
-	fmt.Fprintln(cmd.OutOrStdout(), "log forwarding status not yet available")
-	return nil
+	return writeNotAvailable(cmd, "log forwarding status")
 }
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/plexsphere/plexd/internal/meshdiag"
+	"github.com/plexsphere/plexd/internal/nodeapi"
+	"github.com/plexsphere/plexd/internal/pathsel"
+)
+
+var meshCmd = &cobra.Command{
+	Use:   "mesh",
+	Short: "Mesh diagnostics",
+}
+
+var meshPeersCmd = &cobra.Command{
+	Use:   "peers",
+	Short: "Show mesh peer reachability",
+	Long:  "Connect to the local agent via Unix socket and show which mesh peers answered the last probe round and which path each peer is reached over.",
+	RunE:  runMeshPeers,
+}
+
+func init() {
+	meshCmd.AddCommand(meshPeersCmd)
+	rootCmd.AddCommand(meshCmd)
+}
+
+func runMeshPeers(cmd *cobra.Command, _ []string) error {
+	m, err := fetchMeshMatrix(defaultSocketPath())
+	if err != nil {
+		return fmt.Errorf("plexd mesh peers: %w", err)
+	}
+	// Path selection may be disabled; peers are then shown without a path.
+	paths, _ := fetchMeshPaths(defaultSocketPath())
+	writeResult(cmd, m, func(w io.Writer) { writeMeshPeers(w, m, paths, time.Now()) })
+	return nil
+}
+
+// fetchMeshMatrix reads the reachability matrix from the agent's report entry.
+func fetchMeshMatrix(socketPath string) (meshdiag.Matrix, error) {
+	var m meshdiag.Matrix
+	found, err := fetchReportPayload(socketPath, meshdiag.ReportKey, &m)
+	if err != nil {
+		return m, err
+	}
+	if !found {
+		return m, fmt.Errorf("no probe results yet (mesh diagnostics disabled or still starting)")
+	}
+	return m, nil
+}
+
+// fetchMeshPaths reads the selected path of every peer from the agent's
+// path selection report, keyed by peer ID.
+func fetchMeshPaths(socketPath string) (map[string]pathsel.PeerPath, error) {
+	var r pathsel.Report
+	found, err := fetchReportPayload(socketPath, pathsel.ReportKey, &r)
+	if err != nil || !found {
+		return nil, err
+	}
+	paths := make(map[string]pathsel.PeerPath, len(r.Peers))
+	for _, p := range r.Peers {
+		paths[p.PeerID] = p
+	}
+	return paths, nil
+}
+
+// fetchReportPayload decodes the payload of the agent's report entry at key
+// into v. found is false if the entry does not exist.
+func fetchReportPayload(socketPath, key string, v any) (found bool, err error) {
+	resp, err := socketGet(socketPath, "/v1/state/report/"+key)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var entry nodeapi.ReportEntry
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return false, fmt.Errorf("parse response: %w", err)
+	}
+	if err := json.Unmarshal(entry.Payload, v); err != nil {
+		return false, fmt.Errorf("parse report %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// writeMeshPeers prints one line per peer with the kind of its selected
+// path, or "-" if paths has none. Ages are relative to now.
+func writeMeshPeers(w io.Writer, m meshdiag.Matrix, paths map[string]pathsel.PeerPath, now time.Time) {
+	reachable := 0
+	for _, p := range m.Peers {
+		if p.Reachable {
+			reachable++
+		}
+	}
+	fmt.Fprintf(w, "Peers reachable: %d/%d (probed %s ago)\n\n", reachable, len(m.Peers), meshAge(now, m.UpdatedAt))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PEER\tMESH IP\tSTATUS\tRTT\tPATH\tLAST SUCCESS")
+	for _, p := range m.Peers {
+		status, rtt, path, last := "unreachable", "-", "-", "never"
+		if p.Reachable {
+			status = "reachable"
+			rtt = time.Duration(p.RTTNano).Round(10 * time.Microsecond).String()
+		}
+		if pp, ok := paths[p.PeerID]; ok && pp.Kind != "" {
+			path = string(pp.Kind)
+		}
+		if p.LastSuccess != nil {
+			last = meshAge(now, *p.LastSuccess) + " ago"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", p.PeerID, p.MeshIP, status, rtt, path, last)
+	}
+	tw.Flush()
+}
+
+// meshAge formats the time since t in whole seconds.
+func meshAge(now, t time.Time) string {
+	return now.Sub(t).Round(time.Second).String()
+}
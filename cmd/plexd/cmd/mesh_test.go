@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/meshdiag"
+	"github.com/plexsphere/plexd/internal/nodeapi"
+	"github.com/plexsphere/plexd/internal/pathsel"
+)
+
+func TestMeshPeersCommand_AgentNotRunning(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"mesh", "peers"})
+
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatal("expected error when agent is not running")
+	}
+	if !strings.Contains(err.Error(), "plexd mesh peers") {
+		t.Errorf("error should mention 'plexd mesh peers', got: %v", err)
+	}
+}
+
+// startFakeMeshAgent serves entry at the mesh report key on a Unix socket.
+// A nil entry is served as 404.
+func startFakeMeshAgent(t *testing.T, entry *nodeapi.ReportEntry) string {
+	t.Helper()
+	return startFakeReportAgent(t, meshdiag.ReportKey, entry)
+}
+
+// startFakeReportAgent serves entry at the report key on a Unix socket.
+// A nil entry is served as 404.
+func startFakeReportAgent(t *testing.T, key string, entry *nodeapi.ReportEntry) string {
+	t.Helper()
+	socketPath := filepath.Join(t.TempDir(), "api.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/state/report/"+key, func(w http.ResponseWriter, _ *http.Request) {
+		if entry == nil {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "not found"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(entry)
+	})
+	srv := &http.Server{Handler: mux}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { srv.Close() })
+	return socketPath
+}
+
+func TestFetchMeshMatrix(t *testing.T) {
+	payload, _ := json.Marshal(meshdiag.Matrix{
+		NodeID: "node-1",
+		Peers:  []meshdiag.PeerStatus{{PeerID: "peer-a", MeshIP: "10.0.0.2", Reachable: true}},
+	})
+	socketPath := startFakeMeshAgent(t, &nodeapi.ReportEntry{Key: meshdiag.ReportKey, Payload: payload})
+
+	m, err := fetchMeshMatrix(socketPath)
+	if err != nil {
+		t.Fatalf("fetchMeshMatrix: %v", err)
+	}
+	if m.NodeID != "node-1" || len(m.Peers) != 1 || m.Peers[0].PeerID != "peer-a" {
+		t.Errorf("matrix = %+v", m)
+	}
+}
+
+func TestFetchMeshMatrix_NotFound(t *testing.T) {
+	socketPath := startFakeMeshAgent(t, nil)
+
+	_, err := fetchMeshMatrix(socketPath)
+	if err == nil || !strings.Contains(err.Error(), "no probe results yet") {
+		t.Errorf("err = %v, want no probe results yet", err)
+	}
+}
+
+func TestFetchMeshPaths(t *testing.T) {
+	payload, _ := json.Marshal(pathsel.Report{
+		NodeID: "node-1",
+		Peers:  []pathsel.PeerPath{{PeerID: "peer-a", Endpoint: "127.0.0.1:40000", Kind: pathsel.KindFallback}},
+	})
+	socketPath := startFakeReportAgent(t, pathsel.ReportKey, &nodeapi.ReportEntry{Key: pathsel.ReportKey, Payload: payload})
+
+	paths, err := fetchMeshPaths(socketPath)
+	if err != nil {
+		t.Fatalf("fetchMeshPaths: %v", err)
+	}
+	if p, ok := paths["peer-a"]; !ok || p.Kind != pathsel.KindFallback {
+		t.Errorf("paths = %+v", paths)
+	}
+}
+
+func TestFetchMeshPaths_NotFound(t *testing.T) {
+	socketPath := startFakeReportAgent(t, pathsel.ReportKey, nil)
+
+	paths, err := fetchMeshPaths(socketPath)
+	if err != nil || paths != nil {
+		t.Errorf("fetchMeshPaths = %v, %v, want no paths and no error", paths, err)
+	}
+}
+
+func TestWriteMeshPeers(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	lastA := now.Add(-10 * time.Second)
+	lastB := now.Add(-5 * time.Minute)
+	m := meshdiag.Matrix{
+		NodeID:    "node-1",
+		UpdatedAt: now.Add(-10 * time.Second),
+		Peers: []meshdiag.PeerStatus{
+			{PeerID: "peer-a", MeshIP: "10.0.0.2", Reachable: true, RTTNano: int64(1234 * time.Microsecond), LastSuccess: &lastA},
+			{PeerID: "peer-b", MeshIP: "10.0.0.3", RTTNano: -1, LastSuccess: &lastB},
+			{PeerID: "peer-c", MeshIP: "10.0.0.4", RTTNano: -1},
+		},
+	}
+
+	paths := map[string]pathsel.PeerPath{
+		"peer-a": {PeerID: "peer-a", Kind: pathsel.KindLAN},
+		"peer-b": {PeerID: "peer-b", Kind: pathsel.KindFallback},
+	}
+
+	buf := new(bytes.Buffer)
+	writeMeshPeers(buf, m, paths, now)
+	out := buf.String()
+
+	for _, want := range []string{
+		"Peers reachable: 1/3 (probed 10s ago)",
+		"PEER",
+		"peer-a  10.0.0.2  reachable    1.23ms  lan       10s ago",
+		"peer-b  10.0.0.3  unreachable  -       fallback  5m0s ago",
+		"peer-c  10.0.0.4  unreachable  -       -         never",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+// Values of the --output flag.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// Exit codes of the plexd binary. Provisioning pipelines branch on them, so
+// existing codes must never be renumbered or reused.
+const (
+	// ExitOK means the command succeeded.
+	ExitOK = 0
+	// ExitFailure means the command failed for a reason without a more
+	// specific code.
+	ExitFailure = 1
+	// ExitUsage means invalid flags or an invalid --output value.
+	ExitUsage = 2
+	// ExitConfig means the configuration file could not be read or is
+	// invalid.
+	ExitConfig = 3
+	// ExitPreflight means an install preflight check failed.
+	ExitPreflight = 4
+	// ExitAgentUnavailable means the local agent is not running or its
+	// node API socket cannot be reached.
+	ExitAgentUnavailable = 5
+	// ExitRegistration means registration with the control plane failed.
+	ExitRegistration = 6
+	// ExitIdentityRejected means plexd up halted because the control plane
+	// kept rejecting the node identity.
+	ExitIdentityRejected = 7
+)
+
+// textOnlyAnnotation marks commands that stream output or run as a daemon
+// and therefore reject --output json.
+const textOnlyAnnotation = "plexd.output.text-only"
+
+// outputFormat is the value of the --output flag.
+var outputFormat = outputText
+
+// cmdResult is the result of the running command, reported with
+// writeResult and written by Execute with --output json.
+var cmdResult any
+
+// jsonResult is the document every command writes to stdout with
+// --output json, whether it succeeded or not. Fields are only ever added.
+type jsonResult struct {
+	// Command is the full command path, e.g. "plexd install".
+	Command string `json:"command"`
+	// OK is true if the command succeeded.
+	OK bool `json:"ok"`
+	// ExitCode is the process exit code.
+	ExitCode int `json:"exit_code"`
+	// Result is the command-specific result. It may be set on failure when
+	// the command produced partial results, such as the preflight report
+	// of a failed install.
+	Result any `json:"result"`
+	// Error is the error message on failure.
+	Error string `json:"error,omitempty"`
+}
+
+// exitError attaches an exit code to an error.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// withExitCode returns err with the given exit code, or nil if err is nil.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// ExitCode returns the process exit code for an error returned by Execute.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var ee *exitError
+	if errors.As(err, &ee) {
+		return ee.code
+	}
+	return ExitFailure
+}
+
+// jsonOutput reports whether --output json is in effect.
+func jsonOutput() bool {
+	return outputFormat == outputJSON
+}
+
+// writeResult reports the command's result: with --output json, v becomes
+// the result field of the JSON document; otherwise text writes the human
+// readable form to the command's output.
+func writeResult(cmd *cobra.Command, v any, text func(w io.Writer)) {
+	if jsonOutput() {
+		cmdResult = v
+		return
+	}
+	text(cmd.OutOrStdout())
+}
+
+// writeNotAvailable reports that what is not yet served by the agent. With
+// --output json it is an error, so that pipelines do not mistake the
+// placeholder for an empty result.
+func writeNotAvailable(cmd *cobra.Command, what string) error {
+	if jsonOutput() {
+		return fmt.Errorf("%s: %s not yet available", cmd.CommandPath(), what)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "%s not yet available\n", what)
+	return nil
+}
+
+// checkOutputFormat validates --output for cmd. With json it also silences
+// cobra's error and usage printing, so that stdout carries only the JSON
+// document.
+func checkOutputFormat(cmd *cobra.Command) error {
+	switch outputFormat {
+	case outputText:
+		return nil
+	case outputJSON:
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+		if _, ok := cmd.Annotations[textOnlyAnnotation]; ok {
+			return withExitCode(ExitUsage, fmt.Errorf("%s: --output json is not supported", cmd.CommandPath()))
+		}
+		return nil
+	default:
+		return withExitCode(ExitUsage, fmt.Errorf("invalid --output %q (valid: text, json)", outputFormat))
+	}
+}
+
+// writeJSONResult writes the JSON document for the executed command.
+func writeJSONResult(w io.Writer, cmd *cobra.Command, err error) error {
+	res := jsonResult{
+		Command:  cmd.CommandPath(),
+		OK:       err == nil,
+		ExitCode: ExitCode(err),
+		Result:   cmdResult,
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// executeJSON runs the CLI with args and --output json and decodes the JSON
+// document written to stdout.
+func executeJSON(t *testing.T, args ...string) (jsonResult, json.RawMessage, error) {
+	t.Helper()
+	t.Cleanup(func() { outputFormat = outputText })
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	rootCmd.SetOut(stdout)
+	rootCmd.SetErr(stderr)
+	rootCmd.SetArgs(append(args, "--output", "json"))
+	err := Execute()
+
+	var doc struct {
+		jsonResult
+		Result json.RawMessage `json:"result"`
+	}
+	if derr := json.Unmarshal(stdout.Bytes(), &doc); derr != nil {
+		t.Fatalf("stdout is not a JSON document: %v\n%s", derr, stdout.String())
+	}
+	return doc.jsonResult, doc.Result, err
+}
+
+func TestOutputJSON_ConfigValidate(t *testing.T) {
+	path := writeTestConfig(t, testConfigYAML)
+
+	doc, raw, err := executeJSON(t, "config", "validate", "--config", path)
+	if err != nil {
+		t.Fatalf("Execute() = %v", err)
+	}
+	if doc.Command != "plexd config validate" || !doc.OK || doc.ExitCode != ExitOK || doc.Error != "" {
+		t.Errorf("document = %+v", doc)
+	}
+	var res configValidateResult
+	if err := json.Unmarshal(raw, &res); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Valid || res.File != path || res.Errors == nil || len(res.Errors) != 0 {
+		t.Errorf("result = %+v, want valid with empty errors", res)
+	}
+}
+
+func TestOutputJSON_ConfigValidateInvalid(t *testing.T) {
+	path := writeTestConfig(t, "mode: edge\nlog_levle: debug\n")
+
+	doc, raw, err := executeJSON(t, "config", "validate", "--config", path)
+	if ExitCode(err) != ExitConfig {
+		t.Fatalf("ExitCode(%v) = %d, want %d", err, ExitCode(err), ExitConfig)
+	}
+	if doc.OK || doc.ExitCode != ExitConfig || doc.Error == "" {
+		t.Errorf("document = %+v", doc)
+	}
+	var res configValidateResult
+	if err := json.Unmarshal(raw, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Valid || len(res.Errors) < 2 {
+		t.Errorf("result = %+v, want invalid with errors", res)
+	}
+}
+
+func TestOutputJSON_AgentNotRunning(t *testing.T) {
+	doc, raw, err := executeJSON(t, "status")
+	if ExitCode(err) != ExitAgentUnavailable {
+		t.Fatalf("ExitCode(%v) = %d, want %d", err, ExitCode(err), ExitAgentUnavailable)
+	}
+	if doc.Command != "plexd status" || doc.OK || doc.ExitCode != ExitAgentUnavailable {
+		t.Errorf("document = %+v", doc)
+	}
+	if string(raw) != "null" {
+		t.Errorf("result = %s, want null", raw)
+	}
+}
+
+func TestOutputJSON_TextOnlyCommand(t *testing.T) {
+	doc, _, err := executeJSON(t, "helper")
+	if ExitCode(err) != ExitUsage || doc.ExitCode != ExitUsage {
+		t.Fatalf("ExitCode(%v) = %d, document = %+v, want %d", err, ExitCode(err), doc, ExitUsage)
+	}
+}
+
+func TestOutputJSON_PlaceholderFails(t *testing.T) {
+	doc, _, err := executeJSON(t, "policies")
+	if err == nil || doc.OK {
+		t.Fatalf("Execute() = %v, document = %+v, want failure", err, doc)
+	}
+}
+
+func TestOutput_InvalidFormat(t *testing.T) {
+	t.Cleanup(func() { outputFormat = outputText })
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"config", "validate", "--output", "yaml"})
+
+	if err := Execute(); ExitCode(err) != ExitUsage {
+		t.Fatalf("ExitCode(%v) = %d, want %d", err, ExitCode(err), ExitUsage)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "nil", err: nil, want: ExitOK},
+		{name: "plain", err: errors.New("boom"), want: ExitFailure},
+		{name: "coded", err: withExitCode(ExitPreflight, errors.New("boom")), want: ExitPreflight},
+		{name: "wrapped", err: fmt.Errorf("plexd state: %w", agentUnavailable("/x.sock", errors.New("refused"))), want: ExitAgentUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -23,6 +23,5 @@ func runPeers(cmd *cobra.Command, _ []string) error {
 		return fmt.Errorf("plexd peers: %w", err)
 	}
 	// Peer listing will be wired to a dedicated endpoint in a future iteration.
-	fmt.Fprintln(cmd.OutOrStdout(), "peer listing not yet available")
-	return nil
+	return writeNotAvailable(cmd, "peer listing")
 }
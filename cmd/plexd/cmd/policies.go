@@ -23,6 +23,5 @@ func runPolicies(cmd *cobra.Command, _ []string) error {
 		return fmt.Errorf("plexd policies: %w", err)
 	}
 	// Policy listing will be wired to a dedicated endpoint in a future iteration.
-	fmt.Fprintln(cmd.OutOrStdout(), "policy listing not yet available")
-	return nil
+	return writeNotAvailable(cmd, "policy listing")
 }
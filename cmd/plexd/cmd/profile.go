@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/plexsphere/plexd/internal/agent"
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/nodeapi"
+	"github.com/plexsphere/plexd/internal/privhelper"
+	"github.com/plexsphere/plexd/internal/reconcile"
+	"github.com/plexsphere/plexd/internal/registration"
+)
+
+// runProfile runs an additional mesh profile until ctx is cancelled: it
+// registers with the profile's control plane and runs the profile's event
+// stream, reconciler, and heartbeat. The profile's state is served by
+// nodeAPISrv under /v1/profiles/{name}/state. A registration failure stops
+// only this profile. When the profile's control plane requests
+// decommissioning, the profile is deregistered and its identity and state
+// are wiped; the other meshes keep running. When it keeps rejecting the
+// profile's identity, the profile halts with errIdentityRejected, or
+// registers anew and returns errReregistered so that it is run again.
+func runProfile(ctx context.Context, p agent.ProfileConfig, nodeAPICfg nodeapi.Config, nodeAPISrv *nodeapi.Server, priv privhelper.Resolution, logger *slog.Logger) error {
+	logger = logger.With("profile", p.Name)
+
+	client, err := api.NewControlPlane(p.API, buildVersion, logger)
+	if err != nil {
+		return fmt.Errorf("profile %s: create client: %w", p.Name, err)
+	}
+	registrar := registration.NewRegistrar(client, p.Registration, logger)
+	identity, err := registrar.Register(ctx)
+	if err != nil {
+		return fmt.Errorf("profile %s: registration: %w", p.Name, err)
+	}
+	logger.Info("profile registered",
+		"node_id", identity.NodeID,
+		"mesh_ip", identity.MeshIP,
+	)
+	client.SetAuthToken(identity.NodeSecretKey)
+
+	sigKey, err := base64.StdEncoding.DecodeString(identity.SigningPublicKey)
+	if err != nil {
+		return fmt.Errorf("profile %s: decode signing key: %w", p.Name, err)
+	}
+	if len(sigKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("profile %s: invalid signing key length: got %d, want %d", p.Name, len(sigKey), ed25519.PublicKeySize)
+	}
+	verifier := api.NewEd25519Verifier(ed25519.PublicKey(sigKey))
+
+	sseMgr := api.NewSSEManager(client, verifier, logger)
+	sseMgr.RegisterHandler(api.EventSigningKeyRotated, func(_ context.Context, env api.SignedEnvelope) error {
+		var keys api.SigningKeys
+		if err := json.Unmarshal(env.Payload, &keys); err != nil {
+			return fmt.Errorf("profile %s: parse signing_key_rotated: %w", p.Name, err)
+		}
+		current, prev, expires := decodeSigningKeys(keys, logger)
+		verifier.SetKeys(current, prev, expires)
+		logger.Info("signing keys rotated via SSE")
+		return nil
+	})
+
+	reconciler := reconcile.NewReconciler(client, p.Reconcile, logger)
+	reconciler.RegisterNamedHandler("signing_keys", func(_ context.Context, _ *api.StateResponse, diff reconcile.StateDiff) error {
+		if diff.SigningKeysChanged && diff.NewSigningKeys != nil {
+			current, prev, expires := decodeSigningKeys(*diff.NewSigningKeys, logger)
+			verifier.SetKeys(current, prev, expires)
+			logger.Info("signing keys updated via reconcile")
+		}
+		return nil
+	})
+
+	nodeAPICfg.DataDir = p.Registration.DataDir
+	profileState := nodeapi.NewProfile(p.Name, nodeAPICfg, client, identity.NodeID, []byte(identity.NodeSecretKey), logger)
+	reconciler.RegisterNamedHandler("nodeapi", profileState.ReconcileHandler())
+	nodeAPISrv.AddProfile(profileState)
+	defer nodeAPISrv.RemoveProfile(p.Name)
+
+	runCtx, endRun := context.WithCancel(ctx)
+	defer endRun()
+	var decommissioning atomic.Bool
+
+	heartbeat := agent.NewHeartbeatService(agent.HeartbeatConfig{
+		Interval: p.Heartbeat.Interval,
+		NodeID:   identity.NodeID,
+	}, client, logger)
+	heartbeat.SetReconcileTrigger(reconciler)
+	heartbeat.SetHealthSource(reconciler)
+	heartbeat.SetPrivilege(priv.Privilege, priv.Degraded())
+	var authEnd atomic.Pointer[error]
+	heartbeat.SetOnAuthRejected(p.AuthRecovery.Threshold, func() {
+		err := recoverIdentity(runCtx, p.AuthRecovery.Action, registrar, logger)
+		authEnd.Store(&err)
+		endRun()
+	})
+	heartbeat.SetOnRotateKeys(reconciler.TriggerReconcile)
+	heartbeat.SetOnDecommission(func() {
+		decommissioning.Store(true)
+		endRun()
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		if err := sseMgr.Start(runCtx, identity.NodeID); err != nil {
+			logger.Error("SSE manager stopped", "error", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		_ = heartbeat.Run(runCtx)
+	}()
+	go func() {
+		defer wg.Done()
+		if err := reconciler.Run(runCtx, identity.NodeID); err != nil {
+			logger.Error("reconciler stopped", "error", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if err := profileState.Run(runCtx); err != nil && runCtx.Err() == nil {
+			logger.Error("profile state stopped", "error", err)
+		}
+	}()
+
+	<-runCtx.Done()
+	sseMgr.Shutdown()
+	wg.Wait()
+
+	if err := authEnd.Load(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("profile %s: %w", p.Name, *err)
+	}
+	if decommissioning.Load() && ctx.Err() == nil {
+		// Firewall and routing state is shared with the top-level mesh,
+		// so only the profile's identity and state are removed here. Its
+		// interface goes with the top-level teardown.
+		dec := agent.NewDecommissioner(&agent.AgentConfig{
+			DataDir:      p.Registration.DataDir,
+			Registration: p.Registration,
+		}, client, logger)
+		dec.SetNetworkCleaner(nil)
+		decCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+		defer cancel()
+		if err := dec.Decommission(decCtx, identity.NodeID, agent.DecommissionOptions{Force: true}); err != nil {
+			return fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
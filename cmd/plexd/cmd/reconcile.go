@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/plexsphere/plexd/internal/nodeapi"
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Inspect and control state reconciliation",
+}
+
+var reconcileHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show recent reconciliation cycles",
+	Long:  "Connect to the local agent via Unix socket and show the recent reconciliation cycles that found drift or failed, newest first.",
+	RunE:  runReconcileHistory,
+}
+
+var reconcileTriggerCmd = &cobra.Command{
+	Use:   "trigger",
+	Short: "Run a reconciliation cycle now",
+	Long:  "Ask the local agent to run a reconciliation cycle immediately. Fails while reconciliation is paused.",
+	Args:  cobra.NoArgs,
+	RunE:  runReconcileTrigger,
+}
+
+var reconcilePauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "Pause reconciliation during manual maintenance",
+	Long: `Stop the local agent from correcting drift for --duration, so that temporary
+manual changes are not reverted. Reconciliation resumes by itself when the
+duration has passed, or earlier with 'plexd reconcile resume'. Without
+--duration, shows whether reconciliation is paused.`,
+	Args: cobra.NoArgs,
+	RunE: runReconcilePause,
+}
+
+var reconcileResumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "End a reconciliation pause and run a cycle",
+	Args:  cobra.NoArgs,
+	RunE:  runReconcileResume,
+}
+
+func init() {
+	reconcileHistoryCmd.Flags().Int("limit", 0, "Show at most this many cycles (0 for all)")
+	reconcileHistoryCmd.Flags().Duration("since", 0, "Show only cycles started within this duration (e.g. 1h)")
+	reconcilePauseCmd.Flags().Duration("duration", 0, "How long to pause (e.g. 30m, at most 24h)")
+	reconcileCmd.AddCommand(reconcileHistoryCmd)
+	reconcileCmd.AddCommand(reconcileTriggerCmd)
+	reconcileCmd.AddCommand(reconcilePauseCmd)
+	reconcileCmd.AddCommand(reconcileResumeCmd)
+	rootCmd.AddCommand(reconcileCmd)
+}
+
+func runReconcileHistory(cmd *cobra.Command, _ []string) error {
+	limit, _ := cmd.Flags().GetInt("limit")
+	since, _ := cmd.Flags().GetDuration("since")
+
+	q := url.Values{}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	if since > 0 {
+		q.Set("since", time.Now().Add(-since).UTC().Format(time.RFC3339))
+	}
+	cycles, err := fetchReconcileHistory(defaultSocketPath(), q)
+	if err != nil {
+		return fmt.Errorf("plexd reconcile history: %w", err)
+	}
+	writeResult(cmd, cycles, func(w io.Writer) { writeReconcileHistory(w, cycles) })
+	return nil
+}
+
+func runReconcileTrigger(cmd *cobra.Command, _ []string) error {
+	if _, err := socketRequest(defaultSocketPath(), http.MethodPost, "/v1/reconcile/trigger"); err != nil {
+		return fmt.Errorf("plexd reconcile trigger: %w", err)
+	}
+	writeResult(cmd, nil, func(w io.Writer) { fmt.Fprintln(w, "reconciliation triggered") })
+	return nil
+}
+
+func runReconcilePause(cmd *cobra.Command, _ []string) error {
+	d, _ := cmd.Flags().GetDuration("duration")
+	method, path := http.MethodGet, "/v1/reconcile/pause"
+	if d > 0 {
+		method, path = http.MethodPost, path+"?duration="+url.QueryEscape(d.String())
+	}
+	body, err := socketRequest(defaultSocketPath(), method, path)
+	if err != nil {
+		return fmt.Errorf("plexd reconcile pause: %w", err)
+	}
+	var status nodeapi.PauseStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return fmt.Errorf("plexd reconcile pause: parse response: %w", err)
+	}
+	writeResult(cmd, status, func(w io.Writer) { writePauseStatus(w, status) })
+	return nil
+}
+
+func runReconcileResume(cmd *cobra.Command, _ []string) error {
+	if _, err := socketRequest(defaultSocketPath(), http.MethodDelete, "/v1/reconcile/pause"); err != nil {
+		return fmt.Errorf("plexd reconcile resume: %w", err)
+	}
+	writeResult(cmd, nil, func(w io.Writer) { fmt.Fprintln(w, "reconciliation resumed") })
+	return nil
+}
+
+// writePauseStatus prints whether reconciliation is paused and until when.
+func writePauseStatus(w io.Writer, status nodeapi.PauseStatus) {
+	if !status.Paused || status.Until == nil {
+		fmt.Fprintln(w, "reconciliation is not paused")
+		return
+	}
+	fmt.Fprintf(w, "reconciliation paused until %s\n", status.Until.Local().Format(time.DateTime))
+}
+
+// fetchReconcileHistory reads the recorded cycles, newest first.
+func fetchReconcileHistory(socketPath string, query url.Values) ([]reconcile.CycleRecord, error) {
+	path := "/v1/reconcile/history"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	body, err := socketRequest(socketPath, http.MethodGet, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cycles []reconcile.CycleRecord
+	if err := json.Unmarshal(body, &cycles); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	return cycles, nil
+}
+
+// writeReconcileHistory prints one line per cycle.
+func writeReconcileHistory(w io.Writer, cycles []reconcile.CycleRecord) {
+	if len(cycles) == 0 {
+		fmt.Fprintln(w, "No reconciliation cycles recorded.")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tTRIGGER\tDURATION\tCHANGES\tCORRECTIONS\tFAILED HANDLERS\tERROR")
+	for _, c := range cycles {
+		changes, failed, errMsg := "-", "-", "-"
+		if c.Diff != nil {
+			changes = diffSummaryString(*c.Diff)
+		}
+		var names []string
+		for _, h := range c.Handlers {
+			if h.Status == reconcile.HandlerFailed {
+				names = append(names, h.Name)
+			}
+		}
+		if len(names) > 0 {
+			failed = strings.Join(names, ",")
+		}
+		if c.Error != "" {
+			errMsg = c.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			c.Start.Local().Format(time.DateTime),
+			c.Trigger,
+			time.Duration(c.DurationNano).Round(time.Millisecond),
+			changes,
+			len(c.Corrections),
+			failed,
+			errMsg,
+		)
+	}
+	tw.Flush()
+}
+
+// diffSummaryString formats the non-zero parts of d, e.g. "peers +2 -1 ~0".
+func diffSummaryString(d reconcile.DiffSummary) string {
+	var parts []string
+	if d.PeersAdded+d.PeersRemoved+d.PeersUpdated > 0 {
+		parts = append(parts, fmt.Sprintf("peers +%d -%d ~%d", d.PeersAdded, d.PeersRemoved, d.PeersUpdated))
+	}
+	if d.PoliciesAdded+d.PoliciesRemoved > 0 {
+		parts = append(parts, fmt.Sprintf("policies +%d -%d", d.PoliciesAdded, d.PoliciesRemoved))
+	}
+	for _, f := range []struct {
+		changed bool
+		name    string
+	}{
+		{d.SigningKeysChanged, "signing_keys"},
+		{d.MetadataChanged, "metadata"},
+		{d.DataChanged, "data"},
+		{d.SecretRefsChanged, "secret_refs"},
+	} {
+		if f.changed {
+			parts = append(parts, f.name)
+		}
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, "; ")
+}
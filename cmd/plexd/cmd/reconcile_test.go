@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/nodeapi"
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+// startFakeReconcileAgent serves cycles at /v1/reconcile/history on a Unix
+// socket and records the last query.
+func startFakeReconcileAgent(t *testing.T, cycles []reconcile.CycleRecord, query *url.Values) string {
+	t.Helper()
+	socketPath := filepath.Join(t.TempDir(), "api.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/reconcile/history", func(w http.ResponseWriter, r *http.Request) {
+		*query = r.URL.Query()
+		_ = json.NewEncoder(w).Encode(cycles)
+	})
+	srv := &http.Server{Handler: mux}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { srv.Close() })
+	return socketPath
+}
+
+func TestFetchReconcileHistory(t *testing.T) {
+	var query url.Values
+	socketPath := startFakeReconcileAgent(t, []reconcile.CycleRecord{
+		{Start: time.Now(), Trigger: reconcile.TriggerInterval, Diff: &reconcile.DiffSummary{PeersAdded: 1}},
+	}, &query)
+
+	cycles, err := fetchReconcileHistory(socketPath, url.Values{"limit": {"5"}})
+	if err != nil {
+		t.Fatalf("fetchReconcileHistory: %v", err)
+	}
+	if len(cycles) != 1 || cycles[0].Diff == nil || cycles[0].Diff.PeersAdded != 1 {
+		t.Errorf("cycles = %+v", cycles)
+	}
+	if query.Get("limit") != "5" {
+		t.Errorf("limit = %q, want 5", query.Get("limit"))
+	}
+}
+
+func TestWriteReconcileHistory(t *testing.T) {
+	buf := new(bytes.Buffer)
+	writeReconcileHistory(buf, []reconcile.CycleRecord{
+		{
+			Start:    time.Now(),
+			Trigger:  reconcile.TriggerManual,
+			Diff:     &reconcile.DiffSummary{PeersAdded: 2, PeersRemoved: 1, MetadataChanged: true},
+			Handlers: []reconcile.HandlerResult{{Name: "wireguard", Status: reconcile.HandlerFailed, Error: "boom"}},
+		},
+		{Start: time.Now(), Trigger: reconcile.TriggerInterval, Error: "fetch failed"},
+	})
+	out := buf.String()
+	for _, want := range []string{"peers +2 -1 ~0; metadata", "wireguard", "triggered", "fetch failed"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteReconcileHistory_Empty(t *testing.T) {
+	buf := new(bytes.Buffer)
+	writeReconcileHistory(buf, nil)
+	if !strings.Contains(buf.String(), "No reconciliation cycles recorded") {
+		t.Errorf("output = %q", buf.String())
+	}
+}
+
+func TestSocketRequest_ErrorMessage(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "api.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/reconcile/trigger", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "reconciliation paused until 2025-01-01T00:30:00Z"})
+	})
+	srv := &http.Server{Handler: mux}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { srv.Close() })
+
+	_, err = socketRequest(socketPath, http.MethodPost, "/v1/reconcile/trigger")
+	if err == nil || !strings.Contains(err.Error(), "reconciliation paused until") || !strings.Contains(err.Error(), "409") {
+		t.Errorf("err = %v, want paused error with status 409", err)
+	}
+}
+
+func TestWritePauseStatus(t *testing.T) {
+	buf := new(bytes.Buffer)
+	writePauseStatus(buf, nodeapi.PauseStatus{})
+	if !strings.Contains(buf.String(), "not paused") {
+		t.Errorf("output = %q", buf.String())
+	}
+
+	buf.Reset()
+	until := time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC)
+	writePauseStatus(buf, nodeapi.PauseStatus{Paused: true, Until: &until})
+	if !strings.Contains(buf.String(), "paused until "+until.Local().Format(time.DateTime)) {
+		t.Errorf("output = %q", buf.String())
+	}
+}
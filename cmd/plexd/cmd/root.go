@@ -3,8 +3,11 @@ package cmd
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
+
+	"github.com/plexsphere/plexd/internal/packaging"
 )
 
 var (
@@ -12,6 +15,7 @@ var (
 	logLevel string
 	apiURL   string
 	mode     string
+	setFlags []string
 )
 
 // Build info set from main.
@@ -37,19 +41,43 @@ var rootCmd = &cobra.Command{
 		"It connects to the control plane, registers the node, establishes encrypted\n" +
 		"WireGuard mesh tunnels, enforces network policies, and continuously reconciles local state.",
 	// No Run function — prints help by default.
+	PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+		return checkOutputFormat(cmd)
+	},
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "/etc/plexd/config.yaml", "config file path")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", filepath.Join(packaging.DefaultConfigDir, "config.yaml"), "config file path")
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "log level (debug, info, warn, error)")
 	rootCmd.PersistentFlags().StringVar(&apiURL, "api", "", "control plane API URL (overrides config)")
 	rootCmd.PersistentFlags().StringVar(&mode, "mode", "", "operating mode: node or bridge (overrides config)")
+	rootCmd.PersistentFlags().StringArrayVar(&setFlags, "set", nil, "override a config key, e.g. heartbeat.interval=10s (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", outputText, "output format: text or json")
+	registerFlagValues(rootCmd, "log-level", "debug", "info", "warn", "error")
+	registerFlagValues(rootCmd, "mode", "node", "bridge")
+	registerFlagValues(rootCmd, "output", outputText, outputJSON)
+	rootCmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return withExitCode(ExitUsage, err)
+	})
 
 	rootCmd.Version = buildVersion
 	rootCmd.SetVersionTemplate(fmt.Sprintf("plexd version {{.Version}}\ncommit: %s\nbuilt: %s\n", buildCommit, buildDate))
 }
 
-// Execute runs the root command.
+// Execute runs the root command. With --output json it writes the command's
+// JSON document to stdout, also when the command failed. Use ExitCode to map
+// the returned error to the process exit code.
 func Execute() error {
-	return rootCmd.Execute()
+	cmdResult = nil
+	cmd, err := rootCmd.ExecuteC()
+	if !jsonOutput() || cmd == nil {
+		return err
+	}
+	if help, _ := cmd.Flags().GetBool("help"); help {
+		return err
+	}
+	if werr := writeJSONResult(cmd.OutOrStdout(), cmd, err); werr != nil && err == nil {
+		return werr
+	}
+	return err
 }
@@ -1,20 +1,24 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 
 	"github.com/plexsphere/plexd/internal/nodeapi"
 )
 
-// newSocketClient creates an HTTP client that connects via Unix socket.
+// newSocketClient creates an HTTP client that connects via the local node API
+// socket (a named pipe on Windows).
 func newSocketClient(socketPath string) *http.Client {
 	return &http.Client{
 		Transport: &http.Transport{
 			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
-				return net.Dial("unix", socketPath)
+				return nodeapi.DialLocal(socketPath)
 			},
 		},
 	}
@@ -30,11 +34,67 @@ func socketGet(socketPath, path string) (*http.Response, error) {
 	client := newSocketClient(socketPath)
 	resp, err := client.Get(socketURL(path))
 	if err != nil {
-		return nil, fmt.Errorf("agent not running or socket unavailable at %s: %w", socketPath, err)
+		return nil, agentUnavailable(socketPath, err)
 	}
 	return resp, nil
 }
 
+// socketRequest sends a request to the local agent and returns the
+// response body. Non-2xx responses are returned as errors carrying the
+// agent's error message.
+func socketRequest(socketPath, method, path string) ([]byte, error) {
+	req, err := http.NewRequest(method, socketURL(path), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	return doSocketRequest(socketPath, req)
+}
+
+// socketRequestJSON is socketRequest with v sent as the JSON request body.
+func socketRequestJSON(socketPath, method, path string, v any) ([]byte, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequest(method, socketURL(path), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doSocketRequest(socketPath, req)
+}
+
+// doSocketRequest sends req to the local agent for socketRequest and
+// socketRequestJSON.
+func doSocketRequest(socketPath string, req *http.Request) ([]byte, error) {
+	resp, err := newSocketClient(socketPath).Do(req)
+	if err != nil {
+		return nil, agentUnavailable(socketPath, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Error != "" {
+			return nil, fmt.Errorf("%s (status %d)", e.Error, resp.StatusCode)
+		}
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+// agentUnavailable returns the error for a failed connection to the local
+// agent, with exit code ExitAgentUnavailable.
+func agentUnavailable(socketPath string, err error) error {
+	return withExitCode(ExitAgentUnavailable, fmt.Errorf("agent not running or socket unavailable at %s: %w", socketPath, err))
+}
+
 // defaultSocketPath returns the configured or default socket path.
 func defaultSocketPath() string {
 	return nodeapi.DefaultSocketPath
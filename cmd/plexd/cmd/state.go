@@ -22,7 +22,13 @@ var stateGetCmd = &cobra.Command{
 	Short: "Get a specific state entry",
 	Long:  "Fetch a specific state entry by type (metadata, data, report) and key.",
 	Args:  cobra.ExactArgs(2),
-	RunE:  runStateGet,
+	ValidArgsFunction: func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return []string{"metadata", "data", "report"}, cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: runStateGet,
 }
 
 var stateReportData string
@@ -64,7 +70,7 @@ func runState(cmd *cobra.Command, _ []string) error {
 		return fmt.Errorf("plexd state: format response: %w", err)
 	}
 
-	fmt.Fprintln(cmd.OutOrStdout(), string(pretty))
+	writeResult(cmd, raw, func(w io.Writer) { fmt.Fprintln(w, string(pretty)) })
 	return nil
 }
 
@@ -112,7 +118,7 @@ func runStateGet(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("plexd state get: format response: %w", err)
 	}
 
-	fmt.Fprintln(cmd.OutOrStdout(), string(pretty))
+	writeResult(cmd, raw, func(w io.Writer) { fmt.Fprintln(w, string(pretty)) })
 	return nil
 }
 
@@ -145,7 +151,7 @@ func runStateReport(cmd *cobra.Command, args []string) error {
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("plexd state report: agent not running or socket unavailable: %w", err)
+		return fmt.Errorf("plexd state report: %w", agentUnavailable(defaultSocketPath(), err))
 	}
 	defer resp.Body.Close()
 
@@ -158,6 +164,6 @@ func runStateReport(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("plexd state report: unexpected status %d: %s", resp.StatusCode, string(respBody))
 	}
 
-	fmt.Fprintf(cmd.OutOrStdout(), "report %s written\n", key)
+	writeResult(cmd, nil, func(w io.Writer) { fmt.Fprintf(w, "report %s written\n", key) })
 	return nil
 }
@@ -38,7 +38,12 @@ func runStatus(cmd *cobra.Command, _ []string) error {
 		return fmt.Errorf("plexd status: parse response: %w", err)
 	}
 
-	w := cmd.OutOrStdout()
+	writeResult(cmd, summary, func(w io.Writer) { writeStatus(w, summary) })
+	return nil
+}
+
+// writeStatus prints the entry counts and the metadata.
+func writeStatus(w io.Writer, summary nodeapi.StateSummary) {
 	fmt.Fprintf(w, "Metadata entries: %d\n", len(summary.Metadata))
 	fmt.Fprintf(w, "Data keys:        %d\n", len(summary.DataKeys))
 	fmt.Fprintf(w, "Secret keys:      %d\n", len(summary.SecretKeys))
@@ -50,6 +55,4 @@ func runStatus(cmd *cobra.Command, _ []string) error {
 			fmt.Fprintf(w, "  %s: %s\n", k, v)
 		}
 	}
-
-	return nil
 }
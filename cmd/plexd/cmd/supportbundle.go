@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var supportBundleFile string
+
+// supportBundleResult is the JSON result of plexd support-bundle.
+type supportBundleResult struct {
+	File string `json:"file"`
+	Size int    `json:"size"`
+}
+
+var supportBundleCmd = &cobra.Command{
+	Use:   "support-bundle",
+	Short: "Write a diagnostics bundle of the running agent",
+	Long: `Write a gzipped tar archive with the goroutine stacks, recent logs, reconcile
+history, and config of the local agent to a file, for a support request.
+Secrets in the config are redacted. The logs are only included with
+log_capture set in the agent config. The same bundle is written to crash.dir
+when the agent crashes.`,
+	Args: cobra.NoArgs,
+	RunE: runSupportBundle,
+}
+
+func init() {
+	supportBundleCmd.Flags().StringVar(&supportBundleFile, "file", "", "write the bundle to this file (default plexd-support-<time>.tar.gz in the current directory)")
+	rootCmd.AddCommand(supportBundleCmd)
+}
+
+func runSupportBundle(cmd *cobra.Command, _ []string) error {
+	res, err := writeSupportBundle(defaultSocketPath(), supportBundleFile)
+	if err != nil {
+		return fmt.Errorf("plexd support-bundle: %w", err)
+	}
+	writeResult(cmd, res, func(w io.Writer) {
+		fmt.Fprintf(w, "support bundle written to %s (%d bytes)\n", res.File, res.Size)
+	})
+	return nil
+}
+
+// writeSupportBundle fetches a bundle from the agent and writes it to file,
+// or to a file named after the current time if file is empty.
+func writeSupportBundle(socketPath, file string) (supportBundleResult, error) {
+	bundle, err := socketRequest(socketPath, http.MethodGet, "/v1/debug/support-bundle")
+	if err != nil {
+		return supportBundleResult{}, err
+	}
+	if file == "" {
+		file = fmt.Sprintf("plexd-support-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z"))
+	}
+	// The bundle holds logs and the node's config; keep it private.
+	if err := os.WriteFile(file, bundle, 0o600); err != nil {
+		return supportBundleResult{}, err
+	}
+	return supportBundleResult{File: file, Size: len(bundle)}, nil
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// startFakeBundleAgent serves body at /v1/debug/support-bundle on a Unix
+// socket with the given status.
+func startFakeBundleAgent(t *testing.T, status int, body string) string {
+	t.Helper()
+	socketPath := filepath.Join(t.TempDir(), "api.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/debug/support-bundle", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	})
+	srv := &http.Server{Handler: mux}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { srv.Close() })
+	return socketPath
+}
+
+func TestWriteSupportBundle(t *testing.T) {
+	socketPath := startFakeBundleAgent(t, http.StatusOK, "bundle")
+	file := filepath.Join(t.TempDir(), "bundle.tar.gz")
+
+	res, err := writeSupportBundle(socketPath, file)
+	if err != nil {
+		t.Fatalf("writeSupportBundle: %v", err)
+	}
+	if res.File != file || res.Size != len("bundle") {
+		t.Errorf("result = %+v", res)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil || string(data) != "bundle" {
+		t.Fatalf("file = %q, %v", data, err)
+	}
+	if st, _ := os.Stat(file); st.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", st.Mode().Perm())
+	}
+}
+
+func TestWriteSupportBundle_AgentError(t *testing.T) {
+	socketPath := startFakeBundleAgent(t, http.StatusForbidden, `{"error":"missing scope debug:control"}`)
+	file := filepath.Join(t.TempDir(), "bundle.tar.gz")
+
+	_, err := writeSupportBundle(socketPath, file)
+	if err == nil || !strings.Contains(err.Error(), "debug:control") {
+		t.Fatalf("err = %v, want the agent's error", err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("file written despite the error: %v", err)
+	}
+}
@@ -0,0 +1,356 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/metrics"
+	"github.com/plexsphere/plexd/internal/nodeapi"
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+// topCycles is the number of recent reconciliation cycles plexd top shows.
+const topCycles = 5
+
+// ANSI sequences that move the cursor home and clear the screen.
+const ansiClear = "\x1b[H\x1b[2J"
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Show a live dashboard of the local agent",
+	Long: `Poll the local agent via Unix socket and redraw a dashboard of the mesh
+peers with their handshake ages and transfer rates, the event stream, and
+recent reconciliation cycles. Transfer rates are computed between two
+polls, so they appear from the second refresh on. Press Ctrl-C to quit.
+
+When stdout is not a terminal, frames are printed one after another
+instead of redrawing the screen.`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{textOnlyAnnotation: ""},
+	RunE:        runTop,
+}
+
+var (
+	topInterval time.Duration
+	topOnce     bool
+)
+
+func init() {
+	topCmd.Flags().DurationVarP(&topInterval, "interval", "n", 2*time.Second, "refresh interval")
+	topCmd.Flags().BoolVar(&topOnce, "once", false, "print a single frame and exit")
+	rootCmd.AddCommand(topCmd)
+}
+
+// topSnapshot is one poll of the agent. Each section carries its own error,
+// so that one unavailable endpoint does not blank the whole dashboard.
+type topSnapshot struct {
+	Taken time.Time
+
+	Peers    []nodeapi.MeshPeer
+	PeersErr error
+
+	Events    api.SSEStatus
+	EventsErr error
+
+	Pause    nodeapi.PauseStatus
+	PauseErr error
+
+	Cycles    []reconcile.CycleRecord
+	CyclesErr error
+}
+
+// agentErr returns the first error that means the agent is unreachable, or
+// nil if the agent answered at least one request.
+func (s *topSnapshot) agentErr() error {
+	errs := []error{s.PeersErr, s.EventsErr, s.PauseErr, s.CyclesErr}
+	for _, err := range errs {
+		if ExitCode(err) != ExitAgentUnavailable {
+			return nil
+		}
+	}
+	return errs[0]
+}
+
+func runTop(cmd *cobra.Command, _ []string) error {
+	if topInterval < 100*time.Millisecond {
+		return withExitCode(ExitUsage, fmt.Errorf("plexd top: --interval must be at least 100ms"))
+	}
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+
+	out := cmd.OutOrStdout()
+	redraw := !topOnce && isTerminal(out)
+	socketPath := defaultSocketPath()
+
+	var prev *topSnapshot
+	for {
+		snap := fetchTopSnapshot(socketPath)
+		if prev == nil {
+			// Fail fast instead of drawing an empty dashboard when the
+			// agent is not running at all.
+			if err := snap.agentErr(); err != nil {
+				return fmt.Errorf("plexd top: %w", err)
+			}
+		}
+
+		var frame bytes.Buffer
+		if redraw {
+			frame.WriteString(ansiClear)
+		} else if prev != nil {
+			frame.WriteString("\n")
+		}
+		renderTop(&frame, snap, prev, topInterval)
+		if _, err := out.Write(frame.Bytes()); err != nil {
+			return err
+		}
+		if topOnce {
+			return nil
+		}
+		prev = snap
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(topInterval):
+		}
+	}
+}
+
+// isTerminal reports whether w is a character device, such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// fetchTopSnapshot polls every endpoint the dashboard shows.
+func fetchTopSnapshot(socketPath string) *topSnapshot {
+	s := &topSnapshot{Taken: time.Now()}
+	s.PeersErr = getJSON(socketPath, "/v1/mesh/peers", &s.Peers)
+	s.EventsErr = getJSON(socketPath, "/v1/events/status", &s.Events)
+	s.PauseErr = getJSON(socketPath, "/v1/reconcile/pause", &s.Pause)
+	s.Cycles, s.CyclesErr = fetchReconcileHistory(socketPath, url.Values{"limit": {fmt.Sprint(topCycles)}})
+	return s
+}
+
+// getJSON reads path from the agent and decodes the response into v.
+func getJSON(socketPath, path string, v any) error {
+	body, err := socketRequest(socketPath, http.MethodGet, path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("parse response: %w", err)
+	}
+	return nil
+}
+
+// renderTop writes one dashboard frame for snap. prev is the previous poll,
+// or nil; transfer rates are computed against it.
+func renderTop(w io.Writer, snap, prev *topSnapshot, interval time.Duration) {
+	now := snap.Taken
+	fmt.Fprintf(w, "plexd top - %s, refresh every %s, Ctrl-C to quit\n\n", now.Local().Format(time.DateTime), interval)
+
+	writeTopReconcile(w, snap, now)
+	writeTopEvents(w, snap)
+	fmt.Fprintln(w)
+	writeTopPeers(w, snap, prev, now)
+	fmt.Fprintln(w)
+	writeTopCycles(w, snap)
+}
+
+func writeTopReconcile(w io.Writer, snap *topSnapshot, now time.Time) {
+	state := "running"
+	switch {
+	case snap.PauseErr != nil:
+		state = "unknown (" + topErrString(snap.PauseErr) + ")"
+	case snap.Pause.Paused && snap.Pause.Until != nil:
+		state = fmt.Sprintf("paused for %s", meshAge(*snap.Pause.Until, now))
+	}
+	fmt.Fprintf(w, "Reconcile:  %s\n", state)
+}
+
+func writeTopEvents(w io.Writer, snap *topSnapshot) {
+	if snap.EventsErr != nil {
+		fmt.Fprintf(w, "Events:     %s\n", topErrString(snap.EventsErr))
+		return
+	}
+	ev := snap.Events
+	state := "stopped"
+	switch {
+	case ev.Connected:
+		state = "connected"
+	case ev.Running:
+		state = "reconnecting"
+	}
+	if ev.Transport != "" {
+		state += " (" + ev.Transport + ")"
+	}
+	last := "never"
+	if ev.LastEventAgeNano >= 0 {
+		last = time.Duration(ev.LastEventAgeNano).Round(time.Second).String() + " ago"
+	}
+	fmt.Fprintf(w, "Events:     %s, last event %s", state, last)
+	if ev.LastEventID != "" {
+		fmt.Fprintf(w, " (id %s)", ev.LastEventID)
+	}
+	fmt.Fprintf(w, ", %d received, %d reconnects\n", ev.EventsReceived, ev.Reconnects)
+}
+
+func writeTopPeers(w io.Writer, snap, prev *topSnapshot, now time.Time) {
+	if snap.PeersErr != nil {
+		fmt.Fprintf(w, "Peers: %s\n", topErrString(snap.PeersErr))
+		return
+	}
+	fresh := 0
+	for _, p := range snap.Peers {
+		if p.LastHandshake != nil && now.Sub(*p.LastHandshake) < metrics.DefaultStaleThreshold {
+			fresh++
+		}
+	}
+	fmt.Fprintf(w, "Peers: %d, %d with a recent handshake\n", len(snap.Peers), fresh)
+	if len(snap.Peers) == 0 {
+		return
+	}
+
+	var previous map[string]nodeapi.MeshPeer
+	var elapsed time.Duration
+	if prev != nil && prev.PeersErr == nil {
+		elapsed = snap.Taken.Sub(prev.Taken)
+		previous = make(map[string]nodeapi.MeshPeer, len(prev.Peers))
+		for _, p := range prev.Peers {
+			previous[p.PublicKey] = p
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PEER\tMESH IP\tENDPOINT\tHANDSHAKE\tRX/s\tTX/s\tRX\tTX")
+	for _, p := range snap.Peers {
+		id, meshIP, endpoint, handshake := p.PeerID, p.MeshIP, p.Endpoint, "never"
+		if id == "" {
+			id = "(" + shortKey(p.PublicKey) + ")"
+		}
+		if meshIP == "" {
+			meshIP = "-"
+		}
+		if endpoint == "" {
+			endpoint = "-"
+		}
+		if p.LastHandshake != nil {
+			handshake = meshAge(now, *p.LastHandshake) + " ago"
+			if now.Sub(*p.LastHandshake) >= metrics.DefaultStaleThreshold {
+				handshake += " (stale)"
+			}
+		}
+		rxRate, txRate := "-", "-"
+		if old, ok := previous[p.PublicKey]; ok && elapsed > 0 {
+			rxRate = formatRate(p.RxBytes-old.RxBytes, elapsed)
+			txRate = formatRate(p.TxBytes-old.TxBytes, elapsed)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			id, meshIP, endpoint, handshake, rxRate, txRate, formatBytes(p.RxBytes), formatBytes(p.TxBytes))
+	}
+	tw.Flush()
+}
+
+func writeTopCycles(w io.Writer, snap *topSnapshot) {
+	if snap.CyclesErr != nil {
+		fmt.Fprintf(w, "Recent cycles: %s\n", topErrString(snap.CyclesErr))
+		return
+	}
+	fmt.Fprintln(w, "Recent cycles:")
+	if len(snap.Cycles) == 0 {
+		fmt.Fprintln(w, "  none recorded")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  TIME\tTRIGGER\tDURATION\tCHANGES\tRESULT")
+	for _, c := range snap.Cycles {
+		changes := "-"
+		if c.Diff != nil {
+			changes = diffSummaryString(*c.Diff)
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n",
+			c.Start.Local().Format(time.TimeOnly),
+			c.Trigger,
+			time.Duration(c.DurationNano).Round(time.Millisecond),
+			changes,
+			cycleResult(c),
+		)
+	}
+	tw.Flush()
+}
+
+// cycleResult summarizes the outcome of a cycle in a few words.
+func cycleResult(c reconcile.CycleRecord) string {
+	if c.Error != "" {
+		return "error: " + c.Error
+	}
+	var failed []string
+	for _, h := range c.Handlers {
+		if h.Status == reconcile.HandlerFailed {
+			failed = append(failed, h.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return "failed: " + strings.Join(failed, ",")
+	}
+	if len(c.Corrections) > 0 {
+		return fmt.Sprintf("ok, %d corrections", len(c.Corrections))
+	}
+	return "ok"
+}
+
+// topErrString formats the error of a dashboard section.
+func topErrString(err error) string {
+	if ExitCode(err) == ExitAgentUnavailable {
+		return "agent unavailable"
+	}
+	return "unavailable: " + err.Error()
+}
+
+// shortKey abbreviates a base64 public key for display.
+func shortKey(key string) string {
+	if len(key) > 8 {
+		return key[:8] + "…"
+	}
+	return key
+}
+
+// formatRate formats the bytes transferred over elapsed as a rate.
+func formatRate(delta int64, elapsed time.Duration) string {
+	if delta < 0 {
+		// The counters reset when the peer was re-added.
+		return "-"
+	}
+	return formatBytes(int64(float64(delta)/elapsed.Seconds())) + "/s"
+}
+
+// formatBytes formats n with binary units, e.g. "1.5 KiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/nodeapi"
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+func TestTopCommand_AgentNotRunning(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"top", "--once"})
+	t.Cleanup(func() { topOnce = false })
+
+	err := rootCmd.Execute()
+	if ExitCode(err) != ExitAgentUnavailable {
+		t.Fatalf("ExitCode(%v) = %d, want %d", err, ExitCode(err), ExitAgentUnavailable)
+	}
+	if !strings.Contains(err.Error(), "plexd top") {
+		t.Errorf("error should mention 'plexd top', got: %v", err)
+	}
+}
+
+func TestFetchTopSnapshot(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "api.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/mesh/peers", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode([]nodeapi.MeshPeer{{PeerID: "peer-a", PublicKey: "a", RxBytes: 10}})
+	})
+	mux.HandleFunc("GET /v1/events/status", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "event stream not available"})
+	})
+	mux.HandleFunc("GET /v1/reconcile/pause", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(nodeapi.PauseStatus{})
+	})
+	mux.HandleFunc("GET /v1/reconcile/history", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("limit"); got != "5" {
+			t.Errorf("limit = %q, want 5", got)
+		}
+		_ = json.NewEncoder(w).Encode([]reconcile.CycleRecord{{Trigger: reconcile.TriggerInterval}})
+	})
+	srv := &http.Server{Handler: mux}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { srv.Close() })
+
+	snap := fetchTopSnapshot(socketPath)
+	if snap.PeersErr != nil || len(snap.Peers) != 1 || snap.Peers[0].RxBytes != 10 {
+		t.Errorf("peers = %+v, %v", snap.Peers, snap.PeersErr)
+	}
+	if snap.EventsErr == nil || !strings.Contains(snap.EventsErr.Error(), "event stream not available") {
+		t.Errorf("EventsErr = %v, want the agent's error", snap.EventsErr)
+	}
+	if snap.PauseErr != nil || snap.CyclesErr != nil || len(snap.Cycles) != 1 {
+		t.Errorf("pause = %v, cycles = %+v, %v", snap.PauseErr, snap.Cycles, snap.CyclesErr)
+	}
+	if err := snap.agentErr(); err != nil {
+		t.Errorf("agentErr() = %v, want nil", err)
+	}
+}
+
+func TestRenderTop(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-30 * time.Second)
+	old := now.Add(-10 * time.Minute)
+	until := now.Add(15 * time.Minute)
+
+	prev := &topSnapshot{
+		Taken: now.Add(-2 * time.Second),
+		Peers: []nodeapi.MeshPeer{{PublicKey: "key-a", RxBytes: 1000, TxBytes: 5000}},
+	}
+	snap := &topSnapshot{
+		Taken: now,
+		Peers: []nodeapi.MeshPeer{
+			{PeerID: "peer-a", PublicKey: "key-a", MeshIP: "10.99.0.2", Endpoint: "192.0.2.1:51820", LastHandshake: &recent, RxBytes: 3048, TxBytes: 5000},
+			{PeerID: "peer-b", PublicKey: "key-b", LastHandshake: &old},
+			{PublicKey: "abcdefghijklmnop"},
+		},
+		Events: api.SSEStatus{Connected: true, Transport: "sse", LastEventAgeNano: int64(3 * time.Second), LastEventID: "ev-9", EventsReceived: 42, Reconnects: 1},
+		Pause:  nodeapi.PauseStatus{Paused: true, Until: &until},
+		Cycles: []reconcile.CycleRecord{
+			{Start: now, Trigger: reconcile.TriggerInterval, DurationNano: int64(35 * time.Millisecond), Diff: &reconcile.DiffSummary{PeersAdded: 1}},
+			{Start: now, Trigger: reconcile.TriggerManual, Handlers: []reconcile.HandlerResult{{Name: "policy", Status: reconcile.HandlerFailed}}},
+			{Start: now, Trigger: reconcile.TriggerInterval, Error: "fetch state: timeout"},
+		},
+	}
+
+	var buf bytes.Buffer
+	renderTop(&buf, snap, prev, 2*time.Second)
+	out := buf.String()
+
+	for _, want := range []string{
+		"Reconcile:  paused for 15m0s",
+		"Events:     connected (sse), last event 3s ago (id ev-9), 42 received, 1 reconnects",
+		"Peers: 3, 1 with a recent handshake",
+		"30s ago",
+		"10m0s ago (stale)",
+		"(abcdefgh…)",
+		"1.0 KiB/s",
+		"0 B/s",
+		"3.0 KiB",
+		"peers +1 -0 ~0",
+		"failed: policy",
+		"error: fetch state: timeout",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, ansiClear) {
+		t.Error("renderTop must not clear the screen itself")
+	}
+}
+
+func TestRenderTop_SectionErrors(t *testing.T) {
+	unavailable := agentUnavailable("/x.sock", net.ErrClosed)
+	snap := &topSnapshot{
+		Taken:     time.Now(),
+		PeersErr:  unavailable,
+		EventsErr: unavailable,
+		PauseErr:  unavailable,
+		CyclesErr: unavailable,
+	}
+	if snap.agentErr() == nil {
+		t.Error("agentErr() = nil, want error when every request failed")
+	}
+
+	var buf bytes.Buffer
+	renderTop(&buf, snap, nil, time.Second)
+	if got := strings.Count(buf.String(), "agent unavailable"); got != 4 {
+		t.Errorf("output has %d 'agent unavailable' sections, want 4:\n%s", got, buf.String())
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 << 20, "5.0 MiB"},
+		{3 << 30, "3.0 GiB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.n); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestCompletion_FlagValues(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "output", args: []string{"__complete", "mesh", "peers", "--output", ""}, want: []string{"text", "json"}},
+		{name: "init system", args: []string{"__complete", "install", "--init-system", ""}, want: []string{"systemd", "launchd"}},
+		{name: "state type", args: []string{"__complete", "state", "get", ""}, want: []string{"metadata", "data", "report"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			rootCmd.SetOut(buf)
+			rootCmd.SetErr(new(bytes.Buffer))
+			rootCmd.SetArgs(tt.args)
+			if err := rootCmd.Execute(); err != nil {
+				t.Fatalf("Execute() = %v", err)
+			}
+			lines := strings.Split(buf.String(), "\n")
+			for _, want := range tt.want {
+				found := false
+				for _, l := range lines {
+					if l == want {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("completions missing %q:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 
@@ -10,16 +11,26 @@ import (
 	"github.com/plexsphere/plexd/internal/packaging"
 )
 
-var purge bool
+var (
+	purge            bool
+	uninstallInitSys string
+)
+
+// uninstallResult is the JSON result of plexd uninstall.
+type uninstallResult struct {
+	Purged bool `json:"purged"`
+}
 
 var uninstallCmd = &cobra.Command{
 	Use:   "uninstall",
-	Short: "Remove plexd systemd service",
+	Short: "Remove the plexd system service",
 	RunE:  runUninstall,
 }
 
 func init() {
 	uninstallCmd.Flags().BoolVar(&purge, "purge", false, "also remove data and config directories")
+	uninstallCmd.Flags().StringVar(&uninstallInitSys, "init-system", packaging.InitSystemAuto, "init system: auto, systemd, openrc, sysv, scm, or launchd")
+	registerFlagValues(uninstallCmd, "init-system", initSystems...)
 	rootCmd.AddCommand(uninstallCmd)
 }
 
@@ -27,12 +38,18 @@ func runUninstall(cmd *cobra.Command, _ []string) error {
 	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
 
 	cfg := packaging.InstallConfig{}
-	installer := packaging.NewInstaller(cfg, packaging.NewSystemdController(), packaging.NewRootChecker(), logger)
+	initSys, err := packaging.NewInitSystem(uninstallInitSys)
+	if err != nil {
+		return fmt.Errorf("plexd uninstall: %w", err)
+	}
+	installer := packaging.NewInstaller(cfg, initSys, packaging.NewRootChecker(), logger)
 
 	if err := installer.Uninstall(purge); err != nil {
 		return fmt.Errorf("plexd uninstall: %w", err)
 	}
 
-	fmt.Fprintln(cmd.OutOrStdout(), "plexd uninstalled successfully")
+	writeResult(cmd, uninstallResult{Purged: purge}, func(w io.Writer) {
+		fmt.Fprintln(w, "plexd uninstalled successfully")
+	})
 	return nil
 }
@@ -5,77 +5,193 @@ import (
 	"crypto/ed25519"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
-	"os/signal"
-	"sync"
-	"syscall"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/plexsphere/plexd/internal/agent"
 	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/auditfwd"
+	"github.com/plexsphere/plexd/internal/capabilities"
+	"github.com/plexsphere/plexd/internal/cni"
+	"github.com/plexsphere/plexd/internal/diagnostics"
+	"github.com/plexsphere/plexd/internal/featuregate"
+	"github.com/plexsphere/plexd/internal/integrity"
+	"github.com/plexsphere/plexd/internal/logging"
+	"github.com/plexsphere/plexd/internal/meshdiag"
+	"github.com/plexsphere/plexd/internal/nat"
+	"github.com/plexsphere/plexd/internal/netmon"
 	"github.com/plexsphere/plexd/internal/nodeapi"
+	"github.com/plexsphere/plexd/internal/overrides"
+	"github.com/plexsphere/plexd/internal/packaging"
+	"github.com/plexsphere/plexd/internal/peerexchange"
+	"github.com/plexsphere/plexd/internal/peerhealth"
+	"github.com/plexsphere/plexd/internal/privhelper"
 	"github.com/plexsphere/plexd/internal/reconcile"
 	"github.com/plexsphere/plexd/internal/registration"
+	"github.com/plexsphere/plexd/internal/secretsync"
+	"github.com/plexsphere/plexd/internal/tunnel"
+	"github.com/plexsphere/plexd/internal/wireguard"
 )
 
 // drainTimeout is the maximum time for graceful shutdown.
 const drainTimeout = 30 * time.Second
 
+// reconcileStallFactor is how many reconcile intervals may pass without a
+// cycle before the reconciler is considered stalled.
+const reconcileStallFactor = 3
+
+// signatureModeEnv sets the default of --signature-mode, so that it can be
+// set in the root-owned environment file of the service unit.
+const signatureModeEnv = "PLEXD_SIGNATURE_MODE"
+
+var (
+	upContainer     bool
+	upSignatureMode string
+)
+
 var upCmd = &cobra.Command{
-	Use:   "up",
-	Short: "Start the plexd agent",
+	Use:     "up",
+	Aliases: []string{"run"},
+	Short:   "Start the plexd agent",
 	Long: "Start the plexd agent daemon. Registers with the control plane,\n" +
-		"connects to the SSE event stream, and enters steady state.",
-	RunE: runUp,
+		"connects to the SSE event stream, and enters steady state.\n" +
+		"With --container, run as a container's main process.",
+	Annotations: map[string]string{textOnlyAnnotation: ""},
+	RunE:        runUp,
 }
 
 func init() {
+	upCmd.Flags().BoolVar(&upContainer, "container", false, "run as a container's main process (sets container=true)")
+	defaultMode := os.Getenv(signatureModeEnv)
+	if defaultMode == "" {
+		defaultMode = integrity.SignatureModeOff
+	}
+	upCmd.Flags().StringVar(&upSignatureMode, "signature-mode", defaultMode,
+		"reaction to an invalid binary or config signature: off, restricted, or enforce (default from "+signatureModeEnv+")")
 	rootCmd.AddCommand(upCmd)
 }
 
 func runUp(cmd *cobra.Command, _ []string) error {
-	// 1. Parse config.
-	cfg, err := agent.ParseConfig(cfgFile)
+	// 1. Parse config. The same functions are used for reloads so that flag
+	// and environment overrides keep precedence over the file. The file is
+	// read once and its signature is checked on the bytes that are parsed.
+	readConfig := func() ([]byte, error) {
+		return agent.ReadConfigFile(cfgFile, configOverrides(cmd))
+	}
+	parseConfig := func(data []byte) (*agent.AgentConfig, error) {
+		cfg, err := agent.ParseConfigData(cfgFile, data, configOverrides(cmd))
+		if err != nil {
+			return nil, err
+		}
+		applyDerivedConfig(cfg)
+		return cfg, nil
+	}
+	configData, err := readConfig()
 	if err != nil {
 		return fmt.Errorf("plexd up: %w", err)
 	}
-
-	// Apply CLI flag overrides.
-	if apiURL != "" {
-		cfg.API.BaseURL = apiURL
-	}
-	if mode != "" {
-		cfg.Mode = mode
+	cfg, err := parseConfig(configData)
+	if err != nil {
+		return fmt.Errorf("plexd up: %w", err)
 	}
-	if logLevel != "" {
-		cfg.LogLevel = logLevel
+	sigCfg, err := signatureConfig(upSignatureMode, configData != nil)
+	if err != nil {
+		return fmt.Errorf("plexd up: --signature-mode: %w", err)
 	}
 
 	// 2. Set up structured logger.
 	logger := setupLogger(cfg.LogLevel)
+	applyLogConfig(cfg)
 
 	logger.Info("starting plexd",
 		"version", buildVersion,
 		"mode", cfg.Mode,
+		"container", cfg.Container,
 	)
+	if !cfg.Container && agent.RunningInContainer() {
+		logger.Warn("running in a container without an init system; consider 'plexd up --container'")
+	}
+
+	// A panic writes a diagnostics bundle to crash.dir before it crashes
+	// the agent; so does, on the next start, any other fatal error. The
+	// bundle sources are added as the subsystems are created.
+	diag := diagnostics.NewCollector(cfg.Crash, buildVersion, logger)
+	if err := diag.Arm(); err != nil {
+		logger.Warn("fatal errors will not be bundled", "error", err)
+	}
+	defer func() {
+		if v := recover(); v != nil {
+			diag.Crash(v)
+			panic(v)
+		}
+	}()
+	diag.Add("logs.txt", capturedLogs)
+	diag.Add("config.yaml", func() ([]byte, error) { return agent.MarshalConfig(cfg, true) })
+
+	if v := cfg.FileVersion(); v < agent.CurrentConfigVersion {
+		logger.Warn("config file uses an old layout and was migrated in memory; run 'plexd config migrate' to update it",
+			"path", cfgFile,
+			"config_version", v,
+			"current_version", agent.CurrentConfigVersion,
+		)
+	}
 
 	// 3. Create control plane client.
-	client, err := api.NewControlPlane(cfg.API, buildVersion, logger)
+	client, err := api.NewControlPlane(cfg.API, buildVersion, logger.With("component", "api"))
 	if err != nil {
 		return fmt.Errorf("plexd up: create client: %w", err)
 	}
 
 	// 4. Register (or load existing identity).
-	cfg.Registration.DataDir = cfg.DataDir
 	registrar := registration.NewRegistrar(client, cfg.Registration, logger)
+	if cfg.Ephemeral {
+		registrar.SetEphemeral(cfg.EphemeralTTL)
+	}
 
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	// The capabilities are announced with the registration and republished
+	// by the capability manager whenever the binary, the hooks, or the
+	// feature gates change. Feature gates turn experimental subsystems on
+	// per node; the control plane changes them through node metadata.
+	capMgr := capabilities.NewManager(cfg.Capabilities, client, cfg.DataDir, logger)
+	capMgr.SetBinary(capabilities.Binary(buildVersion, cfg.Integrity.BinaryPath, logger))
+	gates := featuregate.NewGates(cfg.Features, logger)
+	gates.OnChange(capMgr.Trigger)
+	capMgr.SetFeatureGates(gates)
+	caps := capMgr.Payload()
+	registrar.SetCapabilities(&caps)
+
+	ctx, stop := daemonContext(logger)
 	defer stop()
 
+	// A decommission directive from the control plane ends the run like a
+	// shutdown signal; the node is torn down after the drain.
+	ctx, endRun := context.WithCancel(ctx)
+	defer endRun()
+	var decommissioning atomic.Bool
+
+	// Start the systemd watchdog before registration so that keepalives
+	// cover startup. Subsystem checks are added once they run.
+	watchdog := agent.NewWatchdog(agent.NewSDNotifier(), agent.WatchdogTimeout(), logger)
+	go func() {
+		_ = watchdog.Run(ctx)
+	}()
+
+	// Subsystems are started by the orchestrator in dependency order once
+	// they are wired up; it gates readiness until startup has finished.
+	orch := agent.NewOrchestrator(cfg.Startup, watchdog, logger)
+	orch.SetPanicHandler(func(subsystem string, v any) {
+		diag.Crash(fmt.Sprintf("%v (subsystem %s)", v, subsystem))
+	})
+
 	identity, err := registrar.Register(ctx)
 	if err != nil {
 		return fmt.Errorf("plexd up: registration: %w", err)
@@ -99,8 +215,25 @@ func runUp(cmd *cobra.Command, _ []string) error {
 	}
 	verifier := api.NewEd25519Verifier(ed25519.PublicKey(sigKey))
 
+	// Verify the detached signatures of the binary and config file with the
+	// signing key. Depending on --signature-mode a mismatch refuses to start
+	// or restricts the agent.
+	restricted, err := verifyStartupSignatures(ctx, sigCfg, cfg.DataDir, configData, client, identity.NodeID, verifier, logger)
+	if err != nil {
+		return fmt.Errorf("plexd up: %w", err)
+	}
+	if restricted {
+		restrictConfig(cfg)
+	}
+
+	// Record every change the agent makes to the host, attributed to the
+	// control plane event or reconciliation cycle behind it.
+	hostname, _ := os.Hostname()
+	hostChanges := auditfwd.NewRecorder(hostname)
+
 	// 6. Create SSE manager.
-	sseMgr := api.NewSSEManager(client, verifier, logger)
+	sseMgr := api.NewSSEManager(client, verifier, logger.With("component", "api"))
+	sseMgr.SetCauseTracker(hostChanges)
 
 	// Register signing_key_rotated SSE handler to update verifier keys.
 	sseMgr.RegisterHandler(api.EventSigningKeyRotated, func(_ context.Context, env api.SignedEnvelope) error {
@@ -115,8 +248,27 @@ func runUp(cmd *cobra.Command, _ []string) error {
 		return nil
 	})
 
+	// Create tunnel session manager: ssh_session_setup and port_forward_setup
+	// events open a mesh listener for the session and session_revoked events
+	// close it.
+	var tunnelMgr *tunnel.SessionManager
+	if cfg.Tunnel.Enabled {
+		tunnelMgr = tunnel.NewSessionManager(cfg.Tunnel, identity.MeshIP, logger)
+		tunnelReporter := tunnel.NewControlPlaneReporter(client, identity.NodeID, logger)
+		tunnelMgr.SetReporter(tunnelReporter)
+		tunnelMgr.SetRecordingUploader(tunnelReporter)
+		sseMgr.RegisterHandler(api.EventSSHSessionSetup, tunnel.HandleSSHSessionSetup(tunnelMgr, tunnelReporter))
+		sseMgr.RegisterHandler(api.EventPortForwardSetup, tunnel.HandlePortForwardSetup(tunnelMgr, tunnelReporter))
+		sseMgr.RegisterHandler(api.EventSessionRevoked, tunnel.HandleSessionRevoked(tunnelMgr, tunnelReporter))
+	}
+
 	// 7. Create reconciler.
 	reconciler := reconcile.NewReconciler(client, cfg.Reconcile, logger)
+	reconciler.SetCauseTracker(hostChanges)
+	reconciler.RegisterNamedHandler("feature_gates", gates.ReconcileHandler())
+	diag.Add("reconcile-history.json", func() ([]byte, error) {
+		return json.MarshalIndent(reconciler.History(), "", "  ")
+	})
 
 	// 8. Create heartbeat service.
 	hbCfg := agent.HeartbeatConfig{
@@ -126,32 +278,114 @@ func runUp(cmd *cobra.Command, _ []string) error {
 	hbCfg.ApplyDefaults()
 	heartbeat := agent.NewHeartbeatService(hbCfg, client, logger)
 	heartbeat.SetReconcileTrigger(reconciler)
-	heartbeat.SetOnAuthFailure(func() {
-		logger.Warn("heartbeat auth failure, attempting re-registration")
-		newIdentity, err := registrar.Register(ctx)
-		if err != nil {
-			logger.Error("re-registration failed", "error", err)
-			return
-		}
-		client.SetAuthToken(newIdentity.NodeSecretKey)
-		logger.Info("re-registration successful", "node_id", newIdentity.NodeID)
+	heartbeat.SetHealthSource(reconciler)
+	supervisor := agent.NewSupervisor(cfg.Supervisor, orch, watchdog, logger)
+	heartbeat.SetRestartSource(supervisor)
+	// When the control plane keeps rejecting the identity, the node is
+	// registered anew or halted; either way the run ends.
+	var authEnd atomic.Pointer[error]
+	heartbeat.SetOnAuthRejected(cfg.AuthRecovery.Threshold, func() {
+		err := recoverIdentity(ctx, cfg.AuthRecovery.Action, registrar, logger)
+		authEnd.Store(&err)
+		endRun()
 	})
 	heartbeat.SetOnRotateKeys(func() {
 		logger.Info("heartbeat signaled key rotation, triggering reconcile")
 		reconciler.TriggerReconcile()
 	})
+	heartbeat.SetOnDecommission(func() {
+		decommissioning.Store(true)
+		endRun()
+	})
+
+	// Resolve how privileged WireGuard operations are performed: directly
+	// when the agent holds CAP_NET_ADMIN, otherwise through the privileged
+	// helper. Without either, the agent keeps running and reports degraded.
+	priv := privhelper.Resolve(ctx, cfg.PrivHelper, privhelper.HasNetAdmin(), func() (wireguard.WGController, error) {
+		return wireguard.NewDefaultController(logger)
+	}, logger)
+	priv.Controller = wireguard.NewAuditedController(priv.Controller, hostChanges)
+	heartbeat.SetPrivilege(priv.Privilege, priv.Degraded())
+
+	// Create the mesh and its endpoint exchange; a degraded agent runs
+	// without them.
+	var exchanger *peerexchange.Exchanger
+	if !priv.Degraded() {
+		exchanger = setupMesh(ctx, cfg, priv.Controller, identity, client, sseMgr, reconciler, logger)
+	}
+	if exchanger != nil {
+		heartbeat.SetNATSource(exchanger)
+	}
 
 	// 9. Create node API server.
-	cfg.NodeAPI.DataDir = cfg.DataDir
-	cfg.NodeAPI.SecretAuthEnabled = true
 	nsk := []byte(identity.NodeSecretKey)
 	nodeAPISrv := nodeapi.NewServer(cfg.NodeAPI, client, nsk, logger)
+	nodeAPISrv.SetHealthReporter(reconciler)
+	nodeAPISrv.SetReconcileHistory(reconciler)
+	nodeAPISrv.SetReconcileController(reconciler)
+	nodeAPISrv.SetEventStream(sseMgr)
+	nodeAPISrv.SetLivenessReporter(watchdog)
+	nodeAPISrv.SetReadinessReporter(orch)
+	peerStats := wireguard.NewPeerStatsReader(cfg.WireGuard.InterfaceName, reconciler.Applied)
+	nodeAPISrv.SetMeshPeers(peerStats)
+	nodeAPISrv.SetLogLevels(logLevels)
+	nodeAPISrv.SetLogCapture(logCapture)
+	nodeAPISrv.SetSupportBundle(diag)
+	if cfg.Debug.Enabled {
+		nodeAPISrv.SetDebug(reconciler)
+		logger.Warn("debug endpoints enabled on the node API socket")
+	}
 
 	// Register nodeapi reconcile handler so cache updates on drift.
-	reconciler.RegisterHandler(nodeAPISrv.ReconcileHandler())
+	reconciler.RegisterNamedHandler("nodeapi", nodeAPISrv.ReconcileHandler())
+
+	// Create mesh diagnostics: probe peers over the tunnel, write the
+	// reachability matrix as a report entry, and summarize it in heartbeats.
+	meshDiag := meshdiag.NewDiagnostics(cfg.MeshDiag, meshdiag.NewUDPProber(cfg.MeshDiag.Port, cfg.MeshDiag.Timeout), identity.NodeID, logger)
+	meshDiag.SetReportWriter(nodeAPISrv)
+	reconciler.RegisterNamedHandler("meshdiag", meshDiag.ReconcileHandler())
+	heartbeat.SetMeshHealthSource(meshDiag)
+
+	// Create dead peer detection: mark peers without a recent handshake
+	// inactive in heartbeats and, if configured, withdraw their routes
+	// until they recover.
+	peerHealth := peerhealth.NewMonitor(cfg.PeerHealth, peerStats, logger)
+	peerHealth.SetReconcileTrigger(reconciler)
+	heartbeat.SetPeerHealthSource(peerHealth)
+
+	// Create secret sync: mirror selected secrets into a host directory
+	// that pods and other local consumers can mount.
+	secretSync := secretsync.NewSyncer(cfg.SecretSync, client, identity.NodeID, nsk, logger)
+	secretSync.SetAuditRecorder(hostChanges)
+	if cfg.SecretSync.Enabled {
+		reconciler.RegisterNamedHandler("secret_sync", secretSync.ReconcileHandler())
+		sseMgr.RegisterHandler(api.EventNodeSecretsUpdated, secretSync.HandleSecretsUpdated())
+	}
+
+	// Create container network manager: allocate addresses for the
+	// plexd-cni plugin and route other nodes' container prefixes.
+	if cfg.CNI.Enabled {
+		cniMgr := cni.NewManager(cfg.CNI, cni.NewAuditedHostNetwork(cni.NewHostNetwork(), hostChanges), cfg.WireGuard.InterfaceName, cfg.DataDir, logger)
+		if err := cniMgr.Load(); err != nil {
+			logger.Warn("container address allocations not loaded", "error", err)
+		}
+		reconciler.RegisterNamedHandler("cni", cniMgr.ReconcileHandler())
+		nodeAPISrv.SetContainerNetwork(cniMgr)
+		heartbeat.SetContainerNetworkSource(cniMgr)
+	}
+
+	// Apply the break-glass overrides file to every fetched state and
+	// report the overrides in effect in heartbeats.
+	overridesMgr := overrides.NewManager(cfg.Overrides, cni.NewAuditedHostNetwork(cni.NewHostNetwork(), hostChanges), cfg.WireGuard.InterfaceName, cfg.DataDir, logger)
+	if err := overridesMgr.Load(); err != nil {
+		logger.Warn("local overrides not loaded", "error", err)
+	}
+	reconciler.SetStateOverride(reconcile.StateOverrides{overridesMgr, peerHealth})
+	reconciler.RegisterNamedHandler("overrides", overridesMgr.ReconcileHandler())
+	heartbeat.SetLocalOverridesSource(overridesMgr)
 
 	// Register signing keys reconcile handler to update verifier on drift.
-	reconciler.RegisterHandler(func(_ context.Context, desired *api.StateResponse, diff reconcile.StateDiff) error {
+	reconciler.RegisterNamedHandler("signing_keys", func(_ context.Context, desired *api.StateResponse, diff reconcile.StateDiff) error {
 		if diff.SigningKeysChanged && diff.NewSigningKeys != nil {
 			current, prev, expires := decodeSigningKeys(*diff.NewSigningKeys, logger)
 			verifier.SetKeys(current, prev, expires)
@@ -160,42 +394,233 @@ func runUp(cmd *cobra.Command, _ []string) error {
 		return nil
 	})
 
-	// Wait group for all goroutines.
-	var wg sync.WaitGroup
+	// Create config reloader: on SIGHUP, config file changes, or
+	// POST /v1/config/reload, re-verify the config signature, re-read the
+	// config and apply the keys that can change at runtime. Everything else
+	// is reported as requiring a restart.
+	reloadConfig := signedConfigLoader(ctx, readConfig, parseConfig, sigCfg, cfg.DataDir, client, identity.NodeID, verifier, restricted, logger)
+	reloader := agent.NewConfigReloader(reloadConfig, cfg, cfgFile, logger)
+	reloader.RegisterApplier("log_level", func(c *agent.AgentConfig) error {
+		applyLogConfig(c)
+		return nil
+	})
+	reloader.RegisterApplier("log_levels", func(c *agent.AgentConfig) error {
+		applyLogConfig(c)
+		return nil
+	})
+	reloader.RegisterApplier("log_capture", func(c *agent.AgentConfig) error {
+		logCapture.Resize(c.LogCapture)
+		return nil
+	})
+	reloader.RegisterApplier("heartbeat.interval", func(c *agent.AgentConfig) error {
+		heartbeat.SetInterval(c.Heartbeat.Interval)
+		return nil
+	})
+	reloader.RegisterApplier("reconcile.interval", func(c *agent.AgentConfig) error {
+		reconciler.SetInterval(c.Reconcile.Interval)
+		return nil
+	})
+	nodeAPISrv.SetConfigReloader(reloader)
+	diag.Add("config.yaml", func() ([]byte, error) { return agent.MarshalConfig(reloader.Current(), true) })
+	notifyReload(ctx, reloader.TriggerReload)
+
+	// Create audit forwarder for agent-generated audit entries.
+	auditSources := []auditfwd.AuditSource{reloader, nodeAPISrv, hostChanges}
+	if tunnelMgr != nil {
+		auditSources = append(auditSources, tunnelMgr)
+	}
+	auditFwd := auditfwd.NewForwarder(cfg.AuditFwd, auditSources, client, identity.NodeID, hostname, logger)
+
+	// Create network change monitor: on address or default route changes,
+	// send a heartbeat, reconcile, and re-detect the node's endpoint
+	// immediately instead of waiting for timers.
+	netMon := netmon.NewMonitor(netmon.NewSystemWatcher(logger), cfg.NetMon, logger)
+	netMon.OnChange(heartbeat.TriggerHeartbeat)
+	netMon.OnChange(reconciler.TriggerReconcile)
+	if exchanger != nil {
+		netMon.OnChange(exchanger.TriggerDiscovery)
+	}
 
-	// 10. Start SSE manager.
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := sseMgr.Start(ctx, identity.NodeID); err != nil {
-			logger.Error("SSE manager stopped", "error", err)
-		}
-	}()
+	// 10. Register the subsystems with the orchestrator. Each one starts
+	// once the subsystems it depends on are ready: the event stream and the
+	// mesh first, then the node API, then the collectors and profiles that
+	// feed or use it.
+	orch.Add(agent.Subsystem{
+		Name: "sse",
+		Run: func(ctx context.Context) error {
+			return sseMgr.Start(ctx, identity.NodeID)
+		},
+		Ready: sseMgr.Running,
+		Check: func() (string, error) {
+			if !sseMgr.Running() {
+				return "", errors.New("event stream loop stopped")
+			}
+			if sseMgr.Connected() {
+				return "connected", nil
+			}
+			return "reconnecting", nil
+		},
+		Restartable: true,
+	})
+	orch.Add(agent.Subsystem{
+		Name: "reconciler",
+		Run: func(ctx context.Context) error {
+			return reconciler.Run(ctx, identity.NodeID)
+		},
+		Ready: reconciler.InitialCycleDone,
+		Check: agent.ProgressCheck(reconciler.LastCycle, func() time.Duration {
+			return reconcileStallFactor * reconciler.Interval()
+		}),
+		Restartable: true,
+	})
+	orch.Add(agent.Subsystem{
+		Name:        "heartbeat",
+		DependsOn:   []string{"reconciler"},
+		Run:         heartbeat.Run,
+		Restartable: true,
+	})
+	orch.Add(agent.Subsystem{
+		Name:      "nodeapi",
+		DependsOn: []string{"reconciler"},
+		Run: func(ctx context.Context) error {
+			return nodeAPISrv.Start(ctx, identity.NodeID)
+		},
+		Ready: nodeAPISrv.Serving,
+		Check: func() (string, error) {
+			if !nodeAPISrv.Serving() {
+				return "", errors.New("not serving")
+			}
+			return "serving", nil
+		},
+	})
+	if exchanger != nil && cfg.NAT.Enabled {
+		orch.Add(agent.Subsystem{
+			Name:      "peer_exchange",
+			DependsOn: []string{"reconciler"},
+			Run: func(ctx context.Context) error {
+				return exchanger.Run(ctx, identity.NodeID)
+			},
+			Restartable: true,
+		})
+	}
+	if cfg.NetMon.Enabled {
+		orch.Add(agent.Subsystem{
+			Name:        "netmon",
+			DependsOn:   []string{"reconciler", "heartbeat"},
+			Run:         netMon.Run,
+			Restartable: true,
+		})
+	}
+	orch.Add(agent.Subsystem{
+		Name:      "capabilities",
+		DependsOn: []string{"reconciler"},
+		Run: func(ctx context.Context) error {
+			return capMgr.Run(ctx, identity.NodeID)
+		},
+		Restartable: true,
+	})
+	orch.Add(agent.Subsystem{Name: "reload", Run: reloader.Run})
+	orch.Add(agent.Subsystem{
+		Name:      "config_watch",
+		DependsOn: []string{"reload"},
+		Run: func(ctx context.Context) error {
+			if err := agent.WatchConfigFile(ctx, cfgFile, reloader.TriggerReload); err != nil {
+				return fmt.Errorf("%w; reload with SIGHUP or the node API", err)
+			}
+			return nil
+		},
+	})
+	if cfg.AuditFwd.Enabled {
+		orch.Add(agent.Subsystem{
+			Name:        "audit_fwd",
+			DependsOn:   []string{"nodeapi"},
+			Run:         auditFwd.Run,
+			Restartable: true,
+		})
+	}
+	if cfg.Crash.Upload {
+		orch.Add(agent.Subsystem{
+			Name:      "crash_report",
+			DependsOn: []string{"nodeapi"},
+			Run: func(ctx context.Context) error {
+				if err := diag.Upload(nodeAPISrv); err != nil {
+					logger.Warn("crash report failed", "error", err)
+				}
+				<-ctx.Done()
+				return nil
+			},
+		})
+	}
+	if cfg.MeshDiag.Enabled {
+		orch.Add(agent.Subsystem{
+			Name:        "mesh_diag",
+			DependsOn:   []string{"reconciler", "nodeapi"},
+			Run:         meshDiag.Run,
+			Restartable: true,
+		})
+		responder := meshdiag.NewResponder(identity.MeshIP, cfg.MeshDiag.Port, logger)
+		orch.Add(agent.Subsystem{
+			Name:      "mesh_diag_responder",
+			DependsOn: []string{"reconciler"},
+			Run: func(ctx context.Context) error {
+				return responder.Run(ctx, cfg.MeshDiag.Interval)
+			},
+			Restartable: true,
+		})
+	}
+	if cfg.PeerHealth.Enabled {
+		orch.Add(agent.Subsystem{
+			Name:        "peer_health",
+			DependsOn:   []string{"reconciler"},
+			Run:         peerHealth.Run,
+			Restartable: true,
+		})
+	}
+	if cfg.SecretSync.Enabled {
+		orch.Add(agent.Subsystem{
+			Name:        "secret_sync",
+			DependsOn:   []string{"reconciler"},
+			Run:         secretSync.Run,
+			Restartable: true,
+		})
+	}
 
-	// 11. Start heartbeat.
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		_ = heartbeat.Run(ctx)
-	}()
+	// The supervisor restarts a subsystem that exceeds its goroutine
+	// budget or stalls, before the watchdog restarts the whole agent.
+	if cfg.Supervisor.Enabled {
+		orch.Add(agent.Subsystem{
+			Name: "supervisor",
+			Run:  supervisor.Run,
+		})
+	}
 
-	// 12. Start reconciler.
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := reconciler.Run(ctx, identity.NodeID); err != nil {
-			logger.Error("reconciler stopped", "error", err)
-		}
-	}()
+	// Additional mesh profiles register and run on their own once the node
+	// API serves; a failing profile does not affect the top-level mesh.
+	for _, p := range cfg.Profiles {
+		orch.Add(agent.Subsystem{
+			Name:      "profile:" + p.Name,
+			DependsOn: []string{"nodeapi"},
+			Run: func(ctx context.Context) error {
+				for {
+					err := runProfile(ctx, p, cfg.NodeAPI, nodeAPISrv, priv, logger)
+					if !errors.Is(err, errReregistered) {
+						return err
+					}
+				}
+			},
+		})
+	}
 
-	// 13. Start node API server.
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := nodeAPISrv.Start(ctx, identity.NodeID); err != nil {
-			logger.Error("node API server stopped", "error", err)
-		}
-	}()
+	// 11. Start the subsystems. Liveness checks are added as they become
+	// ready, and systemd is told that startup has finished once every
+	// subsystem has settled. A required subsystem that fails ends the run.
+	startErr := orch.Start(ctx)
+	if startErr != nil && ctx.Err() == nil {
+		logger.Error("startup failed", "error", startErr)
+		endRun()
+	} else {
+		startErr = nil
+	}
 
 	// Wait for shutdown signal.
 	<-ctx.Done()
@@ -203,10 +628,13 @@ func runUp(cmd *cobra.Command, _ []string) error {
 
 	// Graceful drain: stop SSE manager and wait for goroutines.
 	sseMgr.Shutdown()
+	if tunnelMgr != nil {
+		tunnelMgr.Shutdown()
+	}
 
 	done := make(chan struct{})
 	go func() {
-		wg.Wait()
+		orch.Wait()
 		close(done)
 	}()
 
@@ -217,15 +645,145 @@ func runUp(cmd *cobra.Command, _ []string) error {
 		logger.Warn("drain timeout exceeded, forcing exit")
 	}
 
+	if decommissioning.Load() {
+		return decommissionNode(cfg, client, identity.NodeID, stop, logger)
+	}
+	if err := authEnd.Load(); err != nil {
+		if errors.Is(*err, errIdentityRejected) {
+			return withExitCode(ExitIdentityRejected, fmt.Errorf("plexd up: %w", *err))
+		}
+		return fmt.Errorf("plexd up: %w", *err)
+	}
+	if cfg.Ephemeral {
+		retireEphemeralNode(cfg, client, identity.NodeID, logger)
+	}
+	if startErr != nil {
+		return fmt.Errorf("plexd up: %w", startErr)
+	}
+
 	logger.Info("plexd stopped")
 	return nil
 }
 
+// setupMesh sets up the WireGuard interface, replacing the one of a previous
+// run, and applies peers from the desired state and peer events. It returns
+// the endpoint exchange, which discovers the node's public endpoint with
+// STUN and moves peers to the endpoints the control plane returns for it,
+// or nil if the interface could not be set up.
+func setupMesh(ctx context.Context, cfg *agent.AgentConfig, ctrl wireguard.WGController, identity *registration.NodeIdentity, client *api.ControlPlane, sseMgr *api.SSEManager, reconciler *reconcile.Reconciler, logger *slog.Logger) *peerexchange.Exchanger {
+	wgMgr := wireguard.NewManager(ctrl, cfg.WireGuard, logger)
+	_ = wgMgr.Teardown()
+	if err := wgMgr.Setup(ctx, identity); err != nil {
+		logger.Error("mesh not set up", "error", err)
+		return nil
+	}
+	reconciler.RegisterNamedHandler("wireguard", wireguard.ReconcileHandler(wgMgr))
+	sseMgr.RegisterHandler(api.EventPeerAdded, wireguard.HandlePeerAdded(wgMgr))
+	sseMgr.RegisterHandler(api.EventPeerRemoved, wireguard.HandlePeerRemoved(wgMgr))
+	sseMgr.RegisterHandler(api.EventPeerKeyRotated, wireguard.HandlePeerKeyRotated(wgMgr))
+
+	discoverer := nat.NewDiscoverer(&nat.UDPSTUNClient{Timeout: cfg.NAT.Timeout}, cfg.NAT, cfg.WireGuard.ListenPort, logger)
+	exchanger := peerexchange.NewExchanger(discoverer, wgMgr, client, peerexchange.Config{Config: cfg.NAT}, logger)
+	exchanger.RegisterHandlers(sseMgr)
+	return exchanger
+}
+
+// decommissionNode retires the node after the control plane requested it:
+// it deregisters, removes the node's network state and identity, and then
+// disables and stops the service so the init system does not restart the
+// agent; in container mode the agent just exits. Deregistration failures do
+// not stop the local teardown, since the
+// control plane has already decided to retire the node. Uninstalling is not
+// done here because it would stop the service mid-way; run
+// 'plexd uninstall' afterwards. release undoes the daemon's signal handling.
+func decommissionNode(cfg *agent.AgentConfig, client *api.ControlPlane, nodeID string, release func(), logger *slog.Logger) error {
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	dec := agent.NewDecommissioner(cfg, client, logger)
+	if err := dec.Decommission(ctx, nodeID, agent.DecommissionOptions{Force: true}); err != nil {
+		logger.Error("decommission incomplete", "error", err)
+	}
+
+	// A container has no service to disable; the agent exits and the
+	// orchestrator decides whether to restart it.
+	if cfg.Container {
+		logger.Info("plexd decommissioned")
+		return nil
+	}
+
+	// Stopping the service from inside it ends this process, so it is the
+	// last step. Default signal handling lets the stop signal terminate the
+	// process instead of waiting for it to exit on its own.
+	release()
+	initSys, err := packaging.NewInitSystem(packaging.InitSystemAuto)
+	if err != nil {
+		logger.Warn("cannot disable service after decommission", "error", err)
+		return nil
+	}
+	if err := initSys.Disable(packaging.DefaultServiceName); err != nil {
+		logger.Warn("disable service", "error", err)
+	}
+	logger.Info("plexd decommissioned, stopping service")
+	if err := initSys.Stop(packaging.DefaultServiceName); err != nil {
+		logger.Warn("stop service", "error", err)
+	}
+	return nil
+}
+
+// retireEphemeralNode deregisters an ephemeral node on shutdown and removes
+// its network state and secrets. Unlike decommissionNode it leaves the
+// service enabled: the next start registers a new node. If deregistration
+// fails, the control plane removes the node once its ephemeral TTL expires.
+func retireEphemeralNode(cfg *agent.AgentConfig, client *api.ControlPlane, nodeID string, logger *slog.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	dec := agent.NewDecommissioner(cfg, client, logger)
+	if err := dec.Decommission(ctx, nodeID, agent.DecommissionOptions{Force: true}); err != nil {
+		logger.Error("ephemeral node cleanup incomplete", "error", err)
+		return
+	}
+	logger.Info("ephemeral node retired", "node_id", nodeID)
+}
+
+// errIdentityRejected ends a run after the control plane kept rejecting the
+// node identity and the node could not register anew.
+var errIdentityRejected = errors.New("control plane rejected the node identity")
+
+// errReregistered ends a run after the node registered anew. The node ID
+// and mesh IP change, so the agent must start over with the new identity.
+var errReregistered = errors.New("node registered anew, restart to use the new identity")
+
+// recoverIdentity handles a node identity the control plane keeps rejecting,
+// for example because the node was deleted server-side. With action
+// reregister it registers the node anew with the standing token and returns
+// errReregistered; otherwise, or if that fails, it returns
+// errIdentityRejected.
+func recoverIdentity(ctx context.Context, action string, registrar *registration.Registrar, logger *slog.Logger) error {
+	if action == agent.AuthRecoveryReregister {
+		identity, err := registrar.Reregister(ctx)
+		if err == nil {
+			logger.Warn("registered anew after the control plane rejected the node identity",
+				"node_id", identity.NodeID,
+				"mesh_ip", identity.MeshIP,
+			)
+			return errReregistered
+		}
+		logger.Error("re-registration failed", "error", err)
+	}
+	logger.Error("control plane rejects the node identity, halting; the node must be registered again")
+	return errIdentityRejected
+}
+
 // decodeSigningKeys decodes base64-encoded signing keys from an api.SigningKeys
 // struct into ed25519 public keys for use with the Ed25519Verifier.
 func decodeSigningKeys(keys api.SigningKeys, logger *slog.Logger) (current, previous ed25519.PublicKey, transitionExpires time.Time) {
 	if keys.Current != "" {
 		decoded, err := base64.StdEncoding.DecodeString(keys.Current)
+		if err == nil && len(decoded) != ed25519.PublicKeySize {
+			err = fmt.Errorf("key is %d bytes, want %d", len(decoded), ed25519.PublicKeySize)
+		}
 		if err != nil {
 			logger.Error("failed to decode current signing key", "error", err)
 		} else {
@@ -234,6 +792,9 @@ func decodeSigningKeys(keys api.SigningKeys, logger *slog.Logger) (current, prev
 	}
 	if keys.Previous != "" {
 		decoded, err := base64.StdEncoding.DecodeString(keys.Previous)
+		if err == nil && len(decoded) != ed25519.PublicKeySize {
+			err = fmt.Errorf("key is %d bytes, want %d", len(decoded), ed25519.PublicKeySize)
+		}
 		if err != nil {
 			logger.Error("failed to decode previous signing key", "error", err)
 		} else {
@@ -246,17 +807,169 @@ func decodeSigningKeys(keys api.SigningKeys, logger *slog.Logger) (current, prev
 	return current, previous, transitionExpires
 }
 
-func setupLogger(level string) *slog.Logger {
-	var lvl slog.Level
-	switch level {
-	case "debug":
-		lvl = slog.LevelDebug
-	case "warn":
-		lvl = slog.LevelWarn
-	case "error":
-		lvl = slog.LevelError
+// applyDerivedConfig applies the settings plexd up derives from other keys to
+// a freshly parsed config.
+func applyDerivedConfig(cfg *agent.AgentConfig) {
+	cfg.Registration.DataDir = cfg.DataDir
+	cfg.NodeAPI.DataDir = cfg.DataDir
+	cfg.NodeAPI.SecretAuthEnabled = true
+	if cfg.Crash.Dir == "" {
+		cfg.Crash.Dir = filepath.Join(cfg.DataDir, "crash")
+	}
+	if cfg.Tunnel.RecordingDir == "" {
+		cfg.Tunnel.RecordingDir = filepath.Join(cfg.DataDir, "recordings")
+	}
+	if cfg.Integrity.BinaryPath == "" {
+		cfg.Integrity.BinaryPath = executablePath()
+	}
+}
+
+// executablePath returns the resolved path of the running binary, or "" if
+// it cannot be determined.
+func executablePath() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return exe
+}
+
+// signatureConfig returns the integrity config of the signature checks at
+// startup and on reload. None of it is read from the config file whose
+// signature is checked, so editing the file cannot turn the check off or
+// point it at another signed file: the mode is --signature-mode, the config
+// path is the --config file unless it is absent in container mode, and the
+// binary is the running executable.
+func signatureConfig(mode string, configExists bool) (integrity.Config, error) {
+	c := integrity.Config{SignatureMode: mode, BinaryPath: executablePath()}
+	if configExists {
+		c.ConfigPath = cfgFile
+	}
+	c.ApplyDefaults()
+	if err := c.Validate(); err != nil {
+		return integrity.Config{}, err
+	}
+	return c, nil
+}
+
+// verifyStartupSignatures verifies the binary signature and the signature of
+// config, the content of sig.ConfigPath, and applies sig.SignatureMode: in
+// enforce mode a failure is returned, in restricted mode it is logged and
+// true is returned so that the agent starts without remote access and remote
+// execution features (see restrictConfig).
+func verifyStartupSignatures(ctx context.Context, sig integrity.Config, dataDir string, config []byte, client integrity.ViolationClient, nodeID string, sv integrity.SignatureVerifier, logger *slog.Logger) (bool, error) {
+	if sig.SignatureMode == integrity.SignatureModeOff {
+		return false, nil
+	}
+	store, err := integrity.NewStore(dataDir)
+	if err != nil {
+		return false, err
+	}
+	v := integrity.NewVerifier(sig, store, integrity.NewControlPlaneReporter(client), logger)
+	err = v.VerifySignatures(ctx, nodeID, sv, config)
+	switch {
+	case err == nil:
+		return false, nil
+	case sig.SignatureMode == integrity.SignatureModeEnforce:
+		return false, err
 	default:
-		lvl = slog.LevelInfo
+		logger.Warn("starting in restricted mode: tunnel sessions, user access, and actions are disabled", "error", err)
+		return true, nil
+	}
+}
+
+// restrictConfig disables the features restricted mode withholds: the
+// remote access features, tunnel sessions and bridge user access, and
+// remote execution, actions including hooks.
+func restrictConfig(cfg *agent.AgentConfig) {
+	cfg.Tunnel.Enabled = false
+	cfg.Bridge.UserAccessEnabled = false
+	cfg.Actions.Enabled = false
+}
+
+// signedConfigLoader returns the config loader of the config reloader. It
+// reads the config file once with read, verifies the signature of those
+// bytes with sig (see signatureConfig), and parses the same bytes with parse,
+// so a file swapped after the check is never applied. In enforce mode a
+// failure rejects the reload; in restricted mode the reloaded config is
+// restricted. An agent that started restricted stays restricted until it
+// restarts.
+func signedConfigLoader(ctx context.Context, read func() ([]byte, error), parse func([]byte) (*agent.AgentConfig, error), sig integrity.Config, dataDir string, client integrity.ViolationClient, nodeID string, sv integrity.SignatureVerifier, restricted bool, logger *slog.Logger) func() (*agent.AgentConfig, error) {
+	return func() (*agent.AgentConfig, error) {
+		data, err := read()
+		if err != nil {
+			return nil, err
+		}
+		failed := false
+		if sig.SignatureMode != integrity.SignatureModeOff {
+			store, err := integrity.NewStore(dataDir)
+			if err != nil {
+				return nil, err
+			}
+			v := integrity.NewVerifier(sig, store, integrity.NewControlPlaneReporter(client), logger)
+			if err := v.VerifyConfigSignature(ctx, nodeID, sv, data); err != nil {
+				if sig.SignatureMode == integrity.SignatureModeEnforce {
+					return nil, fmt.Errorf("config reload rejected: %w", err)
+				}
+				logger.Warn("reloaded config is restricted: tunnel sessions, user access, and actions are disabled", "error", err)
+				failed = true
+			}
+		}
+
+		next, err := parse(data)
+		if err != nil {
+			return nil, err
+		}
+		if restricted || failed {
+			restrictConfig(next)
+		}
+		return next, nil
+	}
+}
+
+// logLevels holds the default and component levels of the logger returned
+// by setupLogger. Changing them takes effect immediately, e.g. on a config
+// reload or through PUT /v1/debug/loglevel.
+var logLevels = logging.NewLevels(slog.LevelInfo)
+
+// logCapture keeps the recent lines of the logger returned by setupLogger
+// for GET /v1/debug/logs. It keeps nothing until log_capture is set.
+var logCapture = logging.NewRing(0)
+
+func setupLogger(level string) *slog.Logger {
+	logLevels.Set("", parseLogLevel(level))
+	out := io.MultiWriter(os.Stderr, logCapture)
+	next := slog.NewTextHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return slog.New(logging.NewHandler(next, logLevels))
+}
+
+// applyLogConfig sets the log levels and the log capture size of cfg,
+// replacing levels set through the node API.
+func applyLogConfig(cfg *agent.AgentConfig) {
+	components := make(map[string]slog.Level, len(cfg.LogLevels))
+	for component, level := range cfg.LogLevels {
+		components[component] = parseLogLevel(level)
+	}
+	logLevels.Replace(parseLogLevel(cfg.LogLevel), components)
+	logCapture.Resize(cfg.LogCapture)
+}
+
+// capturedLogs returns the lines kept by logCapture for diagnostics bundles.
+func capturedLogs() ([]byte, error) {
+	if logCapture.Size() == 0 {
+		return nil, errors.New("log capture disabled (set log_capture)")
+	}
+	return []byte(strings.Join(logCapture.Lines("", 0), "\n") + "\n"), nil
+}
+
+// parseLogLevel parses level, falling back to info for an unknown level.
+func parseLogLevel(level string) slog.Level {
+	l, err := logging.ParseLevel(level)
+	if err != nil {
+		return slog.LevelInfo
 	}
-	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: lvl}))
+	return l
 }
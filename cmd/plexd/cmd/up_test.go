@@ -1,13 +1,19 @@
 package cmd
 
 import (
+	"context"
 	"crypto/ed25519"
 	"encoding/base64"
+	"errors"
 	"log/slog"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/plexsphere/plexd/internal/agent"
 	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/integrity"
 )
 
 func TestDecodeSigningKeys_CurrentOnly(t *testing.T) {
@@ -80,6 +86,23 @@ func TestDecodeSigningKeys_InvalidBase64(t *testing.T) {
 	}
 }
 
+func TestDecodeSigningKeys_WrongLength(t *testing.T) {
+	keys := api.SigningKeys{
+		Current:  base64.StdEncoding.EncodeToString(make([]byte, 31)),
+		Previous: base64.StdEncoding.EncodeToString(make([]byte, 64)),
+	}
+	logger := slog.Default()
+
+	current, previous, _ := decodeSigningKeys(keys, logger)
+
+	if len(current) != 0 {
+		t.Errorf("current should be nil for a 31-byte key, got len %d", len(current))
+	}
+	if len(previous) != 0 {
+		t.Errorf("previous should be nil for a 64-byte key, got len %d", len(previous))
+	}
+}
+
 func TestDecodeSigningKeys_Empty(t *testing.T) {
 	keys := api.SigningKeys{}
 	logger := slog.Default()
@@ -96,3 +119,297 @@ func TestDecodeSigningKeys_Empty(t *testing.T) {
 		t.Errorf("expires should be zero for empty keys, got %v", expires)
 	}
 }
+
+type fakeViolationClient struct {
+	reports []api.IntegrityViolationReport
+}
+
+func (f *fakeViolationClient) ReportIntegrityViolation(_ context.Context, _ string, req api.IntegrityViolationReport) error {
+	f.reports = append(f.reports, req)
+	return nil
+}
+
+// tamperedConfig is an unsigned config that tries to turn signature
+// verification off and point it at another file.
+const tamperedConfig = `api:
+  baseurl: "https://example.com"
+registration:
+  datadir: /tmp/plexd
+node_api:
+  datadir: /tmp/plexd
+heartbeat:
+  nodeid: "node-1"
+integrity:
+  enabled: false
+  signaturemode: off
+  configpath: /etc/plexd/other.yaml
+`
+
+// signedStartup holds a binary and config file signed by the same key and
+// the signature config plexd up uses for them.
+type signedStartup struct {
+	sig    integrity.Config
+	dir    string
+	config []byte
+	sv     *api.Ed25519Verifier
+}
+
+// newSignedStartup returns a signed binary and config file, with the config
+// file replaced by tamperedConfig when tamper is set. mode is the
+// --signature-mode.
+func newSignedStartup(t *testing.T, mode string, tamper bool) *signedStartup {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(content)))
+		if err := os.WriteFile(path+integrity.SignatureSuffix, []byte(sig), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	s := &signedStartup{dir: dir, sv: api.NewEd25519Verifier(pub)}
+	s.sig = integrity.Config{
+		Enabled:        true,
+		VerifyInterval: integrity.DefaultVerifyInterval,
+		SignatureMode:  mode,
+		BinaryPath:     write("plexd", "binary"),
+		ConfigPath:     write("config.yaml", "api:\n  baseurl: \"https://example.com\"\nlog_level: info\n"),
+	}
+	if tamper {
+		if err := os.WriteFile(s.sig.ConfigPath, []byte(tamperedConfig), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.config = s.read(t)
+	return s
+}
+
+// read returns the current content of the config file.
+func (s *signedStartup) read(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile(s.sig.ConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// verify runs verifyStartupSignatures on s.
+func (s *signedStartup) verify(client integrity.ViolationClient) (bool, error) {
+	return verifyStartupSignatures(context.Background(), s.sig, s.dir, s.config, client, "node-1", s.sv, slog.Default())
+}
+
+// loader returns the reload config loader for s, reading with read and
+// parsing with parse.
+func (s *signedStartup) loader(read func() ([]byte, error), parse func([]byte) (*agent.AgentConfig, error), client integrity.ViolationClient, restricted bool) func() (*agent.AgentConfig, error) {
+	if read == nil {
+		read = func() ([]byte, error) { return os.ReadFile(s.sig.ConfigPath) }
+	}
+	return signedConfigLoader(context.Background(), read, parse, s.sig, s.dir, client, "node-1", s.sv, restricted, slog.Default())
+}
+
+func TestVerifyStartupSignatures_Valid(t *testing.T) {
+	s := newSignedStartup(t, integrity.SignatureModeEnforce, false)
+	client := &fakeViolationClient{}
+
+	restricted, err := s.verify(client)
+	if err != nil || restricted {
+		t.Errorf("verifyStartupSignatures() = %v, %v; want false, nil", restricted, err)
+	}
+	if len(client.reports) != 0 {
+		t.Errorf("unexpected reports: %+v", client.reports)
+	}
+}
+
+func TestVerifyStartupSignatures_Enforce(t *testing.T) {
+	s := newSignedStartup(t, integrity.SignatureModeEnforce, true)
+	client := &fakeViolationClient{}
+
+	_, err := s.verify(client)
+	if !errors.Is(err, integrity.ErrSignatureInvalid) {
+		t.Fatalf("verifyStartupSignatures() error = %v, want ErrSignatureInvalid", err)
+	}
+	if len(client.reports) != 1 || client.reports[0].Type != integrity.ViolationTypeConfig {
+		t.Errorf("reports = %+v, want one config violation", client.reports)
+	}
+}
+
+func TestVerifyStartupSignatures_TamperedConfigCannotTurnModeOff(t *testing.T) {
+	s := newSignedStartup(t, integrity.SignatureModeEnforce, true)
+
+	cfg, err := agent.ParseConfigData(s.sig.ConfigPath, s.config, agent.ConfigOverrides{})
+	if err != nil {
+		t.Fatalf("ParseConfigData: %v", err)
+	}
+	if cfg.Integrity.SignatureMode != integrity.SignatureModeOff || cfg.Integrity.ConfigPath != "" {
+		t.Errorf("file set SignatureMode=%q ConfigPath=%q, want the default and empty", cfg.Integrity.SignatureMode, cfg.Integrity.ConfigPath)
+	}
+
+	// The check uses the --signature-mode config, not the file's.
+	if _, err := s.verify(&fakeViolationClient{}); !errors.Is(err, integrity.ErrSignatureInvalid) {
+		t.Fatalf("verifyStartupSignatures() error = %v, want ErrSignatureInvalid", err)
+	}
+}
+
+func TestVerifyStartupSignatures_Restricted(t *testing.T) {
+	s := newSignedStartup(t, integrity.SignatureModeRestricted, true)
+	client := &fakeViolationClient{}
+
+	restricted, err := s.verify(client)
+	if err != nil || !restricted {
+		t.Errorf("verifyStartupSignatures() = %v, %v; want true, nil", restricted, err)
+	}
+	if len(client.reports) != 1 {
+		t.Errorf("reports = %+v, want 1", client.reports)
+	}
+}
+
+func TestVerifyStartupSignatures_Off(t *testing.T) {
+	s := newSignedStartup(t, integrity.SignatureModeOff, true)
+	client := &fakeViolationClient{}
+
+	restricted, err := s.verify(client)
+	if err != nil || restricted {
+		t.Errorf("verifyStartupSignatures() = %v, %v; want false, nil", restricted, err)
+	}
+	if len(client.reports) != 0 {
+		t.Errorf("unexpected reports: %+v", client.reports)
+	}
+}
+
+func TestSignatureConfig(t *testing.T) {
+	sig, err := signatureConfig(integrity.SignatureModeEnforce, true)
+	if err != nil {
+		t.Fatalf("signatureConfig: %v", err)
+	}
+	if !sig.Enabled || sig.SignatureMode != integrity.SignatureModeEnforce || sig.ConfigPath != cfgFile {
+		t.Errorf("signatureConfig() = %+v", sig)
+	}
+
+	if sig, _ := signatureConfig(integrity.SignatureModeEnforce, false); sig.ConfigPath != "" {
+		t.Errorf("ConfigPath = %q without a config file, want empty", sig.ConfigPath)
+	}
+	if _, err := signatureConfig("lenient", true); err == nil {
+		t.Error("signatureConfig accepted an unknown mode")
+	}
+}
+
+func TestRestrictConfig(t *testing.T) {
+	cfg := &agent.AgentConfig{}
+	cfg.Tunnel.Enabled = true
+	cfg.Bridge.UserAccessEnabled = true
+	cfg.Actions.Enabled = true
+
+	restrictConfig(cfg)
+	if cfg.Tunnel.Enabled || cfg.Bridge.UserAccessEnabled || cfg.Actions.Enabled {
+		t.Errorf("restricted config enables tunnel=%v user_access=%v actions=%v",
+			cfg.Tunnel.Enabled, cfg.Bridge.UserAccessEnabled, cfg.Actions.Enabled)
+	}
+}
+
+// reloadedConfig returns a parser for a config that enables the restricted
+// features.
+func reloadedConfig() func([]byte) (*agent.AgentConfig, error) {
+	return func([]byte) (*agent.AgentConfig, error) {
+		next := &agent.AgentConfig{LogLevel: "debug"}
+		next.Tunnel.Enabled = true
+		next.Actions.Enabled = true
+		return next, nil
+	}
+}
+
+func TestSignedConfigLoader_EnforceRejectsTamperedConfig(t *testing.T) {
+	s := newSignedStartup(t, integrity.SignatureModeEnforce, true)
+	client := &fakeViolationClient{}
+
+	next, err := s.loader(nil, reloadedConfig(), client, false)()
+	if !errors.Is(err, integrity.ErrSignatureInvalid) || next != nil {
+		t.Fatalf("load() = %v, %v; want nil, ErrSignatureInvalid", next, err)
+	}
+	if len(client.reports) != 1 || client.reports[0].Type != integrity.ViolationTypeConfig {
+		t.Errorf("reports = %+v, want one config violation", client.reports)
+	}
+}
+
+func TestSignedConfigLoader_ParsesVerifiedBytes(t *testing.T) {
+	s := newSignedStartup(t, integrity.SignatureModeEnforce, false)
+	signed := s.config
+
+	// The file is swapped right after it was read: the bytes read, which
+	// are signed, are parsed.
+	read := func() ([]byte, error) {
+		if err := os.WriteFile(s.sig.ConfigPath, []byte(tamperedConfig), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return signed, nil
+	}
+	var parsed []byte
+	parse := func(data []byte) (*agent.AgentConfig, error) {
+		parsed = data
+		return &agent.AgentConfig{}, nil
+	}
+	if _, err := s.loader(read, parse, &fakeViolationClient{}, false)(); err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if string(parsed) != string(signed) {
+		t.Errorf("parsed %q, want the verified bytes %q", parsed, signed)
+	}
+
+	// Bytes read while a signed file is on disk are verified, not the file.
+	if err := os.WriteFile(s.sig.ConfigPath, signed, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	read = func() ([]byte, error) { return []byte(tamperedConfig), nil }
+	next, err := s.loader(read, parse, &fakeViolationClient{}, false)()
+	if !errors.Is(err, integrity.ErrSignatureInvalid) || next != nil {
+		t.Fatalf("load() = %v, %v; want nil, ErrSignatureInvalid", next, err)
+	}
+}
+
+func TestSignedConfigLoader_EnforceAcceptsSignedConfig(t *testing.T) {
+	s := newSignedStartup(t, integrity.SignatureModeEnforce, false)
+
+	next, err := s.loader(nil, reloadedConfig(), &fakeViolationClient{}, false)()
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if next.LogLevel != "debug" || !next.Tunnel.Enabled {
+		t.Errorf("load() = %+v, want the reloaded config unchanged", next)
+	}
+}
+
+func TestSignedConfigLoader_RestrictedRestrictsTamperedConfig(t *testing.T) {
+	s := newSignedStartup(t, integrity.SignatureModeRestricted, true)
+
+	next, err := s.loader(nil, reloadedConfig(), &fakeViolationClient{}, false)()
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if next.Tunnel.Enabled || next.Actions.Enabled {
+		t.Errorf("reloaded config enables tunnel=%v actions=%v, want restricted", next.Tunnel.Enabled, next.Actions.Enabled)
+	}
+	if next.LogLevel != "debug" {
+		t.Errorf("LogLevel = %q, want the reloaded value", next.LogLevel)
+	}
+}
+
+func TestSignedConfigLoader_StaysRestricted(t *testing.T) {
+	s := newSignedStartup(t, integrity.SignatureModeRestricted, false)
+
+	next, err := s.loader(nil, reloadedConfig(), &fakeViolationClient{}, true)()
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if next.Tunnel.Enabled || next.Actions.Enabled {
+		t.Errorf("reloaded config enables tunnel=%v actions=%v, want restricted until restart", next.Tunnel.Enabled, next.Actions.Enabled)
+	}
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/plexsphere/plexd/internal/agent"
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/packaging"
+	"github.com/plexsphere/plexd/internal/registration"
+)
+
+var (
+	upgradeFile          string
+	upgradeVersion       string
+	upgradeChecksum      string
+	upgradeHealthTimeout time.Duration
+	upgradeInitSys       string
+	upgradeRootless      bool
+	upgradeUser          string
+)
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Replace the installed plexd binary and restart the service",
+	Long: "Replace the installed plexd binary with --file, or with --version downloaded\n" +
+		"from the control plane, and restart the service. The binary must match\n" +
+		"--checksum. If the agent does not report healthy within --health-timeout,\n" +
+		"the previous binary is restored and the service restarted again.\n" +
+		"Configuration, identity, and state are preserved.",
+	RunE: runUpgrade,
+}
+
+func init() {
+	upgradeCmd.Flags().StringVar(&upgradeFile, "file", "", "path to the new plexd binary")
+	upgradeCmd.Flags().StringVar(&upgradeVersion, "version", "", "plexd version to download from the control plane")
+	upgradeCmd.Flags().StringVar(&upgradeChecksum, "checksum", "", "expected SHA-256 checksum of the new binary (hex)")
+	upgradeCmd.Flags().DurationVar(&upgradeHealthTimeout, "health-timeout", packaging.DefaultUpgradeHealthTimeout, "time the upgraded agent has to report healthy before rollback")
+	upgradeCmd.Flags().StringVar(&upgradeInitSys, "init-system", packaging.InitSystemAuto, "init system: auto, systemd, openrc, sysv, scm, or launchd")
+	registerFlagValues(upgradeCmd, "init-system", initSystems...)
+	upgradeCmd.Flags().BoolVar(&upgradeRootless, "rootless", false, "the agent runs as an unprivileged user (as installed with --rootless)")
+	upgradeCmd.Flags().StringVar(&upgradeUser, "user", packaging.DefaultUser, "service user for --rootless")
+	upgradeCmd.MarkFlagsMutuallyExclusive("file", "version")
+	upgradeCmd.MarkFlagsOneRequired("file", "version")
+	_ = upgradeCmd.MarkFlagRequired("checksum")
+	rootCmd.AddCommand(upgradeCmd)
+}
+
+func runUpgrade(cmd *cobra.Command, _ []string) error {
+	if err := checkNotInContainer(cmd); err != nil {
+		return fmt.Errorf("plexd upgrade: %w", err)
+	}
+	cfg, err := agent.ParseConfig(cfgFile, configOverrides(cmd))
+	if err != nil {
+		return withExitCode(ExitConfig, fmt.Errorf("plexd upgrade: %w", err))
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	ctx := context.Background()
+
+	src, err := openUpgradeBinary(ctx, cfg, logger)
+	if err != nil {
+		return fmt.Errorf("plexd upgrade: %w", err)
+	}
+	defer src.Close()
+
+	initSys, err := packaging.NewInitSystem(upgradeInitSys)
+	if err != nil {
+		return fmt.Errorf("plexd upgrade: %w", err)
+	}
+	installer := packaging.NewInstaller(packaging.InstallConfig{
+		DataDir:  cfg.DataDir,
+		Rootless: upgradeRootless,
+		User:     upgradeUser,
+	}, initSys, packaging.NewRootChecker(), logger)
+
+	socketPath := cfg.NodeAPI.SocketPath
+	if socketPath == "" {
+		socketPath = defaultSocketPath()
+	}
+	err = installer.Upgrade(ctx, src, packaging.UpgradeOptions{
+		Checksum:      upgradeChecksum,
+		HealthCheck:   func(ctx context.Context) error { return checkAgentHealth(ctx, socketPath) },
+		HealthTimeout: upgradeHealthTimeout,
+	})
+	if err != nil {
+		return fmt.Errorf("plexd upgrade: %w", err)
+	}
+
+	writeResult(cmd, nil, func(w io.Writer) {
+		fmt.Fprintln(w, "plexd upgraded successfully")
+	})
+	return nil
+}
+
+// openUpgradeBinary opens --file, or downloads --version for this platform
+// with the node's credentials.
+func openUpgradeBinary(ctx context.Context, cfg *agent.AgentConfig, logger *slog.Logger) (io.ReadCloser, error) {
+	if upgradeFile != "" {
+		f, err := os.Open(upgradeFile)
+		if err != nil {
+			return nil, fmt.Errorf("open binary: %w", err)
+		}
+		return f, nil
+	}
+
+	identity, err := registration.LoadIdentity(cfg.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("load identity: %w", err)
+	}
+	client, err := api.NewControlPlane(cfg.API, buildVersion, logger)
+	if err != nil {
+		return nil, fmt.Errorf("create client: %w", err)
+	}
+	client.SetAuthToken(identity.NodeSecretKey)
+
+	body, err := client.FetchArtifact(ctx, upgradeVersion, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return nil, fmt.Errorf("download plexd %s: %w", upgradeVersion, err)
+	}
+	return body, nil
+}
+
+// checkAgentHealth returns nil if the local agent answers GET /healthz
+// with 200.
+func checkAgentHealth(ctx context.Context, socketPath string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, socketURL("/healthz"), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := newSocketClient(socketPath).Do(req)
+	if err != nil {
+		return fmt.Errorf("agent not reachable at %s: %w", socketPath, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("agent reports " + resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckAgentHealth(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "api.sock")
+	if err := checkAgentHealth(context.Background(), socketPath); err == nil {
+		t.Fatal("checkAgentHealth() = nil with no agent running")
+	}
+
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	stalled := true
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			http.NotFound(w, r)
+			return
+		}
+		if stalled {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("alive"))
+	})}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	if err := checkAgentHealth(context.Background(), socketPath); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("checkAgentHealth(stalled) = %v, want 503 error", err)
+	}
+	stalled = false
+	if err := checkAgentHealth(context.Background(), socketPath); err != nil {
+		t.Errorf("checkAgentHealth(alive) = %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/spf13/cobra"
+
+	"github.com/plexsphere/plexd/internal/bridge"
+	"github.com/plexsphere/plexd/internal/nodeapi"
+)
+
+var (
+	userAccessExportPeer       string
+	userAccessExportEndpoint   string
+	userAccessExportPrivateKey string
+)
+
+// userAccessExportResult is the JSON result of plexd useraccess export.
+// Config is the wg-quick configuration.
+type userAccessExportResult struct {
+	PublicKey string `json:"public_key"`
+	Label     string `json:"label"`
+	Config    string `json:"config"`
+}
+
+var userAccessCmd = &cobra.Command{
+	Use:   "useraccess",
+	Short: "User access (bridge mode)",
+}
+
+var userAccessExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export a WireGuard client config for a user access peer",
+	Long: "Connect to the local agent via Unix socket and print a wg-quick configuration for a user access peer, " +
+		"including the DNS servers and search domains pushed by the control plane.",
+	RunE: runUserAccessExport,
+}
+
+func init() {
+	userAccessExportCmd.Flags().StringVar(&userAccessExportPeer, "peer", "", "peer label or public key (required)")
+	userAccessExportCmd.Flags().StringVar(&userAccessExportEndpoint, "endpoint", "", "host or host:port clients use to reach this bridge (default: the configured user access endpoint)")
+	userAccessExportCmd.Flags().StringVar(&userAccessExportPrivateKey, "private-key", "", "client private key to embed (default: placeholder)")
+	_ = userAccessExportCmd.MarkFlagRequired("peer")
+	userAccessCmd.AddCommand(userAccessExportCmd)
+	rootCmd.AddCommand(userAccessCmd)
+}
+
+func runUserAccessExport(cmd *cobra.Command, _ []string) error {
+	e, err := fetchUserAccessExport(defaultSocketPath())
+	if err != nil {
+		return fmt.Errorf("plexd useraccess export: %w", err)
+	}
+	peer, ok := e.FindPeer(userAccessExportPeer)
+	if !ok {
+		return fmt.Errorf("plexd useraccess export: unknown peer %q", userAccessExportPeer)
+	}
+	conf, err := e.ClientConfig(peer, userAccessExportPrivateKey, userAccessExportEndpoint)
+	if err != nil {
+		return fmt.Errorf("plexd useraccess export: %w", err)
+	}
+	res := userAccessExportResult{PublicKey: peer.PublicKey, Label: peer.Label, Config: conf}
+	writeResult(cmd, res, func(w io.Writer) { fmt.Fprint(w, conf) })
+	return nil
+}
+
+// fetchUserAccessExport reads the user access client export from the agent's
+// report entry.
+func fetchUserAccessExport(socketPath string) (bridge.UserAccessExport, error) {
+	var e bridge.UserAccessExport
+	resp, err := socketGet(socketPath, "/v1/state/report/"+bridge.UserAccessExportKey)
+	if err != nil {
+		return e, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return e, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return e, fmt.Errorf("no user access export (user access disabled or not a bridge node)")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return e, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var entry nodeapi.ReportEntry
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return e, fmt.Errorf("parse response: %w", err)
+	}
+	if err := json.Unmarshal(entry.Payload, &e); err != nil {
+		return e, fmt.Errorf("parse export: %w", err)
+	}
+	return e, nil
+}
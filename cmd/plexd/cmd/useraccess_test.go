@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/bridge"
+	"github.com/plexsphere/plexd/internal/nodeapi"
+)
+
+func TestUserAccessExportCommand_AgentNotRunning(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"useraccess", "export", "--peer", "alice", "--endpoint", "bridge.example.com"})
+
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatal("expected error when agent is not running")
+	}
+	if !strings.Contains(err.Error(), "plexd useraccess export") {
+		t.Errorf("error should mention 'plexd useraccess export', got: %v", err)
+	}
+}
+
+// startFakeUserAccessAgent serves entry at the user access export report key
+// on a Unix socket. A nil entry is served as 404.
+func startFakeUserAccessAgent(t *testing.T, entry *nodeapi.ReportEntry) string {
+	t.Helper()
+	socketPath := filepath.Join(t.TempDir(), "api.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/state/report/"+bridge.UserAccessExportKey, func(w http.ResponseWriter, _ *http.Request) {
+		if entry == nil {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "not found"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(entry)
+	})
+	srv := &http.Server{Handler: mux}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { srv.Close() })
+	return socketPath
+}
+
+func TestFetchUserAccessExport(t *testing.T) {
+	payload, _ := json.Marshal(bridge.UserAccessExport{
+		InterfaceName: "wg-access",
+		ListenPort:    51822,
+		DNSServers:    []string{"10.0.0.53"},
+		SearchDomains: []string{"corp.example"},
+		Peers:         []bridge.UserAccessExportPeer{{PublicKey: "pk-1", Label: "alice", AllowedIPs: []string{"10.99.0.1/32"}}},
+	})
+	socketPath := startFakeUserAccessAgent(t, &nodeapi.ReportEntry{Key: bridge.UserAccessExportKey, Payload: payload})
+
+	e, err := fetchUserAccessExport(socketPath)
+	if err != nil {
+		t.Fatalf("fetchUserAccessExport: %v", err)
+	}
+	if e.InterfaceName != "wg-access" || len(e.Peers) != 1 || e.SearchDomains[0] != "corp.example" {
+		t.Errorf("export = %+v", e)
+	}
+}
+
+func TestFetchUserAccessExport_NotFound(t *testing.T) {
+	socketPath := startFakeUserAccessAgent(t, nil)
+
+	_, err := fetchUserAccessExport(socketPath)
+	if err == nil || !strings.Contains(err.Error(), "no user access export") {
+		t.Errorf("err = %v, want no user access export", err)
+	}
+}
@@ -35,8 +35,9 @@ trap cleanup EXIT
 detect_os() {
     OS="$(uname -s)"
     case "${OS}" in
-        Linux) ;;
-        *) fatal "unsupported operating system: ${OS}. Only Linux is supported." ;;
+        Linux)  PLATFORM="linux" ;;
+        Darwin) PLATFORM="darwin" ;;
+        *) fatal "unsupported operating system: ${OS}. Supported: Linux, Darwin (macOS)." ;;
     esac
 }
 
@@ -44,8 +45,8 @@ detect_arch() {
     MACHINE="$(uname -m)"
     case "${MACHINE}" in
         x86_64)  ARCH="amd64" ;;
-        aarch64) ARCH="arm64" ;;
-        *)       fatal "unsupported architecture: ${MACHINE}. Supported: x86_64 (amd64), aarch64 (arm64)." ;;
+        aarch64|arm64) ARCH="arm64" ;;
+        *)       fatal "unsupported architecture: ${MACHINE}. Supported: x86_64 (amd64), aarch64/arm64 (arm64)." ;;
     esac
 }
 
@@ -69,6 +70,55 @@ find_download_cmd() {
     fi
 }
 
+# --- Init system detection ---
+
+detect_init_system() {
+    if [ "${PLATFORM:-}" = "darwin" ] && command -v launchctl >/dev/null 2>&1; then
+        INIT_SYSTEM="launchd"
+    elif [ -d /run/systemd/system ] && command -v systemctl >/dev/null 2>&1; then
+        INIT_SYSTEM="systemd"
+    elif [ -d /run/openrc ] && command -v openrc-run >/dev/null 2>&1; then
+        INIT_SYSTEM="openrc"
+    elif [ -d /etc/init.d ] && { command -v update-rc.d >/dev/null 2>&1 || command -v chkconfig >/dev/null 2>&1; }; then
+        INIT_SYSTEM="sysv"
+    else
+        fatal "no supported init system found (launchd, systemd, openrc, sysv)"
+    fi
+}
+
+start_service() {
+    case "${INIT_SYSTEM}" in
+        systemd)
+            systemctl enable --now plexd
+            ;;
+        openrc)
+            rc-update add plexd default
+            rc-service plexd start
+            ;;
+        sysv)
+            if command -v update-rc.d >/dev/null 2>&1; then
+                update-rc.d plexd defaults
+            else
+                chkconfig --add plexd
+            fi
+            /etc/init.d/plexd start
+            ;;
+        launchd)
+            launchctl enable system/io.plexsphere.plexd
+            launchctl bootstrap system /Library/LaunchDaemons/io.plexsphere.plexd.plist
+            ;;
+    esac
+}
+
+# config_dir prints the platform's default plexd configuration directory.
+config_dir() {
+    if [ "${PLATFORM:-}" = "darwin" ]; then
+        echo "/usr/local/etc/plexd"
+    else
+        echo "/etc/plexd"
+    fi
+}
+
 # --- Download function ---
 
 download() {
@@ -145,13 +195,13 @@ main() {
     find_sha256_cmd
     find_download_cmd
 
-    info "detected: Linux ${ARCH}"
+    info "detected: ${OS} ${ARCH}"
 
     # Create temp directory
     TMPDIR_PATH="$(mktemp -d)"
 
     # Download binary and checksum
-    BINARY_NAME="plexd-linux-${ARCH}"
+    BINARY_NAME="plexd-${PLATFORM}-${ARCH}"
     BINARY_URL="${PLEXD_ARTIFACT_URL}/${VERSION}/${BINARY_NAME}"
     CHECKSUM_URL="${PLEXD_ARTIFACT_URL}/${VERSION}/checksums.sha256"
 
@@ -168,8 +218,9 @@ main() {
     chmod +x "${TMPDIR_PATH}/${BINARY_NAME}"
 
     # Run plexd install
-    info "running plexd install"
-    set -- install
+    detect_init_system
+    info "running plexd install (init system: ${INIT_SYSTEM})"
+    set -- install --init-system "${INIT_SYSTEM}"
     if [ -n "${TOKEN}" ]; then
         set -- "$@" --token "${TOKEN}"
     fi
@@ -181,7 +232,7 @@ main() {
     # Start service unless --no-start
     if [ -z "${NO_START}" ]; then
         info "enabling and starting plexd service"
-        systemctl enable --now plexd
+        start_service
     else
         info "skipping service start (--no-start)"
     fi
@@ -190,15 +241,31 @@ main() {
     info "---"
     info "plexd installed successfully"
     info "  binary:  /usr/local/bin/plexd"
-    info "  config:  /etc/plexd/config.yaml"
-    info "  service: plexd.service"
+    info "  config:  $(config_dir)/config.yaml"
+    info "  service: plexd (${INIT_SYSTEM})"
     info ""
     info "next steps:"
     if [ -z "${TOKEN}" ]; then
         info "  1. Provide a bootstrap token: plexd join"
     fi
-    info "  - Check status: systemctl status plexd"
-    info "  - View logs:    journalctl -u plexd -f"
+    case "${INIT_SYSTEM}" in
+        systemd)
+            info "  - Check status: systemctl status plexd"
+            info "  - View logs:    journalctl -u plexd -f"
+            ;;
+        openrc)
+            info "  - Check status: rc-service plexd status"
+            info "  - View logs:    tail -f /var/log/plexd.log"
+            ;;
+        sysv)
+            info "  - Check status: /etc/init.d/plexd status"
+            info "  - View logs:    tail -f /var/log/plexd.log"
+            ;;
+        launchd)
+            info "  - Check status: launchctl print system/io.plexsphere.plexd"
+            info "  - View logs:    tail -f /Library/Logs/plexd.log"
+            ;;
+    esac
 }
 
 # Guard for testing: source functions without running main
@@ -33,11 +33,45 @@ test_detect_os_linux() {
     if [ "${OS_NAME}" = "Linux" ]; then
         detect_os 2>/dev/null
         pass "detect_os succeeds on Linux"
+        if [ "${PLATFORM}" = "linux" ]; then
+            pass "detect_os sets PLATFORM=linux"
+        else
+            fail "detect_os sets PLATFORM=${PLATFORM}, expected linux"
+        fi
     else
         pass "detect_os skipped (not running on Linux)"
     fi
 }
 
+test_detect_os_darwin() {
+    OS_NAME="$(uname -s)"
+    if [ "${OS_NAME}" = "Darwin" ]; then
+        detect_os 2>/dev/null
+        if [ "${PLATFORM}" = "darwin" ]; then
+            pass "detect_os sets PLATFORM=darwin"
+        else
+            fail "detect_os sets PLATFORM=${PLATFORM}, expected darwin"
+        fi
+    else
+        pass "detect_os darwin skipped (not running on macOS)"
+    fi
+}
+
+test_config_dir() {
+    PLATFORM="darwin"
+    if [ "$(config_dir)" = "/usr/local/etc/plexd" ]; then
+        pass "config_dir on darwin is /usr/local/etc/plexd"
+    else
+        fail "config_dir on darwin is $(config_dir)"
+    fi
+    PLATFORM="linux"
+    if [ "$(config_dir)" = "/etc/plexd" ]; then
+        pass "config_dir on linux is /etc/plexd"
+    else
+        fail "config_dir on linux is $(config_dir)"
+    fi
+}
+
 test_detect_arch_amd64() {
     MACHINE="$(uname -m)"
     if [ "${MACHINE}" = "x86_64" ]; then
@@ -160,10 +194,25 @@ test_find_download_cmd() {
     fi
 }
 
+test_detect_init_system() {
+    INIT_SYSTEM=""
+    if ( detect_init_system ) 2>/dev/null; then
+        detect_init_system
+        case "${INIT_SYSTEM}" in
+            launchd|systemd|openrc|sysv) pass "detect_init_system found: ${INIT_SYSTEM}" ;;
+            *) fail "detect_init_system returned unexpected value: ${INIT_SYSTEM}" ;;
+        esac
+    else
+        pass "detect_init_system skipped (no init system on this host)"
+    fi
+}
+
 # --- Run tests ---
 
 printf "Running install.sh tests...\n"
 test_detect_os_linux
+test_detect_os_darwin
+test_config_dir
 test_detect_arch_amd64
 test_detect_arch_arm64
 test_detect_arch_current
@@ -174,6 +223,7 @@ test_parse_args_no_start
 test_parse_args_combined
 test_find_sha256_cmd
 test_find_download_cmd
+test_detect_init_system
 
 printf "\nResults: %d run, %d passed, %d failed\n" "${TESTS_RUN}" "${TESTS_PASSED}" "${TESTS_FAILED}"
 
@@ -0,0 +1,172 @@
+//go:build linux
+
+package actions
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// cpuPeriod is the cpu.max period in microseconds.
+const cpuPeriod = 100000
+
+// hookCgroup is the cgroup v2 a limited hook execution runs in.
+type hookCgroup struct {
+	path string
+	dir  *os.File
+}
+
+// newHookCgroup creates the cgroup parent/name, enables the controllers that
+// limits needs in parent, and writes the limits.
+func newHookCgroup(parent, name string, limits api.ResourceLimits) (*hookCgroup, error) {
+	files := cgroupLimitFiles(limits)
+
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return nil, fmt.Errorf("actions: cgroup: create parent: %w", err)
+	}
+	for _, ctrl := range cgroupControllers(limits) {
+		if err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte("+"+ctrl), 0o644); err != nil {
+			return nil, fmt.Errorf("actions: cgroup: enable %s controller: %w", ctrl, err)
+		}
+	}
+
+	path := filepath.Join(parent, name)
+	if err := os.Mkdir(path, 0o755); err != nil {
+		return nil, fmt.Errorf("actions: cgroup: create: %w", err)
+	}
+	cg := &hookCgroup{path: path}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(path, f.name), []byte(f.value), 0o644); err != nil && !f.optional {
+			cg.remove()
+			return nil, fmt.Errorf("actions: cgroup: write %s: %w", f.name, err)
+		}
+	}
+
+	dir, err := os.Open(path)
+	if err != nil {
+		cg.remove()
+		return nil, fmt.Errorf("actions: cgroup: open: %w", err)
+	}
+	cg.dir = dir
+	return cg, nil
+}
+
+// cgroupFile is a cgroup interface file and the value written to it.
+type cgroupFile struct {
+	name     string
+	value    string
+	optional bool
+}
+
+// cgroupLimitFiles returns the interface files that set limits.
+func cgroupLimitFiles(limits api.ResourceLimits) []cgroupFile {
+	var files []cgroupFile
+	if limits.CPUPercent > 0 {
+		quota := limits.CPUPercent * cpuPeriod / 100
+		files = append(files, cgroupFile{name: "cpu.max", value: fmt.Sprintf("%d %d", quota, cpuPeriod)})
+	}
+	if limits.MemoryMax > 0 {
+		files = append(files,
+			cgroupFile{name: "memory.max", value: strconv.FormatInt(limits.MemoryMax, 10)},
+			// Without swap accounting the file is absent; memory.max still applies.
+			cgroupFile{name: "memory.swap.max", value: "0", optional: true},
+		)
+	}
+	if limits.PidsMax > 0 {
+		files = append(files, cgroupFile{name: "pids.max", value: strconv.FormatInt(limits.PidsMax, 10)})
+	}
+	if limits.IOWeight > 0 {
+		files = append(files, cgroupFile{name: "io.weight", value: "default " + strconv.Itoa(limits.IOWeight)})
+	}
+	return files
+}
+
+// cgroupControllers returns the controllers limits needs.
+func cgroupControllers(limits api.ResourceLimits) []string {
+	var ctrls []string
+	if limits.CPUPercent > 0 {
+		ctrls = append(ctrls, "cpu")
+	}
+	if limits.MemoryMax > 0 {
+		ctrls = append(ctrls, "memory")
+	}
+	if limits.PidsMax > 0 {
+		ctrls = append(ctrls, "pids")
+	}
+	if limits.IOWeight > 0 {
+		ctrls = append(ctrls, "io")
+	}
+	return ctrls
+}
+
+// attach makes cmd start directly inside the cgroup.
+func (c *hookCgroup) attach(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(c.dir.Fd())
+}
+
+// limitErr returns errOOMKilled or errLimitExceeded when the cgroup's event
+// counters show that a limit stopped the hook, and nil otherwise.
+func (c *hookCgroup) limitErr() error {
+	if readEventCount(filepath.Join(c.path, "memory.events"), "oom_kill") > 0 {
+		return errOOMKilled
+	}
+	if readEventCount(filepath.Join(c.path, "pids.events"), "max") > 0 {
+		return errLimitExceeded
+	}
+	return nil
+}
+
+// remove kills processes left in the cgroup and removes it.
+func (c *hookCgroup) remove() error {
+	if c.dir != nil {
+		c.dir.Close()
+	}
+	// cgroup.kill exists since Linux 5.14; older kernels rely on the hook's
+	// children having exited.
+	_ = os.WriteFile(filepath.Join(c.path, "cgroup.kill"), []byte("1"), 0o644)
+
+	var err error
+	for i := 0; i < 50; i++ {
+		if err = os.Remove(c.path); err == nil || errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if !errors.Is(err, syscall.EBUSY) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return fmt.Errorf("actions: cgroup: remove: %w", err)
+}
+
+// readEventCount returns the value of key in a cgroup events file such as
+// memory.events, or 0 if the file or key is missing.
+func readEventCount(path, key string) int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 2 && fields[0] == key {
+			n, _ := strconv.ParseInt(fields[1], 10, 64)
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,117 @@
+//go:build linux
+
+package actions
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+func TestNewHookCgroup_WritesLimits(t *testing.T) {
+	parent := filepath.Join(t.TempDir(), "plexd-actions")
+	limits := api.ResourceLimits{CPUPercent: 50, MemoryMax: 64 << 20, PidsMax: 32, IOWeight: 200}
+
+	cg, err := newHookCgroup(parent, "exec-1", limits)
+	if err != nil {
+		t.Fatalf("newHookCgroup: %v", err)
+	}
+	defer cg.dir.Close()
+
+	want := map[string]string{
+		"cpu.max":         "50000 100000",
+		"memory.max":      "67108864",
+		"memory.swap.max": "0",
+		"pids.max":        "32",
+		"io.weight":       "default 200",
+	}
+	for name, value := range want {
+		got, err := os.ReadFile(filepath.Join(parent, "exec-1", name))
+		if err != nil {
+			t.Errorf("read %s: %v", name, err)
+			continue
+		}
+		if string(got) != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+	// Controllers are enabled one at a time; the last write remains in a
+	// plain directory.
+	if got, _ := os.ReadFile(filepath.Join(parent, "cgroup.subtree_control")); string(got) != "+io" {
+		t.Errorf("cgroup.subtree_control = %q, want %q", got, "+io")
+	}
+}
+
+func TestCgroupLimitFiles_OnlySetLimits(t *testing.T) {
+	files := cgroupLimitFiles(api.ResourceLimits{PidsMax: 10})
+	if len(files) != 1 || files[0].name != "pids.max" {
+		t.Errorf("files = %+v, want only pids.max", files)
+	}
+	if ctrls := cgroupControllers(api.ResourceLimits{PidsMax: 10}); strings.Join(ctrls, ",") != "pids" {
+		t.Errorf("controllers = %v, want [pids]", ctrls)
+	}
+}
+
+func TestHookCgroup_LimitErr(t *testing.T) {
+	dir := t.TempDir()
+	cg := &hookCgroup{path: dir}
+	if err := cg.limitErr(); err != nil {
+		t.Errorf("limitErr without events = %v, want nil", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "pids.events"), []byte("max 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cg.limitErr(); err != errLimitExceeded {
+		t.Errorf("limitErr = %v, want errLimitExceeded", err)
+	}
+
+	events := "low 0\nhigh 0\nmax 12\noom 1\noom_kill 1\n"
+	if err := os.WriteFile(filepath.Join(dir, "memory.events"), []byte(events), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cg.limitErr(); err != errOOMKilled {
+		t.Errorf("limitErr = %v, want errOOMKilled", err)
+	}
+}
+
+// TestExecutor_RunHook_MemoryLimit runs a hook that exceeds its memory limit
+// in a real cgroup. It needs root and a writable cgroup v2 hierarchy.
+func TestExecutor_RunHook_MemoryLimit(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("requires root")
+	}
+	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err != nil {
+		t.Skip("cgroup v2 not mounted")
+	}
+
+	dir := t.TempDir()
+	writeExecutable(t, dir, "hog", "#!/bin/sh\nexec python3 -c 'b = bytearray(512 << 20); print(len(b))'\n")
+
+	reporter := &mockReporter{}
+	parent := filepath.Join("/sys/fs/cgroup", "plexd-test-"+strings.ReplaceAll(t.Name(), "/", "_"))
+	defer os.Remove(parent)
+	exec := newTestExecutor(Config{
+		HooksDir:     dir,
+		HookLimits:   api.ResourceLimits{MemoryMax: 32 << 20},
+		CgroupParent: parent,
+	}, reporter, &mockVerifier{ok: true})
+	exec.SetHooks([]api.HookInfo{{Name: "hog"}})
+
+	exec.Execute(context.Background(), "node-1", api.ActionRequest{ExecutionID: "exec-oom", Action: "hog", Timeout: "30s"})
+	waitFor(t, 30*time.Second, func() bool {
+		return len(reporter.getResults()) > 0
+	})
+
+	if got := reporter.getResults()[0].Status; got != StatusOOMKilled {
+		t.Errorf("status = %q, want %q (stderr %q)", got, StatusOOMKilled, reporter.getResults()[0].Stderr)
+	}
+	if _, err := os.Stat(filepath.Join(parent, "exec-exec-oom")); !os.IsNotExist(err) {
+		t.Errorf("execution cgroup not removed: %v", err)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package actions
+
+import (
+	"errors"
+	"os/exec"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// hookCgroup is a no-op on non-Linux platforms.
+type hookCgroup struct{}
+
+// newHookCgroup returns an error on non-Linux platforms.
+func newHookCgroup(_, _ string, _ api.ResourceLimits) (*hookCgroup, error) {
+	return nil, errors.New("actions: cgroup: resource limits not supported on this platform")
+}
+
+func (c *hookCgroup) attach(_ *exec.Cmd) {}
+
+func (c *hookCgroup) limitErr() error { return nil }
+
+func (c *hookCgroup) remove() error { return nil }
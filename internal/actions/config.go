@@ -3,7 +3,12 @@ package actions
 
 import (
 	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
 	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
 )
 
 // DefaultMaxConcurrent is the default maximum number of concurrent actions.
@@ -15,6 +20,17 @@ const DefaultMaxActionTimeout = 10 * time.Minute
 // DefaultMaxOutputBytes is the default maximum output size per action (1 MiB).
 const DefaultMaxOutputBytes = 1 << 20
 
+// DefaultMaxHookBytes is the default maximum size of a distributed hook (16 MiB).
+const DefaultMaxHookBytes = 16 << 20
+
+// DefaultMaxFetchBytes is the default maximum size of a file downloaded by
+// the fetch_file action (64 MiB).
+const DefaultMaxFetchBytes = 64 << 20
+
+// DefaultCgroupParent is the default cgroup v2 directory under which hooks
+// with resource limits run.
+const DefaultCgroupParent = "/sys/fs/cgroup/plexd-actions"
+
 // Config holds the configuration for remote action execution.
 type Config struct {
 	// Enabled controls whether action execution is active.
@@ -35,6 +51,48 @@ type Config struct {
 	// MaxOutputBytes is the maximum output size per action in bytes.
 	// Must be at least 1024 when enabled. Default: 1 MiB.
 	MaxOutputBytes int64
+
+	// MaxHookBytes is the maximum size in bytes of a hook distributed by the
+	// control plane: the tarball or inline script, and the unpacked files.
+	// Default: 16 MiB.
+	MaxHookBytes int64
+
+	// AllowedServices lists the systemd units the service_restart action may
+	// restart, as exact names or path.Match patterns (e.g. "nginx.service",
+	// "app-*.service"). Empty disables service_restart.
+	AllowedServices []string
+
+	// AllowedPackages lists the packages the package_install and
+	// package_upgrade actions may install or upgrade, as exact names or
+	// path.Match patterns. Empty disables both actions.
+	AllowedPackages []string
+
+	// FetchFileDirs lists the absolute directories the fetch_file action may
+	// write into, including their subdirectories. Empty disables fetch_file.
+	FetchFileDirs []string
+
+	// MaxFetchBytes is the maximum size of a file downloaded by fetch_file.
+	// Default: 64 MiB.
+	MaxFetchBytes int64
+
+	// AllowedSysctls lists the kernel parameters the sysctl_set action may
+	// set, as exact dotted keys or path.Match patterns (e.g.
+	// "net.ipv4.ip_forward", "net.core.*"). Empty disables sysctl_set.
+	AllowedSysctls []string
+
+	// AllowReboot enables the reboot_scheduled action.
+	AllowReboot bool
+
+	// HookLimits are the cgroup v2 resource limits applied to every hook.
+	// Limits in a hook's sidecar metadata apply where HookLimits leaves a
+	// field unlimited and may only tighten the others. Zero fields are
+	// unlimited.
+	HookLimits api.ResourceLimits
+
+	// CgroupParent is the cgroup v2 directory under which a child cgroup is
+	// created for each limited hook execution.
+	// Default: /sys/fs/cgroup/plexd-actions.
+	CgroupParent string
 }
 
 // ApplyDefaults sets default values for zero-valued fields.
@@ -56,6 +114,15 @@ func (c *Config) ApplyDefaults() {
 	if c.MaxOutputBytes == 0 {
 		c.MaxOutputBytes = DefaultMaxOutputBytes
 	}
+	if c.MaxHookBytes == 0 {
+		c.MaxHookBytes = DefaultMaxHookBytes
+	}
+	if c.MaxFetchBytes == 0 {
+		c.MaxFetchBytes = DefaultMaxFetchBytes
+	}
+	if c.CgroupParent == "" {
+		c.CgroupParent = DefaultCgroupParent
+	}
 }
 
 // Validate checks that configuration values are within acceptable ranges.
@@ -72,5 +139,40 @@ func (c *Config) Validate() error {
 	if c.MaxOutputBytes < 1024 {
 		return errors.New("actions: config: MaxOutputBytes must be at least 1024")
 	}
+	if c.MaxHookBytes < 0 {
+		return errors.New("actions: config: MaxHookBytes must not be negative")
+	}
+	if c.MaxFetchBytes < 0 {
+		return errors.New("actions: config: MaxFetchBytes must not be negative")
+	}
+	for _, list := range []struct {
+		field    string
+		patterns []string
+	}{
+		{"AllowedServices", c.AllowedServices},
+		{"AllowedPackages", c.AllowedPackages},
+		{"AllowedSysctls", c.AllowedSysctls},
+	} {
+		for _, p := range list.patterns {
+			if _, err := path.Match(p, ""); p == "" || err != nil {
+				return fmt.Errorf("actions: config: %s contains invalid pattern %q", list.field, p)
+			}
+		}
+	}
+	for _, dir := range c.FetchFileDirs {
+		if !filepath.IsAbs(dir) {
+			return fmt.Errorf("actions: config: FetchFileDirs entry %q must be an absolute path", dir)
+		}
+	}
+	l := c.HookLimits
+	if l.CPUPercent < 0 || l.MemoryMax < 0 || l.PidsMax < 0 {
+		return errors.New("actions: config: HookLimits must not be negative")
+	}
+	if l.IOWeight < 0 || l.IOWeight > 10000 {
+		return errors.New("actions: config: HookLimits.IOWeight must be between 1 and 10000")
+	}
+	if c.CgroupParent != "" && !filepath.IsAbs(c.CgroupParent) {
+		return errors.New("actions: config: CgroupParent must be an absolute path")
+	}
 	return nil
 }
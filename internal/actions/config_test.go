@@ -21,6 +21,15 @@ func TestConfig_ApplyDefaults(t *testing.T) {
 	if cfg.MaxOutputBytes != DefaultMaxOutputBytes {
 		t.Errorf("MaxOutputBytes = %d, want %d", cfg.MaxOutputBytes, DefaultMaxOutputBytes)
 	}
+	if cfg.MaxHookBytes != DefaultMaxHookBytes {
+		t.Errorf("MaxHookBytes = %d, want %d", cfg.MaxHookBytes, DefaultMaxHookBytes)
+	}
+	if cfg.MaxFetchBytes != DefaultMaxFetchBytes {
+		t.Errorf("MaxFetchBytes = %d, want %d", cfg.MaxFetchBytes, DefaultMaxFetchBytes)
+	}
+	if cfg.CgroupParent != DefaultCgroupParent {
+		t.Errorf("CgroupParent = %q, want %q", cfg.CgroupParent, DefaultCgroupParent)
+	}
 }
 
 func TestConfig_DefaultsPreserveExplicitDisabled(t *testing.T) {
@@ -97,6 +106,87 @@ func TestConfig_ValidateRejectsLowMaxOutputBytes(t *testing.T) {
 	}
 }
 
+func TestConfig_ValidateRejectsNegativeMaxHookBytes(t *testing.T) {
+	cfg := Config{
+		Enabled:          true,
+		MaxConcurrent:    5,
+		MaxActionTimeout: 10 * time.Minute,
+		MaxOutputBytes:   DefaultMaxOutputBytes,
+		MaxHookBytes:     -1,
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want error for negative MaxHookBytes")
+	}
+	want := "actions: config: MaxHookBytes must not be negative"
+	if err.Error() != want {
+		t.Errorf("Validate() error = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestConfig_ValidateRejectsInvalidAllowlists(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{
+			name:   "negative MaxFetchBytes",
+			modify: func(c *Config) { c.MaxFetchBytes = -1 },
+			want:   "actions: config: MaxFetchBytes must not be negative",
+		},
+		{
+			name:   "bad service pattern",
+			modify: func(c *Config) { c.AllowedServices = []string{"app-[.service"} },
+			want:   `actions: config: AllowedServices contains invalid pattern "app-[.service"`,
+		},
+		{
+			name:   "empty package pattern",
+			modify: func(c *Config) { c.AllowedPackages = []string{""} },
+			want:   `actions: config: AllowedPackages contains invalid pattern ""`,
+		},
+		{
+			name:   "bad sysctl pattern",
+			modify: func(c *Config) { c.AllowedSysctls = []string{"net.[core"} },
+			want:   `actions: config: AllowedSysctls contains invalid pattern "net.[core"`,
+		},
+		{
+			name:   "negative hook limit",
+			modify: func(c *Config) { c.HookLimits.MemoryMax = -1 },
+			want:   "actions: config: HookLimits must not be negative",
+		},
+		{
+			name:   "io weight out of range",
+			modify: func(c *Config) { c.HookLimits.IOWeight = 10001 },
+			want:   "actions: config: HookLimits.IOWeight must be between 1 and 10000",
+		},
+		{
+			name:   "relative cgroup parent",
+			modify: func(c *Config) { c.CgroupParent = "plexd-actions" },
+			want:   "actions: config: CgroupParent must be an absolute path",
+		},
+		{
+			name:   "relative fetch dir",
+			modify: func(c *Config) { c.FetchFileDirs = []string{"srv/files"} },
+			want:   `actions: config: FetchFileDirs entry "srv/files" must be an absolute path`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{}
+			cfg.ApplyDefaults()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if err == nil {
+				t.Fatal("Validate() = nil, want error")
+			}
+			if err.Error() != tt.want {
+				t.Errorf("Validate() error = %q, want %q", err.Error(), tt.want)
+			}
+		})
+	}
+}
+
 func TestConfig_ValidateDisabledSkipsValidation(t *testing.T) {
 	cfg := Config{
 		Enabled:          false,
@@ -124,6 +214,11 @@ func TestConfig_ValidateAcceptsCustomValues(t *testing.T) {
 		MaxConcurrent:    10,
 		MaxActionTimeout: 30 * time.Minute,
 		MaxOutputBytes:   2 << 20,
+		AllowedServices:  []string{"nginx.service", "app-*.service"},
+		AllowedPackages:  []string{"curl"},
+		FetchFileDirs:    []string{"/srv/files"},
+		AllowedSysctls:   []string{"net.core.*"},
+		AllowReboot:      true,
 	}
 	if err := cfg.Validate(); err != nil {
 		t.Errorf("Validate() = %v, want nil", err)
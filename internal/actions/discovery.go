@@ -16,10 +16,11 @@ import (
 
 // hookMetadata represents the optional sidecar JSON file for a hook script.
 type hookMetadata struct {
-	Description string            `json:"description"`
-	Parameters  []api.ActionParam `json:"parameters"`
-	Timeout     string            `json:"timeout"`
-	Sandbox     string            `json:"sandbox"`
+	Description string              `json:"description"`
+	Parameters  []api.ActionParam   `json:"parameters"`
+	Timeout     string              `json:"timeout"`
+	Sandbox     string              `json:"sandbox"`
+	Limits      *api.ResourceLimits `json:"limits"`
 }
 
 // DiscoverHooks scans hooksDir for executable files and returns their metadata.
@@ -48,8 +49,8 @@ func DiscoverHooks(hooksDir string, logger *slog.Logger) ([]api.HookInfo, error)
 
 		name := entry.Name()
 
-		// Skip .json sidecar files.
-		if strings.HasSuffix(name, ".json") {
+		// Skip .json sidecar files and hidden temporary and state files.
+		if strings.HasSuffix(name, ".json") || strings.HasPrefix(name, ".") {
 			continue
 		}
 
@@ -89,6 +90,7 @@ func DiscoverHooks(hooksDir string, logger *slog.Logger) ([]api.HookInfo, error)
 				h.Parameters = meta.Parameters
 				h.Timeout = meta.Timeout
 				h.Sandbox = meta.Sandbox
+				h.Limits = meta.Limits
 			}
 		}
 
@@ -110,6 +110,7 @@ func TestDiscoverHooks_SidecarMetadata(t *testing.T) {
 		},
 		Timeout: "30s",
 		Sandbox: "none",
+		Limits:  &api.ResourceLimits{MemoryMax: 64 << 20, PidsMax: 32},
 	}
 	data, err := json.Marshal(meta)
 	if err != nil {
@@ -146,6 +147,9 @@ func TestDiscoverHooks_SidecarMetadata(t *testing.T) {
 	if h.Sandbox != "none" {
 		t.Errorf("Sandbox = %q, want %q", h.Sandbox, "none")
 	}
+	if h.Limits == nil || h.Limits.MemoryMax != 64<<20 || h.Limits.PidsMax != 32 {
+		t.Errorf("Limits = %+v, want memory_max=64MiB pids_max=32", h.Limits)
+	}
 }
 
 func TestDiscoverHooks_NonExecutableSkipped(t *testing.T) {
@@ -245,3 +249,17 @@ func TestDiscoverHooks_JsonFilesSkipped(t *testing.T) {
 		t.Errorf("hooks[0].Name = %q, want %q", hooks[0].Name, "hook.sh")
 	}
 }
+
+func TestDiscoverHooks_HiddenFilesSkipped(t *testing.T) {
+	dir := t.TempDir()
+	writeExecutable(t, dir, "good.sh", "#!/bin/sh\necho good\n")
+	writeExecutable(t, dir, ".tmp-good.sh", "#!/bin/sh\necho partial\n")
+
+	hooks, err := DiscoverHooks(dir, testLogger())
+	if err != nil {
+		t.Fatalf("DiscoverHooks() error = %v", err)
+	}
+	if len(hooks) != 1 || hooks[0].Name != "good.sh" {
+		t.Errorf("hooks = %+v, want only good.sh", hooks)
+	}
+}
@@ -5,15 +5,18 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/auditfwd"
 )
 
 // waitDelayAfterKill is the grace period for a process to exit after context
@@ -35,6 +38,12 @@ type HookVerifier interface {
 	VerifyHook(ctx context.Context, nodeID, hookPath, expectedChecksum string) (bool, error)
 }
 
+// MutationRecorder records host changes for the audit trail. Satisfied by
+// *auditfwd.Recorder.
+type MutationRecorder interface {
+	Record(ctx context.Context, m auditfwd.Mutation)
+}
+
 // builtinEntry pairs a BuiltinFunc with its metadata for capability reporting.
 type builtinEntry struct {
 	fn          BuiltinFunc
@@ -54,6 +63,8 @@ type Executor struct {
 	active       map[string]context.CancelFunc // executionID → cancel
 	builtins     map[string]builtinEntry       // action name → builtin
 	hooks        []api.HookInfo                // discovered hooks snapshot
+	jobs         *JobStore                     // optional persisted executions
+	audit        MutationRecorder              // optional audit trail
 	shuttingDown bool
 }
 
@@ -80,6 +91,61 @@ func (e *Executor) RegisterBuiltin(name, description string, params []api.Action
 	}
 }
 
+// SetJobStore sets the store that persists accepted executions. With a store,
+// replayed action requests are rejected as duplicates across restarts, and
+// RecoverInterrupted reports executions cut short by a restart.
+func (e *Executor) SetJobStore(store *JobStore) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.jobs = store
+}
+
+// SetAuditRecorder sets the recorder every finished execution is recorded
+// with, as a "hook_exec" or "builtin_exec" host change.
+func (e *Executor) SetAuditRecorder(rec MutationRecorder) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.audit = rec
+}
+
+// RecoverInterrupted reports every execution that the job store records as
+// running as interrupted. It must be called before the executor accepts new
+// actions; executions whose report fails stay recorded and are retried on
+// the next call.
+func (e *Executor) RecoverInterrupted(ctx context.Context, nodeID string) {
+	e.mu.Lock()
+	jobs := e.jobs
+	e.mu.Unlock()
+	if jobs == nil {
+		return
+	}
+
+	for _, job := range jobs.Running() {
+		result := api.ExecutionResult{
+			ExecutionID: job.ExecutionID,
+			Status:      JobStatusInterrupted,
+			ExitCode:    1,
+			Stderr:      "execution interrupted by agent restart",
+			FinishedAt:  time.Now().UTC(),
+			TriggeredBy: job.TriggeredBy,
+		}
+		if err := e.reporter.ReportResult(ctx, nodeID, job.ExecutionID, result); err != nil {
+			e.logger.Warn("failed to report interrupted execution",
+				"execution_id", job.ExecutionID,
+				"error", err,
+			)
+			continue
+		}
+		if err := jobs.Finish(job.ExecutionID, JobStatusInterrupted); err != nil {
+			e.logger.Warn("failed to persist execution", "execution_id", job.ExecutionID, "error", err)
+		}
+		e.logger.Info("interrupted execution reported",
+			"execution_id", job.ExecutionID,
+			"action", job.Action,
+		)
+	}
+}
+
 // SetHooks sets the discovered hooks snapshot.
 func (e *Executor) SetHooks(hooks []api.HookInfo) {
 	e.mu.Lock()
@@ -124,7 +190,7 @@ func (e *Executor) Execute(ctx context.Context, nodeID string, req api.ActionReq
 		return
 	}
 
-	if _, exists := e.active[req.ExecutionID]; exists {
+	if _, exists := e.active[req.ExecutionID]; exists || (e.jobs != nil && e.jobs.Has(req.ExecutionID)) {
 		e.mu.Unlock()
 		e.reject(ctx, nodeID, req, "duplicate_execution_id")
 		return
@@ -156,8 +222,22 @@ func (e *Executor) Execute(ctx context.Context, nodeID string, req api.ActionReq
 
 	actionCtx, cancel := context.WithCancel(ctx)
 	e.active[req.ExecutionID] = cancel
+	jobs := e.jobs
 	e.mu.Unlock()
 
+	if jobs != nil {
+		job := Job{
+			ExecutionID: req.ExecutionID,
+			Action:      req.Action,
+			Parameters:  req.Parameters,
+			AcceptedAt:  time.Now().UTC(),
+			TriggeredBy: req.TriggeredBy,
+		}
+		if err := jobs.Accept(job); err != nil {
+			e.logger.Warn("failed to persist execution", "execution_id", req.ExecutionID, "error", err)
+		}
+	}
+
 	ack := api.ExecutionAck{
 		ExecutionID: req.ExecutionID,
 		Status:      "accepted",
@@ -223,6 +303,12 @@ func determineStatus(runErr error, exitCode int, timeoutCtx, parentCtx context.C
 		if parentCtx.Err() == context.Canceled {
 			return "cancelled"
 		}
+		if errors.Is(runErr, errOOMKilled) {
+			return StatusOOMKilled
+		}
+		if errors.Is(runErr, errLimitExceeded) {
+			return StatusLimitExceeded
+		}
 		var exitErr *exec.ExitError
 		if errors.As(runErr, &exitErr) {
 			return "failed"
@@ -236,11 +322,18 @@ func determineStatus(runErr error, exitCode int, timeoutCtx, parentCtx context.C
 }
 
 func (e *Executor) runAction(ctx context.Context, nodeID string, req api.ActionRequest, cancel context.CancelFunc) {
+	status := "error"
 	defer func() {
 		cancel()
 		e.mu.Lock()
 		delete(e.active, req.ExecutionID)
+		jobs := e.jobs
 		e.mu.Unlock()
+		if jobs != nil {
+			if err := jobs.Finish(req.ExecutionID, status); err != nil {
+				e.logger.Warn("failed to persist execution", "execution_id", req.ExecutionID, "error", err)
+			}
+		}
 	}()
 
 	defer func() {
@@ -293,7 +386,7 @@ func (e *Executor) runAction(ctx context.Context, nodeID string, req api.ActionR
 	}
 
 	duration := time.Since(start)
-	status := determineStatus(runErr, exitCode, timeoutCtx, ctx)
+	status = determineStatus(runErr, exitCode, timeoutCtx, ctx)
 
 	result := api.ExecutionResult{
 		ExecutionID: req.ExecutionID,
@@ -318,6 +411,40 @@ func (e *Executor) runAction(ctx context.Context, nodeID string, req api.ActionR
 		"status", status,
 		"duration", duration,
 	)
+
+	e.mu.Lock()
+	rec := e.audit
+	e.mu.Unlock()
+	if rec != nil {
+		rec.Record(ctx, executionMutation(req, isBuiltin, result, runErr))
+	}
+}
+
+// executionMutation describes a finished execution for the audit trail.
+// Its output is left out; it is reported with the result.
+func executionMutation(req api.ActionRequest, builtin bool, result api.ExecutionResult, runErr error) auditfwd.Mutation {
+	action := "hook_exec"
+	if builtin {
+		action = "builtin_exec"
+	}
+	err := runErr
+	if err == nil && result.Status != "success" {
+		err = fmt.Errorf("%s with exit code %d", result.Status, result.ExitCode)
+	}
+	return auditfwd.Mutation{
+		Action: action,
+		Target: req.Action,
+		Object: map[string]any{
+			"action":       req.Action,
+			"execution_id": req.ExecutionID,
+			"parameters":   slices.Sorted(maps.Keys(req.Parameters)),
+			"status":       result.Status,
+			"exit_code":    result.ExitCode,
+			"duration":     result.Duration,
+			"triggered_by": req.TriggeredBy,
+		},
+		Err: err,
+	}
 }
 
 func (e *Executor) runBuiltin(ctx context.Context, name string, params map[string]string) (string, string, int, error) {
@@ -332,9 +459,10 @@ func (e *Executor) runBuiltin(ctx context.Context, name string, params map[strin
 	return entry.fn(ctx, params)
 }
 
-// validateHookName rejects hook names containing path separators or traversal sequences.
+// validateHookName rejects hook names containing path separators or traversal
+// sequences, and hidden names, which are reserved for temporary and state files.
 func validateHookName(name string) error {
-	if name == "" || strings.ContainsAny(name, "/\\") || strings.Contains(name, "..") {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, "/\\") || strings.Contains(name, "..") {
 		return fmt.Errorf("invalid hook name: %s", name)
 	}
 	return nil
@@ -363,6 +491,20 @@ func (e *Executor) runHook(ctx context.Context, nodeID string, req api.ActionReq
 	cmd.WaitDelay = waitDelayAfterKill
 	cmd.Env = e.buildHookEnv(nodeID, req)
 
+	var cg *hookCgroup
+	if limits := e.hookLimits(req.Action); hasLimits(limits) {
+		cg, err = newHookCgroup(e.cfg.CgroupParent, cgroupName(req.ExecutionID), limits)
+		if err != nil {
+			return "", "", 1, fmt.Errorf("resource limits unavailable: %w", err)
+		}
+		defer func() {
+			if err := cg.remove(); err != nil {
+				e.logger.Warn("failed to remove hook cgroup", "execution_id", req.ExecutionID, "error", err)
+			}
+		}()
+		cg.attach(cmd)
+	}
+
 	stdoutW := newLimitedWriter(e.cfg.MaxOutputBytes)
 	stderrW := newLimitedWriter(e.cfg.MaxOutputBytes)
 	cmd.Stdout = stdoutW
@@ -373,6 +515,11 @@ func (e *Executor) runHook(ctx context.Context, nodeID string, req api.ActionReq
 	stdout := collectOutput(stdoutW)
 	stderr := collectOutput(stderrW)
 
+	if runErr != nil && cg != nil {
+		if limitErr := cg.limitErr(); limitErr != nil {
+			runErr = errors.Join(limitErr, runErr)
+		}
+	}
 	if runErr != nil {
 		var exitErr *exec.ExitError
 		if errors.As(runErr, &exitErr) {
@@ -384,6 +531,17 @@ func (e *Executor) runHook(ctx context.Context, nodeID string, req api.ActionReq
 	return stdout, stderr, 0, nil
 }
 
+// cgroupName returns the cgroup directory name for an execution, with
+// characters outside [A-Za-z0-9_-] replaced.
+func cgroupName(executionID string) string {
+	return "exec-" + strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, executionID)
+}
+
 // buildHookEnv constructs the minimal environment for hook execution.
 func (e *Executor) buildHookEnv(nodeID string, req api.ActionRequest) []string {
 	env := []string{
@@ -7,6 +7,7 @@ import (
 	"log/slog"
 
 	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/reconcile"
 )
 
 // HandleActionRequest returns an api.EventHandler for action_request events.
@@ -57,3 +58,14 @@ func HandleActionRequest(executor *Executor, nodeID string, logger *slog.Logger)
 		return nil
 	}
 }
+
+// HookSyncReconcileHandler returns a reconcile.ReconcileHandler that syncs the
+// hooks distributed in the desired data entries whenever they change.
+func HookSyncReconcileHandler(syncer *HookSyncer) reconcile.ReconcileHandler {
+	return func(ctx context.Context, desired *api.StateResponse, diff reconcile.StateDiff) error {
+		if !diff.DataChanged {
+			return nil
+		}
+		return syncer.Sync(ctx, desired.Data)
+	}
+}
@@ -0,0 +1,554 @@
+package actions
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/auditfwd"
+	"github.com/plexsphere/plexd/internal/fsutil"
+)
+
+// HookSourceControlPlane is the HookInfo.Source of hooks installed by the
+// HookSyncer.
+const HookSourceControlPlane = "control_plane"
+
+// hookManifestFile records the hooks installed by the HookSyncer. It lives in
+// HooksDir; as a hidden .json file it is ignored by hook discovery and by the
+// integrity watcher.
+const hookManifestFile = ".plexd-synced-hooks.json"
+
+// hookDownloadTimeout bounds the download of a hook tarball.
+const hookDownloadTimeout = 2 * time.Minute
+
+// SignatureVerifier checks control plane signatures of distributed hooks.
+// It is satisfied by *api.Ed25519Verifier.
+type SignatureVerifier interface {
+	VerifySignature(message []byte, signature string) error
+}
+
+// HookApprover receives the approved hook set. It is satisfied by
+// *integrity.Verifier.
+type HookApprover interface {
+	SetApprovedHooks(hooks []api.HookInfo)
+}
+
+// CapabilitiesPublisher publishes the node's capabilities. It is satisfied by
+// *api.ControlPlane.
+type CapabilitiesPublisher interface {
+	UpdateCapabilities(ctx context.Context, nodeID string, caps api.CapabilitiesPayload) error
+}
+
+// syncedHook is the manifest record of an installed hook.
+type syncedHook struct {
+	// SHA256 is the digest of the distributed tarball or inline script.
+	SHA256 string `json:"sha256"`
+	// Checksum is the digest of the installed script.
+	Checksum string `json:"checksum"`
+	// MetadataSHA256 is the digest of the distributed inline metadata.
+	MetadataSHA256 string `json:"metadata_sha256,omitempty"`
+	// Sidecar is true when a {name}.json sidecar was installed.
+	Sidecar bool `json:"sidecar,omitempty"`
+}
+
+// HookSyncer installs hooks distributed by the control plane as data entries
+// of type api.HookContentType into HooksDir and removes the hooks it installed
+// once they are no longer distributed. Hooks placed in HooksDir by other means
+// are never replaced or removed.
+type HookSyncer struct {
+	cfg      Config
+	executor *Executor
+	verifier SignatureVerifier
+	client   *http.Client
+	logger   *slog.Logger
+
+	approver  HookApprover
+	publisher CapabilitiesPublisher
+	audit     MutationRecorder
+	nodeID    string
+	handlers  []func()
+
+	writeFile func(dir, name string, data []byte, perm os.FileMode) error
+
+	mu sync.Mutex // serializes Sync
+}
+
+// NewHookSyncer creates a HookSyncer that installs into cfg.HooksDir, checks
+// signatures with verifier, and updates the hooks snapshot of executor.
+func NewHookSyncer(cfg Config, executor *Executor, verifier SignatureVerifier, logger *slog.Logger) *HookSyncer {
+	return &HookSyncer{
+		cfg:      cfg,
+		executor: executor,
+		verifier: verifier,
+		client:   &http.Client{Timeout: hookDownloadTimeout},
+		logger:   logger.With("component", "actions"),
+
+		writeFile: fsutil.WriteFileAtomic,
+	}
+}
+
+// SetApprover sets the receiver of the approved hook set. An installed hook
+// is approved once its script and sidecar are written, and a removed hook is
+// unapproved before its files are deleted.
+func (s *HookSyncer) SetApprover(a HookApprover) { s.approver = a }
+
+// SetAuditRecorder sets the recorder every hook file written or removed is
+// recorded with, as a "file_write" or "file_remove" host change.
+func (s *HookSyncer) SetAuditRecorder(rec MutationRecorder) { s.audit = rec }
+
+// SetCapabilitiesPublisher sets where the capabilities of nodeID are published
+// after the installed hooks change. Only actions and hooks are published; an
+// agent with a capabilities.Manager uses OnHooksChanged instead.
+func (s *HookSyncer) SetCapabilitiesPublisher(p CapabilitiesPublisher, nodeID string) {
+	s.publisher = p
+	s.nodeID = nodeID
+}
+
+// OnHooksChanged registers fn to be called after the installed hooks
+// changed and the executor was updated, typically
+// capabilities.Manager.Trigger. Must be called before Sync.
+func (s *HookSyncer) OnHooksChanged(fn func()) {
+	s.handlers = append(s.handlers, fn)
+}
+
+// Sync installs the hooks distributed in data and removes previously synced
+// hooks that are no longer distributed. A hook is installed only when its
+// signature and digest verify; otherwise the installed version, if any, is
+// kept. Individual failures are logged and returned joined.
+func (s *HookSyncer) Sync(ctx context.Context, data []api.DataEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	desired := make(map[string]api.HookDistribution)
+	for _, entry := range data {
+		if entry.ContentType != api.HookContentType {
+			continue
+		}
+		var dist api.HookDistribution
+		if err := json.Unmarshal(entry.Payload, &dist); err != nil {
+			s.logger.Error("hook sync: parse entry failed", "key", entry.Key, "error", err)
+			errs = append(errs, fmt.Errorf("actions: hook sync: parse %s: %w", entry.Key, err))
+			continue
+		}
+		if err := validateHookName(dist.Name); err != nil {
+			s.logger.Error("hook sync: invalid hook name", "key", entry.Key, "error", err)
+			errs = append(errs, fmt.Errorf("actions: hook sync: %s: %w", entry.Key, err))
+			continue
+		}
+		if _, dup := desired[dist.Name]; dup {
+			errs = append(errs, fmt.Errorf("actions: hook sync: hook %s distributed twice", dist.Name))
+			continue
+		}
+		desired[dist.Name] = dist
+	}
+
+	if s.cfg.HooksDir == "" {
+		if len(desired) > 0 {
+			errs = append(errs, errors.New("actions: hook sync: HooksDir is not configured"))
+		}
+		return errors.Join(errs...)
+	}
+	if err := os.MkdirAll(s.cfg.HooksDir, 0o755); err != nil {
+		return fmt.Errorf("actions: hook sync: create hooks dir: %w", err)
+	}
+
+	manifest, err := s.loadManifest()
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for _, name := range sortedKeys(manifest) {
+		if _, ok := desired[name]; ok {
+			continue
+		}
+		if err := s.remove(ctx, name, manifest[name]); err != nil {
+			s.logger.Error("hook sync: remove failed", "hook", name, "error", err)
+			errs = append(errs, err)
+			continue
+		}
+		delete(manifest, name)
+		changed = true
+		s.logger.Info("hook sync: hook removed", "hook", name)
+	}
+
+	for _, name := range sortedKeys(desired) {
+		dist := desired[name]
+		var prev *syncedHook
+		if rec, ok := manifest[name]; ok {
+			if strings.EqualFold(rec.SHA256, dist.SHA256) && rec.MetadataSHA256 == metadataDigest(dist) &&
+				s.installedChecksum(name) == rec.Checksum {
+				continue
+			}
+			prev = &rec
+		}
+		rec, err := s.install(ctx, dist, prev)
+		if err != nil {
+			s.logger.Error("hook sync: install failed", "hook", name, "error", err)
+			errs = append(errs, err)
+			continue
+		}
+		manifest[name] = rec
+		changed = true
+		s.logger.Info("hook sync: hook installed", "hook", name, "checksum", rec.Checksum)
+	}
+
+	if changed {
+		if err := s.saveManifest(manifest); err != nil {
+			errs = append(errs, err)
+		}
+		s.refresh(ctx, manifest)
+	}
+	return errors.Join(errs...)
+}
+
+// install verifies and installs dist. prev is the manifest record of the
+// previously synced version, if any; a hook that was not synced is never
+// replaced.
+func (s *HookSyncer) install(ctx context.Context, dist api.HookDistribution, prev *syncedHook) (syncedHook, error) {
+	name := dist.Name
+	if prev == nil {
+		if _, err := os.Lstat(filepath.Join(s.cfg.HooksDir, name)); err == nil {
+			return syncedHook{}, fmt.Errorf("actions: hook sync: %s: a local hook with this name exists", name)
+		}
+	}
+
+	if err := s.verifier.VerifySignature(signedMessage(dist), dist.Signature); err != nil {
+		return syncedHook{}, fmt.Errorf("actions: hook sync: %s: %w", name, err)
+	}
+
+	script, sidecar, err := s.fetch(ctx, dist)
+	if err != nil {
+		return syncedHook{}, fmt.Errorf("actions: hook sync: %s: %w", name, err)
+	}
+	sum := sha256.Sum256(script)
+	rec := syncedHook{
+		SHA256:         strings.ToLower(dist.SHA256),
+		Checksum:       hex.EncodeToString(sum[:]),
+		MetadataSHA256: metadataDigest(dist),
+		Sidecar:        sidecar != nil,
+	}
+
+	err = s.writeFile(s.cfg.HooksDir, name, script, 0o755)
+	s.recordFile(ctx, "file_write", name, rec.Checksum, err)
+	if err != nil {
+		return syncedHook{}, fmt.Errorf("actions: hook sync: %s: write hook: %w", name, err)
+	}
+	if sidecar != nil {
+		if err := s.writeFile(s.cfg.HooksDir, name+".json", sidecar, 0o644); err != nil {
+			return syncedHook{}, fmt.Errorf("actions: hook sync: %s: write sidecar: %w", name, err)
+		}
+	} else if prev != nil && prev.Sidecar {
+		if err := os.Remove(filepath.Join(s.cfg.HooksDir, name+".json")); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return syncedHook{}, fmt.Errorf("actions: hook sync: %s: remove sidecar: %w", name, err)
+		}
+	}
+	s.approve(map[string]string{name: rec.Checksum})
+	return rec, nil
+}
+
+// remove deletes a synced hook and its sidecar.
+func (s *HookSyncer) remove(ctx context.Context, name string, rec syncedHook) error {
+	s.approve(map[string]string{name: ""})
+	err := os.Remove(filepath.Join(s.cfg.HooksDir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	s.recordFile(ctx, "file_remove", name, "", err)
+	if err != nil {
+		return fmt.Errorf("actions: hook sync: remove %s: %w", name, err)
+	}
+	if rec.Sidecar {
+		if err := os.Remove(filepath.Join(s.cfg.HooksDir, name+".json")); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("actions: hook sync: remove %s sidecar: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// recordFile records a change of the hook file name with the audit
+// recorder, if one is set.
+func (s *HookSyncer) recordFile(ctx context.Context, action, name, checksum string, err error) {
+	if s.audit == nil {
+		return
+	}
+	path := filepath.Join(s.cfg.HooksDir, name)
+	object := map[string]any{"path": path, "hook": name}
+	if checksum != "" {
+		object["sha256"] = checksum
+	}
+	s.audit.Record(ctx, auditfwd.Mutation{Action: action, Target: path, Object: object, Err: err})
+}
+
+// signedMessage returns the message the control plane signs for dist, a JSON
+// object with its keys in sorted order. The digest of inline metadata is
+// included only when metadata is present, so distributions without metadata
+// keep their {name, sha256} message.
+func signedMessage(dist api.HookDistribution) []byte {
+	msg, _ := json.Marshal(struct {
+		MetadataSHA256 string `json:"metadata_sha256,omitempty"`
+		Name           string `json:"name"`
+		SHA256         string `json:"sha256"`
+	}{metadataDigest(dist), dist.Name, dist.SHA256})
+	return msg
+}
+
+// metadataDigest returns the hex SHA-256 digest of the metadata bytes of
+// dist as distributed, or "" if dist has no metadata.
+func metadataDigest(dist api.HookDistribution) string {
+	if len(dist.Metadata) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(dist.Metadata)
+	return hex.EncodeToString(sum[:])
+}
+
+// fetch returns the script and optional sidecar of dist after checking the
+// digest of the distributed bytes.
+func (s *HookSyncer) fetch(ctx context.Context, dist api.HookDistribution) ([]byte, []byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+	switch {
+	case dist.URL != "" && dist.Inline != "":
+		return nil, nil, errors.New("both url and inline are set")
+	case dist.Inline != "":
+		data, err = base64.StdEncoding.DecodeString(dist.Inline)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decode inline: %w", err)
+		}
+		if s.cfg.MaxHookBytes > 0 && int64(len(data)) > s.cfg.MaxHookBytes {
+			return nil, nil, fmt.Errorf("inline hook exceeds %d bytes", s.cfg.MaxHookBytes)
+		}
+	case dist.URL != "":
+		data, err = s.download(ctx, dist.URL)
+		if err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, errors.New("neither url nor inline is set")
+	}
+
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, dist.SHA256) {
+		return nil, nil, fmt.Errorf("sha256 mismatch: got %s, want %s", actual, dist.SHA256)
+	}
+
+	if dist.Inline != "" {
+		var sidecar []byte
+		if len(dist.Metadata) > 0 {
+			if !json.Valid(dist.Metadata) {
+				return nil, nil, errors.New("metadata is not valid JSON")
+			}
+			sidecar = dist.Metadata
+		}
+		return data, sidecar, nil
+	}
+	return unpackHook(data, dist.Name, s.cfg.MaxHookBytes)
+}
+
+// download fetches rawURL, which must be http or https, up to MaxHookBytes.
+func (s *HookSyncer) download(ctx context.Context, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil, fmt.Errorf("unsupported url %q", rawURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download: unexpected status %d", resp.StatusCode)
+	}
+
+	body := io.Reader(resp.Body)
+	if s.cfg.MaxHookBytes > 0 {
+		body = io.LimitReader(resp.Body, s.cfg.MaxHookBytes+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("download: %w", err)
+	}
+	if s.cfg.MaxHookBytes > 0 && int64(len(data)) > s.cfg.MaxHookBytes {
+		return nil, fmt.Errorf("download exceeds %d bytes", s.cfg.MaxHookBytes)
+	}
+	return data, nil
+}
+
+// unpackHook extracts the script name and the optional name.json sidecar
+// from a gzipped tarball. Any other regular file, link, or device is
+// rejected; directories are ignored.
+func unpackHook(data []byte, name string, maxBytes int64) ([]byte, []byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unpack: %w", err)
+	}
+	defer zr.Close()
+
+	var script, sidecar []byte
+	var total int64
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("unpack: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		entry := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if hdr.Typeflag != tar.TypeReg || (entry != name && entry != name+".json") {
+			return nil, nil, fmt.Errorf("unpack: unexpected entry %q", hdr.Name)
+		}
+
+		r := io.Reader(tr)
+		if maxBytes > 0 {
+			r = io.LimitReader(tr, maxBytes-total+1)
+		}
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unpack: %w", err)
+		}
+		total += int64(len(content))
+		if maxBytes > 0 && total > maxBytes {
+			return nil, nil, fmt.Errorf("unpack: content exceeds %d bytes", maxBytes)
+		}
+		if entry == name {
+			script = content
+		} else {
+			sidecar = content
+		}
+	}
+	if script == nil {
+		return nil, nil, fmt.Errorf("unpack: tarball does not contain %q", name)
+	}
+	return script, sidecar, nil
+}
+
+// approve passes the current hooks, with the checksums in changes applied,
+// to the approver. An empty checksum removes the hook from the set.
+func (s *HookSyncer) approve(changes map[string]string) {
+	if s.approver == nil {
+		return
+	}
+	_, current := s.executor.Capabilities()
+	hooks := make([]api.HookInfo, 0, len(current)+len(changes))
+	for _, h := range current {
+		if _, ok := changes[h.Name]; !ok {
+			hooks = append(hooks, h)
+		}
+	}
+	for name, checksum := range changes {
+		if checksum != "" {
+			hooks = append(hooks, api.HookInfo{Name: name, Source: HookSourceControlPlane, Checksum: checksum})
+		}
+	}
+	s.approver.SetApprovedHooks(hooks)
+}
+
+// refresh rediscovers HooksDir, updates the executor and approver, calls the
+// OnHooksChanged handlers, and publishes the new capabilities.
+func (s *HookSyncer) refresh(ctx context.Context, manifest map[string]syncedHook) {
+	hooks, err := DiscoverHooks(s.cfg.HooksDir, s.logger)
+	if err != nil {
+		s.logger.Error("hook sync: discovery failed", "error", err)
+		return
+	}
+	for i := range hooks {
+		if _, ok := manifest[hooks[i].Name]; ok {
+			hooks[i].Source = HookSourceControlPlane
+		}
+	}
+	s.executor.SetHooks(hooks)
+	if s.approver != nil {
+		s.approver.SetApprovedHooks(hooks)
+	}
+	for _, fn := range s.handlers {
+		fn()
+	}
+
+	if s.publisher == nil {
+		return
+	}
+	actions, hooks := s.executor.Capabilities()
+	caps := api.CapabilitiesPayload{BuiltinActions: actions, Hooks: hooks}
+	if err := s.publisher.UpdateCapabilities(ctx, s.nodeID, caps); err != nil {
+		s.logger.Warn("hook sync: publish capabilities failed", "error", err)
+	}
+}
+
+// installedChecksum returns the checksum of the installed hook name, or an
+// empty string if it cannot be read.
+func (s *HookSyncer) installedChecksum(name string) string {
+	data, err := os.ReadFile(filepath.Join(s.cfg.HooksDir, name))
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *HookSyncer) loadManifest() (map[string]syncedHook, error) {
+	manifest := make(map[string]syncedHook)
+	data, err := os.ReadFile(filepath.Join(s.cfg.HooksDir, hookManifestFile))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return manifest, nil
+		}
+		return nil, fmt.Errorf("actions: hook sync: read manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("actions: hook sync: parse manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+func (s *HookSyncer) saveManifest(manifest map[string]syncedHook) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("actions: hook sync: marshal manifest: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(s.cfg.HooksDir, hookManifestFile, data, 0o600); err != nil {
+		return fmt.Errorf("actions: hook sync: write manifest: %w", err)
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,547 @@
+package actions
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+type fakeApprover struct {
+	mu    sync.Mutex
+	hooks []api.HookInfo
+}
+
+func (f *fakeApprover) SetApprovedHooks(hooks []api.HookInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hooks = hooks
+}
+
+func (f *fakeApprover) checksum(name string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, h := range f.hooks {
+		if h.Name == name {
+			return h.Checksum
+		}
+	}
+	return ""
+}
+
+type fakePublisher struct {
+	mu    sync.Mutex
+	calls []api.CapabilitiesPayload
+}
+
+func (f *fakePublisher) UpdateCapabilities(_ context.Context, _ string, caps api.CapabilitiesPayload) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, caps)
+	return nil
+}
+
+func (f *fakePublisher) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.calls)
+}
+
+type hookSyncFixture struct {
+	syncer    *HookSyncer
+	executor  *Executor
+	approver  *fakeApprover
+	publisher *fakePublisher
+	priv      ed25519.PrivateKey
+	hooksDir  string
+}
+
+func newHookSyncFixture(t *testing.T) *hookSyncFixture {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	hooksDir := filepath.Join(t.TempDir(), "hooks")
+	cfg := Config{HooksDir: hooksDir}
+	cfg.ApplyDefaults()
+
+	executor := NewExecutor(cfg, &mockReporter{}, &mockVerifier{ok: true}, discardLogger())
+	syncer := NewHookSyncer(cfg, executor, api.NewEd25519Verifier(pub), discardLogger())
+	f := &hookSyncFixture{
+		syncer:    syncer,
+		executor:  executor,
+		approver:  &fakeApprover{},
+		publisher: &fakePublisher{},
+		priv:      priv,
+		hooksDir:  hooksDir,
+	}
+	syncer.SetApprover(f.approver)
+	syncer.SetCapabilitiesPublisher(f.publisher, "node-1")
+	return f
+}
+
+// sign returns a distribution of name with the digest of data, signed by the
+// fixture's key.
+func (f *hookSyncFixture) sign(name string, data []byte) api.HookDistribution {
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	msg, _ := json.Marshal(map[string]string{"name": name, "sha256": digest})
+	return api.HookDistribution{
+		Name:      name,
+		SHA256:    digest,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(f.priv, msg)),
+	}
+}
+
+func (f *hookSyncFixture) inline(name, script string) api.HookDistribution {
+	dist := f.sign(name, []byte(script))
+	dist.Inline = base64.StdEncoding.EncodeToString([]byte(script))
+	return dist
+}
+
+// withMetadata returns dist with sidecar metadata, re-signed over the digest of
+// the metadata as well.
+func (f *hookSyncFixture) withMetadata(dist api.HookDistribution, metadata string) api.HookDistribution {
+	sum := sha256.Sum256([]byte(metadata))
+	msg, _ := json.Marshal(map[string]string{
+		"name":            dist.Name,
+		"sha256":          dist.SHA256,
+		"metadata_sha256": hex.EncodeToString(sum[:]),
+	})
+	dist.Metadata = json.RawMessage(metadata)
+	dist.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(f.priv, msg))
+	return dist
+}
+
+func hookEntry(t *testing.T, dist api.HookDistribution) api.DataEntry {
+	t.Helper()
+	payload, err := json.Marshal(dist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api.DataEntry{
+		Key:         "hooks/" + dist.Name,
+		ContentType: api.HookContentType,
+		Payload:     payload,
+		Version:     1,
+	}
+}
+
+func makeTarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sha256String(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHookSyncer_InstallsInlineHook(t *testing.T) {
+	f := newHookSyncFixture(t)
+	script := "#!/bin/sh\necho hello\n"
+
+	if err := f.syncer.Sync(context.Background(), []api.DataEntry{hookEntry(t, f.inline("hello", script))}); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	path := filepath.Join(f.hooksDir, "hello")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat hook: %v", err)
+	}
+	if info.Mode().Perm()&0o111 == 0 {
+		t.Errorf("hook mode = %v, want executable", info.Mode())
+	}
+	if got := readFileString(t, path); got != script {
+		t.Errorf("hook content = %q, want %q", got, script)
+	}
+
+	_, hooks := f.executor.Capabilities()
+	if len(hooks) != 1 || hooks[0].Name != "hello" || hooks[0].Source != HookSourceControlPlane {
+		t.Fatalf("executor hooks = %+v", hooks)
+	}
+	if hooks[0].Checksum != sha256String(script) {
+		t.Errorf("Checksum = %q", hooks[0].Checksum)
+	}
+	if got := f.approver.checksum("hello"); got != sha256String(script) {
+		t.Errorf("approved checksum = %q", got)
+	}
+	if got := f.publisher.count(); got != 1 {
+		t.Errorf("capabilities published %d times, want 1", got)
+	}
+}
+
+func TestHookSyncer_OnHooksChanged(t *testing.T) {
+	f := newHookSyncFixture(t)
+	changes := 0
+	f.syncer.OnHooksChanged(func() { changes++ })
+
+	entries := []api.DataEntry{hookEntry(t, f.inline("hello", "#!/bin/sh\n"))}
+	for range 2 {
+		if err := f.syncer.Sync(context.Background(), entries); err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+	}
+	if changes != 1 {
+		t.Errorf("OnHooksChanged called %d times, want 1", changes)
+	}
+}
+
+func TestHookSyncer_InstallsTarballWithSidecar(t *testing.T) {
+	f := newHookSyncFixture(t)
+	script := "#!/bin/sh\necho backup\n"
+	tarball := makeTarball(t, map[string]string{
+		"./backup":    script,
+		"backup.json": `{"description":"Run a backup","timeout":"5m"}`,
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(tarball)
+	}))
+	defer srv.Close()
+
+	dist := f.sign("backup", tarball)
+	dist.URL = srv.URL + "/backup.tar.gz"
+	if err := f.syncer.Sync(context.Background(), []api.DataEntry{hookEntry(t, dist)}); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	if got := readFileString(t, filepath.Join(f.hooksDir, "backup")); got != script {
+		t.Errorf("hook content = %q", got)
+	}
+	_, hooks := f.executor.Capabilities()
+	if len(hooks) != 1 || hooks[0].Description != "Run a backup" || hooks[0].Timeout != "5m" {
+		t.Errorf("executor hooks = %+v", hooks)
+	}
+}
+
+func TestHookSyncer_RejectsBadSignature(t *testing.T) {
+	f := newHookSyncFixture(t)
+	dist := f.inline("hello", "#!/bin/sh\n")
+	dist.Signature = base64.StdEncoding.EncodeToString(make([]byte, ed25519.SignatureSize))
+
+	err := f.syncer.Sync(context.Background(), []api.DataEntry{hookEntry(t, dist)})
+	if err == nil || !strings.Contains(err.Error(), "signature verification failed") {
+		t.Fatalf("Sync error = %v, want signature failure", err)
+	}
+	if _, err := os.Stat(filepath.Join(f.hooksDir, "hello")); !os.IsNotExist(err) {
+		t.Errorf("hook installed despite bad signature: %v", err)
+	}
+	if got := f.publisher.count(); got != 0 {
+		t.Errorf("capabilities published %d times, want 0", got)
+	}
+}
+
+func TestHookSyncer_RejectsRenamedHook(t *testing.T) {
+	f := newHookSyncFixture(t)
+	// A signature for "hello" must not install the script as "other".
+	dist := f.inline("hello", "#!/bin/sh\n")
+	dist.Name = "other"
+
+	if err := f.syncer.Sync(context.Background(), []api.DataEntry{hookEntry(t, dist)}); err == nil {
+		t.Fatal("Sync() = nil, want signature error")
+	}
+	if _, err := os.Stat(filepath.Join(f.hooksDir, "other")); !os.IsNotExist(err) {
+		t.Errorf("renamed hook installed: %v", err)
+	}
+}
+
+func TestHookSyncer_RejectsDigestMismatch(t *testing.T) {
+	f := newHookSyncFixture(t)
+	dist := f.inline("hello", "#!/bin/sh\necho signed\n")
+	dist.Inline = base64.StdEncoding.EncodeToString([]byte("#!/bin/sh\necho swapped\n"))
+
+	err := f.syncer.Sync(context.Background(), []api.DataEntry{hookEntry(t, dist)})
+	if err == nil || !strings.Contains(err.Error(), "sha256 mismatch") {
+		t.Fatalf("Sync error = %v, want sha256 mismatch", err)
+	}
+	if _, err := os.Stat(filepath.Join(f.hooksDir, "hello")); !os.IsNotExist(err) {
+		t.Errorf("hook installed despite digest mismatch: %v", err)
+	}
+}
+
+func TestHookSyncer_RejectsUnsignedMetadata(t *testing.T) {
+	f := newHookSyncFixture(t)
+	ctx := context.Background()
+
+	// Metadata added to a distribution signed without it.
+	dist := f.inline("hello", "#!/bin/sh\necho signed\n")
+	dist.Metadata = json.RawMessage(`{"sandbox":"none"}`)
+	err := f.syncer.Sync(ctx, []api.DataEntry{hookEntry(t, dist)})
+	if err == nil || !strings.Contains(err.Error(), "signature verification failed") {
+		t.Fatalf("Sync error = %v, want signature failure", err)
+	}
+
+	// Metadata swapped after signing.
+	dist = f.withMetadata(f.inline("hello", "#!/bin/sh\necho signed\n"), `{"description":"signed"}`)
+	dist.Metadata = json.RawMessage(`{"description":"signed","sandbox":"none"}`)
+	err = f.syncer.Sync(ctx, []api.DataEntry{hookEntry(t, dist)})
+	if err == nil || !strings.Contains(err.Error(), "signature verification failed") {
+		t.Fatalf("Sync error = %v, want signature failure", err)
+	}
+	for _, name := range []string{"hello", "hello.json"} {
+		if _, err := os.Stat(filepath.Join(f.hooksDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s installed despite unsigned metadata: %v", name, err)
+		}
+	}
+}
+
+func TestHookSyncer_ReinstallsChangedMetadata(t *testing.T) {
+	f := newHookSyncFixture(t)
+	ctx := context.Background()
+	script := "#!/bin/sh\necho hello\n"
+
+	v1 := f.withMetadata(f.inline("hello", script), `{"description":"v1"}`)
+	if err := f.syncer.Sync(ctx, []api.DataEntry{hookEntry(t, v1)}); err != nil {
+		t.Fatalf("Sync v1: %v", err)
+	}
+	v2 := f.withMetadata(f.inline("hello", script), `{"description":"v2"}`)
+	if err := f.syncer.Sync(ctx, []api.DataEntry{hookEntry(t, v2)}); err != nil {
+		t.Fatalf("Sync v2: %v", err)
+	}
+	if got := readFileString(t, filepath.Join(f.hooksDir, "hello.json")); got != `{"description":"v2"}` {
+		t.Errorf("sidecar = %q, want v2 metadata", got)
+	}
+}
+
+func TestHookSyncer_KeepsLocalHook(t *testing.T) {
+	f := newHookSyncFixture(t)
+	if err := os.MkdirAll(f.hooksDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	local := filepath.Join(f.hooksDir, "hello")
+	if err := os.WriteFile(local, []byte("#!/bin/sh\necho local\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	err := f.syncer.Sync(context.Background(), []api.DataEntry{hookEntry(t, f.inline("hello", "#!/bin/sh\necho remote\n"))})
+	if err == nil || !strings.Contains(err.Error(), "local hook") {
+		t.Fatalf("Sync error = %v, want local hook conflict", err)
+	}
+	if got := readFileString(t, local); got != "#!/bin/sh\necho local\n" {
+		t.Errorf("local hook replaced: %q", got)
+	}
+
+	// Removing all distributions never touches the local hook.
+	if err := f.syncer.Sync(context.Background(), nil); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if _, err := os.Stat(local); err != nil {
+		t.Errorf("local hook removed: %v", err)
+	}
+}
+
+func TestHookSyncer_UpdatesAndGarbageCollects(t *testing.T) {
+	f := newHookSyncFixture(t)
+	ctx := context.Background()
+	v1 := f.withMetadata(f.inline("hello", "#!/bin/sh\necho v1\n"), `{"description":"v1"}`)
+	if err := f.syncer.Sync(ctx, []api.DataEntry{hookEntry(t, v1), hookEntry(t, f.inline("bye", "#!/bin/sh\n"))}); err != nil {
+		t.Fatalf("Sync v1: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(f.hooksDir, "hello.json")); err != nil {
+		t.Fatalf("sidecar not installed: %v", err)
+	}
+
+	// Unchanged distributions are not reinstalled or republished.
+	if err := f.syncer.Sync(ctx, []api.DataEntry{hookEntry(t, v1), hookEntry(t, f.inline("bye", "#!/bin/sh\n"))}); err != nil {
+		t.Fatalf("Sync unchanged: %v", err)
+	}
+	if got := f.publisher.count(); got != 1 {
+		t.Errorf("capabilities published %d times, want 1", got)
+	}
+
+	// v2 without metadata replaces the script and drops the old sidecar; bye
+	// is no longer distributed and is removed.
+	if err := f.syncer.Sync(ctx, []api.DataEntry{hookEntry(t, f.inline("hello", "#!/bin/sh\necho v2\n"))}); err != nil {
+		t.Fatalf("Sync v2: %v", err)
+	}
+	if got := readFileString(t, filepath.Join(f.hooksDir, "hello")); got != "#!/bin/sh\necho v2\n" {
+		t.Errorf("hook content = %q", got)
+	}
+	for _, name := range []string{"hello.json", "bye"} {
+		if _, err := os.Stat(filepath.Join(f.hooksDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s still present: %v", name, err)
+		}
+	}
+	_, hooks := f.executor.Capabilities()
+	if len(hooks) != 1 || hooks[0].Name != "hello" {
+		t.Errorf("executor hooks = %+v", hooks)
+	}
+	if got := f.approver.checksum("bye"); got != "" {
+		t.Errorf("removed hook still approved: %q", got)
+	}
+	if got := f.publisher.count(); got != 2 {
+		t.Errorf("capabilities published %d times, want 2", got)
+	}
+}
+
+func TestHookSyncer_FailedUpdateKeepsInstalledVersion(t *testing.T) {
+	f := newHookSyncFixture(t)
+	ctx := context.Background()
+	if err := f.syncer.Sync(ctx, []api.DataEntry{hookEntry(t, f.inline("hello", "#!/bin/sh\necho v1\n"))}); err != nil {
+		t.Fatalf("Sync v1: %v", err)
+	}
+
+	bad := f.inline("hello", "#!/bin/sh\necho v2\n")
+	bad.Signature = ""
+	if err := f.syncer.Sync(ctx, []api.DataEntry{hookEntry(t, bad)}); err == nil {
+		t.Fatal("Sync() = nil, want error for unsigned update")
+	}
+	if got := readFileString(t, filepath.Join(f.hooksDir, "hello")); got != "#!/bin/sh\necho v1\n" {
+		t.Errorf("hook content = %q, want v1", got)
+	}
+}
+
+func TestHookSyncer_ScriptWriteFailureNotApproved(t *testing.T) {
+	f := newHookSyncFixture(t)
+	tarball := makeTarball(t, map[string]string{
+		"backup":      "#!/bin/sh\necho backup\n",
+		"backup.json": `{"description":"Run a backup"}`,
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(tarball)
+	}))
+	defer srv.Close()
+
+	write := f.syncer.writeFile
+	f.syncer.writeFile = func(dir, name string, data []byte, perm os.FileMode) error {
+		if name == "backup" {
+			return errors.New("disk full")
+		}
+		return write(dir, name, data, perm)
+	}
+
+	dist := f.sign("backup", tarball)
+	dist.URL = srv.URL + "/backup.tar.gz"
+	if err := f.syncer.Sync(context.Background(), []api.DataEntry{hookEntry(t, dist)}); err == nil {
+		t.Fatal("Sync() = nil, want error for failed script write")
+	}
+
+	if got := f.approver.checksum("backup"); got != "" {
+		t.Errorf("approved checksum = %q, want none", got)
+	}
+	if _, err := os.Stat(filepath.Join(f.hooksDir, "backup.json")); !os.IsNotExist(err) {
+		t.Errorf("sidecar written despite failed script write: %v", err)
+	}
+}
+
+func TestHookSyncer_InvalidEntries(t *testing.T) {
+	f := newHookSyncFixture(t)
+	entries := []api.DataEntry{
+		{Key: "other", ContentType: "application/json", Payload: json.RawMessage(`{"name":"x"}`)},
+		{Key: "broken", ContentType: api.HookContentType, Payload: json.RawMessage(`{`)},
+		hookEntry(t, f.inline("../escape", "#!/bin/sh\n")),
+		hookEntry(t, f.inline(".hidden", "#!/bin/sh\n")),
+	}
+
+	err := f.syncer.Sync(context.Background(), entries)
+	if err == nil {
+		t.Fatal("Sync() = nil, want errors")
+	}
+	for _, want := range []string{"parse broken", "invalid hook name: ../escape", "invalid hook name: .hidden"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestHookSyncer_NoHooksDir(t *testing.T) {
+	f := newHookSyncFixture(t)
+	f.syncer.cfg.HooksDir = ""
+
+	if err := f.syncer.Sync(context.Background(), nil); err != nil {
+		t.Errorf("Sync without hooks: %v", err)
+	}
+	err := f.syncer.Sync(context.Background(), []api.DataEntry{hookEntry(t, f.inline("hello", "#!/bin/sh\n"))})
+	if err == nil || !strings.Contains(err.Error(), "HooksDir is not configured") {
+		t.Errorf("Sync error = %v", err)
+	}
+}
+
+func TestUnpackHook_RejectsUnexpectedEntries(t *testing.T) {
+	tests := map[string]map[string]string{
+		"traversal":   {"hello": "x", "../evil": "x"},
+		"extra file":  {"hello": "x", "README": "x"},
+		"missing":     {"other": "x"},
+		"wrong level": {"dir/hello": "x"},
+	}
+	for name, files := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := unpackHook(makeTarball(t, files), "hello", DefaultMaxHookBytes); err == nil {
+				t.Error("unpackHook() = nil error")
+			}
+		})
+	}
+}
+
+func TestUnpackHook_SizeLimit(t *testing.T) {
+	tarball := makeTarball(t, map[string]string{"hello": strings.Repeat("x", 2048)})
+	if _, _, err := unpackHook(tarball, "hello", 1024); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("unpackHook() error = %v, want size limit", err)
+	}
+}
+
+func TestHookSyncReconcileHandler(t *testing.T) {
+	f := newHookSyncFixture(t)
+	handler := HookSyncReconcileHandler(f.syncer)
+	desired := &api.StateResponse{Data: []api.DataEntry{hookEntry(t, f.inline("hello", "#!/bin/sh\n"))}}
+
+	if err := handler(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(f.hooksDir, "hello")); !os.IsNotExist(err) {
+		t.Fatal("hook installed without a data change")
+	}
+
+	if err := handler(context.Background(), desired, reconcile.StateDiff{DataChanged: true}); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(f.hooksDir, "hello")); err != nil {
+		t.Errorf("hook not installed: %v", err)
+	}
+}
+
+func readFileString(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(data)
+}
@@ -0,0 +1,173 @@
+package actions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/fsutil"
+)
+
+const jobStoreFileName = "executions.json"
+
+// Job statuses recorded by the JobStore in addition to the final execution
+// statuses.
+const (
+	// JobStatusRunning marks an accepted execution that has not finished.
+	JobStatusRunning = "running"
+	// JobStatusInterrupted is reported for executions that were running when
+	// the agent stopped.
+	JobStatusInterrupted = "interrupted"
+)
+
+// Finished jobs are kept for deduplication of replayed action requests for
+// jobRetention, and at most maxFinishedJobs of them.
+const (
+	jobRetention    = 24 * time.Hour
+	maxFinishedJobs = 1000
+)
+
+// Job is the persisted record of an accepted execution.
+type Job struct {
+	ExecutionID string            `json:"execution_id"`
+	Action      string            `json:"action"`
+	Parameters  map[string]string `json:"parameters,omitempty"`
+	Status      string            `json:"status"`
+	AcceptedAt  time.Time         `json:"accepted_at"`
+	FinishedAt  time.Time         `json:"finished_at,omitempty"`
+	TriggeredBy *api.TriggeredBy  `json:"triggered_by,omitempty"`
+}
+
+// JobStore persists accepted executions as a JSON file in the agent's data
+// directory, so that executions interrupted by a restart can be reported and
+// replayed action requests can be recognized.
+type JobStore struct {
+	mu      sync.Mutex
+	dataDir string
+	jobs    map[string]Job
+}
+
+// NewJobStore creates a JobStore backed by dataDir/executions.json.
+// If the file does not exist, an empty store is created.
+func NewJobStore(dataDir string) (*JobStore, error) {
+	s := &JobStore{
+		dataDir: dataDir,
+		jobs:    make(map[string]Job),
+	}
+
+	data, err := os.ReadFile(filepath.Join(dataDir, jobStoreFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("actions: job store: read: %w", err)
+	}
+
+	var jobs []Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, fmt.Errorf("actions: job store: parse: %w", err)
+	}
+	for _, j := range jobs {
+		s.jobs[j.ExecutionID] = j
+	}
+	return s, nil
+}
+
+// Has reports whether an execution with id was accepted.
+func (s *JobStore) Has(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.jobs[id]
+	return ok
+}
+
+// Accept records job as running and persists the store.
+func (s *JobStore) Accept(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.Status = JobStatusRunning
+	s.jobs[job.ExecutionID] = job
+	return s.persist()
+}
+
+// Finish records the final status of the execution id and persists the
+// store. Finished jobs past the retention limits are pruned.
+func (s *JobStore) Finish(id, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil
+	}
+	job.Status = status
+	job.FinishedAt = time.Now().UTC()
+	s.jobs[id] = job
+	s.prune()
+	return s.persist()
+}
+
+// Running returns the jobs recorded as running, oldest first.
+func (s *JobStore) Running() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var running []Job
+	for _, j := range s.jobs {
+		if j.Status == JobStatusRunning {
+			running = append(running, j)
+		}
+	}
+	sort.Slice(running, func(i, k int) bool {
+		return running[i].AcceptedAt.Before(running[k].AcceptedAt)
+	})
+	return running
+}
+
+// prune drops finished jobs older than jobRetention and the oldest finished
+// jobs beyond maxFinishedJobs. The caller holds s.mu.
+func (s *JobStore) prune() {
+	cutoff := time.Now().Add(-jobRetention)
+	var finished []Job
+	for id, j := range s.jobs {
+		if j.Status == JobStatusRunning {
+			continue
+		}
+		if j.FinishedAt.Before(cutoff) {
+			delete(s.jobs, id)
+			continue
+		}
+		finished = append(finished, j)
+	}
+	if over := len(finished) - maxFinishedJobs; over > 0 {
+		sort.Slice(finished, func(i, k int) bool {
+			return finished[i].FinishedAt.Before(finished[k].FinishedAt)
+		})
+		for _, j := range finished[:over] {
+			delete(s.jobs, j.ExecutionID)
+		}
+	}
+}
+
+// persist writes the store. The caller holds s.mu.
+func (s *JobStore) persist() error {
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].AcceptedAt.Before(jobs[k].AcceptedAt)
+	})
+	data, err := json.Marshal(jobs)
+	if err != nil {
+		return fmt.Errorf("actions: job store: marshal: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(s.dataDir, jobStoreFileName, data, 0o600); err != nil {
+		return fmt.Errorf("actions: job store: write: %w", err)
+	}
+	return nil
+}
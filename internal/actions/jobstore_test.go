@@ -0,0 +1,313 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+func TestJobStore_PersistAndReload(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewJobStore(dir)
+	if err != nil {
+		t.Fatalf("NewJobStore: %v", err)
+	}
+
+	job := Job{
+		ExecutionID: "exec-1",
+		Action:      "deploy",
+		Parameters:  map[string]string{"version": "1.2.3"},
+		AcceptedAt:  time.Now().UTC(),
+		TriggeredBy: &api.TriggeredBy{Type: "user", Email: "ops@example.com"},
+	}
+	if err := store.Accept(job); err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, jobStoreFileName))
+	if err != nil {
+		t.Fatalf("stat store: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("store mode = %o, want 600", perm)
+	}
+
+	reloaded, err := NewJobStore(dir)
+	if err != nil {
+		t.Fatalf("NewJobStore reload: %v", err)
+	}
+	if !reloaded.Has("exec-1") {
+		t.Fatal("reloaded store does not have exec-1")
+	}
+	running := reloaded.Running()
+	if len(running) != 1 {
+		t.Fatalf("running = %d, want 1", len(running))
+	}
+	got := running[0]
+	if got.Status != JobStatusRunning {
+		t.Errorf("status = %q, want %q", got.Status, JobStatusRunning)
+	}
+	if got.Action != "deploy" || got.Parameters["version"] != "1.2.3" {
+		t.Errorf("job = %+v, want action deploy with version 1.2.3", got)
+	}
+	if got.TriggeredBy == nil || got.TriggeredBy.Email != "ops@example.com" {
+		t.Errorf("triggered_by = %+v, want ops@example.com", got.TriggeredBy)
+	}
+}
+
+func TestJobStore_Finish(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewJobStore(dir)
+	if err != nil {
+		t.Fatalf("NewJobStore: %v", err)
+	}
+	if err := store.Accept(Job{ExecutionID: "exec-1", Action: "a", AcceptedAt: time.Now()}); err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	if err := store.Finish("exec-1", "success"); err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+	if err := store.Finish("unknown", "success"); err != nil {
+		t.Fatalf("Finish unknown: %v", err)
+	}
+
+	reloaded, err := NewJobStore(dir)
+	if err != nil {
+		t.Fatalf("NewJobStore reload: %v", err)
+	}
+	if len(reloaded.Running()) != 0 {
+		t.Errorf("running = %d, want 0", len(reloaded.Running()))
+	}
+	if !reloaded.Has("exec-1") {
+		t.Error("finished job should be kept for deduplication")
+	}
+	if reloaded.Has("unknown") {
+		t.Error("Finish of an unknown job should not record it")
+	}
+}
+
+func TestJobStore_RunningOldestFirst(t *testing.T) {
+	store, err := NewJobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewJobStore: %v", err)
+	}
+	now := time.Now()
+	for _, j := range []Job{
+		{ExecutionID: "second", AcceptedAt: now.Add(-time.Minute)},
+		{ExecutionID: "third", AcceptedAt: now},
+		{ExecutionID: "first", AcceptedAt: now.Add(-time.Hour)},
+	} {
+		if err := store.Accept(j); err != nil {
+			t.Fatalf("Accept: %v", err)
+		}
+	}
+
+	running := store.Running()
+	want := []string{"first", "second", "third"}
+	if len(running) != len(want) {
+		t.Fatalf("running = %d, want %d", len(running), len(want))
+	}
+	for i, id := range want {
+		if running[i].ExecutionID != id {
+			t.Errorf("running[%d] = %q, want %q", i, running[i].ExecutionID, id)
+		}
+	}
+}
+
+func TestJobStore_PrunesFinishedJobs(t *testing.T) {
+	store, err := NewJobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewJobStore: %v", err)
+	}
+	store.jobs["expired"] = Job{
+		ExecutionID: "expired",
+		Status:      "success",
+		FinishedAt:  time.Now().Add(-jobRetention - time.Hour),
+	}
+	store.jobs["old-running"] = Job{
+		ExecutionID: "old-running",
+		Status:      JobStatusRunning,
+		AcceptedAt:  time.Now().Add(-jobRetention - time.Hour),
+	}
+	if err := store.Accept(Job{ExecutionID: "recent", AcceptedAt: time.Now()}); err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	if err := store.Finish("recent", "success"); err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+
+	if store.Has("expired") {
+		t.Error("expired finished job was not pruned")
+	}
+	if !store.Has("old-running") {
+		t.Error("running job must not be pruned")
+	}
+	if !store.Has("recent") {
+		t.Error("recent finished job was pruned")
+	}
+}
+
+func TestJobStore_PrunesBeyondLimit(t *testing.T) {
+	store, err := NewJobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewJobStore: %v", err)
+	}
+	now := time.Now()
+	for i := 0; i < maxFinishedJobs; i++ {
+		id := "job-" + time.Duration(i).String()
+		store.jobs[id] = Job{ExecutionID: id, Status: "success", FinishedAt: now.Add(-time.Duration(i+1) * time.Second)}
+	}
+	oldest := "job-" + time.Duration(maxFinishedJobs-1).String()
+
+	if err := store.Accept(Job{ExecutionID: "newest", AcceptedAt: now}); err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	if err := store.Finish("newest", "success"); err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+
+	if len(store.jobs) != maxFinishedJobs {
+		t.Errorf("jobs = %d, want %d", len(store.jobs), maxFinishedJobs)
+	}
+	if store.Has(oldest) {
+		t.Error("oldest finished job was not pruned")
+	}
+	if !store.Has("newest") {
+		t.Error("newest finished job was pruned")
+	}
+}
+
+func TestNewJobStore_CorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, jobStoreFileName), []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewJobStore(dir); err == nil {
+		t.Fatal("expected error for corrupt store")
+	}
+}
+
+func TestExecutor_JobStore_DeduplicatesAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewJobStore(dir)
+	if err != nil {
+		t.Fatalf("NewJobStore: %v", err)
+	}
+
+	reporter := &mockReporter{}
+	exec := newTestExecutor(Config{}, reporter, &mockVerifier{ok: true})
+	exec.SetJobStore(store)
+	exec.RegisterBuiltin("test.echo", "Echo action", nil, func(_ context.Context, _ map[string]string) (string, string, int, error) {
+		return "ok", "", 0, nil
+	})
+
+	req := api.ActionRequest{ExecutionID: "exec-replay", Action: "test.echo", Timeout: "10s"}
+	exec.Execute(context.Background(), "node-1", req)
+	waitFor(t, 5*time.Second, func() bool {
+		return len(reporter.getResults()) > 0
+	})
+	waitFor(t, 5*time.Second, func() bool {
+		return len(store.Running()) == 0
+	})
+
+	// A new executor on a reloaded store stands in for a restarted daemon.
+	reloaded, err := NewJobStore(dir)
+	if err != nil {
+		t.Fatalf("NewJobStore reload: %v", err)
+	}
+	reporter2 := &mockReporter{}
+	exec2 := newTestExecutor(Config{}, reporter2, &mockVerifier{ok: true})
+	exec2.SetJobStore(reloaded)
+	exec2.RegisterBuiltin("test.echo", "Echo action", nil, func(_ context.Context, _ map[string]string) (string, string, int, error) {
+		t.Error("replayed action must not run")
+		return "", "", 0, nil
+	})
+
+	exec2.Execute(context.Background(), "node-1", req)
+
+	acks := reporter2.getAcks()
+	if len(acks) != 1 {
+		t.Fatalf("acks = %d, want 1", len(acks))
+	}
+	if acks[0].Status != "rejected" || acks[0].Reason != "duplicate_execution_id" {
+		t.Errorf("ack = %+v, want rejected duplicate_execution_id", acks[0])
+	}
+}
+
+func TestExecutor_RecoverInterrupted(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewJobStore(dir)
+	if err != nil {
+		t.Fatalf("NewJobStore: %v", err)
+	}
+	trigger := &api.TriggeredBy{Type: "user", UserID: "u-1"}
+	if err := store.Accept(Job{ExecutionID: "exec-cut", Action: "deploy", AcceptedAt: time.Now(), TriggeredBy: trigger}); err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+
+	reloaded, err := NewJobStore(dir)
+	if err != nil {
+		t.Fatalf("NewJobStore reload: %v", err)
+	}
+	reporter := &mockReporter{}
+	exec := newTestExecutor(Config{}, reporter, &mockVerifier{ok: true})
+	exec.SetJobStore(reloaded)
+
+	exec.RecoverInterrupted(context.Background(), "node-1")
+
+	results := reporter.getResults()
+	if len(results) != 1 {
+		t.Fatalf("results = %d, want 1", len(results))
+	}
+	r := results[0]
+	if r.ExecutionID != "exec-cut" || r.Status != JobStatusInterrupted {
+		t.Errorf("result = %+v, want exec-cut interrupted", r)
+	}
+	if r.TriggeredBy == nil || r.TriggeredBy.UserID != "u-1" {
+		t.Errorf("triggered_by = %+v, want u-1", r.TriggeredBy)
+	}
+	if len(reloaded.Running()) != 0 {
+		t.Error("interrupted job still recorded as running")
+	}
+	if !reloaded.Has("exec-cut") {
+		t.Error("interrupted job should be kept for deduplication")
+	}
+
+	// Already reported executions are not reported again.
+	exec.RecoverInterrupted(context.Background(), "node-1")
+	if n := len(reporter.getResults()); n != 1 {
+		t.Errorf("results after second recovery = %d, want 1", n)
+	}
+}
+
+func TestExecutor_RecoverInterrupted_ReportFailureRetries(t *testing.T) {
+	store, err := NewJobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewJobStore: %v", err)
+	}
+	if err := store.Accept(Job{ExecutionID: "exec-cut", Action: "deploy", AcceptedAt: time.Now()}); err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+
+	reporter := &mockReporter{resErr: errors.New("unavailable")}
+	exec := newTestExecutor(Config{}, reporter, &mockVerifier{ok: true})
+	exec.SetJobStore(store)
+
+	exec.RecoverInterrupted(context.Background(), "node-1")
+	if len(store.Running()) != 1 {
+		t.Fatal("job should stay running when the report fails")
+	}
+
+	reporter.mu.Lock()
+	reporter.resErr = nil
+	reporter.mu.Unlock()
+	exec.RecoverInterrupted(context.Background(), "node-1")
+	if len(store.Running()) != 0 {
+		t.Error("job should be finished after a successful report")
+	}
+}
@@ -0,0 +1,408 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/fsutil"
+)
+
+// Names of the built-in actions registered by Library.
+const (
+	ActionServiceRestart  = "service_restart"
+	ActionPackageInstall  = "package_install"
+	ActionPackageUpgrade  = "package_upgrade"
+	ActionFetchFile       = "fetch_file"
+	ActionSysctlSet       = "sysctl_set"
+	ActionRebootScheduled = "reboot_scheduled"
+)
+
+// maxRebootDelay bounds the delay of reboot_scheduled.
+const maxRebootDelay = 24 * time.Hour
+
+var (
+	// unitNamePattern matches systemd unit names; a leading "-" is rejected
+	// so a name cannot be parsed as a systemctl option.
+	unitNamePattern = regexp.MustCompile(`^[A-Za-z0-9:_.@\\][A-Za-z0-9:_.@\\-]*$`)
+	// packageNamePattern matches package names across apt, dnf, zypper and apk.
+	packageNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.+_:~-]*$`)
+	// sysctlKeyPattern matches dotted kernel parameter names.
+	sysctlKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+)
+
+// commandRunner runs an external command with extra environment variables and
+// returns its output and exit code. err is set when the command could not be
+// started.
+type commandRunner func(ctx context.Context, env []string, name string, args ...string) (stdout, stderr string, exitCode int, err error)
+
+// packageManager describes the command lines of a package manager.
+type packageManager struct {
+	name    string
+	install []string
+	upgrade []string
+	env     []string
+}
+
+// packageManagers are probed in order; the first one found in PATH is used.
+var packageManagers = []packageManager{
+	{name: "apt-get", install: []string{"install", "-y"}, upgrade: []string{"install", "-y", "--only-upgrade"}, env: []string{"DEBIAN_FRONTEND=noninteractive"}},
+	{name: "dnf", install: []string{"install", "-y"}, upgrade: []string{"upgrade", "-y"}},
+	{name: "yum", install: []string{"install", "-y"}, upgrade: []string{"update", "-y"}},
+	{name: "zypper", install: []string{"--non-interactive", "install"}, upgrade: []string{"--non-interactive", "update"}},
+	{name: "apk", install: []string{"add"}, upgrade: []string{"upgrade"}},
+}
+
+// Library provides the curated host management built-in actions:
+// service_restart, package_install, package_upgrade, fetch_file, sysctl_set
+// and reboot_scheduled. Each action is guarded by an allowlist in Config and
+// is only registered when its allowlist permits anything.
+type Library struct {
+	cfg    Config
+	logger *slog.Logger
+
+	run        commandRunner
+	lookPath   func(file string) (string, error)
+	httpClient *http.Client
+	procSys    string
+}
+
+// NewLibrary creates a Library for cfg.
+func NewLibrary(cfg Config, logger *slog.Logger) *Library {
+	return &Library{
+		cfg:        cfg,
+		logger:     logger.With("component", "actions"),
+		run:        runCommand,
+		lookPath:   exec.LookPath,
+		httpClient: &http.Client{},
+		procSys:    "/proc/sys",
+	}
+}
+
+// Register registers the actions enabled by the allowlists on e, together
+// with their parameter schemas, and returns their names.
+func (l *Library) Register(e *Executor) []string {
+	var names []string
+	register := func(name, description string, params []api.ActionParam, fn BuiltinFunc) {
+		e.RegisterBuiltin(name, description, params, fn)
+		names = append(names, name)
+	}
+
+	if len(l.cfg.AllowedServices) > 0 {
+		register(ActionServiceRestart, "Restart an allowlisted systemd service", []api.ActionParam{
+			{Name: "service", Type: "string", Required: true, Description: "Unit name, e.g. nginx.service"},
+		}, l.ServiceRestart)
+	}
+	if len(l.cfg.AllowedPackages) > 0 {
+		pkgs := []api.ActionParam{
+			{Name: "packages", Type: "string", Required: true, Description: "Comma-separated package names"},
+		}
+		register(ActionPackageInstall, "Install allowlisted packages", pkgs, l.PackageInstall)
+		register(ActionPackageUpgrade, "Upgrade allowlisted packages", pkgs, l.PackageUpgrade)
+	}
+	if len(l.cfg.FetchFileDirs) > 0 {
+		register(ActionFetchFile, "Download a file over HTTPS into an allowlisted directory", []api.ActionParam{
+			{Name: "url", Type: "string", Required: true, Description: "HTTPS URL to download"},
+			{Name: "path", Type: "string", Required: true, Description: "Absolute destination path"},
+			{Name: "sha256", Type: "string", Required: true, Description: "Expected SHA-256 of the file, hex-encoded"},
+			{Name: "mode", Type: "string", Description: "Octal file mode, default 0644"},
+		}, l.FetchFile)
+	}
+	if len(l.cfg.AllowedSysctls) > 0 {
+		register(ActionSysctlSet, "Set an allowlisted kernel parameter", []api.ActionParam{
+			{Name: "key", Type: "string", Required: true, Description: "Dotted parameter name, e.g. net.ipv4.ip_forward"},
+			{Name: "value", Type: "string", Required: true, Description: "Value to write"},
+		}, l.SysctlSet)
+	}
+	if l.cfg.AllowReboot {
+		register(ActionRebootScheduled, "Schedule a reboot of the node", []api.ActionParam{
+			{Name: "delay", Type: "duration", Description: "Delay before the reboot, e.g. 10m; default 1m, max 24h"},
+			{Name: "message", Type: "string", Description: "Message broadcast to logged-in users"},
+		}, l.RebootScheduled)
+	}
+	return names
+}
+
+// ServiceRestart restarts the systemd unit named by the "service" parameter.
+func (l *Library) ServiceRestart(ctx context.Context, params map[string]string) (string, string, int, error) {
+	service := params["service"]
+	if service == "" {
+		return "", "", 1, errors.New("missing required parameter: service")
+	}
+	if !unitNamePattern.MatchString(service) {
+		return "", fmt.Sprintf("invalid service name: %s", service), 1, nil
+	}
+	if !matchAny(l.cfg.AllowedServices, service) {
+		return "", fmt.Sprintf("service not allowed: %s", service), 1, nil
+	}
+
+	l.logger.Info("restarting service", "service", service)
+	return l.run(ctx, nil, "systemctl", "restart", service)
+}
+
+// PackageInstall installs the packages named by the "packages" parameter.
+func (l *Library) PackageInstall(ctx context.Context, params map[string]string) (string, string, int, error) {
+	return l.managePackages(ctx, params, false)
+}
+
+// PackageUpgrade upgrades the packages named by the "packages" parameter.
+func (l *Library) PackageUpgrade(ctx context.Context, params map[string]string) (string, string, int, error) {
+	return l.managePackages(ctx, params, true)
+}
+
+func (l *Library) managePackages(ctx context.Context, params map[string]string, upgrade bool) (string, string, int, error) {
+	var pkgs []string
+	for _, p := range strings.Split(params["packages"], ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			pkgs = append(pkgs, p)
+		}
+	}
+	if len(pkgs) == 0 {
+		return "", "", 1, errors.New("missing required parameter: packages")
+	}
+	for _, p := range pkgs {
+		if !packageNamePattern.MatchString(p) {
+			return "", fmt.Sprintf("invalid package name: %s", p), 1, nil
+		}
+		if !matchAny(l.cfg.AllowedPackages, p) {
+			return "", fmt.Sprintf("package not allowed: %s", p), 1, nil
+		}
+	}
+
+	pm, ok := l.packageManager()
+	if !ok {
+		return "", "no supported package manager found", 1, nil
+	}
+	args := pm.install
+	if upgrade {
+		args = pm.upgrade
+	}
+	args = append(append([]string{}, args...), pkgs...)
+
+	l.logger.Info("managing packages",
+		"manager", pm.name,
+		"upgrade", upgrade,
+		"packages", strings.Join(pkgs, ","),
+	)
+	return l.run(ctx, pm.env, pm.name, args...)
+}
+
+// packageManager returns the first package manager found in PATH.
+func (l *Library) packageManager() (packageManager, bool) {
+	for _, pm := range packageManagers {
+		if _, err := l.lookPath(pm.name); err == nil {
+			return pm, true
+		}
+	}
+	return packageManager{}, false
+}
+
+// FetchFile downloads the "url" parameter over HTTPS, verifies its SHA-256
+// against the "sha256" parameter and atomically writes it to "path", which
+// must lie within one of Config.FetchFileDirs.
+func (l *Library) FetchFile(ctx context.Context, params map[string]string) (string, string, int, error) {
+	for _, name := range []string{"url", "path", "sha256"} {
+		if params[name] == "" {
+			return "", "", 1, fmt.Errorf("missing required parameter: %s", name)
+		}
+	}
+
+	u, err := url.Parse(params["url"])
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Sprintf("invalid url: must be an https URL: %s", params["url"]), 1, nil
+	}
+	want := strings.ToLower(params["sha256"])
+	if b, err := hex.DecodeString(want); err != nil || len(b) != sha256.Size {
+		return "", "invalid sha256: must be 64 hex characters", 1, nil
+	}
+	mode := os.FileMode(0o644)
+	if m := params["mode"]; m != "" {
+		v, err := strconv.ParseUint(m, 8, 32)
+		if err != nil || v&^0o777 != 0 {
+			return "", fmt.Sprintf("invalid mode: %s", m), 1, nil
+		}
+		mode = os.FileMode(v)
+	}
+	dest, err := l.fetchDestination(params["path"])
+	if err != nil {
+		return "", err.Error(), 1, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", "", 1, fmt.Errorf("fetch_file: request: %w", err)
+	}
+	resp, err := l.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Sprintf("download failed: %v", err), 1, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Sprintf("download failed: HTTP %d", resp.StatusCode), 1, nil
+	}
+
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(resp.Body, l.cfg.MaxFetchBytes+1))
+	if err != nil {
+		return "", fmt.Sprintf("download failed: %v", err), 1, nil
+	}
+	if n > l.cfg.MaxFetchBytes {
+		return "", fmt.Sprintf("file exceeds %d bytes", l.cfg.MaxFetchBytes), 1, nil
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return "", fmt.Sprintf("sha256 mismatch: got %s, want %s", got, want), 1, nil
+	}
+
+	if err := fsutil.WriteFileAtomic(filepath.Dir(dest), filepath.Base(dest), buf.Bytes(), mode); err != nil {
+		return "", fmt.Sprintf("write failed: %v", err), 1, nil
+	}
+	if err := os.Chmod(dest, mode); err != nil {
+		return "", fmt.Sprintf("chmod failed: %v", err), 1, nil
+	}
+
+	l.logger.Info("file fetched", "url", u.Redacted(), "path", dest, "bytes", n)
+	return fmt.Sprintf("wrote %d bytes to %s", n, dest), "", 0, nil
+}
+
+// fetchDestination resolves p and checks that it lies within one of
+// Config.FetchFileDirs, following symlinks in its parent directory.
+func (l *Library) fetchDestination(p string) (string, error) {
+	if !filepath.IsAbs(p) {
+		return "", fmt.Errorf("invalid path: must be absolute: %s", p)
+	}
+	p = filepath.Clean(p)
+	base := filepath.Base(p)
+	if base == "/" || strings.HasPrefix(base, ".") {
+		return "", fmt.Errorf("invalid path: %s", p)
+	}
+	parent, err := filepath.EvalSymlinks(filepath.Dir(p))
+	if err != nil {
+		return "", fmt.Errorf("invalid path: %v", err)
+	}
+	dest := filepath.Join(parent, base)
+	for _, dir := range l.cfg.FetchFileDirs {
+		root, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, dest); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			if info, err := os.Lstat(dest); err == nil && !info.Mode().IsRegular() {
+				return "", fmt.Errorf("invalid path: not a regular file: %s", p)
+			}
+			return dest, nil
+		}
+	}
+	return "", fmt.Errorf("path not allowed: %s", p)
+}
+
+// SysctlSet writes the "value" parameter to the kernel parameter named by
+// "key". The change is not persisted across reboots.
+func (l *Library) SysctlSet(_ context.Context, params map[string]string) (string, string, int, error) {
+	key, value := params["key"], params["value"]
+	if key == "" {
+		return "", "", 1, errors.New("missing required parameter: key")
+	}
+	if value == "" {
+		return "", "", 1, errors.New("missing required parameter: value")
+	}
+	if !sysctlKeyPattern.MatchString(key) {
+		return "", fmt.Sprintf("invalid sysctl key: %s", key), 1, nil
+	}
+	if strings.ContainsAny(value, "\n\x00") {
+		return "", "invalid sysctl value", 1, nil
+	}
+	if !matchAny(l.cfg.AllowedSysctls, key) {
+		return "", fmt.Sprintf("sysctl not allowed: %s", key), 1, nil
+	}
+
+	p := filepath.Join(l.procSys, strings.ReplaceAll(key, ".", "/"))
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return "", fmt.Sprintf("open %s: %v", key, err), 1, nil
+	}
+	if _, err := f.WriteString(value + "\n"); err != nil {
+		f.Close()
+		return "", fmt.Sprintf("write %s: %v", key, err), 1, nil
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Sprintf("write %s: %v", key, err), 1, nil
+	}
+
+	l.logger.Info("sysctl set", "key", key, "value", value)
+	return fmt.Sprintf("%s = %s", key, value), "", 0, nil
+}
+
+// RebootScheduled schedules a reboot after the "delay" parameter, rounded up
+// to whole minutes, using shutdown(8).
+func (l *Library) RebootScheduled(ctx context.Context, params map[string]string) (string, string, int, error) {
+	delay := time.Minute
+	if d := params["delay"]; d != "" {
+		v, err := time.ParseDuration(d)
+		if err != nil || v < 0 || v > maxRebootDelay {
+			return "", fmt.Sprintf("invalid delay: %s (must be between 0 and %s)", d, maxRebootDelay), 1, nil
+		}
+		delay = v
+	}
+	minutes := int((delay + time.Minute - 1) / time.Minute)
+
+	args := []string{"-r", "+" + strconv.Itoa(minutes)}
+	if msg := params["message"]; msg != "" {
+		if strings.HasPrefix(msg, "-") || strings.ContainsAny(msg, "\n\x00") {
+			return "", "invalid message", 1, nil
+		}
+		args = append(args, msg)
+	}
+
+	l.logger.Warn("reboot scheduled", "delay_minutes", minutes)
+	return l.run(ctx, nil, "shutdown", args...)
+}
+
+// matchAny reports whether name matches one of patterns, each an exact name
+// or a path.Match pattern.
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, err := path.Match(p, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// runCommand is the default commandRunner.
+func runCommand(ctx context.Context, env []string, name string, args ...string) (string, string, int, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = 500 * time.Millisecond
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return stdout.String(), stderr.String(), exitErr.ExitCode(), nil
+		}
+		return stdout.String(), stderr.String(), 1, err
+	}
+	return stdout.String(), stderr.String(), 0, nil
+}
@@ -0,0 +1,324 @@
+package actions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recordedCommand is a command captured by a fake commandRunner.
+type recordedCommand struct {
+	env  []string
+	name string
+	args []string
+}
+
+func newTestLibrary(t *testing.T, cfg Config) (*Library, *[]recordedCommand) {
+	t.Helper()
+	cfg.ApplyDefaults()
+	lib := NewLibrary(cfg, testLogger())
+	var cmds []recordedCommand
+	lib.run = func(_ context.Context, env []string, name string, args ...string) (string, string, int, error) {
+		cmds = append(cmds, recordedCommand{env: env, name: name, args: args})
+		return "ok", "", 0, nil
+	}
+	lib.lookPath = func(file string) (string, error) {
+		if file == "dnf" {
+			return "/usr/bin/dnf", nil
+		}
+		return "", errors.New("not found")
+	}
+	return lib, &cmds
+}
+
+func TestLibrary_RegisterGuardedByAllowlists(t *testing.T) {
+	lib, _ := newTestLibrary(t, Config{})
+	exec := newTestExecutor(Config{}, &mockReporter{}, &mockVerifier{ok: true})
+	if names := lib.Register(exec); len(names) != 0 {
+		t.Errorf("registered %v with empty allowlists, want none", names)
+	}
+
+	lib, _ = newTestLibrary(t, Config{
+		AllowedServices: []string{"nginx.service"},
+		AllowedPackages: []string{"curl"},
+		FetchFileDirs:   []string{t.TempDir()},
+		AllowedSysctls:  []string{"net.ipv4.ip_forward"},
+		AllowReboot:     true,
+	})
+	exec = newTestExecutor(Config{}, &mockReporter{}, &mockVerifier{ok: true})
+	names := lib.Register(exec)
+	want := []string{
+		ActionServiceRestart, ActionPackageInstall, ActionPackageUpgrade,
+		ActionFetchFile, ActionSysctlSet, ActionRebootScheduled,
+	}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("registered = %v, want %v", names, want)
+	}
+
+	builtins, _ := exec.Capabilities()
+	params := make(map[string]int)
+	for _, b := range builtins {
+		params[b.Name] = len(b.Parameters)
+	}
+	for _, name := range want {
+		if params[name] == 0 {
+			t.Errorf("capabilities: %s missing or without parameter schema", name)
+		}
+	}
+}
+
+func TestLibrary_ServiceRestart(t *testing.T) {
+	lib, cmds := newTestLibrary(t, Config{AllowedServices: []string{"nginx.service", "app-*.service"}})
+
+	_, _, code, err := lib.ServiceRestart(context.Background(), map[string]string{"service": "app-web.service"})
+	if err != nil || code != 0 {
+		t.Fatalf("ServiceRestart = %d, %v; want 0, nil", code, err)
+	}
+	if len(*cmds) != 1 {
+		t.Fatalf("commands = %d, want 1", len(*cmds))
+	}
+	got := (*cmds)[0]
+	if got.name != "systemctl" || strings.Join(got.args, " ") != "restart app-web.service" {
+		t.Errorf("command = %s %v, want systemctl restart app-web.service", got.name, got.args)
+	}
+
+	for _, svc := range []string{"sshd.service", "--force", "nginx.service;reboot"} {
+		_, stderr, code, _ := lib.ServiceRestart(context.Background(), map[string]string{"service": svc})
+		if code == 0 {
+			t.Errorf("ServiceRestart(%q) succeeded, want failure", svc)
+		}
+		if stderr == "" {
+			t.Errorf("ServiceRestart(%q) stderr empty", svc)
+		}
+	}
+	if len(*cmds) != 1 {
+		t.Errorf("rejected services ran commands: %v", *cmds)
+	}
+
+	if _, _, _, err := lib.ServiceRestart(context.Background(), nil); err == nil {
+		t.Error("ServiceRestart without service: want error")
+	}
+}
+
+func TestLibrary_Packages(t *testing.T) {
+	lib, cmds := newTestLibrary(t, Config{AllowedPackages: []string{"curl", "nginx*"}})
+
+	if _, _, code, err := lib.PackageInstall(context.Background(), map[string]string{"packages": "curl, nginx-core"}); err != nil || code != 0 {
+		t.Fatalf("PackageInstall = %d, %v; want 0, nil", code, err)
+	}
+	if _, _, code, err := lib.PackageUpgrade(context.Background(), map[string]string{"packages": "curl"}); err != nil || code != 0 {
+		t.Fatalf("PackageUpgrade = %d, %v; want 0, nil", code, err)
+	}
+	if len(*cmds) != 2 {
+		t.Fatalf("commands = %d, want 2", len(*cmds))
+	}
+	if got := strings.Join((*cmds)[0].args, " "); (*cmds)[0].name != "dnf" || got != "install -y curl nginx-core" {
+		t.Errorf("install command = %s %s", (*cmds)[0].name, got)
+	}
+	if got := strings.Join((*cmds)[1].args, " "); got != "upgrade -y curl" {
+		t.Errorf("upgrade command = dnf %s", got)
+	}
+
+	for _, pkgs := range []string{"curl,openssh-server", "-y", "curl;rm"} {
+		_, _, code, _ := lib.PackageInstall(context.Background(), map[string]string{"packages": pkgs})
+		if code == 0 {
+			t.Errorf("PackageInstall(%q) succeeded, want failure", pkgs)
+		}
+	}
+	if len(*cmds) != 2 {
+		t.Errorf("rejected packages ran commands: %v", *cmds)
+	}
+	if _, _, _, err := lib.PackageInstall(context.Background(), map[string]string{"packages": " , "}); err == nil {
+		t.Error("PackageInstall without packages: want error")
+	}
+}
+
+func TestLibrary_Packages_NoManager(t *testing.T) {
+	lib, cmds := newTestLibrary(t, Config{AllowedPackages: []string{"curl"}})
+	lib.lookPath = func(string) (string, error) { return "", errors.New("not found") }
+
+	_, stderr, code, _ := lib.PackageInstall(context.Background(), map[string]string{"packages": "curl"})
+	if code == 0 || !strings.Contains(stderr, "no supported package manager") {
+		t.Errorf("PackageInstall = %d, %q; want failure without package manager", code, stderr)
+	}
+	if len(*cmds) != 0 {
+		t.Errorf("commands = %v, want none", *cmds)
+	}
+}
+
+func TestLibrary_FetchFile(t *testing.T) {
+	content := []byte("#!/bin/sh\necho hi\n")
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(content)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	lib, _ := newTestLibrary(t, Config{FetchFileDirs: []string{dir}})
+	lib.httpClient = srv.Client()
+
+	dest := filepath.Join(dir, "bin", "tool")
+	_, stderr, code, err := lib.FetchFile(context.Background(), map[string]string{
+		"url":    srv.URL + "/tool",
+		"path":   dest,
+		"sha256": digest,
+		"mode":   "0750",
+	})
+	if err != nil || code != 0 {
+		t.Fatalf("FetchFile = %d, %v, %q; want success", code, err, stderr)
+	}
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("read fetched file: %v", err)
+	}
+	if string(got) != string(content) {
+		t.Errorf("content = %q, want %q", got, content)
+	}
+	if info, _ := os.Stat(dest); info.Mode().Perm() != 0o750 {
+		t.Errorf("mode = %o, want 750", info.Mode().Perm())
+	}
+
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		params map[string]string
+		want   string
+	}{
+		{"http url", map[string]string{"url": "http://example.com/f", "path": dest, "sha256": digest}, "invalid url"},
+		{"bad digest", map[string]string{"url": srv.URL + "/f", "path": dest, "sha256": "abc"}, "invalid sha256"},
+		{"digest mismatch", map[string]string{"url": srv.URL + "/f", "path": dest, "sha256": strings.Repeat("0", 64)}, "sha256 mismatch"},
+		{"http error", map[string]string{"url": srv.URL + "/missing", "path": dest, "sha256": digest}, "HTTP 404"},
+		{"outside dirs", map[string]string{"url": srv.URL + "/f", "path": filepath.Join(outside, "f"), "sha256": digest}, "path not allowed"},
+		{"symlink escape", map[string]string{"url": srv.URL + "/f", "path": filepath.Join(dir, "escape", "f"), "sha256": digest}, "path not allowed"},
+		{"traversal", map[string]string{"url": srv.URL + "/f", "path": dir + "/../f", "sha256": digest}, "path not allowed"},
+		{"relative path", map[string]string{"url": srv.URL + "/f", "path": "f", "sha256": digest}, "must be absolute"},
+		{"bad mode", map[string]string{"url": srv.URL + "/f", "path": dest, "sha256": digest, "mode": "4755"}, "invalid mode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, stderr, code, _ := lib.FetchFile(context.Background(), tt.params)
+			if code == 0 {
+				t.Fatal("FetchFile succeeded, want failure")
+			}
+			if !strings.Contains(stderr, tt.want) {
+				t.Errorf("stderr = %q, want to contain %q", stderr, tt.want)
+			}
+		})
+	}
+	if _, err := os.Stat(filepath.Join(outside, "f")); err == nil {
+		t.Error("file written outside the allowed directories")
+	}
+}
+
+func TestLibrary_FetchFile_TooLarge(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(make([]byte, 2048))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	lib, _ := newTestLibrary(t, Config{FetchFileDirs: []string{dir}, MaxFetchBytes: 1024})
+	lib.httpClient = srv.Client()
+
+	_, stderr, code, _ := lib.FetchFile(context.Background(), map[string]string{
+		"url":    srv.URL + "/big",
+		"path":   filepath.Join(dir, "big"),
+		"sha256": strings.Repeat("0", 64),
+	})
+	if code == 0 || !strings.Contains(stderr, "exceeds 1024 bytes") {
+		t.Errorf("FetchFile = %d, %q; want size limit failure", code, stderr)
+	}
+}
+
+func TestLibrary_SysctlSet(t *testing.T) {
+	lib, _ := newTestLibrary(t, Config{AllowedSysctls: []string{"net.ipv4.ip_forward", "net.core.*"}})
+	lib.procSys = t.TempDir()
+	param := filepath.Join(lib.procSys, "net", "ipv4", "ip_forward")
+	if err := os.MkdirAll(filepath.Dir(param), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(param, []byte("0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, code, err := lib.SysctlSet(context.Background(), map[string]string{"key": "net.ipv4.ip_forward", "value": "1"})
+	if err != nil || code != 0 {
+		t.Fatalf("SysctlSet = %d, %v; want success", code, err)
+	}
+	if stdout != "net.ipv4.ip_forward = 1" {
+		t.Errorf("stdout = %q", stdout)
+	}
+	if got, _ := os.ReadFile(param); string(got) != "1\n" {
+		t.Errorf("value = %q, want %q", got, "1\n")
+	}
+
+	for _, p := range []map[string]string{
+		{"key": "kernel.panic", "value": "1"},
+		{"key": "net/ipv4/ip_forward", "value": "1"},
+		{"key": "net.ipv4.ip_forward", "value": "1\n0"},
+		{"key": "net.core.rmem_max", "value": "1"}, // allowed, but no such file
+	} {
+		if _, _, code, _ := lib.SysctlSet(context.Background(), p); code == 0 {
+			t.Errorf("SysctlSet(%v) succeeded, want failure", p)
+		}
+	}
+}
+
+func TestLibrary_RebootScheduled(t *testing.T) {
+	lib, cmds := newTestLibrary(t, Config{AllowReboot: true})
+
+	tests := []struct {
+		params map[string]string
+		want   string
+	}{
+		{nil, "-r +1"},
+		{map[string]string{"delay": "0s"}, "-r +0"},
+		{map[string]string{"delay": "90s", "message": "kernel update"}, "-r +2 kernel update"},
+	}
+	for _, tt := range tests {
+		*cmds = nil
+		if _, _, code, err := lib.RebootScheduled(context.Background(), tt.params); err != nil || code != 0 {
+			t.Fatalf("RebootScheduled(%v) = %d, %v", tt.params, code, err)
+		}
+		got := (*cmds)[0]
+		if got.name != "shutdown" || strings.Join(got.args, " ") != tt.want {
+			t.Errorf("RebootScheduled(%v) ran %s %v, want shutdown %s", tt.params, got.name, got.args, tt.want)
+		}
+	}
+
+	*cmds = nil
+	for _, p := range []map[string]string{
+		{"delay": "25h"},
+		{"delay": "-1m"},
+		{"delay": "soon"},
+		{"message": "-c"},
+	} {
+		if _, _, code, _ := lib.RebootScheduled(context.Background(), p); code == 0 {
+			t.Errorf("RebootScheduled(%v) succeeded, want failure", p)
+		}
+	}
+	if len(*cmds) != 0 {
+		t.Errorf("rejected reboots ran commands: %v", *cmds)
+	}
+}
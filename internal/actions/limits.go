@@ -0,0 +1,66 @@
+package actions
+
+import (
+	"errors"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// Execution statuses reported when a hook was stopped by its resource limits.
+const (
+	// StatusOOMKilled is reported when the kernel OOM killer killed a process
+	// of the hook because it exceeded its memory limit.
+	StatusOOMKilled = "oom_killed"
+	// StatusLimitExceeded is reported when a hook failed after hitting its
+	// process limit.
+	StatusLimitExceeded = "limit_exceeded"
+)
+
+var (
+	errOOMKilled     = errors.New("hook killed: memory limit exceeded")
+	errLimitExceeded = errors.New("hook failed: process limit exceeded")
+)
+
+// effectiveLimits merges the configured limits with a hook's own limits.
+// A hook limit applies where the configured one is unlimited and otherwise
+// only if it is tighter.
+func effectiveLimits(cfg api.ResourceLimits, hook *api.ResourceLimits) api.ResourceLimits {
+	if hook == nil {
+		return cfg
+	}
+	return api.ResourceLimits{
+		CPUPercent: int(tighter(int64(cfg.CPUPercent), int64(hook.CPUPercent))),
+		MemoryMax:  tighter(cfg.MemoryMax, hook.MemoryMax),
+		PidsMax:    tighter(cfg.PidsMax, hook.PidsMax),
+		IOWeight:   int(min(tighter(int64(cfg.IOWeight), int64(hook.IOWeight)), 10000)),
+	}
+}
+
+// tighter returns the smaller positive limit of cfg and hook, where zero or
+// negative is unlimited.
+func tighter(cfg, hook int64) int64 {
+	if hook <= 0 {
+		return cfg
+	}
+	if cfg <= 0 || hook < cfg {
+		return hook
+	}
+	return cfg
+}
+
+// hasLimits reports whether any limit is set.
+func hasLimits(l api.ResourceLimits) bool {
+	return l.CPUPercent > 0 || l.MemoryMax > 0 || l.PidsMax > 0 || l.IOWeight > 0
+}
+
+// hookLimits returns the effective limits for the hook name.
+func (e *Executor) hookLimits(name string) api.ResourceLimits {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, h := range e.hooks {
+		if h.Name == name {
+			return effectiveLimits(e.cfg.HookLimits, h.Limits)
+		}
+	}
+	return e.cfg.HookLimits
+}
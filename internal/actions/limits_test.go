@@ -0,0 +1,80 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+func TestEffectiveLimits(t *testing.T) {
+	cfg := api.ResourceLimits{CPUPercent: 100, MemoryMax: 256 << 20}
+
+	if got := effectiveLimits(cfg, nil); got != cfg {
+		t.Errorf("effectiveLimits(nil hook) = %+v, want %+v", got, cfg)
+	}
+
+	got := effectiveLimits(cfg, &api.ResourceLimits{
+		CPUPercent: 400,      // looser than config: ignored
+		MemoryMax:  64 << 20, // tighter: applies
+		PidsMax:    16,       // unlimited in config: applies
+		IOWeight:   20000,    // clamped to the cgroup maximum
+	})
+	want := api.ResourceLimits{CPUPercent: 100, MemoryMax: 64 << 20, PidsMax: 16, IOWeight: 10000}
+	if got != want {
+		t.Errorf("effectiveLimits = %+v, want %+v", got, want)
+	}
+
+	if got := effectiveLimits(cfg, &api.ResourceLimits{MemoryMax: -1}); got != cfg {
+		t.Errorf("negative hook limit: got %+v, want %+v", got, cfg)
+	}
+}
+
+func TestExecutor_HookLimits(t *testing.T) {
+	cfg := Config{HookLimits: api.ResourceLimits{MemoryMax: 128 << 20}}
+	exec := newTestExecutor(cfg, &mockReporter{}, &mockVerifier{ok: true})
+	exec.SetHooks([]api.HookInfo{
+		{Name: "limited", Limits: &api.ResourceLimits{PidsMax: 8}},
+		{Name: "plain"},
+	})
+
+	if got := exec.hookLimits("limited"); got.MemoryMax != 128<<20 || got.PidsMax != 8 {
+		t.Errorf("hookLimits(limited) = %+v", got)
+	}
+	if got := exec.hookLimits("plain"); got != cfg.HookLimits {
+		t.Errorf("hookLimits(plain) = %+v, want %+v", got, cfg.HookLimits)
+	}
+	if hasLimits(newTestExecutor(Config{}, &mockReporter{}, &mockVerifier{ok: true}).hookLimits("plain")) {
+		t.Error("default config should not limit hooks")
+	}
+}
+
+func TestDetermineStatus_Limits(t *testing.T) {
+	exitErr := exec.Command("sh", "-c", "exit 137").Run()
+	if exitErr == nil {
+		t.Fatal("expected exit error")
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{errors.Join(errOOMKilled, exitErr), StatusOOMKilled},
+		{errors.Join(errLimitExceeded, exitErr), StatusLimitExceeded},
+		{exitErr, "failed"},
+	}
+	for _, tt := range tests {
+		if got := determineStatus(tt.err, 137, ctx, ctx); got != tt.want {
+			t.Errorf("determineStatus(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestCgroupName(t *testing.T) {
+	if got := cgroupName("exec-1/../x y"); got != "exec-exec-1____x_y" {
+		t.Errorf("cgroupName = %q", got)
+	}
+}
@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"fmt"
+)
+
+// Actions taken when the control plane keeps rejecting the node identity.
+const (
+	// AuthRecoveryHalt stops the agent with a clear error instead of
+	// sending heartbeats that keep failing.
+	AuthRecoveryHalt = "halt"
+
+	// AuthRecoveryReregister registers the node anew with the standing
+	// token of the registration config.
+	AuthRecoveryReregister = "reregister"
+)
+
+// DefaultAuthRecoveryThreshold is the default number of consecutive
+// heartbeats rejected with 401 Unauthorized before the agent recovers.
+const DefaultAuthRecoveryThreshold = 3
+
+// AuthRecoveryConfig configures what the agent does when the control plane
+// no longer accepts its identity, for example because the node was deleted
+// server-side.
+type AuthRecoveryConfig struct {
+	// Threshold is the number of consecutive heartbeats rejected with 401
+	// Unauthorized after which Action is taken.
+	// Default: 3
+	Threshold int
+
+	// Action is "halt" or "reregister". "reregister" requires
+	// registration.StandingTokenFile.
+	// Default: halt
+	Action string
+}
+
+// ApplyDefaults sets default values for zero-valued fields.
+func (c *AuthRecoveryConfig) ApplyDefaults() {
+	if c.Threshold == 0 {
+		c.Threshold = DefaultAuthRecoveryThreshold
+	}
+	if c.Action == "" {
+		c.Action = AuthRecoveryHalt
+	}
+}
+
+// Validate checks that the values are acceptable.
+func (c *AuthRecoveryConfig) Validate() error {
+	if c.Threshold < 1 {
+		return fmt.Errorf("agent: auth recovery config: Threshold must be at least 1, got %d", c.Threshold)
+	}
+	if c.Action != AuthRecoveryHalt && c.Action != AuthRecoveryReregister {
+		return fmt.Errorf("agent: auth recovery config: invalid Action %q (must be %q or %q)", c.Action, AuthRecoveryHalt, AuthRecoveryReregister)
+	}
+	return nil
+}
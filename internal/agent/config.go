@@ -1,8 +1,16 @@
 package agent
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"maps"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 
@@ -10,17 +18,31 @@ import (
 	"github.com/plexsphere/plexd/internal/api"
 	"github.com/plexsphere/plexd/internal/auditfwd"
 	"github.com/plexsphere/plexd/internal/bridge"
+	"github.com/plexsphere/plexd/internal/capabilities"
+	"github.com/plexsphere/plexd/internal/cni"
+	"github.com/plexsphere/plexd/internal/diagnostics"
+	"github.com/plexsphere/plexd/internal/featuregate"
 	"github.com/plexsphere/plexd/internal/integrity"
 	"github.com/plexsphere/plexd/internal/logfwd"
+	"github.com/plexsphere/plexd/internal/logging"
+	"github.com/plexsphere/plexd/internal/meshdiag"
 	"github.com/plexsphere/plexd/internal/metrics"
 	"github.com/plexsphere/plexd/internal/nat"
+	"github.com/plexsphere/plexd/internal/netmon"
 	"github.com/plexsphere/plexd/internal/nodeapi"
+	"github.com/plexsphere/plexd/internal/overrides"
+	"github.com/plexsphere/plexd/internal/pathsel"
 	"github.com/plexsphere/plexd/internal/peerexchange"
+	"github.com/plexsphere/plexd/internal/peerhealth"
+	"github.com/plexsphere/plexd/internal/pmtu"
 	"github.com/plexsphere/plexd/internal/policy"
+	"github.com/plexsphere/plexd/internal/privhelper"
 	"github.com/plexsphere/plexd/internal/reconcile"
 	"github.com/plexsphere/plexd/internal/registration"
+	"github.com/plexsphere/plexd/internal/secretsync"
 	"github.com/plexsphere/plexd/internal/tunnel"
 	"github.com/plexsphere/plexd/internal/wireguard"
+	"github.com/plexsphere/plexd/internal/wsrelay"
 )
 
 const (
@@ -30,14 +52,23 @@ const (
 	// DefaultLogLevel is the default log level.
 	DefaultLogLevel = "info"
 
-	// DefaultDataDir is the default data directory.
-	DefaultDataDir = "/var/lib/plexd"
+	// MaxLogCapture is the largest accepted log_capture.
+	MaxLogCapture = 100000
+
+	// DefaultEphemeralTTL is the default time after which the control plane
+	// deregisters an ephemeral node that stopped sending heartbeats.
+	DefaultEphemeralTTL = 5 * time.Minute
 )
 
 // AgentConfig is the top-level configuration for the plexd agent.
 // It aggregates all subsystem configurations and is populated from
 // a YAML configuration file via ParseConfig.
 type AgentConfig struct {
+	// ConfigVersion is the layout version of the config file. Files with an
+	// older or no version are migrated on load (see MigrateConfig).
+	// Default: CurrentConfigVersion
+	ConfigVersion int `yaml:"config_version"`
+
 	// Mode is the operating mode: "node" or "bridge".
 	// Default: "node"
 	Mode string `yaml:"mode"`
@@ -46,10 +77,41 @@ type AgentConfig struct {
 	// Default: "info"
 	LogLevel string `yaml:"log_level"`
 
+	// LogLevels sets the log level of single components, e.g.
+	// {"bridge": "debug"}; other components log at LogLevel. Components are
+	// the "component" attribute of log records, such as "api", "reconcile",
+	// "bridge", "nodeapi", or "actions".
+	LogLevels map[string]string `yaml:"log_levels"`
+
+	// LogCapture is the number of recent log lines kept in memory for
+	// "plexd debug logs". 0 disables the capture.
+	// Default: 0
+	LogCapture int `yaml:"log_capture"`
+
 	// DataDir is the directory for persistent agent data.
-	// Default: /var/lib/plexd
+	// Default: /var/lib/plexd (macOS: /usr/local/var/lib/plexd, Windows: C:\ProgramData\plexd\data)
 	DataDir string `yaml:"data_dir"`
 
+	// Ephemeral marks a short-lived node, such as an autoscaled instance or
+	// a CI runner. It registers as ephemeral, keeps its identity in memory
+	// only, and deregisters and cleans up when the agent shuts down.
+	// Default: false
+	Ephemeral bool `yaml:"ephemeral"`
+
+	// EphemeralTTL is advertised to the control plane when an ephemeral node
+	// registers: if the node sends no heartbeat for this long, for example
+	// because the instance was terminated, the control plane deregisters it.
+	// Must be at least twice heartbeat.interval.
+	// Default: 5m
+	EphemeralTTL time.Duration `yaml:"ephemeral_ttl"`
+
+	// Container runs plexd as a container's main process: the config file
+	// is optional, the bootstrap token and identity are read from secrets
+	// mounted at /run/secrets/plexd, an unauthenticated probe listener
+	// serves /healthz and /readyz on :9101, and no init system is used.
+	// Default: false
+	Container bool `yaml:"container"`
+
 	API          api.Config          `yaml:"api"`
 	Registration registration.Config `yaml:"registration"`
 	Reconcile    reconcile.Config    `yaml:"reconcile"`
@@ -64,12 +126,48 @@ type AgentConfig struct {
 	Tunnel       tunnel.Config       `yaml:"tunnel"`
 	NAT          nat.Config          `yaml:"nat"`
 	PeerExchange peerexchange.Config `yaml:"peer_exchange"`
+	NetMon       netmon.Config       `yaml:"net_mon"`
+	MeshDiag     meshdiag.Config     `yaml:"mesh_diag"`
+	PeerHealth   peerhealth.Config   `yaml:"peer_health"`
+	SecretSync   secretsync.Config   `yaml:"secret_sync"`
+	CNI          cni.Config          `yaml:"cni"`
+	Overrides    overrides.Config    `yaml:"overrides"`
+	PathSelect   pathsel.Config      `yaml:"path_select"`
+	WSRelay      wsrelay.Config      `yaml:"ws_relay"`
+	PMTU         pmtu.Config         `yaml:"pmtu"`
 	Bridge       bridge.Config       `yaml:"bridge"`
+	PrivHelper   privhelper.Config   `yaml:"priv_helper"`
 	Heartbeat    HeartbeatConfig     `yaml:"heartbeat"`
+	AuthRecovery AuthRecoveryConfig  `yaml:"auth_recovery"`
+	Startup      StartupConfig       `yaml:"startup"`
+	Supervisor   SupervisorConfig    `yaml:"supervisor"`
+	Debug        DebugConfig         `yaml:"debug"`
+	Crash        diagnostics.Config  `yaml:"crash"`
+	Features     featuregate.Config  `yaml:"features"`
+	Capabilities capabilities.Config `yaml:"capabilities"`
+
+	// Profiles lists additional meshes the node joins alongside the
+	// top-level one. Each profile's data lives in data_dir/profiles/{name}.
+	Profiles []ProfileConfig `yaml:"profiles"`
+
+	// fileVersion is the config_version found in the file before migration.
+	fileVersion int
+}
+
+// DebugConfig configures the profiling and debug endpoints of the node API.
+type DebugConfig struct {
+	// Enabled serves net/http/pprof, goroutine dumps, and a sanitized state
+	// snapshot on the node API Unix socket to clients with the
+	// debug:control scope. They are never served over TCP.
+	// Default: false
+	Enabled bool `yaml:"enabled"`
 }
 
 // ApplyDefaults sets default values for zero-valued fields.
 func (c *AgentConfig) ApplyDefaults() {
+	if c.ConfigVersion == 0 {
+		c.ConfigVersion = CurrentConfigVersion
+	}
 	if c.Mode == "" {
 		c.Mode = DefaultMode
 	}
@@ -79,6 +177,12 @@ func (c *AgentConfig) ApplyDefaults() {
 	if c.DataDir == "" {
 		c.DataDir = DefaultDataDir
 	}
+	if c.EphemeralTTL == 0 {
+		c.EphemeralTTL = DefaultEphemeralTTL
+	}
+	if c.Container {
+		c.applyContainerDefaults()
+	}
 	c.API.ApplyDefaults()
 	c.Registration.ApplyDefaults()
 	c.Reconcile.ApplyDefaults()
@@ -93,80 +197,346 @@ func (c *AgentConfig) ApplyDefaults() {
 	c.Tunnel.ApplyDefaults()
 	c.NAT.ApplyDefaults()
 	c.PeerExchange.ApplyDefaults()
+	c.NetMon.ApplyDefaults()
+	c.MeshDiag.ApplyDefaults()
+	c.PeerHealth.ApplyDefaults()
+	c.SecretSync.ApplyDefaults()
+	c.CNI.ApplyDefaults()
+	c.Overrides.ApplyDefaults()
+	c.PathSelect.ApplyDefaults()
+	c.WSRelay.ApplyDefaults()
+	c.PMTU.ApplyDefaults()
 	c.Bridge.ApplyDefaults()
+	c.PrivHelper.ApplyDefaults()
 	c.Heartbeat.ApplyDefaults()
+	c.AuthRecovery.ApplyDefaults()
+	c.Startup.ApplyDefaults()
+	c.Supervisor.ApplyDefaults()
+	c.Crash.ApplyDefaults()
+	c.Capabilities.ApplyDefaults()
+	for i := range c.Profiles {
+		c.Profiles[i].applyDefaults(c.DataDir, i)
+	}
 }
 
 // Validate checks that required fields are set and values are acceptable.
+// It returns the first error found.
 func (c *AgentConfig) Validate() error {
-	if c.Mode != "node" && c.Mode != "bridge" {
-		return fmt.Errorf("agent: config: invalid mode %q (must be \"node\" or \"bridge\")", c.Mode)
-	}
-	if err := c.API.Validate(); err != nil {
-		return err
+	for _, validate := range c.validators() {
+		if err := validate(); err != nil {
+			return err
+		}
 	}
-	if err := c.Registration.Validate(); err != nil {
-		return err
-	}
-	if err := c.Reconcile.Validate(); err != nil {
-		return err
+	return nil
+}
+
+// ValidateAll checks the same rules as Validate but reports every error,
+// joined with errors.Join, instead of stopping at the first.
+func (c *AgentConfig) ValidateAll() error {
+	var errs []error
+	for _, validate := range c.validators() {
+		if err := validate(); err != nil {
+			errs = append(errs, err)
+		}
 	}
-	if err := c.NodeAPI.Validate(); err != nil {
-		return err
+	return errors.Join(errs...)
+}
+
+// validators returns the checks run by Validate and ValidateAll, in order.
+func (c *AgentConfig) validators() []func() error {
+	return []func() error{
+		c.validateVersion,
+		c.validateMode,
+		c.validateLogging,
+		c.validateEphemeral,
+		c.API.Validate,
+		c.Registration.Validate,
+		c.Reconcile.Validate,
+		c.NodeAPI.Validate,
+		c.Actions.Validate,
+		c.Policy.Validate,
+		c.WireGuard.Validate,
+		c.Metrics.Validate,
+		c.LogFwd.Validate,
+		c.AuditFwd.Validate,
+		c.Integrity.Validate,
+		c.Tunnel.Validate,
+		c.NAT.Validate,
+		c.PeerExchange.Validate,
+		c.NetMon.Validate,
+		c.MeshDiag.Validate,
+		c.PeerHealth.Validate,
+		c.SecretSync.Validate,
+		c.CNI.Validate,
+		c.Overrides.Validate,
+		c.PathSelect.Validate,
+		c.WSRelay.Validate,
+		c.PMTU.Validate,
+		c.Bridge.Validate,
+		c.PrivHelper.Validate,
+		c.Heartbeat.Validate,
+		c.validateAuthRecovery,
+		c.Startup.Validate,
+		c.Supervisor.Validate,
+		c.Crash.Validate,
+		c.Features.Validate,
+		c.Capabilities.Validate,
+		c.validateProfiles,
 	}
-	if err := c.Actions.Validate(); err != nil {
-		return err
+}
+
+func (c *AgentConfig) validateVersion() error {
+	if c.ConfigVersion != CurrentConfigVersion {
+		return fmt.Errorf("agent: config: unsupported config_version %d (want %d)", c.ConfigVersion, CurrentConfigVersion)
 	}
-	if err := c.Policy.Validate(); err != nil {
-		return err
+	return nil
+}
+
+// FileVersion returns the config_version of the file the configuration was
+// loaded from, before migration: 0 for an unversioned file. A result below
+// CurrentConfigVersion means the file should be rewritten with
+// "plexd config migrate".
+func (c *AgentConfig) FileVersion() int {
+	return c.fileVersion
+}
+
+func (c *AgentConfig) validateMode() error {
+	if c.Mode != "node" && c.Mode != "bridge" {
+		return fmt.Errorf("agent: config: invalid mode %q (must be \"node\" or \"bridge\")", c.Mode)
 	}
-	if err := c.WireGuard.Validate(); err != nil {
-		return err
+	return nil
+}
+
+func (c *AgentConfig) validateLogging() error {
+	for _, component := range slices.Sorted(maps.Keys(c.LogLevels)) {
+		if component == "" {
+			return errors.New("agent: config: log_levels: empty component")
+		}
+		if _, err := logging.ParseLevel(c.LogLevels[component]); err != nil {
+			return fmt.Errorf("agent: config: log_levels: %s: %w", component, err)
+		}
 	}
-	if err := c.Metrics.Validate(); err != nil {
-		return err
+	if c.LogCapture < 0 || c.LogCapture > MaxLogCapture {
+		return fmt.Errorf("agent: config: log_capture %d out of range [0, %d]", c.LogCapture, MaxLogCapture)
 	}
-	if err := c.LogFwd.Validate(); err != nil {
-		return err
+	return nil
+}
+
+func (c *AgentConfig) validateEphemeral() error {
+	if !c.Ephemeral {
+		return nil
 	}
-	if err := c.AuditFwd.Validate(); err != nil {
-		return err
+	interval := c.Heartbeat.Interval
+	if interval == 0 {
+		interval = DefaultHeartbeatInterval
 	}
-	if err := c.Integrity.Validate(); err != nil {
-		return err
+	if c.EphemeralTTL < 2*interval {
+		return fmt.Errorf("agent: config: ephemeral_ttl %s must be at least twice heartbeat.interval (%s)", c.EphemeralTTL, interval)
 	}
-	if err := c.Tunnel.Validate(); err != nil {
-		return err
+	if len(c.Profiles) > 0 {
+		return errors.New("agent: config: ephemeral nodes do not support profiles")
 	}
-	if err := c.NAT.Validate(); err != nil {
+	return nil
+}
+
+func (c *AgentConfig) validateAuthRecovery() error {
+	if err := c.AuthRecovery.Validate(); err != nil {
 		return err
 	}
-	if err := c.PeerExchange.Validate(); err != nil {
-		return err
+	if c.AuthRecovery.Action != AuthRecoveryReregister {
+		return nil
 	}
-	if err := c.Bridge.Validate(); err != nil {
-		return err
+	if c.Ephemeral {
+		return errors.New("agent: config: auth_recovery action \"reregister\" is not supported for ephemeral nodes")
 	}
-	if err := c.Heartbeat.Validate(); err != nil {
-		return err
+	if c.Registration.StandingTokenFile == "" {
+		return errors.New("agent: config: auth_recovery action \"reregister\" requires registration.standingtokenfile")
 	}
 	return nil
 }
 
 // ParseConfig reads a YAML configuration file and returns an AgentConfig.
-// It applies defaults and validates the configuration.
-func ParseConfig(path string) (*AgentConfig, error) {
+// It applies the overrides and defaults and validates the configuration.
+func ParseConfig(path string, ov ConfigOverrides) (*AgentConfig, error) {
+	data, err := ReadConfigFile(path, ov)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfigData(path, data, ov)
+}
+
+// ReadConfigFile returns the contents of the configuration file at path. In
+// container mode a missing file is not an error and returns nil; an existing
+// file never returns nil, even when empty.
+func ReadConfigFile(path string, ov ConfigOverrides) ([]byte, error) {
 	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && ov.container() {
+		// In container mode the config file is optional; the agent can
+		// be configured through PLEXD_* variables alone.
+		return nil, nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("agent: config: read %s: %w", path, err)
 	}
-	var cfg AgentConfig
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("agent: config: parse %s: %w", path, err)
+	if data == nil {
+		data = []byte{}
+	}
+	return data, nil
+}
+
+// ParseConfigData is ParseConfig for data, the contents of the configuration
+// file at path as returned by ReadConfigFile. It lets a caller check the
+// bytes it parses, e.g. against a signature, without reading the file twice.
+func ParseConfigData(path string, data []byte, ov ConfigOverrides) (*AgentConfig, error) {
+	cfg, err := decodeConfig(path, data, false, ov)
+	if err != nil {
+		return nil, err
 	}
-	cfg.ApplyDefaults()
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
-	return &cfg, nil
+	return cfg, nil
+}
+
+// LoadConfig reads a YAML configuration file and applies the overrides and
+// defaults without validating the result. Unknown keys in the file are
+// ignored.
+func LoadConfig(path string, ov ConfigOverrides) (*AgentConfig, error) {
+	cfg, err := loadConfig(path, false, ov)
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// CheckConfig reads and validates a YAML configuration file like
+// ParseConfig, but is stricter and more verbose: unknown keys (typically
+// misspellings) are rejected with their line numbers, and every validation
+// error is reported instead of only the first. The returned error joins one
+// error per problem.
+func CheckConfig(path string, ov ConfigOverrides) (*AgentConfig, error) {
+	cfg, err := loadConfig(path, true, ov)
+	if cfg == nil {
+		return nil, err
+	}
+	var errs []error
+	if err != nil {
+		errs = append(errs, err.(interface{ Unwrap() []error }).Unwrap()...)
+	}
+	if err := cfg.ValidateAll(); err != nil {
+		errs = append(errs, err.(interface{ Unwrap() []error }).Unwrap()...)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// loadConfig decodes the file at path, migrates it to CurrentConfigVersion,
+// applies ov, then defaults. If the file cannot be read, is not well-formed
+// YAML, or cannot be migrated it returns a nil config. Unknown or mistyped
+// keys and invalid overrides do not stop loading: the config is returned
+// together with one joined error per problem.
+func loadConfig(path string, strict bool, ov ConfigOverrides) (*AgentConfig, error) {
+	data, err := ReadConfigFile(path, ov)
+	if err != nil {
+		return nil, err
+	}
+	return decodeConfig(path, data, strict, ov)
+}
+
+// decodeConfig is loadConfig for data, the contents of the file at path.
+func decodeConfig(path string, data []byte, strict bool, ov ConfigOverrides) (*AgentConfig, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("agent: config: parse %s: %w", path, err)
+	}
+	res, err := migrateDocument(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("agent: config: %s: %w", path, err)
+	}
+	if len(res.Changes) > 0 {
+		// Decode the migrated document. Line numbers in decode errors
+		// refer to it rather than to the file.
+		if data, err = encodeDocument(&doc); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := AgentConfig{fileVersion: res.From}
+	var errs []error
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(strict)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("agent: config: parse %s: %w", path, err)
+		}
+		// The decoder reports every unknown or mistyped key and still
+		// fills in the rest.
+		for _, msg := range typeErr.Errors {
+			errs = append(errs, fmt.Errorf("agent: config: parse %s: %s", path, msg))
+		}
+	}
+	cfg.ConfigVersion = res.To
+	if err := ov.apply(&cfg); err != nil {
+		errs = append(errs, err.(interface{ Unwrap() []error }).Unwrap()...)
+	}
+	cfg.ApplyDefaults()
+	return &cfg, errors.Join(errs...)
+}
+
+// redactedValue replaces secret values in MarshalConfig output.
+const redactedValue = "REDACTED"
+
+// secretConfigKeys lists the config keys, in DiffConfig notation, whose
+// values are masked by MarshalConfig when redacting.
+var secretConfigKeys = []string{
+	"registration.tokenvalue",
+}
+
+// MarshalConfig encodes cfg as YAML in the config file layout. With redact
+// set, the values of secret keys are replaced with "REDACTED"; empty values
+// are left empty so that it remains visible whether a secret is set.
+func MarshalConfig(cfg *AgentConfig, redact bool) ([]byte, error) {
+	var doc yaml.Node
+	if err := doc.Encode(cfg); err != nil {
+		return nil, fmt.Errorf("agent: config: encode: %w", err)
+	}
+	if redact {
+		redactSecrets(&doc)
+		if profiles := lookupNode(&doc, []string{"profiles"}); profiles != nil && profiles.Kind == yaml.SequenceNode {
+			for _, p := range profiles.Content {
+				redactSecrets(p)
+			}
+		}
+	}
+	return encodeDocument(&doc)
+}
+
+// redactSecrets masks the non-empty secret values in the mapping node n.
+// Profiles share the secret keys of the top level.
+func redactSecrets(n *yaml.Node) {
+	for _, key := range secretConfigKeys {
+		if v := lookupNode(n, strings.Split(key, ".")); v != nil && v.Kind == yaml.ScalarNode && v.Value != "" {
+			v.Tag = "!!str"
+			v.Value = redactedValue
+		}
+	}
+}
+
+// lookupNode returns the value node at path in a mapping node, or nil.
+func lookupNode(n *yaml.Node, path []string) *yaml.Node {
+	if len(path) == 0 {
+		return n
+	}
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == path[0] {
+			return lookupNode(n.Content[i+1], path[1:])
+		}
+	}
+	return nil
 }
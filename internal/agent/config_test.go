@@ -3,7 +3,12 @@ package agent
 import (
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/integrity"
 )
 
 func TestAgentConfig_ApplyDefaults(t *testing.T) {
@@ -29,6 +34,26 @@ func TestAgentConfig_Validate_InvalidMode(t *testing.T) {
 	}
 }
 
+func TestAgentConfig_Validate_Logging(t *testing.T) {
+	cfg := validConfig()
+	cfg.LogLevels = map[string]string{"bridge": "debug", "api": "WARN"}
+	cfg.LogCapture = 500
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	cfg.LogLevels["reconcile"] = "verbose"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "log_levels: reconcile") {
+		t.Errorf("invalid component level: err = %v", err)
+	}
+
+	cfg = validConfig()
+	cfg.LogCapture = -1
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "log_capture") {
+		t.Errorf("negative log_capture: err = %v", err)
+	}
+}
+
 func TestParseConfig_ValidYAML(t *testing.T) {
 	yaml := `
 mode: bridge
@@ -44,7 +69,7 @@ heartbeat:
   nodeid: "node-1"
 `
 	path := writeTemp(t, yaml)
-	cfg, err := ParseConfig(path)
+	cfg, err := ParseConfig(path, ConfigOverrides{})
 	if err != nil {
 		t.Fatalf("ParseConfig: %v", err)
 	}
@@ -74,7 +99,7 @@ heartbeat:
   nodeid: "node-1"
 `
 	path := writeTemp(t, yaml)
-	_, err := ParseConfig(path)
+	_, err := ParseConfig(path, ConfigOverrides{})
 	if err == nil {
 		t.Fatal("expected error for missing api.base_url")
 	}
@@ -93,7 +118,7 @@ heartbeat:
   nodeid: "node-1"
 `
 	path := writeTemp(t, yaml)
-	cfg, err := ParseConfig(path)
+	cfg, err := ParseConfig(path, ConfigOverrides{})
 	if err != nil {
 		t.Fatalf("ParseConfig: %v", err)
 	}
@@ -108,8 +133,153 @@ heartbeat:
 	}
 }
 
+func TestParseConfig_PrivHelper(t *testing.T) {
+	yaml := `
+api:
+  baseurl: "https://example.com"
+registration:
+  datadir: /tmp/plexd
+node_api:
+  datadir: /tmp/plexd
+heartbeat:
+  nodeid: "node-1"
+priv_helper:
+  mode: helper
+  socketpath: /run/plexd/custom-helper.sock
+`
+	path := writeTemp(t, yaml)
+	cfg, err := ParseConfig(path, ConfigOverrides{})
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if cfg.PrivHelper.Mode != "helper" {
+		t.Errorf("PrivHelper.Mode = %q, want %q", cfg.PrivHelper.Mode, "helper")
+	}
+	if cfg.PrivHelper.SocketPath != "/run/plexd/custom-helper.sock" {
+		t.Errorf("PrivHelper.SocketPath = %q, want %q", cfg.PrivHelper.SocketPath, "/run/plexd/custom-helper.sock")
+	}
+	if cfg.PrivHelper.InterfacePrefix != "plexd" {
+		t.Errorf("PrivHelper.InterfacePrefix = %q, want default %q", cfg.PrivHelper.InterfacePrefix, "plexd")
+	}
+
+	bad := writeTemp(t, strings.Replace(yaml, "mode: helper", "mode: sudo", 1))
+	if _, err := ParseConfig(bad, ConfigOverrides{}); err == nil {
+		t.Error("ParseConfig with invalid priv_helper.mode = nil error, want error")
+	}
+}
+
+func TestParseConfig_Startup(t *testing.T) {
+	yaml := `
+api:
+  baseurl: "https://example.com"
+registration:
+  datadir: /tmp/plexd
+node_api:
+  datadir: /tmp/plexd
+heartbeat:
+  nodeid: "node-1"
+`
+	path := writeTemp(t, yaml)
+	cfg, err := ParseConfig(path, ConfigOverrides{})
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if !slices.Equal(cfg.Startup.Required, DefaultRequiredSubsystems) || cfg.Startup.ReadyTimeout != DefaultStartupReadyTimeout {
+		t.Errorf("Startup = %+v, want defaults", cfg.Startup)
+	}
+
+	path = writeTemp(t, yaml+"startup:\n  required: []\n  readytimeout: 10s\n")
+	cfg, err = ParseConfig(path, ConfigOverrides{})
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if len(cfg.Startup.Required) != 0 || cfg.Startup.ReadyTimeout != 10*time.Second {
+		t.Errorf("Startup = %+v, want no required subsystems and a 10s timeout", cfg.Startup)
+	}
+
+	bad := writeTemp(t, yaml+"startup:\n  required: [nodeapi, nodeapi]\n")
+	if _, err := ParseConfig(bad, ConfigOverrides{}); err == nil || !strings.Contains(err.Error(), "required more than once") {
+		t.Errorf("ParseConfig with a duplicate required subsystem = %v, want error", err)
+	}
+}
+
+func TestParseConfig_Ephemeral(t *testing.T) {
+	yaml := `
+api:
+  baseurl: "https://example.com"
+registration:
+  datadir: /tmp/plexd
+node_api:
+  datadir: /tmp/plexd
+heartbeat:
+  nodeid: "node-1"
+  interval: 1m
+ephemeral: true
+`
+	path := writeTemp(t, yaml)
+	cfg, err := ParseConfig(path, ConfigOverrides{})
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if !cfg.Ephemeral || cfg.EphemeralTTL != DefaultEphemeralTTL {
+		t.Errorf("Ephemeral, EphemeralTTL = %v, %s, want true, %s", cfg.Ephemeral, cfg.EphemeralTTL, DefaultEphemeralTTL)
+	}
+
+	short := writeTemp(t, yaml+"ephemeral_ttl: 90s\n")
+	if _, err := ParseConfig(short, ConfigOverrides{}); err == nil || !strings.Contains(err.Error(), "ephemeral_ttl") {
+		t.Errorf("ParseConfig with ephemeral_ttl below twice the heartbeat interval = %v, want error", err)
+	}
+}
+
+func TestParseConfig_AuthRecovery(t *testing.T) {
+	base := `
+api:
+  baseurl: "https://example.com"
+registration:
+  datadir: /tmp/plexd
+node_api:
+  datadir: /tmp/plexd
+heartbeat:
+  nodeid: "node-1"
+`
+	cfg, err := ParseConfig(writeTemp(t, base), ConfigOverrides{})
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if cfg.AuthRecovery.Threshold != DefaultAuthRecoveryThreshold || cfg.AuthRecovery.Action != AuthRecoveryHalt {
+		t.Errorf("AuthRecovery = %+v, want threshold %d and action halt", cfg.AuthRecovery, DefaultAuthRecoveryThreshold)
+	}
+
+	reregister := base + "auth_recovery:\n  threshold: 5\n  action: reregister\n"
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"reregister", strings.Replace(reregister, "datadir: /tmp/plexd\n", "datadir: /tmp/plexd\n  standingtokenfile: /etc/plexd/standing-token\n", 1), ""},
+		{"reregister without standing token", reregister, "requires registration.standingtokenfile"},
+		{"reregister ephemeral", reregister + "ephemeral: true\n", "not supported for ephemeral nodes"},
+		{"unknown action", base + "auth_recovery:\n  action: retry\n", `invalid Action "retry"`},
+		{"negative threshold", base + "auth_recovery:\n  threshold: -1\n", "Threshold must be at least 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConfig(writeTemp(t, tt.yaml), ConfigOverrides{})
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("ParseConfig: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseConfig() = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
 func TestParseConfig_FileNotFound(t *testing.T) {
-	_, err := ParseConfig("/nonexistent/path/config.yaml")
+	_, err := ParseConfig("/nonexistent/path/config.yaml", ConfigOverrides{})
 	if err == nil {
 		t.Fatal("expected error for non-existent file")
 	}
@@ -117,7 +287,7 @@ func TestParseConfig_FileNotFound(t *testing.T) {
 
 func TestParseConfig_InvalidYAML(t *testing.T) {
 	path := writeTemp(t, "{{invalid yaml")
-	_, err := ParseConfig(path)
+	_, err := ParseConfig(path, ConfigOverrides{})
 	if err == nil {
 		t.Fatal("expected error for invalid YAML")
 	}
@@ -144,3 +314,151 @@ func writeTemp(t *testing.T, content string) string {
 	}
 	return path
 }
+
+func TestCheckConfig_UnknownKey(t *testing.T) {
+	yaml := `
+api:
+  baseurl: "https://example.com"
+  base_url: "https://typo.example.com"
+registration:
+  datadir: /tmp/plexd
+node_api:
+  datadir: /tmp/plexd
+heartbeat:
+  nodeid: "node-1"
+`
+	path := writeTemp(t, yaml)
+
+	// ParseConfig ignores unknown keys.
+	if _, err := ParseConfig(path, ConfigOverrides{}); err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+
+	_, err := CheckConfig(path, ConfigOverrides{})
+	if err == nil {
+		t.Fatal("CheckConfig with unknown key = nil error, want error")
+	}
+	if !strings.Contains(err.Error(), "line 4") || !strings.Contains(err.Error(), "base_url") {
+		t.Errorf("error = %q, want line number and key", err)
+	}
+}
+
+func TestCheckConfig_ReportsAllErrors(t *testing.T) {
+	yaml := `
+mode: edge
+heartbeat:
+  nodeid: "node-1"
+`
+	_, err := CheckConfig(writeTemp(t, yaml), ConfigOverrides{})
+	if err == nil {
+		t.Fatal("CheckConfig = nil error, want error")
+	}
+	for _, want := range []string{"invalid mode", "api: config", "registration: config"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %q, want it to contain %q", err, want)
+		}
+	}
+}
+
+func TestCheckConfig_UnknownKeyAndInvalidValue(t *testing.T) {
+	yaml := `
+mode: edge
+log_levle: debug
+heartbeat:
+  nodeid: "node-1"
+`
+	_, err := CheckConfig(writeTemp(t, yaml), ConfigOverrides{})
+	if err == nil {
+		t.Fatal("CheckConfig = nil error, want error")
+	}
+	for _, want := range []string{"line 3", "log_levle", "invalid mode"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %q, want it to contain %q", err, want)
+		}
+	}
+}
+
+func TestCheckConfig_Valid(t *testing.T) {
+	yaml := `
+api:
+  baseurl: "https://example.com"
+registration:
+  datadir: /tmp/plexd
+node_api:
+  datadir: /tmp/plexd
+heartbeat:
+  nodeid: "node-1"
+`
+	cfg, err := CheckConfig(writeTemp(t, yaml), ConfigOverrides{})
+	if err != nil {
+		t.Fatalf("CheckConfig: %v", err)
+	}
+	if cfg.Mode != DefaultMode {
+		t.Errorf("Mode = %q, want default %q", cfg.Mode, DefaultMode)
+	}
+}
+
+func TestMarshalConfig_RoundTripAndRedact(t *testing.T) {
+	cfg := validConfig()
+	cfg.Registration.TokenValue = "plx_bootstrap_secret"
+
+	out, err := MarshalConfig(&cfg, false)
+	if err != nil {
+		t.Fatalf("MarshalConfig: %v", err)
+	}
+	if !strings.Contains(string(out), "plx_bootstrap_secret") {
+		t.Errorf("unredacted output missing token:\n%s", out)
+	}
+	if !strings.Contains(string(out), "interval: 30s") {
+		t.Errorf("output should encode durations as strings:\n%s", out)
+	}
+
+	// The output is a valid config file that loads back to the same config.
+	loaded, err := ParseConfig(writeTemp(t, string(out)), ConfigOverrides{})
+	if err != nil {
+		t.Fatalf("ParseConfig(MarshalConfig): %v", err)
+	}
+	if diff := DiffConfig(&cfg, loaded); len(diff) != 0 {
+		t.Errorf("round trip changed keys %v", diff)
+	}
+
+	redacted, err := MarshalConfig(&cfg, true)
+	if err != nil {
+		t.Fatalf("MarshalConfig(redact): %v", err)
+	}
+	if strings.Contains(string(redacted), "plx_bootstrap_secret") {
+		t.Errorf("redacted output leaks token:\n%s", redacted)
+	}
+	if !strings.Contains(string(redacted), "tokenvalue: REDACTED") {
+		t.Errorf("redacted output missing mask:\n%s", redacted)
+	}
+}
+
+func TestParseConfigData_IgnoresSignatureSettings(t *testing.T) {
+	// The signature settings must not come from the file they protect.
+	data := []byte(`
+api:
+  baseurl: "https://example.com"
+registration:
+  datadir: /tmp/plexd
+node_api:
+  datadir: /tmp/plexd
+heartbeat:
+  nodeid: "node-1"
+integrity:
+  signaturemode: enforce
+  configpath: /etc/plexd/other.yaml
+`)
+	cfg, err := ParseConfigData("config.yaml", data, ConfigOverrides{})
+	if err != nil {
+		t.Fatalf("ParseConfigData: %v", err)
+	}
+	if cfg.Integrity.SignatureMode != integrity.SignatureModeOff || cfg.Integrity.ConfigPath != "" {
+		t.Errorf("SignatureMode = %q, ConfigPath = %q; want the default and empty", cfg.Integrity.SignatureMode, cfg.Integrity.ConfigPath)
+	}
+	for _, key := range ConfigKeys() {
+		if key == "integrity.signaturemode" || key == "integrity.configpath" {
+			t.Errorf("%s is a settable config key", key)
+		}
+	}
+}
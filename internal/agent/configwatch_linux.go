@@ -0,0 +1,84 @@
+//go:build linux
+
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// configWatchMask selects the inotify events that indicate a new config file:
+// an in-place write, a replacement by rename (editors, atomic writers), and
+// a newly created file.
+const configWatchMask = unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_CREATE
+
+// WatchConfigFile calls onChange whenever the file at path is written or
+// replaced, until ctx is cancelled. It watches the containing directory with
+// inotify so that replacement by rename is detected, including the ..data
+// symlink swap of Kubernetes ConfigMap volumes. onChange runs on the watcher
+// goroutine and should only signal another loop, e.g.
+// ConfigReloader.TriggerReload.
+func WatchConfigFile(ctx context.Context, path string, onChange func()) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("agent: config watch: inotify init: %w", err)
+	}
+	// The non-blocking descriptor is handed to the runtime poller, so Close
+	// unblocks a pending Read.
+	f := os.NewFile(uintptr(fd), "inotify")
+	defer f.Close()
+
+	dir, base := filepath.Split(filepath.Clean(path))
+	if dir == "" {
+		dir = "."
+	}
+	if _, err := unix.InotifyAddWatch(fd, dir, configWatchMask); err != nil {
+		return fmt.Errorf("agent: config watch: watch %s: %w", dir, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, os.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("agent: config watch: read: %w", err)
+		}
+		if configEventMatches(buf[:n], base) {
+			onChange()
+		}
+	}
+}
+
+// configEventMatches reports whether any inotify event in buf names base or
+// the ConfigMap ..data symlink.
+func configEventMatches(buf []byte, base string) bool {
+	matched := false
+	for off := 0; off+unix.SizeofInotifyEvent <= len(buf); {
+		ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+		start := off + unix.SizeofInotifyEvent
+		end := start + int(ev.Len)
+		if end > len(buf) {
+			break
+		}
+		name := string(bytes.TrimRight(buf[start:end], "\x00"))
+		if name == base || name == "..data" {
+			matched = true
+		}
+		off = end
+	}
+	return matched
+}
@@ -0,0 +1,63 @@
+//go:build linux
+
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte("mode: node\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan struct{}, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- WatchConfigFile(ctx, path, func() { changes <- struct{}{} })
+	}()
+
+	// Give the watcher time to register.
+	time.Sleep(50 * time.Millisecond)
+
+	// Unrelated files in the directory are ignored.
+	if err := os.WriteFile(filepath.Join(dir, "environment"), []byte("X=1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+		t.Fatal("change reported for unrelated file")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Replacement by rename, as done by editors and atomic writers.
+	tmp := filepath.Join(dir, ".config.yaml.tmp")
+	if err := os.WriteFile(tmp, []byte("mode: bridge\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no change reported after rename")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("WatchConfigFile = %v, want nil", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("WatchConfigFile did not return after cancel")
+	}
+}
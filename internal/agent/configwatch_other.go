@@ -0,0 +1,15 @@
+//go:build !linux
+
+package agent
+
+import (
+	"context"
+	"errors"
+)
+
+// WatchConfigFile watches the config file for changes. File watching is only
+// supported on Linux; elsewhere it returns an error immediately and reloads
+// are triggered by SIGHUP or the node API.
+func WatchConfigFile(ctx context.Context, path string, onChange func()) error {
+	return errors.New("agent: config watch: not supported on this platform")
+}
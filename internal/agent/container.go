@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// DefaultContainerSecretDir is where container mode expects mounted
+	// secrets: the bootstrap token as bootstrap-token and, optionally, a
+	// pre-provisioned node identity.
+	DefaultContainerSecretDir = "/run/secrets/plexd"
+
+	// DefaultContainerProbeListen is the listen address of the
+	// unauthenticated liveness and readiness probe listener in container
+	// mode.
+	DefaultContainerProbeListen = ":9101"
+)
+
+// applyContainerDefaults fills in the container mode defaults. It runs
+// before the subsystem defaults so that they do not mask it.
+func (c *AgentConfig) applyContainerDefaults() {
+	if c.Registration.TokenFile == "" {
+		c.Registration.TokenFile = filepath.Join(DefaultContainerSecretDir, "bootstrap-token")
+	}
+	if c.Registration.IdentityDir == "" {
+		c.Registration.IdentityDir = DefaultContainerSecretDir
+	}
+	if c.NodeAPI.ProbeListen == "" {
+		c.NodeAPI.ProbeListen = DefaultContainerProbeListen
+	}
+}
+
+// RunningInContainer reports whether plexd runs inside a container without
+// an init system, such as a Docker container or a Kubernetes pod.
+func RunningInContainer() bool {
+	return detectContainer(os.DirFS("/"), os.Getenv)
+}
+
+// detectContainer reports whether the root filesystem fsys and environment
+// getenv belong to a container. A container that boots systemd is treated
+// as a host, since plexd can be installed as a service there.
+func detectContainer(fsys fs.FS, getenv func(string) string) bool {
+	if fsExists(fsys, "run/systemd/system") {
+		return false
+	}
+	if fsExists(fsys, ".dockerenv") || fsExists(fsys, "run/.containerenv") {
+		return true
+	}
+	return getenv("container") != "" || getenv("KUBERNETES_SERVICE_HOST") != ""
+}
+
+func fsExists(fsys fs.FS, name string) bool {
+	_, err := fs.Stat(fsys, name)
+	return err == nil
+}
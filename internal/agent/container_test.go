@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDetectContainer(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		env  map[string]string
+		want bool
+	}{
+		{"host", fstest.MapFS{}, nil, false},
+		{"docker", fstest.MapFS{".dockerenv": {}}, nil, true},
+		{"podman", fstest.MapFS{"run/.containerenv": {}}, nil, true},
+		{"container env", fstest.MapFS{}, map[string]string{"container": "oci"}, true},
+		{"kubernetes", fstest.MapFS{}, map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1"}, true},
+		{"systemd container", fstest.MapFS{".dockerenv": {}, "run/systemd/system/x": {}}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(k string) string { return tt.env[k] }
+			if got := detectContainer(tt.fsys, getenv); got != tt.want {
+				t.Errorf("detectContainer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConfig_Container(t *testing.T) {
+	ov := ConfigOverrides{
+		Env: []string{
+			"PLEXD_CONTAINER=true",
+			"PLEXD_API_BASEURL=https://example.com",
+			"PLEXD_REGISTRATION_DATADIR=/tmp/plexd",
+			"PLEXD_NODE_API_DATADIR=/tmp/plexd",
+			"PLEXD_HEARTBEAT_NODEID=node-1",
+		},
+	}
+	missing := filepath.Join(t.TempDir(), "config.yaml")
+	cfg, err := ParseConfig(missing, ov)
+	if err != nil {
+		t.Fatalf("ParseConfig without config file in container mode: %v", err)
+	}
+	if want := filepath.Join(DefaultContainerSecretDir, "bootstrap-token"); cfg.Registration.TokenFile != want {
+		t.Errorf("Registration.TokenFile = %q, want %q", cfg.Registration.TokenFile, want)
+	}
+	if cfg.Registration.IdentityDir != DefaultContainerSecretDir {
+		t.Errorf("Registration.IdentityDir = %q, want %q", cfg.Registration.IdentityDir, DefaultContainerSecretDir)
+	}
+	if cfg.NodeAPI.ProbeListen != DefaultContainerProbeListen {
+		t.Errorf("NodeAPI.ProbeListen = %q, want %q", cfg.NodeAPI.ProbeListen, DefaultContainerProbeListen)
+	}
+
+	ov.Env = ov.Env[1:]
+	if _, err := ParseConfig(missing, ov); err == nil {
+		t.Error("ParseConfig without config file outside container mode = nil, want error")
+	}
+}
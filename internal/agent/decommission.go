@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/plexsphere/plexd/internal/fsutil"
+	"github.com/plexsphere/plexd/internal/nodeapi"
+	"github.com/plexsphere/plexd/internal/registration"
+)
+
+// Deregisterer removes a node from the control plane.
+// *api.ControlPlane satisfies this interface.
+type Deregisterer interface {
+	Deregister(ctx context.Context, nodeID string) error
+}
+
+// NetworkCleaner removes every interface, route, ip rule, and nftables
+// table plexd installed on the host. Implementations must be idempotent.
+type NetworkCleaner interface {
+	Cleanup() error
+}
+
+// DecommissionOptions controls a decommission run.
+type DecommissionOptions struct {
+	// Force continues with the local teardown when deregistration fails,
+	// for example because the control plane is unreachable or has already
+	// deleted the node.
+	Force bool
+}
+
+// Decommissioner retires a node: it deregisters the node from the control
+// plane, tears down its network state, and securely wipes its identity,
+// cached state and secrets, synced secret files, and bootstrap token.
+type Decommissioner struct {
+	dataDir   string
+	tokenFile string
+	secretDir string
+	client    Deregisterer
+	network   NetworkCleaner
+	logger    *slog.Logger
+}
+
+// NewDecommissioner creates a Decommissioner for the node configured by cfg.
+// The platform network cleaner is used unless replaced with
+// SetNetworkCleaner.
+func NewDecommissioner(cfg *AgentConfig, client Deregisterer, logger *slog.Logger) *Decommissioner {
+	logger = logger.With("component", "decommission")
+	d := &Decommissioner{
+		dataDir:   cfg.DataDir,
+		tokenFile: cfg.Registration.TokenFile,
+		client:    client,
+		network:   NewNetworkCleaner(cfg, logger),
+		logger:    logger,
+	}
+	if cfg.SecretSync.Enabled {
+		d.secretDir = cfg.SecretSync.Dir
+	}
+	return d
+}
+
+// SetNetworkCleaner replaces the network cleaner. A nil cleaner skips the
+// network teardown.
+func (d *Decommissioner) SetNetworkCleaner(nc NetworkCleaner) {
+	d.network = nc
+}
+
+// Decommission deregisters nodeID and removes all local node state. A
+// deregistration failure aborts before anything is removed unless
+// opts.Force is set. Local teardown steps run to completion even when one
+// fails; their errors are joined. Decommission must not run while the
+// agent's subsystems are still active.
+func (d *Decommissioner) Decommission(ctx context.Context, nodeID string, opts DecommissionOptions) error {
+	if err := d.client.Deregister(ctx, nodeID); err != nil {
+		if !opts.Force {
+			return fmt.Errorf("agent: decommission: deregister: %w", err)
+		}
+		d.logger.Warn("deregistration failed, continuing with local teardown",
+			"node_id", nodeID,
+			"error", err,
+		)
+	} else {
+		d.logger.Info("node deregistered", "node_id", nodeID)
+	}
+
+	var errs []error
+	if d.network != nil {
+		if err := d.network.Cleanup(); err != nil {
+			errs = append(errs, fmt.Errorf("network cleanup: %w", err))
+		} else {
+			d.logger.Info("network state removed")
+		}
+	}
+	if err := registration.WipeIdentity(d.dataDir); err != nil {
+		errs = append(errs, err)
+	}
+	if err := nodeapi.WipeStateCache(d.dataDir); err != nil {
+		errs = append(errs, err)
+	}
+	if d.secretDir != "" {
+		if err := fsutil.WipeDir(d.secretDir); err != nil {
+			errs = append(errs, fmt.Errorf("wipe secret dir: %w", err))
+		}
+	}
+	if d.tokenFile != "" {
+		if err := fsutil.WipeFile(d.tokenFile); err != nil {
+			errs = append(errs, fmt.Errorf("wipe token file: %w", err))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("agent: decommission: %w", err)
+	}
+	d.logger.Info("node decommissioned", "node_id", nodeID)
+	return nil
+}
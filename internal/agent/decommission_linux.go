@@ -0,0 +1,119 @@
+//go:build linux
+
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/google/nftables"
+	"github.com/vishvananda/netlink"
+
+	"github.com/plexsphere/plexd/internal/bridge"
+)
+
+// linkCleaner removes plexd's links, policy routing table, and nftables
+// tables through netlink.
+type linkCleaner struct {
+	names    []string
+	prefixes []string
+	routes   *bridge.NetlinkRouteController
+	logger   *slog.Logger
+}
+
+// NewNetworkCleaner returns the platform NetworkCleaner for cfg. On Linux it
+// deletes the mesh interfaces of the top level and all profiles, the user
+// access interface, and the site-to-site interfaces (their routes go with
+// them), flushes the bridge routing table and its ip rules, and
+// deletes every nftables table named "plexd" or "plexd-*".
+func NewNetworkCleaner(cfg *AgentConfig, logger *slog.Logger) NetworkCleaner {
+	routes := bridge.NewNetlinkRouteController(logger)
+	routes.SetPolicyRouting(bridge.PolicyRouting{Table: cfg.Bridge.RouteTable})
+
+	var names, prefixes []string
+	candidates := []string{cfg.WireGuard.InterfaceName, cfg.Bridge.UserAccessInterfaceName}
+	for _, p := range cfg.Profiles {
+		candidates = append(candidates, p.WireGuard.InterfaceName)
+	}
+	for _, n := range candidates {
+		if n != "" {
+			names = append(names, n)
+		}
+	}
+	if p := cfg.Bridge.SiteToSiteInterfacePrefix; p != "" {
+		prefixes = append(prefixes, p)
+	}
+	return &linkCleaner{names: names, prefixes: prefixes, routes: routes, logger: logger}
+}
+
+// Cleanup deletes all plexd network state. Missing state is not an error.
+func (c *linkCleaner) Cleanup() error {
+	var errs []error
+
+	links, err := netlink.LinkList()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("list links: %w", err))
+	}
+	for _, l := range links {
+		name := l.Attrs().Name
+		if !c.owns(name) {
+			continue
+		}
+		if err := netlink.LinkDel(l); err != nil {
+			errs = append(errs, fmt.Errorf("delete link %s: %w", name, err))
+			continue
+		}
+		c.logger.Info("interface deleted", "interface", name)
+	}
+
+	if err := c.routes.FlushRouteTable(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := deletePlexdTables(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (c *linkCleaner) owns(name string) bool {
+	for _, n := range c.names {
+		if name == n {
+			return true
+		}
+	}
+	for _, p := range c.prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// deletePlexdTables deletes the nftables tables of every family that plexd
+// owns: the policy table "plexd" and the bridge tables "plexd-*".
+func deletePlexdTables() error {
+	conn, err := nftables.New()
+	if err != nil {
+		return fmt.Errorf("nftables: %w", err)
+	}
+	tables, err := conn.ListTables()
+	if err != nil {
+		return fmt.Errorf("list nftables tables: %w", err)
+	}
+	n := 0
+	for _, t := range tables {
+		if t.Name == "plexd" || strings.HasPrefix(t.Name, "plexd-") {
+			conn.DelTable(t)
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("delete nftables tables: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+//go:build !linux
+
+package agent
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/plexsphere/plexd/internal/wireguard"
+)
+
+// wgCleaner deletes the mesh interfaces through the platform WireGuard
+// controller.
+type wgCleaner struct {
+	ifaces []string
+	logger *slog.Logger
+}
+
+// NewNetworkCleaner returns the platform NetworkCleaner for cfg. Outside
+// Linux, plexd only installs mesh interfaces, so only those of the top level
+// and all profiles are deleted.
+func NewNetworkCleaner(cfg *AgentConfig, logger *slog.Logger) NetworkCleaner {
+	ifaces := []string{cfg.WireGuard.InterfaceName}
+	for _, p := range cfg.Profiles {
+		ifaces = append(ifaces, p.WireGuard.InterfaceName)
+	}
+	return &wgCleaner{ifaces: ifaces, logger: logger}
+}
+
+// Cleanup deletes the mesh interfaces. Missing interfaces are not an error.
+func (c *wgCleaner) Cleanup() error {
+	ctrl, err := wireguard.NewDefaultController(c.logger)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, iface := range c.ifaces {
+		if err := ctrl.DeleteInterface(iface); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,162 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/registration"
+)
+
+type mockDeregisterer struct {
+	err    error
+	called []string
+}
+
+func (m *mockDeregisterer) Deregister(_ context.Context, nodeID string) error {
+	m.called = append(m.called, nodeID)
+	return m.err
+}
+
+type mockNetworkCleaner struct {
+	err   error
+	calls int
+}
+
+func (m *mockNetworkCleaner) Cleanup() error {
+	m.calls++
+	return m.err
+}
+
+// setupDecommission writes an identity, a cached secret index, and a token
+// file into a fresh data dir.
+func setupDecommission(t *testing.T) *AgentConfig {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &AgentConfig{DataDir: dir}
+	cfg.Registration.TokenFile = filepath.Join(dir, "bootstrap-token")
+
+	id := &registration.NodeIdentity{
+		NodeID:           "node-1",
+		MeshIP:           "100.64.0.1",
+		SigningPublicKey: "spk",
+		PrivateKey:       []byte("01234567890123456789012345678901"),
+		NodeSecretKey:    "nsk",
+	}
+	if err := registration.SaveIdentity(dir, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "state"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "state", "secrets.json"), []byte(`[]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfg.Registration.TokenFile, []byte("token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func assertWiped(t *testing.T, cfg *AgentConfig) {
+	t.Helper()
+	if _, err := registration.LoadIdentity(cfg.DataDir); !errors.Is(err, registration.ErrNotRegistered) {
+		t.Errorf("identity not wiped: LoadIdentity err = %v", err)
+	}
+	for _, p := range []string{filepath.Join(cfg.DataDir, "state"), cfg.Registration.TokenFile} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s not wiped", p)
+		}
+	}
+}
+
+func TestDecommissioner_Decommission(t *testing.T) {
+	cfg := setupDecommission(t)
+	client := &mockDeregisterer{}
+	network := &mockNetworkCleaner{}
+
+	d := NewDecommissioner(cfg, client, testLogger())
+	d.SetNetworkCleaner(network)
+
+	if err := d.Decommission(context.Background(), "node-1", DecommissionOptions{}); err != nil {
+		t.Fatalf("Decommission() error = %v", err)
+	}
+	if len(client.called) != 1 || client.called[0] != "node-1" {
+		t.Errorf("Deregister calls = %v, want [node-1]", client.called)
+	}
+	if network.calls != 1 {
+		t.Errorf("Cleanup calls = %d, want 1", network.calls)
+	}
+	assertWiped(t, cfg)
+}
+
+func TestDecommissioner_DeregisterFailureAborts(t *testing.T) {
+	cfg := setupDecommission(t)
+	network := &mockNetworkCleaner{}
+
+	d := NewDecommissioner(cfg, &mockDeregisterer{err: errors.New("unreachable")}, testLogger())
+	d.SetNetworkCleaner(network)
+
+	if err := d.Decommission(context.Background(), "node-1", DecommissionOptions{}); err == nil {
+		t.Fatal("Decommission() error = nil, want error")
+	}
+	if network.calls != 0 {
+		t.Errorf("Cleanup calls = %d, want 0", network.calls)
+	}
+	if _, err := registration.LoadIdentity(cfg.DataDir); err != nil {
+		t.Errorf("identity removed after aborted decommission: %v", err)
+	}
+}
+
+func TestDecommissioner_Force(t *testing.T) {
+	cfg := setupDecommission(t)
+	network := &mockNetworkCleaner{}
+
+	d := NewDecommissioner(cfg, &mockDeregisterer{err: errors.New("unreachable")}, testLogger())
+	d.SetNetworkCleaner(network)
+
+	if err := d.Decommission(context.Background(), "node-1", DecommissionOptions{Force: true}); err != nil {
+		t.Fatalf("Decommission() error = %v", err)
+	}
+	if network.calls != 1 {
+		t.Errorf("Cleanup calls = %d, want 1", network.calls)
+	}
+	assertWiped(t, cfg)
+}
+
+func TestDecommissioner_NetworkFailureStillWipes(t *testing.T) {
+	cfg := setupDecommission(t)
+
+	d := NewDecommissioner(cfg, &mockDeregisterer{}, testLogger())
+	d.SetNetworkCleaner(&mockNetworkCleaner{err: errors.New("netlink: permission denied")})
+
+	if err := d.Decommission(context.Background(), "node-1", DecommissionOptions{}); err == nil {
+		t.Fatal("Decommission() error = nil, want network cleanup error")
+	}
+	assertWiped(t, cfg)
+}
+
+func TestDecommissioner_WipesSecretSyncDir(t *testing.T) {
+	cfg := setupDecommission(t)
+	cfg.SecretSync.Enabled = true
+	cfg.SecretSync.Dir = filepath.Join(cfg.DataDir, "secrets")
+	if err := os.MkdirAll(cfg.SecretSync.Dir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.SecretSync.Dir, "db"), []byte("pass"), 0o440); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDecommissioner(cfg, &mockDeregisterer{}, testLogger())
+	d.SetNetworkCleaner(nil)
+	if err := d.Decommission(context.Background(), "node-1", DecommissionOptions{}); err != nil {
+		t.Fatalf("Decommission() = %v, want nil", err)
+	}
+
+	assertWiped(t, cfg)
+	if _, err := os.Stat(cfg.SecretSync.Dir); !os.IsNotExist(err) {
+		t.Errorf("secret dir not wiped: stat err = %v", err)
+	}
+}
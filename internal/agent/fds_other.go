@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package agent
+
+import "errors"
+
+// countOpenFDs is not supported on this platform; MaxOpenFDs is not
+// enforced.
+func countOpenFDs() (int, error) {
+	return 0, errors.New("agent: supervisor: open file descriptors: not supported on this platform")
+}
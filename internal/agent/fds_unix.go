@@ -0,0 +1,28 @@
+//go:build linux || darwin
+
+package agent
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// countOpenFDs returns the number of open file descriptors of the agent.
+func countOpenFDs() (int, error) {
+	dir := "/proc/self/fd"
+	if runtime.GOOS == "darwin" {
+		dir = "/dev/fd"
+	}
+	f, err := os.Open(dir)
+	if err != nil {
+		return 0, fmt.Errorf("agent: supervisor: open file descriptors: %w", err)
+	}
+	defer f.Close()
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return 0, fmt.Errorf("agent: supervisor: open file descriptors: %w", err)
+	}
+	// Do not count the descriptor of the directory itself.
+	return len(names) - 1, nil
+}
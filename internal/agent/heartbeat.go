@@ -5,9 +5,11 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"sync/atomic"
 	"time"
 
 	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/reconcile"
 )
 
 // DefaultHeartbeatInterval is the default heartbeat interval.
@@ -48,16 +50,82 @@ type ReconcileTrigger interface {
 	TriggerReconcile()
 }
 
+// HealthSource reports reconcile handlers that are currently degraded.
+// *reconcile.Reconciler satisfies this interface.
+type HealthSource interface {
+	DegradedHandlers() []reconcile.HandlerHealth
+}
+
+// NATSource reports the most recently discovered public endpoint and NAT type.
+// *nat.Discoverer and *peerexchange.Exchanger satisfy this interface.
+type NATSource interface {
+	LastResult() *api.NATInfo
+}
+
+// MeshHealthSource reports the reachability of mesh peers.
+// *meshdiag.Diagnostics satisfies this interface.
+type MeshHealthSource interface {
+	MeshHealth() *api.MeshHealthInfo
+}
+
+// PeerHealthSource reports the handshake health of mesh peers.
+// *peerhealth.Monitor satisfies this interface.
+type PeerHealthSource interface {
+	PeerHealth() *api.PeerHealthInfo
+}
+
+// ContainerNetworkSource reports the node's container network.
+// *cni.Manager satisfies this interface.
+type ContainerNetworkSource interface {
+	ContainerNetwork() *api.ContainerNetworkInfo
+}
+
+// LocalOverridesSource reports the local overrides in effect.
+// *overrides.Manager satisfies this interface.
+type LocalOverridesSource interface {
+	LocalOverrides() *api.LocalOverridesInfo
+}
+
+// RestartSource reports the subsystems the agent restarted recently.
+// *Supervisor satisfies this interface.
+type RestartSource interface {
+	SubsystemRestarts() []api.SubsystemRestart
+}
+
 // HeartbeatService sends periodic heartbeats to the control plane and
 // dispatches directive flags from the response.
 type HeartbeatService struct {
-	cfg           HeartbeatConfig
-	client        HeartbeatClient
-	reconciler    ReconcileTrigger
-	onAuthFailure func()
-	onRotateKeys  func()
-	buildRequest  func() api.HeartbeatRequest
-	logger        *slog.Logger
+	cfg            HeartbeatConfig
+	client         HeartbeatClient
+	reconciler     ReconcileTrigger
+	onAuthFailure  func()
+	onAuthRejected func()
+	rejectAfter    int
+	onRotateKeys   func()
+	onDecommission func()
+	buildRequest   func() api.HeartbeatRequest
+	health         HealthSource
+	nat            NATSource
+	meshHealth     MeshHealthSource
+	peerHealth     PeerHealthSource
+	containerNet   ContainerNetworkSource
+	overrides      LocalOverridesSource
+	restarts       RestartSource
+	privilege      string
+	privDegraded   bool
+	logger         *slog.Logger
+
+	// authFailures counts the consecutive heartbeats that failed with 401
+	// Unauthorized. It is only accessed by the Run goroutine.
+	authFailures int
+
+	// trigger is a buffered channel (size 1) used to coalesce TriggerHeartbeat calls.
+	trigger chan struct{}
+
+	// interval holds the current send interval; SetInterval signals
+	// intervalChanged so that Run resets its ticker.
+	interval        atomic.Int64
+	intervalChanged chan struct{}
 }
 
 // NewHeartbeatService creates a new HeartbeatService with the given
@@ -65,11 +133,15 @@ type HeartbeatService struct {
 // for any zero-valued optional fields.
 func NewHeartbeatService(cfg HeartbeatConfig, client HeartbeatClient, logger *slog.Logger) *HeartbeatService {
 	cfg.ApplyDefaults()
-	return &HeartbeatService{
-		cfg:    cfg,
-		client: client,
-		logger: logger.With("component", "heartbeat"),
+	s := &HeartbeatService{
+		cfg:             cfg,
+		client:          client,
+		logger:          logger.With("component", "heartbeat"),
+		trigger:         make(chan struct{}, 1),
+		intervalChanged: make(chan struct{}, 1),
 	}
+	s.interval.Store(int64(cfg.Interval))
+	return s
 }
 
 // SetReconcileTrigger sets the reconcile trigger invoked when the control
@@ -84,55 +156,195 @@ func (s *HeartbeatService) SetOnAuthFailure(fn func()) {
 	s.onAuthFailure = fn
 }
 
+// SetOnAuthRejected sets a callback invoked when threshold consecutive
+// heartbeats have failed with 401 Unauthorized, which means the control
+// plane no longer accepts the node identity, for example because the node
+// was deleted. It is invoked once per series of failures; a successful
+// heartbeat starts a new series.
+func (s *HeartbeatService) SetOnAuthRejected(threshold int, fn func()) {
+	s.rejectAfter = threshold
+	s.onAuthRejected = fn
+}
+
 // SetOnRotateKeys sets a callback invoked when the control plane signals
 // that keys should be rotated.
 func (s *HeartbeatService) SetOnRotateKeys(fn func()) {
 	s.onRotateKeys = fn
 }
 
+// SetOnDecommission sets a callback invoked when the control plane signals
+// that this node is being retired and should decommission itself.
+func (s *HeartbeatService) SetOnDecommission(fn func()) {
+	s.onDecommission = fn
+}
+
 // SetBuildRequest sets a custom heartbeat request builder. If not set,
 // the service sends a zero-valued HeartbeatRequest.
 func (s *HeartbeatService) SetBuildRequest(fn func() api.HeartbeatRequest) {
 	s.buildRequest = fn
 }
 
+// SetHealthSource sets the source of reconcile handler health. When any
+// handler is degraded, the heartbeat Status is set to "degraded" and the
+// handlers are listed in DegradedHandlers.
+func (s *HeartbeatService) SetHealthSource(hs HealthSource) {
+	s.health = hs
+}
+
+// SetNATSource sets the source of NAT discovery results. When set, the
+// heartbeat NAT field is populated from the latest result unless the request
+// builder already filled it.
+func (s *HeartbeatService) SetNATSource(ns NATSource) {
+	s.nat = ns
+}
+
+// SetMeshHealthSource sets the source of mesh peer reachability. When set,
+// the heartbeat MeshHealth field is populated from the latest probe round.
+func (s *HeartbeatService) SetMeshHealthSource(ms MeshHealthSource) {
+	s.meshHealth = ms
+}
+
+// SetPeerHealthSource sets the source of mesh peer handshake health. When
+// set, the heartbeat PeerHealth field lists the inactive and flapping peers
+// as of the latest check.
+func (s *HeartbeatService) SetPeerHealthSource(ps PeerHealthSource) {
+	s.peerHealth = ps
+}
+
+// SetContainerNetworkSource sets the source of the container network status.
+// When set, the heartbeat ContainerNetwork field reports the container
+// prefix and the number of attached containers.
+func (s *HeartbeatService) SetContainerNetworkSource(cs ContainerNetworkSource) {
+	s.containerNet = cs
+}
+
+// SetLocalOverridesSource sets the source of the local overrides. When set,
+// the heartbeat LocalOverrides field reports every override in effect, so
+// the control plane can show that the node deviates from its state.
+func (s *HeartbeatService) SetLocalOverridesSource(lo LocalOverridesSource) {
+	s.overrides = lo
+}
+
+// SetRestartSource sets the source of subsystem restarts. When set, the
+// heartbeat SubsystemRestarts field lists the subsystems the supervisor
+// restarted within its restart window.
+func (s *HeartbeatService) SetRestartSource(rs RestartSource) {
+	s.restarts = rs
+}
+
+// SetPrivilege records how the agent performs privileged operations. The
+// level is reported in every heartbeat; when degraded is true the heartbeat
+// Status is set to "degraded" because mesh networking is unavailable.
+func (s *HeartbeatService) SetPrivilege(level string, degraded bool) {
+	s.privilege = level
+	s.privDegraded = degraded
+}
+
+// TriggerHeartbeat requests an immediate heartbeat, for example after a
+// network change. Multiple calls before the loop picks up the signal are
+// coalesced into one. Safe for concurrent use.
+func (s *HeartbeatService) TriggerHeartbeat() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// SetInterval changes the heartbeat interval of a running service, for
+// example after a configuration reload. The next heartbeat is sent one
+// interval after the change. Non-positive values are ignored. Safe for
+// concurrent use.
+func (s *HeartbeatService) SetInterval(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	s.interval.Store(int64(d))
+	select {
+	case s.intervalChanged <- struct{}{}:
+	default:
+	}
+}
+
 // Run starts the heartbeat loop. It sends one heartbeat immediately and
 // then continues at the configured interval until ctx is cancelled.
+// TriggerHeartbeat sends an extra heartbeat and restarts the interval.
 // Run always returns nil.
 func (s *HeartbeatService) Run(ctx context.Context) error {
 	s.sendHeartbeat(ctx)
 
-	ticker := time.NewTicker(s.cfg.Interval)
+	ticker := time.NewTicker(s.currentInterval())
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
+		case <-s.intervalChanged:
+			ticker.Reset(s.currentInterval())
+		case <-s.trigger:
+			ticker.Reset(s.currentInterval())
+			s.sendHeartbeat(ctx)
 		case <-ticker.C:
 			s.sendHeartbeat(ctx)
 		}
 	}
 }
 
+func (s *HeartbeatService) currentInterval() time.Duration {
+	return time.Duration(s.interval.Load())
+}
+
 func (s *HeartbeatService) sendHeartbeat(ctx context.Context) {
 	var req api.HeartbeatRequest
 	if s.buildRequest != nil {
 		req = s.buildRequest()
 	}
+	if s.nat != nil && req.NAT == nil {
+		req.NAT = s.nat.LastResult()
+	}
+	if s.meshHealth != nil && req.MeshHealth == nil {
+		req.MeshHealth = s.meshHealth.MeshHealth()
+	}
+	if s.peerHealth != nil && req.PeerHealth == nil {
+		req.PeerHealth = s.peerHealth.PeerHealth()
+	}
+	if s.containerNet != nil && req.ContainerNetwork == nil {
+		req.ContainerNetwork = s.containerNet.ContainerNetwork()
+	}
+	if s.overrides != nil && req.LocalOverrides == nil {
+		req.LocalOverrides = s.overrides.LocalOverrides()
+	}
+	if s.restarts != nil && req.SubsystemRestarts == nil {
+		req.SubsystemRestarts = s.restarts.SubsystemRestarts()
+	}
+	if s.health != nil {
+		applyHandlerHealth(&req, s.health.DegradedHandlers())
+	}
+	if s.privilege != "" {
+		req.Privilege = s.privilege
+		if s.privDegraded {
+			req.Status = "degraded"
+		}
+	}
 
 	resp, err := s.client.Heartbeat(ctx, s.cfg.NodeID, req)
 	if err != nil {
 		if errors.Is(err, api.ErrUnauthorized) {
-			s.logger.ErrorContext(ctx, "agent: heartbeat: unauthorized")
+			s.authFailures++
+			s.logger.ErrorContext(ctx, "agent: heartbeat: unauthorized", "consecutive", s.authFailures)
 			if s.onAuthFailure != nil {
 				s.onAuthFailure()
 			}
+			if s.onAuthRejected != nil && s.authFailures == s.rejectAfter {
+				s.logger.ErrorContext(ctx, "agent: heartbeat: node identity rejected by control plane", "consecutive", s.authFailures)
+				s.onAuthRejected()
+			}
 			return
 		}
 		s.logger.ErrorContext(ctx, "agent: heartbeat: send failed", "error", err)
 		return
 	}
+	s.authFailures = 0
 
 	if resp.Reconcile && s.reconciler != nil {
 		s.reconciler.TriggerReconcile()
@@ -140,4 +352,26 @@ func (s *HeartbeatService) sendHeartbeat(ctx context.Context) {
 	if resp.RotateKeys && s.onRotateKeys != nil {
 		s.onRotateKeys()
 	}
+	if resp.Decommission && s.onDecommission != nil {
+		s.logger.WarnContext(ctx, "agent: heartbeat: decommission requested by control plane")
+		s.onDecommission()
+	}
+}
+
+// applyHandlerHealth marks the heartbeat as degraded and lists the degraded
+// reconcile handlers. It leaves req untouched when all handlers are healthy.
+func applyHandlerHealth(req *api.HeartbeatRequest, degraded []reconcile.HandlerHealth) {
+	if len(degraded) == 0 {
+		return
+	}
+	req.Status = "degraded"
+	req.DegradedHandlers = make([]api.DegradedHandler, 0, len(degraded))
+	for _, h := range degraded {
+		req.DegradedHandlers = append(req.DegradedHandlers, api.DegradedHandler{
+			Name:                h.Name,
+			ConsecutiveFailures: h.ConsecutiveFailures,
+			LastError:           h.LastError,
+			CircuitOpen:         h.CircuitOpen,
+		})
+	}
 }
@@ -5,10 +5,12 @@ import (
 	"io"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/reconcile"
 )
 
 // ---------------------------------------------------------------------------
@@ -192,6 +194,28 @@ func TestHeartbeatService_RotateKeys(t *testing.T) {
 	}
 }
 
+func TestHeartbeatService_Decommission(t *testing.T) {
+	client := &mockHeartbeatClient{
+		responses: []*api.HeartbeatResponse{
+			{Decommission: true},
+		},
+	}
+
+	var calls atomic.Int32
+	cfg := HeartbeatConfig{NodeID: "node-1", Interval: time.Hour}
+	svc := NewHeartbeatService(cfg, client, testLogger())
+	svc.SetOnDecommission(func() { calls.Add(1) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	svc.Run(ctx)
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("onDecommission calls = %d, want 1", got)
+	}
+}
+
 func TestHeartbeatService_AuthFailure(t *testing.T) {
 	client := &mockHeartbeatClient{
 		errors: []error{
@@ -224,6 +248,29 @@ func TestHeartbeatService_AuthFailure(t *testing.T) {
 	}
 }
 
+func TestHeartbeatService_AuthRejected(t *testing.T) {
+	unauthorized := &api.APIError{StatusCode: 401, Message: "unknown node"}
+	client := &mockHeartbeatClient{
+		errors: []error{unauthorized, unauthorized, nil, unauthorized, unauthorized, unauthorized, unauthorized},
+	}
+
+	svc := NewHeartbeatService(HeartbeatConfig{NodeID: "node-1"}, client, testLogger())
+	var rejectedAt []int
+	svc.SetOnAuthRejected(3, func() {
+		rejectedAt = append(rejectedAt, client.calls)
+	})
+
+	for range client.errors {
+		svc.sendHeartbeat(context.Background())
+	}
+
+	// The success after two failures starts a new series; the series is
+	// reported once, at its third failure.
+	if len(rejectedAt) != 1 || rejectedAt[0] != 6 {
+		t.Errorf("onAuthRejected after heartbeats %v, want [6]", rejectedAt)
+	}
+}
+
 func TestHeartbeatService_TransientError(t *testing.T) {
 	client := &mockHeartbeatClient{
 		errors: []error{
@@ -308,3 +355,418 @@ func TestHeartbeatService_BuildRequest(t *testing.T) {
 		t.Errorf("request BinaryChecksum = %q, want %q", reqs[0].BinaryChecksum, "abc123")
 	}
 }
+
+type mockHealthSource struct {
+	degraded []reconcile.HandlerHealth
+}
+
+func (m *mockHealthSource) DegradedHandlers() []reconcile.HandlerHealth {
+	return m.degraded
+}
+
+func TestHeartbeatService_DegradedHandlers(t *testing.T) {
+	client := &mockHeartbeatClient{}
+
+	cfg := HeartbeatConfig{NodeID: "node-1", Interval: time.Hour}
+	svc := NewHeartbeatService(cfg, client, testLogger())
+	svc.SetBuildRequest(func() api.HeartbeatRequest {
+		return api.HeartbeatRequest{Status: "healthy"}
+	})
+	svc.SetHealthSource(&mockHealthSource{degraded: []reconcile.HandlerHealth{
+		{Name: "bridge_routes", ConsecutiveFailures: 3, LastError: "route add failed"},
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	svc.Run(ctx)
+
+	reqs := client.getRequests()
+	if len(reqs) == 0 {
+		t.Fatal("expected at least one heartbeat request")
+	}
+	if reqs[0].Status != "degraded" {
+		t.Errorf("request Status = %q, want %q", reqs[0].Status, "degraded")
+	}
+	if len(reqs[0].DegradedHandlers) != 1 {
+		t.Fatalf("DegradedHandlers len = %d, want 1", len(reqs[0].DegradedHandlers))
+	}
+	got := reqs[0].DegradedHandlers[0]
+	if got.Name != "bridge_routes" || got.ConsecutiveFailures != 3 || got.LastError != "route add failed" {
+		t.Errorf("DegradedHandlers[0] = %+v", got)
+	}
+}
+
+func TestHeartbeatService_HealthyHandlersLeaveStatus(t *testing.T) {
+	client := &mockHeartbeatClient{}
+
+	cfg := HeartbeatConfig{NodeID: "node-1", Interval: time.Hour}
+	svc := NewHeartbeatService(cfg, client, testLogger())
+	svc.SetBuildRequest(func() api.HeartbeatRequest {
+		return api.HeartbeatRequest{Status: "healthy"}
+	})
+	svc.SetHealthSource(&mockHealthSource{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	svc.Run(ctx)
+
+	reqs := client.getRequests()
+	if len(reqs) == 0 {
+		t.Fatal("expected at least one heartbeat request")
+	}
+	if reqs[0].Status != "healthy" {
+		t.Errorf("request Status = %q, want %q", reqs[0].Status, "healthy")
+	}
+	if reqs[0].DegradedHandlers != nil {
+		t.Errorf("DegradedHandlers = %v, want nil", reqs[0].DegradedHandlers)
+	}
+}
+
+type mockNATSource struct {
+	info *api.NATInfo
+}
+
+func (m *mockNATSource) LastResult() *api.NATInfo {
+	return m.info
+}
+
+func TestHeartbeatService_NATSource(t *testing.T) {
+	client := &mockHeartbeatClient{}
+
+	cfg := HeartbeatConfig{NodeID: "node-1", Interval: time.Hour}
+	svc := NewHeartbeatService(cfg, client, testLogger())
+	svc.SetNATSource(&mockNATSource{info: &api.NATInfo{PublicEndpoint: "203.0.113.5:51820", Type: "full_cone"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	svc.Run(ctx)
+
+	reqs := client.getRequests()
+	if len(reqs) == 0 {
+		t.Fatal("expected at least one heartbeat request")
+	}
+	if reqs[0].NAT == nil {
+		t.Fatal("request NAT is nil, want populated from source")
+	}
+	if reqs[0].NAT.PublicEndpoint != "203.0.113.5:51820" || reqs[0].NAT.Type != "full_cone" {
+		t.Errorf("request NAT = %+v", reqs[0].NAT)
+	}
+}
+
+func TestHeartbeatService_NATSourceDoesNotOverrideBuilder(t *testing.T) {
+	client := &mockHeartbeatClient{}
+
+	cfg := HeartbeatConfig{NodeID: "node-1", Interval: time.Hour}
+	svc := NewHeartbeatService(cfg, client, testLogger())
+	svc.SetBuildRequest(func() api.HeartbeatRequest {
+		return api.HeartbeatRequest{NAT: &api.NATInfo{PublicEndpoint: "198.51.100.1:1", Type: "none"}}
+	})
+	svc.SetNATSource(&mockNATSource{info: &api.NATInfo{PublicEndpoint: "203.0.113.5:51820", Type: "full_cone"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	svc.Run(ctx)
+
+	reqs := client.getRequests()
+	if len(reqs) == 0 {
+		t.Fatal("expected at least one heartbeat request")
+	}
+	if reqs[0].NAT == nil || reqs[0].NAT.PublicEndpoint != "198.51.100.1:1" {
+		t.Errorf("request NAT = %+v, want builder value", reqs[0].NAT)
+	}
+}
+
+type mockMeshHealthSource struct {
+	info *api.MeshHealthInfo
+}
+
+func (m *mockMeshHealthSource) MeshHealth() *api.MeshHealthInfo {
+	return m.info
+}
+
+func TestHeartbeatService_MeshHealthSource(t *testing.T) {
+	client := &mockHeartbeatClient{}
+
+	cfg := HeartbeatConfig{NodeID: "node-1", Interval: time.Hour}
+	svc := NewHeartbeatService(cfg, client, testLogger())
+	svc.SetMeshHealthSource(&mockMeshHealthSource{info: &api.MeshHealthInfo{PeersTotal: 3, PeersReachable: 2, Unreachable: []string{"peer-c"}}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	svc.Run(ctx)
+
+	reqs := client.getRequests()
+	if len(reqs) == 0 {
+		t.Fatal("expected at least one heartbeat request")
+	}
+	mh := reqs[0].MeshHealth
+	if mh == nil {
+		t.Fatal("request MeshHealth is nil, want populated from source")
+	}
+	if mh.PeersTotal != 3 || mh.PeersReachable != 2 || len(mh.Unreachable) != 1 {
+		t.Errorf("request MeshHealth = %+v", mh)
+	}
+	if reqs[0].Status == "degraded" {
+		t.Error("unreachable peers must not mark the heartbeat degraded")
+	}
+}
+
+type mockPeerHealthSource struct {
+	info *api.PeerHealthInfo
+}
+
+func (m *mockPeerHealthSource) PeerHealth() *api.PeerHealthInfo {
+	return m.info
+}
+
+func TestHeartbeatService_PeerHealthSource(t *testing.T) {
+	client := &mockHeartbeatClient{}
+
+	cfg := HeartbeatConfig{NodeID: "node-1", Interval: time.Hour}
+	svc := NewHeartbeatService(cfg, client, testLogger())
+	svc.SetPeerHealthSource(&mockPeerHealthSource{info: &api.PeerHealthInfo{
+		PeersTotal:  2,
+		PeersActive: 1,
+		Peers:       []api.PeerHealthStatus{{PeerID: "peer-b", Inactive: true}},
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	svc.Run(ctx)
+
+	reqs := client.getRequests()
+	if len(reqs) == 0 {
+		t.Fatal("expected at least one heartbeat request")
+	}
+	ph := reqs[0].PeerHealth
+	if ph == nil {
+		t.Fatal("request PeerHealth is nil, want populated from source")
+	}
+	if ph.PeersTotal != 2 || ph.PeersActive != 1 || len(ph.Peers) != 1 || ph.Peers[0].PeerID != "peer-b" {
+		t.Errorf("request PeerHealth = %+v", ph)
+	}
+	if reqs[0].Status == "degraded" {
+		t.Error("inactive peers must not mark the heartbeat degraded")
+	}
+}
+
+type mockContainerNetworkSource struct {
+	info *api.ContainerNetworkInfo
+}
+
+func (m *mockContainerNetworkSource) ContainerNetwork() *api.ContainerNetworkInfo {
+	return m.info
+}
+
+func TestHeartbeatService_ContainerNetworkSource(t *testing.T) {
+	client := &mockHeartbeatClient{}
+
+	cfg := HeartbeatConfig{NodeID: "node-1", Interval: time.Hour}
+	svc := NewHeartbeatService(cfg, client, testLogger())
+	svc.SetContainerNetworkSource(&mockContainerNetworkSource{info: &api.ContainerNetworkInfo{Prefix: "100.96.3.0/24", Containers: 4}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	svc.Run(ctx)
+
+	reqs := client.getRequests()
+	if len(reqs) == 0 {
+		t.Fatal("expected at least one heartbeat request")
+	}
+	if cn := reqs[0].ContainerNetwork; cn == nil || cn.Prefix != "100.96.3.0/24" || cn.Containers != 4 {
+		t.Errorf("request ContainerNetwork = %+v", cn)
+	}
+}
+
+func TestHeartbeatService_TriggerHeartbeat(t *testing.T) {
+	client := &mockHeartbeatClient{}
+
+	cfg := HeartbeatConfig{NodeID: "node-1", Interval: time.Hour}
+	svc := NewHeartbeatService(cfg, client, testLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		svc.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.After(2 * time.Second)
+	waitRequests := func(want int) {
+		t.Helper()
+		for len(client.getRequests()) < want {
+			select {
+			case <-deadline:
+				t.Fatalf("timed out waiting for %d heartbeats, got %d", want, len(client.getRequests()))
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+	}
+
+	waitRequests(1)
+	svc.TriggerHeartbeat()
+	waitRequests(2)
+
+	cancel()
+	<-done
+}
+
+func TestHeartbeatService_SetInterval(t *testing.T) {
+	client := &mockHeartbeatClient{}
+
+	cfg := HeartbeatConfig{NodeID: "node-1", Interval: time.Hour}
+	svc := NewHeartbeatService(cfg, client, testLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		svc.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.After(2 * time.Second)
+	for len(client.getRequests()) < 1 {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for initial heartbeat")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	svc.SetInterval(20 * time.Millisecond)
+	for len(client.getRequests()) < 3 {
+		select {
+		case <-deadline:
+			t.Fatalf("timed out waiting for heartbeats at new interval, got %d", len(client.getRequests()))
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	// Non-positive intervals are ignored.
+	svc.SetInterval(0)
+	if got := svc.currentInterval(); got != 20*time.Millisecond {
+		t.Errorf("interval = %v, want 20ms", got)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestHeartbeatService_TriggerHeartbeatCoalesces(t *testing.T) {
+	svc := NewHeartbeatService(HeartbeatConfig{NodeID: "node-1"}, &mockHeartbeatClient{}, testLogger())
+
+	// Multiple triggers before Run must not block.
+	svc.TriggerHeartbeat()
+	svc.TriggerHeartbeat()
+
+	if n := len(svc.trigger); n != 1 {
+		t.Errorf("pending triggers = %d, want 1", n)
+	}
+}
+
+func TestHeartbeatService_Privilege(t *testing.T) {
+	tests := []struct {
+		name       string
+		level      string
+		degraded   bool
+		wantStatus string
+	}{
+		{"helper", "helper", false, "healthy"},
+		{"unprivileged", "unprivileged", true, "degraded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockHeartbeatClient{}
+
+			cfg := HeartbeatConfig{NodeID: "node-1", Interval: time.Hour}
+			svc := NewHeartbeatService(cfg, client, testLogger())
+			svc.SetBuildRequest(func() api.HeartbeatRequest {
+				return api.HeartbeatRequest{Status: "healthy"}
+			})
+			svc.SetPrivilege(tt.level, tt.degraded)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			svc.Run(ctx)
+
+			reqs := client.getRequests()
+			if len(reqs) == 0 {
+				t.Fatal("expected at least one heartbeat request")
+			}
+			if reqs[0].Privilege != tt.level {
+				t.Errorf("request Privilege = %q, want %q", reqs[0].Privilege, tt.level)
+			}
+			if reqs[0].Status != tt.wantStatus {
+				t.Errorf("request Status = %q, want %q", reqs[0].Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+type mockLocalOverridesSource struct {
+	info *api.LocalOverridesInfo
+}
+
+func (m *mockLocalOverridesSource) LocalOverrides() *api.LocalOverridesInfo {
+	return m.info
+}
+
+func TestHeartbeatService_LocalOverridesSource(t *testing.T) {
+	client := &mockHeartbeatClient{}
+
+	cfg := HeartbeatConfig{NodeID: "node-1", Interval: time.Hour}
+	svc := NewHeartbeatService(cfg, client, testLogger())
+	svc.SetLocalOverridesSource(&mockLocalOverridesSource{info: &api.LocalOverridesInfo{ExcludedPeers: []string{"peer-b"}}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	svc.Run(ctx)
+
+	reqs := client.getRequests()
+	if len(reqs) == 0 {
+		t.Fatal("expected at least one heartbeat request")
+	}
+	if lo := reqs[0].LocalOverrides; lo == nil || len(lo.ExcludedPeers) != 1 || lo.ExcludedPeers[0] != "peer-b" {
+		t.Errorf("request LocalOverrides = %+v", lo)
+	}
+}
+
+type mockRestartSource struct {
+	restarts []api.SubsystemRestart
+}
+
+func (m *mockRestartSource) SubsystemRestarts() []api.SubsystemRestart {
+	return m.restarts
+}
+
+func TestHeartbeatService_RestartSource(t *testing.T) {
+	client := &mockHeartbeatClient{}
+
+	cfg := HeartbeatConfig{NodeID: "node-1", Interval: time.Hour}
+	svc := NewHeartbeatService(cfg, client, testLogger())
+	svc.SetRestartSource(&mockRestartSource{restarts: []api.SubsystemRestart{{Subsystem: "peer_health", Reason: "goroutines"}}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	svc.Run(ctx)
+
+	reqs := client.getRequests()
+	if len(reqs) == 0 {
+		t.Fatal("expected at least one heartbeat request")
+	}
+	if r := reqs[0].SubsystemRestarts; len(r) != 1 || r[0].Subsystem != "peer_health" {
+		t.Errorf("request SubsystemRestarts = %+v", r)
+	}
+}
@@ -0,0 +1,217 @@
+package agent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentConfigVersion is the config_version written by this release. Files
+// with an older (or no) config_version are migrated on load.
+const CurrentConfigVersion = 1
+
+// configVersionKey is the top-level key holding the config file version.
+const configVersionKey = "config_version"
+
+// configMigration upgrades a config document from version from to from+1.
+// It edits the top-level mapping node in place, so that comments and the
+// order of untouched keys survive a rewrite. It returns a human-readable
+// line per change.
+type configMigration struct {
+	from    int
+	migrate func(root *yaml.Node) ([]string, error)
+}
+
+// configMigrations lists the migrations in order; entry i upgrades version i.
+var configMigrations = []configMigration{
+	{from: 0, migrate: migrateFlatRegistrationKeys},
+}
+
+// MigrationResult describes the outcome of MigrateConfig.
+type MigrationResult struct {
+	// From is the config_version found in the input (0 if absent).
+	From int
+
+	// To is the config_version of the output.
+	To int
+
+	// Changes lists the changes made, one line each.
+	Changes []string
+}
+
+// Migrated reports whether MigrateConfig changed the document.
+func (r MigrationResult) Migrated() bool {
+	return r.From != r.To
+}
+
+// MigrateConfig upgrades a YAML config document to CurrentConfigVersion and
+// returns the rewritten document. Comments and unrelated keys are preserved.
+// A document that is already current is returned unchanged. A document with
+// a config_version newer than CurrentConfigVersion is rejected.
+func MigrateConfig(data []byte) ([]byte, MigrationResult, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, MigrationResult{}, fmt.Errorf("agent: config: parse: %w", err)
+	}
+	res, err := migrateDocument(&doc)
+	if err != nil {
+		return nil, res, fmt.Errorf("agent: config: %w", err)
+	}
+	if !res.Migrated() {
+		return data, res, nil
+	}
+	setMappingValue(doc.Content[0], configVersionKey, strconv.Itoa(res.To))
+	res.Changes = append(res.Changes, fmt.Sprintf("set %s to %d", configVersionKey, res.To))
+	out, err := encodeDocument(&doc)
+	if err != nil {
+		return nil, res, err
+	}
+	return out, res, nil
+}
+
+// encodeDocument encodes doc with the indentation used for config files.
+func encodeDocument(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("agent: config: encode: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("agent: config: encode: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// migrateDocument applies the pending migrations to doc in place. It does
+// not update config_version; res.Changes lists only changes made by the
+// migrations. An empty document has nothing to migrate and counts as
+// current.
+func migrateDocument(doc *yaml.Node) (MigrationResult, error) {
+	res := MigrationResult{From: CurrentConfigVersion, To: CurrentConfigVersion}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return res, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return res, errors.New("top level must be a mapping")
+	}
+
+	version, err := documentVersion(root)
+	if err != nil {
+		return res, err
+	}
+	res.From, res.To = version, version
+	if version > CurrentConfigVersion {
+		return res, fmt.Errorf("config_version %d is newer than supported version %d", version, CurrentConfigVersion)
+	}
+
+	for _, m := range configMigrations[version:] {
+		changes, err := m.migrate(root)
+		if err != nil {
+			return res, fmt.Errorf("migrate from version %d: %w", m.from, err)
+		}
+		res.Changes = append(res.Changes, changes...)
+		res.To = m.from + 1
+	}
+	return res, nil
+}
+
+// documentVersion returns the config_version of the top-level mapping, or 0
+// if it is absent.
+func documentVersion(root *yaml.Node) (int, error) {
+	_, v := mappingEntry(root, configVersionKey)
+	if v == nil {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(v.Value)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("line %d: invalid %s %q", v.Line, configVersionKey, v.Value)
+	}
+	return version, nil
+}
+
+// migrateFlatRegistrationKeys moves the flat top-level keys of unversioned
+// config files (as written by early installers and cloud-init templates)
+// into their sections. A nested key that is already set wins over its flat
+// counterpart, which is dropped.
+func migrateFlatRegistrationKeys(root *yaml.Node) ([]string, error) {
+	moves := []struct{ from, section, key string }{
+		{"api_url", "api", "baseurl"},
+		{"token_file", "registration", "tokenfile"},
+		{"use_metadata", "registration", "usemetadata"},
+		{"metadata_token_path", "registration", "metadatatokenpath"},
+		{"metadata_timeout", "registration", "metadatatimeout"},
+		{"hostname", "registration", "hostname"},
+	}
+	var changes []string
+	for _, mv := range moves {
+		i, v := mappingEntry(root, mv.from)
+		if v == nil {
+			continue
+		}
+		key := root.Content[i]
+		root.Content = append(root.Content[:i], root.Content[i+2:]...)
+
+		target := mv.section + "." + mv.key
+		section := mappingSection(root, mv.section)
+		if section == nil {
+			return changes, fmt.Errorf("line %d: %s is not a mapping", key.Line, mv.section)
+		}
+		if _, existing := mappingEntry(section, mv.key); existing != nil {
+			changes = append(changes, fmt.Sprintf("removed %s (superseded by %s)", mv.from, target))
+			continue
+		}
+		key.Value = mv.key
+		section.Content = append(section.Content, key, v)
+		changes = append(changes, fmt.Sprintf("moved %s to %s", mv.from, target))
+	}
+	return changes, nil
+}
+
+// mappingEntry returns the index of the key node and the value node for key
+// in the mapping m, or -1 and nil if key is absent.
+func mappingEntry(m *yaml.Node, key string) (int, *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i, m.Content[i+1]
+		}
+	}
+	return -1, nil
+}
+
+// mappingSection returns the mapping value of key in m, creating an empty
+// one if key is absent. It returns nil if key holds a non-mapping value.
+func mappingSection(m *yaml.Node, key string) *yaml.Node {
+	if _, v := mappingEntry(m, key); v != nil {
+		if v.Kind == yaml.ScalarNode && v.Tag == "!!null" {
+			v.Kind, v.Tag, v.Value = yaml.MappingNode, "!!map", ""
+		}
+		if v.Kind != yaml.MappingNode {
+			return nil
+		}
+		return v
+	}
+	section := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	m.Content = append(m.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		section,
+	)
+	return section
+}
+
+// setMappingValue sets the scalar value of key in m, inserting key at the
+// top of the mapping if absent.
+func setMappingValue(m *yaml.Node, key, value string) {
+	if _, v := mappingEntry(m, key); v != nil {
+		v.Kind, v.Tag, v.Value = yaml.ScalarNode, "!!int", value
+		return
+	}
+	m.Content = append([]*yaml.Node{
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		{Kind: yaml.ScalarNode, Tag: "!!int", Value: value},
+	}, m.Content...)
+}
@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"maps"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/packaging"
+)
+
+const legacyConfigYAML = `# plexd configuration
+api_url: https://api.example.com # control plane
+data_dir: /var/lib/plexd
+log_level: info
+token_file: /etc/plexd/bootstrap-token
+use_metadata: true
+metadata_token_path: /plexd/bootstrap-token
+metadata_timeout: 2s
+hostname: web-1
+registration:
+  datadir: /tmp/plexd
+node_api:
+  datadir: /tmp/plexd
+heartbeat:
+  nodeid: "node-1"
+`
+
+func TestMigrateConfig_FlatKeys(t *testing.T) {
+	out, res, err := MigrateConfig([]byte(legacyConfigYAML))
+	if err != nil {
+		t.Fatalf("MigrateConfig: %v", err)
+	}
+	if res.From != 0 || res.To != CurrentConfigVersion || !res.Migrated() {
+		t.Errorf("result = %+v, want 0 -> %d", res, CurrentConfigVersion)
+	}
+	wantChanges := []string{
+		"moved api_url to api.baseurl",
+		"moved token_file to registration.tokenfile",
+		"moved use_metadata to registration.usemetadata",
+		"moved metadata_token_path to registration.metadatatokenpath",
+		"moved metadata_timeout to registration.metadatatimeout",
+		"moved hostname to registration.hostname",
+		"set config_version to 1",
+	}
+	if !reflect.DeepEqual(res.Changes, wantChanges) {
+		t.Errorf("Changes = %q, want %q", res.Changes, wantChanges)
+	}
+
+	s := string(out)
+	for _, want := range []string{"config_version: 1", "# plexd configuration", "# control plane", "  datadir: /tmp/plexd"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("migrated output missing %q:\n%s", want, s)
+		}
+	}
+	if strings.Contains(s, "api_url") || strings.Contains(s, "token_file") {
+		t.Errorf("migrated output still contains flat keys:\n%s", s)
+	}
+
+	// The migrated file passes the strict check and migrating again is a no-op.
+	cfg, err := CheckConfig(writeTemp(t, s), ConfigOverrides{})
+	if err != nil {
+		t.Fatalf("CheckConfig(migrated): %v", err)
+	}
+	if cfg.API.BaseURL != "https://api.example.com" || cfg.Registration.TokenFile != "/etc/plexd/bootstrap-token" {
+		t.Errorf("migrated config = api %q, tokenfile %q", cfg.API.BaseURL, cfg.Registration.TokenFile)
+	}
+	if !cfg.Registration.UseMetadata || cfg.Registration.MetadataTimeout != 2*time.Second || cfg.Registration.Hostname != "web-1" {
+		t.Errorf("migrated registration = %+v", cfg.Registration)
+	}
+	if cfg.FileVersion() != CurrentConfigVersion {
+		t.Errorf("FileVersion = %d, want %d", cfg.FileVersion(), CurrentConfigVersion)
+	}
+	again, res, err := MigrateConfig(out)
+	if err != nil || res.Migrated() || string(again) != s {
+		t.Errorf("second MigrateConfig = %+v, %v; want unchanged", res, err)
+	}
+}
+
+func TestMigrateConfig_NestedKeyWins(t *testing.T) {
+	in := "api_url: https://old.example.com\napi:\n  baseurl: https://new.example.com\n"
+	out, res, err := MigrateConfig([]byte(in))
+	if err != nil {
+		t.Fatalf("MigrateConfig: %v", err)
+	}
+	if res.Changes[0] != "removed api_url (superseded by api.baseurl)" {
+		t.Errorf("Changes = %q", res.Changes)
+	}
+	if strings.Contains(string(out), "old.example.com") {
+		t.Errorf("output kept superseded value:\n%s", out)
+	}
+}
+
+func TestMigrateConfig_Errors(t *testing.T) {
+	tests := map[string]string{
+		"config_version: 99\n":         "newer than supported",
+		"config_version: two\n":        "invalid config_version",
+		"- a\n- b\n":                   "top level must be a mapping",
+		"api_url: https://x\napi: 3\n": "api is not a mapping",
+		"{{invalid":                    "parse",
+	}
+	for in, want := range tests {
+		if _, _, err := MigrateConfig([]byte(in)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("MigrateConfig(%q) = %v, want error containing %q", in, err, want)
+		}
+	}
+}
+
+func TestLoadConfig_MigratesLegacyFile(t *testing.T) {
+	cfg, err := ParseConfig(writeTemp(t, legacyConfigYAML), ConfigOverrides{})
+	if err != nil {
+		t.Fatalf("ParseConfig(legacy): %v", err)
+	}
+	if cfg.FileVersion() != 0 {
+		t.Errorf("FileVersion = %d, want 0", cfg.FileVersion())
+	}
+	if cfg.ConfigVersion != CurrentConfigVersion {
+		t.Errorf("ConfigVersion = %d, want %d", cfg.ConfigVersion, CurrentConfigVersion)
+	}
+	if cfg.API.BaseURL != "https://api.example.com" {
+		t.Errorf("API.BaseURL = %q, want migrated api_url", cfg.API.BaseURL)
+	}
+
+	if _, err := ParseConfig(writeTemp(t, "config_version: 2\n"), ConfigOverrides{}); err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Errorf("ParseConfig(future version) = %v, want version error", err)
+	}
+}
+
+func TestGenerateDefaultConfig_IsCurrent(t *testing.T) {
+	metadata := map[string]string{"env": "prod", "team": `a: "b" #c`}
+	out := packaging.GenerateDefaultConfig("https://api.example.com", "web-1", "", metadata)
+	cfg, err := LoadConfig(writeTemp(t, out), ConfigOverrides{})
+	if err != nil {
+		t.Fatalf("LoadConfig(default config): %v", err)
+	}
+	if cfg.FileVersion() != CurrentConfigVersion {
+		t.Errorf("default config has config_version %d, want %d", cfg.FileVersion(), CurrentConfigVersion)
+	}
+	if cfg.API.BaseURL != "https://api.example.com" {
+		t.Errorf("API.BaseURL = %q", cfg.API.BaseURL)
+	}
+	if cfg.Registration.Hostname != "web-1" || !maps.Equal(cfg.Registration.Metadata, metadata) {
+		t.Errorf("Registration hostname = %q, metadata = %v, want web-1 and %v", cfg.Registration.Hostname, cfg.Registration.Metadata, metadata)
+	}
+}
@@ -0,0 +1,242 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is the prefix of environment variables that override config
+// keys. The variable for a key is EnvPrefix followed by the key in upper
+// case with dots replaced by underscores, e.g. PLEXD_LOG_LEVEL for
+// "log_level" or PLEXD_HEARTBEAT_INTERVAL for "heartbeat.interval".
+const EnvPrefix = "PLEXD_"
+
+// ConfigOverrides holds the override layers applied on top of the config
+// file. The full precedence chain, highest first, is Set, Env, the config
+// file, and defaults.
+type ConfigOverrides struct {
+	// Env is an environment in os.Environ form. Variables named after a
+	// config key (see EnvName) override the file; other variables,
+	// including unrelated PLEXD_* ones, are ignored.
+	Env []string
+
+	// Set holds "key=value" assignments, typically from command-line flags.
+	// They are applied in order after Env, so the last assignment of a key
+	// wins. Unknown keys are an error.
+	Set []string
+}
+
+// EnvName returns the environment variable that overrides key.
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// ConfigKeys returns every settable config key in DiffConfig notation,
+// sorted. config_version is not settable; it is managed by migration.
+func ConfigKeys() []string {
+	var keys []string
+	walkConfigKeys(reflect.TypeOf(AgentConfig{}), "", func(key string) {
+		if key != configVersionKey {
+			keys = append(keys, key)
+		}
+	})
+	sort.Strings(keys)
+	return keys
+}
+
+// SetConfigValue parses value according to the type of key and stores it in
+// cfg. Durations use time.ParseDuration syntax, lists are comma-separated,
+// and maps are comma-separated key=value pairs. An empty value resets the
+// key to its zero value, so that ApplyDefaults fills in the default.
+func SetConfigValue(cfg *AgentConfig, key, value string) error {
+	field, ok := configField(reflect.ValueOf(cfg).Elem(), key)
+	if !ok || key == configVersionKey {
+		return fmt.Errorf("agent: config: unknown key %q", key)
+	}
+	if err := setValue(field, value); err != nil {
+		return fmt.Errorf("agent: config: %s: invalid value %q: %w", key, value, err)
+	}
+	return nil
+}
+
+// apply applies the override layers to cfg. It reports every invalid
+// override, not only the first.
+func (o ConfigOverrides) apply(cfg *AgentConfig) error {
+	var errs []error
+
+	envKeys := make(map[string]string)
+	for _, key := range ConfigKeys() {
+		envKeys[EnvName(key)] = key
+	}
+	var names []string
+	values := make(map[string]string)
+	for _, kv := range o.Env {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+		if _, known := envKeys[name]; !known {
+			continue
+		}
+		if _, seen := values[name]; !seen {
+			names = append(names, name)
+		}
+		values[name] = value
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := SetConfigValue(cfg, envKeys[name], values[name]); err != nil {
+			errs = append(errs, fmt.Errorf("%w (from %s)", err, name))
+		}
+	}
+
+	for _, kv := range o.Set {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			errs = append(errs, fmt.Errorf("agent: config: override %q: want key=value", kv))
+			continue
+		}
+		if err := SetConfigValue(cfg, strings.TrimSpace(key), value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// container reports whether the overrides enable container mode.
+func (o ConfigOverrides) container() bool {
+	var cfg AgentConfig
+	_ = o.apply(&cfg)
+	return cfg.Container
+}
+
+// walkConfigKeys calls fn for every leaf key of the struct type t.
+func walkConfigKeys(t reflect.Type, prefix string, fn func(key string)) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := yamlKey(f)
+		if name == "-" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		if f.Type.Kind() == reflect.Struct && f.Type != timeType {
+			walkConfigKeys(f.Type, key, fn)
+			continue
+		}
+		fn(key)
+	}
+}
+
+// configField returns the leaf field of v addressed by key.
+func configField(v reflect.Value, key string) (reflect.Value, bool) {
+	for _, part := range strings.Split(key, ".") {
+		if v.Kind() != reflect.Struct || v.Type() == timeType {
+			return reflect.Value{}, false
+		}
+		found := false
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.IsExported() && yamlKey(f) == part {
+				v, found = v.Field(i), true
+				break
+			}
+		}
+		if !found {
+			return reflect.Value{}, false
+		}
+	}
+	if v.Kind() == reflect.Struct && v.Type() != timeType {
+		return reflect.Value{}, false
+	}
+	return v, true
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setValue parses s into the field v.
+func setValue(v reflect.Value, s string) error {
+	if s == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case v.Kind() == reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if err := setValue(elem.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("want true or false")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items).Convert(v.Type()))
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		m := make(map[string]string)
+		for _, pair := range strings.Split(s, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			k, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("map entry %q: want key=value", pair)
+			}
+			m[strings.TrimSpace(k)] = strings.TrimSpace(val)
+		}
+		v.Set(reflect.ValueOf(m).Convert(v.Type()))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package agent
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"log_level":                        "PLEXD_LOG_LEVEL",
+		"api.baseurl":                      "PLEXD_API_BASEURL",
+		"node_api.datadir":                 "PLEXD_NODE_API_DATADIR",
+		"bridge.accesssubnets":             "PLEXD_BRIDGE_ACCESSSUBNETS",
+		"peer_exchange.config.stunservers": "PLEXD_PEER_EXCHANGE_CONFIG_STUNSERVERS",
+	}
+	for key, want := range tests {
+		if got := EnvName(key); got != want {
+			t.Errorf("EnvName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestConfigKeys_UniqueEnvNames(t *testing.T) {
+	keys := ConfigKeys()
+	if len(keys) == 0 {
+		t.Fatal("ConfigKeys returned no keys")
+	}
+	seen := make(map[string]string)
+	for _, key := range keys {
+		name := EnvName(key)
+		if other, dup := seen[name]; dup {
+			t.Errorf("keys %q and %q share environment variable %s", other, key, name)
+		}
+		seen[name] = key
+	}
+	for _, want := range []string{"mode", "bridge.ingressenabled", "node_api.socketpath", "heartbeat.interval"} {
+		if _, ok := seen[EnvName(want)]; !ok {
+			t.Errorf("ConfigKeys missing %q", want)
+		}
+	}
+}
+
+func TestSetConfigValue_Types(t *testing.T) {
+	var cfg AgentConfig
+	sets := map[string]string{
+		"log_level":                       "debug",
+		"metrics.enabled":                 "true",
+		"heartbeat.interval":              "15s",
+		"actions.maxoutputbytes":          "4096",
+		"bridge.routefwmarkbase":          "256",
+		"bridge.enablenat":                "false",
+		"nat.stunservers":                 "stun1.example.com:3478, stun2.example.com:3478",
+		"registration.metadata":           "zone=eu-1,rack=r7",
+		"net_mon.ignoreinterfaceprefixes": "",
+	}
+	for key, value := range sets {
+		if err := SetConfigValue(&cfg, key, value); err != nil {
+			t.Fatalf("SetConfigValue(%q, %q): %v", key, value, err)
+		}
+	}
+
+	if cfg.LogLevel != "debug" {
+		t.Errorf("LogLevel = %q", cfg.LogLevel)
+	}
+	if !cfg.Metrics.Enabled {
+		t.Error("Metrics.Enabled = false, want true")
+	}
+	if cfg.Heartbeat.Interval != 15*time.Second {
+		t.Errorf("Heartbeat.Interval = %v", cfg.Heartbeat.Interval)
+	}
+	if cfg.Actions.MaxOutputBytes != 4096 {
+		t.Errorf("Actions.MaxOutputBytes = %d", cfg.Actions.MaxOutputBytes)
+	}
+	if cfg.Bridge.RouteFwMarkBase != 256 {
+		t.Errorf("Bridge.RouteFwMarkBase = %d", cfg.Bridge.RouteFwMarkBase)
+	}
+	if cfg.Bridge.EnableNAT == nil || *cfg.Bridge.EnableNAT {
+		t.Errorf("Bridge.EnableNAT = %v, want pointer to false", cfg.Bridge.EnableNAT)
+	}
+	if want := []string{"stun1.example.com:3478", "stun2.example.com:3478"}; !reflect.DeepEqual(cfg.NAT.STUNServers, want) {
+		t.Errorf("NAT.STUNServers = %v, want %v", cfg.NAT.STUNServers, want)
+	}
+	if want := map[string]string{"zone": "eu-1", "rack": "r7"}; !reflect.DeepEqual(cfg.Registration.Metadata, want) {
+		t.Errorf("Registration.Metadata = %v, want %v", cfg.Registration.Metadata, want)
+	}
+}
+
+func TestSetConfigValue_Errors(t *testing.T) {
+	var cfg AgentConfig
+	tests := []struct {
+		key, value, want string
+	}{
+		{"log_levle", "debug", "unknown key"},
+		{"heartbeat", "30s", "unknown key"},
+		{"heartbeat.interval", "soon", "heartbeat.interval: invalid value"},
+		{"metrics.enabled", "yes please", "want true or false"},
+		{"bridge.routefwmarkbase", "-1", "bridge.routefwmarkbase: invalid value"},
+		{"registration.metadata", "zone", "want key=value"},
+	}
+	for _, tt := range tests {
+		err := SetConfigValue(&cfg, tt.key, tt.value)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("SetConfigValue(%q, %q) = %v, want error containing %q", tt.key, tt.value, err, tt.want)
+		}
+	}
+}
+
+func TestLoadConfig_Precedence(t *testing.T) {
+	path := writeTemp(t, `
+log_level: warn
+api:
+  baseurl: "https://file.example.com"
+heartbeat:
+  interval: 45s
+`)
+	ov := ConfigOverrides{
+		Env: []string{
+			"PLEXD_API_BASEURL=https://env.example.com",
+			"PLEXD_LOG_LEVEL=error",
+			"PLEXD_BRIDGE_INGRESSENABLED=true",
+			"PLEXD_BOOTSTRAP_TOKEN=ignored",
+			"HOME=/root",
+		},
+		Set: []string{"log_level=debug"},
+	}
+
+	cfg, err := LoadConfig(path, ov)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.LogLevel != "debug" {
+		t.Errorf("LogLevel = %q, want flag value %q", cfg.LogLevel, "debug")
+	}
+	if cfg.API.BaseURL != "https://env.example.com" {
+		t.Errorf("API.BaseURL = %q, want env value", cfg.API.BaseURL)
+	}
+	if cfg.Heartbeat.Interval != 45*time.Second {
+		t.Errorf("Heartbeat.Interval = %v, want file value 45s", cfg.Heartbeat.Interval)
+	}
+	if !cfg.Bridge.IngressEnabled {
+		t.Error("Bridge.IngressEnabled = false, want env value true")
+	}
+	if cfg.Mode != DefaultMode {
+		t.Errorf("Mode = %q, want default %q", cfg.Mode, DefaultMode)
+	}
+}
+
+func TestCheckConfig_ReportsInvalidOverrides(t *testing.T) {
+	path := writeTemp(t, `
+api:
+  baseurl: "https://example.com"
+registration:
+  datadir: /tmp/plexd
+node_api:
+  datadir: /tmp/plexd
+heartbeat:
+  nodeid: "node-1"
+`)
+	ov := ConfigOverrides{
+		Env: []string{"PLEXD_HEARTBEAT_INTERVAL=often"},
+		Set: []string{"no_such_key=1", "mode"},
+	}
+
+	_, err := CheckConfig(path, ov)
+	if err == nil {
+		t.Fatal("CheckConfig = nil error, want error")
+	}
+	for _, want := range []string{"PLEXD_HEARTBEAT_INTERVAL", `unknown key "no_such_key"`, `override "mode"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %q, want it to contain %q", err, want)
+		}
+	}
+	if _, err := ParseConfig(path, ov); err == nil {
+		t.Error("ParseConfig with invalid overrides = nil error, want error")
+	}
+}
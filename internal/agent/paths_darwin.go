@@ -0,0 +1,6 @@
+//go:build darwin
+
+package agent
+
+// DefaultDataDir is the default data directory.
+const DefaultDataDir = "/usr/local/var/lib/plexd"
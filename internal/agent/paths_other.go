@@ -0,0 +1,6 @@
+//go:build !windows && !darwin
+
+package agent
+
+// DefaultDataDir is the default data directory.
+const DefaultDataDir = "/var/lib/plexd"
@@ -0,0 +1,6 @@
+//go:build windows
+
+package agent
+
+// DefaultDataDir is the default data directory.
+const DefaultDataDir = `C:\ProgramData\plexd\data`
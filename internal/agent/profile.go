@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/reconcile"
+	"github.com/plexsphere/plexd/internal/registration"
+	"github.com/plexsphere/plexd/internal/wireguard"
+)
+
+// profileNamePattern restricts profile names to lowercase DNS labels so
+// they can be used in paths, URLs, and environment variable names.
+var profileNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,30}[a-z0-9])?$`)
+
+// ProfileConfig configures an additional mesh profile: a second control
+// plane or mesh the node joins alongside the one configured at the top
+// level. Each profile has its own identity, WireGuard interface,
+// reconciler, heartbeat, and node API namespace.
+type ProfileConfig struct {
+	// Name identifies the profile in paths and the node API
+	// (/v1/profiles/{name}/state). Lowercase letters, digits, and hyphens.
+	Name string `yaml:"name"`
+
+	API          api.Config          `yaml:"api"`
+	Registration registration.Config `yaml:"registration"`
+	WireGuard    wireguard.Config    `yaml:"wireguard"`
+	Reconcile    reconcile.Config    `yaml:"reconcile"`
+
+	// Heartbeat holds the heartbeat interval. NodeID is taken from the
+	// profile's registration.
+	Heartbeat HeartbeatConfig `yaml:"heartbeat"`
+
+	// AuthRecovery configures what the profile does when its control plane
+	// no longer accepts the profile's identity. Halting stops only this
+	// profile.
+	AuthRecovery AuthRecoveryConfig `yaml:"auth_recovery"`
+}
+
+// ProfileDataDir returns the data directory of the named profile below
+// dataDir. The profile's identity and state cache live there.
+func ProfileDataDir(dataDir, name string) string {
+	return filepath.Join(dataDir, "profiles", name)
+}
+
+// applyDefaults fills in defaults that keep the profile apart from the
+// top-level mesh: its own data directory, bootstrap token sources, and the
+// index-th WireGuard interface and port after the top-level ones.
+func (p *ProfileConfig) applyDefaults(dataDir string, index int) {
+	if p.Registration.DataDir == "" && p.Name != "" {
+		p.Registration.DataDir = ProfileDataDir(dataDir, p.Name)
+	}
+	if p.Registration.TokenFile == "" {
+		p.Registration.TokenFile = registration.DefaultTokenFile + "-" + p.Name
+	}
+	if p.Registration.TokenEnv == "" {
+		p.Registration.TokenEnv = registration.DefaultTokenEnv + "_" + strings.ToUpper(strings.ReplaceAll(p.Name, "-", "_"))
+	}
+	if p.Registration.MetadataTokenPath == "" {
+		p.Registration.MetadataTokenPath = registration.DefaultMetadataTokenPath + "-" + p.Name
+	}
+	if p.WireGuard.InterfaceName == "" {
+		p.WireGuard.InterfaceName = fmt.Sprintf("plexd%d", index+1)
+	}
+	if p.WireGuard.ListenPort == 0 {
+		p.WireGuard.ListenPort = wireguard.DefaultListenPort + index + 1
+	}
+	p.API.ApplyDefaults()
+	p.Registration.ApplyDefaults()
+	p.WireGuard.ApplyDefaults()
+	p.Reconcile.ApplyDefaults()
+	p.Heartbeat.ApplyDefaults()
+	p.AuthRecovery.ApplyDefaults()
+}
+
+// validate checks the profile on its own. Conflicts between profiles are
+// checked by AgentConfig.validateProfiles.
+func (p *ProfileConfig) validate() error {
+	if !profileNamePattern.MatchString(p.Name) {
+		return fmt.Errorf("invalid name %q (lowercase letters, digits, and hyphens, at most 32 characters)", p.Name)
+	}
+	for _, validate := range []func() error{
+		p.API.Validate,
+		p.Registration.Validate,
+		p.WireGuard.Validate,
+		p.Reconcile.Validate,
+		p.AuthRecovery.Validate,
+	} {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+	if p.Heartbeat.Interval < 0 {
+		return errors.New("heartbeat interval must not be negative")
+	}
+	if p.AuthRecovery.Action == AuthRecoveryReregister && p.Registration.StandingTokenFile == "" {
+		return errors.New("auth_recovery action \"reregister\" requires registration.standingtokenfile")
+	}
+	return nil
+}
+
+// validateProfiles checks every profile and that profiles do not share a
+// name, WireGuard interface, or listen port with each other or with the
+// top-level mesh.
+func (c *AgentConfig) validateProfiles() error {
+	names := make(map[string]bool)
+	ifaces := map[string]string{c.WireGuard.InterfaceName: "the top-level mesh"}
+	ports := map[int]string{c.WireGuard.ListenPort: "the top-level mesh"}
+	for i := range c.Profiles {
+		p := &c.Profiles[i]
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("agent: config: profiles[%d]: %w", i, err)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("agent: config: profiles[%d]: duplicate name %q", i, p.Name)
+		}
+		names[p.Name] = true
+		owner := fmt.Sprintf("profile %q", p.Name)
+		if other, ok := ifaces[p.WireGuard.InterfaceName]; ok {
+			return fmt.Errorf("agent: config: profiles[%d]: wireguard interface %q is already used by %s", i, p.WireGuard.InterfaceName, other)
+		}
+		ifaces[p.WireGuard.InterfaceName] = owner
+		if other, ok := ports[p.WireGuard.ListenPort]; ok {
+			return fmt.Errorf("agent: config: profiles[%d]: wireguard listen port %d is already used by %s", i, p.WireGuard.ListenPort, other)
+		}
+		ports[p.WireGuard.ListenPort] = owner
+	}
+	return nil
+}
+
+// Profile returns the profile with the given name.
+func (c *AgentConfig) Profile(name string) (*ProfileConfig, bool) {
+	for i := range c.Profiles {
+		if c.Profiles[i].Name == name {
+			return &c.Profiles[i], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,159 @@
+package agent
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/registration"
+	"github.com/plexsphere/plexd/internal/wireguard"
+)
+
+func TestParseConfig_Profiles(t *testing.T) {
+	yaml := `
+data_dir: /tmp/plexd
+api:
+  baseurl: "https://prod.example.com"
+registration:
+  datadir: /tmp/plexd
+node_api:
+  datadir: /tmp/plexd
+heartbeat:
+  nodeid: "node-1"
+profiles:
+  - name: staging
+    api:
+      baseurl: "https://staging.example.com"
+  - name: lab-eu
+    api:
+      baseurl: "https://lab.example.com"
+    wireguard:
+      interfacename: wg-lab
+      listenport: 51900
+`
+	cfg, err := ParseConfig(writeTemp(t, yaml), ConfigOverrides{})
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if len(cfg.Profiles) != 2 {
+		t.Fatalf("len(Profiles) = %d, want 2", len(cfg.Profiles))
+	}
+
+	staging, ok := cfg.Profile("staging")
+	if !ok {
+		t.Fatal("Profile(staging) not found")
+	}
+	if want := filepath.Join("/tmp/plexd", "profiles", "staging"); staging.Registration.DataDir != want {
+		t.Errorf("staging DataDir = %q, want %q", staging.Registration.DataDir, want)
+	}
+	if want := registration.DefaultTokenFile + "-staging"; staging.Registration.TokenFile != want {
+		t.Errorf("staging TokenFile = %q, want %q", staging.Registration.TokenFile, want)
+	}
+	if staging.WireGuard.InterfaceName != "plexd1" {
+		t.Errorf("staging InterfaceName = %q, want plexd1", staging.WireGuard.InterfaceName)
+	}
+	if staging.WireGuard.ListenPort != wireguard.DefaultListenPort+1 {
+		t.Errorf("staging ListenPort = %d, want %d", staging.WireGuard.ListenPort, wireguard.DefaultListenPort+1)
+	}
+	if staging.Heartbeat.Interval != DefaultHeartbeatInterval {
+		t.Errorf("staging heartbeat interval = %v, want %v", staging.Heartbeat.Interval, DefaultHeartbeatInterval)
+	}
+
+	lab, _ := cfg.Profile("lab-eu")
+	if lab.Registration.TokenEnv != "PLEXD_BOOTSTRAP_TOKEN_LAB_EU" {
+		t.Errorf("lab-eu TokenEnv = %q, want PLEXD_BOOTSTRAP_TOKEN_LAB_EU", lab.Registration.TokenEnv)
+	}
+	if lab.WireGuard.InterfaceName != "wg-lab" || lab.WireGuard.ListenPort != 51900 {
+		t.Errorf("lab-eu wireguard = %s:%d, want wg-lab:51900", lab.WireGuard.InterfaceName, lab.WireGuard.ListenPort)
+	}
+
+	if _, ok := cfg.Profile("missing"); ok {
+		t.Error("Profile(missing) found")
+	}
+}
+
+func TestAgentConfig_ValidateProfiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		profiles []ProfileConfig
+		wantErr  string
+	}{
+		{
+			name:     "invalid name",
+			profiles: []ProfileConfig{{Name: "Prod_1"}},
+			wantErr:  "invalid name",
+		},
+		{
+			name:     "missing api",
+			profiles: []ProfileConfig{{Name: "staging"}},
+			wantErr:  "BaseURL is required",
+		},
+		{
+			name: "duplicate name",
+			profiles: []ProfileConfig{
+				{Name: "staging", API: apiConfig("https://a.example.com")},
+				{Name: "staging", API: apiConfig("https://b.example.com")},
+			},
+			wantErr: "duplicate name",
+		},
+		{
+			name: "interface of top-level mesh",
+			profiles: []ProfileConfig{
+				{Name: "staging", API: apiConfig("https://a.example.com"), WireGuard: wireguard.Config{InterfaceName: wireguard.DefaultInterfaceName}},
+			},
+			wantErr: "already used by the top-level mesh",
+		},
+		{
+			name: "shared listen port",
+			profiles: []ProfileConfig{
+				{Name: "a", API: apiConfig("https://a.example.com"), WireGuard: wireguard.Config{ListenPort: 52000}},
+				{Name: "b", API: apiConfig("https://b.example.com"), WireGuard: wireguard.Config{ListenPort: 52000}},
+			},
+			wantErr: `already used by profile "a"`,
+		},
+		{
+			name: "valid",
+			profiles: []ProfileConfig{
+				{Name: "a", API: apiConfig("https://a.example.com")},
+				{Name: "b", API: apiConfig("https://b.example.com")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Profiles = tt.profiles
+			cfg.ApplyDefaults()
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMarshalConfig_RedactsProfileSecrets(t *testing.T) {
+	cfg := validConfig()
+	cfg.Profiles = []ProfileConfig{{Name: "staging", API: apiConfig("https://staging.example.com")}}
+	cfg.Profiles[0].Registration.TokenValue = "plx_profile_secret"
+	cfg.ApplyDefaults()
+
+	out, err := MarshalConfig(&cfg, true)
+	if err != nil {
+		t.Fatalf("MarshalConfig: %v", err)
+	}
+	if strings.Contains(string(out), "plx_profile_secret") {
+		t.Errorf("redacted output contains profile token:\n%s", out)
+	}
+}
+
+func apiConfig(baseURL string) api.Config {
+	return api.Config{BaseURL: baseURL}
+}
@@ -0,0 +1,354 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/nodeapi"
+)
+
+// DefaultReloadDebounce is the delay between the first reload trigger and the
+// reload itself. Editors often write a file in several steps; triggers that
+// arrive within the delay are coalesced.
+const DefaultReloadDebounce = 500 * time.Millisecond
+
+// maxPendingReloadAudits bounds the audit entries buffered between Collect calls.
+const maxPendingReloadAudits = 100
+
+// ConfigApplier applies a reloaded configuration to a running subsystem.
+type ConfigApplier func(cfg *AgentConfig) error
+
+// registeredApplier pairs a ConfigApplier with the config keys it handles.
+type registeredApplier struct {
+	key   string
+	apply ConfigApplier
+}
+
+// ConfigReloader reloads the agent configuration at runtime. It re-reads the
+// file through the load function, diffs the result against the running
+// configuration key by key, and hands changed keys to registered appliers.
+// Changed keys without an applier take effect only after a restart; they are
+// reported but never stop a reload. Every attempt is recorded as the last
+// reload status and as an audit entry.
+type ConfigReloader struct {
+	load     func() (*AgentConfig, error)
+	source   string
+	hostname string
+	logger   *slog.Logger
+	debounce time.Duration
+	now      func() time.Time
+
+	appliers []registeredApplier
+	trigger  chan struct{}
+
+	// reloadMu serializes reloads.
+	reloadMu sync.Mutex
+
+	mu      sync.Mutex
+	current *AgentConfig
+	last    *nodeapi.ReloadStatus
+	audits  []api.AuditEntry
+}
+
+// NewConfigReloader creates a ConfigReloader. load returns the configuration
+// as it would be loaded at startup, including flag overrides; current is the
+// configuration the agent is running with. source identifies the
+// configuration (usually its path) in logs and audit entries.
+func NewConfigReloader(load func() (*AgentConfig, error), current *AgentConfig, source string, logger *slog.Logger) *ConfigReloader {
+	hostname, _ := os.Hostname()
+	return &ConfigReloader{
+		load:     load,
+		source:   source,
+		hostname: hostname,
+		logger:   logger.With("component", "config_reload"),
+		debounce: DefaultReloadDebounce,
+		now:      time.Now,
+		trigger:  make(chan struct{}, 1),
+		current:  current,
+	}
+}
+
+// RegisterApplier registers fn for key. key is a config key as reported by
+// DiffConfig ("log_level", "heartbeat.interval") or a section ("heartbeat"),
+// which covers every key in it. fn is called once per reload when any
+// covered key changed. RegisterApplier must be called before Run; it is not
+// safe for concurrent use.
+func (r *ConfigReloader) RegisterApplier(key string, fn ConfigApplier) {
+	r.appliers = append(r.appliers, registeredApplier{key: key, apply: fn})
+}
+
+// TriggerReload requests a reload from the Run loop, for example on SIGHUP
+// or a file change. Multiple calls within the debounce delay are coalesced.
+// Safe for concurrent use.
+func (r *ConfigReloader) TriggerReload() {
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run performs a reload for each (debounced) TriggerReload until ctx is
+// cancelled. Run always returns nil.
+func (r *ConfigReloader) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-r.trigger:
+		}
+
+		timer := time.NewTimer(r.debounce)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		// Drop triggers that arrived during the debounce delay.
+		select {
+		case <-r.trigger:
+		default:
+		}
+
+		r.Reload()
+	}
+}
+
+// Reload re-reads the configuration and applies it synchronously. A config
+// that fails to load or validate is rejected and the running configuration
+// is kept. If an applier fails, the keys applied before it stay in effect,
+// the reload is reported as failed, and the running configuration is not
+// replaced, so the next reload retries the remaining changes. Safe for
+// concurrent use.
+func (r *ConfigReloader) Reload() nodeapi.ReloadStatus {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	status := nodeapi.ReloadStatus{
+		Time:            r.now(),
+		Changed:         []string{},
+		Applied:         []string{},
+		RestartRequired: []string{},
+	}
+
+	next, err := r.load()
+	if err != nil {
+		status.Error = err.Error()
+		r.finish(status)
+		return status
+	}
+
+	r.mu.Lock()
+	current := r.current
+	r.mu.Unlock()
+
+	status.Changed = DiffConfig(current, next)
+
+	var pending []registeredApplier
+	for _, key := range status.Changed {
+		a, ok := r.applierFor(key)
+		if !ok {
+			status.RestartRequired = append(status.RestartRequired, key)
+			continue
+		}
+		if !containsApplier(pending, a.key) {
+			pending = append(pending, a)
+		}
+	}
+
+	for _, a := range pending {
+		if err := a.apply(next); err != nil {
+			status.Error = fmt.Sprintf("apply %s: %v", a.key, err)
+			r.finish(status)
+			return status
+		}
+		for _, key := range status.Changed {
+			if keyCovers(a.key, key) {
+				status.Applied = append(status.Applied, key)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	r.current = next
+	r.mu.Unlock()
+
+	status.Success = true
+	r.finish(status)
+	return status
+}
+
+// Current returns the configuration the agent is running with: the one
+// passed to NewConfigReloader, or the last one reloaded successfully. It
+// must not be modified. Safe for concurrent use.
+func (r *ConfigReloader) Current() *AgentConfig {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// LastReload returns the outcome of the most recent reload, or nil if none
+// has been attempted. Safe for concurrent use.
+func (r *ConfigReloader) LastReload() *nodeapi.ReloadStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.last == nil {
+		return nil
+	}
+	last := *r.last
+	return &last
+}
+
+// Collect returns and clears the audit entries recorded since the last call.
+// It implements auditfwd.AuditSource.
+func (r *ConfigReloader) Collect(_ context.Context) ([]api.AuditEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := r.audits
+	r.audits = nil
+	return entries, nil
+}
+
+// finish records status as the last reload, logs it, and queues an audit entry.
+func (r *ConfigReloader) finish(status nodeapi.ReloadStatus) {
+	result := "success"
+	if status.Success {
+		r.logger.Info("config reloaded",
+			"source", r.source,
+			"changed", status.Changed,
+			"applied", status.Applied,
+		)
+		if len(status.RestartRequired) > 0 {
+			r.logger.Warn("config changes require a restart",
+				"source", r.source,
+				"keys", status.RestartRequired,
+			)
+		}
+	} else {
+		result = "failure"
+		r.logger.Error("config reload failed",
+			"source", r.source,
+			"error", status.Error,
+		)
+	}
+
+	subject, _ := json.Marshal(map[string]string{"source": r.source})
+	object, _ := json.Marshal(status)
+	entry := api.AuditEntry{
+		Timestamp: status.Time,
+		Source:    "plexd",
+		EventType: "config_reload",
+		Subject:   subject,
+		Object:    object,
+		Action:    "reload",
+		Result:    result,
+		Hostname:  r.hostname,
+		Raw:       fmt.Sprintf("config reload %s: %s", result, r.source),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.last = &status
+	r.audits = append(r.audits, entry)
+	if over := len(r.audits) - maxPendingReloadAudits; over > 0 {
+		r.audits = r.audits[over:]
+	}
+}
+
+// applierFor returns the applier covering key. When several match, the most
+// specific (longest) registration wins.
+func (r *ConfigReloader) applierFor(key string) (registeredApplier, bool) {
+	var best registeredApplier
+	found := false
+	for _, a := range r.appliers {
+		if keyCovers(a.key, key) && (!found || len(a.key) > len(best.key)) {
+			best, found = a, true
+		}
+	}
+	return best, found
+}
+
+// keyCovers reports whether the registration key prefix covers key.
+func keyCovers(prefix, key string) bool {
+	return key == prefix || strings.HasPrefix(key, prefix+".")
+}
+
+func containsApplier(list []registeredApplier, key string) bool {
+	for _, a := range list {
+		if a.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// DiffConfig returns the sorted keys whose values differ between old and next.
+// Keys use the YAML names of the config file, joined with dots for nested
+// sections, e.g. "log_level" or "reconcile.interval". Slices and maps are
+// compared as a whole; nil and empty are equal.
+func DiffConfig(old, next *AgentConfig) []string {
+	changed := []string{}
+	diffStruct(reflect.ValueOf(old).Elem(), reflect.ValueOf(next).Elem(), "", &changed)
+	sort.Strings(changed)
+	return changed
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func diffStruct(a, b reflect.Value, prefix string, changed *[]string) {
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := yamlKey(f)
+		if name == "-" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		av, bv := a.Field(i), b.Field(i)
+		if f.Type.Kind() == reflect.Struct && f.Type != timeType {
+			diffStruct(av, bv, key, changed)
+			continue
+		}
+		if !valuesEqual(av, bv) {
+			*changed = append(*changed, key)
+		}
+	}
+}
+
+// valuesEqual compares two config values. Nil and empty slices or maps are
+// equal: the config file cannot tell them apart.
+func valuesEqual(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Slice, reflect.Map:
+		if a.Len() == 0 && b.Len() == 0 {
+			return true
+		}
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// yamlKey returns the key yaml.v3 uses for f: the tag name if set, otherwise
+// the lowercased field name.
+func yamlKey(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "" {
+		name = strings.ToLower(f.Name)
+	}
+	return name
+}
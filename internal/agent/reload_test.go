@@ -0,0 +1,262 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestReloader returns a reloader whose load function returns the config
+// built by next, starting from validConfig().
+func newTestReloader(t *testing.T, next func(cfg *AgentConfig) error) *ConfigReloader {
+	t.Helper()
+	current := validConfig()
+	load := func() (*AgentConfig, error) {
+		cfg := validConfig()
+		if err := next(&cfg); err != nil {
+			return nil, err
+		}
+		return &cfg, nil
+	}
+	return NewConfigReloader(load, &current, "/etc/plexd/config.yaml", testLogger())
+}
+
+func TestDiffConfig(t *testing.T) {
+	old := validConfig()
+	next := validConfig()
+	next.LogLevel = "debug"
+	next.Heartbeat.Interval = time.Minute
+	next.Metrics.Enabled = !old.Metrics.Enabled
+	next.NetMon.IgnoreInterfacePrefixes = []string{"lo"}
+
+	got := DiffConfig(&old, &next)
+	want := []string{"heartbeat.interval", "log_level", "metrics.enabled", "net_mon.ignoreinterfaceprefixes"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffConfig = %v, want %v", got, want)
+	}
+
+	if got := DiffConfig(&old, &old); len(got) != 0 {
+		t.Errorf("DiffConfig(same) = %v, want empty", got)
+	}
+}
+
+func TestConfigReloader_AppliesAndReportsRestartRequired(t *testing.T) {
+	r := newTestReloader(t, func(cfg *AgentConfig) error {
+		cfg.LogLevel = "debug"
+		cfg.Heartbeat.Interval = time.Minute
+		cfg.Metrics.Enabled = !cfg.Metrics.Enabled
+		return nil
+	})
+
+	var gotLevel string
+	var hbCalls int
+	r.RegisterApplier("log_level", func(cfg *AgentConfig) error {
+		gotLevel = cfg.LogLevel
+		return nil
+	})
+	r.RegisterApplier("heartbeat", func(cfg *AgentConfig) error {
+		hbCalls++
+		return nil
+	})
+
+	status := r.Reload()
+	if !status.Success {
+		t.Fatalf("Reload failed: %s", status.Error)
+	}
+	if gotLevel != "debug" {
+		t.Errorf("applied log level = %q, want %q", gotLevel, "debug")
+	}
+	if hbCalls != 1 {
+		t.Errorf("heartbeat applier calls = %d, want 1", hbCalls)
+	}
+	if want := []string{"heartbeat.interval", "log_level"}; !reflect.DeepEqual(status.Applied, want) {
+		t.Errorf("Applied = %v, want %v", status.Applied, want)
+	}
+	if want := []string{"metrics.enabled"}; !reflect.DeepEqual(status.RestartRequired, want) {
+		t.Errorf("RestartRequired = %v, want %v", status.RestartRequired, want)
+	}
+
+	// A second reload of the same file changes nothing.
+	status = r.Reload()
+	if !status.Success || len(status.Changed) != 0 {
+		t.Errorf("second Reload = %+v, want success without changes", status)
+	}
+	if hbCalls != 1 {
+		t.Errorf("heartbeat applier calls after no-op reload = %d, want 1", hbCalls)
+	}
+}
+
+func TestConfigReloader_MostSpecificApplierWins(t *testing.T) {
+	r := newTestReloader(t, func(cfg *AgentConfig) error {
+		cfg.Reconcile.Interval = 2 * time.Minute
+		return nil
+	})
+
+	var section, field int
+	r.RegisterApplier("reconcile", func(*AgentConfig) error { section++; return nil })
+	r.RegisterApplier("reconcile.interval", func(*AgentConfig) error { field++; return nil })
+
+	if status := r.Reload(); !status.Success {
+		t.Fatalf("Reload failed: %s", status.Error)
+	}
+	if section != 0 || field != 1 {
+		t.Errorf("section calls = %d, field calls = %d; want 0, 1", section, field)
+	}
+}
+
+func TestConfigReloader_LoadErrorKeepsRunningConfig(t *testing.T) {
+	fail := true
+	r := newTestReloader(t, func(cfg *AgentConfig) error {
+		if fail {
+			return errors.New("agent: config: invalid mode \"edge\"")
+		}
+		cfg.LogLevel = "warn"
+		return nil
+	})
+	var applied int
+	r.RegisterApplier("log_level", func(*AgentConfig) error { applied++; return nil })
+
+	status := r.Reload()
+	if status.Success || status.Error == "" {
+		t.Fatalf("Reload = %+v, want failure", status)
+	}
+	if applied != 0 {
+		t.Errorf("applier called %d times on load error", applied)
+	}
+
+	fail = false
+	status = r.Reload()
+	if !status.Success || !reflect.DeepEqual(status.Applied, []string{"log_level"}) {
+		t.Errorf("Reload after fix = %+v, want log_level applied", status)
+	}
+}
+
+func TestConfigReloader_ApplierErrorRetriesOnNextReload(t *testing.T) {
+	r := newTestReloader(t, func(cfg *AgentConfig) error {
+		cfg.LogLevel = "debug"
+		return nil
+	})
+	var calls int
+	r.RegisterApplier("log_level", func(*AgentConfig) error {
+		calls++
+		if calls == 1 {
+			return errors.New("boom")
+		}
+		return nil
+	})
+
+	status := r.Reload()
+	if status.Success || status.Error != "apply log_level: boom" {
+		t.Fatalf("Reload = %+v, want apply error", status)
+	}
+
+	status = r.Reload()
+	if !status.Success || !reflect.DeepEqual(status.Changed, []string{"log_level"}) {
+		t.Errorf("retry Reload = %+v, want log_level changed and applied", status)
+	}
+}
+
+func TestConfigReloader_Current(t *testing.T) {
+	fail := false
+	r := newTestReloader(t, func(cfg *AgentConfig) error {
+		if fail {
+			return errors.New("parse error")
+		}
+		cfg.LogLevel = "debug"
+		return nil
+	})
+	if got := r.Current().LogLevel; got != "info" {
+		t.Fatalf("Current().LogLevel = %q before reload, want info", got)
+	}
+	r.Reload()
+	if got := r.Current().LogLevel; got != "debug" {
+		t.Errorf("Current().LogLevel = %q after reload, want debug", got)
+	}
+	fail = true
+	r.Reload()
+	if got := r.Current().LogLevel; got != "debug" {
+		t.Errorf("Current().LogLevel = %q after failed reload, want debug", got)
+	}
+}
+
+func TestConfigReloader_LastReloadAndAudit(t *testing.T) {
+	r := newTestReloader(t, func(cfg *AgentConfig) error {
+		cfg.LogLevel = "debug"
+		return nil
+	})
+
+	if r.LastReload() != nil {
+		t.Fatal("LastReload before any reload should be nil")
+	}
+
+	r.Reload()
+
+	last := r.LastReload()
+	if last == nil || !last.Success {
+		t.Fatalf("LastReload = %+v, want success", last)
+	}
+
+	entries, err := r.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("audit entries = %d, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Source != "plexd" || e.EventType != "config_reload" || e.Action != "reload" || e.Result != "success" {
+		t.Errorf("audit entry = %+v", e)
+	}
+	var subject map[string]string
+	if err := json.Unmarshal(e.Subject, &subject); err != nil || subject["source"] != "/etc/plexd/config.yaml" {
+		t.Errorf("subject = %s, want source path", e.Subject)
+	}
+
+	if entries, _ := r.Collect(context.Background()); len(entries) != 0 {
+		t.Errorf("second Collect returned %d entries, want 0", len(entries))
+	}
+}
+
+func TestConfigReloader_RunDebouncesTriggers(t *testing.T) {
+	var loads atomic.Int32
+	current := validConfig()
+	r := NewConfigReloader(func() (*AgentConfig, error) {
+		loads.Add(1)
+		cfg := validConfig()
+		return &cfg, nil
+	}, &current, "test", testLogger())
+	r.debounce = 50 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+
+	for i := 0; i < 5; i++ {
+		r.TriggerReload()
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	deadline := time.After(2 * time.Second)
+	for r.LastReload() == nil {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for reload")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	cancel()
+	<-done
+
+	if n := loads.Load(); n != 1 {
+		t.Errorf("loads = %d, want 1", n)
+	}
+}
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// SDNotifier sends service state notifications to systemd over the datagram
+// socket named by NOTIFY_SOCKET (see sd_notify(3)). When the agent does not
+// run under a service manager that sets NOTIFY_SOCKET, Notify is a no-op.
+type SDNotifier struct {
+	socket string
+}
+
+// NewSDNotifier creates an SDNotifier for the socket in NOTIFY_SOCKET.
+func NewSDNotifier() *SDNotifier {
+	return &SDNotifier{socket: os.Getenv("NOTIFY_SOCKET")}
+}
+
+// Enabled reports whether a notification socket is configured.
+func (n *SDNotifier) Enabled() bool {
+	return n.socket != ""
+}
+
+// Notify sends state, e.g. "READY=1" or "WATCHDOG=1". Socket names starting
+// with "@" are abstract sockets.
+func (n *SDNotifier) Notify(state string) error {
+	if n.socket == "" {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: n.socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("agent: sd_notify: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("agent: sd_notify: %w", err)
+	}
+	return nil
+}
+
+// WatchdogTimeout returns the watchdog timeout systemd enforces on this
+// process (WATCHDOG_USEC), or 0 when the watchdog is disabled or meant for
+// another process (WATCHDOG_PID).
+func WatchdogTimeout() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
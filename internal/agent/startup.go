@@ -0,0 +1,553 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/pprof"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/nodeapi"
+)
+
+// DefaultStartupReadyTimeout is how long a subsystem may take to become
+// ready by default.
+const DefaultStartupReadyTimeout = time.Minute
+
+// DefaultRequiredSubsystems are the subsystems plexd up requires by
+// default: the ones whose liveness the watchdog checks.
+var DefaultRequiredSubsystems = []string{"sse", "reconciler", "nodeapi"}
+
+// startupPollInterval is how often the readiness of a starting subsystem is
+// checked.
+const startupPollInterval = 100 * time.Millisecond
+
+// StartupConfig holds the configuration of the startup Orchestrator.
+type StartupConfig struct {
+	// Required lists the subsystems that must start and become ready within
+	// ReadyTimeout. If one fails, startup fails and the agent exits. All
+	// other subsystems are best-effort: a failure is logged and the agent
+	// runs without them. An empty list makes every subsystem best-effort.
+	// Default: sse, reconciler, nodeapi
+	Required []string
+
+	// ReadyTimeout is how long a subsystem may take to become ready. A
+	// best-effort subsystem that is not ready in time no longer holds up
+	// the subsystems that depend on it.
+	// Default: 1m
+	ReadyTimeout time.Duration
+}
+
+// ApplyDefaults sets default values for zero-valued fields.
+func (c *StartupConfig) ApplyDefaults() {
+	if c.Required == nil {
+		c.Required = slices.Clone(DefaultRequiredSubsystems)
+	}
+	if c.ReadyTimeout == 0 {
+		c.ReadyTimeout = DefaultStartupReadyTimeout
+	}
+}
+
+// Validate checks that the values are acceptable.
+func (c *StartupConfig) Validate() error {
+	if c.ReadyTimeout < 0 {
+		return errors.New("agent: startup config: ReadyTimeout must not be negative")
+	}
+	for i, name := range c.Required {
+		if name == "" {
+			return fmt.Errorf("agent: startup config: Required[%d] is empty", i)
+		}
+		if slices.Contains(c.Required[:i], name) {
+			return fmt.Errorf("agent: startup config: subsystem %q is required more than once", name)
+		}
+	}
+	return nil
+}
+
+// Subsystem is a long-running part of the agent started by an Orchestrator.
+type Subsystem struct {
+	// Name identifies the subsystem in StartupConfig.Required, DependsOn,
+	// readiness, and liveness, e.g. "reconciler".
+	Name string
+
+	// DependsOn lists the subsystems that must be ready before this one
+	// starts.
+	DependsOn []string
+
+	// Run runs the subsystem until ctx is cancelled. Returning before ctx
+	// is cancelled means the subsystem failed.
+	Run func(ctx context.Context) error
+
+	// Ready reports whether the subsystem has finished starting. If nil,
+	// the subsystem is ready once Run was called.
+	Ready func() bool
+
+	// Check is registered with the Watchdog once the subsystem is ready,
+	// or once it ran for ReadyTimeout without becoming ready. Optional.
+	Check LivenessCheck
+
+	// Restartable allows Orchestrator.Restart to cancel the context of Run
+	// and call Run again. Run must then release everything it started
+	// before it returns.
+	Restartable bool
+}
+
+// Startup states of a subsystem.
+const (
+	subsystemWaiting  = "waiting"
+	subsystemStarting = "starting"
+	subsystemReady    = "ready"
+	subsystemFailed   = "failed"
+	subsystemSkipped  = "skipped"
+)
+
+// subsystemState tracks the startup of one subsystem.
+type subsystemState struct {
+	Subsystem
+	required bool
+
+	// settled is closed once dependents may start or have to be skipped:
+	// when the subsystem is ready, failed, was skipped, or is not ready
+	// within the ready timeout.
+	settled chan struct{}
+
+	// state and err are guarded by Orchestrator.mu.
+	state string
+	err   error
+
+	// cancel cancels the context of the current Run, and runDone is
+	// closed once it returned. restarting is set by Restart until Run is
+	// called again. All three are guarded by Orchestrator.mu.
+	cancel     context.CancelFunc
+	runDone    chan struct{}
+	restarting bool
+}
+
+// Orchestrator starts the agent's subsystems in dependency order and
+// reports their readiness. A subsystem starts once every subsystem it
+// depends on is ready; independent subsystems start concurrently.
+// Subsystems listed in StartupConfig.Required must start for startup to
+// succeed; the others are best-effort, and the subsystems that depend on a
+// failed best-effort subsystem are skipped.
+type Orchestrator struct {
+	cfg      StartupConfig
+	watchdog *Watchdog
+	logger   *slog.Logger
+	poll     time.Duration
+	now      func() time.Time
+	onPanic  func(subsystem string, v any)
+
+	wg sync.WaitGroup
+
+	mu         sync.Mutex
+	subsystems []*subsystemState
+	byName     map[string]*subsystemState
+	started    bool
+	finished   bool
+}
+
+// NewOrchestrator creates an Orchestrator. Ready subsystems register their
+// liveness checks with watchdog, and watchdog.Ready is called once startup
+// has finished. Config defaults are applied automatically.
+func NewOrchestrator(cfg StartupConfig, watchdog *Watchdog, logger *slog.Logger) *Orchestrator {
+	cfg.ApplyDefaults()
+	return &Orchestrator{
+		cfg:      cfg,
+		watchdog: watchdog,
+		logger:   logger.With("component", "startup"),
+		poll:     startupPollInterval,
+		now:      time.Now,
+		byName:   make(map[string]*subsystemState),
+	}
+}
+
+// SetPanicHandler sets a function that is called with the value of a panic
+// in the Run function of a subsystem, before the panic continues and
+// crashes the agent. It must be called before Start.
+func (o *Orchestrator) SetPanicHandler(fn func(subsystem string, v any)) {
+	o.onPanic = fn
+}
+
+// Add registers a subsystem. It must be called before Start.
+func (o *Orchestrator) Add(s Subsystem) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.subsystems = append(o.subsystems, &subsystemState{
+		Subsystem: s,
+		required:  slices.Contains(o.cfg.Required, s.Name),
+		settled:   make(chan struct{}),
+		state:     subsystemWaiting,
+	})
+}
+
+// Start starts every subsystem in dependency order and blocks until each
+// has settled. It returns an error as soon as a required subsystem fails,
+// is skipped, or is not ready within the ready timeout; the caller should
+// then cancel ctx and Wait. It also returns an error when the subsystems
+// cannot be ordered: a duplicate or unknown name, or a dependency cycle.
+// On success it calls Watchdog.Ready. If ctx is cancelled first, Start
+// returns ctx.Err().
+func (o *Orchestrator) Start(ctx context.Context) error {
+	order, err := o.order()
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(order))
+	for i, s := range order {
+		names[i] = s.Name
+	}
+	o.logger.Info("starting subsystems", "order", strings.Join(names, ","), "required", strings.Join(o.cfg.Required, ","))
+
+	settled := make(chan *subsystemState, len(order))
+	for _, s := range order {
+		o.wg.Add(1)
+		go o.start(ctx, s, settled)
+	}
+
+	start := o.now()
+	for range order {
+		var s *subsystemState
+		select {
+		case s = <-settled:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if !s.required {
+			continue
+		}
+		o.mu.Lock()
+		state, serr := s.state, s.err
+		o.mu.Unlock()
+		switch state {
+		case subsystemReady:
+		case subsystemFailed, subsystemSkipped:
+			return fmt.Errorf("agent: startup: required subsystem %s %s: %w", s.Name, state, serr)
+		default:
+			return fmt.Errorf("agent: startup: required subsystem %s not ready after %s", s.Name, o.cfg.ReadyTimeout)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	o.finished = true
+	o.mu.Unlock()
+	o.logger.Info("startup finished", "duration", o.now().Sub(start).Round(time.Millisecond))
+	o.watchdog.Ready()
+	return nil
+}
+
+// Wait blocks until every started subsystem has returned from Run.
+func (o *Orchestrator) Wait() {
+	o.wg.Wait()
+}
+
+// order validates the subsystems and returns them in dependency order.
+// Subsystems without an ordering constraint keep the order they were added
+// in.
+func (o *Orchestrator) order() ([]*subsystemState, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.started {
+		return nil, errors.New("agent: startup: already started")
+	}
+	o.started = true
+
+	for _, s := range o.subsystems {
+		if _, ok := o.byName[s.Name]; ok {
+			return nil, fmt.Errorf("agent: startup: subsystem %q added more than once", s.Name)
+		}
+		o.byName[s.Name] = s
+	}
+	for _, name := range o.cfg.Required {
+		if _, ok := o.byName[name]; !ok {
+			return nil, fmt.Errorf("agent: startup: required subsystem %q does not exist or is disabled", name)
+		}
+	}
+	for _, s := range o.subsystems {
+		for _, dep := range s.DependsOn {
+			if _, ok := o.byName[dep]; !ok {
+				return nil, fmt.Errorf("agent: startup: subsystem %q depends on unknown subsystem %q", s.Name, dep)
+			}
+		}
+	}
+
+	order := make([]*subsystemState, 0, len(o.subsystems))
+	placed := make(map[string]bool, len(o.subsystems))
+	for len(order) < len(o.subsystems) {
+		progress := false
+		for _, s := range o.subsystems {
+			if placed[s.Name] || !allPlaced(s.DependsOn, placed) {
+				continue
+			}
+			order = append(order, s)
+			placed[s.Name] = true
+			progress = true
+		}
+		if !progress {
+			var cycle []string
+			for _, s := range o.subsystems {
+				if !placed[s.Name] {
+					cycle = append(cycle, s.Name)
+				}
+			}
+			return nil, fmt.Errorf("agent: startup: dependency cycle between %s", strings.Join(cycle, ", "))
+		}
+	}
+	return order, nil
+}
+
+func allPlaced(names []string, placed map[string]bool) bool {
+	for _, name := range names {
+		if !placed[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// start waits for the dependencies of s, runs it, and waits until it is
+// ready. It reports s on settled once dependents may proceed.
+func (o *Orchestrator) start(ctx context.Context, s *subsystemState, settled chan<- *subsystemState) {
+	defer o.wg.Done()
+	defer func() {
+		close(s.settled)
+		settled <- s
+	}()
+
+	for _, name := range s.DependsOn {
+		dep := o.byName[name]
+		select {
+		case <-dep.settled:
+		case <-ctx.Done():
+			o.setState(s, subsystemSkipped, ctx.Err())
+			return
+		}
+		o.mu.Lock()
+		state := dep.state
+		o.mu.Unlock()
+		if state == subsystemFailed || state == subsystemSkipped {
+			o.setState(s, subsystemSkipped, fmt.Errorf("dependency %s %s", name, state))
+			o.logFailure(s, "subsystem skipped")
+			return
+		}
+	}
+
+	o.setState(s, subsystemStarting, nil)
+	o.logger.Debug("starting subsystem", "subsystem", s.Name)
+	started := o.now()
+	exited := make(chan struct{})
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		if o.onPanic != nil {
+			defer func() {
+				if v := recover(); v != nil {
+					o.onPanic(s.Name, v)
+					panic(v)
+				}
+			}()
+		}
+		err := o.run(ctx, s)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = errors.New("stopped")
+		}
+		o.setState(s, subsystemFailed, err)
+		o.logFailure(s, "subsystem failed")
+		close(exited)
+	}()
+
+	timeout := time.NewTimer(o.cfg.ReadyTimeout)
+	defer timeout.Stop()
+	ticker := time.NewTicker(o.poll)
+	defer ticker.Stop()
+	for s.Ready != nil && !s.Ready() {
+		select {
+		case <-exited:
+			return
+		case <-ctx.Done():
+			return
+		case <-timeout.C:
+			o.logger.Warn("subsystem not ready in time",
+				"subsystem", s.Name,
+				"required", s.required,
+				"timeout", o.cfg.ReadyTimeout,
+			)
+			o.addCheck(s)
+			return
+		case <-ticker.C:
+		}
+	}
+
+	o.mu.Lock()
+	ok := s.state == subsystemStarting
+	if ok {
+		s.state = subsystemReady
+	}
+	o.mu.Unlock()
+	if !ok {
+		return
+	}
+	o.addCheck(s)
+	o.logger.Info("subsystem ready", "subsystem", s.Name, "duration", o.now().Sub(started).Round(time.Millisecond))
+}
+
+// run calls s.Run with the subsystem label set (see SubsystemLabel), and
+// calls it again each time it returned because of Restart. It returns the
+// result of the last call.
+func (o *Orchestrator) run(ctx context.Context, s *subsystemState) error {
+	labels := pprof.Labels(SubsystemLabel, s.Name)
+	for {
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		o.mu.Lock()
+		s.cancel, s.runDone, s.restarting = cancel, done, false
+		o.mu.Unlock()
+
+		var err error
+		pprof.Do(runCtx, labels, func(ctx context.Context) {
+			err = s.Run(ctx)
+		})
+		cancel()
+		close(done)
+
+		o.mu.Lock()
+		restart := s.restarting
+		o.mu.Unlock()
+		if !restart || ctx.Err() != nil {
+			return err
+		}
+		o.logger.Info("restarting subsystem", "subsystem", s.Name)
+	}
+}
+
+// Restart stops a running subsystem by cancelling the context of its Run,
+// and runs it again once Run returned. It waits up to timeout for Run to
+// return and fails if it did not; Run is then called again whenever it
+// returns. Only ready subsystems marked Restartable can be restarted. Safe
+// for concurrent use.
+func (o *Orchestrator) Restart(name string, timeout time.Duration) error {
+	o.mu.Lock()
+	s, ok := o.byName[name]
+	switch {
+	case !ok:
+		o.mu.Unlock()
+		return fmt.Errorf("agent: startup: unknown subsystem %q", name)
+	case !s.Restartable:
+		o.mu.Unlock()
+		return fmt.Errorf("agent: startup: subsystem %s is not restartable", name)
+	case s.state != subsystemReady:
+		o.mu.Unlock()
+		return fmt.Errorf("agent: startup: subsystem %s is %s", name, s.state)
+	case s.cancel == nil:
+		o.mu.Unlock()
+		return fmt.Errorf("agent: startup: subsystem %s is not running yet", name)
+	}
+	s.restarting = true
+	s.cancel()
+	done := s.runDone
+	o.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("agent: startup: subsystem %s did not stop within %s", name, timeout)
+	}
+}
+
+// Restartable reports whether the subsystem name is marked Restartable
+// and ready. Safe for concurrent use.
+func (o *Orchestrator) Restartable(name string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	s, ok := o.byName[name]
+	return ok && s.Restartable && s.state == subsystemReady
+}
+
+func (o *Orchestrator) setState(s *subsystemState, state string, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	s.state, s.err = state, err
+}
+
+func (o *Orchestrator) addCheck(s *subsystemState) {
+	if s.Check != nil {
+		o.watchdog.AddCheck(s.Name, s.Check)
+	}
+}
+
+// logFailure logs a failed or skipped subsystem: as an error if it is
+// required and as a warning if it is best-effort.
+func (o *Orchestrator) logFailure(s *subsystemState, msg string) {
+	o.mu.Lock()
+	err := s.err
+	o.mu.Unlock()
+	level := slog.LevelWarn
+	if s.required {
+		level = slog.LevelError
+	}
+	o.logger.Log(context.Background(), level, msg, "subsystem", s.Name, "required", s.required, "error", err)
+}
+
+// Readiness reports "starting" with the startup state of every subsystem
+// until startup has finished. After that it reports the Watchdog's
+// readiness, followed by the best-effort subsystems that are not ready;
+// those do not affect the status. Safe for concurrent use.
+func (o *Orchestrator) Readiness() nodeapi.ReadinessStatus {
+	o.mu.Lock()
+	finished := o.finished
+	subs := make([]nodeapi.SubsystemLiveness, 0, len(o.subsystems))
+	for _, s := range o.subsystems {
+		if finished && (s.required || s.state == subsystemReady) {
+			continue
+		}
+		subs = append(subs, nodeapi.SubsystemLiveness{
+			Name:   s.Name,
+			Alive:  s.state == subsystemReady,
+			Detail: o.detailLocked(s),
+		})
+	}
+	o.mu.Unlock()
+
+	if !finished {
+		return nodeapi.ReadinessStatus{Status: "starting", Time: o.now(), Subsystems: subs}
+	}
+	status := o.watchdog.Readiness()
+	status.Subsystems = append(status.Subsystems, subs...)
+	return status
+}
+
+// detailLocked describes the startup state of s. Callers must hold o.mu.
+func (o *Orchestrator) detailLocked(s *subsystemState) string {
+	switch s.state {
+	case subsystemWaiting:
+		var pending []string
+		for _, name := range s.DependsOn {
+			if dep, ok := o.byName[name]; !ok || dep.state != subsystemReady {
+				pending = append(pending, name)
+			}
+		}
+		if len(pending) > 0 {
+			return "waiting for " + strings.Join(pending, ", ")
+		}
+		return subsystemWaiting
+	case subsystemFailed, subsystemSkipped:
+		if !s.required {
+			return fmt.Sprintf("best-effort, %s: %v", s.state, s.err)
+		}
+		return fmt.Sprintf("%s: %v", s.state, s.err)
+	default:
+		return s.state
+	}
+}
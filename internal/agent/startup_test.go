@@ -0,0 +1,332 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"runtime/pprof"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestOrchestrator returns an Orchestrator that polls readiness every
+// millisecond, its notifier, and a context that is cancelled, and whose
+// subsystems are waited for, when the test ends.
+func newTestOrchestrator(t *testing.T, cfg StartupConfig) (*Orchestrator, *mockNotifier, context.Context) {
+	t.Helper()
+	n := &mockNotifier{}
+	o := NewOrchestrator(cfg, NewWatchdog(n, 0, testLogger()), testLogger())
+	o.poll = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		o.Wait()
+	})
+	return o, n, ctx
+}
+
+// blockingRun runs until ctx is cancelled.
+func blockingRun(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestOrchestrator_StartsInDependencyOrder(t *testing.T) {
+	o, n, ctx := newTestOrchestrator(t, StartupConfig{Required: []string{"nodeapi"}})
+
+	var (
+		mu      sync.Mutex
+		started []string
+	)
+	record := func(name string) func(context.Context) error {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			started = append(started, name)
+			mu.Unlock()
+			return blockingRun(ctx)
+		}
+	}
+	// A subsystem is ready once its Run was entered, so that the start
+	// order is deterministic.
+	running := func(name string) func() bool {
+		return func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return slices.Contains(started, name)
+		}
+	}
+	var meshReady atomic.Bool
+	// Added in reverse order: the dependencies decide the order.
+	o.Add(Subsystem{Name: "collector", DependsOn: []string{"nodeapi"}, Run: record("collector"), Ready: running("collector")})
+	o.Add(Subsystem{
+		Name:      "nodeapi",
+		DependsOn: []string{"mesh"},
+		Run:       record("nodeapi"),
+		Ready:     running("nodeapi"),
+		Check:     func() (string, error) { return "serving", nil },
+	})
+	o.Add(Subsystem{Name: "mesh", Run: record("mesh"), Ready: func() bool { return meshReady.Load() && running("mesh")() }})
+
+	done := make(chan error, 1)
+	go func() { done <- o.Start(ctx) }()
+
+	// nodeapi waits until mesh is ready.
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		status := o.Readiness()
+		if status.Status != "starting" {
+			t.Fatalf("Status = %q before mesh is ready, want starting", status.Status)
+		}
+		if len(status.Subsystems) == 3 && status.Subsystems[2].Detail == subsystemStarting {
+			if got := status.Subsystems[1].Detail; got != "waiting for mesh" {
+				t.Errorf("nodeapi Detail = %q, want waiting for mesh", got)
+			}
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	if !slices.Equal(started, []string{"mesh"}) {
+		t.Errorf("started before mesh is ready = %v, want [mesh]", started)
+	}
+	mu.Unlock()
+
+	meshReady.Store(true)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Start did not return")
+	}
+	mu.Lock()
+	if !slices.Equal(started, []string{"mesh", "nodeapi", "collector"}) {
+		t.Errorf("start order = %v, want [mesh nodeapi collector]", started)
+	}
+	mu.Unlock()
+
+	if n.count("READY=1") != 1 {
+		t.Error("READY=1 not sent after startup")
+	}
+	status := o.Readiness()
+	if status.Status != "ready" || len(status.Subsystems) != 1 || status.Subsystems[0].Name != "nodeapi" {
+		t.Errorf("Readiness() = %+v, want ready with the nodeapi check", status)
+	}
+}
+
+func TestOrchestrator_RequiredFailure(t *testing.T) {
+	o, n, ctx := newTestOrchestrator(t, StartupConfig{Required: []string{"mesh", "nodeapi"}})
+	o.Add(Subsystem{Name: "mesh", Run: func(context.Context) error { return errors.New("no interface") }, Ready: func() bool { return false }})
+	o.Add(Subsystem{Name: "nodeapi", DependsOn: []string{"mesh"}, Run: blockingRun})
+
+	err := o.Start(ctx)
+	if err == nil || !strings.Contains(err.Error(), "mesh failed: no interface") {
+		t.Fatalf("Start = %v, want mesh failure", err)
+	}
+	if n.count("READY=1") != 0 {
+		t.Error("READY=1 sent although startup failed")
+	}
+	if status := o.Readiness(); status.Status != "starting" {
+		t.Errorf("Status = %q, want starting", status.Status)
+	}
+}
+
+func TestOrchestrator_BestEffortFailure(t *testing.T) {
+	o, _, ctx := newTestOrchestrator(t, StartupConfig{Required: []string{"nodeapi"}})
+	var collectorRan atomic.Bool
+	o.Add(Subsystem{Name: "nodeapi", Run: blockingRun})
+	o.Add(Subsystem{Name: "audit_fwd", DependsOn: []string{"nodeapi"}, Run: func(context.Context) error { return errors.New("spool unavailable") }, Ready: func() bool { return false }})
+	o.Add(Subsystem{Name: "collector", DependsOn: []string{"audit_fwd"}, Run: func(ctx context.Context) error {
+		collectorRan.Store(true)
+		return blockingRun(ctx)
+	}})
+
+	if err := o.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if collectorRan.Load() {
+		t.Error("collector ran although its dependency failed")
+	}
+	status := o.Readiness()
+	if status.Status != "ready" {
+		t.Errorf("Status = %q, want ready", status.Status)
+	}
+	want := map[string]string{
+		"audit_fwd": "best-effort, failed: spool unavailable",
+		"collector": "best-effort, skipped: dependency audit_fwd failed",
+	}
+	if len(status.Subsystems) != len(want) {
+		t.Fatalf("Subsystems = %+v, want %d entries", status.Subsystems, len(want))
+	}
+	for _, s := range status.Subsystems {
+		if s.Alive || s.Detail != want[s.Name] {
+			t.Errorf("subsystem %s = %+v, want not alive with detail %q", s.Name, s, want[s.Name])
+		}
+	}
+}
+
+func TestOrchestrator_ReadyTimeout(t *testing.T) {
+	o, _, ctx := newTestOrchestrator(t, StartupConfig{Required: []string{"nodeapi"}, ReadyTimeout: 20 * time.Millisecond})
+	never := func() bool { return false }
+	o.Add(Subsystem{Name: "mesh", Run: blockingRun, Ready: never})
+	o.Add(Subsystem{Name: "nodeapi", DependsOn: []string{"mesh"}, Run: blockingRun, Ready: never})
+
+	// mesh is best-effort, so nodeapi starts once mesh timed out, but
+	// nodeapi is required and fails startup when it times out itself.
+	err := o.Start(ctx)
+	if err == nil || !strings.Contains(err.Error(), "nodeapi not ready after 20ms") {
+		t.Fatalf("Start = %v, want nodeapi timeout", err)
+	}
+}
+
+func TestOrchestrator_CancelledDuringStartup(t *testing.T) {
+	o, _, _ := newTestOrchestrator(t, StartupConfig{Required: []string{}})
+	o.Add(Subsystem{Name: "mesh", Run: blockingRun, Ready: func() bool { return false }})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := o.Start(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Start = %v, want context.Canceled", err)
+	}
+	o.Wait()
+}
+
+func TestOrchestrator_InvalidSubsystems(t *testing.T) {
+	tests := []struct {
+		name       string
+		required   []string
+		subsystems []Subsystem
+		want       string
+	}{
+		{
+			name:       "duplicate",
+			subsystems: []Subsystem{{Name: "mesh"}, {Name: "mesh"}},
+			want:       `subsystem "mesh" added more than once`,
+		},
+		{
+			name:       "unknown dependency",
+			subsystems: []Subsystem{{Name: "nodeapi", DependsOn: []string{"mesh"}}},
+			want:       `subsystem "nodeapi" depends on unknown subsystem "mesh"`,
+		},
+		{
+			name:       "unknown required",
+			required:   []string{"bridge"},
+			subsystems: []Subsystem{{Name: "mesh"}},
+			want:       `required subsystem "bridge" does not exist`,
+		},
+		{
+			name: "cycle",
+			subsystems: []Subsystem{
+				{Name: "mesh"},
+				{Name: "a", DependsOn: []string{"b"}},
+				{Name: "b", DependsOn: []string{"a", "mesh"}},
+			},
+			want: "dependency cycle between a, b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			required := tt.required
+			if required == nil {
+				required = []string{}
+			}
+			o, _, ctx := newTestOrchestrator(t, StartupConfig{Required: required})
+			for _, s := range tt.subsystems {
+				s.Run = blockingRun
+				o.Add(s)
+			}
+			if err := o.Start(ctx); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Start = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_Restart(t *testing.T) {
+	o, _, ctx := newTestOrchestrator(t, StartupConfig{Required: []string{}})
+	var (
+		runs   atomic.Int32
+		labels = make(chan string, 2)
+	)
+	o.Add(Subsystem{Name: "netmon", Restartable: true, Run: func(ctx context.Context) error {
+		runs.Add(1)
+		label, _ := pprof.Label(ctx, SubsystemLabel)
+		labels <- label
+		return blockingRun(ctx)
+	}})
+	o.Add(Subsystem{Name: "reload", Run: blockingRun})
+
+	if err := o.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	first := <-labels
+	if first != "netmon" {
+		t.Errorf("label %s = %q, want netmon", SubsystemLabel, first)
+	}
+	if !o.Restartable("netmon") || o.Restartable("reload") || o.Restartable("bridge") {
+		t.Error("Restartable: want only netmon")
+	}
+	if err := o.Restart("netmon", time.Second); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	select {
+	case label := <-labels:
+		if label != "netmon" {
+			t.Errorf("label %s after restart = %q, want netmon", SubsystemLabel, label)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Run called %d times, want 2", runs.Load())
+	}
+	if status := o.Readiness(); status.Status != "ready" {
+		t.Errorf("Status after restart = %q, want ready", status.Status)
+	}
+
+	if err := o.Restart("reload", time.Second); err == nil || !strings.Contains(err.Error(), "not restartable") {
+		t.Errorf("Restart(reload) = %v, want not restartable", err)
+	}
+	if err := o.Restart("bridge", time.Second); err == nil || !strings.Contains(err.Error(), "unknown subsystem") {
+		t.Errorf("Restart(bridge) = %v, want unknown subsystem", err)
+	}
+}
+
+func TestOrchestrator_RestartTimeout(t *testing.T) {
+	o, _, ctx := newTestOrchestrator(t, StartupConfig{Required: []string{}})
+	release := make(chan struct{})
+	running := make(chan struct{}, 2)
+	o.Add(Subsystem{Name: "peer_health", Restartable: true, Run: func(ctx context.Context) error {
+		running <- struct{}{}
+		<-ctx.Done()
+		<-release
+		return nil
+	}})
+	if err := o.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	<-running
+	err := o.Restart("peer_health", 20*time.Millisecond)
+	close(release)
+	if err == nil || !strings.Contains(err.Error(), "did not stop within 20ms") {
+		t.Errorf("Restart = %v, want timeout", err)
+	}
+}
+
+func TestStartupConfig_Validate(t *testing.T) {
+	var cfg StartupConfig
+	cfg.ApplyDefaults()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate defaults: %v", err)
+	}
+	cfg.ReadyTimeout = -time.Second
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate with negative ReadyTimeout = nil, want error")
+	}
+	cfg = StartupConfig{Required: []string{"nodeapi", ""}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate with empty subsystem name = nil, want error")
+	}
+}
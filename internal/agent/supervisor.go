@@ -0,0 +1,461 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"runtime/metrics"
+	"runtime/pprof"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/nodeapi"
+)
+
+// SubsystemLabel is the profiler label the Orchestrator sets on the
+// goroutines of a subsystem; goroutines started by them inherit it. The
+// Supervisor counts goroutines by it, and it shows in goroutine profiles.
+const SubsystemLabel = "subsystem"
+
+const (
+	// DefaultSupervisorInterval is how often the Supervisor samples by
+	// default.
+	DefaultSupervisorInterval = 10 * time.Second
+
+	// DefaultMaxGoroutines is the default goroutine budget of a subsystem.
+	DefaultMaxGoroutines = 10000
+
+	// DefaultStallTimeout is how long the liveness check of a subsystem may
+	// fail by default before the subsystem is restarted. It is shorter than
+	// the WatchdogSec of the systemd unit (60s), so that the subsystem is
+	// restarted before systemd restarts the agent.
+	DefaultStallTimeout = 20 * time.Second
+
+	// DefaultMaxRestarts is the default number of restarts of a subsystem
+	// within the restart window.
+	DefaultMaxRestarts = 3
+
+	// DefaultRestartWindow is the default restart window.
+	DefaultRestartWindow = time.Hour
+)
+
+// restartTimeout is how long the Supervisor waits for a subsystem to stop.
+const restartTimeout = 10 * time.Second
+
+// Restart reasons.
+const (
+	RestartGoroutines = "goroutines"
+	RestartOpenFDs    = "open_fds"
+	RestartHeap       = "heap"
+	RestartStalled    = "stalled"
+)
+
+// SupervisorConfig holds the configuration of the Supervisor.
+type SupervisorConfig struct {
+	// Enabled turns on the Supervisor.
+	// Default: true (set by ApplyDefaults).
+	Enabled bool
+
+	// Interval is how often goroutines, open file descriptors, heap, and
+	// liveness are sampled. Must be at least 1s.
+	// Default: 10s
+	Interval time.Duration
+
+	// MaxGoroutines is the goroutine budget of each subsystem.
+	// Default: 10000
+	MaxGoroutines int
+
+	// Goroutines sets the goroutine budget of single subsystems, e.g.
+	// {"nodeapi": 20000}, overriding MaxGoroutines.
+	Goroutines map[string]int
+
+	// MaxOpenFDs is the budget of open file descriptors of the agent; 0
+	// means no budget. Only enforced on Linux and macOS.
+	// Default: 0
+	MaxOpenFDs int
+
+	// MaxHeapBytes is the budget of the heap of the agent in bytes; 0
+	// means no budget.
+	// Default: 0
+	MaxHeapBytes int64
+
+	// StallTimeout is how long the liveness check of a subsystem may fail
+	// before the subsystem is restarted. Keep it below the WatchdogSec of
+	// the systemd unit.
+	// Default: 20s
+	StallTimeout time.Duration
+
+	// MaxRestarts is how often a subsystem is restarted within
+	// RestartWindow. Once reached, the subsystem is left as it is, and a
+	// stalled one makes the watchdog restart the agent.
+	// Default: 3
+	MaxRestarts int
+
+	// RestartWindow is the period MaxRestarts applies to; restarts within
+	// it are reported in heartbeats.
+	// Default: 1h
+	RestartWindow time.Duration
+}
+
+// ApplyDefaults sets default values for zero-valued fields.
+// On a zero-valued SupervisorConfig, Enabled defaults to true; if any
+// field is set, Enabled is respected as-is.
+func (c *SupervisorConfig) ApplyDefaults() {
+	if c.Interval == 0 && c.MaxGoroutines == 0 && c.Goroutines == nil && c.MaxOpenFDs == 0 &&
+		c.MaxHeapBytes == 0 && c.StallTimeout == 0 && c.MaxRestarts == 0 && c.RestartWindow == 0 {
+		c.Enabled = true
+	}
+	if c.Interval == 0 {
+		c.Interval = DefaultSupervisorInterval
+	}
+	if c.MaxGoroutines == 0 {
+		c.MaxGoroutines = DefaultMaxGoroutines
+	}
+	if c.StallTimeout == 0 {
+		c.StallTimeout = DefaultStallTimeout
+	}
+	if c.MaxRestarts == 0 {
+		c.MaxRestarts = DefaultMaxRestarts
+	}
+	if c.RestartWindow == 0 {
+		c.RestartWindow = DefaultRestartWindow
+	}
+}
+
+// Validate checks that the values are acceptable.
+func (c *SupervisorConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Interval < time.Second {
+		return errors.New("agent: supervisor config: Interval must be at least 1s")
+	}
+	if c.MaxGoroutines < 1 {
+		return errors.New("agent: supervisor config: MaxGoroutines must be positive")
+	}
+	for name, n := range c.Goroutines {
+		if n < 1 {
+			return fmt.Errorf("agent: supervisor config: Goroutines[%s] must be positive", name)
+		}
+	}
+	if c.MaxOpenFDs < 0 {
+		return errors.New("agent: supervisor config: MaxOpenFDs must not be negative")
+	}
+	if c.MaxHeapBytes < 0 {
+		return errors.New("agent: supervisor config: MaxHeapBytes must not be negative")
+	}
+	if c.StallTimeout < c.Interval {
+		return errors.New("agent: supervisor config: StallTimeout must be at least Interval")
+	}
+	if c.MaxRestarts < 1 {
+		return errors.New("agent: supervisor config: MaxRestarts must be positive")
+	}
+	if c.RestartWindow < c.Interval {
+		return errors.New("agent: supervisor config: RestartWindow must be at least Interval")
+	}
+	return nil
+}
+
+// SubsystemRestarter restarts the subsystems of the agent.
+// *Orchestrator satisfies this interface.
+type SubsystemRestarter interface {
+	Restartable(name string) bool
+	Restart(name string, timeout time.Duration) error
+}
+
+// LivenessSource reports the liveness of the subsystems.
+// *Watchdog satisfies this interface.
+type LivenessSource interface {
+	Liveness() nodeapi.LivenessStatus
+}
+
+// Supervisor keeps one misbehaving subsystem from taking down the agent. It
+// samples the goroutines of each subsystem, the open file descriptors and
+// heap of the agent, and the liveness checks, and restarts a restartable
+// subsystem that exceeds its goroutine budget or whose check keeps failing.
+// The file descriptors and heap cannot be attributed to a subsystem; when
+// they exceed their budget, the subsystem whose goroutines grew the most
+// since it started is restarted. Restarts are reported in heartbeats.
+type Supervisor struct {
+	cfg        SupervisorConfig
+	subsystems SubsystemRestarter
+	liveness   LivenessSource
+	logger     *slog.Logger
+	now        func() time.Time
+
+	// Sampling functions, replaced in tests.
+	goroutines func() (map[string]int, error)
+	openFDs    func() (int, error)
+	heapBytes  func() int64
+
+	// baseline holds the goroutines of each subsystem at the first sample
+	// after it (re)started; stallSince when its check started failing;
+	// exhausted the subsystems whose restarts ran out, logged once. They
+	// are only accessed by the Run goroutine.
+	baseline   map[string]int
+	stallSince map[string]time.Time
+	exhausted  map[string]bool
+	overBudget bool
+
+	mu       sync.Mutex
+	restarts []api.SubsystemRestart
+}
+
+// NewSupervisor creates a Supervisor that restarts the subsystems of
+// restarter, and watches the liveness checks of liveness. Config defaults
+// are applied automatically.
+func NewSupervisor(cfg SupervisorConfig, restarter SubsystemRestarter, liveness LivenessSource, logger *slog.Logger) *Supervisor {
+	cfg.ApplyDefaults()
+	return &Supervisor{
+		cfg:        cfg,
+		subsystems: restarter,
+		liveness:   liveness,
+		logger:     logger.With("component", "supervisor"),
+		now:        time.Now,
+		goroutines: countGoroutines,
+		openFDs:    countOpenFDs,
+		heapBytes:  readHeapBytes,
+		baseline:   make(map[string]int),
+		stallSince: make(map[string]time.Time),
+		exhausted:  make(map[string]bool),
+	}
+}
+
+// Run samples every Interval until ctx is cancelled. It always returns nil.
+func (s *Supervisor) Run(ctx context.Context) error {
+	s.logger.Info("supervisor started",
+		"interval", s.cfg.Interval,
+		"max_goroutines", s.cfg.MaxGoroutines,
+		"max_open_fds", s.cfg.MaxOpenFDs,
+		"max_heap_bytes", s.cfg.MaxHeapBytes,
+	)
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			s.sample()
+		}
+	}
+}
+
+// SubsystemRestarts returns the restarts within the restart window, oldest
+// first. Safe for concurrent use.
+func (s *Supervisor) SubsystemRestarts() []api.SubsystemRestart {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	if len(s.restarts) == 0 {
+		return nil
+	}
+	return slices.Clone(s.restarts)
+}
+
+// sample checks every budget and liveness check once and restarts at most
+// one subsystem per reason.
+func (s *Supervisor) sample() {
+	counts, err := s.goroutines()
+	if err != nil {
+		s.logger.Warn("counting goroutines failed", "error", err)
+	}
+	for name, n := range counts {
+		if _, ok := s.baseline[name]; !ok {
+			s.baseline[name] = n
+		}
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if budget := s.budget(name); counts[name] > budget {
+			s.restart(name, RestartGoroutines, fmt.Sprintf("%d goroutines, budget %d", counts[name], budget))
+		}
+	}
+
+	s.checkLiveness()
+	s.checkProcess(counts)
+}
+
+// budget returns the goroutine budget of the subsystem name.
+func (s *Supervisor) budget(name string) int {
+	if n, ok := s.cfg.Goroutines[name]; ok {
+		return n
+	}
+	return s.cfg.MaxGoroutines
+}
+
+// checkLiveness restarts the subsystems whose liveness check has been
+// failing for StallTimeout.
+func (s *Supervisor) checkLiveness() {
+	if s.liveness == nil {
+		return
+	}
+	now := s.now()
+	for _, sub := range s.liveness.Liveness().Subsystems {
+		if sub.Alive {
+			delete(s.stallSince, sub.Name)
+			continue
+		}
+		since, ok := s.stallSince[sub.Name]
+		if !ok {
+			s.stallSince[sub.Name] = now
+			continue
+		}
+		if stalled := now.Sub(since); stalled >= s.cfg.StallTimeout {
+			s.restart(sub.Name, RestartStalled, fmt.Sprintf("%s (stalled for %s)", sub.Detail, stalled.Round(time.Second)))
+		}
+	}
+}
+
+// checkProcess enforces the budgets of open file descriptors and heap.
+func (s *Supervisor) checkProcess(counts map[string]int) {
+	var reason, detail string
+	if s.cfg.MaxOpenFDs > 0 {
+		n, err := s.openFDs()
+		if err == nil && n > s.cfg.MaxOpenFDs {
+			reason, detail = RestartOpenFDs, fmt.Sprintf("%d open file descriptors, budget %d", n, s.cfg.MaxOpenFDs)
+		}
+	}
+	if reason == "" && s.cfg.MaxHeapBytes > 0 {
+		if n := s.heapBytes(); n > s.cfg.MaxHeapBytes {
+			reason, detail = RestartHeap, fmt.Sprintf("%d heap bytes, budget %d", n, s.cfg.MaxHeapBytes)
+		}
+	}
+	if reason == "" {
+		s.overBudget = false
+		return
+	}
+
+	// Blame the restartable subsystem whose goroutines grew the most.
+	var suspect string
+	growth := 0
+	for name, n := range counts {
+		if g := n - s.baseline[name]; g > growth && s.subsystems.Restartable(name) {
+			suspect, growth = name, g
+		}
+	}
+	if suspect == "" {
+		if !s.overBudget {
+			s.logger.Warn("agent over budget, no subsystem to restart", "reason", reason, "detail", detail)
+		}
+		s.overBudget = true
+		return
+	}
+	s.overBudget = false
+	s.restart(suspect, reason, fmt.Sprintf("%s; %d goroutines more than at start", detail, growth))
+}
+
+// restart restarts the subsystem name unless it is not restartable or ran
+// out of restarts within the restart window.
+func (s *Supervisor) restart(name, reason, detail string) {
+	if !s.subsystems.Restartable(name) {
+		return
+	}
+	s.mu.Lock()
+	s.pruneLocked()
+	count := 0
+	for _, r := range s.restarts {
+		if r.Subsystem == name {
+			count++
+		}
+	}
+	s.mu.Unlock()
+	if count >= s.cfg.MaxRestarts {
+		if !s.exhausted[name] {
+			s.logger.Error("subsystem over budget, but out of restarts",
+				"subsystem", name,
+				"reason", reason,
+				"detail", detail,
+				"max_restarts", s.cfg.MaxRestarts,
+				"window", s.cfg.RestartWindow,
+			)
+			s.exhausted[name] = true
+		}
+		return
+	}
+	delete(s.exhausted, name)
+
+	s.logger.Warn("restarting subsystem", "subsystem", name, "reason", reason, "detail", detail)
+	rec := api.SubsystemRestart{Subsystem: name, Reason: reason, Detail: detail, Time: s.now().UTC()}
+	if err := s.subsystems.Restart(name, restartTimeout); err != nil {
+		s.logger.Error("subsystem restart failed", "subsystem", name, "error", err)
+		rec.Error = err.Error()
+	}
+	delete(s.baseline, name)
+	delete(s.stallSince, name)
+
+	s.mu.Lock()
+	s.restarts = append(s.restarts, rec)
+	s.mu.Unlock()
+}
+
+// pruneLocked drops the restarts older than the restart window. Callers
+// must hold s.mu.
+func (s *Supervisor) pruneLocked() {
+	cutoff := s.now().Add(-s.cfg.RestartWindow)
+	i := 0
+	for i < len(s.restarts) && s.restarts[i].Time.Before(cutoff) {
+		i++
+	}
+	s.restarts = s.restarts[i:]
+}
+
+// subsystemLabelRE matches the subsystem label in a goroutine profile.
+var subsystemLabelRE = regexp.MustCompile(`"` + SubsystemLabel + `":"([^"]*)"`)
+
+// countGoroutines returns the goroutines of each subsystem, by the
+// subsystem label, from the goroutine profile.
+func countGoroutines() (map[string]int, error) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return nil, fmt.Errorf("agent: supervisor: goroutine profile: %w", err)
+	}
+	return parseGoroutineProfile(&buf), nil
+}
+
+// parseGoroutineProfile counts the goroutines of each subsystem in a
+// goroutine profile written with debug=1: each stack is a line
+// "<count> @ <pcs>", optionally followed by "# labels: {...}".
+func parseGoroutineProfile(buf *bytes.Buffer) map[string]int {
+	counts := make(map[string]int)
+	sc := bufio.NewScanner(buf)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	n := 0
+	for sc.Scan() {
+		line := sc.Text()
+		if count, _, ok := strings.Cut(line, " @ "); ok {
+			n, _ = strconv.Atoi(count)
+			continue
+		}
+		if strings.HasPrefix(line, "# labels: ") {
+			if m := subsystemLabelRE.FindStringSubmatch(line); m != nil {
+				counts[m[1]] += n
+			}
+			n = 0
+		}
+	}
+	return counts
+}
+
+// readHeapBytes returns the bytes of live and not yet swept heap objects.
+func readHeapBytes() int64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return int64(sample[0].Value.Uint64())
+}
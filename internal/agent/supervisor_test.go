@@ -0,0 +1,270 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime/pprof"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/nodeapi"
+)
+
+// fakeRestarter records restarts of the subsystems in restartable.
+type fakeRestarter struct {
+	restartable []string
+	err         error
+
+	mu        sync.Mutex
+	restarted []string
+}
+
+func (f *fakeRestarter) Restartable(name string) bool {
+	return slices.Contains(f.restartable, name)
+}
+
+func (f *fakeRestarter) Restart(name string, _ time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.restarted = append(f.restarted, name)
+	return f.err
+}
+
+func (f *fakeRestarter) Restarted() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.restarted)
+}
+
+// fakeLiveness returns status.
+type fakeLiveness struct {
+	status nodeapi.LivenessStatus
+}
+
+func (f *fakeLiveness) Liveness() nodeapi.LivenessStatus {
+	return f.status
+}
+
+// newTestSupervisor returns a Supervisor with a fake clock at a fixed time,
+// whose goroutine counts are returned by *counts.
+func newTestSupervisor(cfg SupervisorConfig, r SubsystemRestarter, l LivenessSource, counts *map[string]int) (*Supervisor, *time.Time) {
+	s := NewSupervisor(cfg, r, l, testLogger())
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	s.goroutines = func() (map[string]int, error) { return *counts, nil }
+	s.openFDs = func() (int, error) { return 0, errors.ErrUnsupported }
+	s.heapBytes = func() int64 { return 0 }
+	return s, &now
+}
+
+func TestSupervisor_GoroutineBudget(t *testing.T) {
+	r := &fakeRestarter{restartable: []string{"netmon", "peer_health"}}
+	counts := map[string]int{"netmon": 12, "peer_health": 90, "nodeapi": 500}
+	s, _ := newTestSupervisor(SupervisorConfig{
+		MaxGoroutines: 100,
+		Goroutines:    map[string]int{"peer_health": 50},
+	}, r, nil, &counts)
+
+	s.sample()
+	// nodeapi exceeds the budget too, but is not restartable.
+	if got := r.Restarted(); !slices.Equal(got, []string{"peer_health"}) {
+		t.Fatalf("restarted = %v, want [peer_health]", got)
+	}
+	restarts := s.SubsystemRestarts()
+	if len(restarts) != 1 {
+		t.Fatalf("SubsystemRestarts = %+v, want 1 entry", restarts)
+	}
+	if r := restarts[0]; r.Subsystem != "peer_health" || r.Reason != RestartGoroutines || r.Detail != "90 goroutines, budget 50" || r.Error != "" {
+		t.Errorf("restart = %+v", r)
+	}
+}
+
+func TestSupervisor_Stalled(t *testing.T) {
+	r := &fakeRestarter{restartable: []string{"reconciler"}}
+	l := &fakeLiveness{status: nodeapi.LivenessStatus{Subsystems: []nodeapi.SubsystemLiveness{
+		{Name: "reconciler", Alive: false, Detail: "no cycle for 3m0s"},
+		{Name: "sse", Alive: true, Detail: "connected"},
+	}}}
+	counts := map[string]int{}
+	s, now := newTestSupervisor(SupervisorConfig{StallTimeout: 20 * time.Second}, r, l, &counts)
+
+	s.sample()
+	*now = now.Add(10 * time.Second)
+	s.sample()
+	if got := r.Restarted(); len(got) != 0 {
+		t.Fatalf("restarted after 10s = %v, want none", got)
+	}
+	*now = now.Add(10 * time.Second)
+	s.sample()
+	if got := r.Restarted(); !slices.Equal(got, []string{"reconciler"}) {
+		t.Fatalf("restarted after 20s = %v, want [reconciler]", got)
+	}
+	if got := s.SubsystemRestarts()[0]; got.Reason != RestartStalled || got.Detail != "no cycle for 3m0s (stalled for 20s)" {
+		t.Errorf("restart = %+v", got)
+	}
+
+	// Recovering resets the stall timer.
+	l.status.Subsystems[0].Alive = true
+	s.sample()
+	l.status.Subsystems[0].Alive = false
+	*now = now.Add(time.Minute)
+	s.sample()
+	if got := r.Restarted(); len(got) != 1 {
+		t.Errorf("restarted = %v, want no restart right after a new stall", got)
+	}
+}
+
+func TestSupervisor_RestartLimit(t *testing.T) {
+	r := &fakeRestarter{restartable: []string{"netmon"}, err: errors.New("subsystem netmon did not stop within 10s")}
+	counts := map[string]int{"netmon": 200}
+	s, now := newTestSupervisor(SupervisorConfig{
+		MaxGoroutines: 100,
+		MaxRestarts:   2,
+		RestartWindow: time.Hour,
+	}, r, nil, &counts)
+
+	for range 4 {
+		s.sample()
+		*now = now.Add(time.Minute)
+	}
+	if got := r.Restarted(); len(got) != 2 {
+		t.Fatalf("restarted = %v, want 2 restarts", got)
+	}
+	restarts := s.SubsystemRestarts()
+	if len(restarts) != 2 || restarts[0].Error == "" {
+		t.Fatalf("SubsystemRestarts = %+v, want 2 entries with errors", restarts)
+	}
+
+	// Restarts leave the window after an hour.
+	*now = now.Add(time.Hour)
+	if got := s.SubsystemRestarts(); got != nil {
+		t.Errorf("SubsystemRestarts after window = %+v, want nil", got)
+	}
+	s.sample()
+	if got := r.Restarted(); len(got) != 3 {
+		t.Errorf("restarted = %v, want 3 restarts", got)
+	}
+}
+
+func TestSupervisor_ProcessBudget(t *testing.T) {
+	r := &fakeRestarter{restartable: []string{"mesh_diag", "secret_sync"}}
+	counts := map[string]int{"mesh_diag": 5, "secret_sync": 5, "nodeapi": 10}
+	s, _ := newTestSupervisor(SupervisorConfig{MaxOpenFDs: 100, MaxHeapBytes: 1 << 30}, r, nil, &counts)
+	fds := 50
+	s.openFDs = func() (int, error) { return fds, nil }
+
+	s.sample()
+	fds = 150
+	counts = map[string]int{"mesh_diag": 40, "secret_sync": 6, "nodeapi": 400}
+	s.sample()
+	// nodeapi grew the most, but is not restartable.
+	if got := r.Restarted(); !slices.Equal(got, []string{"mesh_diag"}) {
+		t.Fatalf("restarted = %v, want [mesh_diag]", got)
+	}
+	got := s.SubsystemRestarts()[0]
+	if got.Reason != RestartOpenFDs || got.Detail != "150 open file descriptors, budget 100; 35 goroutines more than at start" {
+		t.Errorf("restart = %+v", got)
+	}
+
+	// Over the heap budget, secret_sync grew the most since its start; on
+	// the next sample no subsystem grew, and nothing is restarted.
+	fds = 50
+	s.heapBytes = func() int64 { return 2 << 30 }
+	counts = map[string]int{"mesh_diag": 1, "secret_sync": 6, "nodeapi": 400}
+	s.sample()
+	s.sample()
+	if got := r.Restarted(); len(got) != 2 || got[1] != "secret_sync" {
+		t.Fatalf("restarted = %v, want [mesh_diag secret_sync]", got)
+	}
+	if got := s.SubsystemRestarts()[1]; got.Reason != RestartHeap {
+		t.Errorf("reason = %q, want %q", got.Reason, RestartHeap)
+	}
+}
+
+func TestCountGoroutines(t *testing.T) {
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	pprof.Do(context.Background(), pprof.Labels(SubsystemLabel, "test_supervisor"), func(context.Context) {
+		for range 3 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-stop
+			}()
+		}
+	})
+	defer wg.Wait()
+	defer close(stop)
+
+	counts, err := countGoroutines()
+	if err != nil {
+		t.Fatalf("countGoroutines: %v", err)
+	}
+	if counts["test_supervisor"] != 3 {
+		t.Errorf("counts = %v, want 3 goroutines of test_supervisor", counts)
+	}
+}
+
+func TestParseGoroutineProfile(t *testing.T) {
+	profile := strings.Join([]string{
+		"goroutine profile: total 9",
+		"4 @ 0x1 0x2",
+		`# labels: {"subsystem":"sse"}`,
+		"#\t0x1\tmain.a+0x1\t/src/a.go:1",
+		"",
+		"3 @ 0x1 0x3",
+		"#\t0x1\tmain.b+0x1\t/src/b.go:1",
+		"",
+		"2 @ 0x4",
+		`# labels: {"peer":"p1", "subsystem":"sse"}`,
+		"",
+	}, "\n")
+	counts := parseGoroutineProfile(bytes.NewBufferString(profile))
+	if fmt.Sprint(counts) != "map[sse:6]" {
+		t.Errorf("counts = %v, want map[sse:6]", counts)
+	}
+}
+
+func TestSupervisorConfig_Validate(t *testing.T) {
+	var cfg SupervisorConfig
+	cfg.ApplyDefaults()
+	if !cfg.Enabled {
+		t.Error("Enabled = false after ApplyDefaults on zero config")
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate defaults: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*SupervisorConfig)
+		want   string
+	}{
+		{"interval", func(c *SupervisorConfig) { c.Interval = time.Millisecond }, "Interval must be at least 1s"},
+		{"goroutines", func(c *SupervisorConfig) { c.Goroutines = map[string]int{"sse": 0} }, "Goroutines[sse] must be positive"},
+		{"open fds", func(c *SupervisorConfig) { c.MaxOpenFDs = -1 }, "MaxOpenFDs must not be negative"},
+		{"stall timeout", func(c *SupervisorConfig) { c.StallTimeout = time.Second }, "StallTimeout must be at least Interval"},
+		{"restarts", func(c *SupervisorConfig) { c.MaxRestarts = -1 }, "MaxRestarts must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := SupervisorConfig{Enabled: true}
+			c.ApplyDefaults()
+			tt.modify(&c)
+			if err := c.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	// A disabled supervisor is not validated.
+	cfg = SupervisorConfig{Enabled: false, Interval: time.Millisecond}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate disabled = %v, want nil", err)
+	}
+}
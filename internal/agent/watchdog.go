@@ -0,0 +1,205 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/nodeapi"
+)
+
+// Notifier sends service manager notifications.
+// *SDNotifier satisfies this interface.
+type Notifier interface {
+	Notify(state string) error
+}
+
+// LivenessCheck reports whether a subsystem is making progress. It returns a
+// short description of the subsystem state, and an error when the subsystem
+// has stopped or stalled.
+type LivenessCheck func() (detail string, err error)
+
+// namedCheck pairs a LivenessCheck with the subsystem it covers.
+type namedCheck struct {
+	name  string
+	check LivenessCheck
+}
+
+// Watchdog derives agent liveness from subsystem checks and sends systemd
+// watchdog keepalives while every subsystem is alive. When a subsystem
+// stalls, keepalives stop and systemd restarts the agent once its watchdog
+// timeout expires. Liveness is independent of the control plane heartbeat,
+// so an unreachable control plane does not restart the agent.
+type Watchdog struct {
+	notifier Notifier
+	timeout  time.Duration
+	logger   *slog.Logger
+	now      func() time.Time
+
+	mu      sync.Mutex
+	checks  []namedCheck
+	stalled bool
+	ready   bool
+}
+
+// NewWatchdog creates a Watchdog. timeout is the systemd watchdog timeout
+// (see WatchdogTimeout); keepalives are sent at half of it. A zero timeout
+// disables keepalives, while Liveness keeps working.
+func NewWatchdog(notifier Notifier, timeout time.Duration, logger *slog.Logger) *Watchdog {
+	return &Watchdog{
+		notifier: notifier,
+		timeout:  timeout,
+		logger:   logger.With("component", "watchdog"),
+		now:      time.Now,
+	}
+}
+
+// AddCheck registers the liveness check of a subsystem. Safe for concurrent
+// use; checks may be added while Run is active.
+func (w *Watchdog) AddCheck(name string, check LivenessCheck) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.checks = append(w.checks, namedCheck{name: name, check: check})
+}
+
+// Liveness runs every check and reports the result. Safe for concurrent use.
+func (w *Watchdog) Liveness() nodeapi.LivenessStatus {
+	w.mu.Lock()
+	checks := append([]namedCheck(nil), w.checks...)
+	w.mu.Unlock()
+
+	status := nodeapi.LivenessStatus{
+		Status:     "alive",
+		Time:       w.now(),
+		Subsystems: make([]nodeapi.SubsystemLiveness, 0, len(checks)),
+	}
+	for _, c := range checks {
+		detail, err := c.check()
+		sub := nodeapi.SubsystemLiveness{Name: c.name, Alive: err == nil, Detail: detail}
+		if err != nil {
+			sub.Detail = err.Error()
+			status.Status = "stalled"
+		}
+		status.Subsystems = append(status.Subsystems, sub)
+	}
+	return status
+}
+
+// Readiness reports "starting" until Ready is called and then derives
+// readiness from Liveness. Safe for concurrent use.
+func (w *Watchdog) Readiness() nodeapi.ReadinessStatus {
+	w.mu.Lock()
+	ready := w.ready
+	w.mu.Unlock()
+
+	if !ready {
+		return nodeapi.ReadinessStatus{
+			Status:     "starting",
+			Time:       w.now(),
+			Subsystems: []nodeapi.SubsystemLiveness{},
+		}
+	}
+	live := w.Liveness()
+	status := nodeapi.ReadinessStatus{Status: "ready", Time: live.Time, Subsystems: live.Subsystems}
+	if live.Status != "alive" {
+		status.Status = "stalled"
+	}
+	return status
+}
+
+// Ready marks startup as finished and tells systemd.
+func (w *Watchdog) Ready() {
+	w.mu.Lock()
+	w.ready = true
+	w.mu.Unlock()
+	w.notify("READY=1")
+}
+
+// Run sends a keepalive every half watchdog timeout while all subsystems are
+// alive. It blocks until ctx is cancelled and then tells systemd the agent is
+// stopping.
+func (w *Watchdog) Run(ctx context.Context) error {
+	defer w.notify("STOPPING=1")
+
+	if w.timeout <= 0 {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	w.logger.Info("systemd watchdog enabled", "timeout", w.timeout)
+	w.tick()
+
+	ticker := time.NewTicker(w.timeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			w.tick()
+		}
+	}
+}
+
+// tick checks liveness and sends a keepalive when every subsystem is alive.
+// Transitions between alive and stalled are logged once.
+func (w *Watchdog) tick() {
+	status := w.Liveness()
+	if status.Status == "alive" {
+		w.mu.Lock()
+		recovered := w.stalled
+		w.stalled = false
+		w.mu.Unlock()
+		if recovered {
+			w.logger.Info("all subsystems alive again, resuming watchdog keepalives")
+			w.notify("STATUS=running")
+		}
+		w.notify("WATCHDOG=1")
+		return
+	}
+
+	var stalled []string
+	for _, s := range status.Subsystems {
+		if !s.Alive {
+			stalled = append(stalled, s.Name+": "+s.Detail)
+		}
+	}
+	w.mu.Lock()
+	first := !w.stalled
+	w.stalled = true
+	w.mu.Unlock()
+	if first {
+		w.logger.Error("subsystem stalled, withholding watchdog keepalives",
+			"stalled", stalled,
+			"timeout", w.timeout,
+		)
+	}
+	w.notify("STATUS=stalled: " + strings.Join(stalled, "; "))
+}
+
+func (w *Watchdog) notify(state string) {
+	if err := w.notifier.Notify(state); err != nil {
+		w.logger.Debug("service manager notification failed", "state", state, "error", err)
+	}
+}
+
+// ProgressCheck returns a LivenessCheck that fails when last has not
+// advanced for longer than maxAge. Before the first progress is recorded,
+// the age counts from when the check was created.
+func ProgressCheck(last func() time.Time, maxAge func() time.Duration) LivenessCheck {
+	created := time.Now()
+	return func() (string, error) {
+		t := last()
+		if t.IsZero() {
+			t = created
+		}
+		age := time.Since(t)
+		if limit := maxAge(); age > limit {
+			return "", fmt.Errorf("no progress for %s (limit %s)", age.Round(time.Second), limit)
+		}
+		return fmt.Sprintf("last progress %s ago", age.Round(time.Second)), nil
+	}
+}
@@ -0,0 +1,214 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockNotifier struct {
+	mu     sync.Mutex
+	states []string
+}
+
+func (m *mockNotifier) Notify(state string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states = append(m.states, state)
+	return nil
+}
+
+func (m *mockNotifier) count(state string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, s := range m.states {
+		if s == state {
+			n++
+		}
+	}
+	return n
+}
+
+func TestWatchdog_LivenessAllAlive(t *testing.T) {
+	w := NewWatchdog(&mockNotifier{}, 0, testLogger())
+	w.AddCheck("sse", func() (string, error) { return "connected", nil })
+	w.AddCheck("nodeapi", func() (string, error) { return "serving", nil })
+
+	status := w.Liveness()
+	if status.Status != "alive" {
+		t.Errorf("Status = %q, want alive", status.Status)
+	}
+	if len(status.Subsystems) != 2 || status.Subsystems[0].Name != "sse" || status.Subsystems[0].Detail != "connected" {
+		t.Errorf("Subsystems = %+v", status.Subsystems)
+	}
+}
+
+func TestWatchdog_LivenessStalled(t *testing.T) {
+	w := NewWatchdog(&mockNotifier{}, 0, testLogger())
+	w.AddCheck("sse", func() (string, error) { return "connected", nil })
+	w.AddCheck("reconciler", func() (string, error) { return "", errors.New("no progress") })
+
+	status := w.Liveness()
+	if status.Status != "stalled" {
+		t.Errorf("Status = %q, want stalled", status.Status)
+	}
+	got := status.Subsystems[1]
+	if got.Alive || got.Detail != "no progress" {
+		t.Errorf("reconciler = %+v, want not alive with error detail", got)
+	}
+}
+
+func TestWatchdog_RunSendsKeepalivesWhileAlive(t *testing.T) {
+	n := &mockNotifier{}
+	w := NewWatchdog(n, 20*time.Millisecond, testLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = w.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for n.count("WATCHDOG=1") < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := n.count("WATCHDOG=1"); got < 3 {
+		t.Fatalf("keepalives = %d, want >= 3", got)
+	}
+
+	cancel()
+	<-done
+	if n.count("STOPPING=1") != 1 {
+		t.Error("STOPPING=1 not sent on shutdown")
+	}
+}
+
+func TestWatchdog_RunWithholdsKeepalivesWhenStalled(t *testing.T) {
+	n := &mockNotifier{}
+	w := NewWatchdog(n, 20*time.Millisecond, testLogger())
+	w.AddCheck("reconciler", func() (string, error) { return "", errors.New("no progress") })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_ = w.Run(ctx)
+
+	if got := n.count("WATCHDOG=1"); got != 0 {
+		t.Errorf("keepalives = %d, want 0 while stalled", got)
+	}
+	if !slices.Contains(n.states, "STATUS=stalled: reconciler: no progress") {
+		t.Errorf("states = %v, want stalled status", n.states)
+	}
+}
+
+func TestWatchdog_ReadySendsReady(t *testing.T) {
+	n := &mockNotifier{}
+	NewWatchdog(n, 0, testLogger()).Ready()
+	if n.count("READY=1") != 1 {
+		t.Errorf("states = %v, want READY=1", n.states)
+	}
+}
+
+func TestWatchdog_Readiness(t *testing.T) {
+	w := NewWatchdog(&mockNotifier{}, 0, testLogger())
+	var stalled error
+	w.AddCheck("reconciler", func() (string, error) { return "", stalled })
+
+	if got := w.Readiness().Status; got != "starting" {
+		t.Errorf("Status before Ready = %q, want starting", got)
+	}
+	w.Ready()
+	if got := w.Readiness(); got.Status != "ready" || len(got.Subsystems) != 1 {
+		t.Errorf("Readiness after Ready = %+v, want ready with one subsystem", got)
+	}
+	stalled = errors.New("no progress")
+	if got := w.Readiness().Status; got != "stalled" {
+		t.Errorf("Status with stalled subsystem = %q, want stalled", got)
+	}
+}
+
+func TestProgressCheck(t *testing.T) {
+	var last time.Time
+	maxAge := time.Minute
+	check := ProgressCheck(func() time.Time { return last }, func() time.Duration { return maxAge })
+
+	if _, err := check(); err != nil {
+		t.Errorf("before first progress: err = %v, want nil", err)
+	}
+
+	last = time.Now().Add(-2 * time.Minute)
+	if _, err := check(); err == nil {
+		t.Error("stale progress: err = nil, want error")
+	}
+
+	maxAge = 5 * time.Minute
+	if _, err := check(); err != nil {
+		t.Errorf("after raising limit: err = %v, want nil", err)
+	}
+}
+
+func TestSDNotifier_Notify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets unavailable: %v", err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", path)
+	n := NewSDNotifier()
+	if !n.Enabled() {
+		t.Fatal("Enabled() = false with NOTIFY_SOCKET set")
+	}
+	if err := n.Notify("READY=1"); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	buf := make([]byte, 64)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	nr, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got := string(buf[:nr]); got != "READY=1" {
+		t.Errorf("received %q, want READY=1", got)
+	}
+}
+
+func TestSDNotifier_DisabledWithoutSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	n := NewSDNotifier()
+	if n.Enabled() {
+		t.Error("Enabled() = true without NOTIFY_SOCKET")
+	}
+	if err := n.Notify("READY=1"); err != nil {
+		t.Errorf("Notify() error = %v, want nil", err)
+	}
+}
+
+func TestWatchdogTimeout(t *testing.T) {
+	t.Setenv("WATCHDOG_PID", "")
+	t.Setenv("WATCHDOG_USEC", "60000000")
+	if got := WatchdogTimeout(); got != time.Minute {
+		t.Errorf("WatchdogTimeout() = %v, want 1m", got)
+	}
+
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	if got := WatchdogTimeout(); got != 0 {
+		t.Errorf("WatchdogTimeout() for other pid = %v, want 0", got)
+	}
+
+	t.Setenv("WATCHDOG_PID", "")
+	t.Setenv("WATCHDOG_USEC", "")
+	if got := WatchdogTimeout(); got != 0 {
+		t.Errorf("WatchdogTimeout() unset = %v, want 0", got)
+	}
+}
@@ -0,0 +1,159 @@
+package apitest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// canonicalEnvelope is the signed representation of an envelope. It must
+// match the one the agent verifies.
+type canonicalEnvelope struct {
+	EventType string          `json:"event_type"`
+	EventID   string          `json:"event_id"`
+	IssuedAt  time.Time       `json:"issued_at"`
+	Nonce     string          `json:"nonce"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// Publish signs an event and queues it for the event stream of a node.
+// payload is marshaled to JSON unless it is a json.RawMessage. Events are
+// kept, so a stream that connects later receives all of them, and a stream
+// that reconnects with Last-Event-ID receives those after that ID.
+func (s *Server) Publish(nodeID, eventType string, payload any) (api.SignedEnvelope, error) {
+	raw, ok := payload.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(payload); err != nil {
+			return api.SignedEnvelope{}, fmt.Errorf("apitest: marshal payload: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.nodes[nodeID]
+	if !ok {
+		return api.SignedEnvelope{}, fmt.Errorf("apitest: unknown node %q", nodeID)
+	}
+
+	s.nextEvent++
+	env := api.SignedEnvelope{
+		EventType: eventType,
+		EventID:   fmt.Sprintf("evt-%d", s.nextEvent),
+		IssuedAt:  time.Now().UTC(),
+		Nonce:     randomHex(16),
+		Payload:   raw,
+	}
+	canonical, err := json.Marshal(canonicalEnvelope{
+		EventType: env.EventType,
+		EventID:   env.EventID,
+		IssuedAt:  env.IssuedAt,
+		Nonce:     env.Nonce,
+		Payload:   env.Payload,
+	})
+	if err != nil {
+		return api.SignedEnvelope{}, fmt.Errorf("apitest: marshal envelope: %w", err)
+	}
+	env.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(s.signingKey, canonical))
+
+	n.events = append(n.events, env)
+	close(n.notify)
+	n.notify = make(chan struct{})
+	return env, nil
+}
+
+// Streams returns the number of open event streams of a node.
+func (s *Server) Streams(nodeID string) int {
+	var streams int
+	s.withNode(nodeID, func(n *node) { streams = n.streams })
+	return streams
+}
+
+// WaitForStream blocks until a node has an open event stream or ctx is done.
+func (s *Server) WaitForStream(ctx context.Context, nodeID string) error {
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	for s.Streams(nodeID) == 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// serveEvents streams the events of a node as SSE until the client
+// disconnects or the server is closed. The types query parameter filters
+// event types as the control plane does.
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request, n *node) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	var types map[string]bool
+	if q := r.URL.Query().Get("types"); q != "" {
+		types = make(map[string]bool)
+		for _, t := range strings.Split(q, ",") {
+			types[t] = true
+		}
+	}
+
+	s.mu.Lock()
+	next := 0
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		for i, env := range n.events {
+			if env.EventID == lastID {
+				next = i + 1
+			}
+		}
+	}
+	n.streams++
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		n.streams--
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		s.mu.Lock()
+		pending := n.events[next:]
+		next = len(n.events)
+		notify := n.notify
+		s.mu.Unlock()
+
+		for _, env := range pending {
+			if types != nil && !types[env.EventType] {
+				continue
+			}
+			data, err := json.Marshal(env)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: %s\nid: %s\ndata: %s\n\n", env.EventType, env.EventID, data)
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		case <-notify:
+		}
+	}
+}
@@ -0,0 +1,427 @@
+// Package apitest provides an in-memory fake of the Plexsphere control plane
+// API for tests. It serves registration, desired state, heartbeats, secrets,
+// and the SSE event stream of any number of nodes, signs events with its own
+// Ed25519 key, and records every request, so that tests can exercise the
+// agent end-to-end through a real api.ControlPlane.
+package apitest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// Request is a request received by the fake control plane.
+type Request struct {
+	Method string
+	Path   string
+	// NodeID is the node of a /v1/nodes/{node_id}/ request, or empty.
+	NodeID string
+	// Body is the request body after gzip decompression.
+	Body []byte
+}
+
+// node is the state the fake keeps for one registered node.
+type node struct {
+	id     string
+	secret string
+	req    api.RegisterRequest
+
+	state         api.StateResponse
+	secrets       map[string]api.SecretResponse
+	heartbeats    []api.HeartbeatRequest
+	heartbeatResp api.HeartbeatResponse
+
+	// events are all events published to the node; notify is closed and
+	// replaced when one is added.
+	events  []api.SignedEnvelope
+	notify  chan struct{}
+	streams int
+}
+
+// Server is an in-memory fake control plane served over HTTP.
+type Server struct {
+	srv        *httptest.Server
+	signingKey ed25519.PrivateKey
+	done       chan struct{}
+	closeOnce  sync.Once
+
+	mu        sync.Mutex
+	tokens    map[string]bool
+	nodes     map[string]*node
+	nextNode  int
+	nextEvent int
+	failures  map[string][]int
+	artifacts map[string][]byte
+	requests  []Request
+}
+
+// NewServer starts a fake control plane that is closed when the test ends.
+// Until a bootstrap token is added with AddBootstrapToken, registration
+// accepts any non-empty token.
+func NewServer(tb testing.TB) *Server {
+	tb.Helper()
+	_, signingKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		tb.Fatalf("apitest: generate signing key: %v", err)
+	}
+	s := &Server{
+		signingKey: signingKey,
+		done:       make(chan struct{}),
+		tokens:     make(map[string]bool),
+		nodes:      make(map[string]*node),
+		failures:   make(map[string][]int),
+		artifacts:  make(map[string][]byte),
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	tb.Cleanup(s.Close)
+	return s
+}
+
+// URL returns the base URL of the fake, for api.Config.BaseURL.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Close ends all event streams and shuts the server down.
+func (s *Server) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.srv.Close()
+	})
+}
+
+// Client returns an api.ControlPlane connected to the fake. Its auth token
+// is not set.
+func (s *Server) Client(tb testing.TB) *api.ControlPlane {
+	tb.Helper()
+	client, err := api.NewControlPlane(api.Config{BaseURL: s.URL()}, "test", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		tb.Fatalf("apitest: create client: %v", err)
+	}
+	return client
+}
+
+// SigningPublicKey returns the key that signs published events. It is
+// returned base64 encoded as signing_public_key on registration.
+func (s *Server) SigningPublicKey() ed25519.PublicKey {
+	return s.signingKey.Public().(ed25519.PublicKey)
+}
+
+// Verifier returns a verifier that accepts the events of the fake.
+func (s *Server) Verifier() *api.Ed25519Verifier {
+	return api.NewEd25519Verifier(s.SigningPublicKey())
+}
+
+// AddBootstrapToken adds a token accepted by registration. Once a token is
+// added, registration with any other token fails with 401.
+func (s *Server) AddBootstrapToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token] = true
+}
+
+// AddNode registers a node without a registration request and returns the
+// response Register would have returned. It is used by tests that start
+// from an existing identity.
+func (s *Server) AddNode(hostname string) api.RegisterResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.register(api.RegisterRequest{Hostname: hostname})
+}
+
+// register creates a node. Must be called with mu held.
+func (s *Server) register(req api.RegisterRequest) api.RegisterResponse {
+	s.nextNode++
+	n := &node{
+		id:      fmt.Sprintf("node-%d", s.nextNode),
+		secret:  randomHex(16),
+		req:     req,
+		secrets: make(map[string]api.SecretResponse),
+		notify:  make(chan struct{}),
+	}
+	n.state.Peers = []api.Peer{}
+	s.nodes[n.id] = n
+	return api.RegisterResponse{
+		NodeID:           n.id,
+		MeshIP:           fmt.Sprintf("100.64.%d.%d", s.nextNode/256, s.nextNode%256),
+		SigningPublicKey: base64.StdEncoding.EncodeToString(s.SigningPublicKey()),
+		NodeSecretKey:    n.secret,
+		Peers:            n.state.Peers,
+	}
+}
+
+// Registration returns the registration request of a node and whether the
+// node exists.
+func (s *Server) Registration(nodeID string) (api.RegisterRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.nodes[nodeID]
+	if !ok {
+		return api.RegisterRequest{}, false
+	}
+	return n.req, true
+}
+
+// SetState sets the desired state returned by FetchState. If
+// state.SigningKeys is nil, the signing key of the fake is returned.
+func (s *Server) SetState(nodeID string, state api.StateResponse) {
+	s.withNode(nodeID, func(n *node) { n.state = state })
+}
+
+// SetSecret sets a secret returned by FetchSecret for secret.Key.
+func (s *Server) SetSecret(nodeID string, secret api.SecretResponse) {
+	s.withNode(nodeID, func(n *node) { n.secrets[secret.Key] = secret })
+}
+
+// SetHeartbeatResponse sets the response to all following heartbeats.
+func (s *Server) SetHeartbeatResponse(nodeID string, resp api.HeartbeatResponse) {
+	s.withNode(nodeID, func(n *node) { n.heartbeatResp = resp })
+}
+
+// Heartbeats returns the heartbeats received from a node.
+func (s *Server) Heartbeats(nodeID string) []api.HeartbeatRequest {
+	var hbs []api.HeartbeatRequest
+	s.withNode(nodeID, func(n *node) { hbs = append(hbs, n.heartbeats...) })
+	return hbs
+}
+
+// SetArtifact sets the binary returned by FetchArtifact.
+func (s *Server) SetArtifact(version, goos, arch string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.artifacts[version+"/"+goos+"/"+arch] = data
+}
+
+// Fail makes the next n requests with the given method and path fail with
+// status. Failures queued for the same request are used in order.
+func (s *Server) Fail(method, path string, status, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := method + " " + path
+	for range n {
+		s.failures[key] = append(s.failures[key], status)
+	}
+}
+
+// Requests returns all requests received so far, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// withNode calls fn with mu held if the node exists. Setters on unknown
+// nodes are ignored.
+func (s *Server) withNode(nodeID string, fn func(*node)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n, ok := s.nodes[nodeID]; ok {
+		fn(n)
+	}
+}
+
+// serveHTTP records the request, applies queued failures, and routes it.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	path := r.URL.Path
+	nodeID, sub := splitNodePath(path)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: path, NodeID: nodeID, Body: body})
+	key := r.Method + " " + path
+	if queued := s.failures[key]; len(queued) > 0 {
+		s.failures[key] = queued[1:]
+		s.mu.Unlock()
+		http.Error(w, "injected failure", queued[0])
+		return
+	}
+	s.mu.Unlock()
+
+	switch {
+	case path == "/v1/register" && r.Method == http.MethodPost:
+		s.handleRegister(w, body)
+	case path == "/v1/keys/rotate" && r.Method == http.MethodPost:
+		s.handleRotateKeys(w, r, body)
+	case strings.HasPrefix(path, "/v1/artifacts/plexd/") && r.Method == http.MethodGet:
+		s.handleArtifact(w, strings.TrimPrefix(path, "/v1/artifacts/plexd/"))
+	case nodeID != "":
+		s.handleNode(w, r, nodeID, sub, body)
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func (s *Server) handleRegister(w http.ResponseWriter, body []byte) {
+	var req api.RegisterRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	if req.Token == "" || (len(s.tokens) > 0 && !s.tokens[req.Token]) {
+		s.mu.Unlock()
+		http.Error(w, "invalid bootstrap token", http.StatusUnauthorized)
+		return
+	}
+	resp := s.register(req)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (s *Server) handleRotateKeys(w http.ResponseWriter, r *http.Request, body []byte) {
+	var req api.KeyRotateRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	n, status := s.authenticate(r, req.NodeID)
+	if n == nil {
+		s.mu.Unlock()
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	n.req.PublicKey = req.NewPublicKey
+	resp := api.KeyRotateResponse{UpdatedPeers: n.state.Peers}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleArtifact(w http.ResponseWriter, key string) {
+	s.mu.Lock()
+	data, ok := s.artifacts[key]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+// handleNode serves /v1/nodes/{node_id}/{sub}. Requests must carry the
+// node secret key as bearer token.
+func (s *Server) handleNode(w http.ResponseWriter, r *http.Request, nodeID, sub string, body []byte) {
+	s.mu.Lock()
+	n, status := s.authenticate(r, nodeID)
+	if n == nil {
+		s.mu.Unlock()
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	switch {
+	case sub == "events" && r.Method == http.MethodGet:
+		s.mu.Unlock()
+		s.serveEvents(w, r, n)
+		return
+	case sub == "state" && r.Method == http.MethodGet:
+		state := n.state
+		if state.SigningKeys == nil {
+			state.SigningKeys = &api.SigningKeys{Current: base64.StdEncoding.EncodeToString(s.SigningPublicKey())}
+		}
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, state)
+	case sub == "heartbeat" && r.Method == http.MethodPost:
+		var hb api.HeartbeatRequest
+		if err := json.Unmarshal(body, &hb); err != nil {
+			s.mu.Unlock()
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		n.heartbeats = append(n.heartbeats, hb)
+		resp := n.heartbeatResp
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, resp)
+	case sub == "deregister" && r.Method == http.MethodPost:
+		delete(s.nodes, nodeID)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasPrefix(sub, "secrets/") && r.Method == http.MethodGet:
+		secret, ok := n.secrets[strings.TrimPrefix(sub, "secrets/")]
+		s.mu.Unlock()
+		if !ok {
+			http.Error(w, "secret not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, secret)
+	case sub == "endpoint" && r.Method == http.MethodPut:
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, api.EndpointResponse{PeerEndpoints: []api.PeerEndpoint{}})
+	case r.Method == http.MethodPost || r.Method == http.MethodPut:
+		// Reports, acknowledgements, and uploads are only recorded.
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.mu.Unlock()
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+// authenticate returns the node if the request carries its secret key, or
+// the status to fail with. Must be called with mu held.
+func (s *Server) authenticate(r *http.Request, nodeID string) (*node, int) {
+	n, ok := s.nodes[nodeID]
+	if !ok {
+		return nil, http.StatusNotFound
+	}
+	if r.Header.Get("Authorization") != "Bearer "+n.secret {
+		return nil, http.StatusUnauthorized
+	}
+	return n, 0
+}
+
+// splitNodePath splits /v1/nodes/{node_id}/{sub} into node ID and sub path.
+func splitNodePath(path string) (nodeID, sub string) {
+	rest, ok := strings.CutPrefix(path, "/v1/nodes/")
+	if !ok {
+		return "", ""
+	}
+	nodeID, sub, _ = strings.Cut(rest, "/")
+	return nodeID, sub
+}
+
+// readBody reads the request body, decompressing it if gzip encoded.
+func readBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil || r.Header.Get("Content-Encoding") != "gzip" {
+		return body, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
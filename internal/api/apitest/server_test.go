@@ -0,0 +1,174 @@
+package apitest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+func TestServer_RegisterAndFetchState(t *testing.T) {
+	s := NewServer(t)
+	s.AddBootstrapToken("bootstrap")
+	client := s.Client(t)
+	ctx := context.Background()
+
+	if _, err := client.Register(ctx, api.RegisterRequest{Token: "wrong", Hostname: "h1"}); !errors.Is(err, api.ErrUnauthorized) {
+		t.Fatalf("Register with wrong token: err = %v, want ErrUnauthorized", err)
+	}
+	reg, err := client.Register(ctx, api.RegisterRequest{Token: "bootstrap", Hostname: "h1", PublicKey: "pub"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if req, ok := s.Registration(reg.NodeID); !ok || req.Hostname != "h1" {
+		t.Errorf("Registration(%q) = %+v, %v", reg.NodeID, req, ok)
+	}
+
+	if _, err := client.FetchState(ctx, reg.NodeID); !errors.Is(err, api.ErrUnauthorized) {
+		t.Errorf("FetchState without node secret: err = %v, want ErrUnauthorized", err)
+	}
+
+	client.SetAuthToken(reg.NodeSecretKey)
+	s.SetState(reg.NodeID, api.StateResponse{Peers: []api.Peer{{ID: "peer-1"}}})
+	state, err := client.FetchState(ctx, reg.NodeID)
+	if err != nil {
+		t.Fatalf("FetchState: %v", err)
+	}
+	if len(state.Peers) != 1 || state.Peers[0].ID != "peer-1" {
+		t.Errorf("Peers = %+v, want peer-1", state.Peers)
+	}
+	if state.SigningKeys == nil || state.SigningKeys.Current != reg.SigningPublicKey {
+		t.Errorf("SigningKeys = %+v, want current key %q", state.SigningKeys, reg.SigningPublicKey)
+	}
+
+	if err := client.Deregister(ctx, reg.NodeID); err != nil {
+		t.Fatalf("Deregister: %v", err)
+	}
+	if _, err := client.FetchState(ctx, reg.NodeID); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("FetchState after deregister: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestServer_HeartbeatAndSecrets(t *testing.T) {
+	s := NewServer(t)
+	reg := s.AddNode("h1")
+	client := s.Client(t)
+	client.SetAuthToken(reg.NodeSecretKey)
+	ctx := context.Background()
+
+	s.SetHeartbeatResponse(reg.NodeID, api.HeartbeatResponse{Reconcile: true})
+	resp, err := client.Heartbeat(ctx, reg.NodeID, api.HeartbeatRequest{NodeID: reg.NodeID, Status: "healthy"})
+	if err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	if !resp.Reconcile {
+		t.Error("Reconcile = false, want true")
+	}
+	if hbs := s.Heartbeats(reg.NodeID); len(hbs) != 1 || hbs[0].Status != "healthy" {
+		t.Errorf("Heartbeats = %+v, want one healthy heartbeat", hbs)
+	}
+
+	s.SetSecret(reg.NodeID, api.SecretResponse{Key: "db", Ciphertext: "c", Nonce: "n", Version: 2})
+	secret, err := client.FetchSecret(ctx, reg.NodeID, "db")
+	if err != nil {
+		t.Fatalf("FetchSecret: %v", err)
+	}
+	if secret.Version != 2 {
+		t.Errorf("Version = %d, want 2", secret.Version)
+	}
+	if _, err := client.FetchSecret(ctx, reg.NodeID, "missing"); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("FetchSecret(missing): err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestServer_FailAndRequests(t *testing.T) {
+	s := NewServer(t)
+	reg := s.AddNode("h1")
+	client := s.Client(t)
+	client.SetAuthToken(reg.NodeSecretKey)
+	ctx := context.Background()
+
+	s.Fail("POST", "/v1/nodes/"+reg.NodeID+"/drift", 503, 1)
+	report := api.DriftReport{Corrections: []api.DriftCorrection{{Type: "peer", Detail: strings.Repeat("x", 2048)}}}
+	if err := client.ReportDrift(ctx, reg.NodeID, report); !errors.Is(err, api.ErrServer) {
+		t.Fatalf("ReportDrift: err = %v, want injected ErrServer", err)
+	}
+	if err := client.ReportDrift(ctx, reg.NodeID, report); err != nil {
+		t.Fatalf("ReportDrift after failure: %v", err)
+	}
+
+	reqs := s.Requests()
+	if len(reqs) != 2 {
+		t.Fatalf("Requests() = %d, want 2", len(reqs))
+	}
+	// The body is larger than the gzip threshold of the client.
+	var got api.DriftReport
+	if err := json.Unmarshal(reqs[1].Body, &got); err != nil {
+		t.Fatalf("decode recorded body: %v", err)
+	}
+	if reqs[1].NodeID != reg.NodeID || len(got.Corrections) != 1 {
+		t.Errorf("recorded request = %+v", reqs[1])
+	}
+}
+
+func TestServer_PublishEvents(t *testing.T) {
+	s := NewServer(t)
+	reg := s.AddNode("h1")
+	client := s.Client(t)
+	client.SetAuthToken(reg.NodeSecretKey)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mgr := api.NewSSEManager(client, s.Verifier(), logger)
+	received := make(chan api.SignedEnvelope, 4)
+	mgr.RegisterHandler(api.EventPeerAdded, func(_ context.Context, env api.SignedEnvelope) error {
+		received <- env
+		return nil
+	})
+
+	// Published before the stream connects.
+	if _, err := s.Publish(reg.NodeID, api.EventPeerAdded, api.Peer{ID: "peer-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- mgr.Start(ctx, reg.NodeID) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitCtx, waitCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer waitCancel()
+	if err := s.WaitForStream(waitCtx, reg.NodeID); err != nil {
+		t.Fatalf("WaitForStream: %v", err)
+	}
+	// Not subscribed: the stream asks only for peer_added.
+	if _, err := s.Publish(reg.NodeID, api.EventPolicyUpdated, struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Publish(reg.NodeID, api.EventPeerAdded, api.Peer{ID: "peer-2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"peer-1", "peer-2"} {
+		select {
+		case env := <-received:
+			var peer api.Peer
+			if err := json.Unmarshal(env.Payload, &peer); err != nil || peer.ID != want {
+				t.Errorf("payload = %s, want peer %s", env.Payload, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("event for %s not received", want)
+		}
+	}
+	if st := mgr.Status(); st.UnexpectedEvents != 0 || st.EventsReceived != 2 {
+		t.Errorf("Status() = %+v, want 2 events and none unexpected", st)
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import "context"
+
+// Cause types.
+const (
+	// CauseEvent is a control plane event handled by the EventDispatcher.
+	CauseEvent = "event"
+
+	// CauseReconcile is a reconciliation cycle; the ID is its trigger.
+	CauseReconcile = "reconcile"
+
+	// CauseDriftCheck is a drift check of the reconciler.
+	CauseDriftCheck = "drift_check"
+
+	// CauseAgent is a change the agent makes on its own, without a known
+	// control plane event or reconciliation cycle behind it.
+	CauseAgent = "agent"
+)
+
+// Cause identifies why the agent changes the host, so that audit entries of
+// the change can be attributed to a control plane event or reconciliation
+// cycle.
+type Cause struct {
+	Type string `json:"type"`
+
+	// ID is the event ID of a CauseEvent, or the trigger of a
+	// CauseReconcile ("initial", "interval", "manual", or "resume").
+	ID string `json:"id,omitempty"`
+
+	// EventType is the type of the event of a CauseEvent.
+	EventType string `json:"event_type,omitempty"`
+}
+
+// causeKey is the context key of the Cause.
+type causeKey struct{}
+
+// WithCause returns a copy of ctx that carries c.
+func WithCause(ctx context.Context, c Cause) context.Context {
+	return context.WithValue(ctx, causeKey{}, c)
+}
+
+// CauseFromContext returns the Cause carried by ctx.
+func CauseFromContext(ctx context.Context) (Cause, bool) {
+	c, ok := ctx.Value(causeKey{}).(Cause)
+	return c, ok
+}
+
+// CauseTracker is told which causes are being handled, so that changes made
+// without a context, such as through a controller interface, can still be
+// attributed. Track returns a function that ends the cause. Satisfied by
+// *auditfwd.Recorder.
+type CauseTracker interface {
+	Track(c Cause) (done func())
+}
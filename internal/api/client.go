@@ -2,30 +2,33 @@ package api
 
 import (
 	"bytes"
-	"compress/gzip"
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 )
 
-const (
-	// gzipThreshold is the minimum body size for gzip compression.
-	gzipThreshold = 1024 // 1 KiB
+// userAgentPrefix is the User-Agent header prefix.
+const userAgentPrefix = "plexd/"
 
-	// maxResponseSize is the maximum decompressed response body size (10 MiB).
-	// Protects against gzip bombs in compressed responses.
-	maxResponseSize = 10 * 1024 * 1024
+// ErrResponseTooLarge is returned when a response body exceeds its size
+// limit.
+var ErrResponseTooLarge = errors.New("api: response body too large")
 
-	// userAgentPrefix is the User-Agent header prefix.
-	userAgentPrefix = "plexd/"
-)
+// limits are the timeout and decompressed body size limit of a request.
+type limits struct {
+	timeout time.Duration
+	maxSize int64
+}
 
 // ControlPlane is the client for the Plexsphere control plane API.
 type ControlPlane struct {
@@ -34,6 +37,32 @@ type ControlPlane struct {
 	version    string
 	logger     *slog.Logger
 
+	// subscribeAll disables the types filter of ConnectSSE and
+	// ConnectWebSocket.
+	subscribeAll bool
+
+	// eventTransport and wsFallbackAfter select the transport of SSEStream.
+	eventTransport  string
+	wsFallbackAfter int
+
+	// defaultLimits apply to all JSON endpoints except FetchState.
+	defaultLimits  limits
+	stateLimits    limits
+	artifactLimits limits
+
+	// encodings, compressMinSize and disableCompression configure body
+	// compression; see compression.go.
+	encodings          []string
+	compressMinSize    int64
+	disableCompression bool
+	compression        compressionCounters
+
+	// codecMu guards the registered codecs and the negotiated coding.
+	codecMu         sync.RWMutex
+	codecs          map[string]Codec
+	serverEncodings []string // nil until advertised by the control plane
+	reqEncoding     string   // "" sends request bodies uncompressed
+
 	mu        sync.RWMutex
 	authToken string
 }
@@ -53,11 +82,13 @@ func NewControlPlane(cfg Config, version string, logger *slog.Logger) (*ControlP
 		DialContext: (&net.Dialer{
 			Timeout: cfg.ConnectTimeout,
 		}).DialContext,
-		DisableCompression: true,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		DisableCompression:    true,
 	}
 
+	// Timeouts are applied per request as context deadlines, so that the
+	// SSE stream and artifact downloads are not cut off by RequestTimeout.
 	httpClient := &http.Client{
-		Timeout:   cfg.RequestTimeout,
 		Transport: transport,
 	}
 
@@ -65,13 +96,28 @@ func NewControlPlane(cfg Config, version string, logger *slog.Logger) (*ControlP
 		logger.Warn("TLS certificate verification disabled")
 	}
 
-	return &ControlPlane{
+	c := &ControlPlane{
 		httpClient: httpClient,
 		baseURL:    strings.TrimRight(cfg.BaseURL, "/"),
 		version:    version,
 		logger:     logger,
 		authToken:  "",
-	}, nil
+
+		subscribeAll:    cfg.SubscribeAllEvents,
+		eventTransport:  cfg.EventTransport,
+		wsFallbackAfter: cfg.WebSocketFallbackAfter,
+
+		defaultLimits:  limits{timeout: cfg.RequestTimeout, maxSize: cfg.MaxResponseSize},
+		stateLimits:    limits{timeout: cfg.StateTimeout, maxSize: cfg.MaxStateSize},
+		artifactLimits: limits{timeout: cfg.ArtifactTimeout, maxSize: cfg.MaxArtifactSize},
+
+		encodings:          slices.Clone(cfg.Encodings),
+		compressMinSize:    cfg.CompressMinSize,
+		disableCompression: cfg.DisableCompression,
+		codecs:             map[string]Codec{EncodingGzip: gzipCodec{}},
+	}
+	c.negotiateLocked()
+	return c, nil
 }
 
 // SetAuthToken sets the bearer token used for API authentication.
@@ -88,9 +134,20 @@ func (c *ControlPlane) getAuthToken() string {
 	return c.authToken
 }
 
-// doRequest is the core HTTP helper that handles JSON marshaling, gzip
-// compression, request execution, and response decoding.
+// doRequest is the core HTTP helper that handles JSON marshaling, body
+// compression, request execution, and response decoding. It applies the
+// default limits.
 func (c *ControlPlane) doRequest(ctx context.Context, method, path string, body any, result any) error {
+	return c.doRequestWithLimits(ctx, c.defaultLimits, method, path, body, result)
+}
+
+// doRequestWithLimits is doRequest with the given limits. The timeout only
+// shortens the deadline of ctx, never extends it. A response body larger
+// than lim.maxSize after decompression fails with ErrResponseTooLarge.
+func (c *ControlPlane) doRequestWithLimits(ctx context.Context, lim limits, method, path string, body any, result any) error {
+	ctx, cancel := context.WithTimeout(ctx, lim.timeout)
+	defer cancel()
+
 	resp, err := c.sendRequest(ctx, method, path, body)
 	if err != nil {
 		return err
@@ -102,15 +159,14 @@ func (c *ControlPlane) doRequest(ctx context.Context, method, path string, body
 	}
 
 	if result != nil {
-		var reader io.Reader = resp.Body
-		if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
-			gr, err := gzip.NewReader(resp.Body)
-			if err != nil {
-				return fmt.Errorf("api: gzip decompress response: %w", err)
-			}
-			defer gr.Close()
-			reader = io.LimitReader(gr, maxResponseSize)
+		wire := countingReader{r: resp.Body, n: &c.compression.responseWireBytes}
+		body, err := c.decodeBody(resp.Header, wire)
+		if err != nil {
+			return err
 		}
+		defer body.Close()
+		var reader io.Reader = countingReader{r: body, n: &c.compression.responseBytes}
+		reader = &maxBytesReader{r: reader, n: lim.maxSize}
 		if err := json.NewDecoder(reader).Decode(result); err != nil {
 			return fmt.Errorf("api: decode response: %w", err)
 		}
@@ -119,6 +175,45 @@ func (c *ControlPlane) doRequest(ctx context.Context, method, path string, body
 	return nil
 }
 
+// maxBytesReader reads from r until more than n bytes have been read, then
+// fails with ErrResponseTooLarge. Unlike io.LimitReader it does not
+// silently truncate the body.
+type maxBytesReader struct {
+	r io.Reader
+	n int64 // bytes remaining; negative once the limit was exceeded
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	if m.n < 0 {
+		return 0, ErrResponseTooLarge
+	}
+	if int64(len(p)) > m.n+1 {
+		p = p[:m.n+1]
+	}
+	n, err := m.r.Read(p)
+	if int64(n) <= m.n {
+		m.n -= int64(n)
+		return n, err
+	}
+	n = int(m.n)
+	m.n = -1
+	return n, ErrResponseTooLarge
+}
+
+// streamBody is a response body read through a size limit. Close releases
+// the request's context.
+type streamBody struct {
+	io.Reader
+	body   io.Closer
+	cancel context.CancelFunc
+}
+
+func (b *streamBody) Close() error {
+	err := b.body.Close()
+	b.cancel()
+	return err
+}
+
 // doRequestRaw sends an HTTP request and returns the raw response without
 // reading or closing the body. Used for SSE streams and artifact downloads.
 // The caller is responsible for closing the response body.
@@ -137,52 +232,67 @@ func (c *ControlPlane) doRequestRaw(ctx context.Context, method, path string, bo
 }
 
 // sendRequest builds and executes an HTTP request with standard headers,
-// optional JSON body marshaling, and gzip compression for large payloads.
+// optional JSON body marshaling, and compression for large payloads. A
+// compressed request rejected with 415 Unsupported Media Type is sent once
+// more in the coding the control plane advertises, or uncompressed.
 func (c *ControlPlane) sendRequest(ctx context.Context, method, path string, body any) (*http.Response, error) {
-	var bodyReader io.Reader
-	var compressed bool
-
+	var data []byte
 	if body != nil {
-		data, err := json.Marshal(body)
-		if err != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
 			return nil, fmt.Errorf("api: marshal request body: %w", err)
 		}
+	}
 
-		if len(data) > gzipThreshold {
-			var buf bytes.Buffer
-			gw := gzip.NewWriter(&buf)
-			if _, err := gw.Write(data); err != nil {
-				return nil, fmt.Errorf("api: gzip compress request: %w", err)
+	for attempt := 0; ; attempt++ {
+		var bodyReader io.Reader
+		var encoding string
+		if body != nil {
+			wire, enc, err := c.encodeBody(data)
+			if err != nil {
+				return nil, err
 			}
-			if err := gw.Close(); err != nil {
-				return nil, fmt.Errorf("api: gzip close: %w", err)
+			bodyReader, encoding = bytes.NewReader(wire), enc
+			c.compression.requestBytes.Add(uint64(len(data)))
+			c.compression.requestWireBytes.Add(uint64(len(wire)))
+			if enc != "" {
+				c.compression.compressedRequests.Add(1)
 			}
-			bodyReader = &buf
-			compressed = true
-		} else {
-			bodyReader = bytes.NewReader(data)
 		}
-	}
 
-	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bodyReader)
-	if err != nil {
-		return nil, fmt.Errorf("api: create request: %w", err)
-	}
+		req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bodyReader)
+		if err != nil {
+			return nil, fmt.Errorf("api: create request: %w", err)
+		}
 
-	if body != nil {
-		req.Header.Set("Content-Type", "application/json")
-	}
-	if compressed {
-		req.Header.Set("Content-Encoding", "gzip")
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Accept-Encoding", "gzip")
-	if token := c.getAuthToken(); token != "" {
-		req.Header.Set("Authorization", "Bearer "+token)
-	}
-	req.Header.Set("User-Agent", userAgentPrefix+c.version)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if encoding != "" {
+			req.Header.Set("Content-Encoding", encoding)
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Accept-Encoding", c.acceptEncoding())
+		if token := c.getAuthToken(); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		req.Header.Set("User-Agent", userAgentPrefix+c.version)
 
-	return c.httpClient.Do(req)
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		changed := c.learnEncodings(resp.Header)
+		if resp.StatusCode != http.StatusUnsupportedMediaType || encoding == "" || attempt > 0 {
+			return resp, nil
+		}
+		if !changed {
+			// Without an advertised alternative, fall back to
+			// uncompressed request bodies.
+			c.learnEncodings(http.Header{"Accept-Encoding": {EncodingIdentity}})
+		}
+		resp.Body.Close()
+	}
 }
 
 // Ping sends a GET request to /v1/ping for health checking.
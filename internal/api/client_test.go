@@ -12,6 +12,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 // newTestClient creates a ControlPlane client pointed at the given test server.
@@ -231,9 +232,87 @@ func TestClient_NewControlPlane_AppliesDefaults(t *testing.T) {
 		t.Fatalf("Ping with defaults: %v", err)
 	}
 
-	// Verify the httpClient timeout matches the default.
-	if c.httpClient.Timeout != DefaultRequestTimeout {
-		t.Errorf("httpClient.Timeout = %v, want %v", c.httpClient.Timeout, DefaultRequestTimeout)
+	// Verify the request timeout matches the default. It is applied per
+	// request, not on the http.Client, so it does not cut off SSE streams.
+	if c.defaultLimits.timeout != DefaultRequestTimeout {
+		t.Errorf("defaultLimits.timeout = %v, want %v", c.defaultLimits.timeout, DefaultRequestTimeout)
+	}
+	if c.httpClient.Timeout != 0 {
+		t.Errorf("httpClient.Timeout = %v, want 0", c.httpClient.Timeout)
+	}
+}
+
+func TestClient_RequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	c, err := NewControlPlane(Config{BaseURL: srv.URL, RequestTimeout: 50 * time.Millisecond}, "1.0.0", slog.Default())
+	if err != nil {
+		t.Fatalf("NewControlPlane: %v", err)
+	}
+
+	start := time.Now()
+	err = c.Ping(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Ping error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Ping took %v, want about 50ms", elapsed)
+	}
+}
+
+func TestClient_ResponseTooLarge(t *testing.T) {
+	payload := `{"message":"` + strings.Repeat("x", 200) + `"}`
+	for _, compressed := range []bool{false, true} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if compressed {
+				w.Header().Set("Content-Encoding", "gzip")
+				gw := gzip.NewWriter(w)
+				_, _ = io.WriteString(gw, payload)
+				_ = gw.Close()
+				return
+			}
+			_, _ = io.WriteString(w, payload)
+		}))
+
+		c, err := NewControlPlane(Config{BaseURL: srv.URL, MaxResponseSize: 100}, "1.0.0", slog.Default())
+		if err != nil {
+			t.Fatalf("NewControlPlane: %v", err)
+		}
+		var result map[string]string
+		err = c.GetJSON(context.Background(), "/v1/test", &result)
+		if !errors.Is(err, ErrResponseTooLarge) {
+			t.Errorf("compressed=%v: GetJSON error = %v, want ErrResponseTooLarge", compressed, err)
+		}
+		srv.Close()
+	}
+}
+
+func TestMaxBytesReader_AtLimit(t *testing.T) {
+	r := &maxBytesReader{r: strings.NewReader("12345"), n: 5}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(got) != "12345" {
+		t.Errorf("got %q, want %q", got, "12345")
+	}
+
+	r = &maxBytesReader{r: strings.NewReader("123456"), n: 5}
+	got, err = io.ReadAll(r)
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("ReadAll error = %v, want ErrResponseTooLarge", err)
+	}
+	if string(got) != "12345" {
+		t.Errorf("got %q, want %q", got, "12345")
 	}
 }
 
@@ -0,0 +1,244 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// Content codings of request and response bodies.
+const (
+	EncodingGzip     = "gzip"
+	EncodingIdentity = "identity"
+)
+
+// Codec compresses and decompresses HTTP bodies in one content coding.
+// gzip is built in; other codings such as zstd are added with
+// ControlPlane.RegisterCodec.
+type Codec interface {
+	// Encoding returns the content coding token, e.g. "zstd".
+	Encoding() string
+	// NewWriter returns a writer that compresses into w. Close flushes it.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	// NewReader returns a reader that decompresses r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) Encoding() string { return EncodingGzip }
+
+func (gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// CompressionStats counts the bytes of request and response bodies before
+// and after compression. Only JSON endpoints are counted; the event stream
+// and artifact downloads are not.
+type CompressionStats struct {
+	// RequestEncoding is the coding of compressed request bodies, as
+	// negotiated with the control plane, or "identity".
+	RequestEncoding string `json:"request_encoding"`
+	// RequestBytes and RequestWireBytes are the size of the request bodies
+	// before compression and as sent.
+	RequestBytes     uint64 `json:"request_bytes"`
+	RequestWireBytes uint64 `json:"request_wire_bytes"`
+	// ResponseBytes and ResponseWireBytes are the size of the response
+	// bodies after decompression and as received.
+	ResponseBytes     uint64 `json:"response_bytes"`
+	ResponseWireBytes uint64 `json:"response_wire_bytes"`
+	// CompressedRequests and CompressedResponses count the bodies sent and
+	// received compressed.
+	CompressedRequests  uint64 `json:"compressed_requests"`
+	CompressedResponses uint64 `json:"compressed_responses"`
+	// BytesSaved is the number of bytes compression kept off the wire.
+	BytesSaved int64 `json:"bytes_saved"`
+}
+
+// compressionCounters holds the counters of CompressionStats.
+type compressionCounters struct {
+	requestBytes, requestWireBytes   atomic.Uint64
+	responseBytes, responseWireBytes atomic.Uint64
+	compressedRequests               atomic.Uint64
+	compressedResponses              atomic.Uint64
+}
+
+// RegisterCodec adds a content coding, replacing a codec of the same
+// coding. The coding is only used if it is listed in Config.Encodings. It
+// is typically called right after NewControlPlane, e.g. to add zstd:
+//
+//	client.RegisterCodec(zstdCodec{})
+func (c *ControlPlane) RegisterCodec(codec Codec) {
+	c.codecMu.Lock()
+	defer c.codecMu.Unlock()
+	c.codecs[codec.Encoding()] = codec
+	c.negotiateLocked()
+}
+
+// CompressionStats returns the compression counters. It implements
+// metrics.CompressionStatsReader.
+func (c *ControlPlane) CompressionStats() CompressionStats {
+	s := CompressionStats{
+		RequestEncoding:     c.requestEncoding(),
+		RequestBytes:        c.compression.requestBytes.Load(),
+		RequestWireBytes:    c.compression.requestWireBytes.Load(),
+		ResponseBytes:       c.compression.responseBytes.Load(),
+		ResponseWireBytes:   c.compression.responseWireBytes.Load(),
+		CompressedRequests:  c.compression.compressedRequests.Load(),
+		CompressedResponses: c.compression.compressedResponses.Load(),
+	}
+	if s.RequestEncoding == "" {
+		s.RequestEncoding = EncodingIdentity
+	}
+	s.BytesSaved = int64(s.RequestBytes) - int64(s.RequestWireBytes) + int64(s.ResponseBytes) - int64(s.ResponseWireBytes)
+	return s
+}
+
+// acceptEncoding returns the Accept-Encoding header value: the configured
+// codings that have a codec, in order of preference.
+func (c *ControlPlane) acceptEncoding() string {
+	c.codecMu.RLock()
+	defer c.codecMu.RUnlock()
+	var codings []string
+	if !c.disableCompression {
+		for _, e := range c.encodings {
+			if _, ok := c.codecs[e]; ok {
+				codings = append(codings, e)
+			}
+		}
+	}
+	if len(codings) == 0 {
+		return EncodingIdentity
+	}
+	return strings.Join(codings, ", ")
+}
+
+// requestEncoding returns the coding of compressed request bodies, or ""
+// if request bodies are sent uncompressed.
+func (c *ControlPlane) requestEncoding() string {
+	c.codecMu.RLock()
+	defer c.codecMu.RUnlock()
+	return c.reqEncoding
+}
+
+// negotiateLocked selects the request coding: the most preferred
+// configured coding with a codec that the control plane accepts. Until the
+// control plane advertised the codings it accepts, all are assumed to be
+// accepted. Callers must hold c.codecMu.
+func (c *ControlPlane) negotiateLocked() {
+	c.reqEncoding = ""
+	if c.disableCompression {
+		return
+	}
+	for _, e := range c.encodings {
+		if _, ok := c.codecs[e]; !ok {
+			continue
+		}
+		if c.serverEncodings == nil || slices.Contains(c.serverEncodings, e) {
+			c.reqEncoding = e
+			return
+		}
+	}
+}
+
+// learnEncodings records the request codings the control plane accepts,
+// as advertised in the Accept-Encoding header of a response (RFC 7694). It
+// reports whether the request coding changed.
+func (c *ControlPlane) learnEncodings(h http.Header) bool {
+	values := h.Values("Accept-Encoding")
+	if len(values) == 0 {
+		return false
+	}
+	accepted := []string{}
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			coding, params, _ := strings.Cut(part, ";")
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding == "" || strings.ReplaceAll(strings.TrimSpace(params), " ", "") == "q=0" {
+				continue
+			}
+			accepted = append(accepted, coding)
+		}
+	}
+	c.codecMu.Lock()
+	defer c.codecMu.Unlock()
+	prev := c.reqEncoding
+	c.serverEncodings = accepted
+	c.negotiateLocked()
+	if c.reqEncoding != prev {
+		c.logger.Info("request compression negotiated", "encoding", c.reqEncoding, "previous", prev)
+		return true
+	}
+	return false
+}
+
+// encodeBody compresses data with the request coding if it is at least
+// Config.CompressMinSize bytes long and compression makes it smaller. It
+// returns the body and its coding, or "" if data is sent as is.
+func (c *ControlPlane) encodeBody(data []byte) ([]byte, string, error) {
+	enc := c.requestEncoding()
+	if enc == "" || int64(len(data)) < c.compressMinSize {
+		return data, "", nil
+	}
+	c.codecMu.RLock()
+	codec := c.codecs[enc]
+	c.codecMu.RUnlock()
+
+	var buf bytes.Buffer
+	w, err := codec.NewWriter(&buf)
+	if err != nil {
+		return nil, "", fmt.Errorf("api: %s compress request: %w", enc, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, "", fmt.Errorf("api: %s compress request: %w", enc, err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", fmt.Errorf("api: %s close: %w", enc, err)
+	}
+	if buf.Len() >= len(data) {
+		return data, "", nil
+	}
+	return buf.Bytes(), enc, nil
+}
+
+// decodeBody returns a reader of body decompressed according to the
+// Content-Encoding in h.
+func (c *ControlPlane) decodeBody(h http.Header, body io.Reader) (io.ReadCloser, error) {
+	enc := strings.ToLower(strings.TrimSpace(h.Get("Content-Encoding")))
+	if enc == "" || enc == EncodingIdentity {
+		return io.NopCloser(body), nil
+	}
+	c.codecMu.RLock()
+	codec, ok := c.codecs[enc]
+	c.codecMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("api: unsupported response Content-Encoding %q", enc)
+	}
+	r, err := codec.NewReader(body)
+	if err != nil {
+		return nil, fmt.Errorf("api: %s decompress response: %w", enc, err)
+	}
+	c.compression.compressedResponses.Add(1)
+	return r, nil
+}
+
+// countingReader counts the bytes read from r into n.
+type countingReader struct {
+	r io.Reader
+	n *atomic.Uint64
+}
+
+func (cr countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n.Add(uint64(n))
+	return n, err
+}
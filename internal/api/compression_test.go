@@ -0,0 +1,225 @@
+package api
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// deflateCodec is a Codec for the HTTP "deflate" coding, standing in for a
+// registered coding such as zstd.
+type deflateCodec struct{}
+
+func (deflateCodec) Encoding() string { return "deflate" }
+
+func (deflateCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriter(w), nil
+}
+
+func (deflateCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+// largeBody is a request body above the default CompressMinSize.
+var largeBody = map[string]string{"data": strings.Repeat("abcdefgh", 512)}
+
+// decodeRequest decodes the JSON body of r according to its
+// Content-Encoding.
+func decodeRequest(t *testing.T, r *http.Request, v any) {
+	t.Helper()
+	var body io.Reader = r.Body
+	switch r.Header.Get("Content-Encoding") {
+	case "gzip":
+		gr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("gzip.NewReader: %v", err)
+			return
+		}
+		body = gr
+	case "deflate":
+		zr, err := zlib.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("zlib.NewReader: %v", err)
+			return
+		}
+		body = zr
+	}
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		t.Errorf("decode request: %v", err)
+	}
+}
+
+func TestCompression_RegisteredCodecPreferred(t *testing.T) {
+	var gotEncoding, gotAccept string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEncoding = r.Header.Get("Content-Encoding")
+		gotAccept = r.Header.Get("Accept-Encoding")
+		var v map[string]string
+		decodeRequest(t, r, &v)
+
+		if !strings.HasPrefix(gotAccept, "deflate") {
+			json.NewEncoder(w).Encode(v)
+			return
+		}
+		w.Header().Set("Content-Encoding", "deflate")
+		zw := zlib.NewWriter(w)
+		json.NewEncoder(zw).Encode(v)
+		zw.Close()
+	}))
+	defer srv.Close()
+
+	c, err := NewControlPlane(Config{BaseURL: srv.URL, Encodings: []string{"deflate", "gzip"}}, "1.2.3", slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Before the codec is registered, gzip is the only usable coding.
+	var result map[string]string
+	if err := c.PostJSON(context.Background(), "/test", largeBody, &result); err != nil {
+		t.Fatalf("PostJSON: %v", err)
+	}
+	if gotEncoding != "gzip" || gotAccept != "gzip" {
+		t.Errorf("Content-Encoding = %q, Accept-Encoding = %q before RegisterCodec, want gzip", gotEncoding, gotAccept)
+	}
+
+	c.RegisterCodec(deflateCodec{})
+	if err := c.PostJSON(context.Background(), "/test", largeBody, &result); err != nil {
+		t.Fatalf("PostJSON: %v", err)
+	}
+	if gotEncoding != "deflate" || gotAccept != "deflate, gzip" {
+		t.Errorf("Content-Encoding = %q, Accept-Encoding = %q, want deflate preferred", gotEncoding, gotAccept)
+	}
+	if result["data"] != largeBody["data"] {
+		t.Error("deflate response not decoded")
+	}
+}
+
+func TestCompression_NegotiatesAdvertisedEncoding(t *testing.T) {
+	var encodings []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		// The control plane only accepts gzip request bodies.
+		w.Header().Set("Accept-Encoding", "gzip")
+		if r.Header.Get("Content-Encoding") == "deflate" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		var v map[string]string
+		decodeRequest(t, r, &v)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c, err := NewControlPlane(Config{BaseURL: srv.URL, Encodings: []string{"deflate", "gzip"}}, "1.2.3", slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.RegisterCodec(deflateCodec{})
+
+	for range 2 {
+		if err := c.PostJSON(context.Background(), "/test", largeBody, nil); err != nil {
+			t.Fatalf("PostJSON: %v", err)
+		}
+	}
+	want := []string{"deflate", "gzip", "gzip"}
+	if strings.Join(encodings, ",") != strings.Join(want, ",") {
+		t.Errorf("request encodings = %v, want %v", encodings, want)
+	}
+	if got := c.CompressionStats().RequestEncoding; got != "gzip" {
+		t.Errorf("RequestEncoding = %q, want gzip", got)
+	}
+}
+
+func TestCompression_UnsupportedFallsBackToIdentity(t *testing.T) {
+	var encodings []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		if r.Header.Get("Content-Encoding") != "" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := newTestClient(t, srv.URL)
+	for range 2 {
+		if err := c.PostJSON(context.Background(), "/test", largeBody, nil); err != nil {
+			t.Fatalf("PostJSON: %v", err)
+		}
+	}
+	want := []string{"gzip", "", ""}
+	if strings.Join(encodings, ",") != strings.Join(want, ",") {
+		t.Errorf("request encodings = %q, want %q", encodings, want)
+	}
+}
+
+func TestCompression_MinSizeAndDisable(t *testing.T) {
+	var gotEncoding, gotAccept string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEncoding = r.Header.Get("Content-Encoding")
+		gotAccept = r.Header.Get("Accept-Encoding")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c, err := NewControlPlane(Config{BaseURL: srv.URL, CompressMinSize: 64 << 10}, "1.2.3", slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PostJSON(context.Background(), "/test", largeBody, nil); err != nil {
+		t.Fatalf("PostJSON: %v", err)
+	}
+	if gotEncoding != "" {
+		t.Errorf("Content-Encoding = %q below CompressMinSize, want none", gotEncoding)
+	}
+
+	c, err = NewControlPlane(Config{BaseURL: srv.URL, DisableCompression: true}, "1.2.3", slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PostJSON(context.Background(), "/test", largeBody, nil); err != nil {
+		t.Fatalf("PostJSON: %v", err)
+	}
+	if gotEncoding != "" || gotAccept != "identity" {
+		t.Errorf("Content-Encoding = %q, Accept-Encoding = %q with compression disabled", gotEncoding, gotAccept)
+	}
+}
+
+func TestCompression_Stats(t *testing.T) {
+	payload, _ := json.Marshal(largeBody)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gw := gzip.NewWriter(w)
+		gw.Write(payload)
+		gw.Close()
+	}))
+	defer srv.Close()
+
+	c := newTestClient(t, srv.URL)
+	var result map[string]string
+	if err := c.PostJSON(context.Background(), "/test", largeBody, &result); err != nil {
+		t.Fatalf("PostJSON: %v", err)
+	}
+
+	s := c.CompressionStats()
+	if s.RequestEncoding != "gzip" || s.CompressedRequests != 1 || s.CompressedResponses != 1 {
+		t.Errorf("stats = %+v", s)
+	}
+	if s.RequestBytes != uint64(len(payload)) || s.RequestWireBytes >= s.RequestBytes {
+		t.Errorf("request bytes = %d, wire %d, want %d compressed", s.RequestBytes, s.RequestWireBytes, len(payload))
+	}
+	if s.ResponseBytes != uint64(len(payload)) || s.ResponseWireBytes >= s.ResponseBytes {
+		t.Errorf("response bytes = %d, wire %d", s.ResponseBytes, s.ResponseWireBytes)
+	}
+	want := int64(s.RequestBytes-s.RequestWireBytes) + int64(s.ResponseBytes-s.ResponseWireBytes)
+	if s.BytesSaved != want || s.BytesSaved <= 0 {
+		t.Errorf("BytesSaved = %d, want %d", s.BytesSaved, want)
+	}
+}
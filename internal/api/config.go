@@ -2,6 +2,8 @@ package api
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -20,16 +22,93 @@ type Config struct {
 	// Default: 10s
 	ConnectTimeout time.Duration
 
-	// RequestTimeout is the maximum time for a complete HTTP request/response cycle.
+	// RequestTimeout is the maximum time for a complete HTTP request/response
+	// cycle of endpoints without a timeout of their own. It does not apply to
+	// the SSE stream.
 	// Default: 30s
 	RequestTimeout time.Duration
 
+	// ResponseHeaderTimeout is the maximum time to wait for the response
+	// headers after the request was sent. It applies to all requests,
+	// including the SSE stream and artifact downloads.
+	// Default: 30s
+	ResponseHeaderTimeout time.Duration
+
+	// StateTimeout is the maximum time for FetchState, including reading
+	// the response. The desired state of a large mesh can take longer to
+	// transfer than other responses.
+	// Default: 2m
+	StateTimeout time.Duration
+
+	// ArtifactTimeout is the maximum time for FetchArtifact, including
+	// reading the whole artifact.
+	// Default: 10m
+	ArtifactTimeout time.Duration
+
+	// MaxResponseSize is the maximum size in bytes of a decompressed JSON
+	// response body, except for FetchState.
+	// Default: 10 MiB
+	MaxResponseSize int64
+
+	// MaxStateSize is the maximum size in bytes of the decompressed
+	// FetchState response body.
+	// Default: 64 MiB
+	MaxStateSize int64
+
+	// MaxArtifactSize is the maximum size in bytes of an artifact returned
+	// by FetchArtifact.
+	// Default: 512 MiB
+	MaxArtifactSize int64
+
 	// SSEIdleTimeout is the maximum time to wait for any data on the SSE stream
 	// before considering the connection stale and reconnecting.
 	// Default: 90s
 	SSEIdleTimeout time.Duration
+
+	// SubscribeAllEvents disables event type filtering: the SSE stream is
+	// opened without the types parameter, so the control plane sends every
+	// event type instead of only those the agent has handlers for.
+	// Default: false
+	SubscribeAllEvents bool
+
+	// EventTransport selects how events are received: EventTransportSSE,
+	// EventTransportWebSocket, or EventTransportAuto, which starts with SSE
+	// and switches to WebSocket when SSE fails repeatedly, for example
+	// behind proxies that buffer SSE.
+	// Default: "sse"
+	EventTransport string
+
+	// WebSocketFallbackAfter is the number of consecutive failed connections
+	// after which EventTransportAuto switches from one transport to the
+	// other.
+	// Default: 3
+	WebSocketFallbackAfter int
+
+	// Encodings lists the content codings negotiated for request and
+	// response bodies of JSON endpoints, in order of preference. gzip is
+	// built in; other codings, such as zstd, are used once a Codec for them
+	// is registered with ControlPlane.RegisterCodec.
+	// Default: ["gzip"]
+	Encodings []string
+
+	// CompressMinSize is the minimum size in bytes of a request body to be
+	// compressed. Smaller bodies are sent as is.
+	// Default: 1024
+	CompressMinSize int64
+
+	// DisableCompression sends request bodies uncompressed and asks for
+	// uncompressed responses.
+	// Default: false
+	DisableCompression bool
 }
 
+// Event transports for Config.EventTransport.
+const (
+	EventTransportSSE       = "sse"
+	EventTransportWebSocket = "websocket"
+	EventTransportAuto      = "auto"
+)
+
 // DefaultConnectTimeout is the default TCP connect timeout.
 const DefaultConnectTimeout = 10 * time.Second
 
@@ -39,6 +118,32 @@ const DefaultRequestTimeout = 30 * time.Second
 // DefaultSSEIdleTimeout is the default SSE idle timeout.
 const DefaultSSEIdleTimeout = 90 * time.Second
 
+// DefaultResponseHeaderTimeout is the default time to wait for response headers.
+const DefaultResponseHeaderTimeout = 30 * time.Second
+
+// DefaultStateTimeout is the default FetchState timeout.
+const DefaultStateTimeout = 2 * time.Minute
+
+// DefaultArtifactTimeout is the default FetchArtifact timeout.
+const DefaultArtifactTimeout = 10 * time.Minute
+
+// DefaultMaxResponseSize is the default JSON response size limit (10 MiB).
+const DefaultMaxResponseSize = 10 << 20
+
+// DefaultMaxStateSize is the default FetchState response size limit (64 MiB).
+const DefaultMaxStateSize = 64 << 20
+
+// DefaultMaxArtifactSize is the default artifact size limit (512 MiB).
+const DefaultMaxArtifactSize = 512 << 20
+
+// DefaultWebSocketFallbackAfter is the default number of failed connections
+// before EventTransportAuto switches transports.
+const DefaultWebSocketFallbackAfter = 3
+
+// DefaultCompressMinSize is the default minimum size of a compressed
+// request body (1 KiB).
+const DefaultCompressMinSize = 1024
+
 // ApplyDefaults sets default values for zero-valued fields.
 func (c *Config) ApplyDefaults() {
 	if c.ConnectTimeout == 0 {
@@ -50,12 +155,64 @@ func (c *Config) ApplyDefaults() {
 	if c.SSEIdleTimeout == 0 {
 		c.SSEIdleTimeout = DefaultSSEIdleTimeout
 	}
+	if c.ResponseHeaderTimeout == 0 {
+		c.ResponseHeaderTimeout = DefaultResponseHeaderTimeout
+	}
+	if c.StateTimeout == 0 {
+		c.StateTimeout = DefaultStateTimeout
+	}
+	if c.ArtifactTimeout == 0 {
+		c.ArtifactTimeout = DefaultArtifactTimeout
+	}
+	if c.MaxResponseSize == 0 {
+		c.MaxResponseSize = DefaultMaxResponseSize
+	}
+	if c.MaxStateSize == 0 {
+		c.MaxStateSize = DefaultMaxStateSize
+	}
+	if c.MaxArtifactSize == 0 {
+		c.MaxArtifactSize = DefaultMaxArtifactSize
+	}
+	if c.EventTransport == "" {
+		c.EventTransport = EventTransportSSE
+	}
+	if c.WebSocketFallbackAfter == 0 {
+		c.WebSocketFallbackAfter = DefaultWebSocketFallbackAfter
+	}
+	if c.Encodings == nil {
+		c.Encodings = []string{EncodingGzip}
+	}
+	if c.CompressMinSize == 0 {
+		c.CompressMinSize = DefaultCompressMinSize
+	}
 }
 
-// Validate checks that required fields are set.
+// Validate checks that required fields are set and limits are not negative.
 func (c *Config) Validate() error {
 	if c.BaseURL == "" {
 		return errors.New("api: config: BaseURL is required")
 	}
+	if c.RequestTimeout < 0 || c.ResponseHeaderTimeout < 0 || c.StateTimeout < 0 || c.ArtifactTimeout < 0 {
+		return errors.New("api: config: timeouts must not be negative")
+	}
+	if c.MaxResponseSize < 0 || c.MaxStateSize < 0 || c.MaxArtifactSize < 0 {
+		return errors.New("api: config: response size limits must not be negative")
+	}
+	switch c.EventTransport {
+	case "", EventTransportSSE, EventTransportWebSocket, EventTransportAuto:
+	default:
+		return fmt.Errorf("api: config: unknown EventTransport %q", c.EventTransport)
+	}
+	if c.WebSocketFallbackAfter < 0 {
+		return errors.New("api: config: WebSocketFallbackAfter must not be negative")
+	}
+	for _, e := range c.Encodings {
+		if e == "" || e == EncodingIdentity || e != strings.ToLower(e) || strings.ContainsAny(e, ",; \t") {
+			return fmt.Errorf("api: config: invalid encoding %q", e)
+		}
+	}
+	if c.CompressMinSize < 0 {
+		return errors.New("api: config: CompressMinSize must not be negative")
+	}
 	return nil
 }
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"fmt"
 	"testing"
 	"time"
 )
@@ -21,6 +22,30 @@ func TestConfig_Defaults(t *testing.T) {
 	if cfg.TLSInsecureSkipVerify {
 		t.Error("TLSInsecureSkipVerify = true, want false")
 	}
+	if cfg.ResponseHeaderTimeout != DefaultResponseHeaderTimeout {
+		t.Errorf("ResponseHeaderTimeout = %v, want %v", cfg.ResponseHeaderTimeout, DefaultResponseHeaderTimeout)
+	}
+	if cfg.StateTimeout != DefaultStateTimeout {
+		t.Errorf("StateTimeout = %v, want %v", cfg.StateTimeout, DefaultStateTimeout)
+	}
+	if cfg.ArtifactTimeout != DefaultArtifactTimeout {
+		t.Errorf("ArtifactTimeout = %v, want %v", cfg.ArtifactTimeout, DefaultArtifactTimeout)
+	}
+	if cfg.MaxResponseSize != DefaultMaxResponseSize {
+		t.Errorf("MaxResponseSize = %d, want %d", cfg.MaxResponseSize, DefaultMaxResponseSize)
+	}
+	if cfg.MaxStateSize != DefaultMaxStateSize {
+		t.Errorf("MaxStateSize = %d, want %d", cfg.MaxStateSize, DefaultMaxStateSize)
+	}
+	if cfg.MaxArtifactSize != DefaultMaxArtifactSize {
+		t.Errorf("MaxArtifactSize = %d, want %d", cfg.MaxArtifactSize, DefaultMaxArtifactSize)
+	}
+	if cfg.EventTransport != EventTransportSSE {
+		t.Errorf("EventTransport = %q, want %q", cfg.EventTransport, EventTransportSSE)
+	}
+	if cfg.WebSocketFallbackAfter != DefaultWebSocketFallbackAfter {
+		t.Errorf("WebSocketFallbackAfter = %d, want %d", cfg.WebSocketFallbackAfter, DefaultWebSocketFallbackAfter)
+	}
 }
 
 func TestConfig_DefaultsPreserveExisting(t *testing.T) {
@@ -58,3 +83,66 @@ func TestConfig_ValidateAcceptsValidURL(t *testing.T) {
 		t.Errorf("Validate() = %v, want nil", err)
 	}
 }
+
+func TestConfig_ValidateRejectsNegativeLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		mod  func(*Config)
+		want string
+	}{
+		{"request timeout", func(c *Config) { c.RequestTimeout = -time.Second }, "api: config: timeouts must not be negative"},
+		{"state timeout", func(c *Config) { c.StateTimeout = -time.Second }, "api: config: timeouts must not be negative"},
+		{"artifact timeout", func(c *Config) { c.ArtifactTimeout = -time.Second }, "api: config: timeouts must not be negative"},
+		{"response size", func(c *Config) { c.MaxResponseSize = -1 }, "api: config: response size limits must not be negative"},
+		{"artifact size", func(c *Config) { c.MaxArtifactSize = -1 }, "api: config: response size limits must not be negative"},
+		{"fallback after", func(c *Config) { c.WebSocketFallbackAfter = -1 }, "api: config: WebSocketFallbackAfter must not be negative"},
+		{"compress min size", func(c *Config) { c.CompressMinSize = -1 }, "api: config: CompressMinSize must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{BaseURL: "https://api.example.com"}
+			tt.mod(&cfg)
+			err := cfg.Validate()
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Validate() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateRejectsUnknownEventTransport(t *testing.T) {
+	cfg := Config{BaseURL: "https://api.example.com", EventTransport: "grpc"}
+	err := cfg.Validate()
+	if err == nil || err.Error() != `api: config: unknown EventTransport "grpc"` {
+		t.Errorf("Validate() = %v, want unknown EventTransport error", err)
+	}
+	for _, transport := range []string{EventTransportSSE, EventTransportWebSocket, EventTransportAuto} {
+		cfg.EventTransport = transport
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Validate() with %q = %v, want nil", transport, err)
+		}
+	}
+}
+
+func TestConfig_Encodings(t *testing.T) {
+	cfg := Config{BaseURL: "https://api.example.com"}
+	cfg.ApplyDefaults()
+	if len(cfg.Encodings) != 1 || cfg.Encodings[0] != EncodingGzip {
+		t.Errorf("Encodings = %v, want [gzip]", cfg.Encodings)
+	}
+	if cfg.CompressMinSize != DefaultCompressMinSize {
+		t.Errorf("CompressMinSize = %d, want %d", cfg.CompressMinSize, DefaultCompressMinSize)
+	}
+
+	cfg.Encodings = []string{"zstd", "gzip"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+	for _, e := range []string{"", "identity", "GZIP", "zstd, gzip"} {
+		cfg.Encodings = []string{e}
+		want := fmt.Sprintf("api: config: invalid encoding %q", e)
+		if err := cfg.Validate(); err == nil || err.Error() != want {
+			t.Errorf("Validate() with %q = %v, want %q", e, err, want)
+		}
+	}
+}
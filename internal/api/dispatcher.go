@@ -3,6 +3,8 @@ package api
 import (
 	"context"
 	"log/slog"
+	"maps"
+	"slices"
 	"sync"
 )
 
@@ -11,16 +13,19 @@ type EventHandler func(ctx context.Context, envelope SignedEnvelope) error
 
 // EventDispatcher routes verified events to registered handlers by event type.
 type EventDispatcher struct {
-	mu       sync.RWMutex
-	handlers map[string][]EventHandler
-	logger   *slog.Logger
+	mu         sync.RWMutex
+	handlers   map[string][]EventHandler
+	unexpected map[string]uint64
+	tracker    CauseTracker
+	logger     *slog.Logger
 }
 
 // NewEventDispatcher creates a new EventDispatcher.
 func NewEventDispatcher(logger *slog.Logger) *EventDispatcher {
 	return &EventDispatcher{
-		handlers: make(map[string][]EventHandler),
-		logger:   logger,
+		handlers:   make(map[string][]EventHandler),
+		unexpected: make(map[string]uint64),
+		logger:     logger,
 	}
 }
 
@@ -32,22 +37,62 @@ func (d *EventDispatcher) Register(eventType string, handler EventHandler) {
 	d.handlers[eventType] = append(d.handlers[eventType], handler)
 }
 
-// Dispatch invokes all handlers registered for the event's type.
+// SetCauseTracker sets the tracker told about every event while its
+// handlers run.
+func (d *EventDispatcher) SetCauseTracker(t CauseTracker) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.tracker = t
+}
+
+// Types returns the event types with at least one handler, sorted.
+func (d *EventDispatcher) Types() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return slices.Sorted(maps.Keys(d.handlers))
+}
+
+// Unexpected returns the number of discarded events without a handler,
+// by event type.
+func (d *EventDispatcher) Unexpected() map[string]uint64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return maps.Clone(d.unexpected)
+}
+
+// Dispatch invokes all handlers registered for the event's type. The
+// handlers' context carries the event as a CauseEvent.
 // Handler errors are logged but do not stop processing of subsequent handlers.
-// Events with no registered handler are logged at debug level and discarded.
+// Events with no registered handler are counted and discarded; the first
+// event of each such type is logged at warn level, later ones at debug.
 func (d *EventDispatcher) Dispatch(ctx context.Context, envelope SignedEnvelope) {
 	d.mu.RLock()
 	handlers, ok := d.handlers[envelope.EventType]
+	tracker := d.tracker
 	d.mu.RUnlock()
 
 	if !ok || len(handlers) == 0 {
-		d.logger.Debug("no handler registered for event type",
+		d.mu.Lock()
+		d.unexpected[envelope.EventType]++
+		first := d.unexpected[envelope.EventType] == 1
+		d.mu.Unlock()
+		log := d.logger.Debug
+		if first {
+			log = d.logger.Warn
+		}
+		log("no handler registered for event type",
 			"event_type", envelope.EventType,
 			"event_id", envelope.EventID,
 		)
 		return
 	}
 
+	cause := Cause{Type: CauseEvent, ID: envelope.EventID, EventType: envelope.EventType}
+	ctx = WithCause(ctx, cause)
+	if tracker != nil {
+		defer tracker.Track(cause)()
+	}
+
 	for i, handler := range handlers {
 		if err := handler(ctx, envelope); err != nil {
 			d.logger.Error("event handler failed",
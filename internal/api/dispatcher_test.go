@@ -171,3 +171,75 @@ func TestDispatcher_ConcurrentRegisterAndDispatch(t *testing.T) {
 		t.Fatal("expected at least some handlers to be called")
 	}
 }
+
+func TestDispatcher_TypesAndUnexpected(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	d := NewEventDispatcher(logger)
+	noop := func(context.Context, SignedEnvelope) error { return nil }
+	d.Register("policy_updated", noop)
+	d.Register("peer_added", noop)
+	d.Register("peer_added", noop)
+
+	if got := d.Types(); len(got) != 2 || got[0] != "peer_added" || got[1] != "policy_updated" {
+		t.Errorf("Types() = %v, want [peer_added policy_updated]", got)
+	}
+
+	d.Dispatch(context.Background(), SignedEnvelope{EventType: "peer_added"})
+	d.Dispatch(context.Background(), SignedEnvelope{EventType: "relay_assigned"})
+	d.Dispatch(context.Background(), SignedEnvelope{EventType: "relay_assigned"})
+
+	got := d.Unexpected()
+	if len(got) != 1 || got["relay_assigned"] != 2 {
+		t.Errorf("Unexpected() = %v, want map[relay_assigned:2]", got)
+	}
+}
+
+// recordingTracker records tracked causes and whether they ended.
+type recordingTracker struct {
+	mu      sync.Mutex
+	tracked []Cause
+	ended   int
+}
+
+func (r *recordingTracker) Track(c Cause) func() {
+	r.mu.Lock()
+	r.tracked = append(r.tracked, c)
+	r.mu.Unlock()
+	return func() {
+		r.mu.Lock()
+		r.ended++
+		r.mu.Unlock()
+	}
+}
+
+func TestDispatcher_Cause(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	d := NewEventDispatcher(logger)
+	tracker := &recordingTracker{}
+	d.SetCauseTracker(tracker)
+
+	var got Cause
+	var ok bool
+	d.Register("peer_added", func(ctx context.Context, _ SignedEnvelope) error {
+		got, ok = CauseFromContext(ctx)
+		tracker.mu.Lock()
+		defer tracker.mu.Unlock()
+		if tracker.ended != 0 {
+			t.Error("cause ended before the handler returned")
+		}
+		return nil
+	})
+
+	d.Dispatch(context.Background(), SignedEnvelope{EventType: "peer_added", EventID: "evt_001"})
+
+	want := Cause{Type: CauseEvent, ID: "evt_001", EventType: "peer_added"}
+	if !ok || got != want {
+		t.Fatalf("handler cause = %+v (%v), want %+v", got, ok, want)
+	}
+	if len(tracker.tracked) != 1 || tracker.tracked[0] != want {
+		t.Errorf("tracked = %+v, want [%+v]", tracker.tracked, want)
+	}
+	if tracker.ended != 1 {
+		t.Errorf("ended = %d, want 1", tracker.ended)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 // Register sends a registration request to the control plane.
@@ -18,11 +19,13 @@ func (c *ControlPlane) Register(ctx context.Context, req RegisterRequest) (*Regi
 	return &resp, nil
 }
 
-// ConnectSSE opens an SSE connection to the node event stream.
+// ConnectSSE opens an SSE connection to the node event stream. If types is
+// non-empty and Config.SubscribeAllEvents is false, the control plane is
+// asked to send only events of those types.
 // The caller is responsible for closing the response body.
-// GET /v1/nodes/{node_id}/events
-func (c *ControlPlane) ConnectSSE(ctx context.Context, nodeID, lastEventID string) (*http.Response, error) {
-	path := fmt.Sprintf("/v1/nodes/%s/events", url.PathEscape(nodeID))
+// GET /v1/nodes/{node_id}/events[?types=a,b]
+func (c *ControlPlane) ConnectSSE(ctx context.Context, nodeID, lastEventID string, types []string) (*http.Response, error) {
+	path := fmt.Sprintf("/v1/nodes/%s/events", url.PathEscape(nodeID)) + c.eventTypesQuery(types)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("api: create SSE request: %w", err)
@@ -51,6 +54,92 @@ func (c *ControlPlane) ConnectSSE(ctx context.Context, nodeID, lastEventID strin
 	return resp, nil
 }
 
+// ConnectWebSocket opens a WebSocket connection to the node event stream.
+// Each message is a SignedEnvelope, as in the data field of an SSE event.
+// The types filter and Last-Event-ID work as for ConnectSSE. The caller is
+// responsible for closing the connection.
+// GET /v1/nodes/{node_id}/events/ws[?types=a,b]
+func (c *ControlPlane) ConnectWebSocket(ctx context.Context, nodeID, lastEventID string, types []string) (*WebSocketConn, error) {
+	path := fmt.Sprintf("/v1/nodes/%s/events/ws", url.PathEscape(nodeID)) + c.eventTypesQuery(types)
+	header := http.Header{}
+	if lastEventID != "" {
+		header.Set("Last-Event-ID", lastEventID)
+	}
+	return c.dialWebSocket(ctx, path, header)
+}
+
+// ConnectRelayWebSocket opens a WebSocket connection to the control plane's
+// packet relay, used when no UDP path to a peer works. Each binary message
+// carries one WireGuard packet, addressed to or received from a peer (see
+// package wsrelay). The caller is responsible for closing the connection.
+// GET /v1/nodes/{node_id}/relay/ws
+func (c *ControlPlane) ConnectRelayWebSocket(ctx context.Context, nodeID string) (*WebSocketConn, error) {
+	path := fmt.Sprintf("/v1/nodes/%s/relay/ws", url.PathEscape(nodeID))
+	return c.dialWebSocket(ctx, path, nil)
+}
+
+// dialWebSocket performs the WebSocket handshake on path, with the
+// authentication and user agent headers plus header.
+func (c *ControlPlane) dialWebSocket(ctx context.Context, path string, header http.Header) (*WebSocketConn, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("api: create websocket request: %w", err)
+	}
+
+	key, err := newWebSocketKey()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	if token := c.getAuthToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.Header.Set("User-Agent", userAgentPrefix+c.version)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("api: websocket connect: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusSwitchingProtocols:
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		defer resp.Body.Close()
+		return nil, errorFromResponse(resp)
+	default:
+		// A proxy that does not pass the upgrade through.
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: status %d instead of upgrade", ErrWebSocketProtocol, resp.StatusCode)
+	}
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(key) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: invalid upgrade response", ErrWebSocketProtocol)
+	}
+	rwc, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: connection not writable", ErrWebSocketProtocol)
+	}
+
+	return newWebSocketConn(rwc, c.defaultLimits.maxSize), nil
+}
+
+// eventTypesQuery returns the query string selecting the given event types,
+// or an empty string if all types are subscribed.
+func (c *ControlPlane) eventTypesQuery(types []string) string {
+	if len(types) == 0 || c.subscribeAll {
+		return ""
+	}
+	return "?" + url.Values{"types": {strings.Join(types, ",")}}.Encode()
+}
+
 // Heartbeat sends a heartbeat to the control plane.
 // POST /v1/nodes/{node_id}/heartbeat
 func (c *ControlPlane) Heartbeat(ctx context.Context, nodeID string, req HeartbeatRequest) (*HeartbeatResponse, error) {
@@ -97,12 +186,13 @@ func (c *ControlPlane) ReportEndpoint(ctx context.Context, nodeID string, req En
 	return &resp, nil
 }
 
-// FetchState retrieves the full desired state for a node.
+// FetchState retrieves the full desired state for a node. It is bounded by
+// Config.StateTimeout and Config.MaxStateSize.
 // GET /v1/nodes/{node_id}/state
 func (c *ControlPlane) FetchState(ctx context.Context, nodeID string) (*StateResponse, error) {
 	var resp StateResponse
 	path := fmt.Sprintf("/v1/nodes/%s/state", url.PathEscape(nodeID))
-	if err := c.doRequest(ctx, http.MethodGet, path, nil, &resp); err != nil {
+	if err := c.doRequestWithLimits(ctx, c.stateLimits, http.MethodGet, path, nil, &resp); err != nil {
 		return nil, err
 	}
 	return &resp, nil
@@ -169,15 +259,29 @@ func (c *ControlPlane) ReportAudit(ctx context.Context, nodeID string, batch Aud
 }
 
 // FetchArtifact downloads a plexd binary artifact.
-// The caller is responsible for closing the returned ReadCloser.
+// The caller is responsible for closing the returned ReadCloser. The whole
+// download, including reading the body, is bounded by Config.ArtifactTimeout;
+// reading more than Config.MaxArtifactSize bytes fails with
+// ErrResponseTooLarge.
 // GET /v1/artifacts/plexd/{version}/{os}/{arch}
 func (c *ControlPlane) FetchArtifact(ctx context.Context, version, goos, arch string) (io.ReadCloser, error) {
 	path := fmt.Sprintf("/v1/artifacts/plexd/%s/%s/%s", url.PathEscape(version), url.PathEscape(goos), url.PathEscape(arch))
+	ctx, cancel := context.WithTimeout(ctx, c.artifactLimits.timeout)
 	resp, err := c.doRequestRaw(ctx, http.MethodGet, path, nil)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
-	return resp.Body, nil
+	if resp.ContentLength > c.artifactLimits.maxSize {
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("api: fetch artifact: %d bytes: %w", resp.ContentLength, ErrResponseTooLarge)
+	}
+	return &streamBody{
+		Reader: &maxBytesReader{r: resp.Body, n: c.artifactLimits.maxSize},
+		body:   resp.Body,
+		cancel: cancel,
+	}, nil
 }
 
 // TunnelReady reports that a tunnel listener is ready for connections.
@@ -194,6 +298,21 @@ func (c *ControlPlane) TunnelClosed(ctx context.Context, nodeID, sessionID strin
 	return c.doRequest(ctx, http.MethodPost, path, req, nil)
 }
 
+// UploadTunnelRecording uploads the recording of a closed tunnel session.
+// POST /v1/nodes/{node_id}/tunnels/{session_id}/recording
+func (c *ControlPlane) UploadTunnelRecording(ctx context.Context, nodeID, sessionID string, req TunnelRecording) error {
+	path := fmt.Sprintf("/v1/nodes/%s/tunnels/%s/recording", url.PathEscape(nodeID), url.PathEscape(sessionID))
+	return c.doRequest(ctx, http.MethodPost, path, req, nil)
+}
+
+// RelaySessionClosed reports that a relay session has closed and how much
+// traffic it relayed.
+// POST /v1/nodes/{node_id}/relay/sessions/{session_id}/closed
+func (c *ControlPlane) RelaySessionClosed(ctx context.Context, nodeID, sessionID string, req RelaySessionClosedRequest) error {
+	path := fmt.Sprintf("/v1/nodes/%s/relay/sessions/%s/closed", url.PathEscape(nodeID), url.PathEscape(sessionID))
+	return c.doRequest(ctx, http.MethodPost, path, req, nil)
+}
+
 // ReportIntegrityViolation reports a file integrity violation to the control plane.
 // POST /v1/nodes/{node_id}/integrity/violations
 func (c *ControlPlane) ReportIntegrityViolation(ctx context.Context, nodeID string, req IntegrityViolationReport) error {
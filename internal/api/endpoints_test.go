@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
 	"net/http"
@@ -205,6 +206,132 @@ func TestFetchArtifact_ReturnsBinaryStream(t *testing.T) {
 	}
 }
 
+func TestFetchState_UsesStateSizeLimit(t *testing.T) {
+	peers := make([]Peer, 20)
+	for i := range peers {
+		peers[i] = Peer{ID: strings.Repeat("p", 50)}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(StateResponse{Peers: peers})
+	}))
+	t.Cleanup(srv.Close)
+
+	// The state is larger than MaxResponseSize but within MaxStateSize.
+	client, err := NewControlPlane(Config{BaseURL: srv.URL, MaxResponseSize: 100, MaxStateSize: 1 << 20}, "1.0.0-test", slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := client.FetchState(context.Background(), "n1")
+	if err != nil {
+		t.Fatalf("FetchState: %v", err)
+	}
+	if len(state.Peers) != len(peers) {
+		t.Errorf("len(Peers) = %d, want %d", len(state.Peers), len(peers))
+	}
+
+	client, err = NewControlPlane(Config{BaseURL: srv.URL, MaxStateSize: 100}, "1.0.0-test", slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.FetchState(context.Background(), "n1"); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("FetchState error = %v, want ErrResponseTooLarge", err)
+	}
+}
+
+func TestFetchArtifact_TooLarge(t *testing.T) {
+	content := strings.Repeat("b", 64)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if strings.HasPrefix(r.URL.Path, "/chunked/") {
+			// Flushing before the body forces chunked encoding.
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+		}
+		_, _ = io.WriteString(w, content)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := NewControlPlane(Config{BaseURL: srv.URL, MaxArtifactSize: 32}, "1.0.0-test", slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A declared Content-Length over the limit is rejected before reading.
+	if _, err := client.FetchArtifact(context.Background(), "1.0.0", "linux", "amd64"); !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("FetchArtifact error = %v, want ErrResponseTooLarge", err)
+	}
+
+	// A body without Content-Length fails while reading.
+	client.baseURL = srv.URL + "/chunked"
+	rc, err := client.FetchArtifact(context.Background(), "1.0.0", "linux", "amd64")
+	if err != nil {
+		t.Fatalf("FetchArtifact: %v", err)
+	}
+	defer rc.Close()
+	if _, err := io.ReadAll(rc); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("ReadAll error = %v, want ErrResponseTooLarge", err)
+	}
+}
+
+func TestFetchArtifact_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, "partial")
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+
+	client, err := NewControlPlane(Config{BaseURL: srv.URL, ArtifactTimeout: 100 * time.Millisecond}, "1.0.0-test", slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := client.FetchArtifact(context.Background(), "1.0.0", "linux", "amd64")
+	if err != nil {
+		t.Fatalf("FetchArtifact: %v", err)
+	}
+	defer rc.Close()
+	if _, err := io.ReadAll(rc); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ReadAll error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestConnectSSE_NotLimitedByRequestTimeout(t *testing.T) {
+	client, err := NewControlPlane(Config{BaseURL: "http://unused", RequestTimeout: 50 * time.Millisecond}, "1.0.0-test", slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte("data: late\n\n"))
+	}))
+	t.Cleanup(srv.Close)
+	client.baseURL = srv.URL
+
+	resp, err := client.ConnectSSE(context.Background(), "n1", "", nil)
+	if err != nil {
+		t.Fatalf("ConnectSSE: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(body) != "data: late\n\n" {
+		t.Errorf("body = %q, want %q", body, "data: late\n\n")
+	}
+}
+
 func TestDeregister_Success(t *testing.T) {
 	client, _ := newEndpointTestClient(t, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -563,7 +690,7 @@ func TestConnectSSE_SetsHeaders(t *testing.T) {
 		_, _ = w.Write([]byte("data: hello\n\n"))
 	})
 
-	resp, err := client.ConnectSSE(context.Background(), "n1", "evt-42")
+	resp, err := client.ConnectSSE(context.Background(), "n1", "evt-42", nil)
 	if err != nil {
 		t.Fatalf("ConnectSSE: %v", err)
 	}
@@ -588,6 +715,43 @@ func TestConnectSSE_SetsHeaders(t *testing.T) {
 	}
 }
 
+func TestConnectSSE_EventTypes(t *testing.T) {
+	var gotTypes string
+	var hasTypes bool
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		gotTypes = r.URL.Query().Get("types")
+		hasTypes = r.URL.Query().Has("types")
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+	}
+	types := []string{"peer_added", "policy_updated"}
+
+	client, _ := newEndpointTestClient(t, handler)
+	resp, err := client.ConnectSSE(context.Background(), "n1", "", types)
+	if err != nil {
+		t.Fatalf("ConnectSSE: %v", err)
+	}
+	resp.Body.Close()
+	if gotTypes != "peer_added,policy_updated" {
+		t.Errorf("types = %q, want %q", gotTypes, "peer_added,policy_updated")
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(srv.Close)
+	client, err = NewControlPlane(Config{BaseURL: srv.URL, SubscribeAllEvents: true}, "1.0.0-test", slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = client.ConnectSSE(context.Background(), "n1", "", types)
+	if err != nil {
+		t.Fatalf("ConnectSSE: %v", err)
+	}
+	resp.Body.Close()
+	if hasTypes {
+		t.Errorf("types = %q sent with SubscribeAllEvents", gotTypes)
+	}
+}
+
 func TestEndpoints_PathParametersEscaped(t *testing.T) {
 	// Verify that special characters in path parameters are properly escaped.
 	maliciousNodeID := "../../../etc/passwd"
@@ -679,6 +843,73 @@ func TestTunnelClosed_Success(t *testing.T) {
 	}
 }
 
+func TestRelaySessionClosed_Success(t *testing.T) {
+	client, _ := newEndpointTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		if r.URL.Path != "/v1/nodes/n-001/relay/sessions/relay-001/closed" {
+			t.Errorf("path = %s, want /v1/nodes/n-001/relay/sessions/relay-001/closed", r.URL.Path)
+		}
+
+		var req RelaySessionClosedRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if req.Reason != RelayCloseQuotaExceeded || req.BytesAToB != 1024 {
+			t.Errorf("request = %+v, want quota_exceeded with 1024 bytes A->B", req)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	err := client.RelaySessionClosed(context.Background(), "n-001", "relay-001", RelaySessionClosedRequest{
+		Reason:    RelayCloseQuotaExceeded,
+		BytesAToB: 1024,
+		BytesBToA: 512,
+		Duration:  "1m0s",
+		Timestamp: time.Now().UTC().Truncate(time.Second),
+	})
+	if err != nil {
+		t.Fatalf("RelaySessionClosed() = %v", err)
+	}
+}
+
+func TestUploadTunnelRecording_Success(t *testing.T) {
+	client, _ := newEndpointTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		if r.URL.Path != "/v1/nodes/n-001/tunnels/sess-001/recording" {
+			t.Errorf("path = %s, want /v1/nodes/n-001/tunnels/sess-001/recording", r.URL.Path)
+		}
+
+		var req TunnelRecording
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if req.Format != TunnelRecordingFormat {
+			t.Errorf("format = %q, want %q", req.Format, TunnelRecordingFormat)
+		}
+		if req.TriggeredBy == nil || req.TriggeredBy.Email != "ops@example.com" {
+			t.Errorf("triggered_by = %+v, want ops@example.com", req.TriggeredBy)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	err := client.UploadTunnelRecording(context.Background(), "n-001", "sess-001", TunnelRecording{
+		Format:      TunnelRecordingFormat,
+		Data:        "{\"version\":2,\"width\":80,\"height\":24}\n",
+		StartedAt:   time.Now().UTC(),
+		Duration:    "12s",
+		TriggeredBy: &TriggeredBy{Type: "user", Email: "ops@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("UploadTunnelRecording() = %v", err)
+	}
+}
+
 func TestFetchSecret_PathParametersEscaped(t *testing.T) {
 	// Verify both nodeID and key parameters are escaped.
 	var gotPath string
@@ -20,6 +20,7 @@ const (
 	EventActionRequest       = "action_request"
 	EventSessionRevoked      = "session_revoked"
 	EventSSHSessionSetup     = "ssh_session_setup"
+	EventPortForwardSetup    = "port_forward_setup"
 	EventRotateKeys          = "rotate_keys"
 	EventSigningKeyRotated   = "signing_key_rotated"
 	EventNodeStateUpdated    = "node_state_updated"
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
 	"testing"
 	"time"
@@ -167,6 +168,51 @@ func TestParseEnvelope_PreservesPayload(t *testing.T) {
 	}
 }
 
+// FuzzParseEnvelope parses envelopes as received on the event stream. A
+// parsed envelope must survive re-encoding with its signature intact, and
+// verification must not panic for any signature or signing key.
+func FuzzParseEnvelope(f *testing.F) {
+	f.Add([]byte(`{"event_type":"peer_added","event_id":"evt_1","issued_at":"2026-01-02T03:04:05Z","nonce":"n1","payload":{"id":"p1"},"signature":"c2ln"}`), []byte{})
+	f.Add([]byte(`{"event_type":"rotate_keys","event_id":"e","payload":[ 1, 2 ,3 ],"signature":"!!"}`), make([]byte, ed25519.PublicKeySize))
+	f.Add([]byte(`{"event_type":"\u00e9\ud800","event_id":"\u0000","payload":null}`), []byte("short key"))
+	f.Fuzz(func(t *testing.T, data, key []byte) {
+		env, err := ParseEnvelope(data)
+		if err != nil {
+			return
+		}
+		if env.EventType == "" || env.EventID == "" {
+			t.Fatalf("ParseEnvelope accepted %q without event_type or event_id", data)
+		}
+
+		// An arbitrary key and signature must be rejected, not panic.
+		v := NewEd25519Verifier(ed25519.PublicKey(key))
+		v.SetKeys(ed25519.PublicKey(key), ed25519.PublicKey(key), time.Now().Add(time.Hour))
+		if v.Verify(context.Background(), env) == nil && len(key) != ed25519.PublicKeySize {
+			t.Fatalf("Verify accepted an envelope with a %d-byte key", len(key))
+		}
+		if v.VerifySignature(env.Payload, env.Signature) == nil && len(key) != ed25519.PublicKeySize {
+			t.Fatalf("VerifySignature accepted a %d-byte key", len(key))
+		}
+
+		// Signed by the control plane and sent again, the envelope verifies.
+		pub, priv := generateKey(t)
+		signed := signEnvelope(t, priv, env.EventType, env.EventID, "nonce-"+env.EventID, time.Now(), env.Payload)
+		raw, err := json.Marshal(signed)
+		if err != nil {
+			// Payload is not valid JSON, so the control plane could not
+			// have signed it either.
+			return
+		}
+		received, err := ParseEnvelope(raw)
+		if err != nil {
+			t.Fatalf("ParseEnvelope(%s): %v", raw, err)
+		}
+		if err := NewEd25519Verifier(pub).Verify(context.Background(), received); err != nil {
+			t.Fatalf("Verify re-encoded envelope %s: %v", raw, err)
+		}
+	})
+}
+
 // strContains reports whether s contains substr.
 func strContains(s, substr string) bool {
 	for i := 0; i <= len(s)-len(substr); i++ {
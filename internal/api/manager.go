@@ -19,6 +19,8 @@ type SSEManager struct {
 	mu       sync.Mutex
 	cancel   context.CancelFunc
 	pollFunc PollFunc
+	stream   *SSEStream
+	running  bool
 }
 
 // NewSSEManager creates a new SSEManager. If verifier is nil, NoOpVerifier is used.
@@ -41,6 +43,12 @@ func (m *SSEManager) RegisterHandler(eventType string, handler EventHandler) {
 	m.dispatcher.Register(eventType, handler)
 }
 
+// SetCauseTracker sets the tracker told about every event while its
+// handlers run. Must be called before Start.
+func (m *SSEManager) SetCauseTracker(t CauseTracker) {
+	m.dispatcher.SetCauseTracker(t)
+}
+
 // SetReconnectIntervals configures the base and max backoff intervals.
 // Useful for testing with fast intervals.
 func (m *SSEManager) SetReconnectIntervals(base, max time.Duration) {
@@ -64,13 +72,23 @@ func (m *SSEManager) SetPollFunc(fn PollFunc) {
 // permanent error occurs.
 func (m *SSEManager) Start(ctx context.Context, nodeID string) error {
 	ctx, cancel := context.WithCancel(ctx)
+	stream := NewSSEStream(m.client, m.verifier, m.dispatcher, 90*time.Second, m.logger)
 	m.mu.Lock()
 	m.cancel = cancel
+	m.stream = stream
+	m.running = true
 	pollFn := m.pollFunc
 	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.running = false
+		m.mu.Unlock()
+	}()
 	defer cancel()
 
-	stream := NewSSEStream(m.client, m.verifier, m.dispatcher, 90*time.Second, m.logger)
+	if !m.client.subscribeAll {
+		m.logger.Debug("SSE event types subscribed", "types", m.dispatcher.Types())
+	}
 
 	connectFn := func(ctx context.Context) error {
 		return stream.Connect(ctx, nodeID)
@@ -86,6 +104,83 @@ func (m *SSEManager) Start(ctx context.Context, nodeID string) error {
 	return m.reconnect.Run(ctx, connectFn, pollFn)
 }
 
+// Running reports whether the connection loop is active, either connected,
+// reconnecting, or polling. It is false before Start and after Start returns.
+func (m *SSEManager) Running() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.running
+}
+
+// Connected reports whether the event stream currently holds an open
+// connection.
+func (m *SSEManager) Connected() bool {
+	m.mu.Lock()
+	stream := m.stream
+	m.mu.Unlock()
+	return stream != nil && stream.Connected()
+}
+
+// SSEStatus describes the state of the event stream.
+type SSEStatus struct {
+	// Running reports whether the connection loop is active.
+	Running bool `json:"running"`
+	// Connected reports whether the stream holds an open connection.
+	Connected bool `json:"connected"`
+	// ConnectedSince is when the open connection was established.
+	ConnectedSince *time.Time `json:"connected_since,omitempty"`
+	// LastEventAt is when the last event was received on any connection.
+	LastEventAt *time.Time `json:"last_event_at,omitempty"`
+	// LastEventAgeNano is the time since LastEventAt, or -1 if no event
+	// was received.
+	LastEventAgeNano int64 `json:"last_event_age_nano"`
+	// LastEventID is sent as Last-Event-ID when reconnecting.
+	LastEventID string `json:"last_event_id,omitempty"`
+	// EventsReceived counts the events received, including those that
+	// failed to parse or verify.
+	EventsReceived uint64 `json:"events_received"`
+	// Reconnects counts the connections established after the first.
+	Reconnects uint64 `json:"reconnects"`
+	// Transport is the transport of the open connection, or of the next
+	// one while reconnecting: "sse" or "websocket".
+	Transport string `json:"transport,omitempty"`
+	// UnexpectedEvents counts events of types without a handler, which
+	// the control plane should not have sent.
+	UnexpectedEvents uint64 `json:"unexpected_events"`
+}
+
+// Status returns the state of the event stream. Before Start, nothing is
+// connected and no event has been received.
+func (m *SSEManager) Status() SSEStatus {
+	m.mu.Lock()
+	stream := m.stream
+	running := m.running
+	m.mu.Unlock()
+	status := SSEStatus{LastEventAgeNano: -1}
+	if stream != nil {
+		status = stream.Status()
+		status.Running = running
+	}
+	for _, n := range m.dispatcher.Unexpected() {
+		status.UnexpectedEvents += n
+	}
+	return status
+}
+
+// Reconnect closes the open connection so that a new one is established at
+// once, resuming from the last event ID. It reports whether a connection
+// was open; while reconnecting or polling it does nothing.
+func (m *SSEManager) Reconnect() bool {
+	m.mu.Lock()
+	stream := m.stream
+	m.mu.Unlock()
+	if stream == nil || !stream.Reconnect() {
+		return false
+	}
+	m.logger.Info("SSE reconnect requested")
+	return true
+}
+
 // Shutdown gracefully stops the manager by cancelling its context.
 func (m *SSEManager) Shutdown() {
 	m.mu.Lock()
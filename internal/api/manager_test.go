@@ -363,3 +363,157 @@ func TestManager_RegisterHandlerBeforeStart(t *testing.T) {
 		t.Errorf("handler called %d times, want >= 1", called.Load())
 	}
 }
+
+// ---------------------------------------------------------------------------
+// TestManager_RunningAndConnected — liveness state follows the stream
+// ---------------------------------------------------------------------------
+
+func TestManager_RunningAndConnected(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(200)
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client, err := NewControlPlane(Config{BaseURL: srv.URL}, "1.0.0-test", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetAuthToken("test-token")
+	mgr := NewSSEManager(client, nil, logger)
+
+	if mgr.Running() || mgr.Connected() {
+		t.Fatal("Running or Connected before Start")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- mgr.Start(ctx, "node-1")
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !mgr.Connected() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !mgr.Connected() {
+		t.Fatal("Connected() = false with open stream")
+	}
+	if !mgr.Running() {
+		t.Error("Running() = false while connected")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return within 5s")
+	}
+	if mgr.Running() || mgr.Connected() {
+		t.Error("Running or Connected after Start returned")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// TestManager_StatusAndReconnect — stream stats and forced reconnection
+// ---------------------------------------------------------------------------
+
+func TestManager_StatusAndReconnect(t *testing.T) {
+	var mu sync.Mutex
+	var lastEventIDs []string
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(200)
+		fmt.Fprint(w, makeEnvelopeSSE("peer_added", "evt-1"))
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client, err := NewControlPlane(Config{BaseURL: srv.URL}, "1.0.0-test", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := NewSSEManager(client, nil, logger)
+
+	if st := mgr.Status(); st.Running || st.Connected || st.LastEventAgeNano != -1 {
+		t.Fatalf("Status() before Start = %+v", st)
+	}
+	if mgr.Reconnect() {
+		t.Error("Reconnect() before Start = true, want false")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- mgr.Start(ctx, "node-1")
+	}()
+
+	waitFor := func(cond func(SSEStatus) bool) SSEStatus {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if st := mgr.Status(); cond(st) {
+				return st
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("timed out; Status() = %+v", mgr.Status())
+		return SSEStatus{}
+	}
+
+	st := waitFor(func(st SSEStatus) bool { return st.Connected && st.EventsReceived == 1 })
+	if !st.Running || st.ConnectedSince == nil || st.LastEventAt == nil {
+		t.Errorf("Status() = %+v, want running with connection and event times", st)
+	}
+	if st.LastEventID != "evt-1" || st.Reconnects != 0 || st.LastEventAgeNano < 0 {
+		t.Errorf("Status() = %+v, want last event evt-1, no reconnects", st)
+	}
+	if st.UnexpectedEvents != 1 {
+		t.Errorf("UnexpectedEvents = %d, want 1 for an event without handler", st.UnexpectedEvents)
+	}
+
+	if !mgr.Reconnect() {
+		t.Fatal("Reconnect() = false with open stream")
+	}
+	waitFor(func(st SSEStatus) bool { return st.Connected && st.Reconnects == 1 && st.EventsReceived == 2 })
+
+	mu.Lock()
+	ids := append([]string(nil), lastEventIDs...)
+	mu.Unlock()
+	if len(ids) != 2 || ids[1] != "evt-1" {
+		t.Errorf("Last-Event-ID headers = %q, want second connection to resume from evt-1", ids)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil && err != context.Canceled {
+			t.Fatalf("Start returned unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return within 5s")
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"bufio"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -95,6 +98,10 @@ type SSEEvent struct {
 	Type string // from "event:" field, defaults to "message"
 	Data string // concatenated data fields
 	ID   string // from "id:" field
+
+	// Truncated is set for an event larger than the maximum event size.
+	// Its data is dropped; Type and ID are kept.
+	Truncated bool
 }
 
 // RetryCallback is called when the SSE server sends a retry: field.
@@ -102,15 +109,18 @@ type RetryCallback func(interval time.Duration)
 
 // SSEParser reads from an io.Reader and emits parsed SSE events.
 type SSEParser struct {
-	scanner       *bufio.Scanner
+	reader        *bufio.Reader
+	maxEventSize  int
 	lastEventID   string
 	retryCallback RetryCallback
 }
 
-// NewSSEParser creates a parser reading from the given reader.
+// NewSSEParser creates a parser reading from the given reader. Events are
+// limited to DefaultMaxResponseSize bytes.
 func NewSSEParser(r io.Reader) *SSEParser {
 	return &SSEParser{
-		scanner: bufio.NewScanner(r),
+		reader:       bufio.NewReader(r),
+		maxEventSize: DefaultMaxResponseSize,
 	}
 }
 
@@ -119,6 +129,12 @@ func (p *SSEParser) SetRetryCallback(cb RetryCallback) {
 	p.retryCallback = cb
 }
 
+// SetMaxEventSize limits the lines and the data of an event to n bytes.
+// Larger events are returned with Truncated set, and never buffered whole.
+func (p *SSEParser) SetMaxEventSize(n int) {
+	p.maxEventSize = n
+}
+
 // LastEventID returns the most recently received event ID.
 func (p *SSEParser) LastEventID() string {
 	return p.lastEventID
@@ -131,28 +147,42 @@ func (p *SSEParser) Next() (SSEEvent, bool) {
 	// - Lines starting with ":" are comments (ignore but useful as keepalives)
 	// - "event:" sets the event type
 	// - "data:" appends to the data buffer (multiple data lines concatenated with \n)
-	// - "id:" sets the last event ID (also stored on the event)
-	// - "retry:" sends a retry interval to the client
+	// - "id:" sets the last event ID (also stored on the event); an ID
+	//   containing NUL is ignored
+	// - "retry:" sends a retry interval to the client; only ASCII digits
+	//   are accepted
 	// - An empty line dispatches the accumulated event
 	// - Fields with no colon use the whole line as field name with empty value
 
 	var eventType string
 	var data []string
 	var id string
+	var size int
+	var truncated bool
 
-	for p.scanner.Scan() {
-		line := p.scanner.Text()
+	for {
+		line, tooLong, ok := p.readLine()
+		if !ok {
+			break
+		}
+		if tooLong {
+			// The field of an overlong line is unknown; drop the event.
+			truncated = true
+			data = nil
+			continue
+		}
 
 		// Empty line dispatches the event
 		if line == "" {
-			if len(data) > 0 {
+			if len(data) > 0 || truncated {
 				if eventType == "" {
 					eventType = "message"
 				}
 				evt := SSEEvent{
-					Type: eventType,
-					Data: strings.Join(data, "\n"),
-					ID:   id,
+					Type:      eventType,
+					Data:      strings.Join(data, "\n"),
+					ID:        id,
+					Truncated: truncated,
 				}
 				if id != "" {
 					p.lastEventID = id
@@ -180,12 +210,21 @@ func (p *SSEParser) Next() (SSEEvent, bool) {
 		case "event":
 			eventType = value
 		case "data":
-			data = append(data, value)
+			size += len(value) + 1
+			if size > p.maxEventSize {
+				truncated = true
+				data = nil
+			}
+			if !truncated {
+				data = append(data, value)
+			}
 		case "id":
-			id = value
+			if !strings.ContainsRune(value, 0) {
+				id = value
+			}
 		case "retry":
-			if ms, err := strconv.Atoi(value); err == nil && p.retryCallback != nil {
-				p.retryCallback(time.Duration(ms) * time.Millisecond)
+			if interval, ok := parseRetry(value); ok && p.retryCallback != nil {
+				p.retryCallback(interval)
 			}
 		}
 	}
@@ -193,6 +232,51 @@ func (p *SSEParser) Next() (SSEEvent, bool) {
 	return SSEEvent{}, false
 }
 
+// readLine returns the next line without its line ending. A line longer
+// than the maximum event size is discarded and returned empty with tooLong
+// set. ok is false once the reader is exhausted.
+func (p *SSEParser) readLine() (line string, tooLong, ok bool) {
+	var buf []byte
+	for {
+		chunk, err := p.reader.ReadSlice('\n')
+		if !tooLong {
+			if len(buf)+len(chunk) > p.maxEventSize+2 {
+				tooLong = true
+				buf = nil
+			} else {
+				buf = append(buf, chunk...)
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && len(buf) == 0 && !tooLong {
+			return "", false, false
+		}
+		break
+	}
+	if tooLong {
+		return "", true, true
+	}
+	line = string(buf)
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return line, false, true
+}
+
+// parseRetry parses the value of a retry: field, a number of milliseconds
+// in ASCII digits.
+func parseRetry(value string) (time.Duration, bool) {
+	if value == "" || strings.TrimLeft(value, "0123456789") != "" {
+		return 0, false
+	}
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms > int64(math.MaxInt64/time.Millisecond) {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
 // SSEStream connects to the SSE endpoint, parses events, verifies envelopes,
 // and dispatches them to registered handlers.
 type SSEStream struct {
@@ -202,8 +286,23 @@ type SSEStream struct {
 	logger      *slog.Logger
 	idleTimeout time.Duration
 
+	// mode and fallbackAfter are Config.EventTransport and
+	// Config.WebSocketFallbackAfter.
+	mode          string
+	fallbackAfter int
+
 	mu          sync.Mutex
 	lastEventID string
+	connected   atomic.Bool
+
+	// Guarded by mu.
+	connectedSince time.Time
+	lastEventAt    time.Time
+	events         uint64
+	connects       uint64
+	cancelConn     context.CancelFunc
+	transport      string // of the current or next connection
+	failures       int    // consecutive failed connections
 }
 
 // NewSSEStream creates a new SSEStream.
@@ -211,12 +310,19 @@ func NewSSEStream(client *ControlPlane, verifier EventVerifier, dispatcher *Even
 	if verifier == nil {
 		verifier = NoOpVerifier{}
 	}
+	transport := EventTransportSSE
+	if client.eventTransport == EventTransportWebSocket {
+		transport = EventTransportWebSocket
+	}
 	return &SSEStream{
-		client:      client,
-		verifier:    verifier,
-		dispatcher:  dispatcher,
-		logger:      logger,
-		idleTimeout: idleTimeout,
+		client:        client,
+		verifier:      verifier,
+		dispatcher:    dispatcher,
+		logger:        logger,
+		idleTimeout:   idleTimeout,
+		transport:     transport,
+		mode:          client.eventTransport,
+		fallbackAfter: client.wsFallbackAfter,
 	}
 }
 
@@ -227,20 +333,163 @@ func (s *SSEStream) LastEventID() string {
 	return s.lastEventID
 }
 
-// Connect establishes the SSE connection and processes events until
-// the connection drops or context is cancelled.
-// Returns nil when the connection closes cleanly, or an error.
+// Connected reports whether the stream currently holds an open connection.
+func (s *SSEStream) Connected() bool {
+	return s.connected.Load()
+}
+
+// Status returns the state of the stream. Running is always false; it is
+// set by SSEManager.Status.
+func (s *SSEStream) Status() SSEStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := SSEStatus{
+		Connected:        s.connected.Load(),
+		LastEventID:      s.lastEventID,
+		EventsReceived:   s.events,
+		LastEventAgeNano: -1,
+		Transport:        s.transport,
+	}
+	if s.connects > 1 {
+		status.Reconnects = s.connects - 1
+	}
+	if !s.connectedSince.IsZero() {
+		t := s.connectedSince
+		status.ConnectedSince = &t
+	}
+	if !s.lastEventAt.IsZero() {
+		t := s.lastEventAt
+		status.LastEventAt = &t
+		status.LastEventAgeNano = time.Since(t).Nanoseconds()
+	}
+	return status
+}
+
+// Reconnect closes the open connection so that Connect returns nil and the
+// caller's reconnect loop opens a new one at once. It reports whether a
+// connection was open.
+func (s *SSEStream) Reconnect() bool {
+	s.mu.Lock()
+	cancel := s.cancelConn
+	s.mu.Unlock()
+	if cancel == nil || !s.Connected() {
+		return false
+	}
+	cancel()
+	return true
+}
+
+// Connect establishes the event stream connection and processes events until
+// the connection drops or context is cancelled. It uses SSE or WebSocket as
+// selected by Config.EventTransport.
+// Returns nil when the connection closes cleanly or is closed by Reconnect,
+// or an error.
 func (s *SSEStream) Connect(ctx context.Context, nodeID string) error {
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	s.mu.Lock()
 	lastID := s.lastEventID
+	transport := s.transport
+	events := s.events
+	s.cancelConn = cancel
 	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.cancelConn = nil
+		s.connectedSince = time.Time{}
+		s.mu.Unlock()
+	}()
+
+	var err error
+	if transport == EventTransportWebSocket {
+		err = s.connectWebSocket(ctx, connCtx, nodeID, lastID)
+	} else {
+		err = s.connectSSE(ctx, connCtx, nodeID, lastID)
+	}
+	if ctx.Err() != nil {
+		return err
+	}
+	return s.selectTransport(transport, events, err)
+}
 
-	resp, err := s.client.ConnectSSE(ctx, nodeID, lastID)
+// selectTransport counts consecutive failed connections and, in
+// EventTransportAuto mode, switches to the other transport after
+// fallbackAfter of them. A connection that received an event or closed
+// cleanly resets the count. It returns the error to report for the
+// connection.
+func (s *SSEStream) selectTransport(transport string, eventsBefore uint64, err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err == nil || s.events > eventsBefore {
+		s.failures = 0
+		return err
+	}
+	if s.mode != EventTransportAuto {
+		return err
+	}
+
+	s.failures++
+	// A control plane without the WebSocket endpoint must not stop the
+	// reconnect loop, as a 404 would.
+	unavailable := transport == EventTransportWebSocket && errors.Is(err, ErrNotFound)
+	if s.failures < s.fallbackAfter && !unavailable {
+		return err
+	}
+
+	next := EventTransportWebSocket
+	if transport == EventTransportWebSocket {
+		next = EventTransportSSE
+	}
+	s.logger.Warn("event stream failing, switching transport",
+		"from", transport,
+		"to", next,
+		"failures", s.failures,
+		"error", err,
+	)
+	s.transport = next
+	s.failures = 0
+	if unavailable {
+		return fmt.Errorf("api: websocket transport unavailable: %v", err)
+	}
+	return err
+}
+
+// markConnected marks the start of a connection.
+func (s *SSEStream) markConnected() {
+	s.mu.Lock()
+	s.connects++
+	s.connectedSince = time.Now()
+	s.mu.Unlock()
+	s.connected.Store(true)
+}
+
+// recordEvent updates the last event ID and the stream statistics.
+func (s *SSEStream) recordEvent(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id != "" {
+		s.lastEventID = id
+	}
+	s.events++
+	s.lastEventAt = time.Now()
+}
+
+// connectSSE processes events of an SSE connection.
+func (s *SSEStream) connectSSE(ctx, connCtx context.Context, nodeID, lastID string) error {
+	resp, err := s.client.ConnectSSE(connCtx, nodeID, lastID, s.dispatcher.Types())
 	if err != nil {
+		if connCtx.Err() != nil && ctx.Err() == nil {
+			return nil
+		}
 		return err
 	}
 	defer resp.Body.Close()
 
+	s.markConnected()
+	defer s.connected.Store(false)
+
 	// Wrap body with idle timeout enforcement (REQ-011).
 	// If no data arrives within idleTimeout, the reader returns an error
 	// which breaks out of the parse loop and triggers reconnection.
@@ -248,6 +497,7 @@ func (s *SSEStream) Connect(ctx context.Context, nodeID string) error {
 	defer idleReader.Stop()
 
 	parser := NewSSEParser(idleReader)
+	parser.SetMaxEventSize(int(s.client.defaultLimits.maxSize))
 
 	for {
 		if ctx.Err() != nil {
@@ -263,11 +513,15 @@ func (s *SSEStream) Connect(ctx context.Context, nodeID string) error {
 			return nil
 		}
 
-		// Update last event ID
-		if evt.ID != "" {
-			s.mu.Lock()
-			s.lastEventID = evt.ID
-			s.mu.Unlock()
+		s.recordEvent(evt.ID)
+
+		if evt.Truncated {
+			s.logger.Error("event too large, skipped",
+				"event_type", evt.Type,
+				"event_id", evt.ID,
+				"max_size", s.client.defaultLimits.maxSize,
+			)
+			continue
 		}
 
 		// Parse envelope from data
@@ -281,17 +535,72 @@ func (s *SSEStream) Connect(ctx context.Context, nodeID string) error {
 			continue // skip malformed events
 		}
 
-		// Verify envelope
-		if err := s.verifier.Verify(ctx, envelope); err != nil {
-			s.logger.Error("event verification failed",
-				"event_type", envelope.EventType,
-				"event_id", envelope.EventID,
+		s.verifyAndDispatch(ctx, envelope)
+	}
+}
+
+// connectWebSocket processes events of a WebSocket connection. Each message
+// carries one SignedEnvelope; its event ID is used as Last-Event-ID.
+func (s *SSEStream) connectWebSocket(ctx, connCtx context.Context, nodeID, lastID string) error {
+	conn, err := s.client.ConnectWebSocket(connCtx, nodeID, lastID, s.dispatcher.Types())
+	if err != nil {
+		if connCtx.Err() != nil && ctx.Err() == nil {
+			return nil
+		}
+		return err
+	}
+	defer conn.Close()
+	// Unlike the SSE response body, the upgraded connection is not closed
+	// when the request context is done.
+	stop := context.AfterFunc(connCtx, func() { conn.Close() })
+	defer stop()
+
+	s.markConnected()
+	defer s.connected.Store(false)
+
+	idleReader := conn.withIdleTimeout(s.idleTimeout)
+	defer idleReader.Stop()
+
+	for {
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err := idleReader.Err(); err != nil {
+				return err
+			}
+			if connCtx.Err() != nil || errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("api: websocket read: %w", err)
+		}
+
+		envelope, err := ParseEnvelope(msg)
+		if err != nil {
+			s.recordEvent("")
+			s.logger.Error("failed to parse event envelope",
+				"transport", EventTransportWebSocket,
 				"error", err,
 			)
 			continue
 		}
+		s.recordEvent(envelope.EventID)
+
+		s.verifyAndDispatch(ctx, envelope)
+	}
+}
 
-		// Dispatch
-		s.dispatcher.Dispatch(ctx, envelope)
+// verifyAndDispatch verifies an envelope and dispatches it to the handlers
+// of its event type.
+func (s *SSEStream) verifyAndDispatch(ctx context.Context, envelope SignedEnvelope) {
+	if err := s.verifier.Verify(ctx, envelope); err != nil {
+		s.logger.Error("event verification failed",
+			"event_type", envelope.EventType,
+			"event_id", envelope.EventID,
+			"error", err,
+		)
+		return
 	}
+	s.dispatcher.Dispatch(ctx, envelope)
 }
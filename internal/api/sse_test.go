@@ -1,6 +1,7 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -133,6 +134,96 @@ func TestSSEParser_EmptyLinesResetState(t *testing.T) {
 	}
 }
 
+func TestSSEParser_InvalidRetryIgnored(t *testing.T) {
+	input := "retry: -5\nretry: +5\nretry: 5s\nretry: 99999999999999999999\nretry: 9223372036854775807\nretry: 250\ndata: x\n\n"
+	parser := NewSSEParser(strings.NewReader(input))
+
+	var got []time.Duration
+	parser.SetRetryCallback(func(interval time.Duration) {
+		got = append(got, interval)
+	})
+	parser.Next()
+
+	if len(got) != 1 || got[0] != 250*time.Millisecond {
+		t.Errorf("retry intervals = %v, want [250ms]", got)
+	}
+}
+
+func TestSSEParser_IDWithNULIgnored(t *testing.T) {
+	parser := NewSSEParser(strings.NewReader("id: ok\ndata: x\n\nid: a\x00b\ndata: y\n\n"))
+	parser.Next()
+	evt, _ := parser.Next()
+	if evt.ID != "" || parser.LastEventID() != "ok" {
+		t.Errorf("ID = %q, LastEventID = %q; want the ID with NUL ignored", evt.ID, parser.LastEventID())
+	}
+}
+
+func TestSSEParser_OversizedEventTruncated(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	input := "event: big\nid: id-1\ndata: " + long + "\n\n" + // line too long
+		"id: id-2\ndata: " + long[:40] + "\ndata: " + long[:40] + "\n\n" + // data too long
+		"id: id-3\ndata: small\n\n"
+	parser := NewSSEParser(strings.NewReader(input))
+	parser.SetMaxEventSize(64)
+
+	for _, want := range []SSEEvent{
+		{Type: "big", ID: "id-1", Truncated: true},
+		{Type: "message", ID: "id-2", Truncated: true},
+		{Type: "message", ID: "id-3", Data: "small"},
+	} {
+		evt, ok := parser.Next()
+		if !ok || evt != want {
+			t.Fatalf("Next() = %+v, %v; want %+v", evt, ok, want)
+		}
+	}
+	if _, ok := parser.Next(); ok {
+		t.Error("expected end of stream")
+	}
+}
+
+func TestSSEParser_LongLine(t *testing.T) {
+	// Lines beyond the 64 KiB of a default bufio.Scanner are read whole.
+	data := strings.Repeat("x", 200<<10)
+	parser := NewSSEParser(strings.NewReader("data: " + data + "\r\n\r\n"))
+	evt, ok := parser.Next()
+	if !ok || evt.Data != data || evt.Truncated {
+		t.Errorf("Next() = %d bytes, truncated %v, ok %v; want %d bytes", len(evt.Data), evt.Truncated, ok, len(data))
+	}
+}
+
+// FuzzSSEParser parses arbitrary event streams with a small maximum event
+// size.
+func FuzzSSEParser(f *testing.F) {
+	f.Add([]byte("event: peer_added\ndata: {\"foo\":\"bar\"}\nid: evt_001\n\n"))
+	f.Add([]byte(": keepalive\nretry: 3000\ndata: a\ndata: b\r\n\r\ndata\n\nid\n\n"))
+	f.Add([]byte("retry: -1\nid: \x00\ndata: " + strings.Repeat("y", 100) + "\n\ndata: tail"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		const maxSize = 64
+		parser := NewSSEParser(bytes.NewReader(data))
+		parser.SetMaxEventSize(maxSize)
+		parser.SetRetryCallback(func(interval time.Duration) {
+			if interval < 0 {
+				t.Fatalf("negative retry interval %v", interval)
+			}
+		})
+		for {
+			evt, ok := parser.Next()
+			if !ok {
+				break
+			}
+			if evt.Type == "" {
+				t.Fatalf("event without type: %+v", evt)
+			}
+			if len(evt.Data) > maxSize || (evt.Truncated && evt.Data != "") {
+				t.Fatalf("event data of %d bytes, truncated %v", len(evt.Data), evt.Truncated)
+			}
+			if strings.ContainsRune(evt.ID, 0) || strings.ContainsRune(parser.LastEventID(), 0) {
+				t.Fatalf("event ID %q contains NUL", evt.ID)
+			}
+		}
+	})
+}
+
 // ---------------------------------------------------------------------------
 // SSEStream tests
 // ---------------------------------------------------------------------------
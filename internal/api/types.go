@@ -15,6 +15,41 @@ type RegisterRequest struct {
 	Hostname     string              `json:"hostname"`
 	Metadata     map[string]string   `json:"metadata,omitempty"`
 	Capabilities *CapabilitiesPayload `json:"capabilities,omitempty"`
+
+	// Ephemeral marks a short-lived node. The control plane deregisters it
+	// when it has sent no heartbeat for EphemeralTTL, a Go duration
+	// string such as "5m0s".
+	Ephemeral    bool                 `json:"ephemeral,omitempty"`
+	EphemeralTTL string               `json:"ephemeral_ttl,omitempty"`
+
+	// Attestation proves that the node holds the private key of
+	// PublicKey: a signature over Token and Hostname made with that key.
+	Attestation *KeyAttestation `json:"attestation,omitempty"`
+}
+
+// KeyAttestation is a proof of possession of the WireGuard private key
+// being registered, like the signature of a certificate signing request.
+type KeyAttestation struct {
+	// Scheme is the signature scheme, "xeddsa-25519".
+	Scheme string `json:"scheme"`
+	// Signature is the base64 signature over the token and the hostname.
+	Signature string `json:"signature"`
+	// TPMQuote is an optional quote of the node's TPM over the hash of the
+	// signed message and the public key.
+	TPMQuote *TPMQuote `json:"tpm_quote,omitempty"`
+}
+
+// TPMQuote is a TPM 2.0 quote, signed by an attestation key of the TPM.
+// All fields are base64 TPM wire structures.
+type TPMQuote struct {
+	// Quote is the TPMS_ATTEST structure the TPM signed.
+	Quote string `json:"quote"`
+	// Signature is the TPMT_SIGNATURE over Quote.
+	Signature string `json:"signature"`
+	// AKPublic is the TPMT_PUBLIC area of the attestation key.
+	AKPublic string `json:"ak_public"`
+	// PCRs maps PCR indexes to their hex digests at the time of the quote.
+	PCRs map[int]string `json:"pcrs,omitempty"`
 }
 
 type RegisterResponse struct {
@@ -33,6 +68,9 @@ type Peer struct {
 	Endpoint   string   `json:"endpoint"`
 	AllowedIPs []string `json:"allowed_ips"`
 	PSK        string   `json:"psk"`
+	// Hostname is the peer's host name. Bridge DNS forwarders resolve
+	// its first label under the mesh domain.
+	Hostname string `json:"hostname,omitempty"`
 }
 
 // ---------------------------------------------------------------------------
@@ -46,11 +84,47 @@ type HeartbeatRequest struct {
 	Uptime         string          `json:"uptime"`
 	BinaryChecksum string          `json:"binary_checksum"`
 	Mesh           *MeshInfo       `json:"mesh,omitempty"`
+	MeshHealth     *MeshHealthInfo `json:"mesh_health,omitempty"`
+	PeerHealth     *PeerHealthInfo `json:"peer_health,omitempty"`
 	NAT            *NATInfo        `json:"nat,omitempty"`
 	Bridge         *BridgeInfo     `json:"bridge,omitempty"`
 	UserAccess     *UserAccessInfo `json:"user_access,omitempty"`
 	Ingress        *IngressInfo    `json:"ingress,omitempty"`
 	SiteToSite     *SiteToSiteInfo `json:"site_to_site,omitempty"`
+
+	ContainerNetwork *ContainerNetworkInfo `json:"container_network,omitempty"`
+	LocalOverrides   *LocalOverridesInfo   `json:"local_overrides,omitempty"`
+
+	// Privilege reports how the agent performs privileged operations:
+	// "direct", "helper", or "unprivileged".
+	Privilege string `json:"privilege,omitempty"`
+
+	DegradedHandlers []DegradedHandler `json:"degraded_handlers,omitempty"`
+
+	// SubsystemRestarts lists the subsystems the agent restarted within the
+	// restart window of its supervisor.
+	SubsystemRestarts []SubsystemRestart `json:"subsystem_restarts,omitempty"`
+}
+
+// SubsystemRestart reports a subsystem the agent restarted because it
+// exceeded a budget or stalled.
+type SubsystemRestart struct {
+	Subsystem string `json:"subsystem"`
+	// Reason is "goroutines", "open_fds", "heap", or "stalled".
+	Reason string    `json:"reason"`
+	Detail string    `json:"detail"`
+	Time   time.Time `json:"time"`
+	// Error is set when the subsystem did not stop in time.
+	Error string `json:"error,omitempty"`
+}
+
+// DegradedHandler reports a reconcile handler that is failing, in backoff,
+// or has an open circuit breaker.
+type DegradedHandler struct {
+	Name                string `json:"name"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	LastError           string `json:"last_error"`
+	CircuitOpen         bool   `json:"circuit_open"`
 }
 
 type MeshInfo struct {
@@ -59,14 +133,54 @@ type MeshInfo struct {
 	ListenPort int    `json:"listen_port"`
 }
 
+// MeshHealthInfo summarizes which mesh peers this node can reach over the
+// tunnel, as of the last probe round.
+type MeshHealthInfo struct {
+	PeersTotal     int       `json:"peers_total"`
+	PeersReachable int       `json:"peers_reachable"`
+	Unreachable    []string  `json:"unreachable,omitempty"` // peer IDs
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// PeerHealthInfo summarizes which mesh peers completed a WireGuard handshake
+// recently, as of the last check.
+type PeerHealthInfo struct {
+	PeersTotal  int `json:"peers_total"`
+	PeersActive int `json:"peers_active"`
+	// Peers lists the inactive and the flapping peers, sorted by peer ID.
+	Peers     []PeerHealthStatus `json:"peers,omitempty"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// PeerHealthStatus is the handshake health of a mesh peer.
+type PeerHealthStatus struct {
+	PeerID string `json:"peer_id"`
+	// Inactive is set when the peer has not completed a handshake within
+	// the dead peer threshold.
+	Inactive bool `json:"inactive"`
+	// Since is when the peer became inactive or active.
+	Since time.Time `json:"since"`
+	// LastHandshake is nil if no handshake completed since the interface
+	// was created.
+	LastHandshake *time.Time `json:"last_handshake,omitempty"`
+	// Transitions is the number of changes between active and inactive
+	// within the flap window.
+	Transitions int  `json:"transitions"`
+	Flapping    bool `json:"flapping"`
+	// RoutesRemoved is set while the peer's routes are withdrawn from the
+	// mesh interface.
+	RoutesRemoved bool `json:"routes_removed,omitempty"`
+}
+
 type NATInfo struct {
 	PublicEndpoint string `json:"public_endpoint"`
 	Type           string `json:"type"`
 }
 
 type HeartbeatResponse struct {
-	Reconcile  bool `json:"reconcile"`
-	RotateKeys bool `json:"rotate_keys"`
+	Reconcile    bool `json:"reconcile"`
+	RotateKeys   bool `json:"rotate_keys"`
+	Decommission bool `json:"decommission"`
 }
 
 // ---------------------------------------------------------------------------
@@ -83,6 +197,10 @@ type StateResponse struct {
 	UserAccessConfig *UserAccessConfig  `json:"user_access_config,omitempty"`
 	IngressConfig    *IngressConfig    `json:"ingress_config,omitempty"`
 	SiteToSiteConfig *SiteToSiteConfig `json:"site_to_site_config,omitempty"`
+	ContainerNetworkConfig *ContainerNetworkConfig `json:"container_network_config,omitempty"`
+	// EgressPolicy restricts the node's own outbound traffic on non-mesh
+	// interfaces. Nil leaves it unrestricted.
+	EgressPolicy *EgressPolicy `json:"egress_policy,omitempty"`
 	Data             []DataEntry       `json:"data"`
 	SecretRefs       []SecretRef       `json:"secret_refs"`
 }
@@ -100,6 +218,25 @@ type PolicyRule struct {
 	Action   string `json:"action"`
 }
 
+// EgressPolicy is an allowlist for the outbound traffic the node itself
+// sends on interfaces other than the mesh interface.
+type EgressPolicy struct {
+	// Mode is "monitor", which audits traffic no rule allows, or "enforce",
+	// which also rejects it.
+	Mode  string       `json:"mode"`
+	Rules []EgressRule `json:"rules"`
+}
+
+// EgressRule allows outbound traffic to a destination.
+type EgressRule struct {
+	// Destination is a CIDR subnet, an IP address, or a domain name, which
+	// the node resolves to its current addresses.
+	Destination string `json:"destination"`
+	Port        int    `json:"port,omitempty"`
+	// Protocol is "tcp", "udp", or empty for any. A port requires it.
+	Protocol string `json:"protocol,omitempty"`
+}
+
 type SigningKeys struct {
 	Current           string     `json:"current"`
 	Previous          string     `json:"previous,omitempty"`
@@ -151,6 +288,8 @@ type SecretResponse struct {
 type ReportSyncRequest struct {
 	Entries []ReportEntry `json:"entries"`
 	Deleted []string      `json:"deleted"`
+	// Labels are node metadata keys set locally through the node API.
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 type ReportEntry struct {
@@ -159,6 +298,8 @@ type ReportEntry struct {
 	Payload     json.RawMessage `json:"payload"`
 	Version     int             `json:"version"`
 	UpdatedAt   time.Time       `json:"updated_at"`
+	// ExpiresAt is when the node deletes the entry and syncs the deletion.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
 // ---------------------------------------------------------------------------
@@ -252,6 +393,20 @@ type CapabilitiesPayload struct {
 	Binary         *BinaryInfo  `json:"binary,omitempty"`
 	BuiltinActions []ActionInfo `json:"builtin_actions"`
 	Hooks          []HookInfo   `json:"hooks"`
+
+	// FeatureGates lists the experimental features the agent supports and
+	// whether each is enabled on this node.
+	FeatureGates []FeatureGateInfo `json:"feature_gates,omitempty"`
+}
+
+// FeatureGateInfo reports one feature gate of the agent.
+type FeatureGateInfo struct {
+	Name    string `json:"name"`
+	Stage   string `json:"stage"`
+	Enabled bool   `json:"enabled"`
+	// Source is where the value comes from: "default", "config", or
+	// "control_plane".
+	Source string `json:"source"`
 }
 
 type BinaryInfo struct {
@@ -273,13 +428,51 @@ type ActionParam struct {
 }
 
 type HookInfo struct {
-	Name        string        `json:"name"`
-	Description string        `json:"description"`
-	Source      string        `json:"source"`
-	Checksum    string        `json:"checksum"`
-	Parameters  []ActionParam `json:"parameters"`
-	Timeout     string        `json:"timeout"`
-	Sandbox     string        `json:"sandbox"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Source      string          `json:"source"`
+	Checksum    string          `json:"checksum"`
+	Parameters  []ActionParam   `json:"parameters"`
+	Timeout     string          `json:"timeout"`
+	Sandbox     string          `json:"sandbox"`
+	Limits      *ResourceLimits `json:"limits,omitempty"`
+}
+
+// ResourceLimits are the cgroup v2 resource limits applied to a hook process
+// and its children. Zero fields are unlimited.
+type ResourceLimits struct {
+	// CPUPercent is the CPU quota in percent of one CPU (cpu.max); 200
+	// allows two full CPUs.
+	CPUPercent int `json:"cpu_percent,omitempty"`
+	// MemoryMax is the memory limit in bytes (memory.max). Swap is disabled
+	// for limited hooks.
+	MemoryMax int64 `json:"memory_max,omitempty"`
+	// PidsMax is the maximum number of processes and threads (pids.max).
+	PidsMax int64 `json:"pids_max,omitempty"`
+	// IOWeight is the proportional disk I/O weight, 1-10000 (io.weight).
+	IOWeight int `json:"io_weight,omitempty"`
+}
+
+// HookContentType is the content type of data entries that distribute hooks.
+const HookContentType = "application/vnd.plexd.hook+json"
+
+// HookDistribution is the payload of a hook data entry. The hook is either
+// downloaded from URL as a gzipped tarball holding the script and an optional
+// {name}.json sidecar, or given in Inline as a base64-encoded script with
+// optional sidecar Metadata. SHA256 is the hex digest of the tarball or the
+// decoded script. Signature is the base64 Ed25519 signature of the control
+// plane signing key over the JSON object {"name": Name, "sha256": SHA256}.
+// When Metadata is set, the object also holds "metadata_sha256", the hex
+// SHA-256 digest of the Metadata bytes exactly as they appear in the payload,
+// so the sidecar is covered by the signature as well as the script. The keys
+// of the object are in sorted order.
+type HookDistribution struct {
+	Name      string          `json:"name"`
+	URL       string          `json:"url,omitempty"`
+	Inline    string          `json:"inline,omitempty"`
+	SHA256    string          `json:"sha256"`
+	Signature string          `json:"signature"`
+	Metadata  json.RawMessage `json:"metadata,omitempty"`
 }
 
 // ---------------------------------------------------------------------------
@@ -289,6 +482,10 @@ type HookInfo struct {
 type EndpointReport struct {
 	PublicEndpoint string `json:"public_endpoint"`
 	NATType        string `json:"nat_type"`
+	RelayRequested bool   `json:"relay_requested,omitempty"`
+	// LANEndpoints are the node's private addresses at the WireGuard port,
+	// for peers on the same network.
+	LANEndpoints []string `json:"lan_endpoints,omitempty"`
 }
 
 type EndpointResponse struct {
@@ -296,8 +493,11 @@ type EndpointResponse struct {
 }
 
 type PeerEndpoint struct {
-	PeerID   string `json:"peer_id"`
-	Endpoint string `json:"endpoint"`
+	PeerID        string `json:"peer_id"`
+	Endpoint      string `json:"endpoint"`
+	RelayEndpoint string `json:"relay_endpoint,omitempty"`
+	// LANEndpoints are the private addresses the peer advertised.
+	LANEndpoints []string `json:"lan_endpoints,omitempty"`
 }
 
 // ---------------------------------------------------------------------------
@@ -320,11 +520,44 @@ type KeyRotateResponse struct {
 
 // SSHSessionSetup is the payload of an ssh_session_setup SSE event.
 type SSHSessionSetup struct {
-	SessionID     string    `json:"session_id"`
-	TargetHost    string    `json:"target_host"`
-	TargetPort    int       `json:"target_port"`
-	AuthorizedKey string    `json:"authorized_key"`
-	ExpiresAt     time.Time `json:"expires_at"`
+	SessionID     string       `json:"session_id"`
+	TargetHost    string       `json:"target_host"`
+	TargetPort    int          `json:"target_port"`
+	AuthorizedKey string       `json:"authorized_key"`
+	ExpiresAt     time.Time    `json:"expires_at"`
+	TriggeredBy   *TriggeredBy `json:"triggered_by,omitempty"`
+}
+
+// Port-forward session modes.
+const (
+	// PortForwardTCP forwards every connection to a fixed target.
+	PortForwardTCP = "tcp"
+
+	// PortForwardSOCKS5 serves SOCKS5 CONNECT requests to the allowed
+	// destinations.
+	PortForwardSOCKS5 = "socks5"
+)
+
+// PortForwardSetup is the payload of a port_forward_setup SSE event. It
+// opens a session like SSHSessionSetup, without an SSH key. Session IDs
+// share one namespace with SSH sessions, so session_revoked closes both.
+type PortForwardSetup struct {
+	SessionID  string `json:"session_id"`
+	Mode       string `json:"mode"`
+	TargetHost string `json:"target_host,omitempty"`
+	TargetPort int    `json:"target_port,omitempty"`
+
+	// AllowedSources lists the IPs or CIDRs that may connect to the
+	// session listener. Empty allows any mesh peer.
+	AllowedSources []string `json:"allowed_sources,omitempty"`
+
+	// AllowedDestinations lists the destinations a SOCKS5 session may
+	// connect to, as IP or CIDR with an optional port ("10.0.0.0/24:443",
+	// "[fd00::/64]:22"). Required for socks5, ignored for tcp.
+	AllowedDestinations []string `json:"allowed_destinations,omitempty"`
+
+	ExpiresAt   time.Time    `json:"expires_at"`
+	TriggeredBy *TriggeredBy `json:"triggered_by,omitempty"`
 }
 
 // TunnelReadyRequest is sent when a tunnel listener is ready.
@@ -340,6 +573,21 @@ type TunnelClosedRequest struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
+// TunnelRecordingFormat is the format of TunnelRecording.Data.
+const TunnelRecordingFormat = "asciicast-v2"
+
+// TunnelRecording uploads the recorded input/output of a closed tunnel
+// session.
+type TunnelRecording struct {
+	Format      string       `json:"format"`
+	Data        string       `json:"data"`
+	SHA256      string       `json:"sha256"`
+	Truncated   bool         `json:"truncated"`
+	StartedAt   time.Time    `json:"started_at"`
+	Duration    string       `json:"duration"`
+	TriggeredBy *TriggeredBy `json:"triggered_by,omitempty"`
+}
+
 // ---------------------------------------------------------------------------
 // Integrity  POST /v1/nodes/{node_id}/integrity/violations
 // ---------------------------------------------------------------------------
@@ -363,6 +611,51 @@ type BridgeConfig struct {
 	AccessSubnets    []string `json:"access_subnets"`
 	EnableNAT        bool     `json:"enable_nat"`
 	EnableForwarding bool     `json:"enable_forwarding"`
+	// HA places the bridge in an active/standby group with other bridge
+	// nodes serving the same access subnets. Nil disables HA.
+	HA *BridgeHAConfig `json:"ha,omitempty"`
+	// BGP is the policy of the bridge's BGP speaker. Nil imports nothing
+	// and advertises only the routed access subnets.
+	BGP *BridgeBGPConfig `json:"bgp,omitempty"`
+}
+
+// BridgeBGPConfig is the control plane policy for the routes a bridge node
+// exchanges with the local router over BGP.
+type BridgeBGPConfig struct {
+	// Advertise lists the CIDR subnets, such as the mesh subnets, that are
+	// advertised to the router in addition to the routed access subnets.
+	Advertise []string `json:"advertise,omitempty"`
+	// Import lists the CIDR subnets that routes learned from the router
+	// must lie within to be imported. Routes outside them are rejected.
+	Import []string `json:"import,omitempty"`
+}
+
+// BridgeHAConfig is the high availability group a bridge node belongs to.
+// The members elect one active node over the mesh; the others stand by and
+// take over when the active node stops advertising.
+type BridgeHAConfig struct {
+	GroupID string `json:"group_id"`
+	// Priority ranks this node in the election, 1-254. The highest priority
+	// wins; ties go to the higher node ID.
+	Priority int `json:"priority"`
+	// Peers are the mesh IPs of the other members of the group.
+	Peers []string `json:"peers"`
+	// VirtualIP is an optional CIDR address that the active node holds on
+	// the access interface, for LAN hosts that route mesh traffic through it.
+	VirtualIP string `json:"virtual_ip,omitempty"`
+}
+
+// BridgeHAStatus is the state of a bridge node in its HA group.
+type BridgeHAStatus struct {
+	GroupID  string `json:"group_id"`
+	State    string `json:"state"`
+	Priority int    `json:"priority"`
+	// ActiveNode is the node ID of the active member, if one is known.
+	ActiveNode     string    `json:"active_node,omitempty"`
+	Transitions    int       `json:"transitions"`
+	LastTransition time.Time `json:"last_transition,omitempty"`
+	// VirtualIPError is the last error adding or removing the virtual IP.
+	VirtualIPError string `json:"virtual_ip_error,omitempty"`
 }
 
 // BridgeInfo is the bridge status reported by the node in heartbeats.
@@ -376,6 +669,80 @@ type BridgeInfo struct {
 	ActiveIngressRules      int    `json:"active_ingress_rules"`
 	SiteToSiteEnabled       bool   `json:"site_to_site_enabled"`
 	ActiveSiteToSiteTunnels int    `json:"active_site_to_site_tunnels"`
+	// NATEnabled is true when traffic to the access subnets is masqueraded.
+	NATEnabled bool `json:"nat_enabled"`
+	// NATRules counts the masquerade rules installed on the access
+	// interface: one per IPv4 access subnet, or one for the whole interface.
+	NATRules int `json:"nat_rules"`
+	// NATConflicts describes source NAT rules outside plexd that also
+	// translate traffic on the access interface.
+	NATConflicts []string `json:"nat_conflicts,omitempty"`
+	// ProposedAccessSubnets lists the access subnets a bridge in the
+	// approval access subnet mode found or was configured with, and which
+	// it routes once BridgeConfig.AccessSubnets confirms them.
+	ProposedAccessSubnets []string `json:"proposed_access_subnets,omitempty"`
+	// RelayShaping lists the relay sessions that have a rate limit.
+	RelayShaping []ShapingStatus `json:"relay_shaping,omitempty"`
+	// HA is the node's state in its bridge HA group, if it has one.
+	HA *BridgeHAStatus `json:"ha,omitempty"`
+	// DNSForwardEnabled is true when the bridge runs a DNS forwarder on
+	// the access interface.
+	DNSForwardEnabled bool `json:"dns_forward_enabled,omitempty"`
+	// DNSNames counts the mesh names the DNS forwarder resolves.
+	DNSNames int `json:"dns_names,omitempty"`
+	// BGP is the state of the bridge's BGP speaker, if it runs one.
+	BGP *BridgeBGPStatus `json:"bgp,omitempty"`
+}
+
+// BridgeBGPStatus is the state of the BGP speaker of a bridge node.
+type BridgeBGPStatus struct {
+	LocalAS   uint32              `json:"local_as"`
+	RouterID  string              `json:"router_id"`
+	Neighbors []BGPNeighborStatus `json:"neighbors"`
+	// Advertised lists the subnets advertised to the neighbors.
+	Advertised []string `json:"advertised,omitempty"`
+	// Imported lists the learned routes installed on the node.
+	Imported []BGPRoute `json:"imported,omitempty"`
+	// Rejected counts the learned routes that were not imported: outside
+	// the Import policy, overlapping an advertised subnet, capturing a
+	// protected address, or beyond the import limit.
+	Rejected int `json:"rejected,omitempty"`
+	// Error is the last error installing or removing an imported route.
+	Error string `json:"error,omitempty"`
+}
+
+// BGPNeighborStatus is the session state of a BGP neighbor.
+type BGPNeighborStatus struct {
+	Address string `json:"address"`
+	AS      uint32 `json:"as"`
+	// State is idle, connect, open_sent, open_confirm, or established.
+	State       string    `json:"state"`
+	Established time.Time `json:"established,omitempty"`
+	// Received counts the routes received from the neighbor.
+	Received int `json:"received"`
+	// Error is why the last session ended or could not be established.
+	Error string `json:"error,omitempty"`
+}
+
+// BGPRoute is a route learned over BGP.
+type BGPRoute struct {
+	Prefix   string   `json:"prefix"`
+	NextHop  string   `json:"next_hop"`
+	Neighbor string   `json:"neighbor"`
+	ASPath   []uint32 `json:"as_path,omitempty"`
+}
+
+// ShapingStatus is the state of an egress rate limit reported in heartbeats.
+type ShapingStatus struct {
+	// Target is the tunnel ID, user access interface, or relay session ID.
+	Target   string `json:"target"`
+	RateKbps int64  `json:"rate_kbps"`
+	// Active is true when the limit is enforced.
+	Active bool   `json:"active"`
+	Error  string `json:"error,omitempty"`
+	// Dropped counts packets dropped by the limit. Only relay sessions,
+	// which are shaped in userspace, report it.
+	Dropped uint64 `json:"dropped,omitempty"`
 }
 
 // RelayConfig is the relay configuration pushed from the control plane.
@@ -392,6 +759,36 @@ type RelaySessionAssignment struct {
 	PeerBID       string    `json:"peer_b_id"`
 	PeerBEndpoint string    `json:"peer_b_endpoint"`
 	ExpiresAt     time.Time `json:"expires_at"`
+	// RateLimitKbps limits the traffic relayed in each direction; 0 means
+	// unlimited.
+	RateLimitKbps int64 `json:"rate_limit_kbps,omitempty"`
+	// ByteQuota caps the bytes relayed in both directions together; the
+	// session is closed once it is used up. 0 means unlimited.
+	ByteQuota int64 `json:"byte_quota,omitempty"`
+}
+
+// Relay session close reasons.
+const (
+	RelayCloseExpired       = "expired"
+	RelayCloseRevoked       = "revoked"
+	RelayCloseQuotaExceeded = "quota_exceeded"
+	RelayCloseStopped       = "stopped"
+)
+
+// RelaySessionClosedRequest is sent when a relay session closes, with the
+// traffic relayed over its lifetime.
+// POST /v1/nodes/{node_id}/relay/sessions/{session_id}/closed
+type RelaySessionClosedRequest struct {
+	Reason    string `json:"reason"`
+	BytesAToB uint64 `json:"bytes_a_to_b"`
+	BytesBToA uint64 `json:"bytes_b_to_a"`
+	// Dropped counts the packets dropped by the session's rate limit.
+	Dropped uint64 `json:"dropped,omitempty"`
+	// Offloaded is true when the kernel fast path forwarded the session for
+	// some time; that traffic is not included in the byte counts.
+	Offloaded bool      `json:"offloaded,omitempty"`
+	Duration  string    `json:"duration"`
+	Timestamp time.Time `json:"timestamp"`
 }
 
 // ---------------------------------------------------------------------------
@@ -404,6 +801,13 @@ type UserAccessConfig struct {
 	InterfaceName string           `json:"interface_name"`
 	ListenPort    int              `json:"listen_port"`
 	Peers         []UserAccessPeer `json:"peers"`
+	// EgressRateKbps limits the traffic sent to user access clients; 0 means
+	// unlimited.
+	EgressRateKbps int64 `json:"egress_rate_kbps,omitempty"`
+	// DNSServers and SearchDomains are advertised to user access clients and
+	// programmed into the bridge node's resolver for split DNS.
+	DNSServers    []string `json:"dns_servers,omitempty"`
+	SearchDomains []string `json:"search_domains,omitempty"`
 }
 
 // UserAccessPeer represents a user access peer (external VPN client).
@@ -412,14 +816,21 @@ type UserAccessPeer struct {
 	AllowedIPs []string `json:"allowed_ips"`
 	PSK       string   `json:"psk,omitempty"`
 	Label     string   `json:"label"`
+	// ExpiresAt is when the bridge node revokes the peer on its own; nil
+	// means the peer does not expire.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
 // UserAccessInfo is the user access status reported by the node in heartbeats.
 type UserAccessInfo struct {
-	Enabled       bool   `json:"enabled"`
-	InterfaceName string `json:"interface_name"`
-	PeerCount     int    `json:"peer_count"`
-	ListenPort    int    `json:"listen_port"`
+	Enabled       bool           `json:"enabled"`
+	InterfaceName string         `json:"interface_name"`
+	PeerCount     int            `json:"peer_count"`
+	ListenPort    int            `json:"listen_port"`
+	Shaping       *ShapingStatus `json:"shaping,omitempty"`
+	// ExpiredPeers is the number of peers revoked by the node because their
+	// access expired.
+	ExpiredPeers int `json:"expired_peers"`
 }
 
 // ---------------------------------------------------------------------------
@@ -440,6 +851,13 @@ type IngressRule struct {
 	Mode       string `json:"mode"`
 	CertPEM    string `json:"cert_pem,omitempty"`
 	KeyPEM     string `json:"key_pem,omitempty"`
+	// SendProxyProtocol prefixes each connection to the target with a PROXY
+	// protocol v2 header carrying the client's address.
+	SendProxyProtocol bool `json:"send_proxy_protocol,omitempty"`
+	// AcceptProxyProtocol requires each client connection to start with a
+	// PROXY protocol v2 header from an upstream load balancer, whose source
+	// address is then taken as the client's. Not supported in terminate mode.
+	AcceptProxyProtocol bool `json:"accept_proxy_protocol,omitempty"`
 }
 
 // IngressInfo is the ingress status reported by the node in heartbeats.
@@ -459,9 +877,25 @@ type SiteToSiteConfig struct {
 	Tunnels []SiteToSiteTunnel `json:"tunnels"`
 }
 
+// Site-to-site tunnel types.
+const (
+	// TunnelTypeWireGuard connects the remote site through a WireGuard
+	// interface. It is the default.
+	TunnelTypeWireGuard = "wireguard"
+
+	// TunnelTypeIPsec connects the remote site through IKEv2/IPsec
+	// negotiated by strongSwan, for sites that cannot run WireGuard. The
+	// remote site authenticates with PSK; RemotePublicKey and ListenPort
+	// are not used.
+	TunnelTypeIPsec = "ipsec"
+)
+
 // SiteToSiteTunnel represents a single site-to-site VPN tunnel definition.
 type SiteToSiteTunnel struct {
-	TunnelID        string   `json:"tunnel_id"`
+	TunnelID string `json:"tunnel_id"`
+	// Type is TunnelTypeWireGuard or TunnelTypeIPsec; empty means
+	// TunnelTypeWireGuard.
+	Type            string   `json:"type,omitempty"`
 	RemoteEndpoint  string   `json:"remote_endpoint"`
 	RemotePublicKey string   `json:"remote_public_key"`
 	LocalSubnets    []string `json:"local_subnets"`
@@ -469,10 +903,136 @@ type SiteToSiteTunnel struct {
 	PSK             string   `json:"psk,omitempty"`
 	InterfaceName   string   `json:"interface_name"`
 	ListenPort      int      `json:"listen_port"`
+	// EgressRateKbps limits the traffic sent into the tunnel; 0 means
+	// unlimited.
+	EgressRateKbps int64 `json:"egress_rate_kbps,omitempty"`
+	// NATMap translates a local subnet for this tunnel, so that sites with
+	// overlapping address ranges can be connected; nil means no translation.
+	NATMap *SiteToSiteNATMap `json:"nat_map,omitempty"`
+	// L2 extends a local Linux bridge to the remote site; nil means the
+	// tunnel routes layer-3 traffic only.
+	L2 *SiteToSiteL2 `json:"l2,omitempty"`
+}
+
+// SiteToSiteL2 extends a local Linux bridge to a remote bridge node over a
+// VXLAN carried inside the tunnel, for workloads that need layer-2
+// adjacency across sites. Both sites must use the same VNI.
+type SiteToSiteL2 struct {
+	// VNI is the VXLAN network identifier, 1–16777215.
+	VNI uint32 `json:"vni"`
+	// Bridge is the local Linux bridge the VXLAN interface joins.
+	Bridge string `json:"bridge"`
+	// LocalVTEP is the address of this node the VXLAN is sent from. It
+	// must lie within LocalSubnets.
+	LocalVTEP string `json:"local_vtep"`
+	// RemoteVTEP is the address of the remote bridge node. It must lie
+	// within RemoteSubnets, so that the VXLAN is routed through the tunnel.
+	RemoteVTEP string `json:"remote_vtep"`
+	// MaxMACs limits the MAC addresses learned from the remote site; 0
+	// means the node's default.
+	MaxMACs int `json:"max_macs,omitempty"`
+}
+
+// SiteToSiteNATMap maps the IPv4 subnet Local 1:1 (NETMAP) to the subnet As
+// of the same size. The remote site reaches Local under As, and traffic from
+// Local enters the tunnel with its source translated into As.
+type SiteToSiteNATMap struct {
+	Local string `json:"local"`
+	As    string `json:"as"`
 }
 
 // SiteToSiteInfo is the site-to-site VPN status reported by the node in heartbeats.
 type SiteToSiteInfo struct {
 	Enabled     bool `json:"enabled"`
 	TunnelCount int  `json:"tunnel_count"`
+	// Shaping lists the tunnels that have an egress rate limit.
+	Shaping []ShapingStatus `json:"shaping,omitempty"`
+	// Tunnels reports the traffic of each tunnel, sorted by tunnel ID.
+	Tunnels []SiteToSiteTunnelStatus `json:"tunnels,omitempty"`
+}
+
+// SiteToSiteTunnelStatus is the traffic of a site-to-site tunnel as read
+// from its WireGuard device, or from the SAs of an ipsec tunnel.
+type SiteToSiteTunnelStatus struct {
+	TunnelID  string `json:"tunnel_id"`
+	Interface string `json:"interface"`
+	// Endpoint is the current endpoint of the remote peer, which differs
+	// from the configured one after the peer roamed.
+	Endpoint string `json:"endpoint,omitempty"`
+	RxBytes  uint64 `json:"rx_bytes"`
+	TxBytes  uint64 `json:"tx_bytes"`
+	// LastHandshake is nil if no handshake completed since the interface
+	// was created.
+	LastHandshake *time.Time `json:"last_handshake,omitempty"`
+	// Error is set when the statistics could not be read.
+	Error string `json:"error,omitempty"`
+	// L2 is the state of the layer-2 extension, if the tunnel has one.
+	L2 *SiteToSiteL2Status `json:"l2,omitempty"`
+}
+
+// SiteToSiteL2Status is the state of the layer-2 extension of a tunnel.
+type SiteToSiteL2Status struct {
+	// Interface is the VXLAN interface attached to Bridge.
+	Interface string `json:"interface"`
+	VNI       uint32 `json:"vni"`
+	Bridge    string `json:"bridge"`
+	// Active is true when the VXLAN interface is attached to the bridge.
+	Active bool `json:"active"`
+	// MACs counts the MAC addresses learned from the remote site.
+	MACs    int `json:"macs"`
+	MaxMACs int `json:"max_macs"`
+	// LearningPaused is true while MACs reached MaxMACs and no further
+	// addresses are learned from the remote site.
+	LearningPaused bool   `json:"learning_paused,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// ---------------------------------------------------------------------------
+// Container Network
+// ---------------------------------------------------------------------------
+
+// ContainerNetworkConfig is the container network configuration pushed from
+// the control plane to nodes running the plexd CNI plugin.
+type ContainerNetworkConfig struct {
+	// Prefix is the IPv4 CIDR containers on this node get their addresses
+	// from. The control plane adds it to this node's AllowedIPs on its peers.
+	Prefix string `json:"prefix"`
+	// PeerPrefixes are the container prefixes of other nodes, routed
+	// through the mesh interface.
+	PeerPrefixes []string `json:"peer_prefixes,omitempty"`
+}
+
+// ContainerNetworkInfo is the container network status reported by the node
+// in heartbeats.
+type ContainerNetworkInfo struct {
+	Prefix     string `json:"prefix"`
+	Containers int    `json:"containers"`
+}
+
+// LocalOverridesInfo reports the break-glass overrides from the node's local
+// overrides file that are in effect, reported by the node in heartbeats.
+type LocalOverridesInfo struct {
+	// PinnedPeers are peers kept even if the control plane removes them.
+	PinnedPeers []string `json:"pinned_peers,omitempty"`
+	// HeldPeers are pinned peers the control plane no longer includes; the
+	// node keeps them with their last known configuration.
+	HeldPeers []string `json:"held_peers,omitempty"`
+	// ExcludedPeers are peers the node does not configure.
+	ExcludedPeers []string `json:"excluded_peers,omitempty"`
+	// Endpoints maps peer IDs to the forced endpoint.
+	Endpoints map[string]string `json:"endpoints,omitempty"`
+	// Routes are the static routes added through peers.
+	Routes []LocalRoute `json:"routes,omitempty"`
+	// Unmatched lists the peer IDs of overrides that match no peer.
+	Unmatched []string `json:"unmatched,omitempty"`
+	// Error is set while the file is invalid; the overrides last loaded
+	// successfully stay in effect.
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LocalRoute is a static route added by a local override.
+type LocalRoute struct {
+	Prefix string `json:"prefix"`
+	PeerID string `json:"peer_id"`
 }
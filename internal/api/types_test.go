@@ -341,6 +341,31 @@ func TestTypesTunnelClosedRequest(t *testing.T) {
 	requireEqual(t, orig, got)
 }
 
+func TestTypesRelaySessionClosedRequest(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	orig := RelaySessionClosedRequest{
+		Reason:    RelayCloseExpired,
+		BytesAToB: 4096,
+		BytesBToA: 2048,
+		Dropped:   3,
+		Offloaded: true,
+		Duration:  "10m0s",
+		Timestamp: now,
+	}
+	data, got := roundTrip(t, orig)
+	requireEqual(t, orig, got)
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"reason", "bytes_a_to_b", "bytes_b_to_a", "dropped", "offloaded", "duration", "timestamp"} {
+		if _, ok := raw[key]; !ok {
+			t.Errorf("missing key %q", key)
+		}
+	}
+}
+
 func TestTypesIntegrityViolationReport(t *testing.T) {
 	now := time.Now().UTC().Truncate(time.Second)
 	orig := IntegrityViolationReport{
@@ -1092,3 +1117,25 @@ func TestBridgeInfo_SiteToSiteFields_JSONRoundTrip(t *testing.T) {
 		}
 	}
 }
+
+func TestHeartbeatRequest_WithDegradedHandlers(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	orig := HeartbeatRequest{
+		NodeID:    "n-001",
+		Timestamp: now,
+		Status:    "degraded",
+		DegradedHandlers: []DegradedHandler{
+			{Name: "bridge_routes", ConsecutiveFailures: 5, LastError: "route add failed", CircuitOpen: true},
+		},
+	}
+	_, got := roundTrip(t, orig)
+	requireEqual(t, orig, got)
+
+	// degraded_handlers should be omitted when empty.
+	orig.DegradedHandlers = nil
+	data, got2 := roundTrip(t, orig)
+	requireEqual(t, orig, got2)
+	if s := string(data); contains(s, `"degraded_handlers"`) {
+		t.Errorf("degraded_handlers should be omitted when empty, got: %s", s)
+	}
+}
@@ -143,20 +143,42 @@ func (v *Ed25519Verifier) Verify(_ context.Context, envelope SignedEnvelope) err
 		return fmt.Errorf("api: verifier: signature verification failed")
 	}
 
+	if !v.verifyKeys(canonical, sigBytes) {
+		return fmt.Errorf("api: verifier: signature verification failed")
+	}
+
+	// Record nonce only after successful signature verification.
+	return v.nonces.Add(envelope.Nonce, envelope.IssuedAt)
+}
+
+// VerifySignature checks a base64 Ed25519 signature over message against the
+// current key, or the previous key during a key transition. It is used for
+// artifacts signed by the control plane outside of an envelope, such as
+// distributed hooks.
+func (v *Ed25519Verifier) VerifySignature(message []byte, signature string) error {
+	if signature == "" {
+		return fmt.Errorf("api: verifier: missing signature")
+	}
+	sigBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || !v.verifyKeys(message, sigBytes) {
+		return fmt.Errorf("api: verifier: signature verification failed")
+	}
+	return nil
+}
+
+// verifyKeys reports whether sig is a valid signature of message by the
+// current key or, before the transition expires, the previous key. A key of
+// the wrong length verifies nothing; ed25519.Verify would panic on it.
+func (v *Ed25519Verifier) verifyKeys(message, sig []byte) bool {
 	v.mu.RLock()
 	currentKey := v.currentKey
 	previousKey := v.previousKey
 	transitionExpires := v.transitionExpires
 	v.mu.RUnlock()
 
-	verified := ed25519.Verify(currentKey, canonical, sigBytes)
-	if !verified && len(previousKey) > 0 && time.Now().Before(transitionExpires) {
-		verified = ed25519.Verify(previousKey, canonical, sigBytes)
+	if len(currentKey) == ed25519.PublicKeySize && ed25519.Verify(currentKey, message, sig) {
+		return true
 	}
-	if !verified {
-		return fmt.Errorf("api: verifier: signature verification failed")
-	}
-
-	// Record nonce only after successful signature verification.
-	return v.nonces.Add(envelope.Nonce, envelope.IssuedAt)
+	return len(previousKey) == ed25519.PublicKeySize && time.Now().Before(transitionExpires) &&
+		ed25519.Verify(previousKey, message, sig)
 }
@@ -315,3 +315,40 @@ func TestEd25519Verifier_NonceNotConsumedOnInvalidSignature(t *testing.T) {
 		t.Fatalf("nonce should not have been consumed by failed verification: %v", err)
 	}
 }
+
+func TestEd25519Verifier_VerifySignature(t *testing.T) {
+	oldPub, oldPriv := generateKey(t)
+	newPub, newPriv := generateKey(t)
+	_, otherPriv := generateKey(t)
+
+	v := NewEd25519Verifier(oldPub)
+	v.SetKeys(newPub, oldPub, time.Now().Add(1*time.Hour))
+	msg := []byte(`{"name":"deploy","sha256":"abc"}`)
+	sign := func(priv ed25519.PrivateKey) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(priv, msg))
+	}
+
+	if err := v.VerifySignature(msg, sign(newPriv)); err != nil {
+		t.Errorf("current key: %v", err)
+	}
+	if err := v.VerifySignature(msg, sign(oldPriv)); err != nil {
+		t.Errorf("previous key during transition: %v", err)
+	}
+	if err := v.VerifySignature(msg, sign(otherPriv)); err == nil {
+		t.Error("unknown key: expected error")
+	}
+	if err := v.VerifySignature([]byte(`{"name":"other","sha256":"abc"}`), sign(newPriv)); err == nil {
+		t.Error("different message: expected error")
+	}
+	if err := v.VerifySignature(msg, ""); err == nil || !strContains(err.Error(), "missing signature") {
+		t.Errorf("empty signature: err = %v", err)
+	}
+	if err := v.VerifySignature(msg, "not-base64!"); err == nil {
+		t.Error("invalid base64: expected error")
+	}
+
+	v.SetKeys(newPub, oldPub, time.Now().Add(-1*time.Hour))
+	if err := v.VerifySignature(msg, sign(oldPriv)); err == nil {
+		t.Error("previous key after transition: expected error")
+	}
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrWebSocketProtocol is returned when the WebSocket handshake or a frame
+// violates RFC 6455.
+var ErrWebSocketProtocol = errors.New("api: websocket protocol error")
+
+// webSocketGUID is appended to Sec-WebSocket-Key to compute
+// Sec-WebSocket-Accept (RFC 6455, section 1.3).
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes (RFC 6455, section 5.2).
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+// wsMaxControlPayload is the maximum payload size of a control frame.
+const wsMaxControlPayload = 125
+
+// newWebSocketKey returns a random Sec-WebSocket-Key.
+func newWebSocketKey() (string, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("api: websocket key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// webSocketAccept returns the Sec-WebSocket-Accept value expected for key.
+func webSocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// WebSocketConn is the client side of an upgraded WebSocket connection.
+// It reads text and binary messages and writes binary messages; pings are
+// answered and the close handshake is completed.
+type WebSocketConn struct {
+	rwc     io.ReadWriteCloser
+	r       *bufio.Reader
+	maxSize int64
+
+	wmu       sync.Mutex
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// newWebSocketConn wraps an upgraded connection. Messages larger than
+// maxSize fail with ErrResponseTooLarge.
+func newWebSocketConn(rwc io.ReadWriteCloser, maxSize int64) *WebSocketConn {
+	return &WebSocketConn{
+		rwc:     rwc,
+		r:       bufio.NewReader(rwc),
+		maxSize: maxSize,
+	}
+}
+
+// withIdleTimeout makes reads fail with ErrSSEIdleTimeout when no data,
+// including pings, arrives within d. It must be called before the first
+// ReadMessage.
+func (c *WebSocketConn) withIdleTimeout(d time.Duration) *idleTimeoutReader {
+	idle := newIdleTimeoutReader(c.rwc, d)
+	c.r = bufio.NewReader(idle)
+	return idle
+}
+
+// ReadMessage returns the payload of the next text or binary message,
+// reassembling fragments. It returns io.EOF when the server closes the
+// connection.
+func (c *WebSocketConn) ReadMessage() ([]byte, error) {
+	var msg []byte
+	inMessage := false
+	for {
+		fin, op, payload, err := c.readFrame(c.maxSize - int64(len(msg)))
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			// Echo the status code to complete the close handshake.
+			if len(payload) > 2 {
+				payload = payload[:2]
+			}
+			_ = c.writeFrame(wsOpClose, payload)
+			return nil, io.EOF
+		case wsOpText, wsOpBinary:
+			if inMessage {
+				return nil, fmt.Errorf("%w: message started before final fragment", ErrWebSocketProtocol)
+			}
+			inMessage = true
+			msg = payload
+		case wsOpContinuation:
+			if !inMessage {
+				return nil, fmt.Errorf("%w: continuation without message", ErrWebSocketProtocol)
+			}
+			msg = append(msg, payload...)
+		default:
+			return nil, fmt.Errorf("%w: unknown opcode %#x", ErrWebSocketProtocol, op)
+		}
+
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+// readFrame reads one frame. Data frames with a payload larger than limit
+// fail with ErrResponseTooLarge.
+func (c *WebSocketConn) readFrame(limit int64) (fin bool, op byte, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = hdr[0]&0x80 != 0
+	op = hdr[0] & 0x0f
+	if hdr[0]&0x70 != 0 {
+		return false, 0, nil, fmt.Errorf("%w: reserved bits set", ErrWebSocketProtocol)
+	}
+	if hdr[1]&0x80 != 0 {
+		return false, 0, nil, fmt.Errorf("%w: masked server frame", ErrWebSocketProtocol)
+	}
+
+	n := uint64(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if op >= wsOpClose {
+		if !fin || n > wsMaxControlPayload {
+			return false, 0, nil, fmt.Errorf("%w: invalid control frame", ErrWebSocketProtocol)
+		}
+	} else if limit < 0 || n > uint64(limit) {
+		return false, 0, nil, ErrResponseTooLarge
+	}
+
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	return fin, op, payload, nil
+}
+
+// WriteMessage sends payload as a single binary message. It is safe to call
+// concurrently with ReadMessage.
+func (c *WebSocketConn) WriteMessage(payload []byte) error {
+	return c.writeFrame(wsOpBinary, payload)
+}
+
+// writeFrame writes a final, masked frame, as required for frames sent by a
+// client.
+func (c *WebSocketConn) writeFrame(op byte, payload []byte) error {
+	n := len(payload)
+	buf := make([]byte, 2, 14+n)
+	buf[0] = 0x80 | op
+	switch {
+	case n < 126:
+		buf[1] = 0x80 | byte(n)
+	case n <= 0xffff:
+		buf[1] = 0x80 | 126
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf[1] = 0x80 | 127
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	mask := len(buf)
+	buf = buf[:mask+4]
+	if _, err := rand.Read(buf[mask:]); err != nil {
+		return err
+	}
+	for i, b := range payload {
+		buf = append(buf, b^buf[mask+i%4])
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.rwc.Write(buf)
+	return err
+}
+
+// Close sends a normal closure frame and closes the connection without
+// waiting for the server's reply.
+func (c *WebSocketConn) Close() error {
+	c.closeOnce.Do(func() {
+		_ = c.writeFrame(wsOpClose, []byte{0x03, 0xe8}) // 1000, normal closure
+		c.closeErr = c.rwc.Close()
+	})
+	return c.closeErr
+}
@@ -0,0 +1,385 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writeServerFrame writes an unmasked frame, as sent by a server.
+func writeServerFrame(w io.Writer, fin bool, op byte, payload []byte) error {
+	var hdr []byte
+	b0 := op
+	if fin {
+		b0 |= 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		hdr = []byte{b0, byte(n)}
+	case n <= 0xffff:
+		hdr = []byte{b0, 126, 0, 0}
+		binary.BigEndian.PutUint16(hdr[2:], uint16(n))
+	default:
+		hdr = make([]byte, 10)
+		hdr[0], hdr[1] = b0, 127
+		binary.BigEndian.PutUint64(hdr[2:], uint64(n))
+	}
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// readClientFrame reads a masked frame, as sent by the client.
+func readClientFrame(r io.Reader) (op byte, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	if hdr[1]&0x80 == 0 {
+		return 0, nil, errors.New("client frame not masked")
+	}
+	n := uint64(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return hdr[0] & 0x0f, payload, nil
+}
+
+// wsUpgrade completes the server side of the handshake.
+func wsUpgrade(t *testing.T, w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.ReadWriter) {
+	t.Helper()
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		t.Errorf("Hijack: %v", err)
+		return nil, nil
+	}
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		webSocketAccept(r.Header.Get("Sec-WebSocket-Key")))
+	rw.Flush()
+	return conn, rw
+}
+
+// makeEnvelopeJSON builds a SignedEnvelope message.
+func makeEnvelopeJSON(eventType, eventID string) []byte {
+	data, _ := json.Marshal(SignedEnvelope{
+		EventType: eventType,
+		EventID:   eventID,
+		IssuedAt:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Nonce:     "n",
+		Payload:   json.RawMessage(`{}`),
+		Signature: "sig",
+	})
+	return data
+}
+
+func TestConnectWebSocket_Handshake(t *testing.T) {
+	var gotReq *http.Request
+	client, _ := newEndpointTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		gotReq = r
+		conn, rw := wsUpgrade(t, w, r)
+		if conn == nil {
+			return
+		}
+		defer conn.Close()
+		writeServerFrame(rw, true, wsOpText, makeEnvelopeJSON("peer_added", "evt-1"))
+		rw.Flush()
+		readClientFrame(rw) // wait for close
+	})
+
+	conn, err := client.ConnectWebSocket(context.Background(), "node-1", "evt-0", []string{"peer_added"})
+	if err != nil {
+		t.Fatalf("ConnectWebSocket: %v", err)
+	}
+	defer conn.Close()
+
+	msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	env, err := ParseEnvelope(msg)
+	if err != nil || env.EventID != "evt-1" {
+		t.Errorf("message = %s (%v), want envelope evt-1", msg, err)
+	}
+
+	if gotReq.URL.Path != "/v1/nodes/node-1/events/ws" {
+		t.Errorf("path = %q", gotReq.URL.Path)
+	}
+	if got := gotReq.URL.Query().Get("types"); got != "peer_added" {
+		t.Errorf("types = %q, want %q", got, "peer_added")
+	}
+	for header, want := range map[string]string{
+		"Upgrade":               "websocket",
+		"Sec-WebSocket-Version": "13",
+		"Authorization":         "Bearer test-token",
+		"Last-Event-ID":         "evt-0",
+	} {
+		if got := gotReq.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestConnectWebSocket_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    error
+	}{
+		{"not found", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "not found", http.StatusNotFound)
+		}, ErrNotFound},
+		{"not upgraded", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}, ErrWebSocketProtocol},
+		{"bad accept", func(w http.ResponseWriter, r *http.Request) {
+			conn, rw, _ := w.(http.Hijacker).Hijack()
+			defer conn.Close()
+			fmt.Fprint(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: wrong\r\n\r\n")
+			rw.Flush()
+		}, ErrWebSocketProtocol},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newEndpointTestClient(t, tt.handler)
+			_, err := client.ConnectWebSocket(context.Background(), "node-1", "", nil)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("ConnectWebSocket() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnectRelayWebSocket(t *testing.T) {
+	var gotPath string
+	received := make(chan []byte, 2)
+	client, _ := newEndpointTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		conn, rw := wsUpgrade(t, w, r)
+		if conn == nil {
+			return
+		}
+		defer conn.Close()
+		for range 2 {
+			op, payload, err := readClientFrame(rw)
+			if err != nil || op != wsOpBinary {
+				t.Errorf("client frame = %#x, %v; want binary", op, err)
+				return
+			}
+			received <- payload
+		}
+		writeServerFrame(rw, true, wsOpBinary, []byte("reply"))
+		rw.Flush()
+		readClientFrame(rw) // wait for close
+	})
+
+	conn, err := client.ConnectRelayWebSocket(context.Background(), "node-1")
+	if err != nil {
+		t.Fatalf("ConnectRelayWebSocket: %v", err)
+	}
+	defer conn.Close()
+
+	// A short message and one that needs the 16-bit extended length.
+	small, large := []byte("ping"), bytes.Repeat([]byte{0xab}, 1400)
+	for _, msg := range [][]byte{small, large} {
+		if err := conn.WriteMessage(msg); err != nil {
+			t.Fatalf("WriteMessage: %v", err)
+		}
+	}
+	for _, want := range [][]byte{small, large} {
+		if got := <-received; !bytes.Equal(got, want) {
+			t.Errorf("server received %d bytes, want %d", len(got), len(want))
+		}
+	}
+
+	msg, err := conn.ReadMessage()
+	if err != nil || string(msg) != "reply" {
+		t.Errorf("ReadMessage = %q, %v; want reply", msg, err)
+	}
+	if gotPath != "/v1/nodes/node-1/relay/ws" {
+		t.Errorf("path = %q, want /v1/nodes/node-1/relay/ws", gotPath)
+	}
+}
+
+func TestWebSocketConn_ReadMessage(t *testing.T) {
+	client, server := net.Pipe()
+	conn := newWebSocketConn(client, 1024)
+	defer conn.Close()
+	// Closed first, so that the close frame of conn.Close fails at once.
+	defer server.Close()
+
+	pongs := make(chan []byte, 1)
+	closes := make(chan []byte, 1)
+	go func() {
+		writeServerFrame(server, false, wsOpText, []byte(`{"a":`))
+		writeServerFrame(server, true, wsOpPing, []byte("hi"))
+		if op, payload, err := readClientFrame(server); err == nil && op == wsOpPong {
+			pongs <- payload
+		}
+		writeServerFrame(server, true, wsOpContinuation, []byte(`1}`))
+		writeServerFrame(server, true, wsOpClose, []byte{0x03, 0xe8, 'b', 'y', 'e'})
+		if op, payload, err := readClientFrame(server); err == nil && op == wsOpClose {
+			closes <- payload
+		}
+	}()
+
+	msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if string(msg) != `{"a":1}` {
+		t.Errorf("message = %q, want reassembled fragments", msg)
+	}
+	if got := <-pongs; string(got) != "hi" {
+		t.Errorf("pong payload = %q, want %q", got, "hi")
+	}
+
+	if _, err := conn.ReadMessage(); err != io.EOF {
+		t.Errorf("ReadMessage after close frame = %v, want io.EOF", err)
+	}
+	if got := <-closes; !bytes.Equal(got, []byte{0x03, 0xe8}) {
+		t.Errorf("close reply = %v, want status 1000", got)
+	}
+}
+
+func TestWebSocketConn_InvalidFrames(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+		want  error
+	}{
+		{"too large", append([]byte{0x81, 126, 0x01, 0x00}, make([]byte, 256)...), ErrResponseTooLarge},
+		{"masked", []byte{0x81, 0x81, 1, 2, 3, 4, 'x'}, ErrWebSocketProtocol},
+		{"reserved bits", []byte{0xc1, 0x01, 'x'}, ErrWebSocketProtocol},
+		{"continuation first", []byte{0x80, 0x01, 'x'}, ErrWebSocketProtocol},
+		{"fragmented control", []byte{0x09, 0x00}, ErrWebSocketProtocol},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newWebSocketConn(nopWriteCloser{bytes.NewReader(tt.frame)}, 128)
+			if _, err := conn.ReadMessage(); !errors.Is(err, tt.want) {
+				t.Errorf("ReadMessage() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// nopWriteCloser turns a reader into a connection that discards writes.
+type nopWriteCloser struct{ io.Reader }
+
+func (nopWriteCloser) Write(p []byte) (int, error) { return len(p), nil }
+func (nopWriteCloser) Close() error                { return nil }
+
+func TestManager_WebSocketFallback(t *testing.T) {
+	var sseAttempts atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/ws") {
+			// A buffering proxy in front of the SSE stream.
+			sseAttempts.Add(1)
+			http.Error(w, "gateway timeout", http.StatusGatewayTimeout)
+			return
+		}
+		conn, rw := wsUpgrade(t, w, r)
+		if conn == nil {
+			return
+		}
+		defer conn.Close()
+		writeServerFrame(rw, true, wsOpText, makeEnvelopeJSON("peer_added", "evt-1"))
+		rw.Flush()
+		readClientFrame(rw) // wait for close
+	}))
+	defer srv.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := Config{BaseURL: srv.URL, EventTransport: EventTransportAuto, WebSocketFallbackAfter: 2}
+	client, err := NewControlPlane(cfg, "1.0.0-test", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := NewSSEManager(client, nil, logger)
+	mgr.SetReconnectIntervals(5*time.Millisecond, 10*time.Millisecond)
+	received := make(chan string, 1)
+	mgr.RegisterHandler("peer_added", func(_ context.Context, env SignedEnvelope) error {
+		received <- env.EventID
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- mgr.Start(ctx, "node-1") }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	select {
+	case id := <-received:
+		if id != "evt-1" {
+			t.Errorf("event ID = %q, want evt-1", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received over WebSocket")
+	}
+	if n := sseAttempts.Load(); n != 2 {
+		t.Errorf("SSE attempts = %d, want 2 before fallback", n)
+	}
+	st := mgr.Status()
+	if st.Transport != EventTransportWebSocket || st.LastEventID != "evt-1" {
+		t.Errorf("Status() = %+v, want websocket transport with last event evt-1", st)
+	}
+}
+
+func TestSSEStream_WebSocketUnavailable(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client, err := NewControlPlane(Config{BaseURL: "http://127.0.0.1", EventTransport: EventTransportAuto}, "1.0.0-test", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := NewSSEStream(client, nil, NewEventDispatcher(logger), time.Minute, logger)
+	stream.transport = EventTransportWebSocket
+
+	notFound := &APIError{StatusCode: http.StatusNotFound, Message: "not found"}
+	err = stream.selectTransport(EventTransportWebSocket, 0, notFound)
+	if ClassifyError(err) != RetryTransient {
+		t.Errorf("error %v is not transient; a missing WebSocket endpoint would stop reconnecting", err)
+	}
+	if got := stream.Status().Transport; got != EventTransportSSE {
+		t.Errorf("Transport = %q, want switch back to %q", got, EventTransportSSE)
+	}
+}
@@ -0,0 +1,165 @@
+package auditfwd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// MutationEventType is the event type of the audit entries of host changes.
+const MutationEventType = "host_change"
+
+// maxPendingMutations bounds the audit entries buffered between Collect
+// calls; the oldest are dropped first.
+const maxPendingMutations = 1024
+
+// Mutation describes a change the agent made to the host.
+type Mutation struct {
+	// Action names the change, e.g. "route_add" or "hook_exec".
+	Action string
+
+	// Target is a short description of the changed object, e.g.
+	// "10.1.0.0/24 dev plexd0".
+	Target string
+
+	// Object holds the details of the change. Secret values must not be
+	// included.
+	Object map[string]any
+
+	// Err is the error of a failed change, nil on success.
+	Err error
+}
+
+// Recorder turns the host changes of all subsystems into audit entries,
+// which the Forwarder collects and reports in batches. Each entry is
+// attributed to the cause carried by the context passed to Record or, for
+// changes made without one, to the causes being handled at the time (see
+// Track). It implements AuditSource and api.CauseTracker, and is safe for
+// concurrent use.
+type Recorder struct {
+	hostname string
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries []api.AuditEntry
+	active  []trackedCause
+	nextID  uint64
+}
+
+// trackedCause is a cause being handled, from Track until done.
+type trackedCause struct {
+	id    uint64
+	cause api.Cause
+}
+
+// NewRecorder creates a Recorder whose entries carry hostname.
+func NewRecorder(hostname string) *Recorder {
+	return &Recorder{hostname: hostname, now: time.Now}
+}
+
+// Track marks c as being handled until the returned function is called.
+// Record attributes changes without a cause in their context to every
+// cause being handled; usually there is one.
+func (r *Recorder) Track(c api.Cause) (done func()) {
+	r.mu.Lock()
+	r.nextID++
+	id := r.nextID
+	r.active = append(r.active, trackedCause{id: id, cause: c})
+	r.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			for i, t := range r.active {
+				if t.id == id {
+					r.active = append(r.active[:i], r.active[i+1:]...)
+					break
+				}
+			}
+		})
+	}
+}
+
+// Record queues the audit entry of m.
+func (r *Recorder) Record(ctx context.Context, m Mutation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var causes []api.Cause
+	if c, ok := api.CauseFromContext(ctx); ok {
+		causes = []api.Cause{c}
+	} else {
+		for _, t := range r.active {
+			causes = append(causes, t.cause)
+		}
+	}
+	if len(causes) == 0 {
+		causes = []api.Cause{{Type: api.CauseAgent}}
+	}
+
+	result := "success"
+	object := make(map[string]any, len(m.Object)+1)
+	for k, v := range m.Object {
+		object[k] = v
+	}
+	if m.Err != nil {
+		result = "failure"
+		object["error"] = m.Err.Error()
+	}
+	subjectJSON, _ := json.Marshal(map[string][]api.Cause{"causes": causes})
+	objectJSON, _ := json.Marshal(object)
+
+	raw := fmt.Sprintf("%s %s: %s (%s)", m.Action, m.Target, result, describeCauses(causes))
+	if m.Err != nil {
+		raw = fmt.Sprintf("%s %s: %s: %v (%s)", m.Action, m.Target, result, m.Err, describeCauses(causes))
+	}
+
+	r.entries = append(r.entries, api.AuditEntry{
+		Timestamp: r.now().UTC(),
+		Source:    "plexd",
+		EventType: MutationEventType,
+		Subject:   subjectJSON,
+		Object:    objectJSON,
+		Action:    m.Action,
+		Result:    result,
+		Hostname:  r.hostname,
+		Raw:       raw,
+	})
+	if over := len(r.entries) - maxPendingMutations; over > 0 {
+		r.entries = r.entries[over:]
+	}
+}
+
+// Collect returns and clears the audit entries recorded since the last
+// call. It implements AuditSource.
+func (r *Recorder) Collect(_ context.Context) ([]api.AuditEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := r.entries
+	r.entries = nil
+	return entries, nil
+}
+
+// describeCauses returns a short description of causes for the raw line,
+// e.g. "event evt-1 peer_added".
+func describeCauses(causes []api.Cause) string {
+	parts := make([]string, len(causes))
+	for i, c := range causes {
+		s := c.Type
+		if c.ID != "" {
+			s += " " + c.ID
+		}
+		if c.EventType != "" {
+			s += " " + c.EventType
+		}
+		parts[i] = s
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,147 @@
+package auditfwd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+func recordedCauses(t *testing.T, e api.AuditEntry) []api.Cause {
+	t.Helper()
+	var subject struct {
+		Causes []api.Cause `json:"causes"`
+	}
+	if err := json.Unmarshal(e.Subject, &subject); err != nil {
+		t.Fatalf("unmarshal subject: %v", err)
+	}
+	return subject.Causes
+}
+
+func TestRecorder_CauseFromContext(t *testing.T) {
+	rec := NewRecorder("node-1")
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	rec.now = func() time.Time { return now }
+
+	done := rec.Track(api.Cause{Type: api.CauseReconcile, ID: "interval"})
+	defer done()
+
+	ctx := api.WithCause(context.Background(), api.Cause{Type: api.CauseEvent, ID: "evt-1", EventType: "peer_added"})
+	rec.Record(ctx, Mutation{
+		Action: "route_add",
+		Target: "10.1.0.0/24 dev plexd0",
+		Object: map[string]any{"subnet": "10.1.0.0/24"},
+	})
+
+	entries, err := rec.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Source != "plexd" || e.EventType != MutationEventType || e.Action != "route_add" || e.Result != "success" {
+		t.Errorf("entry = %+v", e)
+	}
+	if e.Hostname != "node-1" || !e.Timestamp.Equal(now) {
+		t.Errorf("hostname = %q, timestamp = %v", e.Hostname, e.Timestamp)
+	}
+	causes := recordedCauses(t, e)
+	if len(causes) != 1 || causes[0].ID != "evt-1" || causes[0].EventType != "peer_added" {
+		t.Errorf("causes = %+v, want only the context cause", causes)
+	}
+	if want := "route_add 10.1.0.0/24 dev plexd0: success (event evt-1 peer_added)"; e.Raw != want {
+		t.Errorf("Raw = %q, want %q", e.Raw, want)
+	}
+
+	entries, _ = rec.Collect(context.Background())
+	if len(entries) != 0 {
+		t.Errorf("second Collect returned %d entries, want 0", len(entries))
+	}
+}
+
+func TestRecorder_TrackedCauses(t *testing.T) {
+	rec := NewRecorder("node-1")
+
+	doneEvent := rec.Track(api.Cause{Type: api.CauseEvent, ID: "evt-1"})
+	doneDrift := rec.Track(api.Cause{Type: api.CauseDriftCheck})
+	rec.Record(context.Background(), Mutation{Action: "peer_add", Target: "peer"})
+
+	doneEvent()
+	doneEvent() // idempotent
+	rec.Record(context.Background(), Mutation{Action: "peer_remove", Target: "peer"})
+
+	doneDrift()
+	rec.Record(context.Background(), Mutation{Action: "interface_up", Target: "plexd0"})
+
+	entries, _ := rec.Collect(context.Background())
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	wantTypes := [][]string{
+		{api.CauseEvent, api.CauseDriftCheck},
+		{api.CauseDriftCheck},
+		{api.CauseAgent},
+	}
+	for i, want := range wantTypes {
+		causes := recordedCauses(t, entries[i])
+		if len(causes) != len(want) {
+			t.Fatalf("entry %d: causes = %+v, want types %v", i, causes, want)
+		}
+		for j, c := range causes {
+			if c.Type != want[j] {
+				t.Errorf("entry %d: cause %d type = %q, want %q", i, j, c.Type, want[j])
+			}
+		}
+	}
+}
+
+func TestRecorder_Failure(t *testing.T) {
+	rec := NewRecorder("node-1")
+	rec.Record(context.Background(), Mutation{
+		Action: "firewall_rules_apply",
+		Target: "plexd-mesh",
+		Object: map[string]any{"chain": "plexd-mesh"},
+		Err:    errors.New("nft: permission denied"),
+	})
+
+	entries, _ := rec.Collect(context.Background())
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Result != "failure" {
+		t.Errorf("Result = %q, want failure", e.Result)
+	}
+	var object map[string]any
+	if err := json.Unmarshal(e.Object, &object); err != nil {
+		t.Fatalf("unmarshal object: %v", err)
+	}
+	if object["chain"] != "plexd-mesh" || object["error"] != "nft: permission denied" {
+		t.Errorf("object = %v", object)
+	}
+	if !strings.Contains(e.Raw, "failure: nft: permission denied (agent)") {
+		t.Errorf("Raw = %q", e.Raw)
+	}
+}
+
+func TestRecorder_BoundedBuffer(t *testing.T) {
+	rec := NewRecorder("node-1")
+	for i := 0; i < maxPendingMutations+10; i++ {
+		rec.Record(context.Background(), Mutation{Action: "route_add", Target: "r"})
+	}
+	rec.Record(context.Background(), Mutation{Action: "route_remove", Target: "last"})
+
+	entries, _ := rec.Collect(context.Background())
+	if len(entries) != maxPendingMutations {
+		t.Fatalf("got %d entries, want %d", len(entries), maxPendingMutations)
+	}
+	if entries[len(entries)-1].Action != "route_remove" {
+		t.Errorf("newest entry dropped")
+	}
+}
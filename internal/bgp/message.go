@@ -0,0 +1,546 @@
+// Package bgp implements the subset of BGP-4 (RFC 4271) that a bridge node
+// needs to exchange unicast routes with a local router: sessions with
+// configured neighbors, four-octet AS numbers (RFC 6793), and IPv4 and IPv6
+// unicast routes through multiprotocol extensions (RFC 4760). It neither
+// selects paths across neighbors nor re-advertises learned routes; the
+// caller decides what to do with them.
+package bgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+)
+
+// Message types.
+const (
+	msgOpen         = 1
+	msgUpdate       = 2
+	msgNotification = 3
+	msgKeepalive    = 4
+)
+
+const (
+	headerLen  = 19
+	maxMsgLen  = 4096
+	bgpVersion = 4
+)
+
+// Path attribute type codes.
+const (
+	attrOrigin      = 1
+	attrASPath      = 2
+	attrNextHop     = 3
+	attrLocalPref   = 5
+	attrMPReach     = 14
+	attrMPUnreach   = 15
+	flagOptional    = 0x80
+	flagTransitive  = 0x40
+	flagExtendedLen = 0x10
+)
+
+// AS_PATH segment types.
+const (
+	asSet      = 1
+	asSequence = 2
+)
+
+// OPEN capability codes.
+const (
+	capMultiprotocol = 1
+	capFourOctetAS   = 65
+)
+
+// asTrans is the AS number sent in the two-octet fields in place of a
+// four-octet AS (RFC 6793).
+const asTrans = 23456
+
+// originIGP is the ORIGIN of routes advertised by the speaker.
+const originIGP = 0
+
+// NOTIFICATION error codes and subcodes.
+const (
+	errMessageHeader = 1
+	errOpenMessage   = 2
+	errUpdateMessage = 3
+	errHoldTimer     = 4
+	errFSM           = 5
+	errCease         = 6
+
+	subBadPeerAS      = 2
+	subUnacceptHold   = 6
+	subBadBGPID       = 3
+	subUnsupVersion   = 1
+	subMalformedAttrs = 1
+	subAdminShutdown  = 2
+	subBadLength      = 2
+)
+
+// Family is an address family and subsequent address family (AFI/SAFI).
+type Family struct {
+	AFI  uint16
+	SAFI uint8
+}
+
+// The families the speaker exchanges routes of.
+var (
+	IPv4Unicast = Family{AFI: 1, SAFI: 1}
+	IPv6Unicast = Family{AFI: 2, SAFI: 1}
+)
+
+// familyOf returns the unicast family of p.
+func familyOf(p netip.Prefix) Family {
+	if p.Addr().Is4() {
+		return IPv4Unicast
+	}
+	return IPv6Unicast
+}
+
+// open is an OPEN message.
+type open struct {
+	AS       uint32
+	HoldTime uint16
+	RouterID netip.Addr
+	// AS4 is whether the sender supports four-octet AS numbers; AS then
+	// holds its four-octet AS.
+	AS4 bool
+	// Families are the families the sender announced; none means IPv4
+	// unicast only.
+	Families []Family
+}
+
+// update is an UPDATE message. IPv4 routes are carried in the classic
+// fields, IPv6 routes in MP_REACH_NLRI and MP_UNREACH_NLRI.
+type update struct {
+	Withdrawn []netip.Prefix
+	NLRI      []netip.Prefix
+	Origin    uint8
+	ASPath    []uint32
+	// NextHop is the next hop of the IPv4 routes in NLRI, NextHop6 that
+	// of the IPv6 routes.
+	NextHop   netip.Addr
+	NextHop6  netip.Addr
+	LocalPref uint32
+}
+
+// notification is a NOTIFICATION message.
+type notification struct {
+	Code    uint8
+	Subcode uint8
+	Data    []byte
+}
+
+func (n *notification) Error() string {
+	return fmt.Sprintf("bgp: notification %d/%d", n.Code, n.Subcode)
+}
+
+// errMalformed reports a message the speaker cannot parse. It is answered
+// with the NOTIFICATION it carries.
+type errMalformed struct {
+	notification
+	msg string
+}
+
+func (e *errMalformed) Error() string {
+	return "bgp: " + e.msg
+}
+
+func malformed(code, subcode uint8, format string, args ...any) error {
+	return &errMalformed{notification{Code: code, Subcode: subcode}, fmt.Sprintf(format, args...)}
+}
+
+// frame prepends the header of a message of type typ to body.
+func frame(typ byte, body []byte) []byte {
+	b := make([]byte, headerLen, headerLen+len(body))
+	for i := range 16 {
+		b[i] = 0xff
+	}
+	binary.BigEndian.PutUint16(b[16:], uint16(headerLen+len(body)))
+	b[18] = typ
+	return append(b, body...)
+}
+
+// parseHeader checks the header of a message and returns its type and
+// length.
+func parseHeader(b []byte) (typ byte, length int, err error) {
+	for _, c := range b[:16] {
+		if c != 0xff {
+			return 0, 0, malformed(errMessageHeader, 1, "connection not synchronized")
+		}
+	}
+	length = int(binary.BigEndian.Uint16(b[16:]))
+	if length < headerLen || length > maxMsgLen {
+		return 0, 0, malformed(errMessageHeader, subBadLength, "bad message length %d", length)
+	}
+	return b[18], length, nil
+}
+
+func marshalOpen(o open) []byte {
+	var caps []byte
+	for _, f := range o.Families {
+		caps = append(caps, capMultiprotocol, 4)
+		caps = binary.BigEndian.AppendUint16(caps, f.AFI)
+		caps = append(caps, 0, f.SAFI)
+	}
+	if o.AS4 {
+		caps = append(caps, capFourOctetAS, 4)
+		caps = binary.BigEndian.AppendUint32(caps, o.AS)
+	}
+	as := o.AS
+	if as > 0xffff {
+		as = asTrans
+	}
+	b := []byte{bgpVersion}
+	b = binary.BigEndian.AppendUint16(b, uint16(as))
+	b = binary.BigEndian.AppendUint16(b, o.HoldTime)
+	id := o.RouterID.As4()
+	b = append(b, id[:]...)
+	if len(caps) == 0 {
+		return frame(msgOpen, append(b, 0))
+	}
+	// All capabilities in one optional parameter of type 2.
+	b = append(b, byte(2+len(caps)), 2, byte(len(caps)))
+	return frame(msgOpen, append(b, caps...))
+}
+
+func parseOpen(b []byte) (open, error) {
+	if len(b) < 10 {
+		return open{}, malformed(errMessageHeader, subBadLength, "short OPEN")
+	}
+	if b[0] != bgpVersion {
+		return open{}, malformed(errOpenMessage, subUnsupVersion, "unsupported version %d", b[0])
+	}
+	o := open{
+		AS:       uint32(binary.BigEndian.Uint16(b[1:])),
+		HoldTime: binary.BigEndian.Uint16(b[3:]),
+		RouterID: netip.AddrFrom4([4]byte(b[5:9])),
+	}
+	params := b[10:]
+	if len(params) != int(b[9]) {
+		return open{}, malformed(errOpenMessage, 0, "bad optional parameters length")
+	}
+	for len(params) > 0 {
+		if len(params) < 2 || len(params) < 2+int(params[1]) {
+			return open{}, malformed(errOpenMessage, 0, "truncated optional parameter")
+		}
+		typ, val := params[0], params[2:2+int(params[1])]
+		params = params[2+int(params[1]):]
+		if typ != 2 {
+			continue
+		}
+		for len(val) > 0 {
+			if len(val) < 2 || len(val) < 2+int(val[1]) {
+				return open{}, malformed(errOpenMessage, 0, "truncated capability")
+			}
+			code, cv := val[0], val[2:2+int(val[1])]
+			val = val[2+int(val[1]):]
+			switch {
+			case code == capMultiprotocol && len(cv) == 4:
+				o.Families = append(o.Families, Family{AFI: binary.BigEndian.Uint16(cv), SAFI: cv[3]})
+			case code == capFourOctetAS && len(cv) == 4:
+				o.AS4 = true
+				o.AS = binary.BigEndian.Uint32(cv)
+			}
+		}
+	}
+	return o, nil
+}
+
+// appendPrefix appends p in NLRI encoding: its length in bits and the
+// significant octets of its address.
+func appendPrefix(b []byte, p netip.Prefix) []byte {
+	b = append(b, byte(p.Bits()))
+	return append(b, p.Addr().AsSlice()[:(p.Bits()+7)/8]...)
+}
+
+// parsePrefixes parses the NLRI encoded prefixes of family f in b.
+func parsePrefixes(b []byte, f Family) ([]netip.Prefix, error) {
+	size := 4
+	if f == IPv6Unicast {
+		size = 16
+	}
+	var ps []netip.Prefix
+	for len(b) > 0 {
+		bits := int(b[0])
+		n := (bits + 7) / 8
+		if bits > size*8 || len(b) < 1+n {
+			return nil, malformed(errUpdateMessage, 10, "invalid prefix length %d", bits)
+		}
+		addr := make([]byte, size)
+		copy(addr, b[1:1+n])
+		a, _ := netip.AddrFromSlice(addr)
+		ps = append(ps, netip.PrefixFrom(a, bits).Masked())
+		b = b[1+n:]
+	}
+	return ps, nil
+}
+
+// appendAttr appends a path attribute, with an extended length when val
+// needs one.
+func appendAttr(b []byte, flags, typ byte, val []byte) []byte {
+	if len(val) > 0xff {
+		b = append(b, flags|flagExtendedLen, typ)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(val)))
+	} else {
+		b = append(b, flags, typ, byte(len(val)))
+	}
+	return append(b, val...)
+}
+
+// marshalUpdate encodes u. as4 selects four-octet AS numbers in AS_PATH;
+// without it, AS numbers above 65535 are sent as AS_TRANS. ibgp adds
+// LOCAL_PREF.
+func marshalUpdate(u update, as4, ibgp bool) []byte {
+	var withdrawn4, withdrawn6, nlri4, nlri6 []byte
+	for _, p := range u.Withdrawn {
+		if p.Addr().Is4() {
+			withdrawn4 = appendPrefix(withdrawn4, p)
+		} else {
+			withdrawn6 = appendPrefix(withdrawn6, p)
+		}
+	}
+	for _, p := range u.NLRI {
+		if p.Addr().Is4() {
+			nlri4 = appendPrefix(nlri4, p)
+		} else {
+			nlri6 = appendPrefix(nlri6, p)
+		}
+	}
+
+	var attrs []byte
+	if len(u.NLRI) > 0 {
+		attrs = appendAttr(attrs, flagTransitive, attrOrigin, []byte{u.Origin})
+		var path []byte
+		if len(u.ASPath) > 0 {
+			path = []byte{asSequence, byte(len(u.ASPath))}
+			for _, as := range u.ASPath {
+				if as4 {
+					path = binary.BigEndian.AppendUint32(path, as)
+				} else {
+					if as > 0xffff {
+						as = asTrans
+					}
+					path = binary.BigEndian.AppendUint16(path, uint16(as))
+				}
+			}
+		}
+		attrs = appendAttr(attrs, flagTransitive, attrASPath, path)
+		if len(nlri4) > 0 {
+			nh := u.NextHop.As4()
+			attrs = appendAttr(attrs, flagTransitive, attrNextHop, nh[:])
+		}
+		if ibgp {
+			attrs = appendAttr(attrs, flagTransitive, attrLocalPref, binary.BigEndian.AppendUint32(nil, u.LocalPref))
+		}
+		if len(nlri6) > 0 {
+			nh := u.NextHop6.As16()
+			val := binary.BigEndian.AppendUint16(nil, IPv6Unicast.AFI)
+			val = append(val, IPv6Unicast.SAFI, 16)
+			val = append(val, nh[:]...)
+			val = append(val, 0)
+			attrs = appendAttr(attrs, flagOptional, attrMPReach, append(val, nlri6...))
+		}
+	}
+	if len(withdrawn6) > 0 {
+		val := binary.BigEndian.AppendUint16(nil, IPv6Unicast.AFI)
+		val = append(val, IPv6Unicast.SAFI)
+		attrs = appendAttr(attrs, flagOptional, attrMPUnreach, append(val, withdrawn6...))
+	}
+
+	b := binary.BigEndian.AppendUint16(nil, uint16(len(withdrawn4)))
+	b = append(b, withdrawn4...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(attrs)))
+	b = append(b, attrs...)
+	return frame(msgUpdate, append(b, nlri4...))
+}
+
+// parseUpdate decodes the body of an UPDATE. as4 selects four-octet AS
+// numbers in AS_PATH. Attributes the speaker does not use are skipped.
+func parseUpdate(b []byte, as4 bool) (update, error) {
+	var u update
+	bad := malformed(errUpdateMessage, subMalformedAttrs, "malformed UPDATE")
+	if len(b) < 4 {
+		return u, bad
+	}
+	wl := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+wl+2 {
+		return u, bad
+	}
+	var err error
+	if u.Withdrawn, err = parsePrefixes(b[2:2+wl], IPv4Unicast); err != nil {
+		return u, err
+	}
+	b = b[2+wl:]
+	al := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+al {
+		return u, bad
+	}
+	attrs, nlri := b[2:2+al], b[2+al:]
+	if u.NLRI, err = parsePrefixes(nlri, IPv4Unicast); err != nil {
+		return u, err
+	}
+
+	for len(attrs) > 0 {
+		if len(attrs) < 3 {
+			return u, bad
+		}
+		flags, typ := attrs[0], attrs[1]
+		var n, off int
+		if flags&flagExtendedLen != 0 {
+			if len(attrs) < 4 {
+				return u, bad
+			}
+			n, off = int(binary.BigEndian.Uint16(attrs[2:])), 4
+		} else {
+			n, off = int(attrs[2]), 3
+		}
+		if len(attrs) < off+n {
+			return u, bad
+		}
+		val := attrs[off : off+n]
+		attrs = attrs[off+n:]
+
+		switch typ {
+		case attrOrigin:
+			if n != 1 {
+				return u, bad
+			}
+			u.Origin = val[0]
+		case attrASPath:
+			if u.ASPath, err = parseASPath(val, as4); err != nil {
+				return u, err
+			}
+		case attrNextHop:
+			if n != 4 {
+				return u, bad
+			}
+			u.NextHop = netip.AddrFrom4([4]byte(val))
+		case attrLocalPref:
+			if n != 4 {
+				return u, bad
+			}
+			u.LocalPref = binary.BigEndian.Uint32(val)
+		case attrMPReach:
+			if err := parseMPReach(&u, val); err != nil {
+				return u, err
+			}
+		case attrMPUnreach:
+			if n < 3 {
+				return u, bad
+			}
+			f := Family{AFI: binary.BigEndian.Uint16(val), SAFI: val[2]}
+			if f != IPv4Unicast && f != IPv6Unicast {
+				continue
+			}
+			ps, err := parsePrefixes(val[3:], f)
+			if err != nil {
+				return u, err
+			}
+			u.Withdrawn = append(u.Withdrawn, ps...)
+		}
+	}
+	if len(nlri) > 0 && !u.NextHop.IsValid() {
+		return u, malformed(errUpdateMessage, 3, "missing NEXT_HOP")
+	}
+	return u, nil
+}
+
+// parseMPReach adds the routes of an MP_REACH_NLRI attribute to u. Families
+// other than IPv4 and IPv6 unicast are skipped.
+func parseMPReach(u *update, val []byte) error {
+	bad := malformed(errUpdateMessage, 9, "malformed MP_REACH_NLRI")
+	if len(val) < 5 || len(val) < 5+int(val[3]) {
+		return bad
+	}
+	f := Family{AFI: binary.BigEndian.Uint16(val), SAFI: val[2]}
+	nhLen := int(val[3])
+	nh := val[4 : 4+nhLen]
+	rest := val[4+nhLen:]
+	if len(rest) < 1 {
+		return bad
+	}
+	rest = rest[1:] // reserved
+	var next netip.Addr
+	switch {
+	case f == IPv6Unicast && (nhLen == 16 || nhLen == 32):
+		// A second next hop is the link-local one; the global one is used.
+		next = netip.AddrFrom16([16]byte(nh[:16]))
+	case f == IPv4Unicast && nhLen == 4:
+		next = netip.AddrFrom4([4]byte(nh))
+	case f == IPv4Unicast || f == IPv6Unicast:
+		return bad
+	default:
+		return nil
+	}
+	ps, err := parsePrefixes(rest, f)
+	if err != nil {
+		return err
+	}
+	if f == IPv6Unicast {
+		u.NextHop6 = next
+	} else {
+		u.NextHop = next
+	}
+	u.NLRI = append(u.NLRI, ps...)
+	return nil
+}
+
+// parseASPath returns the AS numbers of the AS_SEQUENCE and AS_SET segments
+// of an AS_PATH, in order.
+func parseASPath(b []byte, as4 bool) ([]uint32, error) {
+	size := 2
+	if as4 {
+		size = 4
+	}
+	var path []uint32
+	for len(b) > 0 {
+		if len(b) < 2 || (b[0] != asSet && b[0] != asSequence) || len(b) < 2+int(b[1])*size {
+			return nil, malformed(errUpdateMessage, 11, "malformed AS_PATH")
+		}
+		n := int(b[1])
+		for i := range n {
+			v := b[2+i*size:]
+			if as4 {
+				path = append(path, binary.BigEndian.Uint32(v))
+			} else {
+				path = append(path, uint32(binary.BigEndian.Uint16(v)))
+			}
+		}
+		b = b[2+n*size:]
+	}
+	return path, nil
+}
+
+func marshalNotification(n notification) []byte {
+	return frame(msgNotification, append([]byte{n.Code, n.Subcode}, n.Data...))
+}
+
+func parseNotification(b []byte) (*notification, error) {
+	if len(b) < 2 {
+		return nil, errors.New("bgp: short NOTIFICATION")
+	}
+	return &notification{Code: b[0], Subcode: b[1], Data: b[2:]}, nil
+}
+
+func marshalKeepalive() []byte {
+	return frame(msgKeepalive, nil)
+}
+
+// readMessage reads a message from r and returns its type and body.
+func readMessage(r io.Reader) (byte, []byte, error) {
+	hdr := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return 0, nil, err
+	}
+	typ, length, err := parseHeader(hdr)
+	if err != nil {
+		return 0, nil, err
+	}
+	body := make([]byte, length-headerLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return typ, body, nil
+}
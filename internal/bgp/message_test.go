@@ -0,0 +1,170 @@
+package bgp
+
+import (
+	"errors"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestOpen_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   open
+		want open
+	}{
+		{
+			name: "four-octet AS",
+			in:   open{AS: 4200000001, HoldTime: 90, RouterID: netip.MustParseAddr("192.0.2.1"), AS4: true, Families: []Family{IPv4Unicast, IPv6Unicast}},
+			want: open{AS: 4200000001, HoldTime: 90, RouterID: netip.MustParseAddr("192.0.2.1"), AS4: true, Families: []Family{IPv4Unicast, IPv6Unicast}},
+		},
+		{
+			name: "no capabilities",
+			in:   open{AS: 64512, HoldTime: 0, RouterID: netip.MustParseAddr("192.0.2.2")},
+			want: open{AS: 64512, HoldTime: 0, RouterID: netip.MustParseAddr("192.0.2.2")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := marshalOpen(tt.in)
+			typ, length, err := parseHeader(b)
+			if err != nil || typ != msgOpen || length != len(b) {
+				t.Fatalf("parseHeader = %d, %d, %v", typ, length, err)
+			}
+			got, err := parseOpen(b[headerLen:])
+			if err != nil {
+				t.Fatalf("parseOpen: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseOpen = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpen_ASTrans(t *testing.T) {
+	b := marshalOpen(open{AS: 4200000001, RouterID: netip.MustParseAddr("192.0.2.1")})
+	got, err := parseOpen(b[headerLen:])
+	if err != nil {
+		t.Fatalf("parseOpen: %v", err)
+	}
+	if got.AS != asTrans {
+		t.Errorf("AS = %d, want AS_TRANS", got.AS)
+	}
+}
+
+func TestUpdate_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   update
+		as4  bool
+		ibgp bool
+		want update
+	}{
+		{
+			name: "IPv4 and IPv6 routes",
+			in: update{
+				NLRI:     []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16"), netip.MustParsePrefix("fd00:1::/48")},
+				ASPath:   []uint32{4200000001},
+				NextHop:  netip.MustParseAddr("192.0.2.1"),
+				NextHop6: netip.MustParseAddr("2001:db8::1"),
+			},
+			as4: true,
+			want: update{
+				NLRI:     []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16"), netip.MustParsePrefix("fd00:1::/48")},
+				ASPath:   []uint32{4200000001},
+				NextHop:  netip.MustParseAddr("192.0.2.1"),
+				NextHop6: netip.MustParseAddr("2001:db8::1"),
+			},
+		},
+		{
+			name: "two-octet AS path",
+			in: update{
+				NLRI:    []netip.Prefix{netip.MustParsePrefix("10.1.2.0/24")},
+				ASPath:  []uint32{64512, 4200000001},
+				NextHop: netip.MustParseAddr("192.0.2.1"),
+			},
+			want: update{
+				NLRI:    []netip.Prefix{netip.MustParsePrefix("10.1.2.0/24")},
+				ASPath:  []uint32{64512, asTrans},
+				NextHop: netip.MustParseAddr("192.0.2.1"),
+			},
+		},
+		{
+			name: "iBGP",
+			in: update{
+				NLRI:      []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0")},
+				NextHop:   netip.MustParseAddr("192.0.2.1"),
+				LocalPref: 100,
+			},
+			as4:  true,
+			ibgp: true,
+			want: update{
+				NLRI:      []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0")},
+				NextHop:   netip.MustParseAddr("192.0.2.1"),
+				LocalPref: 100,
+			},
+		},
+		{
+			name: "withdrawals",
+			in:   update{Withdrawn: []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16"), netip.MustParsePrefix("fd00:1::/48")}},
+			as4:  true,
+			want: update{Withdrawn: []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16"), netip.MustParsePrefix("fd00:1::/48")}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := marshalUpdate(tt.in, tt.as4, tt.ibgp)
+			got, err := parseUpdate(b[headerLen:], tt.as4)
+			if err != nil {
+				t.Fatalf("parseUpdate: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseUpdate = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUpdate_Malformed(t *testing.T) {
+	valid := marshalUpdate(update{
+		NLRI:    []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")},
+		ASPath:  []uint32{64512},
+		NextHop: netip.MustParseAddr("192.0.2.1"),
+	}, true, false)[headerLen:]
+
+	tests := []struct {
+		name string
+		body []byte
+	}{
+		{"short", []byte{0, 0}},
+		{"truncated attributes", valid[:len(valid)-8]},
+		{"prefix too long", []byte{0, 0, 0, 0, 33, 10, 0, 0, 0, 0}},
+		{"missing next hop", []byte{0, 0, 0, 0, 8, 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseUpdate(tt.body, true)
+			var m *errMalformed
+			if !errors.As(err, &m) {
+				t.Fatalf("parseUpdate error = %v, want a malformed message", err)
+			}
+			if m.Code != errUpdateMessage {
+				t.Errorf("NOTIFICATION code = %d, want %d", m.Code, errUpdateMessage)
+			}
+		})
+	}
+}
+
+func TestParseHeader_Invalid(t *testing.T) {
+	b := marshalKeepalive()
+	b[3] = 0
+	if _, _, err := parseHeader(b); err == nil {
+		t.Error("parseHeader accepted a header without marker")
+	}
+	b = marshalKeepalive()
+	b[16], b[17] = 0x10, 0x01
+	if _, _, err := parseHeader(b); err == nil {
+		t.Error("parseHeader accepted a length above 4096")
+	}
+}
@@ -0,0 +1,743 @@
+package bgp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultPort is the TCP port of BGP.
+const DefaultPort = 179
+
+// Defaults applied by NewSpeaker.
+const (
+	DefaultHoldTime     = 90 * time.Second
+	DefaultConnectRetry = 30 * time.Second
+)
+
+// openHoldTime bounds the wait for the OPEN and KEEPALIVE of a neighbor
+// before a session is established.
+const openHoldTime = 4 * time.Minute
+
+// writeTimeout bounds a write to a neighbor.
+const writeTimeout = 10 * time.Second
+
+// localPref is the LOCAL_PREF of routes advertised to iBGP neighbors.
+const localPref = 100
+
+// updateBatch is the number of prefixes per UPDATE, which keeps IPv6
+// UPDATEs within the maximum message size.
+const updateBatch = 200
+
+// Session states reported in NeighborStatus.
+const (
+	StateIdle        = "idle"
+	StateConnect     = "connect"
+	StateOpenSent    = "open_sent"
+	StateOpenConfirm = "open_confirm"
+	StateEstablished = "established"
+)
+
+// Config configures a Speaker.
+type Config struct {
+	// LocalAS is the AS number of the speaker.
+	LocalAS uint32
+	// RouterID is the BGP identifier of the speaker, an IPv4 address.
+	RouterID netip.Addr
+	// ListenAddr is the TCP address passive neighbors connect to, such as
+	// ":179". Empty means the speaker does not listen.
+	ListenAddr string
+	// HoldTime is the proposed hold time. Default: 90s.
+	HoldTime time.Duration
+	// ConnectRetry is the wait before a neighbor is dialed again after a
+	// failed connection or a closed session. Default: 30s.
+	ConnectRetry time.Duration
+}
+
+// Neighbor is a configured BGP neighbor.
+type Neighbor struct {
+	Address netip.Addr
+	AS      uint32
+	// Port is the port the neighbor is dialed on; 0 means DefaultPort.
+	Port int
+	// Passive neighbors are never dialed; the speaker waits for them to
+	// connect. Connections from neighbors that are not passive are refused,
+	// so there are no connection collisions.
+	Passive bool
+}
+
+// Route is a route received from a neighbor.
+type Route struct {
+	Prefix  netip.Prefix
+	NextHop netip.Addr
+	ASPath  []uint32
+	// Neighbor is the address of the neighbor the route was received from.
+	Neighbor netip.Addr
+}
+
+// NeighborStatus is the session state of a neighbor.
+type NeighborStatus struct {
+	Neighbor
+	State string
+	// Established is when the session was established; zero unless State
+	// is StateEstablished.
+	Established time.Time
+	// Received is the number of routes received in the session.
+	Received int
+	// Error is why the last session ended or could not be established.
+	Error string
+}
+
+// Speaker is a BGP speaker that advertises a set of prefixes to its
+// neighbors and collects the routes they advertise. It does not select
+// among the routes of different neighbors nor advertise received routes.
+// It is safe for concurrent use.
+type Speaker struct {
+	cfg    Config
+	logger *slog.Logger
+	dial   func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	mu         sync.Mutex
+	running    bool
+	ctx        context.Context
+	cancel     context.CancelFunc
+	ln         net.Listener
+	wg         sync.WaitGroup
+	peers      map[netip.Addr]*peer
+	advertised []netip.Prefix
+	hook       func()
+}
+
+// peer is the state of a neighbor. All fields but nb, incoming, and changed
+// are guarded by Speaker.mu.
+type peer struct {
+	nb       Neighbor
+	cancel   context.CancelFunc
+	done     chan struct{}
+	incoming chan net.Conn
+	changed  chan struct{}
+
+	state       string
+	established time.Time
+	err         string
+	routes      map[netip.Prefix]Route
+}
+
+// NewSpeaker returns a Speaker for cfg. It does not connect to neighbors
+// until Start. Zero durations in cfg select the defaults.
+func NewSpeaker(cfg Config, logger *slog.Logger) *Speaker {
+	if cfg.HoldTime <= 0 {
+		cfg.HoldTime = DefaultHoldTime
+	}
+	if cfg.ConnectRetry <= 0 {
+		cfg.ConnectRetry = DefaultConnectRetry
+	}
+	var d net.Dialer
+	return &Speaker{
+		cfg:    cfg,
+		logger: logger,
+		dial:   d.DialContext,
+		peers:  make(map[netip.Addr]*peer),
+	}
+}
+
+// SetRoutesHook sets fn to be called, without locks held, whenever the
+// received routes change. It must be called before Start.
+func (s *Speaker) SetRoutesHook(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hook = fn
+}
+
+// Start listens on ListenAddr, if set, and starts the sessions with the
+// neighbors. The sessions run until Stop or until ctx is cancelled.
+func (s *Speaker) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return errors.New("bgp: speaker already started")
+	}
+	if !s.cfg.RouterID.Is4() {
+		return fmt.Errorf("bgp: router ID %s is not an IPv4 address", s.cfg.RouterID)
+	}
+	if s.cfg.ListenAddr != "" {
+		ln, err := net.Listen("tcp", s.cfg.ListenAddr)
+		if err != nil {
+			return fmt.Errorf("bgp: listen: %w", err)
+		}
+		s.ln = ln
+	}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	s.running = true
+	if s.ln != nil {
+		s.wg.Add(1)
+		go s.accept(s.ln)
+	}
+	for _, p := range s.peers {
+		s.startPeer(p)
+	}
+	return nil
+}
+
+// Stop closes all sessions, sending a Cease NOTIFICATION to established
+// neighbors, and drops the received routes. It is a no-op if the speaker
+// is not running.
+func (s *Speaker) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	s.cancel()
+	if s.ln != nil {
+		s.ln.Close()
+		s.ln = nil
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Addr returns the address the speaker listens on, or nil if it does not
+// listen.
+func (s *Speaker) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ln == nil {
+		return nil
+	}
+	return s.ln.Addr()
+}
+
+// SetNeighbors replaces the configured neighbors. Sessions of removed or
+// changed neighbors are closed; those of unchanged neighbors are kept.
+func (s *Speaker) SetNeighbors(neighbors []Neighbor) {
+	want := make(map[netip.Addr]Neighbor, len(neighbors))
+	for _, nb := range neighbors {
+		if nb.Port == 0 {
+			nb.Port = DefaultPort
+		}
+		want[nb.Address] = nb
+	}
+
+	s.mu.Lock()
+	var stopped []*peer
+	for addr, p := range s.peers {
+		if nb, ok := want[addr]; ok && nb == p.nb {
+			continue
+		}
+		delete(s.peers, addr)
+		if p.cancel != nil {
+			p.cancel()
+			stopped = append(stopped, p)
+		}
+	}
+	for addr, nb := range want {
+		if _, ok := s.peers[addr]; ok {
+			continue
+		}
+		p := &peer{
+			nb:       nb,
+			incoming: make(chan net.Conn, 1),
+			changed:  make(chan struct{}, 1),
+			state:    StateIdle,
+		}
+		s.peers[addr] = p
+		if s.running {
+			s.startPeer(p)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, p := range stopped {
+		<-p.done
+	}
+}
+
+// Advertise replaces the prefixes advertised to the neighbors. IPv6
+// prefixes are only advertised over IPv6 sessions, IPv4 prefixes only over
+// IPv4 sessions, as the next hop is the local address of the session.
+func (s *Speaker) Advertise(prefixes []netip.Prefix) {
+	ps := make([]netip.Prefix, 0, len(prefixes))
+	for _, p := range prefixes {
+		ps = append(ps, p.Masked())
+	}
+	slices.SortFunc(ps, comparePrefix)
+	ps = slices.Compact(ps)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.Equal(ps, s.advertised) {
+		return
+	}
+	s.advertised = ps
+	for _, p := range s.peers {
+		select {
+		case p.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Routes returns the routes received from all neighbors, ordered by prefix
+// and neighbor.
+func (s *Speaker) Routes() []Route {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var routes []Route
+	for _, p := range s.peers {
+		for _, r := range p.routes {
+			r.ASPath = slices.Clone(r.ASPath)
+			routes = append(routes, r)
+		}
+	}
+	slices.SortFunc(routes, func(a, b Route) int {
+		if c := comparePrefix(a.Prefix, b.Prefix); c != 0 {
+			return c
+		}
+		return a.Neighbor.Compare(b.Neighbor)
+	})
+	return routes
+}
+
+// Neighbors returns the session state of the neighbors, ordered by
+// address.
+func (s *Speaker) Neighbors() []NeighborStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]NeighborStatus, 0, len(s.peers))
+	for _, p := range s.peers {
+		out = append(out, NeighborStatus{
+			Neighbor:    p.nb,
+			State:       p.state,
+			Established: p.established,
+			Received:    len(p.routes),
+			Error:       p.err,
+		})
+	}
+	slices.SortFunc(out, func(a, b NeighborStatus) int { return a.Address.Compare(b.Address) })
+	return out
+}
+
+// comparePrefix orders prefixes by address, then length.
+func comparePrefix(a, b netip.Prefix) int {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c
+	}
+	return a.Bits() - b.Bits()
+}
+
+// startPeer starts the session loop of p. The caller must hold s.mu and
+// s must be running.
+func (s *Speaker) startPeer(p *peer) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	p.cancel = cancel
+	p.done = make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(p.done)
+		s.run(ctx, p)
+	}()
+}
+
+// accept hands connections from passive neighbors to their session loops
+// and refuses all others.
+func (s *Speaker) accept(ln net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		addr := conn.RemoteAddr().(*net.TCPAddr).AddrPort().Addr().Unmap()
+		s.mu.Lock()
+		p, ok := s.peers[addr]
+		if !ok || !p.nb.Passive || p.state != StateIdle {
+			s.mu.Unlock()
+			s.logger.Debug("bgp: connection refused", "remote", addr)
+			conn.Close()
+			continue
+		}
+		select {
+		case p.incoming <- conn:
+		default:
+			conn.Close()
+		}
+		s.mu.Unlock()
+	}
+}
+
+// run connects to the neighbor of p, or waits for it to connect, and runs
+// sessions until ctx is cancelled.
+func (s *Speaker) run(ctx context.Context, p *peer) {
+	addr := net.JoinHostPort(p.nb.Address.String(), strconv.Itoa(p.nb.Port))
+	for {
+		var conn net.Conn
+		if p.nb.Passive {
+			select {
+			case conn = <-p.incoming:
+			case <-ctx.Done():
+				return
+			}
+		} else {
+			s.setState(p, StateConnect)
+			dctx, cancel := context.WithTimeout(ctx, s.cfg.ConnectRetry)
+			c, err := s.dial(dctx, "tcp", addr)
+			cancel()
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				s.endSession(p, fmt.Errorf("bgp: connect: %w", err))
+			}
+			conn = c
+		}
+
+		if conn != nil {
+			err := s.session(ctx, p, conn)
+			conn.Close()
+			s.endSession(p, err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if p.nb.Passive {
+			continue
+		}
+		select {
+		case <-time.After(s.cfg.ConnectRetry):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Speaker) setState(p *peer, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.state = state
+	if state == StateEstablished {
+		p.established = time.Now()
+		p.err = ""
+	}
+}
+
+// endSession records why the session of p ended and drops its routes.
+func (s *Speaker) endSession(p *peer, err error) {
+	s.mu.Lock()
+	wasEstablished := p.state == StateEstablished
+	p.state = StateIdle
+	p.established = time.Time{}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		p.err = err.Error()
+	}
+	hadRoutes := len(p.routes) > 0
+	p.routes = nil
+	hook := s.hook
+	s.mu.Unlock()
+
+	if wasEstablished {
+		s.logger.Info("bgp: session closed", "neighbor", p.nb.Address, "error", err)
+	}
+	if hadRoutes && hook != nil {
+		hook()
+	}
+}
+
+// message is a message read from a neighbor.
+type message struct {
+	typ  byte
+	body []byte
+}
+
+// session runs a session over conn until it fails or ctx is cancelled.
+func (s *Speaker) session(ctx context.Context, p *peer, conn net.Conn) error {
+	local := conn.LocalAddr().(*net.TCPAddr).AddrPort().Addr().Unmap()
+	write := func(b []byte) error {
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		_, err := conn.Write(b)
+		return err
+	}
+	notify := func(code, subcode uint8, err error) error {
+		write(marshalNotification(notification{Code: code, Subcode: subcode}))
+		return err
+	}
+	// Closing the connection on cancellation unblocks reads of the
+	// handshake; once established, the loop below takes over.
+	stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	err := write(marshalOpen(open{
+		AS:       s.cfg.LocalAS,
+		HoldTime: uint16(s.cfg.HoldTime / time.Second),
+		RouterID: s.cfg.RouterID,
+		AS4:      true,
+		Families: []Family{IPv4Unicast, IPv6Unicast},
+	}))
+	if err != nil {
+		return fmt.Errorf("bgp: send OPEN: %w", err)
+	}
+	s.setState(p, StateOpenSent)
+
+	conn.SetReadDeadline(time.Now().Add(openHoldTime))
+	typ, body, err := readMessage(conn)
+	if err != nil {
+		return handshakeError(ctx, conn, err)
+	}
+	if typ == msgNotification {
+		n, err := parseNotification(body)
+		if err != nil {
+			return err
+		}
+		return n
+	}
+	if typ != msgOpen {
+		return notify(errFSM, 0, fmt.Errorf("bgp: expected OPEN, got message type %d", typ))
+	}
+	o, err := parseOpen(body)
+	if err != nil {
+		var m *errMalformed
+		if errors.As(err, &m) {
+			return notify(m.Code, m.Subcode, err)
+		}
+		return err
+	}
+	switch {
+	case o.AS != p.nb.AS:
+		return notify(errOpenMessage, subBadPeerAS, fmt.Errorf("bgp: neighbor AS %d, want %d", o.AS, p.nb.AS))
+	case o.HoldTime == 1 || o.HoldTime == 2:
+		return notify(errOpenMessage, subUnacceptHold, fmt.Errorf("bgp: unacceptable hold time %ds", o.HoldTime))
+	case o.RouterID == s.cfg.RouterID || o.RouterID.IsUnspecified():
+		return notify(errOpenMessage, subBadBGPID, fmt.Errorf("bgp: bad router ID %s", o.RouterID))
+	}
+	hold := min(s.cfg.HoldTime, time.Duration(o.HoldTime)*time.Second)
+	families := o.Families
+	if len(families) == 0 {
+		families = []Family{IPv4Unicast}
+	}
+	if err := write(marshalKeepalive()); err != nil {
+		return fmt.Errorf("bgp: send KEEPALIVE: %w", err)
+	}
+	s.setState(p, StateOpenConfirm)
+
+	typ, body, err = readMessage(conn)
+	if err != nil {
+		return handshakeError(ctx, conn, err)
+	}
+	switch typ {
+	case msgKeepalive:
+	case msgNotification:
+		n, err := parseNotification(body)
+		if err != nil {
+			return err
+		}
+		return n
+	default:
+		return notify(errFSM, 0, fmt.Errorf("bgp: expected KEEPALIVE, got message type %d", typ))
+	}
+	stop()
+	conn.SetReadDeadline(time.Time{})
+	s.setState(p, StateEstablished)
+	s.logger.Info("bgp: session established",
+		"neighbor", p.nb.Address,
+		"as", p.nb.AS,
+		"hold_time", hold,
+	)
+
+	msgs := make(chan message)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			typ, body, err := readMessage(conn)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case msgs <- message{typ, body}:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// The family whose prefixes are advertised follows the session's
+	// next hop, and must have been announced by the neighbor.
+	family := familyOf(netip.PrefixFrom(local, 0))
+	if !slices.Contains(families, family) {
+		family = Family{}
+	}
+	ibgp := p.nb.AS == s.cfg.LocalAS
+	sent := make(map[netip.Prefix]bool)
+	advertise := func() error {
+		s.mu.Lock()
+		var want []netip.Prefix
+		for _, pfx := range s.advertised {
+			if familyOf(pfx) == family {
+				want = append(want, pfx)
+			}
+		}
+		s.mu.Unlock()
+
+		var withdrawn, added []netip.Prefix
+		keep := make(map[netip.Prefix]bool, len(want))
+		for _, pfx := range want {
+			keep[pfx] = true
+			if !sent[pfx] {
+				added = append(added, pfx)
+			}
+		}
+		for pfx := range sent {
+			if !keep[pfx] {
+				withdrawn = append(withdrawn, pfx)
+			}
+		}
+		slices.SortFunc(withdrawn, comparePrefix)
+		for chunk := range slices.Chunk(withdrawn, updateBatch) {
+			if err := write(marshalUpdate(update{Withdrawn: chunk}, o.AS4, ibgp)); err != nil {
+				return fmt.Errorf("bgp: send UPDATE: %w", err)
+			}
+		}
+		u := update{Origin: originIGP, NextHop: local, NextHop6: local, LocalPref: localPref}
+		if !ibgp {
+			u.ASPath = []uint32{s.cfg.LocalAS}
+		}
+		for chunk := range slices.Chunk(added, updateBatch) {
+			u.NLRI = chunk
+			if err := write(marshalUpdate(u, o.AS4, ibgp)); err != nil {
+				return fmt.Errorf("bgp: send UPDATE: %w", err)
+			}
+		}
+		clear(sent)
+		for _, pfx := range want {
+			sent[pfx] = true
+		}
+		return nil
+	}
+	if err := advertise(); err != nil {
+		return err
+	}
+
+	var keepalive <-chan time.Time
+	holdTimer := time.NewTimer(hold)
+	defer holdTimer.Stop()
+	if hold > 0 {
+		t := time.NewTicker(hold / 3)
+		defer t.Stop()
+		keepalive = t.C
+	} else {
+		holdTimer.Stop()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return notify(errCease, subAdminShutdown, ctx.Err())
+		case <-p.changed:
+			if err := advertise(); err != nil {
+				return err
+			}
+		case <-keepalive:
+			if err := write(marshalKeepalive()); err != nil {
+				return fmt.Errorf("bgp: send KEEPALIVE: %w", err)
+			}
+		case <-holdTimer.C:
+			return notify(errHoldTimer, 0, errors.New("bgp: hold timer expired"))
+		case err := <-readErr:
+			var m *errMalformed
+			if errors.As(err, &m) {
+				return notify(m.Code, m.Subcode, err)
+			}
+			return err
+		case m := <-msgs:
+			if hold > 0 {
+				holdTimer.Reset(hold)
+			}
+			switch m.typ {
+			case msgKeepalive:
+			case msgUpdate:
+				u, err := parseUpdate(m.body, o.AS4)
+				if err != nil {
+					var mf *errMalformed
+					if errors.As(err, &mf) {
+						return notify(mf.Code, mf.Subcode, err)
+					}
+					return err
+				}
+				s.receive(p, u)
+			case msgNotification:
+				n, err := parseNotification(m.body)
+				if err != nil {
+					return err
+				}
+				return n
+			default:
+				return notify(errFSM, 0, fmt.Errorf("bgp: unexpected message type %d", m.typ))
+			}
+		}
+	}
+}
+
+// handshakeError returns the error of a failed read during the handshake,
+// or that of ctx if it was cancelled.
+func handshakeError(ctx context.Context, conn net.Conn, err error) error {
+	if ctx.Err() != nil {
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		conn.Write(marshalNotification(notification{Code: errCease, Subcode: subAdminShutdown}))
+		return ctx.Err()
+	}
+	return err
+}
+
+// receive applies an UPDATE from the neighbor of p. Routes whose AS path
+// contains the local AS are looped; they withdraw the prefix like an
+// explicit withdrawal.
+func (s *Speaker) receive(p *peer, u update) {
+	s.mu.Lock()
+	changed := false
+	for _, pfx := range u.Withdrawn {
+		if _, ok := p.routes[pfx]; ok {
+			delete(p.routes, pfx)
+			changed = true
+		}
+	}
+	looped := slices.Contains(u.ASPath, s.cfg.LocalAS)
+	for _, pfx := range u.NLRI {
+		if looped {
+			if _, ok := p.routes[pfx]; ok {
+				delete(p.routes, pfx)
+				changed = true
+			}
+			continue
+		}
+		next := u.NextHop
+		if pfx.Addr().Is6() {
+			next = u.NextHop6
+		}
+		if p.routes == nil {
+			p.routes = make(map[netip.Prefix]Route)
+		}
+		p.routes[pfx] = Route{
+			Prefix:   pfx,
+			NextHop:  next,
+			ASPath:   slices.Clone(u.ASPath),
+			Neighbor: p.nb.Address,
+		}
+		changed = true
+	}
+	hook := s.hook
+	s.mu.Unlock()
+
+	if changed && hook != nil {
+		hook()
+	}
+}
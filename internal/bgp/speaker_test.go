@@ -0,0 +1,174 @@
+package bgp_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/bgp"
+)
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// waitFor polls cond until it holds or the test times out.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// newPair starts a listening speaker with AS asA and a speaker with AS asB
+// that dials it, both on the address loopback.
+func newPair(t *testing.T, loopback string, asA, asB uint32) (a, b *bgp.Speaker) {
+	t.Helper()
+	addr := netip.MustParseAddr(loopback)
+	a = bgp.NewSpeaker(bgp.Config{
+		LocalAS:    asA,
+		RouterID:   netip.MustParseAddr("192.0.2.1"),
+		ListenAddr: net.JoinHostPort(loopback, "0"),
+		HoldTime:   3 * time.Second,
+	}, discardLogger())
+	a.SetNeighbors([]bgp.Neighbor{{Address: addr, AS: asB, Passive: true}})
+	if err := a.Start(context.Background()); err != nil {
+		if loopback == "::1" {
+			t.Skipf("no IPv6 loopback: %v", err)
+		}
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(a.Stop)
+
+	b = bgp.NewSpeaker(bgp.Config{
+		LocalAS:      asB,
+		RouterID:     netip.MustParseAddr("192.0.2.2"),
+		HoldTime:     3 * time.Second,
+		ConnectRetry: 100 * time.Millisecond,
+	}, discardLogger())
+	b.SetNeighbors([]bgp.Neighbor{{Address: addr, AS: asA, Port: a.Addr().(*net.TCPAddr).Port}})
+	if err := b.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(b.Stop)
+	return a, b
+}
+
+func established(s *bgp.Speaker) bool {
+	n := s.Neighbors()
+	return len(n) == 1 && n[0].State == bgp.StateEstablished
+}
+
+func prefixes(routes []bgp.Route) []netip.Prefix {
+	var ps []netip.Prefix
+	for _, r := range routes {
+		ps = append(ps, r.Prefix)
+	}
+	return ps
+}
+
+func TestSpeaker_ExchangeRoutes(t *testing.T) {
+	a, b := newPair(t, "127.0.0.1", 4200000001, 64512)
+	a.Advertise([]netip.Prefix{netip.MustParsePrefix("10.1.0.0/16"), netip.MustParsePrefix("fd00:1::/48")})
+	b.Advertise([]netip.Prefix{netip.MustParsePrefix("192.168.10.0/24")})
+
+	waitFor(t, "routes from a", func() bool { return len(b.Routes()) == 1 })
+	waitFor(t, "routes from b", func() bool { return len(a.Routes()) == 1 })
+
+	got := b.Routes()[0]
+	want := bgp.Route{
+		Prefix:   netip.MustParsePrefix("10.1.0.0/16"),
+		NextHop:  netip.MustParseAddr("127.0.0.1"),
+		ASPath:   []uint32{4200000001},
+		Neighbor: netip.MustParseAddr("127.0.0.1"),
+	}
+	if got.Prefix != want.Prefix || got.NextHop != want.NextHop || !slices.Equal(got.ASPath, want.ASPath) || got.Neighbor != want.Neighbor {
+		t.Errorf("route = %+v, want %+v; the IPv6 prefix must not be sent over IPv4", got, want)
+	}
+	if n := a.Neighbors(); n[0].State != bgp.StateEstablished || n[0].Received != 1 || n[0].Established.IsZero() {
+		t.Errorf("neighbor status = %+v", n[0])
+	}
+
+	// Replacing the advertised prefixes withdraws the old ones.
+	a.Advertise([]netip.Prefix{netip.MustParsePrefix("10.2.0.0/16")})
+	waitFor(t, "replaced route", func() bool {
+		return slices.Equal(prefixes(b.Routes()), []netip.Prefix{netip.MustParsePrefix("10.2.0.0/16")})
+	})
+
+	// Stopping a speaker closes the session and drops its routes.
+	a.Stop()
+	waitFor(t, "routes dropped", func() bool { return len(b.Routes()) == 0 && !established(b) })
+	if n := b.Neighbors(); n[0].Error == "" {
+		t.Error("neighbor status has no error after the session was closed")
+	}
+}
+
+func TestSpeaker_IPv6(t *testing.T) {
+	a, b := newPair(t, "::1", 64512, 64513)
+	a.Advertise([]netip.Prefix{netip.MustParsePrefix("10.1.0.0/16"), netip.MustParsePrefix("fd00:1::/48")})
+	waitFor(t, "route from a", func() bool { return len(b.Routes()) == 1 })
+	if got := b.Routes()[0]; got.Prefix != netip.MustParsePrefix("fd00:1::/48") || got.NextHop != netip.MustParseAddr("::1") {
+		t.Errorf("route = %+v, want fd00:1::/48 via ::1", got)
+	}
+}
+
+func TestSpeaker_IBGP(t *testing.T) {
+	a, b := newPair(t, "127.0.0.1", 64512, 64512)
+	a.Advertise([]netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")})
+	waitFor(t, "route from a", func() bool { return len(b.Routes()) == 1 })
+	if got := b.Routes()[0]; len(got.ASPath) != 0 {
+		t.Errorf("AS path = %v, want empty for iBGP", got.ASPath)
+	}
+}
+
+func TestSpeaker_WrongAS(t *testing.T) {
+	a, b := newPair(t, "127.0.0.1", 64512, 64513)
+	// a expects another AS for b.
+	a.SetNeighbors([]bgp.Neighbor{{Address: netip.MustParseAddr("127.0.0.1"), AS: 64999, Passive: true}})
+	waitFor(t, "session error", func() bool {
+		n := a.Neighbors()
+		return len(n) == 1 && n[0].Error != ""
+	})
+	if established(a) || established(b) {
+		t.Error("session established with the wrong AS")
+	}
+}
+
+func TestSpeaker_RefusesUnknownNeighbors(t *testing.T) {
+	a := bgp.NewSpeaker(bgp.Config{
+		LocalAS:    64512,
+		RouterID:   netip.MustParseAddr("192.0.2.1"),
+		ListenAddr: "127.0.0.1:0",
+	}, discardLogger())
+	if err := a.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer a.Stop()
+
+	conn, err := net.Dial("tcp", a.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("read = %v, want the connection closed", err)
+	}
+}
+
+func TestSpeaker_StartInvalidRouterID(t *testing.T) {
+	s := bgp.NewSpeaker(bgp.Config{LocalAS: 64512}, discardLogger())
+	if err := s.Start(context.Background()); err == nil {
+		s.Stop()
+		t.Fatal("Start succeeded without a router ID")
+	}
+}
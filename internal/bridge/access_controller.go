@@ -19,3 +19,12 @@ type AccessController interface {
 	// Idempotent: removing a non-existent peer returns nil.
 	RemovePeer(iface string, publicKey string) error
 }
+
+// PublicKeyReader is implemented by access controllers that can report the
+// public key of a WireGuard interface. UserAccessManager includes the key in
+// the client export when the controller implements it.
+type PublicKeyReader interface {
+	// InterfacePublicKey returns the base64-encoded public key of the
+	// WireGuard interface with the given name.
+	InterfacePublicKey(name string) (string, error)
+}
@@ -0,0 +1,52 @@
+package bridge
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+)
+
+// detectAccessSubnets returns the subnets of the addresses on iface, without
+// host bits. Loopback and link-local addresses, and single-address prefixes
+// such as an HA virtual IP, are skipped.
+func detectAccessSubnets(iface string) ([]string, error) {
+	ifc, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, fmt.Errorf("bridge: detect access subnets on %q: %w", iface, err)
+	}
+	addrs, err := ifc.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("bridge: detect access subnets on %q: %w", iface, err)
+	}
+	var subnets []string
+	for _, a := range addrs {
+		ipn, ok := a.(*net.IPNet)
+		if !ok || ipn.IP.IsLoopback() || ipn.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		addr, ok := netip.AddrFromSlice(ipn.IP)
+		if !ok {
+			continue
+		}
+		bits, _ := ipn.Mask.Size()
+		p := netip.PrefixFrom(addr.Unmap(), bits).Masked()
+		if !p.IsValid() || p.IsSingleIP() {
+			continue
+		}
+		if s := p.String(); !slices.Contains(subnets, s) {
+			subnets = append(subnets, s)
+		}
+	}
+	return subnets, nil
+}
+
+// normalizeSubnet returns subnet without host bits, or subnet unchanged if
+// it is not a valid CIDR.
+func normalizeSubnet(subnet string) string {
+	p, err := netip.ParsePrefix(subnet)
+	if err != nil {
+		return subnet
+	}
+	return p.Masked().String()
+}
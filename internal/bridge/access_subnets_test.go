@@ -0,0 +1,45 @@
+package bridge
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDetectAccessSubnets_Loopback(t *testing.T) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Skipf("list interfaces: %v", err)
+	}
+	for _, ifc := range ifaces {
+		if ifc.Flags&net.FlagLoopback == 0 {
+			continue
+		}
+		subnets, err := detectAccessSubnets(ifc.Name)
+		if err != nil {
+			t.Fatalf("detectAccessSubnets(%q): %v", ifc.Name, err)
+		}
+		if len(subnets) != 0 {
+			t.Errorf("detectAccessSubnets(%q) = %v, want no loopback subnets", ifc.Name, subnets)
+		}
+		return
+	}
+	t.Skip("no loopback interface")
+}
+
+func TestDetectAccessSubnets_UnknownInterface(t *testing.T) {
+	if _, err := detectAccessSubnets("plexd-missing0"); err == nil {
+		t.Error("detectAccessSubnets of a missing interface = nil error, want error")
+	}
+}
+
+func TestNormalizeSubnet(t *testing.T) {
+	for in, want := range map[string]string{
+		"10.0.0.1/24": "10.0.0.0/24",
+		"fd00::1/64":  "fd00::/64",
+		"bad":         "bad",
+	} {
+		if got := normalizeSubnet(in); got != want {
+			t.Errorf("normalizeSubnet(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
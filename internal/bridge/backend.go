@@ -0,0 +1,232 @@
+package bridge
+
+import (
+	"fmt"
+	"log/slog"
+	"net/netip"
+)
+
+const (
+	// BackendNetlink programs routes, NAT, and WireGuard interfaces directly
+	// through netlink, nftables, and wgctrl, and split DNS through
+	// systemd-resolved. It is the default backend.
+	BackendNetlink = "netlink"
+
+	// BackendNoop logs every operation without touching the host. It is
+	// intended for dry runs and hosts without CAP_NET_ADMIN.
+	BackendNoop = "noop"
+)
+
+// Backend bundles the OS-level controllers used by the bridge subsystems.
+// Managers receive the individual controllers, so alternative backends only
+// need to satisfy the RouteController, VPNController, IPsecController,
+// L2Controller, AccessController, and DNSConfigurator interfaces.
+type Backend struct {
+	Name   string
+	Routes RouteController
+	VPN    VPNController
+	IPsec  IPsecController
+	L2     L2Controller
+	Access AccessController
+	DNS    DNSConfigurator
+
+	// FastPath is the in-kernel fast path, or nil when traffic takes the
+	// userspace path.
+	FastPath FastPath
+}
+
+// NewBackend returns the backend selected by cfg.Backend. An empty name
+// selects BackendNetlink. The netlink backend places routes according to
+// cfg.RouteTable, programs masquerade rules with cfg.NATBackend, and sets up the kernel fast path when cfg.FastPath is
+// FastPathAuto and the kernel supports it. Its IPsec controller reaches
+// charon at cfg.SiteToSiteVICISocket.
+func NewBackend(cfg Config, logger *slog.Logger) (*Backend, error) {
+	switch cfg.Backend {
+	case "", BackendNetlink:
+		b, err := newNetlinkBackend(cfg.policyRouting(), cfg.NATBackend, cfg.SiteToSiteVICISocket, logger)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.FastPath == FastPathAuto {
+			b.FastPath = probeFastPath(logger)
+		}
+		return b, nil
+	case BackendNoop:
+		ctrl := &noopController{logger: logger}
+		return &Backend{Name: BackendNoop, Routes: ctrl, VPN: ctrl, IPsec: ctrl, L2: ctrl, Access: ctrl, DNS: ctrl}, nil
+	default:
+		return nil, fmt.Errorf("bridge: unknown backend %q", cfg.Backend)
+	}
+}
+
+// noopController implements RouteController, VPNController, IPsecController,
+// L2Controller, AccessController, and DNSConfigurator by logging each call
+// and returning nil.
+type noopController struct {
+	logger *slog.Logger
+}
+
+func (c *noopController) log(op string, args ...any) error {
+	c.logger.Debug("noop backend: "+op, append([]any{"component", "bridge"}, args...)...)
+	return nil
+}
+
+func (c *noopController) EnableForwarding(meshIface, accessIface string) error {
+	return c.log("enable forwarding", "mesh_iface", meshIface, "access_iface", accessIface)
+}
+
+func (c *noopController) DisableForwarding(meshIface, accessIface string) error {
+	return c.log("disable forwarding", "mesh_iface", meshIface, "access_iface", accessIface)
+}
+
+func (c *noopController) AddRoute(subnet, iface string) error {
+	return c.log("add route", "subnet", subnet, "interface", iface)
+}
+
+func (c *noopController) RemoveRoute(subnet, iface string) error {
+	return c.log("remove route", "subnet", subnet, "interface", iface)
+}
+
+func (c *noopController) AddNATMasquerade(iface string) error {
+	return c.log("add NAT masquerade", "interface", iface)
+}
+
+func (c *noopController) RemoveNATMasquerade(iface string) error {
+	return c.log("remove NAT masquerade", "interface", iface)
+}
+
+func (c *noopController) CreateTunnelInterface(name string, listenPort int) error {
+	return c.log("create tunnel interface", "interface", name, "listen_port", listenPort)
+}
+
+func (c *noopController) RemoveTunnelInterface(name string) error {
+	return c.log("remove tunnel interface", "interface", name)
+}
+
+func (c *noopController) ConfigureTunnelPeer(iface string, publicKey string, allowedIPs []string, endpoint string, psk string) error {
+	return c.log("configure tunnel peer", "interface", iface, "allowed_ips", allowedIPs, "endpoint", endpoint)
+}
+
+func (c *noopController) RemoveTunnelPeer(iface string, publicKey string) error {
+	return c.log("remove tunnel peer", "interface", iface)
+}
+
+func (c *noopController) CreateIPsecInterface(name string, ifID uint32) error {
+	return c.log("create ipsec interface", "interface", name, "if_id", ifID)
+}
+
+func (c *noopController) RemoveIPsecInterface(name string) error {
+	return c.log("remove ipsec interface", "interface", name)
+}
+
+func (c *noopController) LoadIPsecConn(conn IPsecConn) error {
+	return c.log("load ipsec connection", "connection", conn.Name, "remote_host", conn.RemoteHost, "if_id", conn.IfID)
+}
+
+func (c *noopController) UnloadIPsecConn(name string) error {
+	return c.log("unload ipsec connection", "connection", name)
+}
+
+func (c *noopController) CreateL2Interface(name string, ext L2Extension) error {
+	return c.log("create l2 interface", "interface", name, "vni", ext.VNI, "bridge", ext.Bridge)
+}
+
+func (c *noopController) RemoveL2Interface(name string) error {
+	return c.log("remove l2 interface", "interface", name)
+}
+
+func (c *noopController) L2MACCount(name string) (int, error) {
+	return 0, c.log("count l2 MAC addresses", "interface", name)
+}
+
+func (c *noopController) SetL2Learning(name string, on bool) error {
+	return c.log("set l2 learning", "interface", name, "on", on)
+}
+
+func (c *noopController) CreateInterface(name string, listenPort int) error {
+	return c.log("create interface", "interface", name, "listen_port", listenPort)
+}
+
+func (c *noopController) RemoveInterface(name string) error {
+	return c.log("remove interface", "interface", name)
+}
+
+func (c *noopController) ConfigurePeer(iface string, publicKey string, allowedIPs []string, psk string) error {
+	return c.log("configure peer", "interface", iface, "allowed_ips", allowedIPs)
+}
+
+func (c *noopController) RemovePeer(iface string, publicKey string) error {
+	return c.log("remove peer", "interface", iface)
+}
+
+func (c *noopController) FlushConntrackSubnet(subnet string) error {
+	return c.log("flush conntrack subnet", "subnet", subnet)
+}
+
+func (c *noopController) FlushConntrackPort(protocol string, addr netip.Addr, port int) error {
+	return c.log("flush conntrack port", "protocol", protocol, "address", addr.String(), "port", port)
+}
+
+func (c *noopController) SetEgressRate(iface string, rateKbps int64) error {
+	return c.log("set egress rate", "interface", iface, "rate_kbps", rateKbps)
+}
+
+func (c *noopController) ClearEgressRate(iface string) error {
+	return c.log("clear egress rate", "interface", iface)
+}
+
+func (c *noopController) SetMSSClamp(iface string, mss int) error {
+	return c.log("set MSS clamp", "interface", iface, "mss", mss)
+}
+
+func (c *noopController) ClearMSSClamp(iface string) error {
+	return c.log("clear MSS clamp", "interface", iface)
+}
+
+func (c *noopController) AddSubnetMap(iface, local, as string) error {
+	return c.log("add subnet map", "interface", iface, "local", local, "as", as)
+}
+
+func (c *noopController) RemoveSubnetMap(iface, local, as string) error {
+	return c.log("remove subnet map", "interface", iface, "local", local, "as", as)
+}
+
+func (c *noopController) AddMasquerade(iface, subnet string) error {
+	return c.log("add masquerade", "interface", iface, "subnet", subnet)
+}
+
+func (c *noopController) RemoveMasquerade(iface, subnet string) error {
+	return c.log("remove masquerade", "interface", iface, "subnet", subnet)
+}
+
+func (c *noopController) MasqueradeConflicts(iface string) ([]string, error) {
+	return nil, c.log("masquerade conflicts", "interface", iface)
+}
+
+func (c *noopController) SetSplitDNS(servers, domains []string) error {
+	return c.log("set split DNS", "servers", servers, "domains", domains)
+}
+
+func (c *noopController) ClearSplitDNS() error {
+	return c.log("clear split DNS")
+}
+
+func (c *noopController) AddVirtualIP(iface, cidr string) error {
+	return c.log("add virtual IP", "interface", iface, "address", cidr)
+}
+
+func (c *noopController) RemoveVirtualIP(iface, cidr string) error {
+	return c.log("remove virtual IP", "interface", iface, "address", cidr)
+}
+
+func (c *noopController) AnnounceVirtualIP(iface, cidr string) error {
+	return c.log("announce virtual IP", "interface", iface, "address", cidr)
+}
+
+func (c *noopController) AddGatewayRoute(subnet, gateway, iface string) error {
+	return c.log("add gateway route", "subnet", subnet, "gateway", gateway, "interface", iface)
+}
+
+func (c *noopController) RemoveGatewayRoute(subnet, gateway, iface string) error {
+	return c.log("remove gateway route", "subnet", subnet, "gateway", gateway, "interface", iface)
+}
@@ -0,0 +1,23 @@
+//go:build linux
+
+package bridge
+
+import "log/slog"
+
+// newNetlinkBackend returns a Backend backed by netlink, nftables, wgctrl,
+// and the VICI socket of charon at viciSocket.
+func newNetlinkBackend(policy PolicyRouting, natBackend, viciSocket string, logger *slog.Logger) (*Backend, error) {
+	routes := NewNetlinkRouteController(logger)
+	routes.SetPolicyRouting(policy)
+	routes.SetNATBackend(natBackend)
+	wg := NewNetlinkWGController(logger)
+	return &Backend{
+		Name:   BackendNetlink,
+		Routes: routes,
+		VPN:    wg,
+		IPsec:  NewVICIController(viciSocket, logger),
+		L2:     NewNetlinkL2Controller(logger),
+		Access: wg,
+		DNS:    NewResolvedDNSConfigurator(logger),
+	}, nil
+}
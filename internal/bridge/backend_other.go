@@ -0,0 +1,13 @@
+//go:build !linux
+
+package bridge
+
+import (
+	"errors"
+	"log/slog"
+)
+
+// newNetlinkBackend is unavailable on non-Linux platforms.
+func newNetlinkBackend(_ PolicyRouting, _, _ string, _ *slog.Logger) (*Backend, error) {
+	return nil, errors.New("bridge: netlink backend is only supported on linux")
+}
@@ -0,0 +1,72 @@
+package bridge
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewBackend_Noop(t *testing.T) {
+	b, err := NewBackend(Config{Backend: BackendNoop}, discardLogger())
+	if err != nil {
+		t.Fatalf("NewBackend(noop): %v", err)
+	}
+	if b.Name != BackendNoop {
+		t.Errorf("Name = %q, want %q", b.Name, BackendNoop)
+	}
+	if b.Routes == nil || b.VPN == nil || b.IPsec == nil || b.L2 == nil || b.Access == nil {
+		t.Fatal("noop backend has nil controllers")
+	}
+
+	if err := b.Routes.AddRoute("10.0.0.0/24", "eth1"); err != nil {
+		t.Errorf("AddRoute: %v", err)
+	}
+	if err := b.VPN.CreateTunnelInterface("wg-s2s-0", 51823); err != nil {
+		t.Errorf("CreateTunnelInterface: %v", err)
+	}
+	if err := b.Access.ConfigurePeer("wg-access", "key", []string{"10.1.0.2/32"}, ""); err != nil {
+		t.Errorf("ConfigurePeer: %v", err)
+	}
+	if err := b.IPsec.LoadIPsecConn(IPsecConn{Name: "plexd-t1", RemoteHost: "203.0.113.1", IfID: 100}); err != nil {
+		t.Errorf("LoadIPsecConn: %v", err)
+	}
+	if err := b.L2.CreateL2Interface("s2s-vx42", L2Extension{VNI: 42, Bridge: "br-l2"}); err != nil {
+		t.Errorf("CreateL2Interface: %v", err)
+	}
+}
+
+func TestNewBackend_Unknown(t *testing.T) {
+	_, err := NewBackend(Config{Backend: "iproute2"}, discardLogger())
+	if err == nil {
+		t.Fatal("expected error for unknown backend")
+	}
+	if !strings.Contains(err.Error(), "unknown backend") {
+		t.Errorf("error = %q, want unknown backend", err)
+	}
+}
+
+func TestNewBackend_NoopDrivesManager(t *testing.T) {
+	b, err := NewBackend(Config{Backend: BackendNoop}, discardLogger())
+	if err != nil {
+		t.Fatalf("NewBackend: %v", err)
+	}
+	cfg := Config{Enabled: true, AccessInterface: "eth1", AccessSubnets: []string{"10.0.0.0/24"}}
+	mgr := NewManager(b.Routes, cfg, discardLogger())
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := mgr.Teardown(); err != nil {
+		t.Fatalf("Teardown: %v", err)
+	}
+}
+
+func TestConfig_Validate_UnknownBackend(t *testing.T) {
+	cfg := Config{
+		Enabled:         true,
+		Backend:         "iproute2",
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for unknown backend")
+	}
+}
@@ -0,0 +1,442 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"slices"
+	"sync"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/bgp"
+	"github.com/plexsphere/plexd/internal/validation"
+)
+
+// errNoGatewayRouter is reported when learned routes cannot be installed.
+const errNoGatewayRouter = "route controller does not support gateway routes"
+
+// BGPSpeaker exchanges routes between a bridge node and the local router
+// over BGP, so that branch sites need no static routes to the mesh. It
+// advertises the routed access subnets and the subnets of the control
+// plane's policy, and installs the learned routes the policy allows via
+// the access interface, which makes the subnets behind the router
+// reachable from the mesh. The imported routes are reported in the bridge
+// status for the control plane to distribute.
+//
+// Of the routes several neighbors advertise for a prefix, the one with the
+// shortest AS path is imported, ties going to the lowest neighbor address.
+// The policy is applied by Configure; until then only the access subnets
+// are advertised and nothing is imported. BGPSpeaker is concurrent-safe.
+type BGPSpeaker struct {
+	ctrl   RouteController
+	cfg    Config
+	logger *slog.Logger
+
+	mu            sync.Mutex
+	speaker       *bgp.Speaker
+	routerID      netip.Addr
+	policy        *api.BridgeBGPConfig
+	accessSubnets []string
+	protected     []ProtectedSource
+	installed     map[netip.Prefix]bgp.Route
+	rejected      int
+	err           string
+	onImport      func()
+}
+
+// NewBGPSpeaker returns a speaker for the BGP fields of cfg that installs
+// learned routes on cfg.AccessInterface through ctrl.
+func NewBGPSpeaker(ctrl RouteController, cfg Config, logger *slog.Logger) *BGPSpeaker {
+	return &BGPSpeaker{
+		ctrl:      ctrl,
+		cfg:       cfg,
+		logger:    logger.With("component", "bridge"),
+		installed: make(map[netip.Prefix]bgp.Route),
+	}
+}
+
+// SetProtectedSources sets sources of addresses that imported routes must
+// not capture unless AllowFullTunnel is set. It must be called before Start.
+func (s *BGPSpeaker) SetProtectedSources(srcs ...ProtectedSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.protected = srcs
+}
+
+// SetImportHook registers fn to be called after the imported routes change.
+// Callers use it to report the routes to the control plane at once instead
+// of with the next heartbeat.
+func (s *BGPSpeaker) SetImportHook(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onImport = fn
+}
+
+// Start starts the sessions with the configured neighbors. The speaker runs
+// until Stop or until ctx is done.
+func (s *BGPSpeaker) Start(ctx context.Context) error {
+	routerID, err := s.resolveRouterID()
+	if err != nil {
+		return fmt.Errorf("bridge: bgp: %w", err)
+	}
+	neighbors := make([]bgp.Neighbor, 0, len(s.cfg.BGPNeighbors))
+	for _, nb := range s.cfg.BGPNeighbors {
+		addr, err := netip.ParseAddr(nb.Address)
+		if err != nil {
+			return fmt.Errorf("bridge: bgp: invalid neighbor address %q", nb.Address)
+		}
+		neighbors = append(neighbors, bgp.Neighbor{Address: addr.Unmap(), AS: nb.AS, Port: nb.Port, Passive: nb.Passive})
+	}
+
+	sp := bgp.NewSpeaker(bgp.Config{
+		LocalAS:    s.cfg.BGPLocalAS,
+		RouterID:   routerID,
+		ListenAddr: s.cfg.BGPListenAddr,
+		HoldTime:   s.cfg.BGPHoldTime,
+	}, s.logger)
+	sp.SetNeighbors(neighbors)
+	sp.SetRoutesHook(func() { s.sync() })
+
+	s.mu.Lock()
+	if s.speaker != nil {
+		s.mu.Unlock()
+		return errors.New("bridge: bgp: already started")
+	}
+	sp.Advertise(s.advertisedLocked())
+	if err := sp.Start(ctx); err != nil {
+		s.mu.Unlock()
+		return fmt.Errorf("bridge: bgp: %w", err)
+	}
+	s.speaker = sp
+	s.routerID = routerID
+	s.mu.Unlock()
+
+	s.logger.Info("bridge BGP speaker started",
+		"local_as", s.cfg.BGPLocalAS,
+		"router_id", routerID,
+		"neighbors", len(neighbors),
+	)
+	return nil
+}
+
+// resolveRouterID returns BGPRouterID, or the first IPv4 address of the
+// access interface.
+func (s *BGPSpeaker) resolveRouterID() (netip.Addr, error) {
+	if s.cfg.BGPRouterID != "" {
+		id, err := netip.ParseAddr(s.cfg.BGPRouterID)
+		if err != nil || !id.Is4() {
+			return netip.Addr{}, fmt.Errorf("router ID %q is not an IPv4 address", s.cfg.BGPRouterID)
+		}
+		return id, nil
+	}
+	iface, err := net.InterfaceByName(s.cfg.AccessInterface)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("router ID: %w", err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("router ID: %w", err)
+	}
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok {
+			if ip, ok := netip.AddrFromSlice(ipnet.IP); ok && ip.Unmap().Is4() {
+				return ip.Unmap(), nil
+			}
+		}
+	}
+	return netip.Addr{}, fmt.Errorf("router ID: %s has no IPv4 address; set BGPRouterID", s.cfg.AccessInterface)
+}
+
+// Stop closes the sessions and removes the imported routes.
+// Idempotent: stopping a stopped speaker returns nil.
+func (s *BGPSpeaker) Stop() error {
+	s.mu.Lock()
+	sp := s.speaker
+	s.speaker = nil
+	s.mu.Unlock()
+	if sp == nil {
+		return nil
+	}
+	sp.Stop()
+
+	s.mu.Lock()
+	changed, err := s.applyLocked(nil)
+	s.rejected = 0
+	hook := s.hookLocked(changed)
+	s.mu.Unlock()
+
+	hook()
+	s.logger.Info("bridge BGP speaker stopped")
+	return err
+}
+
+// Configure applies the policy of the control plane. A nil policy
+// advertises only the access subnets and imports nothing.
+func (s *BGPSpeaker) Configure(policy *api.BridgeBGPConfig) error {
+	if policy != nil {
+		if err := validateBGPPolicy(policy); err != nil {
+			return fmt.Errorf("bridge: bgp: %w", err)
+		}
+		policy = &api.BridgeBGPConfig{
+			Advertise: slices.Clone(policy.Advertise),
+			Import:    slices.Clone(policy.Import),
+		}
+	}
+	s.mu.Lock()
+	s.policy = policy
+	s.mu.Unlock()
+	return s.sync()
+}
+
+// validateBGPPolicy checks the policy pushed by the control plane.
+func validateBGPPolicy(policy *api.BridgeBGPConfig) error {
+	for _, p := range policy.Advertise {
+		if _, err := netip.ParsePrefix(p); err != nil {
+			return fmt.Errorf("invalid advertised subnet %q", p)
+		}
+	}
+	for _, p := range policy.Import {
+		if _, err := netip.ParsePrefix(p); err != nil {
+			return fmt.Errorf("invalid import subnet %q", p)
+		}
+	}
+	return nil
+}
+
+// SetAccessSubnets sets the routed access subnets, which are advertised
+// along with the subnets of the policy.
+func (s *BGPSpeaker) SetAccessSubnets(subnets []string) error {
+	s.mu.Lock()
+	s.accessSubnets = slices.Clone(subnets)
+	s.mu.Unlock()
+	return s.sync()
+}
+
+// advertisedLocked returns the prefixes to advertise: the access subnets
+// and the policy's Advertise subnets.
+func (s *BGPSpeaker) advertisedLocked() []netip.Prefix {
+	subnets := slices.Clone(s.accessSubnets)
+	if s.policy != nil {
+		subnets = append(subnets, s.policy.Advertise...)
+	}
+	var prefixes []netip.Prefix
+	for _, subnet := range subnets {
+		if p, err := netip.ParsePrefix(subnet); err == nil {
+			prefixes = append(prefixes, p.Masked())
+		}
+	}
+	slices.SortFunc(prefixes, comparePrefix)
+	return slices.Compact(prefixes)
+}
+
+// sync advertises the current prefixes and brings the installed routes in
+// line with the learned routes and the policy. It runs whenever either
+// changes.
+func (s *BGPSpeaker) sync() error {
+	var protected []validation.ProtectedAddr
+	s.mu.Lock()
+	srcs := s.protected
+	s.mu.Unlock()
+	if !s.cfg.AllowFullTunnel {
+		protected = protectedAddrs(srcs)
+	}
+
+	s.mu.Lock()
+	if s.speaker == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	advertised := s.advertisedLocked()
+	s.speaker.Advertise(advertised)
+
+	best := make(map[netip.Prefix]bgp.Route)
+	for _, r := range s.speaker.Routes() {
+		if cur, ok := best[r.Prefix]; ok && len(cur.ASPath) <= len(r.ASPath) {
+			continue
+		}
+		best[r.Prefix] = r
+	}
+	prefixes := make([]netip.Prefix, 0, len(best))
+	for p := range best {
+		prefixes = append(prefixes, p)
+	}
+	slices.SortFunc(prefixes, comparePrefix)
+
+	wanted := make(map[netip.Prefix]bgp.Route)
+	rejected := 0
+	for _, p := range prefixes {
+		r := best[p]
+		if reason := s.rejectLocked(r, advertised, protected); reason != "" {
+			s.logger.Debug("bridge BGP: route rejected",
+				"prefix", p,
+				"neighbor", r.Neighbor,
+				"reason", reason,
+			)
+			rejected++
+			continue
+		}
+		if len(wanted) >= s.cfg.BGPMaxImportedRoutes {
+			rejected++
+			continue
+		}
+		wanted[p] = r
+	}
+	if rejected > s.rejected {
+		s.logger.Warn("bridge BGP: learned routes rejected",
+			"rejected", rejected,
+			"max_imported_routes", s.cfg.BGPMaxImportedRoutes,
+		)
+	}
+	s.rejected = rejected
+	changed, err := s.applyLocked(wanted)
+	hook := s.hookLocked(changed)
+	s.mu.Unlock()
+
+	hook()
+	return err
+}
+
+// rejectLocked returns why the learned route r is not imported, or "" if
+// it is.
+func (s *BGPSpeaker) rejectLocked(r bgp.Route, advertised []netip.Prefix, protected []validation.ProtectedAddr) string {
+	if s.policy == nil || !slices.ContainsFunc(s.policy.Import, func(subnet string) bool {
+		p, err := netip.ParsePrefix(subnet)
+		return err == nil && p.Bits() <= r.Prefix.Bits() && p.Contains(r.Prefix.Addr())
+	}) {
+		return "outside the import policy"
+	}
+	if slices.ContainsFunc(advertised, r.Prefix.Overlaps) {
+		return "overlaps an advertised subnet"
+	}
+	if !r.NextHop.IsValid() || r.NextHop.Is4() != r.Prefix.Addr().Is4() {
+		return "invalid next hop"
+	}
+	if err := validation.Route(r.Prefix, s.cfg.AccessInterface, protected); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// applyLocked installs the routes of wanted via their next hop and removes
+// the other installed routes, and reports whether the installed routes
+// changed. Failures are recorded in the status and returned joined; the
+// remaining routes are still applied.
+func (s *BGPSpeaker) applyLocked(wanted map[netip.Prefix]bgp.Route) (bool, error) {
+	if len(wanted) == 0 && len(s.installed) == 0 {
+		s.err = ""
+		return false, nil
+	}
+	gw, ok := s.ctrl.(GatewayRouter)
+	if !ok {
+		s.err = errNoGatewayRouter
+		return false, nil
+	}
+
+	changed := false
+	var errs []error
+	for p, r := range s.installed {
+		if w, ok := wanted[p]; ok && w.NextHop == r.NextHop {
+			continue
+		}
+		if err := gw.RemoveGatewayRoute(p.String(), r.NextHop.String(), s.cfg.AccessInterface); err != nil {
+			s.logger.Error("bridge BGP: remove imported route failed",
+				"prefix", p,
+				"next_hop", r.NextHop,
+				"error", err,
+			)
+			errs = append(errs, err)
+			continue
+		}
+		delete(s.installed, p)
+		changed = true
+	}
+	for p, r := range wanted {
+		if cur, ok := s.installed[p]; ok && cur.NextHop == r.NextHop {
+			s.installed[p] = r
+			continue
+		}
+		if err := gw.AddGatewayRoute(p.String(), r.NextHop.String(), s.cfg.AccessInterface); err != nil {
+			s.logger.Error("bridge BGP: install imported route failed",
+				"prefix", p,
+				"next_hop", r.NextHop,
+				"error", err,
+			)
+			errs = append(errs, err)
+			continue
+		}
+		s.installed[p] = r
+		changed = true
+	}
+
+	err := errors.Join(errs...)
+	s.err = ""
+	if err != nil {
+		s.err = err.Error()
+	}
+	return changed, err
+}
+
+// hookLocked returns a function that runs the import hook if changed is
+// true. It is called after s.mu is released.
+func (s *BGPSpeaker) hookLocked(changed bool) func() {
+	fn := s.onImport
+	if !changed || fn == nil {
+		return func() {}
+	}
+	return fn
+}
+
+// Status returns the state of the speaker, or nil when it is not running.
+func (s *BGPSpeaker) Status() *api.BridgeBGPStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.speaker == nil {
+		return nil
+	}
+	st := &api.BridgeBGPStatus{
+		LocalAS:   s.cfg.BGPLocalAS,
+		RouterID:  s.routerID.String(),
+		Neighbors: []api.BGPNeighborStatus{},
+		Rejected:  s.rejected,
+		Error:     s.err,
+	}
+	for _, nb := range s.speaker.Neighbors() {
+		st.Neighbors = append(st.Neighbors, api.BGPNeighborStatus{
+			Address:     nb.Address.String(),
+			AS:          nb.AS,
+			State:       nb.State,
+			Established: nb.Established,
+			Received:    nb.Received,
+			Error:       nb.Error,
+		})
+	}
+	for _, p := range s.advertisedLocked() {
+		st.Advertised = append(st.Advertised, p.String())
+	}
+	prefixes := make([]netip.Prefix, 0, len(s.installed))
+	for p := range s.installed {
+		prefixes = append(prefixes, p)
+	}
+	slices.SortFunc(prefixes, comparePrefix)
+	for _, p := range prefixes {
+		r := s.installed[p]
+		st.Imported = append(st.Imported, api.BGPRoute{
+			Prefix:   p.String(),
+			NextHop:  r.NextHop.String(),
+			Neighbor: r.Neighbor.String(),
+			ASPath:   slices.Clone(r.ASPath),
+		})
+	}
+	return st
+}
+
+// comparePrefix orders prefixes by address, then length.
+func comparePrefix(a, b netip.Prefix) int {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c
+	}
+	return a.Bits() - b.Bits()
+}
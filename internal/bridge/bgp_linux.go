@@ -0,0 +1,74 @@
+//go:build linux
+
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// AddGatewayRoute routes the CIDR subnet via gateway on iface, in the route
+// table of plexd's routes.
+func (c *NetlinkRouteController) AddGatewayRoute(subnet, gateway, iface string) error {
+	route, err := c.gatewayRoute(subnet, gateway, iface)
+	if err != nil {
+		return fmt.Errorf("bridge: add gateway route: %w", err)
+	}
+	if err := netlink.RouteReplace(route); err != nil {
+		return fmt.Errorf("bridge: add gateway route %q via %s on %q: %w", subnet, gateway, iface, err)
+	}
+	c.logger.Debug("gateway route added",
+		"component", "bridge",
+		"subnet", subnet,
+		"gateway", gateway,
+		"interface", iface,
+	)
+	return nil
+}
+
+// RemoveGatewayRoute removes the route added by AddGatewayRoute.
+// Idempotent: removing a non-existent route returns nil.
+func (c *NetlinkRouteController) RemoveGatewayRoute(subnet, gateway, iface string) error {
+	route, err := c.gatewayRoute(subnet, gateway, iface)
+	if err != nil {
+		return fmt.Errorf("bridge: remove gateway route: %w", err)
+	}
+	if err := netlink.RouteDel(route); err != nil {
+		if errors.Is(err, unix.ESRCH) {
+			return nil
+		}
+		return fmt.Errorf("bridge: remove gateway route %q via %s on %q: %w", subnet, gateway, iface, err)
+	}
+	c.logger.Debug("gateway route removed",
+		"component", "bridge",
+		"subnet", subnet,
+		"gateway", gateway,
+		"interface", iface,
+	)
+	return nil
+}
+
+func (c *NetlinkRouteController) gatewayRoute(subnet, gateway, iface string) (*netlink.Route, error) {
+	_, dst, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return nil, fmt.Errorf("parse CIDR %q: %w", subnet, err)
+	}
+	gw := net.ParseIP(gateway)
+	if gw == nil {
+		return nil, fmt.Errorf("invalid gateway %q", gateway)
+	}
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		return nil, fmt.Errorf("lookup interface %q: %w", iface, err)
+	}
+	return &netlink.Route{
+		Dst:       dst,
+		Gw:        gw,
+		LinkIndex: link.Attrs().Index,
+		Table:     c.routeTable(),
+	}, nil
+}
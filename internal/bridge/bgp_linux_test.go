@@ -0,0 +1,6 @@
+//go:build linux
+
+package bridge
+
+// Compile-time check that NetlinkRouteController implements GatewayRouter.
+var _ GatewayRouter = (*NetlinkRouteController)(nil)
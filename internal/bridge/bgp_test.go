@@ -0,0 +1,289 @@
+package bridge
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/bgp"
+	"github.com/plexsphere/plexd/internal/validation"
+)
+
+const (
+	bgpTestLocalAS  = 64512
+	bgpTestRouterAS = 65001
+)
+
+// newTestRouter starts a BGP speaker on addr that stands in for the local
+// router and waits for the bridge to connect from 127.0.0.1.
+func newTestRouter(t *testing.T, addr string, advertise ...string) *bgp.Speaker {
+	t.Helper()
+	r := bgp.NewSpeaker(bgp.Config{
+		LocalAS:    bgpTestRouterAS,
+		RouterID:   netip.MustParseAddr("192.0.2.254"),
+		ListenAddr: net.JoinHostPort(addr, "0"),
+	}, discardLogger())
+	r.SetNeighbors([]bgp.Neighbor{{Address: netip.MustParseAddr("127.0.0.1"), AS: bgpTestLocalAS, Passive: true}})
+	var prefixes []netip.Prefix
+	for _, p := range advertise {
+		prefixes = append(prefixes, netip.MustParsePrefix(p))
+	}
+	r.Advertise(prefixes)
+	if err := r.Start(context.Background()); err != nil {
+		t.Skipf("listen on %s: %v", addr, err)
+	}
+	t.Cleanup(r.Stop)
+	return r
+}
+
+// newTestBGPSpeaker returns a started bridge speaker peering with routers.
+func newTestBGPSpeaker(t *testing.T, ctrl RouteController, maxRoutes int, routers ...*bgp.Speaker) *BGPSpeaker {
+	t.Helper()
+	cfg := Config{
+		Enabled:              true,
+		AccessInterface:      "eth1",
+		BGPEnabled:           true,
+		BGPLocalAS:           bgpTestLocalAS,
+		BGPRouterID:          "192.0.2.10",
+		BGPMaxImportedRoutes: maxRoutes,
+	}
+	for _, r := range routers {
+		addr := r.Addr().(*net.TCPAddr)
+		cfg.BGPNeighbors = append(cfg.BGPNeighbors, BGPNeighbor{Address: addr.IP.String(), AS: bgpTestRouterAS, Port: addr.Port})
+	}
+	cfg.ApplyDefaults()
+	s := NewBGPSpeaker(ctrl, cfg, discardLogger())
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { s.Stop() })
+	return s
+}
+
+func routePrefixes(routes []bgp.Route) []string {
+	var ps []string
+	for _, r := range routes {
+		ps = append(ps, r.Prefix.String())
+	}
+	return ps
+}
+
+func importedPrefixes(st *api.BridgeBGPStatus) []string {
+	var ps []string
+	for _, r := range st.Imported {
+		ps = append(ps, r.Prefix)
+	}
+	return ps
+}
+
+func TestBGPSpeaker_ExchangeRoutes(t *testing.T) {
+	router := newTestRouter(t, "127.0.0.1", "192.168.50.0/24", "10.200.0.0/16")
+	ctrl := &mockGatewayRouteController{}
+	s := newTestBGPSpeaker(t, ctrl, 0, router)
+
+	if err := s.SetAccessSubnets([]string{"192.168.1.0/24"}); err != nil {
+		t.Fatalf("SetAccessSubnets: %v", err)
+	}
+	if err := s.Configure(&api.BridgeBGPConfig{
+		Advertise: []string{"10.99.0.0/16"},
+		Import:    []string{"192.168.0.0/16"},
+	}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+
+	waitForCondition(t, 5*time.Second, func() bool {
+		return slices.Equal(routePrefixes(router.Routes()), []string{"10.99.0.0/16", "192.168.1.0/24"})
+	})
+	waitForCondition(t, 5*time.Second, func() bool { return len(ctrl.callsFor("AddGatewayRoute")) == 1 })
+	if got := ctrl.callsFor("AddGatewayRoute")[0].Args; !slices.Equal(got, []interface{}{"192.168.50.0/24", "127.0.0.1", "eth1"}) {
+		t.Errorf("AddGatewayRoute args = %v", got)
+	}
+
+	st := s.Status()
+	if st == nil {
+		t.Fatal("Status() = nil")
+	}
+	if st.LocalAS != bgpTestLocalAS || st.RouterID != "192.0.2.10" {
+		t.Errorf("LocalAS, RouterID = %d, %s", st.LocalAS, st.RouterID)
+	}
+	if len(st.Neighbors) != 1 || st.Neighbors[0].State != bgp.StateEstablished || st.Neighbors[0].Received != 2 {
+		t.Errorf("Neighbors = %+v", st.Neighbors)
+	}
+	if !slices.Equal(st.Advertised, []string{"10.99.0.0/16", "192.168.1.0/24"}) {
+		t.Errorf("Advertised = %v", st.Advertised)
+	}
+	if !slices.Equal(importedPrefixes(st), []string{"192.168.50.0/24"}) || st.Imported[0].NextHop != "127.0.0.1" {
+		t.Errorf("Imported = %+v", st.Imported)
+	}
+	if st.Rejected != 1 {
+		t.Errorf("Rejected = %d, want 1 for the route outside the import policy", st.Rejected)
+	}
+
+	// A withdrawn route is removed.
+	router.Advertise([]netip.Prefix{netip.MustParsePrefix("10.200.0.0/16")})
+	waitForCondition(t, 5*time.Second, func() bool { return len(ctrl.callsFor("RemoveGatewayRoute")) == 1 })
+	if st := s.Status(); len(st.Imported) != 0 {
+		t.Errorf("Imported = %+v after withdrawal", st.Imported)
+	}
+
+	// Stopping withdraws the advertised subnets.
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	waitForCondition(t, 5*time.Second, func() bool { return len(router.Routes()) == 0 })
+	if s.Status() != nil {
+		t.Error("Status() != nil after Stop")
+	}
+}
+
+func TestBGPSpeaker_Rejects(t *testing.T) {
+	tests := []struct {
+		name      string
+		advertise []string
+		protected []validation.ProtectedAddr
+		maxRoutes int
+		want      []string
+		rejected  int
+	}{
+		{
+			name:      "overlapping an advertised subnet",
+			advertise: []string{"192.168.1.128/25", "192.168.2.0/24"},
+			want:      []string{"192.168.2.0/24"},
+			rejected:  1,
+		},
+		{
+			name:      "capturing a protected address",
+			advertise: []string{"192.168.2.0/24", "192.168.3.0/24"},
+			protected: []validation.ProtectedAddr{{Addr: netip.MustParseAddr("192.168.3.10"), Owner: "control plane"}},
+			want:      []string{"192.168.2.0/24"},
+			rejected:  1,
+		},
+		{
+			name:      "default route",
+			advertise: []string{"0.0.0.0/0", "192.168.2.0/24"},
+			want:      []string{"192.168.2.0/24"},
+			rejected:  1,
+		},
+		{
+			name:      "beyond the import limit",
+			advertise: []string{"192.168.2.0/24", "192.168.3.0/24", "192.168.4.0/24"},
+			maxRoutes: 2,
+			want:      []string{"192.168.2.0/24", "192.168.3.0/24"},
+			rejected:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestRouter(t, "127.0.0.1", tt.advertise...)
+			ctrl := &mockGatewayRouteController{}
+			s := newTestBGPSpeaker(t, ctrl, tt.maxRoutes, router)
+			s.SetProtectedSources(ProtectedSourceFunc(func() []validation.ProtectedAddr { return tt.protected }))
+			s.SetAccessSubnets([]string{"192.168.1.0/24"})
+			if err := s.Configure(&api.BridgeBGPConfig{Import: []string{"0.0.0.0/0"}}); err != nil {
+				t.Fatalf("Configure: %v", err)
+			}
+
+			waitForCondition(t, 5*time.Second, func() bool {
+				st := s.Status()
+				return len(st.Neighbors) == 1 && st.Neighbors[0].Received == len(tt.advertise)
+			})
+			// The hook of the last UPDATE may still be running.
+			waitForCondition(t, 5*time.Second, func() bool {
+				return slices.Equal(importedPrefixes(s.Status()), tt.want)
+			})
+			if st := s.Status(); st.Rejected != tt.rejected {
+				t.Errorf("Rejected = %d, want %d", st.Rejected, tt.rejected)
+			}
+		})
+	}
+}
+
+func TestBGPSpeaker_TieBreak(t *testing.T) {
+	low := newTestRouter(t, "127.0.0.1", "192.168.2.0/24")
+	high := bgp.NewSpeaker(bgp.Config{
+		LocalAS:    bgpTestRouterAS,
+		RouterID:   netip.MustParseAddr("192.0.2.253"),
+		ListenAddr: "127.0.0.2:0",
+	}, discardLogger())
+	high.SetNeighbors([]bgp.Neighbor{{Address: netip.MustParseAddr("127.0.0.1"), AS: bgpTestLocalAS, Passive: true}})
+	if err := high.Start(context.Background()); err != nil {
+		t.Skipf("listen on 127.0.0.2: %v", err)
+	}
+	defer high.Stop()
+	high.Advertise([]netip.Prefix{netip.MustParsePrefix("192.168.2.0/24")})
+
+	ctrl := &mockGatewayRouteController{}
+	s := newTestBGPSpeaker(t, ctrl, 0, high, low)
+	if err := s.Configure(&api.BridgeBGPConfig{Import: []string{"192.168.0.0/16"}}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	waitForCondition(t, 5*time.Second, func() bool {
+		st := s.Status()
+		return len(st.Neighbors) == 2 && st.Neighbors[0].Received == 1 && st.Neighbors[1].Received == 1
+	})
+	// Both paths are one AS long: the lower neighbor address wins.
+	waitForCondition(t, 5*time.Second, func() bool {
+		imported := s.Status().Imported
+		return len(imported) == 1 && imported[0].Neighbor == "127.0.0.1"
+	})
+}
+
+func TestBGPSpeaker_NoGatewayRouter(t *testing.T) {
+	router := newTestRouter(t, "127.0.0.1", "192.168.2.0/24")
+	s := newTestBGPSpeaker(t, &mockRouteController{}, 0, router)
+	if err := s.Configure(&api.BridgeBGPConfig{Import: []string{"192.168.0.0/16"}}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	waitForCondition(t, 5*time.Second, func() bool { return s.Status().Error != "" })
+	if st := s.Status(); st.Error != errNoGatewayRouter || len(st.Imported) != 0 {
+		t.Errorf("Error, Imported = %q, %v", st.Error, st.Imported)
+	}
+}
+
+func TestBGPSpeaker_ConfigureInvalid(t *testing.T) {
+	s := NewBGPSpeaker(&mockRouteController{}, Config{}, discardLogger())
+	err := s.Configure(&api.BridgeBGPConfig{Import: []string{"192.168.0.0"}})
+	if err == nil || err.Error() != `bridge: bgp: invalid import subnet "192.168.0.0"` {
+		t.Errorf("Configure() = %v", err)
+	}
+}
+
+func TestManager_BGP(t *testing.T) {
+	mgr := NewManager(&mockRouteController{}, Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"192.168.1.0/24"},
+	}, discardLogger())
+	if mgr.BGP() != nil {
+		t.Fatal("BGP() != nil without BGPEnabled")
+	}
+	if err := mgr.UpdateBGP(&api.BridgeBGPConfig{}); err != nil {
+		t.Errorf("UpdateBGP without BGP = %v", err)
+	}
+
+	mgr = NewManager(&mockRouteController{}, Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"192.168.1.0/24"},
+		BGPEnabled:      true,
+		BGPLocalAS:      bgpTestLocalAS,
+		BGPNeighbors:    []BGPNeighbor{{Address: "192.168.1.254", AS: bgpTestRouterAS}},
+	}, discardLogger())
+	if mgr.BGP() == nil {
+		t.Fatal("BGP() = nil with BGPEnabled")
+	}
+	caps := mgr.BridgeCapabilities()
+	if caps["bgp"] != "true" || caps["bgp_local_as"] != "64512" {
+		t.Errorf("capabilities = %v", caps)
+	}
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if info := mgr.BridgeStatus(); info.BGP != nil {
+		t.Errorf("BridgeStatus().BGP = %+v before StartBGP", info.BGP)
+	}
+}
@@ -3,7 +3,10 @@ package bridge
 import (
 	"fmt"
 	"net"
+	"net/netip"
 	"time"
+
+	"github.com/plexsphere/plexd/internal/validation"
 )
 
 const (
@@ -15,12 +18,49 @@ const (
 	DefaultUserAccessListenPort    = 51822
 	DefaultMaxAccessPeers          = 50
 
-	DefaultMaxIngressRules    = 20
-	DefaultIngressDialTimeout = 10 * time.Second
+	DefaultMaxIngressRules          = 20
+	DefaultIngressDialTimeout       = 10 * time.Second
+	DefaultIngressDrainTimeout      = 30 * time.Second
+	DefaultIngressUDPSessionTimeout = 60 * time.Second
+	DefaultMaxIngressUDPSessions    = 1024
+
+	DefaultRouteFwMarkBase   = 0x504c0000
+	DefaultRouteRulePriority = 10000
 
 	DefaultSiteToSiteInterfacePrefix = "wg-s2s-"
 	DefaultSiteToSiteListenPort      = 51823
 	DefaultMaxSiteToSiteTunnels      = 10
+	DefaultSiteToSiteL2MaxMACs       = 1024
+
+	DefaultHAListenPort     = 51840
+	DefaultHAAdvertInterval = 1 * time.Second
+
+	DefaultDNSForwardPort = 53
+	DefaultDNSDomain      = "mesh"
+
+	DefaultBGPHoldTime          = 90 * time.Second
+	DefaultBGPMaxImportedRoutes = 1000
+)
+
+const (
+	// NATBackendNftables programs masquerade rules in plexd's own nftables
+	// table. It is the default NAT backend.
+	NATBackendNftables = "nftables"
+
+	// NATBackendIPTables programs masquerade rules with the iptables
+	// command, for hosts whose firewall is managed through iptables.
+	NATBackendIPTables = "iptables"
+)
+
+const (
+	// AccessSubnetModeStatic routes AccessSubnets at Setup and the subnets
+	// of the control plane's BridgeConfig afterwards. It is the default.
+	AccessSubnetModeStatic = "static"
+
+	// AccessSubnetModeApproval routes only subnets confirmed in the control
+	// plane's BridgeConfig. The subnets found on the access interface, and
+	// AccessSubnets, are proposed to the control plane instead of routed.
+	AccessSubnetModeApproval = "approval"
 )
 
 // Config holds the configuration for bridge mode.
@@ -30,16 +70,76 @@ type Config struct {
 	// Default: false
 	Enabled bool
 
+	// Backend selects the OS-level controller implementation: "netlink" or "noop".
+	// Default: "netlink"
+	Backend string
+
+	// FastPath selects whether forwarded traffic and relay sessions are
+	// offloaded to an in-kernel fast path: "off" or "auto". With "auto" the
+	// netlink backend probes the kernel and falls back to the userspace path
+	// when the fast path is unavailable.
+	// Default: "off"
+	FastPath string
+
 	// AccessInterface is the name of the access-side network interface.
 	AccessInterface string
 
+	// RouteTable is the routing table that bridge and site-to-site routes are
+	// placed in. Zero places routes in the main table.
+	// Default: 0
+	RouteTable int
+
+	// RouteFwMarkBase is the first fwmark assigned to plexd-managed interfaces
+	// when RouteTable is set. Each interface gets a distinct mark.
+	// Default: 0x504c0000
+	RouteFwMarkBase uint32
+
+	// RouteRulePriority is the ip rule priority used to select RouteTable.
+	// Default: 10000
+	RouteRulePriority int
+
 	// AccessSubnets are the CIDR subnets reachable via the access-side interface.
+	// Required in the static access subnet mode; proposed, not routed, in
+	// the approval mode.
 	AccessSubnets []string
 
+	// AccessSubnetMode selects whether access subnets are routed as
+	// configured or only after the control plane confirms them: "static"
+	// or "approval".
+	// Default: "static"
+	AccessSubnetMode string
+
+	// AllowFullTunnel permits access subnets and site-to-site remote
+	// subnets whose routes would replace the default route or capture the
+	// control plane or a default gateway. Such routes are refused otherwise.
+	// Set it only for intentional full-tunnel setups in which the control
+	// plane stays reachable by other means, such as a more specific route.
+	// Default: false
+	AllowFullTunnel bool
+
 	// EnableNAT controls whether NAT masquerading is applied on the access-side interface.
 	// nil means use default (true); explicit false disables NAT.
+	// When allowed, the control plane switches masquerading on and off
+	// through BridgeConfig.EnableNAT.
 	EnableNAT *bool
 
+	// NATBackend selects how the netlink backend programs masquerade rules:
+	// "nftables" or "iptables".
+	// Default: "nftables"
+	NATBackend string
+
+	// MSSClamp selects how the MSS of TCP connections forwarded through the
+	// access, user access, and site-to-site interfaces is clamped: "off",
+	// "pmtu" to fit the MTU of the route a connection takes, or a fixed MSS
+	// such as "1360".
+	// Default: "pmtu"
+	MSSClamp string
+
+	// MSSClampInterfaces overrides MSSClamp for the interfaces it names,
+	// with the same values. Site-to-site interfaces are named by the
+	// control plane's tunnel InterfaceName.
+	MSSClampInterfaces map[string]string
+
 	// RelayEnabled controls whether the bridge node serves as a relay.
 	// Default: false. Requires Enabled=true.
 	RelayEnabled bool
@@ -68,6 +168,12 @@ type Config struct {
 	// Default: 51822
 	UserAccessListenPort int
 
+	// UserAccessEndpoint is the host, or host:port, user access clients use
+	// to reach this bridge node; without a port UserAccessListenPort is
+	// used. It is published in the client export and profiles. Default:
+	// empty, which leaves the endpoint to whoever renders them.
+	UserAccessEndpoint string
+
 	// MaxAccessPeers is the maximum number of concurrent user access peers.
 	// Default: 50
 	MaxAccessPeers int
@@ -84,6 +190,22 @@ type Config struct {
 	// Default: 10s. Minimum: 1s.
 	IngressDialTimeout time.Duration
 
+	// IngressDrainTimeout is how long a removed ingress rule, or all rules
+	// on teardown, wait for in-flight proxied connections to finish before
+	// they are force-closed.
+	// Default: 30s
+	IngressDrainTimeout time.Duration
+
+	// IngressUDPSessionTimeout is how long a client session of a udp mode
+	// ingress rule stays open without traffic in either direction.
+	// Default: 60s. Minimum: 1s.
+	IngressUDPSessionTimeout time.Duration
+
+	// MaxIngressUDPSessions is the maximum number of concurrent client
+	// sessions per udp mode ingress rule.
+	// Default: 1024
+	MaxIngressUDPSessions int
+
 	// SiteToSiteEnabled controls whether site-to-site VPN connectivity is active.
 	// Default: false. Requires Enabled=true.
 	SiteToSiteEnabled bool
@@ -101,6 +223,113 @@ type Config struct {
 	// MaxSiteToSiteTunnels is the maximum number of concurrent site-to-site tunnels.
 	// Default: 10
 	MaxSiteToSiteTunnels int
+
+	// SiteToSiteVICISocket is the VICI socket of strongSwan's charon, which
+	// negotiates the SAs of site-to-site tunnels of type ipsec.
+	// Default: "/var/run/charon.vici"
+	SiteToSiteVICISocket string
+
+	// SiteToSiteL2MaxMACs limits the MAC addresses learned from the remote
+	// site of a layer-2 extension that sets no limit of its own.
+	// Default: 1024
+	SiteToSiteL2MaxMACs int
+
+	// HAListenPort is the UDP port the HA election listens on for adverts
+	// from the other members of the node's bridge HA group.
+	// Default: 51840
+	HAListenPort int
+
+	// HAAdvertInterval is how often the active member of a bridge HA group
+	// advertises. A standby member takes over after about three intervals
+	// without an advert.
+	// Default: 1s. Minimum: 100ms.
+	HAAdvertInterval time.Duration
+
+	// DNSForwardEnabled controls whether a DNS forwarder runs on the access
+	// interface, resolving mesh names for LAN devices and forwarding other
+	// queries upstream.
+	// Default: false. Requires Enabled=true.
+	DNSForwardEnabled bool
+
+	// DNSForwardPort is the UDP port the DNS forwarder listens on.
+	// Default: 53
+	DNSForwardPort int
+
+	// DNSDomain is the domain under which mesh peers are resolvable, as
+	// <hostname>.<domain> or <peer ID>.<domain>.
+	// Default: "mesh"
+	DNSDomain string
+
+	// DNSUpstreams are the nameservers that queries for other names are
+	// forwarded to, as IP addresses with an optional port. When empty, the
+	// nameservers of /etc/resolv.conf are used.
+	DNSUpstreams []string
+
+	// BGPEnabled controls whether a BGP speaker exchanges routes with the
+	// local router: it advertises the routed access subnets and the
+	// subnets of the control plane's BGP policy, and imports the learned
+	// routes the policy allows.
+	// Default: false. Requires Enabled=true.
+	BGPEnabled bool
+
+	// BGPLocalAS is the AS number of the speaker. Required when BGP is
+	// enabled.
+	BGPLocalAS uint32
+
+	// BGPRouterID is the BGP identifier of the speaker, an IPv4 address.
+	// Default: the first IPv4 address of AccessInterface
+	BGPRouterID string
+
+	// BGPListenAddr is the TCP address the speaker accepts connections
+	// from passive neighbors on, such as ":179". Empty means the speaker
+	// only dials its neighbors.
+	BGPListenAddr string
+
+	// BGPNeighbors are the routers the speaker peers with.
+	BGPNeighbors []BGPNeighbor
+
+	// BGPHoldTime is the hold time the speaker proposes.
+	// Default: 90s. Minimum: 3s.
+	BGPHoldTime time.Duration
+
+	// BGPMaxImportedRoutes limits the number of learned routes installed.
+	// Routes beyond the limit are rejected.
+	// Default: 1000
+	BGPMaxImportedRoutes int
+}
+
+// BGPNeighbor is a router the BGP speaker peers with.
+type BGPNeighbor struct {
+	// Address is the IP address of the router.
+	Address string
+	// AS is the AS number of the router; equal to BGPLocalAS for iBGP.
+	AS uint32
+	// Port is the TCP port the router is dialed on.
+	// Default: 179
+	Port int
+	// Passive neighbors are not dialed; they connect to BGPListenAddr.
+	Passive bool
+}
+
+// ReservedPorts returns the UDP ports of the bridge listeners enabled in c —
+// the relay, the user access interface, the HA election, and the DNS
+// forwarder — which
+// site-to-site tunnels and udp ingress rules must not use.
+func (c *Config) ReservedPorts() []validation.ReservedPort {
+	var ports []validation.ReservedPort
+	if c.Enabled {
+		ports = append(ports, validation.ReservedPort{Port: c.HAListenPort, Owner: "HA election"})
+	}
+	if c.RelayEnabled {
+		ports = append(ports, validation.ReservedPort{Port: c.RelayListenPort, Owner: "relay"})
+	}
+	if c.UserAccessEnabled {
+		ports = append(ports, validation.ReservedPort{Port: c.UserAccessListenPort, Owner: "user access interface"})
+	}
+	if c.DNSForwardEnabled {
+		ports = append(ports, validation.ReservedPort{Port: c.DNSForwardPort, Owner: "DNS forwarder"})
+	}
+	return ports
 }
 
 // BoolPtr returns a pointer to the given bool value.
@@ -114,9 +343,45 @@ func (c *Config) natEnabled() bool {
 	return *c.EnableNAT
 }
 
+// approvalMode reports whether access subnets are routed only after the
+// control plane confirms them.
+func (c *Config) approvalMode() bool {
+	return c.AccessSubnetMode == AccessSubnetModeApproval
+}
+
+// policyRouting returns the routing table placement derived from the config.
+func (c *Config) policyRouting() PolicyRouting {
+	return PolicyRouting{
+		Table:        c.RouteTable,
+		FwMarkBase:   c.RouteFwMarkBase,
+		RulePriority: c.RouteRulePriority,
+	}
+}
+
 // ApplyDefaults sets default values for zero-valued fields.
 func (c *Config) ApplyDefaults() {
 	// EnableNAT is handled via natEnabled(); nil means default true.
+	if c.Backend == "" {
+		c.Backend = BackendNetlink
+	}
+	if c.FastPath == "" {
+		c.FastPath = FastPathOff
+	}
+	if c.NATBackend == "" {
+		c.NATBackend = NATBackendNftables
+	}
+	if c.AccessSubnetMode == "" {
+		c.AccessSubnetMode = AccessSubnetModeStatic
+	}
+	if c.MSSClamp == "" {
+		c.MSSClamp = MSSClampPMTU
+	}
+	if c.RouteFwMarkBase == 0 {
+		c.RouteFwMarkBase = DefaultRouteFwMarkBase
+	}
+	if c.RouteRulePriority == 0 {
+		c.RouteRulePriority = DefaultRouteRulePriority
+	}
 	if c.RelayListenPort == 0 {
 		c.RelayListenPort = DefaultRelayListenPort
 	}
@@ -141,6 +406,15 @@ func (c *Config) ApplyDefaults() {
 	if c.IngressDialTimeout == 0 {
 		c.IngressDialTimeout = DefaultIngressDialTimeout
 	}
+	if c.IngressDrainTimeout == 0 {
+		c.IngressDrainTimeout = DefaultIngressDrainTimeout
+	}
+	if c.IngressUDPSessionTimeout == 0 {
+		c.IngressUDPSessionTimeout = DefaultIngressUDPSessionTimeout
+	}
+	if c.MaxIngressUDPSessions == 0 {
+		c.MaxIngressUDPSessions = DefaultMaxIngressUDPSessions
+	}
 	if c.SiteToSiteInterfacePrefix == "" {
 		c.SiteToSiteInterfacePrefix = DefaultSiteToSiteInterfacePrefix
 	}
@@ -150,6 +424,30 @@ func (c *Config) ApplyDefaults() {
 	if c.MaxSiteToSiteTunnels == 0 {
 		c.MaxSiteToSiteTunnels = DefaultMaxSiteToSiteTunnels
 	}
+	if c.SiteToSiteVICISocket == "" {
+		c.SiteToSiteVICISocket = DefaultSiteToSiteVICISocket
+	}
+	if c.SiteToSiteL2MaxMACs == 0 {
+		c.SiteToSiteL2MaxMACs = DefaultSiteToSiteL2MaxMACs
+	}
+	if c.HAListenPort == 0 {
+		c.HAListenPort = DefaultHAListenPort
+	}
+	if c.HAAdvertInterval == 0 {
+		c.HAAdvertInterval = DefaultHAAdvertInterval
+	}
+	if c.DNSForwardPort == 0 {
+		c.DNSForwardPort = DefaultDNSForwardPort
+	}
+	if c.DNSDomain == "" {
+		c.DNSDomain = DefaultDNSDomain
+	}
+	if c.BGPHoldTime == 0 {
+		c.BGPHoldTime = DefaultBGPHoldTime
+	}
+	if c.BGPMaxImportedRoutes == 0 {
+		c.BGPMaxImportedRoutes = DefaultBGPMaxImportedRoutes
+	}
 }
 
 // Validate checks that configuration values are acceptable.
@@ -167,13 +465,44 @@ func (c *Config) Validate() error {
 	if c.SiteToSiteEnabled && !c.Enabled {
 		return fmt.Errorf("bridge: config: site-to-site requires bridge mode to be enabled")
 	}
+	if c.DNSForwardEnabled && !c.Enabled {
+		return fmt.Errorf("bridge: config: DNS forwarding requires bridge mode to be enabled")
+	}
+	if c.BGPEnabled && !c.Enabled {
+		return fmt.Errorf("bridge: config: BGP requires bridge mode to be enabled")
+	}
 	if !c.Enabled {
 		return nil
 	}
+	if c.Backend != "" && c.Backend != BackendNetlink && c.Backend != BackendNoop {
+		return fmt.Errorf("bridge: config: unknown Backend %q (must be %q or %q)", c.Backend, BackendNetlink, BackendNoop)
+	}
+	if c.FastPath != "" && c.FastPath != FastPathOff && c.FastPath != FastPathAuto {
+		return fmt.Errorf("bridge: config: unknown FastPath %q (must be %q or %q)", c.FastPath, FastPathOff, FastPathAuto)
+	}
+	if c.NATBackend != "" && c.NATBackend != NATBackendNftables && c.NATBackend != NATBackendIPTables {
+		return fmt.Errorf("bridge: config: unknown NATBackend %q (must be %q or %q)", c.NATBackend, NATBackendNftables, NATBackendIPTables)
+	}
+	if err := c.policyRouting().validate(); err != nil {
+		return err
+	}
+	if c.MSSClamp != "" {
+		if _, _, err := parseMSSClamp(c.MSSClamp); err != nil {
+			return fmt.Errorf("bridge: config: invalid MSSClamp %q: %w", c.MSSClamp, err)
+		}
+	}
+	for iface, s := range c.MSSClampInterfaces {
+		if _, _, err := parseMSSClamp(s); err != nil {
+			return fmt.Errorf("bridge: config: invalid MSSClampInterfaces[%q] %q: %w", iface, s, err)
+		}
+	}
 	if c.AccessInterface == "" {
 		return fmt.Errorf("bridge: config: AccessInterface is required when enabled")
 	}
-	if len(c.AccessSubnets) == 0 {
+	if c.AccessSubnetMode != "" && c.AccessSubnetMode != AccessSubnetModeStatic && c.AccessSubnetMode != AccessSubnetModeApproval {
+		return fmt.Errorf("bridge: config: unknown AccessSubnetMode %q (must be %q or %q)", c.AccessSubnetMode, AccessSubnetModeStatic, AccessSubnetModeApproval)
+	}
+	if len(c.AccessSubnets) == 0 && !c.approvalMode() {
 		return fmt.Errorf("bridge: config: at least one AccessSubnet is required when enabled")
 	}
 	for _, subnet := range c.AccessSubnets {
@@ -181,6 +510,14 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("bridge: config: invalid CIDR %q: %w", subnet, err)
 		}
 	}
+	// The HA fields are checked only when set: HA is enabled by the control
+	// plane, and ApplyDefaults fills them in.
+	if c.HAListenPort < 0 || c.HAListenPort > 65535 {
+		return fmt.Errorf("bridge: config: HAListenPort must be between 1 and 65535")
+	}
+	if c.HAAdvertInterval != 0 && c.HAAdvertInterval < 100*time.Millisecond {
+		return fmt.Errorf("bridge: config: HAAdvertInterval must be at least 100ms")
+	}
 	if c.RelayEnabled {
 		if c.RelayListenPort < 1 || c.RelayListenPort > 65535 {
 			return fmt.Errorf("bridge: config: RelayListenPort must be between 1 and 65535")
@@ -199,6 +536,11 @@ func (c *Config) Validate() error {
 		if c.UserAccessInterfaceName == "" {
 			return fmt.Errorf("bridge: config: UserAccessInterfaceName is required when user access is enabled")
 		}
+		if c.UserAccessEndpoint != "" {
+			if _, err := clientEndpoint(c.UserAccessEndpoint, c.UserAccessListenPort); err != nil {
+				return fmt.Errorf("bridge: config: invalid UserAccessEndpoint: %w", err)
+			}
+		}
 		if c.MaxAccessPeers <= 0 {
 			return fmt.Errorf("bridge: config: MaxAccessPeers must be positive when user access is enabled")
 		}
@@ -210,6 +552,15 @@ func (c *Config) Validate() error {
 		if c.IngressDialTimeout < 1*time.Second {
 			return fmt.Errorf("bridge: config: IngressDialTimeout must be at least 1s")
 		}
+		if c.IngressDrainTimeout < 0 {
+			return fmt.Errorf("bridge: config: IngressDrainTimeout must not be negative")
+		}
+		if c.IngressUDPSessionTimeout != 0 && c.IngressUDPSessionTimeout < 1*time.Second {
+			return fmt.Errorf("bridge: config: IngressUDPSessionTimeout must be at least 1s")
+		}
+		if c.MaxIngressUDPSessions < 0 {
+			return fmt.Errorf("bridge: config: MaxIngressUDPSessions must not be negative")
+		}
 	}
 	if c.SiteToSiteEnabled {
 		if c.SiteToSiteListenPort < 1 || c.SiteToSiteListenPort > 65535 {
@@ -221,6 +572,74 @@ func (c *Config) Validate() error {
 		if c.MaxSiteToSiteTunnels <= 0 {
 			return fmt.Errorf("bridge: config: MaxSiteToSiteTunnels must be positive when site-to-site is enabled")
 		}
+		if c.SiteToSiteL2MaxMACs < 0 {
+			return fmt.Errorf("bridge: config: SiteToSiteL2MaxMACs must not be negative")
+		}
+	}
+	if c.DNSForwardEnabled {
+		if c.DNSForwardPort < 1 || c.DNSForwardPort > 65535 {
+			return fmt.Errorf("bridge: config: DNSForwardPort must be between 1 and 65535")
+		}
+		if !validDomain(c.DNSDomain) {
+			return fmt.Errorf("bridge: config: invalid DNSDomain %q", c.DNSDomain)
+		}
+		for _, upstream := range c.DNSUpstreams {
+			if !validDNSUpstream(upstream) {
+				return fmt.Errorf("bridge: config: invalid DNSUpstreams entry %q", upstream)
+			}
+		}
+	}
+	if c.BGPEnabled {
+		if err := c.validateBGP(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateBGP checks the BGP fields of an enabled BGP speaker.
+func (c *Config) validateBGP() error {
+	if c.BGPLocalAS == 0 {
+		return fmt.Errorf("bridge: config: BGPLocalAS is required when BGP is enabled")
+	}
+	if c.BGPRouterID != "" {
+		if id, err := netip.ParseAddr(c.BGPRouterID); err != nil || !id.Is4() {
+			return fmt.Errorf("bridge: config: BGPRouterID %q must be an IPv4 address", c.BGPRouterID)
+		}
+	}
+	if c.BGPListenAddr != "" {
+		if _, _, err := net.SplitHostPort(c.BGPListenAddr); err != nil {
+			return fmt.Errorf("bridge: config: invalid BGPListenAddr %q: %w", c.BGPListenAddr, err)
+		}
+	}
+	if len(c.BGPNeighbors) == 0 {
+		return fmt.Errorf("bridge: config: at least one BGPNeighbor is required when BGP is enabled")
+	}
+	seen := make(map[netip.Addr]bool, len(c.BGPNeighbors))
+	for _, nb := range c.BGPNeighbors {
+		addr, err := netip.ParseAddr(nb.Address)
+		if err != nil {
+			return fmt.Errorf("bridge: config: invalid BGPNeighbors address %q", nb.Address)
+		}
+		if seen[addr.Unmap()] {
+			return fmt.Errorf("bridge: config: duplicate BGPNeighbors address %q", nb.Address)
+		}
+		seen[addr.Unmap()] = true
+		if nb.AS == 0 {
+			return fmt.Errorf("bridge: config: BGPNeighbors %q: AS is required", nb.Address)
+		}
+		if nb.Port < 0 || nb.Port > 65535 {
+			return fmt.Errorf("bridge: config: BGPNeighbors %q: Port must be between 1 and 65535", nb.Address)
+		}
+		if nb.Passive && c.BGPListenAddr == "" {
+			return fmt.Errorf("bridge: config: BGPNeighbors %q: passive neighbors require BGPListenAddr", nb.Address)
+		}
+	}
+	if c.BGPHoldTime != 0 && c.BGPHoldTime < 3*time.Second {
+		return fmt.Errorf("bridge: config: BGPHoldTime must be at least 3s")
+	}
+	if c.BGPMaxImportedRoutes < 0 {
+		return fmt.Errorf("bridge: config: BGPMaxImportedRoutes must not be negative")
 	}
 	return nil
 }
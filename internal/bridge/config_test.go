@@ -395,6 +395,44 @@ func TestConfig_Validate_UserAccessValidConfig(t *testing.T) {
 	}
 }
 
+func TestConfig_Validate_UserAccessEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		wantErr  bool
+	}{
+		{"bridge.example.com", false},
+		{"bridge.example.com:4500", false},
+		{"203.0.113.10", false},
+		{"2001:db8::1", false},
+		{"[2001:db8::1]:4500", false},
+		{":4500", true},
+		{"bridge.example.com:0", true},
+		{"bridge.example.com:http", true},
+		{"https://bridge.example.com", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			cfg := Config{
+				Enabled:                 true,
+				AccessInterface:         "eth1",
+				AccessSubnets:           []string{"10.0.0.0/24"},
+				UserAccessEnabled:       true,
+				UserAccessInterfaceName: "wg-access",
+				UserAccessListenPort:    51822,
+				UserAccessEndpoint:      tt.endpoint,
+				MaxAccessPeers:          50,
+			}
+			err := cfg.Validate()
+			if tt.wantErr && err == nil {
+				t.Fatal("Validate should return error for invalid endpoint")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Validate should return nil, got: %v", err)
+			}
+		})
+	}
+}
+
 func TestConfig_Validate_UserAccessMissingAccessSubnets(t *testing.T) {
 	cfg := Config{
 		Enabled:                true,
@@ -470,6 +508,15 @@ func TestConfig_ApplyDefaults_IngressFields(t *testing.T) {
 	if cfg.IngressDialTimeout != DefaultIngressDialTimeout {
 		t.Errorf("IngressDialTimeout = %v, want %v", cfg.IngressDialTimeout, DefaultIngressDialTimeout)
 	}
+	if cfg.IngressDrainTimeout != DefaultIngressDrainTimeout {
+		t.Errorf("IngressDrainTimeout = %v, want %v", cfg.IngressDrainTimeout, DefaultIngressDrainTimeout)
+	}
+	if cfg.IngressUDPSessionTimeout != DefaultIngressUDPSessionTimeout {
+		t.Errorf("IngressUDPSessionTimeout = %v, want %v", cfg.IngressUDPSessionTimeout, DefaultIngressUDPSessionTimeout)
+	}
+	if cfg.MaxIngressUDPSessions != DefaultMaxIngressUDPSessions {
+		t.Errorf("MaxIngressUDPSessions = %d, want %d", cfg.MaxIngressUDPSessions, DefaultMaxIngressUDPSessions)
+	}
 }
 
 func TestConfig_Validate_IngressWithoutBridge(t *testing.T) {
@@ -550,6 +597,62 @@ func TestConfig_Validate_IngressInvalidDialTimeout(t *testing.T) {
 	}
 }
 
+func TestConfig_Validate_IngressNegativeDrainTimeout(t *testing.T) {
+	cfg := Config{
+		Enabled:             true,
+		AccessInterface:     "eth1",
+		AccessSubnets:       []string{"10.0.0.0/24"},
+		IngressEnabled:      true,
+		MaxIngressRules:     20,
+		IngressDialTimeout:  10 * time.Second,
+		IngressDrainTimeout: -time.Second,
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate should return error for negative IngressDrainTimeout")
+	}
+	want := "bridge: config: IngressDrainTimeout must not be negative"
+	if err.Error() != want {
+		t.Errorf("got %q, want %q", err.Error(), want)
+	}
+}
+
+func TestConfig_Validate_IngressUDPLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{
+			name:    "short session timeout",
+			modify:  func(c *Config) { c.IngressUDPSessionTimeout = 500 * time.Millisecond },
+			wantErr: "bridge: config: IngressUDPSessionTimeout must be at least 1s",
+		},
+		{
+			name:    "negative session limit",
+			modify:  func(c *Config) { c.MaxIngressUDPSessions = -1 },
+			wantErr: "bridge: config: MaxIngressUDPSessions must not be negative",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				Enabled:            true,
+				AccessInterface:    "eth1",
+				AccessSubnets:      []string{"10.0.0.0/24"},
+				IngressEnabled:     true,
+				MaxIngressRules:    20,
+				IngressDialTimeout: 10 * time.Second,
+			}
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Validate() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestConfig_Validate_IngressDisabled(t *testing.T) {
 	cfg := Config{
 		Enabled:         true,
@@ -578,6 +681,12 @@ func TestConfig_ApplyDefaults_SiteToSiteFields(t *testing.T) {
 	if cfg.MaxSiteToSiteTunnels != DefaultMaxSiteToSiteTunnels {
 		t.Errorf("MaxSiteToSiteTunnels = %d, want %d", cfg.MaxSiteToSiteTunnels, DefaultMaxSiteToSiteTunnels)
 	}
+	if cfg.SiteToSiteVICISocket != DefaultSiteToSiteVICISocket {
+		t.Errorf("SiteToSiteVICISocket = %q, want %q", cfg.SiteToSiteVICISocket, DefaultSiteToSiteVICISocket)
+	}
+	if cfg.SiteToSiteL2MaxMACs != DefaultSiteToSiteL2MaxMACs {
+		t.Errorf("SiteToSiteL2MaxMACs = %d, want %d", cfg.SiteToSiteL2MaxMACs, DefaultSiteToSiteL2MaxMACs)
+	}
 }
 
 func TestConfig_Validate_SiteToSiteWithoutBridge(t *testing.T) {
@@ -704,6 +813,22 @@ func TestConfig_Validate_SiteToSiteInvalidMaxTunnels(t *testing.T) {
 	}
 }
 
+func TestConfig_Validate_SiteToSiteNegativeL2MaxMACs(t *testing.T) {
+	cfg := Config{
+		Enabled:             true,
+		AccessInterface:     "eth1",
+		AccessSubnets:       []string{"10.0.0.0/24"},
+		SiteToSiteEnabled:   true,
+		SiteToSiteL2MaxMACs: -1,
+	}
+	cfg.ApplyDefaults()
+	err := cfg.Validate()
+	want := "bridge: config: SiteToSiteL2MaxMACs must not be negative"
+	if err == nil || err.Error() != want {
+		t.Errorf("Validate = %v, want %q", err, want)
+	}
+}
+
 func TestConfig_Validate_SiteToSiteDisabled(t *testing.T) {
 	cfg := Config{
 		Enabled:           true,
@@ -715,3 +840,284 @@ func TestConfig_Validate_SiteToSiteDisabled(t *testing.T) {
 		t.Errorf("Validate should return nil when site-to-site is disabled, got: %v", err)
 	}
 }
+
+func TestConfig_RouteTableDefaults(t *testing.T) {
+	var cfg Config
+	cfg.ApplyDefaults()
+
+	if cfg.RouteTable != 0 {
+		t.Errorf("RouteTable = %d, want 0 (main table)", cfg.RouteTable)
+	}
+	if cfg.RouteFwMarkBase != DefaultRouteFwMarkBase {
+		t.Errorf("RouteFwMarkBase = %#x, want %#x", cfg.RouteFwMarkBase, DefaultRouteFwMarkBase)
+	}
+	if cfg.RouteRulePriority != DefaultRouteRulePriority {
+		t.Errorf("RouteRulePriority = %d, want %d", cfg.RouteRulePriority, DefaultRouteRulePriority)
+	}
+}
+
+func TestConfig_Validate_RouteTable(t *testing.T) {
+	tests := []struct {
+		name    string
+		table   int
+		wantErr string
+	}{
+		{"main table", 0, ""},
+		{"custom table", 51820, ""},
+		{"negative", -1, "must not be negative"},
+		{"reserved main", 254, "reserved"},
+		{"reserved local", 255, "reserved"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				Enabled:         true,
+				AccessInterface: "eth1",
+				AccessSubnets:   []string{"10.0.0.0/24"},
+				RouteTable:      tt.table,
+			}
+			cfg.ApplyDefaults()
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate_NATBackend(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend string
+		wantErr bool
+	}{
+		{"default", "", false},
+		{"nftables", NATBackendNftables, false},
+		{"iptables", NATBackendIPTables, false},
+		{"unknown", "pf", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				Enabled:         true,
+				AccessInterface: "eth1",
+				AccessSubnets:   []string{"10.0.0.0/24"},
+				NATBackend:      tt.backend,
+			}
+			cfg.ApplyDefaults()
+			if tt.backend == "" && cfg.NATBackend != NATBackendNftables {
+				t.Errorf("NATBackend = %q, want %q", cfg.NATBackend, NATBackendNftables)
+			}
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate_AccessSubnetMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		subnets []string
+		wantErr bool
+	}{
+		{"static with subnets", AccessSubnetModeStatic, []string{"10.0.0.0/24"}, false},
+		{"static without subnets", AccessSubnetModeStatic, nil, true},
+		{"approval without subnets", AccessSubnetModeApproval, nil, false},
+		{"approval with invalid subnet", AccessSubnetModeApproval, []string{"bad"}, true},
+		{"unknown", "auto", []string{"10.0.0.0/24"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				Enabled:          true,
+				AccessInterface:  "eth1",
+				AccessSubnets:    tt.subnets,
+				AccessSubnetMode: tt.mode,
+			}
+			cfg.ApplyDefaults()
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate_MSSClamp(t *testing.T) {
+	tests := []struct {
+		name    string
+		clamp   string
+		ifaces  map[string]string
+		wantErr bool
+	}{
+		{"default", "", nil, false},
+		{"off", MSSClampOff, nil, false},
+		{"pmtu", MSSClampPMTU, nil, false},
+		{"fixed", "1360", nil, false},
+		{"too small", "500", nil, true},
+		{"unknown", "auto", nil, true},
+		{"interface override", MSSClampOff, map[string]string{"wg-s2s-a": "1300", "eth1": MSSClampPMTU}, false},
+		{"invalid interface override", MSSClampPMTU, map[string]string{"wg-s2s-a": "65536"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				Enabled:            true,
+				AccessInterface:    "eth1",
+				AccessSubnets:      []string{"10.0.0.0/24"},
+				MSSClamp:           tt.clamp,
+				MSSClampInterfaces: tt.ifaces,
+			}
+			cfg.ApplyDefaults()
+			if tt.clamp == "" && cfg.MSSClamp != MSSClampPMTU {
+				t.Errorf("MSSClamp = %q, want %q", cfg.MSSClamp, MSSClampPMTU)
+			}
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_MSSClampFor(t *testing.T) {
+	cfg := Config{
+		MSSClamp:           MSSClampPMTU,
+		MSSClampInterfaces: map[string]string{"wg-s2s-a": "1300", "wg-access": MSSClampOff},
+	}
+	tests := []struct {
+		iface   string
+		wantMSS int
+		wantOn  bool
+	}{
+		{"eth1", 0, true},
+		{"wg-s2s-a", 1300, true},
+		{"wg-access", 0, false},
+	}
+	for _, tt := range tests {
+		mss, on := cfg.mssClampFor(tt.iface)
+		if mss != tt.wantMSS || on != tt.wantOn {
+			t.Errorf("mssClampFor(%q) = %d, %v, want %d, %v", tt.iface, mss, on, tt.wantMSS, tt.wantOn)
+		}
+	}
+}
+
+func TestConfig_DNSForward(t *testing.T) {
+	var cfg Config
+	cfg.ApplyDefaults()
+	if cfg.DNSForwardEnabled {
+		t.Error("DNSForwardEnabled should default to false")
+	}
+	if cfg.DNSForwardPort != DefaultDNSForwardPort {
+		t.Errorf("DNSForwardPort = %d, want %d", cfg.DNSForwardPort, DefaultDNSForwardPort)
+	}
+	if cfg.DNSDomain != DefaultDNSDomain {
+		t.Errorf("DNSDomain = %q, want %q", cfg.DNSDomain, DefaultDNSDomain)
+	}
+
+	tests := []struct {
+		name string
+		mod  func(*Config)
+		want string
+	}{
+		{"valid", func(*Config) {}, ""},
+		{"without bridge", func(c *Config) { c.Enabled = false }, "bridge: config: DNS forwarding requires bridge mode to be enabled"},
+		{"invalid port", func(c *Config) { c.DNSForwardPort = 70000 }, "bridge: config: DNSForwardPort must be between 1 and 65535"},
+		{"invalid domain", func(c *Config) { c.DNSDomain = "mesh..local" }, `bridge: config: invalid DNSDomain "mesh..local"`},
+		{"invalid upstream", func(c *Config) { c.DNSUpstreams = []string{"dns.example.com"} }, `bridge: config: invalid DNSUpstreams entry "dns.example.com"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				Enabled:           true,
+				AccessInterface:   "eth1",
+				AccessSubnets:     []string{"10.0.0.0/24"},
+				DNSForwardEnabled: true,
+				DNSUpstreams:      []string{"192.168.1.1", "[2001:db8::53]:53"},
+			}
+			cfg.ApplyDefaults()
+			tt.mod(&cfg)
+			err := cfg.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Validate() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_BGP(t *testing.T) {
+	var cfg Config
+	cfg.ApplyDefaults()
+	if cfg.BGPEnabled {
+		t.Error("BGPEnabled should default to false")
+	}
+	if cfg.BGPHoldTime != DefaultBGPHoldTime {
+		t.Errorf("BGPHoldTime = %v, want %v", cfg.BGPHoldTime, DefaultBGPHoldTime)
+	}
+	if cfg.BGPMaxImportedRoutes != DefaultBGPMaxImportedRoutes {
+		t.Errorf("BGPMaxImportedRoutes = %d, want %d", cfg.BGPMaxImportedRoutes, DefaultBGPMaxImportedRoutes)
+	}
+
+	tests := []struct {
+		name string
+		mod  func(*Config)
+		want string
+	}{
+		{"valid", func(*Config) {}, ""},
+		{"without bridge", func(c *Config) { c.Enabled = false }, "bridge: config: BGP requires bridge mode to be enabled"},
+		{"no local AS", func(c *Config) { c.BGPLocalAS = 0 }, "bridge: config: BGPLocalAS is required when BGP is enabled"},
+		{"IPv6 router ID", func(c *Config) { c.BGPRouterID = "2001:db8::1" }, `bridge: config: BGPRouterID "2001:db8::1" must be an IPv4 address`},
+		{"invalid listen address", func(c *Config) { c.BGPListenAddr = "179" }, `bridge: config: invalid BGPListenAddr "179": address 179: missing port in address`},
+		{"no neighbors", func(c *Config) { c.BGPNeighbors = nil }, "bridge: config: at least one BGPNeighbor is required when BGP is enabled"},
+		{"invalid neighbor", func(c *Config) { c.BGPNeighbors[0].Address = "router" }, `bridge: config: invalid BGPNeighbors address "router"`},
+		{"duplicate neighbor", func(c *Config) { c.BGPNeighbors[1].Address = "192.168.1.254" }, `bridge: config: duplicate BGPNeighbors address "192.168.1.254"`},
+		{"neighbor without AS", func(c *Config) { c.BGPNeighbors[0].AS = 0 }, `bridge: config: BGPNeighbors "192.168.1.254": AS is required`},
+		{"neighbor port", func(c *Config) { c.BGPNeighbors[0].Port = 70000 }, `bridge: config: BGPNeighbors "192.168.1.254": Port must be between 1 and 65535`},
+		{"passive without listener", func(c *Config) { c.BGPListenAddr = "" }, `bridge: config: BGPNeighbors "2001:db8::fe": passive neighbors require BGPListenAddr`},
+		{"short hold time", func(c *Config) { c.BGPHoldTime = 2 * time.Second }, "bridge: config: BGPHoldTime must be at least 3s"},
+		{"negative max routes", func(c *Config) { c.BGPMaxImportedRoutes = -1 }, "bridge: config: BGPMaxImportedRoutes must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				Enabled:         true,
+				AccessInterface: "eth1",
+				AccessSubnets:   []string{"10.0.0.0/24"},
+				BGPEnabled:      true,
+				BGPLocalAS:      64512,
+				BGPListenAddr:   ":179",
+				BGPNeighbors: []BGPNeighbor{
+					{Address: "192.168.1.254", AS: 65001},
+					{Address: "2001:db8::fe", AS: 65001, Passive: true},
+				},
+			}
+			cfg.ApplyDefaults()
+			tt.mod(&cfg)
+			err := cfg.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Validate() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+//go:build linux
+
+package bridge
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// FlushConntrackSubnet deletes conntrack entries whose original source or
+// destination address lies within subnet.
+func (c *NetlinkRouteController) FlushConntrackSubnet(subnet string) error {
+	_, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return fmt.Errorf("bridge: flush conntrack: parse CIDR %q: %w", subnet, err)
+	}
+
+	src := &netlink.ConntrackFilter{}
+	if err := src.AddIPNet(netlink.ConntrackOrigSrcIP, ipNet); err != nil {
+		return fmt.Errorf("bridge: flush conntrack %q: %w", subnet, err)
+	}
+	dst := &netlink.ConntrackFilter{}
+	if err := dst.AddIPNet(netlink.ConntrackOrigDstIP, ipNet); err != nil {
+		return fmt.Errorf("bridge: flush conntrack %q: %w", subnet, err)
+	}
+
+	n, err := netlink.ConntrackDeleteFilters(netlink.ConntrackTable, ipFamily(ipNet.IP), src, dst)
+	if err != nil {
+		return fmt.Errorf("bridge: flush conntrack %q: %w", subnet, err)
+	}
+
+	c.logger.Debug("conntrack entries flushed",
+		"component", "bridge",
+		"subnet", subnet,
+		"deleted", n,
+	)
+	return nil
+}
+
+// FlushConntrackPort deletes conntrack entries for the given protocol whose
+// original destination is addr and port. An unspecified addr stands for the
+// addresses of the local interfaces; the IPv6 one also covers IPv4, like a
+// dual-stack socket.
+func (c *NetlinkRouteController) FlushConntrackPort(protocol string, addr netip.Addr, port int) error {
+	proto, err := conntrackProto(protocol)
+	if err != nil {
+		return fmt.Errorf("bridge: flush conntrack port %d: %w", port, err)
+	}
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("bridge: flush conntrack port %d: port out of range", port)
+	}
+	if !addr.IsValid() {
+		return fmt.Errorf("bridge: flush conntrack port %d: invalid address", port)
+	}
+
+	addrs := []netip.Addr{addr}
+	if addr.IsUnspecified() {
+		if addrs, err = localAddrs(addr.Is4()); err != nil {
+			return fmt.Errorf("bridge: flush conntrack port %d: %w", port, err)
+		}
+	}
+
+	filters := make(map[netlink.InetFamily][]netlink.CustomConntrackFilter)
+	for _, a := range addrs {
+		ip := net.IP(a.AsSlice())
+		filter := &netlink.ConntrackFilter{}
+		if err := filter.AddProtocol(proto); err != nil {
+			return fmt.Errorf("bridge: flush conntrack port %d: %w", port, err)
+		}
+		if err := filter.AddIP(netlink.ConntrackOrigDstIP, ip); err != nil {
+			return fmt.Errorf("bridge: flush conntrack port %d: %w", port, err)
+		}
+		if err := filter.AddPort(netlink.ConntrackOrigDstPort, uint16(port)); err != nil {
+			return fmt.Errorf("bridge: flush conntrack port %d: %w", port, err)
+		}
+		filters[ipFamily(ip)] = append(filters[ipFamily(ip)], filter)
+	}
+
+	var deleted uint
+	for family, fs := range filters {
+		n, err := netlink.ConntrackDeleteFilters(netlink.ConntrackTable, family, fs...)
+		if err != nil {
+			return fmt.Errorf("bridge: flush conntrack %s %s: %w", protocol, netip.AddrPortFrom(addr, uint16(port)), err)
+		}
+		deleted += n
+	}
+
+	c.logger.Debug("conntrack entries flushed",
+		"component", "bridge",
+		"protocol", protocol,
+		"address", addr.String(),
+		"port", port,
+		"deleted", deleted,
+	)
+	return nil
+}
+
+// localAddrs returns the addresses of the local interfaces, only the IPv4
+// ones if only4 is set.
+func localAddrs(only4 bool) ([]netip.Addr, error) {
+	family := netlink.FAMILY_ALL
+	if only4 {
+		family = netlink.FAMILY_V4
+	}
+	list, err := netlink.AddrList(nil, family)
+	if err != nil {
+		return nil, fmt.Errorf("list addresses: %w", err)
+	}
+	addrs := make([]netip.Addr, 0, len(list))
+	for _, l := range list {
+		if a, ok := netip.AddrFromSlice(l.IP); ok {
+			addrs = append(addrs, a.Unmap())
+		}
+	}
+	return addrs, nil
+}
+
+// ipFamily returns the conntrack address family for ip.
+func ipFamily(ip net.IP) netlink.InetFamily {
+	if ip.To4() != nil {
+		return unix.AF_INET
+	}
+	return unix.AF_INET6
+}
+
+// conntrackProto maps a protocol name to its IP protocol number.
+func conntrackProto(protocol string) (uint8, error) {
+	switch protocol {
+	case "tcp":
+		return unix.IPPROTO_TCP, nil
+	case "udp":
+		return unix.IPPROTO_UDP, nil
+	default:
+		return 0, fmt.Errorf("unsupported protocol %q", protocol)
+	}
+}
@@ -0,0 +1,138 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DNSConfigurator programs the node's resolver for split DNS: queries for the
+// given domains go to the given servers.
+// All methods must be idempotent.
+type DNSConfigurator interface {
+	// SetSplitDNS routes queries for domains to servers, replacing any
+	// earlier configuration.
+	SetSplitDNS(servers, domains []string) error
+
+	// ClearSplitDNS removes the configuration set by SetSplitDNS.
+	// Idempotent: clearing when nothing is configured returns nil.
+	ClearSplitDNS() error
+}
+
+// DefaultResolvedDropIn is the systemd-resolved drop-in written by
+// ResolvedDNSConfigurator.
+const DefaultResolvedDropIn = "/etc/systemd/resolved.conf.d/plexd-split-dns.conf"
+
+// ResolvedDNSConfigurator implements DNSConfigurator with a systemd-resolved
+// drop-in. The servers are added as global DNS servers and the domains as
+// routing-only domains (~domain), so resolved prefers the servers for those
+// domains.
+type ResolvedDNSConfigurator struct {
+	path   string
+	reload func() error
+	logger *slog.Logger
+}
+
+// NewResolvedDNSConfigurator returns a ResolvedDNSConfigurator that writes
+// DefaultResolvedDropIn and reloads systemd-resolved with systemctl.
+func NewResolvedDNSConfigurator(logger *slog.Logger) *ResolvedDNSConfigurator {
+	return &ResolvedDNSConfigurator{
+		path:   DefaultResolvedDropIn,
+		reload: reloadResolved,
+		logger: logger.With("component", "bridge"),
+	}
+}
+
+// reloadResolved makes systemd-resolved re-read its configuration.
+func reloadResolved() error {
+	out, err := exec.Command("systemctl", "try-reload-or-restart", "systemd-resolved").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("reload systemd-resolved: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// SetSplitDNS writes the drop-in and reloads systemd-resolved.
+func (c *ResolvedDNSConfigurator) SetSplitDNS(servers, domains []string) error {
+	var b strings.Builder
+	b.WriteString("# Managed by plexd: split DNS for user access. Do not edit.\n[Resolve]\n")
+	fmt.Fprintf(&b, "DNS=%s\n", strings.Join(servers, " "))
+	routing := make([]string, len(domains))
+	for i, d := range domains {
+		routing[i] = "~" + d
+	}
+	fmt.Fprintf(&b, "Domains=%s\n", strings.Join(routing, " "))
+	content := []byte(b.String())
+
+	if existing, err := os.ReadFile(c.path); err == nil && string(existing) == string(content) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("bridge: set split DNS: %w", err)
+	}
+	if err := os.WriteFile(c.path, content, 0o644); err != nil {
+		return fmt.Errorf("bridge: set split DNS: %w", err)
+	}
+	if err := c.reload(); err != nil {
+		return fmt.Errorf("bridge: set split DNS: %w", err)
+	}
+	c.logger.Info("split DNS configured",
+		"servers", servers,
+		"domains", domains,
+	)
+	return nil
+}
+
+// ClearSplitDNS removes the drop-in and reloads systemd-resolved.
+func (c *ResolvedDNSConfigurator) ClearSplitDNS() error {
+	if err := os.Remove(c.path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("bridge: clear split DNS: %w", err)
+	}
+	if err := c.reload(); err != nil {
+		return fmt.Errorf("bridge: clear split DNS: %w", err)
+	}
+	c.logger.Info("split DNS removed")
+	return nil
+}
+
+// validateDNS checks that servers are IP addresses and domains are DNS names.
+func validateDNS(servers, domains []string) error {
+	for _, s := range servers {
+		if _, err := netip.ParseAddr(s); err != nil {
+			return fmt.Errorf("invalid DNS server %q", s)
+		}
+	}
+	for _, d := range domains {
+		if !validDomain(d) {
+			return fmt.Errorf("invalid search domain %q", d)
+		}
+	}
+	return nil
+}
+
+// validDomain reports whether d is a DNS name of letters, digits, hyphens,
+// and dots, without a leading or trailing dot or empty labels.
+func validDomain(d string) bool {
+	if d == "" || len(d) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(d, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package bridge
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestResolvedConfigurator(t *testing.T) (*ResolvedDNSConfigurator, *int) {
+	t.Helper()
+	reloads := 0
+	c := &ResolvedDNSConfigurator{
+		path:   filepath.Join(t.TempDir(), "resolved.conf.d", "plexd-split-dns.conf"),
+		reload: func() error { reloads++; return nil },
+		logger: discardLogger(),
+	}
+	return c, &reloads
+}
+
+func TestResolvedDNSConfigurator_SetSplitDNS(t *testing.T) {
+	c, reloads := newTestResolvedConfigurator(t)
+
+	if err := c.SetSplitDNS([]string{"10.0.0.53", "10.0.0.54"}, []string{"corp.example", "lab.example"}); err != nil {
+		t.Fatalf("SetSplitDNS: %v", err)
+	}
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		t.Fatalf("read drop-in: %v", err)
+	}
+	want := "# Managed by plexd: split DNS for user access. Do not edit.\n[Resolve]\n" +
+		"DNS=10.0.0.53 10.0.0.54\nDomains=~corp.example ~lab.example\n"
+	if string(data) != want {
+		t.Errorf("drop-in =\n%s\nwant\n%s", data, want)
+	}
+	if *reloads != 1 {
+		t.Errorf("reloads = %d, want 1", *reloads)
+	}
+
+	// Unchanged configuration does not reload again.
+	if err := c.SetSplitDNS([]string{"10.0.0.53", "10.0.0.54"}, []string{"corp.example", "lab.example"}); err != nil {
+		t.Fatalf("SetSplitDNS: %v", err)
+	}
+	if *reloads != 1 {
+		t.Errorf("reloads after unchanged set = %d, want 1", *reloads)
+	}
+}
+
+func TestResolvedDNSConfigurator_ClearSplitDNS(t *testing.T) {
+	c, reloads := newTestResolvedConfigurator(t)
+
+	// Clearing when nothing is configured is a no-op.
+	if err := c.ClearSplitDNS(); err != nil {
+		t.Fatalf("ClearSplitDNS: %v", err)
+	}
+	if *reloads != 0 {
+		t.Errorf("reloads = %d, want 0", *reloads)
+	}
+
+	if err := c.SetSplitDNS([]string{"10.0.0.53"}, []string{"corp.example"}); err != nil {
+		t.Fatalf("SetSplitDNS: %v", err)
+	}
+	if err := c.ClearSplitDNS(); err != nil {
+		t.Fatalf("ClearSplitDNS: %v", err)
+	}
+	if _, err := os.Stat(c.path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("drop-in still exists: %v", err)
+	}
+	if *reloads != 2 {
+		t.Errorf("reloads = %d, want 2", *reloads)
+	}
+}
+
+func TestResolvedDNSConfigurator_ReloadError(t *testing.T) {
+	c, _ := newTestResolvedConfigurator(t)
+	c.reload = func() error { return errors.New("resolved not running") }
+
+	err := c.SetSplitDNS([]string{"10.0.0.53"}, []string{"corp.example"})
+	if err == nil {
+		t.Fatal("expected error when reload fails")
+	}
+}
+
+func TestValidateDNS(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers []string
+		domains []string
+		wantErr bool
+	}{
+		{"valid", []string{"10.0.0.53", "fd00::53"}, []string{"corp.example", "a-b.example"}, false},
+		{"empty", nil, nil, false},
+		{"server hostname", []string{"dns.example"}, nil, true},
+		{"server with port", []string{"10.0.0.53:53"}, nil, true},
+		{"domain with space", nil, []string{"corp example"}, true},
+		{"domain leading dot", nil, []string{".corp.example"}, true},
+		{"domain leading hyphen", nil, []string{"-corp.example"}, true},
+		{"domain with tilde", nil, []string{"~corp.example"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDNS(tt.servers, tt.domains)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateDNS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,430 @@
+package bridge
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+const (
+	// dnsForwardTimeout bounds the exchange with one upstream nameserver.
+	dnsForwardTimeout = 2 * time.Second
+
+	// dnsForwardTTL is the TTL of answers for mesh names. It is short, so
+	// that LAN devices pick up a changed mesh IP soon.
+	dnsForwardTTL = 60
+
+	// maxDNSForwardQueries bounds the queries handled concurrently; further
+	// queries are dropped and retried by the client.
+	maxDNSForwardQueries = 256
+
+	dnsBufSize = 65535
+)
+
+// resolvConfPath is the resolver configuration that upstream nameservers
+// are read from when none are configured. Tests override it.
+var resolvConfPath = "/etc/resolv.conf"
+
+// DNSForwarder is a DNS server on the access interface that answers queries
+// for mesh names — <peer>.<domain>, by peer hostname or ID — with the peer's
+// mesh IP, and forwards all other queries to upstream nameservers. It lets
+// LAN devices behind a bridge reach mesh peers by name without client
+// software. Only DNS over UDP is served.
+type DNSForwarder struct {
+	iface     string
+	port      int
+	domain    string // lowercase, with trailing dot
+	upstreams []string
+	logger    *slog.Logger
+
+	// listenAddrs returns the addresses of the access interface to listen on.
+	listenAddrs func(iface string) ([]netip.Addr, error)
+
+	mu     sync.RWMutex
+	names  map[string]netip.Addr // lowercase FQDN -> mesh IP
+	conns  []*net.UDPConn
+	active bool
+	sem    chan struct{}
+}
+
+// NewDNSForwarder creates a new DNSForwarder that listens on port of the
+// addresses of iface and answers for names under domain. Upstreams are
+// nameserver addresses, with an optional port; when empty, the nameservers
+// of /etc/resolv.conf are used.
+func NewDNSForwarder(iface string, port int, domain string, upstreams []string, logger *slog.Logger) *DNSForwarder {
+	return &DNSForwarder{
+		iface:       iface,
+		port:        port,
+		domain:      strings.ToLower(strings.TrimSuffix(domain, ".")) + ".",
+		upstreams:   upstreams,
+		logger:      logger.With("component", "bridge"),
+		listenAddrs: interfaceListenAddrs,
+		names:       make(map[string]netip.Addr),
+		sem:         make(chan struct{}, maxDNSForwardQueries),
+	}
+}
+
+// SetPeers replaces the mesh names with those of peers. Each peer is
+// resolvable by its ID and by the first label of its hostname. A hostname
+// shared by several peers is ambiguous and resolves to none of them.
+func (f *DNSForwarder) SetPeers(peers []api.Peer) {
+	names := make(map[string]netip.Addr, 2*len(peers))
+	ambiguous := make(map[string]bool)
+	for _, p := range peers {
+		addr, err := netip.ParseAddr(p.MeshIP)
+		if err != nil {
+			continue
+		}
+		if label := strings.ToLower(p.ID); validDomain(label) && !strings.Contains(label, ".") {
+			names[label+"."+f.domain] = addr
+		}
+		host, _, _ := strings.Cut(strings.ToLower(p.Hostname), ".")
+		if !validDomain(host) || host == strings.ToLower(p.ID) {
+			continue
+		}
+		name := host + "." + f.domain
+		if _, ok := names[name]; ok {
+			ambiguous[name] = true
+			continue
+		}
+		names[name] = addr
+	}
+	for name := range ambiguous {
+		f.logger.Debug("dns forwarder: ambiguous hostname not resolved", "name", name)
+		delete(names, name)
+	}
+
+	f.mu.Lock()
+	f.names = names
+	f.mu.Unlock()
+}
+
+// Names returns the number of resolvable mesh names.
+func (f *DNSForwarder) Names() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.names)
+}
+
+// Start listens on the addresses the access interface has at the time of
+// the call and serves queries until ctx is cancelled or Stop is called.
+func (f *DNSForwarder) Start(ctx context.Context) error {
+	addrs, err := f.listenAddrs(f.iface)
+	if err != nil {
+		return fmt.Errorf("bridge: dns forwarder: %w", err)
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("bridge: dns forwarder: no address on %q to listen on", f.iface)
+	}
+
+	var conns []*net.UDPConn
+	for _, addr := range addrs {
+		conn, err := net.ListenUDP("udp", net.UDPAddrFromAddrPort(netip.AddrPortFrom(addr, uint16(f.port))))
+		if err != nil {
+			for _, c := range conns {
+				c.Close()
+			}
+			return fmt.Errorf("bridge: dns forwarder: listen on %s: %w", netip.AddrPortFrom(addr, uint16(f.port)), err)
+		}
+		conns = append(conns, conn)
+	}
+
+	if len(f.upstreams) == 0 {
+		f.upstreams = resolvConfNameservers(resolvConfPath, addrs)
+	}
+	if len(f.upstreams) == 0 {
+		f.logger.Warn("dns forwarder: no upstream nameservers, only mesh names are resolved")
+	}
+
+	f.mu.Lock()
+	f.conns = conns
+	f.active = true
+	f.mu.Unlock()
+
+	f.logger.Info("dns forwarder started",
+		"interface", f.iface,
+		"port", f.port,
+		"domain", f.domain,
+		"upstreams", f.upstreams,
+	)
+
+	for _, conn := range conns {
+		go f.serve(ctx, conn)
+	}
+	return nil
+}
+
+// Stop closes the listeners. It is a no-op if the forwarder is not running.
+func (f *DNSForwarder) Stop() error {
+	f.mu.Lock()
+	if !f.active {
+		f.mu.Unlock()
+		return nil
+	}
+	f.active = false
+	conns := f.conns
+	f.conns = nil
+	f.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+	f.logger.Info("dns forwarder stopped")
+	return nil
+}
+
+func (f *DNSForwarder) serve(ctx context.Context, conn *net.UDPConn) {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, dnsBufSize)
+	for {
+		n, src, err := conn.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			return // conn closed
+		}
+		select {
+		case f.sem <- struct{}{}:
+		default:
+			continue
+		}
+		query := slices.Clone(buf[:n])
+		go func() {
+			defer func() { <-f.sem }()
+			resp := f.handle(ctx, query)
+			if resp == nil {
+				return
+			}
+			if _, err := conn.WriteToUDPAddrPort(resp, src); err != nil {
+				f.logger.Debug("dns forwarder: reply failed", "client", src.String(), "error", err)
+			}
+		}()
+	}
+}
+
+// handle returns the response to query, or nil if query is not a valid
+// DNS query and is dropped.
+func (f *DNSForwarder) handle(ctx context.Context, query []byte) []byte {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(query)
+	if err != nil || hdr.Response {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return f.reply(hdr, nil, dnsmessage.RCodeFormatError, nil)
+	}
+
+	name := strings.ToLower(q.Name.String())
+	if name == f.domain || strings.HasSuffix(name, "."+f.domain) {
+		return f.answer(hdr, q, name)
+	}
+
+	resp, err := f.forward(ctx, query)
+	if err != nil {
+		f.logger.Debug("dns forwarder: upstream query failed", "name", name, "error", err)
+		return f.reply(hdr, &q, dnsmessage.RCodeServerFailure, nil)
+	}
+	return resp
+}
+
+// answer answers q for name, a name under the mesh domain.
+func (f *DNSForwarder) answer(hdr dnsmessage.Header, q dnsmessage.Question, name string) []byte {
+	f.mu.RLock()
+	addr, ok := f.names[name]
+	f.mu.RUnlock()
+	if !ok {
+		if name == f.domain {
+			return f.reply(hdr, &q, dnsmessage.RCodeSuccess, nil)
+		}
+		return f.reply(hdr, &q, dnsmessage.RCodeNameError, nil)
+	}
+	if q.Class != dnsmessage.ClassINET && q.Class != dnsmessage.ClassANY {
+		return f.reply(hdr, &q, dnsmessage.RCodeSuccess, nil)
+	}
+	switch {
+	case q.Type == dnsmessage.TypeA && addr.Is4():
+		return f.reply(hdr, &q, dnsmessage.RCodeSuccess, &dnsmessage.AResource{A: addr.As4()})
+	case q.Type == dnsmessage.TypeAAAA && addr.Is6():
+		return f.reply(hdr, &q, dnsmessage.RCodeSuccess, &dnsmessage.AAAAResource{AAAA: addr.As16()})
+	}
+	return f.reply(hdr, &q, dnsmessage.RCodeSuccess, nil)
+}
+
+// reply builds an authoritative response to the query with header hdr and
+// question q, with answer as its only answer record if not nil.
+func (f *DNSForwarder) reply(hdr dnsmessage.Header, q *dnsmessage.Question, rcode dnsmessage.RCode, answer dnsmessage.ResourceBody) []byte {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID:                 hdr.ID,
+		Response:           true,
+		OpCode:             hdr.OpCode,
+		Authoritative:      rcode != dnsmessage.RCodeFormatError && rcode != dnsmessage.RCodeServerFailure,
+		RecursionDesired:   hdr.RecursionDesired,
+		RecursionAvailable: true,
+		RCode:              rcode,
+	})
+	b.EnableCompression()
+	if q != nil {
+		if err := b.StartQuestions(); err != nil {
+			return nil
+		}
+		if err := b.Question(*q); err != nil {
+			return nil
+		}
+	}
+	if q != nil && answer != nil {
+		if err := b.StartAnswers(); err != nil {
+			return nil
+		}
+		rh := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: dnsForwardTTL}
+		var err error
+		switch r := answer.(type) {
+		case *dnsmessage.AResource:
+			err = b.AResource(rh, *r)
+		case *dnsmessage.AAAAResource:
+			err = b.AAAAResource(rh, *r)
+		}
+		if err != nil {
+			return nil
+		}
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		return nil
+	}
+	return msg
+}
+
+// forward sends query to the upstream nameservers in order and returns the
+// first response.
+func (f *DNSForwarder) forward(ctx context.Context, query []byte) ([]byte, error) {
+	if len(f.upstreams) == 0 {
+		return nil, errors.New("no upstream nameservers")
+	}
+	var errs []error
+	for _, upstream := range f.upstreams {
+		resp, err := exchangeDNS(ctx, dnsUpstreamAddr(upstream), query)
+		if err == nil {
+			return resp, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", upstream, err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// exchangeDNS sends query to server over UDP and returns the response with
+// the query's ID.
+func exchangeDNS(ctx context.Context, server string, query []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsForwardTimeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, dnsBufSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// Stray responses, such as late answers to an earlier query on a
+		// reused port, are skipped.
+		if n >= 2 && buf[0] == query[0] && buf[1] == query[1] {
+			return buf[:n], nil
+		}
+	}
+}
+
+// dnsUpstreamAddr returns upstream as host:port, adding port 53 to a bare
+// address.
+func dnsUpstreamAddr(upstream string) string {
+	if ap, err := netip.ParseAddrPort(upstream); err == nil {
+		return ap.String()
+	}
+	return net.JoinHostPort(upstream, "53")
+}
+
+// validDNSUpstream reports whether upstream is an IP address with an
+// optional port.
+func validDNSUpstream(upstream string) bool {
+	if _, err := netip.ParseAddrPort(upstream); err == nil {
+		return true
+	}
+	_, err := netip.ParseAddr(upstream)
+	return err == nil
+}
+
+// resolvConfNameservers returns the nameservers of the resolver
+// configuration at path, except the addresses in exclude, which would make
+// the forwarder query itself. A missing file yields none.
+func resolvConfNameservers(path string, exclude []netip.Addr) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+		addr, err := netip.ParseAddr(fields[1])
+		if err != nil || addr.Zone() != "" || slices.Contains(exclude, addr) {
+			continue
+		}
+		servers = append(servers, addr.String())
+	}
+	return servers
+}
+
+// interfaceListenAddrs returns the addresses of iface that the forwarder
+// listens on: all but link-local addresses, which LAN devices do not use
+// as a nameserver.
+func interfaceListenAddrs(iface string) ([]netip.Addr, error) {
+	ifc, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, fmt.Errorf("interface %q: %w", iface, err)
+	}
+	ifAddrs, err := ifc.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("interface %q: %w", iface, err)
+	}
+	var addrs []netip.Addr
+	for _, a := range ifAddrs {
+		ipn, ok := a.(*net.IPNet)
+		if !ok || ipn.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if addr, ok := netip.AddrFromSlice(ipn.IP); ok {
+			addrs = append(addrs, addr.Unmap())
+		}
+	}
+	return addrs, nil
+}
@@ -0,0 +1,266 @@
+package bridge
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+func dnsQuery(t *testing.T, name string, typ dnsmessage.Type) []byte {
+	t.Helper()
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 0x1234, RecursionDesired: true})
+	if err := b.StartQuestions(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Question(dnsmessage.Question{
+		Name:  dnsmessage.MustNewName(name),
+		Type:  typ,
+		Class: dnsmessage.ClassINET,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func parseDNSResponse(t *testing.T, resp []byte) dnsmessage.Message {
+	t.Helper()
+	var msg dnsmessage.Message
+	if err := msg.Unpack(resp); err != nil {
+		t.Fatalf("unpack response: %v", err)
+	}
+	if !msg.Header.Response || msg.Header.ID != 0x1234 {
+		t.Fatalf("header = %+v, want response with ID 0x1234", msg.Header)
+	}
+	return msg
+}
+
+// fakeUpstream answers every query with an A record of 192.0.2.1.
+func fakeUpstream(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, dnsBufSize)
+		for {
+			n, src, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			var msg dnsmessage.Message
+			if err := msg.Unpack(buf[:n]); err != nil {
+				continue
+			}
+			msg.Header.Response = true
+			msg.Answers = []dnsmessage.Resource{{
+				Header: dnsmessage.ResourceHeader{Name: msg.Questions[0].Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 300},
+				Body:   &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}},
+			}}
+			resp, err := msg.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteToUDP(resp, src)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestDNSForwarder_SetPeers(t *testing.T) {
+	f := NewDNSForwarder("eth1", 53, "Mesh.", nil, discardLogger())
+	f.SetPeers([]api.Peer{
+		{ID: "peer-a", MeshIP: "10.0.0.2", Hostname: "DB-1.example.com"},
+		{ID: "peer-b", MeshIP: "10.0.0.3", Hostname: "web"},
+		{ID: "peer-c", MeshIP: "10.0.0.4", Hostname: "web"},
+		{ID: "peer-d", MeshIP: "not-an-ip", Hostname: "broken"},
+		{ID: "peer-e", MeshIP: "fd00::5", Hostname: "bad_name"},
+	})
+
+	var names []string
+	for name := range f.names {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	want := []string{"db-1.mesh.", "peer-a.mesh.", "peer-b.mesh.", "peer-c.mesh.", "peer-e.mesh."}
+	if !slices.Equal(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+	if f.Names() != len(want) {
+		t.Errorf("Names() = %d, want %d", f.Names(), len(want))
+	}
+}
+
+func TestDNSForwarder_MeshNames(t *testing.T) {
+	f := NewDNSForwarder("eth1", 53, "mesh", nil, discardLogger())
+	f.SetPeers([]api.Peer{
+		{ID: "peer-a", MeshIP: "10.0.0.2", Hostname: "db-1"},
+		{ID: "peer-b", MeshIP: "fd00::3"},
+	})
+
+	tests := []struct {
+		name      string
+		qname     string
+		qtype     dnsmessage.Type
+		wantRCode dnsmessage.RCode
+		wantAddr  string
+	}{
+		{"hostname", "DB-1.mesh.", dnsmessage.TypeA, dnsmessage.RCodeSuccess, "10.0.0.2"},
+		{"peer ID", "peer-a.mesh.", dnsmessage.TypeA, dnsmessage.RCodeSuccess, "10.0.0.2"},
+		{"IPv6 mesh IP", "peer-b.mesh.", dnsmessage.TypeAAAA, dnsmessage.RCodeSuccess, "fd00::3"},
+		{"no record of type", "db-1.mesh.", dnsmessage.TypeAAAA, dnsmessage.RCodeSuccess, ""},
+		{"unknown name", "nope.mesh.", dnsmessage.TypeA, dnsmessage.RCodeNameError, ""},
+		{"domain apex", "mesh.", dnsmessage.TypeA, dnsmessage.RCodeSuccess, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := parseDNSResponse(t, f.handle(context.Background(), dnsQuery(t, tt.qname, tt.qtype)))
+			if msg.Header.RCode != tt.wantRCode || !msg.Header.Authoritative {
+				t.Fatalf("header = %+v, want authoritative %v", msg.Header, tt.wantRCode)
+			}
+			if tt.wantAddr == "" {
+				if len(msg.Answers) != 0 {
+					t.Errorf("answers = %+v, want none", msg.Answers)
+				}
+				return
+			}
+			if len(msg.Answers) != 1 {
+				t.Fatalf("answers = %+v, want one", msg.Answers)
+			}
+			var got netip.Addr
+			switch r := msg.Answers[0].Body.(type) {
+			case *dnsmessage.AResource:
+				got = netip.AddrFrom4(r.A)
+			case *dnsmessage.AAAAResource:
+				got = netip.AddrFrom16(r.AAAA)
+			}
+			if got.String() != tt.wantAddr || msg.Answers[0].Header.TTL != dnsForwardTTL {
+				t.Errorf("answer = %v (TTL %d), want %s", got, msg.Answers[0].Header.TTL, tt.wantAddr)
+			}
+		})
+	}
+}
+
+func TestDNSForwarder_Forward(t *testing.T) {
+	f := NewDNSForwarder("eth1", 53, "mesh", []string{fakeUpstream(t)}, discardLogger())
+	msg := parseDNSResponse(t, f.handle(context.Background(), dnsQuery(t, "example.com.", dnsmessage.TypeA)))
+	if len(msg.Answers) != 1 {
+		t.Fatalf("answers = %+v, want the upstream answer", msg.Answers)
+	}
+	if a, ok := msg.Answers[0].Body.(*dnsmessage.AResource); !ok || a.A != [4]byte{192, 0, 2, 1} {
+		t.Errorf("answer = %+v, want 192.0.2.1", msg.Answers[0].Body)
+	}
+}
+
+func TestDNSForwarder_ForwardFailure(t *testing.T) {
+	f := NewDNSForwarder("eth1", 53, "mesh", nil, discardLogger())
+	msg := parseDNSResponse(t, f.handle(context.Background(), dnsQuery(t, "example.com.", dnsmessage.TypeA)))
+	if msg.Header.RCode != dnsmessage.RCodeServerFailure {
+		t.Errorf("RCode = %v, want %v", msg.Header.RCode, dnsmessage.RCodeServerFailure)
+	}
+
+	if resp := f.handle(context.Background(), []byte{0x12}); resp != nil {
+		t.Errorf("handle(garbage) = %x, want nil", resp)
+	}
+}
+
+func TestDNSForwarder_StartStop(t *testing.T) {
+	f := NewDNSForwarder("eth1", 0, "mesh", []string{fakeUpstream(t)}, discardLogger())
+	f.listenAddrs = func(string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("127.0.0.1")}, nil
+	}
+	f.SetPeers([]api.Peer{{ID: "peer-a", MeshIP: "10.0.0.2"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := f.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	addr := f.conns[0].LocalAddr().String()
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	for _, qname := range []string{"peer-a.mesh.", "example.com."} {
+		if _, err := conn.Write(dnsQuery(t, qname, dnsmessage.TypeA)); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, dnsBufSize)
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("%s: %v", qname, err)
+		}
+		if msg := parseDNSResponse(t, buf[:n]); len(msg.Answers) != 1 {
+			t.Errorf("%s: answers = %+v, want one", qname, msg.Answers)
+		}
+	}
+
+	if err := f.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Stop(); err != nil {
+		t.Errorf("second Stop() = %v", err)
+	}
+}
+
+func TestDNSForwarder_StartNoAddress(t *testing.T) {
+	f := NewDNSForwarder("eth1", 0, "mesh", nil, discardLogger())
+	f.listenAddrs = func(string) ([]netip.Addr, error) { return nil, nil }
+	if err := f.Start(context.Background()); err == nil {
+		t.Fatal("Start() = nil, want error")
+	}
+}
+
+func TestResolvConfNameservers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	conf := "# generated\nsearch example.com\nnameserver 192.168.1.1\nnameserver 10.0.0.1\nnameserver fe80::1%eth0\nnameserver 2001:db8::53\n"
+	if err := os.WriteFile(path, []byte(conf), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := resolvConfNameservers(path, []netip.Addr{netip.MustParseAddr("10.0.0.1")})
+	want := []string{"192.168.1.1", "2001:db8::53"}
+	if !slices.Equal(got, want) {
+		t.Errorf("resolvConfNameservers() = %v, want %v", got, want)
+	}
+	if got := resolvConfNameservers(filepath.Join(t.TempDir(), "missing"), nil); got != nil {
+		t.Errorf("resolvConfNameservers(missing) = %v, want nil", got)
+	}
+}
+
+func TestDNSUpstreamAddr(t *testing.T) {
+	tests := map[string]string{
+		"192.168.1.1":      "192.168.1.1:53",
+		"192.168.1.1:5353": "192.168.1.1:5353",
+		"2001:db8::53":     "[2001:db8::53]:53",
+		"[2001:db8::53]:5": "[2001:db8::53]:5",
+	}
+	for in, want := range tests {
+		if !validDNSUpstream(in) {
+			t.Errorf("validDNSUpstream(%q) = false", in)
+		}
+		if got := dnsUpstreamAddr(in); got != want {
+			t.Errorf("dnsUpstreamAddr(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if validDNSUpstream("dns.example.com") {
+		t.Error("validDNSUpstream(hostname) = true, want false")
+	}
+}
@@ -0,0 +1,91 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// Drift correction types reported by Manager.CheckDrift.
+const (
+	// DriftForwardingRestored is forwarding, or its policy routing rule,
+	// re-enabled on the bridge interfaces.
+	DriftForwardingRestored = "forwarding_restored"
+	// DriftRouteRestored is an access subnet route that was missing.
+	DriftRouteRestored = "route_restored"
+)
+
+// CheckDrift compares the kernel's forwarding settings and routes with the
+// state applied by Setup and UpdateRoutes and restores what was changed
+// outside plexd. It returns one correction per repair. It is a no-op when
+// bridge mode is inactive or the controller does not implement
+// RouteInspector.
+func (m *Manager) CheckDrift() ([]api.DriftCorrection, error) {
+	inspector, ok := m.ctrl.(RouteInspector)
+	if !m.active || !ok {
+		return nil, nil
+	}
+
+	var corrections []api.DriftCorrection
+	var errs []error
+
+	// EnableForwarding covers both interfaces, so check both before
+	// repairing once.
+	var disabled []string
+	for _, iface := range []string{m.meshIface, m.cfg.AccessInterface} {
+		enabled, err := inspector.ForwardingEnabled(iface)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !enabled {
+			disabled = append(disabled, iface)
+		}
+	}
+	if len(disabled) > 0 {
+		if err := m.ctrl.EnableForwarding(m.meshIface, m.cfg.AccessInterface); err != nil {
+			errs = append(errs, fmt.Errorf("bridge: check drift: %w", err))
+		} else {
+			for _, iface := range disabled {
+				m.logger.Warn("external forwarding change repaired",
+					"component", "bridge",
+					"interface", iface,
+				)
+				corrections = append(corrections, api.DriftCorrection{
+					Type:   DriftForwardingRestored,
+					Detail: fmt.Sprintf("interface %s", iface),
+				})
+			}
+		}
+	}
+
+	subnets := subnetsFromSet(m.activeRoutes)
+	slices.Sort(subnets)
+	for _, subnet := range subnets {
+		installed, err := inspector.RouteInstalled(subnet, m.cfg.AccessInterface)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if installed {
+			continue
+		}
+		if err := m.ctrl.AddRoute(subnet, m.cfg.AccessInterface); err != nil {
+			errs = append(errs, fmt.Errorf("bridge: check drift: %w", err))
+			continue
+		}
+		m.logger.Warn("external route change repaired",
+			"component", "bridge",
+			"subnet", subnet,
+			"interface", m.cfg.AccessInterface,
+		)
+		corrections = append(corrections, api.DriftCorrection{
+			Type:   DriftRouteRestored,
+			Detail: fmt.Sprintf("route %s via %s", subnet, m.cfg.AccessInterface),
+		})
+	}
+
+	return corrections, errors.Join(errs...)
+}
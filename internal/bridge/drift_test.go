@@ -0,0 +1,102 @@
+package bridge
+
+import (
+	"errors"
+	"testing"
+)
+
+// inspectingRouteController is a mockRouteController with the
+// RouteInspector capability.
+type inspectingRouteController struct {
+	mockRouteController
+	missingRoutes map[string]bool
+	noForwarding  map[string]bool
+	inspectErr    error
+}
+
+func (m *inspectingRouteController) RouteInstalled(subnet, iface string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return !m.missingRoutes[subnet], m.inspectErr
+}
+
+func (m *inspectingRouteController) ForwardingEnabled(iface string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return !m.noForwarding[iface], m.inspectErr
+}
+
+func newDriftTestManager(t *testing.T, ctrl RouteController) *Manager {
+	t.Helper()
+	mgr := NewManager(ctrl, Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24", "192.168.1.0/24"},
+		EnableNAT:       BoolPtr(false),
+	}, discardLogger())
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	return mgr
+}
+
+func TestManager_CheckDrift_InSync(t *testing.T) {
+	ctrl := &inspectingRouteController{}
+	mgr := newDriftTestManager(t, ctrl)
+	ctrl.calls = nil
+
+	corrections, err := mgr.CheckDrift()
+	if err != nil {
+		t.Fatalf("CheckDrift: %v", err)
+	}
+	if len(corrections) != 0 || len(ctrl.calls) != 0 {
+		t.Errorf("corrections = %+v, calls = %+v; want none", corrections, ctrl.calls)
+	}
+}
+
+func TestManager_CheckDrift_Repairs(t *testing.T) {
+	ctrl := &inspectingRouteController{}
+	mgr := newDriftTestManager(t, ctrl)
+	ctrl.calls = nil
+	ctrl.missingRoutes = map[string]bool{"192.168.1.0/24": true}
+	ctrl.noForwarding = map[string]bool{"wg0": true, "eth1": true}
+
+	corrections, err := mgr.CheckDrift()
+	if err != nil {
+		t.Fatalf("CheckDrift: %v", err)
+	}
+	want := []string{DriftForwardingRestored, DriftForwardingRestored, DriftRouteRestored}
+	if len(corrections) != len(want) {
+		t.Fatalf("corrections = %+v, want types %v", corrections, want)
+	}
+	for i, c := range corrections {
+		if c.Type != want[i] {
+			t.Errorf("corrections[%d].Type = %q, want %q", i, c.Type, want[i])
+		}
+	}
+	if n := len(ctrl.callsFor("EnableForwarding")); n != 1 {
+		t.Errorf("EnableForwarding calls = %d, want 1", n)
+	}
+	adds := ctrl.callsFor("AddRoute")
+	if len(adds) != 1 || adds[0].Args[0] != "192.168.1.0/24" || adds[0].Args[1] != "eth1" {
+		t.Errorf("AddRoute calls = %+v, want 192.168.1.0/24 via eth1", adds)
+	}
+}
+
+func TestManager_CheckDrift_InspectError(t *testing.T) {
+	ctrl := &inspectingRouteController{inspectErr: errors.New("netlink: permission denied")}
+	mgr := newDriftTestManager(t, ctrl)
+
+	if _, err := mgr.CheckDrift(); err == nil {
+		t.Error("CheckDrift() = nil error, want inspect error")
+	}
+}
+
+func TestManager_CheckDrift_NoInspector(t *testing.T) {
+	mgr := newDriftTestManager(t, &mockRouteController{})
+
+	corrections, err := mgr.CheckDrift()
+	if err != nil || corrections != nil {
+		t.Errorf("CheckDrift() = %+v, %v; want nil, nil", corrections, err)
+	}
+}
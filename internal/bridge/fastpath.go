@@ -0,0 +1,64 @@
+package bridge
+
+import (
+	"log/slog"
+	"net"
+)
+
+const (
+	// FastPathOff forwards all traffic on the userspace and standard kernel
+	// forwarding paths. It is the default.
+	FastPathOff = "off"
+
+	// FastPathAuto offloads forwarded flows and relay sessions to an
+	// in-kernel fast path when the kernel supports one, and falls back to
+	// the userspace path otherwise.
+	FastPathAuto = "auto"
+)
+
+// FastPath offloads per-packet work to the kernel on high-throughput bridge
+// nodes. Offloads are best effort: callers keep the userspace path running
+// and fall back to it when an offload fails.
+// Implementations must be concurrent-safe.
+type FastPath interface {
+	// Name identifies the implementation for logs and capabilities.
+	Name() string
+
+	// OffloadForwarding moves established flows forwarded between meshIface
+	// and accessIface to the fast path. Idempotent.
+	OffloadForwarding(meshIface, accessIface string) error
+
+	// RemoveForwardingOffload returns forwarded flows to the standard
+	// forwarding path. Idempotent.
+	RemoveForwardingOffload() error
+
+	// OffloadRelaySession forwards the datagrams that peerA and peerB send
+	// to the relay's listenPort directly to each other in the kernel, so
+	// they no longer reach the relay socket. Idempotent per sessionID.
+	OffloadRelaySession(sessionID string, listenPort int, peerA, peerB *net.UDPAddr) error
+
+	// RemoveRelaySession returns a session's datagrams to the relay socket.
+	// Removing an unknown session returns nil.
+	RemoveRelaySession(sessionID string) error
+
+	// Close removes all offloads. Idempotent.
+	Close() error
+}
+
+// probeFastPath returns the kernel fast path, or nil when the kernel does not
+// support it. The reason for a fallback is logged.
+func probeFastPath(logger *slog.Logger) FastPath {
+	fp, err := newFastPath(logger)
+	if err != nil {
+		logger.Warn("bridge: kernel fast path unavailable, using userspace path",
+			"component", "bridge",
+			"error", err,
+		)
+		return nil
+	}
+	logger.Info("bridge kernel fast path enabled",
+		"component", "bridge",
+		"fast_path", fp.Name(),
+	)
+	return fp
+}
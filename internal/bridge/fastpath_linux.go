@@ -0,0 +1,433 @@
+//go:build linux
+
+package bridge
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// Names of the nftables objects owned by the fast path.
+const (
+	fastPathTableName      = "plexd-fastpath"
+	fastPathFlowtableName  = "forward"
+	fastPathForwardChain   = "forward"
+	fastPathRelayDNATChain = "relay-prerouting"
+	fastPathRelaySNATChain = "relay-postrouting"
+)
+
+// ipsDstNAT is the conntrack status bit set on destination-NATed flows.
+const ipsDstNAT = 1 << 5
+
+// ipForwardPath is the sysctl that must be enabled for relay offloads,
+// because offloaded relay datagrams are forwarded rather than delivered.
+const ipForwardPath = "/proc/sys/net/ipv4/ip_forward"
+
+// nftFastPath implements FastPath with the kernel's netfilter fast paths: a
+// software flowtable for bridge forwarding, which lets established flows
+// bypass the forwarding chains and routing lookups, and per-session NAT rules
+// that forward relay datagrams without a round trip through the relay socket.
+// Only IPv4 is offloaded.
+type nftFastPath struct {
+	logger *slog.Logger
+
+	mu         sync.Mutex
+	forwarding []string // offloaded devices; nil when not offloaded
+	sessions   map[string]relayOffload
+}
+
+// relayOffload records an offloaded relay session for conntrack cleanup.
+type relayOffload struct {
+	listenPort   int
+	peerA, peerB *net.UDPAddr
+}
+
+// newFastPath creates the fast path table, replacing any table left behind by
+// a previous run. It fails when the kernel lacks nftables NAT support.
+func newFastPath(logger *slog.Logger) (FastPath, error) {
+	conn, err := nftables.New()
+	if err != nil {
+		return nil, fmt.Errorf("bridge: fast path: %w", err)
+	}
+	if t, err := conn.ListTableOfFamily(fastPathTableName, nftables.TableFamilyIPv4); err == nil {
+		conn.DelTable(t)
+	}
+	table := fastPathTable(conn)
+	fastPathRelayChains(conn, table)
+	if err := conn.Flush(); err != nil {
+		return nil, fmt.Errorf("bridge: fast path: create table: %w", err)
+	}
+	return &nftFastPath{
+		logger:   logger,
+		sessions: make(map[string]relayOffload),
+	}, nil
+}
+
+// Name returns "nftables".
+func (f *nftFastPath) Name() string {
+	return "nftables"
+}
+
+// fastPathTable adds the fast path table to the batch.
+func fastPathTable(conn *nftables.Conn) *nftables.Table {
+	return conn.AddTable(&nftables.Table{
+		Family: nftables.TableFamilyIPv4,
+		Name:   fastPathTableName,
+	})
+}
+
+// fastPathRelayChains adds the relay NAT chains to the batch.
+func fastPathRelayChains(conn *nftables.Conn, table *nftables.Table) (dnat, snat *nftables.Chain) {
+	dnat = conn.AddChain(&nftables.Chain{
+		Name:     fastPathRelayDNATChain,
+		Table:    table,
+		Type:     nftables.ChainTypeNAT,
+		Hooknum:  nftables.ChainHookPrerouting,
+		Priority: nftables.ChainPriorityNATDest,
+	})
+	snat = conn.AddChain(&nftables.Chain{
+		Name:     fastPathRelaySNATChain,
+		Table:    table,
+		Type:     nftables.ChainTypeNAT,
+		Hooknum:  nftables.ChainHookPostrouting,
+		Priority: nftables.ChainPriorityNATSource,
+	})
+	return dnat, snat
+}
+
+// OffloadForwarding adds a flowtable for meshIface and accessIface and a
+// forward chain rule that moves TCP and UDP flows into it.
+// nft equivalent:
+//
+//	flowtable forward { hook ingress priority filter; devices = { wg0, eth1 } }
+//	chain forward { type filter hook forward priority filter; meta l4proto { tcp, udp } flow add @forward }
+func (f *nftFastPath) OffloadForwarding(meshIface, accessIface string) error {
+	devices := []string{meshIface, accessIface}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if slices.Equal(f.forwarding, devices) {
+		return nil
+	}
+
+	conn, err := nftables.New()
+	if err != nil {
+		return fmt.Errorf("bridge: fast path: offload forwarding: %w", err)
+	}
+	table := fastPathTable(conn)
+	if f.forwarding != nil {
+		f.delForwarding(conn, table)
+	}
+	ft := conn.AddFlowtable(&nftables.Flowtable{
+		Table:    table,
+		Name:     fastPathFlowtableName,
+		Hooknum:  nftables.FlowtableHookIngress,
+		Priority: nftables.FlowtablePriorityFilter,
+		Devices:  devices,
+	})
+	chain := conn.AddChain(&nftables.Chain{
+		Name:     fastPathForwardChain,
+		Table:    table,
+		Type:     nftables.ChainTypeFilter,
+		Hooknum:  nftables.ChainHookForward,
+		Priority: nftables.ChainPriorityFilter,
+	})
+	for _, proto := range []byte{unix.IPPROTO_TCP, unix.IPPROTO_UDP} {
+		conn.AddRule(&nftables.Rule{
+			Table: table,
+			Chain: chain,
+			Exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{proto}},
+				&expr.FlowOffload{Name: ft.Name},
+			},
+		})
+	}
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("bridge: fast path: offload forwarding for %s: %w", strings.Join(devices, ", "), err)
+	}
+	f.forwarding = devices
+
+	f.logger.Debug("forwarding offloaded to flowtable",
+		"component", "bridge",
+		"mesh_iface", meshIface,
+		"access_iface", accessIface,
+	)
+	return nil
+}
+
+// RemoveForwardingOffload deletes the forward chain and the flowtable.
+func (f *nftFastPath) RemoveForwardingOffload() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.forwarding == nil {
+		return nil
+	}
+
+	conn, err := nftables.New()
+	if err != nil {
+		return fmt.Errorf("bridge: fast path: remove forwarding offload: %w", err)
+	}
+	f.delForwarding(conn, fastPathTable(conn))
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("bridge: fast path: remove forwarding offload: %w", err)
+	}
+	f.forwarding = nil
+	return nil
+}
+
+// delForwarding adds the deletion of the forward chain and the flowtable to
+// the batch. The chain goes first because its rules reference the flowtable.
+func (f *nftFastPath) delForwarding(conn *nftables.Conn, table *nftables.Table) {
+	conn.DelChain(&nftables.Chain{Name: fastPathForwardChain, Table: table})
+	conn.DelFlowtable(&nftables.Flowtable{Name: fastPathFlowtableName, Table: table})
+}
+
+// OffloadRelaySession adds NAT rules that forward the session's datagrams
+// between the peers in the kernel. Each direction gets a DNAT rule to the
+// other peer and an SNAT rule that keeps the relay address and port as the
+// source, so the peers see the same endpoint as on the userspace path. Conntrack
+// entries of the session's userspace flows are flushed, so that the next
+// datagram is matched by the new rules.
+func (f *nftFastPath) OffloadRelaySession(sessionID string, listenPort int, peerA, peerB *net.UDPAddr) error {
+	if peerA.IP.To4() == nil || peerB.IP.To4() == nil {
+		return fmt.Errorf("bridge: fast path: offload relay session %s: only IPv4 peers are supported", sessionID)
+	}
+	if listenPort < 1 || listenPort > 65535 {
+		return fmt.Errorf("bridge: fast path: offload relay session %s: listen port %d out of range", sessionID, listenPort)
+	}
+	if data, err := os.ReadFile(ipForwardPath); err != nil || strings.TrimSpace(string(data)) != "1" {
+		return fmt.Errorf("bridge: fast path: offload relay session %s: IPv4 forwarding is disabled", sessionID)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.sessions[sessionID]; ok {
+		return nil
+	}
+
+	conn, err := nftables.New()
+	if err != nil {
+		return fmt.Errorf("bridge: fast path: offload relay session %s: %w", sessionID, err)
+	}
+	table := fastPathTable(conn)
+	dnat, snat := fastPathRelayChains(conn, table)
+	userData := relayUserData(sessionID)
+	for _, dir := range [][2]*net.UDPAddr{{peerA, peerB}, {peerB, peerA}} {
+		from, to := dir[0], dir[1]
+		conn.AddRule(&nftables.Rule{
+			Table:    table,
+			Chain:    dnat,
+			Exprs:    relayDNATExprs(from, listenPort, to),
+			UserData: userData,
+		})
+		conn.AddRule(&nftables.Rule{
+			Table:    table,
+			Chain:    snat,
+			Exprs:    relaySNATExprs(from, to, listenPort),
+			UserData: userData,
+		})
+	}
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("bridge: fast path: offload relay session %s: %w", sessionID, err)
+	}
+
+	off := relayOffload{listenPort: listenPort, peerA: peerA, peerB: peerB}
+	f.sessions[sessionID] = off
+	f.flushRelayConntrack(sessionID, off)
+
+	f.logger.Debug("relay session offloaded",
+		"component", "bridge",
+		"session_id", sessionID,
+		"peer_a", peerA.String(),
+		"peer_b", peerB.String(),
+	)
+	return nil
+}
+
+// RemoveRelaySession deletes the session's NAT rules and flushes its
+// conntrack entries, so the peers' next datagrams reach the relay socket.
+func (f *nftFastPath) RemoveRelaySession(sessionID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	off, ok := f.sessions[sessionID]
+	if !ok {
+		return nil
+	}
+
+	conn, err := nftables.New()
+	if err != nil {
+		return fmt.Errorf("bridge: fast path: remove relay session %s: %w", sessionID, err)
+	}
+	table := &nftables.Table{Family: nftables.TableFamilyIPv4, Name: fastPathTableName}
+	userData := relayUserData(sessionID)
+	for _, name := range []string{fastPathRelayDNATChain, fastPathRelaySNATChain} {
+		rules, err := conn.GetRules(table, &nftables.Chain{Name: name, Table: table})
+		if err != nil {
+			return fmt.Errorf("bridge: fast path: remove relay session %s: list rules: %w", sessionID, err)
+		}
+		for _, r := range rules {
+			if string(r.UserData) == string(userData) {
+				if err := conn.DelRule(r); err != nil {
+					return fmt.Errorf("bridge: fast path: remove relay session %s: %w", sessionID, err)
+				}
+			}
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("bridge: fast path: remove relay session %s: %w", sessionID, err)
+	}
+
+	delete(f.sessions, sessionID)
+	f.flushRelayConntrack(sessionID, off)
+	return nil
+}
+
+// Close deletes the fast path table with all offloads.
+func (f *nftFastPath) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	conn, err := nftables.New()
+	if err != nil {
+		return fmt.Errorf("bridge: fast path: close: %w", err)
+	}
+	tables, err := conn.ListTablesOfFamily(nftables.TableFamilyIPv4)
+	if err != nil {
+		return fmt.Errorf("bridge: fast path: close: list tables: %w", err)
+	}
+	for _, t := range tables {
+		if t.Name == fastPathTableName {
+			conn.DelTable(t)
+			if err := conn.Flush(); err != nil {
+				return fmt.Errorf("bridge: fast path: close: %w", err)
+			}
+		}
+	}
+
+	sessions := f.sessions
+	f.sessions = make(map[string]relayOffload)
+	f.forwarding = nil
+	for id, off := range sessions {
+		f.flushRelayConntrack(id, off)
+	}
+	return nil
+}
+
+// flushRelayConntrack deletes the conntrack entries of the datagrams each
+// peer sends to the relay port. Failures are logged: a stale entry only
+// delays the switch between the paths until it expires.
+func (f *nftFastPath) flushRelayConntrack(sessionID string, off relayOffload) {
+	var filters []netlink.CustomConntrackFilter
+	for _, peer := range []*net.UDPAddr{off.peerA, off.peerB} {
+		filter := &netlink.ConntrackFilter{}
+		err := errors.Join(
+			filter.AddProtocol(unix.IPPROTO_UDP),
+			filter.AddIP(netlink.ConntrackOrigSrcIP, peer.IP),
+			filter.AddPort(netlink.ConntrackOrigSrcPort, uint16(peer.Port)),
+			filter.AddPort(netlink.ConntrackOrigDstPort, uint16(off.listenPort)),
+		)
+		if err != nil {
+			f.logger.Warn("bridge: fast path: build conntrack filter failed",
+				"component", "bridge",
+				"session_id", sessionID,
+				"error", err,
+			)
+			return
+		}
+		filters = append(filters, filter)
+	}
+	if _, err := netlink.ConntrackDeleteFilters(netlink.ConntrackTable, unix.AF_INET, filters...); err != nil {
+		f.logger.Warn("bridge: fast path: flush relay conntrack failed",
+			"component", "bridge",
+			"session_id", sessionID,
+			"error", err,
+		)
+	}
+}
+
+// relayUserData tags the NAT rules of a relay session.
+func relayUserData(sessionID string) []byte {
+	return []byte("relay:" + sessionID)
+}
+
+// relayMatchExprs matches UDP datagrams from the given source address and
+// port, loading header fields into register 1.
+func relayMatchExprs(from *net.UDPAddr) []expr.Any {
+	return []expr.Any{
+		&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_UDP}},
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 12, Len: 4},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: from.IP.To4()},
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 0, Len: 2},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: portBytes(from.Port)},
+	}
+}
+
+// relayDNATExprs builds a prerouting rule that redirects datagrams from
+// `from` to the relay port towards `to`.
+// nft equivalent: ip saddr A udp sport pA udp dport L dnat to B:pB
+func relayDNATExprs(from *net.UDPAddr, listenPort int, to *net.UDPAddr) []expr.Any {
+	return append(relayMatchExprs(from),
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 2, Len: 2},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: portBytes(listenPort)},
+		&expr.Counter{},
+		&expr.Immediate{Register: 1, Data: to.IP.To4()},
+		&expr.Immediate{Register: 2, Data: portBytes(to.Port)},
+		&expr.NAT{
+			Type:        expr.NATTypeDestNAT,
+			Family:      unix.NFPROTO_IPV4,
+			RegAddrMin:  1,
+			RegProtoMin: 2,
+			Specified:   true,
+		},
+	)
+}
+
+// relaySNATExprs builds a postrouting rule that gives redirected datagrams
+// the relay address the sender used and the relay port as their source.
+// nft equivalent: ip saddr A udp sport pA ip daddr B udp dport pB ct status dnat snat to ct original ip daddr : L
+func relaySNATExprs(from, to *net.UDPAddr, listenPort int) []expr.Any {
+	return append(relayMatchExprs(from),
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 16, Len: 4},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: to.IP.To4()},
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 2, Len: 2},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: portBytes(to.Port)},
+		&expr.Ct{Key: expr.CtKeySTATUS, Register: 1},
+		&expr.Bitwise{
+			SourceRegister: 1,
+			DestRegister:   1,
+			Len:            4,
+			Mask:           binary.NativeEndian.AppendUint32(nil, ipsDstNAT),
+			Xor:            make([]byte, 4),
+		},
+		&expr.Cmp{Op: expr.CmpOpNeq, Register: 1, Data: make([]byte, 4)},
+		&expr.Counter{},
+		&expr.Ct{Key: expr.CtKeyDST, Register: 1, Direction: 0},
+		&expr.Immediate{Register: 2, Data: portBytes(listenPort)},
+		&expr.NAT{
+			Type:        expr.NATTypeSourceNAT,
+			Family:      unix.NFPROTO_IPV4,
+			RegAddrMin:  1,
+			RegProtoMin: 2,
+			Specified:   true,
+		},
+	)
+}
+
+// portBytes encodes a port in network byte order.
+func portBytes(port int) []byte {
+	return binary.BigEndian.AppendUint16(nil, uint16(port))
+}
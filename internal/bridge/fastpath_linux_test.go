@@ -0,0 +1,108 @@
+//go:build linux
+
+package bridge
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/nftables/expr"
+)
+
+// Compile-time check that nftFastPath implements FastPath.
+var _ FastPath = (*nftFastPath)(nil)
+
+func TestNftFastPathRelaySessionRoundTrip(t *testing.T) {
+	fp, err := newFastPath(discardLoggerRoute())
+	if err != nil {
+		t.Skipf("skipping: requires elevated privileges: %v", err)
+	}
+	defer fp.Close()
+
+	a := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 51820}
+	b := &net.UDPAddr{IP: net.ParseIP("198.51.100.2"), Port: 51821}
+	err = fp.OffloadRelaySession("s1", 3478, a, b)
+	if data, _ := os.ReadFile(ipForwardPath); strings.TrimSpace(string(data)) != "1" {
+		if err == nil || !strings.Contains(err.Error(), "IPv4 forwarding is disabled") {
+			t.Fatalf("OffloadRelaySession without forwarding = %v, want forwarding error", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("OffloadRelaySession: %v", err)
+	}
+	// Offloading again is a no-op.
+	if err := fp.OffloadRelaySession("s1", 3478, a, b); err != nil {
+		t.Fatalf("second OffloadRelaySession: %v", err)
+	}
+	if err := fp.RemoveRelaySession("s1"); err != nil {
+		t.Fatalf("RemoveRelaySession: %v", err)
+	}
+	if err := fp.RemoveRelaySession("s1"); err != nil {
+		t.Fatalf("second RemoveRelaySession: %v", err)
+	}
+}
+
+func TestNftFastPathClose_Idempotent(t *testing.T) {
+	fp, err := newFastPath(discardLoggerRoute())
+	if err != nil {
+		t.Skipf("skipping: requires elevated privileges: %v", err)
+	}
+	if err := fp.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := fp.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+}
+
+func TestNftFastPathOffloadRelaySession_RejectsIPv6(t *testing.T) {
+	fp := &nftFastPath{logger: discardLoggerRoute(), sessions: make(map[string]relayOffload)}
+	a := &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 51820}
+	b := &net.UDPAddr{IP: net.ParseIP("198.51.100.2"), Port: 51821}
+	err := fp.OffloadRelaySession("s1", 3478, a, b)
+	if err == nil || !strings.Contains(err.Error(), "only IPv4 peers are supported") {
+		t.Errorf("OffloadRelaySession = %v, want IPv4 error", err)
+	}
+}
+
+func TestRelayDNATExprs(t *testing.T) {
+	from := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 51820}
+	to := &net.UDPAddr{IP: net.ParseIP("198.51.100.2"), Port: 51821}
+
+	exprs := relayDNATExprs(from, 3478, to)
+	nat, ok := exprs[len(exprs)-1].(*expr.NAT)
+	if !ok || nat.Type != expr.NATTypeDestNAT {
+		t.Fatalf("last expression = %#v, want DNAT", exprs[len(exprs)-1])
+	}
+
+	var cmps [][]byte
+	var imms [][]byte
+	for _, e := range exprs {
+		switch e := e.(type) {
+		case *expr.Cmp:
+			cmps = append(cmps, e.Data)
+		case *expr.Immediate:
+			imms = append(imms, e.Data)
+		}
+	}
+	wantCmps := [][]byte{{17}, {192, 0, 2, 1}, {0xca, 0x6c}, {0x0d, 0x96}}
+	if len(cmps) != len(wantCmps) {
+		t.Fatalf("matches = %v, want %v", cmps, wantCmps)
+	}
+	for i := range wantCmps {
+		if !bytes.Equal(cmps[i], wantCmps[i]) {
+			t.Errorf("match %d = %v, want %v", i, cmps[i], wantCmps[i])
+		}
+	}
+	wantImms := [][]byte{{198, 51, 100, 2}, {0xca, 0x6d}}
+	for i := range wantImms {
+		if i >= len(imms) || !bytes.Equal(imms[i], wantImms[i]) {
+			t.Errorf("immediates = %v, want %v", imms, wantImms)
+			break
+		}
+	}
+}
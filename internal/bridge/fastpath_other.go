@@ -0,0 +1,13 @@
+//go:build !linux
+
+package bridge
+
+import (
+	"errors"
+	"log/slog"
+)
+
+// newFastPath is unavailable on non-Linux platforms.
+func newFastPath(_ *slog.Logger) (FastPath, error) {
+	return nil, errors.New("bridge: fast path is only supported on linux")
+}
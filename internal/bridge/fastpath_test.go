@@ -0,0 +1,215 @@
+package bridge
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+func startFastPathRelay(t *testing.T, fp FastPath) *Relay {
+	t.Helper()
+	relay := NewRelay(0, 10, 5*time.Minute, discardLogger())
+	relay.SetFastPath(fp)
+	if err := relay.Start(t.Context()); err != nil {
+		t.Fatalf("start relay: %v", err)
+	}
+	t.Cleanup(func() { _ = relay.Stop() })
+	return relay
+}
+
+func fastPathAssignment(id string, rateKbps int64) api.RelaySessionAssignment {
+	return api.RelaySessionAssignment{
+		SessionID:     id,
+		PeerAEndpoint: "192.0.2.1:51820",
+		PeerBEndpoint: "198.51.100.2:51821",
+		RateLimitKbps: rateKbps,
+	}
+}
+
+func TestRelay_FastPath_OffloadsUnlimitedSession(t *testing.T) {
+	fp := &mockFastPath{}
+	relay := startFastPathRelay(t, fp)
+
+	if err := relay.AddSession(fastPathAssignment("sess-1", 0)); err != nil {
+		t.Fatalf("AddSession: %v", err)
+	}
+
+	calls := fp.callsFor("OffloadRelaySession")
+	if len(calls) != 1 {
+		t.Fatalf("OffloadRelaySession calls = %d, want 1", len(calls))
+	}
+	port := relay.ListenAddr().(*net.UDPAddr).Port
+	if calls[0].Args[0] != "sess-1" || calls[0].Args[1] != port ||
+		calls[0].Args[2] != "192.0.2.1:51820" || calls[0].Args[3] != "198.51.100.2:51821" {
+		t.Errorf("OffloadRelaySession args = %v", calls[0].Args)
+	}
+	relay.mu.RLock()
+	session := relay.sessions["sess-1"]
+	relay.mu.RUnlock()
+	if !session.Offloaded() {
+		t.Error("session should be offloaded")
+	}
+
+	relay.RemoveSession("sess-1")
+	if calls := fp.callsFor("RemoveRelaySession"); len(calls) != 1 || calls[0].Args[0] != "sess-1" {
+		t.Errorf("RemoveRelaySession calls = %v, want one for sess-1", calls)
+	}
+}
+
+func TestRelay_FastPath_RateLimitedSessionStaysInUserspace(t *testing.T) {
+	fp := &mockFastPath{}
+	relay := startFastPathRelay(t, fp)
+
+	if err := relay.AddSession(fastPathAssignment("sess-1", 1000)); err != nil {
+		t.Fatalf("AddSession: %v", err)
+	}
+	if n := len(fp.callsFor("OffloadRelaySession")); n != 0 {
+		t.Fatalf("OffloadRelaySession calls = %d, want 0 for a rate-limited session", n)
+	}
+
+	// Lifting the limit offloads the session, setting one returns it.
+	if err := relay.SetSessionRateLimit("sess-1", 0); err != nil {
+		t.Fatalf("SetSessionRateLimit: %v", err)
+	}
+	if n := len(fp.callsFor("OffloadRelaySession")); n != 1 {
+		t.Errorf("OffloadRelaySession calls = %d, want 1 after lifting the limit", n)
+	}
+	if err := relay.SetSessionRateLimit("sess-1", 500); err != nil {
+		t.Fatalf("SetSessionRateLimit: %v", err)
+	}
+	if n := len(fp.callsFor("RemoveRelaySession")); n != 1 {
+		t.Errorf("RemoveRelaySession calls = %d, want 1 after setting a limit", n)
+	}
+}
+
+func TestRelay_FastPath_OffloadFailureFallsBack(t *testing.T) {
+	fp := &mockFastPath{offloadErr: errors.New("no kernel support")}
+	relay := startFastPathRelay(t, fp)
+
+	if err := relay.AddSession(fastPathAssignment("sess-1", 0)); err != nil {
+		t.Fatalf("AddSession should succeed when the offload fails: %v", err)
+	}
+	relay.mu.RLock()
+	session := relay.sessions["sess-1"]
+	relay.mu.RUnlock()
+	if session.Offloaded() {
+		t.Error("session should stay on the relay socket")
+	}
+
+	// A session that was never offloaded is not removed from the fast path.
+	relay.RemoveSession("sess-1")
+	if n := len(fp.callsFor("RemoveRelaySession")); n != 0 {
+		t.Errorf("RemoveRelaySession calls = %d, want 0", n)
+	}
+}
+
+func TestRelay_FastPath_StopRemovesOffloads(t *testing.T) {
+	fp := &mockFastPath{}
+	relay := startFastPathRelay(t, fp)
+
+	for _, id := range []string{"sess-1", "sess-2"} {
+		a := fastPathAssignment(id, 0)
+		if id == "sess-2" {
+			a.PeerAEndpoint = "192.0.2.3:51820"
+			a.PeerBEndpoint = "198.51.100.4:51821"
+		}
+		if err := relay.AddSession(a); err != nil {
+			t.Fatalf("AddSession %s: %v", id, err)
+		}
+	}
+	if err := relay.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if n := len(fp.callsFor("RemoveRelaySession")); n != 2 {
+		t.Errorf("RemoveRelaySession calls = %d, want 2", n)
+	}
+}
+
+func TestManager_FastPath_OffloadsForwarding(t *testing.T) {
+	ctrl := &mockRouteController{}
+	fp := &mockFastPath{}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+	mgr.SetFastPath(fp)
+
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	calls := fp.callsFor("OffloadForwarding")
+	if len(calls) != 1 || calls[0].Args[0] != "wg0" || calls[0].Args[1] != "eth1" {
+		t.Fatalf("OffloadForwarding calls = %v, want one for [wg0 eth1]", calls)
+	}
+	if got := mgr.BridgeCapabilities()["fast_path"]; got != "mock" {
+		t.Errorf("fast_path capability = %q, want mock", got)
+	}
+
+	if err := mgr.Teardown(); err != nil {
+		t.Fatalf("Teardown: %v", err)
+	}
+	if n := len(fp.callsFor("RemoveForwardingOffload")); n != 1 {
+		t.Errorf("RemoveForwardingOffload calls = %d, want 1", n)
+	}
+}
+
+func TestManager_FastPath_OffloadFailureKeepsSetup(t *testing.T) {
+	ctrl := &mockRouteController{}
+	fp := &mockFastPath{offloadErr: errors.New("flowtables unsupported")}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+	mgr.SetFastPath(fp)
+
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup should succeed on the standard forwarding path: %v", err)
+	}
+	if len(ctrl.callsFor("AddRoute")) != 1 {
+		t.Error("routes should be configured")
+	}
+}
+
+func TestManager_BridgeCapabilities_NoFastPath(t *testing.T) {
+	mgr := NewManager(&mockRouteController{}, Config{Enabled: true, AccessInterface: "eth1"}, discardLogger())
+	if _, ok := mgr.BridgeCapabilities()["fast_path"]; ok {
+		t.Error("fast_path capability should be absent without a fast path")
+	}
+}
+
+func TestNewBackend_NoopHasNoFastPath(t *testing.T) {
+	b, err := NewBackend(Config{Backend: BackendNoop, FastPath: FastPathAuto}, discardLogger())
+	if err != nil {
+		t.Fatalf("NewBackend: %v", err)
+	}
+	if b.FastPath != nil {
+		t.Errorf("FastPath = %v, want nil for the noop backend", b.FastPath)
+	}
+}
+
+func TestConfig_FastPath(t *testing.T) {
+	var cfg Config
+	cfg.ApplyDefaults()
+	if cfg.FastPath != FastPathOff {
+		t.Errorf("FastPath default = %q, want %q", cfg.FastPath, FastPathOff)
+	}
+
+	cfg = Config{
+		Enabled:         true,
+		FastPath:        "xdp",
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+	}
+	err := cfg.Validate()
+	want := `bridge: config: unknown FastPath "xdp" (must be "off" or "auto")`
+	if err == nil || err.Error() != want {
+		t.Errorf("Validate() = %v, want %q", err, want)
+	}
+}
@@ -0,0 +1,507 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// HA election states reported in api.BridgeHAStatus.
+const (
+	// HAStateDisabled means the node is in no HA group.
+	HAStateDisabled = "disabled"
+	// HAStateStandby means another member is active, or the node is still
+	// waiting to hear from one.
+	HAStateStandby = "standby"
+	// HAStateActive means the node holds the group's virtual IP and serves
+	// the access subnets.
+	HAStateActive = "active"
+)
+
+// haAdvertVersion is the version of the advert wire format.
+const haAdvertVersion = 1
+
+// haAdvert is the datagram the active member of an HA group sends to the
+// other members every advert interval. An advert with priority 0 is sent by
+// an active member that is shutting down, so that a standby member takes
+// over without waiting for the advert timeout.
+type haAdvert struct {
+	Version  int    `json:"v"`
+	Group    string `json:"group"`
+	NodeID   string `json:"node"`
+	Priority int    `json:"priority"`
+}
+
+// HAElector runs a VRRP-style election between the bridge nodes of an HA
+// group. Only the active member advertises. A standby member that hears no
+// advert from a member ranking above it for three advert intervals plus a
+// priority-dependent skew becomes active, and a standby member that outranks
+// the active member preempts it. Adverts are plain UDP datagrams sent to the
+// peers' mesh IPs, so they travel through the WireGuard tunnels and are only
+// accepted from the configured peers.
+//
+// The group is configured by the control plane through Configure; the
+// elector does nothing until it has a group. HAElector is concurrent-safe.
+type HAElector struct {
+	ctrl     RouteController
+	iface    string
+	port     int
+	interval time.Duration
+	logger   *slog.Logger
+	now      func() time.Time
+
+	mu             sync.Mutex
+	nodeID         string
+	cfg            *api.BridgeHAConfig
+	state          string
+	activeNode     string
+	lastAdvert     time.Time
+	transitions    int
+	lastTransition time.Time
+	vipHeld        string
+	vipErr         string
+	conn           *net.UDPConn
+	stop           chan struct{}
+	onTransition   func(state string)
+	send           func(addr string, payload []byte) error
+}
+
+// NewHAElector returns an elector that listens on port, advertises every
+// interval, and moves the group's virtual IP on iface through ctrl.
+func NewHAElector(ctrl RouteController, iface string, port int, interval time.Duration, logger *slog.Logger) *HAElector {
+	e := &HAElector{
+		ctrl:     ctrl,
+		iface:    iface,
+		port:     port,
+		interval: interval,
+		logger:   logger.With("component", "bridge"),
+		now:      time.Now,
+		state:    HAStateDisabled,
+	}
+	e.send = e.sendUDP
+	return e
+}
+
+// SetTransitionHook registers fn to be called with the new state after every
+// transition between standby and active. Callers use it to report the change
+// to the control plane at once instead of with the next heartbeat, so that
+// the mesh routes to the access subnets follow the active member.
+func (e *HAElector) SetTransitionHook(fn func(state string)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onTransition = fn
+}
+
+// Configure sets the HA group. A nil cfg leaves the group: an active node
+// releases the virtual IP first. A node joining a group starts as standby
+// and waits for an advert before it claims the group.
+func (e *HAElector) Configure(cfg *api.BridgeHAConfig) error {
+	if cfg != nil {
+		if err := validateHAConfig(cfg); err != nil {
+			return fmt.Errorf("bridge: ha: %w", err)
+		}
+		cfg = &api.BridgeHAConfig{
+			GroupID:   cfg.GroupID,
+			Priority:  cfg.Priority,
+			Peers:     slices.Clone(cfg.Peers),
+			VirtualIP: cfg.VirtualIP,
+		}
+	}
+
+	e.mu.Lock()
+	var changed bool
+	switch {
+	case cfg == nil:
+		if e.cfg != nil {
+			e.logger.Info("left bridge HA group", "group", e.cfg.GroupID)
+		}
+		changed = e.state == HAStateActive
+		e.releaseVIPLocked()
+		e.cfg = nil
+		e.state = HAStateDisabled
+		e.activeNode = ""
+	case e.cfg == nil || e.cfg.GroupID != cfg.GroupID:
+		changed = e.state == HAStateActive
+		e.releaseVIPLocked()
+		e.cfg = cfg
+		e.state = HAStateStandby
+		e.activeNode = ""
+		e.lastAdvert = e.now()
+		e.logger.Info("joined bridge HA group",
+			"group", cfg.GroupID,
+			"priority", cfg.Priority,
+			"peers", cfg.Peers,
+		)
+	default:
+		vipChanged := e.cfg.VirtualIP != cfg.VirtualIP
+		if vipChanged {
+			e.releaseVIPLocked()
+		}
+		e.cfg = cfg
+		if vipChanged && e.state == HAStateActive {
+			e.acquireVIPLocked()
+		}
+	}
+	hook := e.hookLocked(changed)
+	e.mu.Unlock()
+
+	hook()
+	return nil
+}
+
+// validateHAConfig checks the group settings pushed by the control plane.
+func validateHAConfig(cfg *api.BridgeHAConfig) error {
+	if cfg.GroupID == "" {
+		return fmt.Errorf("group ID is required")
+	}
+	if cfg.Priority < 1 || cfg.Priority > 254 {
+		return fmt.Errorf("priority must be between 1 and 254, got %d", cfg.Priority)
+	}
+	for _, p := range cfg.Peers {
+		if _, err := netip.ParseAddr(p); err != nil {
+			return fmt.Errorf("invalid peer address %q", p)
+		}
+	}
+	if cfg.VirtualIP != "" {
+		if _, err := netip.ParsePrefix(cfg.VirtualIP); err != nil {
+			return fmt.Errorf("invalid virtual IP %q: %w", cfg.VirtualIP, err)
+		}
+	}
+	return nil
+}
+
+// Start opens the advert socket and runs the election until ctx is done or
+// Stop is called. nodeID identifies this node in adverts and breaks ties
+// between members of equal priority.
+func (e *HAElector) Start(ctx context.Context, nodeID string) error {
+	if nodeID == "" {
+		return fmt.Errorf("bridge: ha: node ID is required")
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: e.port})
+	if err != nil {
+		return fmt.Errorf("bridge: ha: listen on :%d: %w", e.port, err)
+	}
+
+	e.mu.Lock()
+	e.nodeID = nodeID
+	e.conn = conn
+	e.stop = make(chan struct{})
+	e.lastAdvert = e.now()
+	stop := e.stop
+	e.mu.Unlock()
+
+	e.logger.Info("bridge HA election started",
+		"listen_port", conn.LocalAddr().(*net.UDPAddr).Port,
+		"advert_interval", e.interval,
+	)
+
+	go e.readLoop(conn)
+	go e.tickLoop(ctx, stop)
+	return nil
+}
+
+// Stop ends the election. An active node sends a priority 0 advert so that
+// a standby member takes over at once, and releases the virtual IP.
+// Idempotent: stopping a stopped elector returns nil.
+func (e *HAElector) Stop() error {
+	e.mu.Lock()
+	conn := e.conn
+	if conn == nil {
+		e.mu.Unlock()
+		return nil
+	}
+	changed := e.state == HAStateActive
+	if changed {
+		e.sendAdvertLocked(0)
+		e.releaseVIPLocked()
+		e.state = HAStateStandby
+		e.activeNode = ""
+		e.transitions++
+		e.lastTransition = e.now()
+	}
+	e.conn = nil
+	close(e.stop)
+	hook := e.hookLocked(changed)
+	e.mu.Unlock()
+
+	hook()
+	if err := conn.Close(); err != nil {
+		return fmt.Errorf("bridge: ha: close: %w", err)
+	}
+	return nil
+}
+
+// Status returns the node's state in its HA group, or nil when it has none.
+func (e *HAElector) Status() *api.BridgeHAStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cfg == nil {
+		return nil
+	}
+	return &api.BridgeHAStatus{
+		GroupID:        e.cfg.GroupID,
+		State:          e.state,
+		Priority:       e.cfg.Priority,
+		ActiveNode:     e.activeNode,
+		Transitions:    e.transitions,
+		LastTransition: e.lastTransition,
+		VirtualIPError: e.vipErr,
+	}
+}
+
+// State returns the current election state.
+func (e *HAElector) State() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.state
+}
+
+func (e *HAElector) readLoop(conn *net.UDPConn) {
+	buf := make([]byte, 1024)
+	for {
+		n, src, err := conn.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			return // conn closed
+		}
+		var a haAdvert
+		if err := json.Unmarshal(buf[:n], &a); err != nil || a.Version != haAdvertVersion {
+			e.logger.Debug("bridge HA: malformed advert", "source", src.String())
+			continue
+		}
+		e.handleAdvert(src.Addr().Unmap(), a)
+	}
+}
+
+func (e *HAElector) tickLoop(ctx context.Context, stop <-chan struct{}) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			_ = e.Stop()
+			return
+		case <-stop:
+			return
+		case <-ticker.C:
+			e.tick()
+		}
+	}
+}
+
+// masterDownLocked is how long a standby member waits for an advert before
+// it claims the group. Higher priorities wait less, so that when the active
+// member fails the best standby member takes over first.
+func (e *HAElector) masterDownLocked() time.Duration {
+	skew := time.Duration(256-e.cfg.Priority) * e.interval / 256
+	return 3*e.interval + skew
+}
+
+// tick advertises when active and takes over when the active member has
+// been silent for the master down interval.
+func (e *HAElector) tick() {
+	e.mu.Lock()
+	if e.cfg == nil || e.nodeID == "" {
+		e.mu.Unlock()
+		return
+	}
+	var changed bool
+	if e.state == HAStateStandby && e.now().Sub(e.lastAdvert) >= e.masterDownLocked() {
+		e.logger.Warn("bridge HA: no advert from an active member, taking over",
+			"group", e.cfg.GroupID,
+			"previous_active", e.activeNode,
+		)
+		e.becomeActiveLocked()
+		changed = true
+	}
+	if e.state == HAStateActive {
+		e.sendAdvertLocked(e.cfg.Priority)
+	}
+	hook := e.hookLocked(changed)
+	e.mu.Unlock()
+
+	hook()
+}
+
+// handleAdvert applies an advert received from src.
+func (e *HAElector) handleAdvert(src netip.Addr, a haAdvert) {
+	e.mu.Lock()
+	if e.cfg == nil || a.Group != e.cfg.GroupID || a.NodeID == e.nodeID {
+		e.mu.Unlock()
+		return
+	}
+	if !slices.ContainsFunc(e.cfg.Peers, func(p string) bool {
+		addr, err := netip.ParseAddr(p)
+		return err == nil && addr == src
+	}) {
+		e.mu.Unlock()
+		e.logger.Debug("bridge HA: advert from unknown peer",
+			"source", src.String(),
+			"node", a.NodeID,
+		)
+		return
+	}
+
+	var changed bool
+	switch {
+	case a.Priority == 0:
+		// The active member is shutting down: wait only for the skew.
+		if a.NodeID == e.activeNode {
+			e.activeNode = ""
+			e.lastAdvert = e.now().Add(-3 * e.interval)
+		}
+	case e.outranksLocked(a):
+		e.activeNode = a.NodeID
+		e.lastAdvert = e.now()
+		if e.state == HAStateActive {
+			e.logger.Info("bridge HA: higher priority member is active, standing by",
+				"group", e.cfg.GroupID,
+				"active", a.NodeID,
+				"active_priority", a.Priority,
+			)
+			e.releaseVIPLocked()
+			e.state = HAStateStandby
+			e.transitions++
+			e.lastTransition = e.now()
+			changed = true
+		}
+	case e.state == HAStateStandby && e.nodeID != "":
+		// A lower priority member is active: preempt it.
+		e.logger.Info("bridge HA: preempting lower priority member",
+			"group", e.cfg.GroupID,
+			"active", a.NodeID,
+			"active_priority", a.Priority,
+		)
+		e.becomeActiveLocked()
+		e.sendAdvertLocked(e.cfg.Priority)
+		changed = true
+	}
+	hook := e.hookLocked(changed)
+	e.mu.Unlock()
+
+	hook()
+}
+
+// outranksLocked reports whether the sender of a ranks above this node.
+func (e *HAElector) outranksLocked(a haAdvert) bool {
+	if a.Priority != e.cfg.Priority {
+		return a.Priority > e.cfg.Priority
+	}
+	return a.NodeID > e.nodeID
+}
+
+func (e *HAElector) becomeActiveLocked() {
+	e.state = HAStateActive
+	e.activeNode = e.nodeID
+	e.transitions++
+	e.lastTransition = e.now()
+	e.acquireVIPLocked()
+	e.logger.Info("bridge HA: became active",
+		"group", e.cfg.GroupID,
+		"priority", e.cfg.Priority,
+	)
+}
+
+// acquireVIPLocked adds and announces the group's virtual IP. Failures are
+// recorded in the status: the node stays active so that mesh-side routing
+// still fails over.
+func (e *HAElector) acquireVIPLocked() {
+	e.vipErr = ""
+	if e.cfg.VirtualIP == "" {
+		return
+	}
+	vip, ok := e.ctrl.(VirtualIPController)
+	if !ok {
+		e.vipErr = "route controller does not support virtual IPs"
+		return
+	}
+	if err := vip.AddVirtualIP(e.iface, e.cfg.VirtualIP); err != nil {
+		e.vipErr = err.Error()
+		e.logger.Error("bridge HA: add virtual IP failed",
+			"address", e.cfg.VirtualIP,
+			"error", err,
+		)
+		return
+	}
+	e.vipHeld = e.cfg.VirtualIP
+	if err := vip.AnnounceVirtualIP(e.iface, e.cfg.VirtualIP); err != nil {
+		e.vipErr = err.Error()
+		e.logger.Warn("bridge HA: announce virtual IP failed",
+			"address", e.cfg.VirtualIP,
+			"error", err,
+		)
+	}
+}
+
+// releaseVIPLocked removes the virtual IP if this node holds it.
+func (e *HAElector) releaseVIPLocked() {
+	if e.vipHeld == "" {
+		return
+	}
+	vip, ok := e.ctrl.(VirtualIPController)
+	if !ok {
+		return
+	}
+	if err := vip.RemoveVirtualIP(e.iface, e.vipHeld); err != nil {
+		e.vipErr = err.Error()
+		e.logger.Error("bridge HA: remove virtual IP failed",
+			"address", e.vipHeld,
+			"error", err,
+		)
+		return
+	}
+	e.vipHeld = ""
+	e.vipErr = ""
+}
+
+// sendAdvertLocked sends an advert with the given priority to every peer.
+func (e *HAElector) sendAdvertLocked(priority int) {
+	payload, err := json.Marshal(haAdvert{
+		Version:  haAdvertVersion,
+		Group:    e.cfg.GroupID,
+		NodeID:   e.nodeID,
+		Priority: priority,
+	})
+	if err != nil {
+		return
+	}
+	for _, p := range e.cfg.Peers {
+		addr := net.JoinHostPort(p, fmt.Sprint(e.port))
+		if err := e.send(addr, payload); err != nil {
+			e.logger.Debug("bridge HA: send advert failed",
+				"peer", addr,
+				"error", err,
+			)
+		}
+	}
+}
+
+// sendUDP writes payload to addr from the advert socket.
+func (e *HAElector) sendUDP(addr string, payload []byte) error {
+	if e.conn == nil {
+		return nil
+	}
+	dst, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+	_, err = e.conn.WriteToUDP(payload, dst)
+	return err
+}
+
+// hookLocked returns a function that runs the transition hook with the
+// current state if changed is true. It is called after e.mu is released.
+func (e *HAElector) hookLocked(changed bool) func() {
+	fn, state := e.onTransition, e.state
+	if !changed || fn == nil {
+		return func() {}
+	}
+	return func() { fn(state) }
+}
@@ -0,0 +1,128 @@
+//go:build linux
+
+package bridge
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// AddVirtualIP assigns the CIDR address to iface.
+func (c *NetlinkRouteController) AddVirtualIP(iface, cidr string) error {
+	addr, err := netlink.ParseAddr(cidr)
+	if err != nil {
+		return fmt.Errorf("bridge: add virtual IP: parse %q: %w", cidr, err)
+	}
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		return fmt.Errorf("bridge: add virtual IP: lookup interface %q: %w", iface, err)
+	}
+	if err := netlink.AddrReplace(link, addr); err != nil {
+		return fmt.Errorf("bridge: add virtual IP %s on %q: %w", cidr, iface, err)
+	}
+	c.logger.Debug("virtual IP added",
+		"component", "bridge",
+		"interface", iface,
+		"address", cidr,
+	)
+	return nil
+}
+
+// RemoveVirtualIP removes the CIDR address from iface.
+// Idempotent: removing an unassigned address or from a missing interface
+// returns nil.
+func (c *NetlinkRouteController) RemoveVirtualIP(iface, cidr string) error {
+	addr, err := netlink.ParseAddr(cidr)
+	if err != nil {
+		return fmt.Errorf("bridge: remove virtual IP: parse %q: %w", cidr, err)
+	}
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		var notFound netlink.LinkNotFoundError
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return fmt.Errorf("bridge: remove virtual IP: lookup interface %q: %w", iface, err)
+	}
+	if err := netlink.AddrDel(link, addr); err != nil {
+		if errors.Is(err, unix.EADDRNOTAVAIL) || errors.Is(err, unix.ENOENT) {
+			return nil
+		}
+		return fmt.Errorf("bridge: remove virtual IP %s on %q: %w", cidr, iface, err)
+	}
+	c.logger.Debug("virtual IP removed",
+		"component", "bridge",
+		"interface", iface,
+		"address", cidr,
+	)
+	return nil
+}
+
+// AnnounceVirtualIP broadcasts a gratuitous ARP request for an IPv4 address
+// on iface. IPv6 addresses and links without an Ethernet address are not
+// announced; their neighbours learn the new owner on the next resolution.
+func (c *NetlinkRouteController) AnnounceVirtualIP(iface, cidr string) error {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return fmt.Errorf("bridge: announce virtual IP: parse %q: %w", cidr, err)
+	}
+	ip := prefix.Addr()
+	if !ip.Is4() {
+		return nil
+	}
+	ifc, err := net.InterfaceByName(iface)
+	if err != nil {
+		return fmt.Errorf("bridge: announce virtual IP: lookup interface %q: %w", iface, err)
+	}
+	if len(ifc.HardwareAddr) != 6 {
+		return nil
+	}
+
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM, int(htons(unix.ETH_P_ARP)))
+	if err != nil {
+		return fmt.Errorf("bridge: announce virtual IP: open packet socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	to := &unix.SockaddrLinklayer{
+		Protocol: htons(unix.ETH_P_ARP),
+		Ifindex:  ifc.Index,
+		Halen:    6,
+		Addr:     [8]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	}
+	if err := unix.Sendto(fd, gratuitousARP(ifc.HardwareAddr, ip.As4()), 0, to); err != nil {
+		return fmt.Errorf("bridge: announce virtual IP %s on %q: %w", cidr, iface, err)
+	}
+	c.logger.Debug("virtual IP announced",
+		"component", "bridge",
+		"interface", iface,
+		"address", cidr,
+	)
+	return nil
+}
+
+// gratuitousARP returns an ARP request in which ip is both the sender and
+// the target address.
+func gratuitousARP(mac net.HardwareAddr, ip [4]byte) []byte {
+	pkt := make([]byte, 28)
+	binary.BigEndian.PutUint16(pkt[0:], 1)      // hardware type: Ethernet
+	binary.BigEndian.PutUint16(pkt[2:], 0x0800) // protocol type: IPv4
+	pkt[4] = 6                                  // hardware address length
+	pkt[5] = 4                                  // protocol address length
+	binary.BigEndian.PutUint16(pkt[6:], 1)      // operation: request
+	copy(pkt[8:14], mac)
+	copy(pkt[14:18], ip[:])
+	copy(pkt[24:28], ip[:])
+	return pkt
+}
+
+// htons converts a 16-bit value to network byte order.
+func htons(v uint16) uint16 {
+	return binary.NativeEndian.Uint16(binary.BigEndian.AppendUint16(nil, v))
+}
@@ -0,0 +1,39 @@
+//go:build linux
+
+package bridge
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// Compile-time check that NetlinkRouteController implements VirtualIPController.
+var _ VirtualIPController = (*NetlinkRouteController)(nil)
+
+func TestGratuitousARP(t *testing.T) {
+	mac := net.HardwareAddr{0x02, 0x00, 0x5e, 0x00, 0x01, 0x01}
+	got := gratuitousARP(mac, [4]byte{192, 168, 1, 1})
+
+	want := []byte{
+		0x00, 0x01, // Ethernet
+		0x08, 0x00, // IPv4
+		6, 4,
+		0x00, 0x01, // request
+		0x02, 0x00, 0x5e, 0x00, 0x01, 0x01, // sender MAC
+		192, 168, 1, 1, // sender IP
+		0, 0, 0, 0, 0, 0, // target MAC
+		192, 168, 1, 1, // target IP
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("gratuitousARP = % x, want % x", got, want)
+	}
+}
+
+func TestHtons(t *testing.T) {
+	got := binary.NativeEndian.AppendUint16(nil, htons(0x0806))
+	if !bytes.Equal(got, []byte{0x08, 0x06}) {
+		t.Errorf("htons(0x0806) in memory = % x, want 08 06", got)
+	}
+}
@@ -0,0 +1,339 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/netip"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// haTestClock is a manually advanced clock for HAElector tests.
+type haTestClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *haTestClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *haTestClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// sentAdvert is an advert captured from an elector under test.
+type sentAdvert struct {
+	addr   string
+	advert haAdvert
+}
+
+// newTestElector returns an elector for nodeID that uses a fake clock and
+// records sent adverts instead of opening a socket.
+func newTestElector(t *testing.T, ctrl RouteController, nodeID string, cfg *api.BridgeHAConfig) (*HAElector, *haTestClock, *[]sentAdvert) {
+	t.Helper()
+	clock := &haTestClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	var sent []sentAdvert
+	e := NewHAElector(ctrl, "eth1", DefaultHAListenPort, time.Second, discardLogger())
+	e.now = clock.Now
+	e.nodeID = nodeID
+	e.send = func(addr string, payload []byte) error {
+		var a haAdvert
+		if err := json.Unmarshal(payload, &a); err != nil {
+			t.Fatalf("unmarshal advert: %v", err)
+		}
+		sent = append(sent, sentAdvert{addr: addr, advert: a})
+		return nil
+	}
+	if err := e.Configure(cfg); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	return e, clock, &sent
+}
+
+func haTestConfig(priority int) *api.BridgeHAConfig {
+	return &api.BridgeHAConfig{
+		GroupID:   "site-a",
+		Priority:  priority,
+		Peers:     []string{"10.99.0.2"},
+		VirtualIP: "192.168.1.1/24",
+	}
+}
+
+var haTestPeer = netip.MustParseAddr("10.99.0.2")
+
+func TestHAElector_TakesOverAfterMasterDown(t *testing.T) {
+	ctrl := &mockVIPRouteController{}
+	e, clock, sent := newTestElector(t, ctrl, "node-a", haTestConfig(100))
+
+	// Master down for priority 100 at 1s is 3s + 156/256s.
+	clock.Advance(3 * time.Second)
+	e.tick()
+	if got := e.State(); got != HAStateStandby {
+		t.Fatalf("state after 3s = %q, want %q", got, HAStateStandby)
+	}
+	if len(*sent) != 0 {
+		t.Fatalf("standby node sent %d adverts, want 0", len(*sent))
+	}
+
+	clock.Advance(time.Second)
+	e.tick()
+	if got := e.State(); got != HAStateActive {
+		t.Fatalf("state after 4s = %q, want %q", got, HAStateActive)
+	}
+	if len(*sent) != 1 {
+		t.Fatalf("sent %d adverts, want 1", len(*sent))
+	}
+	want := sentAdvert{addr: "10.99.0.2:51840", advert: haAdvert{Version: 1, Group: "site-a", NodeID: "node-a", Priority: 100}}
+	if (*sent)[0] != want {
+		t.Errorf("advert = %+v, want %+v", (*sent)[0], want)
+	}
+	if calls := ctrl.callsFor("AddVirtualIP"); len(calls) != 1 || calls[0].Args[1] != "192.168.1.1/24" {
+		t.Errorf("AddVirtualIP calls = %v", calls)
+	}
+	if calls := ctrl.callsFor("AnnounceVirtualIP"); len(calls) != 1 {
+		t.Errorf("AnnounceVirtualIP calls = %d, want 1", len(calls))
+	}
+
+	st := e.Status()
+	if st.ActiveNode != "node-a" || st.Transitions != 1 || st.Priority != 100 {
+		t.Errorf("status = %+v", st)
+	}
+}
+
+func TestHAElector_HigherPriorityAdvertKeepsStandby(t *testing.T) {
+	e, clock, _ := newTestElector(t, &mockVIPRouteController{}, "node-a", haTestConfig(100))
+
+	for i := 0; i < 10; i++ {
+		clock.Advance(time.Second)
+		e.handleAdvert(haTestPeer, haAdvert{Version: 1, Group: "site-a", NodeID: "node-b", Priority: 200})
+		e.tick()
+	}
+	if got := e.State(); got != HAStateStandby {
+		t.Fatalf("state = %q, want %q", got, HAStateStandby)
+	}
+	if got := e.Status().ActiveNode; got != "node-b" {
+		t.Errorf("ActiveNode = %q, want node-b", got)
+	}
+}
+
+func TestHAElector_ActiveStandsDownForHigherPriority(t *testing.T) {
+	ctrl := &mockVIPRouteController{}
+	var states []string
+	e, clock, _ := newTestElector(t, ctrl, "node-a", haTestConfig(100))
+	e.SetTransitionHook(func(state string) { states = append(states, state) })
+
+	clock.Advance(5 * time.Second)
+	e.tick()
+	e.handleAdvert(haTestPeer, haAdvert{Version: 1, Group: "site-a", NodeID: "node-b", Priority: 150})
+
+	if got := e.State(); got != HAStateStandby {
+		t.Fatalf("state = %q, want %q", got, HAStateStandby)
+	}
+	if calls := ctrl.callsFor("RemoveVirtualIP"); len(calls) != 1 {
+		t.Errorf("RemoveVirtualIP calls = %d, want 1", len(calls))
+	}
+	if len(states) != 2 || states[0] != HAStateActive || states[1] != HAStateStandby {
+		t.Errorf("hook states = %v, want [active standby]", states)
+	}
+}
+
+func TestHAElector_EqualPriorityTieBreak(t *testing.T) {
+	e, clock, _ := newTestElector(t, &mockVIPRouteController{}, "node-b", haTestConfig(100))
+	clock.Advance(5 * time.Second)
+	e.tick()
+
+	// A lower node ID at equal priority does not displace node-b.
+	e.handleAdvert(haTestPeer, haAdvert{Version: 1, Group: "site-a", NodeID: "node-a", Priority: 100})
+	if got := e.State(); got != HAStateActive {
+		t.Fatalf("state after node-a advert = %q, want %q", got, HAStateActive)
+	}
+
+	// A higher node ID does.
+	e.handleAdvert(haTestPeer, haAdvert{Version: 1, Group: "site-a", NodeID: "node-c", Priority: 100})
+	if got := e.State(); got != HAStateStandby {
+		t.Fatalf("state after node-c advert = %q, want %q", got, HAStateStandby)
+	}
+}
+
+func TestHAElector_PreemptsLowerPriority(t *testing.T) {
+	e, _, sent := newTestElector(t, &mockVIPRouteController{}, "node-a", haTestConfig(200))
+
+	e.handleAdvert(haTestPeer, haAdvert{Version: 1, Group: "site-a", NodeID: "node-b", Priority: 100})
+	if got := e.State(); got != HAStateActive {
+		t.Fatalf("state = %q, want %q", got, HAStateActive)
+	}
+	if len(*sent) != 1 || (*sent)[0].advert.Priority != 200 {
+		t.Errorf("sent = %+v, want one advert with priority 200", *sent)
+	}
+}
+
+func TestHAElector_PriorityZeroShortensTakeover(t *testing.T) {
+	e, clock, _ := newTestElector(t, &mockVIPRouteController{}, "node-a", haTestConfig(100))
+	e.handleAdvert(haTestPeer, haAdvert{Version: 1, Group: "site-a", NodeID: "node-b", Priority: 200})
+
+	e.handleAdvert(haTestPeer, haAdvert{Version: 1, Group: "site-a", NodeID: "node-b", Priority: 0})
+	clock.Advance(time.Second) // longer than the skew of 156/256s
+	e.tick()
+	if got := e.State(); got != HAStateActive {
+		t.Fatalf("state = %q, want %q", got, HAStateActive)
+	}
+}
+
+func TestHAElector_IgnoresForeignAdverts(t *testing.T) {
+	e, clock, _ := newTestElector(t, &mockVIPRouteController{}, "node-a", haTestConfig(100))
+
+	clock.Advance(2 * time.Second)
+	e.handleAdvert(netip.MustParseAddr("10.99.0.9"), haAdvert{Version: 1, Group: "site-a", NodeID: "node-x", Priority: 250})
+	e.handleAdvert(haTestPeer, haAdvert{Version: 1, Group: "site-b", NodeID: "node-b", Priority: 250})
+	clock.Advance(2 * time.Second)
+	e.tick()
+
+	if got := e.State(); got != HAStateActive {
+		t.Fatalf("state = %q, want %q: adverts from unknown peers or groups must be ignored", got, HAStateActive)
+	}
+}
+
+func TestHAElector_VirtualIPUnsupported(t *testing.T) {
+	e, clock, _ := newTestElector(t, &mockRouteController{}, "node-a", haTestConfig(100))
+	clock.Advance(5 * time.Second)
+	e.tick()
+
+	st := e.Status()
+	if st.State != HAStateActive {
+		t.Fatalf("state = %q, want %q", st.State, HAStateActive)
+	}
+	if st.VirtualIPError == "" {
+		t.Error("VirtualIPError should be set when the controller cannot move virtual IPs")
+	}
+}
+
+func TestHAElector_ConfigureNilReleases(t *testing.T) {
+	ctrl := &mockVIPRouteController{}
+	e, clock, _ := newTestElector(t, ctrl, "node-a", haTestConfig(100))
+	clock.Advance(5 * time.Second)
+	e.tick()
+
+	if err := e.Configure(nil); err != nil {
+		t.Fatalf("Configure(nil): %v", err)
+	}
+	if got := e.State(); got != HAStateDisabled {
+		t.Errorf("state = %q, want %q", got, HAStateDisabled)
+	}
+	if e.Status() != nil {
+		t.Error("Status should be nil without a group")
+	}
+	if calls := ctrl.callsFor("RemoveVirtualIP"); len(calls) != 1 {
+		t.Errorf("RemoveVirtualIP calls = %d, want 1", len(calls))
+	}
+}
+
+func TestHAElector_ConfigureMovesVirtualIP(t *testing.T) {
+	ctrl := &mockVIPRouteController{}
+	e, clock, _ := newTestElector(t, ctrl, "node-a", haTestConfig(100))
+	clock.Advance(5 * time.Second)
+	e.tick()
+
+	cfg := haTestConfig(100)
+	cfg.VirtualIP = "192.168.1.2/24"
+	if err := e.Configure(cfg); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if got := e.State(); got != HAStateActive {
+		t.Errorf("state = %q, want %q", got, HAStateActive)
+	}
+	if calls := ctrl.callsFor("RemoveVirtualIP"); len(calls) != 1 || calls[0].Args[1] != "192.168.1.1/24" {
+		t.Errorf("RemoveVirtualIP calls = %v", calls)
+	}
+	if calls := ctrl.callsFor("AddVirtualIP"); len(calls) != 2 || calls[1].Args[1] != "192.168.1.2/24" {
+		t.Errorf("AddVirtualIP calls = %v", calls)
+	}
+}
+
+func TestHAElector_ConfigureValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  api.BridgeHAConfig
+	}{
+		{"missing group", api.BridgeHAConfig{Priority: 100}},
+		{"priority zero", api.BridgeHAConfig{GroupID: "g", Priority: 0}},
+		{"priority too high", api.BridgeHAConfig{GroupID: "g", Priority: 255}},
+		{"invalid peer", api.BridgeHAConfig{GroupID: "g", Priority: 100, Peers: []string{"node-b"}}},
+		{"invalid virtual IP", api.BridgeHAConfig{GroupID: "g", Priority: 100, VirtualIP: "192.168.1.1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewHAElector(&mockRouteController{}, "eth1", DefaultHAListenPort, time.Second, discardLogger())
+			if err := e.Configure(&tt.cfg); err == nil {
+				t.Error("Configure should fail")
+			}
+		})
+	}
+}
+
+func TestHAElector_StartReceivesAdverts(t *testing.T) {
+	e := NewHAElector(&mockVIPRouteController{}, "eth1", 0, time.Hour, discardLogger())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := e.Start(ctx, "node-a"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer e.Stop()
+	if err := e.Configure(&api.BridgeHAConfig{GroupID: "site-a", Priority: 100, Peers: []string{"127.0.0.1"}}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+
+	e.mu.Lock()
+	port := e.conn.LocalAddr().(*net.UDPAddr).Port
+	e.mu.Unlock()
+	conn, err := net.Dial("udp4", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	payload, _ := json.Marshal(haAdvert{Version: 1, Group: "site-a", NodeID: "node-b", Priority: 200})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := conn.Write(payload); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if st := e.Status(); st != nil && st.ActiveNode == "node-b" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("elector did not record the advert from node-b")
+}
+
+func TestHAElector_StopIdempotent(t *testing.T) {
+	e := NewHAElector(&mockRouteController{}, "eth1", 0, time.Second, discardLogger())
+	if err := e.Stop(); err != nil {
+		t.Errorf("Stop before Start: %v", err)
+	}
+	if err := e.Start(context.Background(), "node-a"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := e.Stop(); err != nil {
+		t.Errorf("first Stop: %v", err)
+	}
+	if err := e.Stop(); err != nil {
+		t.Errorf("second Stop: %v", err)
+	}
+}
+
+func TestHAElector_StartRequiresNodeID(t *testing.T) {
+	e := NewHAElector(&mockRouteController{}, "eth1", 0, time.Second, discardLogger())
+	if err := e.Start(context.Background(), ""); err == nil {
+		t.Error("Start should fail without a node ID")
+	}
+}
@@ -2,6 +2,7 @@ package bridge
 
 import (
 	"context"
+	"errors"
 
 	"github.com/plexsphere/plexd/internal/api"
 	"github.com/plexsphere/plexd/internal/reconcile"
@@ -13,14 +14,24 @@ type ReconcileTrigger interface {
 }
 
 // ReconcileHandler returns a reconcile.ReconcileHandler that updates bridge
-// routes when the desired BridgeConfig changes. If BridgeConfig is nil in the
-// desired state, the handler is a no-op.
+// routes, NAT masquerading, the HA group, and the BGP policy when the
+// desired BridgeConfig changes, and the mesh names of the DNS forwarder when peers change. If
+// BridgeConfig is nil in the desired state, only the mesh names are updated.
 func ReconcileHandler(mgr *Manager) reconcile.ReconcileHandler {
 	return func(_ context.Context, desired *api.StateResponse, _ reconcile.StateDiff) error {
-		if desired == nil || desired.BridgeConfig == nil {
+		if desired == nil {
 			return nil
 		}
-		return mgr.UpdateRoutes(desired.BridgeConfig.AccessSubnets)
+		mgr.UpdateDNSNames(desired.Peers)
+		if desired.BridgeConfig == nil {
+			return nil
+		}
+		return errors.Join(
+			mgr.UpdateRoutes(desired.BridgeConfig.AccessSubnets),
+			mgr.UpdateNAT(desired.BridgeConfig.EnableNAT),
+			mgr.UpdateHA(desired.BridgeConfig.HA),
+			mgr.UpdateBGP(desired.BridgeConfig.BGP),
+		)
 	}
 }
 
@@ -34,3 +45,11 @@ func HandleBridgeConfigUpdated(trigger ReconcileTrigger) api.EventHandler {
 		return nil
 	}
 }
+
+// DriftChecker returns a reconcile.DriftChecker that restores bridge
+// forwarding and routes changed outside plexd via Manager.CheckDrift.
+func DriftChecker(mgr *Manager) reconcile.DriftChecker {
+	return func(context.Context, *api.StateResponse) ([]api.DriftCorrection, error) {
+		return mgr.CheckDrift()
+	}
+}
@@ -154,6 +154,153 @@ func TestReconcileHandler_RemovedSubnets(t *testing.T) {
 	}
 }
 
+func TestReconcileHandler_HAGroup(t *testing.T) {
+	ctrl := &mockRouteController{}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+		EnableNAT:       BoolPtr(true),
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+
+	handler := ReconcileHandler(mgr)
+
+	desired := &api.StateResponse{
+		BridgeConfig: &api.BridgeConfig{
+			AccessSubnets: []string{"10.0.0.0/24"},
+			EnableNAT:     true,
+			HA:            &api.BridgeHAConfig{GroupID: "site-a", Priority: 100, Peers: []string{"10.42.0.3"}},
+		},
+	}
+	if err := handler(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+		t.Fatalf("handler error = %v, want nil", err)
+	}
+	if got := mgr.HA().State(); got != HAStateStandby {
+		t.Errorf("HA state = %q, want %q", got, HAStateStandby)
+	}
+
+	desired.BridgeConfig.HA = nil
+	if err := handler(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+		t.Fatalf("handler error = %v, want nil", err)
+	}
+	if got := mgr.HA().State(); got != HAStateDisabled {
+		t.Errorf("HA state = %q, want %q", got, HAStateDisabled)
+	}
+}
+
+func TestReconcileHandler_InvalidHAGroup(t *testing.T) {
+	ctrl := &mockRouteController{}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+
+	handler := ReconcileHandler(mgr)
+
+	desired := &api.StateResponse{
+		BridgeConfig: &api.BridgeConfig{
+			AccessSubnets: []string{"10.0.0.0/24"},
+			HA:            &api.BridgeHAConfig{GroupID: "site-a", Priority: 300},
+		},
+	}
+	if err := handler(context.Background(), desired, reconcile.StateDiff{}); err == nil {
+		t.Fatal("handler should fail for an invalid HA priority")
+	}
+}
+
+func TestReconcileHandler_InvalidBGPPolicy(t *testing.T) {
+	ctrl := &mockRouteController{}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+		BGPEnabled:      true,
+		BGPLocalAS:      64512,
+		BGPNeighbors:    []BGPNeighbor{{Address: "10.0.0.254", AS: 65001}},
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+
+	handler := ReconcileHandler(mgr)
+
+	desired := &api.StateResponse{
+		BridgeConfig: &api.BridgeConfig{
+			AccessSubnets: []string{"10.0.0.0/24"},
+			BGP:           &api.BridgeBGPConfig{Import: []string{"on-prem"}},
+		},
+	}
+	if err := handler(context.Background(), desired, reconcile.StateDiff{}); err == nil {
+		t.Fatal("handler should fail for an invalid BGP import subnet")
+	}
+}
+
+func TestReconcileHandler_EnableNAT(t *testing.T) {
+	ctrl := &mockRouteController{}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	handler := ReconcileHandler(mgr)
+
+	desired := &api.StateResponse{
+		BridgeConfig: &api.BridgeConfig{AccessSubnets: []string{"10.0.0.0/24"}, EnableNAT: false},
+	}
+	if err := handler(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	if n := len(ctrl.callsFor("RemoveNATMasquerade")); n != 1 {
+		t.Errorf("RemoveNATMasquerade calls = %d, want 1", n)
+	}
+
+	desired.BridgeConfig.EnableNAT = true
+	if err := handler(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	if n := len(ctrl.callsFor("AddNATMasquerade")); n != 2 {
+		t.Errorf("AddNATMasquerade calls = %d, want 2 (Setup and re-enable)", n)
+	}
+	if info := mgr.BridgeStatus(); !info.NATEnabled || info.NATRules != 1 {
+		t.Errorf("NATEnabled = %v, NATRules = %d, want true, 1", info.NATEnabled, info.NATRules)
+	}
+}
+
+func TestReconcileHandler_DNSNames(t *testing.T) {
+	ctrl := &mockRouteController{}
+	cfg := Config{
+		Enabled:           true,
+		AccessInterface:   "eth1",
+		AccessSubnets:     []string{"10.0.0.0/24"},
+		DNSForwardEnabled: true,
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+	handler := ReconcileHandler(mgr)
+
+	// Mesh names are updated without a BridgeConfig.
+	desired := &api.StateResponse{
+		Peers: []api.Peer{{ID: "p1", MeshIP: "10.42.0.2", Hostname: "db-1"}},
+	}
+	if err := handler(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	if n := mgr.DNSForwarder().Names(); n != 2 {
+		t.Errorf("Names() = %d, want 2", n)
+	}
+	if caps := mgr.BridgeCapabilities(); caps["dns_forward"] != "true" || caps["dns_domain"] != DefaultDNSDomain {
+		t.Errorf("capabilities = %v, want dns_forward and dns_domain", caps)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // SSE Handler tests
 // ---------------------------------------------------------------------------
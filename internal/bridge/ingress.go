@@ -8,20 +8,36 @@ import (
 	"io"
 	"log/slog"
 	"net"
+	"net/netip"
 	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/validation"
 )
 
 // activeRule holds the state of a running ingress rule.
 type activeRule struct {
 	rule     api.IngressRule
 	listener net.Listener
-	cancel   context.CancelFunc
-	done     chan struct{} // closed when accept loop exits
+	udp      *udpForwarder      // set instead of listener in udp mode
+	cancel   context.CancelFunc // force-closes the rule's proxy connections
+	done     chan struct{}      // closed when the rule stops accepting
+
+	conns    sync.WaitGroup // in-flight proxy connections
+	inflight atomic.Int64
+}
+
+// DrainResult counts the proxied connections that were in flight when
+// ingress listeners stopped accepting.
+type DrainResult struct {
+	// Drained connections finished within the drain timeout.
+	Drained int
+	// Aborted connections were still open at the drain timeout and were
+	// force-closed.
+	Aborted int
 }
 
 // IngressManager manages public ingress — TCP listeners that proxy traffic
@@ -29,9 +45,14 @@ type activeRule struct {
 // IngressManager is concurrent-safe via mu.
 type IngressManager struct {
 	ctrl        IngressController
+	conntrack   ConntrackFlusher
+	portSources []PortSource
 	cfg         Config
 	logger      *slog.Logger
 	dialTimeout time.Duration
+	// drainTimeout bounds how long removed rules wait for in-flight
+	// connections before force-closing them. Zero closes them at once.
+	drainTimeout time.Duration
 
 	// mu protects active, activeRules from concurrent access by
 	// SSE event handlers and the reconcile loop.
@@ -41,19 +62,53 @@ type IngressManager struct {
 	active      bool
 	activeRules map[string]*activeRule // keyed by rule ID
 	connCount   atomic.Int64          // total active proxy connections across all rules
+
+	// draining tracks removed rules whose connections drain in the
+	// background; Teardown waits for them.
+	draining sync.WaitGroup
 }
 
 // NewIngressManager creates a new IngressManager.
 func NewIngressManager(ctrl IngressController, cfg Config, logger *slog.Logger) *IngressManager {
 	return &IngressManager{
-		ctrl:        ctrl,
-		cfg:         cfg,
-		logger:      logger,
-		dialTimeout: cfg.IngressDialTimeout,
-		activeRules: make(map[string]*activeRule),
+		ctrl:         ctrl,
+		cfg:          cfg,
+		logger:       logger,
+		dialTimeout:  cfg.IngressDialTimeout,
+		drainTimeout: cfg.IngressDrainTimeout,
+		activeRules:  make(map[string]*activeRule),
 	}
 }
 
+// SetConntrackFlusher sets the flusher used to drop conntrack entries for a
+// rule's listen port when the rule is removed. It must be called before Setup.
+// When unset, no conntrack cleanup is performed.
+func (m *IngressManager) SetConntrackFlusher(f ConntrackFlusher) {
+	m.conntrack = f
+}
+
+// SetPortSources sets sources of UDP ports in use, such as the
+// SiteToSiteManager, that udp rules must not take in addition to the ports
+// of the bridge listeners in the config. It must be called before Setup.
+func (m *IngressManager) SetPortSources(srcs ...PortSource) {
+	m.portSources = srcs
+}
+
+// ReservedPorts returns the listen ports of the active udp rules.
+// It implements PortSource.
+func (m *IngressManager) ReservedPorts() []validation.ReservedPort {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ports []validation.ReservedPort
+	for id, ar := range m.activeRules {
+		if ar.rule.Mode == "udp" {
+			ports = append(ports, validation.ReservedPort{Port: ar.rule.ListenPort, Owner: "ingress rule " + id})
+		}
+	}
+	return ports
+}
+
 // Setup initializes the ingress manager.
 // When ingress is disabled this is a no-op.
 func (m *IngressManager) Setup() error {
@@ -70,31 +125,32 @@ func (m *IngressManager) Setup() error {
 		"component", "bridge",
 		"max_rules", m.cfg.MaxIngressRules,
 		"dial_timeout", m.dialTimeout.String(),
+		"drain_timeout", m.drainTimeout.String(),
 	)
 
 	return nil
 }
 
-// Teardown closes all active listeners and cancels proxy connections.
+// Teardown closes all active listeners, drains their in-flight proxy
+// connections for up to the drain timeout, and force-closes the rest.
+// The result counts the drained and aborted connections across all rules.
 // Errors are aggregated — cleanup continues even when individual operations fail.
-// Idempotent: calling Teardown when inactive returns nil.
-func (m *IngressManager) Teardown() error {
+// Idempotent: calling Teardown when inactive returns a zero result and nil.
+func (m *IngressManager) Teardown() (DrainResult, error) {
 	m.mu.Lock()
 
 	if !m.active {
 		m.mu.Unlock()
-		return nil
+		return DrainResult{}, nil
 	}
 
 	var errs []error
-	doneChans := make([]chan struct{}, 0, len(m.activeRules))
+	rules := make(map[string]*activeRule, len(m.activeRules))
 
+	// Stop accepting on every rule before draining any of them.
 	for id, ar := range m.activeRules {
-		doneChans = append(doneChans, ar.done)
-		// Cancel accept loop and proxy connections.
-		ar.cancel()
-		// Close the listener.
-		if err := m.ctrl.Close(ar.listener); err != nil {
+		rules[id] = ar
+		if err := m.stopAccepting(ar); err != nil {
 			errs = append(errs, fmt.Errorf("bridge: ingress: close rule %s: %w", id, err))
 		}
 	}
@@ -103,24 +159,91 @@ func (m *IngressManager) Teardown() error {
 	m.activeRules = make(map[string]*activeRule)
 	m.mu.Unlock()
 
-	// Wait for all accept loop goroutines to exit outside the lock.
-	for _, done := range doneChans {
-		<-done
+	// Drain all rules in parallel against a shared deadline, outside the lock.
+	deadline := time.Now().Add(m.drainTimeout)
+	var (
+		wg     sync.WaitGroup
+		resMu  sync.Mutex
+		result DrainResult
+	)
+	for _, ar := range rules {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := m.drain(ar, deadline)
+			resMu.Lock()
+			result.Drained += r.Drained
+			result.Aborted += r.Aborted
+			resMu.Unlock()
+		}()
+	}
+	wg.Wait()
+	m.draining.Wait()
+
+	// Drop tracked flows to the listen ports once the connections are gone.
+	for id, ar := range rules {
+		if err := m.flushConntrack(ar); err != nil {
+			errs = append(errs, fmt.Errorf("bridge: ingress: flush conntrack for rule %s: %w", id, err))
+		}
 	}
 
 	if len(errs) == 0 {
 		m.logger.Info("ingress manager stopped",
 			"component", "bridge",
+			"drained", result.Drained,
+			"aborted", result.Aborted,
 		)
 	}
 
-	return errors.Join(errs...)
+	return result, errors.Join(errs...)
 }
 
-// AddRule adds an ingress rule and starts a TCP listener for it.
+// stopAccepting closes the listener of ar. In udp mode the socket stays open
+// for the rule's open sessions and only new sessions are refused.
+func (m *IngressManager) stopAccepting(ar *activeRule) error {
+	if ar.udp != nil {
+		ar.udp.stopAccepting()
+		return nil
+	}
+	return m.ctrl.Close(ar.listener)
+}
+
+// drain waits for ar to stop accepting and for its in-flight connections,
+// or udp sessions, to finish until deadline, then force-closes the
+// remaining ones. stopAccepting must already have been called.
+func (m *IngressManager) drain(ar *activeRule, deadline time.Time) DrainResult {
+	<-ar.done
+	inflight := int(ar.inflight.Load())
+
+	finished := make(chan struct{})
+	go func() {
+		ar.conns.Wait()
+		close(finished)
+	}()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-finished:
+		ar.cancel()
+		return DrainResult{Drained: inflight}
+	case <-timer.C:
+	}
+
+	aborted := int(ar.inflight.Load())
+	ar.cancel()
+	<-finished
+	return DrainResult{Drained: inflight - aborted, Aborted: aborted}
+}
+
+// AddRule adds an ingress rule and starts a TCP listener for it, or a UDP
+// socket for rules in udp mode.
 // Returns an error if the manager is inactive, the rule ID already exists,
-// or the maximum rule count is reached.
+// the maximum rule count is reached, or the rule fails validation against
+// the active rules and reserved ports (see validation.IngressRule).
 func (m *IngressManager) AddRule(rule api.IngressRule) error {
+	reserved := reservedPorts(&m.cfg, m.portSources)
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -134,6 +257,38 @@ func (m *IngressManager) AddRule(rule api.IngressRule) error {
 	if len(m.activeRules) >= m.cfg.MaxIngressRules {
 		return fmt.Errorf("bridge: ingress: max rules reached (%d)", m.cfg.MaxIngressRules)
 	}
+	existing := make([]api.IngressRule, 0, len(m.activeRules))
+	for _, ar := range m.activeRules {
+		existing = append(existing, ar.rule)
+	}
+	if err := validation.IngressRule(rule, existing, reserved).Err(); err != nil {
+		return fmt.Errorf("bridge: ingress: %w", err)
+	}
+	// The PROXY header precedes the TLS handshake, which the terminating
+	// listener performs before the manager sees the connection.
+	if rule.AcceptProxyProtocol && rule.Mode == "terminate" {
+		return fmt.Errorf("bridge: ingress: rule %s: accepting PROXY protocol is not supported in terminate mode", rule.RuleID)
+	}
+
+	addr := ":" + strconv.Itoa(rule.ListenPort)
+	if rule.Mode == "udp" {
+		if rule.SendProxyProtocol || rule.AcceptProxyProtocol {
+			return fmt.Errorf("bridge: ingress: rule %s: PROXY protocol is not supported in udp mode", rule.RuleID)
+		}
+		ar, err := m.startUDPRule(rule, addr)
+		if err != nil {
+			return fmt.Errorf("bridge: ingress: rule %s: listen on udp %s: %w", rule.RuleID, addr, err)
+		}
+		m.activeRules[rule.RuleID] = ar
+		m.logger.Info("ingress rule added",
+			"component", "bridge",
+			"rule_id", rule.RuleID,
+			"listen_port", rule.ListenPort,
+			"target", rule.TargetAddr,
+			"mode", rule.Mode,
+		)
+		return nil
+	}
 
 	// Build TLS config for terminate mode.
 	var tlsCfg *tls.Config
@@ -148,7 +303,6 @@ func (m *IngressManager) AddRule(rule api.IngressRule) error {
 		}
 	}
 
-	addr := ":" + strconv.Itoa(rule.ListenPort)
 	ln, err := m.ctrl.Listen(addr, tlsCfg)
 	if err != nil {
 		return fmt.Errorf("bridge: ingress: rule %s: listen on %s: %w", rule.RuleID, addr, err)
@@ -174,42 +328,148 @@ func (m *IngressManager) AddRule(rule api.IngressRule) error {
 		"listen_port", rule.ListenPort,
 		"target", rule.TargetAddr,
 		"mode", rule.Mode,
+		"send_proxy_protocol", rule.SendProxyProtocol,
+		"accept_proxy_protocol", rule.AcceptProxyProtocol,
 	)
 
 	return nil
 }
 
-// RemoveRule stops the listener for the given rule ID and removes it.
-// Removing a non-existent rule or calling on an inactive manager is a no-op.
-func (m *IngressManager) RemoveRule(ruleID string) {
-	m.mu.Lock()
-	if !m.active {
-		m.mu.Unlock()
-		return
+// RemoveRule stops the listener for the given rule ID and removes the rule.
+// Its in-flight proxy connections drain in the background for up to the
+// drain timeout; the returned channel receives the result once they are
+// closed. Removing a non-existent rule or calling on an inactive manager is
+// a no-op and delivers a zero result.
+func (m *IngressManager) RemoveRule(ruleID string) <-chan DrainResult {
+	ar := m.detach(ruleID)
+	if ar == nil {
+		results := make(chan DrainResult, 1)
+		results <- DrainResult{}
+		return results
+	}
+	return m.retire(ar, false)
+}
+
+// ReplaceRule replaces the active rule with the ID of rule, or adds rule if
+// there is none. The old listener is closed before the new one opens, so new
+// connections go to rule at once, while the old rule's in-flight connections
+// drain in the background. In udp mode the old rule's sessions are closed
+// instead, since its socket holds the port; their clients open new sessions
+// with their next datagram.
+func (m *IngressManager) ReplaceRule(rule api.IngressRule) error {
+	if ar := m.detach(rule.RuleID); ar != nil {
+		m.retire(ar, true)
 	}
+	return m.AddRule(rule)
+}
+
+// detach removes the rule with ruleID from the active rules and registers
+// its drain. It returns nil if the manager is inactive or has no such rule.
+func (m *IngressManager) detach(ruleID string) *activeRule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	ar, ok := m.activeRules[ruleID]
-	if !ok {
-		m.mu.Unlock()
-		return
+	if !m.active || !ok {
+		return nil
 	}
 	delete(m.activeRules, ruleID)
-	m.mu.Unlock()
+	m.draining.Add(1)
+	return ar
+}
 
-	ar.cancel()
-	if err := m.ctrl.Close(ar.listener); err != nil {
+// retire stops ar from accepting and drains it in the background, then
+// drops the tracked flows to its listen port unless another rule took the
+// port over. A replaced udp rule is closed before retire returns. The
+// returned channel receives the result.
+func (m *IngressManager) retire(ar *activeRule, replaced bool) <-chan DrainResult {
+	if err := m.stopAccepting(ar); err != nil {
 		m.logger.Error("bridge: ingress: close rule failed",
 			"component", "bridge",
-			"rule_id", ruleID,
+			"rule_id", ar.rule.RuleID,
 			"error", err,
 		)
 	}
-	// Wait for the accept loop goroutine to exit.
-	<-ar.done
+	var closed DrainResult
+	if replaced && ar.udp != nil {
+		closed.Aborted = int(ar.inflight.Load())
+		ar.cancel()
+	}
 
-	m.logger.Info("ingress rule removed",
-		"component", "bridge",
-		"rule_id", ruleID,
-	)
+	results := make(chan DrainResult, 1)
+	go func() {
+		defer m.draining.Done()
+		result := closed
+		if ar.udp == nil || !replaced {
+			result = m.drain(ar, time.Now().Add(m.drainTimeout))
+		}
+
+		if !m.portInUse(ar.rule) {
+			if err := m.flushConntrack(ar); err != nil {
+				m.logger.Error("bridge: ingress: flush conntrack failed",
+					"component", "bridge",
+					"rule_id", ar.rule.RuleID,
+					"error", err,
+				)
+			}
+		}
+
+		m.logger.Info("ingress rule removed",
+			"component", "bridge",
+			"rule_id", ar.rule.RuleID,
+			"drained", result.Drained,
+			"aborted", result.Aborted,
+		)
+		results <- result
+	}()
+	return results
+}
+
+// portInUse reports whether an active rule listens on the port and protocol
+// of rule.
+func (m *IngressManager) portInUse(rule api.IngressRule) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	udp := rule.Mode == "udp"
+	for _, ar := range m.activeRules {
+		if ar.rule.ListenPort == rule.ListenPort && (ar.rule.Mode == "udp") == udp {
+			return true
+		}
+	}
+	return false
+}
+
+// flushConntrack deletes conntrack entries for the rule's listen address and
+// port, using UDP for rules in udp mode and TCP otherwise.
+// It is a no-op when no ConntrackFlusher is set.
+func (m *IngressManager) flushConntrack(ar *activeRule) error {
+	if m.conntrack == nil {
+		return nil
+	}
+	proto := "tcp"
+	if ar.rule.Mode == "udp" {
+		proto = "udp"
+	}
+	return m.conntrack.FlushConntrackPort(proto, ar.listenAddr(), ar.rule.ListenPort)
+}
+
+// listenAddr returns the address the rule's socket is bound to, or the
+// unspecified address if it cannot be determined.
+func (ar *activeRule) listenAddr() netip.Addr {
+	var addr net.Addr
+	switch {
+	case ar.udp != nil:
+		addr = ar.udp.conn.LocalAddr()
+	case ar.listener != nil:
+		addr = ar.listener.Addr()
+	}
+	if addr != nil {
+		if ap, err := netip.ParseAddrPort(addr.String()); err == nil {
+			return ap.Addr().Unmap()
+		}
+	}
+	return netip.IPv6Unspecified()
 }
 
 // GetRule returns the IngressRule for the given ID and true if it exists,
@@ -248,17 +508,46 @@ func (m *IngressManager) acceptLoop(ctx context.Context, ar *activeRule) {
 		}
 
 		m.connCount.Add(1)
-		go m.proxyConnection(ctx, ar.rule, conn)
+		ar.inflight.Add(1)
+		ar.conns.Add(1)
+		go func() {
+			defer func() {
+				ar.inflight.Add(-1)
+				ar.conns.Done()
+			}()
+			m.proxyConnection(ctx, ar.rule, conn)
+		}()
 	}
 }
 
-// proxyConnection dials the target and relays data bidirectionally.
+// proxyConnection dials the target and relays data bidirectionally. When the
+// rule accepts the PROXY protocol, the client's header is read first; when it
+// sends the PROXY protocol, a header is written to the target first.
 func (m *IngressManager) proxyConnection(ctx context.Context, rule api.IngressRule, clientConn net.Conn) {
 	defer func() {
 		clientConn.Close()
 		m.connCount.Add(-1)
 	}()
 
+	src, dst := clientConn.RemoteAddr(), clientConn.LocalAddr()
+	if rule.AcceptProxyProtocol {
+		_ = clientConn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+		hdrSrc, hdrDst, err := readProxyHeaderV2(clientConn)
+		_ = clientConn.SetReadDeadline(time.Time{})
+		if err != nil {
+			m.logger.Warn("bridge: ingress: read PROXY protocol header failed",
+				"component", "bridge",
+				"rule_id", rule.RuleID,
+				"remote", clientConn.RemoteAddr().String(),
+				"error", err,
+			)
+			return
+		}
+		if hdrSrc != nil {
+			src, dst = hdrSrc, hdrDst
+		}
+	}
+
 	// Dial the target with timeout.
 	dialer := net.Dialer{Timeout: m.dialTimeout}
 	targetConn, err := dialer.DialContext(ctx, "tcp", rule.TargetAddr)
@@ -273,6 +562,18 @@ func (m *IngressManager) proxyConnection(ctx context.Context, rule api.IngressRu
 	}
 	defer targetConn.Close()
 
+	if rule.SendProxyProtocol {
+		if err := writeProxyHeaderV2(targetConn, src, dst); err != nil {
+			m.logger.Error("bridge: ingress: write PROXY protocol header failed",
+				"component", "bridge",
+				"rule_id", rule.RuleID,
+				"target", rule.TargetAddr,
+				"error", err,
+			)
+			return
+		}
+	}
+
 	// Bidirectional copy — spawn both directions before select.
 	clientToTarget := make(chan struct{})
 	go func() {
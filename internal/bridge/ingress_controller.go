@@ -17,3 +17,12 @@ type IngressController interface {
 	// Idempotent: closing an already-closed listener returns nil.
 	Close(listener net.Listener) error
 }
+
+// PacketIngressController is implemented by ingress controllers that can
+// open UDP sockets for rules in udp mode. AddRule rejects udp rules when the
+// controller does not implement it.
+type PacketIngressController interface {
+	// ListenPacket opens a UDP socket on the given address.
+	// The manager closes the returned connection itself.
+	ListenPacket(addr string) (net.PacketConn, error)
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/plexsphere/plexd/internal/api"
 	"github.com/plexsphere/plexd/internal/reconcile"
+	"github.com/plexsphere/plexd/internal/validation"
 )
 
 // HandleIngressRuleAssigned returns an api.EventHandler that adds an ingress
@@ -65,49 +66,54 @@ func HandleIngressConfigUpdated(trigger ReconcileTrigger) api.EventHandler {
 // IngressReconcileHandler returns a reconcile.ReconcileHandler that updates
 // ingress rules when the desired IngressConfig changes. It diffs the desired
 // rules against the currently active rules: adding missing rules, removing
-// stale rules, and restarting changed rules (same ID, different config).
+// stale rules, and replacing changed rules (same ID, different config).
+// Removed and replaced rules drain in the background.
+// Desired rules that fail validation (see validation.IngressRules) are
+// neither added nor restarted; their failures are returned as
+// validation.Errors, which the reconciler reports as drift.
 func IngressReconcileHandler(mgr *IngressManager, logger *slog.Logger) reconcile.ReconcileHandler {
 	return func(_ context.Context, desired *api.StateResponse, _ reconcile.StateDiff) error {
 		if desired == nil || desired.IngressConfig == nil {
 			return nil
 		}
 
+		invalid := validation.IngressRules(desired.IngressConfig.Rules, reservedPorts(&mgr.cfg, mgr.portSources))
+		for _, e := range invalid {
+			logger.Warn("ingress reconcile: invalid rule",
+				"rule_id", e.ID,
+				"field", e.Field,
+				"code", e.Code,
+				"error", e.Message,
+			)
+		}
+
 		// Build desired set for diffing.
 		desiredSet := make(map[string]api.IngressRule, len(desired.IngressConfig.Rules))
 		for _, r := range desired.IngressConfig.Rules {
 			desiredSet[r.RuleID] = r
 		}
 
-		currentIDs := mgr.RuleIDs()
-		currentSet := make(map[string]struct{}, len(currentIDs))
-		for _, id := range currentIDs {
-			currentSet[id] = struct{}{}
-		}
-
-		// Remove stale rules (present locally but not in desired state)
-		// and detect changed rules (same ID, different config).
-		for _, id := range currentIDs {
-			desiredRule, inDesired := desiredSet[id]
-			if !inDesired {
-				// Stale rule — remove.
+		// Remove stale rules (present locally but not in desired state).
+		for _, id := range mgr.RuleIDs() {
+			if _, inDesired := desiredSet[id]; !inDesired {
 				mgr.RemoveRule(id)
-				continue
-			}
-			// Check if the rule config changed (requires restart).
-			currentRule, ok := mgr.GetRule(id)
-			if ok && currentRule != desiredRule {
-				mgr.RemoveRule(id)
-				delete(currentSet, id) // mark for re-add below
 			}
 		}
 
-		// Add missing and changed rules.
+		// Add missing rules and replace changed rules (same ID, different
+		// config).
 		var errs []error
 		for _, rule := range desired.IngressConfig.Rules {
-			if _, ok := currentSet[rule.RuleID]; ok {
+			if invalid.Invalid(rule.RuleID) {
 				continue
 			}
-			if err := mgr.AddRule(rule); err != nil {
+			var err error
+			if current, ok := mgr.GetRule(rule.RuleID); !ok {
+				err = mgr.AddRule(rule)
+			} else if current != rule {
+				err = mgr.ReplaceRule(rule)
+			}
+			if err != nil {
 				logger.Error("ingress reconcile: add rule failed",
 					"rule_id", rule.RuleID,
 					"error", err,
@@ -116,6 +122,6 @@ func IngressReconcileHandler(mgr *IngressManager, logger *slog.Logger) reconcile
 			}
 		}
 
-		return errors.Join(errs...)
+		return errors.Join(append([]error{invalid.Err()}, errs...)...)
 	}
 }
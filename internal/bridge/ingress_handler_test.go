@@ -4,11 +4,15 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"io"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/plexsphere/plexd/internal/api"
 	"github.com/plexsphere/plexd/internal/reconcile"
+	"github.com/plexsphere/plexd/internal/validation"
 )
 
 // ---------------------------------------------------------------------------
@@ -120,7 +124,7 @@ func TestHandleIngressConfigUpdated_MalformedPayload(t *testing.T) {
 func TestHandleIngressRuleAssigned(t *testing.T) {
 	ctrl := &mockIngressController{}
 	mgr := newTestIngressManager(t, ctrl)
-	defer func() { _ = mgr.Teardown() }()
+	defer func() { _, _ = mgr.Teardown() }()
 
 	handler := HandleIngressRuleAssigned(mgr, discardLogger())
 
@@ -158,7 +162,7 @@ func TestHandleIngressRuleAssigned(t *testing.T) {
 func TestHandleIngressRuleAssigned_MalformedPayload(t *testing.T) {
 	ctrl := &mockIngressController{}
 	mgr := newTestIngressManager(t, ctrl)
-	defer func() { _ = mgr.Teardown() }()
+	defer func() { _, _ = mgr.Teardown() }()
 
 	handler := HandleIngressRuleAssigned(mgr, discardLogger())
 
@@ -181,7 +185,7 @@ func TestHandleIngressRuleAssigned_MalformedPayload(t *testing.T) {
 func TestHandleIngressRuleRevoked(t *testing.T) {
 	ctrl := &mockIngressController{}
 	mgr := newTestIngressManager(t, ctrl)
-	defer func() { _ = mgr.Teardown() }()
+	defer func() { _, _ = mgr.Teardown() }()
 
 	// Add a rule first.
 	rule := api.IngressRule{
@@ -213,7 +217,7 @@ func TestHandleIngressRuleRevoked(t *testing.T) {
 func TestHandleIngressRuleRevoked_MalformedPayload(t *testing.T) {
 	ctrl := &mockIngressController{}
 	mgr := newTestIngressManager(t, ctrl)
-	defer func() { _ = mgr.Teardown() }()
+	defer func() { _, _ = mgr.Teardown() }()
 
 	handler := HandleIngressRuleRevoked(mgr, discardLogger())
 
@@ -236,7 +240,7 @@ func TestHandleIngressRuleRevoked_MalformedPayload(t *testing.T) {
 func TestIngressReconcileHandler_NilConfig(t *testing.T) {
 	ctrl := &mockIngressController{}
 	mgr := newTestIngressManager(t, ctrl)
-	defer func() { _ = mgr.Teardown() }()
+	defer func() { _, _ = mgr.Teardown() }()
 
 	handler := IngressReconcileHandler(mgr, discardLogger())
 
@@ -259,7 +263,7 @@ func TestIngressReconcileHandler_NilConfig(t *testing.T) {
 func TestIngressReconcileHandler_AddsNewRules(t *testing.T) {
 	ctrl := &mockIngressController{}
 	mgr := newTestIngressManager(t, ctrl)
-	defer func() { _ = mgr.Teardown() }()
+	defer func() { _, _ = mgr.Teardown() }()
 
 	handler := IngressReconcileHandler(mgr, discardLogger())
 
@@ -293,7 +297,7 @@ func TestIngressReconcileHandler_AddsNewRules(t *testing.T) {
 func TestIngressReconcileHandler_RemovesStaleRules(t *testing.T) {
 	ctrl := &mockIngressController{}
 	mgr := newTestIngressManager(t, ctrl)
-	defer func() { _ = mgr.Teardown() }()
+	defer func() { _, _ = mgr.Teardown() }()
 
 	// Pre-populate with two rules.
 	if err := mgr.AddRule(api.IngressRule{RuleID: "rule-1", ListenPort: 0, TargetAddr: "10.0.0.5:8080", Mode: "tcp"}); err != nil {
@@ -340,7 +344,7 @@ func TestIngressReconcileHandler_RemovesStaleRules(t *testing.T) {
 func TestIngressReconcileHandler_DetectsChangedRules(t *testing.T) {
 	ctrl := &mockIngressController{}
 	mgr := newTestIngressManager(t, ctrl)
-	defer func() { _ = mgr.Teardown() }()
+	defer func() { _, _ = mgr.Teardown() }()
 
 	// Pre-populate with a rule targeting port 8080.
 	original := api.IngressRule{RuleID: "rule-1", ListenPort: 0, TargetAddr: "10.0.0.5:8080", Mode: "tcp"}
@@ -386,10 +390,49 @@ func TestIngressReconcileHandler_DetectsChangedRules(t *testing.T) {
 	}
 }
 
+func TestIngressReconcileHandler_ChangedRuleDoesNotWaitForDrain(t *testing.T) {
+	mgr, conn := startDrainTestRule(t, 5*time.Second)
+	defer func() { _, _ = mgr.Teardown() }()
+
+	old, _ := mgr.GetRule("rule-1")
+	changed := old
+	changed.SendProxyProtocol = true
+	desired := &api.StateResponse{
+		IngressConfig: &api.IngressConfig{
+			Enabled: true,
+			Rules:   []api.IngressRule{changed},
+		},
+	}
+
+	handler := IngressReconcileHandler(mgr, discardLogger())
+	start := time.Now()
+	if err := handler(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+		t.Fatalf("handler error = %v, want nil", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("handler took %v, want it not to wait for the old rule to drain", elapsed)
+	}
+
+	got, ok := mgr.GetRule("rule-1")
+	if !ok || !got.SendProxyProtocol {
+		t.Fatalf("rule-1 after reconcile = %+v, %v, want the changed rule", got, ok)
+	}
+
+	// The old rule's connection keeps draining in the background.
+	if _, err := conn.Write([]byte("pong")); err != nil {
+		t.Fatalf("write while draining: %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "pong" {
+		t.Fatalf("echo while draining = %q, %v", buf, err)
+	}
+	conn.Close()
+}
+
 func TestIngressReconcileHandler_UnchangedRulesUntouched(t *testing.T) {
 	ctrl := &mockIngressController{}
 	mgr := newTestIngressManager(t, ctrl)
-	defer func() { _ = mgr.Teardown() }()
+	defer func() { _, _ = mgr.Teardown() }()
 
 	// Pre-populate with a rule.
 	rule := api.IngressRule{RuleID: "rule-1", ListenPort: 0, TargetAddr: "10.0.0.5:8080", Mode: "tcp"}
@@ -426,7 +469,7 @@ func TestIngressReconcileHandler_UnchangedRulesUntouched(t *testing.T) {
 func TestIngressReconcileHandler_Mixed(t *testing.T) {
 	ctrl := &mockIngressController{}
 	mgr := newTestIngressManager(t, ctrl)
-	defer func() { _ = mgr.Teardown() }()
+	defer func() { _, _ = mgr.Teardown() }()
 
 	// Pre-populate with two rules.
 	if err := mgr.AddRule(api.IngressRule{RuleID: "rule-keep", ListenPort: 0, TargetAddr: "10.0.0.5:8080", Mode: "tcp"}); err != nil {
@@ -488,3 +531,35 @@ func TestIngressReconcileHandler_Mixed(t *testing.T) {
 		t.Error("rule-stale should have been removed")
 	}
 }
+
+func TestIngressReconcileHandler_SkipsInvalidRules(t *testing.T) {
+	ctrl := &mockIngressController{}
+	mgr := newTestIngressManager(t, ctrl)
+	defer func() { _, _ = mgr.Teardown() }()
+
+	handler := IngressReconcileHandler(mgr, discardLogger())
+
+	// rule-b takes the port of rule-a.
+	desired := &api.StateResponse{
+		IngressConfig: &api.IngressConfig{
+			Enabled: true,
+			Rules: []api.IngressRule{
+				{RuleID: "rule-a", ListenPort: 18443, TargetAddr: "10.0.0.5:8080", Mode: "tcp"},
+				{RuleID: "rule-b", ListenPort: 18443, TargetAddr: "10.0.0.6:8080", Mode: "tcp"},
+			},
+		},
+	}
+	err := handler(context.Background(), desired, reconcile.StateDiff{})
+
+	var verrs validation.Errors
+	if !errors.As(err, &verrs) || !verrs.Invalid("rule-b") || verrs.Invalid("rule-a") {
+		t.Fatalf("handler error = %v, want a validation failure of rule-b", err)
+	}
+	var dr reconcile.DriftReporter
+	if !errors.As(err, &dr) || len(dr.DriftCorrections()) != 1 {
+		t.Errorf("handler error is not reported as drift: %v", err)
+	}
+	if ids := mgr.RuleIDs(); len(ids) != 1 || ids[0] != "rule-a" {
+		t.Errorf("RuleIDs = %v, want [rule-a]", ids)
+	}
+}
@@ -139,7 +139,7 @@ func TestIngressIntegration_FullLifecycle(t *testing.T) {
 	}
 
 	// --- Step 4: Teardown ---
-	if err := mgr.Teardown(); err != nil {
+	if _, err := mgr.Teardown(); err != nil {
 		t.Fatalf("Teardown: %v", err)
 	}
 	if mgr.IngressStatus() != nil {
@@ -147,7 +147,7 @@ func TestIngressIntegration_FullLifecycle(t *testing.T) {
 	}
 
 	// Second teardown is no-op.
-	if err := mgr.Teardown(); err != nil {
+	if _, err := mgr.Teardown(); err != nil {
 		t.Fatalf("second Teardown: %v", err)
 	}
 }
@@ -238,7 +238,7 @@ func TestIngressIntegration_ReconcileDrift(t *testing.T) {
 	<-done
 
 	// Clean up any remaining listeners/goroutines.
-	_ = mgr.Teardown()
+	_, _ = mgr.Teardown()
 }
 
 // TestIngressIntegration_ConcurrentAccess exercises concurrent SSE events
@@ -361,7 +361,7 @@ func TestIngressIntegration_ConcurrentAccess(t *testing.T) {
 	<-done
 
 	// Clean up any remaining listeners/goroutines.
-	_ = mgr.Teardown()
+	_, _ = mgr.Teardown()
 
 	// Test passes if no race detected. Verify some activity occurred.
 	if n := fetcher.getFetchCount(); n < 2 {
@@ -8,13 +8,17 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
 	"math/big"
 	"net"
+	"net/netip"
 	"testing"
 	"time"
 
 	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/validation"
 )
 
 // ---------------------------------------------------------------------------
@@ -125,7 +129,7 @@ func TestIngressManager_AddRule_StartsListener(t *testing.T) {
 	}
 
 	// Cleanup.
-	_ = mgr.Teardown()
+	_, _ = mgr.Teardown()
 }
 
 func TestIngressManager_AddRule_DuplicateRejects(t *testing.T) {
@@ -165,7 +169,56 @@ func TestIngressManager_AddRule_DuplicateRejects(t *testing.T) {
 	}
 
 	// Cleanup.
-	_ = mgr.Teardown()
+	_, _ = mgr.Teardown()
+}
+
+func TestIngressManager_AddRule_PortConflicts(t *testing.T) {
+	ctrl := &mockIngressController{
+		listenFn: func(addr string, tlsCfg *tls.Config) (net.Listener, error) {
+			return net.Listen("tcp", "127.0.0.1:0")
+		},
+	}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+		IngressEnabled:  true,
+	}
+	cfg.ApplyDefaults()
+
+	mgr := NewIngressManager(ctrl, cfg, discardLogger())
+	mgr.SetPortSources(PortSourceFunc(func() []validation.ReservedPort {
+		return []validation.ReservedPort{{Port: 51820, Owner: "mesh interface"}}
+	}))
+	if err := mgr.Setup(); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	defer func() { _, _ = mgr.Teardown() }()
+
+	if err := mgr.AddRule(api.IngressRule{RuleID: "rule-1", ListenPort: 18443, TargetAddr: "10.0.0.5:8080", Mode: "tcp"}); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		rule api.IngressRule
+	}{
+		{"port of another rule", api.IngressRule{RuleID: "rule-2", ListenPort: 18443, TargetAddr: "10.0.0.5:8080", Mode: "tcp"}},
+		{"port of a port source", api.IngressRule{RuleID: "rule-2", ListenPort: 51820, TargetAddr: "10.0.0.5:53", Mode: "udp"}},
+		{"port of the HA election", api.IngressRule{RuleID: "rule-2", ListenPort: DefaultHAListenPort, TargetAddr: "10.0.0.5:53", Mode: "udp"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := mgr.AddRule(tt.rule)
+			var verrs validation.Errors
+			if !errors.As(err, &verrs) || verrs[0].Code != validation.CodePortConflict {
+				t.Errorf("AddRule = %v, want a port conflict", err)
+			}
+		})
+	}
+	if n := len(ctrl.ingressCallsFor("Listen")); n != 1 {
+		t.Errorf("Listen called %d times, want 1", n)
+	}
 }
 
 func TestIngressManager_AddRule_MaxRulesReject(t *testing.T) {
@@ -214,7 +267,7 @@ func TestIngressManager_AddRule_MaxRulesReject(t *testing.T) {
 	}
 
 	// Cleanup.
-	_ = mgr.Teardown()
+	_, _ = mgr.Teardown()
 }
 
 func TestIngressManager_RemoveRule_StopsListener(t *testing.T) {
@@ -327,7 +380,7 @@ func TestIngressManager_Teardown(t *testing.T) {
 	}
 	ctrl.resetIngress()
 
-	if err := mgr.Teardown(); err != nil {
+	if _, err := mgr.Teardown(); err != nil {
 		t.Fatalf("Teardown: %v", err)
 	}
 
@@ -361,10 +414,10 @@ func TestIngressManager_Teardown_Idempotent(t *testing.T) {
 	mgr := NewIngressManager(ctrl, cfg, discardLogger())
 
 	// Teardown when not active should return nil.
-	if err := mgr.Teardown(); err != nil {
+	if _, err := mgr.Teardown(); err != nil {
 		t.Fatalf("first Teardown: %v", err)
 	}
-	if err := mgr.Teardown(); err != nil {
+	if _, err := mgr.Teardown(); err != nil {
 		t.Fatalf("second Teardown: %v", err)
 	}
 
@@ -422,7 +475,7 @@ func TestIngressManager_Teardown_AggregatesErrors(t *testing.T) {
 		t.Fatalf("AddRule: %v", err)
 	}
 
-	err := mgr.Teardown()
+	_, err := mgr.Teardown()
 	if err == nil {
 		t.Fatal("Teardown should return aggregated errors")
 	}
@@ -479,7 +532,7 @@ func TestIngressManager_IngressStatus_Active(t *testing.T) {
 	}
 
 	// Cleanup.
-	_ = mgr.Teardown()
+	_, _ = mgr.Teardown()
 }
 
 func TestIngressManager_IngressStatus_Inactive(t *testing.T) {
@@ -631,7 +684,7 @@ func TestIngressManager_AddRule_TLSTerminate_ValidCert(t *testing.T) {
 	}
 
 	// Cleanup.
-	_ = mgr.Teardown()
+	_, _ = mgr.Teardown()
 }
 
 func TestIngressManager_AddRule_InactiveRejects(t *testing.T) {
@@ -708,7 +761,7 @@ func TestIngressManager_GetRule(t *testing.T) {
 	if err := mgr.Setup(); err != nil {
 		t.Fatalf("Setup: %v", err)
 	}
-	defer func() { _ = mgr.Teardown() }()
+	defer func() { _, _ = mgr.Teardown() }()
 
 	rule := api.IngressRule{
 		RuleID:     "rule-get",
@@ -766,3 +819,406 @@ func generateSelfSignedCert(t *testing.T) (certPEM string, keyPEM string) {
 
 	return string(certBlock), string(keyBlock)
 }
+
+func TestIngressManager_RemoveRule_FlushesConntrack(t *testing.T) {
+	ctrl := &mockIngressController{
+		listenFn: func(addr string, tlsCfg *tls.Config) (net.Listener, error) {
+			return net.Listen("tcp", "127.0.0.1:0")
+		},
+	}
+	flusher := &mockConntrackRouteController{}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+		IngressEnabled:  true,
+	}
+	cfg.ApplyDefaults()
+
+	mgr := NewIngressManager(ctrl, cfg, discardLogger())
+	mgr.SetConntrackFlusher(flusher)
+	if err := mgr.Setup(); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+
+	rule := api.IngressRule{
+		RuleID:     "rule-1",
+		ListenPort: 8443,
+		TargetAddr: "10.0.0.5:8080",
+		Mode:       "tcp",
+	}
+	if err := mgr.AddRule(rule); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+
+	<-mgr.RemoveRule("rule-1")
+
+	calls := flusher.callsFor("FlushConntrackPort")
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 FlushConntrackPort call, got %d", len(calls))
+	}
+	if calls[0].Args[0] != "tcp" || calls[0].Args[1] != netip.MustParseAddr("127.0.0.1") || calls[0].Args[2] != 8443 {
+		t.Errorf("FlushConntrackPort args = %v, want [tcp 127.0.0.1 8443]", calls[0].Args)
+	}
+}
+
+func TestIngressManager_Teardown_ConntrackError(t *testing.T) {
+	ctrl := &mockIngressController{
+		listenFn: func(addr string, tlsCfg *tls.Config) (net.Listener, error) {
+			return net.Listen("tcp", "127.0.0.1:0")
+		},
+	}
+	flusher := &mockConntrackRouteController{flushConntrackErr: fmt.Errorf("conntrack failed")}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+		IngressEnabled:  true,
+	}
+	cfg.ApplyDefaults()
+
+	mgr := NewIngressManager(ctrl, cfg, discardLogger())
+	mgr.SetConntrackFlusher(flusher)
+	if err := mgr.Setup(); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := mgr.AddRule(api.IngressRule{RuleID: "rule-1", TargetAddr: "10.0.0.5:8080", Mode: "tcp"}); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+
+	if _, err := mgr.Teardown(); err == nil {
+		t.Fatal("Teardown should return the conntrack flush error")
+	}
+	if mgr.IngressStatus() != nil {
+		t.Error("IngressStatus should be nil after teardown")
+	}
+}
+
+// startDrainTestRule starts an ingress manager with the given drain timeout
+// and one rule proxying to a TCP echo server, and returns the manager and a
+// client connection whose echo has gone through the proxy.
+func startDrainTestRule(t *testing.T, drainTimeout time.Duration) (*IngressManager, net.Conn) {
+	t.Helper()
+
+	echoLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("echo listener: %v", err)
+	}
+	t.Cleanup(func() { echoLn.Close() })
+	go func() {
+		for {
+			conn, err := echoLn.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				_, _ = io.Copy(c, c)
+			}(conn)
+		}
+	}()
+
+	ctrl := &mockIngressController{
+		listenFn: func(addr string, tlsCfg *tls.Config) (net.Listener, error) {
+			return net.Listen("tcp", "127.0.0.1:0")
+		},
+	}
+	cfg := Config{
+		Enabled:             true,
+		AccessInterface:     "eth1",
+		AccessSubnets:       []string{"10.0.0.0/24"},
+		IngressEnabled:      true,
+		IngressDrainTimeout: drainTimeout,
+	}
+	cfg.ApplyDefaults()
+
+	mgr := NewIngressManager(ctrl, cfg, discardLogger())
+	if err := mgr.Setup(); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := mgr.AddRule(api.IngressRule{RuleID: "rule-1", TargetAddr: echoLn.Addr().String(), Mode: "tcp"}); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+
+	mgr.mu.Lock()
+	addr := mgr.activeRules["rule-1"].listener.Addr().String()
+	mgr.mu.Unlock()
+
+	conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
+	if err != nil {
+		t.Fatalf("dial ingress listener: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return mgr, conn
+}
+
+func TestIngressManager_RemoveRule_DrainsInFlight(t *testing.T) {
+	mgr, conn := startDrainTestRule(t, 5*time.Second)
+
+	results := mgr.RemoveRule("rule-1")
+	if ids := mgr.RuleIDs(); len(ids) != 0 {
+		t.Errorf("RuleIDs while draining = %v, want empty", ids)
+	}
+
+	// The in-flight connection keeps working while the rule drains.
+	select {
+	case r := <-results:
+		t.Fatalf("RemoveRule returned %+v before the connection finished", r)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, err := conn.Write([]byte("pong")); err != nil {
+		t.Fatalf("write while draining: %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "pong" {
+		t.Fatalf("echo while draining = %q, %v", buf, err)
+	}
+
+	conn.Close()
+	select {
+	case r := <-results:
+		if r != (DrainResult{Drained: 1}) {
+			t.Errorf("RemoveRule = %+v, want {Drained:1 Aborted:0}", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RemoveRule did not return after the connection closed")
+	}
+}
+
+func TestIngressManager_Teardown_AbortsAfterDrainTimeout(t *testing.T) {
+	mgr, conn := startDrainTestRule(t, 100*time.Millisecond)
+
+	start := time.Now()
+	result, err := mgr.Teardown()
+	if err != nil {
+		t.Fatalf("Teardown: %v", err)
+	}
+	if result != (DrainResult{Aborted: 1}) {
+		t.Errorf("Teardown = %+v, want {Drained:0 Aborted:1}", result)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Teardown returned after %v, before the drain timeout", elapsed)
+	}
+
+	// The aborted connection is closed by the proxy.
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("read on aborted connection should fail")
+	}
+}
+
+func TestIngressManager_Teardown_NoInFlight(t *testing.T) {
+	mgr, conn := startDrainTestRule(t, 5*time.Second)
+	conn.Close()
+
+	// Wait until the proxy has noticed the close.
+	deadline := time.Now().Add(2 * time.Second)
+	for mgr.IngressStatus().ConnectionCount != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	start := time.Now()
+	result, err := mgr.Teardown()
+	if err != nil {
+		t.Fatalf("Teardown: %v", err)
+	}
+	if result != (DrainResult{}) {
+		t.Errorf("Teardown = %+v, want zero result", result)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Teardown took %v without in-flight connections", elapsed)
+	}
+}
+
+// startProxyProtocolRule starts an ingress manager with one rule proxying to
+// a target that reads a PROXY protocol v2 header from each connection and
+// reports its source address, then echoes the payload.
+func startProxyProtocolRule(t *testing.T, rule api.IngressRule) (*IngressManager, string, <-chan net.Addr) {
+	t.Helper()
+
+	targetLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("target listener: %v", err)
+	}
+	t.Cleanup(func() { targetLn.Close() })
+	sources := make(chan net.Addr, 1)
+	go func() {
+		for {
+			conn, err := targetLn.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				src, _, err := readProxyHeaderV2(c)
+				if err != nil {
+					return
+				}
+				sources <- src
+				_, _ = io.Copy(c, c)
+			}(conn)
+		}
+	}()
+
+	ctrl := &mockIngressController{
+		listenFn: func(addr string, tlsCfg *tls.Config) (net.Listener, error) {
+			return net.Listen("tcp", "127.0.0.1:0")
+		},
+	}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+		IngressEnabled:  true,
+	}
+	cfg.ApplyDefaults()
+
+	mgr := NewIngressManager(ctrl, cfg, discardLogger())
+	if err := mgr.Setup(); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	t.Cleanup(func() { _, _ = mgr.Teardown() })
+	rule.TargetAddr = targetLn.Addr().String()
+	if err := mgr.AddRule(rule); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+
+	mgr.mu.Lock()
+	addr := mgr.activeRules[rule.RuleID].listener.Addr().String()
+	mgr.mu.Unlock()
+	return mgr, addr, sources
+}
+
+// echoThrough writes msg on conn and checks that it is echoed back.
+func echoThrough(t *testing.T, conn net.Conn, msg string) {
+	t.Helper()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	buf := make([]byte, len(msg))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(buf) != msg {
+		t.Errorf("echo = %q, want %q", buf, msg)
+	}
+}
+
+func TestIngressManager_SendProxyProtocol(t *testing.T) {
+	_, addr, sources := startProxyProtocolRule(t, api.IngressRule{
+		RuleID:            "rule-pp",
+		Mode:              "tcp",
+		SendProxyProtocol: true,
+	})
+
+	conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	echoThrough(t, conn, "hello")
+
+	select {
+	case src := <-sources:
+		if src.String() != conn.LocalAddr().String() {
+			t.Errorf("target saw source %v, want client address %v", src, conn.LocalAddr())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("target did not receive a PROXY protocol header")
+	}
+}
+
+func TestIngressManager_AcceptProxyProtocol(t *testing.T) {
+	_, addr, sources := startProxyProtocolRule(t, api.IngressRule{
+		RuleID:              "rule-pp",
+		Mode:                "passthrough",
+		SendProxyProtocol:   true,
+		AcceptProxyProtocol: true,
+	})
+
+	conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Act as an upstream load balancer forwarding a client at 203.0.113.7.
+	client := &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 51000}
+	if err := writeProxyHeaderV2(conn, client, &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 443}); err != nil {
+		t.Fatalf("write header: %v", err)
+	}
+	echoThrough(t, conn, "hello")
+
+	select {
+	case src := <-sources:
+		if src.String() != client.String() {
+			t.Errorf("target saw source %v, want upstream client %v", src, client)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("target did not receive a PROXY protocol header")
+	}
+}
+
+func TestIngressManager_AcceptProxyProtocol_RejectsMissingHeader(t *testing.T) {
+	_, addr, sources := startProxyProtocolRule(t, api.IngressRule{
+		RuleID:              "rule-pp",
+		Mode:                "tcp",
+		SendProxyProtocol:   true,
+		AcceptProxyProtocol: true,
+	})
+
+	conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: example\r\n\r\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("connection without a PROXY header should be closed")
+	}
+	select {
+	case src := <-sources:
+		t.Errorf("target was dialed for a rejected connection (source %v)", src)
+	default:
+	}
+}
+
+func TestIngressManager_AddRule_AcceptProxyProtocolTerminateRejects(t *testing.T) {
+	ctrl := &mockIngressController{}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+		IngressEnabled:  true,
+	}
+	cfg.ApplyDefaults()
+
+	mgr := NewIngressManager(ctrl, cfg, discardLogger())
+	if err := mgr.Setup(); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	err := mgr.AddRule(api.IngressRule{
+		RuleID:              "rule-pp",
+		TargetAddr:          "10.0.0.5:8080",
+		Mode:                "terminate",
+		AcceptProxyProtocol: true,
+	})
+	if err == nil {
+		t.Fatal("AddRule should reject accepting PROXY protocol in terminate mode")
+	}
+	if len(ctrl.ingressCallsFor("Listen")) != 0 {
+		t.Error("Listen should not be called for a rejected rule")
+	}
+}
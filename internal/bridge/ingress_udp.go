@@ -0,0 +1,274 @@
+package bridge
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// udpIngressBufSize is the largest datagram a udp mode rule forwards.
+const udpIngressBufSize = 65535
+
+// errUDPSessionLimit is returned when a udp mode rule has no session left
+// for a new client.
+var errUDPSessionLimit = errors.New("bridge: ingress: udp session limit reached")
+
+// errUDPDraining is returned when a udp mode rule that stopped accepting
+// receives a datagram from a new client.
+var errUDPDraining = errors.New("bridge: ingress: rule is draining")
+
+// udpSession is one client flow of a udp mode rule. Each session has its own
+// connected socket to the target, so replies map back to the client.
+type udpSession struct {
+	client   net.Addr
+	target   net.Conn
+	lastSeen atomic.Int64 // unix nanoseconds of the last datagram either way
+}
+
+// touch records traffic on the session.
+func (s *udpSession) touch() {
+	s.lastSeen.Store(time.Now().UnixNano())
+}
+
+// udpForwarder forwards the datagrams of a udp mode rule. It keeps a flow
+// table keyed by client address and closes sessions that stay idle for the
+// session timeout.
+type udpForwarder struct {
+	ar          *activeRule
+	conn        net.PacketConn
+	timeout     time.Duration
+	maxSessions int
+	dialTimeout time.Duration
+	connCount   *atomic.Int64
+	logger      *slog.Logger
+
+	// dial opens the target socket of a session; net.DialTimeout unless
+	// replaced in tests.
+	dial func(network, addr string, timeout time.Duration) (net.Conn, error)
+
+	served chan struct{} // closed when serve exits
+
+	mu        sync.Mutex
+	sessions  map[string]*udpSession // keyed by client address
+	accepting bool
+	closed    bool
+}
+
+// newUDPForwarder creates a forwarder for ar reading from conn. Zero limits
+// fall back to the package defaults.
+func newUDPForwarder(ar *activeRule, conn net.PacketConn, m *IngressManager) *udpForwarder {
+	timeout := m.cfg.IngressUDPSessionTimeout
+	if timeout <= 0 {
+		timeout = DefaultIngressUDPSessionTimeout
+	}
+	maxSessions := m.cfg.MaxIngressUDPSessions
+	if maxSessions <= 0 {
+		maxSessions = DefaultMaxIngressUDPSessions
+	}
+	return &udpForwarder{
+		ar:          ar,
+		conn:        conn,
+		timeout:     timeout,
+		maxSessions: maxSessions,
+		dialTimeout: m.dialTimeout,
+		dial:        net.DialTimeout,
+		connCount:   &m.connCount,
+		logger:      m.logger,
+		served:      make(chan struct{}),
+		sessions:    make(map[string]*udpSession),
+		accepting:   true,
+	}
+}
+
+// serve reads client datagrams and forwards them to the target until the
+// socket is closed.
+func (u *udpForwarder) serve() {
+	defer close(u.served)
+	buf := make([]byte, udpIngressBufSize)
+	for {
+		n, client, err := u.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// Transient errors such as ICMP feedback do not stop the rule.
+			continue
+		}
+
+		s, err := u.session(client)
+		if err != nil {
+			u.logger.Debug("bridge: ingress: udp datagram dropped",
+				"component", "bridge",
+				"rule_id", u.ar.rule.RuleID,
+				"remote", client.String(),
+				"error", err,
+			)
+			continue
+		}
+		s.touch()
+		if _, err := s.target.Write(buf[:n]); err != nil {
+			u.logger.Debug("bridge: ingress: udp write to target failed",
+				"component", "bridge",
+				"rule_id", u.ar.rule.RuleID,
+				"target", u.ar.rule.TargetAddr,
+				"error", err,
+			)
+		}
+	}
+}
+
+// session returns the session for client, opening one when the rule still
+// accepts new sessions.
+func (u *udpForwarder) session(client net.Addr) (*udpSession, error) {
+	key := client.String()
+
+	u.mu.Lock()
+	if s, ok := u.sessions[key]; ok {
+		u.mu.Unlock()
+		return s, nil
+	}
+	if !u.accepting {
+		u.mu.Unlock()
+		return nil, errUDPDraining
+	}
+	if len(u.sessions) >= u.maxSessions {
+		u.mu.Unlock()
+		return nil, errUDPSessionLimit
+	}
+	u.mu.Unlock()
+
+	// Only serve opens sessions, so dialing outside the lock cannot race
+	// with another open for the same client.
+	target, err := u.dial("udp", u.ar.rule.TargetAddr, u.dialTimeout)
+	if err != nil {
+		u.logger.Error("bridge: ingress: dial target failed",
+			"component", "bridge",
+			"rule_id", u.ar.rule.RuleID,
+			"target", u.ar.rule.TargetAddr,
+			"error", err,
+		)
+		return nil, err
+	}
+
+	s := &udpSession{client: client, target: target}
+	s.touch()
+
+	// The rule may have stopped accepting during the dial; its drain must
+	// not count a session added after it started waiting.
+	u.mu.Lock()
+	if u.closed {
+		u.mu.Unlock()
+		target.Close()
+		return nil, net.ErrClosed
+	}
+	if !u.accepting {
+		u.mu.Unlock()
+		target.Close()
+		return nil, errUDPDraining
+	}
+	u.sessions[key] = s
+	u.connCount.Add(1)
+	u.ar.inflight.Add(1)
+	u.ar.conns.Add(1)
+	u.mu.Unlock()
+
+	go u.relayReplies(key, s)
+	return s, nil
+}
+
+// relayReplies forwards target datagrams back to the client until the
+// session is idle for the session timeout, the target fails, or the
+// forwarder closes.
+func (u *udpForwarder) relayReplies(key string, s *udpSession) {
+	defer func() {
+		u.mu.Lock()
+		if u.sessions[key] == s {
+			delete(u.sessions, key)
+		}
+		u.mu.Unlock()
+		s.target.Close()
+		u.connCount.Add(-1)
+		u.ar.inflight.Add(-1)
+		u.ar.conns.Done()
+	}()
+
+	buf := make([]byte, udpIngressBufSize)
+	for {
+		deadline := time.Unix(0, s.lastSeen.Load()).Add(u.timeout)
+		_ = s.target.SetReadDeadline(deadline)
+		n, err := s.target.Read(buf)
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				// Client datagrams may have moved the deadline on.
+				if time.Since(time.Unix(0, s.lastSeen.Load())) < u.timeout {
+					continue
+				}
+			}
+			return
+		}
+		s.touch()
+		if _, err := u.conn.WriteTo(buf[:n], s.client); err != nil && errors.Is(err, net.ErrClosed) {
+			return
+		}
+	}
+}
+
+// stopAccepting stops opening sessions for new clients. Open sessions keep
+// forwarding until they go idle or the forwarder closes.
+func (u *udpForwarder) stopAccepting() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.accepting {
+		u.accepting = false
+		close(u.ar.done)
+	}
+}
+
+// close closes the socket and all open sessions and waits for serve to exit.
+// Idempotent.
+func (u *udpForwarder) close() {
+	u.stopAccepting()
+
+	u.mu.Lock()
+	if u.closed {
+		u.mu.Unlock()
+		<-u.served
+		return
+	}
+	u.closed = true
+	for _, s := range u.sessions {
+		s.target.Close()
+	}
+	u.mu.Unlock()
+
+	u.conn.Close()
+	<-u.served
+}
+
+// startUDPRule opens the UDP socket for a udp mode rule and starts
+// forwarding. The caller holds m.mu.
+func (m *IngressManager) startUDPRule(rule api.IngressRule, addr string) (*activeRule, error) {
+	pc, ok := m.ctrl.(PacketIngressController)
+	if !ok {
+		return nil, errors.New("ingress controller does not support udp")
+	}
+	conn, err := pc.ListenPacket(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ar := &activeRule{
+		rule: rule,
+		done: make(chan struct{}),
+	}
+	ar.udp = newUDPForwarder(ar, conn, m)
+	ar.cancel = ar.udp.close
+	go ar.udp.serve()
+	return ar, nil
+}
@@ -0,0 +1,286 @@
+package bridge
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// startUDPEchoTarget starts a UDP echo server and returns its address.
+func startUDPEchoTarget(t *testing.T) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("echo socket: %v", err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = pc.WriteTo(buf[:n], addr)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+// startUDPRule starts an ingress manager with one udp mode rule forwarding to
+// a UDP echo server and returns the manager and the rule's socket address.
+func startUDPRule(t *testing.T, cfg Config) (*IngressManager, string) {
+	t.Helper()
+
+	cfg.Enabled = true
+	cfg.AccessInterface = "eth1"
+	cfg.AccessSubnets = []string{"10.0.0.0/24"}
+	cfg.IngressEnabled = true
+	cfg.ApplyDefaults()
+
+	mgr := NewIngressManager(&mockPacketIngressController{}, cfg, discardLogger())
+	if err := mgr.Setup(); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	t.Cleanup(func() { _, _ = mgr.Teardown() })
+
+	rule := api.IngressRule{RuleID: "rule-udp", TargetAddr: startUDPEchoTarget(t), Mode: "udp"}
+	if err := mgr.AddRule(rule); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+
+	mgr.mu.Lock()
+	addr := mgr.activeRules["rule-udp"].udp.conn.LocalAddr().String()
+	mgr.mu.Unlock()
+	return mgr, addr
+}
+
+// udpEcho sends msg through conn and returns the reply, or an error when
+// none arrives within timeout.
+func udpEcho(conn net.Conn, msg string, timeout time.Duration) (string, error) {
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		return "", err
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return string(buf[:n]), nil
+}
+
+func dialUDP(t *testing.T, addr string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatalf("dial %s: %v", addr, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func waitConnectionCount(t *testing.T, mgr *IngressManager, want int) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for mgr.IngressStatus().ConnectionCount != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("ConnectionCount = %d, want %d", mgr.IngressStatus().ConnectionCount, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestIngressManager_UDP_ForwardsDatagrams(t *testing.T) {
+	mgr, addr := startUDPRule(t, Config{})
+
+	a, b := dialUDP(t, addr), dialUDP(t, addr)
+	for _, tc := range []struct {
+		conn net.Conn
+		msg  string
+	}{{a, "ping-a"}, {b, "ping-b"}, {a, "again-a"}} {
+		got, err := udpEcho(tc.conn, tc.msg, 2*time.Second)
+		if err != nil {
+			t.Fatalf("echo %q: %v", tc.msg, err)
+		}
+		if got != tc.msg {
+			t.Errorf("echo = %q, want %q", got, tc.msg)
+		}
+	}
+
+	// One session per client.
+	if got := mgr.IngressStatus().ConnectionCount; got != 2 {
+		t.Errorf("ConnectionCount = %d, want 2", got)
+	}
+}
+
+func TestIngressManager_UDP_IdleSessionExpires(t *testing.T) {
+	mgr, addr := startUDPRule(t, Config{IngressUDPSessionTimeout: 200 * time.Millisecond})
+
+	conn := dialUDP(t, addr)
+	if _, err := udpEcho(conn, "ping", 2*time.Second); err != nil {
+		t.Fatalf("echo: %v", err)
+	}
+	waitConnectionCount(t, mgr, 0)
+
+	// A new datagram opens a new session.
+	if _, err := udpEcho(conn, "ping", 2*time.Second); err != nil {
+		t.Fatalf("echo after expiry: %v", err)
+	}
+	if got := mgr.IngressStatus().ConnectionCount; got != 1 {
+		t.Errorf("ConnectionCount = %d, want 1", got)
+	}
+}
+
+func TestIngressManager_UDP_SessionLimit(t *testing.T) {
+	mgr, addr := startUDPRule(t, Config{MaxIngressUDPSessions: 1})
+
+	if _, err := udpEcho(dialUDP(t, addr), "first", 2*time.Second); err != nil {
+		t.Fatalf("echo first client: %v", err)
+	}
+	if _, err := udpEcho(dialUDP(t, addr), "second", 200*time.Millisecond); err == nil {
+		t.Error("second client got a reply beyond the session limit")
+	}
+	if got := mgr.IngressStatus().ConnectionCount; got != 1 {
+		t.Errorf("ConnectionCount = %d, want 1", got)
+	}
+}
+
+func TestIngressManager_UDP_RemoveRuleDrainsIdleSessions(t *testing.T) {
+	mgr, addr := startUDPRule(t, Config{
+		IngressUDPSessionTimeout: 200 * time.Millisecond,
+		IngressDrainTimeout:      5 * time.Second,
+	})
+
+	conn := dialUDP(t, addr)
+	if _, err := udpEcho(conn, "ping", 2*time.Second); err != nil {
+		t.Fatalf("echo: %v", err)
+	}
+
+	result := <-mgr.RemoveRule("rule-udp")
+	if result != (DrainResult{Drained: 1}) {
+		t.Errorf("RemoveRule = %+v, want {Drained:1 Aborted:0}", result)
+	}
+	if _, err := udpEcho(conn, "ping", 200*time.Millisecond); err == nil {
+		t.Error("removed rule still forwards datagrams")
+	}
+}
+
+func TestIngressManager_UDP_StopAcceptingDuringDial(t *testing.T) {
+	mgr, _ := startUDPRule(t, Config{})
+	mgr.mu.Lock()
+	ar := mgr.activeRules["rule-udp"]
+	mgr.mu.Unlock()
+
+	// The rule stops accepting while the session's target is dialed.
+	var target net.Conn
+	ar.udp.dial = func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		ar.udp.stopAccepting()
+		conn, err := net.DialTimeout(network, addr, timeout)
+		target = conn
+		return conn, err
+	}
+
+	client := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 40000}
+	if _, err := ar.udp.session(client); !errors.Is(err, errUDPDraining) {
+		t.Fatalf("session error = %v, want errUDPDraining", err)
+	}
+	if n := ar.inflight.Load(); n != 0 {
+		t.Errorf("in-flight sessions = %d, want 0", n)
+	}
+	if _, err := target.Write([]byte("ping")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("write on the dialed target = %v, want net.ErrClosed", err)
+	}
+}
+
+func TestIngressManager_UDP_TeardownAbortsAfterDrainTimeout(t *testing.T) {
+	mgr, addr := startUDPRule(t, Config{IngressDrainTimeout: 100 * time.Millisecond})
+
+	if _, err := udpEcho(dialUDP(t, addr), "ping", 2*time.Second); err != nil {
+		t.Fatalf("echo: %v", err)
+	}
+
+	result, err := mgr.Teardown()
+	if err != nil {
+		t.Fatalf("Teardown: %v", err)
+	}
+	if result != (DrainResult{Aborted: 1}) {
+		t.Errorf("Teardown = %+v, want {Drained:0 Aborted:1}", result)
+	}
+}
+
+func TestIngressManager_UDP_FlushesConntrack(t *testing.T) {
+	cfg := Config{Enabled: true, IngressEnabled: true}
+	cfg.ApplyDefaults()
+	flusher := &mockConntrackRouteController{}
+	mgr := NewIngressManager(&mockPacketIngressController{}, cfg, discardLogger())
+	mgr.SetConntrackFlusher(flusher)
+	if err := mgr.Setup(); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := mgr.AddRule(api.IngressRule{RuleID: "rule-udp", ListenPort: 5353, TargetAddr: "127.0.0.1:53", Mode: "udp"}); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	<-mgr.RemoveRule("rule-udp")
+
+	calls := flusher.callsFor("FlushConntrackPort")
+	if len(calls) != 1 {
+		t.Fatalf("FlushConntrackPort calls = %d, want 1", len(calls))
+	}
+	if calls[0].Args[0] != "udp" || calls[0].Args[1] != netip.MustParseAddr("127.0.0.1") || calls[0].Args[2] != 5353 {
+		t.Errorf("FlushConntrackPort args = %v, want [udp 127.0.0.1 5353]", calls[0].Args)
+	}
+}
+
+func TestIngressManager_UDP_AddRuleRejects(t *testing.T) {
+	cfg := Config{Enabled: true, IngressEnabled: true}
+	cfg.ApplyDefaults()
+
+	tests := []struct {
+		name    string
+		ctrl    IngressController
+		rule    api.IngressRule
+		wantErr string
+	}{
+		{
+			name:    "controller without UDP support",
+			ctrl:    &mockIngressController{},
+			rule:    api.IngressRule{RuleID: "r", TargetAddr: "127.0.0.1:53", Mode: "udp"},
+			wantErr: "ingress controller does not support udp",
+		},
+		{
+			name:    "send PROXY protocol",
+			ctrl:    &mockPacketIngressController{},
+			rule:    api.IngressRule{RuleID: "r", TargetAddr: "127.0.0.1:53", Mode: "udp", SendProxyProtocol: true},
+			wantErr: "PROXY protocol is not supported in udp mode",
+		},
+		{
+			name:    "accept PROXY protocol",
+			ctrl:    &mockPacketIngressController{},
+			rule:    api.IngressRule{RuleID: "r", TargetAddr: "127.0.0.1:53", Mode: "udp", AcceptProxyProtocol: true},
+			wantErr: "PROXY protocol is not supported in udp mode",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr := NewIngressManager(tt.ctrl, cfg, discardLogger())
+			if err := mgr.Setup(); err != nil {
+				t.Fatalf("Setup: %v", err)
+			}
+			defer func() { _, _ = mgr.Teardown() }()
+
+			err := mgr.AddRule(tt.rule)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("AddRule error = %v, want containing %q", err, tt.wantErr)
+			}
+			if _, ok := mgr.GetRule("r"); ok {
+				t.Error("rejected rule should not be active")
+			}
+		})
+	}
+}
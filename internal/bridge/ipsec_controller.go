@@ -0,0 +1,117 @@
+package bridge
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/validation"
+)
+
+// IPsecController abstracts the OS-level operations of site-to-site tunnels
+// of type ipsec. The traffic of a tunnel is routed through an XFRM
+// interface, and the IKE daemon negotiates the SAs bound to it, so routes,
+// NAT maps, and MSS clamping work as for WireGuard interfaces.
+// All methods must be idempotent: repeating an operation that is already applied returns nil.
+type IPsecController interface {
+	// CreateIPsecInterface creates the XFRM interface name for the SAs with
+	// interface ID ifID and sets it up.
+	// Idempotent: creating an already-existing interface with the same ID returns nil.
+	CreateIPsecInterface(name string, ifID uint32) error
+
+	// RemoveIPsecInterface removes the XFRM interface with the given name.
+	// Idempotent: removing a non-existent interface returns nil.
+	RemoveIPsecInterface(name string) error
+
+	// LoadIPsecConn loads the IKEv2 connection and its pre-shared key,
+	// replacing a loaded connection of the same name, and initiates it.
+	// Idempotent: re-applying the same connection returns nil.
+	LoadIPsecConn(conn IPsecConn) error
+
+	// UnloadIPsecConn terminates the SAs of the connection name and unloads
+	// it and its pre-shared key.
+	// Idempotent: unloading a connection that is not loaded returns nil.
+	UnloadIPsecConn(name string) error
+}
+
+// IPsecConn is the IKEv2 connection of an ipsec tunnel.
+type IPsecConn struct {
+	// Name names the connection, its CHILD_SA, and its pre-shared key.
+	Name string
+	// RemoteHost is the address or DNS name of the remote site. It is also
+	// the IKE identity the remote site must authenticate with.
+	RemoteHost string
+	// RemotePort is the IKE port of the remote site; 0 means 500.
+	RemotePort int
+	// LocalTS and RemoteTS are the traffic selectors of the CHILD_SA.
+	LocalTS  []string
+	RemoteTS []string
+	PSK      string
+	// IfID binds the SAs to the XFRM interface with the same ID.
+	IfID uint32
+}
+
+// IPsecStatsReader is implemented by IPsec controllers that can read the
+// traffic of a connection. SiteToSiteManager includes it in
+// SiteToSiteStatus like TunnelStatsReader for WireGuard tunnels.
+type IPsecStatsReader interface {
+	// IPsecConnStats returns the traffic of the SAs of the connection name.
+	// LastHandshake is when the IKE SA was established.
+	IPsecConnStats(name string) (TunnelPeerStats, error)
+}
+
+// errNoIPsecController is returned for ipsec tunnels when no IPsec
+// controller is set.
+var errNoIPsecController = errors.New("ipsec tunnels are not supported: no IPsec controller")
+
+// errNoIPsecStats is reported when the IPsec controller cannot read tunnel
+// traffic.
+const errNoIPsecStats = "IPsec controller does not report traffic statistics"
+
+// ipsecIfIDBase is the first XFRM interface ID of ipsec tunnels, above the
+// IDs that hand-written strongSwan configurations typically use.
+const ipsecIfIDBase = 100
+
+// IKE ports charon listens on while ipsec tunnels are active.
+const (
+	ikePort     = 500
+	ikeNATTPort = 4500
+)
+
+// ipsecConnPrefix prefixes the connection names of ipsec tunnels, so that
+// they do not collide with connections configured on the host.
+const ipsecConnPrefix = "plexd-"
+
+// isIPsec reports whether tunnel is of type ipsec.
+func isIPsec(tunnel api.SiteToSiteTunnel) bool {
+	return tunnel.Type == api.TunnelTypeIPsec
+}
+
+// ipsecConnName returns the connection name of the tunnel with the given ID.
+func ipsecConnName(tunnelID string) string {
+	return ipsecConnPrefix + tunnelID
+}
+
+// ipsecConn returns the connection of an ipsec tunnel bound to ifID. The
+// local subnet of a NAT map is announced under the subnet it is mapped to,
+// since the remote site only sees translated addresses.
+func ipsecConn(tunnel api.SiteToSiteTunnel, ifID uint32) IPsecConn {
+	host, port, _ := validation.SplitEndpoint(tunnel.RemoteEndpoint)
+	local := slices.Clone(tunnel.LocalSubnets)
+	if nm := tunnel.NATMap; nm != nil {
+		for i, s := range local {
+			if s == nm.Local {
+				local[i] = nm.As
+			}
+		}
+	}
+	return IPsecConn{
+		Name:       ipsecConnName(tunnel.TunnelID),
+		RemoteHost: host,
+		RemotePort: port,
+		LocalTS:    local,
+		RemoteTS:   slices.Clone(tunnel.RemoteSubnets),
+		PSK:        tunnel.PSK,
+		IfID:       ifID,
+	}
+}
@@ -0,0 +1,65 @@
+//go:build linux
+
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+)
+
+// CreateIPsecInterface creates the XFRM interface name bound to ifID and sets
+// it up. An existing interface of the name with another ID or link type is
+// replaced.
+func (c *VICIController) CreateIPsecInterface(name string, ifID uint32) error {
+	if existing, err := netlink.LinkByName(name); err == nil {
+		if x, ok := existing.(*netlink.Xfrmi); !ok || x.Ifid != ifID {
+			if err := netlink.LinkDel(existing); err != nil {
+				return fmt.Errorf("bridge: create ipsec interface %q: replace: %w", name, err)
+			}
+		}
+	}
+
+	la := netlink.NewLinkAttrs()
+	la.Name = name
+	if err := netlink.LinkAdd(&netlink.Xfrmi{LinkAttrs: la, Ifid: ifID}); err != nil && !errors.Is(err, syscall.EEXIST) {
+		return fmt.Errorf("bridge: create ipsec interface %q: %w", name, err)
+	}
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return fmt.Errorf("bridge: create ipsec interface %q: lookup: %w", name, err)
+	}
+	if err := netlink.LinkSetUp(link); err != nil {
+		return fmt.Errorf("bridge: create ipsec interface %q: set up: %w", name, err)
+	}
+
+	c.logger.Debug("ipsec interface created",
+		"component", "bridge",
+		"interface", name,
+		"if_id", ifID,
+	)
+	return nil
+}
+
+// RemoveIPsecInterface removes the XFRM interface name.
+func (c *VICIController) RemoveIPsecInterface(name string) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		var notFound netlink.LinkNotFoundError
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return fmt.Errorf("bridge: remove ipsec interface %q: %w", name, err)
+	}
+	if err := netlink.LinkDel(link); err != nil {
+		return fmt.Errorf("bridge: remove ipsec interface %q: %w", name, err)
+	}
+
+	c.logger.Debug("ipsec interface removed",
+		"component", "bridge",
+		"interface", name,
+	)
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package bridge
+
+import "errors"
+
+// errNoXFRM is returned on platforms without XFRM interfaces.
+var errNoXFRM = errors.New("bridge: ipsec interfaces are only supported on linux")
+
+// CreateIPsecInterface is unavailable on non-Linux platforms.
+func (c *VICIController) CreateIPsecInterface(_ string, _ uint32) error {
+	return errNoXFRM
+}
+
+// RemoveIPsecInterface returns nil: there are no XFRM interfaces to remove
+// on non-Linux platforms.
+func (c *VICIController) RemoveIPsecInterface(_ string) error {
+	return nil
+}
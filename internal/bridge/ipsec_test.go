@@ -0,0 +1,229 @@
+package bridge
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+func newIPsecTestManager(t *testing.T, ipsec IPsecController) (*SiteToSiteManager, *mockVPNController, *mockMappingRouteController) {
+	t.Helper()
+	routes := &mockMappingRouteController{}
+	mgr, vpn := newNATMapTestManager(t, routes)
+	if ipsec != nil {
+		mgr.SetIPsecController(ipsec)
+	}
+	return mgr, vpn, routes
+}
+
+func newIPsecTestTunnel(id, iface string) api.SiteToSiteTunnel {
+	return api.SiteToSiteTunnel{
+		TunnelID:       id,
+		Type:           api.TunnelTypeIPsec,
+		RemoteEndpoint: "203.0.113.9",
+		LocalSubnets:   []string{"10.0.0.0/24"},
+		RemoteSubnets:  []string{"192.168.50.0/24"},
+		PSK:            "legacy-secret",
+		InterfaceName:  iface,
+	}
+}
+
+func TestSiteToSiteManager_IPsecTunnel(t *testing.T) {
+	ipsec := &mockIPsecController{stats: map[string]TunnelPeerStats{
+		"plexd-fw-1": {Endpoint: "203.0.113.9:500", RxBytes: 10, TxBytes: 20, LastHandshake: time.Unix(1700000000, 0)},
+	}}
+	mgr, vpn, routes := newIPsecTestManager(t, ipsec)
+
+	tunnel := newIPsecTestTunnel("fw-1", "xfrm-s2s-1")
+	if err := mgr.AddTunnel(tunnel); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+	if len(vpn.calls) != 0 {
+		t.Errorf("VPN controller calls = %v, want none for an ipsec tunnel", vpn.calls)
+	}
+	created := ipsec.callsFor("CreateIPsecInterface")
+	if len(created) != 1 || created[0].Args[0] != "xfrm-s2s-1" || created[0].Args[1] != uint32(ipsecIfIDBase) {
+		t.Fatalf("CreateIPsecInterface calls = %v, want xfrm-s2s-1 with ID %d", created, ipsecIfIDBase)
+	}
+	want := IPsecConn{
+		Name:       "plexd-fw-1",
+		RemoteHost: "203.0.113.9",
+		LocalTS:    []string{"10.0.0.0/24"},
+		RemoteTS:   []string{"192.168.50.0/24"},
+		PSK:        "legacy-secret",
+		IfID:       ipsecIfIDBase,
+	}
+	loaded := ipsec.callsFor("LoadIPsecConn")
+	if len(loaded) != 1 || !reflect.DeepEqual(loaded[0].Args[0], want) {
+		t.Fatalf("LoadIPsecConn calls = %+v, want %+v", loaded, want)
+	}
+	if calls := routes.callsFor("AddRoute"); len(calls) != 1 || calls[0].Args[0] != "192.168.50.0/24" || calls[0].Args[1] != "xfrm-s2s-1" {
+		t.Errorf("AddRoute calls = %v, want 192.168.50.0/24 via xfrm-s2s-1", calls)
+	}
+
+	// A second tunnel is bound to the next interface ID.
+	second := newIPsecTestTunnel("fw-2", "xfrm-s2s-2")
+	second.RemoteSubnets = []string{"192.168.60.0/24"}
+	if err := mgr.AddTunnel(second); err != nil {
+		t.Fatalf("AddTunnel second: %v", err)
+	}
+	if created := ipsec.callsFor("CreateIPsecInterface"); created[1].Args[1] != uint32(ipsecIfIDBase+1) {
+		t.Errorf("second interface ID = %v, want %d", created[1].Args[1], ipsecIfIDBase+1)
+	}
+
+	var ikePorts []int
+	for _, p := range mgr.ReservedPorts() {
+		ikePorts = append(ikePorts, p.Port)
+	}
+	if !slices.Contains(ikePorts, 500) || !slices.Contains(ikePorts, 4500) {
+		t.Errorf("ReservedPorts = %v, want the IKE ports", ikePorts)
+	}
+	if got := mgr.SiteToSiteCapabilities()["site_to_site_types"]; got != "wireguard,ipsec" {
+		t.Errorf("site_to_site_types = %q, want %q", got, "wireguard,ipsec")
+	}
+
+	status := mgr.SiteToSiteStatus()
+	if len(status.Tunnels) != 2 {
+		t.Fatalf("status tunnels = %+v, want 2", status.Tunnels)
+	}
+	if ts := status.Tunnels[0]; ts.TunnelID != "fw-1" || ts.Endpoint != "203.0.113.9:500" || ts.RxBytes != 10 || ts.TxBytes != 20 || ts.LastHandshake == nil || ts.Error != "" {
+		t.Errorf("status of fw-1 = %+v", ts)
+	}
+
+	mgr.RemoveTunnel("fw-1")
+	if calls := ipsec.callsFor("UnloadIPsecConn"); len(calls) != 1 || calls[0].Args[0] != "plexd-fw-1" {
+		t.Errorf("UnloadIPsecConn calls = %v, want plexd-fw-1", calls)
+	}
+	if calls := ipsec.callsFor("RemoveIPsecInterface"); len(calls) != 1 || calls[0].Args[0] != "xfrm-s2s-1" {
+		t.Errorf("RemoveIPsecInterface calls = %v, want xfrm-s2s-1", calls)
+	}
+
+	// The freed interface ID is reused.
+	if err := mgr.AddTunnel(tunnel); err != nil {
+		t.Fatalf("AddTunnel again: %v", err)
+	}
+	if created := ipsec.callsFor("CreateIPsecInterface"); created[2].Args[1] != uint32(ipsecIfIDBase) {
+		t.Errorf("interface ID after removal = %v, want %d", created[2].Args[1], ipsecIfIDBase)
+	}
+
+	ipsec.reset()
+	if err := mgr.Teardown(); err != nil {
+		t.Fatalf("Teardown: %v", err)
+	}
+	if calls := ipsec.callsFor("UnloadIPsecConn"); len(calls) != 2 {
+		t.Errorf("UnloadIPsecConn calls on teardown = %v, want 2", calls)
+	}
+	if calls := ipsec.callsFor("RemoveIPsecInterface"); len(calls) != 2 {
+		t.Errorf("RemoveIPsecInterface calls on teardown = %v, want 2", calls)
+	}
+}
+
+func TestSiteToSiteManager_IPsecTunnel_NoController(t *testing.T) {
+	mgr, _, routes := newIPsecTestManager(t, nil)
+
+	err := mgr.AddTunnel(newIPsecTestTunnel("fw-1", "xfrm-s2s-1"))
+	if !errors.Is(err, errNoIPsecController) {
+		t.Fatalf("AddTunnel = %v, want %v", err, errNoIPsecController)
+	}
+	if len(routes.calls) != 0 {
+		t.Errorf("route calls = %v, want none", routes.calls)
+	}
+	if got := mgr.SiteToSiteCapabilities()["site_to_site_types"]; got != "wireguard" {
+		t.Errorf("site_to_site_types = %q, want %q", got, "wireguard")
+	}
+}
+
+func TestSiteToSiteManager_IPsecTunnel_Rollback(t *testing.T) {
+	ipsec := &mockIPsecController{loadErr: errors.New("vici: load-conn: parsing failed")}
+	mgr, _, _ := newIPsecTestManager(t, ipsec)
+
+	if err := mgr.AddTunnel(newIPsecTestTunnel("fw-1", "xfrm-s2s-1")); err == nil {
+		t.Fatal("AddTunnel succeeded, want error")
+	}
+	if calls := ipsec.callsFor("UnloadIPsecConn"); len(calls) != 1 {
+		t.Errorf("UnloadIPsecConn calls = %v, want the partial connection unloaded", calls)
+	}
+	if calls := ipsec.callsFor("RemoveIPsecInterface"); len(calls) != 1 {
+		t.Errorf("RemoveIPsecInterface calls = %v, want the interface rolled back", calls)
+	}
+	if _, ok := mgr.GetTunnel("fw-1"); ok {
+		t.Error("tunnel tracked after failed AddTunnel")
+	}
+}
+
+func TestSiteToSiteManager_UpdateTunnel_IPsec(t *testing.T) {
+	ipsec := &mockIPsecController{}
+	mgr, _, _ := newIPsecTestManager(t, ipsec)
+	tunnel := newIPsecTestTunnel("fw-1", "xfrm-s2s-1")
+	if err := mgr.AddTunnel(tunnel); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+
+	// An egress rate alone leaves the connection alone.
+	ipsec.reset()
+	updated := tunnel
+	updated.EgressRateKbps = 1000
+	if err := mgr.UpdateTunnel(updated); err != nil {
+		t.Fatalf("UpdateTunnel egress rate: %v", err)
+	}
+	if calls := ipsec.callsFor("LoadIPsecConn"); len(calls) != 0 {
+		t.Errorf("LoadIPsecConn calls = %v, want none", calls)
+	}
+
+	// Local subnets are traffic selectors and reload the connection.
+	updated.LocalSubnets = []string{"10.0.0.0/24", "10.0.1.0/24"}
+	if err := mgr.UpdateTunnel(updated); err != nil {
+		t.Fatalf("UpdateTunnel local subnets: %v", err)
+	}
+	loaded := ipsec.callsFor("LoadIPsecConn")
+	if len(loaded) != 1 || !slices.Equal(loaded[0].Args[0].(IPsecConn).LocalTS, updated.LocalSubnets) {
+		t.Fatalf("LoadIPsecConn calls = %+v, want one with the new local subnets", loaded)
+	}
+
+	// A failed reload restores the previous connection.
+	ipsec.reset()
+	ipsec.loadErr = errors.New("vici: load-conn failed")
+	failing := updated
+	failing.PSK = "rotated"
+	if err := mgr.UpdateTunnel(failing); err == nil {
+		t.Fatal("UpdateTunnel succeeded, want error")
+	}
+	loaded = ipsec.callsFor("LoadIPsecConn")
+	if len(loaded) != 2 || loaded[1].Args[0].(IPsecConn).PSK != "legacy-secret" {
+		t.Errorf("LoadIPsecConn calls = %+v, want the new and the previous connection", loaded)
+	}
+
+	// The type cannot change in place.
+	wg := updated
+	wg.Type = api.TunnelTypeWireGuard
+	wg.RemotePublicKey = "key"
+	if err := mgr.UpdateTunnel(wg); !errors.Is(err, ErrTunnelRecreate) {
+		t.Errorf("UpdateTunnel type change = %v, want ErrTunnelRecreate", err)
+	}
+}
+
+func TestIPsecConn(t *testing.T) {
+	tunnel := newIPsecTestTunnel("fw-1", "xfrm-s2s-1")
+	tunnel.RemoteEndpoint = "[2001:db8::9]:4500"
+	tunnel.LocalSubnets = []string{"10.0.0.0/24", "10.0.5.0/24"}
+	tunnel.NATMap = &api.SiteToSiteNATMap{Local: "10.0.0.0/24", As: "10.200.0.0/24"}
+
+	conn := ipsecConn(tunnel, 7)
+	if conn.RemoteHost != "2001:db8::9" || conn.RemotePort != 4500 {
+		t.Errorf("remote = %s port %d, want 2001:db8::9 port 4500", conn.RemoteHost, conn.RemotePort)
+	}
+	// The remote site sees the mapped subnet.
+	if !slices.Equal(conn.LocalTS, []string{"10.200.0.0/24", "10.0.5.0/24"}) {
+		t.Errorf("LocalTS = %v", conn.LocalTS)
+	}
+	if tunnel.LocalSubnets[0] != "10.0.0.0/24" {
+		t.Error("ipsecConn modified the local subnets of the tunnel")
+	}
+	if conn.Name != "plexd-fw-1" || conn.IfID != 7 {
+		t.Errorf("conn = %+v", conn)
+	}
+}
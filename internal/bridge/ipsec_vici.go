@@ -0,0 +1,238 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/vici"
+)
+
+// DefaultSiteToSiteVICISocket is the VICI socket of strongSwan's charon.
+const DefaultSiteToSiteVICISocket = vici.DefaultSocketPath
+
+// VICIController implements IPsecController and IPsecStatsReader by
+// programming strongSwan's charon over VICI, and XFRM interfaces through
+// netlink. Connections are loaded with start_action=trap, so that traffic
+// into the tunnel, or the remote site, can establish the SAs at any time,
+// and are initiated right away.
+type VICIController struct {
+	client *vici.Client
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+// NewVICIController returns a VICIController for the VICI socket at
+// socketPath; an empty path selects DefaultSiteToSiteVICISocket.
+func NewVICIController(socketPath string, logger *slog.Logger) *VICIController {
+	return &VICIController{
+		client: vici.NewClient(socketPath, 0),
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// LoadIPsecConn loads the pre-shared key and the connection. SAs of a
+// replaced connection keep their settings until they are re-established, so
+// they are terminated; the initiation that follows re-establishes them.
+func (c *VICIController) LoadIPsecConn(conn IPsecConn) error {
+	ctx := context.Background()
+
+	conns, err := c.client.Call(ctx, "get-conns", nil)
+	if err != nil {
+		return fmt.Errorf("bridge: load ipsec connection %s: %w", conn.Name, err)
+	}
+	replaced := slices.Contains(conns.List("conns"), conn.Name)
+
+	key := vici.NewMessage()
+	key.Set("id", conn.Name)
+	key.Set("type", "IKE")
+	key.Set("data", conn.PSK)
+	key.SetList("owners", []string{conn.RemoteHost})
+	if _, err := c.client.Call(ctx, "load-shared", key); err != nil {
+		return fmt.Errorf("bridge: load ipsec connection %s: %w", conn.Name, err)
+	}
+	if _, err := c.client.Call(ctx, "load-conn", connMessage(conn)); err != nil {
+		return fmt.Errorf("bridge: load ipsec connection %s: %w", conn.Name, err)
+	}
+	if replaced {
+		if err := c.terminate(ctx, conn.Name); err != nil {
+			return fmt.Errorf("bridge: load ipsec connection %s: %w", conn.Name, err)
+		}
+	}
+
+	// A negative timeout returns without waiting for the SA; failures show
+	// in the tunnel status, and the trap policy retries on traffic.
+	req := vici.NewMessage()
+	req.Set("child", conn.Name)
+	req.Set("ike", conn.Name)
+	req.Set("timeout", "-1")
+	if _, err := c.client.Call(ctx, "initiate", req); err != nil {
+		return fmt.Errorf("bridge: initiate ipsec connection %s: %w", conn.Name, err)
+	}
+
+	c.logger.Debug("ipsec connection loaded",
+		"component", "bridge",
+		"connection", conn.Name,
+		"remote_host", conn.RemoteHost,
+		"if_id", conn.IfID,
+		"replaced", replaced,
+	)
+	return nil
+}
+
+// connMessage returns the load-conn message of conn. Proposals are left at
+// the defaults of charon, which cover the algorithms of common firewalls.
+func connMessage(conn IPsecConn) *vici.Message {
+	ifID := strconv.FormatUint(uint64(conn.IfID), 10)
+	child := vici.NewMessage()
+	if len(conn.LocalTS) > 0 {
+		child.SetList("local_ts", conn.LocalTS)
+	}
+	if len(conn.RemoteTS) > 0 {
+		child.SetList("remote_ts", conn.RemoteTS)
+	}
+	child.Set("if_id_in", ifID)
+	child.Set("if_id_out", ifID)
+	child.Set("start_action", "trap")
+	child.Set("dpd_action", "restart")
+	children := vici.NewMessage()
+	children.SetSection(conn.Name, child)
+
+	local := vici.NewMessage()
+	local.Set("auth", "psk")
+	remote := vici.NewMessage()
+	remote.Set("auth", "psk")
+	remote.Set("id", conn.RemoteHost)
+
+	c := vici.NewMessage()
+	c.Set("version", "2")
+	c.SetList("remote_addrs", []string{conn.RemoteHost})
+	if conn.RemotePort != 0 {
+		c.Set("remote_port", strconv.Itoa(conn.RemotePort))
+	}
+	c.Set("dpd_delay", "30s")
+	c.SetSection("local", local)
+	c.SetSection("remote", remote)
+	c.SetSection("children", children)
+
+	msg := vici.NewMessage()
+	msg.SetSection(conn.Name, c)
+	return msg
+}
+
+// UnloadIPsecConn terminates the SAs of the connection, then unloads the
+// connection and its pre-shared key if they are loaded.
+func (c *VICIController) UnloadIPsecConn(name string) error {
+	ctx := context.Background()
+	var errs []error
+
+	if err := c.terminate(ctx, name); err != nil {
+		errs = append(errs, err)
+	}
+	conns, err := c.client.Call(ctx, "get-conns", nil)
+	if err != nil {
+		errs = append(errs, err)
+	} else if slices.Contains(conns.List("conns"), name) {
+		req := vici.NewMessage()
+		req.Set("name", name)
+		if _, err := c.client.Call(ctx, "unload-conn", req); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	keys, err := c.client.Call(ctx, "get-shared", nil)
+	if err != nil {
+		errs = append(errs, err)
+	} else if slices.Contains(keys.List("keys"), name) {
+		req := vici.NewMessage()
+		req.Set("id", name)
+		if _, err := c.client.Call(ctx, "unload-shared", req); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("bridge: unload ipsec connection %s: %w", name, err)
+	}
+
+	c.logger.Debug("ipsec connection unloaded",
+		"component", "bridge",
+		"connection", name,
+	)
+	return nil
+}
+
+// terminate terminates the IKE SAs of the connection name, if any, without
+// waiting for the remote site to confirm.
+func (c *VICIController) terminate(ctx context.Context, name string) error {
+	sas, err := c.listSAs(ctx, name)
+	if err != nil || len(sas) == 0 {
+		return err
+	}
+	req := vici.NewMessage()
+	req.Set("ike", name)
+	req.Set("force", "yes")
+	req.Set("timeout", "-1")
+	_, err = c.client.Call(ctx, "terminate", req)
+	return err
+}
+
+// listSAs returns the IKE SAs of the connection name.
+func (c *VICIController) listSAs(ctx context.Context, name string) ([]*vici.Message, error) {
+	req := vici.NewMessage()
+	req.Set("ike", name)
+	events, _, err := c.client.Stream(ctx, "list-sas", "list-sa", req)
+	if err != nil {
+		return nil, err
+	}
+	var sas []*vici.Message
+	for _, ev := range events {
+		if sa := ev.Section(name); sa != nil {
+			sas = append(sas, sa)
+		}
+	}
+	return sas, nil
+}
+
+// IPsecConnStats sums the traffic of the CHILD_SAs of the connection name.
+// Endpoint and LastHandshake are those of the most recently established
+// IKE SA; a connection without an established SA reports zero values.
+func (c *VICIController) IPsecConnStats(name string) (TunnelPeerStats, error) {
+	sas, err := c.listSAs(context.Background(), name)
+	if err != nil {
+		return TunnelPeerStats{}, fmt.Errorf("bridge: read ipsec connection %s: %w", name, err)
+	}
+	var stats TunnelPeerStats
+	now := c.now()
+	for _, sa := range sas {
+		if sa.Get("state") != "ESTABLISHED" {
+			continue
+		}
+		if secs, err := strconv.ParseInt(sa.Get("established"), 10, 64); err == nil {
+			at := now.Add(-time.Duration(secs) * time.Second)
+			if at.After(stats.LastHandshake) {
+				stats.LastHandshake = at
+				stats.Endpoint = net.JoinHostPort(sa.Get("remote-host"), sa.Get("remote-port"))
+			}
+		}
+		children := sa.Section("child-sas")
+		if children == nil {
+			continue
+		}
+		for _, key := range children.Keys() {
+			child := children.Section(key)
+			if child == nil {
+				continue
+			}
+			in, _ := strconv.ParseUint(child.Get("bytes-in"), 10, 64)
+			out, _ := strconv.ParseUint(child.Get("bytes-out"), 10, 64)
+			stats.RxBytes += in
+			stats.TxBytes += out
+		}
+	}
+	return stats, nil
+}
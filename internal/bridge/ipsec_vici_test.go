@@ -0,0 +1,153 @@
+package bridge
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/vici"
+	"github.com/plexsphere/plexd/internal/vici/vicitest"
+)
+
+var (
+	_ IPsecController  = (*VICIController)(nil)
+	_ IPsecStatsReader = (*VICIController)(nil)
+)
+
+func testIPsecConn() IPsecConn {
+	return IPsecConn{
+		Name:       "plexd-fw-1",
+		RemoteHost: "203.0.113.9",
+		RemotePort: 4500,
+		LocalTS:    []string{"10.0.0.0/24"},
+		RemoteTS:   []string{"192.168.50.0/24", "192.168.51.0/24"},
+		PSK:        "legacy-secret",
+		IfID:       100,
+	}
+}
+
+func TestVICIController_LoadIPsecConn(t *testing.T) {
+	srv := vicitest.NewServer(t)
+	c := NewVICIController(srv.Path(), discardLogger())
+
+	if err := c.LoadIPsecConn(testIPsecConn()); err != nil {
+		t.Fatalf("LoadIPsecConn: %v", err)
+	}
+	key := srv.Shared("plexd-fw-1")
+	if key == nil || key.Get("type") != "IKE" || key.Get("data") != "legacy-secret" || !slices.Equal(key.List("owners"), []string{"203.0.113.9"}) {
+		t.Fatalf("shared key = %+v", key)
+	}
+	conn := srv.Conn("plexd-fw-1")
+	if conn == nil {
+		t.Fatal("connection not loaded")
+	}
+	if conn.Get("version") != "2" || conn.Get("remote_port") != "4500" || !slices.Equal(conn.List("remote_addrs"), []string{"203.0.113.9"}) {
+		t.Errorf("connection = %+v", conn)
+	}
+	if r := conn.Section("remote"); r.Get("auth") != "psk" || r.Get("id") != "203.0.113.9" {
+		t.Errorf("remote = %+v", r)
+	}
+	child := conn.Section("children").Section("plexd-fw-1")
+	if child.Get("if_id_in") != "100" || child.Get("if_id_out") != "100" || child.Get("start_action") != "trap" {
+		t.Errorf("child = %+v", child)
+	}
+	if !slices.Equal(child.List("local_ts"), []string{"10.0.0.0/24"}) || !slices.Equal(child.List("remote_ts"), []string{"192.168.50.0/24", "192.168.51.0/24"}) {
+		t.Errorf("traffic selectors = %v, %v", child.List("local_ts"), child.List("remote_ts"))
+	}
+	if srv.SA("plexd-fw-1") == nil {
+		t.Error("connection not initiated")
+	}
+	if cmds := srv.Commands(); slices.Contains(cmds, "terminate") {
+		t.Errorf("commands = %v, want no terminate for a new connection", cmds)
+	}
+
+	// Reloading terminates the SA with the old settings and initiates again.
+	conn2 := testIPsecConn()
+	conn2.PSK = "rotated"
+	if err := c.LoadIPsecConn(conn2); err != nil {
+		t.Fatalf("LoadIPsecConn again: %v", err)
+	}
+	cmds := srv.Commands()
+	if i := slices.Index(cmds, "terminate"); i < 0 || !slices.Contains(cmds[i:], "initiate") {
+		t.Errorf("commands = %v, want terminate followed by initiate", cmds)
+	}
+	if srv.Shared("plexd-fw-1").Get("data") != "rotated" || srv.SA("plexd-fw-1") == nil {
+		t.Error("rotated connection not loaded and initiated")
+	}
+}
+
+func TestVICIController_LoadIPsecConnError(t *testing.T) {
+	srv := vicitest.NewServer(t)
+	srv.Fail("load-conn", "parsing request failed")
+	c := NewVICIController(srv.Path(), discardLogger())
+
+	err := c.LoadIPsecConn(testIPsecConn())
+	if err == nil || err.Error() != "bridge: load ipsec connection plexd-fw-1: vici: load-conn: parsing request failed" {
+		t.Fatalf("LoadIPsecConn = %v", err)
+	}
+	if slices.Contains(srv.Commands(), "initiate") {
+		t.Error("failed connection initiated")
+	}
+}
+
+func TestVICIController_UnloadIPsecConn(t *testing.T) {
+	srv := vicitest.NewServer(t)
+	c := NewVICIController(srv.Path(), discardLogger())
+	if err := c.LoadIPsecConn(testIPsecConn()); err != nil {
+		t.Fatalf("LoadIPsecConn: %v", err)
+	}
+
+	if err := c.UnloadIPsecConn("plexd-fw-1"); err != nil {
+		t.Fatalf("UnloadIPsecConn: %v", err)
+	}
+	if srv.Conn("plexd-fw-1") != nil || srv.Shared("plexd-fw-1") != nil || srv.SA("plexd-fw-1") != nil {
+		t.Error("connection, key, or SA left after UnloadIPsecConn")
+	}
+
+	// Unloading again is a no-op.
+	if err := c.UnloadIPsecConn("plexd-fw-1"); err != nil {
+		t.Errorf("UnloadIPsecConn again: %v", err)
+	}
+}
+
+func TestVICIController_IPsecConnStats(t *testing.T) {
+	srv := vicitest.NewServer(t)
+	c := NewVICIController(srv.Path(), discardLogger())
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	// No SA: zero values.
+	stats, err := c.IPsecConnStats("plexd-fw-1")
+	if err != nil || stats != (TunnelPeerStats{}) {
+		t.Fatalf("IPsecConnStats without SA = %+v, %v", stats, err)
+	}
+
+	sa := vici.NewMessage()
+	sa.Set("state", "ESTABLISHED")
+	sa.Set("established", "90")
+	sa.Set("remote-host", "203.0.113.9")
+	sa.Set("remote-port", "4500")
+	children := vici.NewMessage()
+	for i, b := range [][2]string{{"100", "200"}, {"5", "7"}} {
+		child := vici.NewMessage()
+		child.Set("bytes-in", b[0])
+		child.Set("bytes-out", b[1])
+		children.SetSection("plexd-fw-1-"+string(rune('1'+i)), child)
+	}
+	sa.SetSection("child-sas", children)
+	srv.SetSA("plexd-fw-1", sa)
+
+	stats, err = c.IPsecConnStats("plexd-fw-1")
+	if err != nil {
+		t.Fatalf("IPsecConnStats: %v", err)
+	}
+	want := TunnelPeerStats{
+		Endpoint:      "203.0.113.9:4500",
+		RxBytes:       105,
+		TxBytes:       207,
+		LastHandshake: now.Add(-90 * time.Second),
+	}
+	if stats != want {
+		t.Errorf("IPsecConnStats = %+v, want %+v", stats, want)
+	}
+}
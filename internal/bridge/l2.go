@@ -0,0 +1,198 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// L2Controller abstracts the OS-level operations of the layer-2 extension of
+// site-to-site tunnels: a VXLAN interface, carried inside the tunnel, that is
+// a port of a local Linux bridge. Frames from the remote site are learned by
+// the bridge like those of any other port.
+// All methods must be idempotent: repeating an operation that is already applied returns nil.
+type L2Controller interface {
+	// CreateL2Interface creates the VXLAN interface name for ext, adds it
+	// to ext.Bridge, which must exist, with MAC learning on, and sets it
+	// up. Its MTU fits the tunnel interface ext.Underlay.
+	// Idempotent: creating an interface that exists with the same settings returns nil.
+	CreateL2Interface(name string, ext L2Extension) error
+
+	// RemoveL2Interface removes the VXLAN interface with the given name.
+	// Idempotent: removing a non-existent interface returns nil.
+	RemoveL2Interface(name string) error
+
+	// L2MACCount returns the number of MAC addresses the bridge learned on
+	// the port name. Static entries are not counted.
+	L2MACCount(name string) (int, error)
+
+	// SetL2Learning turns MAC learning on the bridge port name on or off.
+	SetL2Learning(name string, on bool) error
+}
+
+// L2Extension is the VXLAN of the layer-2 extension of a tunnel.
+type L2Extension struct {
+	VNI    uint32
+	Bridge string
+	// Local and Remote are the VTEP addresses of this node and the remote
+	// bridge node.
+	Local  string
+	Remote string
+	// Underlay is the interface of the tunnel the VXLAN is carried in.
+	Underlay string
+}
+
+// errNoL2Controller is reported for layer-2 extensions when no L2 controller
+// is set.
+const errNoL2Controller = "layer-2 extensions are not supported: no L2 controller"
+
+// VXLANPort is the UDP port of the VXLAN of layer-2 extensions.
+const VXLANPort = 4789
+
+// l2IfacePrefix prefixes the name of the VXLAN interface of a layer-2
+// extension, which is followed by the VNI.
+const l2IfacePrefix = "s2s-vx"
+
+// l2IfaceName returns the name of the VXLAN interface for vni.
+func l2IfaceName(vni uint32) string {
+	return l2IfacePrefix + strconv.FormatUint(uint64(vni), 10)
+}
+
+// l2ResumePercent is the share of the MAC limit learning resumes below once
+// it was paused, so that learning does not flap at the limit.
+const l2ResumePercent = 90
+
+// l2Learning reports whether MAC learning should be on for a port with macs
+// learned addresses and the given limit, given whether it is paused.
+func l2Learning(macs, limit int, paused bool) bool {
+	if paused {
+		return macs*100 < limit*l2ResumePercent
+	}
+	return macs < limit
+}
+
+// l2MaxMACs returns the MAC limit of l2: its own, or the configured default.
+func (c *Config) l2MaxMACs(l2 *api.SiteToSiteL2) int {
+	if l2.MaxMACs > 0 {
+		return l2.MaxMACs
+	}
+	if c.SiteToSiteL2MaxMACs > 0 {
+		return c.SiteToSiteL2MaxMACs
+	}
+	return DefaultSiteToSiteL2MaxMACs
+}
+
+// l2Equal reports whether two layer-2 extensions, either of which may be nil,
+// are the same apart from their MAC limit, which applies without recreating
+// the VXLAN interface.
+func l2Equal(a, b *api.SiteToSiteL2) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	x, y := *a, *b
+	x.MaxMACs, y.MaxMACs = 0, 0
+	return x == y
+}
+
+// applyL2 creates the VXLAN interface of the layer-2 extension of tunnel on
+// iface through ctrl and returns its status. Like egress limits, a failure
+// is reported in the status rather than failing the tunnel.
+func applyL2(ctrl L2Controller, cfg *Config, tunnel api.SiteToSiteTunnel, iface string) *api.SiteToSiteL2Status {
+	l2 := tunnel.L2
+	st := &api.SiteToSiteL2Status{
+		Interface: l2IfaceName(l2.VNI),
+		VNI:       l2.VNI,
+		Bridge:    l2.Bridge,
+		MaxMACs:   cfg.l2MaxMACs(l2),
+	}
+	if ctrl == nil {
+		st.Error = errNoL2Controller
+		return st
+	}
+	ext := L2Extension{
+		VNI:      l2.VNI,
+		Bridge:   l2.Bridge,
+		Local:    l2.LocalVTEP,
+		Remote:   l2.RemoteVTEP,
+		Underlay: iface,
+	}
+	if err := ctrl.CreateL2Interface(st.Interface, ext); err != nil {
+		st.Error = err.Error()
+		return st
+	}
+	st.Active = true
+	return st
+}
+
+// Drift correction types reported by SiteToSiteManager.CheckL2.
+const (
+	// DriftL2LearningPaused is MAC learning turned off on the VXLAN port
+	// of a layer-2 extension that reached its MAC limit.
+	DriftL2LearningPaused = "l2_learning_paused"
+	// DriftL2LearningResumed is MAC learning turned on again after learned
+	// addresses aged out below the limit.
+	DriftL2LearningResumed = "l2_learning_resumed"
+)
+
+// CheckL2 enforces the MAC limits of the layer-2 extensions: learning on the
+// VXLAN port of a tunnel stops when the bridge learned MaxMACs addresses
+// from the remote site, and resumes once learned addresses aged out below
+// 90% of the limit. Frames to addresses that are not learned are still
+// flooded, so the remote site stays reachable, but a flood of source
+// addresses cannot exhaust the bridge's forwarding database. It returns one
+// correction per change. It is a no-op when the manager is inactive.
+func (m *SiteToSiteManager) CheckL2() ([]api.DriftCorrection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.active {
+		return nil, nil
+	}
+
+	ids := make([]string, 0, len(m.activeTunnels))
+	for id, at := range m.activeTunnels {
+		if at.l2 != nil && at.l2.Active {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	var corrections []api.DriftCorrection
+	var errs []error
+	for _, id := range ids {
+		st := m.activeTunnels[id].l2
+		macs, err := m.l2.L2MACCount(st.Interface)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("bridge: site-to-site: check layer-2 extension for tunnel %s: %w", id, err))
+			continue
+		}
+		learn := l2Learning(macs, st.MaxMACs, st.LearningPaused)
+		if learn != st.LearningPaused {
+			continue
+		}
+		if err := m.l2.SetL2Learning(st.Interface, learn); err != nil {
+			errs = append(errs, fmt.Errorf("bridge: site-to-site: check layer-2 extension for tunnel %s: %w", id, err))
+			continue
+		}
+		st.LearningPaused = !learn
+
+		typ, msg := DriftL2LearningResumed, "MAC learning resumed"
+		if !learn {
+			typ, msg = DriftL2LearningPaused, "MAC learning paused at the limit"
+		}
+		m.logger.Warn("bridge: site-to-site: "+msg,
+			"tunnel_id", id,
+			"interface", st.Interface,
+			"macs", macs,
+			"max_macs", st.MaxMACs,
+		)
+		corrections = append(corrections, api.DriftCorrection{
+			Type:   typ,
+			Detail: fmt.Sprintf("tunnel %s: %d of %d MAC addresses", id, macs, st.MaxMACs),
+		})
+	}
+	return corrections, errors.Join(errs...)
+}
@@ -0,0 +1,161 @@
+//go:build linux
+
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// VXLAN encapsulation overhead: the inner Ethernet header, VXLAN, UDP, and
+// the outer IP header.
+const (
+	vxlanOverheadIPv4 = 14 + 8 + 8 + 20
+	vxlanOverheadIPv6 = 14 + 8 + 8 + 40
+)
+
+// NetlinkL2Controller implements L2Controller with VXLAN interfaces and the
+// bridge FDB through netlink.
+type NetlinkL2Controller struct {
+	logger *slog.Logger
+}
+
+// NewNetlinkL2Controller returns a new NetlinkL2Controller.
+func NewNetlinkL2Controller(logger *slog.Logger) *NetlinkL2Controller {
+	return &NetlinkL2Controller{logger: logger}
+}
+
+// CreateL2Interface creates the VXLAN interface name with a single remote
+// VTEP, so it neither floods to nor learns other VTEPs, and makes it a port
+// of ext.Bridge. An existing interface of the name with other settings or
+// another link type is replaced.
+func (c *NetlinkL2Controller) CreateL2Interface(name string, ext L2Extension) error {
+	local, remote := net.ParseIP(ext.Local), net.ParseIP(ext.Remote)
+	if local == nil || remote == nil {
+		return fmt.Errorf("bridge: create l2 interface %q: invalid VTEP addresses %q and %q", name, ext.Local, ext.Remote)
+	}
+	br, err := netlink.LinkByName(ext.Bridge)
+	if err != nil {
+		return fmt.Errorf("bridge: create l2 interface %q: bridge %q: %w", name, ext.Bridge, err)
+	}
+	if _, ok := br.(*netlink.Bridge); !ok {
+		return fmt.Errorf("bridge: create l2 interface %q: %q is not a bridge", name, ext.Bridge)
+	}
+	underlay, err := netlink.LinkByName(ext.Underlay)
+	if err != nil {
+		return fmt.Errorf("bridge: create l2 interface %q: underlay %q: %w", name, ext.Underlay, err)
+	}
+
+	if existing, err := netlink.LinkByName(name); err == nil {
+		if v, ok := existing.(*netlink.Vxlan); !ok || v.VxlanId != int(ext.VNI) || !v.SrcAddr.Equal(local) || !v.Group.Equal(remote) {
+			if err := netlink.LinkDel(existing); err != nil {
+				return fmt.Errorf("bridge: create l2 interface %q: replace: %w", name, err)
+			}
+		}
+	}
+
+	overhead := vxlanOverheadIPv4
+	if local.To4() == nil {
+		overhead = vxlanOverheadIPv6
+	}
+	la := netlink.NewLinkAttrs()
+	la.Name = name
+	la.MTU = underlay.Attrs().MTU - overhead
+	vx := &netlink.Vxlan{
+		LinkAttrs:    la,
+		VxlanId:      int(ext.VNI),
+		VtepDevIndex: underlay.Attrs().Index,
+		SrcAddr:      local,
+		Group:        remote,
+		Port:         VXLANPort,
+		Learning:     false,
+	}
+	if err := netlink.LinkAdd(vx); err != nil && !errors.Is(err, syscall.EEXIST) {
+		return fmt.Errorf("bridge: create l2 interface %q: %w", name, err)
+	}
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return fmt.Errorf("bridge: create l2 interface %q: lookup: %w", name, err)
+	}
+	if err := netlink.LinkSetMaster(link, br); err != nil {
+		return fmt.Errorf("bridge: create l2 interface %q: add to bridge %q: %w", name, ext.Bridge, err)
+	}
+	if err := netlink.LinkSetLearning(link, true); err != nil {
+		return fmt.Errorf("bridge: create l2 interface %q: enable learning: %w", name, err)
+	}
+	if err := netlink.LinkSetUp(link); err != nil {
+		return fmt.Errorf("bridge: create l2 interface %q: set up: %w", name, err)
+	}
+
+	c.logger.Debug("l2 interface created",
+		"component", "bridge",
+		"interface", name,
+		"vni", ext.VNI,
+		"bridge", ext.Bridge,
+		"underlay", ext.Underlay,
+		"mtu", la.MTU,
+	)
+	return nil
+}
+
+// RemoveL2Interface removes the VXLAN interface name. The bridge drops the
+// addresses learned on it.
+func (c *NetlinkL2Controller) RemoveL2Interface(name string) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		var notFound netlink.LinkNotFoundError
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return fmt.Errorf("bridge: remove l2 interface %q: %w", name, err)
+	}
+	if err := netlink.LinkDel(link); err != nil {
+		return fmt.Errorf("bridge: remove l2 interface %q: %w", name, err)
+	}
+
+	c.logger.Debug("l2 interface removed",
+		"component", "bridge",
+		"interface", name,
+	)
+	return nil
+}
+
+// L2MACCount counts the entries of the bridge FDB learned on the port name.
+// Entries of the VXLAN driver itself, which have no master, and permanent
+// entries, such as the address of the port, are skipped.
+func (c *NetlinkL2Controller) L2MACCount(name string) (int, error) {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return 0, fmt.Errorf("bridge: count l2 MAC addresses of %q: %w", name, err)
+	}
+	neighs, err := netlink.NeighList(link.Attrs().Index, unix.AF_BRIDGE)
+	if err != nil {
+		return 0, fmt.Errorf("bridge: count l2 MAC addresses of %q: %w", name, err)
+	}
+	n := 0
+	for _, neigh := range neighs {
+		if neigh.LinkIndex != link.Attrs().Index || neigh.MasterIndex == 0 || neigh.State&(netlink.NUD_PERMANENT|netlink.NUD_NOARP) != 0 {
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
+
+// SetL2Learning turns MAC learning on the bridge port name on or off.
+func (c *NetlinkL2Controller) SetL2Learning(name string, on bool) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return fmt.Errorf("bridge: set l2 learning of %q: %w", name, err)
+	}
+	if err := netlink.LinkSetLearning(link, on); err != nil {
+		return fmt.Errorf("bridge: set l2 learning of %q: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build linux
+
+package bridge
+
+import (
+	"strings"
+	"testing"
+)
+
+// Compile-time check that NetlinkL2Controller implements L2Controller.
+var _ L2Controller = (*NetlinkL2Controller)(nil)
+
+func TestNetlinkL2Controller_InvalidVTEP(t *testing.T) {
+	c := NewNetlinkL2Controller(discardLogger())
+	err := c.CreateL2Interface("s2s-vx42", L2Extension{VNI: 42, Bridge: "br-l2", Local: "10.0.0.1", Remote: "not-an-ip"})
+	if err == nil || !strings.Contains(err.Error(), "invalid VTEP addresses") {
+		t.Errorf("CreateL2Interface = %v, want invalid VTEP error", err)
+	}
+}
@@ -0,0 +1,177 @@
+package bridge
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/validation"
+)
+
+func newL2TestTunnel(id string, vni uint32) api.SiteToSiteTunnel {
+	return api.SiteToSiteTunnel{
+		TunnelID:        id,
+		RemoteEndpoint:  "203.0.113.5:51820",
+		RemotePublicKey: "key-" + id,
+		LocalSubnets:    []string{"10.0.0.0/24"},
+		RemoteSubnets:   []string{"192.168.70.0/24"},
+		InterfaceName:   "wg-s2s-" + id,
+		L2: &api.SiteToSiteL2{
+			VNI:        vni,
+			Bridge:     "br-l2",
+			LocalVTEP:  "10.0.0.1",
+			RemoteVTEP: "192.168.70.1",
+			MaxMACs:    10,
+		},
+	}
+}
+
+func TestSiteToSiteManager_L2(t *testing.T) {
+	l2 := &mockL2Controller{}
+	mgr, _ := newNATMapTestManager(t, &mockRouteController{})
+	mgr.SetL2Controller(l2)
+
+	tunnel := newL2TestTunnel("t1", 42)
+	if err := mgr.AddTunnel(tunnel); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+	created := l2.callsFor("CreateL2Interface")
+	want := L2Extension{VNI: 42, Bridge: "br-l2", Local: "10.0.0.1", Remote: "192.168.70.1", Underlay: "wg-s2s-t1"}
+	if len(created) != 1 || created[0].Args[0] != "s2s-vx42" || created[0].Args[1] != want {
+		t.Fatalf("CreateL2Interface calls = %v, want s2s-vx42 %+v", created, want)
+	}
+	if !slices.ContainsFunc(mgr.ReservedPorts(), func(p validation.ReservedPort) bool { return p.Port == VXLANPort }) {
+		t.Errorf("ReservedPorts = %v, want the VXLAN port", mgr.ReservedPorts())
+	}
+
+	l2.setMACs("s2s-vx42", 3)
+	status := mgr.SiteToSiteStatus()
+	got := status.Tunnels[0].L2
+	if got == nil || !got.Active || got.MACs != 3 || got.MaxMACs != 10 || got.Interface != "s2s-vx42" || got.LearningPaused {
+		t.Fatalf("L2 status = %+v", got)
+	}
+	if caps := mgr.SiteToSiteCapabilities(); caps["site_to_site_l2"] != "true" {
+		t.Errorf("site_to_site_l2 = %q, want true", caps["site_to_site_l2"])
+	}
+
+	// A changed MAC limit applies without recreating the interface.
+	tunnel.L2 = &api.SiteToSiteL2{VNI: 42, Bridge: "br-l2", LocalVTEP: "10.0.0.1", RemoteVTEP: "192.168.70.1", MaxMACs: 20}
+	if err := mgr.UpdateTunnel(tunnel); err != nil {
+		t.Fatalf("UpdateTunnel: %v", err)
+	}
+	if n := len(l2.callsFor("CreateL2Interface")); n != 1 {
+		t.Errorf("CreateL2Interface calls = %d after a limit change, want 1", n)
+	}
+	if got := mgr.SiteToSiteStatus().Tunnels[0].L2.MaxMACs; got != 20 {
+		t.Errorf("MaxMACs = %d, want 20", got)
+	}
+
+	// A changed VNI recreates it.
+	tunnel.L2 = &api.SiteToSiteL2{VNI: 43, Bridge: "br-l2", LocalVTEP: "10.0.0.1", RemoteVTEP: "192.168.70.1"}
+	if err := mgr.UpdateTunnel(tunnel); err != nil {
+		t.Fatalf("UpdateTunnel VNI: %v", err)
+	}
+	if removed := l2.callsFor("RemoveL2Interface"); len(removed) != 1 || removed[0].Args[0] != "s2s-vx42" {
+		t.Errorf("RemoveL2Interface calls = %v, want s2s-vx42", removed)
+	}
+	if created := l2.callsFor("CreateL2Interface"); len(created) != 2 || created[1].Args[0] != "s2s-vx43" {
+		t.Errorf("CreateL2Interface calls = %v, want s2s-vx43", created)
+	}
+	if got := mgr.SiteToSiteStatus().Tunnels[0].L2.MaxMACs; got != DefaultSiteToSiteL2MaxMACs {
+		t.Errorf("MaxMACs = %d, want the default %d", got, DefaultSiteToSiteL2MaxMACs)
+	}
+
+	mgr.RemoveTunnel("t1")
+	if removed := l2.callsFor("RemoveL2Interface"); len(removed) != 2 || removed[1].Args[0] != "s2s-vx43" {
+		t.Errorf("RemoveL2Interface calls = %v, want s2s-vx43 removed", removed)
+	}
+}
+
+func TestSiteToSiteManager_L2NotApplied(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctrl    *mockL2Controller
+		wantErr string
+	}{
+		{"no controller", nil, errNoL2Controller},
+		{"create fails", &mockL2Controller{createErr: errors.New("bridge br-l2 not found")}, "bridge br-l2 not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr, vpn := newNATMapTestManager(t, &mockRouteController{})
+			if tt.ctrl != nil {
+				mgr.SetL2Controller(tt.ctrl)
+			}
+
+			// The tunnel is added; the extension is reported as inactive.
+			if err := mgr.AddTunnel(newL2TestTunnel("t1", 42)); err != nil {
+				t.Fatalf("AddTunnel: %v", err)
+			}
+			if len(vpn.vpnCallsFor("ConfigureTunnelPeer")) != 1 {
+				t.Error("tunnel peer not configured")
+			}
+			got := mgr.SiteToSiteStatus().Tunnels[0].L2
+			if got == nil || got.Active || got.Error != tt.wantErr {
+				t.Errorf("L2 status = %+v, want inactive with error %q", got, tt.wantErr)
+			}
+
+			// Nothing was created, so nothing is removed.
+			mgr.RemoveTunnel("t1")
+			if tt.ctrl != nil && len(tt.ctrl.callsFor("RemoveL2Interface")) != 0 {
+				t.Error("RemoveL2Interface called for an inactive extension")
+			}
+		})
+	}
+}
+
+func TestSiteToSiteManager_CheckL2(t *testing.T) {
+	l2 := &mockL2Controller{}
+	mgr, _ := newNATMapTestManager(t, &mockRouteController{})
+	mgr.SetL2Controller(l2)
+	if err := mgr.AddTunnel(newL2TestTunnel("t1", 42)); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+
+	steps := []struct {
+		macs       int
+		wantType   string
+		wantPaused bool
+	}{
+		{macs: 9},
+		{macs: 10, wantType: DriftL2LearningPaused, wantPaused: true},
+		{macs: 10, wantPaused: true},
+		// Learning resumes below 90% of the limit only.
+		{macs: 9, wantPaused: true},
+		{macs: 8, wantType: DriftL2LearningResumed},
+		{macs: 9},
+	}
+	for i, s := range steps {
+		l2.setMACs("s2s-vx42", s.macs)
+		corrections, err := mgr.CheckL2()
+		if err != nil {
+			t.Fatalf("step %d: CheckL2: %v", i, err)
+		}
+		var types []string
+		for _, c := range corrections {
+			types = append(types, c.Type)
+		}
+		if s.wantType == "" && len(types) != 0 || s.wantType != "" && !slices.Equal(types, []string{s.wantType}) {
+			t.Errorf("step %d (%d MACs): corrections = %v, want %q", i, s.macs, types, s.wantType)
+		}
+		if got := mgr.SiteToSiteStatus().Tunnels[0].L2.LearningPaused; got != s.wantPaused {
+			t.Errorf("step %d (%d MACs): LearningPaused = %v, want %v", i, s.macs, got, s.wantPaused)
+		}
+	}
+	learning := l2.callsFor("SetL2Learning")
+	if len(learning) != 2 || learning[0].Args[1] != false || learning[1].Args[1] != true {
+		t.Errorf("SetL2Learning calls = %v, want off, then on", learning)
+	}
+
+	// The drift checker reports the same corrections.
+	l2.setMACs("s2s-vx42", 50)
+	corrections, err := SiteToSiteDriftChecker(mgr)(t.Context(), nil)
+	if err != nil || len(corrections) != 1 || corrections[0].Type != DriftL2LearningPaused {
+		t.Errorf("drift checker = %v, %v, want %s", corrections, err, DriftL2LearningPaused)
+	}
+}
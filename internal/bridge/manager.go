@@ -5,6 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"net/netip"
+	"slices"
+	"sync"
 
 	"github.com/plexsphere/plexd/internal/api"
 )
@@ -16,12 +20,32 @@ type Manager struct {
 	cfg    Config
 	logger *slog.Logger
 	relay  *Relay
+	ha     *HAElector
+	dnsFwd *DNSForwarder
+	bgp    *BGPSpeaker
+
+	fastPath FastPath
 
 	// tracked state
 	active        bool
 	meshIface     string
 	activeRoutes  map[string]struct{}
 	natConfigured bool
+
+	// natOn is whether masquerading is wanted: the local EnableNAT setting
+	// combined with BridgeConfig.EnableNAT from the control plane.
+	natOn bool
+	// natRules holds the masqueraded subnets when ctrl is a Masquerader.
+	natRules     map[string]struct{}
+	natConflicts []string
+
+	// detectSubnets scans the access interface for subnets to propose in
+	// the approval access subnet mode.
+	detectSubnets func(iface string) ([]string, error)
+
+	// protectedSources report the addresses UpdateRoutes must not route
+	// away from the node.
+	protectedSources []ProtectedSource
 }
 
 // NewManager creates a new Manager. Config defaults are applied automatically.
@@ -33,17 +57,43 @@ func NewManager(ctrl RouteController, cfg Config, logger *slog.Logger) *Manager
 		relay = NewRelay(cfg.RelayListenPort, cfg.MaxRelaySessions, cfg.SessionTTL, logger)
 	}
 
+	var ha *HAElector
+	if cfg.Enabled {
+		ha = NewHAElector(ctrl, cfg.AccessInterface, cfg.HAListenPort, cfg.HAAdvertInterval, logger)
+	}
+
+	var dnsFwd *DNSForwarder
+	if cfg.Enabled && cfg.DNSForwardEnabled {
+		dnsFwd = NewDNSForwarder(cfg.AccessInterface, cfg.DNSForwardPort, cfg.DNSDomain, cfg.DNSUpstreams, logger)
+	}
+
+	var bgp *BGPSpeaker
+	if cfg.Enabled && cfg.BGPEnabled {
+		bgp = NewBGPSpeaker(ctrl, cfg, logger)
+	}
+
 	return &Manager{
 		ctrl:         ctrl,
 		cfg:          cfg,
 		logger:       logger,
 		relay:        relay,
+		ha:           ha,
+		dnsFwd:       dnsFwd,
+		bgp:          bgp,
 		activeRoutes: make(map[string]struct{}),
+		natOn:        cfg.natEnabled(),
+		natRules:     make(map[string]struct{}),
+
+		detectSubnets: detectAccessSubnets,
 	}
 }
 
 // Setup configures bridge mode routing: enables forwarding, adds routes, and
-// optionally configures NAT masquerading. When bridge mode is disabled this is a no-op.
+// optionally configures NAT masquerading. When ctrl is a Masquerader, each
+// IPv4 access subnet gets its own masquerade rule; otherwise all traffic
+// leaving the access interface is masqueraded. In the approval access subnet
+// mode no subnet is routed until the control plane confirms it through
+// UpdateRoutes. When bridge mode is disabled this is a no-op.
 // On partial route failure, previously added routes in this call are rolled back.
 func (m *Manager) Setup(meshIface string) error {
 	if !m.cfg.Enabled {
@@ -58,8 +108,12 @@ func (m *Manager) Setup(meshIface string) error {
 	}
 
 	// Add routes with rollback on failure.
+	subnets := m.cfg.AccessSubnets
+	if m.cfg.approvalMode() {
+		subnets = nil
+	}
 	var added []string
-	for _, subnet := range m.cfg.AccessSubnets {
+	for _, subnet := range subnets {
 		if err := m.ctrl.AddRoute(subnet, m.cfg.AccessInterface); err != nil {
 			m.logger.Error("bridge: setup: add route failed, rolling back",
 				"component", "bridge",
@@ -78,27 +132,59 @@ func (m *Manager) Setup(meshIface string) error {
 	}
 
 	// Configure NAT if enabled.
-	if m.cfg.natEnabled() {
-		if err := m.ctrl.AddNATMasquerade(m.cfg.AccessInterface); err != nil {
+	if m.natOn {
+		if err := m.applyNAT(true); err != nil {
 			m.logger.Error("bridge: setup: add NAT masquerade failed, rolling back",
 				"component", "bridge",
 				"error", err,
 			)
+			m.applyNAT(false)
 			m.rollbackSetup(subnetsFromSet(m.activeRoutes))
 			m.activeRoutes = make(map[string]struct{})
 			return fmt.Errorf("bridge: setup: add NAT masquerade: %w", err)
 		}
-		m.natConfigured = true
+	}
+
+	// Clamp the MSS of forwarded TCP connections; without a clamp they
+	// rely on path MTU discovery.
+	if err := applyMSSClamp(m.ctrl, &m.cfg, m.cfg.AccessInterface); err != nil {
+		m.logger.Warn("bridge: setup: MSS clamp not applied",
+			"component", "bridge",
+			"error", err,
+		)
+	}
+
+	// Offload forwarded flows; the standard forwarding path keeps working
+	// when the offload fails.
+	if m.fastPath != nil {
+		if err := m.fastPath.OffloadForwarding(meshIface, m.cfg.AccessInterface); err != nil {
+			m.logger.Warn("bridge: setup: fast path offload failed, using standard forwarding",
+				"component", "bridge",
+				"fast_path", m.fastPath.Name(),
+				"error", err,
+			)
+		}
 	}
 
 	m.active = true
 
+	// Advertise the routed access subnets once the speaker runs.
+	if m.bgp != nil {
+		if err := m.bgp.SetAccessSubnets(subnetsFromSet(m.activeRoutes)); err != nil {
+			m.logger.Warn("bridge: setup: BGP routes not applied",
+				"component", "bridge",
+				"error", err,
+			)
+		}
+	}
+
 	m.logger.Info("bridge mode configured",
 		"component", "bridge",
 		"mesh_iface", meshIface,
 		"access_iface", m.cfg.AccessInterface,
-		"subnets", m.cfg.AccessSubnets,
-		"nat", m.cfg.natEnabled(),
+		"subnets", subnets,
+		"access_subnet_mode", m.cfg.AccessSubnetMode,
+		"nat", m.natOn,
 	)
 
 	return nil
@@ -157,15 +243,28 @@ func (m *Manager) Teardown() error {
 	m.activeRoutes = make(map[string]struct{})
 
 	// Remove NAT masquerade if it was configured.
-	if m.natConfigured {
-		if err := m.ctrl.RemoveNATMasquerade(m.cfg.AccessInterface); err != nil {
-			m.logger.Error("bridge: teardown: remove NAT masquerade failed",
+	if err := m.applyNAT(false); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Remove the MSS clamp.
+	if err := clearMSSClamp(m.ctrl, &m.cfg, m.cfg.AccessInterface); err != nil {
+		m.logger.Error("bridge: teardown: clear MSS clamp failed",
+			"component", "bridge",
+			"error", err,
+		)
+		errs = append(errs, err)
+	}
+
+	// Remove the forwarding offload before forwarding is disabled.
+	if m.fastPath != nil {
+		if err := m.fastPath.RemoveForwardingOffload(); err != nil {
+			m.logger.Error("bridge: teardown: remove fast path offload failed",
 				"component", "bridge",
 				"error", err,
 			)
 			errs = append(errs, err)
 		}
-		m.natConfigured = false
 	}
 
 	// Disable forwarding.
@@ -177,6 +276,43 @@ func (m *Manager) Teardown() error {
 		errs = append(errs, err)
 	}
 
+	// Flush the dedicated routing table so no plexd route survives teardown.
+	if flusher, ok := m.ctrl.(RouteTableFlusher); ok && m.cfg.RouteTable > 0 {
+		if err := flusher.FlushRouteTable(); err != nil {
+			m.logger.Error("bridge: teardown: flush route table failed",
+				"component", "bridge",
+				"table", m.cfg.RouteTable,
+				"error", err,
+			)
+			errs = append(errs, err)
+		}
+	}
+
+	// Leave the HA group so a standby member takes over at once.
+	if m.ha != nil {
+		if err := m.ha.Stop(); err != nil {
+			m.logger.Error("bridge: teardown: stop HA election failed",
+				"component", "bridge",
+				"error", err,
+			)
+			errs = append(errs, err)
+		}
+		if err := m.ha.Configure(nil); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// Withdraw the advertised subnets and remove the imported routes.
+	if m.bgp != nil {
+		if err := m.bgp.Stop(); err != nil {
+			m.logger.Error("bridge: teardown: stop BGP speaker failed",
+				"component", "bridge",
+				"error", err,
+			)
+			errs = append(errs, err)
+		}
+	}
+
 	// Stop relay if configured.
 	if m.relay != nil {
 		if err := m.relay.Stop(); err != nil {
@@ -193,6 +329,17 @@ func (m *Manager) Teardown() error {
 	return errors.Join(errs...)
 }
 
+// SetFastPath sets the kernel fast path used for forwarded flows and relay
+// sessions, typically Backend.FastPath. It must be called before Setup and
+// StartRelay. When unset or nil, all traffic takes the userspace and standard
+// forwarding paths. The caller closes fp after Teardown.
+func (m *Manager) SetFastPath(fp FastPath) {
+	m.fastPath = fp
+	if m.relay != nil {
+		m.relay.SetFastPath(fp)
+	}
+}
+
 // Relay returns the relay instance, or nil if relay is not configured.
 func (m *Manager) Relay() *Relay {
 	return m.relay
@@ -214,51 +361,327 @@ func (m *Manager) StopRelay() error {
 	return m.relay.Stop()
 }
 
+// HA returns the HA elector, or nil if bridge mode is disabled.
+func (m *Manager) HA() *HAElector {
+	return m.ha
+}
+
+// StartHA starts the HA election with nodeID as this node's identity. The
+// election stays idle until UpdateHA supplies a group. No-op if bridge mode
+// is disabled.
+func (m *Manager) StartHA(ctx context.Context, nodeID string) error {
+	if m.ha == nil {
+		return nil
+	}
+	return m.ha.Start(ctx, nodeID)
+}
+
+// UpdateHA applies the HA group from the desired BridgeConfig. A nil cfg
+// leaves the group. No-op if bridge mode is disabled.
+func (m *Manager) UpdateHA(cfg *api.BridgeHAConfig) error {
+	if m.ha == nil {
+		return nil
+	}
+	return m.ha.Configure(cfg)
+}
+
+// DNSForwarder returns the DNS forwarder, or nil if DNS forwarding is not
+// configured.
+func (m *Manager) DNSForwarder() *DNSForwarder {
+	return m.dnsFwd
+}
+
+// StartDNSForwarder starts the DNS forwarder on the access interface. It must
+// be called after Setup, once the access interface has its addresses.
+// No-op if DNS forwarding is not configured.
+func (m *Manager) StartDNSForwarder(ctx context.Context) error {
+	if m.dnsFwd == nil {
+		return nil
+	}
+	return m.dnsFwd.Start(ctx)
+}
+
+// StopDNSForwarder stops the DNS forwarder. No-op if DNS forwarding is not
+// configured.
+func (m *Manager) StopDNSForwarder() error {
+	if m.dnsFwd == nil {
+		return nil
+	}
+	return m.dnsFwd.Stop()
+}
+
+// UpdateDNSNames replaces the mesh names the DNS forwarder resolves with
+// those of peers. No-op if DNS forwarding is not configured.
+func (m *Manager) UpdateDNSNames(peers []api.Peer) {
+	if m.dnsFwd == nil {
+		return
+	}
+	m.dnsFwd.SetPeers(peers)
+}
+
+// BGP returns the BGP speaker, or nil if BGP is not configured.
+func (m *Manager) BGP() *BGPSpeaker {
+	return m.bgp
+}
+
+// StartBGP starts the BGP speaker. It must be called after Setup, so that
+// the router ID can be taken from the access interface. No-op if BGP is not
+// configured.
+func (m *Manager) StartBGP(ctx context.Context) error {
+	if m.bgp == nil {
+		return nil
+	}
+	return m.bgp.Start(ctx)
+}
+
+// StopBGP stops the BGP speaker and removes the routes it imported. No-op
+// if BGP is not configured.
+func (m *Manager) StopBGP() error {
+	if m.bgp == nil {
+		return nil
+	}
+	return m.bgp.Stop()
+}
+
+// UpdateBGP applies the BGP policy from the desired BridgeConfig. No-op if
+// BGP is not configured.
+func (m *Manager) UpdateBGP(policy *api.BridgeBGPConfig) error {
+	if m.bgp == nil {
+		return nil
+	}
+	return m.bgp.Configure(policy)
+}
+
+// SetProtectedSources sets sources of addresses, such as ControlPlaneAddrs
+// and DefaultGateways, that UpdateRoutes refuses to route via the access
+// interface unless AllowFullTunnel is set. The BGP speaker does not import
+// routes that capture them either. It must be called before Setup.
+func (m *Manager) SetProtectedSources(srcs ...ProtectedSource) {
+	m.protectedSources = srcs
+	if m.bgp != nil {
+		m.bgp.SetProtectedSources(srcs...)
+	}
+}
+
 // UpdateRoutes computes the diff between current active routes and the desired
-// subnets, adding new and removing stale routes.
+// subnets, adding new and removing stale routes. Stale routes are removed
+// before new routes are added; within each step up to routeWorkers routes
+// are programmed concurrently. Subnets whose route would replace the default
+// route or capture a protected address are refused: they are treated as not
+// desired and returned as validation.Errors, which the reconciler reports
+// as drift.
 func (m *Manager) UpdateRoutes(subnets []string) error {
+	refused := checkAccessRoutes(&m.cfg, m.protectedSources, subnets)
+	for _, e := range refused {
+		m.logger.Warn("bridge: update routes: route refused",
+			"component", "bridge",
+			"subnet", e.ID,
+			"error", e.Message,
+		)
+	}
+	subnets = slices.DeleteFunc(slices.Clone(subnets), refused.Invalid)
+
 	desired := make(map[string]struct{}, len(subnets))
 	for _, s := range subnets {
 		desired[s] = struct{}{}
 	}
 
-	var errs []error
+	errs := []error{refused.Err()}
 
 	// Remove stale routes.
+	var stale []string
 	for subnet := range m.activeRoutes {
 		if _, ok := desired[subnet]; !ok {
-			if err := m.ctrl.RemoveRoute(subnet, m.cfg.AccessInterface); err != nil {
-				m.logger.Error("bridge: update routes: remove stale route failed",
-					"component", "bridge",
-					"subnet", subnet,
-					"error", err,
-				)
-				errs = append(errs, err)
-			} else {
-				delete(m.activeRoutes, subnet)
-			}
+			stale = append(stale, subnet)
+		}
+	}
+	for i, err := range forEachSubnet(stale, func(subnet string) error {
+		return m.ctrl.RemoveRoute(subnet, m.cfg.AccessInterface)
+	}) {
+		if err != nil {
+			m.logger.Error("bridge: update routes: remove stale route failed",
+				"component", "bridge",
+				"subnet", stale[i],
+				"error", err,
+			)
+			errs = append(errs, err)
+		} else {
+			delete(m.activeRoutes, stale[i])
 		}
 	}
 
 	// Add new routes.
+	var added []string
 	for _, subnet := range subnets {
-		if _, ok := m.activeRoutes[subnet]; !ok {
-			if err := m.ctrl.AddRoute(subnet, m.cfg.AccessInterface); err != nil {
-				m.logger.Error("bridge: update routes: add route failed",
+		if _, ok := m.activeRoutes[subnet]; ok {
+			continue
+		}
+		if _, ok := desired[subnet]; ok {
+			// Queue each subnet once, even if it is listed twice.
+			delete(desired, subnet)
+			added = append(added, subnet)
+		}
+	}
+	for i, err := range forEachSubnet(added, func(subnet string) error {
+		return m.ctrl.AddRoute(subnet, m.cfg.AccessInterface)
+	}) {
+		if err != nil {
+			m.logger.Error("bridge: update routes: add route failed",
+				"component", "bridge",
+				"subnet", added[i],
+				"error", err,
+			)
+			errs = append(errs, err)
+		} else {
+			m.activeRoutes[added[i]] = struct{}{}
+		}
+	}
+
+	// Masquerade rules and BGP advertisements follow the routed subnets.
+	if m.active {
+		errs = append(errs, m.applyNAT(m.natOn))
+		if m.bgp != nil {
+			errs = append(errs, m.bgp.SetAccessSubnets(subnetsFromSet(m.activeRoutes)))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// UpdateNAT applies BridgeConfig.EnableNAT from the control plane. When the
+// local config disables NAT, masquerading stays off whatever the control
+// plane asks for. Before Setup the setting is only recorded.
+func (m *Manager) UpdateNAT(enabled bool) error {
+	m.natOn = enabled && m.cfg.natEnabled()
+	if !m.active {
+		return nil
+	}
+	return m.applyNAT(m.natOn)
+}
+
+// applyNAT brings the masquerade rules in line with on and the routed
+// subnets. With a Masquerader it adds a rule for every routed IPv4 subnet
+// and removes the rules of other subnets, then looks for conflicting rules
+// outside plexd; otherwise it adds or removes the interface-wide masquerade.
+// Failures are logged and joined; the remaining rules are still applied.
+func (m *Manager) applyNAT(on bool) error {
+	masq, ok := m.ctrl.(Masquerader)
+	if !ok {
+		switch {
+		case on && !m.natConfigured:
+			if err := m.ctrl.AddNATMasquerade(m.cfg.AccessInterface); err != nil {
+				return err
+			}
+			m.natConfigured = true
+		case !on && m.natConfigured:
+			m.natConfigured = false
+			if err := m.ctrl.RemoveNATMasquerade(m.cfg.AccessInterface); err != nil {
+				m.logger.Error("bridge: remove NAT masquerade failed",
 					"component", "bridge",
-					"subnet", subnet,
 					"error", err,
 				)
-				errs = append(errs, err)
-			} else {
-				m.activeRoutes[subnet] = struct{}{}
+				return err
+			}
+		}
+		return nil
+	}
+
+	wanted := make(map[string]struct{})
+	if on {
+		for subnet := range m.activeRoutes {
+			if p, err := netip.ParsePrefix(subnet); err == nil && p.Addr().Is4() {
+				wanted[p.Masked().String()] = struct{}{}
 			}
 		}
 	}
 
+	var errs []error
+	for subnet := range m.natRules {
+		if _, ok := wanted[subnet]; ok {
+			continue
+		}
+		if err := masq.RemoveMasquerade(m.cfg.AccessInterface, subnet); err != nil {
+			m.logger.Error("bridge: remove masquerade failed",
+				"component", "bridge",
+				"subnet", subnet,
+				"error", err,
+			)
+			errs = append(errs, err)
+		}
+		delete(m.natRules, subnet)
+	}
+	for _, subnet := range slices.Sorted(maps.Keys(wanted)) {
+		if _, ok := m.natRules[subnet]; ok {
+			continue
+		}
+		if err := masq.AddMasquerade(m.cfg.AccessInterface, subnet); err != nil {
+			m.logger.Error("bridge: add masquerade failed",
+				"component", "bridge",
+				"subnet", subnet,
+				"error", err,
+			)
+			errs = append(errs, err)
+			continue
+		}
+		m.natRules[subnet] = struct{}{}
+	}
+
+	m.checkNATConflicts(masq)
 	return errors.Join(errs...)
 }
 
+// checkNATConflicts records the source NAT rules outside plexd that also
+// match the access interface, and warns when they change. plexd's rules are
+// kept: a conflicting rule is reported, not removed.
+func (m *Manager) checkNATConflicts(masq Masquerader) {
+	if len(m.natRules) == 0 {
+		m.natConflicts = nil
+		return
+	}
+	conflicts, err := masq.MasqueradeConflicts(m.cfg.AccessInterface)
+	if err != nil {
+		m.logger.Warn("bridge: check masquerade conflicts failed",
+			"component", "bridge",
+			"error", err,
+		)
+		return
+	}
+	if len(conflicts) > 0 && !slices.Equal(conflicts, m.natConflicts) {
+		m.logger.Warn("bridge: NAT rules outside plexd also translate traffic on the access interface",
+			"component", "bridge",
+			"access_iface", m.cfg.AccessInterface,
+			"conflicts", conflicts,
+		)
+	}
+	m.natConflicts = conflicts
+}
+
+// routeWorkers bounds the number of routes UpdateRoutes programs at once.
+// Each route is a separate kernel request, so large route sets are
+// dominated by round trips that can overlap.
+const routeWorkers = 8
+
+// forEachSubnet calls fn for every subnet with at most routeWorkers calls in
+// flight and returns the error of each call at the subnet's index.
+func forEachSubnet(subnets []string, fn func(subnet string) error) []error {
+	errs := make([]error, len(subnets))
+	sem := make(chan struct{}, routeWorkers)
+	var wg sync.WaitGroup
+	for i, subnet := range subnets {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = fn(subnet)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
 // BridgeStatus returns bridge status for heartbeat reporting.
 // Returns nil when bridge mode is not active.
 func (m *Manager) BridgeStatus() *api.BridgeInfo {
@@ -269,14 +692,65 @@ func (m *Manager) BridgeStatus() *api.BridgeInfo {
 		Enabled:         true,
 		AccessInterface: m.cfg.AccessInterface,
 		ActiveRoutes:    len(m.activeRoutes),
+		NATEnabled:      m.natOn,
+		NATRules:        len(m.natRules),
+		NATConflicts:    m.natConflicts,
+	}
+	if m.natConfigured {
+		info.NATRules = 1
+	}
+	if m.cfg.approvalMode() {
+		info.ProposedAccessSubnets = m.ProposedSubnets()
 	}
 	if m.relay != nil {
 		info.RelayEnabled = true
 		info.ActiveRelaySessions = m.relay.ActiveCount()
+		info.RelayShaping = m.relay.ShapingStatus()
+	}
+	if m.ha != nil {
+		info.HA = m.ha.Status()
+	}
+	if m.dnsFwd != nil {
+		info.DNSForwardEnabled = true
+		info.DNSNames = m.dnsFwd.Names()
+	}
+	if m.bgp != nil {
+		info.BGP = m.bgp.Status()
 	}
 	return info
 }
 
+// ProposedSubnets returns the access subnets awaiting confirmation by the
+// control plane in the approval access subnet mode: the subnets found on the
+// access interface and the configured AccessSubnets, less those already
+// routed, sorted. A failed interface scan leaves only the configured subnets.
+func (m *Manager) ProposedSubnets() []string {
+	candidates := slices.Clone(m.cfg.AccessSubnets)
+	detected, err := m.detectSubnets(m.cfg.AccessInterface)
+	if err != nil {
+		m.logger.Debug("bridge: detect access subnets failed",
+			"component", "bridge",
+			"error", err,
+		)
+	}
+	candidates = append(candidates, detected...)
+
+	routed := make(map[string]struct{}, len(m.activeRoutes))
+	for subnet := range m.activeRoutes {
+		routed[normalizeSubnet(subnet)] = struct{}{}
+	}
+	var proposed []string
+	for _, subnet := range candidates {
+		subnet = normalizeSubnet(subnet)
+		if _, ok := routed[subnet]; ok || slices.Contains(proposed, subnet) {
+			continue
+		}
+		proposed = append(proposed, subnet)
+	}
+	slices.Sort(proposed)
+	return proposed
+}
+
 // BridgeCapabilities returns bridge capability metadata for registration.
 // Returns nil when bridge mode is disabled.
 func (m *Manager) BridgeCapabilities() map[string]string {
@@ -287,12 +761,33 @@ func (m *Manager) BridgeCapabilities() map[string]string {
 		"bridge":           "true",
 		"access_interface": m.cfg.AccessInterface,
 	}
-	for i, s := range m.cfg.AccessSubnets {
-		caps[fmt.Sprintf("access_subnet_%d", i)] = s
+	if m.cfg.approvalMode() {
+		caps["access_subnet_mode"] = AccessSubnetModeApproval
+		for i, s := range m.ProposedSubnets() {
+			caps[fmt.Sprintf("proposed_access_subnet_%d", i)] = s
+		}
+	} else {
+		for i, s := range m.cfg.AccessSubnets {
+			caps[fmt.Sprintf("access_subnet_%d", i)] = s
+		}
 	}
 	if m.cfg.RelayEnabled {
 		caps["relay"] = "true"
 		caps["relay_listen_port"] = fmt.Sprintf("%d", m.cfg.RelayListenPort)
 	}
+	if m.fastPath != nil {
+		caps["fast_path"] = m.fastPath.Name()
+	}
+	if _, ok := m.ctrl.(MSSClamper); ok {
+		caps["mss_clamp"] = m.cfg.MSSClamp
+	}
+	if m.dnsFwd != nil {
+		caps["dns_forward"] = "true"
+		caps["dns_domain"] = m.cfg.DNSDomain
+	}
+	if m.bgp != nil {
+		caps["bgp"] = "true"
+		caps["bgp_local_as"] = fmt.Sprintf("%d", m.cfg.BGPLocalAS)
+	}
 	return caps
 }
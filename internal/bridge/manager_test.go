@@ -2,8 +2,16 @@ package bridge
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/netip"
+	"slices"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/validation"
 )
 
 func TestManager_Setup_Enabled(t *testing.T) {
@@ -501,6 +509,41 @@ func TestManager_BridgeStatus_WithRelay(t *testing.T) {
 	}
 }
 
+func TestManager_BridgeStatus_WithHA(t *testing.T) {
+	ctrl := &mockRouteController{}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if info := mgr.BridgeStatus(); info.HA != nil {
+		t.Errorf("BridgeInfo.HA = %+v, want nil without a group", info.HA)
+	}
+
+	if err := mgr.UpdateHA(&api.BridgeHAConfig{GroupID: "site-a", Priority: 50}); err != nil {
+		t.Fatalf("UpdateHA: %v", err)
+	}
+	info := mgr.BridgeStatus()
+	if info.HA == nil {
+		t.Fatal("BridgeInfo.HA should be set in a group")
+	}
+	if info.HA.GroupID != "site-a" || info.HA.State != HAStateStandby || info.HA.Priority != 50 {
+		t.Errorf("BridgeInfo.HA = %+v", info.HA)
+	}
+
+	if err := mgr.Teardown(); err != nil {
+		t.Fatalf("Teardown: %v", err)
+	}
+	if got := mgr.HA().State(); got != HAStateDisabled {
+		t.Errorf("HA state after Teardown = %q, want %q", got, HAStateDisabled)
+	}
+}
+
 func TestManager_BridgeCapabilities_WithRelay(t *testing.T) {
 	ctrl := &mockRouteController{}
 	cfg := Config{
@@ -593,3 +636,451 @@ func TestManager_UpdateRoutes(t *testing.T) {
 		t.Errorf("ActiveRoutes = %d, want 2", info.ActiveRoutes)
 	}
 }
+
+// slowRouteController is a mockRouteController whose AddRoute blocks briefly
+// and records the highest number of concurrent calls.
+type slowRouteController struct {
+	mockRouteController
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+}
+
+func (c *slowRouteController) AddRoute(subnet, iface string) error {
+	n := c.inFlight.Add(1)
+	defer c.inFlight.Add(-1)
+	for {
+		max := c.maxInFlight.Load()
+		if n <= max || c.maxInFlight.CompareAndSwap(max, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	return c.mockRouteController.AddRoute(subnet, iface)
+}
+
+func TestManager_UpdateRoutes_RefusesBlackhole(t *testing.T) {
+	ctrl := &mockRouteController{}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+		EnableNAT:       BoolPtr(false),
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+	mgr.SetProtectedSources(ProtectedSourceFunc(func() []validation.ProtectedAddr {
+		return []validation.ProtectedAddr{{Addr: netip.MustParseAddr("198.51.100.10"), Owner: "control plane"}}
+	}))
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	ctrl.reset()
+
+	err := mgr.UpdateRoutes([]string{"10.0.0.0/24", "0.0.0.0/0", "198.51.100.0/24"})
+
+	var verrs validation.Errors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("UpdateRoutes = %v, want validation.Errors", err)
+	}
+	if len(verrs) != 2 || verrs[0].ID != "0.0.0.0/0" || verrs[1].ID != "198.51.100.0/24" || verrs[1].Code != validation.CodeRouteBlackhole {
+		t.Errorf("refused = %v", verrs)
+	}
+	if n := len(ctrl.callsFor("AddRoute")); n != 0 {
+		t.Errorf("expected no AddRoute calls, got %d", n)
+	}
+	if got := mgr.BridgeStatus().ActiveRoutes; got != 1 {
+		t.Errorf("ActiveRoutes = %d, want 1", got)
+	}
+
+	// AllowFullTunnel permits the same routes.
+	cfg.AllowFullTunnel = true
+	full := NewManager(ctrl, cfg, discardLogger())
+	if err := full.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := full.UpdateRoutes([]string{"10.0.0.0/24", "0.0.0.0/0"}); err != nil {
+		t.Fatalf("UpdateRoutes with AllowFullTunnel: %v", err)
+	}
+}
+
+func TestManager_UpdateRoutes_Concurrent(t *testing.T) {
+	ctrl := &slowRouteController{}
+	ctrl.addRouteErrFor = map[string]error{"10.0.7.0/24": fmt.Errorf("route rejected")}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		EnableNAT:       BoolPtr(false),
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+
+	var subnets []string
+	for i := range 64 {
+		subnets = append(subnets, fmt.Sprintf("10.0.%d.0/24", i))
+	}
+	// A duplicate is programmed once.
+	subnets = append(subnets, subnets[0])
+
+	if err := mgr.UpdateRoutes(subnets); err == nil {
+		t.Fatal("UpdateRoutes = nil, want error for rejected route")
+	}
+	if n := len(ctrl.callsFor("AddRoute")); n != 64 {
+		t.Errorf("expected 64 AddRoute calls, got %d", n)
+	}
+	if max := ctrl.maxInFlight.Load(); max < 2 || max > routeWorkers {
+		t.Errorf("max concurrent AddRoute calls = %d, want 2..%d", max, routeWorkers)
+	}
+	if got := mgr.BridgeStatus().ActiveRoutes; got != 63 {
+		t.Errorf("ActiveRoutes = %d, want 63", got)
+	}
+
+	// The failed route is retried by the next update.
+	ctrl.mu.Lock()
+	ctrl.addRouteErrFor = nil
+	ctrl.mu.Unlock()
+	if err := mgr.UpdateRoutes(subnets); err != nil {
+		t.Fatalf("UpdateRoutes retry: %v", err)
+	}
+	if got := mgr.BridgeStatus().ActiveRoutes; got != 64 {
+		t.Errorf("ActiveRoutes after retry = %d, want 64", got)
+	}
+}
+
+func TestManager_Teardown_FlushesRouteTable(t *testing.T) {
+	ctrl := &mockFlushingRouteController{}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+		RouteTable:      51820,
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := mgr.Teardown(); err != nil {
+		t.Fatalf("Teardown: %v", err)
+	}
+
+	if calls := ctrl.callsFor("FlushRouteTable"); len(calls) != 1 {
+		t.Fatalf("expected 1 FlushRouteTable call, got %d", len(calls))
+	}
+}
+
+func TestManager_Teardown_NoFlushWithMainTable(t *testing.T) {
+	ctrl := &mockFlushingRouteController{}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := mgr.Teardown(); err != nil {
+		t.Fatalf("Teardown: %v", err)
+	}
+
+	if calls := ctrl.callsFor("FlushRouteTable"); len(calls) != 0 {
+		t.Fatalf("expected no FlushRouteTable calls, got %d", len(calls))
+	}
+}
+
+func TestManager_Teardown_FlushError(t *testing.T) {
+	ctrl := &mockFlushingRouteController{flushErr: fmt.Errorf("flush failed")}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+		RouteTable:      51820,
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := mgr.Teardown(); err == nil {
+		t.Fatal("expected Teardown error when flush fails")
+	}
+}
+
+// masqSubnets returns the subnet argument of each call, sorted.
+func masqSubnets(calls []mockCall) []string {
+	var subnets []string
+	for _, c := range calls {
+		subnets = append(subnets, c.Args[1].(string))
+	}
+	slices.Sort(subnets)
+	return subnets
+}
+
+func TestManager_Setup_MasqueradePerSubnet(t *testing.T) {
+	ctrl := &mockMasqRouteController{conflicts: []string{"-A POSTROUTING -o eth1 -j MASQUERADE"}}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24", "192.168.1.0/24", "fd00::/64"},
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if n := len(ctrl.callsFor("AddNATMasquerade")); n != 0 {
+		t.Errorf("AddNATMasquerade calls = %d, want 0", n)
+	}
+	if got := masqSubnets(ctrl.callsFor("AddMasquerade")); !slices.Equal(got, []string{"10.0.0.0/24", "192.168.1.0/24"}) {
+		t.Errorf("AddMasquerade subnets = %v, want the IPv4 access subnets", got)
+	}
+
+	info := mgr.BridgeStatus()
+	if !info.NATEnabled || info.NATRules != 2 {
+		t.Errorf("NATEnabled = %v, NATRules = %d, want true, 2", info.NATEnabled, info.NATRules)
+	}
+	if !slices.Equal(info.NATConflicts, ctrl.conflicts) {
+		t.Errorf("NATConflicts = %v, want %v", info.NATConflicts, ctrl.conflicts)
+	}
+
+	if err := mgr.Teardown(); err != nil {
+		t.Fatalf("Teardown: %v", err)
+	}
+	if got := masqSubnets(ctrl.callsFor("RemoveMasquerade")); !slices.Equal(got, []string{"10.0.0.0/24", "192.168.1.0/24"}) {
+		t.Errorf("RemoveMasquerade subnets = %v, want the IPv4 access subnets", got)
+	}
+}
+
+func TestManager_Setup_MasqueradeRollback(t *testing.T) {
+	ctrl := &mockMasqRouteController{
+		addMasqueradeErrFor: map[string]error{"192.168.1.0/24": fmt.Errorf("nft failed")},
+	}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24", "192.168.1.0/24"},
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+
+	if err := mgr.Setup("wg0"); err == nil {
+		t.Fatal("Setup succeeded, want error")
+	}
+	if got := masqSubnets(ctrl.callsFor("RemoveMasquerade")); !slices.Equal(got, []string{"10.0.0.0/24"}) {
+		t.Errorf("RemoveMasquerade subnets = %v, want [10.0.0.0/24]", got)
+	}
+	if n := len(ctrl.callsFor("RemoveRoute")); n != 2 {
+		t.Errorf("RemoveRoute calls = %d, want 2", n)
+	}
+}
+
+func TestManager_UpdateRoutes_Masquerade(t *testing.T) {
+	ctrl := &mockMasqRouteController{}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24", "10.1.0.0/24"},
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	ctrl.reset()
+
+	if err := mgr.UpdateRoutes([]string{"10.1.0.0/24", "10.2.0.0/24"}); err != nil {
+		t.Fatalf("UpdateRoutes: %v", err)
+	}
+	if got := masqSubnets(ctrl.callsFor("RemoveMasquerade")); !slices.Equal(got, []string{"10.0.0.0/24"}) {
+		t.Errorf("RemoveMasquerade subnets = %v, want [10.0.0.0/24]", got)
+	}
+	if got := masqSubnets(ctrl.callsFor("AddMasquerade")); !slices.Equal(got, []string{"10.2.0.0/24"}) {
+		t.Errorf("AddMasquerade subnets = %v, want [10.2.0.0/24]", got)
+	}
+	if n := mgr.BridgeStatus().NATRules; n != 2 {
+		t.Errorf("NATRules = %d, want 2", n)
+	}
+}
+
+func TestManager_UpdateNAT(t *testing.T) {
+	ctrl := &mockMasqRouteController{}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+
+	if err := mgr.UpdateNAT(false); err != nil {
+		t.Fatalf("UpdateNAT(false): %v", err)
+	}
+	if info := mgr.BridgeStatus(); info.NATEnabled || info.NATRules != 0 {
+		t.Errorf("after disable: NATEnabled = %v, NATRules = %d, want false, 0", info.NATEnabled, info.NATRules)
+	}
+	if n := len(ctrl.callsFor("RemoveMasquerade")); n != 1 {
+		t.Errorf("RemoveMasquerade calls = %d, want 1", n)
+	}
+
+	if err := mgr.UpdateNAT(true); err != nil {
+		t.Fatalf("UpdateNAT(true): %v", err)
+	}
+	if info := mgr.BridgeStatus(); !info.NATEnabled || info.NATRules != 1 {
+		t.Errorf("after enable: NATEnabled = %v, NATRules = %d, want true, 1", info.NATEnabled, info.NATRules)
+	}
+}
+
+func TestManager_UpdateNAT_LocallyDisabled(t *testing.T) {
+	ctrl := &mockMasqRouteController{}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+		EnableNAT:       BoolPtr(false),
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := mgr.UpdateNAT(true); err != nil {
+		t.Fatalf("UpdateNAT: %v", err)
+	}
+	if n := len(ctrl.callsFor("AddMasquerade")); n != 0 {
+		t.Errorf("AddMasquerade calls = %d, want 0", n)
+	}
+}
+
+func TestManager_ApprovalMode(t *testing.T) {
+	ctrl := &mockRouteController{}
+	cfg := Config{
+		Enabled:          true,
+		AccessInterface:  "eth1",
+		AccessSubnets:    []string{"10.9.0.0/24"},
+		AccessSubnetMode: AccessSubnetModeApproval,
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+	mgr.detectSubnets = func(iface string) ([]string, error) {
+		if iface != "eth1" {
+			t.Errorf("detectSubnets(%q), want eth1", iface)
+		}
+		return []string{"192.168.1.0/24", "10.0.0.0/16"}, nil
+	}
+
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if n := len(ctrl.callsFor("AddRoute")); n != 0 {
+		t.Errorf("AddRoute calls after Setup = %d, want 0", n)
+	}
+
+	want := []string{"10.0.0.0/16", "10.9.0.0/24", "192.168.1.0/24"}
+	if got := mgr.BridgeStatus().ProposedAccessSubnets; !slices.Equal(got, want) {
+		t.Errorf("ProposedAccessSubnets = %v, want %v", got, want)
+	}
+	caps := mgr.BridgeCapabilities()
+	if caps["access_subnet_mode"] != AccessSubnetModeApproval || caps["proposed_access_subnet_0"] != "10.0.0.0/16" {
+		t.Errorf("BridgeCapabilities = %v, want approval mode with proposals", caps)
+	}
+	if _, ok := caps["access_subnet_0"]; ok {
+		t.Error("BridgeCapabilities advertises an unconfirmed access subnet")
+	}
+
+	// The control plane confirms one proposal.
+	if err := mgr.UpdateRoutes([]string{"192.168.1.0/24"}); err != nil {
+		t.Fatalf("UpdateRoutes: %v", err)
+	}
+	routes := ctrl.callsFor("AddRoute")
+	if len(routes) != 1 || routes[0].Args[0] != "192.168.1.0/24" {
+		t.Errorf("AddRoute calls = %v, want only the confirmed subnet", routes)
+	}
+	want = []string{"10.0.0.0/16", "10.9.0.0/24"}
+	if got := mgr.BridgeStatus().ProposedAccessSubnets; !slices.Equal(got, want) {
+		t.Errorf("ProposedAccessSubnets = %v, want %v", got, want)
+	}
+}
+
+func TestManager_ApprovalMode_DetectFails(t *testing.T) {
+	cfg := Config{
+		Enabled:          true,
+		AccessInterface:  "eth1",
+		AccessSubnets:    []string{"10.9.0.1/24"},
+		AccessSubnetMode: AccessSubnetModeApproval,
+	}
+	mgr := NewManager(&mockRouteController{}, cfg, discardLogger())
+	mgr.detectSubnets = func(string) ([]string, error) {
+		return nil, fmt.Errorf("no such interface")
+	}
+	if got := mgr.ProposedSubnets(); !slices.Equal(got, []string{"10.9.0.0/24"}) {
+		t.Errorf("ProposedSubnets = %v, want the configured subnet", got)
+	}
+}
+
+func TestManager_StaticMode_NoProposals(t *testing.T) {
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+	}
+	mgr := NewManager(&mockRouteController{}, cfg, discardLogger())
+	mgr.detectSubnets = func(string) ([]string, error) {
+		t.Error("detectSubnets called in static mode")
+		return nil, nil
+	}
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if got := mgr.BridgeStatus().ProposedAccessSubnets; got != nil {
+		t.Errorf("ProposedAccessSubnets = %v, want nil", got)
+	}
+}
+
+func TestManager_MSSClamp(t *testing.T) {
+	ctrl := &mockClampingRouteController{}
+	cfg := Config{
+		Enabled:         true,
+		AccessInterface: "eth1",
+		AccessSubnets:   []string{"10.0.0.0/24"},
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	calls := ctrl.callsFor("SetMSSClamp")
+	if len(calls) != 1 || calls[0].Args[0] != "eth1" || calls[0].Args[1] != 0 {
+		t.Fatalf("SetMSSClamp calls = %v, want [eth1 0]", calls)
+	}
+	if caps := mgr.BridgeCapabilities(); caps["mss_clamp"] != MSSClampPMTU {
+		t.Errorf("caps[mss_clamp] = %q, want %q", caps["mss_clamp"], MSSClampPMTU)
+	}
+
+	if err := mgr.Teardown(); err != nil {
+		t.Fatalf("Teardown: %v", err)
+	}
+	if calls := ctrl.callsFor("ClearMSSClamp"); len(calls) != 1 || calls[0].Args[0] != "eth1" {
+		t.Errorf("ClearMSSClamp calls = %v, want [eth1]", calls)
+	}
+}
+
+func TestManager_MSSClamp_ErrorKeepsSetup(t *testing.T) {
+	ctrl := &mockClampingRouteController{setMSSClampErr: errors.New("nft failed")}
+	cfg := Config{
+		Enabled:            true,
+		AccessInterface:    "eth1",
+		AccessSubnets:      []string{"10.0.0.0/24"},
+		MSSClampInterfaces: map[string]string{"eth1": "1360"},
+	}
+	mgr := NewManager(ctrl, cfg, discardLogger())
+
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if calls := ctrl.callsFor("SetMSSClamp"); len(calls) != 1 || calls[0].Args[1] != 1360 {
+		t.Errorf("SetMSSClamp calls = %v, want [eth1 1360]", calls)
+	}
+	if st := mgr.BridgeStatus(); st == nil || !st.Enabled {
+		t.Errorf("BridgeStatus = %+v, want active bridge", st)
+	}
+}
@@ -0,0 +1,146 @@
+//go:build linux
+
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// iptablesMasqueradeComment tags the iptables rules added by plexd, so that
+// MasqueradeConflicts can tell them from rules of the host firewall.
+const iptablesMasqueradeComment = "plexd-bridge"
+
+// iptablesMasquerader programs masquerade rules in the POSTROUTING chain of
+// the iptables nat table, for the iptables NAT backend.
+type iptablesMasquerader struct {
+	// run executes iptables with args and returns its combined output and
+	// exit status. err is set only when iptables could not be run.
+	run func(args ...string) (out []byte, status int, err error)
+}
+
+func newIPTablesMasquerader() *iptablesMasquerader {
+	return &iptablesMasquerader{run: runIPTables}
+}
+
+// runIPTables runs the iptables command, waiting for the xtables lock.
+func runIPTables(args ...string) ([]byte, int, error) {
+	out, err := exec.Command("iptables", append([]string{"-w"}, args...)...).CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return out, exitErr.ExitCode(), nil
+	}
+	return out, 0, err
+}
+
+// add appends the masquerade rule for iface and subnet unless it exists.
+func (m *iptablesMasquerader) add(iface, subnet string) error {
+	ok, err := m.exists(iface, subnet)
+	if err != nil || ok {
+		return err
+	}
+	return m.exec(append([]string{"-t", "nat", "-A"}, masqueradeRuleSpec(iface, subnet)...)...)
+}
+
+// remove deletes every copy of the masquerade rule for iface and subnet.
+func (m *iptablesMasquerader) remove(iface, subnet string) error {
+	for {
+		ok, err := m.exists(iface, subnet)
+		if err != nil || !ok {
+			return err
+		}
+		if err := m.exec(append([]string{"-t", "nat", "-D"}, masqueradeRuleSpec(iface, subnet)...)...); err != nil {
+			return err
+		}
+	}
+}
+
+// conflicts returns the POSTROUTING rules not added by plexd that
+// masquerade or SNAT traffic sent on iface, in iptables -S format.
+func (m *iptablesMasquerader) conflicts(iface string) ([]string, error) {
+	out, status, err := m.run("-t", "nat", "-S", "POSTROUTING")
+	if err != nil {
+		return nil, fmt.Errorf("iptables: %w", err)
+	}
+	if status != 0 {
+		return nil, fmt.Errorf("iptables -S: exit status %d: %s", status, strings.TrimSpace(string(out)))
+	}
+	return parseIPTablesConflicts(string(out), iface), nil
+}
+
+// exists reports whether the masquerade rule for iface and subnet exists.
+func (m *iptablesMasquerader) exists(iface, subnet string) (bool, error) {
+	out, status, err := m.run(append([]string{"-t", "nat", "-C"}, masqueradeRuleSpec(iface, subnet)...)...)
+	switch {
+	case err != nil:
+		return false, fmt.Errorf("iptables: %w", err)
+	case status == 0:
+		return true, nil
+	case status == 1:
+		// -C exits with 1 when the rule does not exist.
+		return false, nil
+	default:
+		return false, fmt.Errorf("iptables -C: exit status %d: %s", status, strings.TrimSpace(string(out)))
+	}
+}
+
+// exec runs iptables with args and fails on a non-zero exit status.
+func (m *iptablesMasquerader) exec(args ...string) error {
+	out, status, err := m.run(args...)
+	if err != nil {
+		return fmt.Errorf("iptables: %w", err)
+	}
+	if status != 0 {
+		return fmt.Errorf("iptables %s: exit status %d: %s", args[2], status, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// masqueradeRuleSpec returns the POSTROUTING rule masquerading traffic sent
+// on iface to subnet.
+func masqueradeRuleSpec(iface, subnet string) []string {
+	return []string{
+		"POSTROUTING",
+		"-o", iface,
+		"-d", subnet,
+		"-m", "comment", "--comment", iptablesMasqueradeComment,
+		"-j", "MASQUERADE",
+	}
+}
+
+// parseIPTablesConflicts returns the rules of iptables -S output that jump
+// to MASQUERADE or SNAT, are not tagged with iptablesMasqueradeComment, and
+// either have no output interface match or one that accepts iface. An
+// interface name ending in '+' matches by prefix.
+func parseIPTablesConflicts(out, iface string) []string {
+	var conflicts []string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "-A" {
+			continue
+		}
+		if strings.Contains(line, iptablesMasqueradeComment) {
+			continue
+		}
+		j := slices.Index(fields, "-j")
+		if j < 0 || j+1 >= len(fields) || (fields[j+1] != "MASQUERADE" && fields[j+1] != "SNAT") {
+			continue
+		}
+		if o := slices.Index(fields, "-o"); o >= 0 && o+1 < len(fields) {
+			name := fields[o+1]
+			match := name == iface
+			if prefix, ok := strings.CutSuffix(name, "+"); ok {
+				match = strings.HasPrefix(iface, prefix)
+			}
+			negated := o > 0 && fields[o-1] == "!"
+			if match == negated {
+				continue
+			}
+		}
+		conflicts = append(conflicts, strings.TrimSpace(line))
+	}
+	return conflicts
+}
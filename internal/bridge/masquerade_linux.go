@@ -0,0 +1,264 @@
+//go:build linux
+
+package bridge
+
+import (
+	"bytes"
+	"fmt"
+	"net/netip"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+)
+
+// AddMasquerade installs a rule in the plexd-nat table that masquerades
+// traffic sent on iface to subnet. An existing rule for the same interface
+// and subnet is replaced. With the iptables NAT backend the rule is added to
+// the POSTROUTING chain of the iptables nat table instead.
+// nft equivalent:
+//
+//	chain postrouting { type nat hook postrouting priority srcnat; oifname "eth1" ip daddr 192.168.1.0/24 masquerade }
+func (c *NetlinkRouteController) AddMasquerade(iface, subnet string) error {
+	if err := validateIfaceName(iface); err != nil {
+		return fmt.Errorf("bridge: add masquerade: %w", err)
+	}
+	dst, err := parseMasqueradeSubnet(subnet)
+	if err != nil {
+		return fmt.Errorf("bridge: add masquerade on %q: %w", iface, err)
+	}
+	if ipt := c.iptablesBackend(); ipt != nil {
+		if err := ipt.add(iface, subnet); err != nil {
+			return fmt.Errorf("bridge: add masquerade %s on %q: %w", subnet, iface, err)
+		}
+		c.logMasquerade("masquerade added", iface, subnet)
+		return nil
+	}
+
+	conn, err := nftables.New()
+	if err != nil {
+		return fmt.Errorf("bridge: add masquerade: %w", err)
+	}
+	table := conn.AddTable(&nftables.Table{
+		Family: nftables.TableFamilyIPv4,
+		Name:   natTableName,
+	})
+	chain := conn.AddChain(&nftables.Chain{
+		Name:     natChainName,
+		Table:    table,
+		Type:     nftables.ChainTypeNAT,
+		Hooknum:  nftables.ChainHookPostrouting,
+		Priority: nftables.ChainPriorityNATSource,
+	})
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("bridge: add masquerade %s on %q: create table: %w", subnet, iface, err)
+	}
+
+	userData := masqueradeUserData(iface, subnet)
+	if err := delMasqueradeRules(conn, table, userData); err != nil {
+		return fmt.Errorf("bridge: add masquerade %s on %q: %w", subnet, iface, err)
+	}
+	conn.AddRule(&nftables.Rule{
+		Table:    table,
+		Chain:    chain,
+		Exprs:    masqueradeExprs(iface, dst),
+		UserData: userData,
+	})
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("bridge: add masquerade %s on %q: %w", subnet, iface, err)
+	}
+
+	c.logMasquerade("masquerade added", iface, subnet)
+	return nil
+}
+
+// RemoveMasquerade deletes the rule installed by AddMasquerade.
+// Idempotent: removing a rule that is not installed returns nil.
+func (c *NetlinkRouteController) RemoveMasquerade(iface, subnet string) error {
+	if ipt := c.iptablesBackend(); ipt != nil {
+		if err := ipt.remove(iface, subnet); err != nil {
+			return fmt.Errorf("bridge: remove masquerade %s on %q: %w", subnet, iface, err)
+		}
+		c.logMasquerade("masquerade removed", iface, subnet)
+		return nil
+	}
+
+	conn, err := nftables.New()
+	if err != nil {
+		return fmt.Errorf("bridge: remove masquerade: %w", err)
+	}
+	if _, err := conn.ListTableOfFamily(natTableName, nftables.TableFamilyIPv4); err != nil {
+		// Table does not exist — idempotent success.
+		return nil
+	}
+	table := &nftables.Table{Family: nftables.TableFamilyIPv4, Name: natTableName}
+	if err := delMasqueradeRules(conn, table, masqueradeUserData(iface, subnet)); err != nil {
+		return fmt.Errorf("bridge: remove masquerade %s on %q: %w", subnet, iface, err)
+	}
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("bridge: remove masquerade %s on %q: %w", subnet, iface, err)
+	}
+
+	c.logMasquerade("masquerade removed", iface, subnet)
+	return nil
+}
+
+// MasqueradeConflicts lists the source NAT rules outside plexd's tables that
+// match traffic sent on iface: rules in IPv4 and inet nat chains on the
+// postrouting hook that masquerade or SNAT and either do not match on the
+// output interface or match iface. With the iptables NAT backend the
+// POSTROUTING chain of the iptables nat table is searched instead.
+func (c *NetlinkRouteController) MasqueradeConflicts(iface string) ([]string, error) {
+	if ipt := c.iptablesBackend(); ipt != nil {
+		conflicts, err := ipt.conflicts(iface)
+		if err != nil {
+			return nil, fmt.Errorf("bridge: masquerade conflicts on %q: %w", iface, err)
+		}
+		return conflicts, nil
+	}
+
+	conn, err := nftables.New()
+	if err != nil {
+		return nil, fmt.Errorf("bridge: masquerade conflicts: %w", err)
+	}
+	chains, err := conn.ListChains()
+	if err != nil {
+		return nil, fmt.Errorf("bridge: masquerade conflicts on %q: list chains: %w", iface, err)
+	}
+	var conflicts []string
+	for _, ch := range chains {
+		if ch.Type != nftables.ChainTypeNAT || ch.Hooknum == nil || *ch.Hooknum != *nftables.ChainHookPostrouting {
+			continue
+		}
+		if ch.Table.Name == natTableName || ch.Table.Name == netmapTableName {
+			continue
+		}
+		family := "ip"
+		switch ch.Table.Family {
+		case nftables.TableFamilyIPv4:
+		case nftables.TableFamilyINet:
+			family = "inet"
+		default:
+			continue
+		}
+		rules, err := conn.GetRules(ch.Table, ch)
+		if err != nil {
+			return nil, fmt.Errorf("bridge: masquerade conflicts on %q: list rules of %s %s: %w", iface, ch.Table.Name, ch.Name, err)
+		}
+		for _, r := range rules {
+			if sourceNATMatches(r.Exprs, iface) {
+				conflicts = append(conflicts, fmt.Sprintf("nftables %s %s %s handle %d", family, ch.Table.Name, ch.Name, r.Handle))
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// iptablesBackend returns the iptables masquerader, or nil when masquerade
+// rules are programmed with nftables.
+func (c *NetlinkRouteController) iptablesBackend() *iptablesMasquerader {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.iptables
+}
+
+func (c *NetlinkRouteController) logMasquerade(msg, iface, subnet string) {
+	c.logger.Debug(msg,
+		"component", "bridge",
+		"interface", iface,
+		"subnet", subnet,
+	)
+}
+
+// parseMasqueradeSubnet parses a masquerade destination: an IPv4 CIDR
+// without host bits.
+func parseMasqueradeSubnet(s string) (netip.Prefix, error) {
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if !p.Addr().Is4() {
+		return netip.Prefix{}, fmt.Errorf("%s: only IPv4 subnets are supported", s)
+	}
+	if p != p.Masked() {
+		return netip.Prefix{}, fmt.Errorf("%s: host bits are set", s)
+	}
+	return p, nil
+}
+
+// delMasqueradeRules adds the deletion of the rules tagged with userData to
+// the batch.
+func delMasqueradeRules(conn *nftables.Conn, table *nftables.Table, userData []byte) error {
+	rules, err := conn.GetRules(table, &nftables.Chain{Name: natChainName, Table: table})
+	if err != nil {
+		return fmt.Errorf("list rules: %w", err)
+	}
+	for _, r := range rules {
+		if bytes.Equal(r.UserData, userData) {
+			if err := conn.DelRule(r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// masqueradeUserData tags the rule of a masqueraded subnet.
+func masqueradeUserData(iface, subnet string) []byte {
+	return []byte("masq:" + iface + ":" + subnet)
+}
+
+// masqueradeExprs builds a rule that masquerades packets sent on iface whose
+// IPv4 destination lies in dst.
+func masqueradeExprs(iface string, dst netip.Prefix) []expr.Any {
+	var mask [4]byte
+	for i := range dst.Bits() {
+		mask[i/8] |= 0x80 >> (i % 8)
+	}
+	dstNet := dst.Addr().As4()
+
+	return []expr.Any{
+		&expr.Meta{Key: expr.MetaKeyOIFNAME, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: ifaceNameBytes(iface)},
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: 16, Len: 4},
+		&expr.Bitwise{SourceRegister: 1, DestRegister: 1, Len: 4, Mask: mask[:], Xor: make([]byte, 4)},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: dstNet[:]},
+		&expr.Counter{},
+		&expr.Masq{},
+	}
+}
+
+// sourceNATMatches reports whether a rule masquerades or SNATs traffic sent
+// on iface: it translates source addresses, and every output interface match
+// it has accepts iface. A name match without a terminating NUL is an nft
+// wildcard ("eth*") and matches by prefix.
+func sourceNATMatches(exprs []expr.Any, iface string) bool {
+	snat := false
+	for i, e := range exprs {
+		switch e := e.(type) {
+		case *expr.Masq:
+			snat = true
+		case *expr.NAT:
+			if e.Type == expr.NATTypeSourceNAT {
+				snat = true
+			}
+		case *expr.Meta:
+			if e.Key != expr.MetaKeyOIFNAME || i+1 >= len(exprs) {
+				continue
+			}
+			cmp, ok := exprs[i+1].(*expr.Cmp)
+			if !ok || cmp.Register != e.Register {
+				continue
+			}
+			var match bool
+			if name, ok := bytes.CutSuffix(cmp.Data, []byte{0}); ok {
+				match = string(name) == iface
+			} else {
+				match = bytes.HasPrefix([]byte(iface), cmp.Data)
+			}
+			if match != (cmp.Op == expr.CmpOpEq) {
+				return false
+			}
+		}
+	}
+	return snat
+}
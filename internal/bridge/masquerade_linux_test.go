@@ -0,0 +1,162 @@
+//go:build linux
+
+package bridge
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/nftables/expr"
+)
+
+// Compile-time check that NetlinkRouteController implements Masquerader.
+var _ Masquerader = (*NetlinkRouteController)(nil)
+
+func TestMasqueradeExprs(t *testing.T) {
+	exprs := masqueradeExprs("eth1", netip.MustParsePrefix("192.168.0.0/20"))
+	if _, ok := exprs[len(exprs)-1].(*expr.Masq); !ok {
+		t.Fatalf("last expression = %#v, want masquerade", exprs[len(exprs)-1])
+	}
+	if !sourceNATMatches(exprs, "eth1") {
+		t.Error("sourceNATMatches(own rule, eth1) = false, want true")
+	}
+	if sourceNATMatches(exprs, "eth2") {
+		t.Error("sourceNATMatches(own rule, eth2) = true, want false")
+	}
+}
+
+func TestSourceNATMatches(t *testing.T) {
+	oif := func(op expr.CmpOp, data string) []expr.Any {
+		return []expr.Any{
+			&expr.Meta{Key: expr.MetaKeyOIFNAME, Register: 1},
+			&expr.Cmp{Op: op, Register: 1, Data: []byte(data)},
+		}
+	}
+	tests := []struct {
+		name  string
+		exprs []expr.Any
+		want  bool
+	}{
+		{"masquerade all", []expr.Any{&expr.Masq{}}, true},
+		{"snat all", []expr.Any{&expr.NAT{Type: expr.NATTypeSourceNAT}}, true},
+		{"dnat", []expr.Any{&expr.NAT{Type: expr.NATTypeDestNAT}}, false},
+		{"same interface", append(oif(expr.CmpOpEq, "eth1\x00"), &expr.Masq{}), true},
+		{"other interface", append(oif(expr.CmpOpEq, "eth2\x00"), &expr.Masq{}), false},
+		{"wildcard", append(oif(expr.CmpOpEq, "eth"), &expr.Masq{}), true},
+		{"negated other interface", append(oif(expr.CmpOpNeq, "eth2\x00"), &expr.Masq{}), true},
+		{"negated same interface", append(oif(expr.CmpOpNeq, "eth1\x00"), &expr.Masq{}), false},
+		{"no translation", oif(expr.CmpOpEq, "eth1\x00"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sourceNATMatches(tt.exprs, "eth1"); got != tt.want {
+				t.Errorf("sourceNATMatches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddMasquerade_Invalid(t *testing.T) {
+	c := NewNetlinkRouteController(discardLogger())
+	for _, subnet := range []string{"bad", "fd00::/64", "10.0.0.1/24"} {
+		if err := c.AddMasquerade("eth1", subnet); err == nil {
+			t.Errorf("AddMasquerade(%q) = nil, want error", subnet)
+		}
+	}
+	if err := c.AddMasquerade("", "10.0.0.0/24"); err == nil {
+		t.Error("AddMasquerade with empty interface = nil, want error")
+	}
+}
+
+// fakeIPTables records iptables invocations and keeps the nat POSTROUTING
+// rules in memory.
+type fakeIPTables struct {
+	calls []string
+	rules []string
+}
+
+func (f *fakeIPTables) run(args ...string) ([]byte, int, error) {
+	f.calls = append(f.calls, strings.Join(args, " "))
+	spec := strings.Join(args[3:], " ")
+	switch args[2] {
+	case "-C":
+		if slices.Contains(f.rules, spec) {
+			return nil, 0, nil
+		}
+		return []byte("iptables: Bad rule (does a matching rule exist in that chain?)."), 1, nil
+	case "-A":
+		f.rules = append(f.rules, spec)
+	case "-D":
+		i := slices.Index(f.rules, spec)
+		f.rules = slices.Delete(f.rules, i, i+1)
+	case "-S":
+		return []byte("-P POSTROUTING ACCEPT\n-A " + strings.Join(f.rules, "\n-A ") + "\n"), 0, nil
+	}
+	return nil, 0, nil
+}
+
+func TestIPTablesMasquerader(t *testing.T) {
+	fake := &fakeIPTables{rules: []string{"POSTROUTING -o eth1 -j MASQUERADE"}}
+	m := &iptablesMasquerader{run: fake.run}
+
+	for range 2 {
+		if err := m.add("eth1", "10.0.0.0/24"); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+	if len(fake.rules) != 2 {
+		t.Fatalf("rules = %v, want the host rule and one plexd rule", fake.rules)
+	}
+
+	conflicts, err := m.conflicts("eth1")
+	if err != nil {
+		t.Fatalf("conflicts: %v", err)
+	}
+	if !slices.Equal(conflicts, []string{"-A POSTROUTING -o eth1 -j MASQUERADE"}) {
+		t.Errorf("conflicts = %v, want only the host rule", conflicts)
+	}
+
+	if err := m.remove("eth1", "10.0.0.0/24"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if err := m.remove("eth1", "10.0.0.0/24"); err != nil {
+		t.Fatalf("second remove: %v", err)
+	}
+	if len(fake.rules) != 1 {
+		t.Errorf("rules = %v, want only the host rule", fake.rules)
+	}
+}
+
+func TestIPTablesMasquerader_Error(t *testing.T) {
+	m := &iptablesMasquerader{run: func(args ...string) ([]byte, int, error) {
+		return []byte("iptables v1.8.9: can't initialize iptables table `nat'"), 3, nil
+	}}
+	err := m.add("eth1", "10.0.0.0/24")
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("add = %v, want exit status error", err)
+	}
+}
+
+func TestParseIPTablesConflicts(t *testing.T) {
+	out := `-P POSTROUTING ACCEPT
+-A POSTROUTING -o eth1 -j MASQUERADE
+-A POSTROUTING -o eth2 -j MASQUERADE
+-A POSTROUTING -s 10.0.0.0/8 -j SNAT --to-source 192.0.2.1
+-A POSTROUTING -o eth+ -j MASQUERADE
+-A POSTROUTING ! -o eth1 -j MASQUERADE
+-A POSTROUTING ! -o docker0 -j MASQUERADE
+-A POSTROUTING -o eth1 -j ACCEPT
+-A POSTROUTING -o eth1 -d 10.0.0.0/24 -m comment --comment plexd-bridge -j MASQUERADE
+`
+	want := []string{
+		"-A POSTROUTING -o eth1 -j MASQUERADE",
+		"-A POSTROUTING -s 10.0.0.0/8 -j SNAT --to-source 192.0.2.1",
+		"-A POSTROUTING -o eth+ -j MASQUERADE",
+		"-A POSTROUTING ! -o docker0 -j MASQUERADE",
+	}
+	if got := parseIPTablesConflicts(out, "eth1"); !slices.Equal(got, want) {
+		t.Errorf("parseIPTablesConflicts =\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,65 @@
+package bridge
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// mockDNSConfigurator is a test double for DNSConfigurator.
+type mockDNSConfigurator struct {
+	mu sync.Mutex
+
+	calls []mockCall
+
+	setErr   error
+	clearErr error
+}
+
+func (m *mockDNSConfigurator) SetSplitDNS(servers, domains []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, mockCall{Method: "SetSplitDNS", Args: []interface{}{servers, domains}})
+	return m.setErr
+}
+
+func (m *mockDNSConfigurator) ClearSplitDNS() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, mockCall{Method: "ClearSplitDNS"})
+	return m.clearErr
+}
+
+// callsFor returns all recorded calls for the given method name.
+func (m *mockDNSConfigurator) callsFor(method string) []mockCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []mockCall
+	for _, c := range m.calls {
+		if c.Method == method {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// mockReportWriter records the last payload written per key.
+type mockReportWriter struct {
+	mu      sync.Mutex
+	reports map[string]json.RawMessage
+}
+
+func (m *mockReportWriter) WriteReport(key string, payload json.RawMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.reports == nil {
+		m.reports = make(map[string]json.RawMessage)
+	}
+	m.reports[key] = payload
+	return nil
+}
+
+func (m *mockReportWriter) get(key string) json.RawMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reports[key]
+}
@@ -81,3 +81,20 @@ func (m *mockIngressController) resetIngress() {
 
 // Verify mockIngressController satisfies IngressController at compile time.
 var _ IngressController = (*mockIngressController)(nil)
+
+// mockPacketIngressController is a mockIngressController that also implements
+// PacketIngressController with real UDP sockets on the loopback interface.
+type mockPacketIngressController struct {
+	mockIngressController
+}
+
+func (m *mockPacketIngressController) ListenPacket(addr string) (net.PacketConn, error) {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockIngressCall{Method: "ListenPacket", Args: []interface{}{addr}})
+	err := m.listenErr
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return net.ListenPacket("udp", "127.0.0.1:0")
+}
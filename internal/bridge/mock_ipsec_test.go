@@ -0,0 +1,69 @@
+package bridge
+
+import "sync"
+
+// mockIPsecController is a test double for IPsecController and
+// IPsecStatsReader. It records calls like mockVPNController.
+type mockIPsecController struct {
+	mu    sync.Mutex
+	calls []mockVPNCall
+
+	createErr error
+	loadErr   error
+
+	stats map[string]TunnelPeerStats // keyed by connection name
+}
+
+func (m *mockIPsecController) record(method string, args ...interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, mockVPNCall{Method: method, Args: args})
+}
+
+func (m *mockIPsecController) CreateIPsecInterface(name string, ifID uint32) error {
+	m.record("CreateIPsecInterface", name, ifID)
+	return m.createErr
+}
+
+func (m *mockIPsecController) RemoveIPsecInterface(name string) error {
+	m.record("RemoveIPsecInterface", name)
+	return nil
+}
+
+func (m *mockIPsecController) LoadIPsecConn(conn IPsecConn) error {
+	m.record("LoadIPsecConn", conn)
+	return m.loadErr
+}
+
+func (m *mockIPsecController) UnloadIPsecConn(name string) error {
+	m.record("UnloadIPsecConn", name)
+	return nil
+}
+
+func (m *mockIPsecController) IPsecConnStats(name string) (TunnelPeerStats, error) {
+	m.record("IPsecConnStats", name)
+	return m.stats[name], nil
+}
+
+func (m *mockIPsecController) callsFor(method string) []mockVPNCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []mockVPNCall
+	for _, c := range m.calls {
+		if c.Method == method {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (m *mockIPsecController) reset() {
+	m.mu.Lock()
+	m.calls = nil
+	m.mu.Unlock()
+}
+
+var (
+	_ IPsecController  = (*mockIPsecController)(nil)
+	_ IPsecStatsReader = (*mockIPsecController)(nil)
+)
@@ -0,0 +1,63 @@
+package bridge
+
+import "sync"
+
+// mockL2Controller is a test double for L2Controller. It records calls like
+// mockVPNController and reports the MAC count set in macs.
+type mockL2Controller struct {
+	mu    sync.Mutex
+	calls []mockVPNCall
+
+	createErr error
+	macs      map[string]int // keyed by interface
+}
+
+func (m *mockL2Controller) record(method string, args ...interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, mockVPNCall{Method: method, Args: args})
+}
+
+func (m *mockL2Controller) CreateL2Interface(name string, ext L2Extension) error {
+	m.record("CreateL2Interface", name, ext)
+	return m.createErr
+}
+
+func (m *mockL2Controller) RemoveL2Interface(name string) error {
+	m.record("RemoveL2Interface", name)
+	return nil
+}
+
+func (m *mockL2Controller) L2MACCount(name string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.macs[name], nil
+}
+
+func (m *mockL2Controller) SetL2Learning(name string, on bool) error {
+	m.record("SetL2Learning", name, on)
+	return nil
+}
+
+func (m *mockL2Controller) setMACs(name string, n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.macs == nil {
+		m.macs = make(map[string]int)
+	}
+	m.macs[name] = n
+}
+
+func (m *mockL2Controller) callsFor(method string) []mockVPNCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []mockVPNCall
+	for _, c := range m.calls {
+		if c.Method == method {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+var _ L2Controller = (*mockL2Controller)(nil)
@@ -2,6 +2,8 @@ package bridge
 
 import (
 	"log/slog"
+	"net"
+	"net/netip"
 	"sync"
 )
 
@@ -88,6 +90,190 @@ func (m *mockRouteController) RemoveNATMasquerade(iface string) error {
 	return err
 }
 
+// mockFlushingRouteController is a mockRouteController that also implements
+// RouteTableFlusher.
+type mockFlushingRouteController struct {
+	mockRouteController
+	flushErr error
+}
+
+func (m *mockFlushingRouteController) FlushRouteTable() error {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockCall{Method: "FlushRouteTable"})
+	err := m.flushErr
+	m.mu.Unlock()
+	return err
+}
+
+// mockConntrackRouteController is a mockRouteController that also implements
+// ConntrackFlusher.
+type mockConntrackRouteController struct {
+	mockRouteController
+	flushConntrackErr error
+}
+
+func (m *mockConntrackRouteController) FlushConntrackSubnet(subnet string) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockCall{Method: "FlushConntrackSubnet", Args: []interface{}{subnet}})
+	err := m.flushConntrackErr
+	m.mu.Unlock()
+	return err
+}
+
+func (m *mockConntrackRouteController) FlushConntrackPort(protocol string, addr netip.Addr, port int) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockCall{Method: "FlushConntrackPort", Args: []interface{}{protocol, addr, port}})
+	err := m.flushConntrackErr
+	m.mu.Unlock()
+	return err
+}
+
+// mockShapingRouteController is a mockRouteController that also implements
+// TrafficShaper.
+type mockShapingRouteController struct {
+	mockRouteController
+	setEgressRateErr error
+}
+
+func (m *mockShapingRouteController) SetEgressRate(iface string, rateKbps int64) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockCall{Method: "SetEgressRate", Args: []interface{}{iface, rateKbps}})
+	err := m.setEgressRateErr
+	m.mu.Unlock()
+	return err
+}
+
+func (m *mockShapingRouteController) ClearEgressRate(iface string) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockCall{Method: "ClearEgressRate", Args: []interface{}{iface}})
+	m.mu.Unlock()
+	return nil
+}
+
+// mockClampingRouteController is a mockRouteController that also implements
+// MSSClamper.
+type mockClampingRouteController struct {
+	mockRouteController
+	setMSSClampErr error
+}
+
+func (m *mockClampingRouteController) SetMSSClamp(iface string, mss int) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockCall{Method: "SetMSSClamp", Args: []interface{}{iface, mss}})
+	err := m.setMSSClampErr
+	m.mu.Unlock()
+	return err
+}
+
+func (m *mockClampingRouteController) ClearMSSClamp(iface string) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockCall{Method: "ClearMSSClamp", Args: []interface{}{iface}})
+	m.mu.Unlock()
+	return nil
+}
+
+// mockVIPRouteController is a mockRouteController that also implements
+// VirtualIPController.
+type mockVIPRouteController struct {
+	mockRouteController
+	addVirtualIPErr error
+}
+
+func (m *mockVIPRouteController) AddVirtualIP(iface, cidr string) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockCall{Method: "AddVirtualIP", Args: []interface{}{iface, cidr}})
+	err := m.addVirtualIPErr
+	m.mu.Unlock()
+	return err
+}
+
+func (m *mockVIPRouteController) RemoveVirtualIP(iface, cidr string) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockCall{Method: "RemoveVirtualIP", Args: []interface{}{iface, cidr}})
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *mockVIPRouteController) AnnounceVirtualIP(iface, cidr string) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockCall{Method: "AnnounceVirtualIP", Args: []interface{}{iface, cidr}})
+	m.mu.Unlock()
+	return nil
+}
+
+// mockMappingRouteController is a mockRouteController that also implements
+// SubnetMapper and ConntrackFlusher.
+type mockMappingRouteController struct {
+	mockConntrackRouteController
+	addSubnetMapErr error
+}
+
+func (m *mockMappingRouteController) AddSubnetMap(iface, local, as string) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockCall{Method: "AddSubnetMap", Args: []interface{}{iface, local, as}})
+	err := m.addSubnetMapErr
+	m.mu.Unlock()
+	return err
+}
+
+func (m *mockMappingRouteController) RemoveSubnetMap(iface, local, as string) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockCall{Method: "RemoveSubnetMap", Args: []interface{}{iface, local, as}})
+	m.mu.Unlock()
+	return nil
+}
+
+// mockMasqRouteController is a mockRouteController that also implements
+// Masquerader.
+type mockMasqRouteController struct {
+	mockRouteController
+	addMasqueradeErrFor map[string]error
+	conflicts           []string
+}
+
+func (m *mockMasqRouteController) AddMasquerade(iface, subnet string) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockCall{Method: "AddMasquerade", Args: []interface{}{iface, subnet}})
+	err := errForKey(m.addMasqueradeErrFor, subnet, nil)
+	m.mu.Unlock()
+	return err
+}
+
+func (m *mockMasqRouteController) RemoveMasquerade(iface, subnet string) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockCall{Method: "RemoveMasquerade", Args: []interface{}{iface, subnet}})
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *mockMasqRouteController) MasqueradeConflicts(iface string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.conflicts, nil
+}
+
+// mockGatewayRouteController is a mockRouteController that also implements
+// GatewayRouter.
+type mockGatewayRouteController struct {
+	mockRouteController
+	addGatewayRouteErr error
+}
+
+func (m *mockGatewayRouteController) AddGatewayRoute(subnet, gateway, iface string) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockCall{Method: "AddGatewayRoute", Args: []interface{}{subnet, gateway, iface}})
+	err := m.addGatewayRouteErr
+	m.mu.Unlock()
+	return err
+}
+
+func (m *mockGatewayRouteController) RemoveGatewayRoute(subnet, gateway, iface string) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockCall{Method: "RemoveGatewayRoute", Args: []interface{}{subnet, gateway, iface}})
+	m.mu.Unlock()
+	return nil
+}
+
 // callsFor returns all recorded calls for the given method name.
 func (m *mockRouteController) callsFor(method string) []mockCall {
 	m.mu.Lock()
@@ -115,3 +301,60 @@ func (nopWriter) Write(p []byte) (int, error) { return len(p), nil }
 func discardLogger() *slog.Logger {
 	return slog.New(slog.NewTextHandler(nopWriter{}, nil))
 }
+
+// mockFastPath is a test double for FastPath that records calls.
+type mockFastPath struct {
+	mu    sync.Mutex
+	calls []mockCall
+
+	offloadErr error
+}
+
+func (m *mockFastPath) record(method string, args ...interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, mockCall{Method: method, Args: args})
+}
+
+func (m *mockFastPath) Name() string { return "mock" }
+
+func (m *mockFastPath) OffloadForwarding(meshIface, accessIface string) error {
+	m.record("OffloadForwarding", meshIface, accessIface)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.offloadErr
+}
+
+func (m *mockFastPath) RemoveForwardingOffload() error {
+	m.record("RemoveForwardingOffload")
+	return nil
+}
+
+func (m *mockFastPath) OffloadRelaySession(sessionID string, listenPort int, peerA, peerB *net.UDPAddr) error {
+	m.record("OffloadRelaySession", sessionID, listenPort, peerA.String(), peerB.String())
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.offloadErr
+}
+
+func (m *mockFastPath) RemoveRelaySession(sessionID string) error {
+	m.record("RemoveRelaySession", sessionID)
+	return nil
+}
+
+func (m *mockFastPath) Close() error {
+	m.record("Close")
+	return nil
+}
+
+func (m *mockFastPath) callsFor(method string) []mockCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []mockCall
+	for _, c := range m.calls {
+		if c.Method == method {
+			result = append(result, c)
+		}
+	}
+	return result
+}
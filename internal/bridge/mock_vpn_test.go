@@ -75,3 +75,23 @@ func (m *mockVPNController) resetVPN() {
 
 // Verify mockVPNController satisfies VPNController at compile time.
 var _ VPNController = (*mockVPNController)(nil)
+
+// mockStatsVPNController extends mockVPNController with TunnelStatsReader.
+type mockStatsVPNController struct {
+	mockVPNController
+
+	stats    map[string]TunnelPeerStats // keyed by publicKey
+	statsErr error
+}
+
+func (m *mockStatsVPNController) TunnelPeerStats(iface, publicKey string) (TunnelPeerStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, mockVPNCall{Method: "TunnelPeerStats", Args: []interface{}{iface, publicKey}})
+	if m.statsErr != nil {
+		return TunnelPeerStats{}, m.statsErr
+	}
+	return m.stats[publicKey], nil
+}
+
+var _ TunnelStatsReader = (*mockStatsVPNController)(nil)
@@ -0,0 +1,104 @@
+package bridge
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	// MSSClampOff leaves the MSS of forwarded TCP connections unchanged.
+	MSSClampOff = "off"
+
+	// MSSClampPMTU lowers the MSS of forwarded TCP connections to fit the
+	// MTU of the route they take. It is the default.
+	MSSClampPMTU = "pmtu"
+)
+
+// MinMSS and MaxMSS bound a fixed MSS clamp. 536 is the smallest MSS every
+// IPv4 host must accept; 65495 fits the largest IPv4 packet.
+const (
+	MinMSS = 536
+	MaxMSS = 65495
+)
+
+// MSSClamper is implemented by route controllers that can clamp the maximum
+// segment size of TCP connections forwarded through an interface. Clamping
+// makes both ends of a connection send segments that fit the tunnel, so
+// they do not depend on ICMP "fragmentation needed" messages, which some
+// networks drop (a PMTU blackhole). Managers install a clamp on the access,
+// user access, and site-to-site interfaces according to the MSSClamp
+// settings; without an MSSClamper the setting has no effect.
+type MSSClamper interface {
+	// SetMSSClamp lowers the MSS option of TCP SYN packets forwarded into
+	// or out of iface to mss, or with mss 0 to the MSS that fits the MTU
+	// of the route the packet takes. An MSS is never raised. An earlier
+	// clamp on iface is replaced.
+	SetMSSClamp(iface string, mss int) error
+
+	// ClearMSSClamp removes the clamp from iface.
+	// Idempotent: clearing an interface without a clamp returns nil.
+	ClearMSSClamp(iface string) error
+}
+
+// parseMSSClamp parses an MSS clamp setting: "off", "pmtu", or a fixed MSS.
+// It returns the MSS to pass to SetMSSClamp, 0 for "pmtu", and whether
+// clamping is on.
+func parseMSSClamp(s string) (mss int, on bool, err error) {
+	switch s {
+	case MSSClampOff:
+		return 0, false, nil
+	case MSSClampPMTU:
+		return 0, true, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, false, fmt.Errorf("must be %q, %q, or an MSS", MSSClampOff, MSSClampPMTU)
+	}
+	if n < MinMSS || n > MaxMSS {
+		return 0, false, fmt.Errorf("MSS must be between %d and %d", MinMSS, MaxMSS)
+	}
+	return n, true, nil
+}
+
+// mssClampFor returns the MSS clamp for iface: the entry in
+// MSSClampInterfaces if there is one, else MSSClamp. Settings are checked
+// by Validate; an invalid one turns clamping off.
+func (c *Config) mssClampFor(iface string) (mss int, on bool) {
+	s, ok := c.MSSClampInterfaces[iface]
+	if !ok {
+		s = c.MSSClamp
+	}
+	mss, on, err := parseMSSClamp(s)
+	if err != nil {
+		return 0, false
+	}
+	return mss, on
+}
+
+// applyMSSClamp installs the MSS clamp configured for iface through routes.
+// It is a no-op when clamping is off for iface or routes is not an
+// MSSClamper. Callers log a failure rather than fail: the interface keeps
+// working, relying on path MTU discovery.
+func applyMSSClamp(routes RouteController, cfg *Config, iface string) error {
+	mss, on := cfg.mssClampFor(iface)
+	if !on {
+		return nil
+	}
+	clamper, ok := routes.(MSSClamper)
+	if !ok {
+		return nil
+	}
+	return clamper.SetMSSClamp(iface, mss)
+}
+
+// clearMSSClamp removes the MSS clamp installed by applyMSSClamp on iface.
+func clearMSSClamp(routes RouteController, cfg *Config, iface string) error {
+	if _, on := cfg.mssClampFor(iface); !on {
+		return nil
+	}
+	clamper, ok := routes.(MSSClamper)
+	if !ok {
+		return nil
+	}
+	return clamper.ClearMSSClamp(iface)
+}
@@ -0,0 +1,157 @@
+//go:build linux
+
+package bridge
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+)
+
+// Names of the nftables objects owned by MSS clamps. The inet table covers
+// IPv4 and IPv6 traffic.
+const (
+	mssTableName = "plexd-mss"
+	mssChainName = "forward"
+)
+
+// tcpOptMaxSeg is the kind of the TCP MSS option.
+const tcpOptMaxSeg = 2
+
+// SetMSSClamp installs rules that clamp the MSS of TCP SYN packets forwarded
+// into or out of iface. With mss 0 the MSS is lowered to fit the MTU of the
+// route the packet takes; otherwise it is lowered to mss. The kernel never
+// raises an MSS. Existing rules for iface are replaced.
+// nft equivalent:
+//
+//	chain forward { type filter hook forward priority mangle; iifname "eth1" tcp flags & (syn | rst) == syn tcp option maxseg size set rt mtu }
+//	chain forward { type filter hook forward priority mangle; oifname "eth1" tcp flags & (syn | rst) == syn tcp option maxseg size set rt mtu }
+func (c *NetlinkRouteController) SetMSSClamp(iface string, mss int) error {
+	if err := validateIfaceName(iface); err != nil {
+		return fmt.Errorf("bridge: set MSS clamp: %w", err)
+	}
+	if mss != 0 && (mss < MinMSS || mss > MaxMSS) {
+		return fmt.Errorf("bridge: set MSS clamp on %q: MSS must be between %d and %d, got %d", iface, MinMSS, MaxMSS, mss)
+	}
+
+	conn, err := nftables.New()
+	if err != nil {
+		return fmt.Errorf("bridge: set MSS clamp: %w", err)
+	}
+	table := conn.AddTable(&nftables.Table{
+		Family: nftables.TableFamilyINet,
+		Name:   mssTableName,
+	})
+	chain := conn.AddChain(&nftables.Chain{
+		Name:     mssChainName,
+		Table:    table,
+		Type:     nftables.ChainTypeFilter,
+		Hooknum:  nftables.ChainHookForward,
+		Priority: nftables.ChainPriorityMangle,
+	})
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("bridge: set MSS clamp on %q: create table: %w", iface, err)
+	}
+
+	userData := mssClampUserData(iface)
+	if err := delMSSClampRules(conn, table, userData); err != nil {
+		return fmt.Errorf("bridge: set MSS clamp on %q: %w", iface, err)
+	}
+	for _, key := range []expr.MetaKey{expr.MetaKeyIIFNAME, expr.MetaKeyOIFNAME} {
+		conn.AddRule(&nftables.Rule{
+			Table:    table,
+			Chain:    chain,
+			Exprs:    mssClampExprs(key, iface, mss),
+			UserData: userData,
+		})
+	}
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("bridge: set MSS clamp on %q: %w", iface, err)
+	}
+
+	c.logger.Debug("MSS clamp set",
+		"component", "bridge",
+		"interface", iface,
+		"mss", mss,
+	)
+	return nil
+}
+
+// ClearMSSClamp deletes the rules installed by SetMSSClamp for iface.
+// Idempotent: clearing an interface without a clamp returns nil.
+func (c *NetlinkRouteController) ClearMSSClamp(iface string) error {
+	conn, err := nftables.New()
+	if err != nil {
+		return fmt.Errorf("bridge: clear MSS clamp: %w", err)
+	}
+	if _, err := conn.ListTableOfFamily(mssTableName, nftables.TableFamilyINet); err != nil {
+		// Table does not exist — idempotent success.
+		return nil
+	}
+	table := &nftables.Table{Family: nftables.TableFamilyINet, Name: mssTableName}
+	if err := delMSSClampRules(conn, table, mssClampUserData(iface)); err != nil {
+		return fmt.Errorf("bridge: clear MSS clamp on %q: %w", iface, err)
+	}
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("bridge: clear MSS clamp on %q: %w", iface, err)
+	}
+
+	c.logger.Debug("MSS clamp cleared",
+		"component", "bridge",
+		"interface", iface,
+	)
+	return nil
+}
+
+// delMSSClampRules adds the deletion of the rules tagged with userData to
+// the batch.
+func delMSSClampRules(conn *nftables.Conn, table *nftables.Table, userData []byte) error {
+	rules, err := conn.GetRules(table, &nftables.Chain{Name: mssChainName, Table: table})
+	if err != nil {
+		return fmt.Errorf("list rules: %w", err)
+	}
+	for _, r := range rules {
+		if string(r.UserData) == string(userData) {
+			if err := conn.DelRule(r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mssClampUserData tags the rules of an MSS clamp.
+func mssClampUserData(iface string) []byte {
+	return []byte("mss:" + iface)
+}
+
+// mssClampExprs builds a rule that matches TCP SYN packets on iface (by
+// ifaceKey) and writes mss into their MSS option, or with mss 0 the MSS
+// that fits the route MTU.
+func mssClampExprs(ifaceKey expr.MetaKey, iface string, mss int) []expr.Any {
+	exprs := []expr.Any{
+		&expr.Meta{Key: ifaceKey, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: ifaceNameBytes(iface)},
+		&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_TCP}},
+		// tcp flags & (syn | rst) == syn
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 13, Len: 1},
+		&expr.Bitwise{SourceRegister: 1, DestRegister: 1, Len: 1, Mask: []byte{0x06}, Xor: []byte{0x00}},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{0x02}},
+	}
+	if mss == 0 {
+		exprs = append(exprs,
+			&expr.Rt{Register: 1, Key: expr.RtTCPMSS},
+			&expr.Byteorder{SourceRegister: 1, DestRegister: 1, Op: expr.ByteorderHton, Len: 2, Size: 2},
+		)
+	} else {
+		exprs = append(exprs, &expr.Immediate{Register: 1, Data: binary.BigEndian.AppendUint16(nil, uint16(mss))})
+	}
+	return append(exprs,
+		&expr.Counter{},
+		&expr.Exthdr{SourceRegister: 1, Type: tcpOptMaxSeg, Offset: 2, Len: 2, Op: expr.ExthdrOpTcpopt},
+	)
+}
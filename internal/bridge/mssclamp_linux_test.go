@@ -0,0 +1,79 @@
+//go:build linux
+
+package bridge
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/nftables/expr"
+)
+
+// Compile-time check that NetlinkRouteController implements MSSClamper.
+var _ MSSClamper = (*NetlinkRouteController)(nil)
+
+func TestMSSClampExprs(t *testing.T) {
+	tests := []struct {
+		name string
+		mss  int
+		want func(t *testing.T, exprs []expr.Any)
+	}{
+		{
+			name: "route MTU",
+			mss:  0,
+			want: func(t *testing.T, exprs []expr.Any) {
+				var rt *expr.Rt
+				for _, e := range exprs {
+					if e, ok := e.(*expr.Rt); ok {
+						rt = e
+					}
+				}
+				if rt == nil || rt.Key != expr.RtTCPMSS {
+					t.Errorf("no rt tcpmss load in %#v", exprs)
+				}
+			},
+		},
+		{
+			name: "fixed",
+			mss:  1360,
+			want: func(t *testing.T, exprs []expr.Any) {
+				var imm *expr.Immediate
+				for _, e := range exprs {
+					if e, ok := e.(*expr.Immediate); ok {
+						imm = e
+					}
+				}
+				if imm == nil || !bytes.Equal(imm.Data, []byte{0x05, 0x50}) {
+					t.Errorf("immediate = %#v, want 1360 in network byte order", imm)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exprs := mssClampExprs(expr.MetaKeyOIFNAME, "wg-s2s-a", tt.mss)
+			meta, ok := exprs[0].(*expr.Meta)
+			if !ok || meta.Key != expr.MetaKeyOIFNAME {
+				t.Fatalf("first expression = %#v, want oifname", exprs[0])
+			}
+			if cmp, ok := exprs[1].(*expr.Cmp); !ok || !bytes.Equal(cmp.Data, []byte("wg-s2s-a\x00")) {
+				t.Fatalf("second expression = %#v, want interface match", exprs[1])
+			}
+			ext, ok := exprs[len(exprs)-1].(*expr.Exthdr)
+			if !ok || ext.Op != expr.ExthdrOpTcpopt || ext.Type != tcpOptMaxSeg || ext.SourceRegister != 1 || ext.Len != 2 {
+				t.Fatalf("last expression = %#v, want MSS option write from register 1", exprs[len(exprs)-1])
+			}
+			tt.want(t, exprs)
+		})
+	}
+}
+
+func TestSetMSSClamp_Invalid(t *testing.T) {
+	ctrl := NewNetlinkRouteController(discardLoggerRoute())
+	if err := ctrl.SetMSSClamp("eth1", 100); err == nil {
+		t.Error("SetMSSClamp with MSS 100: expected error")
+	}
+	if err := ctrl.SetMSSClamp("", 0); err == nil {
+		t.Error("SetMSSClamp with empty interface: expected error")
+	}
+}
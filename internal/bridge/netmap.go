@@ -0,0 +1,47 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// errNoSubnetMapper is returned for tunnels with a NAT map when the route
+// controller cannot translate subnets.
+var errNoSubnetMapper = errors.New("route controller does not support NAT maps")
+
+// parseNATMap parses and checks a site-to-site NAT map: both subnets must be
+// IPv4 CIDRs without host bits, of the same size, and must not overlap.
+func parseNATMap(nm api.SiteToSiteNATMap) (local, as netip.Prefix, err error) {
+	local, err = parseNATMapSubnet("local", nm.Local)
+	if err != nil {
+		return netip.Prefix{}, netip.Prefix{}, err
+	}
+	as, err = parseNATMapSubnet("as", nm.As)
+	if err != nil {
+		return netip.Prefix{}, netip.Prefix{}, err
+	}
+	if local.Bits() != as.Bits() {
+		return netip.Prefix{}, netip.Prefix{}, fmt.Errorf("local %s and as %s differ in size", local, as)
+	}
+	if local.Overlaps(as) {
+		return netip.Prefix{}, netip.Prefix{}, fmt.Errorf("local %s and as %s overlap", local, as)
+	}
+	return local, as, nil
+}
+
+func parseNATMapSubnet(field, s string) (netip.Prefix, error) {
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%s: %w", field, err)
+	}
+	if !p.Addr().Is4() {
+		return netip.Prefix{}, fmt.Errorf("%s %s: only IPv4 subnets are supported", field, s)
+	}
+	if p != p.Masked() {
+		return netip.Prefix{}, fmt.Errorf("%s %s: host bits are set", field, s)
+	}
+	return p, nil
+}
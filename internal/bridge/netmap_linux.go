@@ -0,0 +1,188 @@
+//go:build linux
+
+package bridge
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// Names of the nftables objects owned by site-to-site NAT maps. They live in
+// their own table because RemoveNATMasquerade deletes plexd-nat as a whole.
+const (
+	netmapTableName     = "plexd-netmap"
+	netmapPreChainName  = "prerouting"
+	netmapPostChainName = "postrouting"
+)
+
+// AddSubnetMap installs a prerouting rule that maps destinations in as to
+// local for traffic received on iface, and a postrouting rule that maps
+// sources in local to as for traffic sent on iface. Both keep the host bits.
+// Existing rules for the same mapping are replaced.
+// nft equivalent:
+//
+//	chain prerouting { type nat hook prerouting priority dstnat; iifname "wg-s2s-a" ip daddr 10.200.0.0/24 dnat ip to ip daddr & 0.0.0.255 | 10.0.0.0 }
+//	chain postrouting { type nat hook postrouting priority srcnat; oifname "wg-s2s-a" ip saddr 10.0.0.0/24 snat ip to ip saddr & 0.0.0.255 | 10.200.0.0 }
+func (c *NetlinkRouteController) AddSubnetMap(iface, local, as string) error {
+	if err := validateIfaceName(iface); err != nil {
+		return fmt.Errorf("bridge: add subnet map: %w", err)
+	}
+	localNet, asNet, err := parseNATMap(api.SiteToSiteNATMap{Local: local, As: as})
+	if err != nil {
+		return fmt.Errorf("bridge: add subnet map on %q: %w", iface, err)
+	}
+
+	conn, err := nftables.New()
+	if err != nil {
+		return fmt.Errorf("bridge: add subnet map: %w", err)
+	}
+	table := netmapTable(conn)
+	pre, post := netmapChains(conn, table)
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("bridge: add subnet map on %q: create table: %w", iface, err)
+	}
+
+	userData := subnetMapUserData(iface, local, as)
+	if err := delSubnetMapRules(conn, table, userData); err != nil {
+		return fmt.Errorf("bridge: add subnet map on %q: %w", iface, err)
+	}
+	conn.AddRule(&nftables.Rule{
+		Table:    table,
+		Chain:    pre,
+		Exprs:    subnetMapExprs(expr.MetaKeyIIFNAME, iface, 16, asNet, localNet, expr.NATTypeDestNAT),
+		UserData: userData,
+	})
+	conn.AddRule(&nftables.Rule{
+		Table:    table,
+		Chain:    post,
+		Exprs:    subnetMapExprs(expr.MetaKeyOIFNAME, iface, 12, localNet, asNet, expr.NATTypeSourceNAT),
+		UserData: userData,
+	})
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("bridge: add subnet map on %q: %w", iface, err)
+	}
+
+	c.logger.Debug("subnet map added",
+		"component", "bridge",
+		"interface", iface,
+		"local", local,
+		"as", as,
+	)
+	return nil
+}
+
+// RemoveSubnetMap deletes the rules installed by AddSubnetMap.
+// Idempotent: removing a mapping that is not installed returns nil.
+func (c *NetlinkRouteController) RemoveSubnetMap(iface, local, as string) error {
+	conn, err := nftables.New()
+	if err != nil {
+		return fmt.Errorf("bridge: remove subnet map: %w", err)
+	}
+	if _, err := conn.ListTableOfFamily(netmapTableName, nftables.TableFamilyIPv4); err != nil {
+		// Table does not exist — idempotent success.
+		return nil
+	}
+	table := &nftables.Table{Family: nftables.TableFamilyIPv4, Name: netmapTableName}
+	if err := delSubnetMapRules(conn, table, subnetMapUserData(iface, local, as)); err != nil {
+		return fmt.Errorf("bridge: remove subnet map on %q: %w", iface, err)
+	}
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("bridge: remove subnet map on %q: %w", iface, err)
+	}
+
+	c.logger.Debug("subnet map removed",
+		"component", "bridge",
+		"interface", iface,
+		"local", local,
+		"as", as,
+	)
+	return nil
+}
+
+// netmapTable adds the NAT map table to the batch.
+func netmapTable(conn *nftables.Conn) *nftables.Table {
+	return conn.AddTable(&nftables.Table{
+		Family: nftables.TableFamilyIPv4,
+		Name:   netmapTableName,
+	})
+}
+
+// netmapChains adds the NAT map chains to the batch.
+func netmapChains(conn *nftables.Conn, table *nftables.Table) (pre, post *nftables.Chain) {
+	pre = conn.AddChain(&nftables.Chain{
+		Name:     netmapPreChainName,
+		Table:    table,
+		Type:     nftables.ChainTypeNAT,
+		Hooknum:  nftables.ChainHookPrerouting,
+		Priority: nftables.ChainPriorityNATDest,
+	})
+	post = conn.AddChain(&nftables.Chain{
+		Name:     netmapPostChainName,
+		Table:    table,
+		Type:     nftables.ChainTypeNAT,
+		Hooknum:  nftables.ChainHookPostrouting,
+		Priority: nftables.ChainPriorityNATSource,
+	})
+	return pre, post
+}
+
+// delSubnetMapRules adds the deletion of the rules tagged with userData to
+// the batch.
+func delSubnetMapRules(conn *nftables.Conn, table *nftables.Table, userData []byte) error {
+	for _, name := range []string{netmapPreChainName, netmapPostChainName} {
+		rules, err := conn.GetRules(table, &nftables.Chain{Name: name, Table: table})
+		if err != nil {
+			return fmt.Errorf("list rules: %w", err)
+		}
+		for _, r := range rules {
+			if string(r.UserData) == string(userData) {
+				if err := conn.DelRule(r); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// subnetMapUserData tags the rules of a subnet map.
+func subnetMapUserData(iface, local, as string) []byte {
+	return []byte("netmap:" + iface + ":" + local + ":" + as)
+}
+
+// subnetMapExprs builds a rule that matches packets on iface (by ifaceKey)
+// whose IPv4 address at offset, 12 for the source or 16 for the destination,
+// lies in from, and translates it into to with natType, keeping the host bits.
+func subnetMapExprs(ifaceKey expr.MetaKey, iface string, offset uint32, from, to netip.Prefix, natType expr.NATType) []expr.Any {
+	var mask [4]byte
+	for i := range from.Bits() {
+		mask[i/8] |= 0x80 >> (i % 8)
+	}
+	hostMask := mask
+	for i := range hostMask {
+		hostMask[i] = ^hostMask[i]
+	}
+	fromNet, toNet := from.Addr().As4(), to.Addr().As4()
+
+	return []expr.Any{
+		&expr.Meta{Key: ifaceKey, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: ifaceNameBytes(iface)},
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: offset, Len: 4},
+		&expr.Bitwise{SourceRegister: 1, DestRegister: 1, Len: 4, Mask: mask[:], Xor: make([]byte, 4)},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: fromNet[:]},
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: offset, Len: 4},
+		&expr.Bitwise{SourceRegister: 1, DestRegister: 1, Len: 4, Mask: hostMask[:], Xor: toNet[:]},
+		&expr.Counter{},
+		&expr.NAT{
+			Type:       natType,
+			Family:     unix.NFPROTO_IPV4,
+			RegAddrMin: 1,
+		},
+	}
+}
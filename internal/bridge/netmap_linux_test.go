@@ -0,0 +1,64 @@
+//go:build linux
+
+package bridge
+
+import (
+	"bytes"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/google/nftables/expr"
+)
+
+// Compile-time check that NetlinkRouteController implements SubnetMapper.
+var _ SubnetMapper = (*NetlinkRouteController)(nil)
+
+func TestSubnetMapExprs(t *testing.T) {
+	local := netip.MustParsePrefix("10.0.0.0/20")
+	as := netip.MustParsePrefix("10.200.16.0/20")
+
+	exprs := subnetMapExprs(expr.MetaKeyOIFNAME, "wg-s2s-a", 12, local, as, expr.NATTypeSourceNAT)
+	nat, ok := exprs[len(exprs)-1].(*expr.NAT)
+	if !ok || nat.Type != expr.NATTypeSourceNAT || nat.RegAddrMin != 1 {
+		t.Fatalf("last expression = %#v, want SNAT from register 1", exprs[len(exprs)-1])
+	}
+
+	var bitwise []*expr.Bitwise
+	var cmps [][]byte
+	for _, e := range exprs {
+		switch e := e.(type) {
+		case *expr.Bitwise:
+			bitwise = append(bitwise, e)
+		case *expr.Cmp:
+			cmps = append(cmps, e.Data)
+		case *expr.Payload:
+			if e.Offset != 12 {
+				t.Errorf("payload offset = %d, want 12 (source address)", e.Offset)
+			}
+		}
+	}
+	if len(cmps) != 2 || !bytes.Equal(cmps[0], []byte("wg-s2s-a\x00")) || !bytes.Equal(cmps[1], []byte{10, 0, 0, 0}) {
+		t.Errorf("matches = %v, want interface and local network", cmps)
+	}
+	if len(bitwise) != 2 {
+		t.Fatalf("bitwise expressions = %d, want 2", len(bitwise))
+	}
+	if want := []byte{255, 255, 240, 0}; !bytes.Equal(bitwise[0].Mask, want) {
+		t.Errorf("network mask = %v, want %v", bitwise[0].Mask, want)
+	}
+	if want := []byte{0, 0, 15, 255}; !bytes.Equal(bitwise[1].Mask, want) {
+		t.Errorf("host mask = %v, want %v", bitwise[1].Mask, want)
+	}
+	if want := []byte{10, 200, 16, 0}; !bytes.Equal(bitwise[1].Xor, want) {
+		t.Errorf("mapped network = %v, want %v", bitwise[1].Xor, want)
+	}
+}
+
+func TestAddSubnetMap_Invalid(t *testing.T) {
+	ctrl := NewNetlinkRouteController(discardLoggerRoute())
+	err := ctrl.AddSubnetMap("wg-s2s-a", "10.0.0.0/24", "10.200.0.0/16")
+	if err == nil || !strings.Contains(err.Error(), "differ in size") {
+		t.Errorf("AddSubnetMap = %v, want size error", err)
+	}
+}
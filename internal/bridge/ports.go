@@ -0,0 +1,28 @@
+package bridge
+
+import "github.com/plexsphere/plexd/internal/validation"
+
+// PortSource reports UDP ports in use that site-to-site tunnels and udp
+// ingress rules must not take. *SiteToSiteManager and *IngressManager
+// implement it, so each can be given the other.
+type PortSource interface {
+	ReservedPorts() []validation.ReservedPort
+}
+
+// PortSourceFunc adapts a function to a PortSource, e.g. for the listen port
+// of the mesh interface.
+type PortSourceFunc func() []validation.ReservedPort
+
+// ReservedPorts calls f.
+func (f PortSourceFunc) ReservedPorts() []validation.ReservedPort { return f() }
+
+// reservedPorts returns the ports of the bridge listeners in cfg and of srcs.
+// It must not be called with a manager lock held, since the sources take
+// their own.
+func reservedPorts(cfg *Config, srcs []PortSource) []validation.ReservedPort {
+	ports := cfg.ReservedPorts()
+	for _, src := range srcs {
+		ports = append(ports, src.ReservedPorts()...)
+	}
+	return ports
+}
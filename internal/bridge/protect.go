@@ -0,0 +1,100 @@
+package bridge
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/validation"
+)
+
+// resolveTimeout bounds the lookup of the control plane host.
+const resolveTimeout = 2 * time.Second
+
+// ProtectedSource reports addresses that routes from the desired state must
+// not capture, such as the control plane.
+type ProtectedSource interface {
+	ProtectedAddrs() []validation.ProtectedAddr
+}
+
+// ProtectedSourceFunc adapts a function to a ProtectedSource.
+type ProtectedSourceFunc func() []validation.ProtectedAddr
+
+// ProtectedAddrs calls f.
+func (f ProtectedSourceFunc) ProtectedAddrs() []validation.ProtectedAddr { return f() }
+
+// ControlPlaneAddrs returns a ProtectedSource with the addresses of the
+// control plane at baseURL: the host if it is an IP address, else the
+// addresses it resolves to. If a lookup fails, the addresses of the last
+// successful one are reported.
+func ControlPlaneAddrs(baseURL string) ProtectedSource {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Hostname() == "" {
+		return ProtectedSourceFunc(func() []validation.ProtectedAddr { return nil })
+	}
+	return &controlPlaneAddrs{host: u.Hostname(), lookup: net.DefaultResolver.LookupNetIP}
+}
+
+type controlPlaneAddrs struct {
+	host   string
+	lookup func(ctx context.Context, network, host string) ([]netip.Addr, error)
+
+	mu   sync.Mutex
+	last []validation.ProtectedAddr
+}
+
+func (c *controlPlaneAddrs) ProtectedAddrs() []validation.ProtectedAddr {
+	if addr, err := netip.ParseAddr(c.host); err == nil {
+		return []validation.ProtectedAddr{{Addr: addr.Unmap(), Owner: "control plane"}}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	addrs, err := c.lookup(ctx, "ip", c.host)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		c.last = c.last[:0]
+		for _, a := range addrs {
+			c.last = append(c.last, validation.ProtectedAddr{Addr: a.Unmap(), Owner: "control plane"})
+		}
+	}
+	return append([]validation.ProtectedAddr(nil), c.last...)
+}
+
+// protectedAddrs returns the addresses of srcs.
+func protectedAddrs(srcs []ProtectedSource) []validation.ProtectedAddr {
+	var addrs []validation.ProtectedAddr
+	for _, src := range srcs {
+		addrs = append(addrs, src.ProtectedAddrs()...)
+	}
+	return addrs
+}
+
+// checkAccessRoutes checks the routes of access subnets via the access
+// interface against the protected addresses of srcs (see
+// validation.AccessSubnetRoutes). Nothing is refused with AllowFullTunnel.
+// It must not be called with a manager lock held, since the sources may
+// take their own.
+func checkAccessRoutes(cfg *Config, srcs []ProtectedSource, subnets []string) validation.Errors {
+	if cfg.AllowFullTunnel {
+		return nil
+	}
+	return validation.AccessSubnetRoutes(subnets, cfg.AccessInterface, protectedAddrs(srcs))
+}
+
+// checkTunnelRoutes checks the routes of the remote subnets of tunnels
+// against the protected addresses of srcs (see validation.SiteToSiteRoutes).
+// Nothing is refused with AllowFullTunnel. It must not be called with a
+// manager lock held.
+func checkTunnelRoutes(cfg *Config, srcs []ProtectedSource, tunnels ...api.SiteToSiteTunnel) validation.Errors {
+	if cfg.AllowFullTunnel {
+		return nil
+	}
+	return validation.SiteToSiteRoutes(tunnels, protectedAddrs(srcs))
+}
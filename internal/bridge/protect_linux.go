@@ -0,0 +1,49 @@
+//go:build linux
+
+package bridge
+
+import (
+	"net/netip"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/plexsphere/plexd/internal/validation"
+)
+
+// DefaultGateways returns a ProtectedSource with the gateways of the default
+// routes in the main table and the interfaces they are reached over. If the
+// routes cannot be read, it reports none.
+func DefaultGateways() ProtectedSource {
+	return ProtectedSourceFunc(func() []validation.ProtectedAddr {
+		routes, err := netlink.RouteList(nil, netlink.FAMILY_ALL)
+		if err != nil {
+			return nil
+		}
+		var addrs []validation.ProtectedAddr
+		for _, r := range routes {
+			if r.Gw == nil || (r.Dst != nil && !isDefaultDst(r.Dst.Mask)) {
+				continue
+			}
+			gw, ok := netip.AddrFromSlice(r.Gw)
+			if !ok {
+				continue
+			}
+			p := validation.ProtectedAddr{Addr: gw.Unmap(), Owner: "default gateway"}
+			if link, err := netlink.LinkByIndex(r.LinkIndex); err == nil {
+				p.Iface = link.Attrs().Name
+			}
+			addrs = append(addrs, p)
+		}
+		return addrs
+	})
+}
+
+// isDefaultDst reports whether a route destination mask is /0.
+func isDefaultDst(mask []byte) bool {
+	for _, b := range mask {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
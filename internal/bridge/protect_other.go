@@ -0,0 +1,11 @@
+//go:build !linux
+
+package bridge
+
+import "github.com/plexsphere/plexd/internal/validation"
+
+// DefaultGateways returns a ProtectedSource that reports no addresses; the
+// default routes are only read on linux.
+func DefaultGateways() ProtectedSource {
+	return ProtectedSourceFunc(func() []validation.ProtectedAddr { return nil })
+}
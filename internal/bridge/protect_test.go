@@ -0,0 +1,45 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestControlPlaneAddrs_IP(t *testing.T) {
+	addrs := ControlPlaneAddrs("https://198.51.100.10:8443/api").ProtectedAddrs()
+
+	if len(addrs) != 1 || addrs[0].Addr != netip.MustParseAddr("198.51.100.10") || addrs[0].Owner != "control plane" {
+		t.Errorf("ProtectedAddrs = %+v", addrs)
+	}
+}
+
+func TestControlPlaneAddrs_KeepsLastLookup(t *testing.T) {
+	var fail bool
+	src := &controlPlaneAddrs{
+		host: "cp.example.com",
+		lookup: func(_ context.Context, _, host string) ([]netip.Addr, error) {
+			if fail {
+				return nil, errors.New("no such host")
+			}
+			return []netip.Addr{netip.MustParseAddr("::ffff:203.0.113.7"), netip.MustParseAddr("2001:db8::7")}, nil
+		},
+	}
+
+	addrs := src.ProtectedAddrs()
+	if len(addrs) != 2 || addrs[0].Addr != netip.MustParseAddr("203.0.113.7") {
+		t.Fatalf("ProtectedAddrs = %+v", addrs)
+	}
+
+	fail = true
+	if again := src.ProtectedAddrs(); len(again) != 2 {
+		t.Errorf("ProtectedAddrs after failed lookup = %+v, want the last result", again)
+	}
+}
+
+func TestControlPlaneAddrs_InvalidURL(t *testing.T) {
+	if addrs := ControlPlaneAddrs("://").ProtectedAddrs(); addrs != nil {
+		t.Errorf("ProtectedAddrs = %+v, want none", addrs)
+	}
+}
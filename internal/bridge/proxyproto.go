@@ -0,0 +1,132 @@
+package bridge
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"time"
+)
+
+// PROXY protocol v2 constants, see
+// https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt.
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyHeaderTimeout bounds how long an ingress connection may take to send
+// its PROXY protocol header.
+const proxyHeaderTimeout = 5 * time.Second
+
+const (
+	proxyV2HeaderLen = 16
+
+	proxyV2CmdLocal = 0x20 // version 2, LOCAL
+	proxyV2CmdProxy = 0x21 // version 2, PROXY
+
+	proxyV2FamTCP4 = 0x11
+	proxyV2FamTCP6 = 0x21
+
+	proxyV2AddrLenTCP4 = 12
+	proxyV2AddrLenTCP6 = 36
+)
+
+// errProxyHeader is wrapped by all PROXY protocol header parse errors.
+var errProxyHeader = errors.New("invalid PROXY protocol v2 header")
+
+// writeProxyHeaderV2 writes a PROXY protocol v2 header for a TCP connection
+// from src to dst. Addresses that are not TCP addresses produce a LOCAL
+// header, which tells the receiver to use the connection's own addresses.
+func writeProxyHeaderV2(w io.Writer, src, dst net.Addr) error {
+	_, err := w.Write(proxyHeaderV2(src, dst))
+	return err
+}
+
+// proxyHeaderV2 encodes the header written by writeProxyHeaderV2.
+func proxyHeaderV2(src, dst net.Addr) []byte {
+	hdr := make([]byte, proxyV2HeaderLen, proxyV2HeaderLen+proxyV2AddrLenTCP6)
+	copy(hdr, proxyV2Signature)
+
+	srcAP, srcOK := tcpAddrPort(src)
+	dstAP, dstOK := tcpAddrPort(dst)
+	if !srcOK || !dstOK {
+		hdr[12] = proxyV2CmdLocal
+		return hdr
+	}
+
+	hdr[12] = proxyV2CmdProxy
+	if srcAP.Addr().Is4() && dstAP.Addr().Is4() {
+		hdr[13] = proxyV2FamTCP4
+		binary.BigEndian.PutUint16(hdr[14:], proxyV2AddrLenTCP4)
+		s, d := srcAP.Addr().As4(), dstAP.Addr().As4()
+		hdr = append(hdr, s[:]...)
+		hdr = append(hdr, d[:]...)
+	} else {
+		// Mixed families are sent as IPv4-mapped IPv6 addresses.
+		hdr[13] = proxyV2FamTCP6
+		binary.BigEndian.PutUint16(hdr[14:], proxyV2AddrLenTCP6)
+		s, d := srcAP.Addr().As16(), dstAP.Addr().As16()
+		hdr = append(hdr, s[:]...)
+		hdr = append(hdr, d[:]...)
+	}
+	hdr = binary.BigEndian.AppendUint16(hdr, srcAP.Port())
+	hdr = binary.BigEndian.AppendUint16(hdr, dstAP.Port())
+	return hdr
+}
+
+// tcpAddrPort returns the address and port of a *net.TCPAddr.
+func tcpAddrPort(a net.Addr) (netip.AddrPort, bool) {
+	tcp, ok := a.(*net.TCPAddr)
+	if !ok || tcp == nil {
+		return netip.AddrPort{}, false
+	}
+	ap := tcp.AddrPort()
+	return netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port()), ap.IsValid()
+}
+
+// readProxyHeaderV2 reads a PROXY protocol v2 header from r and returns the
+// source and destination addresses it carries. It reads exactly the header,
+// so the connection's payload is left unread. A LOCAL header, or a header
+// for a family other than TCP over IPv4 or IPv6, yields nil addresses.
+func readProxyHeaderV2(r io.Reader) (src, dst net.Addr, err error) {
+	hdr := make([]byte, proxyV2HeaderLen)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", errProxyHeader, err)
+	}
+	if !bytes.Equal(hdr[:12], proxyV2Signature) {
+		return nil, nil, fmt.Errorf("%w: bad signature", errProxyHeader)
+	}
+	if hdr[12]>>4 != 2 {
+		return nil, nil, fmt.Errorf("%w: unsupported version %d", errProxyHeader, hdr[12]>>4)
+	}
+	cmd := hdr[12]
+	if cmd != proxyV2CmdLocal && cmd != proxyV2CmdProxy {
+		return nil, nil, fmt.Errorf("%w: unsupported command %#x", errProxyHeader, cmd&0x0f)
+	}
+
+	body := make([]byte, binary.BigEndian.Uint16(hdr[14:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", errProxyHeader, err)
+	}
+	if cmd == proxyV2CmdLocal {
+		return nil, nil, nil
+	}
+
+	switch hdr[13] {
+	case proxyV2FamTCP4:
+		if len(body) < proxyV2AddrLenTCP4 {
+			return nil, nil, fmt.Errorf("%w: short IPv4 address block", errProxyHeader)
+		}
+		src = &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:]))}
+		dst = &net.TCPAddr{IP: net.IP(body[4:8]), Port: int(binary.BigEndian.Uint16(body[10:]))}
+	case proxyV2FamTCP6:
+		if len(body) < proxyV2AddrLenTCP6 {
+			return nil, nil, fmt.Errorf("%w: short IPv6 address block", errProxyHeader)
+		}
+		src = &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:]))}
+		dst = &net.TCPAddr{IP: net.IP(body[16:32]), Port: int(binary.BigEndian.Uint16(body[34:]))}
+	}
+	// Any TLVs after the address block have been consumed with body.
+	return src, dst, nil
+}
@@ -0,0 +1,136 @@
+package bridge
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestProxyHeaderV2_RoundTripIPv4(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 51000}
+	dst := &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 443}
+
+	var buf bytes.Buffer
+	if err := writeProxyHeaderV2(&buf, src, dst); err != nil {
+		t.Fatalf("writeProxyHeaderV2: %v", err)
+	}
+	if buf.Len() != 16+12 {
+		t.Fatalf("header length = %d, want 28", buf.Len())
+	}
+	if b := buf.Bytes(); b[12] != 0x21 || b[13] != 0x11 {
+		t.Errorf("ver/cmd, family = %#x, %#x, want 0x21, 0x11", b[12], b[13])
+	}
+	buf.WriteString("payload")
+
+	gotSrc, gotDst, err := readProxyHeaderV2(&buf)
+	if err != nil {
+		t.Fatalf("readProxyHeaderV2: %v", err)
+	}
+	if gotSrc.String() != src.String() || gotDst.String() != dst.String() {
+		t.Errorf("addresses = %v -> %v, want %v -> %v", gotSrc, gotDst, src, dst)
+	}
+	if buf.String() != "payload" {
+		t.Errorf("remaining = %q, want payload left unread", buf.String())
+	}
+}
+
+func TestProxyHeaderV2_RoundTripIPv6(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("2001:db8::7"), Port: 51000}
+	dst := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 443}
+
+	var buf bytes.Buffer
+	if err := writeProxyHeaderV2(&buf, src, dst); err != nil {
+		t.Fatalf("writeProxyHeaderV2: %v", err)
+	}
+	if buf.Len() != 16+36 {
+		t.Fatalf("header length = %d, want 52", buf.Len())
+	}
+
+	gotSrc, gotDst, err := readProxyHeaderV2(&buf)
+	if err != nil {
+		t.Fatalf("readProxyHeaderV2: %v", err)
+	}
+	if gotSrc.String() != src.String() || gotDst.String() != dst.String() {
+		t.Errorf("addresses = %v -> %v, want %v -> %v", gotSrc, gotDst, src, dst)
+	}
+}
+
+func TestProxyHeaderV2_MixedFamiliesUseIPv6(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 51000}
+	dst := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 443}
+
+	hdr := proxyHeaderV2(src, dst)
+	if hdr[13] != 0x21 {
+		t.Fatalf("family = %#x, want TCP over IPv6 (0x21)", hdr[13])
+	}
+	gotSrc, _, err := readProxyHeaderV2(bytes.NewReader(hdr))
+	if err != nil {
+		t.Fatalf("readProxyHeaderV2: %v", err)
+	}
+	if !gotSrc.(*net.TCPAddr).IP.Equal(src.IP) {
+		t.Errorf("src = %v, want %v", gotSrc, src)
+	}
+}
+
+func TestProxyHeaderV2_LocalForNonTCP(t *testing.T) {
+	hdr := proxyHeaderV2(&net.UnixAddr{Name: "/tmp/x", Net: "unix"}, nil)
+	if len(hdr) != 16 || hdr[12] != 0x20 {
+		t.Fatalf("header = % x, want a 16-byte LOCAL header", hdr)
+	}
+	src, dst, err := readProxyHeaderV2(bytes.NewReader(hdr))
+	if err != nil {
+		t.Fatalf("readProxyHeaderV2: %v", err)
+	}
+	if src != nil || dst != nil {
+		t.Errorf("LOCAL header addresses = %v, %v, want nil", src, dst)
+	}
+}
+
+func TestReadProxyHeaderV2_SkipsTLVs(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 51000}
+	dst := &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 443}
+	hdr := proxyHeaderV2(src, dst)
+	tlv := []byte{0x04, 0x00, 0x03, 'a', 'b', 'c'} // PP2_TYPE_NOOP
+	hdr[15] += byte(len(tlv))
+	hdr = append(hdr, tlv...)
+	hdr = append(hdr, "payload"...)
+
+	r := bytes.NewReader(hdr)
+	gotSrc, _, err := readProxyHeaderV2(r)
+	if err != nil {
+		t.Fatalf("readProxyHeaderV2: %v", err)
+	}
+	if gotSrc.String() != src.String() {
+		t.Errorf("src = %v, want %v", gotSrc, src)
+	}
+	if r.Len() != len("payload") {
+		t.Errorf("remaining = %d bytes, want the payload only", r.Len())
+	}
+}
+
+func TestReadProxyHeaderV2_Invalid(t *testing.T) {
+	valid := proxyHeaderV2(
+		&net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 1},
+		&net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 2},
+	)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"PROXY v1", []byte("PROXY TCP4 203.0.113.7 198.51.100.1 1 2\r\n")},
+		{"bad version", func() []byte { b := bytes.Clone(valid); b[12] = 0x11; return b }()},
+		{"bad command", func() []byte { b := bytes.Clone(valid); b[12] = 0x22; return b }()},
+		{"truncated", valid[:20]},
+		{"short address block", func() []byte { b := bytes.Clone(valid[:16+4]); b[15] = 4; return b }()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := readProxyHeaderV2(bytes.NewReader(tt.data))
+			if !errors.Is(err, errProxyHeader) {
+				t.Errorf("err = %v, want errProxyHeader", err)
+			}
+		})
+	}
+}
@@ -1,11 +1,14 @@
 package bridge
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"log/slog"
 	"net"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/plexsphere/plexd/internal/api"
@@ -20,25 +23,41 @@ type RelaySession struct {
 	conn   *net.UDPConn // shared relay socket
 	logger *slog.Logger
 
-	mu     sync.Mutex
-	closed bool
+	mu        sync.Mutex
+	closed    bool
+	offloaded bool // forwarded by the kernel fast path
+	// rateKbps limits the traffic relayed in each direction; 0 means
+	// unlimited. limitAB and limitBA enforce it and are nil when unlimited.
+	rateKbps int64
+	limitAB  *tokenBucket
+	limitBA  *tokenBucket
+	dropped  atomic.Uint64
+
+	// quota caps the bytes relayed in both directions; 0 means unlimited.
+	// Once it is used up, packets are dropped and onQuotaExceeded, if set,
+	// is called once.
+	quota           int64
+	quotaExceeded   bool
+	onQuotaExceeded func()
+	bytesAB         uint64
+	bytesBA         uint64
+	// wasOffloaded is true once the fast path forwarded the session; that
+	// traffic is not counted.
+	wasOffloaded bool
+	openedAt     time.Time
+	expiresAt    time.Time
 }
 
 // Forward sends data to the peer that is NOT the source.
 // If srcAddr matches PeerA, forward to PeerB and vice versa.
-// Packets from unknown sources are dropped.
+// Packets from unknown sources, packets over the session's rate limit and
+// packets beyond its byte quota are dropped.
 func (s *RelaySession) Forward(srcAddr *net.UDPAddr, data []byte) {
-	s.mu.Lock()
-	if s.closed {
-		s.mu.Unlock()
-		return
-	}
-	s.mu.Unlock()
-
 	var dst *net.UDPAddr
+	aToB := false
 	switch srcAddr.String() {
 	case s.PeerAAddr.String():
-		dst = s.PeerBAddr
+		dst, aToB = s.PeerBAddr, true
 	case s.PeerBAddr.String():
 		dst = s.PeerAAddr
 	default:
@@ -50,6 +69,38 @@ func (s *RelaySession) Forward(srcAddr *net.UDPAddr, data []byte) {
 		return
 	}
 
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	limit := s.limitBA
+	if aToB {
+		limit = s.limitAB
+	}
+	allowed := limit == nil || limit.allow(len(data), time.Now())
+	overQuota := allowed && s.quota > 0 && s.bytesAB+s.bytesBA+uint64(len(data)) > uint64(s.quota)
+	notify := overQuota && !s.quotaExceeded
+	switch {
+	case overQuota:
+		s.quotaExceeded = true
+	case allowed && aToB:
+		s.bytesAB += uint64(len(data))
+	case allowed:
+		s.bytesBA += uint64(len(data))
+	}
+	s.mu.Unlock()
+	if notify && s.onQuotaExceeded != nil {
+		s.onQuotaExceeded()
+	}
+	if !allowed {
+		s.dropped.Add(1)
+		return
+	}
+	if overQuota {
+		return
+	}
+
 	if _, err := s.conn.WriteToUDP(data, dst); err != nil {
 		s.logger.Error("relay: forward failed",
 			"component", "bridge",
@@ -60,6 +111,68 @@ func (s *RelaySession) Forward(srcAddr *net.UDPAddr, data []byte) {
 	}
 }
 
+// SetRateLimit limits the traffic relayed in each direction to rateKbps
+// kbit/s; 0 removes the limit. Setting the current limit again is a no-op.
+func (s *RelaySession) SetRateLimit(rateKbps int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rateKbps == s.rateKbps {
+		return
+	}
+	s.rateKbps = rateKbps
+	s.limitAB, s.limitBA = nil, nil
+	if rateKbps > 0 {
+		now := time.Now()
+		s.limitAB = newTokenBucket(rateKbps, now)
+		s.limitBA = newTokenBucket(rateKbps, now)
+	}
+}
+
+// RateLimit returns the session's rate limit in kbit/s, or 0 if unlimited.
+func (s *RelaySession) RateLimit() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rateKbps
+}
+
+// SetByteQuota caps the bytes relayed in both directions together; 0 removes
+// the cap. It reports whether the quota is already used up.
+func (s *RelaySession) SetByteQuota(quota int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quota = quota
+	s.quotaExceeded = quota > 0 && s.bytesAB+s.bytesBA >= uint64(quota)
+	return s.quotaExceeded
+}
+
+// ByteQuota returns the session's byte quota, or 0 if unlimited.
+func (s *RelaySession) ByteQuota() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.quota
+}
+
+// Bytes returns the bytes relayed from peer A to peer B and from peer B to
+// peer A. Traffic forwarded by the fast path is not counted.
+func (s *RelaySession) Bytes() (aToB, bToA uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bytesAB, s.bytesBA
+}
+
+// Offloaded reports whether the session is forwarded by the kernel fast path
+// rather than the relay socket.
+func (s *RelaySession) Offloaded() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offloaded
+}
+
+// Dropped returns the number of packets dropped by the session's rate limit.
+func (s *RelaySession) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
 // Close marks the session as closed. Idempotent.
 func (s *RelaySession) Close() error {
 	s.mu.Lock()
@@ -81,12 +194,19 @@ type Relay struct {
 	maxSessions int
 	sessionTTL  time.Duration
 	logger      *slog.Logger
+	fastPath    FastPath
+	reporter    RelayClient
+	nodeID      string
 
 	mu        sync.RWMutex
 	conn      *net.UDPConn
 	sessions  map[string]*RelaySession
 	addrIndex map[string]*RelaySession // srcAddr.String() -> session for O(1) lookup
 	timers    map[string]*time.Timer   // TTL timers per session
+	// exhausted holds the sessions closed because their byte quota was used
+	// up, with the time they would have expired. They are not re-added
+	// until then.
+	exhausted map[string]time.Time
 	active    bool
 }
 
@@ -100,9 +220,25 @@ func NewRelay(listenPort, maxSessions int, sessionTTL time.Duration, logger *slo
 		sessions:    make(map[string]*RelaySession),
 		addrIndex:   make(map[string]*RelaySession),
 		timers:      make(map[string]*time.Timer),
+		exhausted:   make(map[string]time.Time),
 	}
 }
 
+// SetFastPath sets the kernel fast path that forwards sessions without a
+// rate limit. It must be called before Start. Sessions stay on the relay
+// socket when fp is nil or an offload fails.
+func (r *Relay) SetFastPath(fp FastPath) {
+	r.fastPath = fp
+}
+
+// SetReporter sets the control plane client that closed sessions, with the
+// traffic they relayed, are reported to. It must be called before Start.
+// Without one, closed sessions are only logged.
+func (r *Relay) SetReporter(client RelayClient, nodeID string) {
+	r.reporter = client
+	r.nodeID = nodeID
+}
+
 // Start opens a UDP socket and begins the dispatch loop.
 func (r *Relay) Start(ctx context.Context) error {
 	addr := &net.UDPAddr{IP: net.IPv4zero, Port: r.listenPort}
@@ -175,14 +311,25 @@ func (r *Relay) AddSession(assignment api.RelaySessionAssignment) error {
 	if peerA.String() == peerB.String() {
 		return fmt.Errorf("bridge: relay: peer A and peer B endpoints must differ: %s", peerA.String())
 	}
+	if assignment.RateLimitKbps < 0 {
+		return fmt.Errorf("bridge: relay: negative rate limit: %d", assignment.RateLimitKbps)
+	}
+	if assignment.ByteQuota < 0 {
+		return fmt.Errorf("bridge: relay: negative byte quota: %d", assignment.ByteQuota)
+	}
 
 	r.mu.Lock()
-	defer r.mu.Unlock()
 
 	if _, exists := r.sessions[assignment.SessionID]; exists {
+		r.mu.Unlock()
 		return fmt.Errorf("bridge: relay: duplicate session ID: %s", assignment.SessionID)
 	}
+	if r.quotaExhaustedLocked(assignment.SessionID) {
+		r.mu.Unlock()
+		return fmt.Errorf("bridge: relay: byte quota used up: %s", assignment.SessionID)
+	}
 	if len(r.sessions) >= r.maxSessions {
+		r.mu.Unlock()
 		return fmt.Errorf("bridge: relay: max sessions reached (%d)", r.maxSessions)
 	}
 
@@ -192,7 +339,13 @@ func (r *Relay) AddSession(assignment api.RelaySessionAssignment) error {
 		PeerBAddr: peerB,
 		conn:      r.conn,
 		logger:    r.logger,
+		openedAt:  time.Now(),
+		onQuotaExceeded: func() {
+			r.closeSession(assignment.SessionID, api.RelayCloseQuotaExceeded)
+		},
 	}
+	session.SetRateLimit(assignment.RateLimitKbps)
+	session.SetByteQuota(assignment.ByteQuota)
 
 	r.sessions[assignment.SessionID] = session
 	r.addrIndex[peerA.String()] = session
@@ -206,8 +359,9 @@ func (r *Relay) AddSession(assignment api.RelaySessionAssignment) error {
 			ttl = remaining
 		}
 	}
+	session.expiresAt = time.Now().Add(ttl)
 	timer := time.AfterFunc(ttl, func() {
-		r.RemoveSession(assignment.SessionID)
+		r.closeSession(assignment.SessionID, api.RelayCloseExpired)
 	})
 	r.timers[assignment.SessionID] = timer
 
@@ -216,13 +370,136 @@ func (r *Relay) AddSession(assignment api.RelaySessionAssignment) error {
 		"peer_a", peerA.String(),
 		"peer_b", peerB.String(),
 		"ttl", ttl.String(),
+		"rate_limit_kbps", assignment.RateLimitKbps,
+		"byte_quota", assignment.ByteQuota,
 	)
+	r.mu.Unlock()
 
+	r.syncOffload(session)
 	return nil
 }
 
+// syncOffload offloads s to the fast path when it has neither a rate limit
+// nor a byte quota, and returns it to the relay socket otherwise, because
+// rate limits, quotas and drop counters are enforced on the relay socket
+// only. Closed sessions are
+// returned to the relay socket. Failures are logged and leave s where it is.
+func (r *Relay) syncOffload(s *RelaySession) {
+	if r.fastPath == nil {
+		return
+	}
+
+	s.mu.Lock()
+	want := !s.closed && s.rateKbps == 0 && s.quota == 0
+	has := s.offloaded
+	s.mu.Unlock()
+	if want == has {
+		return
+	}
+
+	var err error
+	if want {
+		addr, ok := r.ListenAddr().(*net.UDPAddr)
+		if !ok {
+			return // not started
+		}
+		err = r.fastPath.OffloadRelaySession(s.SessionID, addr.Port, s.PeerAAddr, s.PeerBAddr)
+	} else {
+		err = r.fastPath.RemoveRelaySession(s.SessionID)
+	}
+	if err != nil {
+		r.logger.Warn("relay: fast path update failed",
+			"session_id", s.SessionID,
+			"offload", want,
+			"error", err,
+		)
+		return
+	}
+
+	s.mu.Lock()
+	s.offloaded = want
+	s.wasOffloaded = s.wasOffloaded || want
+	s.mu.Unlock()
+}
+
+// SetSessionRateLimit changes the rate limit of an existing session; 0
+// removes the limit. No-op if the session is not found.
+func (r *Relay) SetSessionRateLimit(sessionID string, rateKbps int64) error {
+	if rateKbps < 0 {
+		return fmt.Errorf("bridge: relay: negative rate limit: %d", rateKbps)
+	}
+	r.mu.RLock()
+	session, ok := r.sessions[sessionID]
+	r.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	if session.RateLimit() != rateKbps {
+		session.SetRateLimit(rateKbps)
+		r.logger.Info("relay session rate limit changed",
+			"session_id", sessionID,
+			"rate_limit_kbps", rateKbps,
+		)
+		r.syncOffload(session)
+	}
+	return nil
+}
+
+// SetSessionByteQuota changes the byte quota of an existing session; 0
+// removes the quota. A session that has already relayed quota bytes is
+// closed. No-op if the session is not found.
+func (r *Relay) SetSessionByteQuota(sessionID string, quota int64) error {
+	if quota < 0 {
+		return fmt.Errorf("bridge: relay: negative byte quota: %d", quota)
+	}
+	r.mu.RLock()
+	session, ok := r.sessions[sessionID]
+	r.mu.RUnlock()
+	if !ok || session.ByteQuota() == quota {
+		return nil
+	}
+	exceeded := session.SetByteQuota(quota)
+	r.logger.Info("relay session byte quota changed",
+		"session_id", sessionID,
+		"byte_quota", quota,
+	)
+	if exceeded {
+		r.closeSession(sessionID, api.RelayCloseQuotaExceeded)
+		return nil
+	}
+	r.syncOffload(session)
+	return nil
+}
+
+// QuotaExhausted reports whether sessionID was closed because its byte quota
+// was used up and has not expired yet. Such sessions cannot be added again.
+func (r *Relay) QuotaExhausted(sessionID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.quotaExhaustedLocked(sessionID)
+}
+
+// quotaExhaustedLocked reports whether sessionID is exhausted, forgetting
+// exhausted sessions that have expired. Caller must hold r.mu.
+func (r *Relay) quotaExhaustedLocked(sessionID string) bool {
+	now := time.Now()
+	for id, expiresAt := range r.exhausted {
+		if !now.Before(expiresAt) {
+			delete(r.exhausted, id)
+		}
+	}
+	_, ok := r.exhausted[sessionID]
+	return ok
+}
+
 // RemoveSession closes and removes a session by ID. No-op if not found.
 func (r *Relay) RemoveSession(sessionID string) {
+	r.closeSession(sessionID, api.RelayCloseRevoked)
+}
+
+// closeSession closes and removes a session by ID, and reports it as closed
+// for reason. No-op if not found.
+func (r *Relay) closeSession(sessionID, reason string) {
 	r.mu.Lock()
 	session, ok := r.sessions[sessionID]
 	if !ok {
@@ -240,9 +517,20 @@ func (r *Relay) RemoveSession(sessionID string) {
 	delete(r.addrIndex, session.PeerAAddr.String())
 	delete(r.addrIndex, session.PeerBAddr.String())
 	delete(r.sessions, sessionID)
+	if reason == api.RelayCloseQuotaExceeded {
+		r.exhausted[sessionID] = session.expiresAt
+	}
 	r.mu.Unlock()
 
+	if reason == api.RelayCloseQuotaExceeded {
+		r.logger.Warn("relay session byte quota exceeded",
+			"session_id", sessionID,
+			"byte_quota", session.ByteQuota(),
+		)
+	}
 	session.Close()
+	r.syncOffload(session)
+	go r.reportClosed(session, reason)
 }
 
 // Stop closes all sessions and the UDP listener. Idempotent.
@@ -272,10 +560,19 @@ func (r *Relay) Stop() error {
 	r.conn = nil
 	r.mu.Unlock()
 
-	// Close all sessions.
+	// Close all sessions and report them concurrently, so that their usage
+	// is not lost on shutdown.
+	var wg sync.WaitGroup
 	for _, s := range sessions {
 		s.Close()
+		r.syncOffload(s)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.reportClosed(s, api.RelayCloseStopped)
+		}()
 	}
+	wg.Wait()
 
 	// Close UDP listener.
 	if conn != nil {
@@ -304,6 +601,30 @@ func (r *Relay) SessionIDs() []string {
 	return ids
 }
 
+// ShapingStatus returns the rate limits of all rate-limited sessions for
+// heartbeat reporting, sorted by session ID.
+func (r *Relay) ShapingStatus() []api.ShapingStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []api.ShapingStatus
+	for id, s := range r.sessions {
+		rate := s.RateLimit()
+		if rate == 0 {
+			continue
+		}
+		out = append(out, api.ShapingStatus{
+			Target:   id,
+			RateKbps: rate,
+			Active:   true,
+			Dropped:  s.Dropped(),
+		})
+	}
+	slices.SortFunc(out, func(a, b api.ShapingStatus) int {
+		return cmp.Compare(a.Target, b.Target)
+	})
+	return out
+}
+
 // ListenAddr returns the local address of the relay UDP listener.
 // Returns nil if not started.
 func (r *Relay) ListenAddr() net.Addr {
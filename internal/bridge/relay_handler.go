@@ -53,7 +53,9 @@ func HandleRelaySessionRevoked(relay *Relay, logger *slog.Logger) api.EventHandl
 
 // RelayReconcileHandler returns a reconcile.ReconcileHandler that reconciles
 // relay sessions to match the desired RelayConfig. Sessions not in the desired
-// state are removed; missing sessions are added.
+// state are removed; missing sessions are added, except those whose byte
+// quota was used up; existing sessions take the desired rate limit and byte
+// quota.
 func RelayReconcileHandler(relay *Relay, logger *slog.Logger) reconcile.ReconcileHandler {
 	return func(_ context.Context, desired *api.StateResponse, _ reconcile.StateDiff) error {
 		if desired == nil || desired.RelayConfig == nil {
@@ -83,6 +85,23 @@ func RelayReconcileHandler(relay *Relay, logger *slog.Logger) reconcile.Reconcil
 		var errs []error
 		for id, assignment := range desiredSet {
 			if currentSet[id] {
+				if err := relay.SetSessionRateLimit(id, assignment.RateLimitKbps); err != nil {
+					logger.Error("relay reconcile: set rate limit failed",
+						"session_id", id,
+						"error", err,
+					)
+					errs = append(errs, err)
+				}
+				if err := relay.SetSessionByteQuota(id, assignment.ByteQuota); err != nil {
+					logger.Error("relay reconcile: set byte quota failed",
+						"session_id", id,
+						"error", err,
+					)
+					errs = append(errs, err)
+				}
+				continue
+			}
+			if relay.QuotaExhausted(id) {
 				continue
 			}
 			if err := relay.AddSession(assignment); err != nil {
@@ -358,3 +358,77 @@ func TestRelayReconcileHandler_Mixed(t *testing.T) {
 		t.Error("expected stale-sess to be removed")
 	}
 }
+
+func TestRelayReconcileHandler_UpdatesRateLimit(t *testing.T) {
+	relay := NewRelay(0, 100, 5*time.Minute, discardLogger())
+
+	handler := RelayReconcileHandler(relay, discardLogger())
+
+	desired := &api.StateResponse{
+		RelayConfig: &api.RelayConfig{
+			Sessions: []api.RelaySessionAssignment{
+				{
+					SessionID:     "sess-1",
+					PeerAEndpoint: "127.0.0.1:5000",
+					PeerBEndpoint: "127.0.0.1:5001",
+					ExpiresAt:     time.Now().Add(5 * time.Minute),
+				},
+			},
+		},
+	}
+	if err := handler(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+		t.Fatalf("handler error = %v, want nil", err)
+	}
+	if st := relay.ShapingStatus(); len(st) != 0 {
+		t.Fatalf("ShapingStatus = %v, want empty", st)
+	}
+
+	desired.RelayConfig.Sessions[0].RateLimitKbps = 1000
+	if err := handler(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+		t.Fatalf("handler error = %v, want nil", err)
+	}
+
+	st := relay.ShapingStatus()
+	if len(st) != 1 || st[0].Target != "sess-1" || st[0].RateKbps != 1000 || !st[0].Active {
+		t.Errorf("ShapingStatus = %+v, want active sess-1 at 1000", st)
+	}
+}
+
+func TestRelayReconcileHandler_ByteQuota(t *testing.T) {
+	relay := startTestRelay(t, 100, 5*time.Minute)
+
+	handler := RelayReconcileHandler(relay, discardLogger())
+
+	desired := &api.StateResponse{
+		RelayConfig: &api.RelayConfig{
+			Sessions: []api.RelaySessionAssignment{
+				{
+					SessionID:     "sess-1",
+					PeerAEndpoint: "127.0.0.1:5000",
+					PeerBEndpoint: "127.0.0.1:5001",
+					ExpiresAt:     time.Now().Add(5 * time.Minute),
+				},
+			},
+		},
+	}
+	if err := handler(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+		t.Fatalf("handler error = %v, want nil", err)
+	}
+
+	relay.mu.RLock()
+	session := relay.sessions["sess-1"]
+	relay.mu.RUnlock()
+	session.Forward(session.PeerAAddr, make([]byte, 2048))
+
+	// The new quota is already used up: the session is closed and not
+	// re-added by later cycles.
+	desired.RelayConfig.Sessions[0].ByteQuota = 1024
+	for range 2 {
+		if err := handler(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+			t.Fatalf("handler error = %v, want nil", err)
+		}
+	}
+	if relay.ActiveCount() != 0 {
+		t.Errorf("ActiveCount = %d, want 0", relay.ActiveCount())
+	}
+}
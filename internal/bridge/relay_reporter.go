@@ -0,0 +1,51 @@
+package bridge
+
+import (
+	"context"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// RelayClient is the subset of the control plane client used to report
+// closed relay sessions. It is satisfied by *api.ControlPlane.
+type RelayClient interface {
+	RelaySessionClosed(ctx context.Context, nodeID, sessionID string, req api.RelaySessionClosedRequest) error
+}
+
+// relayReportTimeout bounds the report of a single closed session.
+const relayReportTimeout = 10 * time.Second
+
+// reportClosed reports s as closed for reason, with the traffic it relayed.
+// Reporting is best effort: failures are logged. No-op without a reporter.
+func (r *Relay) reportClosed(s *RelaySession, reason string) {
+	if r.reporter == nil {
+		return
+	}
+	req := relaySessionClosedRequest(s, reason, time.Now())
+
+	ctx, cancel := context.WithTimeout(context.Background(), relayReportTimeout)
+	defer cancel()
+	if err := r.reporter.RelaySessionClosed(ctx, r.nodeID, s.SessionID, req); err != nil {
+		r.logger.Warn("relay: report session closed failed",
+			"session_id", s.SessionID,
+			"reason", reason,
+			"error", err,
+		)
+	}
+}
+
+// relaySessionClosedRequest returns the close report of s at now.
+func relaySessionClosedRequest(s *RelaySession, reason string, now time.Time) api.RelaySessionClosedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return api.RelaySessionClosedRequest{
+		Reason:    reason,
+		BytesAToB: s.bytesAB,
+		BytesBToA: s.bytesBA,
+		Dropped:   s.dropped.Load(),
+		Offloaded: s.wasOffloaded,
+		Duration:  now.Sub(s.openedAt).Round(time.Millisecond).String(),
+		Timestamp: now.UTC(),
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -443,3 +444,279 @@ func TestRelay_SessionExpiry(t *testing.T) {
 		t.Errorf("ActiveCount after expiry = %d, want 0", relay.ActiveCount())
 	}
 }
+
+func TestRelaySession_RateLimitDrops(t *testing.T) {
+	relayConn := newTestUDPConn(t)
+	peerA := newTestUDPConn(t)
+	peerB := newTestUDPConn(t)
+
+	session := &RelaySession{
+		SessionID: "sess-limited",
+		PeerAAddr: peerA.LocalAddr().(*net.UDPAddr),
+		PeerBAddr: peerB.LocalAddr().(*net.UDPAddr),
+		conn:      relayConn,
+		logger:    discardLogger(),
+	}
+	// 8 kbit/s: the burst admits one large datagram, the refill nothing more.
+	session.SetRateLimit(8)
+
+	data := make([]byte, 40000)
+	session.Forward(session.PeerAAddr, data)
+	session.Forward(session.PeerAAddr, data)
+
+	if got := session.Dropped(); got != 1 {
+		t.Errorf("Dropped = %d, want 1", got)
+	}
+
+	// The B->A direction has its own bucket.
+	session.Forward(session.PeerBAddr, data)
+	if got := session.Dropped(); got != 1 {
+		t.Errorf("Dropped after B->A = %d, want 1", got)
+	}
+
+	session.SetRateLimit(0)
+	session.Forward(session.PeerAAddr, data)
+	if got := session.Dropped(); got != 1 {
+		t.Errorf("Dropped after removing limit = %d, want 1", got)
+	}
+}
+
+func TestRelay_AddSession_NegativeRateLimit(t *testing.T) {
+	relay := startTestRelay(t, 100, 5*time.Minute)
+
+	err := relay.AddSession(api.RelaySessionAssignment{
+		SessionID:     "sess-1",
+		PeerAEndpoint: "127.0.0.1:5000",
+		PeerBEndpoint: "127.0.0.1:5001",
+		RateLimitKbps: -1,
+	})
+	if err == nil {
+		t.Fatal("expected error for negative rate limit")
+	}
+}
+
+// mockRelayClient records the relay sessions reported as closed.
+type mockRelayClient struct {
+	mu      sync.Mutex
+	reports map[string]api.RelaySessionClosedRequest
+	nodeIDs []string
+}
+
+func (c *mockRelayClient) RelaySessionClosed(_ context.Context, nodeID, sessionID string, req api.RelaySessionClosedRequest) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.reports == nil {
+		c.reports = make(map[string]api.RelaySessionClosedRequest)
+	}
+	c.reports[sessionID] = req
+	c.nodeIDs = append(c.nodeIDs, nodeID)
+	return nil
+}
+
+func (c *mockRelayClient) report(sessionID string) (api.RelaySessionClosedRequest, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	req, ok := c.reports[sessionID]
+	return req, ok
+}
+
+func TestRelaySession_ByteQuota(t *testing.T) {
+	relayConn := newTestUDPConn(t)
+	peerA := newTestUDPConn(t)
+	peerB := newTestUDPConn(t)
+
+	exceeded := 0
+	session := &RelaySession{
+		SessionID:       "sess-quota",
+		PeerAAddr:       peerA.LocalAddr().(*net.UDPAddr),
+		PeerBAddr:       peerB.LocalAddr().(*net.UDPAddr),
+		conn:            relayConn,
+		logger:          discardLogger(),
+		onQuotaExceeded: func() { exceeded++ },
+	}
+	if session.SetByteQuota(100) {
+		t.Fatal("SetByteQuota reported a fresh session as exceeded")
+	}
+
+	session.Forward(session.PeerAAddr, make([]byte, 60))
+	session.Forward(session.PeerBAddr, make([]byte, 30))
+	if aToB, bToA := session.Bytes(); aToB != 60 || bToA != 30 {
+		t.Errorf("Bytes = %d/%d, want 60/30", aToB, bToA)
+	}
+	if exceeded != 0 {
+		t.Fatalf("onQuotaExceeded called %d times within quota", exceeded)
+	}
+
+	// 60 more bytes would exceed the quota: dropped, reported once.
+	session.Forward(session.PeerAAddr, make([]byte, 60))
+	session.Forward(session.PeerAAddr, make([]byte, 60))
+	if aToB, _ := session.Bytes(); aToB != 60 {
+		t.Errorf("BytesAToB = %d, want 60", aToB)
+	}
+	if exceeded != 1 {
+		t.Errorf("onQuotaExceeded called %d times, want 1", exceeded)
+	}
+
+	if !session.SetByteQuota(90) {
+		t.Error("SetByteQuota(90) = false, want true after relaying 90 bytes")
+	}
+}
+
+func TestRelay_QuotaExceeded(t *testing.T) {
+	client := &mockRelayClient{}
+	relay := NewRelay(0, 100, 5*time.Minute, discardLogger())
+	relay.SetReporter(client, "node-1")
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := relay.Start(ctx); err != nil {
+		t.Fatalf("start relay: %v", err)
+	}
+	t.Cleanup(func() { _ = relay.Stop() })
+
+	peerA := newTestUDPConn(t)
+	peerB := newTestUDPConn(t)
+	assignment := api.RelaySessionAssignment{
+		SessionID:     "sess-quota",
+		PeerAEndpoint: peerA.LocalAddr().String(),
+		PeerBEndpoint: peerB.LocalAddr().String(),
+		ExpiresAt:     time.Now().Add(5 * time.Minute),
+		ByteQuota:     10,
+	}
+	if err := relay.AddSession(assignment); err != nil {
+		t.Fatalf("AddSession: %v", err)
+	}
+
+	relayAddr, err := net.ResolveUDPAddr("udp", relay.ListenAddr().String())
+	if err != nil {
+		t.Fatalf("resolve relay addr: %v", err)
+	}
+	if _, err := peerA.WriteToUDP([]byte("hello"), relayAddr); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	buf := make([]byte, 64)
+	_ = peerB.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := peerB.ReadFromUDP(buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if _, err := peerA.WriteToUDP([]byte("over the quota"), relayAddr); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	waitForCondition(t, 2*time.Second, func() bool {
+		_, ok := client.report("sess-quota")
+		return ok
+	})
+	if relay.ActiveCount() != 0 {
+		t.Errorf("ActiveCount = %d, want 0", relay.ActiveCount())
+	}
+	req, _ := client.report("sess-quota")
+	if req.Reason != api.RelayCloseQuotaExceeded || req.BytesAToB != 5 || req.BytesBToA != 0 {
+		t.Errorf("report = %+v, want quota_exceeded with 5/0 bytes", req)
+	}
+
+	// The exhausted session is not re-added until it expires.
+	if !relay.QuotaExhausted("sess-quota") {
+		t.Error("QuotaExhausted = false, want true")
+	}
+	if err := relay.AddSession(assignment); err == nil || !strings.Contains(err.Error(), "byte quota used up") {
+		t.Errorf("AddSession error = %v, want byte quota used up", err)
+	}
+}
+
+func TestRelay_ReportsCloseReasons(t *testing.T) {
+	client := &mockRelayClient{}
+	relay := NewRelay(0, 100, 5*time.Minute, discardLogger())
+	relay.SetReporter(client, "node-1")
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := relay.Start(ctx); err != nil {
+		t.Fatalf("start relay: %v", err)
+	}
+
+	add := func(id string, port int, expiresAt time.Time) {
+		t.Helper()
+		if err := relay.AddSession(api.RelaySessionAssignment{
+			SessionID:     id,
+			PeerAEndpoint: fmt.Sprintf("127.0.0.1:%d", port),
+			PeerBEndpoint: fmt.Sprintf("127.0.0.1:%d", port+1),
+			ExpiresAt:     expiresAt,
+		}); err != nil {
+			t.Fatalf("AddSession %s: %v", id, err)
+		}
+	}
+	add("sess-expired", 5000, time.Now().Add(20*time.Millisecond))
+	add("sess-revoked", 5002, time.Now().Add(5*time.Minute))
+	add("sess-stopped", 5004, time.Now().Add(5*time.Minute))
+
+	relay.RemoveSession("sess-revoked")
+	waitForCondition(t, 2*time.Second, func() bool {
+		_, ok := client.report("sess-expired")
+		return ok
+	})
+	if err := relay.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	for id, want := range map[string]string{
+		"sess-expired": api.RelayCloseExpired,
+		"sess-revoked": api.RelayCloseRevoked,
+		"sess-stopped": api.RelayCloseStopped,
+	} {
+		var req api.RelaySessionClosedRequest
+		waitForCondition(t, 2*time.Second, func() bool {
+			var ok bool
+			req, ok = client.report(id)
+			return ok
+		})
+		if req.Reason != want {
+			t.Errorf("%s reason = %q, want %q", id, req.Reason, want)
+		}
+		if req.Duration == "" || req.Timestamp.IsZero() {
+			t.Errorf("%s report = %+v, want duration and timestamp", id, req)
+		}
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	for _, id := range client.nodeIDs {
+		if id != "node-1" {
+			t.Errorf("reported node ID = %q, want node-1", id)
+		}
+	}
+}
+
+func TestRelay_SetSessionByteQuota(t *testing.T) {
+	relay := startTestRelay(t, 100, 5*time.Minute)
+
+	if err := relay.AddSession(api.RelaySessionAssignment{
+		SessionID:     "sess-1",
+		PeerAEndpoint: "127.0.0.1:5000",
+		PeerBEndpoint: "127.0.0.1:5001",
+	}); err != nil {
+		t.Fatalf("AddSession: %v", err)
+	}
+	if err := relay.SetSessionByteQuota("sess-1", -1); err == nil {
+		t.Error("expected error for negative byte quota")
+	}
+	if err := relay.SetSessionByteQuota("sess-1", 1<<20); err != nil {
+		t.Fatalf("SetSessionByteQuota: %v", err)
+	}
+	if relay.ActiveCount() != 1 {
+		t.Fatalf("ActiveCount = %d, want 1", relay.ActiveCount())
+	}
+
+	relay.mu.RLock()
+	session := relay.sessions["sess-1"]
+	relay.mu.RUnlock()
+	session.Forward(session.PeerAAddr, make([]byte, 2048))
+
+	// A quota below the traffic already relayed closes the session.
+	if err := relay.SetSessionByteQuota("sess-1", 1024); err != nil {
+		t.Fatalf("SetSessionByteQuota: %v", err)
+	}
+	if relay.ActiveCount() != 0 {
+		t.Errorf("ActiveCount = %d, want 0", relay.ActiveCount())
+	}
+	if !relay.QuotaExhausted("sess-1") {
+		t.Error("QuotaExhausted = false, want true")
+	}
+}
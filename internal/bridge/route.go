@@ -1,7 +1,11 @@
 package bridge
 
+import "net/netip"
+
 // RouteController abstracts OS-level routing and forwarding operations for testability.
 // All methods must be idempotent: repeating an operation that is already applied returns nil.
+// AddRoute and RemoveRoute must be safe for concurrent use; Manager.UpdateRoutes
+// programs routes with several workers.
 type RouteController interface {
 	// EnableForwarding enables IP forwarding between the mesh and access interfaces.
 	EnableForwarding(meshIface, accessIface string) error
@@ -24,3 +28,126 @@ type RouteController interface {
 	// Idempotent: removing non-existent masquerade returns nil.
 	RemoveNATMasquerade(iface string) error
 }
+
+// ConntrackFlusher is implemented by route controllers that can delete
+// connection tracking entries. Managers call it after removing a tunnel or
+// ingress rule so that established flows stop matching immediately instead
+// of surviving on their existing conntrack state.
+// Idempotent: flushing when no entries match returns nil.
+type ConntrackFlusher interface {
+	// FlushConntrackSubnet deletes entries whose original source or
+	// destination lies within the given CIDR subnet.
+	FlushConntrackSubnet(subnet string) error
+
+	// FlushConntrackPort deletes entries of the given protocol ("tcp" or
+	// "udp") whose original destination is addr and port. An unspecified
+	// addr matches the addresses of the local interfaces, so flows
+	// forwarded to the same port on other hosts are kept.
+	FlushConntrackPort(protocol string, addr netip.Addr, port int) error
+}
+
+// TrafficShaper is implemented by route controllers that can limit the
+// egress bandwidth of an interface. Managers call it for site-to-site tunnels
+// and the user access interface when the control plane sets an egress rate.
+// Managers that find no TrafficShaper report the limit as inactive.
+type TrafficShaper interface {
+	// SetEgressRate limits traffic sent on iface to rateKbps kbit/s,
+	// replacing any earlier limit. rateKbps must be positive.
+	SetEgressRate(iface string, rateKbps int64) error
+
+	// ClearEgressRate removes the limit from iface.
+	// Idempotent: clearing an unshaped or missing interface returns nil.
+	ClearEgressRate(iface string) error
+}
+
+// VirtualIPController is implemented by route controllers that can move a
+// virtual IP address between the members of a bridge HA group. The active
+// member adds and announces the address; a member that stands down removes
+// it. Without a VirtualIPController a configured virtual IP is reported as
+// unsupported and only the election runs.
+type VirtualIPController interface {
+	// AddVirtualIP assigns the CIDR address to iface.
+	// Idempotent: adding an address that is already assigned returns nil.
+	AddVirtualIP(iface, cidr string) error
+
+	// RemoveVirtualIP removes the CIDR address from iface.
+	// Idempotent: removing an unassigned address returns nil.
+	RemoveVirtualIP(iface, cidr string) error
+
+	// AnnounceVirtualIP tells the hosts on iface's link that the address
+	// is now reachable through this node, so they update their neighbour
+	// caches without waiting for the old entries to expire.
+	AnnounceVirtualIP(iface, cidr string) error
+}
+
+// RouteInspector is implemented by route controllers that can read back the
+// state applied by EnableForwarding and AddRoute. Managers use it to detect
+// and repair changes made outside plexd, such as an `ip route del` or a
+// forwarding sysctl reset. Without a RouteInspector, external changes go
+// unnoticed until the next Setup.
+type RouteInspector interface {
+	// RouteInstalled reports whether the route for the CIDR subnet via
+	// iface exists.
+	RouteInstalled(subnet, iface string) (bool, error)
+
+	// ForwardingEnabled reports whether IP forwarding is enabled on iface
+	// and, with policy routing, whether the ip rule selecting its routes
+	// is installed.
+	ForwardingEnabled(iface string) (bool, error)
+}
+
+// SubnetMapper is implemented by route controllers that can translate a
+// subnet 1:1 to another subnet of the same size (NETMAP). The site-to-site
+// manager uses it for tunnels with a NAT map, so that sites with overlapping
+// address ranges can be connected. Tunnels with a NAT map fail when the route
+// controller is not a SubnetMapper.
+type SubnetMapper interface {
+	// AddSubnetMap translates destination addresses in the CIDR subnet as
+	// to local for traffic received on iface, and source addresses in
+	// local to as for traffic sent on iface. Host bits are preserved.
+	// Idempotent: adding an existing mapping returns nil.
+	AddSubnetMap(iface, local, as string) error
+
+	// RemoveSubnetMap removes the mapping added by AddSubnetMap.
+	// Idempotent: removing a non-existent mapping returns nil.
+	RemoveSubnetMap(iface, local, as string) error
+}
+
+// Masquerader is implemented by route controllers that can masquerade the
+// traffic to each access subnet separately. The bridge manager installs one
+// rule per IPv4 access subnet, so routes and NAT follow the same subnet set
+// and a subnet removed by the control plane stops being translated. Without
+// a Masquerader the manager falls back to AddNATMasquerade, which translates
+// all traffic leaving the access interface.
+type Masquerader interface {
+	// AddMasquerade translates the source address of traffic sent on
+	// iface to the IPv4 CIDR subnet to the address of iface.
+	// Idempotent: adding an existing rule returns nil.
+	AddMasquerade(iface, subnet string) error
+
+	// RemoveMasquerade removes the rule added by AddMasquerade.
+	// Idempotent: removing a non-existent rule returns nil.
+	RemoveMasquerade(iface, subnet string) error
+
+	// MasqueradeConflicts describes the source NAT rules not installed by
+	// plexd that also translate traffic sent on iface, such as a
+	// MASQUERADE rule of the host firewall. Such rules take precedence
+	// over or duplicate plexd's rules depending on their priority.
+	MasqueradeConflicts(iface string) ([]string, error)
+}
+
+// GatewayRouter is implemented by route controllers that can route a subnet
+// via a gateway on a link, rather than onto the link itself. The BGP speaker
+// installs the routes it imports from the local router with it. Without a
+// GatewayRouter imported routes are reported as unsupported and not
+// installed.
+type GatewayRouter interface {
+	// AddGatewayRoute routes the CIDR subnet via gateway on iface,
+	// replacing a route for the subnet via another gateway.
+	// Idempotent: adding an existing route returns nil.
+	AddGatewayRoute(subnet, gateway, iface string) error
+
+	// RemoveGatewayRoute removes the route added by AddGatewayRoute.
+	// Idempotent: removing a non-existent route returns nil.
+	RemoveGatewayRoute(subnet, gateway, iface string) error
+}
@@ -8,6 +8,8 @@ import (
 	"log/slog"
 	"net"
 	"os"
+	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/google/nftables"
@@ -23,8 +25,15 @@ const natChainName = "postrouting"
 
 // NetlinkRouteController implements RouteController using Linux netlink for
 // route management, sysctl for IP forwarding, and nftables for NAT masquerade.
+// It also implements RouteInspector, SubnetMapper, Masquerader, and
+// RouteTableFlusher when policy routing is enabled.
 type NetlinkRouteController struct {
 	logger *slog.Logger
+
+	mu       sync.Mutex
+	policy   PolicyRouting
+	marks    map[string]uint32 // interface name → fwmark
+	iptables *iptablesMasquerader
 }
 
 // NewNetlinkRouteController returns a new NetlinkRouteController.
@@ -32,12 +41,38 @@ func NewNetlinkRouteController(logger *slog.Logger) *NetlinkRouteController {
 	return &NetlinkRouteController{logger: logger}
 }
 
+// SetPolicyRouting places subsequently added routes in a dedicated routing
+// table selected by per-interface fwmark rules. It must be called before any
+// route is added.
+func (c *NetlinkRouteController) SetPolicyRouting(p PolicyRouting) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.policy = p
+}
+
+// SetNATBackend selects how masquerade rules are programmed:
+// NATBackendNftables, the default, or NATBackendIPTables. It must be called
+// before any masquerade rule is added. AddNATMasquerade always uses nftables.
+func (c *NetlinkRouteController) SetNATBackend(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.iptables = nil
+	if name == NATBackendIPTables {
+		c.iptables = newIPTablesMasquerader()
+	}
+}
+
 // EnableForwarding enables IPv4 forwarding for the given interfaces via sysctl.
+// With policy routing enabled, traffic entering either interface is marked
+// so that it is routed via the dedicated table.
 func (c *NetlinkRouteController) EnableForwarding(meshIface, accessIface string) error {
 	for _, iface := range []string{meshIface, accessIface} {
 		if err := setSysctl(iface, "1"); err != nil {
 			return fmt.Errorf("bridge: enable forwarding: %w", err)
 		}
+		if err := c.ensureInterfaceMark(iface); err != nil {
+			return fmt.Errorf("bridge: enable forwarding: %w", err)
+		}
 	}
 
 	c.logger.Debug("IP forwarding enabled",
@@ -64,6 +99,24 @@ func (c *NetlinkRouteController) DisableForwarding(meshIface, accessIface string
 	return nil
 }
 
+// ForwardingEnabled reports whether IPv4 forwarding is enabled on iface via
+// sysctl and, with policy routing, whether the ip rule for its fwmark is
+// installed.
+func (c *NetlinkRouteController) ForwardingEnabled(iface string) (bool, error) {
+	value, err := getSysctl(iface)
+	if err != nil {
+		return false, fmt.Errorf("bridge: check forwarding: %w", err)
+	}
+	if value != "1" {
+		return false, nil
+	}
+	ok, err := c.ipRuleInstalled(iface)
+	if err != nil {
+		return false, fmt.Errorf("bridge: check forwarding: %w", err)
+	}
+	return ok, nil
+}
+
 // validateIfaceName checks that the interface name is safe for use in filesystem paths.
 // It rejects names containing path traversal characters.
 func validateIfaceName(name string) error {
@@ -90,6 +143,49 @@ func setSysctl(iface, value string) error {
 	return nil
 }
 
+// getSysctl reads the per-interface IPv4 forwarding sysctl.
+func getSysctl(iface string) (string, error) {
+	if err := validateIfaceName(iface); err != nil {
+		return "", err
+	}
+	path := fmt.Sprintf("/proc/sys/net/ipv4/conf/%s/forwarding", iface)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("sysctl %s: %w", path, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// RouteInstalled reports whether the route for the given CIDR subnet via the
+// given interface exists in the table AddRoute uses.
+func (c *NetlinkRouteController) RouteInstalled(subnet, iface string) (bool, error) {
+	_, dst, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return false, fmt.Errorf("bridge: check route: parse CIDR %q: %w", subnet, err)
+	}
+
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		return false, fmt.Errorf("bridge: check route: lookup interface %q: %w", iface, err)
+	}
+
+	table := c.routeTable()
+	if table == 0 {
+		table = syscall.RT_TABLE_MAIN
+	}
+	family := netlink.FAMILY_V4
+	if dst.IP.To4() == nil {
+		family = netlink.FAMILY_V6
+	}
+	routes, err := netlink.RouteListFiltered(family,
+		&netlink.Route{Dst: dst, LinkIndex: link.Attrs().Index, Table: table},
+		netlink.RT_FILTER_DST|netlink.RT_FILTER_OIF|netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return false, fmt.Errorf("bridge: check route %q via %q: %w", subnet, iface, err)
+	}
+	return len(routes) > 0, nil
+}
+
 // AddRoute adds a route for the given CIDR subnet via the given interface.
 // Idempotent: adding an existing route returns nil.
 func (c *NetlinkRouteController) AddRoute(subnet, iface string) error {
@@ -107,6 +203,7 @@ func (c *NetlinkRouteController) AddRoute(subnet, iface string) error {
 		Dst:       dst,
 		LinkIndex: link.Attrs().Index,
 		Scope:     netlink.SCOPE_LINK,
+		Table:     c.routeTable(),
 	}
 
 	if err := netlink.RouteAdd(route); err != nil {
@@ -143,9 +240,13 @@ func (c *NetlinkRouteController) RemoveRoute(subnet, iface string) error {
 		return fmt.Errorf("bridge: remove route: lookup interface %q: %w", iface, err)
 	}
 
+	// The scope must match AddRoute: the kernel does not delete a scope
+	// link route for a request with the default scope and reports ESRCH.
 	route := &netlink.Route{
 		Dst:       dst,
 		LinkIndex: link.Attrs().Index,
+		Scope:     netlink.SCOPE_LINK,
+		Table:     c.routeTable(),
 	}
 
 	if err := netlink.RouteDel(route); err != nil {
@@ -5,6 +5,7 @@ package bridge
 import (
 	"io"
 	"log/slog"
+	"net/netip"
 	"strings"
 	"testing"
 )
@@ -275,3 +276,107 @@ func TestAddAndRemoveNATMasqueradeRoundTrip(t *testing.T) {
 		t.Fatalf("second RemoveNATMasquerade failed: %v", err)
 	}
 }
+
+// Compile-time check that NetlinkRouteController implements RouteTableFlusher.
+var _ RouteTableFlusher = (*NetlinkRouteController)(nil)
+
+// Compile-time check that NetlinkRouteController implements RouteInspector.
+var _ RouteInspector = (*NetlinkRouteController)(nil)
+
+func TestForwardingEnabledInvalidInterface(t *testing.T) {
+	ctrl := NewNetlinkRouteController(discardLoggerRoute())
+	if _, err := ctrl.ForwardingEnabled("../all"); err == nil {
+		t.Error("ForwardingEnabled with a path traversal name = nil error, want error")
+	}
+}
+
+func TestFlushRouteTableDisabled(t *testing.T) {
+	ctrl := NewNetlinkRouteController(discardLoggerRoute())
+	if err := ctrl.FlushRouteTable(); err != nil {
+		t.Errorf("FlushRouteTable without policy routing = %v, want nil", err)
+	}
+}
+
+func TestEnsureInterfaceMarkDisabled(t *testing.T) {
+	ctrl := NewNetlinkRouteController(discardLoggerRoute())
+	if err := ctrl.ensureInterfaceMark("eth1"); err != nil {
+		t.Errorf("ensureInterfaceMark without policy routing = %v, want nil", err)
+	}
+	if len(ctrl.marks) != 0 {
+		t.Errorf("marks = %v, want empty", ctrl.marks)
+	}
+}
+
+func TestRouteTableFromPolicy(t *testing.T) {
+	ctrl := NewNetlinkRouteController(discardLoggerRoute())
+	if got := ctrl.routeTable(); got != 0 {
+		t.Errorf("routeTable() = %d, want 0", got)
+	}
+	ctrl.SetPolicyRouting(PolicyRouting{Table: 51820, FwMarkBase: 0x100, RulePriority: 10000})
+	if got := ctrl.routeTable(); got != 51820 {
+		t.Errorf("routeTable() = %d, want 51820", got)
+	}
+}
+
+// Compile-time check that NetlinkRouteController implements ConntrackFlusher.
+var _ ConntrackFlusher = (*NetlinkRouteController)(nil)
+
+func TestFlushConntrackSubnetInvalidCIDR(t *testing.T) {
+	ctrl := NewNetlinkRouteController(discardLoggerRoute())
+
+	err := ctrl.FlushConntrackSubnet("not-a-cidr")
+	if err == nil {
+		t.Fatal("expected error for invalid CIDR")
+	}
+	if !strings.HasPrefix(err.Error(), "bridge: flush conntrack: parse CIDR") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestFlushConntrackPortInvalid(t *testing.T) {
+	ctrl := NewNetlinkRouteController(discardLoggerRoute())
+
+	addr := netip.IPv6Unspecified()
+	if err := ctrl.FlushConntrackPort("icmp", addr, 80); err == nil {
+		t.Error("expected error for unsupported protocol")
+	}
+	if err := ctrl.FlushConntrackPort("tcp", addr, 0); err == nil {
+		t.Error("expected error for port 0")
+	}
+	if err := ctrl.FlushConntrackPort("udp", addr, 70000); err == nil {
+		t.Error("expected error for port above 65535")
+	}
+	if err := ctrl.FlushConntrackPort("tcp", netip.Addr{}, 80); err == nil {
+		t.Error("expected error for invalid address")
+	}
+}
+
+func TestSetEgressRateNonExistentInterface(t *testing.T) {
+	ctrl := NewNetlinkRouteController(discardLoggerRoute())
+
+	err := ctrl.SetEgressRate("plexd-nonexistent", 1000)
+	if err == nil {
+		t.Fatal("expected error for non-existent interface")
+	}
+
+	expected := "bridge: set egress rate: lookup interface"
+	if !strings.HasPrefix(err.Error(), expected) {
+		t.Errorf("expected error prefix %q, got %q", expected, err.Error())
+	}
+}
+
+func TestSetEgressRateNonPositive(t *testing.T) {
+	ctrl := NewNetlinkRouteController(discardLoggerRoute())
+
+	if err := ctrl.SetEgressRate("lo", 0); err == nil {
+		t.Fatal("expected error for zero rate")
+	}
+}
+
+func TestClearEgressRateNonExistentInterface(t *testing.T) {
+	ctrl := NewNetlinkRouteController(discardLoggerRoute())
+
+	if err := ctrl.ClearEgressRate("plexd-nonexistent"); err != nil {
+		t.Errorf("ClearEgressRate = %v, want nil", err)
+	}
+}
@@ -0,0 +1,52 @@
+package bridge
+
+import "fmt"
+
+// PolicyRouting places bridge and site-to-site routes in a dedicated routing
+// table instead of the main table. Traffic entering each plexd-managed
+// interface is tagged with a per-interface fwmark, and an ip rule sends
+// marked traffic to the dedicated table. Because every plexd route lives in
+// one table, all of them can be removed in a single flush.
+type PolicyRouting struct {
+	// Table is the routing table ID. Zero means routes go to the main table
+	// and no rules or marks are installed.
+	Table int
+
+	// FwMarkBase is the first fwmark assigned to an interface. Each further
+	// interface receives the next value.
+	FwMarkBase uint32
+
+	// RulePriority is the priority of the installed ip rules.
+	RulePriority int
+}
+
+// Enabled reports whether routes are placed in a dedicated table.
+func (p PolicyRouting) Enabled() bool {
+	return p.Table > 0
+}
+
+// validate checks that the table ID does not collide with kernel-reserved tables.
+func (p PolicyRouting) validate() error {
+	if p.Table < 0 {
+		return fmt.Errorf("bridge: config: RouteTable must not be negative")
+	}
+	switch p.Table {
+	case 253, 254, 255:
+		return fmt.Errorf("bridge: config: RouteTable %d is reserved (default, main, local)", p.Table)
+	}
+	if p.Enabled() && p.FwMarkBase == 0 {
+		return fmt.Errorf("bridge: config: RouteFwMarkBase must be non-zero when RouteTable is set")
+	}
+	if p.RulePriority < 0 || p.RulePriority > 32765 {
+		return fmt.Errorf("bridge: config: RouteRulePriority must be between 0 and 32765")
+	}
+	return nil
+}
+
+// RouteTableFlusher is implemented by route controllers that place routes in
+// a dedicated routing table. FlushRouteTable removes every route in the
+// table together with the ip rules and fwmarks that select it.
+// Idempotent: flushing an empty or missing table returns nil.
+type RouteTableFlusher interface {
+	FlushRouteTable() error
+}
@@ -0,0 +1,213 @@
+//go:build linux
+
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/google/nftables/expr"
+	"github.com/vishvananda/netlink"
+)
+
+// markTableName is the nftables table used to tag traffic entering
+// plexd-managed interfaces with their fwmark.
+const markTableName = "plexd-mark"
+
+// markChainName is the prerouting chain inside markTableName.
+const markChainName = "prerouting"
+
+// fwmarkMask restricts rule matching to the bits plexd sets.
+const fwmarkMask = 0xffffffff
+
+// routeTable returns the routing table for new routes; zero selects main.
+func (c *NetlinkRouteController) routeTable() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.policy.Table
+}
+
+// ensureInterfaceMark assigns iface a fwmark, installs an nftables rule that
+// marks traffic arriving on iface, and adds an ip rule routing marked traffic
+// via the dedicated table. It is a no-op when policy routing is disabled. If
+// the interface already has a mark, only the ip rule is re-added, so that a
+// rule deleted outside plexd is restored.
+func (c *NetlinkRouteController) ensureInterfaceMark(iface string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.policy.Enabled() {
+		return nil
+	}
+	if c.marks == nil {
+		c.marks = make(map[string]uint32)
+	}
+	if mark, ok := c.marks[iface]; ok {
+		return c.addIPRule(mark)
+	}
+
+	mark := c.policy.FwMarkBase + uint32(len(c.marks))
+
+	if err := c.addMarkRule(iface, mark); err != nil {
+		return fmt.Errorf("mark interface %q: %w", iface, err)
+	}
+	if err := c.addIPRule(mark); err != nil {
+		return err
+	}
+
+	c.marks[iface] = mark
+
+	c.logger.Debug("policy routing mark installed",
+		"component", "bridge",
+		"interface", iface,
+		"fwmark", mark,
+		"table", c.policy.Table,
+	)
+	return nil
+}
+
+// addIPRule adds the ip rule routing traffic with mark via the dedicated
+// table. Idempotent: an existing rule is left as it is. c.mu must be held.
+func (c *NetlinkRouteController) addIPRule(mark uint32) error {
+	mask := uint32(fwmarkMask)
+	rule := netlink.NewRule()
+	rule.Family = netlink.FAMILY_V4
+	rule.Mark = mark
+	rule.Mask = &mask
+	rule.Table = c.policy.Table
+	rule.Priority = c.policy.RulePriority
+	if err := netlink.RuleAdd(rule); err != nil && !errors.Is(err, syscall.EEXIST) {
+		return fmt.Errorf("add ip rule fwmark %#x table %d: %w", mark, c.policy.Table, err)
+	}
+	return nil
+}
+
+// ipRuleInstalled reports whether the ip rule for iface's mark exists. It
+// returns true when policy routing is disabled or iface has no mark.
+func (c *NetlinkRouteController) ipRuleInstalled(iface string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	mark, ok := c.marks[iface]
+	if !c.policy.Enabled() || !ok {
+		return true, nil
+	}
+	rules, err := netlink.RuleListFiltered(netlink.FAMILY_V4,
+		&netlink.Rule{Table: c.policy.Table, Mark: mark},
+		netlink.RT_FILTER_TABLE|netlink.RT_FILTER_MARK)
+	if err != nil {
+		return false, fmt.Errorf("list rules for table %d: %w", c.policy.Table, err)
+	}
+	return len(rules) > 0, nil
+}
+
+// addMarkRule appends `iifname <iface> meta mark set <mark>` to the plexd
+// prerouting mangle chain, creating the table and chain if needed.
+func (c *NetlinkRouteController) addMarkRule(iface string, mark uint32) error {
+	conn, err := nftables.New()
+	if err != nil {
+		return err
+	}
+
+	table := conn.AddTable(&nftables.Table{
+		Family: nftables.TableFamilyIPv4,
+		Name:   markTableName,
+	})
+	chain := conn.AddChain(&nftables.Chain{
+		Name:     markChainName,
+		Table:    table,
+		Type:     nftables.ChainTypeFilter,
+		Hooknum:  nftables.ChainHookPrerouting,
+		Priority: nftables.ChainPriorityMangle,
+	})
+	conn.AddRule(&nftables.Rule{
+		Table: table,
+		Chain: chain,
+		Exprs: []expr.Any{
+			&expr.Meta{Key: expr.MetaKeyIIFNAME, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: ifaceNameBytes(iface)},
+			&expr.Immediate{Register: 1, Data: binaryutil.NativeEndian.PutUint32(mark)},
+			&expr.Meta{Key: expr.MetaKeyMARK, SourceRegister: true, Register: 1},
+		},
+	})
+	return conn.Flush()
+}
+
+// FlushRouteTable removes every route in the dedicated routing table, the ip
+// rules selecting it, and the plexd-mark nftables table.
+// Idempotent: returns nil when policy routing is disabled or nothing is installed.
+func (c *NetlinkRouteController) FlushRouteTable() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.policy.Enabled() {
+		return nil
+	}
+
+	var errs []error
+
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4,
+		&netlink.Route{Table: c.policy.Table}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("list routes in table %d: %w", c.policy.Table, err))
+	}
+	for i := range routes {
+		if err := netlink.RouteDel(&routes[i]); err != nil && !errors.Is(err, syscall.ESRCH) {
+			errs = append(errs, fmt.Errorf("delete route %s: %w", routes[i].Dst, err))
+		}
+	}
+
+	rules, err := netlink.RuleListFiltered(netlink.FAMILY_V4,
+		&netlink.Rule{Table: c.policy.Table}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("list rules for table %d: %w", c.policy.Table, err))
+	}
+	for i := range rules {
+		if err := netlink.RuleDel(&rules[i]); err != nil && !errors.Is(err, syscall.ENOENT) {
+			errs = append(errs, fmt.Errorf("delete rule %s: %w", rules[i], err))
+		}
+	}
+
+	if err := deleteNFTable(markTableName); err != nil {
+		errs = append(errs, err)
+	}
+
+	c.marks = nil
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("bridge: flush route table %d: %w", c.policy.Table, err)
+	}
+
+	c.logger.Debug("policy routing table flushed",
+		"component", "bridge",
+		"table", c.policy.Table,
+		"routes", len(routes),
+		"rules", len(rules),
+	)
+	return nil
+}
+
+// deleteNFTable deletes the named IPv4 nftables table if it exists.
+func deleteNFTable(name string) error {
+	conn, err := nftables.New()
+	if err != nil {
+		return fmt.Errorf("nftables: %w", err)
+	}
+	tables, err := conn.ListTablesOfFamily(nftables.TableFamilyIPv4)
+	if err != nil {
+		return fmt.Errorf("list nftables tables: %w", err)
+	}
+	for _, t := range tables {
+		if t.Name == name {
+			conn.DelTable(t)
+			if err := conn.Flush(); err != nil {
+				return fmt.Errorf("delete nftables table %q: %w", name, err)
+			}
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package bridge
+
+import (
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// errNoTrafficShaper is reported when the route controller cannot shape.
+const errNoTrafficShaper = "route controller does not support traffic shaping"
+
+// applyEgressRate limits egress on iface to rateKbps through routes and
+// returns the resulting status for target. A failure is recorded in the
+// status rather than returned: the interface keeps working unshaped.
+func applyEgressRate(routes RouteController, target, iface string, rateKbps int64) api.ShapingStatus {
+	st := api.ShapingStatus{Target: target, RateKbps: rateKbps}
+	shaper, ok := routes.(TrafficShaper)
+	if !ok {
+		st.Error = errNoTrafficShaper
+		return st
+	}
+	if err := shaper.SetEgressRate(iface, rateKbps); err != nil {
+		st.Error = err.Error()
+		return st
+	}
+	st.Active = true
+	return st
+}
+
+// minRelayBurst is the smallest burst of a relay rate limit. It admits the
+// largest datagram the relay reads, so no packet is rejected outright.
+const minRelayBurst = relayBufSize
+
+// tokenBucket limits relayed bytes to a rate with a burst of 100ms of
+// traffic, but at least minRelayBurst. It is not concurrent-safe.
+type tokenBucket struct {
+	rate   float64 // bytes per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a full bucket for rateKbps kbit/s.
+func newTokenBucket(rateKbps int64, now time.Time) *tokenBucket {
+	rate := float64(rateKbps) * 1000 / 8
+	burst := max(rate/10, minRelayBurst)
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: now}
+}
+
+// allow reports whether n bytes may pass at now and takes them from the
+// bucket if so.
+func (b *tokenBucket) allow(n int, now time.Time) bool {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+	if float64(n) > b.tokens {
+		return false
+	}
+	b.tokens -= float64(n)
+	return true
+}
@@ -0,0 +1,90 @@
+//go:build linux
+
+package bridge
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/vishvananda/netlink"
+)
+
+// shapingRootHandle is the handle of the HTB root qdisc installed by
+// SetEgressRate; shapingClassMinor is the minor number of its single class,
+// which is also the default class for all traffic.
+var shapingRootHandle = netlink.MakeHandle(1, 0)
+
+const shapingClassMinor = 1
+
+// SetEgressRate installs an HTB root qdisc on iface whose default class
+// limits all egress traffic to rateKbps kbit/s. An existing limit is replaced.
+func (c *NetlinkRouteController) SetEgressRate(iface string, rateKbps int64) error {
+	if rateKbps <= 0 {
+		return fmt.Errorf("bridge: set egress rate on %q: rate must be positive, got %d", iface, rateKbps)
+	}
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		return fmt.Errorf("bridge: set egress rate: lookup interface %q: %w", iface, err)
+	}
+	index := link.Attrs().Index
+
+	qdisc := netlink.NewHtb(netlink.QdiscAttrs{
+		LinkIndex: index,
+		Handle:    shapingRootHandle,
+		Parent:    netlink.HANDLE_ROOT,
+	})
+	qdisc.Defcls = shapingClassMinor
+	if err := netlink.QdiscReplace(qdisc); err != nil {
+		return fmt.Errorf("bridge: set egress rate on %q: replace qdisc: %w", iface, err)
+	}
+
+	bps := uint64(rateKbps) * 1000
+	class := netlink.NewHtbClass(netlink.ClassAttrs{
+		LinkIndex: index,
+		Parent:    shapingRootHandle,
+		Handle:    netlink.MakeHandle(1, shapingClassMinor),
+	}, netlink.HtbClassAttrs{Rate: bps, Ceil: bps})
+	if err := netlink.ClassReplace(class); err != nil {
+		return fmt.Errorf("bridge: set egress rate on %q: replace class: %w", iface, err)
+	}
+
+	c.logger.Debug("egress rate set",
+		"component", "bridge",
+		"interface", iface,
+		"rate_kbps", rateKbps,
+	)
+	return nil
+}
+
+// ClearEgressRate deletes the HTB root qdisc installed by SetEgressRate.
+// Other root qdiscs are left alone.
+// Idempotent: clearing an unshaped or missing interface returns nil.
+func (c *NetlinkRouteController) ClearEgressRate(iface string) error {
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		var notFound netlink.LinkNotFoundError
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return fmt.Errorf("bridge: clear egress rate: lookup interface %q: %w", iface, err)
+	}
+
+	qdiscs, err := netlink.QdiscList(link)
+	if err != nil {
+		return fmt.Errorf("bridge: clear egress rate on %q: list qdiscs: %w", iface, err)
+	}
+	for _, q := range qdiscs {
+		attrs := q.Attrs()
+		if attrs.Parent != netlink.HANDLE_ROOT || attrs.Handle != shapingRootHandle || q.Type() != "htb" {
+			continue
+		}
+		if err := netlink.QdiscDel(q); err != nil {
+			return fmt.Errorf("bridge: clear egress rate on %q: delete qdisc: %w", iface, err)
+		}
+		c.logger.Debug("egress rate cleared",
+			"component", "bridge",
+			"interface", iface,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package bridge
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestApplyEgressRate_Success(t *testing.T) {
+	routes := &mockShapingRouteController{}
+
+	st := applyEgressRate(routes, "tun-1", "wg-s2s0", 10000)
+
+	if !st.Active || st.Error != "" {
+		t.Errorf("status = %+v, want active without error", st)
+	}
+	if st.Target != "tun-1" || st.RateKbps != 10000 {
+		t.Errorf("status = %+v, want target tun-1 rate 10000", st)
+	}
+	calls := routes.callsFor("SetEgressRate")
+	if len(calls) != 1 || calls[0].Args[0] != "wg-s2s0" || calls[0].Args[1] != int64(10000) {
+		t.Errorf("SetEgressRate calls = %v, want [wg-s2s0 10000]", calls)
+	}
+}
+
+func TestApplyEgressRate_Error(t *testing.T) {
+	routes := &mockShapingRouteController{setEgressRateErr: errors.New("qdisc failed")}
+
+	st := applyEgressRate(routes, "tun-1", "wg-s2s0", 10000)
+
+	if st.Active {
+		t.Error("Active should be false after a failure")
+	}
+	if st.Error != "qdisc failed" {
+		t.Errorf("Error = %q, want %q", st.Error, "qdisc failed")
+	}
+}
+
+func TestApplyEgressRate_Unsupported(t *testing.T) {
+	st := applyEgressRate(&mockRouteController{}, "tun-1", "wg-s2s0", 10000)
+
+	if st.Active {
+		t.Error("Active should be false without a TrafficShaper")
+	}
+	if st.Error != errNoTrafficShaper {
+		t.Errorf("Error = %q, want %q", st.Error, errNoTrafficShaper)
+	}
+}
+
+func TestTokenBucket_BurstThenRefill(t *testing.T) {
+	now := time.Unix(0, 0)
+	// 8000 kbit/s = 1,000,000 bytes/s; burst is 100ms = 100,000 bytes.
+	b := newTokenBucket(8000, now)
+
+	if !b.allow(100000, now) {
+		t.Fatal("full burst should be allowed")
+	}
+	if b.allow(1, now) {
+		t.Fatal("empty bucket should reject")
+	}
+	now = now.Add(10 * time.Millisecond)
+	if !b.allow(10000, now) {
+		t.Error("10ms of refill should admit 10,000 bytes")
+	}
+	if b.allow(1000, now) {
+		t.Error("bucket should be empty again")
+	}
+	now = now.Add(time.Hour)
+	if b.allow(100001, now) {
+		t.Error("refill must not exceed the burst")
+	}
+}
+
+func TestTokenBucket_MinBurstAdmitsLargestDatagram(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTokenBucket(8, now)
+
+	if !b.allow(relayBufSize, now) {
+		t.Error("a maximum-size datagram should fit into a full bucket")
+	}
+}
@@ -1,29 +1,52 @@
 package bridge
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strconv"
 	"sync"
 
 	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/validation"
 )
 
+// ErrTunnelRecreate is returned by UpdateTunnel when a change cannot be
+// applied to the running interface and the tunnel must be removed and added.
+var ErrTunnelRecreate = errors.New("bridge: site-to-site: tunnel must be recreated")
+
 // activeTunnel holds the state of a running site-to-site tunnel.
 type activeTunnel struct {
 	tunnel api.SiteToSiteTunnel
 	iface  string
+	// ifID is the XFRM interface ID of an ipsec tunnel.
+	ifID uint32
+	// shaping is the egress limit status, or nil when the tunnel has none.
+	shaping *api.ShapingStatus
+	// l2 is the status of the layer-2 extension, or nil when the tunnel
+	// has none.
+	l2 *api.SiteToSiteL2Status
 }
 
-// SiteToSiteManager manages site-to-site VPN tunnels — WireGuard interfaces
+// SiteToSiteManager manages site-to-site VPN tunnels — WireGuard interfaces,
+// or XFRM interfaces of IKEv2/IPsec connections for tunnels of type ipsec,
 // that establish VPN connections to external networks via a bridge node.
 // SiteToSiteManager is concurrent-safe via mu.
 type SiteToSiteManager struct {
-	ctrl   VPNController
-	routes RouteController
-	cfg    Config
-	logger *slog.Logger
+	ctrl        VPNController
+	ipsec       IPsecController
+	l2          L2Controller
+	routes      RouteController
+	conntrack   ConntrackFlusher
+	cfg         Config
+	logger      *slog.Logger
+	portSources []PortSource
+
+	// protectedSources report the addresses remote subnets must not route
+	// away from the node.
+	protectedSources []ProtectedSource
 
 	// mu protects active, activeTunnels from concurrent access.
 	mu sync.Mutex
@@ -45,6 +68,71 @@ func NewSiteToSiteManager(ctrl VPNController, routes RouteController, cfg Config
 	}
 }
 
+// SetConntrackFlusher sets the flusher used to drop conntrack entries for the
+// remote subnets of a tunnel when the tunnel or some of its subnets are
+// removed. It must be called before Setup. When unset, no conntrack cleanup
+// is performed.
+func (m *SiteToSiteManager) SetConntrackFlusher(f ConntrackFlusher) {
+	m.conntrack = f
+}
+
+// SetPortSources sets sources of UDP ports in use, such as the
+// IngressManager, that tunnel listen ports must not take in addition to the
+// ports of the bridge listeners in the config. It must be called before
+// Setup.
+func (m *SiteToSiteManager) SetPortSources(srcs ...PortSource) {
+	m.portSources = srcs
+}
+
+// SetProtectedSources sets sources of addresses, such as ControlPlaneAddrs
+// and DefaultGateways, that the remote subnets of a tunnel must not capture
+// unless AllowFullTunnel is set. It must be called before Setup.
+func (m *SiteToSiteManager) SetProtectedSources(srcs ...ProtectedSource) {
+	m.protectedSources = srcs
+}
+
+// SetIPsecController sets the controller of tunnels of type ipsec. Without
+// one, ipsec tunnels are rejected. It must be called before Setup.
+func (m *SiteToSiteManager) SetIPsecController(ctrl IPsecController) {
+	m.ipsec = ctrl
+}
+
+// SetL2Controller sets the controller of layer-2 extensions. Without one,
+// layer-2 extensions are reported as inactive. It must be called before
+// Setup.
+func (m *SiteToSiteManager) SetL2Controller(ctrl L2Controller) {
+	m.l2 = ctrl
+}
+
+// ReservedPorts returns the listen ports of the active tunnels, the IKE
+// ports while ipsec tunnels are active, and the VXLAN port while tunnels
+// with a layer-2 extension are active.
+// It implements PortSource.
+func (m *SiteToSiteManager) ReservedPorts() []validation.ReservedPort {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ports []validation.ReservedPort
+	ipsec, l2 := false, false
+	for id, at := range m.activeTunnels {
+		if at.tunnel.ListenPort != 0 {
+			ports = append(ports, validation.ReservedPort{Port: at.tunnel.ListenPort, Owner: "site-to-site tunnel " + id})
+		}
+		ipsec = ipsec || isIPsec(at.tunnel)
+		l2 = l2 || at.l2 != nil
+	}
+	if ipsec {
+		ports = append(ports,
+			validation.ReservedPort{Port: ikePort, Owner: "IKE of ipsec site-to-site tunnels"},
+			validation.ReservedPort{Port: ikeNATTPort, Owner: "IKE of ipsec site-to-site tunnels"},
+		)
+	}
+	if l2 {
+		ports = append(ports, validation.ReservedPort{Port: VXLANPort, Owner: "VXLAN of site-to-site layer-2 extensions"})
+	}
+	return ports
+}
+
 // Setup initializes the site-to-site manager with the given mesh interface.
 // When site-to-site is disabled this is a no-op.
 func (m *SiteToSiteManager) Setup(meshIface string) error {
@@ -81,20 +169,43 @@ func (m *SiteToSiteManager) Teardown() error {
 	var errs []error
 
 	for id, at := range m.activeTunnels {
+		// Detach the remote site from the local bridge.
+		if err := m.removeL2(at); err != nil {
+			errs = append(errs, fmt.Errorf("bridge: site-to-site: remove layer-2 extension for tunnel %s: %w", id, err))
+		}
 		// Remove routes for remote subnets.
 		for _, subnet := range at.tunnel.RemoteSubnets {
 			if err := m.routes.RemoveRoute(subnet, at.iface); err != nil {
 				errs = append(errs, fmt.Errorf("bridge: site-to-site: remove route %s for tunnel %s: %w", subnet, id, err))
 			}
 		}
+		// Remove the NAT map.
+		if err := m.removeNATMap(at); err != nil {
+			errs = append(errs, fmt.Errorf("bridge: site-to-site: remove NAT map for tunnel %s: %w", id, err))
+		}
+		// Remove the MSS clamp.
+		if err := clearMSSClamp(m.routes, &m.cfg, at.iface); err != nil {
+			errs = append(errs, fmt.Errorf("bridge: site-to-site: clear MSS clamp for tunnel %s: %w", id, err))
+		}
 		// Disable forwarding between tunnel and mesh interfaces.
 		if err := m.routes.DisableForwarding(at.iface, m.meshIface); err != nil {
 			errs = append(errs, fmt.Errorf("bridge: site-to-site: disable forwarding for tunnel %s: %w", id, err))
 		}
+		// A WireGuard peer goes with its interface, but charon keeps an
+		// IPsec connection until it is unloaded.
+		if isIPsec(at.tunnel) {
+			if err := m.removePeer(at, at.tunnel); err != nil {
+				errs = append(errs, fmt.Errorf("bridge: site-to-site: unload connection for tunnel %s: %w", id, err))
+			}
+		}
 		// Remove the tunnel interface.
-		if err := m.ctrl.RemoveTunnelInterface(at.iface); err != nil {
+		if err := m.removeInterface(at); err != nil {
 			errs = append(errs, fmt.Errorf("bridge: site-to-site: remove interface for tunnel %s: %w", id, err))
 		}
+		// Drop tracked flows so established connections stop immediately.
+		for _, err := range m.flushConntrack(at.tunnel.RemoteSubnets) {
+			errs = append(errs, fmt.Errorf("bridge: site-to-site: flush conntrack for tunnel %s: %w", id, err))
+		}
 	}
 
 	m.active = false
@@ -109,9 +220,25 @@ func (m *SiteToSiteManager) Teardown() error {
 
 // AddTunnel establishes a site-to-site tunnel: creates a WireGuard interface,
 // configures the remote peer, enables forwarding, and adds routes for remote subnets.
+// A tunnel of type ipsec gets an XFRM interface and an IKEv2 connection
+// loaded through the IPsec controller instead of the WireGuard interface and
+// peer.
+// When EgressRateKbps is set, egress on the interface is limited; a limit that
+// cannot be applied is logged and reported in SiteToSiteStatus but does not
+// fail the tunnel. When L2 is set, a VXLAN interface joins the local bridge;
+// like the egress limit, it does not fail the tunnel. When NATMap is set, the
+// local subnet is translated on the interface by the route controller, which
+// must implement SubnetMapper.
 // Returns an error if the manager is inactive, the tunnel ID already exists,
-// or the maximum tunnel count is reached.
+// the maximum tunnel count is reached, the tunnel fails validation against
+// the active tunnels and reserved ports (see validation.SiteToSiteTunnel),
+// a remote subnet would replace the default route or capture a protected
+// address (see validation.SiteToSiteRoutes), the egress rate is negative, or
+// the NAT map is invalid or unsupported.
 func (m *SiteToSiteManager) AddTunnel(tunnel api.SiteToSiteTunnel) error {
+	reserved := reservedPorts(&m.cfg, m.portSources)
+	protected := protectedAddrs(m.protectedSources)
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -125,29 +252,55 @@ func (m *SiteToSiteManager) AddTunnel(tunnel api.SiteToSiteTunnel) error {
 	if len(m.activeTunnels) >= m.cfg.MaxSiteToSiteTunnels {
 		return fmt.Errorf("bridge: site-to-site: max tunnels reached (%d)", m.cfg.MaxSiteToSiteTunnels)
 	}
+	mapper, err := m.checkTunnel(tunnel, reserved, protected)
+	if err != nil {
+		return err
+	}
 
 	iface := tunnel.InterfaceName
+	at := &activeTunnel{
+		tunnel: tunnel,
+		iface:  iface,
+	}
+	if isIPsec(tunnel) {
+		at.ifID = m.nextIfID()
+	}
 
-	// Create the WireGuard interface.
-	if err := m.ctrl.CreateTunnelInterface(iface, tunnel.ListenPort); err != nil {
+	// Create the tunnel interface.
+	if err := m.createInterface(at); err != nil {
 		return fmt.Errorf("bridge: site-to-site: create interface for tunnel %s: %w", tunnel.TunnelID, err)
 	}
 
 	// Configure the remote peer.
-	if err := m.ctrl.ConfigureTunnelPeer(iface, tunnel.RemotePublicKey, tunnel.RemoteSubnets, tunnel.RemoteEndpoint, tunnel.PSK); err != nil {
-		// Rollback: remove the interface.
-		_ = m.ctrl.RemoveTunnelInterface(iface)
+	if err := m.configurePeer(at, tunnel); err != nil {
+		// Rollback: unload a partially loaded IPsec connection, and remove
+		// the interface.
+		if isIPsec(tunnel) {
+			_ = m.removePeer(at, tunnel)
+		}
+		_ = m.removeInterface(at)
 		return fmt.Errorf("bridge: site-to-site: configure peer for tunnel %s: %w", tunnel.TunnelID, err)
 	}
 
 	// Enable forwarding between tunnel and mesh interfaces.
 	if err := m.routes.EnableForwarding(iface, m.meshIface); err != nil {
 		// Rollback: remove peer and interface.
-		_ = m.ctrl.RemoveTunnelPeer(iface, tunnel.RemotePublicKey)
-		_ = m.ctrl.RemoveTunnelInterface(iface)
+		_ = m.removePeer(at, tunnel)
+		_ = m.removeInterface(at)
 		return fmt.Errorf("bridge: site-to-site: enable forwarding for tunnel %s: %w", tunnel.TunnelID, err)
 	}
 
+	// Translate the local subnet on the tunnel interface.
+	if mapper != nil {
+		if err := mapper.AddSubnetMap(iface, tunnel.NATMap.Local, tunnel.NATMap.As); err != nil {
+			// Rollback: forwarding, peer, and interface.
+			_ = m.routes.DisableForwarding(iface, m.meshIface)
+			_ = m.removePeer(at, tunnel)
+			_ = m.removeInterface(at)
+			return fmt.Errorf("bridge: site-to-site: add NAT map for tunnel %s: %w", tunnel.TunnelID, err)
+		}
+	}
+
 	// Add routes for remote subnets.
 	var addedRoutes []string
 	for _, subnet := range tunnel.RemoteSubnets {
@@ -156,20 +309,41 @@ func (m *SiteToSiteManager) AddTunnel(tunnel api.SiteToSiteTunnel) error {
 			for _, added := range addedRoutes {
 				_ = m.routes.RemoveRoute(added, iface)
 			}
-			// Rollback forwarding, peer, and interface.
+			// Rollback NAT map, forwarding, peer, and interface.
+			if mapper != nil {
+				_ = mapper.RemoveSubnetMap(iface, tunnel.NATMap.Local, tunnel.NATMap.As)
+			}
 			_ = m.routes.DisableForwarding(iface, m.meshIface)
-			_ = m.ctrl.RemoveTunnelPeer(iface, tunnel.RemotePublicKey)
-			_ = m.ctrl.RemoveTunnelInterface(iface)
+			_ = m.removePeer(at, tunnel)
+			_ = m.removeInterface(at)
 			return fmt.Errorf("bridge: site-to-site: add route %s for tunnel %s: %w", subnet, tunnel.TunnelID, err)
 		}
 		addedRoutes = append(addedRoutes, subnet)
 	}
 
-	m.activeTunnels[tunnel.TunnelID] = &activeTunnel{
-		tunnel: tunnel,
-		iface:  iface,
+	if err := applyMSSClamp(m.routes, &m.cfg, iface); err != nil {
+		m.logger.Warn("bridge: site-to-site: MSS clamp not applied",
+			"tunnel_id", tunnel.TunnelID,
+			"error", err,
+		)
 	}
 
+	if tunnel.EgressRateKbps > 0 {
+		st := applyEgressRate(m.routes, tunnel.TunnelID, iface, tunnel.EgressRateKbps)
+		if st.Error != "" {
+			m.logger.Warn("bridge: site-to-site: egress rate not applied",
+				"tunnel_id", tunnel.TunnelID,
+				"rate_kbps", tunnel.EgressRateKbps,
+				"error", st.Error,
+			)
+		}
+		at.shaping = &st
+	}
+	if tunnel.L2 != nil {
+		m.updateL2(at, tunnel)
+	}
+	m.activeTunnels[tunnel.TunnelID] = at
+
 	m.logger.Info("site-to-site tunnel added",
 		"tunnel_id", tunnel.TunnelID,
 		"interface", iface,
@@ -180,8 +354,352 @@ func (m *SiteToSiteManager) AddTunnel(tunnel api.SiteToSiteTunnel) error {
 	return nil
 }
 
-// RemoveTunnel removes a site-to-site tunnel: removes routes, disables forwarding,
-// removes the peer, and removes the interface.
+// checkTunnel validates tunnel against the other active tunnels, the
+// reserved ports, and unless AllowFullTunnel is set the protected addresses,
+// checks its egress rate and NAT map, and that an IPsec controller is set
+// for an ipsec tunnel. It returns the SubnetMapper that
+// applies the NAT map, or nil when the tunnel has none. Caller must hold
+// m.mu.
+func (m *SiteToSiteManager) checkTunnel(tunnel api.SiteToSiteTunnel, reserved []validation.ReservedPort, protected []validation.ProtectedAddr) (SubnetMapper, error) {
+	existing := make([]api.SiteToSiteTunnel, 0, len(m.activeTunnels))
+	for _, at := range m.activeTunnels {
+		existing = append(existing, at.tunnel)
+	}
+	slices.SortFunc(existing, func(a, b api.SiteToSiteTunnel) int {
+		return cmp.Compare(a.TunnelID, b.TunnelID)
+	})
+	errs := validation.SiteToSiteTunnel(tunnel, existing, reserved)
+	if !m.cfg.AllowFullTunnel {
+		errs = append(errs, validation.SiteToSiteRoutes([]api.SiteToSiteTunnel{tunnel}, protected)...)
+	}
+	if err := errs.Err(); err != nil {
+		return nil, fmt.Errorf("bridge: site-to-site: %w", err)
+	}
+	if isIPsec(tunnel) && m.ipsec == nil {
+		return nil, fmt.Errorf("bridge: site-to-site: tunnel %s: %w", tunnel.TunnelID, errNoIPsecController)
+	}
+	if tunnel.EgressRateKbps < 0 {
+		return nil, fmt.Errorf("bridge: site-to-site: negative egress rate for tunnel %s: %d", tunnel.TunnelID, tunnel.EgressRateKbps)
+	}
+	if tunnel.NATMap == nil {
+		return nil, nil
+	}
+	if _, _, err := parseNATMap(*tunnel.NATMap); err != nil {
+		return nil, fmt.Errorf("bridge: site-to-site: invalid NAT map for tunnel %s: %w", tunnel.TunnelID, err)
+	}
+	mapper, ok := m.routes.(SubnetMapper)
+	if !ok {
+		return nil, fmt.Errorf("bridge: site-to-site: tunnel %s: %w", tunnel.TunnelID, errNoSubnetMapper)
+	}
+	return mapper, nil
+}
+
+// nextIfID returns the lowest XFRM interface ID from ipsecIfIDBase that no
+// active tunnel uses. Caller must hold m.mu.
+func (m *SiteToSiteManager) nextIfID() uint32 {
+	used := make(map[uint32]bool, len(m.activeTunnels))
+	for _, at := range m.activeTunnels {
+		used[at.ifID] = true
+	}
+	id := uint32(ipsecIfIDBase)
+	for used[id] {
+		id++
+	}
+	return id
+}
+
+// createInterface creates the interface of at: a WireGuard interface, or an
+// XFRM interface for an ipsec tunnel. Caller must hold m.mu.
+func (m *SiteToSiteManager) createInterface(at *activeTunnel) error {
+	if isIPsec(at.tunnel) {
+		return m.ipsec.CreateIPsecInterface(at.iface, at.ifID)
+	}
+	return m.ctrl.CreateTunnelInterface(at.iface, at.tunnel.ListenPort)
+}
+
+// removeInterface removes the interface of at. Caller must hold m.mu.
+func (m *SiteToSiteManager) removeInterface(at *activeTunnel) error {
+	if isIPsec(at.tunnel) {
+		return m.ipsec.RemoveIPsecInterface(at.iface)
+	}
+	return m.ctrl.RemoveTunnelInterface(at.iface)
+}
+
+// configurePeer configures the remote site of tunnel on the interface of
+// at: the WireGuard peer, or the IPsec connection of an ipsec tunnel.
+// Caller must hold m.mu.
+func (m *SiteToSiteManager) configurePeer(at *activeTunnel, tunnel api.SiteToSiteTunnel) error {
+	if isIPsec(tunnel) {
+		return m.ipsec.LoadIPsecConn(ipsecConn(tunnel, at.ifID))
+	}
+	return m.ctrl.ConfigureTunnelPeer(at.iface, tunnel.RemotePublicKey, tunnel.RemoteSubnets, tunnel.RemoteEndpoint, tunnel.PSK)
+}
+
+// removePeer removes the remote site of tunnel from the interface of at.
+// Caller must hold m.mu.
+func (m *SiteToSiteManager) removePeer(at *activeTunnel, tunnel api.SiteToSiteTunnel) error {
+	if isIPsec(tunnel) {
+		return m.ipsec.UnloadIPsecConn(ipsecConnName(tunnel.TunnelID))
+	}
+	return m.ctrl.RemoveTunnelPeer(at.iface, tunnel.RemotePublicKey)
+}
+
+// UpdateTunnel applies a changed definition to an active tunnel without
+// recreating its interface, so traffic keeps flowing: the remote peer is
+// reconfigured for a new endpoint, public key, PSK, or remote subnets, routes
+// are added and removed by the subnet difference, and the NAT map and egress
+// rate are replaced when they differ. A rotated public key is configured
+// before the old peer is removed; a PSK that is dropped rather than rotated
+// re-adds the peer. Conntrack entries of subnets that are no longer routed
+// are flushed, and those of all old remote subnets when a NAT map is
+// replaced or dropped. The IPsec connection of an ipsec tunnel is reloaded when its
+// endpoint, PSK, traffic selectors, or NAT map change, which re-establishes
+// its SAs. A changed layer-2 extension recreates the VXLAN interface; a
+// changed MAC limit alone is applied by the next CheckL2.
+//
+// A change of Type, InterfaceName, or ListenPort cannot be applied in place
+// and returns ErrTunnelRecreate; the caller removes and re-adds the tunnel.
+// On any other error the previous definition stays in effect.
+func (m *SiteToSiteManager) UpdateTunnel(tunnel api.SiteToSiteTunnel) error {
+	reserved := reservedPorts(&m.cfg, m.portSources)
+	protected := protectedAddrs(m.protectedSources)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.active {
+		return fmt.Errorf("bridge: site-to-site: manager is not active")
+	}
+	at, ok := m.activeTunnels[tunnel.TunnelID]
+	if !ok {
+		return fmt.Errorf("bridge: site-to-site: tunnel not found: %s", tunnel.TunnelID)
+	}
+	old := at.tunnel
+	if isIPsec(tunnel) != isIPsec(old) || tunnel.InterfaceName != old.InterfaceName || tunnel.ListenPort != old.ListenPort {
+		return fmt.Errorf("bridge: site-to-site: update tunnel %s: %w", tunnel.TunnelID, ErrTunnelRecreate)
+	}
+	mapper, err := m.checkTunnel(tunnel, reserved, protected)
+	if err != nil {
+		return err
+	}
+	iface := at.iface
+
+	// Reconfigure the remote peer. WireGuard keeps the session of an
+	// existing peer, so only a dropped PSK needs the peer re-added. IPsec
+	// tunnels have no public key and always a PSK, but their traffic
+	// selectors include the local subnets.
+	ipsec := isIPsec(tunnel)
+	keyChanged := !ipsec && tunnel.RemotePublicKey != old.RemotePublicKey
+	pskDropped := !ipsec && !keyChanged && old.PSK != "" && tunnel.PSK == ""
+	peerChanged := keyChanged || tunnel.RemoteEndpoint != old.RemoteEndpoint ||
+		tunnel.PSK != old.PSK || !slices.Equal(tunnel.RemoteSubnets, old.RemoteSubnets) ||
+		ipsec && (!slices.Equal(tunnel.LocalSubnets, old.LocalSubnets) || !natMapEqual(tunnel.NATMap, old.NATMap))
+	if pskDropped {
+		if err := m.ctrl.RemoveTunnelPeer(iface, old.RemotePublicKey); err != nil {
+			return fmt.Errorf("bridge: site-to-site: remove peer for tunnel %s: %w", tunnel.TunnelID, err)
+		}
+	}
+	if peerChanged {
+		if err := m.configurePeer(at, tunnel); err != nil {
+			// A failed load may have replaced the pre-shared key of an
+			// IPsec connection already.
+			if pskDropped || ipsec {
+				_ = m.configurePeer(at, old)
+			}
+			return fmt.Errorf("bridge: site-to-site: configure peer for tunnel %s: %w", tunnel.TunnelID, err)
+		}
+	}
+	// restorePeer rolls the peer back to the previous definition.
+	restorePeer := func() {
+		if !peerChanged {
+			return
+		}
+		if keyChanged {
+			_ = m.ctrl.RemoveTunnelPeer(iface, tunnel.RemotePublicKey)
+		}
+		_ = m.configurePeer(at, old)
+	}
+
+	// Add routes for new remote subnets.
+	addSubnets := subnetsMissing(tunnel.RemoteSubnets, old.RemoteSubnets)
+	removeSubnets := subnetsMissing(old.RemoteSubnets, tunnel.RemoteSubnets)
+	var addedRoutes []string
+	for _, subnet := range addSubnets {
+		if err := m.routes.AddRoute(subnet, iface); err != nil {
+			for _, added := range addedRoutes {
+				_ = m.routes.RemoveRoute(added, iface)
+			}
+			restorePeer()
+			return fmt.Errorf("bridge: site-to-site: add route %s for tunnel %s: %w", subnet, tunnel.TunnelID, err)
+		}
+		addedRoutes = append(addedRoutes, subnet)
+	}
+	rollbackRoutes := func() {
+		for _, added := range addedRoutes {
+			_ = m.routes.RemoveRoute(added, iface)
+		}
+		restorePeer()
+	}
+
+	// Replace the NAT map.
+	natChanged := !natMapEqual(old.NATMap, tunnel.NATMap)
+	if natChanged {
+		if err := m.removeNATMap(at); err != nil {
+			rollbackRoutes()
+			return fmt.Errorf("bridge: site-to-site: remove NAT map for tunnel %s: %w", tunnel.TunnelID, err)
+		}
+		if mapper != nil {
+			if err := mapper.AddSubnetMap(iface, tunnel.NATMap.Local, tunnel.NATMap.As); err != nil {
+				if nm := old.NATMap; nm != nil {
+					if oldMapper, ok := m.routes.(SubnetMapper); ok {
+						_ = oldMapper.AddSubnetMap(iface, nm.Local, nm.As)
+					}
+				}
+				rollbackRoutes()
+				return fmt.Errorf("bridge: site-to-site: add NAT map for tunnel %s: %w", tunnel.TunnelID, err)
+			}
+		}
+	}
+
+	// The new definition is in effect; clean up what it no longer uses.
+	for _, subnet := range removeSubnets {
+		if err := m.routes.RemoveRoute(subnet, iface); err != nil {
+			m.logger.Error("bridge: site-to-site: remove route failed",
+				"tunnel_id", tunnel.TunnelID,
+				"subnet", subnet,
+				"error", err,
+			)
+		}
+	}
+	if keyChanged {
+		if err := m.ctrl.RemoveTunnelPeer(iface, old.RemotePublicKey); err != nil {
+			m.logger.Error("bridge: site-to-site: remove peer failed",
+				"tunnel_id", tunnel.TunnelID,
+				"error", err,
+			)
+		}
+	}
+	if tunnel.EgressRateKbps != old.EgressRateKbps {
+		m.updateEgressRate(at, tunnel)
+	}
+	switch {
+	case !l2Equal(old.L2, tunnel.L2):
+		m.updateL2(at, tunnel)
+	case at.l2 != nil:
+		at.l2.MaxMACs = m.cfg.l2MaxMACs(tunnel.L2)
+	}
+	// Flows translated by the old NAT map run to any of the old remote
+	// subnets.
+	stale := removeSubnets
+	if natChanged && old.NATMap != nil {
+		stale = old.RemoteSubnets
+	}
+	for _, err := range m.flushConntrack(stale) {
+		m.logger.Error("bridge: site-to-site: flush conntrack failed",
+			"tunnel_id", tunnel.TunnelID,
+			"error", err,
+		)
+	}
+
+	at.tunnel = tunnel
+
+	m.logger.Info("site-to-site tunnel updated",
+		"tunnel_id", tunnel.TunnelID,
+		"interface", iface,
+		"remote_endpoint", tunnel.RemoteEndpoint,
+		"remote_subnets", tunnel.RemoteSubnets,
+		"peer_changed", peerChanged,
+		"nat_map_changed", natChanged,
+	)
+
+	return nil
+}
+
+// updateL2 replaces the layer-2 extension of at with that of tunnel. Like in
+// AddTunnel, a failure is logged and reported in SiteToSiteStatus. Caller
+// must hold m.mu.
+func (m *SiteToSiteManager) updateL2(at *activeTunnel, tunnel api.SiteToSiteTunnel) {
+	if err := m.removeL2(at); err != nil {
+		m.logger.Error("bridge: site-to-site: remove layer-2 extension failed",
+			"tunnel_id", tunnel.TunnelID,
+			"error", err,
+		)
+	}
+	at.l2 = nil
+	if tunnel.L2 == nil {
+		return
+	}
+	st := applyL2(m.l2, &m.cfg, tunnel, at.iface)
+	if st.Error != "" {
+		m.logger.Warn("bridge: site-to-site: layer-2 extension not applied",
+			"tunnel_id", tunnel.TunnelID,
+			"vni", st.VNI,
+			"bridge", st.Bridge,
+			"error", st.Error,
+		)
+	}
+	at.l2 = st
+}
+
+// removeL2 removes the VXLAN interface of the layer-2 extension of at, if
+// it was created. Caller must hold m.mu.
+func (m *SiteToSiteManager) removeL2(at *activeTunnel) error {
+	if at.l2 == nil || !at.l2.Active {
+		return nil
+	}
+	return m.l2.RemoveL2Interface(at.l2.Interface)
+}
+
+// updateEgressRate applies the egress rate of tunnel to at, clearing the
+// limit when the rate is 0. Like in AddTunnel, a failure is logged and
+// reported in SiteToSiteStatus. Caller must hold m.mu.
+func (m *SiteToSiteManager) updateEgressRate(at *activeTunnel, tunnel api.SiteToSiteTunnel) {
+	if tunnel.EgressRateKbps == 0 {
+		if shaper, ok := m.routes.(TrafficShaper); ok && at.shaping != nil && at.shaping.Active {
+			if err := shaper.ClearEgressRate(at.iface); err != nil {
+				m.logger.Warn("bridge: site-to-site: clear egress rate failed",
+					"tunnel_id", tunnel.TunnelID,
+					"error", err,
+				)
+				return
+			}
+		}
+		at.shaping = nil
+		return
+	}
+	st := applyEgressRate(m.routes, tunnel.TunnelID, at.iface, tunnel.EgressRateKbps)
+	if st.Error != "" {
+		m.logger.Warn("bridge: site-to-site: egress rate not applied",
+			"tunnel_id", tunnel.TunnelID,
+			"rate_kbps", tunnel.EgressRateKbps,
+			"error", st.Error,
+		)
+	}
+	at.shaping = &st
+}
+
+// subnetsMissing returns the subnets in a that are not in b.
+func subnetsMissing(a, b []string) []string {
+	var missing []string
+	for _, s := range a {
+		if !slices.Contains(b, s) {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
+
+// natMapEqual reports whether two NAT maps, either of which may be nil, are
+// the same.
+func natMapEqual(a, b *api.SiteToSiteNATMap) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// RemoveTunnel removes a site-to-site tunnel: removes the layer-2 extension
+// and routes, disables forwarding, removes the peer, and removes the interface.
 // Removing a non-existent tunnel or calling on an inactive manager is a no-op.
 func (m *SiteToSiteManager) RemoveTunnel(tunnelID string) {
 	m.mu.Lock()
@@ -195,6 +713,14 @@ func (m *SiteToSiteManager) RemoveTunnel(tunnelID string) {
 		return
 	}
 
+	// Detach the remote site from the local bridge.
+	if err := m.removeL2(at); err != nil {
+		m.logger.Error("bridge: site-to-site: remove layer-2 extension failed",
+			"tunnel_id", tunnelID,
+			"error", err,
+		)
+	}
+
 	// Remove routes for remote subnets.
 	for _, subnet := range at.tunnel.RemoteSubnets {
 		if err := m.routes.RemoveRoute(subnet, at.iface); err != nil {
@@ -206,6 +732,22 @@ func (m *SiteToSiteManager) RemoveTunnel(tunnelID string) {
 		}
 	}
 
+	// Remove the NAT map.
+	if err := m.removeNATMap(at); err != nil {
+		m.logger.Error("bridge: site-to-site: remove NAT map failed",
+			"tunnel_id", tunnelID,
+			"error", err,
+		)
+	}
+
+	// Remove the MSS clamp.
+	if err := clearMSSClamp(m.routes, &m.cfg, at.iface); err != nil {
+		m.logger.Error("bridge: site-to-site: clear MSS clamp failed",
+			"tunnel_id", tunnelID,
+			"error", err,
+		)
+	}
+
 	// Disable forwarding between tunnel and mesh interfaces.
 	if err := m.routes.DisableForwarding(at.iface, m.meshIface); err != nil {
 		m.logger.Error("bridge: site-to-site: disable forwarding failed",
@@ -215,19 +757,27 @@ func (m *SiteToSiteManager) RemoveTunnel(tunnelID string) {
 	}
 
 	// Remove the tunnel peer and interface.
-	if err := m.ctrl.RemoveTunnelPeer(at.iface, at.tunnel.RemotePublicKey); err != nil {
+	if err := m.removePeer(at, at.tunnel); err != nil {
 		m.logger.Error("bridge: site-to-site: remove peer failed",
 			"tunnel_id", tunnelID,
 			"error", err,
 		)
 	}
-	if err := m.ctrl.RemoveTunnelInterface(at.iface); err != nil {
+	if err := m.removeInterface(at); err != nil {
 		m.logger.Error("bridge: site-to-site: remove interface failed",
 			"tunnel_id", tunnelID,
 			"error", err,
 		)
 	}
 
+	// Drop tracked flows so established connections stop immediately.
+	for _, err := range m.flushConntrack(at.tunnel.RemoteSubnets) {
+		m.logger.Error("bridge: site-to-site: flush conntrack failed",
+			"tunnel_id", tunnelID,
+			"error", err,
+		)
+	}
+
 	delete(m.activeTunnels, tunnelID)
 
 	m.logger.Info("site-to-site tunnel removed",
@@ -235,6 +785,37 @@ func (m *SiteToSiteManager) RemoveTunnel(tunnelID string) {
 	)
 }
 
+// removeNATMap removes the NAT map of at, if any. Caller must hold m.mu.
+func (m *SiteToSiteManager) removeNATMap(at *activeTunnel) error {
+	nm := at.tunnel.NATMap
+	if nm == nil {
+		return nil
+	}
+	mapper, ok := m.routes.(SubnetMapper)
+	if !ok {
+		return nil
+	}
+	return mapper.RemoveSubnetMap(at.iface, nm.Local, nm.As)
+}
+
+// flushConntrack deletes conntrack entries to or from the given remote
+// subnets of a tunnel. Every flow through the tunnel has a remote end, also
+// when the local subnet is mapped, so flows of the local subnets with other
+// peers are kept. It is a no-op when no ConntrackFlusher is set.
+// Caller must hold m.mu.
+func (m *SiteToSiteManager) flushConntrack(remoteSubnets []string) []error {
+	if m.conntrack == nil {
+		return nil
+	}
+	var errs []error
+	for _, subnet := range remoteSubnets {
+		if err := m.conntrack.FlushConntrackSubnet(subnet); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
 // GetTunnel returns the SiteToSiteTunnel for the given ID and true if it exists,
 // or a zero value and false otherwise.
 func (m *SiteToSiteManager) GetTunnel(tunnelID string) (api.SiteToSiteTunnel, bool) {
@@ -260,29 +841,108 @@ func (m *SiteToSiteManager) TunnelIDs() []string {
 	return ids
 }
 
-// SiteToSiteStatus returns site-to-site status for heartbeat reporting.
-// Returns nil when site-to-site is not active.
+// SiteToSiteStatus returns site-to-site status for heartbeat reporting,
+// including the egress limits of shaped tunnels and the traffic of every
+// tunnel, both sorted by tunnel ID. The traffic of ipsec tunnels is read
+// through the IPsec controller, and the learned MAC addresses of layer-2
+// extensions through the L2 controller. Returns nil when site-to-site is not
+// active.
 func (m *SiteToSiteManager) SiteToSiteStatus() *api.SiteToSiteInfo {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	if !m.active {
+		m.mu.Unlock()
 		return nil
 	}
-	return &api.SiteToSiteInfo{
+	info := &api.SiteToSiteInfo{
 		Enabled:     true,
 		TunnelCount: len(m.activeTunnels),
 	}
+	peers := make(map[string]string, len(m.activeTunnels))
+	ipsec := make(map[string]bool)
+	for id, at := range m.activeTunnels {
+		if at.shaping != nil {
+			info.Shaping = append(info.Shaping, *at.shaping)
+		}
+		ts := api.SiteToSiteTunnelStatus{
+			TunnelID:  id,
+			Interface: at.iface,
+		}
+		if at.l2 != nil {
+			l2 := *at.l2
+			ts.L2 = &l2
+		}
+		info.Tunnels = append(info.Tunnels, ts)
+		peers[id] = at.tunnel.RemotePublicKey
+		if isIPsec(at.tunnel) {
+			ipsec[id] = true
+		}
+	}
+	m.mu.Unlock()
+
+	slices.SortFunc(info.Shaping, func(a, b api.ShapingStatus) int {
+		return cmp.Compare(a.Target, b.Target)
+	})
+	slices.SortFunc(info.Tunnels, func(a, b api.SiteToSiteTunnelStatus) int {
+		return cmp.Compare(a.TunnelID, b.TunnelID)
+	})
+
+	// The device is read without holding the lock; a tunnel removed in
+	// the meantime is reported with an error.
+	reader, ok := m.ctrl.(TunnelStatsReader)
+	ipsecReader, ipsecOK := m.ipsec.(IPsecStatsReader)
+	for i := range info.Tunnels {
+		ts := &info.Tunnels[i]
+		if l2 := ts.L2; l2 != nil && l2.Active {
+			if macs, err := m.l2.L2MACCount(l2.Interface); err != nil {
+				l2.Error = err.Error()
+			} else {
+				l2.MACs = macs
+			}
+		}
+		var stats TunnelPeerStats
+		var err error
+		switch {
+		case ipsec[ts.TunnelID] && !ipsecOK:
+			ts.Error = errNoIPsecStats
+			continue
+		case ipsec[ts.TunnelID]:
+			stats, err = ipsecReader.IPsecConnStats(ipsecConnName(ts.TunnelID))
+		case !ok:
+			ts.Error = errNoTunnelStats
+			continue
+		default:
+			stats, err = reader.TunnelPeerStats(ts.Interface, peers[ts.TunnelID])
+		}
+		if err != nil {
+			ts.Error = err.Error()
+			continue
+		}
+		ts.Endpoint = stats.Endpoint
+		ts.RxBytes = stats.RxBytes
+		ts.TxBytes = stats.TxBytes
+		if !stats.LastHandshake.IsZero() {
+			hs := stats.LastHandshake
+			ts.LastHandshake = &hs
+		}
+	}
+	return info
 }
 
-// SiteToSiteCapabilities returns capability metadata for registration.
-// Returns nil when site-to-site is not enabled.
+// SiteToSiteCapabilities returns capability metadata for registration,
+// including the supported tunnel types and whether layer-2 extensions are
+// supported. Returns nil when site-to-site is not enabled.
 func (m *SiteToSiteManager) SiteToSiteCapabilities() map[string]string {
 	if !m.cfg.SiteToSiteEnabled {
 		return nil
 	}
+	types := api.TunnelTypeWireGuard
+	if m.ipsec != nil {
+		types += "," + api.TunnelTypeIPsec
+	}
 	return map[string]string{
 		"site_to_site":             "true",
 		"max_site_to_site_tunnels": strconv.Itoa(m.cfg.MaxSiteToSiteTunnels),
+		"site_to_site_types":       types,
+		"site_to_site_l2":          strconv.FormatBool(m.l2 != nil),
 	}
 }
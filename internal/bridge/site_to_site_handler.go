@@ -10,6 +10,7 @@ import (
 
 	"github.com/plexsphere/plexd/internal/api"
 	"github.com/plexsphere/plexd/internal/reconcile"
+	"github.com/plexsphere/plexd/internal/validation"
 )
 
 // HandleSiteToSiteTunnelAssigned returns an api.EventHandler that adds a
@@ -66,13 +67,30 @@ func HandleSiteToSiteConfigUpdated(trigger ReconcileTrigger) api.EventHandler {
 // SiteToSiteReconcileHandler returns a reconcile.ReconcileHandler that updates
 // site-to-site tunnels when the desired SiteToSiteConfig changes. It diffs the
 // desired tunnels against the currently active tunnels: adding missing tunnels,
-// removing stale tunnels, and restarting changed tunnels (same ID, different config).
+// removing stale tunnels, and updating changed tunnels (same ID, different
+// config) in place. Changed tunnels that cannot be updated in place are
+// restarted. Desired tunnels that fail validation (see
+// validation.SiteToSiteTunnels), or whose remote subnets would replace the
+// default route or capture a protected address (see
+// validation.SiteToSiteRoutes), are neither added nor updated; their failures
+// are returned as validation.Errors, which the reconciler reports as drift.
 func SiteToSiteReconcileHandler(mgr *SiteToSiteManager, logger *slog.Logger) reconcile.ReconcileHandler {
 	return func(_ context.Context, desired *api.StateResponse, _ reconcile.StateDiff) error {
 		if desired == nil || desired.SiteToSiteConfig == nil {
 			return nil
 		}
 
+		invalid := validation.SiteToSiteTunnels(desired.SiteToSiteConfig.Tunnels, reservedPorts(&mgr.cfg, mgr.portSources))
+		invalid = append(invalid, checkTunnelRoutes(&mgr.cfg, mgr.protectedSources, desired.SiteToSiteConfig.Tunnels...)...)
+		for _, e := range invalid {
+			logger.Warn("site-to-site reconcile: invalid tunnel",
+				"tunnel_id", e.ID,
+				"field", e.Field,
+				"code", e.Code,
+				"error", e.Message,
+			)
+		}
+
 		// Build desired set for diffing.
 		desiredSet := make(map[string]api.SiteToSiteTunnel, len(desired.SiteToSiteConfig.Tunnels))
 		for _, t := range desired.SiteToSiteConfig.Tunnels {
@@ -86,7 +104,8 @@ func SiteToSiteReconcileHandler(mgr *SiteToSiteManager, logger *slog.Logger) rec
 		}
 
 		// Remove stale tunnels (present locally but not in desired state)
-		// and detect changed tunnels (same ID, different config).
+		// and update changed tunnels (same ID, different config).
+		var errs []error
 		for _, id := range currentIDs {
 			desiredTunnel, inDesired := desiredSet[id]
 			if !inDesired {
@@ -94,18 +113,28 @@ func SiteToSiteReconcileHandler(mgr *SiteToSiteManager, logger *slog.Logger) rec
 				mgr.RemoveTunnel(id)
 				continue
 			}
-			// Check if the tunnel config changed (requires restart).
 			currentTunnel, ok := mgr.GetTunnel(id)
-			if ok && !reflect.DeepEqual(currentTunnel, desiredTunnel) {
+			if !ok || reflect.DeepEqual(currentTunnel, desiredTunnel) || invalid.Invalid(id) {
+				continue
+			}
+			err := mgr.UpdateTunnel(desiredTunnel)
+			switch {
+			case errors.Is(err, ErrTunnelRecreate):
+				// Interface changed — restart.
 				mgr.RemoveTunnel(id)
 				delete(currentSet, id) // mark for re-add below
+			case err != nil:
+				logger.Error("site-to-site reconcile: update tunnel failed",
+					"tunnel_id", id,
+					"error", err,
+				)
+				errs = append(errs, err)
 			}
 		}
 
-		// Add missing and changed tunnels.
-		var errs []error
+		// Add missing and restarted tunnels.
 		for _, tunnel := range desired.SiteToSiteConfig.Tunnels {
-			if _, ok := currentSet[tunnel.TunnelID]; ok {
+			if _, ok := currentSet[tunnel.TunnelID]; ok || invalid.Invalid(tunnel.TunnelID) {
 				continue
 			}
 			if err := mgr.AddTunnel(tunnel); err != nil {
@@ -117,6 +146,14 @@ func SiteToSiteReconcileHandler(mgr *SiteToSiteManager, logger *slog.Logger) rec
 			}
 		}
 
-		return errors.Join(errs...)
+		return errors.Join(append([]error{invalid.Err()}, errs...)...)
+	}
+}
+
+// SiteToSiteDriftChecker returns a reconcile.DriftChecker that enforces the
+// MAC limits of layer-2 extensions via SiteToSiteManager.CheckL2.
+func SiteToSiteDriftChecker(mgr *SiteToSiteManager) reconcile.DriftChecker {
+	return func(context.Context, *api.StateResponse) ([]api.DriftCorrection, error) {
+		return mgr.CheckL2()
 	}
 }
@@ -3,10 +3,14 @@ package bridge
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/plexsphere/plexd/internal/api"
 	"github.com/plexsphere/plexd/internal/reconcile"
+	"github.com/plexsphere/plexd/internal/validation"
 )
 
 // ---------------------------------------------------------------------------
@@ -64,15 +68,30 @@ func newTestSiteToSiteManager(t *testing.T, vpnCtrl *mockVPNController, routeCtr
 }
 
 // testTunnel returns a standard tunnel for testing.
+// testTunnelIndex assigns each tunnel ID a stable index, so that tunnels built
+// by testTunnel never share an interface, listen port, or remote subnet.
+var (
+	testTunnelMu    sync.Mutex
+	testTunnelIndex = map[string]int{}
+)
+
 func testTunnel(id string) api.SiteToSiteTunnel {
+	testTunnelMu.Lock()
+	idx, ok := testTunnelIndex[id]
+	if !ok {
+		idx = len(testTunnelIndex)
+		testTunnelIndex[id] = idx
+	}
+	testTunnelMu.Unlock()
+
 	return api.SiteToSiteTunnel{
 		TunnelID:        id,
 		RemoteEndpoint:  "203.0.113.1:51820",
 		RemotePublicKey: "remote-pub-key-" + id,
 		LocalSubnets:    []string{"10.0.0.0/24"},
-		RemoteSubnets:   []string{"192.168.1.0/24"},
-		InterfaceName:   "wg-s2s-" + id,
-		ListenPort:      51823,
+		RemoteSubnets:   []string{fmt.Sprintf("192.168.%d.0/24", idx%256)},
+		InterfaceName:   fmt.Sprintf("wg-s2s-%d", idx),
+		ListenPort:      52000 + idx,
 	}
 }
 
@@ -391,14 +410,16 @@ func TestSiteToSiteReconcileHandler_DetectsChangedTunnels(t *testing.T) {
 		t.Fatalf("handler error = %v, want nil", err)
 	}
 
-	// Should have removed the old tunnel and re-added.
-	removeCalls := vpnCtrl.vpnCallsFor("RemoveTunnelInterface")
-	if len(removeCalls) != 1 {
-		t.Fatalf("expected 1 RemoveTunnelInterface call for changed tunnel, got %d", len(removeCalls))
+	// Should have updated the peer in place, keeping the interface.
+	if n := len(vpnCtrl.vpnCallsFor("RemoveTunnelInterface")); n != 0 {
+		t.Errorf("expected no RemoveTunnelInterface call for changed endpoint, got %d", n)
 	}
-	createCalls := vpnCtrl.vpnCallsFor("CreateTunnelInterface")
-	if len(createCalls) != 1 {
-		t.Fatalf("expected 1 CreateTunnelInterface call for changed tunnel, got %d", len(createCalls))
+	if n := len(vpnCtrl.vpnCallsFor("CreateTunnelInterface")); n != 0 {
+		t.Errorf("expected no CreateTunnelInterface call for changed endpoint, got %d", n)
+	}
+	configureCalls := vpnCtrl.vpnCallsFor("ConfigureTunnelPeer")
+	if len(configureCalls) != 1 || configureCalls[0].Args[3] != "203.0.113.99:51820" {
+		t.Fatalf("ConfigureTunnelPeer calls = %+v, want 1 with the new endpoint", configureCalls)
 	}
 
 	// Verify the active tunnel has the new config.
@@ -411,6 +432,113 @@ func TestSiteToSiteReconcileHandler_DetectsChangedTunnels(t *testing.T) {
 	}
 }
 
+func TestSiteToSiteReconcileHandler_RestartsOnInterfaceChange(t *testing.T) {
+	vpnCtrl := &mockVPNController{}
+	routeCtrl := &mockRouteController{}
+	mgr := newTestSiteToSiteManager(t, vpnCtrl, routeCtrl)
+	defer func() { _ = mgr.Teardown() }()
+
+	if err := mgr.AddTunnel(testTunnel("tun-1")); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+	vpnCtrl.resetVPN()
+
+	handler := SiteToSiteReconcileHandler(mgr, discardLogger())
+
+	// A new listen port cannot be applied in place.
+	changed := testTunnel("tun-1")
+	changed.ListenPort = 51900
+	desired := &api.StateResponse{
+		SiteToSiteConfig: &api.SiteToSiteConfig{
+			Enabled: true,
+			Tunnels: []api.SiteToSiteTunnel{changed},
+		},
+	}
+	if err := handler(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+		t.Fatalf("handler error = %v, want nil", err)
+	}
+
+	if n := len(vpnCtrl.vpnCallsFor("RemoveTunnelInterface")); n != 1 {
+		t.Errorf("expected 1 RemoveTunnelInterface call, got %d", n)
+	}
+	createCalls := vpnCtrl.vpnCallsFor("CreateTunnelInterface")
+	if len(createCalls) != 1 || createCalls[0].Args[1] != 51900 {
+		t.Errorf("CreateTunnelInterface calls = %+v, want 1 with port 51900", createCalls)
+	}
+}
+
+func TestSiteToSiteReconcileHandler_SkipsInvalidTunnels(t *testing.T) {
+	vpnCtrl := &mockVPNController{}
+	routeCtrl := &mockRouteController{}
+	mgr := newTestSiteToSiteManager(t, vpnCtrl, routeCtrl)
+	defer func() { _ = mgr.Teardown() }()
+
+	handler := SiteToSiteReconcileHandler(mgr, discardLogger())
+
+	// The second tunnel routes the subnet of the first.
+	overlapping := testTunnel("tun-overlap")
+	overlapping.RemoteSubnets = testTunnel("tun-1").RemoteSubnets
+	desired := &api.StateResponse{
+		SiteToSiteConfig: &api.SiteToSiteConfig{
+			Enabled: true,
+			Tunnels: []api.SiteToSiteTunnel{testTunnel("tun-1"), overlapping},
+		},
+	}
+	err := handler(context.Background(), desired, reconcile.StateDiff{})
+
+	var verrs validation.Errors
+	if !errors.As(err, &verrs) || len(verrs) != 1 || verrs[0].ID != "tun-overlap" || verrs[0].Code != validation.CodeSubnetOverlap {
+		t.Fatalf("handler error = %v, want a subnet overlap of tun-overlap", err)
+	}
+	var dr reconcile.DriftReporter
+	if !errors.As(err, &dr) || len(dr.DriftCorrections()) != 1 {
+		t.Errorf("handler error is not reported as drift: %v", err)
+	}
+	if ids := mgr.TunnelIDs(); len(ids) != 1 || ids[0] != "tun-1" {
+		t.Errorf("TunnelIDs = %v, want [tun-1]", ids)
+	}
+}
+
+func TestSiteToSiteReconcileHandler_RefusesBlackholeRoutes(t *testing.T) {
+	vpnCtrl := &mockVPNController{}
+	routeCtrl := &mockRouteController{}
+	mgr := newTestSiteToSiteManager(t, vpnCtrl, routeCtrl)
+	defer func() { _ = mgr.Teardown() }()
+
+	handler := SiteToSiteReconcileHandler(mgr, discardLogger())
+
+	fullTunnel := testTunnel("tun-full")
+	fullTunnel.RemoteSubnets = []string{"::/0"}
+	desired := &api.StateResponse{
+		SiteToSiteConfig: &api.SiteToSiteConfig{
+			Enabled: true,
+			Tunnels: []api.SiteToSiteTunnel{testTunnel("tun-1"), fullTunnel},
+		},
+	}
+	err := handler(context.Background(), desired, reconcile.StateDiff{})
+
+	var verrs validation.Errors
+	if !errors.As(err, &verrs) || len(verrs) != 1 || verrs[0].ID != "tun-full" || verrs[0].Code != validation.CodeRouteBlackhole {
+		t.Fatalf("handler error = %v, want a route blackhole of tun-full", err)
+	}
+	var dr reconcile.DriftReporter
+	if !errors.As(err, &dr) || len(dr.DriftCorrections()) != 1 {
+		t.Errorf("handler error is not reported as drift: %v", err)
+	}
+	if ids := mgr.TunnelIDs(); len(ids) != 1 || ids[0] != "tun-1" {
+		t.Errorf("TunnelIDs = %v, want [tun-1]", ids)
+	}
+
+	// AllowFullTunnel accepts the tunnel.
+	mgr.cfg.AllowFullTunnel = true
+	if err := handler(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+		t.Fatalf("handler with AllowFullTunnel: %v", err)
+	}
+	if ids := mgr.TunnelIDs(); len(ids) != 2 {
+		t.Errorf("TunnelIDs = %v, want both tunnels", ids)
+	}
+}
+
 func TestSiteToSiteReconcileHandler_UnchangedTunnelsUntouched(t *testing.T) {
 	vpnCtrl := &mockVPNController{}
 	routeCtrl := &mockRouteController{}
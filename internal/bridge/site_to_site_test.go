@@ -1,10 +1,16 @@
 package bridge
 
 import (
+	"errors"
 	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/validation"
 )
 
 // ---------------------------------------------------------------------------
@@ -1043,6 +1049,66 @@ func TestSiteToSiteManager_SiteToSiteStatus_Active(t *testing.T) {
 	if status.TunnelCount != 1 {
 		t.Errorf("TunnelCount = %d, want 1", status.TunnelCount)
 	}
+	if len(status.Tunnels) != 1 || status.Tunnels[0].Error != errNoTunnelStats {
+		t.Errorf("Tunnels = %+v, want t-1 with error %q", status.Tunnels, errNoTunnelStats)
+	}
+}
+
+func TestSiteToSiteManager_SiteToSiteStatus_TrafficStats(t *testing.T) {
+	handshake := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	vpn := &mockStatsVPNController{stats: map[string]TunnelPeerStats{
+		"rpk-1": {Endpoint: "1.2.3.5:40000", RxBytes: 1000, TxBytes: 2000, LastHandshake: handshake},
+	}}
+	routes := &mockRouteController{}
+	cfg := Config{
+		Enabled:           true,
+		AccessInterface:   "eth1",
+		AccessSubnets:     []string{"10.0.0.0/24"},
+		SiteToSiteEnabled: true,
+	}
+	cfg.ApplyDefaults()
+
+	mgr := NewSiteToSiteManager(vpn, routes, cfg, discardLogger())
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	for i, id := range []string{"t-2", "t-1"} {
+		tunnel := api.SiteToSiteTunnel{
+			TunnelID:        id,
+			RemoteEndpoint:  "1.2.3.4:51823",
+			RemotePublicKey: "rpk-" + strconv.Itoa(2-i),
+			LocalSubnets:    []string{"10.0.0.0/24"},
+			RemoteSubnets:   []string{"10.1." + strconv.Itoa(i) + ".0/24"},
+			InterfaceName:   "wg-s2s-" + strconv.Itoa(i),
+			ListenPort:      51823 + i,
+		}
+		if err := mgr.AddTunnel(tunnel); err != nil {
+			t.Fatalf("AddTunnel(%s): %v", id, err)
+		}
+	}
+
+	status := mgr.SiteToSiteStatus()
+	if len(status.Tunnels) != 2 {
+		t.Fatalf("Tunnels = %+v, want 2", status.Tunnels)
+	}
+	got := status.Tunnels[0]
+	if got.TunnelID != "t-1" || got.Interface != "wg-s2s-1" || got.Endpoint != "1.2.3.5:40000" ||
+		got.RxBytes != 1000 || got.TxBytes != 2000 || got.Error != "" {
+		t.Errorf("Tunnels[0] = %+v", got)
+	}
+	if got.LastHandshake == nil || !got.LastHandshake.Equal(handshake) {
+		t.Errorf("Tunnels[0].LastHandshake = %v, want %v", got.LastHandshake, handshake)
+	}
+	// No handshake yet: LastHandshake stays nil.
+	if got := status.Tunnels[1]; got.TunnelID != "t-2" || got.LastHandshake != nil {
+		t.Errorf("Tunnels[1] = %+v, want t-2 without handshake", got)
+	}
+
+	vpn.statsErr = fmt.Errorf("device gone")
+	status = mgr.SiteToSiteStatus()
+	if status.Tunnels[0].Error != "device gone" {
+		t.Errorf("Tunnels[0].Error = %q, want %q", status.Tunnels[0].Error, "device gone")
+	}
 }
 
 func TestSiteToSiteManager_SiteToSiteStatus_Inactive(t *testing.T) {
@@ -1109,3 +1175,641 @@ func TestSiteToSiteManager_SiteToSiteCapabilities_Disabled(t *testing.T) {
 		t.Errorf("SiteToSiteCapabilities should be nil when disabled, got %v", caps)
 	}
 }
+
+// ---------------------------------------------------------------------------
+// SiteToSiteManager conntrack tests
+// ---------------------------------------------------------------------------
+
+func newConntrackTestTunnel() api.SiteToSiteTunnel {
+	return api.SiteToSiteTunnel{
+		TunnelID:        "t-1",
+		RemoteEndpoint:  "1.2.3.4:51823",
+		RemotePublicKey: "rpk-1",
+		LocalSubnets:    []string{"10.0.0.0/24"},
+		RemoteSubnets:   []string{"10.1.0.0/24", "10.2.0.0/24"},
+		InterfaceName:   "wg-s2s-0",
+		ListenPort:      51823,
+	}
+}
+
+func TestSiteToSiteManager_RemoveTunnel_FlushesConntrack(t *testing.T) {
+	vpn := &mockVPNController{}
+	routes := &mockConntrackRouteController{}
+	cfg := Config{
+		Enabled:           true,
+		AccessInterface:   "eth1",
+		AccessSubnets:     []string{"10.0.0.0/24"},
+		SiteToSiteEnabled: true,
+	}
+	cfg.ApplyDefaults()
+
+	mgr := NewSiteToSiteManager(vpn, routes, cfg, discardLogger())
+	mgr.SetConntrackFlusher(routes)
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := mgr.AddTunnel(newConntrackTestTunnel()); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+	if n := len(routes.callsFor("FlushConntrackSubnet")); n != 0 {
+		t.Fatalf("FlushConntrackSubnet called %d times on add, want 0", n)
+	}
+
+	mgr.RemoveTunnel("t-1")
+
+	// Flows of the local subnet with other peers are kept.
+	calls := routes.callsFor("FlushConntrackSubnet")
+	want := []string{"10.1.0.0/24", "10.2.0.0/24"}
+	if len(calls) != len(want) {
+		t.Fatalf("expected %d FlushConntrackSubnet calls, got %d", len(want), len(calls))
+	}
+	for i, subnet := range want {
+		if calls[i].Args[0] != subnet {
+			t.Errorf("FlushConntrackSubnet[%d] = %v, want %s", i, calls[i].Args[0], subnet)
+		}
+	}
+}
+
+func TestSiteToSiteManager_RemoveTunnel_ConntrackErrorContinues(t *testing.T) {
+	vpn := &mockVPNController{}
+	routes := &mockConntrackRouteController{flushConntrackErr: fmt.Errorf("conntrack failed")}
+	cfg := Config{
+		Enabled:           true,
+		AccessInterface:   "eth1",
+		AccessSubnets:     []string{"10.0.0.0/24"},
+		SiteToSiteEnabled: true,
+	}
+	cfg.ApplyDefaults()
+
+	mgr := NewSiteToSiteManager(vpn, routes, cfg, discardLogger())
+	mgr.SetConntrackFlusher(routes)
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := mgr.AddTunnel(newConntrackTestTunnel()); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+
+	mgr.RemoveTunnel("t-1")
+
+	if n := len(routes.callsFor("FlushConntrackSubnet")); n != 2 {
+		t.Errorf("expected 2 FlushConntrackSubnet calls despite errors, got %d", n)
+	}
+	if _, ok := mgr.GetTunnel("t-1"); ok {
+		t.Error("tunnel should be removed even when conntrack flush fails")
+	}
+}
+
+func TestSiteToSiteManager_Teardown_FlushesConntrack(t *testing.T) {
+	vpn := &mockVPNController{}
+	routes := &mockConntrackRouteController{flushConntrackErr: fmt.Errorf("conntrack failed")}
+	cfg := Config{
+		Enabled:           true,
+		AccessInterface:   "eth1",
+		AccessSubnets:     []string{"10.0.0.0/24"},
+		SiteToSiteEnabled: true,
+	}
+	cfg.ApplyDefaults()
+
+	mgr := NewSiteToSiteManager(vpn, routes, cfg, discardLogger())
+	mgr.SetConntrackFlusher(routes)
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := mgr.AddTunnel(newConntrackTestTunnel()); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+
+	if err := mgr.Teardown(); err == nil {
+		t.Fatal("Teardown should return the conntrack flush error")
+	}
+	if n := len(routes.callsFor("FlushConntrackSubnet")); n != 2 {
+		t.Errorf("expected 2 FlushConntrackSubnet calls, got %d", n)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// SiteToSiteManager egress shaping tests
+// ---------------------------------------------------------------------------
+
+func TestSiteToSiteManager_AddTunnel_EgressRate(t *testing.T) {
+	vpn := &mockVPNController{}
+	routes := &mockShapingRouteController{}
+	cfg := Config{
+		Enabled:           true,
+		AccessInterface:   "eth1",
+		AccessSubnets:     []string{"10.0.0.0/24"},
+		SiteToSiteEnabled: true,
+	}
+	cfg.ApplyDefaults()
+
+	mgr := NewSiteToSiteManager(vpn, routes, cfg, discardLogger())
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	shaped := newConntrackTestTunnel()
+	shaped.EgressRateKbps = 20000
+	if err := mgr.AddTunnel(shaped); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+	unshaped := newConntrackTestTunnel()
+	unshaped.TunnelID = "t-2"
+	unshaped.InterfaceName = "wg-s2s-t2"
+	unshaped.RemoteSubnets = []string{"10.3.0.0/24"}
+	unshaped.ListenPort = 51824
+	if err := mgr.AddTunnel(unshaped); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+
+	calls := routes.callsFor("SetEgressRate")
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 SetEgressRate call, got %d", len(calls))
+	}
+	if calls[0].Args[0] != shaped.InterfaceName || calls[0].Args[1] != int64(20000) {
+		t.Errorf("SetEgressRate args = %v, want [%s 20000]", calls[0].Args, shaped.InterfaceName)
+	}
+
+	status := mgr.SiteToSiteStatus()
+	if len(status.Shaping) != 1 {
+		t.Fatalf("Shaping = %v, want 1 entry", status.Shaping)
+	}
+	if st := status.Shaping[0]; st.Target != "t-1" || st.RateKbps != 20000 || !st.Active {
+		t.Errorf("Shaping[0] = %+v, want active t-1 at 20000", st)
+	}
+}
+
+func TestSiteToSiteManager_AddTunnel_EgressRateErrorKeepsTunnel(t *testing.T) {
+	vpn := &mockVPNController{}
+	routes := &mockShapingRouteController{setEgressRateErr: fmt.Errorf("qdisc failed")}
+	cfg := Config{
+		Enabled:           true,
+		AccessInterface:   "eth1",
+		AccessSubnets:     []string{"10.0.0.0/24"},
+		SiteToSiteEnabled: true,
+	}
+	cfg.ApplyDefaults()
+
+	mgr := NewSiteToSiteManager(vpn, routes, cfg, discardLogger())
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	tunnel := newConntrackTestTunnel()
+	tunnel.EgressRateKbps = 20000
+	if err := mgr.AddTunnel(tunnel); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+
+	status := mgr.SiteToSiteStatus()
+	if status.TunnelCount != 1 {
+		t.Errorf("TunnelCount = %d, want 1", status.TunnelCount)
+	}
+	if len(status.Shaping) != 1 || status.Shaping[0].Active || status.Shaping[0].Error != "qdisc failed" {
+		t.Errorf("Shaping = %+v, want one inactive entry with error", status.Shaping)
+	}
+}
+
+func TestSiteToSiteManager_AddTunnel_NegativeEgressRate(t *testing.T) {
+	vpn := &mockVPNController{}
+	routes := &mockShapingRouteController{}
+	cfg := Config{
+		Enabled:           true,
+		AccessInterface:   "eth1",
+		AccessSubnets:     []string{"10.0.0.0/24"},
+		SiteToSiteEnabled: true,
+	}
+	cfg.ApplyDefaults()
+
+	mgr := NewSiteToSiteManager(vpn, routes, cfg, discardLogger())
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	tunnel := newConntrackTestTunnel()
+	tunnel.EgressRateKbps = -1
+	if err := mgr.AddTunnel(tunnel); err == nil {
+		t.Fatal("expected error for negative egress rate")
+	}
+	if n := len(vpn.vpnCallsFor("CreateTunnelInterface")); n != 0 {
+		t.Errorf("CreateTunnelInterface called %d times, want 0", n)
+	}
+}
+
+func newNATMapTestManager(t *testing.T, routes RouteController) (*SiteToSiteManager, *mockVPNController) {
+	t.Helper()
+	vpn := &mockVPNController{}
+	cfg := Config{
+		Enabled:           true,
+		AccessInterface:   "eth1",
+		AccessSubnets:     []string{"10.0.0.0/24"},
+		SiteToSiteEnabled: true,
+	}
+	cfg.ApplyDefaults()
+
+	mgr := NewSiteToSiteManager(vpn, routes, cfg, discardLogger())
+	if f, ok := routes.(ConntrackFlusher); ok {
+		mgr.SetConntrackFlusher(f)
+	}
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	return mgr, vpn
+}
+
+func TestSiteToSiteManager_AddTunnel_NATMap(t *testing.T) {
+	routes := &mockMappingRouteController{}
+	mgr, _ := newNATMapTestManager(t, routes)
+
+	tunnel := newConntrackTestTunnel()
+	tunnel.NATMap = &api.SiteToSiteNATMap{Local: "10.0.0.0/24", As: "10.200.0.0/24"}
+	if err := mgr.AddTunnel(tunnel); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+	calls := routes.callsFor("AddSubnetMap")
+	if len(calls) != 1 || calls[0].Args[0] != tunnel.InterfaceName || calls[0].Args[1] != "10.0.0.0/24" || calls[0].Args[2] != "10.200.0.0/24" {
+		t.Fatalf("AddSubnetMap calls = %v, want one for %s", calls, tunnel.InterfaceName)
+	}
+
+	mgr.RemoveTunnel(tunnel.TunnelID)
+	if calls := routes.callsFor("RemoveSubnetMap"); len(calls) != 1 || calls[0].Args[1] != "10.0.0.0/24" {
+		t.Errorf("RemoveSubnetMap calls = %v, want one", calls)
+	}
+	// The remote subnets are an end of every mapped flow.
+	flushed := routes.callsFor("FlushConntrackSubnet")
+	if len(flushed) != 2 || flushed[0].Args[0] != "10.1.0.0/24" || flushed[1].Args[0] != "10.2.0.0/24" {
+		t.Errorf("FlushConntrackSubnet calls = %v, want the remote subnets flushed", flushed)
+	}
+}
+
+func TestSiteToSiteManager_AddTunnel_NATMapErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes RouteController
+		natMap api.SiteToSiteNATMap
+		want   string
+	}{
+		{"size mismatch", &mockMappingRouteController{}, api.SiteToSiteNATMap{Local: "10.0.0.0/24", As: "10.200.0.0/16"}, "differ in size"},
+		{"overlap", &mockMappingRouteController{}, api.SiteToSiteNATMap{Local: "10.0.0.0/24", As: "10.0.0.0/24"}, "overlap"},
+		{"host bits", &mockMappingRouteController{}, api.SiteToSiteNATMap{Local: "10.0.0.1/24", As: "10.200.0.0/24"}, "host bits are set"},
+		{"ipv6", &mockMappingRouteController{}, api.SiteToSiteNATMap{Local: "fd00::/64", As: "fd01::/64"}, "only IPv4"},
+		{"unsupported", &mockRouteController{}, api.SiteToSiteNATMap{Local: "10.0.0.0/24", As: "10.200.0.0/24"}, "does not support NAT maps"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr, vpn := newNATMapTestManager(t, tt.routes)
+			tunnel := newConntrackTestTunnel()
+			tunnel.NATMap = &tt.natMap
+			err := mgr.AddTunnel(tunnel)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("AddTunnel = %v, want error containing %q", err, tt.want)
+			}
+			if n := len(vpn.vpnCallsFor("CreateTunnelInterface")); n != 0 {
+				t.Errorf("CreateTunnelInterface called %d times, want 0", n)
+			}
+		})
+	}
+}
+
+func TestSiteToSiteManager_AddTunnel_NATMapRollback(t *testing.T) {
+	routes := &mockMappingRouteController{addSubnetMapErr: fmt.Errorf("nft failed")}
+	mgr, vpn := newNATMapTestManager(t, routes)
+
+	tunnel := newConntrackTestTunnel()
+	tunnel.NATMap = &api.SiteToSiteNATMap{Local: "10.0.0.0/24", As: "10.200.0.0/24"}
+	if err := mgr.AddTunnel(tunnel); err == nil {
+		t.Fatal("expected error when the NAT map cannot be added")
+	}
+	if n := len(routes.callsFor("DisableForwarding")); n != 1 {
+		t.Errorf("DisableForwarding called %d times, want 1", n)
+	}
+	if n := len(vpn.vpnCallsFor("RemoveTunnelInterface")); n != 1 {
+		t.Errorf("RemoveTunnelInterface called %d times, want 1", n)
+	}
+	if _, ok := mgr.GetTunnel(tunnel.TunnelID); ok {
+		t.Error("tunnel tracked after a failed NAT map")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// SiteToSiteManager UpdateTunnel tests
+// ---------------------------------------------------------------------------
+
+func TestSiteToSiteManager_UpdateTunnel_InPlace(t *testing.T) {
+	routes := &mockMappingRouteController{}
+	mgr, vpn := newNATMapTestManager(t, routes)
+
+	tunnel := newConntrackTestTunnel()
+	tunnel.PSK = "psk-1"
+	if err := mgr.AddTunnel(tunnel); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+	vpn.resetVPN()
+	routes.reset()
+
+	updated := newConntrackTestTunnel()
+	updated.RemoteEndpoint = "5.6.7.8:51823"
+	updated.RemoteSubnets = []string{"10.2.0.0/24", "10.3.0.0/24"}
+	updated.PSK = "psk-2"
+	updated.NATMap = &api.SiteToSiteNATMap{Local: "10.0.0.0/24", As: "10.200.0.0/24"}
+	if err := mgr.UpdateTunnel(updated); err != nil {
+		t.Fatalf("UpdateTunnel: %v", err)
+	}
+
+	// The interface and peer are kept.
+	for _, method := range []string{"CreateTunnelInterface", "RemoveTunnelInterface", "RemoveTunnelPeer"} {
+		if n := len(vpn.vpnCallsFor(method)); n != 0 {
+			t.Errorf("%s called %d times, want 0", method, n)
+		}
+	}
+	configured := vpn.vpnCallsFor("ConfigureTunnelPeer")
+	if len(configured) != 1 || configured[0].Args[3] != "5.6.7.8:51823" || configured[0].Args[4] != "psk-2" {
+		t.Fatalf("ConfigureTunnelPeer calls = %+v, want one with the new endpoint and PSK", configured)
+	}
+
+	// Only the subnet difference is routed.
+	if calls := routes.callsFor("AddRoute"); len(calls) != 1 || calls[0].Args[0] != "10.3.0.0/24" {
+		t.Errorf("AddRoute calls = %v, want 10.3.0.0/24", calls)
+	}
+	if calls := routes.callsFor("RemoveRoute"); len(calls) != 1 || calls[0].Args[0] != "10.1.0.0/24" {
+		t.Errorf("RemoveRoute calls = %v, want 10.1.0.0/24", calls)
+	}
+	if calls := routes.callsFor("FlushConntrackSubnet"); len(calls) != 1 || calls[0].Args[0] != "10.1.0.0/24" {
+		t.Errorf("FlushConntrackSubnet calls = %v, want the removed subnet", calls)
+	}
+	if calls := routes.callsFor("AddSubnetMap"); len(calls) != 1 {
+		t.Errorf("AddSubnetMap calls = %v, want 1", calls)
+	}
+
+	got, _ := mgr.GetTunnel(tunnel.TunnelID)
+	if got.RemoteEndpoint != "5.6.7.8:51823" || got.PSK != "psk-2" || got.NATMap == nil {
+		t.Errorf("GetTunnel = %+v, want the updated definition", got)
+	}
+}
+
+func TestSiteToSiteManager_UpdateTunnel_NATMapFlushesOldRemoteSubnets(t *testing.T) {
+	routes := &mockMappingRouteController{}
+	mgr, _ := newNATMapTestManager(t, routes)
+
+	tunnel := newConntrackTestTunnel()
+	tunnel.NATMap = &api.SiteToSiteNATMap{Local: "10.0.0.0/24", As: "10.200.0.0/24"}
+	if err := mgr.AddTunnel(tunnel); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+	routes.reset()
+
+	updated := newConntrackTestTunnel()
+	updated.NATMap = &api.SiteToSiteNATMap{Local: "10.0.0.0/24", As: "10.201.0.0/24"}
+	if err := mgr.UpdateTunnel(updated); err != nil {
+		t.Fatalf("UpdateTunnel: %v", err)
+	}
+
+	flushed := routes.callsFor("FlushConntrackSubnet")
+	if len(flushed) != 2 || flushed[0].Args[0] != "10.1.0.0/24" || flushed[1].Args[0] != "10.2.0.0/24" {
+		t.Errorf("FlushConntrackSubnet calls = %v, want the old remote subnets", flushed)
+	}
+}
+
+func TestSiteToSiteManager_UpdateTunnel_PeerChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*api.SiteToSiteTunnel)
+		want   []string
+	}{
+		{
+			name:   "rotated key",
+			change: func(tun *api.SiteToSiteTunnel) { tun.RemotePublicKey = "rpk-2" },
+			want:   []string{"ConfigureTunnelPeer rpk-2", "RemoveTunnelPeer rpk-1"},
+		},
+		{
+			name:   "dropped PSK",
+			change: func(tun *api.SiteToSiteTunnel) { tun.PSK = "" },
+			want:   []string{"RemoveTunnelPeer rpk-1", "ConfigureTunnelPeer rpk-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr, vpn := newNATMapTestManager(t, &mockRouteController{})
+			tunnel := newConntrackTestTunnel()
+			tunnel.PSK = "psk-1"
+			if err := mgr.AddTunnel(tunnel); err != nil {
+				t.Fatalf("AddTunnel: %v", err)
+			}
+			vpn.resetVPN()
+
+			updated := tunnel
+			tt.change(&updated)
+			if err := mgr.UpdateTunnel(updated); err != nil {
+				t.Fatalf("UpdateTunnel: %v", err)
+			}
+
+			var got []string
+			for _, c := range vpn.calls {
+				got = append(got, c.Method+" "+c.Args[1].(string))
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("calls = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSiteToSiteManager_UpdateTunnel_EgressRate(t *testing.T) {
+	routes := &mockShapingRouteController{}
+	mgr, _ := newNATMapTestManager(t, routes)
+
+	tunnel := newConntrackTestTunnel()
+	if err := mgr.AddTunnel(tunnel); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+
+	tunnel.EgressRateKbps = 5000
+	if err := mgr.UpdateTunnel(tunnel); err != nil {
+		t.Fatalf("UpdateTunnel: %v", err)
+	}
+	if calls := routes.callsFor("SetEgressRate"); len(calls) != 1 || calls[0].Args[1] != int64(5000) {
+		t.Errorf("SetEgressRate calls = %v, want 5000", calls)
+	}
+	if st := mgr.SiteToSiteStatus(); len(st.Shaping) != 1 || !st.Shaping[0].Active {
+		t.Errorf("Shaping = %+v, want active", st.Shaping)
+	}
+
+	tunnel.EgressRateKbps = 0
+	if err := mgr.UpdateTunnel(tunnel); err != nil {
+		t.Fatalf("UpdateTunnel: %v", err)
+	}
+	if n := len(routes.callsFor("ClearEgressRate")); n != 1 {
+		t.Errorf("ClearEgressRate called %d times, want 1", n)
+	}
+	if st := mgr.SiteToSiteStatus(); len(st.Shaping) != 0 {
+		t.Errorf("Shaping = %+v, want none", st.Shaping)
+	}
+}
+
+func TestSiteToSiteManager_UpdateTunnel_Rollback(t *testing.T) {
+	routes := &mockRouteController{addRouteErrFor: map[string]error{"10.3.0.0/24": fmt.Errorf("route failed")}}
+	mgr, vpn := newNATMapTestManager(t, routes)
+
+	tunnel := newConntrackTestTunnel()
+	if err := mgr.AddTunnel(tunnel); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+	vpn.resetVPN()
+	routes.reset()
+
+	updated := newConntrackTestTunnel()
+	updated.RemoteSubnets = []string{"10.1.0.0/24", "10.3.0.0/24"}
+	if err := mgr.UpdateTunnel(updated); err == nil {
+		t.Fatal("UpdateTunnel = nil, want error")
+	}
+
+	// The peer is restored to the previous subnets and nothing is removed.
+	configured := vpn.vpnCallsFor("ConfigureTunnelPeer")
+	if len(configured) != 2 || strings.Join(configured[1].Args[2].([]string), ",") != "10.1.0.0/24,10.2.0.0/24" {
+		t.Errorf("ConfigureTunnelPeer calls = %+v, want the previous subnets restored", configured)
+	}
+	if n := len(routes.callsFor("RemoveRoute")); n != 0 {
+		t.Errorf("RemoveRoute called %d times, want 0", n)
+	}
+	if got, _ := mgr.GetTunnel(tunnel.TunnelID); len(got.RemoteSubnets) != 2 || got.RemoteSubnets[1] != "10.2.0.0/24" {
+		t.Errorf("GetTunnel = %+v, want the previous definition", got)
+	}
+}
+
+func TestSiteToSiteManager_UpdateTunnel_Errors(t *testing.T) {
+	mgr, _ := newNATMapTestManager(t, &mockRouteController{})
+	tunnel := newConntrackTestTunnel()
+
+	if err := mgr.UpdateTunnel(tunnel); err == nil {
+		t.Error("UpdateTunnel of an unknown tunnel = nil, want error")
+	}
+	if err := mgr.AddTunnel(tunnel); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+
+	renamed := tunnel
+	renamed.InterfaceName = "wg-s2s-9"
+	if err := mgr.UpdateTunnel(renamed); !errors.Is(err, ErrTunnelRecreate) {
+		t.Errorf("UpdateTunnel with a new interface = %v, want ErrTunnelRecreate", err)
+	}
+
+	mapped := tunnel
+	mapped.NATMap = &api.SiteToSiteNATMap{Local: "10.0.0.0/24", As: "10.200.0.0/24"}
+	if err := mgr.UpdateTunnel(mapped); !errors.Is(err, errNoSubnetMapper) {
+		t.Errorf("UpdateTunnel with an unsupported NAT map = %v, want errNoSubnetMapper", err)
+	}
+
+	if err := mgr.Teardown(); err != nil {
+		t.Fatalf("Teardown: %v", err)
+	}
+	if err := mgr.UpdateTunnel(tunnel); err == nil {
+		t.Error("UpdateTunnel on an inactive manager = nil, want error")
+	}
+}
+
+func TestSiteToSiteManager_AddTunnel_Validation(t *testing.T) {
+	vpn := &mockVPNController{}
+	cfg := Config{
+		Enabled:           true,
+		AccessInterface:   "eth1",
+		AccessSubnets:     []string{"10.0.0.0/24"},
+		SiteToSiteEnabled: true,
+		UserAccessEnabled: true,
+	}
+	cfg.ApplyDefaults()
+
+	mgr := NewSiteToSiteManager(vpn, &mockRouteController{}, cfg, discardLogger())
+	mgr.SetPortSources(PortSourceFunc(func() []validation.ReservedPort {
+		return []validation.ReservedPort{{Port: 5353, Owner: "ingress rule r-1"}}
+	}))
+	mgr.SetProtectedSources(ProtectedSourceFunc(func() []validation.ProtectedAddr {
+		return []validation.ProtectedAddr{{Addr: netip.MustParseAddr("198.51.100.10"), Owner: "control plane"}}
+	}))
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := mgr.AddTunnel(newConntrackTestTunnel()); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+	if ports := mgr.ReservedPorts(); len(ports) != 1 || ports[0].Port != 51823 {
+		t.Errorf("ReservedPorts = %+v, want the tunnel port", ports)
+	}
+	vpn.resetVPN()
+
+	tests := []struct {
+		name   string
+		change func(*api.SiteToSiteTunnel)
+		code   string
+	}{
+		{"overlapping subnet", func(tun *api.SiteToSiteTunnel) { tun.RemoteSubnets = []string{"10.1.0.128/25"} }, validation.CodeSubnetOverlap},
+		{"port of another tunnel", func(tun *api.SiteToSiteTunnel) { tun.ListenPort = 51823 }, validation.CodePortConflict},
+		{"port of a port source", func(tun *api.SiteToSiteTunnel) { tun.ListenPort = 5353 }, validation.CodePortConflict},
+		{"user access port", func(tun *api.SiteToSiteTunnel) { tun.ListenPort = DefaultUserAccessListenPort }, validation.CodePortConflict},
+		{"invalid CIDR", func(tun *api.SiteToSiteTunnel) { tun.RemoteSubnets = []string{"10.9.0.0"} }, validation.CodeInvalidCIDR},
+		{"long interface name", func(tun *api.SiteToSiteTunnel) { tun.InterfaceName = "wg-site-to-site-1" }, validation.CodeInvalidInterfaceName},
+		{"default route", func(tun *api.SiteToSiteTunnel) { tun.RemoteSubnets = []string{"::/0"} }, validation.CodeRouteBlackhole},
+		{"control plane", func(tun *api.SiteToSiteTunnel) { tun.RemoteSubnets = []string{"198.51.100.0/24"} }, validation.CodeRouteBlackhole},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tunnel := api.SiteToSiteTunnel{
+				TunnelID:        "t-2",
+				RemotePublicKey: "rpk-2",
+				LocalSubnets:    []string{"10.0.0.0/24"},
+				RemoteSubnets:   []string{"10.9.0.0/24"},
+				InterfaceName:   "wg-s2s-1",
+				ListenPort:      51824,
+			}
+			tt.change(&tunnel)
+			err := mgr.AddTunnel(tunnel)
+			var verrs validation.Errors
+			if !errors.As(err, &verrs) || verrs[0].Code != tt.code {
+				t.Errorf("AddTunnel = %v, want %s", err, tt.code)
+			}
+		})
+	}
+	if n := len(vpn.vpnCallsFor("CreateTunnelInterface")); n != 0 {
+		t.Errorf("CreateTunnelInterface called %d times, want 0", n)
+	}
+}
+
+func TestSiteToSiteManager_MSSClamp(t *testing.T) {
+	vpn := &mockVPNController{}
+	routes := &mockClampingRouteController{}
+	cfg := Config{
+		Enabled:            true,
+		AccessInterface:    "eth1",
+		AccessSubnets:      []string{"10.0.0.0/24"},
+		SiteToSiteEnabled:  true,
+		MSSClampInterfaces: map[string]string{"wg-s2s-t2": MSSClampOff},
+	}
+	cfg.ApplyDefaults()
+
+	mgr := NewSiteToSiteManager(vpn, routes, cfg, discardLogger())
+	if err := mgr.Setup("wg0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	clamped := newConntrackTestTunnel()
+	if err := mgr.AddTunnel(clamped); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+	unclamped := newConntrackTestTunnel()
+	unclamped.TunnelID = "t-2"
+	unclamped.InterfaceName = "wg-s2s-t2"
+	unclamped.RemoteSubnets = []string{"10.3.0.0/24"}
+	unclamped.ListenPort = 51824
+	if err := mgr.AddTunnel(unclamped); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+
+	calls := routes.callsFor("SetMSSClamp")
+	if len(calls) != 1 || calls[0].Args[0] != clamped.InterfaceName || calls[0].Args[1] != 0 {
+		t.Fatalf("SetMSSClamp calls = %v, want [%s 0]", calls, clamped.InterfaceName)
+	}
+
+	mgr.RemoveTunnel(clamped.TunnelID)
+	if err := mgr.Teardown(); err != nil {
+		t.Fatalf("Teardown: %v", err)
+	}
+	calls = routes.callsFor("ClearMSSClamp")
+	if len(calls) != 1 || calls[0].Args[0] != clamped.InterfaceName {
+		t.Errorf("ClearMSSClamp calls = %v, want [%s]", calls, clamped.InterfaceName)
+	}
+}
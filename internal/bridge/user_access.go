@@ -2,11 +2,16 @@
 package bridge
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"slices"
+	"sort"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/plexsphere/plexd/internal/api"
 )
@@ -15,31 +20,65 @@ import (
 // that allows external VPN clients to reach the mesh via a bridge node.
 // UserAccessManager is concurrent-safe via mu.
 type UserAccessManager struct {
-	ctrl   AccessController
-	routes RouteController
-	cfg    Config
-	logger *slog.Logger
+	ctrl     AccessController
+	routes   RouteController
+	cfg      Config
+	logger   *slog.Logger
+	dns      DNSConfigurator
+	report   ReportWriter
+	hostname string
 
 	// mu protects active and activePeers from concurrent access by
-	// SSE event handlers and the reconcile loop.
+	// SSE event handlers, expiry timers and the reconcile loop.
 	mu sync.Mutex
 
 	// tracked state
-	active      bool
-	activePeers map[string]struct{} // keyed by public key
+	active        bool
+	activePeers   map[string]api.UserAccessPeer // keyed by public key
+	shaping       *api.ShapingStatus            // nil when no egress limit is set
+	publicKey     string
+	dnsServers    []string
+	searchDomains []string
+	dnsApplied    bool // split DNS may be programmed into the node resolver
+	dnsPending    bool // the last resolver update failed and is retried
+
+	expiry       map[string]*time.Timer // expiry timers per public key
+	expiredPeers int                    // peers revoked on expiry
+	audits       []api.AuditEntry
 }
 
+// expiryRetryInterval is how long to wait before retrying the removal of an
+// expired peer that failed.
+const expiryRetryInterval = 30 * time.Second
+
 // NewUserAccessManager creates a new UserAccessManager.
 func NewUserAccessManager(ctrl AccessController, routes RouteController, cfg Config, logger *slog.Logger) *UserAccessManager {
+	hostname, _ := os.Hostname()
 	return &UserAccessManager{
 		ctrl:        ctrl,
 		routes:      routes,
 		cfg:         cfg,
 		logger:      logger,
-		activePeers: make(map[string]struct{}),
+		hostname:    hostname,
+		activePeers: make(map[string]api.UserAccessPeer),
+		expiry:      make(map[string]*time.Timer),
 	}
 }
 
+// SetDNSConfigurator sets the configurator used to program the node's
+// resolver with the split DNS pushed in UserAccessConfig. It must be called
+// before Setup. When unset, DNS settings are only advertised to clients.
+func (m *UserAccessManager) SetDNSConfigurator(d DNSConfigurator) {
+	m.dns = d
+}
+
+// SetReportWriter sets where the client export and the client profiles are
+// written, under UserAccessExportKey and UserAccessProfilesKey. It must be
+// called before Setup.
+func (m *UserAccessManager) SetReportWriter(w ReportWriter) {
+	m.report = w
+}
+
 // Setup creates the WireGuard interface for user access and enables forwarding.
 // When user access is disabled this is a no-op.
 func (m *UserAccessManager) Setup() error {
@@ -60,8 +99,27 @@ func (m *UserAccessManager) Setup() error {
 		return fmt.Errorf("bridge: user access: enable forwarding: %w", err)
 	}
 
+	if err := applyMSSClamp(m.routes, &m.cfg, m.cfg.UserAccessInterfaceName); err != nil {
+		m.logger.Warn("bridge: user access: MSS clamp not applied",
+			"component", "bridge",
+			"error", err,
+		)
+	}
+
 	m.active = true
 
+	if reader, ok := m.ctrl.(PublicKeyReader); ok {
+		key, err := reader.InterfacePublicKey(m.cfg.UserAccessInterfaceName)
+		if err != nil {
+			m.logger.Warn("bridge: user access: read public key failed",
+				"component", "bridge",
+				"error", err,
+			)
+		}
+		m.publicKey = key
+	}
+	m.writeExport()
+
 	m.logger.Info("user access interface created",
 		"component", "bridge",
 		"interface", m.cfg.UserAccessInterfaceName,
@@ -92,7 +150,10 @@ func (m *UserAccessManager) Teardown() error {
 		}
 	}
 
-	// Disable forwarding.
+	// Remove the MSS clamp and disable forwarding.
+	if err := clearMSSClamp(m.routes, &m.cfg, m.cfg.UserAccessInterfaceName); err != nil {
+		errs = append(errs, err)
+	}
 	if err := m.routes.DisableForwarding(m.cfg.UserAccessInterfaceName, m.cfg.AccessInterface); err != nil {
 		errs = append(errs, err)
 	}
@@ -102,8 +163,23 @@ func (m *UserAccessManager) Teardown() error {
 		errs = append(errs, err)
 	}
 
+	// Remove split DNS from the node resolver.
+	if m.dnsApplied {
+		if err := m.dns.ClearSplitDNS(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, t := range m.expiry {
+		t.Stop()
+	}
+
 	m.active = false
-	m.activePeers = make(map[string]struct{})
+	m.activePeers = make(map[string]api.UserAccessPeer)
+	m.expiry = make(map[string]*time.Timer)
+	m.shaping = nil
+	m.dnsServers, m.searchDomains = nil, nil
+	m.dnsApplied, m.dnsPending = false, false
 
 	if len(errs) == 0 {
 		m.logger.Info("user access interface removed",
@@ -115,12 +191,17 @@ func (m *UserAccessManager) Teardown() error {
 	return errors.Join(errs...)
 }
 
-// AddPeer adds a single user access peer. Returns an error if the maximum
-// number of peers has been reached or the peer is already tracked.
+// AddPeer adds a single user access peer. A peer with ExpiresAt set is
+// revoked when it expires, without waiting for the control plane. Returns an
+// error if the maximum number of peers has been reached, the peer is already
+// tracked, or it has already expired.
 func (m *UserAccessManager) AddPeer(peer api.UserAccessPeer) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if peerExpired(peer, time.Now()) {
+		return fmt.Errorf("bridge: user access: peer already expired: %s", peer.PublicKey)
+	}
 	if _, ok := m.activePeers[peer.PublicKey]; ok {
 		return fmt.Errorf("bridge: user access: peer already exists: %s", peer.PublicKey)
 	}
@@ -132,7 +213,9 @@ func (m *UserAccessManager) AddPeer(peer api.UserAccessPeer) error {
 		return fmt.Errorf("bridge: user access: configure peer: %w", err)
 	}
 
-	m.activePeers[peer.PublicKey] = struct{}{}
+	m.activePeers[peer.PublicKey] = peer
+	m.scheduleExpiry(peer)
+	m.writeExport()
 	return nil
 }
 
@@ -153,7 +236,304 @@ func (m *UserAccessManager) RemovePeer(publicKey string) {
 		)
 		return
 	}
+	m.stopExpiry(publicKey)
 	delete(m.activePeers, publicKey)
+	m.writeExport()
+}
+
+// SetPeerExpiry changes when an active peer expires; nil removes the expiry.
+// A time in the past revokes the peer right away. Unknown peers and
+// unchanged expiries are ignored.
+func (m *UserAccessManager) SetPeerExpiry(publicKey string, expiresAt *time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	peer, ok := m.activePeers[publicKey]
+	if !ok || sameExpiry(peer.ExpiresAt, expiresAt) {
+		return
+	}
+	if expiresAt != nil {
+		t := *expiresAt
+		expiresAt = &t
+	}
+	peer.ExpiresAt = expiresAt
+	m.activePeers[publicKey] = peer
+	m.scheduleExpiry(peer)
+	m.writeExport()
+
+	m.logger.Info("user access peer expiry updated",
+		"component", "bridge",
+		"public_key", publicKey,
+		"expires_at", expiresAt,
+	)
+}
+
+// scheduleExpiry (re)starts the expiry timer of peer, or stops it when the
+// peer does not expire. Caller must hold m.mu.
+func (m *UserAccessManager) scheduleExpiry(peer api.UserAccessPeer) {
+	m.stopExpiry(peer.PublicKey)
+	if peer.ExpiresAt == nil {
+		return
+	}
+	m.expireAfter(peer.PublicKey, *peer.ExpiresAt, time.Until(*peer.ExpiresAt))
+}
+
+// expireAfter revokes the peer with publicKey after d, provided it still
+// expires at expiresAt by then. Caller must hold m.mu.
+func (m *UserAccessManager) expireAfter(publicKey string, expiresAt time.Time, d time.Duration) {
+	m.expiry[publicKey] = time.AfterFunc(d, func() {
+		m.expirePeer(publicKey, expiresAt)
+	})
+}
+
+// stopExpiry stops the expiry timer of the peer with publicKey, if any.
+// Caller must hold m.mu.
+func (m *UserAccessManager) stopExpiry(publicKey string) {
+	if t, ok := m.expiry[publicKey]; ok {
+		t.Stop()
+		delete(m.expiry, publicKey)
+	}
+}
+
+// expirePeer revokes an expired peer and records an audit entry. A removal
+// that fails is retried after expiryRetryInterval. Timers that fire after the
+// peer was removed or its expiry changed are ignored.
+func (m *UserAccessManager) expirePeer(publicKey string, expiresAt time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	peer, ok := m.activePeers[publicKey]
+	if !ok || peer.ExpiresAt == nil || !peer.ExpiresAt.Equal(expiresAt) {
+		return
+	}
+	delete(m.expiry, publicKey)
+
+	if err := m.ctrl.RemovePeer(m.cfg.UserAccessInterfaceName, publicKey); err != nil {
+		m.logger.Error("bridge: user access: revoke expired peer failed",
+			"component", "bridge",
+			"public_key", publicKey,
+			"retry_in", expiryRetryInterval,
+			"error", err,
+		)
+		m.expireAfter(publicKey, expiresAt, expiryRetryInterval)
+		return
+	}
+	delete(m.activePeers, publicKey)
+	m.expiredPeers++
+	m.queueAudit(peerExpiredAudit(peer, m.cfg.UserAccessInterfaceName, m.hostname))
+	m.writeExport()
+
+	m.logger.Info("user access peer expired",
+		"component", "bridge",
+		"public_key", publicKey,
+		"label", peer.Label,
+		"expires_at", expiresAt,
+	)
+}
+
+// peerExpired reports whether peer's access has expired at now.
+func peerExpired(peer api.UserAccessPeer, now time.Time) bool {
+	return peer.ExpiresAt != nil && !now.Before(*peer.ExpiresAt)
+}
+
+// sameExpiry reports whether a and b denote the same expiry.
+func sameExpiry(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// SetEgressRate limits the traffic sent to user access clients to rateKbps
+// kbit/s; 0 removes the limit. A limit that cannot be applied is logged and
+// reported in UserAccessStatus, and retried on the next call. Setting the
+// rate that is already in effect is a no-op, as is any call while user access
+// is not active. Returns an error only for a negative rate.
+func (m *UserAccessManager) SetEgressRate(rateKbps int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rateKbps < 0 {
+		return fmt.Errorf("bridge: user access: negative egress rate: %d", rateKbps)
+	}
+	if !m.active {
+		return nil
+	}
+
+	iface := m.cfg.UserAccessInterfaceName
+	if rateKbps == 0 {
+		if m.shaping == nil {
+			return nil
+		}
+		if shaper, ok := m.routes.(TrafficShaper); ok && m.shaping.Active {
+			if err := shaper.ClearEgressRate(iface); err != nil {
+				m.logger.Warn("bridge: user access: clear egress rate failed",
+					"component", "bridge",
+					"interface", iface,
+					"error", err,
+				)
+				return nil
+			}
+		}
+		m.shaping = nil
+		m.logger.Info("user access egress rate cleared",
+			"component", "bridge",
+			"interface", iface,
+		)
+		return nil
+	}
+
+	if m.shaping != nil && m.shaping.Active && m.shaping.RateKbps == rateKbps {
+		return nil
+	}
+	st := applyEgressRate(m.routes, iface, iface, rateKbps)
+	m.shaping = &st
+	if st.Error != "" {
+		m.logger.Warn("bridge: user access: egress rate not applied",
+			"component", "bridge",
+			"interface", iface,
+			"rate_kbps", rateKbps,
+			"error", st.Error,
+		)
+		return nil
+	}
+	m.logger.Info("user access egress rate set",
+		"component", "bridge",
+		"interface", iface,
+		"rate_kbps", rateKbps,
+	)
+	return nil
+}
+
+// SetDNS sets the DNS servers and search domains advertised to user access
+// clients. When a DNSConfigurator is set and both lists are non-empty, the
+// node's resolver is also programmed to send queries for the search domains
+// to the servers; empty lists remove that configuration. A resolver update
+// that fails is logged and retried on the next call. Setting the current
+// values again is a no-op, as is any call while user access is not active.
+// Returns an error for an invalid server address or domain.
+func (m *UserAccessManager) SetDNS(servers, domains []string) error {
+	if err := validateDNS(servers, domains); err != nil {
+		return fmt.Errorf("bridge: user access: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.active {
+		return nil
+	}
+
+	changed := !slices.Equal(servers, m.dnsServers) || !slices.Equal(domains, m.searchDomains)
+	if !changed && !m.dnsPending {
+		return nil
+	}
+	m.dnsServers = slices.Clone(servers)
+	m.searchDomains = slices.Clone(domains)
+	if changed {
+		m.writeExport()
+		m.logger.Info("user access DNS updated",
+			"component", "bridge",
+			"servers", servers,
+			"domains", domains,
+		)
+	}
+
+	if m.dns == nil {
+		return nil
+	}
+	split := len(servers) > 0 && len(domains) > 0
+	var err error
+	switch {
+	case split:
+		err = m.dns.SetSplitDNS(servers, domains)
+	case m.dnsApplied:
+		err = m.dns.ClearSplitDNS()
+	}
+	m.dnsPending = err != nil
+	if err != nil {
+		// A failed update may have left the resolver partly configured;
+		// Teardown clears it in that case.
+		m.dnsApplied = m.dnsApplied || split
+		m.logger.Warn("bridge: user access: program resolver failed",
+			"component", "bridge",
+			"error", err,
+		)
+		return nil
+	}
+	m.dnsApplied = split
+	return nil
+}
+
+// Export returns the client export: the interface, routed subnets, DNS
+// settings, and peers, sorted by label.
+func (m *UserAccessManager) Export() UserAccessExport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.exportLocked()
+}
+
+// exportLocked builds the client export. Caller must hold m.mu.
+func (m *UserAccessManager) exportLocked() UserAccessExport {
+	e := UserAccessExport{
+		InterfaceName: m.cfg.UserAccessInterfaceName,
+		PublicKey:     m.publicKey,
+		ListenPort:    m.cfg.UserAccessListenPort,
+		RoutedSubnets: slices.Clone(m.cfg.AccessSubnets),
+		DNSServers:    slices.Clone(m.dnsServers),
+		SearchDomains: slices.Clone(m.searchDomains),
+		Peers:         make([]UserAccessExportPeer, 0, len(m.activePeers)),
+	}
+	if m.cfg.UserAccessEndpoint != "" {
+		// Validate has checked the endpoint.
+		e.Endpoint, _ = clientEndpoint(m.cfg.UserAccessEndpoint, m.cfg.UserAccessListenPort)
+	}
+	for _, p := range m.activePeers {
+		e.Peers = append(e.Peers, UserAccessExportPeer{
+			PublicKey:  p.PublicKey,
+			Label:      p.Label,
+			AllowedIPs: slices.Clone(p.AllowedIPs),
+			ExpiresAt:  p.ExpiresAt,
+		})
+	}
+	sort.Slice(e.Peers, func(i, j int) bool {
+		if e.Peers[i].Label != e.Peers[j].Label {
+			return e.Peers[i].Label < e.Peers[j].Label
+		}
+		return e.Peers[i].PublicKey < e.Peers[j].PublicKey
+	})
+	return e
+}
+
+// writeExport writes the client export and the client profiles to the
+// report writer, if one is set. Caller must hold m.mu.
+func (m *UserAccessManager) writeExport() {
+	if m.report == nil {
+		return
+	}
+	e := m.exportLocked()
+	m.writeReport(UserAccessExportKey, e)
+	m.writeReport(UserAccessProfilesKey, e.Profiles())
+}
+
+// writeReport writes v as the report entry key. Caller must hold m.mu.
+func (m *UserAccessManager) writeReport(key string, v any) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		m.logger.Error("bridge: user access: marshal export failed",
+			"component", "bridge",
+			"key", key,
+			"error", err,
+		)
+		return
+	}
+	if err := m.report.WriteReport(key, payload); err != nil {
+		m.logger.Warn("bridge: user access: write export failed",
+			"component", "bridge",
+			"key", key,
+			"error", err,
+		)
+	}
 }
 
 // PeerPublicKeys returns the public keys of all active peers.
@@ -177,12 +557,18 @@ func (m *UserAccessManager) UserAccessStatus() *api.UserAccessInfo {
 	if !m.active {
 		return nil
 	}
-	return &api.UserAccessInfo{
+	info := &api.UserAccessInfo{
 		Enabled:       true,
 		InterfaceName: m.cfg.UserAccessInterfaceName,
 		PeerCount:     len(m.activePeers),
 		ListenPort:    m.cfg.UserAccessListenPort,
+		ExpiredPeers:  m.expiredPeers,
+	}
+	if m.shaping != nil {
+		st := *m.shaping
+		info.Shaping = &st
 	}
+	return info
 }
 
 // UserAccessCapabilities returns capability metadata for registration.
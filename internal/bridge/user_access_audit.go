@@ -0,0 +1,64 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// maxPendingAccessAudits bounds the audit entries buffered between Collect
+// calls; the oldest are dropped first.
+const maxPendingAccessAudits = 256
+
+// accessAuditEventType is the event type of user access audit entries.
+const accessAuditEventType = "user_access_peer"
+
+// Collect returns and clears the audit entries recorded since the last call:
+// one per peer revoked because its access expired. It implements
+// auditfwd.AuditSource.
+func (m *UserAccessManager) Collect(_ context.Context) ([]api.AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := m.audits
+	m.audits = nil
+	return entries, nil
+}
+
+// queueAudit buffers entry. Caller must hold m.mu.
+func (m *UserAccessManager) queueAudit(entry api.AuditEntry) {
+	m.audits = append(m.audits, entry)
+	if over := len(m.audits) - maxPendingAccessAudits; over > 0 {
+		m.audits = m.audits[over:]
+	}
+}
+
+// peerExpiredAudit returns the audit entry for a peer revoked on expiry.
+func peerExpiredAudit(peer api.UserAccessPeer, iface, hostname string) api.AuditEntry {
+	subject, _ := json.Marshal(map[string]string{
+		"public_key": peer.PublicKey,
+		"label":      peer.Label,
+	})
+	object, _ := json.Marshal(map[string]any{
+		"interface":   iface,
+		"allowed_ips": peer.AllowedIPs,
+		"expires_at":  peer.ExpiresAt.UTC(),
+	})
+	who := peer.Label
+	if who == "" {
+		who = peer.PublicKey
+	}
+	return api.AuditEntry{
+		Timestamp: time.Now().UTC(),
+		Source:    "plexd",
+		EventType: accessAuditEventType,
+		Subject:   subject,
+		Object:    object,
+		Action:    "expire",
+		Result:    "success",
+		Hostname:  hostname,
+		Raw:       fmt.Sprintf("user access peer %s revoked: access expired at %s", who, peer.ExpiresAt.UTC().Format(time.RFC3339)),
+	}
+}
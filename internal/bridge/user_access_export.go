@@ -0,0 +1,212 @@
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UserAccessExportKey is the node API report key the user access client
+// export is written to.
+const UserAccessExportKey = "useraccess.export"
+
+// UserAccessProfilesKey is the node API report key the per-peer client
+// profiles are written to.
+const UserAccessProfilesKey = "useraccess.profiles"
+
+// clientKeepalive is the persistent keepalive interval, in seconds, of
+// client configurations. It keeps the NAT mappings of clients open.
+const clientKeepalive = 25
+
+// ReportWriter stores a node API report entry and syncs it to the control
+// plane. *nodeapi.Server satisfies this interface.
+type ReportWriter interface {
+	WriteReport(key string, payload json.RawMessage) error
+}
+
+// UserAccessExport is what a user access client needs to connect through
+// this bridge node. UserAccessManager keeps it up to date under
+// UserAccessExportKey; `plexd useraccess export` renders it as a client
+// configuration.
+type UserAccessExport struct {
+	InterfaceName string `json:"interface_name"`
+	// Endpoint is Config.UserAccessEndpoint as host:port, or empty if it is
+	// not set.
+	Endpoint string `json:"endpoint,omitempty"`
+	// PublicKey is the public key of the user access interface, or empty if
+	// the access controller cannot report it.
+	PublicKey  string `json:"public_key,omitempty"`
+	ListenPort int    `json:"listen_port"`
+	// RoutedSubnets are the subnets clients reach through the bridge.
+	RoutedSubnets []string               `json:"routed_subnets"`
+	DNSServers    []string               `json:"dns_servers,omitempty"`
+	SearchDomains []string               `json:"search_domains,omitempty"`
+	Peers         []UserAccessExportPeer `json:"peers"`
+}
+
+// UserAccessExportPeer is a user access peer in the export. Pre-shared keys
+// are never exported.
+type UserAccessExportPeer struct {
+	PublicKey  string   `json:"public_key"`
+	Label      string   `json:"label"`
+	AllowedIPs []string `json:"allowed_ips"`
+	// ExpiresAt is when the peer's access expires; nil means never.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// FindPeer returns the peer whose label or public key is id.
+func (e UserAccessExport) FindPeer(id string) (UserAccessExportPeer, bool) {
+	for _, p := range e.Peers {
+		if p.Label == id || p.PublicKey == id {
+			return p, true
+		}
+	}
+	return UserAccessExportPeer{}, false
+}
+
+// ClientConfig renders a wg-quick configuration for peer. endpoint is the
+// host, or host:port, clients use to reach the bridge; without a port the
+// listen port is used, and when empty the export's Endpoint is. An empty
+// privateKey leaves a placeholder for the client to fill in. DNS servers
+// that lie outside the routed subnets are added to AllowedIPs so that
+// clients can reach them through the tunnel.
+func (e UserAccessExport) ClientConfig(peer UserAccessExportPeer, privateKey, endpoint string) (string, error) {
+	if endpoint == "" {
+		endpoint = e.Endpoint
+	}
+	if endpoint == "" {
+		return "", fmt.Errorf("bridge: user access export: endpoint is required")
+	}
+	endpoint, err := clientEndpoint(endpoint, e.ListenPort)
+	if err != nil {
+		return "", fmt.Errorf("bridge: user access export: %w", err)
+	}
+	if privateKey == "" {
+		privateKey = "<client private key>"
+	}
+	serverKey := e.PublicKey
+	if serverKey == "" {
+		serverKey = "<bridge public key>"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# plexd user access client %q via %s\n", peer.Label, e.InterfaceName)
+	b.WriteString("[Interface]\n")
+	fmt.Fprintf(&b, "PrivateKey = %s\n", privateKey)
+	fmt.Fprintf(&b, "Address = %s\n", strings.Join(peer.AllowedIPs, ", "))
+	if dns := append(append([]string{}, e.DNSServers...), e.SearchDomains...); len(dns) > 0 {
+		fmt.Fprintf(&b, "DNS = %s\n", strings.Join(dns, ", "))
+	}
+	b.WriteString("\n[Peer]\n")
+	fmt.Fprintf(&b, "PublicKey = %s\n", serverKey)
+	fmt.Fprintf(&b, "Endpoint = %s\n", endpoint)
+	fmt.Fprintf(&b, "AllowedIPs = %s\n", strings.Join(e.clientAllowedIPs(), ", "))
+	fmt.Fprintf(&b, "PersistentKeepalive = %d\n", clientKeepalive)
+	return b.String(), nil
+}
+
+// UserAccessProfile is what a portal needs to show a user access client how
+// to connect: the settings of a WireGuard client, field by field as the
+// WireGuard apps for Windows and macOS ask for them, and as a wg-quick file
+// the apps can import.
+type UserAccessProfile struct {
+	// PublicKey is the public key of the peer.
+	PublicKey string `json:"public_key"`
+	Label     string `json:"label"`
+	// Endpoint is the export's Endpoint; empty if it is not configured.
+	Endpoint string `json:"endpoint,omitempty"`
+	// ServerPublicKey is the public key of the user access interface.
+	ServerPublicKey string `json:"server_public_key,omitempty"`
+	// Addresses are the client's tunnel addresses, the peer's allowed IPs.
+	Addresses []string `json:"addresses"`
+	// AllowedIPs are the subnets the client routes through the tunnel.
+	AllowedIPs          []string   `json:"allowed_ips"`
+	DNSServers          []string   `json:"dns_servers,omitempty"`
+	SearchDomains       []string   `json:"search_domains,omitempty"`
+	PersistentKeepalive int        `json:"persistent_keepalive"`
+	ExpiresAt           *time.Time `json:"expires_at,omitempty"`
+	// Config is the wg-quick configuration with a placeholder for the
+	// client's private key; empty when Endpoint is.
+	Config string `json:"config,omitempty"`
+}
+
+// Profiles returns the client profile of each peer, keyed by label. A peer
+// without a label, or whose label an earlier peer already has, is keyed by
+// its public key instead.
+func (e UserAccessExport) Profiles() map[string]UserAccessProfile {
+	profiles := make(map[string]UserAccessProfile, len(e.Peers))
+	for _, peer := range e.Peers {
+		p := UserAccessProfile{
+			PublicKey:           peer.PublicKey,
+			Label:               peer.Label,
+			Endpoint:            e.Endpoint,
+			ServerPublicKey:     e.PublicKey,
+			Addresses:           peer.AllowedIPs,
+			AllowedIPs:          e.clientAllowedIPs(),
+			DNSServers:          e.DNSServers,
+			SearchDomains:       e.SearchDomains,
+			PersistentKeepalive: clientKeepalive,
+			ExpiresAt:           peer.ExpiresAt,
+		}
+		if e.Endpoint != "" {
+			if conf, err := e.ClientConfig(peer, "", e.Endpoint); err == nil {
+				p.Config = conf
+			}
+		}
+		key := peer.Label
+		if _, taken := profiles[key]; taken || key == "" {
+			key = peer.PublicKey
+		}
+		profiles[key] = p
+	}
+	return profiles
+}
+
+// clientEndpoint returns endpoint as host:port, adding port when endpoint
+// is a bare host or IP address.
+func clientEndpoint(endpoint string, port int) (string, error) {
+	host, p, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		host, p = strings.Trim(endpoint, "[]"), strconv.Itoa(port)
+	}
+	if host == "" || strings.ContainsAny(host, " /") {
+		return "", fmt.Errorf("invalid endpoint %q", endpoint)
+	}
+	if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("invalid port in endpoint %q", endpoint)
+	}
+	return net.JoinHostPort(host, p), nil
+}
+
+// clientAllowedIPs returns the routed subnets plus a host route for each DNS
+// server outside them.
+func (e UserAccessExport) clientAllowedIPs() []string {
+	allowed := append([]string{}, e.RoutedSubnets...)
+	var prefixes []netip.Prefix
+	for _, s := range e.RoutedSubnets {
+		if p, err := netip.ParsePrefix(s); err == nil {
+			prefixes = append(prefixes, p)
+		}
+	}
+	for _, s := range e.DNSServers {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			continue
+		}
+		covered := false
+		for _, p := range prefixes {
+			if p.Contains(addr) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			allowed = append(allowed, netip.PrefixFrom(addr, addr.BitLen()).String())
+		}
+	}
+	return allowed
+}
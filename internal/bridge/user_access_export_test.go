@@ -0,0 +1,153 @@
+package bridge
+
+import (
+	"strings"
+	"testing"
+)
+
+func testUserAccessExport() UserAccessExport {
+	return UserAccessExport{
+		InterfaceName: "wg-access",
+		PublicKey:     "c2VydmVyLWtleQ==",
+		ListenPort:    51822,
+		RoutedSubnets: []string{"10.0.0.0/24"},
+		DNSServers:    []string{"10.0.0.53", "192.168.1.53"},
+		SearchDomains: []string{"corp.example"},
+		Peers: []UserAccessExportPeer{
+			{PublicKey: "pk-alice", Label: "alice", AllowedIPs: []string{"10.99.0.1/32"}},
+		},
+	}
+}
+
+func TestUserAccessExport_FindPeer(t *testing.T) {
+	e := testUserAccessExport()
+
+	for _, id := range []string{"alice", "pk-alice"} {
+		if _, ok := e.FindPeer(id); !ok {
+			t.Errorf("FindPeer(%q) not found", id)
+		}
+	}
+	if _, ok := e.FindPeer("bob"); ok {
+		t.Error("FindPeer(bob) should not be found")
+	}
+}
+
+func TestUserAccessExport_ClientConfig(t *testing.T) {
+	e := testUserAccessExport()
+	peer, _ := e.FindPeer("alice")
+
+	conf, err := e.ClientConfig(peer, "client-key", "bridge.example.com")
+	if err != nil {
+		t.Fatalf("ClientConfig: %v", err)
+	}
+	for _, want := range []string{
+		"PrivateKey = client-key\n",
+		"Address = 10.99.0.1/32\n",
+		"DNS = 10.0.0.53, 192.168.1.53, corp.example\n",
+		"PublicKey = c2VydmVyLWtleQ==\n",
+		"Endpoint = bridge.example.com:51822\n",
+		// 10.0.0.53 is inside the routed subnet; 192.168.1.53 needs a host route.
+		"AllowedIPs = 10.0.0.0/24, 192.168.1.53/32\n",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("config missing %q:\n%s", want, conf)
+		}
+	}
+}
+
+func TestUserAccessExport_ClientConfig_Placeholders(t *testing.T) {
+	e := testUserAccessExport()
+	e.PublicKey = ""
+	e.DNSServers, e.SearchDomains = nil, nil
+	peer, _ := e.FindPeer("alice")
+
+	conf, err := e.ClientConfig(peer, "", "[2001:db8::1]:4500")
+	if err != nil {
+		t.Fatalf("ClientConfig: %v", err)
+	}
+	for _, want := range []string{
+		"PrivateKey = <client private key>\n",
+		"PublicKey = <bridge public key>\n",
+		"Endpoint = [2001:db8::1]:4500\n",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("config missing %q:\n%s", want, conf)
+		}
+	}
+	if strings.Contains(conf, "DNS =") {
+		t.Errorf("config should have no DNS line:\n%s", conf)
+	}
+}
+
+func TestUserAccessExport_ClientConfig_NoEndpoint(t *testing.T) {
+	e := testUserAccessExport()
+	if _, err := e.ClientConfig(e.Peers[0], "", ""); err == nil {
+		t.Fatal("expected error without endpoint")
+	}
+}
+
+func TestUserAccessExport_ClientConfig_DefaultEndpoint(t *testing.T) {
+	e := testUserAccessExport()
+	e.Endpoint = "bridge.example.com:4500"
+
+	conf, err := e.ClientConfig(e.Peers[0], "", "")
+	if err != nil {
+		t.Fatalf("ClientConfig: %v", err)
+	}
+	if !strings.Contains(conf, "Endpoint = bridge.example.com:4500\n") {
+		t.Errorf("config should use the export's endpoint:\n%s", conf)
+	}
+}
+
+func TestUserAccessExport_Profiles(t *testing.T) {
+	e := testUserAccessExport()
+	e.Endpoint = "bridge.example.com:51822"
+	e.Peers = append(e.Peers,
+		UserAccessExportPeer{PublicKey: "pk-alice-2", Label: "alice", AllowedIPs: []string{"10.99.0.2/32"}},
+		UserAccessExportPeer{PublicKey: "pk-unlabeled", AllowedIPs: []string{"10.99.0.3/32"}},
+	)
+
+	profiles := e.Profiles()
+	if len(profiles) != 3 {
+		t.Fatalf("got %d profiles, want 3: %v", len(profiles), profiles)
+	}
+	p, ok := profiles["alice"]
+	if !ok || p.PublicKey != "pk-alice" {
+		t.Fatalf("profiles[alice] = %+v, want the first peer labeled alice", p)
+	}
+	if p.Endpoint != "bridge.example.com:51822" || p.ServerPublicKey != "c2VydmVyLWtleQ==" || p.PersistentKeepalive != 25 {
+		t.Errorf("profile = %+v", p)
+	}
+	if strings.Join(p.Addresses, ",") != "10.99.0.1/32" {
+		t.Errorf("Addresses = %v, want [10.99.0.1/32]", p.Addresses)
+	}
+	if strings.Join(p.AllowedIPs, ",") != "10.0.0.0/24,192.168.1.53/32" {
+		t.Errorf("AllowedIPs = %v, want the routed subnets and the DNS server outside them", p.AllowedIPs)
+	}
+	for _, want := range []string{
+		"PrivateKey = <client private key>\n",
+		"Endpoint = bridge.example.com:51822\n",
+	} {
+		if !strings.Contains(p.Config, want) {
+			t.Errorf("config missing %q:\n%s", want, p.Config)
+		}
+	}
+
+	// A duplicate label and a missing one fall back to the public key.
+	for _, key := range []string{"pk-alice-2", "pk-unlabeled"} {
+		if p, ok := profiles[key]; !ok || p.PublicKey != key {
+			t.Errorf("profiles[%s] = %+v", key, p)
+		}
+	}
+}
+
+func TestUserAccessExport_Profiles_NoEndpoint(t *testing.T) {
+	e := testUserAccessExport()
+	p := e.Profiles()["alice"]
+	if p.Endpoint != "" || p.Config != "" {
+		t.Errorf("profile without endpoint has Endpoint %q, Config %q; want both empty", p.Endpoint, p.Config)
+	}
+	if p.ServerPublicKey == "" || len(p.AllowedIPs) == 0 {
+		t.Errorf("profile without endpoint misses the other settings: %+v", p)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/plexsphere/plexd/internal/api"
 	"github.com/plexsphere/plexd/internal/reconcile"
@@ -65,7 +66,9 @@ func HandleUserAccessConfigUpdated(trigger ReconcileTrigger) api.EventHandler {
 // UserAccessReconcileHandler returns a reconcile.ReconcileHandler that updates
 // user access peers when the desired UserAccessConfig changes. It diffs the
 // desired peers against the currently active peers, adding missing and removing
-// stale peers.
+// stale peers, and applies the desired egress rate and DNS settings. Peers
+// whose access has already expired are not added; the expiry of peers that
+// remain is updated in place.
 func UserAccessReconcileHandler(mgr *UserAccessManager, logger *slog.Logger) reconcile.ReconcileHandler {
 	return func(_ context.Context, desired *api.StateResponse, _ reconcile.StateDiff) error {
 		if desired == nil || desired.UserAccessConfig == nil {
@@ -91,10 +94,36 @@ func UserAccessReconcileHandler(mgr *UserAccessManager, logger *slog.Logger) rec
 			}
 		}
 
-		// Add missing peers (present in desired state but not locally).
 		var errs []error
+
+		// Apply the desired egress rate.
+		if err := mgr.SetEgressRate(desired.UserAccessConfig.EgressRateKbps); err != nil {
+			logger.Error("user access reconcile: set egress rate failed",
+				"error", err,
+			)
+			errs = append(errs, err)
+		}
+
+		// Apply the desired DNS servers and search domains.
+		if err := mgr.SetDNS(desired.UserAccessConfig.DNSServers, desired.UserAccessConfig.SearchDomains); err != nil {
+			logger.Error("user access reconcile: set DNS failed",
+				"error", err,
+			)
+			errs = append(errs, err)
+		}
+
+		// Add missing peers (present in desired state but not locally).
+		now := time.Now()
 		for _, peer := range desired.UserAccessConfig.Peers {
 			if _, ok := currentSet[peer.PublicKey]; ok {
+				mgr.SetPeerExpiry(peer.PublicKey, peer.ExpiresAt)
+				continue
+			}
+			if peerExpired(peer, now) {
+				logger.Debug("user access reconcile: skipping expired peer",
+					"public_key", peer.PublicKey,
+					"expires_at", peer.ExpiresAt,
+				)
 				continue
 			}
 			if err := mgr.AddPeer(peer); err != nil {
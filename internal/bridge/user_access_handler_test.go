@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/plexsphere/plexd/internal/api"
 	"github.com/plexsphere/plexd/internal/reconcile"
@@ -430,3 +431,121 @@ func TestUserAccessReconcileHandler_Mixed(t *testing.T) {
 		t.Errorf("PeerCount = %d, want 2", mgr.UserAccessStatus().PeerCount)
 	}
 }
+
+func TestUserAccessReconcileHandler_EgressRate(t *testing.T) {
+	routes := &mockShapingRouteController{}
+	cfg := Config{
+		Enabled:                 true,
+		AccessInterface:         "eth1",
+		AccessSubnets:           []string{"10.0.0.0/24"},
+		UserAccessEnabled:       true,
+		UserAccessInterfaceName: "wg-access",
+		UserAccessListenPort:    51822,
+	}
+	cfg.ApplyDefaults()
+	mgr := NewUserAccessManager(&mockAccessController{}, routes, cfg, discardLogger())
+	if err := mgr.Setup(); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+
+	handler := UserAccessReconcileHandler(mgr, discardLogger())
+	desired := &api.StateResponse{
+		UserAccessConfig: &api.UserAccessConfig{
+			Enabled:        true,
+			InterfaceName:  "wg-access",
+			ListenPort:     51822,
+			EgressRateKbps: 2000,
+		},
+	}
+	if err := handler(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+		t.Fatalf("handler error = %v, want nil", err)
+	}
+	if st := mgr.UserAccessStatus().Shaping; st == nil || st.RateKbps != 2000 {
+		t.Fatalf("Shaping = %+v, want rate 2000", st)
+	}
+
+	desired.UserAccessConfig.EgressRateKbps = 0
+	if err := handler(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+		t.Fatalf("handler error = %v, want nil", err)
+	}
+	if n := len(routes.callsFor("ClearEgressRate")); n != 1 {
+		t.Errorf("expected 1 ClearEgressRate call, got %d", n)
+	}
+}
+
+func TestUserAccessReconcileHandler_DNS(t *testing.T) {
+	dns := &mockDNSConfigurator{}
+	cfg := Config{
+		Enabled:                 true,
+		AccessInterface:         "eth1",
+		AccessSubnets:           []string{"10.0.0.0/24"},
+		UserAccessEnabled:       true,
+		UserAccessInterfaceName: "wg-access",
+		UserAccessListenPort:    51822,
+	}
+	cfg.ApplyDefaults()
+	mgr := NewUserAccessManager(&mockAccessController{}, &mockRouteController{}, cfg, discardLogger())
+	mgr.SetDNSConfigurator(dns)
+	if err := mgr.Setup(); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+
+	handler := UserAccessReconcileHandler(mgr, discardLogger())
+	desired := &api.StateResponse{
+		UserAccessConfig: &api.UserAccessConfig{
+			Enabled:       true,
+			InterfaceName: "wg-access",
+			ListenPort:    51822,
+			DNSServers:    []string{"10.0.0.53"},
+			SearchDomains: []string{"corp.example"},
+		},
+	}
+	if err := handler(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+		t.Fatalf("handler error = %v, want nil", err)
+	}
+	if n := len(dns.callsFor("SetSplitDNS")); n != 1 {
+		t.Errorf("expected 1 SetSplitDNS call, got %d", n)
+	}
+
+	desired.UserAccessConfig.SearchDomains = []string{"bad domain"}
+	if err := handler(context.Background(), desired, reconcile.StateDiff{}); err == nil {
+		t.Error("expected error for invalid search domain")
+	}
+}
+
+func TestUserAccessReconcileHandler_Expiry(t *testing.T) {
+	ctrl := &mockAccessController{}
+	routes := &mockRouteController{}
+	mgr := newTestUserAccessManager(t, ctrl, routes)
+
+	later := time.Now().Add(time.Hour)
+	if err := mgr.AddPeer(api.UserAccessPeer{PublicKey: "pk-1", AllowedIPs: []string{"10.99.0.1/32"}, ExpiresAt: &later}); err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+	ctrl.resetAccess()
+
+	handler := UserAccessReconcileHandler(mgr, discardLogger())
+
+	past := time.Now().Add(-time.Minute)
+	desired := &api.StateResponse{
+		UserAccessConfig: &api.UserAccessConfig{
+			Enabled: true,
+			Peers: []api.UserAccessPeer{
+				// Access extended: no longer expires.
+				{PublicKey: "pk-1", AllowedIPs: []string{"10.99.0.1/32"}},
+				// Already expired: not added.
+				{PublicKey: "pk-2", AllowedIPs: []string{"10.99.0.2/32"}, ExpiresAt: &past},
+			},
+		},
+	}
+	if err := handler(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+		t.Fatalf("handler error = %v, want nil", err)
+	}
+
+	if n := len(ctrl.accessCallsFor("ConfigurePeer")); n != 0 {
+		t.Errorf("ConfigurePeer calls = %d, want 0", n)
+	}
+	if p := mgr.Export().Peers; len(p) != 1 || p[0].ExpiresAt != nil {
+		t.Errorf("export peers = %+v, want pk-1 without expiry", p)
+	}
+}
@@ -1,8 +1,13 @@
 package bridge
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/plexsphere/plexd/internal/api"
 )
@@ -524,3 +529,448 @@ func TestUserAccessManager_UserAccessCapabilities_Disabled(t *testing.T) {
 		t.Errorf("UserAccessCapabilities should be nil when disabled, got %v", caps)
 	}
 }
+
+// ---------------------------------------------------------------------------
+// UserAccessManager egress shaping tests
+// ---------------------------------------------------------------------------
+
+func newShapingUserAccessManager(t *testing.T, routes *mockShapingRouteController) *UserAccessManager {
+	t.Helper()
+	cfg := Config{
+		Enabled:                 true,
+		AccessInterface:         "eth1",
+		AccessSubnets:           []string{"10.0.0.0/24"},
+		UserAccessEnabled:       true,
+		UserAccessInterfaceName: "wg-access",
+		UserAccessListenPort:    51822,
+	}
+	cfg.ApplyDefaults()
+	mgr := NewUserAccessManager(&mockAccessController{}, routes, cfg, discardLogger())
+	if err := mgr.Setup(); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	return mgr
+}
+
+func TestUserAccessManager_SetEgressRate(t *testing.T) {
+	routes := &mockShapingRouteController{}
+	mgr := newShapingUserAccessManager(t, routes)
+
+	if err := mgr.SetEgressRate(5000); err != nil {
+		t.Fatalf("SetEgressRate: %v", err)
+	}
+	// Same rate again is a no-op.
+	if err := mgr.SetEgressRate(5000); err != nil {
+		t.Fatalf("SetEgressRate: %v", err)
+	}
+
+	calls := routes.callsFor("SetEgressRate")
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 SetEgressRate call, got %d", len(calls))
+	}
+	if calls[0].Args[0] != "wg-access" || calls[0].Args[1] != int64(5000) {
+		t.Errorf("SetEgressRate args = %v, want [wg-access 5000]", calls[0].Args)
+	}
+	st := mgr.UserAccessStatus().Shaping
+	if st == nil || !st.Active || st.RateKbps != 5000 || st.Target != "wg-access" {
+		t.Errorf("Shaping = %+v, want active wg-access at 5000", st)
+	}
+
+	if err := mgr.SetEgressRate(0); err != nil {
+		t.Fatalf("SetEgressRate(0): %v", err)
+	}
+	if n := len(routes.callsFor("ClearEgressRate")); n != 1 {
+		t.Errorf("expected 1 ClearEgressRate call, got %d", n)
+	}
+	if st := mgr.UserAccessStatus().Shaping; st != nil {
+		t.Errorf("Shaping = %+v, want nil after clearing", st)
+	}
+}
+
+func TestUserAccessManager_SetEgressRate_ErrorRetried(t *testing.T) {
+	routes := &mockShapingRouteController{setEgressRateErr: fmt.Errorf("qdisc failed")}
+	mgr := newShapingUserAccessManager(t, routes)
+
+	if err := mgr.SetEgressRate(5000); err != nil {
+		t.Fatalf("SetEgressRate: %v", err)
+	}
+	st := mgr.UserAccessStatus().Shaping
+	if st == nil || st.Active || st.Error != "qdisc failed" {
+		t.Fatalf("Shaping = %+v, want inactive with error", st)
+	}
+
+	routes.mu.Lock()
+	routes.setEgressRateErr = nil
+	routes.mu.Unlock()
+	if err := mgr.SetEgressRate(5000); err != nil {
+		t.Fatalf("SetEgressRate: %v", err)
+	}
+	if st := mgr.UserAccessStatus().Shaping; st == nil || !st.Active {
+		t.Errorf("Shaping = %+v, want active after retry", st)
+	}
+}
+
+func TestUserAccessManager_SetEgressRate_Negative(t *testing.T) {
+	mgr := newShapingUserAccessManager(t, &mockShapingRouteController{})
+
+	if err := mgr.SetEgressRate(-1); err == nil {
+		t.Fatal("expected error for negative rate")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// UserAccessManager DNS and export tests
+// ---------------------------------------------------------------------------
+
+func newDNSUserAccessManager(t *testing.T, dns *mockDNSConfigurator, report *mockReportWriter) *UserAccessManager {
+	t.Helper()
+	cfg := Config{
+		Enabled:                 true,
+		AccessInterface:         "eth1",
+		AccessSubnets:           []string{"10.0.0.0/24"},
+		UserAccessEnabled:       true,
+		UserAccessInterfaceName: "wg-access",
+		UserAccessListenPort:    51822,
+		UserAccessEndpoint:      "bridge.example.com",
+	}
+	cfg.ApplyDefaults()
+	mgr := NewUserAccessManager(&mockAccessController{}, &mockRouteController{}, cfg, discardLogger())
+	mgr.SetDNSConfigurator(dns)
+	mgr.SetReportWriter(report)
+	if err := mgr.Setup(); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	return mgr
+}
+
+func TestUserAccessManager_SetDNS(t *testing.T) {
+	dns := &mockDNSConfigurator{}
+	mgr := newDNSUserAccessManager(t, dns, &mockReportWriter{})
+
+	servers, domains := []string{"10.0.0.53"}, []string{"corp.example"}
+	if err := mgr.SetDNS(servers, domains); err != nil {
+		t.Fatalf("SetDNS: %v", err)
+	}
+	// Same values again are a no-op.
+	if err := mgr.SetDNS(servers, domains); err != nil {
+		t.Fatalf("SetDNS: %v", err)
+	}
+	if n := len(dns.callsFor("SetSplitDNS")); n != 1 {
+		t.Fatalf("expected 1 SetSplitDNS call, got %d", n)
+	}
+
+	e := mgr.Export()
+	if len(e.DNSServers) != 1 || e.DNSServers[0] != "10.0.0.53" || len(e.SearchDomains) != 1 {
+		t.Errorf("export DNS = %v %v, want [10.0.0.53] [corp.example]", e.DNSServers, e.SearchDomains)
+	}
+
+	if err := mgr.SetDNS(nil, nil); err != nil {
+		t.Fatalf("SetDNS(nil): %v", err)
+	}
+	if n := len(dns.callsFor("ClearSplitDNS")); n != 1 {
+		t.Errorf("expected 1 ClearSplitDNS call, got %d", n)
+	}
+}
+
+func TestUserAccessManager_SetDNS_ServersOnlyNotProgrammed(t *testing.T) {
+	dns := &mockDNSConfigurator{}
+	mgr := newDNSUserAccessManager(t, dns, &mockReportWriter{})
+
+	if err := mgr.SetDNS([]string{"10.0.0.53"}, nil); err != nil {
+		t.Fatalf("SetDNS: %v", err)
+	}
+	if n := len(dns.calls); n != 0 {
+		t.Errorf("expected no resolver calls without search domains, got %d", n)
+	}
+	if e := mgr.Export(); len(e.DNSServers) != 1 {
+		t.Errorf("export DNSServers = %v, want 1 server", e.DNSServers)
+	}
+}
+
+func TestUserAccessManager_SetDNS_RetriesAfterFailure(t *testing.T) {
+	dns := &mockDNSConfigurator{setErr: fmt.Errorf("resolved not running")}
+	mgr := newDNSUserAccessManager(t, dns, &mockReportWriter{})
+
+	servers, domains := []string{"10.0.0.53"}, []string{"corp.example"}
+	if err := mgr.SetDNS(servers, domains); err != nil {
+		t.Fatalf("SetDNS: %v", err)
+	}
+	dns.mu.Lock()
+	dns.setErr = nil
+	dns.mu.Unlock()
+	if err := mgr.SetDNS(servers, domains); err != nil {
+		t.Fatalf("SetDNS: %v", err)
+	}
+	if err := mgr.SetDNS(servers, domains); err != nil {
+		t.Fatalf("SetDNS: %v", err)
+	}
+	if n := len(dns.callsFor("SetSplitDNS")); n != 2 {
+		t.Errorf("expected 2 SetSplitDNS calls (failure and retry), got %d", n)
+	}
+}
+
+func TestUserAccessManager_SetDNS_Invalid(t *testing.T) {
+	mgr := newDNSUserAccessManager(t, &mockDNSConfigurator{}, &mockReportWriter{})
+
+	if err := mgr.SetDNS([]string{"not-an-ip"}, nil); err == nil {
+		t.Fatal("expected error for invalid DNS server")
+	}
+	if err := mgr.SetDNS(nil, []string{"bad domain"}); err == nil {
+		t.Fatal("expected error for invalid search domain")
+	}
+}
+
+func TestUserAccessManager_Teardown_ClearsDNS(t *testing.T) {
+	dns := &mockDNSConfigurator{}
+	mgr := newDNSUserAccessManager(t, dns, &mockReportWriter{})
+
+	if err := mgr.SetDNS([]string{"10.0.0.53"}, []string{"corp.example"}); err != nil {
+		t.Fatalf("SetDNS: %v", err)
+	}
+	if err := mgr.Teardown(); err != nil {
+		t.Fatalf("Teardown: %v", err)
+	}
+	if n := len(dns.callsFor("ClearSplitDNS")); n != 1 {
+		t.Errorf("expected 1 ClearSplitDNS call, got %d", n)
+	}
+}
+
+func TestUserAccessManager_WritesExport(t *testing.T) {
+	report := &mockReportWriter{}
+	mgr := newDNSUserAccessManager(t, &mockDNSConfigurator{}, report)
+
+	if err := mgr.AddPeer(api.UserAccessPeer{
+		PublicKey:  "pk-1",
+		AllowedIPs: []string{"10.99.0.1/32"},
+		PSK:        "secret-psk",
+		Label:      "alice",
+	}); err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+
+	payload := report.get(UserAccessExportKey)
+	if payload == nil {
+		t.Fatal("export not written")
+	}
+	if strings.Contains(string(payload), "secret-psk") {
+		t.Error("export must not contain the pre-shared key")
+	}
+	var e UserAccessExport
+	if err := json.Unmarshal(payload, &e); err != nil {
+		t.Fatalf("unmarshal export: %v", err)
+	}
+	if e.InterfaceName != "wg-access" || e.ListenPort != 51822 {
+		t.Errorf("export interface = %s:%d, want wg-access:51822", e.InterfaceName, e.ListenPort)
+	}
+	if e.Endpoint != "bridge.example.com:51822" {
+		t.Errorf("Endpoint = %q, want bridge.example.com:51822", e.Endpoint)
+	}
+	if len(e.RoutedSubnets) != 1 || e.RoutedSubnets[0] != "10.0.0.0/24" {
+		t.Errorf("RoutedSubnets = %v, want [10.0.0.0/24]", e.RoutedSubnets)
+	}
+	if len(e.Peers) != 1 || e.Peers[0].Label != "alice" {
+		t.Errorf("Peers = %+v, want alice", e.Peers)
+	}
+	var profiles map[string]UserAccessProfile
+	if err := json.Unmarshal(report.get(UserAccessProfilesKey), &profiles); err != nil {
+		t.Fatalf("unmarshal profiles: %v", err)
+	}
+	if p, ok := profiles["alice"]; !ok || p.PublicKey != "pk-1" || p.Config == "" {
+		t.Errorf("profiles = %+v, want alice with a config", profiles)
+	}
+
+	mgr.RemovePeer("pk-1")
+	if err := json.Unmarshal(report.get(UserAccessExportKey), &e); err != nil {
+		t.Fatalf("unmarshal export: %v", err)
+	}
+	if len(e.Peers) != 0 {
+		t.Errorf("Peers after removal = %+v, want none", e.Peers)
+	}
+	profiles = nil
+	if err := json.Unmarshal(report.get(UserAccessProfilesKey), &profiles); err != nil {
+		t.Fatalf("unmarshal profiles: %v", err)
+	}
+	if len(profiles) != 0 {
+		t.Errorf("profiles after removal = %+v, want none", profiles)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Peer expiry tests
+// ---------------------------------------------------------------------------
+
+func TestUserAccessManager_PeerExpiry(t *testing.T) {
+	ctrl := &mockAccessController{}
+	routes := &mockRouteController{}
+	mgr := newTestUserAccessManager(t, ctrl, routes)
+
+	expiresAt := time.Now().Add(20 * time.Millisecond)
+	peer := api.UserAccessPeer{PublicKey: "pk-1", AllowedIPs: []string{"10.99.0.1/32"}, Label: "contractor", ExpiresAt: &expiresAt}
+	if err := mgr.AddPeer(peer); err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+	if err := mgr.AddPeer(api.UserAccessPeer{PublicKey: "pk-2", AllowedIPs: []string{"10.99.0.2/32"}, Label: "bob"}); err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+
+	waitForCondition(t, 2*time.Second, func() bool {
+		return mgr.UserAccessStatus().PeerCount == 1
+	})
+
+	status := mgr.UserAccessStatus()
+	if status.ExpiredPeers != 1 {
+		t.Errorf("ExpiredPeers = %d, want 1", status.ExpiredPeers)
+	}
+	removeCalls := ctrl.accessCallsFor("RemovePeer")
+	if len(removeCalls) != 1 || removeCalls[0].Args[1] != "pk-1" {
+		t.Errorf("RemovePeer calls = %+v, want pk-1 once", removeCalls)
+	}
+	if keys := mgr.PeerPublicKeys(); len(keys) != 1 || keys[0] != "pk-2" {
+		t.Errorf("PeerPublicKeys = %v, want [pk-2]", keys)
+	}
+
+	audits, err := mgr.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(audits) != 1 {
+		t.Fatalf("audit entries = %d, want 1", len(audits))
+	}
+	a := audits[0]
+	if a.EventType != accessAuditEventType || a.Action != "expire" || a.Result != "success" {
+		t.Errorf("audit = %s/%s/%s, want %s/expire/success", a.EventType, a.Action, a.Result, accessAuditEventType)
+	}
+	if !strings.Contains(string(a.Subject), "pk-1") || !strings.Contains(a.Raw, "contractor") {
+		t.Errorf("audit subject = %s, raw = %q, want pk-1 and contractor", a.Subject, a.Raw)
+	}
+	if audits, _ := mgr.Collect(context.Background()); len(audits) != 0 {
+		t.Errorf("second Collect = %d entries, want 0", len(audits))
+	}
+}
+
+func TestUserAccessManager_AddPeer_Expired(t *testing.T) {
+	ctrl := &mockAccessController{}
+	routes := &mockRouteController{}
+	mgr := newTestUserAccessManager(t, ctrl, routes)
+
+	expiresAt := time.Now().Add(-time.Minute)
+	err := mgr.AddPeer(api.UserAccessPeer{PublicKey: "pk-1", AllowedIPs: []string{"10.99.0.1/32"}, ExpiresAt: &expiresAt})
+	if err == nil || !strings.Contains(err.Error(), "already expired") {
+		t.Fatalf("AddPeer error = %v, want already expired", err)
+	}
+	if len(ctrl.accessCallsFor("ConfigurePeer")) != 0 {
+		t.Error("ConfigurePeer should not be called for an expired peer")
+	}
+}
+
+func TestUserAccessManager_PeerExpiry_RemovedFirst(t *testing.T) {
+	ctrl := &mockAccessController{}
+	routes := &mockRouteController{}
+	mgr := newTestUserAccessManager(t, ctrl, routes)
+
+	expiresAt := time.Now().Add(20 * time.Millisecond)
+	if err := mgr.AddPeer(api.UserAccessPeer{PublicKey: "pk-1", AllowedIPs: []string{"10.99.0.1/32"}, ExpiresAt: &expiresAt}); err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+	mgr.RemovePeer("pk-1")
+	time.Sleep(60 * time.Millisecond)
+
+	if n := len(ctrl.accessCallsFor("RemovePeer")); n != 1 {
+		t.Errorf("RemovePeer calls = %d, want 1", n)
+	}
+	if got := mgr.UserAccessStatus().ExpiredPeers; got != 0 {
+		t.Errorf("ExpiredPeers = %d, want 0", got)
+	}
+	if audits, _ := mgr.Collect(context.Background()); len(audits) != 0 {
+		t.Errorf("audit entries = %d, want 0", len(audits))
+	}
+}
+
+func TestUserAccessManager_PeerExpiry_RemoveFails(t *testing.T) {
+	ctrl := &mockAccessController{removePeerErr: errors.New("netlink busy")}
+	routes := &mockRouteController{}
+	mgr := newTestUserAccessManager(t, ctrl, routes)
+
+	expiresAt := time.Now().Add(10 * time.Millisecond)
+	if err := mgr.AddPeer(api.UserAccessPeer{PublicKey: "pk-1", AllowedIPs: []string{"10.99.0.1/32"}, ExpiresAt: &expiresAt}); err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+	waitForCondition(t, 2*time.Second, func() bool {
+		return len(ctrl.accessCallsFor("RemovePeer")) == 1
+	})
+
+	// The peer stays tracked so the removal is retried.
+	status := mgr.UserAccessStatus()
+	if status.PeerCount != 1 || status.ExpiredPeers != 0 {
+		t.Errorf("PeerCount = %d, ExpiredPeers = %d, want 1, 0", status.PeerCount, status.ExpiredPeers)
+	}
+	if err := mgr.Teardown(); err == nil {
+		t.Error("Teardown error = nil, want remove peer error")
+	}
+}
+
+func TestUserAccessManager_SetPeerExpiry(t *testing.T) {
+	report := &mockReportWriter{}
+	mgr := newDNSUserAccessManager(t, &mockDNSConfigurator{}, report)
+
+	later := time.Now().Add(time.Hour)
+	for _, pk := range []string{"pk-1", "pk-2"} {
+		if err := mgr.AddPeer(api.UserAccessPeer{PublicKey: pk, AllowedIPs: []string{"10.99.0.1/32"}, ExpiresAt: &later}); err != nil {
+			t.Fatalf("AddPeer %s: %v", pk, err)
+		}
+	}
+
+	// Extending to no expiry is reflected in the export.
+	mgr.SetPeerExpiry("pk-2", nil)
+	var e UserAccessExport
+	if err := json.Unmarshal(report.get(UserAccessExportKey), &e); err != nil {
+		t.Fatalf("unmarshal export: %v", err)
+	}
+	for _, p := range e.Peers {
+		if p.PublicKey == "pk-1" && (p.ExpiresAt == nil || !p.ExpiresAt.Equal(later)) {
+			t.Errorf("pk-1 ExpiresAt = %v, want %v", p.ExpiresAt, later)
+		}
+		if p.PublicKey == "pk-2" && p.ExpiresAt != nil {
+			t.Errorf("pk-2 ExpiresAt = %v, want nil", p.ExpiresAt)
+		}
+	}
+
+	// Shortening to the past revokes the peer right away.
+	past := time.Now().Add(-time.Second)
+	mgr.SetPeerExpiry("pk-1", &past)
+	waitForCondition(t, 2*time.Second, func() bool {
+		return mgr.UserAccessStatus().ExpiredPeers == 1
+	})
+	if keys := mgr.PeerPublicKeys(); len(keys) != 1 || keys[0] != "pk-2" {
+		t.Errorf("PeerPublicKeys = %v, want [pk-2]", keys)
+	}
+}
+
+func TestUserAccessManager_MSSClamp(t *testing.T) {
+	routes := &mockClampingRouteController{}
+	cfg := Config{
+		Enabled:                 true,
+		AccessInterface:         "eth1",
+		AccessSubnets:           []string{"10.0.0.0/24"},
+		UserAccessEnabled:       true,
+		UserAccessInterfaceName: "wg-access",
+		UserAccessListenPort:    51822,
+		MSSClampInterfaces:      map[string]string{"wg-access": "1280"},
+	}
+	cfg.ApplyDefaults()
+	mgr := NewUserAccessManager(&mockAccessController{}, routes, cfg, discardLogger())
+	if err := mgr.Setup(); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	calls := routes.callsFor("SetMSSClamp")
+	if len(calls) != 1 || calls[0].Args[0] != "wg-access" || calls[0].Args[1] != 1280 {
+		t.Fatalf("SetMSSClamp calls = %v, want [wg-access 1280]", calls)
+	}
+
+	if err := mgr.Teardown(); err != nil {
+		t.Fatalf("Teardown: %v", err)
+	}
+	if calls := routes.callsFor("ClearMSSClamp"); len(calls) != 1 || calls[0].Args[0] != "wg-access" {
+		t.Errorf("ClearMSSClamp calls = %v, want [wg-access]", calls)
+	}
+}
@@ -1,5 +1,7 @@
 package bridge
 
+import "time"
+
 // VPNController abstracts OS-level WireGuard tunnel operations for site-to-site testability.
 // All methods must be idempotent: repeating an operation that is already applied returns nil.
 type VPNController interface {
@@ -22,3 +24,28 @@ type VPNController interface {
 	// Idempotent: removing a non-existent peer returns nil.
 	RemoveTunnelPeer(iface string, publicKey string) error
 }
+
+// errNoTunnelStats is reported when the VPN controller cannot read tunnel
+// traffic.
+const errNoTunnelStats = "VPN controller does not report traffic statistics"
+
+// TunnelPeerStats is the traffic of the remote peer of a site-to-site tunnel.
+type TunnelPeerStats struct {
+	// Endpoint is the current endpoint of the peer (host:port), or empty
+	// if the peer has none.
+	Endpoint string
+	RxBytes  uint64
+	TxBytes  uint64
+	// LastHandshake is zero if no handshake completed.
+	LastHandshake time.Time
+}
+
+// TunnelStatsReader is implemented by VPN controllers that can read the
+// traffic counters of a tunnel peer. SiteToSiteManager includes them in
+// SiteToSiteStatus; without a TunnelStatsReader the tunnels are reported
+// with an error instead.
+type TunnelStatsReader interface {
+	// TunnelPeerStats returns the traffic of the peer with the base64
+	// public key on the WireGuard interface iface.
+	TunnelPeerStats(iface, publicKey string) (TunnelPeerStats, error)
+}
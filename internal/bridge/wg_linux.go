@@ -0,0 +1,293 @@
+//go:build linux
+
+package bridge
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// NetlinkWGController implements VPNController and AccessController using
+// Linux netlink for link management and wgctrl for WireGuard device
+// configuration. It never shells out to ip(8) or wg(8).
+type NetlinkWGController struct {
+	logger *slog.Logger
+}
+
+// NewNetlinkWGController returns a new NetlinkWGController.
+func NewNetlinkWGController(logger *slog.Logger) *NetlinkWGController {
+	return &NetlinkWGController{logger: logger}
+}
+
+// CreateTunnelInterface creates a WireGuard interface for a site-to-site tunnel.
+func (c *NetlinkWGController) CreateTunnelInterface(name string, listenPort int) error {
+	return c.createInterface(name, listenPort)
+}
+
+// RemoveTunnelInterface removes the site-to-site WireGuard interface.
+func (c *NetlinkWGController) RemoveTunnelInterface(name string) error {
+	return c.removeInterface(name)
+}
+
+// ConfigureTunnelPeer configures the remote peer of a site-to-site tunnel.
+func (c *NetlinkWGController) ConfigureTunnelPeer(iface string, publicKey string, allowedIPs []string, endpoint string, psk string) error {
+	return c.configurePeer(iface, publicKey, allowedIPs, endpoint, psk)
+}
+
+// RemoveTunnelPeer removes the remote peer of a site-to-site tunnel.
+func (c *NetlinkWGController) RemoveTunnelPeer(iface string, publicKey string) error {
+	return c.removePeer(iface, publicKey)
+}
+
+// CreateInterface creates a WireGuard interface for user access.
+func (c *NetlinkWGController) CreateInterface(name string, listenPort int) error {
+	return c.createInterface(name, listenPort)
+}
+
+// RemoveInterface removes the user access WireGuard interface.
+func (c *NetlinkWGController) RemoveInterface(name string) error {
+	return c.removeInterface(name)
+}
+
+// ConfigurePeer adds or updates a user access peer. User access peers have
+// no fixed endpoint; WireGuard learns it from the first handshake.
+func (c *NetlinkWGController) ConfigurePeer(iface string, publicKey string, allowedIPs []string, psk string) error {
+	return c.configurePeer(iface, publicKey, allowedIPs, "", psk)
+}
+
+// RemovePeer removes a user access peer.
+func (c *NetlinkWGController) RemovePeer(iface string, publicKey string) error {
+	return c.removePeer(iface, publicKey)
+}
+
+// createInterface creates the WireGuard link if missing, sets the listen
+// port, generates a private key when the device has none, and brings the
+// link up. Idempotent: an existing interface is reconfigured in place.
+func (c *NetlinkWGController) createInterface(name string, listenPort int) error {
+	la := netlink.NewLinkAttrs()
+	la.Name = name
+	link := &netlink.GenericLink{LinkAttrs: la, LinkType: "wireguard"}
+
+	if err := netlink.LinkAdd(link); err != nil && !errors.Is(err, syscall.EEXIST) {
+		return fmt.Errorf("bridge: create interface %q: %w", name, err)
+	}
+
+	client, err := wgctrl.New()
+	if err != nil {
+		return fmt.Errorf("bridge: create interface %q: open wgctrl: %w", name, err)
+	}
+	defer client.Close()
+
+	cfg := wgtypes.Config{ListenPort: &listenPort}
+
+	dev, err := client.Device(name)
+	if err != nil {
+		return fmt.Errorf("bridge: create interface %q: read device: %w", name, err)
+	}
+	if dev.PrivateKey == (wgtypes.Key{}) {
+		key, err := wgtypes.GeneratePrivateKey()
+		if err != nil {
+			return fmt.Errorf("bridge: create interface %q: generate key: %w", name, err)
+		}
+		cfg.PrivateKey = &key
+	}
+
+	if err := client.ConfigureDevice(name, cfg); err != nil {
+		return fmt.Errorf("bridge: create interface %q: configure device: %w", name, err)
+	}
+
+	existing, err := netlink.LinkByName(name)
+	if err != nil {
+		return fmt.Errorf("bridge: create interface %q: lookup: %w", name, err)
+	}
+	if err := netlink.LinkSetUp(existing); err != nil {
+		return fmt.Errorf("bridge: create interface %q: set up: %w", name, err)
+	}
+
+	c.logger.Debug("wireguard interface created",
+		"component", "bridge",
+		"interface", name,
+		"listen_port", listenPort,
+	)
+	return nil
+}
+
+// removeInterface deletes the named link. Idempotent: a missing link returns nil.
+func (c *NetlinkWGController) removeInterface(name string) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		var notFound netlink.LinkNotFoundError
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return fmt.Errorf("bridge: remove interface %q: %w", name, err)
+	}
+	if err := netlink.LinkDel(link); err != nil {
+		return fmt.Errorf("bridge: remove interface %q: %w", name, err)
+	}
+
+	c.logger.Debug("wireguard interface removed",
+		"component", "bridge",
+		"interface", name,
+	)
+	return nil
+}
+
+// configurePeer adds or replaces the allowed IPs of a peer on iface.
+func (c *NetlinkWGController) configurePeer(iface, publicKey string, allowedIPs []string, endpoint, psk string) error {
+	peer, err := buildPeerConfig(publicKey, allowedIPs, endpoint, psk)
+	if err != nil {
+		return fmt.Errorf("bridge: configure peer on %q: %w", iface, err)
+	}
+
+	client, err := wgctrl.New()
+	if err != nil {
+		return fmt.Errorf("bridge: configure peer on %q: open wgctrl: %w", iface, err)
+	}
+	defer client.Close()
+
+	if err := client.ConfigureDevice(iface, wgtypes.Config{Peers: []wgtypes.PeerConfig{peer}}); err != nil {
+		return fmt.Errorf("bridge: configure peer on %q: %w", iface, err)
+	}
+
+	c.logger.Debug("wireguard peer configured",
+		"component", "bridge",
+		"interface", iface,
+		"allowed_ips", allowedIPs,
+	)
+	return nil
+}
+
+// InterfacePublicKey returns the public key of the WireGuard interface name.
+func (c *NetlinkWGController) InterfacePublicKey(name string) (string, error) {
+	client, err := wgctrl.New()
+	if err != nil {
+		return "", fmt.Errorf("bridge: read public key %q: open wgctrl: %w", name, err)
+	}
+	defer client.Close()
+
+	dev, err := client.Device(name)
+	if err != nil {
+		return "", fmt.Errorf("bridge: read public key %q: %w", name, err)
+	}
+	return dev.PublicKey.String(), nil
+}
+
+// TunnelPeerStats returns the endpoint, traffic counters, and last handshake
+// of the peer with publicKey on the WireGuard interface iface.
+func (c *NetlinkWGController) TunnelPeerStats(iface, publicKey string) (TunnelPeerStats, error) {
+	key, err := parseWGKey(publicKey)
+	if err != nil {
+		return TunnelPeerStats{}, fmt.Errorf("bridge: read peer stats on %q: public key: %w", iface, err)
+	}
+
+	client, err := wgctrl.New()
+	if err != nil {
+		return TunnelPeerStats{}, fmt.Errorf("bridge: read peer stats on %q: open wgctrl: %w", iface, err)
+	}
+	defer client.Close()
+
+	dev, err := client.Device(iface)
+	if err != nil {
+		return TunnelPeerStats{}, fmt.Errorf("bridge: read peer stats on %q: %w", iface, err)
+	}
+	for _, p := range dev.Peers {
+		if p.PublicKey != key {
+			continue
+		}
+		stats := TunnelPeerStats{
+			RxBytes:       uint64(p.ReceiveBytes),
+			TxBytes:       uint64(p.TransmitBytes),
+			LastHandshake: p.LastHandshakeTime,
+		}
+		if p.Endpoint != nil {
+			stats.Endpoint = p.Endpoint.String()
+		}
+		return stats, nil
+	}
+	return TunnelPeerStats{}, fmt.Errorf("bridge: read peer stats on %q: peer not configured", iface)
+}
+
+// removePeer removes a peer from iface. Idempotent: a missing interface or
+// peer returns nil.
+func (c *NetlinkWGController) removePeer(iface, publicKey string) error {
+	key, err := parseWGKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("bridge: remove peer from %q: public key: %w", iface, err)
+	}
+
+	client, err := wgctrl.New()
+	if err != nil {
+		return fmt.Errorf("bridge: remove peer from %q: open wgctrl: %w", iface, err)
+	}
+	defer client.Close()
+
+	err = client.ConfigureDevice(iface, wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{{PublicKey: key, Remove: true}},
+	})
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("bridge: remove peer from %q: %w", iface, err)
+	}
+	return nil
+}
+
+// buildPeerConfig translates controller arguments into a wgtypes.PeerConfig
+// that replaces the peer's allowed IPs.
+func buildPeerConfig(publicKey string, allowedIPs []string, endpoint, psk string) (wgtypes.PeerConfig, error) {
+	key, err := parseWGKey(publicKey)
+	if err != nil {
+		return wgtypes.PeerConfig{}, fmt.Errorf("public key: %w", err)
+	}
+
+	peer := wgtypes.PeerConfig{
+		PublicKey:         key,
+		ReplaceAllowedIPs: true,
+	}
+
+	for _, cidr := range allowedIPs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return wgtypes.PeerConfig{}, fmt.Errorf("allowed IP %q: %w", cidr, err)
+		}
+		peer.AllowedIPs = append(peer.AllowedIPs, *ipNet)
+	}
+
+	if endpoint != "" {
+		addr, err := net.ResolveUDPAddr("udp", endpoint)
+		if err != nil {
+			return wgtypes.PeerConfig{}, fmt.Errorf("endpoint %q: %w", endpoint, err)
+		}
+		peer.Endpoint = addr
+	}
+
+	if psk != "" {
+		pskKey, err := parseWGKey(psk)
+		if err != nil {
+			return wgtypes.PeerConfig{}, fmt.Errorf("psk: %w", err)
+		}
+		peer.PresharedKey = &pskKey
+	}
+
+	return peer, nil
+}
+
+// parseWGKey decodes a base64-encoded 32-byte WireGuard key.
+func parseWGKey(s string) (wgtypes.Key, error) {
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return wgtypes.Key{}, err
+	}
+	return wgtypes.NewKey(raw)
+}
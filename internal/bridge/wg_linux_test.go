@@ -0,0 +1,102 @@
+//go:build linux
+
+package bridge
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// Compile-time checks that NetlinkWGController implements both WireGuard controller interfaces.
+var (
+	_ VPNController    = (*NetlinkWGController)(nil)
+	_ AccessController = (*NetlinkWGController)(nil)
+)
+
+func testWGKey() string {
+	return base64.StdEncoding.EncodeToString(make([]byte, 32))
+}
+
+func TestBuildPeerConfig(t *testing.T) {
+	peer, err := buildPeerConfig(testWGKey(), []string{"10.0.0.0/24", "10.1.0.0/16"}, "192.0.2.1:51820", testWGKey())
+	if err != nil {
+		t.Fatalf("buildPeerConfig: %v", err)
+	}
+	if !peer.ReplaceAllowedIPs {
+		t.Error("ReplaceAllowedIPs = false, want true")
+	}
+	if len(peer.AllowedIPs) != 2 {
+		t.Errorf("AllowedIPs len = %d, want 2", len(peer.AllowedIPs))
+	}
+	if peer.Endpoint == nil || peer.Endpoint.Port != 51820 {
+		t.Errorf("Endpoint = %v, want port 51820", peer.Endpoint)
+	}
+	if peer.PresharedKey == nil {
+		t.Error("PresharedKey = nil, want set")
+	}
+}
+
+func TestBuildPeerConfig_NoEndpointNoPSK(t *testing.T) {
+	peer, err := buildPeerConfig(testWGKey(), []string{"10.0.0.2/32"}, "", "")
+	if err != nil {
+		t.Fatalf("buildPeerConfig: %v", err)
+	}
+	if peer.Endpoint != nil {
+		t.Errorf("Endpoint = %v, want nil", peer.Endpoint)
+	}
+	if peer.PresharedKey != nil {
+		t.Error("PresharedKey set, want nil")
+	}
+}
+
+func TestBuildPeerConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        string
+		allowedIPs []string
+		endpoint   string
+		psk        string
+		wantErr    string
+	}{
+		{"bad key", "not-base64!", nil, "", "", "public key"},
+		{"short key", base64.StdEncoding.EncodeToString([]byte("short")), nil, "", "", "public key"},
+		{"bad CIDR", testWGKey(), []string{"10.0.0.0"}, "", "", "allowed IP"},
+		{"bad endpoint", testWGKey(), nil, "no-port", "", "endpoint"},
+		{"bad psk", testWGKey(), nil, "", "short", "psk"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildPeerConfig(tt.key, tt.allowedIPs, tt.endpoint, tt.psk)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want substring %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNetlinkWGController_RemoveInterfaceNonExistent(t *testing.T) {
+	ctrl := NewNetlinkWGController(discardLogger())
+	if err := ctrl.RemoveInterface("plexd-nonexist"); err != nil {
+		t.Errorf("RemoveInterface on missing link = %v, want nil", err)
+	}
+}
+
+func TestNewBackend_Netlink(t *testing.T) {
+	b, err := NewBackend(Config{}, discardLogger())
+	if err != nil {
+		t.Fatalf("NewBackend: %v", err)
+	}
+	if b.Name != BackendNetlink {
+		t.Errorf("Name = %q, want %q", b.Name, BackendNetlink)
+	}
+	if _, ok := b.Routes.(*NetlinkRouteController); !ok {
+		t.Errorf("Routes = %T, want *NetlinkRouteController", b.Routes)
+	}
+	if _, ok := b.VPN.(*NetlinkWGController); !ok {
+		t.Errorf("VPN = %T, want *NetlinkWGController", b.VPN)
+	}
+}
@@ -0,0 +1,54 @@
+// Package capabilities keeps the capabilities the control plane knows of a
+// node current: the payload is recomputed whenever hooks, feature gates, or
+// the binary change and is pushed with UpdateCapabilities.
+package capabilities
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultDebounce is the default delay between the first change and
+// publishing. Changes arriving within the window are coalesced.
+const DefaultDebounce = 2 * time.Second
+
+// DefaultRetryInterval is the default delay before a failed publish is
+// retried.
+const DefaultRetryInterval = 30 * time.Second
+
+// Config holds the configuration for capability publishing.
+type Config struct {
+	// Debounce is the delay between the first change and publishing.
+	// Must be between 100ms and 1m.
+	// Default: 2s
+	Debounce time.Duration
+
+	// RetryInterval is the delay before a failed publish is retried.
+	// Must be at least 1s.
+	// Default: 30s
+	RetryInterval time.Duration
+}
+
+// ApplyDefaults sets default values for zero-valued fields.
+func (c *Config) ApplyDefaults() {
+	if c.Debounce == 0 {
+		c.Debounce = DefaultDebounce
+	}
+	if c.RetryInterval == 0 {
+		c.RetryInterval = DefaultRetryInterval
+	}
+}
+
+// Validate checks that configuration values are within acceptable ranges.
+func (c *Config) Validate() error {
+	if c.Debounce < 100*time.Millisecond {
+		return errors.New("capabilities: config: Debounce must be at least 100ms")
+	}
+	if c.Debounce > time.Minute {
+		return errors.New("capabilities: config: Debounce must be at most 1m")
+	}
+	if c.RetryInterval < time.Second {
+		return errors.New("capabilities: config: RetryInterval must be at least 1s")
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package capabilities
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfig_ApplyDefaults(t *testing.T) {
+	var cfg Config
+	cfg.ApplyDefaults()
+	if cfg.Debounce != DefaultDebounce || cfg.RetryInterval != DefaultRetryInterval {
+		t.Errorf("defaults = %+v", cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate defaults: %v", err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"debounce too short", Config{Debounce: 10 * time.Millisecond, RetryInterval: time.Minute}},
+		{"debounce too long", Config{Debounce: 2 * time.Minute, RetryInterval: time.Minute}},
+		{"retry too short", Config{Debounce: time.Second, RetryInterval: 100 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); err == nil {
+				t.Error("Validate = nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package capabilities
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/fsutil"
+	"github.com/plexsphere/plexd/internal/integrity"
+)
+
+// stateFileName records the digest of the capabilities last accepted by the
+// control plane in the data directory, so that a restart with an upgraded
+// binary or changed hooks publishes again while an unchanged restart does
+// not.
+const stateFileName = "capabilities.json"
+
+// Publisher publishes the node's capabilities. *api.ControlPlane satisfies
+// this interface.
+type Publisher interface {
+	UpdateCapabilities(ctx context.Context, nodeID string, caps api.CapabilitiesPayload) error
+}
+
+// ActionSource reports the builtin actions and hooks of the node.
+// *actions.Executor satisfies this interface.
+type ActionSource interface {
+	Capabilities() ([]api.ActionInfo, []api.HookInfo)
+}
+
+// FeatureGateSource reports the feature gates of the node.
+// *featuregate.Gates satisfies this interface.
+type FeatureGateSource interface {
+	Capabilities() []api.FeatureGateInfo
+}
+
+// state is the content of stateFileName.
+type state struct {
+	Digest string `json:"digest"`
+}
+
+// Manager computes the capabilities of the node from its sources and
+// publishes them whenever they change. Sources signal changes through
+// Trigger; changes within Config.Debounce are published together, and a
+// payload equal to the one last accepted is not published again.
+type Manager struct {
+	cfg       Config
+	publisher Publisher
+	dataDir   string
+	logger    *slog.Logger
+	trigger   chan struct{}
+
+	mu      sync.Mutex
+	binary  *api.BinaryInfo
+	actions ActionSource
+	gates   FeatureGateSource
+}
+
+// NewManager creates a Manager that publishes through publisher and keeps
+// its state in dataDir. Config defaults are applied automatically.
+func NewManager(cfg Config, publisher Publisher, dataDir string, logger *slog.Logger) *Manager {
+	cfg.ApplyDefaults()
+	return &Manager{
+		cfg:       cfg,
+		publisher: publisher,
+		dataDir:   dataDir,
+		logger:    logger.With("component", "capabilities"),
+		trigger:   make(chan struct{}, 1),
+	}
+}
+
+// SetBinary sets the binary reported in the capabilities.
+func (m *Manager) SetBinary(info *api.BinaryInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.binary = info
+}
+
+// SetActionSource sets the source of the builtin actions and hooks.
+// Changes of the hooks must be signalled with Trigger.
+func (m *Manager) SetActionSource(s ActionSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.actions = s
+}
+
+// SetFeatureGates sets the source of the feature gates. Changes of the
+// gates must be signalled with Trigger.
+func (m *Manager) SetFeatureGates(s FeatureGateSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gates = s
+}
+
+// Payload returns the current capabilities. Actions and hooks are sorted by
+// name so that equal capabilities have equal payloads.
+func (m *Manager) Payload() api.CapabilitiesPayload {
+	m.mu.Lock()
+	binary, actions, gates := m.binary, m.actions, m.gates
+	m.mu.Unlock()
+
+	caps := api.CapabilitiesPayload{
+		BuiltinActions: []api.ActionInfo{},
+		Hooks:          []api.HookInfo{},
+	}
+	if binary != nil {
+		b := *binary
+		caps.Binary = &b
+	}
+	if actions != nil {
+		caps.BuiltinActions, caps.Hooks = actions.Capabilities()
+		slices.SortFunc(caps.BuiltinActions, func(a, b api.ActionInfo) int { return strings.Compare(a.Name, b.Name) })
+		slices.SortFunc(caps.Hooks, func(a, b api.HookInfo) int { return strings.Compare(a.Name, b.Name) })
+	}
+	if gates != nil {
+		caps.FeatureGates = gates.Capabilities()
+	}
+	return caps
+}
+
+// Trigger signals that a source changed. It never blocks; triggers before
+// the pending publish are coalesced.
+func (m *Manager) Trigger() {
+	select {
+	case m.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run publishes the capabilities of nodeID once at start, unless the
+// control plane already accepted them, and then after every Trigger until
+// ctx is cancelled. A failed publish is retried after Config.RetryInterval.
+func (m *Manager) Run(ctx context.Context, nodeID string) error {
+	published, err := m.loadState()
+	if err != nil {
+		m.logger.Warn("capabilities state unreadable, publishing again", "error", err)
+	}
+
+	m.logger.Info("capability publishing started", "debounce", m.cfg.Debounce.String())
+
+	timer := time.NewTimer(0)
+	armed := true
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-m.trigger:
+			if !armed {
+				timer.Reset(m.cfg.Debounce)
+				armed = true
+			}
+		case <-timer.C:
+			armed = false
+			digest, err := m.publish(ctx, nodeID, published)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				m.logger.Warn("publish capabilities failed", "error", err, "retry_in", m.cfg.RetryInterval.String())
+				timer.Reset(m.cfg.RetryInterval)
+				armed = true
+				continue
+			}
+			published = digest
+		}
+	}
+}
+
+// publish publishes the current capabilities unless their digest equals
+// published, and returns the digest of the capabilities the control plane
+// now has.
+func (m *Manager) publish(ctx context.Context, nodeID, published string) (string, error) {
+	caps := m.Payload()
+	digest, err := payloadDigest(caps)
+	if err != nil {
+		return "", err
+	}
+	if digest == published {
+		m.logger.Debug("capabilities unchanged")
+		return digest, nil
+	}
+	if err := m.publisher.UpdateCapabilities(ctx, nodeID, caps); err != nil {
+		return "", fmt.Errorf("capabilities: publish: %w", err)
+	}
+
+	attrs := []any{
+		"actions", len(caps.BuiltinActions),
+		"hooks", len(caps.Hooks),
+		"feature_gates", len(caps.FeatureGates),
+	}
+	if caps.Binary != nil {
+		attrs = append(attrs, "version", caps.Binary.Version)
+	}
+	m.logger.Info("capabilities published", attrs...)
+
+	if err := m.saveState(digest); err != nil {
+		m.logger.Warn("capabilities state not saved", "error", err)
+	}
+	return digest, nil
+}
+
+func (m *Manager) loadState() (string, error) {
+	if m.dataDir == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(filepath.Join(m.dataDir, stateFileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("capabilities: read state: %w", err)
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return "", fmt.Errorf("capabilities: parse state: %w", err)
+	}
+	return st.Digest, nil
+}
+
+func (m *Manager) saveState(digest string) error {
+	if m.dataDir == "" {
+		return nil
+	}
+	data, err := json.Marshal(state{Digest: digest})
+	if err != nil {
+		return fmt.Errorf("capabilities: marshal state: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(m.dataDir, stateFileName, data, 0o600); err != nil {
+		return fmt.Errorf("capabilities: write state: %w", err)
+	}
+	return nil
+}
+
+// payloadDigest returns the hex SHA-256 of the JSON encoding of caps.
+func payloadDigest(caps api.CapabilitiesPayload) (string, error) {
+	data, err := json.Marshal(caps)
+	if err != nil {
+		return "", fmt.Errorf("capabilities: marshal payload: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Binary returns the BinaryInfo of the executable at path with the given
+// version. The checksum is empty when path is empty or cannot be read.
+func Binary(version, path string, logger *slog.Logger) *api.BinaryInfo {
+	info := &api.BinaryInfo{Version: version}
+	if path == "" {
+		return info
+	}
+	checksum, err := integrity.HashFile(path)
+	if err != nil {
+		logger.Warn("binary checksum unavailable for capabilities", "component", "capabilities", "path", path, "error", err)
+		return info
+	}
+	info.Checksum = checksum
+	return info
+}
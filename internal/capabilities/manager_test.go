@@ -0,0 +1,209 @@
+package capabilities
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+type fakePublisher struct {
+	mu    sync.Mutex
+	err   error
+	calls []api.CapabilitiesPayload
+}
+
+func (p *fakePublisher) UpdateCapabilities(_ context.Context, nodeID string, caps api.CapabilitiesPayload) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if nodeID != "node-1" {
+		return errors.New("unexpected node ID " + nodeID)
+	}
+	if p.err != nil {
+		return p.err
+	}
+	p.calls = append(p.calls, caps)
+	return nil
+}
+
+func (p *fakePublisher) setErr(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+func (p *fakePublisher) published() []api.CapabilitiesPayload {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]api.CapabilitiesPayload(nil), p.calls...)
+}
+
+type fakeActions struct {
+	mu    sync.Mutex
+	hooks []api.HookInfo
+}
+
+func (a *fakeActions) Capabilities() ([]api.ActionInfo, []api.HookInfo) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	actions := []api.ActionInfo{{Name: "restart"}, {Name: "diagnostics"}}
+	return actions, append([]api.HookInfo(nil), a.hooks...)
+}
+
+func (a *fakeActions) setHooks(hooks ...api.HookInfo) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.hooks = hooks
+}
+
+type fakeGates []api.FeatureGateInfo
+
+func (g fakeGates) Capabilities() []api.FeatureGateInfo { return g }
+
+// startManager runs m until the test ends.
+func startManager(t *testing.T, m *Manager) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx, "node-1") }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// waitPublished waits until pub has n calls.
+func waitPublished(t *testing.T, pub *fakePublisher, n int) []api.CapabilitiesPayload {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if calls := pub.published(); len(calls) >= n {
+			return calls
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("published %d times, want %d", len(pub.published()), n)
+	return nil
+}
+
+func TestManager_Payload(t *testing.T) {
+	m := NewManager(Config{}, &fakePublisher{}, "", testLogger())
+	caps := m.Payload()
+	if caps.BuiltinActions == nil || caps.Hooks == nil || caps.Binary != nil || caps.FeatureGates != nil {
+		t.Errorf("Payload() without sources = %+v", caps)
+	}
+
+	actions := &fakeActions{}
+	actions.setHooks(api.HookInfo{Name: "rotate"}, api.HookInfo{Name: "backup"})
+	m.SetActionSource(actions)
+	m.SetFeatureGates(fakeGates{{Name: "DeltaState", Enabled: true}})
+	m.SetBinary(&api.BinaryInfo{Version: "1.2.0", Checksum: "abc"})
+
+	caps = m.Payload()
+	if caps.BuiltinActions[0].Name != "diagnostics" || caps.Hooks[0].Name != "backup" {
+		t.Errorf("actions, hooks = %+v, %+v, want sorted by name", caps.BuiltinActions, caps.Hooks)
+	}
+	if caps.Binary == nil || caps.Binary.Version != "1.2.0" || len(caps.FeatureGates) != 1 {
+		t.Errorf("Payload() = %+v", caps)
+	}
+}
+
+func TestManager_PublishesChanges(t *testing.T) {
+	pub := &fakePublisher{}
+	m := NewManager(Config{Debounce: 20 * time.Millisecond}, pub, t.TempDir(), testLogger())
+	actions := &fakeActions{}
+	m.SetActionSource(actions)
+	startManager(t, m)
+
+	// Published once at start.
+	waitPublished(t, pub, 1)
+
+	// Changes within the debounce window are published together.
+	actions.setHooks(api.HookInfo{Name: "backup"})
+	m.Trigger()
+	actions.setHooks(api.HookInfo{Name: "backup"}, api.HookInfo{Name: "rotate"})
+	m.Trigger()
+	calls := waitPublished(t, pub, 2)
+	if len(calls[1].Hooks) != 2 {
+		t.Errorf("published hooks = %+v, want both hooks", calls[1].Hooks)
+	}
+
+	// A trigger without a change publishes nothing.
+	m.Trigger()
+	time.Sleep(60 * time.Millisecond)
+	if n := len(pub.published()); n != 2 {
+		t.Errorf("published %d times after an unchanged trigger, want 2", n)
+	}
+}
+
+func TestManager_SkipsPublishedAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	binary := &api.BinaryInfo{Version: "1.2.0"}
+
+	first := &fakePublisher{}
+	m := NewManager(Config{}, first, dir, testLogger())
+	m.SetBinary(binary)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx, "node-1") }()
+	waitPublished(t, first, 1)
+	cancel()
+	<-done
+	if _, err := os.Stat(filepath.Join(dir, stateFileName)); err != nil {
+		t.Fatalf("state not saved: %v", err)
+	}
+
+	// A restart with the same binary does not publish.
+	second := &fakePublisher{}
+	m = NewManager(Config{}, second, dir, testLogger())
+	m.SetBinary(binary)
+	startManager(t, m)
+	time.Sleep(50 * time.Millisecond)
+	if n := len(second.published()); n != 0 {
+		t.Errorf("published %d times after an unchanged restart, want 0", n)
+	}
+
+	// A restart with an upgraded binary publishes.
+	third := &fakePublisher{}
+	m = NewManager(Config{}, third, dir, testLogger())
+	m.SetBinary(&api.BinaryInfo{Version: "1.3.0"})
+	startManager(t, m)
+	if calls := waitPublished(t, third, 1); calls[0].Binary.Version != "1.3.0" {
+		t.Errorf("published binary = %+v, want 1.3.0", calls[0].Binary)
+	}
+}
+
+func TestManager_RetriesFailedPublish(t *testing.T) {
+	pub := &fakePublisher{err: errors.New("unavailable")}
+	m := NewManager(Config{RetryInterval: 20 * time.Millisecond}, pub, t.TempDir(), testLogger())
+	startManager(t, m)
+
+	time.Sleep(30 * time.Millisecond)
+	pub.setErr(nil)
+	waitPublished(t, pub, 1)
+}
+
+func TestBinary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plexd")
+	if err := os.WriteFile(path, []byte("binary"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	info := Binary("1.2.0", path, testLogger())
+	if info.Version != "1.2.0" || len(info.Checksum) != 64 {
+		t.Errorf("Binary() = %+v, want version and SHA-256 checksum", info)
+	}
+	if info := Binary("1.2.0", filepath.Join(t.TempDir(), "missing"), testLogger()); info.Checksum != "" {
+		t.Errorf("Binary() of a missing file = %+v, want no checksum", info)
+	}
+}
@@ -0,0 +1,48 @@
+package cni
+
+import (
+	"context"
+
+	"github.com/plexsphere/plexd/internal/auditfwd"
+)
+
+// MutationRecorder records host changes for the audit trail. Satisfied by
+// *auditfwd.Recorder.
+type MutationRecorder interface {
+	Record(ctx context.Context, m auditfwd.Mutation)
+}
+
+// auditedHost is a HostNetwork that records every change it passes on to
+// the wrapped HostNetwork.
+type auditedHost struct {
+	host HostNetwork
+	rec  MutationRecorder
+}
+
+// NewAuditedHostNetwork returns a HostNetwork that passes every change to
+// host and records it with rec.
+func NewAuditedHostNetwork(host HostNetwork, rec MutationRecorder) HostNetwork {
+	return &auditedHost{host: host, rec: rec}
+}
+
+func (a *auditedHost) EnableForwarding(iface string) error {
+	err := a.host.EnableForwarding(iface)
+	a.record("forwarding_enable", iface, map[string]any{"interface": iface}, err)
+	return err
+}
+
+func (a *auditedHost) AddRoute(prefix, iface string) error {
+	err := a.host.AddRoute(prefix, iface)
+	a.record("route_add", prefix+" dev "+iface, map[string]any{"prefix": prefix, "interface": iface}, err)
+	return err
+}
+
+func (a *auditedHost) RemoveRoute(prefix, iface string) error {
+	err := a.host.RemoveRoute(prefix, iface)
+	a.record("route_remove", prefix+" dev "+iface, map[string]any{"prefix": prefix, "interface": iface}, err)
+	return err
+}
+
+func (a *auditedHost) record(action, target string, object map[string]any, err error) {
+	a.rec.Record(context.Background(), auditfwd.Mutation{Action: action, Target: target, Object: object, Err: err})
+}
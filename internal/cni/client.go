@@ -0,0 +1,100 @@
+package cni
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/plexsphere/plexd/internal/nodeapi"
+)
+
+// Client calls the CNI allocation endpoints of the local node API.
+type Client struct {
+	socketPath string
+	http       *http.Client
+}
+
+// NewClient returns a Client for the node API listening on socketPath.
+func NewClient(socketPath string) *Client {
+	return &Client{
+		socketPath: socketPath,
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(context.Context, string, string) (net.Conn, error) {
+					return nodeapi.DialLocal(socketPath)
+				},
+			},
+		},
+	}
+}
+
+// Allocate requests the address of the container interface.
+func (c *Client) Allocate(ctx context.Context, containerID, ifName string) (nodeapi.CNIAllocation, error) {
+	body, err := json.Marshal(nodeapi.CNIAllocationRequest{ContainerID: containerID, IfName: ifName})
+	if err != nil {
+		return nodeapi.CNIAllocation{}, err
+	}
+	var alloc nodeapi.CNIAllocation
+	if err := c.do(ctx, http.MethodPost, "/v1/cni/allocations", body, &alloc); err != nil {
+		return nodeapi.CNIAllocation{}, err
+	}
+	return alloc, nil
+}
+
+// Release frees the address of the container interface.
+func (c *Client) Release(ctx context.Context, containerID, ifName string) error {
+	path := "/v1/cni/allocations/" + url.PathEscape(containerID) + "/" + url.PathEscape(ifName)
+	return c.do(ctx, http.MethodDelete, path, nil, nil)
+}
+
+// Allocations returns all current allocations.
+func (c *Client) Allocations(ctx context.Context) ([]nodeapi.CNIAllocation, error) {
+	var allocs []nodeapi.CNIAllocation
+	if err := c.do(ctx, http.MethodGet, "/v1/cni/allocations", nil, &allocs); err != nil {
+		return nil, err
+	}
+	return allocs, nil
+}
+
+// do sends a request and decodes a JSON response into out, if non-nil.
+// Non-2xx responses are returned as errors carrying the agent's message.
+func (c *Client) do(ctx context.Context, method, path string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, "http://localhost"+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("plexd not running or socket unavailable at %s: %w", c.socketPath, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			return fmt.Errorf("%s (status %d)", e.Error, resp.StatusCode)
+		}
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+// Package cni exposes the mesh to containers. The daemon side, Manager,
+// allocates per-container addresses from the node's container prefix and
+// routes the container prefixes of other nodes through the mesh interface.
+// The plugin side, RunPlugin, implements the CNI plugin protocol for the
+// plexd-cni binary: it asks the daemon for an address over the local node
+// API and wires a veth pair between the container and the host, so that
+// containers become directly addressable mesh endpoints.
+package cni
+
+import "errors"
+
+// DefaultMTU is the default MTU of container interfaces. It matches the
+// default WireGuard interface MTU, through which all container traffic to
+// the mesh is sent.
+const DefaultMTU = 1420
+
+// Config holds the daemon-side container network configuration.
+type Config struct {
+	// Enabled controls whether the node serves CNI address allocations and
+	// routes container prefixes.
+	// Default: false
+	Enabled bool
+
+	// MTU is the MTU of container interfaces and their host veth ends.
+	// Default: 1420
+	MTU int
+}
+
+// ApplyDefaults sets default values for zero-valued fields.
+func (c *Config) ApplyDefaults() {
+	if c.MTU == 0 {
+		c.MTU = DefaultMTU
+	}
+}
+
+// Validate checks that required fields are set and values are acceptable.
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.MTU < 576 || c.MTU > 9000 {
+		return errors.New("cni: config: MTU must be between 576 and 9000")
+	}
+	return nil
+}
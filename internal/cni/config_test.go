@@ -0,0 +1,35 @@
+package cni
+
+import "testing"
+
+func TestConfig_ApplyDefaults(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+
+	if cfg.Enabled {
+		t.Error("Enabled = true, want false")
+	}
+	if cfg.MTU != DefaultMTU {
+		t.Errorf("MTU = %d, want %d", cfg.MTU, DefaultMTU)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "disabled", cfg: Config{MTU: 1}},
+		{name: "valid", cfg: Config{Enabled: true, MTU: 1420}},
+		{name: "mtu too small", cfg: Config{Enabled: true, MTU: 100}, wantErr: true},
+		{name: "mtu too large", cfg: Config{Enabled: true, MTU: 65535}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
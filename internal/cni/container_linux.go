@@ -0,0 +1,170 @@
+//go:build linux
+
+package cni
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+
+	"github.com/plexsphere/plexd/internal/nodeapi"
+)
+
+// vethNetworker implements ContainerNetworker with routed veth pairs: the
+// container end holds the /32 address and routes everything through the
+// link-local Gateway, and the host end answers ARP for it through proxy ARP
+// and holds the /32 route back to the container.
+type vethNetworker struct{}
+
+// NewContainerNetworker returns the platform ContainerNetworker. On Linux it
+// wires routed veth pairs with netlink.
+func NewContainerNetworker() ContainerNetworker {
+	return vethNetworker{}
+}
+
+func (vethNetworker) Setup(netnsPath string, alloc nodeapi.CNIAllocation) (string, error) {
+	addr, gw, err := parseAllocation(alloc)
+	if err != nil {
+		return "", err
+	}
+	ns, err := netns.GetFromPath(netnsPath)
+	if err != nil {
+		return "", fmt.Errorf("open netns %s: %w", netnsPath, err)
+	}
+	defer ns.Close()
+
+	veth := &netlink.Veth{
+		LinkAttrs:     netlink.LinkAttrs{Name: alloc.HostInterface, MTU: alloc.MTU},
+		PeerName:      alloc.IfName,
+		PeerMTU:       uint32(alloc.MTU),
+		PeerNamespace: netlink.NsFd(int(ns)),
+	}
+	if err := netlink.LinkAdd(veth); err != nil {
+		return "", fmt.Errorf("create veth %s: %w", alloc.HostInterface, err)
+	}
+
+	// Host end: proxy ARP for the gateway, forwarding, and the route back.
+	host, err := netlink.LinkByName(alloc.HostInterface)
+	if err != nil {
+		return "", fmt.Errorf("lookup %s: %w", alloc.HostInterface, err)
+	}
+	for _, key := range []string{"proxy_arp", "forwarding"} {
+		if err := setIfaceSysctl(alloc.HostInterface, key, "1"); err != nil {
+			return "", err
+		}
+	}
+	if err := netlink.LinkSetUp(host); err != nil {
+		return "", fmt.Errorf("set %s up: %w", alloc.HostInterface, err)
+	}
+	hostRoute := &netlink.Route{
+		Dst:       addr.IPNet,
+		LinkIndex: host.Attrs().Index,
+		Scope:     netlink.SCOPE_LINK,
+	}
+	if err := netlink.RouteAdd(hostRoute); err != nil && !errors.Is(err, syscall.EEXIST) {
+		return "", fmt.Errorf("add route %s dev %s: %w", addr.IPNet, alloc.HostInterface, err)
+	}
+
+	// Container end: address, link-local gateway, and default route.
+	h, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		return "", fmt.Errorf("open netlink in %s: %w", netnsPath, err)
+	}
+	defer h.Close()
+
+	link, err := h.LinkByName(alloc.IfName)
+	if err != nil {
+		return "", fmt.Errorf("lookup %s in container: %w", alloc.IfName, err)
+	}
+	if err := h.AddrAdd(link, addr); err != nil && !errors.Is(err, syscall.EEXIST) {
+		return "", fmt.Errorf("add address %s: %w", addr.IPNet, err)
+	}
+	if err := h.LinkSetUp(link); err != nil {
+		return "", fmt.Errorf("set %s up in container: %w", alloc.IfName, err)
+	}
+	if lo, err := h.LinkByName("lo"); err == nil {
+		_ = h.LinkSetUp(lo)
+	}
+	routes := []*netlink.Route{
+		{Dst: &net.IPNet{IP: gw, Mask: net.CIDRMask(32, 32)}, LinkIndex: link.Attrs().Index, Scope: netlink.SCOPE_LINK},
+		{Gw: gw, LinkIndex: link.Attrs().Index},
+	}
+	for _, r := range routes {
+		if err := h.RouteAdd(r); err != nil && !errors.Is(err, syscall.EEXIST) {
+			return "", fmt.Errorf("add container route: %w", err)
+		}
+	}
+	return link.Attrs().HardwareAddr.String(), nil
+}
+
+func (vethNetworker) Teardown(hostIface string) error {
+	link, err := netlink.LinkByName(hostIface)
+	if err != nil {
+		var notFound netlink.LinkNotFoundError
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return fmt.Errorf("lookup %s: %w", hostIface, err)
+	}
+	if err := netlink.LinkDel(link); err != nil {
+		return fmt.Errorf("delete %s: %w", hostIface, err)
+	}
+	return nil
+}
+
+func (vethNetworker) Check(netnsPath string, alloc nodeapi.CNIAllocation) error {
+	addr, _, err := parseAllocation(alloc)
+	if err != nil {
+		return err
+	}
+	host, err := netlink.LinkByName(alloc.HostInterface)
+	if err != nil {
+		return fmt.Errorf("host interface %s: %w", alloc.HostInterface, err)
+	}
+	if host.Attrs().OperState == netlink.OperDown {
+		return fmt.Errorf("host interface %s is down", alloc.HostInterface)
+	}
+
+	ns, err := netns.GetFromPath(netnsPath)
+	if err != nil {
+		return fmt.Errorf("open netns %s: %w", netnsPath, err)
+	}
+	defer ns.Close()
+	h, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		return fmt.Errorf("open netlink in %s: %w", netnsPath, err)
+	}
+	defer h.Close()
+
+	link, err := h.LinkByName(alloc.IfName)
+	if err != nil {
+		return fmt.Errorf("container interface %s: %w", alloc.IfName, err)
+	}
+	addrs, err := h.AddrList(link, netlink.FAMILY_V4)
+	if err != nil {
+		return fmt.Errorf("list addresses of %s: %w", alloc.IfName, err)
+	}
+	for _, a := range addrs {
+		if a.IPNet.String() == addr.IPNet.String() {
+			return nil
+		}
+	}
+	return fmt.Errorf("container interface %s lacks address %s", alloc.IfName, addr.IPNet)
+}
+
+// parseAllocation returns the container address and gateway of alloc.
+func parseAllocation(alloc nodeapi.CNIAllocation) (*netlink.Addr, net.IP, error) {
+	addr, err := netlink.ParseAddr(alloc.Address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid address %q: %w", alloc.Address, err)
+	}
+	gw := net.ParseIP(alloc.Gateway).To4()
+	if gw == nil {
+		return nil, nil, fmt.Errorf("invalid gateway %q", alloc.Gateway)
+	}
+	return addr, gw, nil
+}
@@ -0,0 +1,55 @@
+package cni
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/plexsphere/plexd/internal/nodeapi"
+)
+
+// Gateway is the link-local next hop of every container. The host end of
+// each veth pair answers ARP for it through proxy ARP, so it needs no
+// address from the container prefix.
+const Gateway = "169.254.1.1"
+
+// hostInterfacePrefix is the name prefix of the host ends of veth pairs.
+const hostInterfacePrefix = "plexc"
+
+// HostInterfaceName returns the name of the host end of the veth pair for
+// the container interface: the prefix "plexc" and a hash of the container ID
+// and interface name, 15 characters in total to fit IFNAMSIZ.
+func HostInterfaceName(containerID, ifName string) string {
+	sum := sha256.Sum256([]byte(containerID + "/" + ifName))
+	return hostInterfacePrefix + hex.EncodeToString(sum[:])[:10]
+}
+
+// HostNetwork abstracts the host routing changes the Manager makes.
+// All methods must be idempotent.
+type HostNetwork interface {
+	// EnableForwarding enables IPv4 forwarding on iface.
+	EnableForwarding(iface string) error
+
+	// AddRoute routes the CIDR prefix through iface.
+	// Idempotent: adding an existing route returns nil.
+	AddRoute(prefix, iface string) error
+
+	// RemoveRoute removes the route for the CIDR prefix through iface.
+	// Idempotent: removing a non-existent route returns nil.
+	RemoveRoute(prefix, iface string) error
+}
+
+// ContainerNetworker wires container network namespaces for the plugin.
+type ContainerNetworker interface {
+	// Setup creates the veth pair for alloc, moves its container end into
+	// the network namespace at netnsPath, and configures the addresses and
+	// routes on both ends. It returns the MAC address of the container end.
+	Setup(netnsPath string, alloc nodeapi.CNIAllocation) (string, error)
+
+	// Teardown deletes the veth pair with the given host end, which also
+	// removes its routes.
+	// Idempotent: tearing down a missing pair returns nil.
+	Teardown(hostIface string) error
+
+	// Check verifies that the veth pair for alloc exists and is configured.
+	Check(netnsPath string, alloc nodeapi.CNIAllocation) error
+}
@@ -0,0 +1,82 @@
+//go:build linux
+
+package cni
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+)
+
+// netlinkHost implements HostNetwork with netlink and sysctl.
+type netlinkHost struct{}
+
+// NewHostNetwork returns the platform HostNetwork. On Linux it uses
+// netlink for routes and sysctl for forwarding.
+func NewHostNetwork() HostNetwork {
+	return netlinkHost{}
+}
+
+func (netlinkHost) EnableForwarding(iface string) error {
+	return setIfaceSysctl(iface, "forwarding", "1")
+}
+
+func (netlinkHost) AddRoute(prefix, iface string) error {
+	route, err := linkRoute(prefix, iface)
+	if err != nil {
+		return err
+	}
+	if err := netlink.RouteAdd(route); err != nil && !errors.Is(err, syscall.EEXIST) {
+		return fmt.Errorf("add route %s dev %s: %w", prefix, iface, err)
+	}
+	return nil
+}
+
+func (netlinkHost) RemoveRoute(prefix, iface string) error {
+	route, err := linkRoute(prefix, iface)
+	if err != nil {
+		var notFound netlink.LinkNotFoundError
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return err
+	}
+	if err := netlink.RouteDel(route); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return fmt.Errorf("remove route %s dev %s: %w", prefix, iface, err)
+	}
+	return nil
+}
+
+// linkRoute returns the scope link route for prefix through iface.
+func linkRoute(prefix, iface string) (*netlink.Route, error) {
+	_, dst, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("parse CIDR %q: %w", prefix, err)
+	}
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		return nil, fmt.Errorf("lookup interface %q: %w", iface, err)
+	}
+	return &netlink.Route{
+		Dst:       dst,
+		LinkIndex: link.Attrs().Index,
+		Scope:     netlink.SCOPE_LINK,
+	}, nil
+}
+
+// setIfaceSysctl writes value to net.ipv4.conf.{iface}.{key}.
+func setIfaceSysctl(iface, key, value string) error {
+	if iface == "" || strings.ContainsAny(iface, "/.\x00") {
+		return fmt.Errorf("invalid interface name %q", iface)
+	}
+	path := fmt.Sprintf("/proc/sys/net/ipv4/conf/%s/%s", iface, key)
+	if err := os.WriteFile(path, []byte(value), 0o644); err != nil {
+		return fmt.Errorf("sysctl %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+//go:build !linux
+
+package cni
+
+import (
+	"errors"
+
+	"github.com/plexsphere/plexd/internal/nodeapi"
+)
+
+// errUnsupported is returned by the host and container networkers on
+// platforms without container network support.
+var errUnsupported = errors.New("cni: container networking is only supported on Linux")
+
+type unsupportedHost struct{}
+
+// NewHostNetwork returns the platform HostNetwork. Container networking is
+// only supported on Linux; elsewhere every method fails.
+func NewHostNetwork() HostNetwork {
+	return unsupportedHost{}
+}
+
+func (unsupportedHost) EnableForwarding(string) error    { return errUnsupported }
+func (unsupportedHost) AddRoute(string, string) error    { return errUnsupported }
+func (unsupportedHost) RemoveRoute(string, string) error { return errUnsupported }
+
+type unsupportedContainer struct{}
+
+// NewContainerNetworker returns the platform ContainerNetworker. Container
+// networking is only supported on Linux; elsewhere every method fails.
+func NewContainerNetworker() ContainerNetworker {
+	return unsupportedContainer{}
+}
+
+func (unsupportedContainer) Setup(string, nodeapi.CNIAllocation) (string, error) {
+	return "", errUnsupported
+}
+func (unsupportedContainer) Teardown(string) error                     { return errUnsupported }
+func (unsupportedContainer) Check(string, nodeapi.CNIAllocation) error { return errUnsupported }
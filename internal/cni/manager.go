@@ -0,0 +1,340 @@
+package cni
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/fsutil"
+	"github.com/plexsphere/plexd/internal/nodeapi"
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+// stateFile is the name of the allocation state file in the state dir.
+const stateFile = "allocations.json"
+
+// allocation is a persisted container address.
+type allocation struct {
+	ContainerID string     `json:"container_id"`
+	IfName      string     `json:"ifname"`
+	Address     netip.Addr `json:"address"`
+}
+
+// Manager allocates container addresses from the node's container prefix
+// and routes the container prefixes of other nodes through the mesh
+// interface. Allocations are persisted in data_dir/cni, so containers keep
+// their addresses across agent restarts.
+type Manager struct {
+	cfg       Config
+	host      HostNetwork
+	meshIface string
+	stateDir  string
+	logger    *slog.Logger
+
+	mu         sync.Mutex
+	prefix     netip.Prefix
+	routes     []string
+	forwarding bool
+	allocs     map[string]allocation // "{container_id}/{ifname}" → allocation
+	next       netip.Addr
+}
+
+// NewManager creates a Manager that keeps its state in dataDir/cni. Config
+// defaults are applied automatically.
+func NewManager(cfg Config, host HostNetwork, meshIface, dataDir string, logger *slog.Logger) *Manager {
+	cfg.ApplyDefaults()
+	return &Manager{
+		cfg:       cfg,
+		host:      host,
+		meshIface: meshIface,
+		stateDir:  filepath.Join(dataDir, "cni"),
+		logger:    logger.With("component", "cni"),
+		allocs:    make(map[string]allocation),
+	}
+}
+
+// Load reads the persisted allocations. A missing state file is not an
+// error.
+func (m *Manager) Load() error {
+	data, err := os.ReadFile(filepath.Join(m.stateDir, stateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cni: load allocations: %w", err)
+	}
+	var allocs []allocation
+	if err := json.Unmarshal(data, &allocs); err != nil {
+		return fmt.Errorf("cni: load allocations: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, a := range allocs {
+		m.allocs[allocKey(a.ContainerID, a.IfName)] = a
+	}
+	return nil
+}
+
+// Apply applies the container network configuration pushed by the control
+// plane. A nil cfg clears the prefix, so no new addresses are allocated,
+// and removes the routes to other nodes' containers. Existing allocations
+// are kept until their containers are deleted.
+func (m *Manager) Apply(cfg *api.ContainerNetworkConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var prefix netip.Prefix
+	var routes []string
+	if cfg != nil {
+		p, err := netip.ParsePrefix(cfg.Prefix)
+		if err != nil || !p.Addr().Is4() {
+			return fmt.Errorf("cni: invalid container prefix %q", cfg.Prefix)
+		}
+		prefix = p.Masked()
+		routes = cfg.PeerPrefixes
+	}
+
+	if prefix != m.prefix {
+		m.logger.Info("container prefix changed", "prefix", prefix, "previous", m.prefix)
+		m.prefix = prefix
+		m.next = netip.Addr{}
+	}
+
+	var errs []error
+	if prefix.IsValid() && !m.forwarding {
+		if err := m.host.EnableForwarding(m.meshIface); err != nil {
+			errs = append(errs, fmt.Errorf("cni: enable forwarding: %w", err))
+		} else {
+			m.forwarding = true
+		}
+	}
+	errs = append(errs, m.syncRoutes(routes))
+	return errors.Join(errs...)
+}
+
+// syncRoutes installs routes for want through the mesh interface and
+// removes previously installed routes that are no longer wanted. Routes
+// that fail are retried by the next Apply. Callers must hold m.mu.
+func (m *Manager) syncRoutes(want []string) error {
+	var errs []error
+	var installed []string
+	for _, r := range m.routes {
+		if slices.Contains(want, r) {
+			continue
+		}
+		if err := m.host.RemoveRoute(r, m.meshIface); err != nil {
+			errs = append(errs, fmt.Errorf("cni: remove route %s: %w", r, err))
+			installed = append(installed, r)
+			continue
+		}
+		m.logger.Info("container route removed", "prefix", r)
+	}
+	for _, r := range want {
+		if _, err := netip.ParsePrefix(r); err != nil {
+			errs = append(errs, fmt.Errorf("cni: invalid peer prefix %q", r))
+			continue
+		}
+		if slices.Contains(m.routes, r) {
+			installed = append(installed, r)
+			continue
+		}
+		if err := m.host.AddRoute(r, m.meshIface); err != nil {
+			errs = append(errs, fmt.Errorf("cni: add route %s: %w", r, err))
+			continue
+		}
+		installed = append(installed, r)
+		m.logger.Info("container route added", "prefix", r)
+	}
+	m.routes = installed
+	return errors.Join(errs...)
+}
+
+// Allocate returns the allocation for the container interface, allocating
+// the next free address of the container prefix if it has none.
+func (m *Manager) Allocate(containerID, ifName string) (nodeapi.CNIAllocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := allocKey(containerID, ifName)
+	if a, ok := m.allocs[key]; ok {
+		return m.toAPI(a), nil
+	}
+	if !m.prefix.IsValid() {
+		return nodeapi.CNIAllocation{}, errors.New("cni: no container prefix assigned to this node")
+	}
+
+	addr, ok := m.nextFree()
+	if !ok {
+		return nodeapi.CNIAllocation{}, fmt.Errorf("cni: container prefix %s exhausted", m.prefix)
+	}
+	a := allocation{ContainerID: containerID, IfName: ifName, Address: addr}
+	m.allocs[key] = a
+	if err := m.save(); err != nil {
+		delete(m.allocs, key)
+		return nodeapi.CNIAllocation{}, err
+	}
+	m.next = addr.Next()
+	m.logger.Info("container address allocated",
+		"container_id", containerID,
+		"ifname", ifName,
+		"address", addr,
+	)
+	return m.toAPI(a), nil
+}
+
+// nextFree returns the first unused host address of the prefix, starting
+// after the most recent allocation so that released addresses are not
+// reused right away. Callers must hold m.mu.
+func (m *Manager) nextFree() (netip.Addr, bool) {
+	used := make(map[netip.Addr]bool, len(m.allocs))
+	for _, a := range m.allocs {
+		used[a.Address] = true
+	}
+	first, last := hostRange(m.prefix)
+	start := m.next
+	if !start.IsValid() || start.Less(first) || last.Less(start) {
+		start = first
+	}
+	addr := start
+	for {
+		if !used[addr] {
+			return addr, true
+		}
+		if addr == last {
+			addr = first
+		} else {
+			addr = addr.Next()
+		}
+		if addr == start {
+			return netip.Addr{}, false
+		}
+	}
+}
+
+// Release frees the container interface's address.
+func (m *Manager) Release(containerID, ifName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := allocKey(containerID, ifName)
+	a, ok := m.allocs[key]
+	if !ok {
+		return nil
+	}
+	delete(m.allocs, key)
+	if err := m.save(); err != nil {
+		m.allocs[key] = a
+		return err
+	}
+	m.logger.Info("container address released",
+		"container_id", containerID,
+		"ifname", ifName,
+		"address", a.Address,
+	)
+	return nil
+}
+
+// Allocations returns all current allocations, sorted by address.
+func (m *Manager) Allocations() []nodeapi.CNIAllocation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]nodeapi.CNIAllocation, 0, len(m.allocs))
+	for _, a := range m.allocs {
+		out = append(out, m.toAPI(a))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return netip.MustParsePrefix(out[i].Address).Addr().Less(netip.MustParsePrefix(out[j].Address).Addr())
+	})
+	return out
+}
+
+// ContainerNetwork returns the container network status for heartbeats, or
+// nil if no container prefix is assigned.
+func (m *Manager) ContainerNetwork() *api.ContainerNetworkInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.prefix.IsValid() {
+		return nil
+	}
+	return &api.ContainerNetworkInfo{
+		Prefix:     m.prefix.String(),
+		Containers: len(m.allocs),
+	}
+}
+
+// ReconcileHandler returns a reconcile.ReconcileHandler that applies the
+// desired ContainerNetworkConfig.
+func (m *Manager) ReconcileHandler() reconcile.ReconcileHandler {
+	return func(_ context.Context, desired *api.StateResponse, _ reconcile.StateDiff) error {
+		if desired == nil {
+			return nil
+		}
+		return m.Apply(desired.ContainerNetworkConfig)
+	}
+}
+
+// save persists the allocations. Callers must hold m.mu.
+func (m *Manager) save() error {
+	allocs := make([]allocation, 0, len(m.allocs))
+	for _, a := range m.allocs {
+		allocs = append(allocs, a)
+	}
+	sort.Slice(allocs, func(i, j int) bool { return allocs[i].Address.Less(allocs[j].Address) })
+	data, err := json.MarshalIndent(allocs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cni: save allocations: %w", err)
+	}
+	if err := os.MkdirAll(m.stateDir, 0o700); err != nil {
+		return fmt.Errorf("cni: save allocations: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(m.stateDir, stateFile, data, 0o600); err != nil {
+		return fmt.Errorf("cni: save allocations: %w", err)
+	}
+	return nil
+}
+
+// toAPI converts a to the node API representation.
+func (m *Manager) toAPI(a allocation) nodeapi.CNIAllocation {
+	return nodeapi.CNIAllocation{
+		ContainerID:   a.ContainerID,
+		IfName:        a.IfName,
+		Address:       netip.PrefixFrom(a.Address, 32).String(),
+		Gateway:       Gateway,
+		HostInterface: HostInterfaceName(a.ContainerID, a.IfName),
+		MTU:           m.cfg.MTU,
+	}
+}
+
+func allocKey(containerID, ifName string) string {
+	return containerID + "/" + ifName
+}
+
+// hostRange returns the first and last assignable address of the IPv4
+// prefix p. The network and broadcast addresses are excluded unless p is a
+// /31 or /32.
+func hostRange(p netip.Prefix) (first, last netip.Addr) {
+	first = p.Addr()
+	b := first.As4()
+	hostBits := 32 - p.Bits()
+	for i := 3; i >= 0 && hostBits > 0; i-- {
+		n := min(hostBits, 8)
+		b[i] |= byte(1<<n - 1)
+		hostBits -= n
+	}
+	last = netip.AddrFrom4(b)
+	if p.Bits() < 31 {
+		first, last = first.Next(), last.Prev()
+	}
+	return first, last
+}
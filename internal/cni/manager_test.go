@@ -0,0 +1,206 @@
+package cni
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+type mockHost struct {
+	forwarding []string
+	routes     []string
+	failAdd    bool
+}
+
+func (h *mockHost) EnableForwarding(iface string) error {
+	h.forwarding = append(h.forwarding, iface)
+	return nil
+}
+
+func (h *mockHost) AddRoute(prefix, _ string) error {
+	if h.failAdd {
+		return errors.New("add failed")
+	}
+	h.routes = append(h.routes, prefix)
+	return nil
+}
+
+func (h *mockHost) RemoveRoute(prefix, _ string) error {
+	h.routes = slices.DeleteFunc(h.routes, func(r string) bool { return r == prefix })
+	return nil
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func newTestManager(t *testing.T, dir string) (*Manager, *mockHost) {
+	t.Helper()
+	host := &mockHost{}
+	m := NewManager(Config{Enabled: true}, host, "plexd0", dir, testLogger())
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	return m, host
+}
+
+func TestManager_AllocateWithoutPrefix(t *testing.T) {
+	m, _ := newTestManager(t, t.TempDir())
+	if _, err := m.Allocate("c1", "eth0"); err == nil {
+		t.Fatal("Allocate() without prefix: error = nil, want error")
+	}
+}
+
+func TestManager_Allocate(t *testing.T) {
+	m, host := newTestManager(t, t.TempDir())
+	if err := m.Apply(&api.ContainerNetworkConfig{Prefix: "100.96.3.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(host.forwarding, []string{"plexd0"}) {
+		t.Errorf("forwarding = %v, want [plexd0]", host.forwarding)
+	}
+
+	a1, err := m.Allocate("c1", "eth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a1.Address != "100.96.3.1/32" || a1.Gateway != Gateway || a1.MTU != DefaultMTU {
+		t.Errorf("allocation = %+v", a1)
+	}
+	if a1.HostInterface != HostInterfaceName("c1", "eth0") || len(a1.HostInterface) != 15 {
+		t.Errorf("HostInterface = %q", a1.HostInterface)
+	}
+
+	again, err := m.Allocate("c1", "eth0")
+	if err != nil || again != a1 {
+		t.Errorf("repeated Allocate() = %+v, %v; want %+v", again, err, a1)
+	}
+
+	a2, err := m.Allocate("c2", "eth0")
+	if err != nil || a2.Address != "100.96.3.2/32" {
+		t.Fatalf("second Allocate() = %+v, %v", a2, err)
+	}
+
+	// A released address is not reused right away.
+	if err := m.Release("c1", "eth0"); err != nil {
+		t.Fatal(err)
+	}
+	a3, err := m.Allocate("c3", "eth0")
+	if err != nil || a3.Address != "100.96.3.3/32" {
+		t.Errorf("Allocate() after release = %+v, %v; want 100.96.3.3/32", a3, err)
+	}
+	if info := m.ContainerNetwork(); info == nil || info.Prefix != "100.96.3.0/24" || info.Containers != 2 {
+		t.Errorf("ContainerNetwork() = %+v", info)
+	}
+}
+
+func TestManager_Exhausted(t *testing.T) {
+	m, _ := newTestManager(t, t.TempDir())
+	if err := m.Apply(&api.ContainerNetworkConfig{Prefix: "10.0.0.0/30"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"c1", "c2"} {
+		if _, err := m.Allocate(id, "eth0"); err != nil {
+			t.Fatalf("Allocate(%s) = %v", id, err)
+		}
+	}
+	if _, err := m.Allocate("c3", "eth0"); err == nil {
+		t.Fatal("Allocate() on a full /30: error = nil, want error")
+	}
+
+	// Wraps around to a released address.
+	if err := m.Release("c1", "eth0"); err != nil {
+		t.Fatal(err)
+	}
+	a, err := m.Allocate("c3", "eth0")
+	if err != nil || a.Address != "10.0.0.1/32" {
+		t.Errorf("Allocate() = %+v, %v; want 10.0.0.1/32", a, err)
+	}
+}
+
+func TestManager_Persistence(t *testing.T) {
+	dir := t.TempDir()
+	m, _ := newTestManager(t, dir)
+	if err := m.Apply(&api.ContainerNetworkConfig{Prefix: "100.96.3.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+	a, err := m.Allocate("c1", "eth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, _ := newTestManager(t, dir)
+	allocs := restarted.Allocations()
+	if len(allocs) != 1 || allocs[0] != a {
+		t.Fatalf("Allocations() after restart = %+v, want [%+v]", allocs, a)
+	}
+	if err := restarted.Apply(&api.ContainerNetworkConfig{Prefix: "100.96.3.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := restarted.Allocate("c2", "eth0")
+	if err != nil || b.Address == a.Address {
+		t.Errorf("Allocate() after restart = %+v, %v; want a new address", b, err)
+	}
+}
+
+func TestManager_ApplyRoutes(t *testing.T) {
+	m, host := newTestManager(t, t.TempDir())
+	cfg := &api.ContainerNetworkConfig{
+		Prefix:       "100.96.3.0/24",
+		PeerPrefixes: []string{"100.96.4.0/24", "100.96.5.0/24"},
+	}
+	if err := m.Apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(host.routes, cfg.PeerPrefixes) {
+		t.Errorf("routes = %v, want %v", host.routes, cfg.PeerPrefixes)
+	}
+
+	cfg.PeerPrefixes = []string{"100.96.5.0/24", "100.96.6.0/24"}
+	if err := m.Apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(host.routes, []string{"100.96.5.0/24", "100.96.6.0/24"}) {
+		t.Errorf("routes = %v", host.routes)
+	}
+
+	if err := m.Apply(nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(host.routes) != 0 {
+		t.Errorf("routes after nil config = %v, want none", host.routes)
+	}
+	if m.ContainerNetwork() != nil {
+		t.Error("ContainerNetwork() != nil after the prefix was cleared")
+	}
+}
+
+func TestManager_ApplyRetriesFailedRoutes(t *testing.T) {
+	m, host := newTestManager(t, t.TempDir())
+	host.failAdd = true
+	cfg := &api.ContainerNetworkConfig{Prefix: "100.96.3.0/24", PeerPrefixes: []string{"100.96.4.0/24"}}
+	if err := m.Apply(cfg); err == nil {
+		t.Fatal("Apply() with failing route: error = nil, want error")
+	}
+
+	host.failAdd = false
+	if err := m.Apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(host.routes, []string{"100.96.4.0/24"}) {
+		t.Errorf("routes = %v", host.routes)
+	}
+}
+
+func TestManager_ApplyInvalidPrefix(t *testing.T) {
+	m, _ := newTestManager(t, t.TempDir())
+	for _, p := range []string{"", "bogus", "fd00::/64"} {
+		if err := m.Apply(&api.ContainerNetworkConfig{Prefix: p}); err == nil {
+			t.Errorf("Apply(%q): error = nil, want error", p)
+		}
+	}
+}
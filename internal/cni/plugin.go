@@ -0,0 +1,238 @@
+package cni
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/nodeapi"
+)
+
+// PluginType is the type of the plexd CNI plugin in network configurations.
+const PluginType = "plexd-cni"
+
+// SupportedVersions lists the CNI specification versions the plugin
+// implements.
+var SupportedVersions = []string{"0.3.0", "0.3.1", "0.4.0", "1.0.0"}
+
+// pluginTimeout bounds the node API calls of one plugin invocation.
+const pluginTimeout = 30 * time.Second
+
+// CNI error codes, from the CNI specification.
+const (
+	ErrCodeIncompatibleVersion = 1
+	ErrCodeUnknownContainer    = 3
+	ErrCodeInvalidEnvironment  = 4
+	ErrCodeIO                  = 5
+	ErrCodeDecode              = 6
+	ErrCodeInvalidConfig       = 7
+	ErrCodeTryAgainLater       = 11
+)
+
+// NetConf is the network configuration the runtime passes to the plugin on
+// stdin.
+type NetConf struct {
+	CNIVersion string `json:"cniVersion"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	// Socket is the path of the node API socket.
+	// Default: nodeapi.DefaultSocketPath
+	Socket string `json:"socket,omitempty"`
+}
+
+// PluginArgs are the CNI_* parameters the runtime passes in the
+// environment.
+type PluginArgs struct {
+	Command     string
+	ContainerID string
+	Netns       string
+	IfName      string
+}
+
+// ArgsFromEnv reads PluginArgs with getenv, typically os.Getenv.
+func ArgsFromEnv(getenv func(string) string) PluginArgs {
+	return PluginArgs{
+		Command:     getenv("CNI_COMMAND"),
+		ContainerID: getenv("CNI_CONTAINERID"),
+		Netns:       getenv("CNI_NETNS"),
+		IfName:      getenv("CNI_IFNAME"),
+	}
+}
+
+// Error is a CNI error result.
+type Error struct {
+	CNIVersion string `json:"cniVersion"`
+	Code       int    `json:"code"`
+	Msg        string `json:"msg"`
+	Details    string `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Details == "" {
+		return e.Msg
+	}
+	return e.Msg + ": " + e.Details
+}
+
+// Result is the result of a successful ADD.
+type Result struct {
+	CNIVersion string      `json:"cniVersion"`
+	Interfaces []Interface `json:"interfaces"`
+	IPs        []IPConfig  `json:"ips"`
+	Routes     []Route     `json:"routes"`
+	DNS        struct{}    `json:"dns"`
+}
+
+// Interface describes an interface created by the plugin.
+type Interface struct {
+	Name    string `json:"name"`
+	Mac     string `json:"mac,omitempty"`
+	Sandbox string `json:"sandbox,omitempty"`
+}
+
+// IPConfig describes an address assigned to an interface. Version is set
+// for specification versions before 1.0.0 only.
+type IPConfig struct {
+	Version   string `json:"version,omitempty"`
+	Address   string `json:"address"`
+	Gateway   string `json:"gateway"`
+	Interface *int   `json:"interface"`
+}
+
+// Route is a route added to the container.
+type Route struct {
+	Dst string `json:"dst"`
+	GW  string `json:"gw,omitempty"`
+}
+
+// RunPlugin runs one CNI plugin invocation: it reads the network
+// configuration from stdin, performs args.Command, and writes the result,
+// or a CNI error, to stdout. The returned error is non-nil when an error
+// result was written, and the plugin must then exit with a non-zero status.
+func RunPlugin(args PluginArgs, stdin io.Reader, stdout io.Writer, cn ContainerNetworker) error {
+	version := SupportedVersions[len(SupportedVersions)-1]
+	res, err := runPlugin(args, stdin, cn, &version)
+	if err != nil {
+		var cniErr *Error
+		if !errors.As(err, &cniErr) {
+			cniErr = &Error{Code: ErrCodeTryAgainLater, Msg: "plexd-cni failed", Details: err.Error()}
+		}
+		cniErr.CNIVersion = version
+		_ = json.NewEncoder(stdout).Encode(cniErr)
+		return cniErr
+	}
+	if res != nil {
+		if err := json.NewEncoder(stdout).Encode(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runPlugin performs the command and returns the value to print, if any.
+// It sets *version to the configuration's CNI version once known.
+func runPlugin(args PluginArgs, stdin io.Reader, cn ContainerNetworker, version *string) (any, error) {
+	if args.Command == "VERSION" {
+		return map[string]any{"cniVersion": *version, "supportedVersions": SupportedVersions}, nil
+	}
+
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return nil, &Error{Code: ErrCodeIO, Msg: "read network configuration", Details: err.Error()}
+	}
+	var conf NetConf
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return nil, &Error{Code: ErrCodeDecode, Msg: "decode network configuration", Details: err.Error()}
+	}
+	if conf.CNIVersion != "" {
+		*version = conf.CNIVersion
+	}
+	if !slices.Contains(SupportedVersions, conf.CNIVersion) {
+		return nil, &Error{Code: ErrCodeIncompatibleVersion, Msg: fmt.Sprintf("unsupported CNI version %q", conf.CNIVersion)}
+	}
+	if conf.Name == "" {
+		return nil, &Error{Code: ErrCodeInvalidConfig, Msg: "network configuration lacks a name"}
+	}
+	if conf.Socket == "" {
+		conf.Socket = nodeapi.DefaultSocketPath
+	}
+	if args.ContainerID == "" || args.IfName == "" {
+		return nil, &Error{Code: ErrCodeInvalidEnvironment, Msg: "CNI_CONTAINERID and CNI_IFNAME are required"}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pluginTimeout)
+	defer cancel()
+	client := NewClient(conf.Socket)
+
+	switch args.Command {
+	case "ADD":
+		if args.Netns == "" {
+			return nil, &Error{Code: ErrCodeInvalidEnvironment, Msg: "CNI_NETNS is required"}
+		}
+		return add(ctx, client, cn, args, conf.CNIVersion)
+	case "DEL":
+		if err := cn.Teardown(HostInterfaceName(args.ContainerID, args.IfName)); err != nil {
+			return nil, err
+		}
+		return nil, client.Release(ctx, args.ContainerID, args.IfName)
+	case "CHECK":
+		return nil, check(ctx, client, cn, args)
+	default:
+		return nil, &Error{Code: ErrCodeInvalidEnvironment, Msg: fmt.Sprintf("unsupported CNI_COMMAND %q", args.Command)}
+	}
+}
+
+// add allocates an address and wires the container. On failure the veth
+// pair is removed and the address released.
+func add(ctx context.Context, client *Client, cn ContainerNetworker, args PluginArgs, version string) (*Result, error) {
+	alloc, err := client.Allocate(ctx, args.ContainerID, args.IfName)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := cn.Setup(args.Netns, alloc)
+	if err != nil {
+		_ = cn.Teardown(alloc.HostInterface)
+		_ = client.Release(ctx, args.ContainerID, args.IfName)
+		return nil, err
+	}
+
+	ipVersion := ""
+	if strings.HasPrefix(version, "0.") {
+		ipVersion = "4"
+	}
+	containerIdx := 1
+	return &Result{
+		CNIVersion: version,
+		Interfaces: []Interface{
+			{Name: alloc.HostInterface},
+			{Name: alloc.IfName, Mac: mac, Sandbox: args.Netns},
+		},
+		IPs: []IPConfig{{
+			Version:   ipVersion,
+			Address:   alloc.Address,
+			Gateway:   alloc.Gateway,
+			Interface: &containerIdx,
+		}},
+		Routes: []Route{{Dst: "0.0.0.0/0", GW: alloc.Gateway}},
+	}, nil
+}
+
+// check verifies that the container interface has an allocation and is
+// wired as Setup left it.
+func check(ctx context.Context, client *Client, cn ContainerNetworker, args PluginArgs) error {
+	allocs, err := client.Allocations(ctx)
+	if err != nil {
+		return err
+	}
+	for _, a := range allocs {
+		if a.ContainerID == args.ContainerID && a.IfName == args.IfName {
+			return cn.Check(args.Netns, a)
+		}
+	}
+	return &Error{Code: ErrCodeUnknownContainer, Msg: fmt.Sprintf("no address allocated for %s/%s", args.ContainerID, args.IfName)}
+}
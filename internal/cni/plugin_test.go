@@ -0,0 +1,221 @@
+package cni
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/nodeapi"
+)
+
+type mockNetworker struct {
+	setup    []nodeapi.CNIAllocation
+	teardown []string
+	setupErr error
+	checkErr error
+}
+
+func (n *mockNetworker) Setup(_ string, alloc nodeapi.CNIAllocation) (string, error) {
+	if n.setupErr != nil {
+		return "", n.setupErr
+	}
+	n.setup = append(n.setup, alloc)
+	return "0a:58:64:60:03:01", nil
+}
+
+func (n *mockNetworker) Teardown(hostIface string) error {
+	n.teardown = append(n.teardown, hostIface)
+	return nil
+}
+
+func (n *mockNetworker) Check(string, nodeapi.CNIAllocation) error {
+	return n.checkErr
+}
+
+// startAgent serves the node API CNI endpoints for m on a Unix socket and
+// returns its path.
+func startAgent(t *testing.T, m *Manager) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "plexd-cni")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "api.sock")
+
+	h := nodeapi.NewHandler(nodeapi.NewStateCache(t.TempDir(), testLogger()), nil, "node-1", nil, testLogger())
+	if m != nil {
+		h.SetContainerNetwork(m)
+	}
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: h.Mux()}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return socket
+}
+
+func newAgentManager(t *testing.T) *Manager {
+	t.Helper()
+	m, _ := newTestManager(t, t.TempDir())
+	if err := m.Apply(&api.ContainerNetworkConfig{Prefix: "100.96.3.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func netConf(version, socket string) *strings.Reader {
+	data, _ := json.Marshal(NetConf{CNIVersion: version, Name: "plexd", Type: PluginType, Socket: socket})
+	return strings.NewReader(string(data))
+}
+
+func TestRunPlugin_Version(t *testing.T) {
+	var out bytes.Buffer
+	if err := RunPlugin(PluginArgs{Command: "VERSION"}, strings.NewReader("{}"), &out, &mockNetworker{}); err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		SupportedVersions []string `json:"supportedVersions"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &v); err != nil || len(v.SupportedVersions) != len(SupportedVersions) {
+		t.Errorf("VERSION output = %s", out.String())
+	}
+}
+
+func TestRunPlugin_AddDel(t *testing.T) {
+	m := newAgentManager(t)
+	socket := startAgent(t, m)
+	cn := &mockNetworker{}
+	args := PluginArgs{Command: "ADD", ContainerID: "c1", Netns: "/var/run/netns/c1", IfName: "eth0"}
+
+	var out bytes.Buffer
+	if err := RunPlugin(args, netConf("1.0.0", socket), &out, cn); err != nil {
+		t.Fatalf("ADD: %v, output %s", err, out.String())
+	}
+	var res Result
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.IPs) != 1 || res.IPs[0].Address != "100.96.3.1/32" || res.IPs[0].Gateway != Gateway || res.IPs[0].Version != "" {
+		t.Errorf("ips = %+v", res.IPs)
+	}
+	if len(res.Interfaces) != 2 || res.Interfaces[1].Name != "eth0" || res.Interfaces[1].Sandbox != args.Netns {
+		t.Errorf("interfaces = %+v", res.Interfaces)
+	}
+	if len(cn.setup) != 1 || cn.setup[0].HostInterface != HostInterfaceName("c1", "eth0") {
+		t.Errorf("setup = %+v", cn.setup)
+	}
+
+	args.Command = "CHECK"
+	out.Reset()
+	if err := RunPlugin(args, netConf("1.0.0", socket), &out, cn); err != nil {
+		t.Fatalf("CHECK: %v, output %s", err, out.String())
+	}
+
+	args.Command = "DEL"
+	out.Reset()
+	if err := RunPlugin(args, netConf("1.0.0", socket), &out, cn); err != nil {
+		t.Fatalf("DEL: %v, output %s", err, out.String())
+	}
+	if len(m.Allocations()) != 0 {
+		t.Errorf("allocations after DEL = %+v", m.Allocations())
+	}
+	if len(cn.teardown) != 1 {
+		t.Errorf("teardown = %v", cn.teardown)
+	}
+
+	// DEL is idempotent.
+	if err := RunPlugin(args, netConf("1.0.0", socket), &out, cn); err != nil {
+		t.Fatalf("repeated DEL: %v", err)
+	}
+
+	args.Command = "CHECK"
+	out.Reset()
+	err := RunPlugin(args, netConf("1.0.0", socket), &out, cn)
+	var cniErr *Error
+	if !errors.As(err, &cniErr) || cniErr.Code != ErrCodeUnknownContainer {
+		t.Errorf("CHECK after DEL = %v, want unknown container error", err)
+	}
+}
+
+func TestRunPlugin_AddLegacyVersion(t *testing.T) {
+	socket := startAgent(t, newAgentManager(t))
+	args := PluginArgs{Command: "ADD", ContainerID: "c1", Netns: "/proc/1/ns/net", IfName: "eth0"}
+
+	var out bytes.Buffer
+	if err := RunPlugin(args, netConf("0.4.0", socket), &out, &mockNetworker{}); err != nil {
+		t.Fatal(err)
+	}
+	var res Result
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.CNIVersion != "0.4.0" || res.IPs[0].Version != "4" {
+		t.Errorf("result = %+v, want version 0.4.0 with IP version 4", res)
+	}
+}
+
+func TestRunPlugin_AddSetupFailureReleases(t *testing.T) {
+	m := newAgentManager(t)
+	socket := startAgent(t, m)
+	cn := &mockNetworker{setupErr: errors.New("netns gone")}
+	args := PluginArgs{Command: "ADD", ContainerID: "c1", Netns: "/proc/1/ns/net", IfName: "eth0"}
+
+	var out bytes.Buffer
+	if err := RunPlugin(args, netConf("1.0.0", socket), &out, cn); err == nil {
+		t.Fatal("ADD: error = nil, want error")
+	}
+	var cniErr Error
+	if err := json.Unmarshal(out.Bytes(), &cniErr); err != nil || cniErr.Code != ErrCodeTryAgainLater || !strings.Contains(cniErr.Details, "netns gone") {
+		t.Errorf("error output = %s", out.String())
+	}
+	if len(m.Allocations()) != 0 {
+		t.Errorf("allocations after failed ADD = %+v", m.Allocations())
+	}
+	if len(cn.teardown) != 1 {
+		t.Errorf("teardown = %v, want cleanup of the host interface", cn.teardown)
+	}
+}
+
+func TestRunPlugin_Errors(t *testing.T) {
+	socket := startAgent(t, nil)
+	add := PluginArgs{Command: "ADD", ContainerID: "c1", Netns: "/proc/1/ns/net", IfName: "eth0"}
+
+	tests := []struct {
+		name     string
+		args     PluginArgs
+		stdin    string
+		wantCode int
+	}{
+		{name: "bad json", args: add, stdin: "{", wantCode: ErrCodeDecode},
+		{name: "unsupported version", args: add, stdin: `{"cniVersion":"9.9.9"}`, wantCode: ErrCodeIncompatibleVersion},
+		{name: "missing name", args: add, stdin: `{"cniVersion":"1.0.0"}`, wantCode: ErrCodeInvalidConfig},
+		{name: "missing container id", args: PluginArgs{Command: "ADD", Netns: "/x", IfName: "eth0"}, stdin: `{"cniVersion":"1.0.0","name":"plexd"}`, wantCode: ErrCodeInvalidEnvironment},
+		{name: "missing netns", args: PluginArgs{Command: "ADD", ContainerID: "c1", IfName: "eth0"}, stdin: `{"cniVersion":"1.0.0","name":"plexd"}`, wantCode: ErrCodeInvalidEnvironment},
+		{name: "unknown command", args: PluginArgs{Command: "GC", ContainerID: "c1", IfName: "eth0"}, stdin: `{"cniVersion":"1.0.0","name":"plexd"}`, wantCode: ErrCodeInvalidEnvironment},
+		{name: "agent without container network", args: add, stdin: `{"cniVersion":"1.0.0","name":"plexd","socket":"` + socket + `"}`, wantCode: ErrCodeTryAgainLater},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := RunPlugin(tt.args, strings.NewReader(tt.stdin), &out, &mockNetworker{})
+			var cniErr *Error
+			if !errors.As(err, &cniErr) || cniErr.Code != tt.wantCode {
+				t.Fatalf("RunPlugin() = %v, want code %d", err, tt.wantCode)
+			}
+			var written Error
+			if err := json.Unmarshal(out.Bytes(), &written); err != nil || written.Code != tt.wantCode || written.CNIVersion == "" {
+				t.Errorf("output = %s", out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package diagnostics
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// File names of a bundle, next to the files of the added sources.
+const (
+	infoFile   = "info.json"
+	stacksFile = "goroutines.txt"
+)
+
+// bundlePrefix and bundleSuffix frame the names of crash bundles; the
+// UTC time in between makes the names sort by age.
+const (
+	bundlePrefix = "plexd-crash-"
+	bundleSuffix = ".tar.gz"
+	bundleTime   = "20060102T150405.000Z"
+)
+
+// maxStackBytes bounds the goroutine stacks of a bundle.
+const maxStackBytes = 64 << 20
+
+// Source returns the content of a file of a bundle. Secret values must not
+// be included.
+type Source func() ([]byte, error)
+
+// Info describes a bundle. It is the info.json file of the bundle.
+type Info struct {
+	Time      time.Time `json:"time"`
+	Reason    string    `json:"reason"`
+	Version   string    `json:"version"`
+	GoVersion string    `json:"go_version"`
+	OS        string    `json:"os"`
+	Arch      string    `json:"arch"`
+	Hostname  string    `json:"hostname,omitempty"`
+	PID       int       `json:"pid"`
+
+	// Files lists the files of the bundle besides info.json.
+	Files []string `json:"files"`
+
+	// Errors holds the error of each source that failed, by file name.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// namedSource is a Source and the file name of its content.
+type namedSource struct {
+	name string
+	src  Source
+}
+
+// Collector writes diagnostics bundles with the files of the added sources.
+// It writes a crash bundle when the agent panics or dies of a fatal error
+// (see Arm and Crash) and support bundles on demand (see WriteBundle). It
+// is safe for concurrent use.
+type Collector struct {
+	cfg     Config
+	version string
+	logger  *slog.Logger
+	now     func() time.Time
+
+	mu      sync.Mutex
+	sources []namedSource
+
+	// crashed is set by the first Crash call; later ones do nothing.
+	crashed atomic.Bool
+}
+
+// NewCollector creates a Collector that writes crash bundles as configured
+// by cfg. Bundles report version as the agent version.
+func NewCollector(cfg Config, version string, logger *slog.Logger) *Collector {
+	return &Collector{
+		cfg:     cfg,
+		version: version,
+		logger:  logger.With("component", "diagnostics"),
+		now:     time.Now,
+	}
+}
+
+// Add adds the file name with the content of src to the bundles, replacing
+// a source added before under the same name.
+func (c *Collector) Add(name string, src Source) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, s := range c.sources {
+		if s.name == name {
+			c.sources[i].src = src
+			return
+		}
+	}
+	c.sources = append(c.sources, namedSource{name: name, src: src})
+}
+
+// WriteBundle writes a bundle with the stacks of all goroutines and the
+// files of the sources to w. reason is recorded in info.json.
+func (c *Collector) WriteBundle(w io.Writer, reason string) error {
+	return c.write(w, c.info(reason), allStacks(), c.snapshot())
+}
+
+// info returns the Info of a bundle written now for reason.
+func (c *Collector) info(reason string) Info {
+	hostname, _ := os.Hostname()
+	return Info{
+		Time:      c.now().UTC(),
+		Reason:    reason,
+		Version:   c.version,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Hostname:  hostname,
+		PID:       os.Getpid(),
+	}
+}
+
+// snapshot returns a copy of the sources.
+func (c *Collector) snapshot() []namedSource {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.sources)
+}
+
+// write writes a bundle of info, stacks, and the files of sources to w. A
+// failed source is recorded in info.Errors rather than failing the bundle.
+func (c *Collector) write(w io.Writer, info Info, stacks []byte, sources []namedSource) error {
+	type file struct {
+		name string
+		data []byte
+	}
+	var files []file
+	if len(stacks) > 0 {
+		files = append(files, file{stacksFile, stacks})
+	}
+	for _, s := range sources {
+		data, err := readSource(s.src)
+		if err != nil {
+			if info.Errors == nil {
+				info.Errors = make(map[string]string)
+			}
+			info.Errors[s.name] = err.Error()
+			continue
+		}
+		files = append(files, file{s.name, data})
+	}
+	info.Files = make([]string, len(files))
+	for i, f := range files {
+		info.Files[i] = f.name
+	}
+	infoJSON, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("diagnostics: encode info: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, f := range append([]file{{infoFile, infoJSON}}, files...) {
+		hdr := &tar.Header{
+			Name:    f.name,
+			Mode:    0o600,
+			Size:    int64(len(f.data)),
+			ModTime: info.Time,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("diagnostics: write %s: %w", f.name, err)
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return fmt.Errorf("diagnostics: write %s: %w", f.name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("diagnostics: write bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("diagnostics: write bundle: %w", err)
+	}
+	return nil
+}
+
+// readSource calls src with panic recovery, since bundles are also written
+// while the agent is crashing.
+func readSource(src Source) (data []byte, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("source panicked: %v", v)
+		}
+	}()
+	return src()
+}
+
+// save writes a bundle to a new file in the bundle directory, deletes the
+// oldest bundles beyond MaxBundles, and returns the path of the file.
+func (c *Collector) save(info Info, stacks []byte, sources []namedSource) (string, error) {
+	if c.cfg.Dir == "" {
+		return "", fmt.Errorf("diagnostics: no bundle directory configured")
+	}
+	if err := os.MkdirAll(c.cfg.Dir, 0o700); err != nil {
+		return "", fmt.Errorf("diagnostics: create bundle directory: %w", err)
+	}
+	name := bundlePrefix + info.Time.UTC().Format(bundleTime) + bundleSuffix
+	path := filepath.Join(c.cfg.Dir, name)
+
+	// Write to a temporary file first so that Upload never reads a
+	// partial bundle.
+	tmp, err := os.CreateTemp(c.cfg.Dir, ".bundle-*")
+	if err != nil {
+		return "", fmt.Errorf("diagnostics: create bundle: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := c.write(tmp, info, stacks, sources); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("diagnostics: write bundle: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("diagnostics: write bundle: %w", err)
+	}
+	c.prune()
+	return path, nil
+}
+
+// prune deletes the oldest crash bundles beyond MaxBundles.
+func (c *Collector) prune() {
+	bundles, err := c.bundles()
+	if err != nil {
+		c.logger.Warn("listing crash bundles failed", "error", err)
+		return
+	}
+	for len(bundles) > c.cfg.MaxBundles {
+		if err := os.Remove(filepath.Join(c.cfg.Dir, bundles[0])); err != nil {
+			c.logger.Warn("deleting old crash bundle failed", "bundle", bundles[0], "error", err)
+		}
+		bundles = bundles[1:]
+	}
+}
+
+// bundles returns the file names of the crash bundles, oldest first.
+func (c *Collector) bundles() ([]string, error) {
+	entries, err := os.ReadDir(c.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasPrefix(e.Name(), bundlePrefix) && strings.HasSuffix(e.Name(), bundleSuffix) {
+			names = append(names, e.Name())
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// allStacks returns the stacks of all goroutines, at most maxStackBytes.
+func allStacks() []byte {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxStackBytes {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,146 @@
+package diagnostics
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func newTestCollector(t *testing.T) *Collector {
+	t.Helper()
+	cfg := Config{Dir: t.TempDir()}
+	cfg.ApplyDefaults()
+	return NewCollector(cfg, "v1.2.3", discardLogger())
+}
+
+// readBundle returns the files of a bundle by name.
+func readBundle(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	files := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatalf("tar: %v", err)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("read %s: %v", hdr.Name, err)
+		}
+		files[hdr.Name] = string(b)
+	}
+}
+
+func bundleInfo(t *testing.T, files map[string]string) Info {
+	t.Helper()
+	var info Info
+	if err := json.Unmarshal([]byte(files[infoFile]), &info); err != nil {
+		t.Fatalf("info.json: %v", err)
+	}
+	return info
+}
+
+func TestConfig_Validate(t *testing.T) {
+	var cfg Config
+	cfg.ApplyDefaults()
+	if cfg.MaxBundles != DefaultMaxBundles {
+		t.Errorf("MaxBundles = %d, want %d", cfg.MaxBundles, DefaultMaxBundles)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	for _, n := range []int{-1, 101} {
+		cfg.MaxBundles = n
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("MaxBundles %d: expected error", n)
+		}
+	}
+}
+
+func TestCollector_WriteBundle(t *testing.T) {
+	c := newTestCollector(t)
+	c.Add("config.yaml", func() ([]byte, error) { return []byte("old"), nil })
+	c.Add("logs.txt", func() ([]byte, error) { return nil, errors.New("log capture disabled") })
+	c.Add("reconcile-history.json", func() ([]byte, error) { panic("boom") })
+	c.Add("config.yaml", func() ([]byte, error) { return []byte("token: REDACTED"), nil })
+
+	var buf bytes.Buffer
+	if err := c.WriteBundle(&buf, "support"); err != nil {
+		t.Fatalf("WriteBundle: %v", err)
+	}
+	files := readBundle(t, buf.Bytes())
+
+	if files["config.yaml"] != "token: REDACTED" {
+		t.Errorf("config.yaml = %q, want the replaced source", files["config.yaml"])
+	}
+	if !strings.Contains(files[stacksFile], "TestCollector_WriteBundle") {
+		t.Errorf("%s does not hold the goroutine stacks", stacksFile)
+	}
+	info := bundleInfo(t, files)
+	if info.Reason != "support" || info.Version != "v1.2.3" || info.GoVersion == "" {
+		t.Errorf("info = %+v", info)
+	}
+	if want := []string{stacksFile, "config.yaml"}; strings.Join(info.Files, ",") != strings.Join(want, ",") {
+		t.Errorf("Files = %v, want %v", info.Files, want)
+	}
+	if info.Errors["logs.txt"] != "log capture disabled" {
+		t.Errorf("Errors[logs.txt] = %q", info.Errors["logs.txt"])
+	}
+	if !strings.Contains(info.Errors["reconcile-history.json"], "panicked") {
+		t.Errorf("Errors[reconcile-history.json] = %q", info.Errors["reconcile-history.json"])
+	}
+}
+
+func TestCollector_SavePrunes(t *testing.T) {
+	c := newTestCollector(t)
+	c.cfg.MaxBundles = 2
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	var paths []string
+	for i := range 3 {
+		info := c.info("crash")
+		info.Time = start.Add(time.Duration(i) * time.Second)
+		path, err := c.save(info, []byte("stacks"), nil)
+		if err != nil {
+			t.Fatalf("save: %v", err)
+		}
+		paths = append(paths, path)
+	}
+	if filepath.Base(paths[0]) != "plexd-crash-20260102T030405.000Z.tar.gz" {
+		t.Errorf("bundle name = %s", filepath.Base(paths[0]))
+	}
+
+	bundles, err := c.bundles()
+	if err != nil {
+		t.Fatalf("bundles: %v", err)
+	}
+	if len(bundles) != 2 || bundles[0] != filepath.Base(paths[1]) || bundles[1] != filepath.Base(paths[2]) {
+		t.Errorf("bundles = %v, want the 2 newest", bundles)
+	}
+	st, err := os.Stat(paths[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := st.Mode().Perm(); perm&0o077 != 0 {
+		t.Errorf("bundle mode = %v, want private", perm)
+	}
+}
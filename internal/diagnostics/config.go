@@ -0,0 +1,50 @@
+// Package diagnostics writes diagnostics bundles: gzipped tar archives with
+// the goroutine stacks, recent logs, reconcile history, and redacted config
+// of the agent. A bundle is written to the data directory when the agent
+// crashes, and on demand for "plexd support-bundle".
+package diagnostics
+
+import "errors"
+
+// DefaultMaxBundles is the default number of crash bundles kept.
+const DefaultMaxBundles = 5
+
+// maxMaxBundles is the largest accepted MaxBundles.
+const maxMaxBundles = 100
+
+// ReportKey is the node API report key the most recent crash is reported
+// under when Config.Upload is set.
+const ReportKey = "plexd.crash"
+
+// Config holds the configuration for crash bundles.
+type Config struct {
+	// Dir is the directory crash bundles are written to.
+	// Default: {DataDir}/crash (set by plexd up)
+	Dir string
+
+	// Upload reports the most recent crash bundle as the "plexd.crash"
+	// report entry when the agent runs again, so that the control plane
+	// learns about crashes without access to the node.
+	// Default: false
+	Upload bool
+
+	// MaxBundles is the number of crash bundles kept in Dir; the oldest
+	// are deleted first. Must be between 1 and 100.
+	// Default: 5
+	MaxBundles int
+}
+
+// ApplyDefaults sets default values for zero-valued fields.
+func (c *Config) ApplyDefaults() {
+	if c.MaxBundles == 0 {
+		c.MaxBundles = DefaultMaxBundles
+	}
+}
+
+// Validate checks that configuration values are within acceptable ranges.
+func (c *Config) Validate() error {
+	if c.MaxBundles < 1 || c.MaxBundles > maxMaxBundles {
+		return errors.New("diagnostics: config: MaxBundles must be between 1 and 100")
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package diagnostics
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+)
+
+// fatalOutputFile is the file in the bundle directory the Go runtime
+// writes the output of a fatal error to once Arm was called.
+const fatalOutputFile = "fatal.log"
+
+// uploadedFile records the name of the last uploaded crash bundle.
+const uploadedFile = ".uploaded"
+
+// maxUploadBytes bounds the bundles included in a crash report; larger
+// ones are reported without their data.
+const maxUploadBytes = 512 << 10
+
+// maxReportStackBytes bounds the stacks included in a crash report.
+const maxReportStackBytes = 64 << 10
+
+// ReportWriter writes node API report entries. Satisfied by
+// *nodeapi.Server.
+type ReportWriter interface {
+	WriteReport(key string, payload json.RawMessage) error
+}
+
+// CrashReport is the payload of the ReportKey report entry.
+type CrashReport struct {
+	Info
+
+	// Bundle is the file name of the bundle in the bundle directory.
+	Bundle string `json:"bundle"`
+
+	// Size is the size of the bundle in bytes.
+	Size int64 `json:"size"`
+
+	// Stacks holds the beginning of the goroutine stacks of the bundle.
+	Stacks string `json:"stacks,omitempty"`
+
+	// Data is the bundle itself, omitted if larger than 512 KiB.
+	Data []byte `json:"data,omitempty"`
+}
+
+// Arm prepares crash bundles for this run. A panic the agent recovers is
+// handed to Crash; every other fatal error, such as a panic in a goroutine
+// without a recover or a fatal runtime error, is written by the Go runtime
+// to fatal.log in the bundle directory. Arm turns the output a crashed
+// previous run left there into a bundle, which holds only the stacks of
+// that run, and then points the runtime at the file for this run.
+func (c *Collector) Arm() error {
+	if c.cfg.Dir == "" {
+		return errors.New("diagnostics: no bundle directory configured")
+	}
+	if err := os.MkdirAll(c.cfg.Dir, 0o700); err != nil {
+		return fmt.Errorf("diagnostics: create bundle directory: %w", err)
+	}
+	path := filepath.Join(c.cfg.Dir, fatalOutputFile)
+	if out, err := os.ReadFile(path); err == nil && len(bytes.TrimSpace(out)) > 0 {
+		info := c.info(firstLine(out) + " (previous run)")
+		if st, err := os.Stat(path); err == nil {
+			info.Time = st.ModTime().UTC()
+		}
+		info.PID = 0
+		bundle, err := c.save(info, out, nil)
+		if err != nil {
+			c.logger.Error("writing crash bundle of the previous run failed", "error", err)
+		} else {
+			c.logger.Warn("the previous run crashed; diagnostics bundle written", "bundle", bundle, "reason", info.Reason)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("diagnostics: open fatal error output: %w", err)
+	}
+	// SetCrashOutput duplicates the descriptor, so f can be closed.
+	defer f.Close()
+	if err := debug.SetCrashOutput(f, debug.CrashOptions{}); err != nil {
+		return fmt.Errorf("diagnostics: set crash output: %w", err)
+	}
+	return nil
+}
+
+// Crash writes a crash bundle for the panic v, with the stacks of all
+// goroutines and the files of the sources. It is called from a deferred
+// function that recovered v and panics again afterwards; the runtime then
+// no longer writes to fatal.log, so that the crash is not bundled again on
+// the next start. Only the first call writes a bundle. Crash does not
+// panic.
+func (c *Collector) Crash(v any) {
+	if !c.crashed.CompareAndSwap(false, true) {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			c.logger.Error("writing crash bundle panicked", "panic", r)
+		}
+	}()
+	info := c.info(fmt.Sprintf("panic: %v", v))
+	bundle, err := c.save(info, allStacks(), c.snapshot())
+	if err != nil {
+		c.logger.Error("writing crash bundle failed", "error", err)
+		return
+	}
+	debug.SetCrashOutput(nil, debug.CrashOptions{})
+	c.logger.Error("agent crashed; diagnostics bundle written", "bundle", bundle, "reason", info.Reason)
+}
+
+// Upload writes the most recent crash bundle to w as the ReportKey report
+// entry, unless it was uploaded before. It does nothing if there is no
+// crash bundle.
+func (c *Collector) Upload(w ReportWriter) error {
+	bundles, err := c.bundles()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("diagnostics: list crash bundles: %w", err)
+	}
+	if len(bundles) == 0 {
+		return nil
+	}
+	latest := bundles[len(bundles)-1]
+	marker := filepath.Join(c.cfg.Dir, uploadedFile)
+	if last, err := os.ReadFile(marker); err == nil && strings.TrimSpace(string(last)) == latest {
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.cfg.Dir, latest))
+	if err != nil {
+		return fmt.Errorf("diagnostics: read crash bundle: %w", err)
+	}
+	report, err := crashReport(latest, data)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("diagnostics: encode crash report: %w", err)
+	}
+	if err := w.WriteReport(ReportKey, payload); err != nil {
+		return fmt.Errorf("diagnostics: report crash: %w", err)
+	}
+	if err := os.WriteFile(marker, []byte(latest+"\n"), 0o600); err != nil {
+		return fmt.Errorf("diagnostics: record upload: %w", err)
+	}
+	c.logger.Info("crash reported", "bundle", latest, "reason", report.Reason)
+	return nil
+}
+
+// crashReport returns the report of the bundle data named name.
+func crashReport(name string, data []byte) (CrashReport, error) {
+	report := CrashReport{Bundle: name, Size: int64(len(data))}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return report, fmt.Errorf("diagnostics: read crash bundle %s: %w", name, err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return report, fmt.Errorf("diagnostics: read crash bundle %s: %w", name, err)
+		}
+		switch hdr.Name {
+		case infoFile:
+			if err := json.NewDecoder(tr).Decode(&report.Info); err != nil {
+				return report, fmt.Errorf("diagnostics: read crash bundle %s: %s: %w", name, infoFile, err)
+			}
+		case stacksFile:
+			stacks, err := io.ReadAll(io.LimitReader(tr, maxReportStackBytes))
+			if err != nil {
+				return report, fmt.Errorf("diagnostics: read crash bundle %s: %s: %w", name, stacksFile, err)
+			}
+			report.Stacks = string(stacks)
+		}
+	}
+	if len(data) <= maxUploadBytes {
+		report.Data = data
+	}
+	return report, nil
+}
+
+// firstLine returns the first non-empty line of out, such as
+// "panic: runtime error: ..." or "fatal error: concurrent map writes".
+func firstLine(out []byte) string {
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			return line
+		}
+	}
+	return "fatal error"
+}
@@ -0,0 +1,126 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+type mockReportWriter struct {
+	keys     []string
+	payloads []json.RawMessage
+}
+
+func (m *mockReportWriter) WriteReport(key string, payload json.RawMessage) error {
+	m.keys = append(m.keys, key)
+	m.payloads = append(m.payloads, payload)
+	return nil
+}
+
+// armForTest calls Arm and restores the crash output of the test binary
+// when the test ends.
+func armForTest(t *testing.T, c *Collector) {
+	t.Helper()
+	t.Cleanup(func() { debug.SetCrashOutput(nil, debug.CrashOptions{}) })
+	if err := c.Arm(); err != nil {
+		t.Fatalf("Arm: %v", err)
+	}
+}
+
+func TestCollector_ArmBundlesPreviousFatalError(t *testing.T) {
+	c := newTestCollector(t)
+	out := "fatal error: concurrent map writes\n\ngoroutine 1 [running]:\nmain.main()\n"
+	if err := os.WriteFile(filepath.Join(c.cfg.Dir, fatalOutputFile), []byte(out), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	armForTest(t, c)
+
+	bundles, err := c.bundles()
+	if err != nil || len(bundles) != 1 {
+		t.Fatalf("bundles = %v, %v; want one", bundles, err)
+	}
+	data, err := os.ReadFile(filepath.Join(c.cfg.Dir, bundles[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := readBundle(t, data)
+	if files[stacksFile] != out {
+		t.Errorf("%s = %q, want the fatal error output", stacksFile, files[stacksFile])
+	}
+	if info := bundleInfo(t, files); info.Reason != "fatal error: concurrent map writes (previous run)" {
+		t.Errorf("Reason = %q", info.Reason)
+	}
+
+	// The output file is truncated for this run, so a second start does not
+	// bundle the same crash again.
+	armForTest(t, c)
+	if bundles, _ := c.bundles(); len(bundles) != 1 {
+		t.Errorf("bundles after second Arm = %v, want one", bundles)
+	}
+}
+
+func TestCollector_Crash(t *testing.T) {
+	c := newTestCollector(t)
+	armForTest(t, c)
+	c.Add("config.yaml", func() ([]byte, error) { return []byte("mode: node"), nil })
+
+	c.Crash("nil map")
+	c.Crash("second panic")
+
+	bundles, err := c.bundles()
+	if err != nil || len(bundles) != 1 {
+		t.Fatalf("bundles = %v, %v; want one", bundles, err)
+	}
+	data, err := os.ReadFile(filepath.Join(c.cfg.Dir, bundles[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := readBundle(t, data)
+	if info := bundleInfo(t, files); info.Reason != "panic: nil map" {
+		t.Errorf("Reason = %q, want panic: nil map", info.Reason)
+	}
+	if files["config.yaml"] != "mode: node" {
+		t.Errorf("config.yaml = %q", files["config.yaml"])
+	}
+	if !strings.Contains(files[stacksFile], "TestCollector_Crash") {
+		t.Errorf("%s does not hold the goroutine stacks", stacksFile)
+	}
+}
+
+func TestCollector_Upload(t *testing.T) {
+	c := newTestCollector(t)
+	w := &mockReportWriter{}
+
+	if err := c.Upload(w); err != nil || len(w.keys) != 0 {
+		t.Fatalf("Upload without bundles: %v, %d reports", err, len(w.keys))
+	}
+
+	info := c.info("panic: boom")
+	if _, err := c.save(info, []byte("goroutine 1 [running]:"), nil); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := c.Upload(w); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if len(w.keys) != 1 || w.keys[0] != ReportKey {
+		t.Fatalf("reports = %v, want one %s", w.keys, ReportKey)
+	}
+	var report CrashReport
+	if err := json.Unmarshal(w.payloads[0], &report); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	if report.Reason != "panic: boom" || report.Stacks != "goroutine 1 [running]:" {
+		t.Errorf("report = %+v", report)
+	}
+	if report.Size == 0 || int64(len(report.Data)) != report.Size {
+		t.Errorf("Data = %d bytes, Size = %d", len(report.Data), report.Size)
+	}
+
+	// The same bundle is not reported twice.
+	if err := c.Upload(w); err != nil || len(w.keys) != 1 {
+		t.Errorf("second Upload: %v, %d reports", err, len(w.keys))
+	}
+}
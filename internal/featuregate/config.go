@@ -0,0 +1,39 @@
+// Package featuregate turns experimental agent features on or off per node.
+// A gate is set in the agent config or by the control plane through node
+// metadata; the local config takes precedence. The gates and their values
+// are published with the node's capabilities, so the control plane knows
+// which features each node supports and runs.
+package featuregate
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+)
+
+// Config holds the configuration for feature gates.
+type Config struct {
+	// Gates turns features on or off by name, e.g. DeltaState: "true".
+	// Values are parsed with strconv.ParseBool. A gate set here takes
+	// precedence over the control plane.
+	// Default: none; every feature has its default value
+	Gates map[string]string
+
+	// DisableRemote ignores gates set by the control plane.
+	// Default: false
+	DisableRemote bool
+}
+
+// Validate checks that required fields are set and values are acceptable.
+func (c *Config) Validate() error {
+	for _, name := range slices.Sorted(maps.Keys(c.Gates)) {
+		if _, ok := Known[Feature(name)]; !ok {
+			return fmt.Errorf("featuregate: config: unknown feature gate %q", name)
+		}
+		if _, err := strconv.ParseBool(c.Gates[name]); err != nil {
+			return fmt.Errorf("featuregate: config: feature gate %s: invalid value %q", name, c.Gates[name])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package featuregate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		gates   map[string]string
+		wantErr string
+	}{
+		{name: "empty"},
+		{name: "known", gates: map[string]string{"DeltaState": "true", "eBPFDataPlane": "0"}},
+		{name: "unknown", gates: map[string]string{"Teleport": "true"}, wantErr: `unknown feature gate "Teleport"`},
+		{name: "invalid value", gates: map[string]string{"DeltaState": "yes"}, wantErr: `feature gate DeltaState: invalid value "yes"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{Gates: tt.gates}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package featuregate
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+// Feature names an experimental feature.
+type Feature string
+
+// Features of this agent version.
+const (
+	// EBPFDataPlane processes mesh traffic with eBPF programs instead of
+	// nftables.
+	EBPFDataPlane Feature = "eBPFDataPlane"
+	// DeltaState fetches incremental changes of the desired state instead
+	// of the full state in every reconcile cycle.
+	DeltaState Feature = "DeltaState"
+)
+
+// Stage is the maturity of a feature.
+type Stage string
+
+// Stages of a feature.
+const (
+	Alpha Stage = "alpha"
+	Beta  Stage = "beta"
+)
+
+// Spec describes a feature.
+type Spec struct {
+	// Default is whether the feature is enabled when no gate is set.
+	Default bool
+	Stage   Stage
+}
+
+// Known lists the features this agent supports.
+var Known = map[Feature]Spec{
+	EBPFDataPlane: {Stage: Alpha},
+	DeltaState:    {Stage: Alpha},
+}
+
+// MetadataKey is the node metadata key through which the control plane
+// sets gates. Its value has the form "DeltaState=true,eBPFDataPlane=false".
+const MetadataKey = "feature_gates"
+
+// Sources of a gate value, as reported in api.FeatureGateInfo.
+const (
+	SourceDefault      = "default"
+	SourceConfig       = "config"
+	SourceControlPlane = "control_plane"
+)
+
+// Gates holds the feature gates of the node. Gates from the config are
+// fixed; gates from the control plane follow the node metadata in every
+// reconcile cycle. Safe for concurrent use.
+type Gates struct {
+	local         map[Feature]bool
+	disableRemote bool
+	logger        *slog.Logger
+
+	handlers []func()
+
+	mu          sync.Mutex
+	remote      map[Feature]bool
+	remoteValue string
+}
+
+// NewGates creates Gates from a validated Config.
+func NewGates(cfg Config, logger *slog.Logger) *Gates {
+	local := make(map[Feature]bool, len(cfg.Gates))
+	for name, v := range cfg.Gates {
+		if enabled, err := strconv.ParseBool(v); err == nil {
+			local[Feature(name)] = enabled
+		}
+	}
+	return &Gates{
+		local:         local,
+		disableRemote: cfg.DisableRemote,
+		logger:        logger.With("component", "featuregate"),
+	}
+}
+
+// OnChange registers fn to be called after a control plane gate changed
+// the value of a feature, typically to republish the capabilities.
+// Handlers are called in registration order from the reconcile handler and
+// should return quickly. Must be called before the reconcile handler runs.
+func (g *Gates) OnChange(fn func()) {
+	g.handlers = append(g.handlers, fn)
+}
+
+// Enabled reports whether feature f is enabled. Unknown features are
+// disabled.
+func (g *Gates) Enabled(f Feature) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	enabled, _ := g.valueLocked(f)
+	return enabled
+}
+
+// Capabilities returns every known feature with its value, sorted by name.
+func (g *Gates) Capabilities() []api.FeatureGateInfo {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.capabilitiesLocked()
+}
+
+func (g *Gates) capabilitiesLocked() []api.FeatureGateInfo {
+	features := slices.Sorted(maps.Keys(Known))
+	out := make([]api.FeatureGateInfo, 0, len(features))
+	for _, f := range features {
+		enabled, source := g.valueLocked(f)
+		out = append(out, api.FeatureGateInfo{
+			Name:    string(f),
+			Stage:   string(Known[f].Stage),
+			Enabled: enabled,
+			Source:  source,
+		})
+	}
+	return out
+}
+
+// valueLocked returns the value of f and where it comes from. Callers
+// must hold g.mu.
+func (g *Gates) valueLocked(f Feature) (bool, string) {
+	spec, ok := Known[f]
+	if !ok {
+		return false, SourceDefault
+	}
+	if v, ok := g.local[f]; ok {
+		return v, SourceConfig
+	}
+	if v, ok := g.remote[f]; ok {
+		return v, SourceControlPlane
+	}
+	return spec.Default, SourceDefault
+}
+
+// ReconcileHandler returns a handler that applies the gates in the
+// MetadataKey node metadata and calls the OnChange handlers when a feature
+// changed.
+func (g *Gates) ReconcileHandler() reconcile.ReconcileHandler {
+	return func(_ context.Context, desired *api.StateResponse, diff reconcile.StateDiff) error {
+		if !diff.MetadataChanged {
+			return nil
+		}
+		g.mu.Lock()
+		changed := g.setRemoteLocked(desired.Metadata[MetadataKey])
+		g.mu.Unlock()
+		if changed {
+			for _, fn := range g.handlers {
+				fn()
+			}
+		}
+		return nil
+	}
+}
+
+// setRemoteLocked applies the control plane gates in value. An invalid
+// value is logged and the previous gates stay in effect; unknown features
+// are ignored, since the control plane may know newer features than this
+// agent. It reports whether the value of a feature changed. Callers must
+// hold g.mu.
+func (g *Gates) setRemoteLocked(value string) bool {
+	if value == g.remoteValue {
+		return false
+	}
+	g.remoteValue = value
+	if g.disableRemote {
+		g.logger.Info("control plane feature gates ignored", "value", value)
+		return false
+	}
+	gates, err := Parse(value)
+	if err != nil {
+		g.logger.Error("invalid control plane feature gates, previous gates kept", "value", value, "error", err)
+		return false
+	}
+	for f := range gates {
+		if _, ok := Known[f]; !ok {
+			g.logger.Warn("unknown feature gate from control plane ignored", "feature", string(f))
+			delete(gates, f)
+		}
+	}
+
+	before := g.capabilitiesLocked()
+	g.remote = gates
+	changed := false
+	for i, gate := range g.capabilitiesLocked() {
+		if gate.Enabled != before[i].Enabled {
+			g.logger.Info("feature gate changed", "feature", gate.Name, "enabled", gate.Enabled, "source", gate.Source)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Parse parses gates of the form "DeltaState=true,eBPFDataPlane=false".
+// Values are parsed with strconv.ParseBool; empty items are skipped.
+func Parse(s string) (map[Feature]bool, error) {
+	gates := make(map[Feature]bool)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("featuregate: invalid gate %q (want name=bool)", item)
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("featuregate: gate %s: invalid value %q", name, value)
+		}
+		gates[Feature(name)] = enabled
+	}
+	return gates, nil
+}
@@ -0,0 +1,120 @@
+package featuregate
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// metadataCycle runs handler for a cycle whose desired state has the given
+// feature gate metadata.
+func metadataCycle(t *testing.T, handler reconcile.ReconcileHandler, value string) error {
+	t.Helper()
+	desired := &api.StateResponse{Metadata: map[string]string{MetadataKey: value}}
+	return handler(context.Background(), desired, reconcile.StateDiff{MetadataChanged: true})
+}
+
+func gateInfo(caps []api.FeatureGateInfo, name string) api.FeatureGateInfo {
+	for _, c := range caps {
+		if c.Name == name {
+			return c
+		}
+	}
+	return api.FeatureGateInfo{}
+}
+
+func TestGates_Defaults(t *testing.T) {
+	g := NewGates(Config{}, testLogger())
+	if g.Enabled(DeltaState) || g.Enabled(EBPFDataPlane) || g.Enabled("Unknown") {
+		t.Error("feature enabled without a gate")
+	}
+	caps := g.Capabilities()
+	if len(caps) != len(Known) {
+		t.Fatalf("Capabilities() = %+v, want %d features", caps, len(Known))
+	}
+	if caps[0].Name != string(DeltaState) || caps[0].Source != SourceDefault || caps[0].Stage != string(Alpha) {
+		t.Errorf("Capabilities()[0] = %+v, want DeltaState from default", caps[0])
+	}
+}
+
+func TestGates_ControlPlane(t *testing.T) {
+	g := NewGates(Config{Gates: map[string]string{"eBPFDataPlane": "false"}}, testLogger())
+	changes := 0
+	g.OnChange(func() { changes++ })
+	handler := g.ReconcileHandler()
+
+	// The config takes precedence; unknown features are ignored.
+	if err := metadataCycle(t, handler, "DeltaState=true, eBPFDataPlane=true, Teleport=true"); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	if !g.Enabled(DeltaState) || g.Enabled(EBPFDataPlane) {
+		t.Errorf("DeltaState, eBPFDataPlane = %v, %v, want true, false", g.Enabled(DeltaState), g.Enabled(EBPFDataPlane))
+	}
+	if changes != 1 {
+		t.Fatalf("OnChange called %d times, want 1", changes)
+	}
+	caps := g.Capabilities()
+	if got := gateInfo(caps, "DeltaState"); !got.Enabled || got.Source != SourceControlPlane {
+		t.Errorf("DeltaState = %+v, want enabled by the control plane", got)
+	}
+	if got := gateInfo(caps, "eBPFDataPlane"); got.Enabled || got.Source != SourceConfig {
+		t.Errorf("eBPFDataPlane = %+v, want disabled by the config", got)
+	}
+
+	// A value that does not change a feature is not reported.
+	if err := metadataCycle(t, handler, "DeltaState=true"); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	if changes != 1 {
+		t.Errorf("OnChange called %d times after no change, want 1", changes)
+	}
+
+	// An invalid value keeps the previous gates.
+	if err := metadataCycle(t, handler, "DeltaState"); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	if !g.Enabled(DeltaState) {
+		t.Error("DeltaState disabled by an invalid value")
+	}
+
+	// Removing the key drops the control plane gates.
+	if err := metadataCycle(t, handler, ""); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	if g.Enabled(DeltaState) || changes != 2 {
+		t.Errorf("DeltaState = %v, OnChange called %d times, want false and 2", g.Enabled(DeltaState), changes)
+	}
+}
+
+func TestGates_DisableRemote(t *testing.T) {
+	g := NewGates(Config{DisableRemote: true}, testLogger())
+	if err := metadataCycle(t, g.ReconcileHandler(), "DeltaState=true"); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	if g.Enabled(DeltaState) {
+		t.Error("control plane gate applied with DisableRemote")
+	}
+}
+
+func TestParse(t *testing.T) {
+	gates, err := Parse("DeltaState=true,,eBPFDataPlane = false")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(gates) != 2 || !gates[DeltaState] || gates[EBPFDataPlane] {
+		t.Errorf("Parse() = %v", gates)
+	}
+	for _, s := range []string{"DeltaState", "=true", "DeltaState=maybe"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q) = nil error, want error", s)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package fsutil
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// WipeFile overwrites the contents of path with random bytes, syncs it to
+// disk, and removes it. A missing file is not an error.
+//
+// Overwriting in place does not reach copies left behind by journaling or
+// copy-on-write filesystems, or by SSD wear levelling; it keeps plain reads of
+// the disk from recovering the data.
+func WipeFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := io.CopyN(f, rand.Reader, info.Size()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// WipeDir wipes every regular file below dir with WipeFile and removes the
+// tree. A missing directory is not an error. All files are attempted; the
+// errors are joined.
+func WipeDir(dir string) error {
+	var errs []error
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			errs = append(errs, err)
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if err := WipeFile(path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
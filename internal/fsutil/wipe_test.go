@@ -0,0 +1,52 @@
+package fsutil
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWipeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("node-secret-key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WipeFile(path); err != nil {
+		t.Fatalf("WipeFile() error = %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("file still exists after wipe: %v", err)
+	}
+}
+
+func TestWipeFile_Missing(t *testing.T) {
+	if err := WipeFile(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("WipeFile() on missing file error = %v, want nil", err)
+	}
+}
+
+func TestWipeDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	for _, name := range []string{"secrets.json", "data/a.json", "report/b.json"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("payload"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := WipeDir(dir); err != nil {
+		t.Fatalf("WipeDir() error = %v", err)
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("directory still exists after wipe: %v", err)
+	}
+	if err := WipeDir(dir); err != nil {
+		t.Errorf("WipeDir() on missing dir error = %v, want nil", err)
+	}
+}
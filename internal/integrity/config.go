@@ -3,12 +3,25 @@ package integrity
 
 import (
 	"errors"
+	"fmt"
 	"time"
 )
 
 // DefaultVerifyInterval is the default interval between integrity verification runs.
 const DefaultVerifyInterval = 5 * time.Minute
 
+// Signature modes control what happens when the binary or config signature
+// does not verify at startup.
+const (
+	// SignatureModeOff skips signature verification.
+	SignatureModeOff = "off"
+	// SignatureModeRestricted starts the agent without remote access and
+	// remote execution features.
+	SignatureModeRestricted = "restricted"
+	// SignatureModeEnforce refuses to start.
+	SignatureModeEnforce = "enforce"
+)
+
 // Config holds the configuration for integrity verification.
 type Config struct {
 	// Enabled controls whether integrity verification is active.
@@ -21,6 +34,19 @@ type Config struct {
 	// HooksDir is the directory containing hook scripts to verify.
 	HooksDir string
 
+	// ConfigPath is the path to the agent config file whose signature is
+	// verified at startup. Empty skips the config signature. It is not read
+	// from the config file, which could otherwise point it at another signed
+	// file; plexd up sets it to the --config file.
+	ConfigPath string `yaml:"-"`
+
+	// SignatureMode selects the reaction to a missing or invalid detached
+	// signature of the binary or config file at startup: off, restricted, or
+	// enforce. It is not read from the config file, which could otherwise
+	// turn its own verification off; plexd up sets it from --signature-mode.
+	// Default: off
+	SignatureMode string `yaml:"-"`
+
 	// VerifyInterval is the interval between integrity verification runs.
 	// Must be at least 30s when enabled.
 	// Default: 5m
@@ -38,6 +64,9 @@ func (c *Config) ApplyDefaults() {
 		c.Enabled = true
 		c.VerifyInterval = DefaultVerifyInterval
 	}
+	if c.SignatureMode == "" {
+		c.SignatureMode = SignatureModeOff
+	}
 }
 
 // Validate checks that configuration values are within acceptable ranges.
@@ -48,5 +77,10 @@ func (c *Config) Validate() error {
 	if c.VerifyInterval < 30*time.Second {
 		return errors.New("integrity: config: VerifyInterval must be at least 30s when enabled")
 	}
+	switch c.SignatureMode {
+	case "", SignatureModeOff, SignatureModeRestricted, SignatureModeEnforce:
+	default:
+		return fmt.Errorf("integrity: config: SignatureMode must be %q, %q, or %q", SignatureModeOff, SignatureModeRestricted, SignatureModeEnforce)
+	}
 	return nil
 }
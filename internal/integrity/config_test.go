@@ -15,6 +15,9 @@ func TestConfig_Defaults(t *testing.T) {
 	if cfg.VerifyInterval != DefaultVerifyInterval {
 		t.Errorf("VerifyInterval = %v, want %v", cfg.VerifyInterval, DefaultVerifyInterval)
 	}
+	if cfg.SignatureMode != SignatureModeOff {
+		t.Errorf("SignatureMode = %q, want %q", cfg.SignatureMode, SignatureModeOff)
+	}
 }
 
 func TestConfig_DefaultsPreserveExplicitDisabled(t *testing.T) {
@@ -84,3 +87,22 @@ func TestConfig_ValidateAcceptsCustomValues(t *testing.T) {
 		t.Errorf("Validate() = %v, want nil", err)
 	}
 }
+
+func TestConfig_ValidateSignatureMode(t *testing.T) {
+	for _, mode := range []string{SignatureModeOff, SignatureModeRestricted, SignatureModeEnforce} {
+		cfg := Config{Enabled: true, VerifyInterval: DefaultVerifyInterval, SignatureMode: mode}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Validate(%q) = %v, want nil", mode, err)
+		}
+	}
+
+	cfg := Config{Enabled: true, VerifyInterval: DefaultVerifyInterval, SignatureMode: "strict"}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want error for unknown SignatureMode")
+	}
+	want := `integrity: config: SignatureMode must be "off", "restricted", or "enforce"`
+	if err.Error() != want {
+		t.Errorf("Validate() error = %q, want %q", err.Error(), want)
+	}
+}
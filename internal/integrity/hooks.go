@@ -0,0 +1,185 @@
+package integrity
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// SetApprovedHooks replaces the approved hook set with the name and checksum
+// of each hook. It is called with the hooks reported in the node's
+// capabilities; hooks in HooksDir that are missing from the set or whose
+// checksum differs are quarantined by VerifyHooks and WatchHooks. A
+// quarantined hook whose file matches its new approved checksum is released,
+// so that a change seen by WatchHooks before it was approved does not stay
+// quarantined.
+func (v *Verifier) SetApprovedHooks(hooks []api.HookInfo) {
+	approved := make(map[string]string, len(hooks))
+	for _, h := range hooks {
+		approved[h.Name] = h.Checksum
+	}
+
+	v.mu.Lock()
+	v.approvedHooks = approved
+	var recheck []string
+	for path := range v.quarantined {
+		if _, ok := approved[filepath.Base(path)]; ok {
+			recheck = append(recheck, path)
+		}
+	}
+	v.mu.Unlock()
+
+	for _, path := range recheck {
+		actual, err := HashFile(path)
+		if err != nil || actual != approved[filepath.Base(path)] {
+			continue
+		}
+		if v.release(path) {
+			v.logger.Info("hook released from quarantine", "path", path, "checksum", actual)
+		}
+	}
+}
+
+// HookQuarantined reports whether the hook at hookPath is quarantined.
+func (v *Verifier) HookQuarantined(hookPath string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	_, ok := v.quarantined[hookPath]
+	return ok
+}
+
+// VerifyHooks checks every approved hook and every executable file in
+// HooksDir against the approved hook set. It does nothing until
+// SetApprovedHooks has been called.
+func (v *Verifier) VerifyHooks(ctx context.Context, nodeID string) {
+	if v.cfg.HooksDir == "" {
+		return
+	}
+
+	v.mu.Lock()
+	names := make(map[string]struct{}, len(v.approvedHooks))
+	for name := range v.approvedHooks {
+		names[name] = struct{}{}
+	}
+	v.mu.Unlock()
+
+	entries, err := os.ReadDir(v.cfg.HooksDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		v.logger.Error("hooks dir read failed", "path", v.cfg.HooksDir, "error", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() && isHookName(e.Name()) {
+			names[e.Name()] = struct{}{}
+		}
+	}
+
+	for name := range names {
+		v.checkHook(ctx, nodeID, name)
+	}
+}
+
+// isHookName reports whether name can be a hook script. Sidecar .json files
+// hold hook metadata, and hidden files are temporary or state files written
+// while hooks are installed; neither are hooks.
+func isHookName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ".") && !strings.HasSuffix(name, ".json")
+}
+
+// checkHook compares the hook name against the approved hook set. A hook that
+// was modified, removed, cannot be read, or appeared without approval is
+// quarantined and reported; a quarantined hook whose checksum matches the
+// approved one again is released. Each distinct state is reported once.
+func (v *Verifier) checkHook(ctx context.Context, nodeID, name string) {
+	path := filepath.Join(v.cfg.HooksDir, name)
+
+	v.mu.Lock()
+	approved := v.approvedHooks
+	expected, known := approved[name]
+	v.mu.Unlock()
+	if approved == nil {
+		return
+	}
+
+	var actual, detail string
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if !known {
+			v.release(path)
+			return
+		}
+		detail = "approved hook removed"
+	case err != nil:
+		detail = "hook stat failed: " + err.Error()
+	case info.IsDir():
+		return
+	case !known && info.Mode().Perm()&0o111 == 0:
+		// Not executable and not approved: not a hook.
+		v.release(path)
+		return
+	default:
+		actual, err = HashFile(path)
+		switch {
+		case err != nil:
+			detail = "hook hash failed: " + err.Error()
+		case !known:
+			detail = "hook not in approved set"
+		case actual != expected:
+			detail = "hook checksum mismatch"
+		}
+	}
+
+	if detail == "" {
+		if v.release(path) {
+			v.logger.Info("hook released from quarantine", "path", path, "checksum", actual)
+		}
+		return
+	}
+
+	state := detail + "\x00" + actual
+	v.mu.Lock()
+	if v.quarantined == nil {
+		v.quarantined = make(map[string]string)
+	}
+	prev, already := v.quarantined[path]
+	v.quarantined[path] = state
+	v.mu.Unlock()
+	if already && prev == state {
+		return
+	}
+
+	v.logger.Error("hook quarantined",
+		"path", path,
+		"expected_checksum", expected,
+		"actual_checksum", actual,
+		"detail", detail,
+	)
+
+	report := api.IntegrityViolationReport{
+		Type:             ViolationTypeHook,
+		Path:             path,
+		ExpectedChecksum: expected,
+		ActualChecksum:   actual,
+		Detail:           detail,
+		Timestamp:        time.Now().UTC(),
+	}
+	if err := v.reporter.ReportViolation(ctx, nodeID, report); err != nil {
+		v.logger.Warn("failed to report hook violation", "error", err)
+	}
+}
+
+// release lifts the quarantine of path and reports whether it was
+// quarantined.
+func (v *Verifier) release(path string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	_, ok := v.quarantined[path]
+	delete(v.quarantined, path)
+	return ok
+}
@@ -0,0 +1,223 @@
+package integrity
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// newHookVerifier returns a Verifier watching a fresh hooks dir with one
+// approved executable hook named "deploy".
+func newHookVerifier(t *testing.T) (*Verifier, *mockReporter, string) {
+	t.Helper()
+	dir := t.TempDir()
+	hooksDir := filepath.Join(dir, "hooks")
+	if err := os.Mkdir(hooksDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeHook(t, hooksDir, "deploy", "#!/bin/sh\necho deploy\n")
+
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	reporter := &mockReporter{}
+	v := NewVerifier(Config{
+		Enabled:        true,
+		HooksDir:       hooksDir,
+		VerifyInterval: DefaultVerifyInterval,
+	}, store, reporter, slog.Default())
+	v.SetApprovedHooks([]api.HookInfo{{Name: "deploy", Checksum: sha256Hex("#!/bin/sh\necho deploy\n")}})
+	return v, reporter, hooksDir
+}
+
+func writeHook(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte(content), 0o755); err != nil {
+		t.Fatalf("write hook: %v", err)
+	}
+	return p
+}
+
+func TestVerifier_VerifyHooks_Unchanged(t *testing.T) {
+	v, reporter, hooksDir := newHookVerifier(t)
+
+	v.VerifyHooks(context.Background(), "node-1")
+
+	if viol := reporter.get(); len(viol) != 0 {
+		t.Errorf("unexpected violations: %v", viol)
+	}
+	if v.HookQuarantined(filepath.Join(hooksDir, "deploy")) {
+		t.Error("unchanged hook is quarantined")
+	}
+}
+
+func TestVerifier_VerifyHooks_NoApprovedSet(t *testing.T) {
+	v, reporter, hooksDir := newHookVerifier(t)
+	v.mu.Lock()
+	v.approvedHooks = nil
+	v.mu.Unlock()
+	writeHook(t, hooksDir, "deploy", "tampered")
+
+	v.VerifyHooks(context.Background(), "node-1")
+
+	if viol := reporter.get(); len(viol) != 0 {
+		t.Errorf("violations before the approved set is known: %v", viol)
+	}
+}
+
+func TestVerifier_VerifyHooks_ModifiedHookQuarantined(t *testing.T) {
+	v, reporter, hooksDir := newHookVerifier(t)
+	path := writeHook(t, hooksDir, "deploy", "#!/bin/sh\nrm -rf /\n")
+
+	v.VerifyHooks(context.Background(), "node-1")
+
+	viol := reporter.get()
+	if len(viol) != 1 {
+		t.Fatalf("violations = %d, want 1", len(viol))
+	}
+	if viol[0].Type != ViolationTypeHook || viol[0].Path != path {
+		t.Errorf("violation = %+v", viol[0])
+	}
+	if viol[0].Detail != "hook checksum mismatch" {
+		t.Errorf("Detail = %q", viol[0].Detail)
+	}
+	if viol[0].ActualChecksum != sha256Hex("#!/bin/sh\nrm -rf /\n") {
+		t.Errorf("ActualChecksum = %q", viol[0].ActualChecksum)
+	}
+	if !v.HookQuarantined(path) {
+		t.Error("modified hook is not quarantined")
+	}
+
+	// The same state is reported only once.
+	v.VerifyHooks(context.Background(), "node-1")
+	if got := len(reporter.get()); got != 1 {
+		t.Errorf("violations after re-check = %d, want 1", got)
+	}
+}
+
+func TestVerifier_VerifyHooks_QuarantineRefusesExecution(t *testing.T) {
+	v, _, hooksDir := newHookVerifier(t)
+	content := "#!/bin/sh\necho modified\n"
+	path := writeHook(t, hooksDir, "deploy", content)
+
+	v.VerifyHooks(context.Background(), "node-1")
+
+	// Even a request carrying the checksum of the modified file is refused.
+	ok, err := v.VerifyHook(context.Background(), "node-1", path, sha256Hex(content))
+	if err != nil {
+		t.Fatalf("VerifyHook: %v", err)
+	}
+	if ok {
+		t.Error("VerifyHook() = true for a quarantined hook")
+	}
+}
+
+func TestVerifier_VerifyHooks_RestoredHookReleased(t *testing.T) {
+	v, reporter, hooksDir := newHookVerifier(t)
+	path := writeHook(t, hooksDir, "deploy", "tampered")
+	v.VerifyHooks(context.Background(), "node-1")
+	if !v.HookQuarantined(path) {
+		t.Fatal("modified hook is not quarantined")
+	}
+
+	writeHook(t, hooksDir, "deploy", "#!/bin/sh\necho deploy\n")
+	v.VerifyHooks(context.Background(), "node-1")
+
+	if v.HookQuarantined(path) {
+		t.Error("restored hook is still quarantined")
+	}
+	if got := len(reporter.get()); got != 1 {
+		t.Errorf("violations = %d, want 1", got)
+	}
+}
+
+func TestVerifier_VerifyHooks_UnapprovedHook(t *testing.T) {
+	v, reporter, hooksDir := newHookVerifier(t)
+	path := writeHook(t, hooksDir, "backdoor", "#!/bin/sh\n")
+	if err := os.WriteFile(filepath.Join(hooksDir, "notes.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(hooksDir, "backdoor.json"), []byte("{}"), 0o755); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	v.VerifyHooks(context.Background(), "node-1")
+
+	viol := reporter.get()
+	if len(viol) != 1 {
+		t.Fatalf("violations = %v, want 1", viol)
+	}
+	if viol[0].Path != path || viol[0].Detail != "hook not in approved set" {
+		t.Errorf("violation = %+v", viol[0])
+	}
+	if !v.HookQuarantined(path) {
+		t.Error("unapproved hook is not quarantined")
+	}
+}
+
+func TestVerifier_VerifyHooks_RemovedHook(t *testing.T) {
+	v, reporter, hooksDir := newHookVerifier(t)
+	path := filepath.Join(hooksDir, "deploy")
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	v.VerifyHooks(context.Background(), "node-1")
+
+	viol := reporter.get()
+	if len(viol) != 1 || viol[0].Detail != "approved hook removed" {
+		t.Fatalf("violations = %v", viol)
+	}
+	if viol[0].ActualChecksum != "" {
+		t.Errorf("ActualChecksum = %q, want empty", viol[0].ActualChecksum)
+	}
+}
+
+func TestVerifier_SetApprovedHooks_ApprovesNewChecksum(t *testing.T) {
+	v, reporter, hooksDir := newHookVerifier(t)
+	content := "#!/bin/sh\necho v2\n"
+	path := writeHook(t, hooksDir, "deploy", content)
+
+	v.SetApprovedHooks([]api.HookInfo{{Name: "deploy", Checksum: sha256Hex(content)}})
+	v.VerifyHooks(context.Background(), "node-1")
+
+	if viol := reporter.get(); len(viol) != 0 {
+		t.Errorf("unexpected violations: %v", viol)
+	}
+	ok, err := v.VerifyHook(context.Background(), "node-1", path, sha256Hex(content))
+	if err != nil || !ok {
+		t.Errorf("VerifyHook() = %v, %v; want true, nil", ok, err)
+	}
+}
+
+func TestVerifier_SetApprovedHooks_ReleasesApprovedChange(t *testing.T) {
+	v, _, hooksDir := newHookVerifier(t)
+	content := "#!/bin/sh\necho v2\n"
+	path := writeHook(t, hooksDir, "deploy", content)
+	v.VerifyHooks(context.Background(), "node-1")
+	if !v.HookQuarantined(path) {
+		t.Fatal("modified hook is not quarantined")
+	}
+
+	v.SetApprovedHooks([]api.HookInfo{{Name: "deploy", Checksum: sha256Hex(content)}})
+	if v.HookQuarantined(path) {
+		t.Error("approved hook is still quarantined")
+	}
+}
+
+func TestVerifier_VerifyHooks_IgnoresHiddenFiles(t *testing.T) {
+	v, reporter, hooksDir := newHookVerifier(t)
+	writeHook(t, hooksDir, ".tmp-deploy", "#!/bin/sh\necho partial\n")
+
+	v.VerifyHooks(context.Background(), "node-1")
+
+	if viol := reporter.get(); len(viol) != 0 {
+		t.Errorf("unexpected violations: %v", viol)
+	}
+}
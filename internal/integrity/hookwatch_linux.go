@@ -0,0 +1,89 @@
+//go:build linux
+
+package integrity
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// hookWatchMask selects the inotify events that can change a hook: completed
+// writes, files created, removed, or renamed into or out of the directory,
+// and permission changes that make a file executable.
+const hookWatchMask = unix.IN_CLOSE_WRITE | unix.IN_CREATE | unix.IN_DELETE |
+	unix.IN_MOVED_TO | unix.IN_MOVED_FROM | unix.IN_ATTRIB
+
+// WatchHooks watches HooksDir with inotify and checks each changed hook
+// against the approved hook set as soon as it changes, until ctx is
+// cancelled. Modified or unapproved hooks are quarantined and reported
+// immediately instead of on the next periodic run.
+func (v *Verifier) WatchHooks(ctx context.Context, nodeID string) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("integrity: hook watch: inotify init: %w", err)
+	}
+	// The non-blocking descriptor is handed to the runtime poller, so Close
+	// unblocks a pending Read.
+	f := os.NewFile(uintptr(fd), "inotify")
+	defer f.Close()
+
+	if _, err := unix.InotifyAddWatch(fd, v.cfg.HooksDir, hookWatchMask); err != nil {
+		return fmt.Errorf("integrity: hook watch: watch %s: %w", v.cfg.HooksDir, err)
+	}
+	v.logger.Info("watching hooks dir", "path", v.cfg.HooksDir)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			f.Close()
+		case <-stop:
+		}
+	}()
+
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, os.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("integrity: hook watch: read: %w", err)
+		}
+		for _, name := range hookEventNames(buf[:n]) {
+			v.checkHook(ctx, nodeID, name)
+		}
+	}
+}
+
+// hookEventNames returns the distinct hook names of the inotify events in
+// buf, in order of first occurrence.
+func hookEventNames(buf []byte) []string {
+	var names []string
+	seen := make(map[string]struct{})
+	for off := 0; off+unix.SizeofInotifyEvent <= len(buf); {
+		ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+		start := off + unix.SizeofInotifyEvent
+		end := start + int(ev.Len)
+		if end > len(buf) {
+			break
+		}
+		off = end
+		name := string(bytes.TrimRight(buf[start:end], "\x00"))
+		if !isHookName(name) {
+			continue
+		}
+		if _, ok := seen[name]; !ok {
+			seen[name] = struct{}{}
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,83 @@
+//go:build linux
+
+package integrity
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestVerifier_WatchHooks_ReportsImmediately(t *testing.T) {
+	v, reporter, hooksDir := newHookVerifier(t)
+	path := filepath.Join(hooksDir, "deploy")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- v.WatchHooks(ctx, "node-1") }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("WatchHooks: %v", err)
+		}
+	}()
+
+	// The watch is registered asynchronously; keep modifying the hook until
+	// the change is observed.
+	deadline := time.Now().Add(2 * time.Second)
+	for i := 0; len(reporter.get()) == 0; i++ {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the hook violation")
+		}
+		if err := os.WriteFile(path, []byte("tampered "+string(rune('a'+i%26))), 0o755); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	viol := reporter.get()
+	if viol[0].Path != path || viol[0].Type != ViolationTypeHook {
+		t.Errorf("violation = %+v", viol[0])
+	}
+	if !v.HookQuarantined(path) {
+		t.Error("modified hook is not quarantined")
+	}
+}
+
+func TestVerifier_WatchHooks_MissingDir(t *testing.T) {
+	v, _, hooksDir := newHookVerifier(t)
+	if err := os.RemoveAll(hooksDir); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	if err := v.WatchHooks(context.Background(), "node-1"); err == nil {
+		t.Error("WatchHooks() = nil, want error for a missing dir")
+	}
+}
+
+func TestHookEventNames_SkipsSidecars(t *testing.T) {
+	buf := append(inotifyEvent("deploy"), inotifyEvent("deploy.json")...)
+	buf = append(buf, inotifyEvent("deploy")...)
+	buf = append(buf, inotifyEvent("backup")...)
+
+	got := hookEventNames(buf)
+	if len(got) != 2 || got[0] != "deploy" || got[1] != "backup" {
+		t.Errorf("hookEventNames() = %v, want [deploy backup]", got)
+	}
+}
+
+// inotifyEvent encodes an IN_CLOSE_WRITE event for name with NUL padding.
+func inotifyEvent(name string) []byte {
+	nameLen := (len(name)/8 + 1) * 8
+	buf := make([]byte, unix.SizeofInotifyEvent+nameLen)
+	ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[0]))
+	ev.Mask = unix.IN_CLOSE_WRITE
+	ev.Len = uint32(nameLen)
+	copy(buf[unix.SizeofInotifyEvent:], name)
+	return buf
+}
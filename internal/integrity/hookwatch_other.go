@@ -0,0 +1,15 @@
+//go:build !linux
+
+package integrity
+
+import (
+	"context"
+	"errors"
+)
+
+// WatchHooks watches HooksDir for changes. Directory watching is only
+// supported on Linux; elsewhere it returns an error immediately and hooks
+// are verified by the periodic run and before each execution.
+func (v *Verifier) WatchHooks(ctx context.Context, nodeID string) error {
+	return errors.New("integrity: hook watch: not supported on this platform")
+}
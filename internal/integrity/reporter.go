@@ -0,0 +1,29 @@
+package integrity
+
+import (
+	"context"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// ViolationClient is the subset of the control plane client used to report
+// violations. It is satisfied by *api.ControlPlane.
+type ViolationClient interface {
+	ReportIntegrityViolation(ctx context.Context, nodeID string, req api.IntegrityViolationReport) error
+}
+
+// ControlPlaneReporter is a ViolationReporter that posts violations to the
+// control plane.
+type ControlPlaneReporter struct {
+	client ViolationClient
+}
+
+// NewControlPlaneReporter returns a ControlPlaneReporter using client.
+func NewControlPlaneReporter(client ViolationClient) *ControlPlaneReporter {
+	return &ControlPlaneReporter{client: client}
+}
+
+// ReportViolation reports a violation of nodeID.
+func (r *ControlPlaneReporter) ReportViolation(ctx context.Context, nodeID string, report api.IntegrityViolationReport) error {
+	return r.client.ReportIntegrityViolation(ctx, nodeID, report)
+}
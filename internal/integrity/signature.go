@@ -0,0 +1,134 @@
+package integrity
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// SignatureSuffix is appended to a file path to locate its detached
+// signature, e.g. /usr/local/bin/plexd.sig.
+const SignatureSuffix = ".sig"
+
+// ErrSignatureInvalid is returned by VerifySignatures when a signature is
+// missing or does not verify.
+var ErrSignatureInvalid = errors.New("integrity: signature verification failed")
+
+// SignatureVerifier checks Ed25519 signatures with the control plane signing
+// keys. It is satisfied by *api.Ed25519Verifier.
+type SignatureVerifier interface {
+	VerifySignature(message []byte, signature string) error
+}
+
+// VerifySignatures checks the detached Ed25519 signatures of BinaryPath and,
+// when set, ConfigPath. config is the content of ConfigPath as read by the
+// caller: it is verified instead of the file so that the caller parses the
+// bytes that were verified. Each signature file holds the signature of the
+// whole file, raw or base64-encoded. A missing or invalid signature is
+// logged and reported as a violation, and ErrSignatureInvalid is returned;
+// the caller applies SignatureMode. It does nothing when SignatureMode is
+// off.
+func (v *Verifier) VerifySignatures(ctx context.Context, nodeID string, sv SignatureVerifier, config []byte) error {
+	return v.verifySignatures(ctx, nodeID, sv, true, config)
+}
+
+// VerifyConfigSignature checks the detached signature of ConfigPath like
+// VerifySignatures, without the binary. It is used before a changed config
+// file is applied at runtime.
+func (v *Verifier) VerifyConfigSignature(ctx context.Context, nodeID string, sv SignatureVerifier, config []byte) error {
+	return v.verifySignatures(ctx, nodeID, sv, false, config)
+}
+
+// verifySignatures checks config against the signature of ConfigPath and,
+// if binary is set, the signature of BinaryPath.
+func (v *Verifier) verifySignatures(ctx context.Context, nodeID string, sv SignatureVerifier, binary bool, config []byte) error {
+	if !v.cfg.Enabled || v.cfg.SignatureMode == SignatureModeOff {
+		return nil
+	}
+
+	var failed []string
+	check := func(violationType, path string, data []byte) {
+		if path == "" {
+			return
+		}
+		if data == nil {
+			var err error
+			if data, err = os.ReadFile(path); err != nil {
+				v.reportSignature(ctx, nodeID, violationType, path, "", fmt.Errorf("read file: %w", err))
+				failed = append(failed, path)
+				return
+			}
+		}
+		if err := verifyDataSignature(path, data, sv); err != nil {
+			sum := sha256.Sum256(data)
+			v.reportSignature(ctx, nodeID, violationType, path, hex.EncodeToString(sum[:]), err)
+			failed = append(failed, path)
+			return
+		}
+		v.logger.Info("signature verified", "path", path)
+	}
+	if binary {
+		check(ViolationTypeBinary, v.cfg.BinaryPath, nil)
+	}
+	if config == nil {
+		config = []byte{}
+	}
+	check(ViolationTypeConfig, v.cfg.ConfigPath, config)
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %s", ErrSignatureInvalid, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// reportSignature logs and reports a signature violation of path, whose
+// verified content has the SHA-256 checksum actual.
+func (v *Verifier) reportSignature(ctx context.Context, nodeID, violationType, path, actual string, cause error) {
+	v.logger.Error("signature verification failed",
+		"path", path,
+		"type", violationType,
+		"error", cause,
+	)
+
+	report := api.IntegrityViolationReport{
+		Type:           violationType,
+		Path:           path,
+		ActualChecksum: actual,
+		Detail:         cause.Error(),
+		Timestamp:      time.Now().UTC(),
+	}
+	if err := v.reporter.ReportViolation(ctx, nodeID, report); err != nil {
+		v.logger.Warn("failed to report signature violation", "error", err)
+	}
+}
+
+// verifyDataSignature verifies data, the content of path, against
+// path+SignatureSuffix.
+func verifyDataSignature(path string, data []byte, sv SignatureVerifier) error {
+	sigData, err := os.ReadFile(path + SignatureSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return errors.New("signature file missing")
+	}
+	if err != nil {
+		return fmt.Errorf("read signature: %w", err)
+	}
+	sig := strings.TrimSpace(string(sigData))
+	if len(sigData) == ed25519.SignatureSize {
+		sig = base64.StdEncoding.EncodeToString(sigData)
+	}
+
+	if err := sv.VerifySignature(data, sig); err != nil {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
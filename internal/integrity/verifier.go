@@ -13,6 +13,7 @@ import (
 const (
 	ViolationTypeBinary = "binary"
 	ViolationTypeHook   = "hook"
+	ViolationTypeConfig = "config"
 )
 
 // ViolationReporter abstracts control plane violation reporting for testability.
@@ -29,6 +30,8 @@ type Verifier struct {
 
 	mu             sync.Mutex
 	binaryChecksum string
+	approvedHooks  map[string]string // hook name → approved checksum
+	quarantined    map[string]string // hook path → reported violation
 }
 
 // NewVerifier creates a Verifier with the given configuration, store, reporter, and logger.
@@ -98,9 +101,15 @@ func (v *Verifier) VerifyBinary(ctx context.Context, nodeID string) error {
 }
 
 // VerifyHook verifies a hook script against the expected checksum from the control plane.
-// Returns true if the hook is safe to execute, false if there is a mismatch.
+// Returns true if the hook is safe to execute, false if there is a mismatch or
+// the hook is quarantined.
 // An error is returned if the expected checksum is empty (hooks require a checksum).
 func (v *Verifier) VerifyHook(ctx context.Context, nodeID, hookPath, expectedChecksum string) (bool, error) {
+	if v.HookQuarantined(hookPath) {
+		v.logger.Error("hook quarantined, refusing execution", "path", hookPath)
+		return false, nil
+	}
+
 	result, err := VerifyFile(hookPath, expectedChecksum, true)
 	if err != nil {
 		return false, err
@@ -132,15 +141,30 @@ func (v *Verifier) VerifyHook(ctx context.Context, nodeID, hookPath, expectedChe
 	return false, nil
 }
 
-// Run performs startup binary verification and then periodically re-verifies
-// at the configured interval. When the config is disabled, Run returns immediately.
-// Run blocks until the context is cancelled.
+// Run periodically re-verifies the binary and the hooks at the configured
+// interval. When HooksDir is set, the hooks directory is also watched so that
+// hook changes are checked and reported as they happen. When the config is
+// disabled, Run returns immediately. Run blocks until the context is cancelled.
 func (v *Verifier) Run(ctx context.Context, nodeID string) error {
 	if !v.cfg.Enabled {
 		v.logger.Info("integrity verification disabled")
 		return nil
 	}
 
+	if v.cfg.HooksDir != "" {
+		watchDone := make(chan struct{})
+		go func() {
+			defer close(watchDone)
+			if err := v.WatchHooks(ctx, nodeID); err != nil {
+				v.logger.Warn("hooks dir watch unavailable, relying on periodic verification",
+					"path", v.cfg.HooksDir,
+					"error", err,
+				)
+			}
+		}()
+		defer func() { <-watchDone }()
+	}
+
 	ticker := time.NewTicker(v.cfg.VerifyInterval)
 	defer ticker.Stop()
 
@@ -152,6 +176,7 @@ func (v *Verifier) Run(ctx context.Context, nodeID string) error {
 			if err := v.VerifyBinary(ctx, nodeID); err != nil {
 				v.logger.Error("periodic binary verification failed", "error", err)
 			}
+			v.VerifyHooks(ctx, nodeID)
 		}
 	}
 }
@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// ComponentKey is the attribute that names the component of a log record.
+const ComponentKey = "component"
+
+// Handler is a slog.Handler that drops records below the level of their
+// component and passes the others to the wrapped handler. The component is
+// taken from a top-level ComponentKey attribute, added with Logger.With or
+// to the record itself; records without one use the default level.
+type Handler struct {
+	next      slog.Handler
+	levels    *Levels
+	component string
+	grouped   bool
+}
+
+// NewHandler returns a Handler that filters by levels and writes to next.
+// next must accept every level that levels may be set to, e.g. by being
+// created with slog.LevelDebug.
+func NewHandler(next slog.Handler, levels *Levels) *Handler {
+	return &Handler{next: next, levels: levels}
+}
+
+// Enabled reports whether some component logs at level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.levels.Min() && h.next.Enabled(ctx, level)
+}
+
+// Handle passes r to the wrapped handler if r is at or above the level of
+// its component.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	component := h.component
+	if component == "" && !h.grouped {
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == ComponentKey {
+				component = a.Value.String()
+				return false
+			}
+			return true
+		})
+	}
+	if r.Level < h.levels.Level(component) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a Handler whose records carry attrs, and whose
+// component is the ComponentKey attribute among them, if any.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.next = h.next.WithAttrs(attrs)
+	if !h.grouped {
+		for _, a := range attrs {
+			if a.Key == ComponentKey {
+				h2.component = a.Value.String()
+			}
+		}
+	}
+	return &h2
+}
+
+// WithGroup returns a Handler that nests further attributes in the group
+// name. ComponentKey attributes inside a group do not name the component.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.next = h.next.WithGroup(name)
+	h2.grouped = true
+	return &h2
+}
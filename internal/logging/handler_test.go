@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func newTestLogger(levels *Levels) (*slog.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	next := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return slog.New(NewHandler(next, levels)), &buf
+}
+
+func TestHandler_ComponentLevels(t *testing.T) {
+	levels := NewLevels(slog.LevelInfo)
+	levels.Set("bridge", slog.LevelDebug)
+	levels.Set("api", slog.LevelError)
+	logger, buf := newTestLogger(levels)
+
+	logger.Debug("bridge debug", "component", "bridge")
+	logger.With("component", "bridge").Debug("bridge with debug")
+	logger.Debug("reconcile debug", "component", "reconcile")
+	logger.Debug("no component debug")
+	logger.Info("no component info")
+	logger.With("component", "api").Warn("api warn")
+	logger.With("component", "api").Error("api error")
+	logger.WithGroup("g").Debug("grouped debug", "component", "bridge")
+
+	out := buf.String()
+	for _, want := range []string{"bridge debug", "bridge with debug", "no component info", "api error"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"reconcile debug", "no component debug", "api warn", "grouped debug"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("output contains %q:\n%s", unwanted, out)
+		}
+	}
+}
+
+func TestHandler_LevelsChangeAtRuntime(t *testing.T) {
+	levels := NewLevels(slog.LevelInfo)
+	logger, buf := newTestLogger(levels)
+	nodeapi := logger.With("component", "nodeapi")
+
+	nodeapi.Debug("before")
+	levels.Set("nodeapi", slog.LevelDebug)
+	nodeapi.Debug("during")
+	levels.Reset("nodeapi")
+	nodeapi.Debug("after")
+
+	out := buf.String()
+	if strings.Contains(out, "before") || !strings.Contains(out, "during") || strings.Contains(out, "after") {
+		t.Errorf("output:\n%s", out)
+	}
+}
+
+func TestLevels_MinAndSnapshot(t *testing.T) {
+	levels := NewLevels(slog.LevelWarn)
+	if levels.Min() != slog.LevelWarn {
+		t.Errorf("Min = %v, want WARN", levels.Min())
+	}
+	levels.Set("actions", slog.LevelDebug)
+	if levels.Min() != slog.LevelDebug {
+		t.Errorf("Min = %v, want DEBUG", levels.Min())
+	}
+
+	s := levels.Snapshot()
+	if s.Default != "warn" || len(s.Components) != 1 || s.Components["actions"] != "debug" {
+		t.Errorf("Snapshot = %+v", s)
+	}
+
+	levels.Replace(slog.LevelInfo, nil)
+	if levels.Min() != slog.LevelInfo || levels.Level("actions") != slog.LevelInfo {
+		t.Errorf("after Replace: Min = %v, actions = %v", levels.Min(), levels.Level("actions"))
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for s, want := range map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"INFO":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	} {
+		got, err := ParseLevel(s)
+		if err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", s, got, err, want)
+		}
+		if back, _ := ParseLevel(LevelName(got)); back != got {
+			t.Errorf("LevelName(%v) = %q does not parse back", got, LevelName(got))
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("ParseLevel(verbose): expected error")
+	}
+}
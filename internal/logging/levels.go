@@ -0,0 +1,136 @@
+// Package logging lets the log level of the agent change at runtime, as a
+// whole and per component, and keeps an optional in-memory capture of
+// recent log lines. A component is the value of the "component" attribute
+// that subsystems add to their log records, e.g. "bridge" or "reconcile".
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"strings"
+	"sync"
+)
+
+// ParseLevel parses one of "debug", "info", "warn", or "error", in any
+// case.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("logging: invalid level %q (must be debug, info, warn, or error)", s)
+	}
+}
+
+// LevelName returns the name of l as accepted by ParseLevel.
+func LevelName(l slog.Level) string {
+	return strings.ToLower(l.String())
+}
+
+// LevelSnapshot is the default level and the component levels at one point
+// in time.
+type LevelSnapshot struct {
+	Default    string            `json:"default"`
+	Components map[string]string `json:"components"`
+}
+
+// Levels holds the default log level and the levels of components that
+// differ from it. The zero value logs at info for every component. Levels
+// is safe for concurrent use.
+type Levels struct {
+	mu         sync.RWMutex
+	def        slog.Level
+	components map[string]slog.Level
+
+	// min is the lowest of all levels, so that Handler.Enabled can drop
+	// records below it without looking up a component.
+	min slog.LevelVar
+}
+
+// NewLevels returns Levels with the default level def and no component
+// levels.
+func NewLevels(def slog.Level) *Levels {
+	l := &Levels{}
+	l.Replace(def, nil)
+	return l
+}
+
+// Level returns the level of component, or the default level if the
+// component has none.
+func (l *Levels) Level(component string) slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if lvl, ok := l.components[component]; ok {
+		return lvl
+	}
+	return l.def
+}
+
+// Min returns the lowest of the default and the component levels.
+func (l *Levels) Min() slog.Level {
+	return l.min.Level()
+}
+
+// Set sets the level of component; an empty component sets the default
+// level.
+func (l *Levels) Set(component string, level slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if component == "" {
+		l.def = level
+	} else {
+		if l.components == nil {
+			l.components = make(map[string]slog.Level)
+		}
+		l.components[component] = level
+	}
+	l.updateMin()
+}
+
+// Reset removes the level of component, which then logs at the default
+// level.
+func (l *Levels) Reset(component string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.components, component)
+	l.updateMin()
+}
+
+// Replace sets the default level and replaces all component levels.
+func (l *Levels) Replace(def slog.Level, components map[string]slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.def = def
+	l.components = maps.Clone(components)
+	l.updateMin()
+}
+
+// Snapshot returns the current levels.
+func (l *Levels) Snapshot() LevelSnapshot {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	s := LevelSnapshot{
+		Default:    LevelName(l.def),
+		Components: make(map[string]string, len(l.components)),
+	}
+	for c, lvl := range l.components {
+		s.Components[c] = LevelName(lvl)
+	}
+	return s
+}
+
+// updateMin recomputes min. The caller holds mu.
+func (l *Levels) updateMin() {
+	low := l.def
+	for _, lvl := range l.components {
+		low = min(low, lvl)
+	}
+	l.min.Set(low)
+}
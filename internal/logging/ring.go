@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Ring is an io.Writer that keeps the most recent log lines in memory, so
+// that they can be read without access to the journal. A Ring of size 0
+// keeps nothing. Ring is safe for concurrent use.
+type Ring struct {
+	mu      sync.Mutex
+	size    int
+	lines   []string
+	partial []byte
+}
+
+// NewRing returns a Ring that keeps up to size lines.
+func NewRing(size int) *Ring {
+	return &Ring{size: max(size, 0)}
+}
+
+// Write stores the complete lines of p. A line split across writes is
+// stored once it is complete.
+func (r *Ring) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.size == 0 {
+		return len(p), nil
+	}
+	data := p
+	if len(r.partial) > 0 {
+		data = append(r.partial, p...)
+		r.partial = nil
+	}
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		r.lines = append(r.lines, string(data[:i]))
+		data = data[i+1:]
+	}
+	if len(data) > 0 {
+		r.partial = append([]byte(nil), data...)
+	}
+	if over := len(r.lines) - r.size; over > 0 {
+		r.lines = r.lines[over:]
+	}
+	return len(p), nil
+}
+
+// Size returns the number of lines the Ring keeps.
+func (r *Ring) Size() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.size
+}
+
+// Resize changes the number of lines the Ring keeps, dropping the oldest
+// lines beyond size. Resizing to 0 drops all lines and stops the capture.
+func (r *Ring) Resize(size int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.size = max(size, 0)
+	if over := len(r.lines) - r.size; over > 0 {
+		r.lines = r.lines[over:]
+	}
+	if r.size == 0 {
+		r.lines, r.partial = nil, nil
+	}
+}
+
+// Lines returns the kept lines, oldest first. A non-empty component keeps
+// only the lines of that component; a positive limit keeps only the limit
+// most recent lines.
+func (r *Ring) Lines(component string, limit int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []string
+	for i := len(r.lines) - 1; i >= 0 && (limit <= 0 || len(out) < limit); i-- {
+		if component == "" || lineComponent(r.lines[i]) == component {
+			out = append(out, r.lines[i])
+		}
+	}
+	slices.Reverse(out)
+	return out
+}
+
+// lineComponent returns the value of the first component attribute of a
+// line written by slog.TextHandler, or "" if it has none.
+func lineComponent(line string) string {
+	const key = " " + ComponentKey + "="
+	i := strings.Index(line, key)
+	if i < 0 {
+		return ""
+	}
+	v := line[i+len(key):]
+	if j := strings.IndexByte(v, ' '); j >= 0 {
+		v = v[:j]
+	}
+	return strings.Trim(v, `"`)
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestRing_KeepsNewestLines(t *testing.T) {
+	r := NewRing(3)
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(r, "line %d\n", i)
+	}
+	want := []string{"line 3", "line 4", "line 5"}
+	if got := r.Lines("", 0); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines = %q, want %q", got, want)
+	}
+	if got := r.Lines("", 2); !reflect.DeepEqual(got, want[1:]) {
+		t.Errorf("Lines(limit 2) = %q, want %q", got, want[1:])
+	}
+}
+
+func TestRing_PartialWrites(t *testing.T) {
+	r := NewRing(10)
+	r.Write([]byte("first "))
+	r.Write([]byte("half\nsecond\nthi"))
+	if got, want := r.Lines("", 0), []string{"first half", "second"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines = %q, want %q", got, want)
+	}
+}
+
+func TestRing_ComponentFilter(t *testing.T) {
+	r := NewRing(10)
+	logger := slog.New(slog.NewTextHandler(r, nil))
+	logger.Info("one", "component", "bridge")
+	logger.With("component", "api").Info("two")
+	logger.Info("three", "component", "bridge", "peer", "p1")
+	logger.Info("four")
+
+	got := r.Lines("bridge", 0)
+	if len(got) != 2 {
+		t.Fatalf("Lines(bridge) = %q, want 2 lines", got)
+	}
+	if got := r.Lines("api", 0); len(got) != 1 {
+		t.Errorf("Lines(api) = %q, want 1 line", got)
+	}
+}
+
+func TestRing_Resize(t *testing.T) {
+	r := NewRing(0)
+	fmt.Fprintln(r, "dropped")
+	if got := r.Lines("", 0); len(got) != 0 {
+		t.Errorf("size 0 kept %q", got)
+	}
+
+	r.Resize(2)
+	fmt.Fprintln(r, "a")
+	fmt.Fprintln(r, "b")
+	fmt.Fprintln(r, "c")
+	r.Resize(1)
+	if got, want := r.Lines("", 0), []string{"c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines = %q, want %q", got, want)
+	}
+	r.Resize(0)
+	if got := r.Lines("", 0); len(got) != 0 {
+		t.Errorf("after Resize(0) kept %q", got)
+	}
+}
@@ -0,0 +1,83 @@
+// Package meshdiag probes mesh peers over the tunnel and reports which peers
+// this node can reach, so that connectivity problems in the mesh are visible
+// without logging into each node.
+package meshdiag
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultInterval is the default time between probe rounds.
+const DefaultInterval = 30 * time.Second
+
+// DefaultTimeout is the default time to wait for a peer's echo reply.
+const DefaultTimeout = 2 * time.Second
+
+// DefaultPort is the default UDP port of the mesh echo responder.
+const DefaultPort = 51830
+
+// ReportKey is the node API report key the reachability matrix is written to.
+const ReportKey = "mesh.peers"
+
+// Config holds the configuration for mesh diagnostics.
+type Config struct {
+	// Enabled controls whether peers are probed and the echo responder runs.
+	// Default: true (set by ApplyDefaults).
+	Enabled bool
+
+	// Interval is the time between probe rounds. Must be at least 1s.
+	// Default: 30s
+	Interval time.Duration
+
+	// Timeout is the time to wait for a peer's echo reply. Must be positive
+	// and less than Interval.
+	// Default: 2s
+	Timeout time.Duration
+
+	// Port is the UDP port the echo responder listens on at the mesh IP and
+	// peers are probed on. All nodes of a mesh must use the same port.
+	// Default: 51830
+	Port int
+}
+
+// ApplyDefaults sets default values for zero-valued fields.
+// On a zero-valued Config, Enabled defaults to true.
+// To disable diagnostics, set Enabled=false before or after calling ApplyDefaults.
+func (c *Config) ApplyDefaults() {
+	// Enabled defaults to true for zero-valued Config. If any field is
+	// non-zero, the caller constructed the config explicitly and we respect
+	// Enabled as-is.
+	if c.Interval == 0 && c.Timeout == 0 && c.Port == 0 {
+		c.Enabled = true
+	}
+	if c.Interval == 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.Port == 0 {
+		c.Port = DefaultPort
+	}
+}
+
+// Validate checks that configuration values are within acceptable ranges.
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Interval < time.Second {
+		return errors.New("meshdiag: config: Interval must be at least 1s")
+	}
+	if c.Timeout <= 0 {
+		return errors.New("meshdiag: config: Timeout must be positive")
+	}
+	if c.Timeout >= c.Interval {
+		return errors.New("meshdiag: config: Timeout must be less than Interval")
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return errors.New("meshdiag: config: Port must be between 1 and 65535")
+	}
+	return nil
+}
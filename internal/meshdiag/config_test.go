@@ -0,0 +1,62 @@
+package meshdiag
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfig_Defaults(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+
+	if !cfg.Enabled {
+		t.Error("Enabled = false, want true")
+	}
+	if cfg.Interval != DefaultInterval {
+		t.Errorf("Interval = %v, want %v", cfg.Interval, DefaultInterval)
+	}
+	if cfg.Timeout != DefaultTimeout {
+		t.Errorf("Timeout = %v, want %v", cfg.Timeout, DefaultTimeout)
+	}
+	if cfg.Port != DefaultPort {
+		t.Errorf("Port = %d, want %d", cfg.Port, DefaultPort)
+	}
+}
+
+func TestConfig_DefaultsPreserveExplicitDisabled(t *testing.T) {
+	cfg := Config{Enabled: false, Interval: time.Minute}
+	cfg.ApplyDefaults()
+
+	if cfg.Enabled {
+		t.Error("Enabled = true, want false when explicitly configured with other non-zero fields")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"valid", Config{Enabled: true, Interval: 30 * time.Second, Timeout: 2 * time.Second, Port: 51830}, ""},
+		{"interval too short", Config{Enabled: true, Interval: 500 * time.Millisecond, Timeout: 100 * time.Millisecond, Port: 51830}, "meshdiag: config: Interval must be at least 1s"},
+		{"timeout not positive", Config{Enabled: true, Interval: time.Second, Timeout: -time.Second, Port: 51830}, "meshdiag: config: Timeout must be positive"},
+		{"timeout not below interval", Config{Enabled: true, Interval: time.Second, Timeout: time.Second, Port: 51830}, "meshdiag: config: Timeout must be less than Interval"},
+		{"port out of range", Config{Enabled: true, Interval: time.Second, Timeout: 100 * time.Millisecond, Port: 70000}, "meshdiag: config: Port must be between 1 and 65535"},
+		{"disabled skips checks", Config{Enabled: false, Port: -1}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Validate() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
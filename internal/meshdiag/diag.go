@@ -0,0 +1,265 @@
+package meshdiag
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+// maxConcurrentProbes bounds the number of peers probed in parallel.
+const maxConcurrentProbes = 16
+
+// Prober measures the round-trip time to a peer's mesh IP.
+type Prober interface {
+	Probe(ctx context.Context, meshIP string) (time.Duration, error)
+}
+
+// ReportWriter stores a node API report entry and syncs it to the control
+// plane. *nodeapi.Server satisfies this interface.
+type ReportWriter interface {
+	WriteReport(key string, payload json.RawMessage) error
+}
+
+// PeerStatus is the reachability of one peer from this node.
+type PeerStatus struct {
+	PeerID    string `json:"peer_id"`
+	MeshIP    string `json:"mesh_ip"`
+	Reachable bool   `json:"reachable"`
+	// RTTNano is the round-trip time of the last probe, or -1 if it failed.
+	RTTNano int64 `json:"rtt_nano"`
+	// LastSuccess is the time of the last answered probe, or nil if the
+	// peer has not answered since it was added.
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	LastProbe           time.Time  `json:"last_probe"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Error               string     `json:"error,omitempty"`
+}
+
+// Matrix is this node's row of the mesh reachability matrix. The control
+// plane assembles the full matrix from the rows reported by all nodes.
+type Matrix struct {
+	NodeID    string       `json:"node_id"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	Peers     []PeerStatus `json:"peers"`
+}
+
+// Diagnostics probes all mesh peers periodically and keeps their
+// reachability.
+type Diagnostics struct {
+	cfg    Config
+	prober Prober
+	nodeID string
+	report ReportWriter
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	peers     map[string]string // peer ID -> mesh IP
+	status    map[string]PeerStatus
+	updatedAt time.Time
+}
+
+// NewDiagnostics creates a new Diagnostics. Config defaults are applied
+// automatically.
+func NewDiagnostics(cfg Config, prober Prober, nodeID string, logger *slog.Logger) *Diagnostics {
+	cfg.ApplyDefaults()
+	return &Diagnostics{
+		cfg:    cfg,
+		prober: prober,
+		nodeID: nodeID,
+		logger: logger.With("component", "meshdiag"),
+		peers:  make(map[string]string),
+		status: make(map[string]PeerStatus),
+	}
+}
+
+// SetReportWriter sets where the matrix is written after each probe round,
+// under ReportKey. It must be called before Run.
+func (d *Diagnostics) SetReportWriter(w ReportWriter) {
+	d.report = w
+}
+
+// SetPeers replaces the set of peers to probe. The status of a peer whose
+// mesh IP changed is reset; peers no longer present are dropped.
+func (d *Diagnostics) SetPeers(peers []api.Peer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	next := make(map[string]string, len(peers))
+	for _, p := range peers {
+		if p.MeshIP == "" {
+			continue
+		}
+		next[p.ID] = p.MeshIP
+	}
+	for id, st := range d.status {
+		if ip, ok := next[id]; !ok || ip != st.MeshIP {
+			delete(d.status, id)
+		}
+	}
+	d.peers = next
+}
+
+// ReconcileHandler returns a handler that updates the probed peers when the
+// desired peer set drifts.
+func (d *Diagnostics) ReconcileHandler() reconcile.ReconcileHandler {
+	return func(_ context.Context, desired *api.StateResponse, diff reconcile.StateDiff) error {
+		if len(diff.PeersToAdd) == 0 && len(diff.PeersToRemove) == 0 && len(diff.PeersToUpdate) == 0 {
+			return nil
+		}
+		d.SetPeers(desired.Peers)
+		return nil
+	}
+}
+
+// Run probes all peers immediately and then every Interval until ctx is
+// cancelled. It returns nil immediately when diagnostics are disabled.
+// Run always returns nil.
+func (d *Diagnostics) Run(ctx context.Context) error {
+	if !d.cfg.Enabled {
+		d.logger.Info("mesh diagnostics disabled")
+		return nil
+	}
+	d.logger.Info("mesh diagnostics started", "interval", d.cfg.Interval.String())
+
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		d.ProbeAll(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProbeAll probes every peer once, updates their status, and writes the
+// matrix to the report writer if one is set.
+func (d *Diagnostics) ProbeAll(ctx context.Context) {
+	d.mu.Lock()
+	peers := make(map[string]string, len(d.peers))
+	for id, ip := range d.peers {
+		peers[id] = ip
+	}
+	d.mu.Unlock()
+
+	type result struct {
+		id, ip string
+		rtt    time.Duration
+		err    error
+		at     time.Time
+	}
+	results := make(chan result, len(peers))
+	sem := make(chan struct{}, maxConcurrentProbes)
+	var wg sync.WaitGroup
+	for id, ip := range peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			rtt, err := d.prober.Probe(ctx, ip)
+			results <- result{id: id, ip: ip, rtt: rtt, err: err, at: time.Now()}
+		}()
+	}
+	wg.Wait()
+	close(results)
+	if ctx.Err() != nil {
+		return
+	}
+
+	d.mu.Lock()
+	for r := range results {
+		// Skip peers removed or changed while the probe was in flight.
+		if d.peers[r.id] != r.ip {
+			continue
+		}
+		st := d.status[r.id]
+		st.PeerID, st.MeshIP, st.LastProbe = r.id, r.ip, r.at
+		if r.err != nil {
+			if st.Reachable || st.ConsecutiveFailures == 0 {
+				d.logger.Warn("mesh peer unreachable", "peer_id", r.id, "mesh_ip", r.ip, "error", r.err)
+			}
+			st.Reachable = false
+			st.RTTNano = -1
+			st.ConsecutiveFailures++
+			st.Error = r.err.Error()
+		} else {
+			if !st.Reachable && st.ConsecutiveFailures > 0 {
+				d.logger.Info("mesh peer reachable again", "peer_id", r.id, "mesh_ip", r.ip)
+			}
+			at := r.at
+			st.Reachable = true
+			st.RTTNano = r.rtt.Nanoseconds()
+			st.LastSuccess = &at
+			st.ConsecutiveFailures = 0
+			st.Error = ""
+		}
+		d.status[r.id] = st
+	}
+	d.updatedAt = time.Now()
+	d.mu.Unlock()
+
+	if d.report == nil {
+		return
+	}
+	payload, err := json.Marshal(d.Matrix())
+	if err != nil {
+		d.logger.Error("mesh matrix marshal failed", "error", err)
+		return
+	}
+	if err := d.report.WriteReport(ReportKey, payload); err != nil {
+		d.logger.Warn("mesh matrix report failed", "error", err)
+	}
+}
+
+// Matrix returns the current reachability of all peers, sorted by peer ID.
+// Peers not yet probed are omitted.
+func (d *Diagnostics) Matrix() Matrix {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m := Matrix{
+		NodeID:    d.nodeID,
+		UpdatedAt: d.updatedAt,
+		Peers:     make([]PeerStatus, 0, len(d.status)),
+	}
+	for _, st := range d.status {
+		if st.LastSuccess != nil {
+			at := *st.LastSuccess
+			st.LastSuccess = &at
+		}
+		m.Peers = append(m.Peers, st)
+	}
+	slices.SortFunc(m.Peers, func(a, b PeerStatus) int {
+		return cmp.Compare(a.PeerID, b.PeerID)
+	})
+	return m
+}
+
+// MeshHealth returns a summary of peer reachability for the heartbeat, or
+// nil before the first probe round.
+func (d *Diagnostics) MeshHealth() *api.MeshHealthInfo {
+	m := d.Matrix()
+	if m.UpdatedAt.IsZero() {
+		return nil
+	}
+	info := &api.MeshHealthInfo{
+		PeersTotal: len(m.Peers),
+		UpdatedAt:  m.UpdatedAt,
+	}
+	for _, st := range m.Peers {
+		if st.Reachable {
+			info.PeersReachable++
+		} else {
+			info.Unreachable = append(info.Unreachable, st.PeerID)
+		}
+	}
+	return info
+}
@@ -0,0 +1,204 @@
+package meshdiag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+// fakeProber answers probes from a fixed table of mesh IP to error.
+type fakeProber struct {
+	mu   sync.Mutex
+	down map[string]bool
+}
+
+func (p *fakeProber) Probe(_ context.Context, meshIP string) (time.Duration, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.down[meshIP] {
+		return 0, errors.New("no reply")
+	}
+	return 3 * time.Millisecond, nil
+}
+
+func (p *fakeProber) setDown(meshIP string, down bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.down[meshIP] = down
+}
+
+type fakeReportWriter struct {
+	mu      sync.Mutex
+	key     string
+	payload json.RawMessage
+	writes  int
+}
+
+func (w *fakeReportWriter) WriteReport(key string, payload json.RawMessage) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.key, w.payload = key, payload
+	w.writes++
+	return nil
+}
+
+func testPeers() []api.Peer {
+	return []api.Peer{
+		{ID: "peer-b", MeshIP: "10.0.0.3"},
+		{ID: "peer-a", MeshIP: "10.0.0.2"},
+	}
+}
+
+func TestDiagnostics_ProbeAll(t *testing.T) {
+	prober := &fakeProber{down: map[string]bool{"10.0.0.3": true}}
+	writer := &fakeReportWriter{}
+	d := NewDiagnostics(Config{}, prober, "node-1", discardLogger())
+	d.SetReportWriter(writer)
+	d.SetPeers(testPeers())
+
+	if h := d.MeshHealth(); h != nil {
+		t.Errorf("MeshHealth before probing = %+v, want nil", h)
+	}
+
+	d.ProbeAll(context.Background())
+
+	m := d.Matrix()
+	if m.NodeID != "node-1" || m.UpdatedAt.IsZero() {
+		t.Errorf("matrix = %+v", m)
+	}
+	if len(m.Peers) != 2 || m.Peers[0].PeerID != "peer-a" || m.Peers[1].PeerID != "peer-b" {
+		t.Fatalf("peers = %+v, want peer-a and peer-b", m.Peers)
+	}
+	a, b := m.Peers[0], m.Peers[1]
+	if !a.Reachable || a.RTTNano != int64(3*time.Millisecond) || a.LastSuccess == nil {
+		t.Errorf("peer-a = %+v, want reachable", a)
+	}
+	if b.Reachable || b.RTTNano != -1 || b.LastSuccess != nil || b.ConsecutiveFailures != 1 || b.Error != "no reply" {
+		t.Errorf("peer-b = %+v, want unreachable", b)
+	}
+
+	h := d.MeshHealth()
+	if h == nil || h.PeersTotal != 2 || h.PeersReachable != 1 || len(h.Unreachable) != 1 || h.Unreachable[0] != "peer-b" {
+		t.Errorf("MeshHealth = %+v", h)
+	}
+
+	if writer.key != ReportKey {
+		t.Errorf("report key = %q, want %q", writer.key, ReportKey)
+	}
+	var reported Matrix
+	if err := json.Unmarshal(writer.payload, &reported); err != nil {
+		t.Fatalf("unmarshal report: %v", err)
+	}
+	if len(reported.Peers) != 2 {
+		t.Errorf("reported peers = %d, want 2", len(reported.Peers))
+	}
+}
+
+func TestDiagnostics_LastSuccessKeptWhileDown(t *testing.T) {
+	prober := &fakeProber{down: map[string]bool{}}
+	d := NewDiagnostics(Config{}, prober, "node-1", discardLogger())
+	d.SetPeers(testPeers())
+
+	d.ProbeAll(context.Background())
+	first := *d.Matrix().Peers[0].LastSuccess
+
+	prober.setDown("10.0.0.2", true)
+	d.ProbeAll(context.Background())
+	d.ProbeAll(context.Background())
+
+	a := d.Matrix().Peers[0]
+	if a.Reachable || a.ConsecutiveFailures != 2 {
+		t.Errorf("peer-a = %+v, want 2 consecutive failures", a)
+	}
+	if a.LastSuccess == nil || !a.LastSuccess.Equal(first) {
+		t.Errorf("LastSuccess = %v, want %v", a.LastSuccess, first)
+	}
+
+	prober.setDown("10.0.0.2", false)
+	d.ProbeAll(context.Background())
+	a = d.Matrix().Peers[0]
+	if !a.Reachable || a.ConsecutiveFailures != 0 || a.Error != "" {
+		t.Errorf("peer-a = %+v, want reachable again", a)
+	}
+}
+
+func TestDiagnostics_SetPeersDropsRemovedAndChanged(t *testing.T) {
+	d := NewDiagnostics(Config{}, &fakeProber{down: map[string]bool{}}, "node-1", discardLogger())
+	d.SetPeers(testPeers())
+	d.ProbeAll(context.Background())
+
+	d.SetPeers([]api.Peer{{ID: "peer-a", MeshIP: "10.0.0.9"}})
+	if peers := d.Matrix().Peers; len(peers) != 0 {
+		t.Errorf("peers = %+v, want none before the next probe", peers)
+	}
+
+	d.ProbeAll(context.Background())
+	peers := d.Matrix().Peers
+	if len(peers) != 1 || peers[0].MeshIP != "10.0.0.9" {
+		t.Errorf("peers = %+v, want peer-a at 10.0.0.9", peers)
+	}
+}
+
+func TestDiagnostics_ReconcileHandler(t *testing.T) {
+	d := NewDiagnostics(Config{}, &fakeProber{down: map[string]bool{}}, "node-1", discardLogger())
+	h := d.ReconcileHandler()
+
+	desired := &api.StateResponse{Peers: testPeers()}
+	if err := h(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+		t.Fatal(err)
+	}
+	d.ProbeAll(context.Background())
+	if n := len(d.Matrix().Peers); n != 0 {
+		t.Errorf("peers = %d without peer drift, want 0", n)
+	}
+
+	if err := h(context.Background(), desired, reconcile.StateDiff{PeersToAdd: desired.Peers}); err != nil {
+		t.Fatal(err)
+	}
+	d.ProbeAll(context.Background())
+	if n := len(d.Matrix().Peers); n != 2 {
+		t.Errorf("peers = %d after peer drift, want 2", n)
+	}
+}
+
+func TestDiagnostics_RunDisabled(t *testing.T) {
+	writer := &fakeReportWriter{}
+	d := NewDiagnostics(Config{Enabled: false, Interval: time.Second}, &fakeProber{}, "node-1", discardLogger())
+	d.SetReportWriter(writer)
+
+	if err := d.Run(context.Background()); err != nil {
+		t.Fatalf("Run = %v, want nil", err)
+	}
+	if writer.writes != 0 {
+		t.Errorf("writes = %d, want 0", writer.writes)
+	}
+}
+
+func TestDiagnostics_RunProbesImmediately(t *testing.T) {
+	writer := &fakeReportWriter{}
+	d := NewDiagnostics(Config{}, &fakeProber{down: map[string]bool{}}, "node-1", discardLogger())
+	d.SetReportWriter(writer)
+	d.SetPeers(testPeers())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- d.Run(ctx) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for d.MeshHealth() == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run = %v, want nil", err)
+	}
+	if h := d.MeshHealth(); h == nil || h.PeersReachable != 2 {
+		t.Errorf("MeshHealth = %+v, want 2 reachable", h)
+	}
+}
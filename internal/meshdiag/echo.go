@@ -0,0 +1,132 @@
+package meshdiag
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"time"
+)
+
+// echoMagic prefixes every echo request and reply. The responder ignores
+// datagrams without it.
+var echoMagic = []byte("PXME")
+
+// echoSize is the size of an echo datagram: echoMagic and an 8-byte nonce.
+const echoSize = 12
+
+// Responder answers echo probes from peers. It listens on the node's mesh IP,
+// so it is reachable only over the tunnel.
+type Responder struct {
+	addr   string
+	logger *slog.Logger
+}
+
+// NewResponder creates a Responder for meshIP and port.
+func NewResponder(meshIP string, port int, logger *slog.Logger) *Responder {
+	return &Responder{
+		addr:   net.JoinHostPort(meshIP, strconv.Itoa(port)),
+		logger: logger.With("component", "meshdiag"),
+	}
+}
+
+// Run answers echo probes until ctx is cancelled. While the mesh IP is not
+// yet configured the listen fails; Run retries every retry interval.
+// It always returns nil.
+func (r *Responder) Run(ctx context.Context, retry time.Duration) error {
+	for {
+		conn, err := net.ListenPacket("udp", r.addr)
+		if err == nil {
+			r.logger.Info("mesh echo responder started", "addr", r.addr)
+			r.serve(ctx, conn)
+			return nil
+		}
+		r.logger.Warn("mesh echo responder listen failed, retrying", "addr", r.addr, "error", err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(retry):
+		}
+	}
+}
+
+func (r *Responder) serve(ctx context.Context, conn net.PacketConn) {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	buf := make([]byte, 64)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() == nil {
+				r.logger.Warn("mesh echo responder stopped", "error", err)
+			}
+			return
+		}
+		if n != echoSize || !bytes.HasPrefix(buf, echoMagic) {
+			continue
+		}
+		if _, err := conn.WriteTo(buf[:n], addr); err != nil {
+			r.logger.Debug("mesh echo reply failed", "peer", addr.String(), "error", err)
+		}
+	}
+}
+
+// UDPProber probes peers by sending an echo request to their responder and
+// measuring the time until the reply arrives.
+type UDPProber struct {
+	port    int
+	timeout time.Duration
+}
+
+// NewUDPProber creates a UDPProber for the responder port. Each probe waits
+// at most timeout for the reply.
+func NewUDPProber(port int, timeout time.Duration) *UDPProber {
+	return &UDPProber{port: port, timeout: timeout}
+}
+
+// Probe sends one echo request to meshIP and returns the round-trip time.
+func (p *UDPProber) Probe(ctx context.Context, meshIP string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", net.JoinHostPort(meshIP, strconv.Itoa(p.port)))
+	if err != nil {
+		return 0, fmt.Errorf("meshdiag: probe %s: %w", meshIP, err)
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	req := make([]byte, echoSize)
+	copy(req, echoMagic)
+	if _, err := rand.Read(req[len(echoMagic):]); err != nil {
+		return 0, fmt.Errorf("meshdiag: probe %s: %w", meshIP, err)
+	}
+
+	start := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return 0, fmt.Errorf("meshdiag: probe %s: %w", meshIP, err)
+	}
+	buf := make([]byte, 64)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				return 0, fmt.Errorf("meshdiag: probe %s: no reply within %s", meshIP, p.timeout)
+			}
+			return 0, fmt.Errorf("meshdiag: probe %s: %w", meshIP, err)
+		}
+		// Ignore datagrams that do not echo this request.
+		if n == echoSize && bytes.Equal(buf[:n], req) {
+			return time.Since(start), nil
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package meshdiag
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// freeUDPPort returns a UDP port on 127.0.0.1 that is currently unused.
+func freeUDPPort(t *testing.T) int {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestUDPProber_Responder(t *testing.T) {
+	port := freeUDPPort(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = NewResponder("127.0.0.1", port, discardLogger()).Run(ctx, 10*time.Millisecond)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	prober := NewUDPProber(port, 200*time.Millisecond)
+	var err error
+	for i := 0; i < 20; i++ {
+		var rtt time.Duration
+		if rtt, err = prober.Probe(context.Background(), "127.0.0.1"); err == nil {
+			if rtt <= 0 {
+				t.Errorf("rtt = %v, want > 0", rtt)
+			}
+			return
+		}
+		// The responder may not be listening yet.
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Probe: %v", err)
+}
+
+func TestUDPProber_NoResponder(t *testing.T) {
+	prober := NewUDPProber(freeUDPPort(t), 50*time.Millisecond)
+	if _, err := prober.Probe(context.Background(), "127.0.0.1"); err == nil {
+		t.Fatal("Probe succeeded without a responder")
+	}
+}
+
+func TestResponder_IgnoresForeignDatagrams(t *testing.T) {
+	port := freeUDPPort(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = NewResponder("127.0.0.1", port, discardLogger()).Run(ctx, 10*time.Millisecond)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	conn, err := net.Dial("udp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(150 * time.Millisecond))
+	if _, err := conn.Write([]byte("hello, not an echo")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	if n, err := conn.Read(buf); err == nil {
+		t.Errorf("got reply %q to foreign datagram", buf[:n])
+	}
+}
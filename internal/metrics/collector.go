@@ -11,8 +11,36 @@ const (
 	GroupSystem  = "system"
 	GroupTunnel  = "tunnel"
 	GroupLatency = "latency"
+	// GroupReportSync holds the node API report sync stats.
+	GroupReportSync = "report_sync"
+	// GroupEventStream holds the control plane event stream state.
+	GroupEventStream = "event_stream"
+	// GroupAPICompression holds the control plane client compression stats.
+	GroupAPICompression = "api_compression"
+	// GroupSiteToSite holds the traffic of site-to-site tunnels.
+	GroupSiteToSite = "site_to_site"
+	// GroupPeerHealth holds the inactive and flapping mesh peers.
+	GroupPeerHealth = "peer_health"
+
+	// Node health groups produced by NodeCollector.
+	GroupNodeCPU        = "node_cpu"
+	GroupNodeMemory     = "node_memory"
+	GroupNodeFilesystem = "node_filesystem"
+	GroupNodeNetwork    = "node_network"
+	GroupNodeLoad       = "node_load"
+	GroupNodeWireGuard  = "node_wireguard"
 )
 
+// NodeGroups lists every group NodeCollector can produce, in collection order.
+var NodeGroups = []string{
+	GroupNodeCPU,
+	GroupNodeMemory,
+	GroupNodeFilesystem,
+	GroupNodeNetwork,
+	GroupNodeLoad,
+	GroupNodeWireGuard,
+}
+
 // Collector collects metrics from a specific subsystem.
 type Collector interface {
 	Collect(ctx context.Context) ([]api.MetricPoint, error)
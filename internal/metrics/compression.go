@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// CompressionStatsReader abstracts control plane compression stats
+// retrieval. *api.ControlPlane satisfies this interface.
+type CompressionStatsReader interface {
+	CompressionStats() api.CompressionStats
+}
+
+// CompressionCollector implements Collector for control plane compression
+// metrics.
+type CompressionCollector struct {
+	reader CompressionStatsReader
+}
+
+// NewCompressionCollector creates a new CompressionCollector.
+func NewCompressionCollector(reader CompressionStatsReader) *CompressionCollector {
+	return &CompressionCollector{reader: reader}
+}
+
+// Collect returns a single MetricPoint with the body sizes before and after
+// compression and the bytes saved.
+func (c *CompressionCollector) Collect(_ context.Context) ([]api.MetricPoint, error) {
+	data, err := json.Marshal(c.reader.CompressionStats())
+	if err != nil {
+		return nil, fmt.Errorf("metrics: api compression: %w", err)
+	}
+	return []api.MetricPoint{{
+		Timestamp: time.Now(),
+		Group:     GroupAPICompression,
+		Data:      data,
+	}}, nil
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+type staticCompressionReader struct {
+	stats api.CompressionStats
+}
+
+func (r staticCompressionReader) CompressionStats() api.CompressionStats { return r.stats }
+
+func TestCompressionCollector_Collect(t *testing.T) {
+	want := api.CompressionStats{
+		RequestEncoding:     "gzip",
+		RequestBytes:        4096,
+		RequestWireBytes:    512,
+		ResponseBytes:       8192,
+		ResponseWireBytes:   1024,
+		CompressedRequests:  2,
+		CompressedResponses: 3,
+		BytesSaved:          10752,
+	}
+	c := NewCompressionCollector(staticCompressionReader{stats: want})
+
+	points, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(points) != 1 {
+		t.Fatalf("len(points) = %d, want 1", len(points))
+	}
+	if points[0].Group != GroupAPICompression {
+		t.Errorf("Group = %q, want %q", points[0].Group, GroupAPICompression)
+	}
+	var got api.CompressionStats
+	if err := json.Unmarshal(points[0].Data, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+}
@@ -3,6 +3,8 @@ package metrics
 
 import (
 	"errors"
+	"fmt"
+	"slices"
 	"time"
 )
 
@@ -15,6 +17,10 @@ const DefaultReportInterval = 60 * time.Second
 // DefaultBatchSize is the default maximum number of metric points per report batch.
 const DefaultBatchSize = 100
 
+// DefaultMaxSeriesPerGroup is the default maximum number of per-device points
+// NodeCollector emits for a single group in one collection cycle.
+const DefaultMaxSeriesPerGroup = 32
+
 // Config holds the configuration for metrics collection and reporting.
 type Config struct {
 	// Enabled controls whether metrics collection is active.
@@ -32,6 +38,15 @@ type Config struct {
 	// BatchSize is the maximum number of metric points per report batch.
 	// Must be > 0. Default: 100.
 	BatchSize int
+
+	// NodeGroups selects the node health groups NodeCollector produces.
+	// Default: all groups in NodeGroups.
+	NodeGroups []string
+
+	// MaxSeriesPerGroup caps the per-device points (filesystems, network
+	// interfaces, WireGuard interfaces) NodeCollector emits per group and
+	// cycle. Must not be negative. Default: 32.
+	MaxSeriesPerGroup int
 }
 
 // ApplyDefaults sets default values for zero-valued fields.
@@ -53,6 +68,12 @@ func (c *Config) ApplyDefaults() {
 	if c.BatchSize == 0 {
 		c.BatchSize = DefaultBatchSize
 	}
+	if c.NodeGroups == nil {
+		c.NodeGroups = slices.Clone(NodeGroups)
+	}
+	if c.MaxSeriesPerGroup == 0 {
+		c.MaxSeriesPerGroup = DefaultMaxSeriesPerGroup
+	}
 }
 
 // Validate checks that configuration values are within acceptable ranges.
@@ -72,5 +93,13 @@ func (c *Config) Validate() error {
 	if c.BatchSize <= 0 {
 		return errors.New("metrics: config: BatchSize must be > 0")
 	}
+	for _, g := range c.NodeGroups {
+		if !slices.Contains(NodeGroups, g) {
+			return fmt.Errorf("metrics: config: unknown node group %q", g)
+		}
+	}
+	if c.MaxSeriesPerGroup < 0 {
+		return errors.New("metrics: config: MaxSeriesPerGroup must not be negative")
+	}
 	return nil
 }
@@ -158,3 +158,35 @@ func TestConfig_DefaultsBatchSizePreservesExisting(t *testing.T) {
 		t.Errorf("BatchSize = %d, want 50", cfg.BatchSize)
 	}
 }
+
+func TestConfig_DefaultsNodeCollector(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+
+	if len(cfg.NodeGroups) != len(NodeGroups) {
+		t.Errorf("NodeGroups = %v, want %v", cfg.NodeGroups, NodeGroups)
+	}
+	if cfg.MaxSeriesPerGroup != DefaultMaxSeriesPerGroup {
+		t.Errorf("MaxSeriesPerGroup = %d, want %d", cfg.MaxSeriesPerGroup, DefaultMaxSeriesPerGroup)
+	}
+}
+
+func TestConfig_ValidateNodeCollector(t *testing.T) {
+	tests := []struct {
+		name string
+		mod  func(*Config)
+	}{
+		{"unknown group", func(c *Config) { c.NodeGroups = []string{GroupNodeCPU, "node_gpu"} }},
+		{"negative series cap", func(c *Config) { c.MaxSeriesPerGroup = -1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{}
+			cfg.ApplyDefaults()
+			tt.mod(&cfg)
+			if err := cfg.Validate(); err == nil {
+				t.Error("Validate() = nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// EventStreamStatusReader abstracts event stream state retrieval.
+// *api.SSEManager satisfies this interface.
+type EventStreamStatusReader interface {
+	Status() api.SSEStatus
+}
+
+// EventStreamCollector implements Collector for event stream metrics.
+type EventStreamCollector struct {
+	reader EventStreamStatusReader
+}
+
+// NewEventStreamCollector creates a new EventStreamCollector.
+func NewEventStreamCollector(reader EventStreamStatusReader) *EventStreamCollector {
+	return &EventStreamCollector{reader: reader}
+}
+
+// Collect returns a single MetricPoint with the current event stream state:
+// whether it is connected, the age of the last event, the number of events
+// and reconnects, and the Last-Event-ID.
+func (c *EventStreamCollector) Collect(_ context.Context) ([]api.MetricPoint, error) {
+	data, err := json.Marshal(c.reader.Status())
+	if err != nil {
+		return nil, fmt.Errorf("metrics: event stream: %w", err)
+	}
+	return []api.MetricPoint{{
+		Timestamp: time.Now(),
+		Group:     GroupEventStream,
+		Data:      data,
+	}}, nil
+}
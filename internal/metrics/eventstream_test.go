@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+type staticEventStreamReader struct {
+	status api.SSEStatus
+}
+
+func (r staticEventStreamReader) Status() api.SSEStatus { return r.status }
+
+func TestEventStreamCollector_Collect(t *testing.T) {
+	want := api.SSEStatus{
+		Running:          true,
+		Connected:        true,
+		LastEventAgeNano: 2_000_000_000,
+		LastEventID:      "evt-7",
+		EventsReceived:   7,
+		Reconnects:       1,
+	}
+	c := NewEventStreamCollector(staticEventStreamReader{status: want})
+
+	points, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(points) != 1 {
+		t.Fatalf("len(points) = %d, want 1", len(points))
+	}
+	if points[0].Group != GroupEventStream {
+		t.Errorf("Group = %q, want %q", points[0].Group, GroupEventStream)
+	}
+	var got api.SSEStatus
+	if err := json.Unmarshal(points[0].Data, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got != want {
+		t.Errorf("status = %+v, want %+v", got, want)
+	}
+}
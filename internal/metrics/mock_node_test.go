@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+)
+
+// mockNodeReader is a test double for NodeStatsReader.
+type mockNodeReader struct {
+	mu          sync.Mutex
+	cpu         *CPUStats
+	memory      *MemoryStats
+	filesystems []FilesystemStats
+	network     []NetworkStats
+	load        *LoadStats
+	errs        map[string]error // keyed by group
+}
+
+func (m *mockNodeReader) err(group string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.errs[group]
+}
+
+func (m *mockNodeReader) ReadCPU(context.Context) (*CPUStats, error) {
+	return m.cpu, m.err(GroupNodeCPU)
+}
+
+func (m *mockNodeReader) ReadMemory(context.Context) (*MemoryStats, error) {
+	return m.memory, m.err(GroupNodeMemory)
+}
+
+func (m *mockNodeReader) ReadFilesystems(context.Context) ([]FilesystemStats, error) {
+	return m.filesystems, m.err(GroupNodeFilesystem)
+}
+
+func (m *mockNodeReader) ReadNetwork(context.Context) ([]NetworkStats, error) {
+	return m.network, m.err(GroupNodeNetwork)
+}
+
+func (m *mockNodeReader) ReadLoad(context.Context) (*LoadStats, error) {
+	return m.load, m.err(GroupNodeLoad)
+}
+
+// mockWireGuardReader is a test double for WireGuardStatsReader.
+type mockWireGuardReader struct {
+	stats []WireGuardStats
+	err   error
+}
+
+func (m *mockWireGuardReader) ReadWireGuardStats(context.Context) ([]WireGuardStats, error) {
+	return m.stats, m.err
+}
@@ -0,0 +1,240 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// CPUStats holds CPU utilisation since the previous reading, in percent of
+// all cores. The first reading covers the time since boot.
+type CPUStats struct {
+	Cores           int     `json:"cores"`
+	UsagePercent    float64 `json:"usage_percent"`
+	UserPercent     float64 `json:"user_percent"`
+	SystemPercent   float64 `json:"system_percent"`
+	IOWaitPercent   float64 `json:"iowait_percent"`
+	StealPercent    float64 `json:"steal_percent"`
+	ContextSwitches uint64  `json:"context_switches"`
+}
+
+// MemoryStats holds physical memory and swap usage.
+type MemoryStats struct {
+	TotalBytes     uint64 `json:"total_bytes"`
+	AvailableBytes uint64 `json:"available_bytes"`
+	UsedBytes      uint64 `json:"used_bytes"`
+	SwapTotalBytes uint64 `json:"swap_total_bytes"`
+	SwapUsedBytes  uint64 `json:"swap_used_bytes"`
+}
+
+// FilesystemStats holds the usage of a single mounted filesystem.
+type FilesystemStats struct {
+	Mountpoint string `json:"mountpoint"`
+	Device     string `json:"device"`
+	FSType     string `json:"fs_type"`
+	TotalBytes uint64 `json:"total_bytes"`
+	FreeBytes  uint64 `json:"free_bytes"`
+	AvailBytes uint64 `json:"avail_bytes"`
+	UsedBytes  uint64 `json:"used_bytes"`
+	Files      uint64 `json:"files"`
+	FilesFree  uint64 `json:"files_free"`
+}
+
+// NetworkStats holds the cumulative counters of a single network interface.
+type NetworkStats struct {
+	Interface string `json:"interface"`
+	RxBytes   uint64 `json:"rx_bytes"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+	TxPackets uint64 `json:"tx_packets"`
+	RxErrors  uint64 `json:"rx_errors"`
+	TxErrors  uint64 `json:"tx_errors"`
+	RxDropped uint64 `json:"rx_dropped"`
+	TxDropped uint64 `json:"tx_dropped"`
+}
+
+// LoadStats holds the system load averages.
+type LoadStats struct {
+	Load1  float64 `json:"load1"`
+	Load5  float64 `json:"load5"`
+	Load15 float64 `json:"load15"`
+}
+
+// WireGuardStats holds the transfer totals of a single WireGuard interface,
+// summed over its peers.
+type WireGuardStats struct {
+	Interface  string `json:"interface"`
+	ListenPort int    `json:"listen_port"`
+	Peers      int    `json:"peers"`
+	RxBytes    uint64 `json:"rx_bytes"`
+	TxBytes    uint64 `json:"tx_bytes"`
+}
+
+// NodeStatsReader abstracts OS-level node health readings.
+type NodeStatsReader interface {
+	ReadCPU(ctx context.Context) (*CPUStats, error)
+	ReadMemory(ctx context.Context) (*MemoryStats, error)
+	ReadFilesystems(ctx context.Context) ([]FilesystemStats, error)
+	ReadNetwork(ctx context.Context) ([]NetworkStats, error)
+	ReadLoad(ctx context.Context) (*LoadStats, error)
+}
+
+// WireGuardStatsReader abstracts per-interface WireGuard transfer readings.
+type WireGuardStatsReader interface {
+	ReadWireGuardStats(ctx context.Context) ([]WireGuardStats, error)
+}
+
+// NodeCollector implements Collector for node health metrics. It emits one
+// point per enabled group, or one point per device for the filesystem,
+// network, and WireGuard groups, capped at MaxSeriesPerGroup.
+type NodeCollector struct {
+	reader    NodeStatsReader
+	wg        WireGuardStatsReader
+	groups    []string
+	maxSeries int
+	logger    *slog.Logger
+}
+
+// NewNodeCollector creates a new NodeCollector. Groups and the series cap
+// come from cfg; zero values fall back to the defaults. wg may be nil, in
+// which case the WireGuard group is skipped.
+func NewNodeCollector(reader NodeStatsReader, wg WireGuardStatsReader, cfg Config, logger *slog.Logger) *NodeCollector {
+	groups := cfg.NodeGroups
+	if groups == nil {
+		groups = NodeGroups
+	}
+	maxSeries := cfg.MaxSeriesPerGroup
+	if maxSeries <= 0 {
+		maxSeries = DefaultMaxSeriesPerGroup
+	}
+	return &NodeCollector{
+		reader:    reader,
+		wg:        wg,
+		groups:    slices.Clone(groups),
+		maxSeries: maxSeries,
+		logger:    logger,
+	}
+}
+
+// Collect reads every enabled group. A group that fails is logged and
+// skipped so one broken source does not drop the others; an error is
+// returned only when every enabled group failed.
+func (c *NodeCollector) Collect(ctx context.Context) ([]api.MetricPoint, error) {
+	now := time.Now()
+	var points []api.MetricPoint
+	var errs []error
+	attempted := 0
+
+	for _, group := range c.groups {
+		if group == GroupNodeWireGuard && c.wg == nil {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return points, err
+		}
+		attempted++
+
+		data, err := c.readGroup(ctx, group)
+		if err != nil {
+			c.logger.Warn("node metrics group failed",
+				"component", "metrics",
+				"group", group,
+				"error", err,
+			)
+			errs = append(errs, fmt.Errorf("%s: %w", group, err))
+			continue
+		}
+		if len(data) > c.maxSeries {
+			c.logger.Debug("node metrics series capped",
+				"component", "metrics",
+				"group", group,
+				"series", len(data),
+				"max_series", c.maxSeries,
+			)
+			data = data[:c.maxSeries]
+		}
+		for _, d := range data {
+			points = append(points, api.MetricPoint{
+				Timestamp: now,
+				Group:     group,
+				Data:      d,
+			})
+		}
+	}
+
+	if attempted > 0 && len(errs) == attempted {
+		return nil, fmt.Errorf("metrics: node: %w", errors.Join(errs...))
+	}
+	if points == nil {
+		points = []api.MetricPoint{}
+	}
+	return points, nil
+}
+
+// readGroup reads a single group and returns one encoded payload per series.
+// Per-device series are sorted by name so the cap keeps a stable set.
+func (c *NodeCollector) readGroup(ctx context.Context, group string) ([]json.RawMessage, error) {
+	switch group {
+	case GroupNodeCPU:
+		s, err := c.reader.ReadCPU(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return encodeSeries([]*CPUStats{s})
+	case GroupNodeMemory:
+		s, err := c.reader.ReadMemory(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return encodeSeries([]*MemoryStats{s})
+	case GroupNodeFilesystem:
+		s, err := c.reader.ReadFilesystems(ctx)
+		if err != nil {
+			return nil, err
+		}
+		slices.SortFunc(s, func(a, b FilesystemStats) int { return strings.Compare(a.Mountpoint, b.Mountpoint) })
+		return encodeSeries(s)
+	case GroupNodeNetwork:
+		s, err := c.reader.ReadNetwork(ctx)
+		if err != nil {
+			return nil, err
+		}
+		slices.SortFunc(s, func(a, b NetworkStats) int { return strings.Compare(a.Interface, b.Interface) })
+		return encodeSeries(s)
+	case GroupNodeLoad:
+		s, err := c.reader.ReadLoad(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return encodeSeries([]*LoadStats{s})
+	case GroupNodeWireGuard:
+		s, err := c.wg.ReadWireGuardStats(ctx)
+		if err != nil {
+			return nil, err
+		}
+		slices.SortFunc(s, func(a, b WireGuardStats) int { return strings.Compare(a.Interface, b.Interface) })
+		return encodeSeries(s)
+	default:
+		return nil, fmt.Errorf("unknown group %q", group)
+	}
+}
+
+// encodeSeries JSON-encodes each element of s.
+func encodeSeries[T any](s []T) ([]json.RawMessage, error) {
+	out := make([]json.RawMessage, 0, len(s))
+	for _, v := range s {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, data)
+	}
+	return out, nil
+}
@@ -0,0 +1,315 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ignoredFSTypes are pseudo and virtual filesystems that carry no node
+// storage and would only add series.
+var ignoredFSTypes = map[string]bool{
+	"autofs": true, "binfmt_misc": true, "bpf": true, "cgroup": true,
+	"cgroup2": true, "configfs": true, "debugfs": true, "devpts": true,
+	"devtmpfs": true, "fusectl": true, "hugetlbfs": true, "mqueue": true,
+	"nsfs": true, "overlay": true, "proc": true, "pstore": true,
+	"ramfs": true, "rpc_pipefs": true, "securityfs": true, "selinuxfs": true,
+	"squashfs": true, "sysfs": true, "tmpfs": true, "tracefs": true,
+}
+
+// cpuTimes is one aggregate line of /proc/stat, in clock ticks.
+type cpuTimes struct {
+	user, nice, system, idle, iowait, irq, softirq, steal uint64
+}
+
+func (t cpuTimes) total() uint64 {
+	return t.user + t.nice + t.system + t.idle + t.iowait + t.irq + t.softirq + t.steal
+}
+
+// ProcfsReader implements NodeStatsReader from the Linux proc filesystem.
+// CPU utilisation is computed from the difference to the previous reading.
+type ProcfsReader struct {
+	root string
+
+	mu      sync.Mutex
+	prevCPU *cpuTimes
+}
+
+// NewProcfsReader creates a ProcfsReader. root is the directory that holds
+// proc, normally "/"; an empty root means "/". Mountpoints are resolved
+// below root, so a containerised agent can point it at the host's root.
+func NewProcfsReader(root string) *ProcfsReader {
+	if root == "" {
+		root = "/"
+	}
+	return &ProcfsReader{root: root}
+}
+
+func (r *ProcfsReader) path(elem ...string) string {
+	return filepath.Join(append([]string{r.root}, elem...)...)
+}
+
+// ReadCPU reads /proc/stat.
+func (r *ProcfsReader) ReadCPU(_ context.Context) (*CPUStats, error) {
+	f, err := os.Open(r.path("proc", "stat"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cur *cpuTimes
+	stats := &CPUStats{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch {
+		case fields[0] == "cpu":
+			t, err := parseCPUTimes(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("parse /proc/stat: %w", err)
+			}
+			cur = &t
+		case strings.HasPrefix(fields[0], "cpu"):
+			stats.Cores++
+		case fields[0] == "ctxt" && len(fields) > 1:
+			stats.ContextSwitches, _ = strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if cur == nil {
+		return nil, errors.New("parse /proc/stat: no cpu line")
+	}
+
+	r.mu.Lock()
+	prev := r.prevCPU
+	r.prevCPU = cur
+	r.mu.Unlock()
+
+	delta := *cur
+	if prev != nil && cur.total() >= prev.total() {
+		delta = cpuTimes{
+			user:    cur.user - min(prev.user, cur.user),
+			nice:    cur.nice - min(prev.nice, cur.nice),
+			system:  cur.system - min(prev.system, cur.system),
+			idle:    cur.idle - min(prev.idle, cur.idle),
+			iowait:  cur.iowait - min(prev.iowait, cur.iowait),
+			irq:     cur.irq - min(prev.irq, cur.irq),
+			softirq: cur.softirq - min(prev.softirq, cur.softirq),
+			steal:   cur.steal - min(prev.steal, cur.steal),
+		}
+	}
+	total := float64(delta.total())
+	if total > 0 {
+		pct := func(v uint64) float64 { return float64(v) / total * 100 }
+		stats.UsagePercent = 100 - pct(delta.idle+delta.iowait)
+		stats.UserPercent = pct(delta.user + delta.nice)
+		stats.SystemPercent = pct(delta.system + delta.irq + delta.softirq)
+		stats.IOWaitPercent = pct(delta.iowait)
+		stats.StealPercent = pct(delta.steal)
+	}
+	return stats, nil
+}
+
+// parseCPUTimes parses the counters of a /proc/stat cpu line. Older kernels
+// report fewer columns; missing columns are zero.
+func parseCPUTimes(fields []string) (cpuTimes, error) {
+	var v [8]uint64
+	if len(fields) < 4 {
+		return cpuTimes{}, fmt.Errorf("short cpu line: %d fields", len(fields))
+	}
+	for i := 0; i < len(v) && i < len(fields); i++ {
+		n, err := strconv.ParseUint(fields[i], 10, 64)
+		if err != nil {
+			return cpuTimes{}, err
+		}
+		v[i] = n
+	}
+	return cpuTimes{
+		user: v[0], nice: v[1], system: v[2], idle: v[3],
+		iowait: v[4], irq: v[5], softirq: v[6], steal: v[7],
+	}, nil
+}
+
+// ReadMemory reads /proc/meminfo.
+func (r *ProcfsReader) ReadMemory(_ context.Context) (*MemoryStats, error) {
+	f, err := os.Open(r.path("proc", "meminfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	kb := make(map[string]uint64)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		key, rest, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		n, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		kb[key] = n
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	total, ok := kb["MemTotal"]
+	if !ok {
+		return nil, errors.New("parse /proc/meminfo: no MemTotal")
+	}
+	avail, ok := kb["MemAvailable"]
+	if !ok {
+		// Kernels before 3.14 lack MemAvailable.
+		avail = kb["MemFree"] + kb["Buffers"] + kb["Cached"]
+	}
+	avail = min(avail, total)
+	swapTotal := kb["SwapTotal"]
+	swapFree := min(kb["SwapFree"], swapTotal)
+	return &MemoryStats{
+		TotalBytes:     total * 1024,
+		AvailableBytes: avail * 1024,
+		UsedBytes:      (total - avail) * 1024,
+		SwapTotalBytes: swapTotal * 1024,
+		SwapUsedBytes:  (swapTotal - swapFree) * 1024,
+	}, nil
+}
+
+// ReadFilesystems reads /proc/mounts and stats every mounted block
+// filesystem. A mountpoint that cannot be stat'ed is skipped.
+func (r *ProcfsReader) ReadFilesystems(_ context.Context) ([]FilesystemStats, error) {
+	f, err := os.Open(r.path("proc", "mounts"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	var out []FilesystemStats
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		device, mountpoint, fsType := fields[0], unescapeMountField(fields[1]), fields[2]
+		if ignoredFSTypes[fsType] || seen[mountpoint] {
+			continue
+		}
+		seen[mountpoint] = true
+
+		s, err := statFilesystem(r.path(mountpoint))
+		if err != nil {
+			continue
+		}
+		s.Mountpoint = mountpoint
+		s.Device = device
+		s.FSType = fsType
+		out = append(out, s)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// unescapeMountField decodes the octal escapes /proc/mounts uses for
+// spaces, tabs, newlines and backslashes.
+func unescapeMountField(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// ReadNetwork reads /proc/net/dev. The loopback interface is skipped.
+func (r *ProcfsReader) ReadNetwork(_ context.Context) ([]NetworkStats, error) {
+	f, err := os.Open(r.path("proc", "net", "dev"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []NetworkStats
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		name, rest, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		if name == "lo" {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 16 {
+			continue
+		}
+		var v [16]uint64
+		for i := range v {
+			v[i], _ = strconv.ParseUint(fields[i], 10, 64)
+		}
+		out = append(out, NetworkStats{
+			Interface: name,
+			RxBytes:   v[0],
+			RxPackets: v[1],
+			RxErrors:  v[2],
+			RxDropped: v[3],
+			TxBytes:   v[8],
+			TxPackets: v[9],
+			TxErrors:  v[10],
+			TxDropped: v[11],
+		})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReadLoad reads /proc/loadavg.
+func (r *ProcfsReader) ReadLoad(_ context.Context) (*LoadStats, error) {
+	data, err := os.ReadFile(r.path("proc", "loadavg"))
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return nil, errors.New("parse /proc/loadavg: short line")
+	}
+	var v [3]float64
+	for i := range v {
+		v[i], err = strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse /proc/loadavg: %w", err)
+		}
+	}
+	return &LoadStats{Load1: v[0], Load5: v[1], Load15: v[2]}, nil
+}
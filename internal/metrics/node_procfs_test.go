@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// writeProcFile writes a fixture below root/proc.
+func writeProcFile(t *testing.T, root, name, content string) {
+	t.Helper()
+	path := filepath.Join(root, "proc", name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcfsReader_ReadCPU_Delta(t *testing.T) {
+	root := t.TempDir()
+	writeProcFile(t, root, "stat", "cpu  100 0 100 800 0 0 0 0 0 0\ncpu0 50 0 50 400 0 0 0 0 0 0\ncpu1 50 0 50 400 0 0 0 0 0 0\nctxt 42\n")
+	r := NewProcfsReader(root)
+
+	first, err := r.ReadCPU(context.Background())
+	if err != nil {
+		t.Fatalf("ReadCPU() error = %v", err)
+	}
+	if first.Cores != 2 {
+		t.Errorf("Cores = %d, want 2", first.Cores)
+	}
+	if first.ContextSwitches != 42 {
+		t.Errorf("ContextSwitches = %d, want 42", first.ContextSwitches)
+	}
+	if math.Abs(first.UsagePercent-20) > 0.01 {
+		t.Errorf("first UsagePercent = %v, want 20 (since boot)", first.UsagePercent)
+	}
+
+	// 100 more ticks: 50 user, 10 iowait, 40 idle.
+	writeProcFile(t, root, "stat", "cpu  150 0 100 840 10 0 0 0 0 0\ncpu0 75 0 50 420 5 0 0 0 0 0\ncpu1 75 0 50 420 5 0 0 0 0 0\n")
+	second, err := r.ReadCPU(context.Background())
+	if err != nil {
+		t.Fatalf("ReadCPU() error = %v", err)
+	}
+	if math.Abs(second.UsagePercent-50) > 0.01 {
+		t.Errorf("UsagePercent = %v, want 50", second.UsagePercent)
+	}
+	if math.Abs(second.UserPercent-50) > 0.01 {
+		t.Errorf("UserPercent = %v, want 50", second.UserPercent)
+	}
+	if math.Abs(second.IOWaitPercent-10) > 0.01 {
+		t.Errorf("IOWaitPercent = %v, want 10", second.IOWaitPercent)
+	}
+}
+
+func TestProcfsReader_ReadMemory(t *testing.T) {
+	root := t.TempDir()
+	writeProcFile(t, root, "meminfo", "MemTotal:        8000 kB\nMemFree:         1000 kB\nMemAvailable:    3000 kB\nSwapTotal:       2000 kB\nSwapFree:        1500 kB\n")
+	r := NewProcfsReader(root)
+
+	got, err := r.ReadMemory(context.Background())
+	if err != nil {
+		t.Fatalf("ReadMemory() error = %v", err)
+	}
+	want := MemoryStats{
+		TotalBytes:     8000 * 1024,
+		AvailableBytes: 3000 * 1024,
+		UsedBytes:      5000 * 1024,
+		SwapTotalBytes: 2000 * 1024,
+		SwapUsedBytes:  500 * 1024,
+	}
+	if *got != want {
+		t.Errorf("ReadMemory() = %+v, want %+v", *got, want)
+	}
+}
+
+func TestProcfsReader_ReadNetwork(t *testing.T) {
+	root := t.TempDir()
+	writeProcFile(t, root, "net/dev", `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:     100       1    0    0    0     0          0         0      100       1    0    0    0     0       0          0
+  eth0:    5000      50    1    2    0     0          0         0     7000      70    3    4    0     0       0          0
+`)
+	r := NewProcfsReader(root)
+
+	got, err := r.ReadNetwork(context.Background())
+	if err != nil {
+		t.Fatalf("ReadNetwork() error = %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("len = %d, want 1 (loopback skipped)", len(got))
+	}
+	want := NetworkStats{
+		Interface: "eth0", RxBytes: 5000, RxPackets: 50, RxErrors: 1, RxDropped: 2,
+		TxBytes: 7000, TxPackets: 70, TxErrors: 3, TxDropped: 4,
+	}
+	if got[0] != want {
+		t.Errorf("ReadNetwork() = %+v, want %+v", got[0], want)
+	}
+}
+
+func TestProcfsReader_ReadLoad(t *testing.T) {
+	root := t.TempDir()
+	writeProcFile(t, root, "loadavg", "0.52 0.41 0.30 1/123 4567\n")
+	r := NewProcfsReader(root)
+
+	got, err := r.ReadLoad(context.Background())
+	if err != nil {
+		t.Fatalf("ReadLoad() error = %v", err)
+	}
+	if *got != (LoadStats{Load1: 0.52, Load5: 0.41, Load15: 0.30}) {
+		t.Errorf("ReadLoad() = %+v", *got)
+	}
+}
+
+func TestProcfsReader_ReadFilesystems(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("filesystem stats require linux")
+	}
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "data dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeProcFile(t, root, "mounts", `/dev/sda1 / ext4 rw 0 0
+proc /proc proc rw 0 0
+tmpfs /run tmpfs rw 0 0
+/dev/sdb1 /data\040dir xfs rw 0 0
+/dev/sdc1 /missing ext4 rw 0 0
+`)
+	r := NewProcfsReader(root)
+
+	got, err := r.ReadFilesystems(context.Background())
+	if err != nil {
+		t.Fatalf("ReadFilesystems() error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("len = %d, want 2: %+v", len(got), got)
+	}
+	if got[0].Mountpoint != "/" || got[0].Device != "/dev/sda1" || got[0].FSType != "ext4" {
+		t.Errorf("got[0] = %+v", got[0])
+	}
+	if got[1].Mountpoint != "/data dir" {
+		t.Errorf("got[1].Mountpoint = %q, want %q", got[1].Mountpoint, "/data dir")
+	}
+	if got[0].TotalBytes == 0 {
+		t.Error("TotalBytes = 0, want statfs result")
+	}
+}
+
+func TestProcfsReader_MissingProc(t *testing.T) {
+	r := NewProcfsReader(t.TempDir())
+	if _, err := r.ReadCPU(context.Background()); err == nil {
+		t.Error("ReadCPU() error = nil, want error")
+	}
+	if _, err := r.ReadLoad(context.Background()); err == nil {
+		t.Error("ReadLoad() error = nil, want error")
+	}
+}
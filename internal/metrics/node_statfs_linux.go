@@ -0,0 +1,22 @@
+package metrics
+
+import "golang.org/x/sys/unix"
+
+// statFilesystem returns the usage of the filesystem mounted at path.
+func statFilesystem(path string) (FilesystemStats, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return FilesystemStats{}, err
+	}
+	bsize := uint64(st.Bsize)
+	total := st.Blocks * bsize
+	free := st.Bfree * bsize
+	return FilesystemStats{
+		TotalBytes: total,
+		FreeBytes:  free,
+		AvailBytes: st.Bavail * bsize,
+		UsedBytes:  total - free,
+		Files:      st.Files,
+		FilesFree:  st.Ffree,
+	}, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package metrics
+
+import "errors"
+
+// statFilesystem is unavailable on non-Linux platforms.
+func statFilesystem(_ string) (FilesystemStats, error) {
+	return FilesystemStats{}, errors.New("metrics: filesystem stats are only supported on linux")
+}
@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+func newTestNodeReader() *mockNodeReader {
+	return &mockNodeReader{
+		cpu:    &CPUStats{Cores: 4, UsagePercent: 12.5},
+		memory: &MemoryStats{TotalBytes: 4096, AvailableBytes: 1024, UsedBytes: 3072},
+		filesystems: []FilesystemStats{
+			{Mountpoint: "/var", TotalBytes: 100},
+			{Mountpoint: "/", TotalBytes: 200},
+		},
+		network: []NetworkStats{
+			{Interface: "eth1", RxBytes: 1},
+			{Interface: "eth0", RxBytes: 2},
+		},
+		load: &LoadStats{Load1: 0.5, Load5: 0.25, Load15: 0.1},
+	}
+}
+
+func countGroups(points []api.MetricPoint) map[string]int {
+	counts := make(map[string]int)
+	for _, p := range points {
+		counts[p.Group]++
+	}
+	return counts
+}
+
+func TestNodeCollector_CollectAllGroups(t *testing.T) {
+	wg := &mockWireGuardReader{stats: []WireGuardStats{{Interface: "plexd0", Peers: 2, RxBytes: 10, TxBytes: 20}}}
+	c := NewNodeCollector(newTestNodeReader(), wg, Config{}, discardLogger())
+
+	points, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	want := map[string]int{
+		GroupNodeCPU:        1,
+		GroupNodeMemory:     1,
+		GroupNodeFilesystem: 2,
+		GroupNodeNetwork:    2,
+		GroupNodeLoad:       1,
+		GroupNodeWireGuard:  1,
+	}
+	got := countGroups(points)
+	for g, n := range want {
+		if got[g] != n {
+			t.Errorf("points in group %q = %d, want %d", g, got[g], n)
+		}
+	}
+	for _, p := range points {
+		if p.PeerID != "" {
+			t.Errorf("PeerID = %q, want empty", p.PeerID)
+		}
+		if p.Timestamp.IsZero() {
+			t.Error("Timestamp is zero")
+		}
+	}
+}
+
+func TestNodeCollector_SortsAndCapsSeries(t *testing.T) {
+	c := NewNodeCollector(newTestNodeReader(), nil, Config{
+		NodeGroups:        []string{GroupNodeNetwork},
+		MaxSeriesPerGroup: 1,
+	}, discardLogger())
+
+	points, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(points) != 1 {
+		t.Fatalf("len(points) = %d, want 1", len(points))
+	}
+	var got NetworkStats
+	if err := json.Unmarshal(points[0].Data, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.Interface != "eth0" {
+		t.Errorf("Interface = %q, want %q (first by name)", got.Interface, "eth0")
+	}
+}
+
+func TestNodeCollector_SelectedGroupsOnly(t *testing.T) {
+	wg := &mockWireGuardReader{stats: []WireGuardStats{{Interface: "plexd0"}}}
+	c := NewNodeCollector(newTestNodeReader(), wg, Config{
+		NodeGroups: []string{GroupNodeLoad, GroupNodeWireGuard},
+	}, discardLogger())
+
+	points, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	got := countGroups(points)
+	if len(got) != 2 || got[GroupNodeLoad] != 1 || got[GroupNodeWireGuard] != 1 {
+		t.Errorf("groups = %v, want node_load and node_wireguard only", got)
+	}
+}
+
+func TestNodeCollector_NilWireGuardReaderSkipsGroup(t *testing.T) {
+	c := NewNodeCollector(newTestNodeReader(), nil, Config{
+		NodeGroups: []string{GroupNodeWireGuard},
+	}, discardLogger())
+
+	points, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if points == nil || len(points) != 0 {
+		t.Errorf("points = %v, want empty non-nil slice", points)
+	}
+}
+
+func TestNodeCollector_PartialFailure(t *testing.T) {
+	reader := newTestNodeReader()
+	reader.errs = map[string]error{GroupNodeFilesystem: errors.New("mounts unreadable")}
+	c := NewNodeCollector(reader, nil, Config{}, discardLogger())
+
+	points, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect() error = %v, want nil on partial failure", err)
+	}
+	got := countGroups(points)
+	if got[GroupNodeFilesystem] != 0 {
+		t.Errorf("filesystem points = %d, want 0", got[GroupNodeFilesystem])
+	}
+	if got[GroupNodeCPU] != 1 {
+		t.Errorf("cpu points = %d, want 1", got[GroupNodeCPU])
+	}
+}
+
+func TestNodeCollector_AllGroupsFail(t *testing.T) {
+	procErr := errors.New("no procfs")
+	reader := &mockNodeReader{errs: map[string]error{
+		GroupNodeCPU:    procErr,
+		GroupNodeMemory: procErr,
+	}}
+	c := NewNodeCollector(reader, nil, Config{
+		NodeGroups: []string{GroupNodeCPU, GroupNodeMemory},
+	}, discardLogger())
+
+	points, err := c.Collect(context.Background())
+	if err == nil {
+		t.Fatal("Collect() error = nil, want error")
+	}
+	if points != nil {
+		t.Errorf("points = %v, want nil", points)
+	}
+	if !errors.Is(err, procErr) {
+		t.Errorf("error does not wrap original: %v", err)
+	}
+}
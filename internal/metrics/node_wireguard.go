@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+)
+
+// WGCtrlReader implements WireGuardStatsReader through wgctrl. It reports
+// every WireGuard interface on the node.
+type WGCtrlReader struct{}
+
+// NewWGCtrlReader creates a WGCtrlReader.
+func NewWGCtrlReader() *WGCtrlReader {
+	return &WGCtrlReader{}
+}
+
+// ReadWireGuardStats returns the transfer totals of each WireGuard interface.
+func (WGCtrlReader) ReadWireGuardStats(_ context.Context) ([]WireGuardStats, error) {
+	client, err := wgctrl.New()
+	if err != nil {
+		return nil, fmt.Errorf("open wgctrl: %w", err)
+	}
+	defer client.Close()
+
+	devices, err := client.Devices()
+	if err != nil {
+		return nil, fmt.Errorf("list devices: %w", err)
+	}
+	out := make([]WireGuardStats, 0, len(devices))
+	for _, d := range devices {
+		s := WireGuardStats{
+			Interface:  d.Name,
+			ListenPort: d.ListenPort,
+			Peers:      len(d.Peers),
+		}
+		for _, p := range d.Peers {
+			s.RxBytes += uint64(p.ReceiveBytes)
+			s.TxBytes += uint64(p.TransmitBytes)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// PeerHealthReader abstracts peer handshake health retrieval.
+// *peerhealth.Monitor satisfies this interface.
+type PeerHealthReader interface {
+	PeerHealth() *api.PeerHealthInfo
+}
+
+// PeerHealthCollector implements Collector for inactive and flapping mesh
+// peers.
+type PeerHealthCollector struct {
+	reader PeerHealthReader
+}
+
+// NewPeerHealthCollector creates a new PeerHealthCollector.
+func NewPeerHealthCollector(reader PeerHealthReader) *PeerHealthCollector {
+	return &PeerHealthCollector{reader: reader}
+}
+
+// Collect returns a MetricPoint per inactive or flapping peer with its state
+// and the number of state changes within the flap window. No points are
+// returned before the first check or while every peer is healthy.
+func (c *PeerHealthCollector) Collect(_ context.Context) ([]api.MetricPoint, error) {
+	info := c.reader.PeerHealth()
+	if info == nil {
+		return nil, nil
+	}
+
+	now := time.Now()
+	points := make([]api.MetricPoint, 0, len(info.Peers))
+	for _, p := range info.Peers {
+		data, err := json.Marshal(p)
+		if err != nil {
+			return nil, fmt.Errorf("metrics: peer health: %w", err)
+		}
+		points = append(points, api.MetricPoint{
+			Timestamp: now,
+			Group:     GroupPeerHealth,
+			PeerID:    p.PeerID,
+			Data:      data,
+		})
+	}
+	return points, nil
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+type staticPeerHealthReader struct {
+	info *api.PeerHealthInfo
+}
+
+func (r staticPeerHealthReader) PeerHealth() *api.PeerHealthInfo { return r.info }
+
+func TestPeerHealthCollector_Collect(t *testing.T) {
+	since := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	info := &api.PeerHealthInfo{
+		PeersTotal:  3,
+		PeersActive: 2,
+		Peers: []api.PeerHealthStatus{
+			{PeerID: "peer-a", Since: since, Transitions: 4, Flapping: true},
+			{PeerID: "peer-b", Inactive: true, Since: since, Transitions: 1, RoutesRemoved: true},
+		},
+	}
+	c := NewPeerHealthCollector(staticPeerHealthReader{info: info})
+
+	points, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("len(points) = %d, want 2", len(points))
+	}
+	for i, p := range points {
+		if p.Group != GroupPeerHealth {
+			t.Errorf("points[%d].Group = %q, want %q", i, p.Group, GroupPeerHealth)
+		}
+		if p.PeerID != info.Peers[i].PeerID {
+			t.Errorf("points[%d].PeerID = %q, want %q", i, p.PeerID, info.Peers[i].PeerID)
+		}
+		var got api.PeerHealthStatus
+		if err := json.Unmarshal(p.Data, &got); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if got.Flapping != info.Peers[i].Flapping || got.Inactive != info.Peers[i].Inactive || got.Transitions != info.Peers[i].Transitions {
+			t.Errorf("points[%d] = %+v, want %+v", i, got, info.Peers[i])
+		}
+	}
+}
+
+func TestPeerHealthCollector_NotChecked(t *testing.T) {
+	c := NewPeerHealthCollector(staticPeerHealthReader{})
+
+	points, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(points) != 0 {
+		t.Errorf("len(points) = %d, want 0", len(points))
+	}
+}
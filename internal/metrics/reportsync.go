@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// ReportSyncStats holds the state of report syncing to the control plane.
+type ReportSyncStats struct {
+	// PendingChanges is the number of buffered report updates, report
+	// deletions and labels not yet synced.
+	PendingChanges int `json:"pending_changes"`
+	// LagSeconds is the age of the oldest change not yet synced, or 0.
+	LagSeconds float64 `json:"lag_seconds"`
+	// LastSuccess is the time of the last successful sync.
+	LastSuccess time.Time `json:"last_success"`
+	// LastBatchSize is the number of changes sent by the last successful sync.
+	LastBatchSize int `json:"last_batch_size"`
+	// Syncs and Failures count successful and failed sync requests.
+	Syncs    uint64 `json:"syncs"`
+	Failures uint64 `json:"failures"`
+	// ConsecutiveFailures is the number of failed syncs since the last success.
+	ConsecutiveFailures int `json:"consecutive_failures"`
+}
+
+// ReportSyncStatsReader abstracts report sync stats retrieval.
+type ReportSyncStatsReader interface {
+	ReportSyncStats() ReportSyncStats
+}
+
+// ReportSyncCollector implements Collector for report sync metrics.
+type ReportSyncCollector struct {
+	reader ReportSyncStatsReader
+}
+
+// NewReportSyncCollector creates a new ReportSyncCollector.
+func NewReportSyncCollector(reader ReportSyncStatsReader) *ReportSyncCollector {
+	return &ReportSyncCollector{reader: reader}
+}
+
+// Collect returns a single MetricPoint with the current report sync stats.
+func (c *ReportSyncCollector) Collect(_ context.Context) ([]api.MetricPoint, error) {
+	data, err := json.Marshal(c.reader.ReportSyncStats())
+	if err != nil {
+		return nil, fmt.Errorf("metrics: report sync: %w", err)
+	}
+	return []api.MetricPoint{{
+		Timestamp: time.Now(),
+		Group:     GroupReportSync,
+		Data:      data,
+	}}, nil
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type staticReportSyncReader struct {
+	stats ReportSyncStats
+}
+
+func (r staticReportSyncReader) ReportSyncStats() ReportSyncStats { return r.stats }
+
+func TestReportSyncCollector_Collect(t *testing.T) {
+	want := ReportSyncStats{
+		PendingChanges: 3,
+		LagSeconds:     1.5,
+		LastSuccess:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		LastBatchSize:  7,
+		Syncs:          10,
+		Failures:       2,
+	}
+	c := NewReportSyncCollector(staticReportSyncReader{stats: want})
+
+	points, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(points) != 1 {
+		t.Fatalf("len(points) = %d, want 1", len(points))
+	}
+	if points[0].Group != GroupReportSync {
+		t.Errorf("Group = %q, want %q", points[0].Group, GroupReportSync)
+	}
+	var got ReportSyncStats
+	if err := json.Unmarshal(points[0].Data, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+}
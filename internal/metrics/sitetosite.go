@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// SiteToSiteStatusReader abstracts site-to-site status retrieval.
+// *bridge.SiteToSiteManager satisfies this interface.
+type SiteToSiteStatusReader interface {
+	SiteToSiteStatus() *api.SiteToSiteInfo
+}
+
+// SiteToSiteCollector implements Collector for per-tunnel site-to-site
+// traffic metrics.
+type SiteToSiteCollector struct {
+	reader SiteToSiteStatusReader
+}
+
+// NewSiteToSiteCollector creates a new SiteToSiteCollector.
+func NewSiteToSiteCollector(reader SiteToSiteStatusReader) *SiteToSiteCollector {
+	return &SiteToSiteCollector{reader: reader}
+}
+
+// Collect returns a MetricPoint per tunnel with its endpoint, traffic
+// counters, and last handshake. PeerID is the tunnel ID. No points are
+// returned while site-to-site is not active.
+func (c *SiteToSiteCollector) Collect(_ context.Context) ([]api.MetricPoint, error) {
+	info := c.reader.SiteToSiteStatus()
+	if info == nil {
+		return nil, nil
+	}
+
+	now := time.Now()
+	points := make([]api.MetricPoint, 0, len(info.Tunnels))
+	for _, t := range info.Tunnels {
+		data, err := json.Marshal(t)
+		if err != nil {
+			return nil, fmt.Errorf("metrics: site to site: %w", err)
+		}
+		points = append(points, api.MetricPoint{
+			Timestamp: now,
+			Group:     GroupSiteToSite,
+			PeerID:    t.TunnelID,
+			Data:      data,
+		})
+	}
+	return points, nil
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+type staticSiteToSiteReader struct {
+	info *api.SiteToSiteInfo
+}
+
+func (r staticSiteToSiteReader) SiteToSiteStatus() *api.SiteToSiteInfo { return r.info }
+
+func TestSiteToSiteCollector_Collect(t *testing.T) {
+	info := &api.SiteToSiteInfo{
+		Enabled:     true,
+		TunnelCount: 2,
+		Tunnels: []api.SiteToSiteTunnelStatus{
+			{TunnelID: "t-1", Interface: "wg-s2s-0", Endpoint: "1.2.3.4:51823", RxBytes: 1000, TxBytes: 2000},
+			{TunnelID: "t-2", Interface: "wg-s2s-1", Error: "peer not configured"},
+		},
+	}
+	c := NewSiteToSiteCollector(staticSiteToSiteReader{info: info})
+
+	points, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("len(points) = %d, want 2", len(points))
+	}
+	for i, p := range points {
+		if p.Group != GroupSiteToSite {
+			t.Errorf("points[%d].Group = %q, want %q", i, p.Group, GroupSiteToSite)
+		}
+		if p.PeerID != info.Tunnels[i].TunnelID {
+			t.Errorf("points[%d].PeerID = %q, want %q", i, p.PeerID, info.Tunnels[i].TunnelID)
+		}
+		var got api.SiteToSiteTunnelStatus
+		if err := json.Unmarshal(p.Data, &got); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if got != info.Tunnels[i] {
+			t.Errorf("points[%d] = %+v, want %+v", i, got, info.Tunnels[i])
+		}
+	}
+}
+
+func TestSiteToSiteCollector_Inactive(t *testing.T) {
+	c := NewSiteToSiteCollector(staticSiteToSiteReader{})
+
+	points, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(points) != 0 {
+		t.Errorf("len(points) = %d, want 0", len(points))
+	}
+}
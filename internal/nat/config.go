@@ -12,6 +12,12 @@ const DefaultRefreshInterval = 60 * time.Second
 // DefaultTimeout is the default per-server STUN request timeout.
 const DefaultTimeout = 5 * time.Second
 
+// DefaultHolePunchAttempts is the default number of UDP packets sent per hole punch.
+const DefaultHolePunchAttempts = 5
+
+// DefaultHolePunchInterval is the default delay between hole punch packets.
+const DefaultHolePunchInterval = 100 * time.Millisecond
+
 // DefaultSTUNServers is the default list of STUN servers used for NAT traversal.
 var DefaultSTUNServers = []string{
 	"stun.l.google.com:19302",
@@ -34,6 +40,15 @@ type Config struct {
 	// Timeout is the per-server STUN request timeout.
 	// Must be positive.
 	Timeout time.Duration
+
+	// HolePunchAttempts is the number of UDP packets sent toward each peer
+	// endpoint before the WireGuard endpoint is updated.
+	// Default: 5
+	HolePunchAttempts int
+
+	// HolePunchInterval is the delay between hole punch packets.
+	// Default: 100ms
+	HolePunchInterval time.Duration
 }
 
 // ApplyDefaults sets default values for zero-valued fields.
@@ -55,6 +70,12 @@ func (c *Config) ApplyDefaults() {
 	if c.Timeout == 0 {
 		c.Timeout = DefaultTimeout
 	}
+	if c.HolePunchAttempts == 0 {
+		c.HolePunchAttempts = DefaultHolePunchAttempts
+	}
+	if c.HolePunchInterval == 0 {
+		c.HolePunchInterval = DefaultHolePunchInterval
+	}
 }
 
 // Validate checks that configuration values are within acceptable ranges.
@@ -71,5 +92,11 @@ func (c *Config) Validate() error {
 	if c.Timeout <= 0 {
 		return errors.New("nat: config: Timeout must be positive")
 	}
+	if c.HolePunchAttempts < 0 {
+		return errors.New("nat: config: HolePunchAttempts must not be negative")
+	}
+	if c.HolePunchInterval < 0 {
+		return errors.New("nat: config: HolePunchInterval must not be negative")
+	}
 	return nil
 }
@@ -26,6 +26,12 @@ func TestConfig_Defaults(t *testing.T) {
 	if cfg.Timeout != 5*time.Second {
 		t.Errorf("Timeout = %v, want %v", cfg.Timeout, 5*time.Second)
 	}
+	if cfg.HolePunchAttempts != DefaultHolePunchAttempts {
+		t.Errorf("HolePunchAttempts = %d, want %d", cfg.HolePunchAttempts, DefaultHolePunchAttempts)
+	}
+	if cfg.HolePunchInterval != DefaultHolePunchInterval {
+		t.Errorf("HolePunchInterval = %v, want %v", cfg.HolePunchInterval, DefaultHolePunchInterval)
+	}
 }
 
 func TestConfig_DefaultsPreserveExplicitDisabled(t *testing.T) {
@@ -149,3 +155,18 @@ func TestConfig_ValidateAcceptsCustomValues(t *testing.T) {
 		t.Errorf("Validate() = %v, want nil", err)
 	}
 }
+
+func TestConfig_ValidateRejectsNegativeHolePunch(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+	cfg.HolePunchAttempts = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for negative HolePunchAttempts")
+	}
+
+	cfg.HolePunchAttempts = DefaultHolePunchAttempts
+	cfg.HolePunchInterval = -time.Second
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for negative HolePunchInterval")
+	}
+}
@@ -14,6 +14,10 @@ import (
 type DiscoveryResult struct {
 	Endpoint string  // "ip:port" format
 	NATType  NATType
+
+	// LANEndpoints are the private endpoints reported alongside the public
+	// one; empty unless AdvertiseLAN was called.
+	LANEndpoints []string
 }
 
 // Discoverer performs STUN-based NAT traversal to discover the node's public endpoint.
@@ -22,6 +26,15 @@ type Discoverer struct {
 	cfg       Config
 	localPort int
 	logger    *slog.Logger
+	puncher   HolePuncher
+
+	// advertiseLAN enables LAN endpoint reporting; lanExclude lists the
+	// interfaces left out.
+	advertiseLAN bool
+	lanExclude   []string
+
+	// trigger is a buffered channel (size 1) used to coalesce TriggerDiscovery calls.
+	trigger chan struct{}
 
 	mu         sync.RWMutex
 	lastResult *api.NATInfo
@@ -34,6 +47,46 @@ func NewDiscoverer(client STUNClient, cfg Config, localPort int, logger *slog.Lo
 		cfg:       cfg,
 		localPort: localPort,
 		logger:    logger,
+		trigger:   make(chan struct{}, 1),
+	}
+}
+
+// SetHolePuncher sets the puncher used to open NAT mappings toward peer
+// endpoints before they are applied. It must be called before Run. When
+// unset, peer endpoints are applied without punching.
+func (d *Discoverer) SetHolePuncher(p HolePuncher) {
+	d.puncher = p
+}
+
+// AdvertiseLAN makes every endpoint report include the host's private
+// addresses at the local port, so that peers on the same network can reach
+// the node directly. Interfaces named in exclude are skipped. It must be
+// called before Run.
+func (d *Discoverer) AdvertiseLAN(exclude ...string) {
+	d.advertiseLAN = true
+	d.lanExclude = exclude
+}
+
+// addLANEndpoints fills in result.LANEndpoints when LAN advertising is on.
+func (d *Discoverer) addLANEndpoints(result *DiscoveryResult) {
+	if !d.advertiseLAN {
+		return
+	}
+	eps, err := LANEndpoints(d.localPort, d.lanExclude...)
+	if err != nil {
+		d.logger.Warn("LAN endpoint enumeration failed", "component", "nat", "error", err)
+		return
+	}
+	result.LANEndpoints = eps
+}
+
+// TriggerDiscovery requests an immediate STUN re-detection, for example after
+// a local network change. Multiple calls before the loop picks up the signal
+// are coalesced into one. Safe for concurrent use.
+func (d *Discoverer) TriggerDiscovery() {
+	select {
+	case d.trigger <- struct{}{}:
+	default:
 	}
 }
 
@@ -120,14 +173,16 @@ func (d *Discoverer) LastResult() *api.NATInfo {
 }
 
 // Run performs initial STUN discovery, reports the endpoint, then enters a refresh loop.
+// A refresh runs every RefreshInterval and whenever TriggerDiscovery is called.
 // It blocks until ctx is cancelled or an unrecoverable error occurs.
 func (d *Discoverer) Run(ctx context.Context, reporter EndpointReporter, updater PeerUpdater, nodeID string) error {
 	result, err := d.Discover(ctx)
 	if err != nil {
 		return fmt.Errorf("nat: initial discovery: %w", err)
 	}
+	d.addLANEndpoints(result)
 
-	if err := reportAndApply(ctx, reporter, updater, nodeID, result, d.logger); err != nil {
+	if err := reportAndApply(ctx, reporter, updater, d.puncher, d.localPort, nodeID, result, d.logger); err != nil {
 		d.logger.Warn("endpoint report failed", "component", "nat", "error", err)
 	}
 
@@ -140,25 +195,30 @@ func (d *Discoverer) Run(ctx context.Context, reporter EndpointReporter, updater
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case <-d.trigger:
+			d.logger.Debug("STUN re-detection triggered", "component", "nat")
+			ticker.Reset(d.cfg.RefreshInterval)
 		case <-ticker.C:
-			result, err := d.Discover(ctx)
-			if err != nil {
-				d.logger.Warn("STUN refresh failed", "component", "nat", "error", err)
-				continue
-			}
-
-			if result.Endpoint != prevEndpoint {
-				d.logger.Info("endpoint changed",
-					"component", "nat",
-					"old_endpoint", prevEndpoint,
-					"new_endpoint", result.Endpoint,
-				)
-			}
-			prevEndpoint = result.Endpoint
-
-			if err := reportAndApply(ctx, reporter, updater, nodeID, result, d.logger); err != nil {
-				d.logger.Warn("endpoint report failed", "component", "nat", "error", err)
-			}
+		}
+
+		result, err := d.Discover(ctx)
+		if err != nil {
+			d.logger.Warn("STUN refresh failed", "component", "nat", "error", err)
+			continue
+		}
+
+		if result.Endpoint != prevEndpoint {
+			d.logger.Info("endpoint changed",
+				"component", "nat",
+				"old_endpoint", prevEndpoint,
+				"new_endpoint", result.Endpoint,
+			)
+		}
+		prevEndpoint = result.Endpoint
+		d.addLANEndpoints(result)
+
+		if err := reportAndApply(ctx, reporter, updater, d.puncher, d.localPort, nodeID, result, d.logger); err != nil {
+			d.logger.Warn("endpoint report failed", "component", "nat", "error", err)
 		}
 	}
 }
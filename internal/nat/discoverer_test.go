@@ -540,3 +540,69 @@ func TestRun_ReturnsErrorOnInitialDiscoveryFailure(t *testing.T) {
 		t.Errorf("expected 0 report calls, got %d", len(reporter.calls))
 	}
 }
+
+func TestRun_TriggerDiscovery(t *testing.T) {
+	addr := MappedAddress{IP: net.IPv4(203, 0, 113, 1), Port: 12345}
+	client := &sequenceMockSTUN{
+		results: []mockBindResult{
+			{Addr: addr},
+		},
+	}
+	reporter := &mockReporter{response: &api.EndpointResponse{}}
+	updater := &mockUpdater{}
+
+	cfg := Config{
+		Enabled:         true,
+		STUNServers:     []string{"stun1:3478"},
+		RefreshInterval: time.Hour,
+		Timeout:         5 * time.Second,
+	}
+	d := NewDiscoverer(client, cfg, 51820, discardLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- d.Run(ctx, reporter, updater, "node-1") }()
+
+	waitReports := func(want int) {
+		t.Helper()
+		deadline := time.After(2 * time.Second)
+		for {
+			reporter.mu.Lock()
+			n := len(reporter.calls)
+			reporter.mu.Unlock()
+			if n >= want {
+				return
+			}
+			select {
+			case <-deadline:
+				t.Fatalf("timed out waiting for %d report calls, got %d", want, n)
+			default:
+				time.Sleep(5 * time.Millisecond)
+			}
+		}
+	}
+
+	waitReports(1)
+	d.TriggerDiscovery()
+	waitReports(2)
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestTriggerDiscovery_Coalesces(t *testing.T) {
+	d := NewDiscoverer(&mockSTUNClient{}, Config{}, 51820, discardLogger())
+
+	// Multiple triggers before Run must not block.
+	d.TriggerDiscovery()
+	d.TriggerDiscovery()
+	d.TriggerDiscovery()
+
+	if n := len(d.trigger); n != 1 {
+		t.Errorf("pending triggers = %d, want 1", n)
+	}
+}
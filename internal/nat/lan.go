@@ -0,0 +1,36 @@
+package nat
+
+import (
+	"net"
+	"slices"
+	"strconv"
+)
+
+// LANEndpoints returns the host's private unicast addresses joined with
+// port, in interface order. Interfaces that are down, loopback, or named
+// in exclude (such as the WireGuard interface, whose mesh address is not
+// reachable without the tunnel) are skipped.
+func LANEndpoints(port int, exclude ...string) ([]string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var eps []string
+	for _, ifc := range ifaces {
+		if ifc.Flags&net.FlagUp == 0 || ifc.Flags&net.FlagLoopback != 0 || slices.Contains(exclude, ifc.Name) {
+			continue
+		}
+		addrs, err := ifc.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			ipn, ok := a.(*net.IPNet)
+			if !ok || !ipn.IP.IsPrivate() {
+				continue
+			}
+			eps = append(eps, net.JoinHostPort(ipn.IP.String(), strconv.Itoa(port)))
+		}
+	}
+	return eps, nil
+}
@@ -0,0 +1,46 @@
+package nat
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestLANEndpoints(t *testing.T) {
+	eps, err := LANEndpoints(51820)
+	if err != nil {
+		t.Fatalf("LANEndpoints: %v", err)
+	}
+	for _, ep := range eps {
+		host, port, err := net.SplitHostPort(ep)
+		if err != nil {
+			t.Fatalf("invalid endpoint %q: %v", ep, err)
+		}
+		if port != "51820" {
+			t.Errorf("endpoint %q: port = %s, want 51820", ep, port)
+		}
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsPrivate() || ip.IsLoopback() {
+			t.Errorf("endpoint %q is not a private address", ep)
+		}
+	}
+}
+
+func TestLANEndpoints_Exclude(t *testing.T) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatalf("net.Interfaces: %v", err)
+	}
+	names := make([]string, 0, len(ifaces))
+	for _, ifc := range ifaces {
+		names = append(names, ifc.Name)
+	}
+
+	eps, err := LANEndpoints(51820, names...)
+	if err != nil {
+		t.Fatalf("LANEndpoints: %v", err)
+	}
+	if len(eps) != 0 {
+		t.Errorf("expected no endpoints with all interfaces excluded (%s), got %v", strings.Join(names, ","), eps)
+	}
+}
@@ -68,3 +68,23 @@ func (nopWriter) Write(p []byte) (int, error) { return len(p), nil }
 func discardLogger() *slog.Logger {
 	return slog.New(slog.NewTextHandler(nopWriter{}, nil))
 }
+
+// mockPunchCall records a single Punch invocation.
+type mockPunchCall struct {
+	LocalPort  int
+	RemoteAddr string
+}
+
+// mockHolePuncher is a test double for HolePuncher.
+type mockHolePuncher struct {
+	mu    sync.Mutex
+	calls []mockPunchCall
+	err   error
+}
+
+func (m *mockHolePuncher) Punch(ctx context.Context, localPort int, remoteAddr string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, mockPunchCall{LocalPort: localPort, RemoteAddr: remoteAddr})
+	return m.err
+}
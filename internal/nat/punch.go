@@ -0,0 +1,61 @@
+package nat
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"time"
+)
+
+// HolePuncher abstracts UDP hole punching toward a peer endpoint for testability.
+type HolePuncher interface {
+	Punch(ctx context.Context, localPort int, remoteAddr string) error
+}
+
+// UDPHolePuncher opens a NAT mapping toward a peer by sending a short burst of
+// UDP packets from the WireGuard listen port. Both peers punch at the same
+// time because the control plane hands each of them the other's endpoint in
+// the same exchange round; the subsequent WireGuard handshake then traverses
+// the mappings created on both sides.
+type UDPHolePuncher struct {
+	// Attempts is the number of packets sent per punch.
+	Attempts int
+
+	// Interval is the delay between packets.
+	Interval time.Duration
+}
+
+// Punch sends Attempts packets from localPort to remoteAddr. Each packet is a
+// STUN Binding Request, which WireGuard silently discards.
+func (p *UDPHolePuncher) Punch(ctx context.Context, localPort int, remoteAddr string) error {
+	remote, err := net.ResolveUDPAddr("udp4", remoteAddr)
+	if err != nil {
+		return fmt.Errorf("nat: hole punch: resolve %q: %w", remoteAddr, err)
+	}
+
+	conn, err := net.DialUDP("udp4", &net.UDPAddr{Port: localPort}, remote)
+	if err != nil {
+		return fmt.Errorf("nat: hole punch: dial %q: %w", remoteAddr, err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < p.Attempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("nat: hole punch: %w", ctx.Err())
+			case <-time.After(p.Interval):
+			}
+		}
+
+		var txID [12]byte
+		if _, err := rand.Read(txID[:]); err != nil {
+			return fmt.Errorf("nat: hole punch: random tx id: %w", err)
+		}
+		if _, err := conn.Write(buildBindingRequest(txID)); err != nil {
+			return fmt.Errorf("nat: hole punch: write to %q: %w", remoteAddr, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package nat
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUDPHolePuncher_SendsAttempts(t *testing.T) {
+	remote, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer remote.Close()
+
+	p := &UDPHolePuncher{Attempts: 3, Interval: time.Millisecond}
+	if err := p.Punch(context.Background(), 0, remote.LocalAddr().String()); err != nil {
+		t.Fatalf("Punch: %v", err)
+	}
+
+	if err := remote.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	for i := 0; i < 3; i++ {
+		n, _, err := remote.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatalf("read packet %d: %v", i, err)
+		}
+		if n != 20 {
+			t.Errorf("packet %d length = %d, want 20", i, n)
+		}
+	}
+}
+
+func TestUDPHolePuncher_InvalidAddress(t *testing.T) {
+	p := &UDPHolePuncher{Attempts: 1}
+	if err := p.Punch(context.Background(), 0, "not-an-address"); err == nil {
+		t.Fatal("expected error for invalid address")
+	}
+}
+
+func TestUDPHolePuncher_ContextCancelled(t *testing.T) {
+	remote, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer remote.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := &UDPHolePuncher{Attempts: 3, Interval: time.Hour}
+	if err := p.Punch(ctx, 0, remote.LocalAddr().String()); err == nil {
+		t.Fatal("expected error for cancelled context")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"sync"
 
 	"github.com/plexsphere/plexd/internal/api"
 )
@@ -18,12 +19,38 @@ type PeerUpdater interface {
 	UpdatePeer(peer api.Peer) error
 }
 
+// maxConcurrentPunches bounds the number of peers punched in parallel, so a
+// refresh takes about HolePunchAttempts*HolePunchInterval per batch of peers
+// instead of per peer.
+const maxConcurrentPunches = 16
+
+// PathSelector is an optional PeerUpdater capability: instead of applying
+// the endpoint chosen here, every candidate endpoint of a peer is handed to
+// the updater, which probes them and programs the best one itself.
+// *pathsel.Selector satisfies this interface.
+type PathSelector interface {
+	SetPeerEndpoints(pe api.PeerEndpoint)
+}
+
 // reportAndApply reports the discovered endpoint to the control plane and applies
 // peer endpoint updates from the response.
-func reportAndApply(ctx context.Context, reporter EndpointReporter, updater PeerUpdater, nodeID string, result *DiscoveryResult, logger *slog.Logger) error {
+//
+// Behind a symmetric NAT the mapped port differs per destination, so direct
+// endpoints are unusable; the report requests a relay and peers are pointed at
+// their relay endpoint instead. Otherwise, when puncher is non-nil, a hole is
+// punched toward each direct endpoint before the peers are updated; up to
+// maxConcurrentPunches peers are punched in parallel.
+//
+// If updater is a PathSelector, the candidates are passed to it instead; a
+// direct public endpoint is left out behind a symmetric NAT.
+func reportAndApply(ctx context.Context, reporter EndpointReporter, updater PeerUpdater, puncher HolePuncher, localPort int, nodeID string, result *DiscoveryResult, logger *slog.Logger) error {
+	useRelay := result.NATType == NATSymmetric
+
 	resp, err := reporter.ReportEndpoint(ctx, nodeID, api.EndpointReport{
 		PublicEndpoint: result.Endpoint,
 		NATType:        string(result.NATType),
+		RelayRequested: useRelay,
+		LANEndpoints:   result.LANEndpoints,
 	})
 	if err != nil {
 		return fmt.Errorf("nat: report endpoint: %w", err)
@@ -32,18 +59,105 @@ func reportAndApply(ctx context.Context, reporter EndpointReporter, updater Peer
 		return nil
 	}
 
+	selector, selects := updater.(PathSelector)
+	if selects {
+		peers := make([]api.PeerEndpoint, 0, len(resp.PeerEndpoints))
+		var targets []api.PeerEndpoint
+		for _, pe := range resp.PeerEndpoints {
+			if useRelay {
+				pe.Endpoint = ""
+			}
+			if pe.Endpoint != "" {
+				targets = append(targets, pe)
+			}
+			peers = append(peers, pe)
+		}
+		punchAll(ctx, puncher, localPort, targets, logger)
+		for _, pe := range peers {
+			selector.SetPeerEndpoints(pe)
+		}
+		return nil
+	}
+
+	type update struct {
+		peerID   string
+		endpoint string
+		relayed  bool
+	}
+	var (
+		updates []update
+		targets []api.PeerEndpoint
+	)
 	for _, pe := range resp.PeerEndpoints {
-		if pe.Endpoint == "" {
+		endpoint := pe.Endpoint
+		relayed := false
+		if (useRelay || endpoint == "") && pe.RelayEndpoint != "" {
+			endpoint = pe.RelayEndpoint
+			relayed = true
+		}
+		if endpoint == "" {
 			continue
 		}
-		if err := updater.UpdatePeer(api.Peer{ID: pe.PeerID, Endpoint: pe.Endpoint}); err != nil {
+		if !relayed {
+			targets = append(targets, api.PeerEndpoint{PeerID: pe.PeerID, Endpoint: endpoint})
+		}
+		updates = append(updates, update{pe.PeerID, endpoint, relayed})
+	}
+	punchAll(ctx, puncher, localPort, targets, logger)
+
+	for _, u := range updates {
+		if err := updater.UpdatePeer(api.Peer{ID: u.peerID, Endpoint: u.endpoint}); err != nil {
 			logger.Warn("failed to update peer endpoint",
 				"component", "nat",
-				"peer_id", pe.PeerID,
+				"peer_id", u.peerID,
 				"error", err,
 			)
+			continue
+		}
+		if u.relayed {
+			logger.Info("peer routed via relay",
+				"component", "nat",
+				"peer_id", u.peerID,
+				"relay_endpoint", u.endpoint,
+			)
 		}
 	}
 
 	return nil
 }
+
+// punchAll punches toward the Endpoint of every target, up to
+// maxConcurrentPunches at a time, and returns when all punches are done.
+func punchAll(ctx context.Context, puncher HolePuncher, localPort int, targets []api.PeerEndpoint, logger *slog.Logger) {
+	if puncher == nil {
+		return
+	}
+	sem := make(chan struct{}, maxConcurrentPunches)
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			punch(ctx, puncher, localPort, t.PeerID, t.Endpoint, logger)
+		}()
+	}
+	wg.Wait()
+}
+
+// punch opens a NAT mapping toward endpoint if puncher is non-nil. Failures
+// are logged only: the endpoint may still work without it.
+func punch(ctx context.Context, puncher HolePuncher, localPort int, peerID, endpoint string, logger *slog.Logger) {
+	if puncher == nil {
+		return
+	}
+	if err := puncher.Punch(ctx, localPort, endpoint); err != nil {
+		logger.Debug("hole punch failed",
+			"component", "nat",
+			"peer_id", peerID,
+			"endpoint", endpoint,
+			"error", err,
+		)
+	}
+}
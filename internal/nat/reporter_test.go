@@ -3,8 +3,10 @@ package nat
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/plexsphere/plexd/internal/api"
 )
@@ -61,7 +63,7 @@ func TestReportAndApply_Success(t *testing.T) {
 	updater := &mockUpdater{}
 	logger := discardLogger()
 
-	err := reportAndApply(context.Background(), reporter, updater, "node-1", &DiscoveryResult{Endpoint: "9.8.7.6:51820", NATType: NATFullCone}, logger)
+	err := reportAndApply(context.Background(), reporter, updater, nil, 0, "node-1", &DiscoveryResult{Endpoint: "9.8.7.6:51820", NATType: NATFullCone}, logger)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -105,7 +107,7 @@ func TestReportAndApply_SkipsEmptyPeerEndpoint(t *testing.T) {
 	updater := &mockUpdater{}
 	logger := discardLogger()
 
-	err := reportAndApply(context.Background(), reporter, updater, "node-1", &DiscoveryResult{Endpoint: "9.8.7.6:51820", NATType: NATFullCone}, logger)
+	err := reportAndApply(context.Background(), reporter, updater, nil, 0, "node-1", &DiscoveryResult{Endpoint: "9.8.7.6:51820", NATType: NATFullCone}, logger)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -126,7 +128,7 @@ func TestReportAndApply_ReporterError(t *testing.T) {
 	updater := &mockUpdater{}
 	logger := discardLogger()
 
-	err := reportAndApply(context.Background(), reporter, updater, "node-1", &DiscoveryResult{Endpoint: "9.8.7.6:51820", NATType: NATFullCone}, logger)
+	err := reportAndApply(context.Background(), reporter, updater, nil, 0, "node-1", &DiscoveryResult{Endpoint: "9.8.7.6:51820", NATType: NATFullCone}, logger)
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -156,7 +158,7 @@ func TestReportAndApply_UpdaterErrorContinues(t *testing.T) {
 	}
 	logger := discardLogger()
 
-	err := reportAndApply(context.Background(), reporter, updater, "node-1", &DiscoveryResult{Endpoint: "9.8.7.6:51820", NATType: NATFullCone}, logger)
+	err := reportAndApply(context.Background(), reporter, updater, nil, 0, "node-1", &DiscoveryResult{Endpoint: "9.8.7.6:51820", NATType: NATFullCone}, logger)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -172,3 +174,224 @@ func TestReportAndApply_UpdaterErrorContinues(t *testing.T) {
 		t.Errorf("expected second call for peer-2, got %q", updater.calls[1].ID)
 	}
 }
+
+func TestReportAndApply_PunchesBeforeUpdate(t *testing.T) {
+	reporter := &mockReporter{
+		response: &api.EndpointResponse{
+			PeerEndpoints: []api.PeerEndpoint{
+				{PeerID: "peer-1", Endpoint: "1.2.3.4:51820"},
+			},
+		},
+	}
+	updater := &mockUpdater{}
+	puncher := &mockHolePuncher{err: errors.New("address in use")}
+
+	err := reportAndApply(context.Background(), reporter, updater, puncher, 51820, "node-1", &DiscoveryResult{Endpoint: "9.8.7.6:51820", NATType: NATFullCone}, discardLogger())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(puncher.calls) != 1 {
+		t.Fatalf("expected 1 punch call, got %d", len(puncher.calls))
+	}
+	if puncher.calls[0].LocalPort != 51820 || puncher.calls[0].RemoteAddr != "1.2.3.4:51820" {
+		t.Errorf("unexpected punch call: %+v", puncher.calls[0])
+	}
+	// A failed punch must not prevent the endpoint update.
+	if len(updater.calls) != 1 || updater.calls[0].Endpoint != "1.2.3.4:51820" {
+		t.Errorf("unexpected updater calls: %+v", updater.calls)
+	}
+	if reporter.calls[0].Report.RelayRequested {
+		t.Error("RelayRequested should be false for full cone NAT")
+	}
+}
+
+// slowHolePuncher takes delay per punch and records the highest number of
+// punches in flight.
+type slowHolePuncher struct {
+	delay time.Duration
+
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+	calls    int
+}
+
+func (s *slowHolePuncher) Punch(ctx context.Context, localPort int, remoteAddr string) error {
+	s.mu.Lock()
+	s.calls++
+	s.inFlight++
+	s.peak = max(s.peak, s.inFlight)
+	s.mu.Unlock()
+
+	time.Sleep(s.delay)
+
+	s.mu.Lock()
+	s.inFlight--
+	s.mu.Unlock()
+	return nil
+}
+
+func TestReportAndApply_PunchesPeersConcurrently(t *testing.T) {
+	const peers = 4 * maxConcurrentPunches
+	resp := &api.EndpointResponse{}
+	for i := range peers {
+		resp.PeerEndpoints = append(resp.PeerEndpoints, api.PeerEndpoint{
+			PeerID:   fmt.Sprintf("peer-%d", i),
+			Endpoint: fmt.Sprintf("1.2.3.%d:51820", i+1),
+		})
+	}
+	reporter := &mockReporter{response: resp}
+	updater := &mockUpdater{}
+	puncher := &slowHolePuncher{delay: 100 * time.Millisecond}
+
+	start := time.Now()
+	err := reportAndApply(context.Background(), reporter, updater, puncher, 51820, "node-1", &DiscoveryResult{Endpoint: "9.8.7.6:51820", NATType: NATFullCone}, discardLogger())
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Punched serially the refresh would take peers*delay (6.4s); in
+	// batches of maxConcurrentPunches it takes about 4*delay.
+	if elapsed > 2*time.Second {
+		t.Errorf("refresh took %v, want well under %v", elapsed, peers*puncher.delay)
+	}
+	if puncher.calls != peers {
+		t.Errorf("punch calls = %d, want %d", puncher.calls, peers)
+	}
+	if puncher.peak > maxConcurrentPunches {
+		t.Errorf("peak concurrent punches = %d, want at most %d", puncher.peak, maxConcurrentPunches)
+	}
+	if len(updater.calls) != peers {
+		t.Errorf("updater calls = %d, want %d", len(updater.calls), peers)
+	}
+}
+
+func TestReportAndApply_SymmetricNATUsesRelay(t *testing.T) {
+	reporter := &mockReporter{
+		response: &api.EndpointResponse{
+			PeerEndpoints: []api.PeerEndpoint{
+				{PeerID: "peer-1", Endpoint: "1.2.3.4:51820", RelayEndpoint: "198.51.100.10:51821"},
+				{PeerID: "peer-2", Endpoint: "5.6.7.8:51820"},
+			},
+		},
+	}
+	updater := &mockUpdater{}
+	puncher := &mockHolePuncher{}
+
+	err := reportAndApply(context.Background(), reporter, updater, puncher, 51820, "node-1", &DiscoveryResult{Endpoint: "9.8.7.6:40000", NATType: NATSymmetric}, discardLogger())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reporter.calls[0].Report.RelayRequested {
+		t.Error("RelayRequested should be true for symmetric NAT")
+	}
+	if len(updater.calls) != 2 {
+		t.Fatalf("expected 2 updater calls, got %d", len(updater.calls))
+	}
+	if updater.calls[0].Endpoint != "198.51.100.10:51821" {
+		t.Errorf("peer-1 endpoint = %q, want relay endpoint", updater.calls[0].Endpoint)
+	}
+	// Without a relay endpoint the direct endpoint is still attempted.
+	if updater.calls[1].Endpoint != "5.6.7.8:51820" {
+		t.Errorf("peer-2 endpoint = %q, want direct endpoint", updater.calls[1].Endpoint)
+	}
+	if len(puncher.calls) != 1 || puncher.calls[0].RemoteAddr != "5.6.7.8:51820" {
+		t.Errorf("expected a single punch toward peer-2, got %+v", puncher.calls)
+	}
+}
+
+func TestReportAndApply_RelayWhenPeerHasNoEndpoint(t *testing.T) {
+	reporter := &mockReporter{
+		response: &api.EndpointResponse{
+			PeerEndpoints: []api.PeerEndpoint{
+				{PeerID: "peer-1", RelayEndpoint: "198.51.100.10:51821"},
+			},
+		},
+	}
+	updater := &mockUpdater{}
+	puncher := &mockHolePuncher{}
+
+	err := reportAndApply(context.Background(), reporter, updater, puncher, 51820, "node-1", &DiscoveryResult{Endpoint: "9.8.7.6:51820", NATType: NATFullCone}, discardLogger())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(updater.calls) != 1 || updater.calls[0].Endpoint != "198.51.100.10:51821" {
+		t.Errorf("unexpected updater calls: %+v", updater.calls)
+	}
+	if len(puncher.calls) != 0 {
+		t.Errorf("relay endpoints must not be punched, got %+v", puncher.calls)
+	}
+}
+
+// mockSelector is a PeerUpdater with the PathSelector capability.
+type mockSelector struct {
+	mockUpdater
+	endpoints []api.PeerEndpoint
+}
+
+func (m *mockSelector) SetPeerEndpoints(pe api.PeerEndpoint) {
+	m.mu.Lock()
+	m.endpoints = append(m.endpoints, pe)
+	m.mu.Unlock()
+}
+
+func TestReportAndApply_PathSelector(t *testing.T) {
+	reporter := &mockReporter{
+		response: &api.EndpointResponse{
+			PeerEndpoints: []api.PeerEndpoint{
+				{PeerID: "peer-1", Endpoint: "1.2.3.4:51820", RelayEndpoint: "198.51.100.10:51821", LANEndpoints: []string{"192.168.1.5:51820"}},
+			},
+		},
+	}
+	selector := &mockSelector{}
+	puncher := &mockHolePuncher{}
+	result := &DiscoveryResult{Endpoint: "9.8.7.6:51820", NATType: NATFullCone, LANEndpoints: []string{"10.0.0.2:51820"}}
+
+	if err := reportAndApply(context.Background(), reporter, selector, puncher, 51820, "node-1", result, discardLogger()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := reporter.calls[0].Report.LANEndpoints; len(got) != 1 || got[0] != "10.0.0.2:51820" {
+		t.Errorf("reported LAN endpoints = %v", got)
+	}
+	if len(selector.calls) != 0 {
+		t.Errorf("UpdatePeer must not be called on a PathSelector, got %+v", selector.calls)
+	}
+	if len(selector.endpoints) != 1 {
+		t.Fatalf("expected 1 SetPeerEndpoints call, got %d", len(selector.endpoints))
+	}
+	pe := selector.endpoints[0]
+	if pe.Endpoint != "1.2.3.4:51820" || pe.RelayEndpoint != "198.51.100.10:51821" || len(pe.LANEndpoints) != 1 {
+		t.Errorf("unexpected candidates: %+v", pe)
+	}
+	if len(puncher.calls) != 1 || puncher.calls[0].RemoteAddr != "1.2.3.4:51820" {
+		t.Errorf("expected a single punch toward the public endpoint, got %+v", puncher.calls)
+	}
+}
+
+func TestReportAndApply_PathSelectorSymmetricNAT(t *testing.T) {
+	reporter := &mockReporter{
+		response: &api.EndpointResponse{
+			PeerEndpoints: []api.PeerEndpoint{
+				{PeerID: "peer-1", Endpoint: "1.2.3.4:51820", RelayEndpoint: "198.51.100.10:51821"},
+			},
+		},
+	}
+	selector := &mockSelector{}
+	puncher := &mockHolePuncher{}
+
+	if err := reportAndApply(context.Background(), reporter, selector, puncher, 51820, "node-1", &DiscoveryResult{Endpoint: "9.8.7.6:40000", NATType: NATSymmetric}, discardLogger()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(selector.endpoints) != 1 || selector.endpoints[0].Endpoint != "" || selector.endpoints[0].RelayEndpoint != "198.51.100.10:51821" {
+		t.Errorf("direct endpoint must be dropped behind a symmetric NAT, got %+v", selector.endpoints)
+	}
+	if len(puncher.calls) != 0 {
+		t.Errorf("expected no punches, got %+v", puncher.calls)
+	}
+}
@@ -0,0 +1,66 @@
+// Package netmon detects host network changes (address and default route
+// updates) so that plexd can re-detect its endpoint and re-establish
+// connectivity without waiting for the next timer.
+package netmon
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultDebounce is the default delay between the first change event and
+// notifying handlers. Events arriving within the window are coalesced.
+const DefaultDebounce = 2 * time.Second
+
+// DefaultIgnoreInterfacePrefixes lists interface name prefixes whose changes
+// are ignored by default: loopback and interfaces created by plexd itself.
+var DefaultIgnoreInterfacePrefixes = []string{"lo", "plexd", "wg-"}
+
+// Config holds the configuration for network change detection.
+type Config struct {
+	// Enabled controls whether network change detection is active.
+	// Default: true (set by ApplyDefaults).
+	Enabled bool
+
+	// Debounce is the delay between the first change event and notifying
+	// handlers. Must be between 100ms and 30s.
+	// Default: 2s
+	Debounce time.Duration
+
+	// IgnoreInterfacePrefixes lists interface name prefixes whose address
+	// and route changes are ignored.
+	// Default: ["lo", "plexd", "wg-"]
+	IgnoreInterfacePrefixes []string
+}
+
+// ApplyDefaults sets default values for zero-valued fields.
+// On a zero-valued Config, Enabled defaults to true.
+// To disable detection, set Enabled=false before or after calling ApplyDefaults.
+func (c *Config) ApplyDefaults() {
+	// Enabled defaults to true for zero-valued Config. Since bool zero is false,
+	// we use a heuristic: if all fields are zero, the caller wants defaults (including Enabled=true).
+	// If any field is non-zero, the caller constructed the config explicitly and we respect Enabled as-is.
+	if c.Debounce == 0 && c.IgnoreInterfacePrefixes == nil {
+		c.Enabled = true
+	}
+	if c.Debounce == 0 {
+		c.Debounce = DefaultDebounce
+	}
+	if c.IgnoreInterfacePrefixes == nil {
+		c.IgnoreInterfacePrefixes = append([]string{}, DefaultIgnoreInterfacePrefixes...)
+	}
+}
+
+// Validate checks that configuration values are within acceptable ranges.
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Debounce < 100*time.Millisecond {
+		return errors.New("netmon: config: Debounce must be at least 100ms")
+	}
+	if c.Debounce > 30*time.Second {
+		return errors.New("netmon: config: Debounce must be at most 30s")
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package netmon
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfig_Defaults(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+
+	if !cfg.Enabled {
+		t.Error("Enabled = false, want true")
+	}
+	if cfg.Debounce != DefaultDebounce {
+		t.Errorf("Debounce = %v, want %v", cfg.Debounce, DefaultDebounce)
+	}
+	if len(cfg.IgnoreInterfacePrefixes) != len(DefaultIgnoreInterfacePrefixes) {
+		t.Errorf("IgnoreInterfacePrefixes = %v, want %v", cfg.IgnoreInterfacePrefixes, DefaultIgnoreInterfacePrefixes)
+	}
+}
+
+func TestConfig_DefaultsPreserveExplicitDisabled(t *testing.T) {
+	cfg := Config{Enabled: false, Debounce: time.Second}
+	cfg.ApplyDefaults()
+
+	if cfg.Enabled {
+		t.Error("Enabled = true, want false when explicitly configured with other non-zero fields")
+	}
+}
+
+func TestConfig_DefaultsPreserveEmptyIgnoreList(t *testing.T) {
+	cfg := Config{Enabled: true, IgnoreInterfacePrefixes: []string{}}
+	cfg.ApplyDefaults()
+
+	if len(cfg.IgnoreInterfacePrefixes) != 0 {
+		t.Errorf("IgnoreInterfacePrefixes = %v, want empty", cfg.IgnoreInterfacePrefixes)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"defaults", Config{Enabled: true, Debounce: DefaultDebounce}, false},
+		{"disabled skips validation", Config{Enabled: false, Debounce: time.Nanosecond}, false},
+		{"debounce too short", Config{Enabled: true, Debounce: 10 * time.Millisecond}, true},
+		{"debounce too long", Config{Enabled: true, Debounce: time.Minute}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
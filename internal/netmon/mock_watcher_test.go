@@ -0,0 +1,32 @@
+package netmon
+
+import (
+	"context"
+	"log/slog"
+)
+
+// mockWatcher is a test double for Watcher. Events sent on ch are delivered
+// to the monitor; err is returned from Watch when set.
+type mockWatcher struct {
+	ch  chan Event
+	err error
+}
+
+func newMockWatcher() *mockWatcher {
+	return &mockWatcher{ch: make(chan Event, 16)}
+}
+
+func (m *mockWatcher) Watch(ctx context.Context) (<-chan Event, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.ch, nil
+}
+
+type nopWriter struct{}
+
+func (nopWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(nopWriter{}, nil))
+}
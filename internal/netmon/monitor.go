@@ -0,0 +1,102 @@
+package netmon
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Monitor watches for host network changes and notifies registered handlers,
+// debounced so that a burst of events (e.g. a Wi-Fi switch that removes an
+// address, adds a new one, and replaces the default route) results in a
+// single notification.
+type Monitor struct {
+	watcher  Watcher
+	cfg      Config
+	logger   *slog.Logger
+	handlers []func()
+}
+
+// NewMonitor creates a new Monitor. Config defaults are applied automatically.
+func NewMonitor(watcher Watcher, cfg Config, logger *slog.Logger) *Monitor {
+	cfg.ApplyDefaults()
+	return &Monitor{
+		watcher: watcher,
+		cfg:     cfg,
+		logger:  logger.With("component", "netmon"),
+	}
+}
+
+// OnChange registers fn to be called after a network change. Handlers are
+// called sequentially in registration order and should return quickly;
+// typical handlers only signal another loop (e.g. TriggerReconcile).
+// Must be called before Run; it is not safe for concurrent use.
+func (m *Monitor) OnChange(fn func()) {
+	m.handlers = append(m.handlers, fn)
+}
+
+// Run watches for network changes until ctx is cancelled. It returns nil
+// immediately when detection is disabled and an error if the watcher cannot
+// be started.
+func (m *Monitor) Run(ctx context.Context) error {
+	if !m.cfg.Enabled {
+		m.logger.Info("network change detection disabled")
+		return nil
+	}
+
+	events, err := m.watcher.Watch(ctx)
+	if err != nil {
+		return fmt.Errorf("netmon: watch: %w", err)
+	}
+
+	m.logger.Info("network change detection started", "debounce", m.cfg.Debounce.String())
+
+	var timer *time.Timer
+	var fire <-chan time.Time
+	pending := 0
+
+	for {
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return ctx.Err()
+		case ev, ok := <-events:
+			if !ok {
+				return ctx.Err()
+			}
+			if m.ignored(ev.Interface) {
+				continue
+			}
+			m.logger.Debug("network change event",
+				"kind", string(ev.Kind),
+				"interface", ev.Interface,
+				"detail", ev.Detail,
+			)
+			pending++
+			if timer == nil {
+				timer = time.NewTimer(m.cfg.Debounce)
+				fire = timer.C
+			}
+		case <-fire:
+			m.logger.Info("network change detected", "events", pending)
+			timer, fire, pending = nil, nil, 0
+			for _, fn := range m.handlers {
+				fn()
+			}
+		}
+	}
+}
+
+// ignored reports whether events on iface should be dropped.
+func (m *Monitor) ignored(iface string) bool {
+	for _, prefix := range m.cfg.IgnoreInterfacePrefixes {
+		if prefix != "" && strings.HasPrefix(iface, prefix) {
+			return true
+		}
+	}
+	return false
+}
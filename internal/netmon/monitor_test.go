@@ -0,0 +1,151 @@
+package netmon
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testConfig() Config {
+	return Config{
+		Enabled:                 true,
+		Debounce:                100 * time.Millisecond,
+		IgnoreInterfacePrefixes: []string{"plexd"},
+	}
+}
+
+func startMonitor(t *testing.T, m *Monitor) (context.CancelFunc, <-chan error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx) }()
+	return cancel, done
+}
+
+func waitCount(t *testing.T, n *atomic.Int32, want int32) {
+	t.Helper()
+	deadline := time.After(2 * time.Second)
+	for n.Load() < want {
+		select {
+		case <-deadline:
+			t.Fatalf("timed out waiting for %d notifications, got %d", want, n.Load())
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func TestMonitor_DebouncesBurst(t *testing.T) {
+	w := newMockWatcher()
+	m := NewMonitor(w, testConfig(), discardLogger())
+
+	var calls atomic.Int32
+	m.OnChange(func() { calls.Add(1) })
+
+	cancel, done := startMonitor(t, m)
+	defer cancel()
+
+	w.ch <- Event{Kind: EventAddress, Interface: "wlan0", Detail: "192.168.1.5/24"}
+	w.ch <- Event{Kind: EventAddress, Interface: "wlan0", Detail: "10.0.0.7/24"}
+	w.ch <- Event{Kind: EventRoute, Interface: "wlan0", Detail: "default"}
+
+	waitCount(t, &calls, 1)
+	time.Sleep(200 * time.Millisecond)
+	if got := calls.Load(); got != 1 {
+		t.Errorf("handler called %d times, want 1", got)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() = %v, want context.Canceled", err)
+	}
+}
+
+func TestMonitor_CallsAllHandlersInOrder(t *testing.T) {
+	w := newMockWatcher()
+	m := NewMonitor(w, testConfig(), discardLogger())
+
+	var order []int
+	var calls atomic.Int32
+	m.OnChange(func() { order = append(order, 1) })
+	m.OnChange(func() { order = append(order, 2); calls.Add(1) })
+
+	cancel, _ := startMonitor(t, m)
+	defer cancel()
+
+	w.ch <- Event{Kind: EventRoute, Interface: "eth0"}
+	waitCount(t, &calls, 1)
+
+	if len(order) != 2 || order[0] != 1 || order[1] != 2 {
+		t.Errorf("handler order = %v, want [1 2]", order)
+	}
+}
+
+func TestMonitor_SeparateBurstsNotifyTwice(t *testing.T) {
+	w := newMockWatcher()
+	m := NewMonitor(w, testConfig(), discardLogger())
+
+	var calls atomic.Int32
+	m.OnChange(func() { calls.Add(1) })
+
+	cancel, _ := startMonitor(t, m)
+	defer cancel()
+
+	w.ch <- Event{Kind: EventAddress, Interface: "eth0"}
+	waitCount(t, &calls, 1)
+	w.ch <- Event{Kind: EventAddress, Interface: "eth0"}
+	waitCount(t, &calls, 2)
+}
+
+func TestMonitor_IgnoresConfiguredInterfaces(t *testing.T) {
+	w := newMockWatcher()
+	m := NewMonitor(w, testConfig(), discardLogger())
+
+	var calls atomic.Int32
+	m.OnChange(func() { calls.Add(1) })
+
+	cancel, _ := startMonitor(t, m)
+	defer cancel()
+
+	w.ch <- Event{Kind: EventAddress, Interface: "plexd0", Detail: "10.42.0.1/16"}
+	time.Sleep(250 * time.Millisecond)
+	if got := calls.Load(); got != 0 {
+		t.Errorf("handler called %d times for ignored interface, want 0", got)
+	}
+}
+
+func TestMonitor_Disabled(t *testing.T) {
+	w := newMockWatcher()
+	cfg := testConfig()
+	cfg.Enabled = false
+	m := NewMonitor(w, cfg, discardLogger())
+
+	if err := m.Run(context.Background()); err != nil {
+		t.Errorf("Run() = %v, want nil when disabled", err)
+	}
+}
+
+func TestMonitor_WatchError(t *testing.T) {
+	w := newMockWatcher()
+	w.err = errors.New("permission denied")
+	m := NewMonitor(w, testConfig(), discardLogger())
+
+	err := m.Run(context.Background())
+	if err == nil {
+		t.Fatal("Run() = nil, want error")
+	}
+	if !errors.Is(err, w.err) {
+		t.Errorf("Run() = %v, want wrapped %v", err, w.err)
+	}
+}
+
+func TestMonitor_ClosedEventChannel(t *testing.T) {
+	w := newMockWatcher()
+	m := NewMonitor(w, testConfig(), discardLogger())
+
+	close(w.ch)
+	if err := m.Run(context.Background()); err != nil {
+		t.Errorf("Run() = %v, want nil after event channel closed", err)
+	}
+}
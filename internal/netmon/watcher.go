@@ -0,0 +1,28 @@
+package netmon
+
+import "context"
+
+// EventKind identifies the type of a network change.
+type EventKind string
+
+const (
+	// EventAddress is emitted when an address is added to or removed from an interface.
+	EventAddress EventKind = "address"
+
+	// EventRoute is emitted when a default route is added or removed.
+	EventRoute EventKind = "route"
+)
+
+// Event describes a single host network change.
+type Event struct {
+	Kind      EventKind
+	Interface string // interface name; empty if it could not be resolved
+	Detail    string // address or route destination, for logging
+}
+
+// Watcher abstracts the OS-level source of network change events for testability.
+// Watch returns a channel that receives events until ctx is cancelled, at
+// which point the channel is closed.
+type Watcher interface {
+	Watch(ctx context.Context) (<-chan Event, error)
+}
@@ -0,0 +1,99 @@
+//go:build linux
+
+package netmon
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// NetlinkWatcher implements Watcher by subscribing to rtnetlink address and
+// route multicast groups.
+type NetlinkWatcher struct {
+	logger *slog.Logger
+}
+
+// NewSystemWatcher returns the Watcher for the current platform. On Linux it
+// is a NetlinkWatcher.
+func NewSystemWatcher(logger *slog.Logger) Watcher {
+	return &NetlinkWatcher{logger: logger}
+}
+
+// Watch subscribes to address and route updates. Only default route changes
+// are reported; other route updates (including plexd's own subnet routes)
+// are dropped.
+func (w *NetlinkWatcher) Watch(ctx context.Context) (<-chan Event, error) {
+	done := make(chan struct{})
+	addrCh := make(chan netlink.AddrUpdate, 16)
+	routeCh := make(chan netlink.RouteUpdate, 16)
+
+	onErr := func(err error) {
+		w.logger.Warn("netmon: netlink subscription error", "component", "netmon", "error", err)
+	}
+
+	if err := netlink.AddrSubscribeWithOptions(addrCh, done, netlink.AddrSubscribeOptions{ErrorCallback: onErr}); err != nil {
+		close(done)
+		return nil, fmt.Errorf("netmon: subscribe addresses: %w", err)
+	}
+	if err := netlink.RouteSubscribeWithOptions(routeCh, done, netlink.RouteSubscribeOptions{ErrorCallback: onErr}); err != nil {
+		close(done)
+		return nil, fmt.Errorf("netmon: subscribe routes: %w", err)
+	}
+
+	out := make(chan Event, 16)
+	go func() {
+		defer close(out)
+		defer close(done)
+		for {
+			var ev Event
+			select {
+			case <-ctx.Done():
+				return
+			case u, ok := <-addrCh:
+				if !ok {
+					return
+				}
+				ev = Event{Kind: EventAddress, Interface: linkName(u.LinkIndex), Detail: u.LinkAddress.String()}
+			case u, ok := <-routeCh:
+				if !ok {
+					return
+				}
+				if !isDefaultRoute(u.Route) || (u.Type != unix.RTM_NEWROUTE && u.Type != unix.RTM_DELROUTE) {
+					continue
+				}
+				ev = Event{Kind: EventRoute, Interface: linkName(u.LinkIndex), Detail: "default"}
+			}
+			select {
+			case out <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// isDefaultRoute reports whether r is an IPv4 or IPv6 default route in the main table.
+func isDefaultRoute(r netlink.Route) bool {
+	if r.Table != 0 && r.Table != unix.RT_TABLE_MAIN {
+		return false
+	}
+	if r.Dst == nil {
+		return true
+	}
+	ones, _ := r.Dst.Mask.Size()
+	return ones == 0
+}
+
+// linkName resolves an interface index to its name, or "" if the link is gone.
+func linkName(index int) string {
+	link, err := netlink.LinkByIndex(index)
+	if err != nil {
+		return ""
+	}
+	return link.Attrs().Name
+}
@@ -0,0 +1,39 @@
+//go:build linux
+
+package netmon
+
+import (
+	"net"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// Compile-time check that NetlinkWatcher implements Watcher.
+var _ Watcher = (*NetlinkWatcher)(nil)
+
+func TestIsDefaultRoute(t *testing.T) {
+	_, v4Default, _ := net.ParseCIDR("0.0.0.0/0")
+	_, v6Default, _ := net.ParseCIDR("::/0")
+	_, subnet, _ := net.ParseCIDR("10.1.0.0/24")
+
+	tests := []struct {
+		name  string
+		route netlink.Route
+		want  bool
+	}{
+		{"nil dst", netlink.Route{}, true},
+		{"ipv4 default", netlink.Route{Dst: v4Default, Table: unix.RT_TABLE_MAIN}, true},
+		{"ipv6 default", netlink.Route{Dst: v6Default}, true},
+		{"subnet route", netlink.Route{Dst: subnet}, false},
+		{"default in other table", netlink.Route{Dst: v4Default, Table: 100}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDefaultRoute(tt.route); got != tt.want {
+				t.Errorf("isDefaultRoute() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
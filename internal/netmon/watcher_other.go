@@ -0,0 +1,22 @@
+//go:build !linux
+
+package netmon
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// unsupportedWatcher is returned on platforms without a network change source.
+type unsupportedWatcher struct{}
+
+// NewSystemWatcher returns the Watcher for the current platform. Network
+// change detection is only supported on Linux; elsewhere Watch returns an error.
+func NewSystemWatcher(logger *slog.Logger) Watcher {
+	return unsupportedWatcher{}
+}
+
+func (unsupportedWatcher) Watch(ctx context.Context) (<-chan Event, error) {
+	return nil, errors.New("netmon: network change detection is not supported on this platform")
+}
@@ -0,0 +1,129 @@
+//go:build linux && netns
+
+package netnstest_test
+
+import (
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/plexsphere/plexd/internal/bridge"
+	"github.com/plexsphere/plexd/internal/netnstest"
+)
+
+// TestBridge routes a mesh peer through the node to a host on the access
+// side: the mesh peer reaches 192.168.60.1 only through the route, the
+// forwarding, and the masquerade rule that Manager.Setup programs.
+//
+//	mesh peer 10.99.0.2 ── mesh0 10.99.0.1 [node] acc0 172.31.0.1 ── 172.31.0.2, 192.168.60.1 LAN host
+func TestBridge(t *testing.T) {
+	meshPeer := netnstest.New(t, "mesh-peer")
+	lan := netnstest.New(t, "lan")
+	netnstest.Veth(t, "mesh0", "10.99.0.1/24", meshPeer, "eth0", "10.99.0.2/24")
+	netnstest.Veth(t, "acc0", "172.31.0.1/30", lan, "eth0", "172.31.0.2/30")
+	lan.AddAddr(t, "eth0", "192.168.60.1/24")
+	meshPeer.AddRoute(t, "192.168.60.0/24", "10.99.0.1")
+	serveEcho(lan.Listen(t, "tcp", "192.168.60.1:8080"))
+
+	// Without bridge mode, the node has no route to the LAN.
+	if conn, err := meshPeer.Dial("tcp", "192.168.60.1:8080", dialTimeout); err == nil {
+		conn.Close()
+		t.Fatal("LAN host reachable before Setup")
+	}
+
+	cfg := bridge.Config{
+		Enabled:         true,
+		AccessInterface: "acc0",
+		AccessSubnets:   []string{"192.168.60.0/24"},
+	}
+	cfg.ApplyDefaults()
+	backend, err := bridge.NewBackend(cfg, discardLogger())
+	if err != nil {
+		t.Fatalf("NewBackend: %v", err)
+	}
+	mgr := bridge.NewManager(backend.Routes, cfg, discardLogger())
+	if err := mgr.Setup("mesh0"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	t.Cleanup(func() { mgr.Teardown() })
+
+	for _, iface := range []string{"mesh0", "acc0"} {
+		if got := forwarding(t, iface); got != "1" {
+			t.Errorf("forwarding on %s = %s, want 1", iface, got)
+		}
+	}
+	if !hasRoute(t, "192.168.60.0/24", "acc0") {
+		t.Fatal("route 192.168.60.0/24 dev acc0 not installed")
+	}
+
+	conn, err := meshPeer.Dial("tcp", "192.168.60.1:8080", dialTimeout)
+	if err != nil {
+		t.Fatalf("dial LAN host from mesh peer: %v", err)
+	}
+	echo(t, conn, "through the bridge")
+	conn.Close()
+
+	// A route deleted outside plexd is restored.
+	_, subnet, _ := net.ParseCIDR("192.168.60.0/24")
+	acc, err := netlink.LinkByName("acc0")
+	if err != nil {
+		t.Fatalf("look up acc0: %v", err)
+	}
+	if err := netlink.RouteDel(&netlink.Route{LinkIndex: acc.Attrs().Index, Dst: subnet, Scope: netlink.SCOPE_LINK}); err != nil {
+		t.Fatalf("delete route: %v", err)
+	}
+	corrections, err := mgr.CheckDrift()
+	if err != nil {
+		t.Fatalf("CheckDrift: %v", err)
+	}
+	if len(corrections) != 1 || corrections[0].Type != bridge.DriftRouteRestored {
+		t.Errorf("corrections = %+v, want one %s", corrections, bridge.DriftRouteRestored)
+	}
+	if !hasRoute(t, "192.168.60.0/24", "acc0") {
+		t.Error("route not restored by CheckDrift")
+	}
+
+	if err := mgr.Teardown(); err != nil {
+		t.Fatalf("Teardown: %v", err)
+	}
+	if hasRoute(t, "192.168.60.0/24", "acc0") {
+		t.Error("route still installed after Teardown")
+	}
+	if conn, err := meshPeer.Dial("tcp", "192.168.60.1:8080", dialTimeout); err == nil {
+		conn.Close()
+		t.Error("LAN host still reachable after Teardown")
+	}
+}
+
+// forwarding returns the IPv4 forwarding sysctl of iface in the node
+// namespace.
+func forwarding(t *testing.T, iface string) string {
+	t.Helper()
+	b, err := os.ReadFile("/proc/sys/net/ipv4/conf/" + iface + "/forwarding")
+	if err != nil {
+		t.Fatalf("read forwarding of %s: %v", iface, err)
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// hasRoute reports whether the node routes the CIDR subnet dst via iface.
+func hasRoute(t *testing.T, dst, iface string) bool {
+	t.Helper()
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		t.Fatalf("look up %s: %v", iface, err)
+	}
+	routes, err := netlink.RouteList(link, netlink.FAMILY_V4)
+	if err != nil {
+		t.Fatalf("list routes of %s: %v", iface, err)
+	}
+	for _, r := range routes {
+		if r.Dst != nil && r.Dst.String() == dst {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,17 @@
+// Package netnstest runs tests against real links, routes, nftables rules,
+// and WireGuard interfaces in network namespaces, so that regressions in the
+// netlink programming of the bridge managers are caught, which the mocks of
+// the unit tests cannot catch.
+//
+// The harness and its tests are built only with the netns build tag on
+// Linux, and need root (CAP_NET_ADMIN and CAP_SYS_ADMIN):
+//
+//	go test -exec sudo -tags netns -count=1 ./internal/netnstest/
+//
+// Main re-executes the test binary in a new network namespace, which stands
+// for the node: the managers under test program it like plexd up programs
+// the host, and the host itself is never touched. New creates further
+// namespaces for the peers of the node, such as a mesh peer, a LAN host
+// behind the access interface, or a remote site, and Veth connects them to
+// the node. Without the privileges to create namespaces, the tests skip.
+package netnstest
@@ -0,0 +1,87 @@
+//go:build linux && netns
+
+package netnstest_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/netnstest"
+	"github.com/plexsphere/plexd/internal/policy"
+)
+
+// TestEgress restricts the outbound traffic of the node to one of two hosts
+// behind its uplink, first in the monitor mode, then in the enforce mode.
+//
+//	[node] up0 10.95.0.1 ── 10.95.0.2, 10.95.0.3 remote
+func TestEgress(t *testing.T) {
+	remote := netnstest.New(t, "remote")
+	netnstest.Veth(t, "up0", "10.95.0.1/24", remote, "eth0", "10.95.0.2/24")
+	remote.AddAddr(t, "eth0", "10.95.0.3/24")
+	serveEcho(remote.Listen(t, "tcp", "10.95.0.2:8080"))
+	serveEcho(remote.Listen(t, "tcp", "10.95.0.3:8080"))
+
+	ctrl := policy.NewNftablesController(discardLogger())
+	enf := policy.NewEgressEnforcer(ctrl, policy.Config{}, "plexd0", 51820, discardLogger())
+	t.Cleanup(func() { enf.Teardown() })
+
+	dial := func(addr string) error {
+		conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		echo(t, conn, "egress to "+addr)
+		return nil
+	}
+
+	p := &api.EgressPolicy{
+		Mode:  policy.EgressModeMonitor,
+		Rules: []api.EgressRule{{Destination: "10.95.0.2", Port: 8080, Protocol: "tcp"}},
+	}
+	if err := enf.Apply(context.Background(), p); err != nil {
+		t.Fatalf("Apply monitor: %v", err)
+	}
+	if err := dial("10.95.0.2:8080"); err != nil {
+		t.Fatalf("dial allowed host in the monitor mode: %v", err)
+	}
+	if err := dial("10.95.0.3:8080"); err != nil {
+		t.Fatalf("dial disallowed host in the monitor mode: %v", err)
+	}
+	violations, err := ctrl.EgressViolations()
+	if err != nil {
+		t.Fatalf("EgressViolations: %v", err)
+	}
+	want := policy.EgressViolation{Destination: netip.MustParseAddr("10.95.0.3"), Protocol: "tcp", Port: 8080}
+	if !slices.Equal(violations, []policy.EgressViolation{want}) {
+		t.Errorf("violations = %+v, want %+v", violations, want)
+	}
+	if violations, err := ctrl.EgressViolations(); err != nil || len(violations) != 0 {
+		t.Errorf("violations after collecting = %+v, %v, want none", violations, err)
+	}
+
+	p.Mode = policy.EgressModeEnforce
+	if err := enf.Apply(context.Background(), p); err != nil {
+		t.Fatalf("Apply enforce: %v", err)
+	}
+	if err := dial("10.95.0.2:8080"); err != nil {
+		t.Fatalf("dial allowed host in the enforce mode: %v", err)
+	}
+	if err := dial("10.95.0.3:8080"); err == nil {
+		t.Error("dial disallowed host succeeded in the enforce mode")
+	}
+	if violations, err := ctrl.EgressViolations(); err != nil || !slices.Equal(violations, []policy.EgressViolation{want}) {
+		t.Errorf("violations = %+v, %v, want %+v", violations, err, want)
+	}
+
+	if err := enf.Teardown(); err != nil {
+		t.Fatalf("Teardown: %v", err)
+	}
+	if err := dial("10.95.0.3:8080"); err != nil {
+		t.Errorf("dial after Teardown: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+//go:build linux && netns
+
+package netnstest_test
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/bridge"
+	"github.com/plexsphere/plexd/internal/netnstest"
+)
+
+// socketIngress opens the listeners of ingress rules as plain sockets in
+// the node namespace.
+type socketIngress struct{}
+
+func (socketIngress) Listen(addr string, tlsCfg *tls.Config) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsCfg != nil {
+		return tls.NewListener(ln, tlsCfg), nil
+	}
+	return ln, nil
+}
+
+func (socketIngress) Close(ln net.Listener) error {
+	if err := ln.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+	return nil
+}
+
+func (socketIngress) ListenPacket(addr string) (net.PacketConn, error) {
+	return net.ListenPacket("udp", addr)
+}
+
+// TestIngress proxies TCP and UDP traffic from a client through ingress
+// rules of the node to a backend behind another link.
+//
+//	client 10.97.0.2 ── ing0 10.97.0.1 [node] be0 10.96.0.1 ── 10.96.0.2 backend
+func TestIngress(t *testing.T) {
+	client := netnstest.New(t, "client")
+	backend := netnstest.New(t, "backend")
+	netnstest.Veth(t, "ing0", "10.97.0.1/24", client, "eth0", "10.97.0.2/24")
+	netnstest.Veth(t, "be0", "10.96.0.1/24", backend, "eth0", "10.96.0.2/24")
+	serveEcho(backend.Listen(t, "tcp", "10.96.0.2:8080"))
+	serveUDPEcho(backend.ListenPacket(t, "udp", "10.96.0.2:5353"))
+
+	// UDP sessions stay open until they idle out; do not wait for them on
+	// Teardown.
+	cfg := bridge.Config{Enabled: true, IngressEnabled: true, IngressDrainTimeout: 100 * time.Millisecond}
+	cfg.ApplyDefaults()
+	mgr := bridge.NewIngressManager(socketIngress{}, cfg, discardLogger())
+	if err := mgr.Setup(); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	t.Cleanup(func() { mgr.Teardown() })
+
+	rules := []api.IngressRule{
+		{RuleID: "web", ListenPort: 8443, TargetAddr: "10.96.0.2:8080", Mode: "tcp"},
+		{RuleID: "dns", ListenPort: 5353, TargetAddr: "10.96.0.2:5353", Mode: "udp"},
+	}
+	for _, r := range rules {
+		if err := mgr.AddRule(r); err != nil {
+			t.Fatalf("AddRule %s: %v", r.RuleID, err)
+		}
+	}
+
+	conn, err := client.Dial("tcp", "10.97.0.1:8443", dialTimeout)
+	if err != nil {
+		t.Fatalf("dial tcp rule from client: %v", err)
+	}
+	echo(t, conn, "through the tcp rule")
+	conn.Close()
+
+	udp, err := client.Dial("udp", "10.97.0.1:5353", dialTimeout)
+	if err != nil {
+		t.Fatalf("dial udp rule from client: %v", err)
+	}
+	defer udp.Close()
+	udp.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := udp.Write([]byte("through the udp rule")); err != nil {
+		t.Fatalf("write udp: %v", err)
+	}
+	buf := make([]byte, 64)
+	n, err := udp.Read(buf)
+	if err != nil || string(buf[:n]) != "through the udp rule" {
+		t.Fatalf("udp echo = %q, %v", buf[:n], err)
+	}
+
+	if status := mgr.IngressStatus(); status.RuleCount != 2 {
+		t.Errorf("RuleCount = %d, want 2", status.RuleCount)
+	}
+
+	// A removed rule stops accepting at once.
+	mgr.RemoveRule("web")
+	if conn, err := client.Dial("tcp", "10.97.0.1:8443", dialTimeout); err == nil {
+		conn.Close()
+		t.Error("tcp rule still accepts after RemoveRule")
+	}
+	if _, err := mgr.Teardown(); err != nil {
+		t.Fatalf("Teardown: %v", err)
+	}
+}
+
+// serveUDPEcho echoes every datagram received on pc until pc is closed.
+func serveUDPEcho(pc net.PacketConn) {
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(buf[:n], addr)
+		}
+	}()
+}
@@ -0,0 +1,122 @@
+//go:build linux && netns
+
+package netnstest_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/plexsphere/plexd/internal/bridge"
+	"github.com/plexsphere/plexd/internal/netnstest"
+)
+
+// TestL2Extension bridges a remote host into a local bridge through the
+// VXLAN of a layer-2 extension. The veth underlay stands in for the tunnel.
+//
+//	[node] br-l2 172.30.0.1/24 ── s2s-vx42 ── l2-u 10.98.1.1 ── 10.98.1.2 remote
+//	                                                            vx0 172.30.0.2/24 remote
+func TestL2Extension(t *testing.T) {
+	netnstest.Require(t)
+	remote := netnstest.New(t, "remote")
+	netnstest.Veth(t, "l2-u", "10.98.1.1/24", remote, "eth0", "10.98.1.2/24")
+	addBridge(t, "br-l2", "172.30.0.1/24")
+	if err := remote.Do(func() error {
+		la := netlink.NewLinkAttrs()
+		la.Name = "vx0"
+		return netlink.LinkAdd(&netlink.Vxlan{
+			LinkAttrs: la,
+			VxlanId:   42,
+			SrcAddr:   net.ParseIP("10.98.1.2"),
+			Group:     net.ParseIP("10.98.1.1"),
+			Port:      bridge.VXLANPort,
+		})
+	}); err != nil {
+		t.Fatalf("add remote vxlan: %v", err)
+	}
+	remote.AddAddr(t, "vx0", "172.30.0.2/24")
+	if err := remote.Do(func() error {
+		link, err := netlink.LinkByName("vx0")
+		if err != nil {
+			return err
+		}
+		return netlink.LinkSetUp(link)
+	}); err != nil {
+		t.Fatalf("set remote vxlan up: %v", err)
+	}
+	serveEcho(remote.Listen(t, "tcp", "172.30.0.2:8080"))
+
+	cfg := bridge.Config{Enabled: true}
+	cfg.ApplyDefaults()
+	backend, err := bridge.NewBackend(cfg, discardLogger())
+	if err != nil {
+		t.Fatalf("NewBackend: %v", err)
+	}
+	ext := bridge.L2Extension{VNI: 42, Bridge: "br-l2", Local: "10.98.1.1", Remote: "10.98.1.2", Underlay: "l2-u"}
+	if err := backend.L2.CreateL2Interface("s2s-vx42", ext); err != nil {
+		t.Fatalf("CreateL2Interface: %v", err)
+	}
+	// Creating it again is a no-op.
+	if err := backend.L2.CreateL2Interface("s2s-vx42", ext); err != nil {
+		t.Fatalf("CreateL2Interface again: %v", err)
+	}
+	vx, err := netlink.LinkByName("s2s-vx42")
+	if err != nil {
+		t.Fatalf("look up s2s-vx42: %v", err)
+	}
+	if ul, _ := netlink.LinkByName("l2-u"); vx.Attrs().MTU != ul.Attrs().MTU-50 {
+		t.Errorf("MTU = %d, want %d", vx.Attrs().MTU, ul.Attrs().MTU-50)
+	}
+
+	conn, err := net.DialTimeout("tcp", "172.30.0.2:8080", dialTimeout)
+	if err != nil {
+		t.Fatalf("dial remote host across the layer-2 extension: %v", err)
+	}
+	echo(t, conn, "across the layer-2 extension")
+	conn.Close()
+
+	macs, err := backend.L2.L2MACCount("s2s-vx42")
+	if err != nil {
+		t.Fatalf("L2MACCount: %v", err)
+	}
+	if macs != 1 {
+		t.Errorf("L2MACCount = %d, want 1 for the remote host", macs)
+	}
+	if err := backend.L2.SetL2Learning("s2s-vx42", false); err != nil {
+		t.Fatalf("SetL2Learning: %v", err)
+	}
+
+	if err := backend.L2.RemoveL2Interface("s2s-vx42"); err != nil {
+		t.Fatalf("RemoveL2Interface: %v", err)
+	}
+	if _, err := netlink.LinkByName("s2s-vx42"); err == nil {
+		t.Error("s2s-vx42 not removed")
+	}
+	if err := backend.L2.RemoveL2Interface("s2s-vx42"); err != nil {
+		t.Errorf("RemoveL2Interface again: %v", err)
+	}
+}
+
+// addBridge creates the bridge name with the CIDR address addr in the node
+// namespace.
+func addBridge(t *testing.T, name, addr string) {
+	t.Helper()
+	la := netlink.NewLinkAttrs()
+	la.Name = name
+	br := &netlink.Bridge{LinkAttrs: la}
+	if err := netlink.LinkAdd(br); err != nil {
+		t.Fatalf("add bridge %s: %v", name, err)
+	}
+	t.Cleanup(func() { netlink.LinkDel(br) })
+	a, err := netlink.ParseAddr(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := netlink.AddrAdd(br, a); err != nil {
+		t.Fatalf("add address %s to %s: %v", addr, name, err)
+	}
+	if err := netlink.LinkSetUp(br); err != nil {
+		t.Fatalf("set %s up: %v", name, err)
+	}
+}
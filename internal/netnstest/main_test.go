@@ -0,0 +1,58 @@
+//go:build linux && netns
+
+package netnstest_test
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/netnstest"
+)
+
+func TestMain(m *testing.M) {
+	netnstest.Main(m)
+}
+
+// dialTimeout bounds connection attempts that are expected to fail.
+const dialTimeout = 2 * time.Second
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// serveEcho echoes every line received on the connections accepted by ln
+// until ln is closed.
+func serveEcho(ln net.Listener) {
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+}
+
+// echo sends msg on conn and fails t unless it is echoed back.
+func echo(t *testing.T, conn net.Conn, msg string) {
+	t.Helper()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.WriteString(conn, msg+"\n"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("read echo: %v", err)
+	}
+	if line != msg+"\n" {
+		t.Fatalf("echo = %q, want %q", line, msg+"\n")
+	}
+}
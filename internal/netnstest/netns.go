@@ -0,0 +1,358 @@
+//go:build linux && netns
+
+package netnstest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// childEnv marks the test binary re-executed by Main in the node namespace.
+const childEnv = "PLEXD_NETNSTEST_CHILD"
+
+// unavailableEnv holds why Main could not create the node namespace; the
+// tests then skip.
+const unavailableEnv = "PLEXD_NETNSTEST_UNAVAILABLE"
+
+// Main runs the tests of the package in a new network namespace and exits.
+// It must be called from TestMain. Every thread of the re-executed test
+// binary is in the node namespace, so managers can start goroutines that
+// program links and routes without reaching the host.
+func Main(m *testing.M) {
+	if os.Getenv(childEnv) != "" {
+		if err := setUp("lo"); err != nil {
+			fmt.Fprintf(os.Stderr, "netnstest: bring up lo: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(m.Run())
+	}
+
+	cmd := exec.Command("/proc/self/exe", os.Args[1:]...)
+	cmd.Env = append(os.Environ(), childEnv+"=1")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWNET}
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		os.Exit(0)
+	case errors.As(err, &exitErr):
+		os.Exit(exitErr.ExitCode())
+	}
+	// Without the privileges to create a namespace, the tests run in the
+	// host namespace and skip.
+	os.Setenv(unavailableEnv, err.Error())
+	os.Exit(m.Run())
+}
+
+// Require skips t unless it runs in the node namespace created by Main.
+func Require(t testing.TB) {
+	t.Helper()
+	if os.Getenv(childEnv) == "" {
+		t.Skipf("network namespaces unavailable (run as root): %s", os.Getenv(unavailableEnv))
+	}
+}
+
+// RequireWireGuard skips t unless the kernel supports WireGuard interfaces.
+func RequireWireGuard(t testing.TB) {
+	t.Helper()
+	Require(t)
+	la := netlink.NewLinkAttrs()
+	la.Name = "wgprobe0"
+	link := &netlink.GenericLink{LinkAttrs: la, LinkType: "wireguard"}
+	if err := netlink.LinkAdd(link); err != nil {
+		t.Skipf("kernel WireGuard unavailable: %v", err)
+	}
+	if err := netlink.LinkDel(link); err != nil {
+		t.Fatalf("delete WireGuard probe interface: %v", err)
+	}
+}
+
+// Namespace is a network namespace of a peer of the node.
+type Namespace struct {
+	// Name names the namespace in test failures.
+	Name string
+
+	handle netns.NsHandle
+}
+
+// New creates a network namespace with lo up. It is deleted, with the
+// links in it, when the test ends.
+func New(t testing.TB, name string) *Namespace {
+	t.Helper()
+	Require(t)
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	node, err := netns.Get()
+	if err != nil {
+		t.Fatalf("netnstest: %s: get node namespace: %v", name, err)
+	}
+	defer node.Close()
+	handle, err := netns.New()
+	if err != nil {
+		t.Fatalf("netnstest: %s: create namespace: %v", name, err)
+	}
+	if err := netns.Set(node); err != nil {
+		// The thread is left in the new namespace; terminate it with the
+		// goroutine rather than returning it to the scheduler.
+		runtime.LockOSThread()
+		t.Fatalf("netnstest: %s: return to node namespace: %v", name, err)
+	}
+	ns := &Namespace{Name: name, handle: handle}
+	t.Cleanup(func() { handle.Close() })
+
+	if err := ns.Do(func() error { return setUp("lo") }); err != nil {
+		t.Fatalf("netnstest: %s: bring up lo: %v", name, err)
+	}
+	return ns
+}
+
+// Do calls fn on a thread in the namespace. Sockets and links fn creates
+// stay in the namespace, but goroutines fn starts run in the node
+// namespace.
+func (ns *Namespace) Do(fn func() error) error {
+	runtime.LockOSThread()
+	node, err := netns.Get()
+	if err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("netnstest: get node namespace: %w", err)
+	}
+	defer node.Close()
+	if err := netns.Set(ns.handle); err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("netnstest: enter %s: %w", ns.Name, err)
+	}
+	fnErr := fn()
+	if err := netns.Set(node); err != nil {
+		// Keep the thread locked so that it terminates with the goroutine.
+		return fmt.Errorf("netnstest: leave %s: %w", ns.Name, err)
+	}
+	runtime.UnlockOSThread()
+	return fnErr
+}
+
+// must calls fn in ns and fails t on error.
+func (ns *Namespace) must(t testing.TB, fn func() error) {
+	t.Helper()
+	if err := ns.Do(fn); err != nil {
+		t.Fatalf("netnstest: %s: %v", ns.Name, err)
+	}
+}
+
+// Veth connects the node to ns with a veth pair: name with the CIDR address
+// addr in the node namespace, and peer with peerAddr in ns. Both ends are up.
+// An empty address is not assigned.
+func Veth(t testing.TB, name, addr string, ns *Namespace, peer, peerAddr string) {
+	t.Helper()
+	Require(t)
+	la := netlink.NewLinkAttrs()
+	la.Name = name
+	if err := netlink.LinkAdd(&netlink.Veth{LinkAttrs: la, PeerName: peer}); err != nil {
+		t.Fatalf("netnstest: add veth %s/%s: %v", name, peer, err)
+	}
+	t.Cleanup(func() {
+		if link, err := netlink.LinkByName(name); err == nil {
+			netlink.LinkDel(link)
+		}
+	})
+	peerLink, err := netlink.LinkByName(peer)
+	if err != nil {
+		t.Fatalf("netnstest: look up %s: %v", peer, err)
+	}
+	if err := netlink.LinkSetNsFd(peerLink, int(ns.handle)); err != nil {
+		t.Fatalf("netnstest: move %s to %s: %v", peer, ns.Name, err)
+	}
+	if err := configure(name, addr); err != nil {
+		t.Fatalf("netnstest: %v", err)
+	}
+	ns.must(t, func() error { return configure(peer, peerAddr) })
+}
+
+// Dummy creates the dummy link name with the CIDR address addr in the node
+// namespace, for addresses that are not bound to a peer.
+func Dummy(t testing.TB, name, addr string) {
+	t.Helper()
+	Require(t)
+	la := netlink.NewLinkAttrs()
+	la.Name = name
+	if err := netlink.LinkAdd(&netlink.Dummy{LinkAttrs: la}); err != nil {
+		t.Fatalf("netnstest: add dummy %s: %v", name, err)
+	}
+	t.Cleanup(func() {
+		if link, err := netlink.LinkByName(name); err == nil {
+			netlink.LinkDel(link)
+		}
+	})
+	if err := configure(name, addr); err != nil {
+		t.Fatalf("netnstest: %v", err)
+	}
+}
+
+// AddAddr assigns the CIDR address addr to the link name in ns.
+func (ns *Namespace) AddAddr(t testing.TB, name, addr string) {
+	t.Helper()
+	ns.must(t, func() error { return addAddr(name, addr) })
+}
+
+// AddRoute adds a route to the CIDR subnet dst via the gateway gw in ns.
+func (ns *Namespace) AddRoute(t testing.TB, dst, gw string) {
+	t.Helper()
+	ns.must(t, func() error {
+		_, subnet, err := net.ParseCIDR(dst)
+		if err != nil {
+			return err
+		}
+		if err := netlink.RouteAdd(&netlink.Route{Dst: subnet, Gw: net.ParseIP(gw)}); err != nil {
+			return fmt.Errorf("add route %s via %s: %w", dst, gw, err)
+		}
+		return nil
+	})
+}
+
+// AddLinkRoute adds a route to the CIDR subnet dst through the link dev in
+// ns.
+func (ns *Namespace) AddLinkRoute(t testing.TB, dst, dev string) {
+	t.Helper()
+	ns.must(t, func() error {
+		_, subnet, err := net.ParseCIDR(dst)
+		if err != nil {
+			return err
+		}
+		link, err := netlink.LinkByName(dev)
+		if err != nil {
+			return fmt.Errorf("look up %s: %w", dev, err)
+		}
+		route := &netlink.Route{Dst: subnet, LinkIndex: link.Attrs().Index, Scope: netlink.SCOPE_LINK}
+		if err := netlink.RouteAdd(route); err != nil {
+			return fmt.Errorf("add route %s dev %s: %w", dst, dev, err)
+		}
+		return nil
+	})
+}
+
+// Listen announces on the local network address in ns. The listener is
+// closed when the test ends.
+func (ns *Namespace) Listen(t testing.TB, network, addr string) net.Listener {
+	t.Helper()
+	var ln net.Listener
+	ns.must(t, func() (err error) {
+		ln, err = net.Listen(network, addr)
+		return err
+	})
+	t.Cleanup(func() { ln.Close() })
+	return ln
+}
+
+// ListenPacket announces on the local network address in ns. The
+// connection is closed when the test ends.
+func (ns *Namespace) ListenPacket(t testing.TB, network, addr string) net.PacketConn {
+	t.Helper()
+	var pc net.PacketConn
+	ns.must(t, func() (err error) {
+		pc, err = net.ListenPacket(network, addr)
+		return err
+	})
+	t.Cleanup(func() { pc.Close() })
+	return pc
+}
+
+// Dial connects to addr from ns.
+func (ns *Namespace) Dial(network, addr string, timeout time.Duration) (net.Conn, error) {
+	var conn net.Conn
+	err := ns.Do(func() (err error) {
+		conn, err = net.DialTimeout(network, addr, timeout)
+		return err
+	})
+	return conn, err
+}
+
+// WireGuard creates the WireGuard interface name with the CIDR address addr
+// in ns, listening on port. It returns the public key of the interface.
+func (ns *Namespace) WireGuard(t testing.TB, name, addr string, port int) wgtypes.Key {
+	t.Helper()
+	key, err := wgtypes.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("netnstest: generate key: %v", err)
+	}
+	ns.must(t, func() error {
+		la := netlink.NewLinkAttrs()
+		la.Name = name
+		if err := netlink.LinkAdd(&netlink.GenericLink{LinkAttrs: la, LinkType: "wireguard"}); err != nil {
+			return fmt.Errorf("add %s: %w", name, err)
+		}
+		if err := configureDevice(name, wgtypes.Config{PrivateKey: &key, ListenPort: &port}); err != nil {
+			return err
+		}
+		return configure(name, addr)
+	})
+	return key.PublicKey()
+}
+
+// AddPeer adds peer to the WireGuard interface name in ns.
+func (ns *Namespace) AddPeer(t testing.TB, name string, peer wgtypes.PeerConfig) {
+	t.Helper()
+	ns.must(t, func() error {
+		return configureDevice(name, wgtypes.Config{Peers: []wgtypes.PeerConfig{peer}})
+	})
+}
+
+func configureDevice(name string, cfg wgtypes.Config) error {
+	client, err := wgctrl.New()
+	if err != nil {
+		return fmt.Errorf("open wgctrl: %w", err)
+	}
+	defer client.Close()
+	if err := client.ConfigureDevice(name, cfg); err != nil {
+		return fmt.Errorf("configure %s: %w", name, err)
+	}
+	return nil
+}
+
+// configure assigns addr, unless empty, to the link name and brings it up.
+func configure(name, addr string) error {
+	if addr != "" {
+		if err := addAddr(name, addr); err != nil {
+			return err
+		}
+	}
+	return setUp(name)
+}
+
+func addAddr(name, addr string) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return fmt.Errorf("look up %s: %w", name, err)
+	}
+	a, err := netlink.ParseAddr(addr)
+	if err != nil {
+		return fmt.Errorf("parse address %s: %w", addr, err)
+	}
+	if err := netlink.AddrAdd(link, a); err != nil {
+		return fmt.Errorf("add address %s to %s: %w", addr, name, err)
+	}
+	return nil
+}
+
+func setUp(name string) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return fmt.Errorf("look up %s: %w", name, err)
+	}
+	if err := netlink.LinkSetUp(link); err != nil {
+		return fmt.Errorf("set %s up: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+//go:build linux && netns
+
+package netnstest_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/plexsphere/plexd/internal/netnstest"
+	"github.com/plexsphere/plexd/internal/policy"
+)
+
+// TestPolicyHostDestination allows forwarded mesh traffic to a hostname
+// only: the node resolves app.example.com through a local nameserver and
+// allows the address it answers, then follows the answer when it changes.
+//
+//	mesh peer 10.98.0.2 ── mesh0 10.98.0.1 [node] up0 10.97.0.1 ── 10.97.0.2, 10.97.0.3 remote
+func TestPolicyHostDestination(t *testing.T) {
+	meshPeer := netnstest.New(t, "mesh-peer")
+	remote := netnstest.New(t, "remote")
+	netnstest.Veth(t, "mesh0", "10.98.0.1/24", meshPeer, "eth0", "10.98.0.2/24")
+	netnstest.Veth(t, "up0", "10.97.0.1/24", remote, "eth0", "10.97.0.2/24")
+	remote.AddAddr(t, "eth0", "10.97.0.3/24")
+	meshPeer.AddRoute(t, "10.97.0.0/24", "10.98.0.1")
+	remote.AddRoute(t, "10.98.0.0/24", "10.97.0.1")
+	serveEcho(remote.Listen(t, "tcp", "10.97.0.2:8080"))
+	serveEcho(remote.Listen(t, "tcp", "10.97.0.3:8080"))
+	if err := os.WriteFile("/proc/sys/net/ipv4/ip_forward", []byte("1"), 0o644); err != nil {
+		t.Fatalf("enable forwarding: %v", err)
+	}
+
+	var answer atomic.Value
+	answer.Store(netip.MustParseAddr("10.97.0.2"))
+	nameserver := serveDNS(t, "app.example.com.", &answer)
+
+	ctrl := policy.NewNftablesController(discardLogger())
+	resolver := policy.NewHostResolver(ctrl, policy.Config{DNSMinTTL: time.Second}, discardLogger())
+	resolver.SetLookup(policy.NewDNSLookup(nameserver))
+	chain := "plexd-mesh"
+	if err := ctrl.EnsureChain(chain); err != nil {
+		t.Fatalf("EnsureChain: %v", err)
+	}
+	t.Cleanup(func() {
+		ctrl.DeleteChain(chain)
+		resolver.Teardown()
+	})
+
+	rules := []policy.FirewallRule{
+		{Interface: "mesh0", DstHost: "app.example.com", Port: 8080, Protocol: "tcp", Action: "allow"},
+		{Interface: "mesh0", SrcIP: "0.0.0.0/0", DstIP: "0.0.0.0/0", Action: "deny"},
+	}
+	if err := resolver.Track([]string{"app.example.com"}); err != nil {
+		t.Fatalf("Track: %v", err)
+	}
+	if err := ctrl.ApplyRules(chain, rules); err != nil {
+		t.Fatalf("ApplyRules: %v", err)
+	}
+
+	dial := func(addr string) error {
+		conn, err := meshPeer.Dial("tcp", addr, dialTimeout)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		echo(t, conn, "mesh peer to "+addr)
+		return nil
+	}
+	if err := dial("10.97.0.2:8080"); err != nil {
+		t.Fatalf("dial resolved address: %v", err)
+	}
+	if err := dial("10.97.0.3:8080"); err == nil {
+		t.Fatal("dial unresolved address succeeded")
+	}
+
+	// Once the TTL expires, the changed answer is added to the set; the
+	// old address is retained for clients that cached it.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go resolver.Run(ctx)
+	answer.Store(netip.MustParseAddr("10.97.0.3"))
+	want := []netip.Addr{netip.MustParseAddr("10.97.0.2"), netip.MustParseAddr("10.97.0.3")}
+	deadline := time.Now().Add(5 * time.Second)
+	for !slices.Equal(resolver.Addresses("app.example.com"), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Addresses = %v, want %v", resolver.Addresses("app.example.com"), want)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	for _, addr := range []string{"10.97.0.2:8080", "10.97.0.3:8080"} {
+		if err := dial(addr); err != nil {
+			t.Fatalf("dial %s after the refresh: %v", addr, err)
+		}
+	}
+
+	// Without a rule for the host, its set is removed.
+	if err := ctrl.ApplyRules(chain, rules[1:]); err != nil {
+		t.Fatalf("ApplyRules without the host: %v", err)
+	}
+	if err := resolver.Retain(nil); err != nil {
+		t.Fatalf("Retain: %v", err)
+	}
+	if got := resolver.Addresses("app.example.com"); got != nil {
+		t.Errorf("Addresses after Retain = %v, want none", got)
+	}
+	if err := dial("10.97.0.3:8080"); err == nil {
+		t.Error("dial succeeded after the host rule was removed")
+	}
+}
+
+// serveDNS answers A queries for name with the address in answer and
+// returns the address of the nameserver.
+func serveDNS(t *testing.T, name string, answer *atomic.Value) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen dns: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) != 1 {
+				continue
+			}
+			q := query.Questions[0]
+			resp := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true},
+				Questions: query.Questions,
+			}
+			if q.Type == dnsmessage.TypeA && q.Name.String() == name {
+				resp.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 1},
+					Body:   &dnsmessage.AResource{A: answer.Load().(netip.Addr).As4()},
+				}}
+			}
+			out, err := resp.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(out, from)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
@@ -0,0 +1,83 @@
+//go:build linux && netns
+
+package netnstest_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/netnstest"
+	"github.com/plexsphere/plexd/internal/policy"
+)
+
+// TestPolicyRollback applies a rule set that cuts the mesh peer off from the
+// remote host, which the apply check needs to reach; the enforcer restores
+// the rules that allowed it.
+//
+//	mesh peer 10.98.0.2 ── mesh0 10.98.0.1 [node] up0 10.97.0.1 ── 10.97.0.2 remote
+func TestPolicyRollback(t *testing.T) {
+	meshPeer := netnstest.New(t, "mesh-peer")
+	remote := netnstest.New(t, "remote")
+	netnstest.Veth(t, "mesh0", "10.98.0.1/24", meshPeer, "eth0", "10.98.0.2/24")
+	netnstest.Veth(t, "up0", "10.97.0.1/24", remote, "eth0", "10.97.0.2/24")
+	meshPeer.AddRoute(t, "10.97.0.0/24", "10.98.0.1")
+	remote.AddRoute(t, "10.98.0.0/24", "10.97.0.1")
+	serveEcho(remote.Listen(t, "tcp", "10.97.0.2:8080"))
+	if err := os.WriteFile("/proc/sys/net/ipv4/ip_forward", []byte("1"), 0o644); err != nil {
+		t.Fatalf("enable forwarding: %v", err)
+	}
+
+	cfg := policy.Config{}
+	cfg.ApplyDefaults()
+	cfg.ApplyCheckTimeout = time.Second
+	cfg.ApplyCheckInterval = 200 * time.Millisecond
+	ctrl := policy.NewNftablesController(discardLogger())
+	enf := policy.NewEnforcer(policy.NewPolicyEngine(discardLogger()), ctrl, cfg, discardLogger())
+	enf.SetApplyCheck(func(ctx context.Context) error {
+		conn, err := meshPeer.Dial("tcp", "10.97.0.2:8080", 500*time.Millisecond)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+	t.Cleanup(func() { enf.Teardown() })
+
+	peersByID := map[string]string{"node": "10.98.0.1"}
+	allowAll := []api.Policy{{ID: "all", Rules: []api.PolicyRule{{Src: "*", Dst: "*", Action: "allow"}}}}
+	if err := enf.ApplyFirewallRules(allowAll, "node", "mesh0", peersByID); err != nil {
+		t.Fatalf("ApplyFirewallRules allow all: %v", err)
+	}
+
+	// Without policies, only the default deny is left, and the check fails.
+	err := enf.ApplyFirewallRules(nil, "node", "mesh0", peersByID)
+	if !errors.Is(err, policy.ErrRulesRolledBack) {
+		t.Fatalf("ApplyFirewallRules deny all = %v, want ErrRulesRolledBack", err)
+	}
+	conn, err := meshPeer.Dial("tcp", "10.97.0.2:8080", dialTimeout)
+	if err != nil {
+		t.Fatalf("dial after rollback: %v", err)
+	}
+	echo(t, conn, "mesh peer after rollback")
+	conn.Close()
+	allowRule := policy.FirewallRule{Interface: "mesh0", SrcIP: "0.0.0.0/0", DstIP: "0.0.0.0/0", Action: "allow"}
+	denyRule := policy.FirewallRule{Interface: "mesh0", SrcIP: "0.0.0.0/0", DstIP: "0.0.0.0/0", Action: "deny"}
+	if err := ctrl.VerifyRules(cfg.ChainName, []policy.FirewallRule{allowRule, denyRule}); err != nil {
+		t.Errorf("VerifyRules after rollback: %v", err)
+	}
+	if err := ctrl.VerifyRules(cfg.ChainName, []policy.FirewallRule{denyRule}); err == nil {
+		t.Error("VerifyRules with the rejected rules = nil, want error")
+	}
+
+	// The rejected rules are not applied again.
+	start := time.Now()
+	if err := enf.ApplyFirewallRules(nil, "node", "mesh0", peersByID); !errors.Is(err, policy.ErrRulesRolledBack) {
+		t.Fatalf("ApplyFirewallRules rejected rules again = %v, want ErrRulesRolledBack", err)
+	}
+	if elapsed := time.Since(start); elapsed > cfg.ApplyCheckTimeout {
+		t.Errorf("rejected rules took %s, want no apply check", elapsed)
+	}
+}
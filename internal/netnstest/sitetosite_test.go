@@ -0,0 +1,108 @@
+//go:build linux && netns
+
+package netnstest_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/bridge"
+	"github.com/plexsphere/plexd/internal/netnstest"
+)
+
+// TestSiteToSite connects the node to a remote site through a real
+// WireGuard tunnel over a veth underlay.
+//
+//	[node] s2s-mesh 10.100.0.1
+//	[node] s2s-u 10.98.0.1 ── 10.98.0.2 remote
+//	[node] wg-s2s-t1 ══ wg0 192.168.70.1/24 remote
+func TestSiteToSite(t *testing.T) {
+	netnstest.RequireWireGuard(t)
+	remote := netnstest.New(t, "remote")
+	netnstest.Veth(t, "s2s-u", "10.98.0.1/24", remote, "eth0", "10.98.0.2/24")
+	netnstest.Dummy(t, "s2s-mesh", "10.100.0.1/24")
+	remoteKey := remote.WireGuard(t, "wg0", "192.168.70.1/24", 51900)
+	serveEcho(remote.Listen(t, "tcp", "192.168.70.1:8080"))
+
+	cfg := bridge.Config{Enabled: true, SiteToSiteEnabled: true}
+	cfg.ApplyDefaults()
+	backend, err := bridge.NewBackend(cfg, discardLogger())
+	if err != nil {
+		t.Fatalf("NewBackend: %v", err)
+	}
+	mgr := bridge.NewSiteToSiteManager(backend.VPN, backend.Routes, cfg, discardLogger())
+	if err := mgr.Setup("s2s-mesh"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	t.Cleanup(func() { mgr.Teardown() })
+
+	const iface = "wg-s2s-t1"
+	tunnel := api.SiteToSiteTunnel{
+		TunnelID:        "t1",
+		InterfaceName:   iface,
+		ListenPort:      51901,
+		RemoteEndpoint:  "10.98.0.2:51900",
+		RemotePublicKey: remoteKey.String(),
+		LocalSubnets:    []string{"10.100.0.0/24"},
+		RemoteSubnets:   []string{"192.168.70.0/24"},
+	}
+	if err := mgr.AddTunnel(tunnel); err != nil {
+		t.Fatalf("AddTunnel: %v", err)
+	}
+
+	dev := device(t, iface)
+	if dev.ListenPort != tunnel.ListenPort {
+		t.Errorf("ListenPort = %d, want %d", dev.ListenPort, tunnel.ListenPort)
+	}
+	if len(dev.Peers) != 1 || dev.Peers[0].PublicKey != remoteKey {
+		t.Fatalf("peers = %+v, want the remote site", dev.Peers)
+	}
+	if got := dev.Peers[0].Endpoint.String(); got != tunnel.RemoteEndpoint {
+		t.Errorf("endpoint = %s, want %s", got, tunnel.RemoteEndpoint)
+	}
+	if !hasRoute(t, "192.168.70.0/24", iface) {
+		t.Fatalf("no route to the remote subnet through %s", iface)
+	}
+
+	// The remote site accepts the local subnet from the node.
+	_, local, _ := net.ParseCIDR("10.100.0.0/24")
+	remote.AddPeer(t, "wg0", wgtypes.PeerConfig{
+		PublicKey:  dev.PublicKey,
+		AllowedIPs: []net.IPNet{*local},
+	})
+	remote.AddLinkRoute(t, "10.100.0.0/24", "wg0")
+
+	dialer := net.Dialer{Timeout: dialTimeout, LocalAddr: &net.TCPAddr{IP: net.ParseIP("10.100.0.1")}}
+	conn, err := dialer.Dial("tcp", "192.168.70.1:8080")
+	if err != nil {
+		t.Fatalf("dial through the tunnel: %v", err)
+	}
+	echo(t, conn, "through the tunnel")
+	conn.Close()
+
+	// Removing the interface takes its routes along.
+	mgr.RemoveTunnel("t1")
+	if _, err := netlink.LinkByName(iface); err == nil {
+		t.Errorf("%s still exists after RemoveTunnel", iface)
+	}
+}
+
+// device returns the WireGuard device iface of the node.
+func device(t *testing.T, iface string) *wgtypes.Device {
+	t.Helper()
+	client, err := wgctrl.New()
+	if err != nil {
+		t.Fatalf("wgctrl: %v", err)
+	}
+	defer client.Close()
+	dev, err := client.Device(iface)
+	if err != nil {
+		t.Fatalf("read %s: %v", iface, err)
+	}
+	return dev
+}
@@ -0,0 +1,128 @@
+package nodeapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// maxPendingAccessAudits bounds the audit entries buffered between Collect
+// calls; the oldest are dropped first.
+const maxPendingAccessAudits = 256
+
+// accessAuditEventType is the event type of node API access audit entries.
+const accessAuditEventType = "nodeapi_access"
+
+// reconcileAuditEventType is the event type of audit entries for
+// reconciliation triggered, paused or resumed through the node API.
+const reconcileAuditEventType = "reconcile_control"
+
+// auditLog buffers audit entries until they are collected by the audit
+// forwarder.
+type auditLog struct {
+	hostname string
+
+	mu      sync.Mutex
+	entries []api.AuditEntry
+}
+
+func newAuditLog(hostname string) *auditLog {
+	return &auditLog{hostname: hostname}
+}
+
+// collect returns and clears the buffered entries.
+func (l *auditLog) collect() []api.AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := l.entries
+	l.entries = nil
+	return entries
+}
+
+// add queues entry, dropping the oldest entries beyond
+// maxPendingAccessAudits.
+func (l *auditLog) add(entry api.AuditEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+	if over := len(l.entries) - maxPendingAccessAudits; over > 0 {
+		l.entries = l.entries[over:]
+	}
+}
+
+// peerDenied queues the audit entry for a Unix socket request denied scope
+// by peer authorization. cred is nil if the peer credentials were unavailable.
+func (l *auditLog) peerDenied(r *http.Request, scope string, cred *PeerCredentials) {
+	peer := "unknown peer"
+	subject := []byte(`{}`)
+	if cred != nil {
+		peer = fmt.Sprintf("uid %d", cred.UID)
+		subject, _ = json.Marshal(map[string]uint32{
+			"uid": cred.UID,
+			"gid": cred.GID,
+			"pid": cred.PID,
+		})
+	}
+	object, _ := json.Marshal(map[string]string{
+		"scope":  scope,
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+	entry := api.AuditEntry{
+		Timestamp: time.Now().UTC(),
+		Source:    "plexd",
+		EventType: accessAuditEventType,
+		Subject:   subject,
+		Object:    object,
+		Action:    scope,
+		Result:    "denied",
+		Hostname:  l.hostname,
+		Raw:       fmt.Sprintf("node API access denied: %s lacks scope %s for %s %s", peer, scope, r.Method, r.URL.Path),
+	}
+	l.add(entry)
+}
+
+// reconcileControl queues the audit entry for a request that triggered,
+// paused or resumed reconciliation. object describes the request, e.g. the
+// pause duration.
+func (l *auditLog) reconcileControl(r *http.Request, action string, object map[string]string, raw string) {
+	who := "local peer"
+	subject := []byte(`{}`)
+	if c := ClientFromContext(r.Context()); c != nil {
+		who = "client " + c.Name
+		subject, _ = json.Marshal(map[string]string{"client": c.Name})
+	} else if cred := requestPeerCredentials(r); cred != nil {
+		who = fmt.Sprintf("uid %d", cred.UID)
+		subject, _ = json.Marshal(map[string]uint32{
+			"uid": cred.UID,
+			"gid": cred.GID,
+			"pid": cred.PID,
+		})
+	}
+	obj, _ := json.Marshal(object)
+	entry := api.AuditEntry{
+		Timestamp: time.Now().UTC(),
+		Source:    "plexd",
+		EventType: reconcileAuditEventType,
+		Subject:   subject,
+		Object:    obj,
+		Action:    action,
+		Result:    "success",
+		Hostname:  l.hostname,
+		Raw:       fmt.Sprintf("%s by %s", raw, who),
+	}
+	l.add(entry)
+}
+
+// Collect returns and clears the audit entries recorded since the last call:
+// one per Unix socket request denied by peer authorization and one per
+// reconciliation trigger, pause or resume. It implements
+// auditfwd.AuditSource.
+func (s *Server) Collect(_ context.Context) ([]api.AuditEntry, error) {
+	return s.audit.collect(), nil
+}
@@ -0,0 +1,62 @@
+package nodeapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuditLog_PeerDenied(t *testing.T) {
+	l := newAuditLog("host-1")
+	req := httptest.NewRequest(http.MethodGet, "/v1/state/secrets/db", nil)
+	l.peerDenied(req, ScopeSecretsRead, nil)
+
+	entries := l.collect()
+	if len(entries) != 1 {
+		t.Fatalf("entries = %d, want 1", len(entries))
+	}
+	e := entries[0]
+	if string(e.Subject) != "{}" {
+		t.Errorf("subject = %s, want {}", e.Subject)
+	}
+	if string(e.Object) != `{"method":"GET","path":"/v1/state/secrets/db","scope":"secrets:read"}` {
+		t.Errorf("object = %s", e.Object)
+	}
+	if !strings.Contains(e.Raw, "unknown peer lacks scope secrets:read") {
+		t.Errorf("raw = %q", e.Raw)
+	}
+
+	if entries := l.collect(); len(entries) != 0 {
+		t.Errorf("entries after collect = %d, want 0", len(entries))
+	}
+}
+
+func TestAuditLog_Bounded(t *testing.T) {
+	l := newAuditLog("host-1")
+	req := httptest.NewRequest(http.MethodGet, "/v1/state/secrets", nil)
+	for i := 0; i < maxPendingAccessAudits+10; i++ {
+		l.peerDenied(req, ScopeSecretsRead, &PeerCredentials{UID: uint32(i)})
+	}
+	entries := l.collect()
+	if len(entries) != maxPendingAccessAudits {
+		t.Fatalf("entries = %d, want %d", len(entries), maxPendingAccessAudits)
+	}
+	if !strings.Contains(entries[0].Raw, "uid 10 ") {
+		t.Errorf("oldest entry = %q, want uid 10", entries[0].Raw)
+	}
+}
+
+func TestServer_Collect(t *testing.T) {
+	srv := NewServer(Config{DataDir: t.TempDir()}, &serverTestClient{}, nil, discardLogger())
+	srv.audit.peerDenied(httptest.NewRequest(http.MethodGet, "/v1/state/secrets", nil), ScopeSecretsRead, nil)
+
+	entries, err := srv.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Source != "plexd" {
+		t.Errorf("entries = %+v", entries)
+	}
+}
@@ -9,14 +9,28 @@ import (
 
 // BearerAuthMiddleware returns middleware that validates Bearer token
 // authentication. Requests without a valid token receive 401 Unauthorized.
-// Unix socket requests bypass this middleware (it is only applied to the TCP
-// listener).
+// The token is granted all scopes. Unix socket requests bypass this
+// middleware (it is only applied to the TCP listener).
 func BearerAuthMiddleware(token string) func(http.Handler) http.Handler {
+	return TokenAuthMiddleware([]Token{{Name: "default", Token: token, Scopes: AllScopes}}, true)
+}
+
+// TokenAuthMiddleware returns middleware that authenticates Bearer tokens
+// against tokens and stores the matching *Client in the request context for
+// per-route scope checks. If required is true, requests without a valid
+// token receive 401 Unauthorized; otherwise only requests presenting an
+// invalid token are rejected and requests without an Authorization header
+// pass through unauthenticated.
+func TokenAuthMiddleware(tokens []Token, required bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			auth := r.Header.Get("Authorization")
 			if auth == "" {
-				writeAuthError(w)
+				if required {
+					writeAuthError(w)
+					return
+				}
+				next.ServeHTTP(w, r)
 				return
 			}
 
@@ -27,17 +41,31 @@ func BearerAuthMiddleware(token string) func(http.Handler) http.Handler {
 				return
 			}
 
-			// Constant-time comparison to prevent timing attacks.
-			if subtle.ConstantTimeCompare([]byte(parts[1]), []byte(token)) != 1 {
+			t, ok := matchToken(tokens, parts[1])
+			if !ok {
 				writeAuthError(w)
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			client := &Client{Name: t.Name, Scopes: t.Scopes}
+			next.ServeHTTP(w, r.WithContext(WithClient(r.Context(), client)))
 		})
 	}
 }
 
+// matchToken returns the token whose value equals presented. Every token is
+// compared in constant time to prevent timing attacks.
+func matchToken(tokens []Token, presented string) (Token, bool) {
+	var match Token
+	found := false
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(t.Token)) == 1 && !found {
+			match, found = t, true
+		}
+	}
+	return match, found
+}
+
 func writeAuthError(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusUnauthorized)
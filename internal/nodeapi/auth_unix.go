@@ -3,7 +3,6 @@
 package nodeapi
 
 import (
-	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net"
@@ -55,13 +54,6 @@ func (OSGroupChecker) IsInGroup(uid, gid uint32, groupName string) bool {
 	return false
 }
 
-// PeerCredentials holds the peer credentials extracted from a Unix socket connection.
-type PeerCredentials struct {
-	PID uint32
-	UID uint32
-	GID uint32
-}
-
 // GetPeerCredentials extracts peer credentials from a Unix socket connection
 // using the SO_PEERCRED socket option. Returns an error if the connection
 // is not a Unix socket or the credentials cannot be retrieved.
@@ -100,6 +92,7 @@ type PeerCredGetter interface {
 
 // SetSocketPermissions sets ownership and permissions on the Unix socket file.
 // If the plexd group exists, the socket is chowned to root:plexd with mode 0660.
+// An unprivileged (rootless) agent keeps ownership and only sets the group.
 // If the group does not exist, the socket gets mode 0666 and a warning is logged.
 func SetSocketPermissions(socketPath string, logger *slog.Logger) error {
 	grp, err := user.LookupGroup("plexd")
@@ -113,7 +106,11 @@ func SetSocketPermissions(socketPath string, logger *slog.Logger) error {
 	if err != nil {
 		return fmt.Errorf("nodeapi: auth: parse gid: %w", err)
 	}
-	if err := os.Chown(socketPath, 0, gid); err != nil {
+	uid := 0
+	if os.Geteuid() != 0 {
+		uid = -1
+	}
+	if err := os.Chown(socketPath, uid, gid); err != nil {
 		return fmt.Errorf("nodeapi: auth: chown socket: %w", err)
 	}
 	if err := os.Chmod(socketPath, 0660); err != nil {
@@ -121,45 +118,3 @@ func SetSocketPermissions(socketPath string, logger *slog.Logger) error {
 	}
 	return nil
 }
-
-// SecretAuthMiddleware returns HTTP middleware that restricts access to secret
-// endpoints. Access is granted to root (UID 0) or processes whose user is a
-// member of the plexd-secrets group.
-//
-// The middleware extracts peer credentials from the request's underlying
-// connection using a PeerCredGetter. In production, this is backed by
-// SO_PEERCRED; in tests, a mock can be injected.
-func SecretAuthMiddleware(checker GroupChecker, getter PeerCredGetter, logger *slog.Logger) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			cred, err := getter.GetPeerCredentials(r)
-			if err != nil {
-				logger.Error("failed to get peer credentials", "error", err)
-				writeSecretAuthError(w)
-				return
-			}
-			// Root always has access.
-			if cred.UID == 0 {
-				next.ServeHTTP(w, r)
-				return
-			}
-			// Check plexd-secrets group membership.
-			if checker.IsInGroup(cred.UID, cred.GID, "plexd-secrets") {
-				next.ServeHTTP(w, r)
-				return
-			}
-			logger.Warn("secret access denied",
-				"uid", cred.UID,
-				"gid", cred.GID,
-				"path", r.URL.Path,
-			)
-			writeSecretAuthError(w)
-		})
-	}
-}
-
-func writeSecretAuthError(w http.ResponseWriter) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusForbidden)
-	_ = json.NewEncoder(w).Encode(map[string]string{"error": "forbidden: insufficient privileges for secret access"})
-}
@@ -1,12 +1,16 @@
 package nodeapi
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -29,17 +33,56 @@ type ReportEntry struct {
 	Payload     json.RawMessage `json:"payload"`
 	Version     int             `json:"version"`
 	UpdatedAt   time.Time       `json:"updated_at"`
+	// ExpiresAt is when the entry is deleted, or nil if it does not expire.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
-// StateCache holds node state in memory with file persistence.
+// expired reports whether the entry has expired at now.
+func (e ReportEntry) expired(now time.Time) bool {
+	return e.ExpiresAt != nil && !now.Before(*e.ExpiresAt)
+}
+
+// dataEntry is a data entry held by the StateCache. Its Payload is always
+// nil: the payload is held by the payload cache or read back from the
+// entry's state file.
+type dataEntry struct {
+	api.DataEntry
+	size int64 // payload size in bytes
+	// resident is the payload of an entry whose state file could not be
+	// written. It is kept in memory until the entry is replaced.
+	resident json.RawMessage
+}
+
+// DataCacheStats is the size accounting of the data entries in a
+// StateCache.
+type DataCacheStats struct {
+	// Entries is the number of data entries.
+	Entries int
+	// PayloadBytes is the total payload size of all data entries.
+	PayloadBytes int64
+	// CachedEntries and CachedBytes are the number and total size of the
+	// payloads held in memory.
+	CachedEntries int
+	CachedBytes   int64
+	// CacheLimit bounds CachedBytes, except for payloads that could not be
+	// written to disk.
+	CacheLimit int64
+}
+
+// StateCache holds node state in memory with file persistence. Data entry
+// payloads are held in an LRU cache bounded by SetDataCacheLimit; evicted
+// payloads are read back from disk on access.
 type StateCache struct {
 	mu          sync.RWMutex
 	dataDir     string // base dir; state lives under dataDir/state/
 	logger      *slog.Logger
 	metadata    map[string]string
-	data        map[string]api.DataEntry
+	data        map[string]dataEntry
+	payloads    *payloadCache
 	secretIndex []api.SecretRef
 	reports     map[string]ReportEntry
+	labels      map[string]string // metadata written through the node API
+	cipher      *stateCipher      // nil: files are stored in plaintext
 }
 
 // NewStateCache creates a new StateCache with empty maps. dataDir is the base
@@ -49,10 +92,44 @@ func NewStateCache(dataDir string, logger *slog.Logger) *StateCache {
 		dataDir:     dataDir,
 		logger:      logger,
 		metadata:    make(map[string]string),
-		data:        make(map[string]api.DataEntry),
+		data:        make(map[string]dataEntry),
+		payloads:    newPayloadCache(DefaultDataCacheBytes),
 		secretIndex: nil,
 		reports:     make(map[string]ReportEntry),
+		labels:      make(map[string]string),
+	}
+}
+
+// SetEncryptionKey enables encryption of state files at rest with a key
+// derived from the node secret key. Plaintext files found by the first Load
+// are encrypted in place; later plaintext files are rejected. It must be
+// called before Load.
+func (sc *StateCache) SetEncryptionKey(nsk []byte) error {
+	c, err := newStateCipher(nsk)
+	if err != nil {
+		return err
 	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.cipher = c
+	return nil
+}
+
+// SetDataCacheLimit bounds the memory held by data entry payloads to limit
+// bytes. Least recently used payloads beyond the limit are evicted, and
+// payloads larger than the limit are never kept in memory.
+func (sc *StateCache) SetDataCacheLimit(limit int64) {
+	sc.payloads.setMax(limit)
+}
+
+// WipeStateCache securely erases the persisted state cache under
+// dataDir/state/, including cached secrets, and removes the directory.
+// It must not be called while a StateCache for dataDir is in use.
+func WipeStateCache(dataDir string) error {
+	if err := fsutil.WipeDir(filepath.Join(dataDir, "state")); err != nil {
+		return fmt.Errorf("nodeapi: wipe state cache: %w", err)
+	}
+	return nil
 }
 
 // stateDir returns the path to the state subdirectory.
@@ -60,8 +137,48 @@ func (sc *StateCache) stateDir() string {
 	return filepath.Join(sc.dataDir, "state")
 }
 
+// readState reads the state file at rel, relative to the state directory,
+// and returns its plaintext. It returns nil data for a missing file and an
+// error wrapping errStateDecrypt for an encrypted file that cannot be
+// decrypted. plaintext reports whether the file was stored unencrypted.
+func (sc *StateCache) readState(rel string) (data []byte, plaintext bool, err error) {
+	raw, err := os.ReadFile(filepath.Join(sc.stateDir(), rel))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if !isEncryptedState(raw) {
+		return raw, true, nil
+	}
+	if sc.cipher == nil {
+		return nil, false, fmt.Errorf("nodeapi: state file %s is encrypted but no key is set", rel)
+	}
+	data, err = sc.cipher.open(filepath.ToSlash(rel), raw)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %s", err, rel)
+	}
+	return data, false, nil
+}
+
+// moveStateAside renames the state file at rel so that it is no longer
+// loaded, keeping it for inspection.
+func (sc *StateCache) moveStateAside(rel, reason string) error {
+	path := filepath.Join(sc.stateDir(), rel)
+	if err := os.Rename(path, path+corruptStateSuffix); err != nil {
+		return fmt.Errorf("nodeapi: move aside state file %s: %w", rel, err)
+	}
+	sc.logger.Error("moved aside state file", "path", rel, "reason", reason,
+		"moved_to", rel+corruptStateSuffix)
+	return nil
+}
+
 // Load reads persisted state from disk. Missing files or directories are
 // treated as fresh (empty) state. The directory tree is created if absent.
+// With an encryption key set, files that fail to decrypt are moved aside.
+// Plaintext files are encrypted in place by the first Load with a key, and
+// moved aside by every later one.
 func (sc *StateCache) Load() error {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
@@ -75,32 +192,70 @@ func (sc *StateCache) Load() error {
 		}
 	}
 
+	// Plaintext files are accepted only until they have been migrated once.
+	migrating := false
+	if sc.cipher != nil {
+		_, err := os.Stat(filepath.Join(sd, stateEncryptedMarker))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		migrating = os.IsNotExist(err)
+	}
+
+	// Plaintext files to encrypt once loaded, keyed by relative path.
+	migrate := make(map[string][]byte)
+	read := func(rel string) ([]byte, error) {
+		data, plaintext, err := sc.readState(rel)
+		if errors.Is(err, errStateDecrypt) {
+			return nil, sc.moveStateAside(rel, err.Error())
+		}
+		if err != nil || !plaintext || sc.cipher == nil {
+			return data, err
+		}
+		if !migrating {
+			return nil, sc.moveStateAside(rel, "plaintext file after migration to encrypted state")
+		}
+		migrate[rel] = data
+		return data, nil
+	}
+
 	// Load metadata.json.
-	if data, err := os.ReadFile(filepath.Join(sd, "metadata.json")); err == nil {
+	if data, err := read("metadata.json"); err != nil {
+		return err
+	} else if data != nil {
 		var m map[string]string
 		if err := json.Unmarshal(data, &m); err != nil {
 			return err
 		}
 		sc.metadata = m
-	} else if !os.IsNotExist(err) {
+	}
+
+	// Load labels.json.
+	sc.labels = make(map[string]string)
+	if data, err := read("labels.json"); err != nil {
 		return err
+	} else if data != nil {
+		if err := json.Unmarshal(data, &sc.labels); err != nil {
+			return err
+		}
 	}
 
 	// Load secrets.json.
-	if data, err := os.ReadFile(filepath.Join(sd, "secrets.json")); err == nil {
+	if data, err := read("secrets.json"); err != nil {
+		return err
+	} else if data != nil {
 		var refs []api.SecretRef
 		if err := json.Unmarshal(data, &refs); err != nil {
 			return err
 		}
 		sc.secretIndex = refs
-	} else if !os.IsNotExist(err) {
-		return err
 	}
 
-	// Load data/*.json.
-	sc.data = make(map[string]api.DataEntry)
-	dataDir := filepath.Join(sd, "data")
-	dataEntries, err := os.ReadDir(dataDir)
+	// Load data/*.json. Payloads are cached up to the limit; the others are
+	// read back from their files on access.
+	sc.data = make(map[string]dataEntry)
+	sc.payloads.clear()
+	dataEntries, err := os.ReadDir(filepath.Join(sd, "data"))
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
@@ -108,21 +263,23 @@ func (sc *StateCache) Load() error {
 		if de.IsDir() || !strings.HasSuffix(de.Name(), ".json") {
 			continue
 		}
-		raw, err := os.ReadFile(filepath.Join(dataDir, de.Name()))
+		raw, err := read(filepath.Join("data", de.Name()))
 		if err != nil {
 			return err
 		}
+		if raw == nil {
+			continue
+		}
 		var entry api.DataEntry
 		if err := json.Unmarshal(raw, &entry); err != nil {
 			return err
 		}
-		sc.data[entry.Key] = entry
+		sc.data[entry.Key] = sc.storeData(entry, true)
 	}
 
 	// Load report/*.json.
 	sc.reports = make(map[string]ReportEntry)
-	reportDir := filepath.Join(sd, "report")
-	reportEntries, err := os.ReadDir(reportDir)
+	reportEntries, err := os.ReadDir(filepath.Join(sd, "report"))
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
@@ -130,10 +287,13 @@ func (sc *StateCache) Load() error {
 		if re.IsDir() || !strings.HasSuffix(re.Name(), ".json") {
 			continue
 		}
-		raw, err := os.ReadFile(filepath.Join(reportDir, re.Name()))
+		raw, err := read(filepath.Join("report", re.Name()))
 		if err != nil {
 			return err
 		}
+		if raw == nil {
+			continue
+		}
 		var entry ReportEntry
 		if err := json.Unmarshal(raw, &entry); err != nil {
 			return err
@@ -141,6 +301,22 @@ func (sc *StateCache) Load() error {
 		sc.reports[entry.Key] = entry
 	}
 
+	// Encrypt plaintext files left by an earlier version, then record the
+	// migration so that later plaintext files are rejected.
+	if migrating {
+		for _, rel := range slices.Sorted(maps.Keys(migrate)) {
+			if err := sc.writeState(filepath.Join(sd, rel), migrate[rel]); err != nil {
+				return fmt.Errorf("nodeapi: encrypt state file %s: %w", rel, err)
+			}
+		}
+		if err := fsutil.WriteFileAtomic(sd, stateEncryptedMarker, nil, 0600); err != nil {
+			return fmt.Errorf("nodeapi: record state encryption: %w", err)
+		}
+		if len(migrate) > 0 {
+			sc.logger.Info("encrypted plaintext state files", "count", len(migrate))
+		}
+	}
+
 	return nil
 }
 
@@ -155,6 +331,7 @@ func (sc *StateCache) UpdateMetadata(m map[string]string) {
 
 // UpdateData replaces data entries in memory and persists each to
 // data/{key}.json. Files for entries no longer present are removed.
+// Entries whose key is not a valid file name, such as "../x", are skipped.
 func (sc *StateCache) UpdateData(entries []api.DataEntry) {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
@@ -165,17 +342,31 @@ func (sc *StateCache) UpdateData(entries []api.DataEntry) {
 		oldKeys[k] = struct{}{}
 	}
 
-	newData := make(map[string]api.DataEntry, len(entries))
+	newData := make(map[string]dataEntry, len(entries))
 	dataDir := filepath.Join(sc.stateDir(), "data")
 	for _, e := range entries {
-		newData[e.Key] = e
+		// Keys name files; a key from the control plane must not reach
+		// outside the data directory.
+		if !validReportKey(e.Key) {
+			sc.logger.Warn("data entry skipped: invalid key", "key", e.Key)
+			continue
+		}
+		// Data files are written compactly, so a payload read back from
+		// disk has the bytes it was cached with.
+		persisted := false
+		if raw, err := json.Marshal(e); err != nil {
+			sc.logger.Error("persist marshal failed", "key", e.Key, "error", err)
+		} else {
+			persisted = sc.writeState(filepath.Join(dataDir, e.Key+".json"), raw) == nil
+		}
+		newData[e.Key] = sc.storeData(e, persisted)
 		delete(oldKeys, e.Key)
-		sc.persistJSON(filepath.Join(dataDir, e.Key+".json"), e)
 	}
 
 	// Remove files for entries no longer present.
 	for k := range oldKeys {
 		os.Remove(filepath.Join(dataDir, k+".json"))
+		sc.payloads.remove(k)
 	}
 
 	sc.data = newData
@@ -193,34 +384,153 @@ func (sc *StateCache) UpdateSecretIndex(refs []api.SecretRef) {
 	sc.persistJSON(filepath.Join(sc.stateDir(), "secrets.json"), sc.secretIndex)
 }
 
-// GetMetadata returns a copy of the metadata map.
+// GetMetadata returns a copy of the metadata map, with labels written
+// through PutLabel taking precedence over control plane metadata.
 func (sc *StateCache) GetMetadata() map[string]string {
 	sc.mu.RLock()
 	defer sc.mu.RUnlock()
-	return maps.Clone(sc.metadata)
+	m := maps.Clone(sc.metadata)
+	if m == nil {
+		m = make(map[string]string, len(sc.labels))
+	}
+	maps.Copy(m, sc.labels)
+	return m
 }
 
 // GetMetadataKey returns the value for a metadata key and whether it exists.
 func (sc *StateCache) GetMetadataKey(key string) (string, bool) {
 	sc.mu.RLock()
 	defer sc.mu.RUnlock()
+	if v, ok := sc.labels[key]; ok {
+		return v, true
+	}
 	v, ok := sc.metadata[key]
 	return v, ok
 }
 
-// GetData returns a copy of the data map.
+// PutLabel sets a locally written metadata key and persists all labels to
+// labels.json. Labels survive control plane metadata updates.
+func (sc *StateCache) PutLabel(key, value string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.labels[key] = value
+	sc.persistJSON(filepath.Join(sc.stateDir(), "labels.json"), sc.labels)
+}
+
+// GetLabels returns a copy of the locally written metadata keys.
+func (sc *StateCache) GetLabels() map[string]string {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return maps.Clone(sc.labels)
+}
+
+// storeData caches the payload of e and returns e without it. The payload
+// of an entry that was not persisted stays resident. Callers must hold
+// sc.mu for writing.
+func (sc *StateCache) storeData(e api.DataEntry, persisted bool) dataEntry {
+	d := dataEntry{DataEntry: e, size: int64(len(e.Payload))}
+	d.Payload = nil
+	if persisted {
+		sc.payloads.put(e.Key, e.Payload)
+	} else {
+		sc.payloads.remove(e.Key)
+		d.resident = e.Payload
+	}
+	return d
+}
+
+// GetData returns the data entries by key, without their payloads.
+// GetDataEntry and OpenDataEntry return the payload of a single entry.
 func (sc *StateCache) GetData() map[string]api.DataEntry {
 	sc.mu.RLock()
 	defer sc.mu.RUnlock()
-	return maps.Clone(sc.data)
+	m := make(map[string]api.DataEntry, len(sc.data))
+	for k, d := range sc.data {
+		m[k] = d.DataEntry
+	}
+	return m
 }
 
-// GetDataEntry returns a data entry by key and whether it exists.
+// GetDataEntry returns a data entry by key, including its payload, and
+// whether it exists. An entry whose payload cannot be read back from disk
+// does not exist.
 func (sc *StateCache) GetDataEntry(key string) (api.DataEntry, bool) {
 	sc.mu.RLock()
 	defer sc.mu.RUnlock()
-	e, ok := sc.data[key]
-	return e, ok
+	d, ok := sc.data[key]
+	if !ok {
+		return api.DataEntry{}, false
+	}
+	payload, err := sc.payload(d)
+	if err != nil {
+		sc.logger.Error("read data payload failed", "key", key, "error", err)
+		return api.DataEntry{}, false
+	}
+	e := d.DataEntry
+	e.Payload = payload
+	return e, true
+}
+
+// OpenDataEntry returns a data entry by key without its payload, and a
+// reader of the payload. The payload is served from memory when cached and
+// otherwise read back from disk without being cached if it exceeds the
+// cache limit. It returns ErrNotFound if the key does not exist.
+func (sc *StateCache) OpenDataEntry(key string) (api.DataEntry, io.Reader, error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	d, ok := sc.data[key]
+	if !ok {
+		return api.DataEntry{}, nil, ErrNotFound
+	}
+	payload, err := sc.payload(d)
+	if err != nil {
+		return api.DataEntry{}, nil, err
+	}
+	return d.DataEntry, bytes.NewReader(payload), nil
+}
+
+// payload returns the payload of d from memory, or reads it back from the
+// entry's state file and caches it. Callers must hold sc.mu.
+func (sc *StateCache) payload(d dataEntry) (json.RawMessage, error) {
+	if d.resident != nil {
+		return d.resident, nil
+	}
+	if p, ok := sc.payloads.get(d.Key); ok {
+		return p, nil
+	}
+	raw, plaintext, err := sc.readState(filepath.Join("data", d.Key+".json"))
+	if err != nil {
+		return nil, err
+	}
+	if plaintext && sc.cipher != nil {
+		return nil, fmt.Errorf("nodeapi: data entry %s: state file is not encrypted", d.Key)
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("nodeapi: data entry %s: state file missing or unreadable", d.Key)
+	}
+	var e api.DataEntry
+	if err := json.Unmarshal(raw, &e); err != nil {
+		return nil, fmt.Errorf("nodeapi: data entry %s: %w", d.Key, err)
+	}
+	if e.Key != d.Key || e.Version != d.Version {
+		return nil, fmt.Errorf("nodeapi: data entry %s: state file has version %d, want %d", d.Key, e.Version, d.Version)
+	}
+	sc.payloads.put(d.Key, e.Payload)
+	return e.Payload, nil
+}
+
+// DataCacheStats returns the size accounting of the data entries.
+func (sc *StateCache) DataCacheStats() DataCacheStats {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	var s DataCacheStats
+	s.Entries = len(sc.data)
+	for _, d := range sc.data {
+		s.PayloadBytes += d.size
+	}
+	s.CachedEntries, s.CachedBytes, s.CacheLimit = sc.payloads.stats()
+	return s
 }
 
 // GetSecretIndex returns a copy of the secret index.
@@ -235,18 +545,28 @@ func (sc *StateCache) GetSecretIndex() []api.SecretRef {
 	return cp
 }
 
-// GetReports returns a copy of the reports map.
+// GetReports returns a copy of the reports map. Expired entries that have
+// not been removed by ExpireReports yet are omitted.
 func (sc *StateCache) GetReports() map[string]ReportEntry {
 	sc.mu.RLock()
 	defer sc.mu.RUnlock()
-	return maps.Clone(sc.reports)
+	now := time.Now()
+	reports := maps.Clone(sc.reports)
+	maps.DeleteFunc(reports, func(_ string, r ReportEntry) bool {
+		return r.expired(now)
+	})
+	return reports
 }
 
-// GetReport returns a report entry by key and whether it exists.
+// GetReport returns a report entry by key and whether it exists. An expired
+// entry does not exist.
 func (sc *StateCache) GetReport(key string) (ReportEntry, bool) {
 	sc.mu.RLock()
 	defer sc.mu.RUnlock()
 	r, ok := sc.reports[key]
+	if !ok || r.expired(time.Now()) {
+		return ReportEntry{}, false
+	}
 	return r, ok
 }
 
@@ -254,10 +574,21 @@ func (sc *StateCache) GetReport(key string) (ReportEntry, bool) {
 // is non-nil, it must equal the current version or ErrVersionConflict is
 // returned. Version starts at 1 for new entries and increments on update.
 func (sc *StateCache) PutReport(key, contentType string, payload json.RawMessage, ifMatch *int) (ReportEntry, error) {
+	return sc.PutReportWithExpiry(key, contentType, payload, ifMatch, time.Time{})
+}
+
+// PutReportWithExpiry is like PutReport but sets the entry to expire at
+// expiresAt. A zero expiresAt means the entry does not expire. An expired
+// entry that has not been removed yet counts as absent.
+func (sc *StateCache) PutReportWithExpiry(key, contentType string, payload json.RawMessage, ifMatch *int, expiresAt time.Time) (ReportEntry, error) {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
 
+	now := time.Now()
 	existing, exists := sc.reports[key]
+	if exists && existing.expired(now) {
+		exists = false
+	}
 
 	version := 1
 	if exists {
@@ -274,7 +605,10 @@ func (sc *StateCache) PutReport(key, contentType string, payload json.RawMessage
 		ContentType: contentType,
 		Payload:     payload,
 		Version:     version,
-		UpdatedAt:   time.Now(),
+		UpdatedAt:   now,
+	}
+	if !expiresAt.IsZero() {
+		entry.ExpiresAt = &expiresAt
 	}
 	sc.reports[key] = entry
 	sc.persistJSON(filepath.Join(sc.stateDir(), "report", key+".json"), entry)
@@ -288,7 +622,8 @@ func (sc *StateCache) DeleteReport(key string) error {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
 
-	if _, ok := sc.reports[key]; !ok {
+	r, ok := sc.reports[key]
+	if !ok || r.expired(time.Now()) {
 		return ErrNotFound
 	}
 	delete(sc.reports, key)
@@ -296,6 +631,25 @@ func (sc *StateCache) DeleteReport(key string) error {
 	return nil
 }
 
+// ExpireReports removes the report entries that have expired at now and
+// their files, and returns the removed keys.
+func (sc *StateCache) ExpireReports(now time.Time) []string {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	var expired []string
+	for key, r := range sc.reports {
+		if !r.expired(now) {
+			continue
+		}
+		delete(sc.reports, key)
+		os.Remove(filepath.Join(sc.stateDir(), "report", key+".json"))
+		expired = append(expired, key)
+	}
+	slices.Sort(expired)
+	return expired
+}
+
 // persistJSON marshals v to JSON and writes it atomically to path.
 func (sc *StateCache) persistJSON(path string, v any) {
 	data, err := json.MarshalIndent(v, "", "  ")
@@ -303,9 +657,27 @@ func (sc *StateCache) persistJSON(path string, v any) {
 		sc.logger.Error("persist marshal failed", "path", path, "error", err)
 		return
 	}
+	sc.writeState(path, data)
+}
+
+// writeState writes data atomically to path, encrypted if a key is set.
+// Errors are logged and returned.
+func (sc *StateCache) writeState(path string, data []byte) error {
+	if sc.cipher != nil {
+		rel, err := filepath.Rel(sc.stateDir(), path)
+		if err == nil {
+			data, err = sc.cipher.seal(filepath.ToSlash(rel), data)
+		}
+		if err != nil {
+			sc.logger.Error("persist encrypt failed", "path", path, "error", err)
+			return err
+		}
+	}
 	dir := filepath.Dir(path)
 	name := filepath.Base(path)
 	if err := fsutil.WriteFileAtomic(dir, name, data, 0600); err != nil {
 		sc.logger.Error("persist write failed", "path", path, "error", err)
+		return err
 	}
+	return nil
 }
@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -96,6 +97,37 @@ func TestStateCache_UpdateMetadata(t *testing.T) {
 	}
 }
 
+func TestStateCache_PutLabel(t *testing.T) {
+	dir := t.TempDir()
+	sc := NewStateCache(dir, discardLogger())
+	if err := sc.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	sc.UpdateMetadata(map[string]string{"role": "worker", "label.pool": "old"})
+	sc.PutLabel("label.pool", "gpu")
+
+	if v, ok := sc.GetMetadataKey("label.pool"); !ok || v != "gpu" {
+		t.Errorf("GetMetadataKey(label.pool) = (%q, %v), want (%q, true)", v, ok, "gpu")
+	}
+
+	// Labels survive control plane metadata updates.
+	sc.UpdateMetadata(map[string]string{"role": "worker"})
+	got := sc.GetMetadata()
+	if got["label.pool"] != "gpu" || got["role"] != "worker" {
+		t.Errorf("GetMetadata = %v, want role=worker label.pool=gpu", got)
+	}
+
+	// Labels are reloaded from disk.
+	sc2 := NewStateCache(dir, discardLogger())
+	if err := sc2.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if labels := sc2.GetLabels(); len(labels) != 1 || labels["label.pool"] != "gpu" {
+		t.Errorf("reloaded labels = %v, want label.pool=gpu", labels)
+	}
+}
+
 func TestStateCache_UpdateData(t *testing.T) {
 	dir := t.TempDir()
 	sc := NewStateCache(dir, discardLogger())
@@ -162,6 +194,61 @@ func TestStateCache_UpdateData(t *testing.T) {
 	}
 }
 
+func TestStateCache_UpdateDataInvalidKey(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "node")
+	sc := NewStateCache(dir, discardLogger())
+	if err := sc.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	sc.UpdateData([]api.DataEntry{
+		{Key: "../../escaped", Payload: json.RawMessage(`1`)},
+		{Key: "nul\x00", Payload: json.RawMessage(`2`)},
+		{Key: "ok", Payload: json.RawMessage(`3`)},
+	})
+
+	if got := sc.GetData(); len(got) != 1 || got["ok"].Key != "ok" {
+		t.Errorf("GetData() = %v, want only ok", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "..", "escaped.json")); !os.IsNotExist(err) {
+		t.Errorf("entry written outside the state directory: %v", err)
+	}
+}
+
+// FuzzStateCache_UpdateData caches the data of state responses as the
+// control plane sends them. Every cached entry must survive a restart.
+func FuzzStateCache_UpdateData(f *testing.F) {
+	f.Add([]byte(`{"data":[{"key":"config","content_type":"application/json","payload":{"x":1},"version":1}]}`))
+	f.Add([]byte(`{"data":[{"key":"../../escaped","payload":1},{"key":"a","payload":[]},{"key":"a","payload":"dup"}]}`))
+	f.Add([]byte(`{"data":[{"key":"\u0000"},{"key":"` + strings.Repeat("k", 250) + `"},{"key":"\ud800"}]}`))
+	f.Fuzz(func(t *testing.T, state []byte) {
+		var desired api.StateResponse
+		if json.Unmarshal(state, &desired) != nil {
+			return
+		}
+		dir := filepath.Join(t.TempDir(), "node")
+		sc := NewStateCache(dir, discardLogger())
+		if err := sc.Load(); err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		sc.UpdateData(desired.Data)
+
+		restarted := NewStateCache(dir, discardLogger())
+		if err := restarted.Load(); err != nil {
+			t.Fatalf("Load after restart: %v", err)
+		}
+		want, got := sc.GetData(), restarted.GetData()
+		if len(got) != len(want) {
+			t.Fatalf("%d entries after restart, want %d", len(got), len(want))
+		}
+		for key, e := range want {
+			if got[key].Version != e.Version {
+				t.Errorf("entry %q not restored", key)
+			}
+		}
+	})
+}
+
 func TestStateCache_UpdateSecretIndex(t *testing.T) {
 	dir := t.TempDir()
 	sc := NewStateCache(dir, discardLogger())
@@ -413,6 +500,107 @@ func TestStateCache_DeleteReportNotFound(t *testing.T) {
 	}
 }
 
+func TestStateCache_ReportExpiry(t *testing.T) {
+	dir := t.TempDir()
+	sc := NewStateCache(dir, discardLogger())
+	if err := sc.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	payload := json.RawMessage(`{"ok":true}`)
+	past := time.Now().Add(-time.Second)
+	future := time.Now().Add(time.Hour)
+	if _, err := sc.PutReportWithExpiry("probe", "application/json", payload, nil, past); err != nil {
+		t.Fatalf("PutReportWithExpiry(probe): %v", err)
+	}
+	entry, err := sc.PutReportWithExpiry("diag", "application/json", payload, nil, future)
+	if err != nil {
+		t.Fatalf("PutReportWithExpiry(diag): %v", err)
+	}
+	if entry.ExpiresAt == nil || !entry.ExpiresAt.Equal(future) {
+		t.Errorf("ExpiresAt = %v, want %v", entry.ExpiresAt, future)
+	}
+	if _, err := sc.PutReport("keep", "application/json", payload, nil); err != nil {
+		t.Fatalf("PutReport(keep): %v", err)
+	}
+
+	// Expired entries are hidden before they are collected.
+	if _, ok := sc.GetReport("probe"); ok {
+		t.Error("GetReport(probe) found expired entry")
+	}
+	if _, ok := sc.GetReports()["probe"]; ok {
+		t.Error("GetReports contains expired entry")
+	}
+	if err := sc.DeleteReport("probe"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteReport(probe) = %v, want ErrNotFound", err)
+	}
+
+	expired := sc.ExpireReports(time.Now())
+	if len(expired) != 1 || expired[0] != "probe" {
+		t.Fatalf("ExpireReports = %v, want [probe]", expired)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "state", "report", "probe.json")); !os.IsNotExist(err) {
+		t.Errorf("probe.json still exists: %v", err)
+	}
+
+	expired = sc.ExpireReports(future.Add(time.Second))
+	if len(expired) != 1 || expired[0] != "diag" {
+		t.Fatalf("ExpireReports(after future) = %v, want [diag]", expired)
+	}
+	if _, ok := sc.GetReport("keep"); !ok {
+		t.Error("entry without expiry was removed")
+	}
+}
+
+func TestStateCache_ReportExpiryPersisted(t *testing.T) {
+	dir := t.TempDir()
+	sc := NewStateCache(dir, discardLogger())
+	if err := sc.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	if _, err := sc.PutReportWithExpiry("diag", "text/plain", json.RawMessage(`"x"`), nil, expiresAt); err != nil {
+		t.Fatalf("PutReportWithExpiry: %v", err)
+	}
+
+	sc2 := NewStateCache(dir, discardLogger())
+	if err := sc2.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	entry, ok := sc2.GetReport("diag")
+	if !ok {
+		t.Fatal("diag not loaded")
+	}
+	if entry.ExpiresAt == nil || !entry.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("ExpiresAt = %v, want %v", entry.ExpiresAt, expiresAt)
+	}
+}
+
+func TestStateCache_PutReportOverExpiredEntry(t *testing.T) {
+	sc := NewStateCache(t.TempDir(), discardLogger())
+	if err := sc.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	payload := json.RawMessage(`{}`)
+	if _, err := sc.PutReportWithExpiry("probe", "application/json", payload, nil, time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("PutReportWithExpiry: %v", err)
+	}
+
+	// The expired entry counts as absent: If-Match 0 succeeds and the
+	// version starts over.
+	zero := 0
+	entry, err := sc.PutReport("probe", "application/json", payload, &zero)
+	if err != nil {
+		t.Fatalf("PutReport: %v", err)
+	}
+	if entry.Version != 1 {
+		t.Errorf("Version = %d, want 1", entry.Version)
+	}
+	if entry.ExpiresAt != nil {
+		t.Errorf("ExpiresAt = %v, want nil", entry.ExpiresAt)
+	}
+}
+
 func TestStateCache_ConcurrentAccess(t *testing.T) {
 	dir := t.TempDir()
 	sc := NewStateCache(dir, discardLogger())
@@ -528,3 +716,135 @@ func TestStateCache_LoadMissingDir(t *testing.T) {
 		t.Error("reports should be empty")
 	}
 }
+
+func TestWipeStateCache(t *testing.T) {
+	dir := t.TempDir()
+
+	stateDir := filepath.Join(dir, "state")
+	if err := os.MkdirAll(filepath.Join(stateDir, "data"), 0700); err != nil {
+		t.Fatalf("mkdir data: %v", err)
+	}
+	secretsJSON, _ := json.Marshal([]api.SecretRef{{Key: "s1", Version: 2}})
+	if err := os.WriteFile(filepath.Join(stateDir, "secrets.json"), secretsJSON, 0600); err != nil {
+		t.Fatalf("write secrets.json: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(stateDir, "data", "cfg.json"), []byte(`{}`), 0600); err != nil {
+		t.Fatalf("write data entry: %v", err)
+	}
+
+	if err := WipeStateCache(dir); err != nil {
+		t.Fatalf("WipeStateCache: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "state")); !os.IsNotExist(err) {
+		t.Errorf("state dir still exists after wipe: %v", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("data dir removed: %v", err)
+	}
+}
+
+func TestStateCache_DataCacheLimit(t *testing.T) {
+	dir := t.TempDir()
+	sc := NewStateCache(dir, discardLogger())
+	sc.SetDataCacheLimit(20)
+	if err := sc.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	big := json.RawMessage(`"` + strings.Repeat("x", 64) + `"`)
+	sc.UpdateData([]api.DataEntry{
+		{Key: "a", Payload: json.RawMessage(`"aaaaaaaa"`), Version: 1},
+		{Key: "b", Payload: json.RawMessage(`"bbbbbbbb"`), Version: 1},
+		{Key: "big", Payload: big, Version: 3},
+	})
+
+	// Only the payloads within the limit are held in memory.
+	st := sc.DataCacheStats()
+	if st.Entries != 3 || st.PayloadBytes != int64(20+len(big)) {
+		t.Errorf("stats = %+v, want 3 entries of %d bytes", st, 20+len(big))
+	}
+	if st.CachedEntries != 2 || st.CachedBytes != 20 || st.CacheLimit != 20 {
+		t.Errorf("stats = %+v, want a and b cached", st)
+	}
+	if d := sc.GetData(); d["big"].Payload != nil || d["big"].Version != 3 {
+		t.Errorf("GetData()[big] = %+v, want version 3 without payload", d["big"])
+	}
+
+	// An evicted payload is read back from disk.
+	e, ok := sc.GetDataEntry("big")
+	if !ok || string(e.Payload) != string(big) {
+		t.Fatalf("GetDataEntry(big) = %s, %v", e.Payload, ok)
+	}
+	if st := sc.DataCacheStats(); st.CachedBytes != 20 {
+		t.Errorf("CachedBytes = %d after reading a payload above the limit, want 20", st.CachedBytes)
+	}
+
+	// Reading a caches it and evicts b, the least recently used payload.
+	sc.UpdateData([]api.DataEntry{
+		{Key: "a", Payload: json.RawMessage(`"aaaaaaaa"`), Version: 1},
+		{Key: "b", Payload: json.RawMessage(`"bbbbbbbb"`), Version: 1},
+		{Key: "c", Payload: json.RawMessage(`"cccccccc"`), Version: 1},
+	})
+	if _, ok := sc.payloads.get("a"); ok {
+		t.Error("a is cached, want evicted by c")
+	}
+	_, r, err := sc.OpenDataEntry("a")
+	if err != nil {
+		t.Fatalf("OpenDataEntry(a): %v", err)
+	}
+	if p, _ := io.ReadAll(r); string(p) != `"aaaaaaaa"` {
+		t.Errorf("payload = %s", p)
+	}
+	if _, ok := sc.payloads.get("b"); ok {
+		t.Error("b is cached, want evicted by a")
+	}
+	if _, _, err := sc.OpenDataEntry("big"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("OpenDataEntry(big) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStateCache_DataCacheLoad(t *testing.T) {
+	dir := t.TempDir()
+	sc := newEncryptedCache(t, dir, "node-secret")
+	sc.UpdateData([]api.DataEntry{
+		{Key: "a", Payload: json.RawMessage(`{"n":1}`), Version: 1},
+		{Key: "b", Payload: json.RawMessage(`{"n":2}`), Version: 2},
+	})
+
+	// A restarted cache with room for one payload reads the other back
+	// from its encrypted file.
+	sc2 := NewStateCache(dir, discardLogger())
+	if err := sc2.SetEncryptionKey([]byte("node-secret")); err != nil {
+		t.Fatal(err)
+	}
+	sc2.SetDataCacheLimit(8)
+	if err := sc2.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if st := sc2.DataCacheStats(); st.Entries != 2 || st.CachedEntries != 1 {
+		t.Errorf("stats = %+v, want 2 entries, 1 cached", st)
+	}
+	for key, want := range map[string]string{"a": `{"n":1}`, "b": `{"n":2}`} {
+		if e, ok := sc2.GetDataEntry(key); !ok || string(e.Payload) != want {
+			t.Errorf("GetDataEntry(%s) = %s, %v, want %s", key, e.Payload, ok, want)
+		}
+	}
+}
+
+func TestStateCache_DataResidentWhenNotPersisted(t *testing.T) {
+	dir := t.TempDir()
+	sc := NewStateCache(dir, discardLogger())
+	sc.SetDataCacheLimit(1)
+	if err := sc.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	// Without the data directory the entry cannot be written, so its
+	// payload must stay in memory.
+	if err := os.RemoveAll(filepath.Join(dir, "state", "data")); err != nil {
+		t.Fatal(err)
+	}
+	sc.UpdateData([]api.DataEntry{{Key: "cfg", Payload: json.RawMessage(`"v"`), Version: 1}})
+	if e, ok := sc.GetDataEntry("cfg"); !ok || string(e.Payload) != `"v"` {
+		t.Errorf("GetDataEntry(cfg) = %s, %v", e.Payload, ok)
+	}
+}
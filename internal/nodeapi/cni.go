@@ -0,0 +1,113 @@
+package nodeapi
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// CNIAllocation is a container address allocated from the node's container
+// prefix, with the parameters the CNI plugin needs to wire the container.
+type CNIAllocation struct {
+	ContainerID string `json:"container_id"`
+	IfName      string `json:"ifname"`
+	// Address is the container address in CIDR form, e.g. "100.96.3.7/32".
+	Address string `json:"address"`
+	// Gateway is the next hop the container routes through.
+	Gateway string `json:"gateway"`
+	// HostInterface is the name of the host end of the veth pair.
+	HostInterface string `json:"host_interface"`
+	MTU           int    `json:"mtu"`
+}
+
+// CNIAllocationRequest is the request body for POST /v1/cni/allocations.
+type CNIAllocationRequest struct {
+	ContainerID string `json:"container_id"`
+	IfName      string `json:"ifname"`
+}
+
+// ContainerNetwork allocates container addresses for the CNI plugin.
+// *cni.Manager satisfies this interface.
+type ContainerNetwork interface {
+	// Allocate returns the allocation for the container interface,
+	// allocating an address if it has none. Repeated calls return the same
+	// allocation.
+	Allocate(containerID, ifName string) (CNIAllocation, error)
+
+	// Release frees the container interface's address.
+	// Idempotent: releasing an unknown interface returns nil.
+	Release(containerID, ifName string) error
+
+	// Allocations returns all current allocations.
+	Allocations() []CNIAllocation
+}
+
+// maxCNIBodyBytes is the maximum allowed request body size for CNI
+// allocation requests (4 KiB).
+const maxCNIBodyBytes = 4 << 10
+
+// SetContainerNetwork sets the allocator behind /v1/cni/allocations. If not
+// set, the endpoints return 503.
+func (h *Handler) SetContainerNetwork(cn ContainerNetwork) {
+	h.containerNet = cn
+}
+
+func (h *Handler) handleGetCNIAllocations(w http.ResponseWriter, r *http.Request) {
+	if h.containerNet == nil {
+		writeError(w, http.StatusServiceUnavailable, "container network not available")
+		return
+	}
+	allocs := h.containerNet.Allocations()
+	if allocs == nil {
+		allocs = []CNIAllocation{}
+	}
+	writeJSON(w, http.StatusOK, allocs)
+}
+
+func (h *Handler) handlePostCNIAllocation(w http.ResponseWriter, r *http.Request) {
+	if h.containerNet == nil {
+		writeError(w, http.StatusServiceUnavailable, "container network not available")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxCNIBodyBytes)
+
+	var req CNIAllocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if req.ContainerID == "" || req.IfName == "" {
+		writeError(w, http.StatusBadRequest, "container_id and ifname are required")
+		return
+	}
+
+	alloc, err := h.containerNet.Allocate(req.ContainerID, req.IfName)
+	if err != nil {
+		h.logger.Warn("container address allocation failed",
+			"container_id", req.ContainerID,
+			"ifname", req.IfName,
+			"error", err,
+		)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, alloc)
+}
+
+func (h *Handler) handleDeleteCNIAllocation(w http.ResponseWriter, r *http.Request) {
+	if h.containerNet == nil {
+		writeError(w, http.StatusServiceUnavailable, "container network not available")
+		return
+	}
+	containerID, ifName := r.PathValue("container_id"), r.PathValue("ifname")
+	if err := h.containerNet.Release(containerID, ifName); err != nil {
+		h.logger.Warn("container address release failed",
+			"container_id", containerID,
+			"ifname", ifName,
+			"error", err,
+		)
+		writeError(w, http.StatusInternalServerError, "release failed")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
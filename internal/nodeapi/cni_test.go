@@ -0,0 +1,125 @@
+package nodeapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type mockContainerNetwork struct {
+	allocs   map[string]CNIAllocation
+	err      error
+	released []string
+}
+
+func (m *mockContainerNetwork) Allocate(containerID, ifName string) (CNIAllocation, error) {
+	if m.err != nil {
+		return CNIAllocation{}, m.err
+	}
+	a := CNIAllocation{ContainerID: containerID, IfName: ifName, Address: "100.96.3.1/32"}
+	m.allocs[containerID+"/"+ifName] = a
+	return a, nil
+}
+
+func (m *mockContainerNetwork) Release(containerID, ifName string) error {
+	m.released = append(m.released, containerID+"/"+ifName)
+	delete(m.allocs, containerID+"/"+ifName)
+	return nil
+}
+
+func (m *mockContainerNetwork) Allocations() []CNIAllocation {
+	var out []CNIAllocation
+	for _, a := range m.allocs {
+		out = append(out, a)
+	}
+	return out
+}
+
+func newCNITestServer(t *testing.T, cn ContainerNetwork) *httptest.Server {
+	t.Helper()
+	cache := NewStateCache(t.TempDir(), discardLogger())
+	if err := cache.Load(); err != nil {
+		t.Fatalf("cache.Load: %v", err)
+	}
+	h := NewHandler(cache, &mockSecretFetcher{}, "node-1", testKey(t), discardLogger())
+	if cn != nil {
+		h.SetContainerNetwork(cn)
+	}
+	srv := httptest.NewServer(h.Mux())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHandler_CNI_NotConfigured(t *testing.T) {
+	srv := newCNITestServer(t, nil)
+
+	resp := mustGet(t, srv.URL+"/v1/cni/allocations")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("GET status = %d, want 503", resp.StatusCode)
+	}
+}
+
+func TestHandler_CNI_AllocateAndRelease(t *testing.T) {
+	cn := &mockContainerNetwork{allocs: map[string]CNIAllocation{}}
+	srv := newCNITestServer(t, cn)
+
+	resp, err := http.Post(srv.URL+"/v1/cni/allocations", "application/json",
+		strings.NewReader(`{"container_id":"c1","ifname":"eth0"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var alloc CNIAllocation
+	decodeJSON(t, resp, &alloc)
+	if resp.StatusCode != http.StatusOK || alloc.Address != "100.96.3.1/32" {
+		t.Fatalf("POST status = %d, alloc = %+v", resp.StatusCode, alloc)
+	}
+
+	resp = mustGet(t, srv.URL+"/v1/cni/allocations")
+	var allocs []CNIAllocation
+	decodeJSON(t, resp, &allocs)
+	if len(allocs) != 1 {
+		t.Errorf("GET allocations = %+v, want 1", allocs)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/v1/cni/allocations/c1/eth0", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE status = %d, want 204", resp.StatusCode)
+	}
+	if len(cn.released) != 1 || cn.released[0] != "c1/eth0" {
+		t.Errorf("released = %v", cn.released)
+	}
+}
+
+func TestHandler_CNI_AllocateErrors(t *testing.T) {
+	cn := &mockContainerNetwork{allocs: map[string]CNIAllocation{}}
+	srv := newCNITestServer(t, cn)
+
+	resp, err := http.Post(srv.URL+"/v1/cni/allocations", "application/json", strings.NewReader(`{"container_id":"c1"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("missing ifname: status = %d, want 400", resp.StatusCode)
+	}
+
+	cn.err = errors.New("cni: no container prefix assigned to this node")
+	resp, err = http.Post(srv.URL+"/v1/cni/allocations", "application/json",
+		strings.NewReader(`{"container_id":"c1","ifname":"eth0"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]string
+	decodeJSON(t, resp, &body)
+	if resp.StatusCode != http.StatusServiceUnavailable || !strings.Contains(body["error"], "no container prefix") {
+		t.Errorf("status = %d, body = %v", resp.StatusCode, body)
+	}
+}
@@ -2,14 +2,17 @@ package nodeapi
 
 import (
 	"errors"
+	"fmt"
+	"slices"
 	"time"
 )
 
 // Config holds the configuration for the local node API server.
 // Config is passed as a constructor argument — no file I/O in this package.
 type Config struct {
-	// SocketPath is the path to the Unix domain socket.
-	// Default: /var/run/plexd/api.sock
+	// SocketPath is the path to the Unix domain socket, or the named pipe
+	// on Windows.
+	// Default: /var/run/plexd/api.sock (Windows: \\.\pipe\plexd-api)
 	SocketPath string
 
 	// HTTPEnabled enables the optional HTTP listener.
@@ -20,13 +23,39 @@ type Config struct {
 	// Default: 127.0.0.1:9100
 	HTTPListen string
 
+	// ProbeListen is the listen address of an unauthenticated HTTP listener
+	// that serves only GET /healthz and GET /readyz, for container
+	// orchestrator probes.
+	// Default: "" (disabled)
+	ProbeListen string
+
 	// HTTPTokenFile is the path to the HTTP bearer token file.
 	HTTPTokenFile string
 
+	// HTTPTokenDir is a directory of scoped client tokens, one
+	// {client}.json file per client (see LoadTokenDir). Its tokens are
+	// accepted on the TCP listener and, optionally, on the Unix socket.
+	// The token in HTTPTokenFile keeps all scopes.
+	// Default: "" (no scoped tokens)
+	HTTPTokenDir string
+
 	// DebouncePeriod is the debounce period for coalescing events.
 	// Default: 5s
 	DebouncePeriod time.Duration
 
+	// ReportSyncMaxDelay bounds how long report changes may be held back
+	// while new changes keep arriving within DebouncePeriod. Every change
+	// restarts the DebouncePeriod wait, up to ReportSyncMaxDelay after the
+	// first unsynced change. A value not above DebouncePeriod flushes
+	// DebouncePeriod after the first change.
+	// Default: 30s
+	ReportSyncMaxDelay time.Duration
+
+	// ReportGCInterval is how often expired report entries are deleted
+	// and synced as deletions to the control plane.
+	// Default: 1m
+	ReportGCInterval time.Duration
+
 	// ShutdownTimeout is the maximum time to wait for a graceful shutdown.
 	// Default: 5s
 	ShutdownTimeout time.Duration
@@ -34,15 +63,37 @@ type Config struct {
 	// DataDir is the path to the data directory (required).
 	DataDir string
 
+	// DataCacheBytes bounds the memory held by data entry payloads, in
+	// bytes. Least recently used payloads beyond it are evicted and read
+	// back from the state cache files on access.
+	// Default: 32 MiB
+	DataCacheBytes int64
+
+	// SecretDecryptWorkers bounds the number of secret decryptions that run
+	// at once. Further secret reads wait for a free worker.
+	// Default: 4
+	SecretDecryptWorkers int
+
 	// SecretAuthEnabled enables SO_PEERCRED-based authentication for
 	// /v1/state/secrets/* routes on the Unix socket. When enabled, only
 	// root (UID 0) or plexd-secrets group members may access secrets.
 	// Default: false (enabled by cmd/plexd/cmd/up.go in production).
 	SecretAuthEnabled bool
-}
 
-// DefaultSocketPath is the default Unix domain socket path.
-const DefaultSocketPath = "/var/run/plexd/api.sock"
+	// PeerAuth restricts scopes on the Unix socket to local users and
+	// groups, keyed by scope (e.g. "secrets:read") and identified via
+	// SO_PEERCRED (Linux only). Root is always allowed; scopes without an
+	// entry are not restricted. SecretAuthEnabled adds a secrets:read entry
+	// for the plexd-secrets group unless one is configured.
+	// Default: none
+	PeerAuth map[string]PeerAccess
+
+	// MetadataWritePrefix enables PUT /v1/state/metadata/{key} for keys
+	// starting with this prefix, e.g. "label.". Written keys are synced to
+	// the control plane as node labels.
+	// Default: "" (metadata is read-only).
+	MetadataWritePrefix string
+}
 
 // DefaultHTTPListen is the default HTTP listen address.
 const DefaultHTTPListen = "127.0.0.1:9100"
@@ -50,9 +101,25 @@ const DefaultHTTPListen = "127.0.0.1:9100"
 // DefaultDebouncePeriod is the default debounce period.
 const DefaultDebouncePeriod = 5 * time.Second
 
+// DefaultReportSyncMaxDelay is the default upper bound of the report sync
+// coalescing window.
+const DefaultReportSyncMaxDelay = 30 * time.Second
+
+// DefaultReportGCInterval is the default interval for deleting expired
+// report entries.
+const DefaultReportGCInterval = time.Minute
+
 // DefaultShutdownTimeout is the default graceful shutdown timeout.
 const DefaultShutdownTimeout = 5 * time.Second
 
+// DefaultSecretDecryptWorkers is the default number of concurrent secret
+// decryptions.
+const DefaultSecretDecryptWorkers = 4
+
+// DefaultDataCacheBytes is the default bound of the memory held by data
+// entry payloads (32 MiB).
+const DefaultDataCacheBytes = 32 << 20
+
 // ApplyDefaults sets default values for zero-valued fields.
 func (c *Config) ApplyDefaults() {
 	if c.SocketPath == "" {
@@ -67,6 +134,18 @@ func (c *Config) ApplyDefaults() {
 	if c.ShutdownTimeout == 0 {
 		c.ShutdownTimeout = DefaultShutdownTimeout
 	}
+	if c.ReportSyncMaxDelay == 0 {
+		c.ReportSyncMaxDelay = DefaultReportSyncMaxDelay
+	}
+	if c.ReportGCInterval == 0 {
+		c.ReportGCInterval = DefaultReportGCInterval
+	}
+	if c.DataCacheBytes == 0 {
+		c.DataCacheBytes = DefaultDataCacheBytes
+	}
+	if c.SecretDecryptWorkers == 0 {
+		c.SecretDecryptWorkers = DefaultSecretDecryptWorkers
+	}
 }
 
 // Validate checks that required fields are set and values are acceptable.
@@ -80,5 +159,25 @@ func (c *Config) Validate() error {
 	if c.ShutdownTimeout <= 0 {
 		return errors.New("nodeapi: config: ShutdownTimeout must be positive")
 	}
+	if c.ReportSyncMaxDelay < 0 {
+		return errors.New("nodeapi: config: ReportSyncMaxDelay must not be negative")
+	}
+	if c.ReportGCInterval < 0 {
+		return errors.New("nodeapi: config: ReportGCInterval must not be negative")
+	}
+	if c.DataCacheBytes < 0 {
+		return errors.New("nodeapi: config: DataCacheBytes must not be negative")
+	}
+	if c.SecretDecryptWorkers < 0 {
+		return errors.New("nodeapi: config: SecretDecryptWorkers must not be negative")
+	}
+	if c.MetadataWritePrefix != "" && !validReportKey(c.MetadataWritePrefix) {
+		return errors.New("nodeapi: config: MetadataWritePrefix must not contain path separators")
+	}
+	for scope := range c.PeerAuth {
+		if !slices.Contains(AllScopes, scope) {
+			return fmt.Errorf("nodeapi: config: PeerAuth has unknown scope %q", scope)
+		}
+	}
 	return nil
 }
@@ -106,6 +106,19 @@ func TestConfig_ValidateAcceptsValid(t *testing.T) {
 	}
 }
 
+func TestConfig_ValidateRejectsMetadataWritePrefixWithSeparator(t *testing.T) {
+	cfg := Config{DataDir: "/var/lib/plexd", MetadataWritePrefix: "labels/"}
+	cfg.ApplyDefaults()
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected error for MetadataWritePrefix with path separator")
+	}
+	want := "nodeapi: config: MetadataWritePrefix must not contain path separators"
+	if err.Error() != want {
+		t.Errorf("error = %q, want %q", err.Error(), want)
+	}
+}
+
 func TestConfig_ValidateAcceptsDefaults(t *testing.T) {
 	cfg := Config{DataDir: "/var/lib/plexd"}
 	cfg.ApplyDefaults()
@@ -113,3 +126,85 @@ func TestConfig_ValidateAcceptsDefaults(t *testing.T) {
 		t.Errorf("Validate() = %v, want nil", err)
 	}
 }
+
+func TestConfig_ValidateRejectsUnknownPeerAuthScope(t *testing.T) {
+	cfg := Config{
+		DataDir:  "/var/lib/plexd",
+		PeerAuth: map[string]PeerAccess{"secrets:write": {Groups: []string{"plexd-secrets"}}},
+	}
+	cfg.ApplyDefaults()
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected error for unknown PeerAuth scope")
+	}
+	want := `nodeapi: config: PeerAuth has unknown scope "secrets:write"`
+	if err.Error() != want {
+		t.Errorf("error = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestConfig_ReportGCIntervalDefault(t *testing.T) {
+	cfg := Config{DataDir: "/var/lib/plexd"}
+	cfg.ApplyDefaults()
+	if cfg.ReportGCInterval != DefaultReportGCInterval {
+		t.Errorf("ReportGCInterval = %v, want %v", cfg.ReportGCInterval, DefaultReportGCInterval)
+	}
+}
+
+func TestConfig_ValidateRejectsNegativeReportGCInterval(t *testing.T) {
+	cfg := Config{DataDir: "/var/lib/plexd", ReportGCInterval: -time.Second}
+	cfg.ApplyDefaults()
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected error for negative ReportGCInterval")
+	}
+	want := "nodeapi: config: ReportGCInterval must not be negative"
+	if err.Error() != want {
+		t.Errorf("error = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestConfig_ReportSyncMaxDelay(t *testing.T) {
+	cfg := Config{DataDir: "/var/lib/plexd"}
+	cfg.ApplyDefaults()
+	if cfg.ReportSyncMaxDelay != DefaultReportSyncMaxDelay {
+		t.Errorf("ReportSyncMaxDelay = %v, want %v", cfg.ReportSyncMaxDelay, DefaultReportSyncMaxDelay)
+	}
+
+	cfg.ReportSyncMaxDelay = -time.Second
+	err := cfg.Validate()
+	want := "nodeapi: config: ReportSyncMaxDelay must not be negative"
+	if err == nil || err.Error() != want {
+		t.Errorf("Validate() = %v, want %q", err, want)
+	}
+}
+
+func TestConfig_DataCacheBytes(t *testing.T) {
+	cfg := Config{DataDir: "/var/lib/plexd"}
+	cfg.ApplyDefaults()
+	if cfg.DataCacheBytes != DefaultDataCacheBytes {
+		t.Errorf("DataCacheBytes = %d, want %d", cfg.DataCacheBytes, DefaultDataCacheBytes)
+	}
+
+	cfg.DataCacheBytes = -1
+	err := cfg.Validate()
+	want := "nodeapi: config: DataCacheBytes must not be negative"
+	if err == nil || err.Error() != want {
+		t.Errorf("Validate() = %v, want %q", err, want)
+	}
+}
+
+func TestConfig_SecretDecryptWorkers(t *testing.T) {
+	cfg := Config{DataDir: "/var/lib/plexd"}
+	cfg.ApplyDefaults()
+	if cfg.SecretDecryptWorkers != DefaultSecretDecryptWorkers {
+		t.Errorf("SecretDecryptWorkers = %d, want %d", cfg.SecretDecryptWorkers, DefaultSecretDecryptWorkers)
+	}
+
+	cfg.SecretDecryptWorkers = -1
+	err := cfg.Validate()
+	want := "nodeapi: config: SecretDecryptWorkers must not be negative"
+	if err == nil || err.Error() != want {
+		t.Errorf("Validate() = %v, want %q", err, want)
+	}
+}
@@ -0,0 +1,147 @@
+package nodeapi
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/plexsphere/plexd/internal/logging"
+)
+
+// maxLogLevelBodyBytes bounds the body of PUT /v1/debug/loglevel.
+const maxLogLevelBodyBytes = 1024
+
+// LogLevelController reads and changes the log levels of the running agent.
+// *logging.Levels satisfies this interface.
+type LogLevelController interface {
+	// Snapshot returns the default level and the component levels.
+	Snapshot() logging.LevelSnapshot
+	// Set sets the level of component, or the default level if component
+	// is empty.
+	Set(component string, level slog.Level)
+	// Reset makes component log at the default level again.
+	Reset(component string)
+}
+
+// LogCapture holds recent log lines in memory.
+// *logging.Ring satisfies this interface.
+type LogCapture interface {
+	// Size returns the number of lines kept; 0 if capture is disabled.
+	Size() int
+	// Lines returns the kept lines of component (all if empty), oldest
+	// first, at most limit of them if limit is positive.
+	Lines(component string, limit int) []string
+}
+
+// LogLevelRequest is the body of PUT /v1/debug/loglevel.
+type LogLevelRequest struct {
+	// Component is the component to change; empty for the default level.
+	Component string `json:"component,omitempty"`
+	// Level is "debug", "info", "warn", or "error". Empty resets
+	// Component to the default level.
+	Level string `json:"level"`
+}
+
+// CapturedLogs is the response of GET /v1/debug/logs.
+type CapturedLogs struct {
+	// Size is the number of lines the agent keeps (log_capture).
+	Size  int      `json:"size"`
+	Lines []string `json:"lines"`
+}
+
+// SetLogLevels sets the controller behind /v1/debug/loglevel. If not set,
+// the endpoints return 503.
+func (h *Handler) SetLogLevels(lc LogLevelController) {
+	h.logLevels = lc
+}
+
+// SetLogCapture sets the source of GET /v1/debug/logs. If not set, the
+// endpoint returns 503.
+func (h *Handler) SetLogCapture(lc LogCapture) {
+	h.logCapture = lc
+}
+
+func (h *Handler) handleGetLogLevel(w http.ResponseWriter, r *http.Request) {
+	if h.logLevels == nil {
+		writeError(w, http.StatusServiceUnavailable, "log levels not available")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.logLevels.Snapshot())
+}
+
+// handlePutLogLevel changes the default level or the level of one
+// component until the next config reload that changes log_level or
+// log_levels, or the next restart.
+func (h *Handler) handlePutLogLevel(w http.ResponseWriter, r *http.Request) {
+	if h.logLevels == nil {
+		writeError(w, http.StatusServiceUnavailable, "log levels not available")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxLogLevelBodyBytes)
+
+	var req LogLevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	attrs := []any{"target", req.Component}
+	if req.Component == "" {
+		attrs = []any{"target", "default"}
+	}
+	if c := ClientFromContext(r.Context()); c != nil {
+		attrs = append(attrs, "client", c.Name)
+	} else if cred := requestPeerCredentials(r); cred != nil {
+		attrs = append(attrs, "uid", cred.UID, "pid", cred.PID)
+	}
+
+	if req.Level == "" {
+		if req.Component == "" {
+			writeError(w, http.StatusBadRequest, "level is required for the default level")
+			return
+		}
+		h.logLevels.Reset(req.Component)
+		h.logger.Info("log level reset via node API", attrs...)
+		writeJSON(w, http.StatusOK, h.logLevels.Snapshot())
+		return
+	}
+	level, err := logging.ParseLevel(req.Level)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid level: must be debug, info, warn, or error")
+		return
+	}
+	h.logLevels.Set(req.Component, level)
+	h.logger.Info("log level changed via node API", append(attrs, "level", logging.LevelName(level))...)
+	writeJSON(w, http.StatusOK, h.logLevels.Snapshot())
+}
+
+// handleGetLogs returns the captured log lines, oldest first. The optional
+// query parameters component and lines narrow the result to one component
+// and to the most recent lines.
+func (h *Handler) handleGetLogs(w http.ResponseWriter, r *http.Request) {
+	if h.logCapture == nil {
+		writeError(w, http.StatusServiceUnavailable, "log capture not available")
+		return
+	}
+	size := h.logCapture.Size()
+	if size == 0 {
+		writeError(w, http.StatusServiceUnavailable, "log capture disabled (set log_capture)")
+		return
+	}
+	limit := 0
+	if s := r.URL.Query().Get("lines"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "invalid lines: must be a positive integer")
+			return
+		}
+		limit = n
+	}
+	lines := h.logCapture.Lines(r.URL.Query().Get("component"), limit)
+	if lines == nil {
+		lines = []string{}
+	}
+	writeJSON(w, http.StatusOK, CapturedLogs{Size: size, Lines: lines})
+}
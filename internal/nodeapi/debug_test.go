@@ -0,0 +1,140 @@
+package nodeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/logging"
+)
+
+func newDebugTestServer(t *testing.T, levels LogLevelController, capture LogCapture) *httptest.Server {
+	t.Helper()
+	cache := NewStateCache(t.TempDir(), discardLogger())
+	if err := cache.Load(); err != nil {
+		t.Fatalf("cache.Load: %v", err)
+	}
+	h := NewHandler(cache, &mockSecretFetcher{}, "node-1", testKey(t), discardLogger())
+	if levels != nil {
+		h.SetLogLevels(levels)
+	}
+	if capture != nil {
+		h.SetLogCapture(capture)
+	}
+	srv := httptest.NewServer(h.Mux())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func putLogLevel(t *testing.T, url, body string) (*http.Response, logging.LevelSnapshot) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPut, url+"/v1/debug/loglevel", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var snap logging.LevelSnapshot
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&snap); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	return resp, snap
+}
+
+func TestHandler_LogLevel(t *testing.T) {
+	levels := logging.NewLevels(slog.LevelInfo)
+	srv := newDebugTestServer(t, levels, nil)
+
+	resp, snap := putLogLevel(t, srv.URL, `{"component": "bridge", "level": "debug"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT status = %d, want 200", resp.StatusCode)
+	}
+	if snap.Default != "info" || snap.Components["bridge"] != "debug" {
+		t.Errorf("snapshot = %+v", snap)
+	}
+	if levels.Level("bridge") != slog.LevelDebug {
+		t.Errorf("bridge level = %v, want DEBUG", levels.Level("bridge"))
+	}
+
+	if resp, _ := putLogLevel(t, srv.URL, `{"level": "warn"}`); resp.StatusCode != http.StatusOK {
+		t.Errorf("PUT default status = %d, want 200", resp.StatusCode)
+	}
+	if resp, snap = putLogLevel(t, srv.URL, `{"component": "bridge"}`); resp.StatusCode != http.StatusOK || len(snap.Components) != 0 {
+		t.Errorf("reset: status = %d, snapshot = %+v", resp.StatusCode, snap)
+	}
+
+	get := mustGet(t, srv.URL+"/v1/debug/loglevel")
+	defer get.Body.Close()
+	if err := json.NewDecoder(get.Body).Decode(&snap); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if snap.Default != "warn" {
+		t.Errorf("GET default = %q, want warn", snap.Default)
+	}
+
+	for _, body := range []string{`{"level": "verbose"}`, `{}`, `not json`} {
+		if resp, _ := putLogLevel(t, srv.URL, body); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("PUT %s: status = %d, want 400", body, resp.StatusCode)
+		}
+	}
+}
+
+func TestHandler_LogLevel_NotConfigured(t *testing.T) {
+	srv := newDebugTestServer(t, nil, nil)
+	for _, path := range []string{"/v1/debug/loglevel", "/v1/debug/logs"} {
+		resp := mustGet(t, srv.URL+path)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("GET %s: status = %d, want 503", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestHandler_Logs(t *testing.T) {
+	ring := logging.NewRing(0)
+	srv := newDebugTestServer(t, nil, ring)
+
+	resp := mustGet(t, srv.URL+"/v1/debug/logs")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("disabled capture: status = %d, want 503", resp.StatusCode)
+	}
+
+	ring.Resize(10)
+	logger := slog.New(slog.NewTextHandler(ring, nil))
+	for i := 0; i < 3; i++ {
+		logger.Info(fmt.Sprintf("bridge %d", i), "component", "bridge")
+		logger.Info(fmt.Sprintf("api %d", i), "component", "api")
+	}
+
+	resp = mustGet(t, srv.URL+"/v1/debug/logs?component=bridge&lines=2")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var logs CapturedLogs
+	if err := json.NewDecoder(resp.Body).Decode(&logs); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if logs.Size != 10 || len(logs.Lines) != 2 {
+		t.Fatalf("logs = %+v, want size 10 and 2 lines", logs)
+	}
+	if !strings.Contains(logs.Lines[0], "bridge 1") || !strings.Contains(logs.Lines[1], "bridge 2") {
+		t.Errorf("lines = %q", logs.Lines)
+	}
+
+	resp2 := mustGet(t, srv.URL+"/v1/debug/logs?lines=0")
+	resp2.Body.Close()
+	if resp2.StatusCode != http.StatusBadRequest {
+		t.Errorf("lines=0: status = %d, want 400", resp2.StatusCode)
+	}
+}
@@ -1,49 +1,122 @@
 package nodeapi
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"encoding/base64"
 	"errors"
+	"sync"
 )
 
+// errDecrypt is returned for every decryption failure, to avoid leaking
+// cryptographic details.
+var errDecrypt = errors.New("nodeapi: decryption failed")
+
 // DecryptSecret decrypts an AES-256-GCM encrypted secret.
 // The ciphertext and nonce are base64-encoded (standard encoding).
 // The NSK (node secret key) must be exactly 32 bytes.
 // Returns a generic error on failure to avoid leaking cryptographic details.
+// The AES-GCM instance of the NSK is cached, so the key schedule is not
+// computed again on every call.
 func DecryptSecret(nsk []byte, ciphertext string, nonce string) (string, error) {
-	if len(nsk) != 32 {
-		return "", errors.New("nodeapi: decryption failed")
+	gcm, err := secretAEADs.get(nsk)
+	if err != nil {
+		return "", errDecrypt
 	}
 
 	ciphertextBytes, err := base64.StdEncoding.DecodeString(ciphertext)
 	if err != nil {
-		return "", errors.New("nodeapi: decryption failed")
+		return "", errDecrypt
 	}
 
 	nonceBytes, err := base64.StdEncoding.DecodeString(nonce)
 	if err != nil {
-		return "", errors.New("nodeapi: decryption failed")
+		return "", errDecrypt
 	}
 
-	block, err := aes.NewCipher(nsk)
-	if err != nil {
-		return "", errors.New("nodeapi: decryption failed")
+	if len(nonceBytes) != gcm.NonceSize() {
+		return "", errDecrypt
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	plaintext, err := gcm.Open(nil, nonceBytes, ciphertextBytes, nil)
 	if err != nil {
-		return "", errors.New("nodeapi: decryption failed")
+		return "", errDecrypt
 	}
 
-	if len(nonceBytes) != gcm.NonceSize() {
-		return "", errors.New("nodeapi: decryption failed")
+	return string(plaintext), nil
+}
+
+// maxCachedAEADs bounds the number of keys in an aeadCache. A node has one
+// NSK, and a few more after re-registration.
+const maxCachedAEADs = 8
+
+// aeadCache caches the AES-GCM instances of NSKs. The instances are safe
+// for concurrent use, so lookups of cached keys only take a read lock.
+type aeadCache struct {
+	mu sync.RWMutex
+	m  map[[32]byte]cipher.AEAD
+}
+
+// secretAEADs is the cache used by DecryptSecret.
+var secretAEADs = &aeadCache{m: make(map[[32]byte]cipher.AEAD)}
+
+// get returns the AES-GCM instance of nsk, creating and caching it on the
+// first use.
+func (c *aeadCache) get(nsk []byte) (cipher.AEAD, error) {
+	if len(nsk) != 32 {
+		return nil, errDecrypt
 	}
+	k := [32]byte(nsk)
 
-	plaintext, err := gcm.Open(nil, nonceBytes, ciphertextBytes, nil)
+	c.mu.RLock()
+	gcm, ok := c.m[k]
+	c.mu.RUnlock()
+	if ok {
+		return gcm, nil
+	}
+
+	block, err := aes.NewCipher(nsk)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err = cipher.NewGCM(block)
 	if err != nil {
-		return "", errors.New("nodeapi: decryption failed")
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.m) >= maxCachedAEADs {
+		clear(c.m)
 	}
+	c.m[k] = gcm
+	return gcm, nil
+}
 
-	return string(plaintext), nil
+// secretDecryptor runs secret decryptions on a bounded number of workers,
+// so a burst of secret reads, such as many applications starting at once,
+// does not occupy every CPU. Requests beyond the limit wait for a free
+// worker. It is shared by all handlers of a Server.
+type secretDecryptor struct {
+	workers chan struct{}
+}
+
+// newSecretDecryptor returns a secretDecryptor with the given number of
+// workers, at least one.
+func newSecretDecryptor(workers int) *secretDecryptor {
+	workers = max(workers, 1)
+	return &secretDecryptor{workers: make(chan struct{}, workers)}
+}
+
+// decrypt waits for a free worker and decrypts the secret with
+// DecryptSecret. It returns ctx.Err() if ctx is done before a worker is
+// free.
+func (d *secretDecryptor) decrypt(ctx context.Context, nsk []byte, ciphertext, nonce string) (string, error) {
+	select {
+	case d.workers <- struct{}{}:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	defer func() { <-d.workers }()
+	return DecryptSecret(nsk, ciphertext, nonce)
 }
@@ -1,13 +1,19 @@
 package nodeapi
 
 import (
+	"bytes"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func testEncrypt(t *testing.T, key []byte, plaintext string) (ciphertext, nonce string) {
@@ -171,3 +177,96 @@ func TestDecryptSecret_ErrorMessageGeneric(t *testing.T) {
 		})
 	}
 }
+
+func TestAEADCache_ReusesInstance(t *testing.T) {
+	c := &aeadCache{m: make(map[[32]byte]cipher.AEAD)}
+	key := testKey(t)
+	a, err := c.get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.get(bytes.Clone(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Error("get() returned a new AEAD for a cached key")
+	}
+	if _, err := c.get(key[:16]); err == nil {
+		t.Error("get() with a 16-byte key = nil error, want error")
+	}
+
+	// The cache stays bounded.
+	for range maxCachedAEADs + 1 {
+		if _, err := c.get(testKey(t)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(c.m); n > maxCachedAEADs {
+		t.Errorf("cache holds %d keys, want at most %d", n, maxCachedAEADs)
+	}
+}
+
+func TestSecretDecryptor_Concurrent(t *testing.T) {
+	d := newSecretDecryptor(2)
+	key := testKey(t)
+	ct, nonce := testEncrypt(t, key, "burst")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for range 64 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := d.decrypt(context.Background(), key, ct, nonce)
+			if err == nil && got != "burst" {
+				err = fmt.Errorf("decrypt() = %q, want burst", got)
+			}
+			if err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestSecretDecryptor_WaitsForWorker(t *testing.T) {
+	d := newSecretDecryptor(1)
+	key := testKey(t)
+	ct, nonce := testEncrypt(t, key, "queued")
+
+	// Occupy the only worker.
+	d.workers <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := d.decrypt(ctx, key, ct, nonce); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("decrypt() with all workers busy = %v, want DeadlineExceeded", err)
+	}
+
+	// Once the worker is free the decryption runs.
+	<-d.workers
+	if got, err := d.decrypt(context.Background(), key, ct, nonce); err != nil || got != "queued" {
+		t.Errorf("decrypt() = %q, %v", got, err)
+	}
+}
+
+func BenchmarkDecryptSecret(b *testing.B) {
+	key := make([]byte, 32)
+	block, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCM(block)
+	nonce := make([]byte, gcm.NonceSize())
+	ct := base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, []byte("db-password"), nil))
+	n := base64.StdEncoding.EncodeToString(nonce)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := DecryptSecret(key, ct, n); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package nodeapi
+
+import (
+	"net/http"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// EventStream reports the state of the control plane event stream and
+// forces it to reconnect. *api.SSEManager satisfies this interface.
+type EventStream interface {
+	// Status returns the state of the event stream.
+	Status() api.SSEStatus
+	// Reconnect closes the open connection so that a new one is
+	// established at once. It reports whether a connection was open.
+	Reconnect() bool
+}
+
+// SetEventStream sets the source of GET /v1/events/status and POST
+// /v1/events/reconnect. If not set, those endpoints return 503.
+func (h *Handler) SetEventStream(es EventStream) {
+	h.eventStream = es
+}
+
+func (h *Handler) handleGetEventsStatus(w http.ResponseWriter, r *http.Request) {
+	if h.eventStream == nil {
+		writeError(w, http.StatusServiceUnavailable, "event stream not available")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.eventStream.Status())
+}
+
+// handlePostEventsReconnect closes the open event stream connection so that
+// the agent reconnects at once. While the stream is not connected the
+// request is rejected with 409; the agent is already reconnecting.
+func (h *Handler) handlePostEventsReconnect(w http.ResponseWriter, r *http.Request) {
+	if h.eventStream == nil {
+		writeError(w, http.StatusServiceUnavailable, "event stream not available")
+		return
+	}
+	if !h.eventStream.Reconnect() {
+		writeError(w, http.StatusConflict, "event stream not connected")
+		return
+	}
+	attrs := []any{}
+	if c := ClientFromContext(r.Context()); c != nil {
+		attrs = append(attrs, "client", c.Name)
+	} else if cred := requestPeerCredentials(r); cred != nil {
+		attrs = append(attrs, "uid", cred.UID, "pid", cred.PID)
+	}
+	h.logger.Info("event stream reconnect requested via node API", attrs...)
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "reconnecting"})
+}
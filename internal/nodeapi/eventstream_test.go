@@ -0,0 +1,101 @@
+package nodeapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+type mockEventStream struct {
+	status     api.SSEStatus
+	reconnects int
+}
+
+func (m *mockEventStream) Status() api.SSEStatus { return m.status }
+
+func (m *mockEventStream) Reconnect() bool {
+	if !m.status.Connected {
+		return false
+	}
+	m.reconnects++
+	return true
+}
+
+func newEventStreamTestServer(t *testing.T, es EventStream) *httptest.Server {
+	t.Helper()
+	cache := NewStateCache(t.TempDir(), discardLogger())
+	if err := cache.Load(); err != nil {
+		t.Fatalf("cache.Load: %v", err)
+	}
+	h := NewHandler(cache, &mockSecretFetcher{}, "node-1", testKey(t), discardLogger())
+	if es != nil {
+		h.SetEventStream(es)
+	}
+	srv := httptest.NewServer(h.Mux())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHandler_EventStream_NotConfigured(t *testing.T) {
+	srv := newEventStreamTestServer(t, nil)
+
+	for _, tc := range []struct{ method, path string }{
+		{http.MethodGet, "/v1/events/status"},
+		{http.MethodPost, "/v1/events/reconnect"},
+	} {
+		resp := doReconcileRequest(t, tc.method, srv.URL+tc.path)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("%s %s: status = %d, want 503", tc.method, tc.path, resp.StatusCode)
+		}
+	}
+}
+
+func TestHandler_EventStreamStatus(t *testing.T) {
+	es := &mockEventStream{status: api.SSEStatus{
+		Running:          true,
+		Connected:        true,
+		LastEventID:      "evt-9",
+		LastEventAgeNano: 1500,
+		EventsReceived:   9,
+		Reconnects:       2,
+	}}
+	srv := newEventStreamTestServer(t, es)
+
+	resp := mustGet(t, srv.URL+"/v1/events/status")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var got api.SSEStatus
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got != es.status {
+		t.Errorf("status = %+v, want %+v", got, es.status)
+	}
+}
+
+func TestHandler_EventStreamReconnect(t *testing.T) {
+	es := &mockEventStream{status: api.SSEStatus{Running: true, Connected: true}}
+	srv := newEventStreamTestServer(t, es)
+
+	resp := doReconcileRequest(t, http.MethodPost, srv.URL+"/v1/events/reconnect")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", resp.StatusCode)
+	}
+	if es.reconnects != 1 {
+		t.Errorf("reconnects = %d, want 1", es.reconnects)
+	}
+
+	es.status.Connected = false
+	resp = doReconcileRequest(t, http.MethodPost, srv.URL+"/v1/events/reconnect")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("status = %d, want 409 while not connected", resp.StatusCode)
+	}
+}
@@ -1,15 +1,21 @@
 package nodeapi
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/reconcile"
 )
 
 // SecretFetcher abstracts the control plane client for secret retrieval.
@@ -17,13 +23,38 @@ type SecretFetcher interface {
 	FetchSecret(ctx context.Context, nodeID, key string) (*api.SecretResponse, error)
 }
 
+// HealthReporter reports reconcile handlers that are currently degraded.
+// *reconcile.Reconciler satisfies this interface.
+type HealthReporter interface {
+	DegradedHandlers() []reconcile.HandlerHealth
+}
+
 // Handler provides HTTP handlers for the local node API.
 type Handler struct {
-	cache         *StateCache
-	secretFetcher SecretFetcher
-	nodeID        string
-	nsk           []byte
-	logger        *slog.Logger
+	cache            *StateCache
+	secretFetcher    SecretFetcher
+	nodeID           string
+	nsk              []byte
+	decryptor        *secretDecryptor
+	logger           *slog.Logger
+	health           HealthReporter
+	liveness         LivenessReporter
+	readiness        ReadinessReporter
+	reloader         ConfigReloader
+	reconcileHistory ReconcileHistory
+	reconcileCtl     ReconcileController
+	eventStream      EventStream
+	containerNet     ContainerNetwork
+	meshPeers        MeshPeerSource
+	logLevels        LogLevelController
+	logCapture       LogCapture
+	supportBundle    SupportBundler
+	debug            bool
+	desired          DesiredStateSource
+	labelPrefix      string
+	peerAuth         PeerAuthorizer
+	audit            *auditLog
+	profiles         *profileSet
 }
 
 // NewHandler creates a new Handler.
@@ -33,24 +64,73 @@ func NewHandler(cache *StateCache, secretFetcher SecretFetcher, nodeID string, n
 		secretFetcher: secretFetcher,
 		nodeID:        nodeID,
 		nsk:           nsk,
+		decryptor:     newSecretDecryptor(DefaultSecretDecryptWorkers),
 		logger:        logger.With("component", "nodeapi"),
 	}
 }
 
-// Mux returns a configured ServeMux with all local node API routes.
+// SetHealthReporter sets the source for GET /v1/health. If not set, the
+// endpoint always reports a healthy status.
+func (h *Handler) SetHealthReporter(hr HealthReporter) {
+	h.health = hr
+}
+
+// SetPeerAuthorizer restricts scopes of Unix socket requests to the peers
+// pa allows. Denials are recorded in audit if it is non-nil.
+func (h *Handler) SetPeerAuthorizer(pa PeerAuthorizer, audit *auditLog) {
+	h.peerAuth = pa
+	h.audit = audit
+}
+
+// SetMetadataWritePrefix enables PUT /v1/state/metadata/{key} for keys
+// starting with prefix. If not set, or set to "", the endpoint returns 403.
+func (h *Handler) SetMetadataWritePrefix(prefix string) {
+	h.labelPrefix = prefix
+}
+
+// Mux returns a configured ServeMux with all local node API routes. Each
+// route except GET /v1/health, GET /healthz, and GET /readyz requires a scope from
+// requests authenticated with a token (see TokenAuthMiddleware).
 func (h *Handler) Mux() *http.ServeMux {
 	mux := http.NewServeMux()
-	mux.HandleFunc("GET /v1/state", h.handleGetState)
-	mux.HandleFunc("GET /v1/state/metadata", h.handleGetMetadataAll)
-	mux.HandleFunc("GET /v1/state/metadata/{key}", h.handleGetMetadataKey)
-	mux.HandleFunc("GET /v1/state/data", h.handleGetDataAll)
-	mux.HandleFunc("GET /v1/state/data/{key}", h.handleGetDataKey)
-	mux.HandleFunc("GET /v1/state/secrets", h.handleGetSecretsList)
-	mux.HandleFunc("GET /v1/state/secrets/{key}", h.handleGetSecretValue)
-	mux.HandleFunc("GET /v1/state/report", h.handleGetReportAll)
-	mux.HandleFunc("GET /v1/state/report/{key}", h.handleGetReportKey)
-	mux.HandleFunc("PUT /v1/state/report/{key}", h.handlePutReport)
-	mux.HandleFunc("DELETE /v1/state/report/{key}", h.handleDeleteReport)
+	mux.HandleFunc("GET /healthz", h.handleGetHealthz)
+	mux.HandleFunc("GET /readyz", h.handleGetReadyz)
+	mux.HandleFunc("GET /v1/health", h.handleGetHealth)
+	mux.HandleFunc("GET /v1/state", h.requireScope(ScopeStateRead, h.handleGetState))
+	mux.HandleFunc("GET /v1/state/metadata", h.requireScope(ScopeStateRead, h.handleGetMetadataAll))
+	mux.HandleFunc("GET /v1/state/metadata/{key}", h.requireScope(ScopeStateRead, h.handleGetMetadataKey))
+	mux.HandleFunc("PUT /v1/state/metadata/{key}", h.requireScope(ScopeMetadataWrite, h.handlePutMetadataKey))
+	mux.HandleFunc("GET /v1/state/data", h.requireScope(ScopeStateRead, h.handleGetDataAll))
+	mux.HandleFunc("GET /v1/state/data/{key}", h.requireScope(ScopeStateRead, h.handleGetDataKey))
+	mux.HandleFunc("GET /v1/state/secrets", h.requireScope(ScopeSecretsRead, h.handleGetSecretsList))
+	mux.HandleFunc("GET /v1/state/secrets/{key}", h.requireScope(ScopeSecretsRead, h.handleGetSecretValue))
+	mux.HandleFunc("GET /v1/state/report", h.requireScope(ScopeStateRead, h.handleGetReportAll))
+	mux.HandleFunc("GET /v1/state/report/{key}", h.requireScope(ScopeStateRead, h.handleGetReportKey))
+	mux.HandleFunc("PUT /v1/state/report/{key}", h.requireScope(ScopeReportsWrite, h.handlePutReport))
+	mux.HandleFunc("DELETE /v1/state/report/{key}", h.requireScope(ScopeReportsWrite, h.handleDeleteReport))
+	mux.HandleFunc("GET /v1/config/reload", h.requireScope(ScopeConfigReload, h.handleGetConfigReload))
+	mux.HandleFunc("POST /v1/config/reload", h.requireScope(ScopeConfigReload, h.handlePostConfigReload))
+	mux.HandleFunc("GET /v1/reconcile/history", h.requireScope(ScopeStateRead, h.handleGetReconcileHistory))
+	mux.HandleFunc("POST /v1/reconcile/trigger", h.requireScope(ScopeReconcileControl, h.handlePostReconcileTrigger))
+	mux.HandleFunc("GET /v1/reconcile/pause", h.requireScope(ScopeStateRead, h.handleGetReconcilePause))
+	mux.HandleFunc("POST /v1/reconcile/pause", h.requireScope(ScopeReconcileControl, h.handlePostReconcilePause))
+	mux.HandleFunc("DELETE /v1/reconcile/pause", h.requireScope(ScopeReconcileControl, h.handleDeleteReconcilePause))
+	mux.HandleFunc("GET /v1/events/status", h.requireScope(ScopeStateRead, h.handleGetEventsStatus))
+	mux.HandleFunc("POST /v1/events/reconnect", h.requireScope(ScopeEventsControl, h.handlePostEventsReconnect))
+	mux.HandleFunc("GET /v1/mesh/peers", h.requireScope(ScopeStateRead, h.handleGetMeshPeers))
+	mux.HandleFunc("GET /v1/cni/allocations", h.requireScope(ScopeStateRead, h.handleGetCNIAllocations))
+	mux.HandleFunc("POST /v1/cni/allocations", h.requireScope(ScopeCNIManage, h.handlePostCNIAllocation))
+	mux.HandleFunc("DELETE /v1/cni/allocations/{container_id}/{ifname}", h.requireScope(ScopeCNIManage, h.handleDeleteCNIAllocation))
+	mux.HandleFunc("GET /v1/debug/loglevel", h.requireScope(ScopeStateRead, h.handleGetLogLevel))
+	mux.HandleFunc("PUT /v1/debug/loglevel", h.requireScope(ScopeDebugControl, h.handlePutLogLevel))
+	mux.HandleFunc("GET /v1/debug/logs", h.requireScope(ScopeDebugControl, h.handleGetLogs))
+	mux.HandleFunc("GET /v1/debug/support-bundle", h.localOnly(h.requireScope(ScopeDebugControl, h.handleGetSupportBundle)))
+	mux.HandleFunc("GET /v1/profiles", h.requireScope(ScopeStateRead, h.handleGetProfiles))
+	mux.HandleFunc("/v1/profiles/{name}/state", h.handleProfileState)
+	mux.HandleFunc("/v1/profiles/{name}/state/", h.handleProfileState)
+	if h.debug {
+		h.registerDebug(mux)
+	}
 	return mux
 }
 
@@ -78,9 +158,37 @@ type reportKeySummary struct {
 	Version int    `json:"version"`
 }
 
+type metadataPutRequest struct {
+	Value *string `json:"value"`
+}
+
 type reportPutRequest struct {
 	ContentType string          `json:"content_type"`
 	Payload     json.RawMessage `json:"payload"`
+	// TTL is a duration such as "10m" after which the entry expires.
+	TTL string `json:"ttl,omitempty"`
+	// ExpiresAt is when the entry expires. Mutually exclusive with TTL.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// expiry returns when the entry expires, or the zero time if it does not.
+func (req reportPutRequest) expiry(now time.Time) (time.Time, error) {
+	switch {
+	case req.TTL != "" && req.ExpiresAt != nil:
+		return time.Time{}, errors.New("ttl and expires_at are mutually exclusive")
+	case req.TTL != "":
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			return time.Time{}, errors.New("ttl must be a positive duration")
+		}
+		return now.Add(ttl), nil
+	case req.ExpiresAt != nil:
+		if !req.ExpiresAt.After(now) {
+			return time.Time{}, errors.New("expires_at must be in the future")
+		}
+		return *req.ExpiresAt, nil
+	}
+	return time.Time{}, nil
 }
 
 func (h *Handler) handleGetState(w http.ResponseWriter, r *http.Request) {
@@ -122,6 +230,26 @@ func (h *Handler) handleGetState(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// HealthStatus is the response for GET /v1/health.
+type HealthStatus struct {
+	Status           string                    `json:"status"`
+	DegradedHandlers []reconcile.HandlerHealth `json:"degraded_handlers"`
+}
+
+func (h *Handler) handleGetHealth(w http.ResponseWriter, r *http.Request) {
+	resp := HealthStatus{
+		Status:           "healthy",
+		DegradedHandlers: []reconcile.HandlerHealth{},
+	}
+	if h.health != nil {
+		if degraded := h.health.DegradedHandlers(); len(degraded) > 0 {
+			resp.Status = "degraded"
+			resp.DegradedHandlers = degraded
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
 func (h *Handler) handleGetMetadataAll(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, h.cache.GetMetadata())
 }
@@ -136,6 +264,41 @@ func (h *Handler) handleGetMetadataKey(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{"key": key, "value": val})
 }
 
+// maxMetadataBodyBytes is the maximum allowed request body size for metadata
+// PUT requests (64 KiB).
+const maxMetadataBodyBytes = 64 << 10
+
+func (h *Handler) handlePutMetadataKey(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if h.labelPrefix == "" {
+		writeError(w, http.StatusForbidden, "metadata writes disabled")
+		return
+	}
+	if !strings.HasPrefix(key, h.labelPrefix) {
+		writeError(w, http.StatusForbidden, "metadata key not writable")
+		return
+	}
+	if !validReportKey(key) || key == h.labelPrefix {
+		writeError(w, http.StatusBadRequest, "invalid metadata key")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxMetadataBodyBytes)
+
+	var req metadataPutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if req.Value == nil {
+		writeError(w, http.StatusBadRequest, "value is required")
+		return
+	}
+
+	h.cache.PutLabel(key, *req.Value)
+	writeJSON(w, http.StatusOK, map[string]string{"key": key, "value": *req.Value})
+}
+
 func (h *Handler) handleGetDataAll(w http.ResponseWriter, r *http.Request) {
 	data := h.cache.GetData()
 	summaries := make([]dataKeySummary, 0, len(data))
@@ -151,12 +314,38 @@ func (h *Handler) handleGetDataAll(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) handleGetDataKey(w http.ResponseWriter, r *http.Request) {
 	key := r.PathValue("key")
-	entry, ok := h.cache.GetDataEntry(key)
-	if !ok {
+	entry, payload, err := h.cache.OpenDataEntry(key)
+	if errors.Is(err, ErrNotFound) {
 		writeError(w, http.StatusNotFound, "not found")
 		return
 	}
-	writeJSON(w, http.StatusOK, entry)
+	if err != nil {
+		h.logger.Error("read data entry failed", "key", key, "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	writeDataEntry(w, entry, payload)
+}
+
+// writeDataEntry writes entry as JSON with the payload copied from payload,
+// so the payload is not buffered once more to encode the response.
+func writeDataEntry(w http.ResponseWriter, entry api.DataEntry, payload io.Reader) {
+	// Encode the entry with a placeholder payload and splice the payload
+	// in its place.
+	entry.Payload = json.RawMessage(`null`)
+	head, err := json.Marshal(entry)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	const field = `"payload":null`
+	i := bytes.Index(head, []byte(field))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(head[:i+len(`"payload":`)])
+	_, _ = io.Copy(w, payload)
+	_, _ = w.Write(head[i+len(field):])
+	_, _ = w.Write([]byte("\n"))
 }
 
 func (h *Handler) handleGetSecretsList(w http.ResponseWriter, r *http.Request) {
@@ -181,7 +370,11 @@ func (h *Handler) handleGetSecretValue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	plaintext, err := DecryptSecret(h.nsk, resp.Ciphertext, resp.Nonce)
+	plaintext, err := h.decryptor.decrypt(r.Context(), h.nsk, resp.Ciphertext, resp.Nonce)
+	if r.Context().Err() != nil {
+		writeError(w, http.StatusServiceUnavailable, "request canceled")
+		return
+	}
 	if err != nil {
 		h.logger.Error("secret decryption failed", "key", key, "error", err)
 		writeError(w, http.StatusInternalServerError, "internal error")
@@ -249,6 +442,12 @@ func (h *Handler) handlePutReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	expiresAt, err := req.expiry(time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	var ifMatch *int
 	if ifMatchStr := r.Header.Get("If-Match"); ifMatchStr != "" {
 		v, err := strconv.Atoi(ifMatchStr)
@@ -259,7 +458,7 @@ func (h *Handler) handlePutReport(w http.ResponseWriter, r *http.Request) {
 		ifMatch = &v
 	}
 
-	entry, err := h.cache.PutReport(key, req.ContentType, req.Payload, ifMatch)
+	entry, err := h.cache.PutReportWithExpiry(key, req.ContentType, req.Payload, ifMatch, expiresAt)
 	if err != nil {
 		if errors.Is(err, ErrVersionConflict) {
 			writeError(w, http.StatusConflict, "version conflict")
@@ -293,11 +492,20 @@ func (h *Handler) handleDeleteReport(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// maxKeyBytes bounds the length of report, label, and data keys, so that
+// their file names, with the ".tmp-" prefix and ".json" suffix of an atomic
+// write, stay below the 255 bytes file systems allow.
+const maxKeyBytes = 200
+
 // validReportKey returns true if key is safe to use in file paths.
-// It rejects empty keys, path separators, '..' sequences, and the current
-// directory reference '.'.
+// It rejects empty keys, path separators, '..' sequences, the current
+// directory reference '.', control characters such as NUL, invalid UTF-8,
+// which JSON would not round-trip, and keys longer than maxKeyBytes.
 func validReportKey(key string) bool {
-	return key != "" && key != "." && key != ".." && !strings.ContainsAny(key, "/\\")
+	if key == "" || key == "." || key == ".." || len(key) > maxKeyBytes || !utf8.ValidString(key) {
+		return false
+	}
+	return !strings.ContainsAny(key, "/\\") && !strings.ContainsFunc(key, unicode.IsControl)
 }
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
@@ -1,6 +1,7 @@
 package nodeapi
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -8,11 +9,13 @@ import (
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/reconcile"
 )
 
 type mockSecretFetcher struct {
@@ -233,6 +236,31 @@ func TestHandler_GetDataKey(t *testing.T) {
 	resp2.Body.Close()
 }
 
+func TestHandler_GetDataKey_Evicted(t *testing.T) {
+	srv, cache := newTestHandler(t, &mockSecretFetcher{})
+	cache.SetDataCacheLimit(16)
+	payload := `{"blob":"` + strings.Repeat("z", 1<<16) + `"}`
+	cache.UpdateData([]api.DataEntry{
+		{Key: "big", ContentType: "application/json", Payload: json.RawMessage(payload), Version: 7},
+	})
+
+	resp := mustGet(t, srv.URL+"/v1/state/data/big")
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var entry api.DataEntry
+	decodeJSON(t, resp, &entry)
+	if entry.Key != "big" || entry.Version != 7 || entry.ContentType != "application/json" {
+		t.Errorf("entry = %+v", entry)
+	}
+	if string(entry.Payload) != payload {
+		t.Errorf("payload has %d bytes, want %d", len(entry.Payload), len(payload))
+	}
+}
+
 func TestHandler_GetSecretsList(t *testing.T) {
 	srv, cache := newTestHandler(t, &mockSecretFetcher{})
 	cache.UpdateSecretIndex([]api.SecretRef{
@@ -418,6 +446,97 @@ func TestHandler_PutReport(t *testing.T) {
 	}
 }
 
+func TestHandler_PutMetadataKey(t *testing.T) {
+	srv, cache := newTestHandlerWithPrefix(t, "label.")
+
+	resp := mustPut(t, srv.URL+"/v1/state/metadata/label.pool", `{"value":"gpu"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var result map[string]string
+	decodeJSON(t, resp, &result)
+	if result["key"] != "label.pool" || result["value"] != "gpu" {
+		t.Errorf("response = %v, want key=label.pool value=gpu", result)
+	}
+	if v, _ := cache.GetMetadataKey("label.pool"); v != "gpu" {
+		t.Errorf("cached value = %q, want %q", v, "gpu")
+	}
+
+	// An empty value is allowed.
+	resp = mustPut(t, srv.URL+"/v1/state/metadata/label.pool", `{"value":""}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("empty value: status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestHandler_PutMetadataKey_Rejected(t *testing.T) {
+	srv, cache := newTestHandlerWithPrefix(t, "label.")
+
+	tests := []struct {
+		name string
+		key  string
+		body string
+		want int
+	}{
+		{"outside prefix", "role", `{"value":"x"}`, http.StatusForbidden},
+		{"prefix only", "label.", `{"value":"x"}`, http.StatusBadRequest},
+		{"invalid JSON", "label.pool", `{`, http.StatusBadRequest},
+		{"missing value", "label.pool", `{}`, http.StatusBadRequest},
+		{"oversized body", "label.pool", `{"value":"` + strings.Repeat("x", maxMetadataBodyBytes) + `"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := mustPut(t, srv.URL+"/v1/state/metadata/"+tt.key, tt.body)
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+	if labels := cache.GetLabels(); len(labels) != 0 {
+		t.Errorf("labels = %v, want none", labels)
+	}
+}
+
+func TestHandler_PutMetadataKey_Disabled(t *testing.T) {
+	srv, _ := newTestHandler(t, &mockSecretFetcher{})
+
+	resp := mustPut(t, srv.URL+"/v1/state/metadata/label.pool", `{"value":"gpu"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", resp.StatusCode)
+	}
+}
+
+// newTestHandlerWithPrefix is newTestHandler with metadata writes enabled
+// for prefix.
+func newTestHandlerWithPrefix(t *testing.T, prefix string) (*httptest.Server, *StateCache) {
+	t.Helper()
+	cache := NewStateCache(t.TempDir(), discardLogger())
+	if err := cache.Load(); err != nil {
+		t.Fatalf("cache.Load: %v", err)
+	}
+	h := NewHandler(cache, &mockSecretFetcher{}, "node-1", testKey(t), discardLogger())
+	h.SetMetadataWritePrefix(prefix)
+	srv := httptest.NewServer(h.Mux())
+	t.Cleanup(srv.Close)
+	return srv, cache
+}
+
+func mustPut(t *testing.T, url, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPut, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT %s: %v", url, err)
+	}
+	return resp
+}
+
 func TestHandler_PutReport_IfMatchConflict(t *testing.T) {
 	srv, cache := newTestHandler(t, &mockSecretFetcher{})
 	_, _ = cache.PutReport("health", "application/json", json.RawMessage(`{"ok":true}`), nil)
@@ -544,6 +663,12 @@ func TestValidReportKey(t *testing.T) {
 		{"foo/bar", false},
 		{"foo\\bar", false},
 		{"/absolute", false},
+		{"nul\x00byte", false},
+		{"line\nbreak", false},
+		{"bad\xffutf8", false},
+		{"grüße", true},
+		{strings.Repeat("k", 200), true},
+		{strings.Repeat("k", 201), false},
 	}
 	for _, tc := range tests {
 		got := validReportKey(tc.key)
@@ -553,6 +678,68 @@ func TestValidReportKey(t *testing.T) {
 	}
 }
 
+// fuzzPut sends a PUT of body to path through a handler with metadata
+// writes enabled for "app.", and returns the status and the cache
+// restarted from the handler's state directory.
+func fuzzPut(t *testing.T, path string, body []byte) (int, *StateCache) {
+	t.Helper()
+	dir := t.TempDir()
+	cache := NewStateCache(dir, discardLogger())
+	if err := cache.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	h := NewHandler(cache, &mockSecretFetcher{}, "node-1", nil, discardLogger())
+	h.SetMetadataWritePrefix("app.")
+	rec := httptest.NewRecorder()
+	h.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodPut, path, bytes.NewReader(body)))
+	if rec.Code >= 500 {
+		t.Fatalf("PUT %s %q: status %d: %s", path, body, rec.Code, rec.Body)
+	}
+
+	restarted := NewStateCache(dir, discardLogger())
+	if err := restarted.Load(); err != nil {
+		t.Fatalf("Load after restart: %v", err)
+	}
+	return rec.Code, restarted
+}
+
+// FuzzHandler_PutReport writes reports with arbitrary keys and bodies. A
+// stored report must survive a restart.
+func FuzzHandler_PutReport(f *testing.F) {
+	f.Add("health", []byte(`{"content_type":"application/json","payload":{"ok":true},"ttl":"10m"}`))
+	f.Add("../etc", []byte(`{"content_type":"text/plain","payload":"x","expires_at":"2999-01-01T00:00:00Z"}`))
+	f.Add("nul\x00", []byte(`{"content_type":"a","payload":1}`))
+	f.Add(strings.Repeat("k", 251), []byte(`{"content_type":"a","payload":[1, 2]}`))
+	f.Add("ttl", []byte(`{"content_type":"a","payload":{},"ttl":"-1s"}{}`))
+	f.Fuzz(func(t *testing.T, key string, body []byte) {
+		code, restarted := fuzzPut(t, "/v1/state/report/"+url.PathEscape(key), body)
+		if code != http.StatusOK {
+			return
+		}
+		if _, ok := restarted.GetReport(key); !ok {
+			t.Fatalf("report %q lost on restart", key)
+		}
+	})
+}
+
+// FuzzHandler_PutMetadataKey writes labels with arbitrary keys and bodies.
+// A stored label must survive a restart.
+func FuzzHandler_PutMetadataKey(f *testing.F) {
+	f.Add("app.team", []byte(`{"value":"core"}`))
+	f.Add("app.", []byte(`{"value":""}`))
+	f.Add("app.\xff", []byte(`{"value":"\ud800"}`))
+	f.Add("other", []byte(`{"value":null}`))
+	f.Fuzz(func(t *testing.T, key string, body []byte) {
+		code, restarted := fuzzPut(t, "/v1/state/metadata/"+url.PathEscape(key), body)
+		if code != http.StatusOK {
+			return
+		}
+		if _, ok := restarted.GetLabels()[key]; !ok {
+			t.Fatalf("label %q lost on restart", key)
+		}
+	})
+}
+
 func TestHandler_PutReport_InvalidKey(t *testing.T) {
 	// Test with backslash-containing key (reaches handler since no path separator for URL routing).
 	srv, _ := newTestHandler(t, &mockSecretFetcher{})
@@ -633,3 +820,241 @@ func TestHandler_MethodNotAllowed(t *testing.T) {
 	}
 	resp.Body.Close()
 }
+
+type mockHealthReporter struct {
+	degraded []reconcile.HandlerHealth
+}
+
+func (m *mockHealthReporter) DegradedHandlers() []reconcile.HandlerHealth {
+	return m.degraded
+}
+
+func TestHandler_GetHealth(t *testing.T) {
+	srv, _ := newTestHandler(t, &mockSecretFetcher{})
+
+	resp := mustGet(t, srv.URL+"/v1/health")
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var result HealthStatus
+	decodeJSON(t, resp, &result)
+	if result.Status != "healthy" {
+		t.Errorf("status = %q, want %q", result.Status, "healthy")
+	}
+	if len(result.DegradedHandlers) != 0 {
+		t.Errorf("degraded_handlers = %v, want empty", result.DegradedHandlers)
+	}
+}
+
+func TestHandler_GetHealth_Degraded(t *testing.T) {
+	cache := NewStateCache(t.TempDir(), discardLogger())
+	if err := cache.Load(); err != nil {
+		t.Fatalf("cache.Load: %v", err)
+	}
+	h := NewHandler(cache, &mockSecretFetcher{}, "node-1", testKey(t), discardLogger())
+	h.SetHealthReporter(&mockHealthReporter{degraded: []reconcile.HandlerHealth{
+		{Name: "bridge_routes", ConsecutiveFailures: 6, LastError: "netlink: file exists", CircuitOpen: true},
+	}})
+	srv := httptest.NewServer(h.Mux())
+	t.Cleanup(srv.Close)
+
+	resp := mustGet(t, srv.URL+"/v1/health")
+	var result HealthStatus
+	decodeJSON(t, resp, &result)
+	if result.Status != "degraded" {
+		t.Errorf("status = %q, want %q", result.Status, "degraded")
+	}
+	if len(result.DegradedHandlers) != 1 || result.DegradedHandlers[0].Name != "bridge_routes" {
+		t.Fatalf("degraded_handlers = %+v, want [bridge_routes]", result.DegradedHandlers)
+	}
+	if !result.DegradedHandlers[0].CircuitOpen {
+		t.Error("circuit_open = false, want true")
+	}
+}
+
+func TestHandler_PutReport_TTL(t *testing.T) {
+	srv, cache := newTestHandler(t, &mockSecretFetcher{})
+
+	before := time.Now()
+	resp := mustPut(t, srv.URL+"/v1/state/report/probe", `{"content_type":"application/json","payload":{},"ttl":"10m"}`)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var entry ReportEntry
+	decodeJSON(t, resp, &entry)
+	if entry.ExpiresAt == nil {
+		t.Fatal("ExpiresAt not set")
+	}
+	if d := entry.ExpiresAt.Sub(before); d < 10*time.Minute || d > 11*time.Minute {
+		t.Errorf("ExpiresAt - now = %v, want about 10m", d)
+	}
+	if cached, _ := cache.GetReport("probe"); cached.ExpiresAt == nil {
+		t.Error("cached entry has no ExpiresAt")
+	}
+}
+
+func TestHandler_PutReport_ExpiresAt(t *testing.T) {
+	srv, _ := newTestHandler(t, &mockSecretFetcher{})
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	body := `{"content_type":"application/json","payload":{},"expires_at":"` + expiresAt.Format(time.RFC3339) + `"}`
+	resp := mustPut(t, srv.URL+"/v1/state/report/diag", body)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var entry ReportEntry
+	decodeJSON(t, resp, &entry)
+	if entry.ExpiresAt == nil || !entry.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("ExpiresAt = %v, want %v", entry.ExpiresAt, expiresAt)
+	}
+}
+
+func TestHandler_PutReport_InvalidExpiry(t *testing.T) {
+	srv, _ := newTestHandler(t, &mockSecretFetcher{})
+
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"negative ttl", `{"content_type":"text/plain","payload":"x","ttl":"-1m"}`, "ttl must be a positive duration"},
+		{"invalid ttl", `{"content_type":"text/plain","payload":"x","ttl":"soon"}`, "ttl must be a positive duration"},
+		{"past expires_at", `{"content_type":"text/plain","payload":"x","expires_at":"` + past + `"}`, "expires_at must be in the future"},
+		{"both", `{"content_type":"text/plain","payload":"x","ttl":"1m","expires_at":"2999-01-01T00:00:00Z"}`, "ttl and expires_at are mutually exclusive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := mustPut(t, srv.URL+"/v1/state/report/k", tt.body)
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", resp.StatusCode)
+			}
+			var body map[string]string
+			decodeJSON(t, resp, &body)
+			if body["error"] != tt.want {
+				t.Errorf("error = %q, want %q", body["error"], tt.want)
+			}
+		})
+	}
+}
+
+type mockLivenessReporter struct {
+	status LivenessStatus
+}
+
+func (m *mockLivenessReporter) Liveness() LivenessStatus {
+	return m.status
+}
+
+func TestHandler_GetHealthz(t *testing.T) {
+	tests := []struct {
+		name     string
+		reporter LivenessReporter
+		want     int
+	}{
+		{"no reporter", nil, http.StatusOK},
+		{"alive", &mockLivenessReporter{status: LivenessStatus{
+			Status:     "alive",
+			Subsystems: []SubsystemLiveness{{Name: "sse", Alive: true}},
+		}}, http.StatusOK},
+		{"stalled", &mockLivenessReporter{status: LivenessStatus{
+			Status:     "stalled",
+			Subsystems: []SubsystemLiveness{{Name: "reconciler", Detail: "no progress"}},
+		}}, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewStateCache(t.TempDir(), discardLogger())
+			if err := cache.Load(); err != nil {
+				t.Fatalf("cache.Load: %v", err)
+			}
+			h := NewHandler(cache, &mockSecretFetcher{}, "node-1", testKey(t), discardLogger())
+			if tt.reporter != nil {
+				h.SetLivenessReporter(tt.reporter)
+			}
+			srv := httptest.NewServer(h.Mux())
+			t.Cleanup(srv.Close)
+
+			resp := mustGet(t, srv.URL+"/healthz")
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			var result LivenessStatus
+			decodeJSON(t, resp, &result)
+			if result.Subsystems == nil {
+				t.Error("subsystems = nil, want list")
+			}
+		})
+	}
+}
+
+type mockReadinessReporter struct {
+	status ReadinessStatus
+}
+
+func (m *mockReadinessReporter) Readiness() ReadinessStatus {
+	return m.status
+}
+
+func TestHandler_ProbeMux(t *testing.T) {
+	cache := NewStateCache(t.TempDir(), discardLogger())
+	if err := cache.Load(); err != nil {
+		t.Fatalf("cache.Load: %v", err)
+	}
+	h := NewHandler(cache, &mockSecretFetcher{}, "node-1", testKey(t), discardLogger())
+	ready := &mockReadinessReporter{status: ReadinessStatus{Status: "starting", Subsystems: []SubsystemLiveness{}}}
+	h.SetReadinessReporter(ready)
+	srv := httptest.NewServer(h.ProbeMux())
+	t.Cleanup(srv.Close)
+
+	resp := mustGet(t, srv.URL+"/readyz")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("/readyz while starting status = %d, want 503", resp.StatusCode)
+	}
+	ready.status.Status = "ready"
+	resp = mustGet(t, srv.URL+"/readyz")
+	var result ReadinessStatus
+	decodeJSON(t, resp, &result)
+	if resp.StatusCode != http.StatusOK || result.Status != "ready" {
+		t.Errorf("/readyz = %d %q, want 200 ready", resp.StatusCode, result.Status)
+	}
+	resp = mustGet(t, srv.URL+"/healthz")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("/healthz status = %d, want 200", resp.StatusCode)
+	}
+	resp = mustGet(t, srv.URL+"/v1/state")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("/v1/state status = %d, want 404 on probe mux", resp.StatusCode)
+	}
+}
+
+func TestLivenessBypass(t *testing.T) {
+	open := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	authed := TokenAuthMiddleware([]Token{{Name: "t", Token: "secret"}}, true)(open)
+	srv := httptest.NewServer(livenessBypass(open, authed))
+	t.Cleanup(srv.Close)
+
+	resp := mustGet(t, srv.URL+"/healthz")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("/healthz status = %d, want 200 without token", resp.StatusCode)
+	}
+	resp = mustGet(t, srv.URL+"/readyz")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("/readyz status = %d, want 200 without token", resp.StatusCode)
+	}
+	resp = mustGet(t, srv.URL+"/v1/health")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("/v1/health status = %d, want 401 without token", resp.StatusCode)
+	}
+}
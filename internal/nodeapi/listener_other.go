@@ -0,0 +1,41 @@
+//go:build !windows
+
+package nodeapi
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// DefaultSocketPath is the default Unix domain socket path.
+const DefaultSocketPath = "/var/run/plexd/api.sock"
+
+// listenLocal removes a stale socket, creates the socket directory, and
+// listens on the Unix domain socket at path.
+func listenLocal(path string) (net.Listener, error) {
+	os.Remove(path)
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("nodeapi: create socket dir: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("nodeapi: listen unix %s: %w", path, err)
+	}
+	return ln, nil
+}
+
+// removeLocal removes the socket file at path.
+func removeLocal(path string) {
+	os.Remove(path)
+}
+
+// DialLocal connects to the node API Unix domain socket at path.
+func DialLocal(path string) (net.Conn, error) {
+	return net.Dial("unix", path)
+}
@@ -0,0 +1,55 @@
+//go:build windows
+
+package nodeapi
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.zx2c4.com/wireguard/ipc/namedpipe"
+)
+
+// DefaultSocketPath is the default named pipe path.
+const DefaultSocketPath = `\\.\pipe\plexd-api`
+
+// pipeSDDL restricts the node API pipe to LocalSystem and Administrators,
+// matching the root-only default of the Unix socket.
+const pipeSDDL = "D:P(A;;GA;;;SY)(A;;GA;;;BA)"
+
+// pipeBufferSize is the initial size of the pipe's input and output buffers.
+const pipeBufferSize = 64 * 1024
+
+// pipeDialTimeout bounds how long DialLocal waits for a free pipe instance.
+const pipeDialTimeout = 5 * time.Second
+
+// listenLocal listens on the named pipe at path. The pipe is opened for
+// overlapped I/O, so reads and writes on a connection run concurrently and
+// honour deadlines. Listening fails if the pipe already exists, e.g. because
+// another agent is running.
+func listenLocal(path string) (net.Listener, error) {
+	sd, err := windows.SecurityDescriptorFromString(pipeSDDL)
+	if err != nil {
+		return nil, fmt.Errorf("nodeapi: listen pipe %s: parse security descriptor: %w", path, err)
+	}
+	cfg := namedpipe.ListenConfig{
+		SecurityDescriptor: sd,
+		InputBufferSize:    pipeBufferSize,
+		OutputBufferSize:   pipeBufferSize,
+	}
+	ln, err := cfg.Listen(path)
+	if err != nil {
+		return nil, fmt.Errorf("nodeapi: listen pipe %s: %w", path, err)
+	}
+	return ln, nil
+}
+
+// removeLocal is a no-op: named pipes disappear with their last handle.
+func removeLocal(_ string) {}
+
+// DialLocal connects to the node API named pipe at path, retrying while all
+// instances are busy for up to pipeDialTimeout.
+func DialLocal(path string) (net.Conn, error) {
+	return namedpipe.DialTimeout(path, pipeDialTimeout)
+}
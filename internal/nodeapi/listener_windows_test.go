@@ -0,0 +1,149 @@
+//go:build windows
+
+package nodeapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPipePath returns a pipe path unique to the running test.
+func testPipePath(t *testing.T) string {
+	t.Helper()
+	return fmt.Sprintf(`\\.\pipe\plexd-test-%d-%d`, os.Getpid(), time.Now().UnixNano())
+}
+
+func listenTestPipe(t *testing.T) (net.Listener, string) {
+	t.Helper()
+	path := testPipePath(t)
+	ln, err := listenLocal(path)
+	if err != nil {
+		t.Fatalf("listenLocal: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return ln, path
+}
+
+func TestListenLocal_HTTPRoundTrip(t *testing.T) {
+	ln, path := listenTestPipe(t)
+
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprintf(w, "%s %s", r.Method, body)
+		}),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+		IdleTimeout:  5 * time.Second,
+	}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(context.Context, string, string) (net.Conn, error) {
+				return DialLocal(path)
+			},
+		},
+	}
+
+	// Two requests, the second over the kept-alive connection.
+	for i := range 2 {
+		body := fmt.Sprintf("request-%d", i)
+		resp, err := client.Post("http://plexd/v1/echo", "text/plain", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		got, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("read response %d: %v", i, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("request %d: status = %d, want 200", i, resp.StatusCode)
+		}
+		if want := "POST " + body; string(got) != want {
+			t.Errorf("request %d: body = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestListenLocal_ReadDeadline(t *testing.T) {
+	ln, path := listenTestPipe(t)
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+		close(accepted)
+	}()
+
+	client, err := DialLocal(path)
+	if err != nil {
+		t.Fatalf("DialLocal: %v", err)
+	}
+	defer client.Close()
+
+	conn, ok := <-accepted
+	if !ok {
+		t.Fatal("Accept failed")
+	}
+	defer conn.Close()
+
+	if err := conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+		t.Fatalf("SetReadDeadline: %v", err)
+	}
+	start := time.Now()
+	_, err = conn.Read(make([]byte, 1))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Read error = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Read returned after %v, want about 50ms", elapsed)
+	}
+}
+
+func TestListenLocal_SecondListenerFails(t *testing.T) {
+	_, path := listenTestPipe(t)
+
+	if ln, err := listenLocal(path); err == nil {
+		ln.Close()
+		t.Fatal("second listenLocal on the same pipe succeeded")
+	}
+}
+
+func TestListenLocal_CloseUnblocksAccept(t *testing.T) {
+	ln, err := listenLocal(testPipePath(t))
+	if err != nil {
+		t.Fatalf("listenLocal: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ln.Accept()
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	ln.Close()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("Accept error = %v, want net.ErrClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Accept did not return after Close")
+	}
+}
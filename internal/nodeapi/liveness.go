@@ -0,0 +1,135 @@
+package nodeapi
+
+import (
+	"net/http"
+	"time"
+)
+
+// SubsystemLiveness describes whether one agent subsystem is making progress.
+type SubsystemLiveness struct {
+	// Name identifies the subsystem, e.g. "sse" or "reconciler".
+	Name string `json:"name"`
+
+	// Alive is false when the subsystem has stopped or stalled.
+	Alive bool `json:"alive"`
+
+	// Detail describes the subsystem state or why it is not alive.
+	Detail string `json:"detail,omitempty"`
+}
+
+// LivenessStatus is the response for GET /healthz.
+type LivenessStatus struct {
+	// Status is "alive" when every subsystem is alive and "stalled" otherwise.
+	Status string `json:"status"`
+
+	// Time is when the subsystems were checked.
+	Time time.Time `json:"time"`
+
+	// Subsystems lists the result of every check.
+	Subsystems []SubsystemLiveness `json:"subsystems"`
+}
+
+// ReadinessStatus is the response for GET /readyz.
+type ReadinessStatus struct {
+	// Status is "ready" once startup has finished and every subsystem is
+	// alive, "starting" before that, and "stalled" when a subsystem stalls.
+	Status string `json:"status"`
+
+	// Time is when readiness was checked.
+	Time time.Time `json:"time"`
+
+	// Subsystems lists the result of every liveness check. While the agent
+	// is starting it lists the startup state of each subsystem instead.
+	Subsystems []SubsystemLiveness `json:"subsystems"`
+}
+
+// LivenessReporter reports whether the agent's subsystems are making
+// progress. *agent.Watchdog satisfies this interface.
+type LivenessReporter interface {
+	Liveness() LivenessStatus
+}
+
+// ReadinessReporter reports whether the agent has finished startup and can
+// serve traffic. *agent.Orchestrator and *agent.Watchdog satisfy this
+// interface.
+type ReadinessReporter interface {
+	Readiness() ReadinessStatus
+}
+
+// SetLivenessReporter sets the source for GET /healthz. If not set, the
+// endpoint reports the agent alive whenever it can answer.
+func (h *Handler) SetLivenessReporter(lr LivenessReporter) {
+	h.liveness = lr
+}
+
+// SetReadinessReporter sets the source for GET /readyz. If not set, the
+// endpoint reports the agent ready whenever it can answer.
+func (h *Handler) SetReadinessReporter(rr ReadinessReporter) {
+	h.readiness = rr
+}
+
+// handleGetHealthz serves the liveness endpoint. It returns 503 when a
+// subsystem is stalled so that probes can restart a wedged agent.
+func (h *Handler) handleGetHealthz(w http.ResponseWriter, r *http.Request) {
+	if h.liveness == nil {
+		writeJSON(w, http.StatusOK, LivenessStatus{
+			Status:     "alive",
+			Time:       time.Now(),
+			Subsystems: []SubsystemLiveness{},
+		})
+		return
+	}
+	status := h.liveness.Liveness()
+	code := http.StatusOK
+	if status.Status != "alive" {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, status)
+}
+
+// handleGetReadyz serves the readiness endpoint. It returns 503 until
+// startup has finished and while a subsystem is stalled, so that probes
+// hold traffic back from an agent that cannot serve it.
+func (h *Handler) handleGetReadyz(w http.ResponseWriter, r *http.Request) {
+	if h.readiness == nil {
+		writeJSON(w, http.StatusOK, ReadinessStatus{
+			Status:     "ready",
+			Time:       time.Now(),
+			Subsystems: []SubsystemLiveness{},
+		})
+		return
+	}
+	status := h.readiness.Readiness()
+	code := http.StatusOK
+	if status.Status != "ready" {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, status)
+}
+
+// ProbeMux returns a ServeMux with only GET /healthz and GET /readyz. It
+// backs the unauthenticated probe listener.
+func (h *Handler) ProbeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", h.handleGetHealthz)
+	mux.HandleFunc("GET /readyz", h.handleGetReadyz)
+	return mux
+}
+
+// isProbeRequest reports whether r is a liveness or readiness probe.
+func isProbeRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet && (r.URL.Path == "/healthz" || r.URL.Path == "/readyz")
+}
+
+// livenessBypass serves GET /healthz and GET /readyz from open without
+// authentication and passes every other request to authed. Probes such as
+// the kubelet cannot present a bearer token.
+func livenessBypass(open, authed http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isProbeRequest(r) {
+			open.ServeHTTP(w, r)
+			return
+		}
+		authed.ServeHTTP(w, r)
+	})
+}
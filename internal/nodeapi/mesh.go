@@ -0,0 +1,51 @@
+package nodeapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// MeshPeer is the live WireGuard state of a mesh peer.
+type MeshPeer struct {
+	// PeerID is empty for peers that are configured on the interface but
+	// not part of the applied state.
+	PeerID    string `json:"peer_id,omitempty"`
+	PublicKey string `json:"public_key"`
+	MeshIP    string `json:"mesh_ip,omitempty"`
+	Endpoint  string `json:"endpoint,omitempty"`
+	// LastHandshake is nil if no handshake completed since the interface
+	// was created.
+	LastHandshake *time.Time `json:"last_handshake,omitempty"`
+	RxBytes       int64      `json:"rx_bytes"`
+	TxBytes       int64      `json:"tx_bytes"`
+}
+
+// MeshPeerSource reports the live state of the mesh peers.
+// *wireguard.PeerStatsReader satisfies this interface.
+type MeshPeerSource interface {
+	MeshPeers(ctx context.Context) ([]MeshPeer, error)
+}
+
+// SetMeshPeers sets the source of GET /v1/mesh/peers. If not set, the
+// endpoint returns 503.
+func (h *Handler) SetMeshPeers(mp MeshPeerSource) {
+	h.meshPeers = mp
+}
+
+func (h *Handler) handleGetMeshPeers(w http.ResponseWriter, r *http.Request) {
+	if h.meshPeers == nil {
+		writeError(w, http.StatusServiceUnavailable, "mesh peers not available")
+		return
+	}
+	peers, err := h.meshPeers.MeshPeers(r.Context())
+	if err != nil {
+		h.logger.Warn("read mesh peers failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to read mesh peers")
+		return
+	}
+	if peers == nil {
+		peers = []MeshPeer{}
+	}
+	writeJSON(w, http.StatusOK, peers)
+}
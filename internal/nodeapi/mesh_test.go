@@ -0,0 +1,76 @@
+package nodeapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type mockMeshPeers struct {
+	peers []MeshPeer
+	err   error
+}
+
+func (m *mockMeshPeers) MeshPeers(context.Context) ([]MeshPeer, error) {
+	return m.peers, m.err
+}
+
+func newMeshTestServer(t *testing.T, mp MeshPeerSource) *httptest.Server {
+	t.Helper()
+	cache := NewStateCache(t.TempDir(), discardLogger())
+	if err := cache.Load(); err != nil {
+		t.Fatalf("cache.Load: %v", err)
+	}
+	h := NewHandler(cache, &mockSecretFetcher{}, "node-1", testKey(t), discardLogger())
+	if mp != nil {
+		h.SetMeshPeers(mp)
+	}
+	srv := httptest.NewServer(h.Mux())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHandler_MeshPeers(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     MeshPeerSource
+		wantStatus int
+		wantPeers  int
+	}{
+		{name: "not configured", source: nil, wantStatus: http.StatusServiceUnavailable},
+		{name: "read error", source: &mockMeshPeers{err: errors.New("no device")}, wantStatus: http.StatusInternalServerError},
+		{name: "empty", source: &mockMeshPeers{}, wantStatus: http.StatusOK, wantPeers: 0},
+		{
+			name: "peers",
+			source: &mockMeshPeers{peers: []MeshPeer{
+				{PeerID: "peer-a", PublicKey: "a", RxBytes: 10, TxBytes: 20},
+				{PublicKey: "b"},
+			}},
+			wantStatus: http.StatusOK,
+			wantPeers:  2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newMeshTestServer(t, tt.source)
+			resp := mustGet(t, srv.URL+"/v1/mesh/peers")
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var peers []MeshPeer
+			if err := json.NewDecoder(resp.Body).Decode(&peers); err != nil {
+				t.Fatal(err)
+			}
+			if peers == nil || len(peers) != tt.wantPeers {
+				t.Errorf("peers = %v, want %d non-nil entries", peers, tt.wantPeers)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package nodeapi
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+)
+
+// payloadCache is an LRU cache of data entry payloads bounded by their
+// total size in bytes. Evicted payloads are read back from their state
+// files. It is safe for concurrent use.
+type payloadCache struct {
+	mu    sync.Mutex
+	max   int64
+	size  int64
+	order *list.List // *payloadItem, most recently used first
+	items map[string]*list.Element
+}
+
+type payloadItem struct {
+	key     string
+	payload json.RawMessage
+}
+
+func newPayloadCache(max int64) *payloadCache {
+	return &payloadCache{
+		max:   max,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached payload of key and marks it as recently used.
+func (c *payloadCache) get(key string) (json.RawMessage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*payloadItem).payload, true
+}
+
+// put caches payload for key, replacing a cached payload, and evicts the
+// least recently used payloads beyond the limit. A payload larger than the
+// limit is not cached.
+func (c *payloadCache) put(key string, payload json.RawMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(key)
+	n := int64(len(payload))
+	if n > c.max {
+		return
+	}
+	c.items[key] = c.order.PushFront(&payloadItem{key: key, payload: payload})
+	c.size += n
+	c.evictLocked()
+}
+
+// remove drops the cached payload of key, if any.
+func (c *payloadCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(key)
+}
+
+// clear drops all cached payloads.
+func (c *payloadCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	clear(c.items)
+	c.size = 0
+}
+
+// setMax changes the limit and evicts payloads beyond it.
+func (c *payloadCache) setMax(max int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.max = max
+	c.evictLocked()
+}
+
+// stats returns the number and total size of the cached payloads, and the
+// limit.
+func (c *payloadCache) stats() (entries int, size, max int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items), c.size, c.max
+}
+
+func (c *payloadCache) removeLocked(key string) {
+	el, ok := c.items[key]
+	if !ok {
+		return
+	}
+	c.order.Remove(el)
+	delete(c.items, key)
+	c.size -= int64(len(el.Value.(*payloadItem).payload))
+}
+
+func (c *payloadCache) evictLocked() {
+	for c.size > c.max {
+		c.removeLocked(c.order.Back().Value.(*payloadItem).key)
+	}
+}
@@ -0,0 +1,61 @@
+package nodeapi
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPayloadCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := newPayloadCache(10)
+	c.put("a", json.RawMessage(`"aaa"`))
+	c.put("b", json.RawMessage(`"bbb"`))
+	c.get("a")
+	c.put("c", json.RawMessage(`"ccc"`))
+
+	if _, ok := c.get("b"); ok {
+		t.Error("b is cached, want evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.get(key); !ok {
+			t.Errorf("%s is not cached", key)
+		}
+	}
+	if n, size, _ := c.stats(); n != 2 || size != 10 {
+		t.Errorf("stats = %d entries, %d bytes, want 2, 10", n, size)
+	}
+}
+
+func TestPayloadCache_Replace(t *testing.T) {
+	c := newPayloadCache(10)
+	c.put("a", json.RawMessage(`"aaa"`))
+	c.put("a", json.RawMessage(`"a"`))
+	if p, _ := c.get("a"); string(p) != `"a"` {
+		t.Errorf("get(a) = %s, want the replacement", p)
+	}
+	if _, size, _ := c.stats(); size != 3 {
+		t.Errorf("size = %d, want 3", size)
+	}
+
+	// A payload above the limit replaces the cached one without being
+	// cached itself.
+	c.put("a", json.RawMessage(`"aaaaaaaaaaaa"`))
+	if _, ok := c.get("a"); ok {
+		t.Error("payload above the limit is cached")
+	}
+	if n, size, _ := c.stats(); n != 0 || size != 0 {
+		t.Errorf("stats = %d entries, %d bytes, want empty", n, size)
+	}
+}
+
+func TestPayloadCache_SetMax(t *testing.T) {
+	c := newPayloadCache(100)
+	c.put("a", json.RawMessage(`"aaa"`))
+	c.put("b", json.RawMessage(`"bbb"`))
+	c.setMax(5)
+	if _, ok := c.get("a"); ok {
+		t.Error("a is cached after lowering the limit, want evicted")
+	}
+	if n, size, limit := c.stats(); n != 1 || size != 5 || limit != 5 {
+		t.Errorf("stats = %d, %d, %d, want 1, 5, 5", n, size, limit)
+	}
+}
@@ -0,0 +1,62 @@
+package nodeapi
+
+import (
+	"context"
+	"net/http"
+)
+
+// PeerCredentials holds the peer credentials extracted from a Unix socket connection.
+type PeerCredentials struct {
+	PID uint32
+	UID uint32
+	GID uint32
+}
+
+// PeerAccess lists the local users and groups allowed to use a scope on the
+// Unix socket. Users are names or numeric UIDs; groups are names. Root is
+// always allowed.
+type PeerAccess struct {
+	Users  []string `yaml:"users"`
+	Groups []string `yaml:"groups"`
+}
+
+// secretsGroup is the group granted secrets:read when SecretAuthEnabled is set.
+const secretsGroup = "plexd-secrets"
+
+// PeerAuthorizer decides whether the process on the other end of a Unix
+// socket request may use a scope.
+type PeerAuthorizer interface {
+	// AuthorizePeer reports whether the peer of r may use scope, and returns
+	// the peer's credentials, or nil if they are unavailable.
+	AuthorizePeer(r *http.Request, scope string) (*PeerCredentials, bool)
+}
+
+// localRequestKey marks requests received on the Unix socket.
+type localRequestKey struct{}
+
+// markLocal wraps the Unix socket handler so that the Handler can tell its
+// requests from TCP requests, which are not subject to peer authorization.
+func markLocal(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), localRequestKey{}, true)))
+	})
+}
+
+// isLocalRequest reports whether the request was received on the Unix socket.
+func isLocalRequest(ctx context.Context) bool {
+	local, _ := ctx.Value(localRequestKey{}).(bool)
+	return local
+}
+
+// peerAuthRules returns the configured peer rules, adding the plexd-secrets
+// rule for secrets:read if SecretAuthEnabled is set and no rule overrides it.
+func peerAuthRules(cfg Config) map[string]PeerAccess {
+	rules := make(map[string]PeerAccess, len(cfg.PeerAuth)+1)
+	for scope, access := range cfg.PeerAuth {
+		rules[scope] = access
+	}
+	if _, ok := rules[ScopeSecretsRead]; cfg.SecretAuthEnabled && !ok {
+		rules[ScopeSecretsRead] = PeerAccess{Groups: []string{secretsGroup}}
+	}
+	return rules
+}
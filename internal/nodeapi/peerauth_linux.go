@@ -0,0 +1,100 @@
+//go:build linux
+
+package nodeapi
+
+import (
+	"log/slog"
+	"net/http"
+	"os/user"
+	"slices"
+	"strconv"
+)
+
+// peerAuthorizer authorizes Unix socket peers by SO_PEERCRED credentials.
+type peerAuthorizer struct {
+	rules   map[string]peerRule
+	checker GroupChecker
+	getter  PeerCredGetter
+}
+
+// peerRule is a PeerAccess with its users resolved to UIDs.
+type peerRule struct {
+	uids   []uint32
+	groups []string
+}
+
+// newPeerAuthorizer returns a PeerAuthorizer enforcing the peer rules of
+// cfg, or nil if there are none. Users that cannot be resolved are logged
+// and ignored, which only narrows access.
+func newPeerAuthorizer(cfg Config, logger *slog.Logger) PeerAuthorizer {
+	rules := peerAuthRules(cfg)
+	if len(rules) == 0 {
+		return nil
+	}
+	return &peerAuthorizer{
+		rules:   resolvePeerRules(rules, lookupUID, logger),
+		checker: OSGroupChecker{},
+		getter:  contextPeerCredGetter{},
+	}
+}
+
+// resolvePeerRules resolves the users of each rule to UIDs using lookup.
+func resolvePeerRules(rules map[string]PeerAccess, lookup func(string) (uint32, error), logger *slog.Logger) map[string]peerRule {
+	resolved := make(map[string]peerRule, len(rules))
+	for scope, access := range rules {
+		rule := peerRule{groups: access.Groups}
+		for _, name := range access.Users {
+			uid, err := lookup(name)
+			if err != nil {
+				logger.Warn("peer auth: unknown user ignored",
+					"scope", scope,
+					"user", name,
+					"error", err,
+				)
+				continue
+			}
+			rule.uids = append(rule.uids, uid)
+		}
+		resolved[scope] = rule
+	}
+	return resolved
+}
+
+// lookupUID returns the UID of a user name or numeric UID.
+func lookupUID(name string) (uint32, error) {
+	if uid, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(uid), nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(uid), nil
+}
+
+// AuthorizePeer allows root, the rule's users and members of the rule's
+// groups. Scopes without a rule are allowed. Requests whose credentials
+// cannot be determined are denied for scopes with a rule.
+func (a *peerAuthorizer) AuthorizePeer(r *http.Request, scope string) (*PeerCredentials, bool) {
+	rule, ok := a.rules[scope]
+	if !ok {
+		return nil, true
+	}
+	cred, err := a.getter.GetPeerCredentials(r)
+	if err != nil {
+		return nil, false
+	}
+	if cred.UID == 0 || slices.Contains(rule.uids, cred.UID) {
+		return cred, true
+	}
+	for _, g := range rule.groups {
+		if a.checker.IsInGroup(cred.UID, cred.GID, g) {
+			return cred, true
+		}
+	}
+	return cred, false
+}
@@ -0,0 +1,132 @@
+//go:build linux
+
+package nodeapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestPeerAuthorizer(rules map[string]PeerAccess, checker GroupChecker, getter PeerCredGetter) *peerAuthorizer {
+	lookup := func(name string) (uint32, error) {
+		if name == "deploy" {
+			return 1001, nil
+		}
+		return lookupUID(name)
+	}
+	return &peerAuthorizer{
+		rules:   resolvePeerRules(rules, lookup, discardLogger()),
+		checker: checker,
+		getter:  getter,
+	}
+}
+
+func TestPeerAuthorizer(t *testing.T) {
+	rules := map[string]PeerAccess{
+		ScopeSecretsRead:  {Groups: []string{"plexd-secrets"}},
+		ScopeReportsWrite: {Users: []string{"deploy", "2000", "no-such-user-xyz"}},
+	}
+	checker := &mockGroupChecker{groups: map[string]bool{"1500:plexd-secrets": true}}
+
+	tests := []struct {
+		name  string
+		uid   uint32
+		scope string
+		want  bool
+	}{
+		{"root allowed", 0, ScopeSecretsRead, true},
+		{"group member allowed", 1500, ScopeSecretsRead, true},
+		{"non-member denied", 1001, ScopeSecretsRead, false},
+		{"user by name allowed", 1001, ScopeReportsWrite, true},
+		{"user by uid allowed", 2000, ScopeReportsWrite, true},
+		{"other user denied", 1500, ScopeReportsWrite, false},
+		{"scope without rule allowed", 1500, ScopeStateRead, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getter := &mockPeerCredGetter{creds: &PeerCredentials{PID: 42, UID: tt.uid, GID: tt.uid}}
+			a := newTestPeerAuthorizer(rules, checker, getter)
+			req := httptest.NewRequest(http.MethodGet, "/v1/state", nil)
+			cred, ok := a.AuthorizePeer(req, tt.scope)
+			if ok != tt.want {
+				t.Errorf("AuthorizePeer = %v, want %v", ok, tt.want)
+			}
+			if tt.scope != ScopeStateRead && (cred == nil || cred.UID != tt.uid) {
+				t.Errorf("cred = %+v, want uid %d", cred, tt.uid)
+			}
+		})
+	}
+}
+
+func TestPeerAuthorizer_CredentialError(t *testing.T) {
+	rules := map[string]PeerAccess{ScopeSecretsRead: {}}
+	getter := &mockPeerCredGetter{err: errors.New("no creds")}
+	a := newTestPeerAuthorizer(rules, &mockGroupChecker{}, getter)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/state/secrets", nil)
+	cred, ok := a.AuthorizePeer(req, ScopeSecretsRead)
+	if ok {
+		t.Error("expected denial without peer credentials")
+	}
+	if cred != nil {
+		t.Errorf("cred = %+v, want nil", cred)
+	}
+}
+
+func TestNewPeerAuthorizer_NoRules(t *testing.T) {
+	if a := newPeerAuthorizer(Config{}, discardLogger()); a != nil {
+		t.Errorf("newPeerAuthorizer without rules = %v, want nil", a)
+	}
+	if a := newPeerAuthorizer(Config{SecretAuthEnabled: true}, discardLogger()); a == nil {
+		t.Error("newPeerAuthorizer with SecretAuthEnabled = nil, want authorizer")
+	}
+}
+
+func TestHandler_PeerAuthDenialAudited(t *testing.T) {
+	cache := NewStateCache(t.TempDir(), discardLogger())
+	if err := cache.Load(); err != nil {
+		t.Fatalf("cache.Load: %v", err)
+	}
+	h := NewHandler(cache, &mockSecretFetcher{}, "node-1", testKey(t), discardLogger())
+	audit := newAuditLog("host-1")
+	h.SetPeerAuthorizer(newTestPeerAuthorizer(
+		map[string]PeerAccess{ScopeConfigReload: {}},
+		&mockGroupChecker{},
+		contextPeerCredGetter{},
+	), audit)
+	mux := h.Mux()
+
+	cred := &PeerCredentials{PID: 7, UID: 1000, GID: 1000}
+	ctx := context.WithValue(context.Background(), peerCredKey{}, cred)
+
+	// Unix socket request from a non-root peer is denied.
+	req := httptest.NewRequest(http.MethodPost, "/v1/config/reload", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	markLocal(mux).ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", rec.Code)
+	}
+
+	// The same request over TCP is not subject to peer authorization.
+	req = httptest.NewRequest(http.MethodGet, "/v1/config/reload", nil).WithContext(ctx)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code == http.StatusForbidden {
+		t.Fatalf("TCP request status = 403, want peer auth skipped")
+	}
+
+	entries := audit.collect()
+	if len(entries) != 1 {
+		t.Fatalf("audit entries = %d, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.EventType != accessAuditEventType || e.Result != "denied" || e.Action != ScopeConfigReload || e.Hostname != "host-1" {
+		t.Errorf("entry = %+v", e)
+	}
+	if string(e.Subject) != `{"gid":1000,"pid":7,"uid":1000}` {
+		t.Errorf("subject = %s", e.Subject)
+	}
+}
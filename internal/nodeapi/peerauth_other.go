@@ -0,0 +1,15 @@
+//go:build !linux
+
+package nodeapi
+
+import "log/slog"
+
+// newPeerAuthorizer returns nil on non-Linux platforms (no SO_PEERCRED).
+// Explicit PeerAuth rules are logged as ignored; socket permissions (or the
+// named pipe ACL) are the only gate.
+func newPeerAuthorizer(cfg Config, logger *slog.Logger) PeerAuthorizer {
+	if len(cfg.PeerAuth) > 0 {
+		logger.Warn("peer auth not supported on this platform, PeerAuth rules ignored")
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package nodeapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPeerAuthRules(t *testing.T) {
+	rules := peerAuthRules(Config{SecretAuthEnabled: true})
+	if got := rules[ScopeSecretsRead].Groups; len(got) != 1 || got[0] != "plexd-secrets" {
+		t.Errorf("secrets:read groups = %v, want [plexd-secrets]", got)
+	}
+
+	// A configured secrets:read rule replaces the default.
+	cfg := Config{
+		SecretAuthEnabled: true,
+		PeerAuth:          map[string]PeerAccess{ScopeSecretsRead: {Users: []string{"vault"}}},
+	}
+	rules = peerAuthRules(cfg)
+	if got := rules[ScopeSecretsRead]; len(got.Groups) != 0 || len(got.Users) != 1 {
+		t.Errorf("secrets:read = %+v, want configured rule", got)
+	}
+
+	if rules := peerAuthRules(Config{}); len(rules) != 0 {
+		t.Errorf("rules = %v, want none", rules)
+	}
+}
+
+func TestMarkLocal(t *testing.T) {
+	var local bool
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		local = isLocalRequest(r.Context())
+	})
+
+	inner.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/state", nil))
+	if local {
+		t.Error("unmarked request reported as local")
+	}
+	markLocal(inner).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/state", nil))
+	if !local {
+		t.Error("marked request not reported as local")
+	}
+}
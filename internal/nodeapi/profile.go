@@ -0,0 +1,191 @@
+package nodeapi
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+// Profile is the state namespace of an additional mesh profile. Its state
+// cache, secrets, and reports are served under /v1/profiles/{name}/state
+// with the same routes and scopes as /v1/state, and reports written there
+// are synced to the profile's control plane.
+type Profile struct {
+	name   string
+	nodeID string
+	client NodeAPIClient
+	nsk    []byte
+	cfg    Config
+	cache  *StateCache
+	syncer *ReportSyncer
+	logger *slog.Logger
+
+	// loaded is true once Run has loaded the cache from disk.
+	loaded atomic.Bool
+
+	// handler serves the rewritten /v1/state routes; set by Server.AddProfile.
+	handler http.Handler
+}
+
+// NewProfile creates the namespace of the named profile. cfg.DataDir is the
+// profile's data directory; its state cache lives in cfg.DataDir/state/ and
+// is encrypted with a key derived from nsk.
+func NewProfile(name string, cfg Config, client NodeAPIClient, nodeID string, nsk []byte, logger *slog.Logger) *Profile {
+	cfg.ApplyDefaults()
+	lg := logger.With("component", "nodeapi", "profile", name)
+	cache := NewStateCache(cfg.DataDir, lg)
+	if len(nsk) > 0 {
+		if err := cache.SetEncryptionKey(nsk); err != nil {
+			lg.Error("state cache encryption disabled", "error", err)
+		}
+	}
+	syncer := NewReportSyncer(client, nodeID, cfg.DebouncePeriod, lg)
+	syncer.SetMaxDelay(cfg.ReportSyncMaxDelay)
+	return &Profile{
+		name:   name,
+		nodeID: nodeID,
+		client: client,
+		nsk:    nsk,
+		cfg:    cfg,
+		cache:  cache,
+		syncer: syncer,
+		logger: lg,
+	}
+}
+
+// Name returns the profile name.
+func (p *Profile) Name() string {
+	return p.name
+}
+
+// ReconcileHandler returns a reconcile.ReconcileHandler that updates the
+// profile's cache when drift is detected.
+func (p *Profile) ReconcileHandler() reconcile.ReconcileHandler {
+	return cacheReconcileHandler(p.cache)
+}
+
+// Run loads the profile's cache and syncs its reports until ctx is
+// cancelled. Requests to the namespace are rejected until the cache is
+// loaded.
+func (p *Profile) Run(ctx context.Context) error {
+	if err := p.cache.Load(); err != nil {
+		return err
+	}
+	p.loaded.Store(true)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_ = p.syncer.Run(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		runReportGC(ctx, p.cache, p.syncer, p.cfg.ReportGCInterval, p.logger)
+	}()
+	wg.Wait()
+	return ctx.Err()
+}
+
+// profileSet holds the profiles mounted on a server. Profiles may be added
+// while the server runs, e.g. once a profile finishes registering.
+type profileSet struct {
+	mu sync.RWMutex
+	m  map[string]*Profile
+}
+
+func (ps *profileSet) add(p *Profile) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.m == nil {
+		ps.m = make(map[string]*Profile)
+	}
+	ps.m[p.name] = p
+}
+
+func (ps *profileSet) remove(name string) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	delete(ps.m, name)
+}
+
+func (ps *profileSet) get(name string) (*Profile, bool) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	p, ok := ps.m[name]
+	return p, ok
+}
+
+// ProfileSummary is an entry of the GET /v1/profiles response.
+type ProfileSummary struct {
+	Name   string `json:"name"`
+	NodeID string `json:"node_id"`
+	Ready  bool   `json:"ready"`
+}
+
+func (ps *profileSet) list() []ProfileSummary {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	out := make([]ProfileSummary, 0, len(ps.m))
+	for _, p := range ps.m {
+		out = append(out, ProfileSummary{Name: p.name, NodeID: p.nodeID, Ready: p.loaded.Load()})
+	}
+	slices.SortFunc(out, func(a, b ProfileSummary) int { return strings.Compare(a.Name, b.Name) })
+	return out
+}
+
+// AddProfile mounts p under /v1/profiles/{name}/state. The namespace uses
+// the server's peer authorization, scoped tokens, and metadata write
+// prefix. It may be called before or after Start.
+func (s *Server) AddProfile(p *Profile) {
+	h := NewHandler(p.cache, p.client, p.nodeID, p.nsk, p.logger)
+	h.SetMetadataWritePrefix(s.cfg.MetadataWritePrefix)
+	h.decryptor = s.decrypt
+	h.SetPeerAuthorizer(newPeerAuthorizer(s.cfg, s.logger), s.audit)
+	p.handler = reportNotifyMiddleware(h.Mux(), p.cache, p.syncer)
+	s.profiles.add(p)
+	s.logger.Info("profile namespace added", "profile", p.name, "node_id", p.nodeID)
+}
+
+// RemoveProfile unmounts the named profile. Requests to its namespace
+// return 404 afterwards.
+func (s *Server) RemoveProfile(name string) {
+	s.profiles.remove(name)
+}
+
+func (h *Handler) handleGetProfiles(w http.ResponseWriter, _ *http.Request) {
+	profiles := []ProfileSummary{}
+	if h.profiles != nil {
+		profiles = h.profiles.list()
+	}
+	writeJSON(w, http.StatusOK, profiles)
+}
+
+// handleProfileState serves /v1/profiles/{name}/state and below by
+// rewriting the path to /v1/state and passing the request to the profile's
+// handler, which checks scopes itself.
+func (h *Handler) handleProfileState(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var p *Profile
+	if h.profiles != nil {
+		p, _ = h.profiles.get(name)
+	}
+	if p == nil || p.handler == nil {
+		writeError(w, http.StatusNotFound, "profile not found")
+		return
+	}
+	if !p.loaded.Load() {
+		writeError(w, http.StatusServiceUnavailable, "profile state not loaded")
+		return
+	}
+	r2 := r.Clone(r.Context())
+	r2.URL.Path = "/v1" + strings.TrimPrefix(r.URL.Path, "/v1/profiles/"+name)
+	r2.URL.RawPath = ""
+	p.handler.ServeHTTP(w, r2)
+}
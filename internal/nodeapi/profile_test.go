@@ -0,0 +1,148 @@
+package nodeapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/reconcile"
+	"go.uber.org/goleak"
+)
+
+func TestServer_ProfileNamespace(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	srv, cfg := newTestServer(t, &serverTestClient{})
+
+	var mu sync.Mutex
+	var syncedNodes []string
+	profileClient := &configurableTestClient{
+		syncReports: func(_ context.Context, nodeID string, _ api.ReportSyncRequest) error {
+			mu.Lock()
+			syncedNodes = append(syncedNodes, nodeID)
+			mu.Unlock()
+			return nil
+		},
+	}
+	profile := NewProfile("staging", Config{
+		DataDir:        t.TempDir(),
+		DebouncePeriod: 50 * time.Millisecond,
+	}, profileClient, "node-staging", make([]byte, 32), discardLogger())
+	srv.AddProfile(profile)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.Start(ctx, "node-1") }()
+	if !waitForSocket(t, cfg.SocketPath, 2*time.Second) {
+		cancel()
+		t.Fatal("socket did not appear")
+	}
+	client := unixSocketClient(cfg.SocketPath)
+
+	get := func(path string) *http.Response {
+		t.Helper()
+		resp, err := client.Get("http://unix" + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	// Not loaded yet.
+	if resp := get("/v1/profiles/staging/state"); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("before Run: status = %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	profileDone := make(chan error, 1)
+	go func() { profileDone <- profile.Run(ctx) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for get("/v1/profiles/staging/state").StatusCode != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatal("profile namespace did not become ready")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if resp := get("/v1/profiles/unknown/state"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown profile: status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+
+	// Reconcile into the profile cache and read it back through the namespace.
+	err := profile.ReconcileHandler()(ctx, &api.StateResponse{
+		Metadata: map[string]string{"env": "staging"},
+	}, reconcile.StateDiff{MetadataChanged: true})
+	if err != nil {
+		t.Fatalf("ReconcileHandler: %v", err)
+	}
+	resp, err := client.Get("http://unix/v1/profiles/staging/state/metadata/env")
+	if err != nil {
+		t.Fatalf("GET metadata: %v", err)
+	}
+	var meta map[string]string
+	_ = json.NewDecoder(resp.Body).Decode(&meta)
+	resp.Body.Close()
+	if meta["value"] != "staging" {
+		t.Errorf("profile metadata = %v, want env=staging", meta)
+	}
+
+	// A report written to the profile is synced as the profile's node and
+	// does not appear in the top-level namespace.
+	req, _ := http.NewRequest(http.MethodPut, "http://unix/v1/profiles/staging/state/report/health",
+		strings.NewReader(`{"content_type":"application/json","payload":{"ok":true}}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("PUT report: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT report: status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if resp := get("/v1/state/report/health"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("top-level report: status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+
+	deadline = time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(syncedNodes)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("profile report was not synced")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	if syncedNodes[0] != "node-staging" {
+		t.Errorf("synced node = %q, want node-staging", syncedNodes[0])
+	}
+	mu.Unlock()
+
+	// The profile list.
+	resp, err = client.Get("http://unix/v1/profiles")
+	if err != nil {
+		t.Fatalf("GET /v1/profiles: %v", err)
+	}
+	var profiles []ProfileSummary
+	_ = json.NewDecoder(resp.Body).Decode(&profiles)
+	resp.Body.Close()
+	if len(profiles) != 1 || profiles[0].Name != "staging" || profiles[0].NodeID != "node-staging" || !profiles[0].Ready {
+		t.Errorf("profiles = %+v, want [staging node-staging ready]", profiles)
+	}
+
+	cancel()
+	<-errCh
+	<-profileDone
+}
@@ -0,0 +1,50 @@
+package nodeapi
+
+import (
+	"net/http"
+	"net/http/pprof"
+	runtimepprof "runtime/pprof"
+)
+
+// SetDebug enables the profiling and debug endpoints: net/http/pprof under
+// /debug/pprof/, GET /v1/debug/goroutines, and GET /v1/debug/state-snapshot
+// with desired as the source of the desired state. They are served on the
+// Unix socket only and require ScopeDebugControl. If not called, the
+// endpoints do not exist.
+func (h *Handler) SetDebug(desired DesiredStateSource) {
+	h.debug = true
+	h.desired = desired
+}
+
+// registerDebug adds the endpoints enabled by SetDebug to mux.
+func (h *Handler) registerDebug(mux *http.ServeMux) {
+	debug := func(next http.HandlerFunc) http.HandlerFunc {
+		return h.localOnly(h.requireScope(ScopeDebugControl, next))
+	}
+	mux.HandleFunc("/debug/pprof/", debug(pprof.Index))
+	mux.HandleFunc("GET /debug/pprof/cmdline", debug(pprof.Cmdline))
+	mux.HandleFunc("GET /debug/pprof/profile", debug(pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", debug(pprof.Symbol))
+	mux.HandleFunc("GET /debug/pprof/trace", debug(pprof.Trace))
+	mux.HandleFunc("GET /v1/debug/goroutines", debug(h.handleGetGoroutines))
+	mux.HandleFunc("GET /v1/debug/state-snapshot", debug(h.handleGetStateSnapshot))
+}
+
+// localOnly answers requests not received on the Unix socket with 404, so
+// that the debug endpoints cannot be reached over TCP, even with a token.
+func (h *Handler) localOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isLocalRequest(r.Context()) {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleGetGoroutines writes the stacks of all goroutines as text.
+func (h *Handler) handleGetGoroutines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = runtimepprof.Lookup("goroutine").WriteTo(w, 2)
+}
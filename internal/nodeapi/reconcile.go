@@ -0,0 +1,178 @@
+package nodeapi
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+// ReconcileHistory reports recent reconciliation cycles.
+// *reconcile.Reconciler satisfies this interface.
+type ReconcileHistory interface {
+	// History returns the recorded cycles, oldest first.
+	History() []reconcile.CycleRecord
+}
+
+// SetReconcileHistory sets the source of GET /v1/reconcile/history. If not
+// set, the endpoint returns 503.
+func (h *Handler) SetReconcileHistory(rh ReconcileHistory) {
+	h.reconcileHistory = rh
+}
+
+// handleGetReconcileHistory returns the recorded cycles, newest first. The
+// optional query parameters since (RFC 3339) and limit narrow the result to
+// cycles started at or after since and to the limit most recent ones.
+func (h *Handler) handleGetReconcileHistory(w http.ResponseWriter, r *http.Request) {
+	if h.reconcileHistory == nil {
+		writeError(w, http.StatusServiceUnavailable, "reconcile history not available")
+		return
+	}
+
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid since: must be an RFC 3339 timestamp")
+			return
+		}
+		since = t
+	}
+	limit := 0
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "invalid limit: must be a positive integer")
+			return
+		}
+		limit = n
+	}
+
+	history := h.reconcileHistory.History()
+	cycles := make([]reconcile.CycleRecord, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Start.Before(since) || (limit > 0 && len(cycles) == limit) {
+			break
+		}
+		cycles = append(cycles, history[i])
+	}
+	writeJSON(w, http.StatusOK, cycles)
+}
+
+// MaxPauseDuration is the longest pause accepted by
+// POST /v1/reconcile/pause, so that a forgotten pause ends by itself.
+const MaxPauseDuration = 24 * time.Hour
+
+// ReconcileController triggers, pauses and resumes reconciliation.
+// *reconcile.Reconciler satisfies this interface.
+type ReconcileController interface {
+	// TriggerReconcile requests an immediate cycle.
+	TriggerReconcile()
+	// Pause skips cycles for d and returns the deadline.
+	Pause(d time.Duration) time.Time
+	// Resume ends a pause early and reports whether one was active.
+	Resume() bool
+	// PausedUntil returns the deadline of the current pause, or the zero
+	// time if reconciliation is not paused.
+	PausedUntil() time.Time
+}
+
+// PauseStatus is the response of the /v1/reconcile/pause endpoints.
+type PauseStatus struct {
+	Paused bool       `json:"paused"`
+	Until  *time.Time `json:"until,omitempty"`
+}
+
+// SetReconcileController sets the controller behind POST
+// /v1/reconcile/trigger and /v1/reconcile/pause. If not set, those
+// endpoints return 503.
+func (h *Handler) SetReconcileController(rc ReconcileController) {
+	h.reconcileCtl = rc
+}
+
+// pauseStatus returns the current pause of h.reconcileCtl.
+func (h *Handler) pauseStatus() PauseStatus {
+	until := h.reconcileCtl.PausedUntil()
+	if until.IsZero() {
+		return PauseStatus{}
+	}
+	return PauseStatus{Paused: true, Until: &until}
+}
+
+// auditReconcile logs and audits a reconciliation control request.
+func (h *Handler) auditReconcile(r *http.Request, action string, object map[string]string, msg string) {
+	attrs := []any{"action", action}
+	if c := ClientFromContext(r.Context()); c != nil {
+		attrs = append(attrs, "client", c.Name)
+	} else if cred := requestPeerCredentials(r); cred != nil {
+		attrs = append(attrs, "uid", cred.UID, "pid", cred.PID)
+	}
+	for _, k := range slices.Sorted(maps.Keys(object)) {
+		attrs = append(attrs, k, object[k])
+	}
+	h.logger.Info(msg, attrs...)
+	if h.audit != nil {
+		h.audit.reconcileControl(r, action, object, msg)
+	}
+}
+
+// handlePostReconcileTrigger requests an immediate reconciliation cycle.
+// While reconciliation is paused the request is rejected with 409.
+func (h *Handler) handlePostReconcileTrigger(w http.ResponseWriter, r *http.Request) {
+	if h.reconcileCtl == nil {
+		writeError(w, http.StatusServiceUnavailable, "reconcile control not available")
+		return
+	}
+	if status := h.pauseStatus(); status.Paused {
+		writeError(w, http.StatusConflict, "reconciliation paused until "+status.Until.UTC().Format(time.RFC3339))
+		return
+	}
+	h.reconcileCtl.TriggerReconcile()
+	h.auditReconcile(r, "reconcile.trigger", nil, "reconciliation triggered via node API")
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "triggered"})
+}
+
+func (h *Handler) handleGetReconcilePause(w http.ResponseWriter, r *http.Request) {
+	if h.reconcileCtl == nil {
+		writeError(w, http.StatusServiceUnavailable, "reconcile control not available")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.pauseStatus())
+}
+
+// handlePostReconcilePause pauses reconciliation for the duration query
+// parameter, at most MaxPauseDuration. Pausing while paused replaces the
+// deadline.
+func (h *Handler) handlePostReconcilePause(w http.ResponseWriter, r *http.Request) {
+	if h.reconcileCtl == nil {
+		writeError(w, http.StatusServiceUnavailable, "reconcile control not available")
+		return
+	}
+	d, err := time.ParseDuration(r.URL.Query().Get("duration"))
+	if err != nil || d <= 0 || d > MaxPauseDuration {
+		writeError(w, http.StatusBadRequest, "invalid duration: must be a positive duration of at most "+MaxPauseDuration.String())
+		return
+	}
+	until := h.reconcileCtl.Pause(d)
+	h.auditReconcile(r, "reconcile.pause", map[string]string{
+		"duration": d.String(),
+		"until":    until.UTC().Format(time.RFC3339),
+	}, "reconciliation paused via node API")
+	writeJSON(w, http.StatusOK, PauseStatus{Paused: true, Until: &until})
+}
+
+// handleDeleteReconcilePause ends a pause early. Deleting when not paused
+// succeeds without effect.
+func (h *Handler) handleDeleteReconcilePause(w http.ResponseWriter, r *http.Request) {
+	if h.reconcileCtl == nil {
+		writeError(w, http.StatusServiceUnavailable, "reconcile control not available")
+		return
+	}
+	if h.reconcileCtl.Resume() {
+		h.auditReconcile(r, "reconcile.resume", nil, "reconciliation resumed via node API")
+	}
+	writeJSON(w, http.StatusOK, PauseStatus{})
+}
@@ -0,0 +1,265 @@
+package nodeapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+type mockReconcileHistory struct {
+	cycles []reconcile.CycleRecord
+}
+
+func (m *mockReconcileHistory) History() []reconcile.CycleRecord {
+	return m.cycles
+}
+
+func newReconcileTestServer(t *testing.T, rh ReconcileHistory) *httptest.Server {
+	t.Helper()
+	cache := NewStateCache(t.TempDir(), discardLogger())
+	if err := cache.Load(); err != nil {
+		t.Fatalf("cache.Load: %v", err)
+	}
+	h := NewHandler(cache, &mockSecretFetcher{}, "node-1", testKey(t), discardLogger())
+	if rh != nil {
+		h.SetReconcileHistory(rh)
+	}
+	srv := httptest.NewServer(h.Mux())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHandler_ReconcileHistory_NotConfigured(t *testing.T) {
+	srv := newReconcileTestServer(t, nil)
+
+	resp := mustGet(t, srv.URL+"/v1/reconcile/history")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", resp.StatusCode)
+	}
+}
+
+func TestHandler_ReconcileHistory(t *testing.T) {
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	rh := &mockReconcileHistory{}
+	for i := range 4 {
+		rh.cycles = append(rh.cycles, reconcile.CycleRecord{
+			Start:   base.Add(time.Duration(i) * time.Minute),
+			Trigger: reconcile.TriggerInterval,
+		})
+	}
+	srv := newReconcileTestServer(t, rh)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []time.Time
+	}{
+		{"all newest first", "", []time.Time{base.Add(3 * time.Minute), base.Add(2 * time.Minute), base.Add(time.Minute), base}},
+		{"limit", "?limit=2", []time.Time{base.Add(3 * time.Minute), base.Add(2 * time.Minute)}},
+		{"since", "?since=" + url.QueryEscape(base.Add(2*time.Minute).Format(time.RFC3339)), []time.Time{base.Add(3 * time.Minute), base.Add(2 * time.Minute)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := mustGet(t, srv.URL+"/v1/reconcile/history"+tt.query)
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want 200", resp.StatusCode)
+			}
+			var got []reconcile.CycleRecord
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d cycles, want %d", len(got), len(tt.want))
+			}
+			for i, c := range got {
+				if !c.Start.Equal(tt.want[i]) {
+					t.Errorf("cycle %d start = %v, want %v", i, c.Start, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_ReconcileHistory_BadQuery(t *testing.T) {
+	srv := newReconcileTestServer(t, &mockReconcileHistory{})
+
+	for _, q := range []string{"?limit=0", "?limit=x", "?since=yesterday"} {
+		resp := mustGet(t, srv.URL+"/v1/reconcile/history"+q)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", q, resp.StatusCode)
+		}
+	}
+}
+
+type mockReconcileController struct {
+	triggers int
+	until    time.Time
+}
+
+func (m *mockReconcileController) TriggerReconcile() { m.triggers++ }
+
+func (m *mockReconcileController) Pause(d time.Duration) time.Time {
+	m.until = time.Now().Add(d)
+	return m.until
+}
+
+func (m *mockReconcileController) Resume() bool {
+	paused := !m.until.IsZero()
+	m.until = time.Time{}
+	return paused
+}
+
+func (m *mockReconcileController) PausedUntil() time.Time { return m.until }
+
+func newReconcileControlTestServer(t *testing.T, rc ReconcileController, audit *auditLog) *httptest.Server {
+	t.Helper()
+	cache := NewStateCache(t.TempDir(), discardLogger())
+	if err := cache.Load(); err != nil {
+		t.Fatalf("cache.Load: %v", err)
+	}
+	h := NewHandler(cache, &mockSecretFetcher{}, "node-1", testKey(t), discardLogger())
+	h.SetPeerAuthorizer(nil, audit)
+	if rc != nil {
+		h.SetReconcileController(rc)
+	}
+	srv := httptest.NewServer(h.Mux())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func doReconcileRequest(t *testing.T, method, url string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestHandler_ReconcileControl_NotConfigured(t *testing.T) {
+	srv := newReconcileControlTestServer(t, nil, nil)
+
+	for _, tc := range []struct{ method, path string }{
+		{http.MethodPost, "/v1/reconcile/trigger"},
+		{http.MethodGet, "/v1/reconcile/pause"},
+		{http.MethodPost, "/v1/reconcile/pause?duration=30m"},
+		{http.MethodDelete, "/v1/reconcile/pause"},
+	} {
+		resp := doReconcileRequest(t, tc.method, srv.URL+tc.path)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("%s %s: status = %d, want 503", tc.method, tc.path, resp.StatusCode)
+		}
+	}
+}
+
+func TestHandler_ReconcileTrigger(t *testing.T) {
+	rc := &mockReconcileController{}
+	audit := newAuditLog("host-1")
+	srv := newReconcileControlTestServer(t, rc, audit)
+
+	resp := doReconcileRequest(t, http.MethodPost, srv.URL+"/v1/reconcile/trigger")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", resp.StatusCode)
+	}
+	if rc.triggers != 1 {
+		t.Errorf("triggers = %d, want 1", rc.triggers)
+	}
+	entries := audit.collect()
+	if len(entries) != 1 || entries[0].EventType != reconcileAuditEventType || entries[0].Action != "reconcile.trigger" || entries[0].Hostname != "host-1" {
+		t.Errorf("audit entries = %+v", entries)
+	}
+}
+
+func TestHandler_ReconcileTrigger_Paused(t *testing.T) {
+	rc := &mockReconcileController{until: time.Now().Add(time.Hour)}
+	srv := newReconcileControlTestServer(t, rc, nil)
+
+	resp := doReconcileRequest(t, http.MethodPost, srv.URL+"/v1/reconcile/trigger")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("status = %d, want 409", resp.StatusCode)
+	}
+	if rc.triggers != 0 {
+		t.Errorf("triggers = %d, want 0 while paused", rc.triggers)
+	}
+}
+
+func TestHandler_ReconcilePause(t *testing.T) {
+	rc := &mockReconcileController{}
+	audit := newAuditLog("host-1")
+	srv := newReconcileControlTestServer(t, rc, audit)
+
+	resp := doReconcileRequest(t, http.MethodPost, srv.URL+"/v1/reconcile/pause?duration=30m")
+	var status PauseStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST status = %d, want 200", resp.StatusCode)
+	}
+	if !status.Paused || status.Until == nil || time.Until(*status.Until) < 29*time.Minute {
+		t.Errorf("POST status = %+v, want paused for 30m", status)
+	}
+
+	resp = mustGet(t, srv.URL+"/v1/reconcile/pause")
+	status = PauseStatus{}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if !status.Paused {
+		t.Errorf("GET status = %+v, want paused", status)
+	}
+
+	resp = doReconcileRequest(t, http.MethodDelete, srv.URL+"/v1/reconcile/pause")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("DELETE status = %d, want 200", resp.StatusCode)
+	}
+	if !rc.until.IsZero() {
+		t.Error("pause not ended by DELETE")
+	}
+
+	// A second DELETE has nothing to resume and is not audited.
+	resp = doReconcileRequest(t, http.MethodDelete, srv.URL+"/v1/reconcile/pause")
+	resp.Body.Close()
+
+	entries := audit.collect()
+	if len(entries) != 2 {
+		t.Fatalf("got %d audit entries, want 2", len(entries))
+	}
+	if entries[0].Action != "reconcile.pause" || entries[1].Action != "reconcile.resume" {
+		t.Errorf("audit actions = %q, %q", entries[0].Action, entries[1].Action)
+	}
+	var object map[string]string
+	if err := json.Unmarshal(entries[0].Object, &object); err != nil || object["duration"] != "30m0s" {
+		t.Errorf("pause audit object = %s", entries[0].Object)
+	}
+}
+
+func TestHandler_ReconcilePause_BadDuration(t *testing.T) {
+	srv := newReconcileControlTestServer(t, &mockReconcileController{}, nil)
+
+	for _, q := range []string{"", "?duration=soon", "?duration=-1m", "?duration=25h"} {
+		resp := doReconcileRequest(t, http.MethodPost, srv.URL+"/v1/reconcile/pause"+q)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%q: status = %d, want 400", q, resp.StatusCode)
+		}
+	}
+}
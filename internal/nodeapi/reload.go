@@ -0,0 +1,73 @@
+package nodeapi
+
+import (
+	"net/http"
+	"time"
+)
+
+// ReloadStatus describes the outcome of a configuration reload.
+type ReloadStatus struct {
+	// Time is when the reload was attempted.
+	Time time.Time `json:"time"`
+
+	// Success is false when the file could not be read, failed validation,
+	// or a change could not be applied.
+	Success bool `json:"success"`
+
+	// Error describes the failure. Empty on success.
+	Error string `json:"error,omitempty"`
+
+	// Changed lists the config keys that differ from the running config,
+	// e.g. "log_level" or "heartbeat.interval".
+	Changed []string `json:"changed"`
+
+	// Applied lists the changed keys that took effect without a restart.
+	Applied []string `json:"applied"`
+
+	// RestartRequired lists the changed keys that take effect only after
+	// the agent is restarted.
+	RestartRequired []string `json:"restart_required"`
+}
+
+// ConfigReloader reloads the agent configuration file.
+// *agent.ConfigReloader satisfies this interface.
+type ConfigReloader interface {
+	// Reload re-reads the configuration and applies it synchronously.
+	Reload() ReloadStatus
+
+	// LastReload returns the outcome of the most recent reload, or nil if
+	// none has been attempted.
+	LastReload() *ReloadStatus
+}
+
+// SetConfigReloader sets the reloader behind GET and POST /v1/config/reload.
+// If not set, both endpoints return 503.
+func (h *Handler) SetConfigReloader(cr ConfigReloader) {
+	h.reloader = cr
+}
+
+func (h *Handler) handleGetConfigReload(w http.ResponseWriter, r *http.Request) {
+	if h.reloader == nil {
+		writeError(w, http.StatusServiceUnavailable, "config reload not available")
+		return
+	}
+	last := h.reloader.LastReload()
+	if last == nil {
+		writeError(w, http.StatusNotFound, "no config reload attempted")
+		return
+	}
+	writeJSON(w, http.StatusOK, last)
+}
+
+func (h *Handler) handlePostConfigReload(w http.ResponseWriter, r *http.Request) {
+	if h.reloader == nil {
+		writeError(w, http.StatusServiceUnavailable, "config reload not available")
+		return
+	}
+	status := h.reloader.Reload()
+	code := http.StatusOK
+	if !status.Success {
+		code = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, code, status)
+}
@@ -0,0 +1,125 @@
+package nodeapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type mockConfigReloader struct {
+	status ReloadStatus
+	last   *ReloadStatus
+	calls  int
+}
+
+func (m *mockConfigReloader) Reload() ReloadStatus {
+	m.calls++
+	m.last = &m.status
+	return m.status
+}
+
+func (m *mockConfigReloader) LastReload() *ReloadStatus {
+	return m.last
+}
+
+func newReloadTestServer(t *testing.T, cr ConfigReloader) *httptest.Server {
+	t.Helper()
+	cache := NewStateCache(t.TempDir(), discardLogger())
+	if err := cache.Load(); err != nil {
+		t.Fatalf("cache.Load: %v", err)
+	}
+	h := NewHandler(cache, &mockSecretFetcher{}, "node-1", testKey(t), discardLogger())
+	if cr != nil {
+		h.SetConfigReloader(cr)
+	}
+	srv := httptest.NewServer(h.Mux())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHandler_ConfigReload_NotConfigured(t *testing.T) {
+	srv := newReloadTestServer(t, nil)
+
+	resp := mustGet(t, srv.URL+"/v1/config/reload")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("GET status = %d, want 503", resp.StatusCode)
+	}
+
+	resp, err := http.Post(srv.URL+"/v1/config/reload", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("POST status = %d, want 503", resp.StatusCode)
+	}
+}
+
+func TestHandler_GetConfigReload_NoneAttempted(t *testing.T) {
+	srv := newReloadTestServer(t, &mockConfigReloader{})
+
+	resp := mustGet(t, srv.URL+"/v1/config/reload")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestHandler_PostConfigReload(t *testing.T) {
+	cr := &mockConfigReloader{status: ReloadStatus{
+		Time:            time.Now(),
+		Success:         true,
+		Changed:         []string{"log_level", "metrics.enabled"},
+		Applied:         []string{"log_level"},
+		RestartRequired: []string{"metrics.enabled"},
+	}}
+	srv := newReloadTestServer(t, cr)
+
+	resp, err := http.Post(srv.URL+"/v1/config/reload", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST status = %d, want 200", resp.StatusCode)
+	}
+	var result ReloadStatus
+	decodeJSON(t, resp, &result)
+	if !result.Success || len(result.Applied) != 1 || result.Applied[0] != "log_level" {
+		t.Errorf("result = %+v, want success with applied [log_level]", result)
+	}
+	if cr.calls != 1 {
+		t.Errorf("Reload calls = %d, want 1", cr.calls)
+	}
+
+	resp = mustGet(t, srv.URL+"/v1/config/reload")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET status = %d, want 200", resp.StatusCode)
+	}
+	decodeJSON(t, resp, &result)
+	if len(result.RestartRequired) != 1 || result.RestartRequired[0] != "metrics.enabled" {
+		t.Errorf("restart_required = %v, want [metrics.enabled]", result.RestartRequired)
+	}
+}
+
+func TestHandler_PostConfigReload_Failure(t *testing.T) {
+	cr := &mockConfigReloader{status: ReloadStatus{
+		Time:  time.Now(),
+		Error: `agent: config: invalid mode "edge" (must be "node" or "bridge")`,
+	}}
+	srv := newReloadTestServer(t, cr)
+
+	resp, err := http.Post(srv.URL+"/v1/config/reload", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", resp.StatusCode)
+	}
+	var result ReloadStatus
+	decodeJSON(t, resp, &result)
+	if result.Success || result.Error == "" {
+		t.Errorf("result = %+v, want failure with error", result)
+	}
+}
@@ -0,0 +1,191 @@
+package nodeapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Authorization scopes granted to node API clients.
+const (
+	// ScopeStateRead allows reading state, metadata, data and report entries.
+	ScopeStateRead = "state:read"
+	// ScopeSecretsRead allows listing and reading secrets.
+	ScopeSecretsRead = "secrets:read"
+	// ScopeReportsWrite allows creating, updating and deleting report entries.
+	ScopeReportsWrite = "reports:write"
+	// ScopeMetadataWrite allows setting node labels.
+	ScopeMetadataWrite = "metadata:write"
+	// ScopeConfigReload allows reading the reload status and reloading the
+	// agent configuration.
+	ScopeConfigReload = "config:reload"
+	// ScopeReconcileControl allows triggering, pausing and resuming
+	// reconciliation.
+	ScopeReconcileControl = "reconcile:control"
+	// ScopeEventsControl allows forcing the control plane event stream to
+	// reconnect.
+	ScopeEventsControl = "events:control"
+	// ScopeCNIManage allows allocating and releasing container addresses.
+	ScopeCNIManage = "cni:manage"
+	// ScopeDebugControl allows changing log levels and reading captured
+	// log lines.
+	ScopeDebugControl = "debug:control"
+)
+
+// AllScopes lists every scope. A token read from Config.HTTPTokenFile is
+// granted all of them.
+var AllScopes = []string{
+	ScopeStateRead,
+	ScopeSecretsRead,
+	ScopeReportsWrite,
+	ScopeMetadataWrite,
+	ScopeConfigReload,
+	ScopeReconcileControl,
+	ScopeEventsControl,
+	ScopeCNIManage,
+	ScopeDebugControl,
+}
+
+// Client is an authenticated node API client and the scopes it was granted.
+type Client struct {
+	// Name identifies the client in logs, e.g. the token file name.
+	Name   string
+	Scopes []string
+}
+
+// HasScope reports whether c was granted scope.
+func (c *Client) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// clientKey is the context key for the authenticated *Client.
+type clientKey struct{}
+
+// WithClient returns a copy of ctx carrying the authenticated client.
+func WithClient(ctx context.Context, c *Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, c)
+}
+
+// ClientFromContext returns the authenticated client, or nil if the request
+// was not authenticated with a token.
+func ClientFromContext(ctx context.Context) *Client {
+	c, _ := ctx.Value(clientKey{}).(*Client)
+	return c
+}
+
+// Token is a bearer token and the scopes it grants.
+type Token struct {
+	// Name identifies the token's client in logs.
+	Name string `json:"-"`
+	// Token is the bearer token value.
+	Token string `json:"token"`
+	// Scopes are the scopes granted to requests bearing the token.
+	Scopes []string `json:"scopes"`
+}
+
+// LoadTokenDir reads the tokens in dir. Each token is a file named
+// {client}.json holding {"token": "...", "scopes": ["state:read", ...]};
+// the file name without extension becomes the client name. Hidden files and
+// files without the .json extension are ignored.
+func LoadTokenDir(dir string) ([]Token, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("nodeapi: token dir: %w", err)
+	}
+
+	var tokens []Token
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("nodeapi: token dir: %w", err)
+		}
+		var t Token
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, fmt.Errorf("nodeapi: token dir: %s: %w", name, err)
+		}
+		t.Name = strings.TrimSuffix(name, ".json")
+		if err := validateToken(t); err != nil {
+			return nil, fmt.Errorf("nodeapi: token dir: %s: %w", name, err)
+		}
+		tokens = append(tokens, t)
+	}
+	if err := checkDuplicateTokens(tokens); err != nil {
+		return nil, fmt.Errorf("nodeapi: token dir: %w", err)
+	}
+	return tokens, nil
+}
+
+// checkDuplicateTokens returns an error if two tokens share a value, which
+// would make the granted scopes depend on lookup order.
+func checkDuplicateTokens(tokens []Token) error {
+	seen := make(map[string]string, len(tokens))
+	for _, t := range tokens {
+		if prev, ok := seen[t.Token]; ok {
+			return fmt.Errorf("%s and %s use the same token", prev, t.Name)
+		}
+		seen[t.Token] = t.Name
+	}
+	return nil
+}
+
+// validateToken checks that t has a value and only known scopes.
+func validateToken(t Token) error {
+	if strings.TrimSpace(t.Token) == "" {
+		return fmt.Errorf("token is empty")
+	}
+	for _, s := range t.Scopes {
+		if !slices.Contains(AllScopes, s) {
+			return fmt.Errorf("unknown scope %q", s)
+		}
+	}
+	return nil
+}
+
+// requireScope wraps a route handler so that token-authenticated requests
+// without scope are rejected with 403 Forbidden. Requests without a client,
+// such as Unix socket requests without a token, are passed through. Unix
+// socket requests must additionally come from a peer allowed scope.
+func (h *Handler) requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.peerAuth != nil && isLocalRequest(r.Context()) {
+			if cred, ok := h.peerAuth.AuthorizePeer(r, scope); !ok {
+				h.denyPeer(w, r, scope, cred)
+				return
+			}
+		}
+		if c := ClientFromContext(r.Context()); c != nil && !c.HasScope(scope) {
+			h.logger.Warn("request denied: missing scope",
+				"client", c.Name,
+				"scope", scope,
+				"method", r.Method,
+				"path", r.URL.Path,
+			)
+			writeError(w, http.StatusForbidden, "forbidden: missing scope "+scope)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// denyPeer rejects a Unix socket request whose peer is not allowed scope,
+// logging and auditing the denial.
+func (h *Handler) denyPeer(w http.ResponseWriter, r *http.Request, scope string, cred *PeerCredentials) {
+	attrs := []any{"scope", scope, "method", r.Method, "path", r.URL.Path}
+	if cred != nil {
+		attrs = append(attrs, "uid", cred.UID, "gid", cred.GID, "pid", cred.PID)
+	}
+	h.logger.Warn("request denied: peer not authorized", attrs...)
+	if h.audit != nil {
+		h.audit.peerDenied(r, scope, cred)
+	}
+	writeError(w, http.StatusForbidden, "forbidden: peer not authorized for scope "+scope)
+}
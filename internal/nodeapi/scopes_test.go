@@ -0,0 +1,208 @@
+package nodeapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTokenFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadTokenDir(t *testing.T) {
+	dir := t.TempDir()
+	writeTokenFile(t, dir, "monitoring.json", `{"token":"tok-mon","scopes":["state:read"]}`)
+	writeTokenFile(t, dir, "deployer.json", `{"token":"tok-dep","scopes":["state:read","reports:write"]}`)
+	writeTokenFile(t, dir, ".hidden.json", `{"token":"tok-hidden","scopes":["secrets:read"]}`)
+	writeTokenFile(t, dir, "README", "not a token")
+
+	tokens, err := LoadTokenDir(dir)
+	if err != nil {
+		t.Fatalf("LoadTokenDir: %v", err)
+	}
+	if len(tokens) != 2 {
+		t.Fatalf("len(tokens) = %d, want 2", len(tokens))
+	}
+	// os.ReadDir returns entries sorted by name.
+	if tokens[0].Name != "deployer" || tokens[0].Token != "tok-dep" || len(tokens[0].Scopes) != 2 {
+		t.Errorf("tokens[0] = %+v", tokens[0])
+	}
+	if tokens[1].Name != "monitoring" || tokens[1].Token != "tok-mon" {
+		t.Errorf("tokens[1] = %+v", tokens[1])
+	}
+}
+
+func TestLoadTokenDir_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "empty token",
+			files: map[string]string{"a.json": `{"token":" ","scopes":["state:read"]}`},
+			want:  "token is empty",
+		},
+		{
+			name:  "unknown scope",
+			files: map[string]string{"a.json": `{"token":"x","scopes":["state:write"]}`},
+			want:  `unknown scope "state:write"`,
+		},
+		{
+			name:  "invalid json",
+			files: map[string]string{"a.json": `{`},
+			want:  "a.json",
+		},
+		{
+			name: "duplicate token",
+			files: map[string]string{
+				"a.json": `{"token":"same","scopes":["state:read"]}`,
+				"b.json": `{"token":"same","scopes":["secrets:read"]}`,
+			},
+			want: "a and b use the same token",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				writeTokenFile(t, dir, name, content)
+			}
+			_, err := LoadTokenDir(dir)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTokenDir_Missing(t *testing.T) {
+	if _, err := LoadTokenDir(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("expected error for missing directory")
+	}
+}
+
+func TestTokenAuth_StoresClient(t *testing.T) {
+	tokens := []Token{
+		{Name: "monitoring", Token: "tok-mon", Scopes: []string{ScopeStateRead}},
+		{Name: "deployer", Token: "tok-dep", Scopes: []string{ScopeReportsWrite}},
+	}
+
+	var got *Client
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = ClientFromContext(r.Context())
+	})
+	handler := TokenAuthMiddleware(tokens, true)(inner)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/state", nil)
+	req.Header.Set("Authorization", "Bearer tok-dep")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if got == nil || got.Name != "deployer" {
+		t.Fatalf("client = %+v, want deployer", got)
+	}
+	if !got.HasScope(ScopeReportsWrite) || got.HasScope(ScopeStateRead) {
+		t.Errorf("scopes = %v", got.Scopes)
+	}
+}
+
+func TestTokenAuth_Optional(t *testing.T) {
+	tokens := []Token{{Name: "monitoring", Token: "tok-mon", Scopes: []string{ScopeStateRead}}}
+
+	var called bool
+	var got *Client
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		got = ClientFromContext(r.Context())
+	})
+	handler := TokenAuthMiddleware(tokens, false)(inner)
+
+	// No Authorization header: passed through without a client.
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/state", nil))
+	if !called || got != nil {
+		t.Fatalf("called = %v, client = %+v; want called without client", called, got)
+	}
+
+	// Invalid token: rejected even though auth is optional.
+	called = false
+	req := httptest.NewRequest(http.MethodGet, "/v1/state", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", rec.Code)
+	}
+	if called {
+		t.Error("inner handler should not be called")
+	}
+}
+
+func TestHandler_RequireScope(t *testing.T) {
+	cache := NewStateCache(t.TempDir(), discardLogger())
+	if err := cache.Load(); err != nil {
+		t.Fatalf("cache.Load: %v", err)
+	}
+	h := NewHandler(cache, &mockSecretFetcher{}, "node-1", testKey(t), discardLogger())
+	tokens := []Token{{Name: "monitoring", Token: "tok-mon", Scopes: []string{ScopeStateRead}}}
+	srv := httptest.NewServer(TokenAuthMiddleware(tokens, true)(h.Mux()))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{http.MethodGet, "/v1/health", "", http.StatusOK},
+		{http.MethodGet, "/v1/state", "", http.StatusOK},
+		{http.MethodGet, "/v1/state/report", "", http.StatusOK},
+		{http.MethodGet, "/v1/state/secrets", "", http.StatusForbidden},
+		{http.MethodGet, "/v1/state/secrets/db", "", http.StatusForbidden},
+		{http.MethodPut, "/v1/state/report/k", `{"data":{}}`, http.StatusForbidden},
+		{http.MethodDelete, "/v1/state/report/k", "", http.StatusForbidden},
+		{http.MethodPut, "/v1/state/metadata/k", `{"value":"v"}`, http.StatusForbidden},
+		{http.MethodPost, "/v1/config/reload", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer tok-mon")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.want == http.StatusForbidden {
+				var body map[string]string
+				if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if !strings.HasPrefix(body["error"], "forbidden: missing scope ") {
+					t.Errorf("error = %q", body["error"])
+				}
+			}
+		})
+	}
+}
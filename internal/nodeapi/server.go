@@ -2,16 +2,20 @@ package nodeapi
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/metrics"
 	"github.com/plexsphere/plexd/internal/reconcile"
 )
 
@@ -21,14 +25,36 @@ type NodeAPIClient interface {
 	ReportSyncClient
 }
 
-// Server is the local node API server. It serves HTTP over a Unix socket and
-// optionally over TCP with bearer token authentication.
+// Server is the local node API server. It serves HTTP over a Unix socket (a
+// named pipe on Windows) and optionally over TCP with bearer token
+// authentication.
 type Server struct {
-	cfg    Config
-	client NodeAPIClient
-	nsk    []byte
-	logger *slog.Logger
-	cache  *StateCache
+	cfg      Config
+	client   NodeAPIClient
+	nsk      []byte
+	logger   *slog.Logger
+	cache    *StateCache
+	health   HealthReporter
+	liveness LivenessReporter
+	ready    ReadinessReporter
+	reload   ConfigReloader
+	history  ReconcileHistory
+	control  ReconcileController
+	events   EventStream
+	cni      ContainerNetwork
+	mesh     MeshPeerSource
+	levels   LogLevelController
+	capture  LogCapture
+	bundle   SupportBundler
+	debug    bool
+	desired  DesiredStateSource
+	audit    *auditLog
+	decrypt  *secretDecryptor
+	syncer   atomic.Pointer[ReportSyncer]
+	profiles profileSet
+
+	// serving is true while the local listener accepts requests.
+	serving atomic.Bool
 }
 
 // NewServer creates a new Server. Config defaults are applied automatically.
@@ -40,15 +66,112 @@ func NewServer(cfg Config, client NodeAPIClient, nsk []byte, logger *slog.Logger
 		logger = slog.Default()
 	}
 	lg := logger.With("component", "nodeapi")
+	hostname, _ := os.Hostname()
+	cache := NewStateCache(cfg.DataDir, lg)
+	cache.SetDataCacheLimit(cfg.DataCacheBytes)
+	// Encrypt the state cache at rest with a key derived from the NSK.
+	if len(nsk) > 0 {
+		if err := cache.SetEncryptionKey(nsk); err != nil {
+			lg.Error("state cache encryption disabled", "error", err)
+		}
+	}
 	return &Server{
-		cfg:    cfg,
-		client: client,
-		nsk:    nsk,
-		logger: lg,
-		cache:  NewStateCache(cfg.DataDir, lg),
+		cfg:     cfg,
+		client:  client,
+		nsk:     nsk,
+		logger:  lg,
+		cache:   cache,
+		audit:   newAuditLog(hostname),
+		decrypt: newSecretDecryptor(cfg.SecretDecryptWorkers),
 	}
 }
 
+// SetHealthReporter sets the source of reconcile handler health exposed via
+// GET /v1/health. It must be called before Start.
+func (s *Server) SetHealthReporter(hr HealthReporter) {
+	s.health = hr
+}
+
+// SetLivenessReporter sets the source of subsystem liveness exposed via
+// GET /healthz. It must be called before Start.
+func (s *Server) SetLivenessReporter(lr LivenessReporter) {
+	s.liveness = lr
+}
+
+// SetReadinessReporter sets the source of agent readiness exposed via
+// GET /readyz. It must be called before Start.
+func (s *Server) SetReadinessReporter(rr ReadinessReporter) {
+	s.ready = rr
+}
+
+// Serving reports whether the local listener is accepting requests.
+func (s *Server) Serving() bool {
+	return s.serving.Load()
+}
+
+// SetConfigReloader sets the configuration reloader exposed via
+// GET and POST /v1/config/reload. It must be called before Start.
+func (s *Server) SetConfigReloader(cr ConfigReloader) {
+	s.reload = cr
+}
+
+// SetReconcileHistory sets the source of GET /v1/reconcile/history. It must
+// be called before Start.
+func (s *Server) SetReconcileHistory(rh ReconcileHistory) {
+	s.history = rh
+}
+
+// SetReconcileController sets the controller behind POST
+// /v1/reconcile/trigger and /v1/reconcile/pause. It must be called before
+// Start.
+func (s *Server) SetReconcileController(rc ReconcileController) {
+	s.control = rc
+}
+
+// SetEventStream sets the source of GET /v1/events/status and POST
+// /v1/events/reconnect. It must be called before Start.
+func (s *Server) SetEventStream(es EventStream) {
+	s.events = es
+}
+
+// SetContainerNetwork sets the allocator behind /v1/cni/allocations. It must
+// be called before Start.
+func (s *Server) SetContainerNetwork(cn ContainerNetwork) {
+	s.cni = cn
+}
+
+// SetMeshPeers sets the source of GET /v1/mesh/peers. It must be called
+// before Start.
+func (s *Server) SetMeshPeers(mp MeshPeerSource) {
+	s.mesh = mp
+}
+
+// SetLogLevels sets the controller behind /v1/debug/loglevel. It must be
+// called before Start.
+func (s *Server) SetLogLevels(lc LogLevelController) {
+	s.levels = lc
+}
+
+// SetLogCapture sets the source of GET /v1/debug/logs. It must be called
+// before Start.
+func (s *Server) SetLogCapture(lc LogCapture) {
+	s.capture = lc
+}
+
+// SetSupportBundle sets the source of GET /v1/debug/support-bundle. It
+// must be called before Start.
+func (s *Server) SetSupportBundle(b SupportBundler) {
+	s.bundle = b
+}
+
+// SetDebug enables the profiling and debug endpoints on the Unix socket,
+// with desired as the source of the desired state in
+// GET /v1/debug/state-snapshot. It must be called before Start.
+func (s *Server) SetDebug(desired DesiredStateSource) {
+	s.debug = true
+	s.desired = desired
+}
+
 // Start initializes and runs the server. It blocks until ctx is cancelled.
 func (s *Server) Start(ctx context.Context, nodeID string) error {
 	if err := s.cfg.Validate(); err != nil {
@@ -62,38 +185,86 @@ func (s *Server) Start(ctx context.Context, nodeID string) error {
 
 	// Start report syncer.
 	syncer := NewReportSyncer(s.client, nodeID, s.cfg.DebouncePeriod, s.logger)
+	syncer.SetMaxDelay(s.cfg.ReportSyncMaxDelay)
+	s.syncer.Store(syncer)
 
 	// Set up HTTP handler.
 	handler := NewHandler(s.cache, s.client, nodeID, s.nsk, s.logger)
+	if s.health != nil {
+		handler.SetHealthReporter(s.health)
+	}
+	if s.liveness != nil {
+		handler.SetLivenessReporter(s.liveness)
+	}
+	if s.ready != nil {
+		handler.SetReadinessReporter(s.ready)
+	}
+	if s.reload != nil {
+		handler.SetConfigReloader(s.reload)
+	}
+	if s.history != nil {
+		handler.SetReconcileHistory(s.history)
+	}
+	if s.control != nil {
+		handler.SetReconcileController(s.control)
+	}
+	if s.events != nil {
+		handler.SetEventStream(s.events)
+	}
+	if s.cni != nil {
+		handler.SetContainerNetwork(s.cni)
+	}
+	if s.mesh != nil {
+		handler.SetMeshPeers(s.mesh)
+	}
+	if s.levels != nil {
+		handler.SetLogLevels(s.levels)
+	}
+	if s.capture != nil {
+		handler.SetLogCapture(s.capture)
+	}
+	if s.bundle != nil {
+		handler.SetSupportBundle(s.bundle)
+	}
+	if s.debug {
+		handler.SetDebug(s.desired)
+	}
+	handler.SetMetadataWritePrefix(s.cfg.MetadataWritePrefix)
+	handler.decryptor = s.decrypt
+	handler.profiles = &s.profiles
+	// Restrict scopes on the Unix socket by peer (Linux: SO_PEERCRED).
+	// SecretAuthEnabled requires root or plexd-secrets for secrets:read.
+	handler.SetPeerAuthorizer(newPeerAuthorizer(s.cfg, s.logger), s.audit)
 	mux := handler.Mux()
 
 	// Wrap mux with a report-sync notifier.
 	wrappedMux := reportNotifyMiddleware(mux, s.cache, syncer)
 
-	// Remove stale socket.
-	os.Remove(s.cfg.SocketPath)
-
-	// Ensure socket directory exists.
-	if dir := filepath.Dir(s.cfg.SocketPath); dir != "" {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("nodeapi: create socket dir: %w", err)
+	// Load scoped client tokens.
+	var scoped []Token
+	if s.cfg.HTTPTokenDir != "" {
+		var err error
+		if scoped, err = LoadTokenDir(s.cfg.HTTPTokenDir); err != nil {
+			return err
 		}
 	}
 
-	// Open Unix socket listener.
-	unixLn, err := net.Listen("unix", s.cfg.SocketPath)
+	// Open the local listener (Unix socket, or named pipe on Windows).
+	unixLn, err := listenLocal(s.cfg.SocketPath)
 	if err != nil {
-		return fmt.Errorf("nodeapi: listen unix %s: %w", s.cfg.SocketPath, err)
+		return err
 	}
 
 	// Set socket ownership and permissions (Linux: root:plexd 0660).
 	applySocketPermissions(s.cfg.SocketPath, s.logger)
 
-	// Wrap secret routes with peer credential auth (Linux: SO_PEERCRED).
-	// Only enabled when SecretAuthEnabled is set (requires root to set socket perms).
-	var unixHandler http.Handler = wrappedMux
-	if s.cfg.SecretAuthEnabled {
-		unixHandler = wrapSecretAuth(wrappedMux, s.logger)
+	// Mark Unix socket requests for peer authorization.
+	unixHandler := markLocal(wrappedMux)
+
+	// Scoped tokens are optional on the Unix socket: requests presenting
+	// one are restricted to its scopes, requests without one are not.
+	if len(scoped) > 0 {
+		unixHandler = TokenAuthMiddleware(scoped, false)(unixHandler)
 	}
 
 	unixServer := &http.Server{
@@ -105,31 +276,50 @@ func (s *Server) Start(ctx context.Context, nodeID string) error {
 	var tcpLn net.Listener
 
 	if s.cfg.HTTPEnabled {
-		// Read token from file.
-		token, err := readTokenFile(s.cfg.HTTPTokenFile)
+		tokens, err := s.tcpTokens(scoped)
 		if err != nil {
 			unixLn.Close()
-			os.Remove(s.cfg.SocketPath)
-			return fmt.Errorf("nodeapi: read token file: %w", err)
+			removeLocal(s.cfg.SocketPath)
+			return err
 		}
 
-		// TCP mux wraps with auth middleware.
-		authMiddleware := BearerAuthMiddleware(token)
-		tcpHandler := authMiddleware(wrappedMux)
+		// TCP mux wraps with auth middleware; only the liveness
+		// endpoint is reachable without a token.
+		authMiddleware := TokenAuthMiddleware(tokens, true)
+		tcpHandler := livenessBypass(wrappedMux, authMiddleware(wrappedMux))
 
 		tcpLn, err = net.Listen("tcp", s.cfg.HTTPListen)
 		if err != nil {
 			unixLn.Close()
-			os.Remove(s.cfg.SocketPath)
+			removeLocal(s.cfg.SocketPath)
 			return fmt.Errorf("nodeapi: listen tcp %s: %w", s.cfg.HTTPListen, err)
 		}
 		tcpServer = &http.Server{Handler: tcpHandler}
 	}
 
+	// The probe listener serves only the liveness and readiness endpoints,
+	// without authentication.
+	var probeServer *http.Server
+	var probeLn net.Listener
+
+	if s.cfg.ProbeListen != "" {
+		probeLn, err = net.Listen("tcp", s.cfg.ProbeListen)
+		if err != nil {
+			if tcpLn != nil {
+				tcpLn.Close()
+			}
+			unixLn.Close()
+			removeLocal(s.cfg.SocketPath)
+			return fmt.Errorf("nodeapi: listen probes %s: %w", s.cfg.ProbeListen, err)
+		}
+		probeServer = &http.Server{Handler: handler.ProbeMux()}
+	}
+
 	s.logger.Info("server started",
 		"socket", s.cfg.SocketPath,
 		"http_enabled", s.cfg.HTTPEnabled,
 		"http_listen", s.cfg.HTTPListen,
+		"probe_listen", s.cfg.ProbeListen,
 		"node_id", nodeID,
 	)
 
@@ -144,10 +334,19 @@ func (s *Server) Start(ctx context.Context, nodeID string) error {
 		_ = syncer.Run(syncCtx)
 	}()
 
+	// Report GC goroutine.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runReportGC(syncCtx, s.cache, syncer, s.cfg.ReportGCInterval, s.logger)
+	}()
+
 	// Unix socket serve goroutine.
 	wg.Add(1)
+	s.serving.Store(true)
 	go func() {
 		defer wg.Done()
+		defer s.serving.Store(false)
 		if err := unixServer.Serve(unixLn); err != http.ErrServerClosed {
 			s.logger.Error("unix server error", "error", err)
 		}
@@ -164,6 +363,17 @@ func (s *Server) Start(ctx context.Context, nodeID string) error {
 		}()
 	}
 
+	// Probe serve goroutine.
+	if probeServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := probeServer.Serve(probeLn); err != http.ErrServerClosed {
+				s.logger.Error("probe server error", "error", err)
+			}
+		}()
+	}
+
 	// Wait for context cancellation.
 	<-ctx.Done()
 
@@ -177,12 +387,15 @@ func (s *Server) Start(ctx context.Context, nodeID string) error {
 	if tcpServer != nil {
 		_ = tcpServer.Shutdown(shutdownCtx)
 	}
+	if probeServer != nil {
+		_ = probeServer.Shutdown(shutdownCtx)
+	}
 
 	// Stop syncer.
 	syncCancel()
 
 	// Remove socket file.
-	os.Remove(s.cfg.SocketPath)
+	removeLocal(s.cfg.SocketPath)
 
 	// Wait for all goroutines.
 	wg.Wait()
@@ -200,26 +413,85 @@ func (s *Server) RegisterEventHandlers(dispatcher *api.EventDispatcher) {
 // ReconcileHandler returns a reconcile.ReconcileHandler that updates the cache
 // when drift is detected in metadata, data, or secret refs.
 func (s *Server) ReconcileHandler() reconcile.ReconcileHandler {
+	return cacheReconcileHandler(s.cache)
+}
+
+// cacheReconcileHandler returns a reconcile.ReconcileHandler that updates
+// cache from the desired state.
+func cacheReconcileHandler(cache *StateCache) reconcile.ReconcileHandler {
 	return func(ctx context.Context, desired *api.StateResponse, diff reconcile.StateDiff) error {
 		if diff.MetadataChanged {
-			s.cache.UpdateMetadata(desired.Metadata)
+			cache.UpdateMetadata(desired.Metadata)
 		}
 		if diff.DataChanged {
-			s.cache.UpdateData(desired.Data)
+			cache.UpdateData(desired.Data)
 		}
 		if diff.SecretRefsChanged {
-			s.cache.UpdateSecretIndex(desired.SecretRefs)
+			cache.UpdateSecretIndex(desired.SecretRefs)
 		}
 		return nil
 	}
 }
 
-// reportNotifyMiddleware wraps a handler to notify the syncer after report mutations.
+// ReportSyncStats returns the report sync stats. It implements
+// metrics.ReportSyncStatsReader; before Start the stats are zero.
+func (s *Server) ReportSyncStats() metrics.ReportSyncStats {
+	if syncer := s.syncer.Load(); syncer != nil {
+		return syncer.Stats()
+	}
+	return metrics.ReportSyncStats{}
+}
+
+// WriteReport stores a report entry written by the agent itself, replacing
+// any previous version, and queues it for sync to the control plane. It
+// fails before Start has loaded the cache.
+func (s *Server) WriteReport(key string, payload json.RawMessage) error {
+	syncer := s.syncer.Load()
+	if syncer == nil {
+		return errors.New("nodeapi: server not started")
+	}
+	entry, err := s.cache.PutReport(key, "application/json", payload, nil)
+	if err != nil {
+		return err
+	}
+	syncer.NotifyChange([]api.ReportEntry{
+		{
+			Key:         entry.Key,
+			ContentType: entry.ContentType,
+			Payload:     entry.Payload,
+			Version:     entry.Version,
+			UpdatedAt:   entry.UpdatedAt,
+		},
+	}, nil)
+	return nil
+}
+
+// runReportGC deletes expired report entries of cache once at start and
+// then every interval, and queues their deletion for sync, until ctx is done.
+func runReportGC(ctx context.Context, cache *StateCache, syncer *ReportSyncer, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if expired := cache.ExpireReports(time.Now()); len(expired) > 0 {
+			logger.Info("expired report entries deleted", "keys", expired)
+			syncer.NotifyChange(nil, expired)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reportNotifyMiddleware wraps a handler to notify the syncer after report
+// and metadata mutations.
 func reportNotifyMiddleware(next http.Handler, cache *StateCache, syncer *ReportSyncer) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Capture report state before the request for mutation detection.
 		isPutReport := r.Method == http.MethodPut && isReportPath(r.URL.Path)
 		isDeleteReport := r.Method == http.MethodDelete && isReportPath(r.URL.Path)
+		isPutMetadata := r.Method == http.MethodPut && isMetadataPath(r.URL.Path)
 
 		// Use a response recorder to detect status.
 		rw := &statusRecorder{ResponseWriter: w}
@@ -235,6 +507,7 @@ func reportNotifyMiddleware(next http.Handler, cache *StateCache, syncer *Report
 						Payload:     entry.Payload,
 						Version:     entry.Version,
 						UpdatedAt:   entry.UpdatedAt,
+						ExpiresAt:   entry.ExpiresAt,
 					},
 				}, nil)
 			}
@@ -243,6 +516,12 @@ func reportNotifyMiddleware(next http.Handler, cache *StateCache, syncer *Report
 			key := extractReportKey(r.URL.Path)
 			syncer.NotifyChange(nil, []string{key})
 		}
+		if isPutMetadata && rw.status == http.StatusOK {
+			key := extractReportKey(r.URL.Path)
+			if value, ok := cache.GetLabels()[key]; ok {
+				syncer.NotifyLabel(key, value)
+			}
+		}
 	})
 }
 
@@ -251,7 +530,13 @@ func isReportPath(path string) bool {
 	return strings.HasPrefix(path, "/v1/state/report/") && strings.Count(path, "/") == 4
 }
 
-// extractReportKey extracts the key from /v1/state/report/{key}.
+// isMetadataPath checks if the path matches /v1/state/metadata/{key}.
+func isMetadataPath(path string) bool {
+	return strings.HasPrefix(path, "/v1/state/metadata/") && strings.Count(path, "/") == 4
+}
+
+// extractReportKey extracts the key from /v1/state/report/{key} and
+// /v1/state/metadata/{key}.
 func extractReportKey(path string) string {
 	parts := strings.Split(path, "/")
 	if len(parts) >= 5 {
@@ -278,6 +563,24 @@ func (r *statusRecorder) Write(b []byte) (int, error) {
 	return r.ResponseWriter.Write(b)
 }
 
+// tcpTokens returns the tokens accepted on the TCP listener: the token in
+// HTTPTokenFile with all scopes, if set, followed by the scoped tokens.
+func (s *Server) tcpTokens(scoped []Token) ([]Token, error) {
+	var tokens []Token
+	if s.cfg.HTTPTokenFile != "" || len(scoped) == 0 {
+		token, err := readTokenFile(s.cfg.HTTPTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("nodeapi: read token file: %w", err)
+		}
+		tokens = append(tokens, Token{Name: "default", Token: token, Scopes: AllScopes})
+	}
+	tokens = append(tokens, scoped...)
+	if err := checkDuplicateTokens(tokens); err != nil {
+		return nil, fmt.Errorf("nodeapi: tokens: %w", err)
+	}
+	return tokens, nil
+}
+
 // readTokenFile reads and trims a bearer token from a file.
 func readTokenFile(path string) (string, error) {
 	if path == "" {
@@ -202,6 +202,95 @@ func TestServer_TCPListener(t *testing.T) {
 	}
 }
 
+func TestServer_TCPListener_TokenDir(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	client := &serverTestClient{}
+	srv, cfg := newTestServer(t, client)
+
+	tokenDir := filepath.Join(cfg.DataDir, "tokens")
+	if err := os.Mkdir(tokenDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tokenDir, "monitoring.json"),
+		[]byte(`{"token":"mon-token","scopes":["state:read"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	// Only scoped tokens: no HTTPTokenFile is required.
+	srv.cfg.HTTPEnabled = true
+	srv.cfg.HTTPListen = addr
+	srv.cfg.HTTPTokenDir = tokenDir
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.Start(ctx, "node-1") }()
+
+	if !waitForSocket(t, cfg.SocketPath, 2*time.Second) {
+		cancel()
+		t.Fatal("socket did not appear")
+	}
+	if !waitForTCP(t, addr, 2*time.Second) {
+		cancel()
+		t.Fatal("TCP listener not ready")
+	}
+
+	get := func(path string) int {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+addr+path, nil)
+		req.Header.Set("Authorization", "Bearer mon-token")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("GET %s: %v", path, err)
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := get("/v1/state"); code != http.StatusOK {
+		t.Errorf("GET /v1/state status = %d, want 200", code)
+	}
+	if code := get("/v1/state/secrets"); code != http.StatusForbidden {
+		t.Errorf("GET /v1/state/secrets status = %d, want 403", code)
+	}
+
+	cancel()
+	if err := <-errCh; err != nil && err != context.Canceled {
+		t.Fatalf("Start returned: %v", err)
+	}
+}
+
+func TestServer_InvalidTokenDir(t *testing.T) {
+	client := &serverTestClient{}
+	srv, cfg := newTestServer(t, client)
+
+	tokenDir := filepath.Join(cfg.DataDir, "tokens")
+	if err := os.Mkdir(tokenDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tokenDir, "bad.json"),
+		[]byte(`{"token":"x","scopes":["everything"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	srv.cfg.HTTPTokenDir = tokenDir
+
+	err := srv.Start(context.Background(), "node-1")
+	if err == nil || !strings.Contains(err.Error(), "unknown scope") {
+		t.Fatalf("Start error = %v, want unknown scope error", err)
+	}
+	if _, statErr := os.Stat(cfg.SocketPath); statErr == nil {
+		t.Error("socket should not be created when tokens are invalid")
+	}
+}
+
 func TestServer_GracefulShutdown(t *testing.T) {
 	defer goleak.VerifyNone(t)
 
@@ -515,6 +604,161 @@ func TestServer_ReportSyncIntegration(t *testing.T) {
 	<-errCh
 }
 
+func TestServer_ReportGCSyncsDeletes(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	syncCalls := make(chan api.ReportSyncRequest, 10)
+	tmpDir := t.TempDir()
+	cfg := Config{
+		SocketPath:       filepath.Join(tmpDir, "api.sock"),
+		DataDir:          tmpDir,
+		DebouncePeriod:   50 * time.Millisecond,
+		ShutdownTimeout:  2 * time.Second,
+		ReportGCInterval: 50 * time.Millisecond,
+	}
+	srv := NewServer(cfg, &trackingSyncClient{calls: syncCalls}, make([]byte, 32), nil)
+
+	// An entry persisted with an expiry that passes while the server runs.
+	if err := srv.cache.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.cache.PutReportWithExpiry("probe", "application/json", json.RawMessage(`{}`), nil, time.Now().Add(100*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.Start(ctx, "node-1") }()
+
+	deadline := time.After(3 * time.Second)
+	for {
+		select {
+		case req := <-syncCalls:
+			if len(req.Deleted) == 1 && req.Deleted[0] == "probe" {
+				cancel()
+				<-errCh
+				if _, ok := srv.cache.GetReports()["probe"]; ok {
+					t.Error("expired entry still in cache")
+				}
+				return
+			}
+		case <-deadline:
+			cancel()
+			<-errCh
+			t.Fatal("delete sync for expired entry not received")
+		}
+	}
+}
+
+func TestServer_ReportSyncStats(t *testing.T) {
+	srv, _ := newTestServer(t, &serverTestClient{})
+	if st := srv.ReportSyncStats(); st.Syncs != 0 || st.PendingChanges != 0 {
+		t.Errorf("stats before Start = %+v, want zero", st)
+	}
+}
+
+func TestServer_WriteReport(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	syncCalls := make(chan api.ReportSyncRequest, 10)
+	tmpDir := t.TempDir()
+	cfg := Config{
+		SocketPath:      filepath.Join(tmpDir, "api.sock"),
+		DataDir:         tmpDir,
+		DebouncePeriod:  50 * time.Millisecond,
+		ShutdownTimeout: 2 * time.Second,
+	}
+	srv := NewServer(cfg, &trackingSyncClient{calls: syncCalls}, make([]byte, 32), nil)
+
+	if err := srv.WriteReport("mesh.peers", json.RawMessage(`{}`)); err == nil {
+		t.Error("WriteReport before Start succeeded")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.Start(ctx, "node-1") }()
+
+	if !waitForSocket(t, cfg.SocketPath, 2*time.Second) {
+		cancel()
+		t.Fatal("socket did not appear")
+	}
+
+	if err := srv.WriteReport("mesh.peers", json.RawMessage(`{"peers":[]}`)); err != nil {
+		cancel()
+		t.Fatalf("WriteReport: %v", err)
+	}
+	if entry, ok := srv.cache.GetReport("mesh.peers"); !ok || entry.ContentType != "application/json" {
+		t.Errorf("cached entry = %+v, %v", entry, ok)
+	}
+
+	select {
+	case syncReq := <-syncCalls:
+		if len(syncReq.Entries) != 1 || syncReq.Entries[0].Key != "mesh.peers" {
+			t.Errorf("synced entries = %+v, want mesh.peers", syncReq.Entries)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("report sync not received")
+	}
+
+	cancel()
+	<-errCh
+}
+
+func TestServer_MetadataLabelSync(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	syncCalls := make(chan api.ReportSyncRequest, 10)
+	tmpDir := t.TempDir()
+	cfg := Config{
+		SocketPath:          filepath.Join(tmpDir, "api.sock"),
+		DataDir:             tmpDir,
+		DebouncePeriod:      50 * time.Millisecond,
+		ShutdownTimeout:     2 * time.Second,
+		MetadataWritePrefix: "label.",
+	}
+	srv := NewServer(cfg, &trackingSyncClient{calls: syncCalls}, make([]byte, 32), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.Start(ctx, "node-1") }()
+
+	if !waitForSocket(t, cfg.SocketPath, 2*time.Second) {
+		cancel()
+		t.Fatal("socket did not appear")
+	}
+
+	httpClient := unixSocketClient(cfg.SocketPath)
+	req, _ := http.NewRequest(http.MethodPut, "http://unix/v1/state/metadata/label.pool", strings.NewReader(`{"value":"gpu"}`))
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		cancel()
+		t.Fatalf("PUT: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		cancel()
+		t.Fatalf("PUT status = %d, want 200", resp.StatusCode)
+	}
+
+	select {
+	case syncReq := <-syncCalls:
+		if syncReq.Labels["label.pool"] != "gpu" {
+			t.Errorf("synced labels = %v, want label.pool=gpu", syncReq.Labels)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("label sync not received")
+	}
+
+	cancel()
+	<-errCh
+}
+
 type trackingSyncClient struct {
 	calls chan api.ReportSyncRequest
 }
@@ -0,0 +1,120 @@
+package nodeapi
+
+import (
+	"net/http"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+// redactedValue replaces secret values in the state snapshot.
+const redactedValue = "REDACTED"
+
+// DesiredStateSource reports the desired state the agent last applied.
+// *reconcile.Reconciler satisfies this interface.
+type DesiredStateSource interface {
+	// Applied returns the desired state of the last cycle that left the
+	// node in sync, or nil.
+	Applied() *api.StateResponse
+}
+
+// StateSnapshot is the response of GET /v1/debug/state-snapshot.
+type StateSnapshot struct {
+	Time   time.Time `json:"time"`
+	NodeID string    `json:"node_id"`
+
+	// Desired is the last applied desired state with preshared keys
+	// redacted and data payloads removed; nil before the first cycle.
+	Desired *api.StateResponse `json:"desired"`
+
+	// Actual is the live state of the mesh peers; ActualError is set
+	// instead if it could not be read.
+	Actual      []MeshPeer `json:"actual_peers"`
+	ActualError string     `json:"actual_error,omitempty"`
+
+	// DegradedHandlers lists the reconcile handlers that are failing.
+	DegradedHandlers []reconcile.HandlerHealth `json:"degraded_handlers"`
+
+	Goroutines int `json:"goroutines"`
+}
+
+func (h *Handler) handleGetStateSnapshot(w http.ResponseWriter, r *http.Request) {
+	snap := StateSnapshot{
+		Time:             time.Now().UTC(),
+		NodeID:           h.nodeID,
+		Actual:           []MeshPeer{},
+		DegradedHandlers: []reconcile.HandlerHealth{},
+		Goroutines:       runtime.NumGoroutine(),
+	}
+	if h.desired != nil {
+		snap.Desired = sanitizeState(h.desired.Applied())
+	}
+	if h.meshPeers != nil {
+		peers, err := h.meshPeers.MeshPeers(r.Context())
+		if err != nil {
+			snap.ActualError = err.Error()
+		} else if peers != nil {
+			snap.Actual = peers
+		}
+	}
+	if h.health != nil {
+		if degraded := h.health.DegradedHandlers(); degraded != nil {
+			snap.DegradedHandlers = degraded
+		}
+	}
+	writeJSON(w, http.StatusOK, snap)
+}
+
+// sanitizeState returns a copy of s without secrets: preshared keys and
+// ingress private keys are replaced with redactedValue and data payloads are
+// removed.
+func sanitizeState(s *api.StateResponse) *api.StateResponse {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	c.Peers = slices.Clone(s.Peers)
+	for i := range c.Peers {
+		c.Peers[i].PSK = redact(c.Peers[i].PSK)
+	}
+	if s.UserAccessConfig != nil {
+		ua := *s.UserAccessConfig
+		ua.Peers = slices.Clone(ua.Peers)
+		for i := range ua.Peers {
+			ua.Peers[i].PSK = redact(ua.Peers[i].PSK)
+		}
+		c.UserAccessConfig = &ua
+	}
+	if s.SiteToSiteConfig != nil {
+		s2s := *s.SiteToSiteConfig
+		s2s.Tunnels = slices.Clone(s2s.Tunnels)
+		for i := range s2s.Tunnels {
+			s2s.Tunnels[i].PSK = redact(s2s.Tunnels[i].PSK)
+		}
+		c.SiteToSiteConfig = &s2s
+	}
+	if s.IngressConfig != nil {
+		ing := *s.IngressConfig
+		ing.Rules = slices.Clone(ing.Rules)
+		for i := range ing.Rules {
+			ing.Rules[i].KeyPEM = redact(ing.Rules[i].KeyPEM)
+		}
+		c.IngressConfig = &ing
+	}
+	c.Data = slices.Clone(s.Data)
+	for i := range c.Data {
+		c.Data[i].Payload = nil
+	}
+	return &c
+}
+
+// redact returns redactedValue for a non-empty secret.
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redactedValue
+}
@@ -8,7 +8,6 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
-	"strings"
 )
 
 // applySocketPermissions sets socket ownership and permissions on Linux.
@@ -37,6 +36,13 @@ func connContextWithPeerCred(logger *slog.Logger) func(ctx context.Context, c ne
 // contextPeerCredGetter extracts peer credentials from the request context.
 type contextPeerCredGetter struct{}
 
+// requestPeerCredentials returns the peer credentials of a Unix socket
+// request, or nil if they are unavailable.
+func requestPeerCredentials(r *http.Request) *PeerCredentials {
+	cred, _ := contextPeerCredGetter{}.GetPeerCredentials(r)
+	return cred
+}
+
 func (contextPeerCredGetter) GetPeerCredentials(r *http.Request) (*PeerCredentials, error) {
 	cred, ok := r.Context().Value(peerCredKey{}).(*PeerCredentials)
 	if !ok || cred == nil {
@@ -44,20 +50,3 @@ func (contextPeerCredGetter) GetPeerCredentials(r *http.Request) (*PeerCredentia
 	}
 	return cred, nil
 }
-
-// wrapSecretAuth wraps a handler with SecretAuthMiddleware for secret routes
-// on the Unix socket. On Linux, SO_PEERCRED is used for authorization.
-func wrapSecretAuth(next http.Handler, logger *slog.Logger) http.Handler {
-	checker := OSGroupChecker{}
-	getter := contextPeerCredGetter{}
-	secretAuth := SecretAuthMiddleware(checker, getter, logger)
-	protected := secretAuth(next)
-
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasPrefix(r.URL.Path, "/v1/state/secrets") {
-			protected.ServeHTTP(w, r)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
-}
@@ -13,6 +13,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
 )
 
 func TestApplySocketPermissions_NoPlexdGroup(t *testing.T) {
@@ -115,13 +117,22 @@ func TestContextPeerCredGetter(t *testing.T) {
 	}
 }
 
-func TestWrapSecretAuth_ProtectsSecretRoutes(t *testing.T) {
-	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	})
+// newSecretAuthTestMux returns the Unix socket handler chain with
+// SecretAuthEnabled peer authorization.
+func newSecretAuthTestMux(t *testing.T) http.Handler {
+	t.Helper()
+	cache := NewStateCache(t.TempDir(), discardLogger())
+	if err := cache.Load(); err != nil {
+		t.Fatalf("cache.Load: %v", err)
+	}
+	cache.UpdateSecretIndex([]api.SecretRef{{Key: "key", Version: 1}})
+	h := NewHandler(cache, &mockSecretFetcher{}, "node-1", testKey(t), discardLogger())
+	h.SetPeerAuthorizer(newPeerAuthorizer(Config{SecretAuthEnabled: true}, discardLogger()), nil)
+	return markLocal(h.Mux())
+}
 
-	logger := slog.Default()
-	wrapped := wrapSecretAuth(inner, logger)
+func TestSecretAuth_ProtectsSecretRoutes(t *testing.T) {
+	wrapped := newSecretAuthTestMux(t)
 
 	// Non-secret route should pass through.
 	req := httptest.NewRequest(http.MethodGet, "/v1/state", nil)
@@ -132,7 +143,7 @@ func TestWrapSecretAuth_ProtectsSecretRoutes(t *testing.T) {
 	}
 
 	// Secret route without peer creds should be forbidden.
-	req = httptest.NewRequest(http.MethodGet, "/v1/state/secrets/key", nil)
+	req = httptest.NewRequest(http.MethodGet, "/v1/state/secrets", nil)
 	rec = httptest.NewRecorder()
 	wrapped.ServeHTTP(rec, req)
 	if rec.Code != http.StatusForbidden {
@@ -142,7 +153,7 @@ func TestWrapSecretAuth_ProtectsSecretRoutes(t *testing.T) {
 	// Secret route with root peer creds should pass.
 	cred := &PeerCredentials{PID: 1, UID: 0, GID: 0}
 	ctx := context.WithValue(req.Context(), peerCredKey{}, cred)
-	req = httptest.NewRequest(http.MethodGet, "/v1/state/secrets/key", nil).WithContext(ctx)
+	req = httptest.NewRequest(http.MethodGet, "/v1/state/secrets", nil).WithContext(ctx)
 	rec = httptest.NewRecorder()
 	wrapped.ServeHTTP(rec, req)
 	if rec.Code != http.StatusOK {
@@ -150,30 +161,22 @@ func TestWrapSecretAuth_ProtectsSecretRoutes(t *testing.T) {
 	}
 }
 
-func TestWrapSecretAuth_SecretListAlsoProtected(t *testing.T) {
-	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	})
+func TestSecretAuth_SecretValueAlsoProtected(t *testing.T) {
+	wrapped := newSecretAuthTestMux(t)
 
-	wrapped := wrapSecretAuth(inner, slog.Default())
-
-	// /v1/state/secrets (list) should also be protected.
-	req := httptest.NewRequest(http.MethodGet, "/v1/state/secrets", nil)
+	// /v1/state/secrets/{key} should also be protected.
+	req := httptest.NewRequest(http.MethodGet, "/v1/state/secrets/key", nil)
 	rec := httptest.NewRecorder()
 	wrapped.ServeHTTP(rec, req)
 	if rec.Code != http.StatusForbidden {
-		t.Errorf("secret list without creds: status = %d, want 403", rec.Code)
+		t.Errorf("secret value without creds: status = %d, want 403", rec.Code)
 	}
 }
 
-func TestWrapSecretAuth_MetadataNotProtected(t *testing.T) {
-	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	})
-
-	wrapped := wrapSecretAuth(inner, slog.Default())
+func TestSecretAuth_MetadataNotProtected(t *testing.T) {
+	wrapped := newSecretAuthTestMux(t)
 
-	paths := []string{"/v1/state", "/v1/state/metadata", "/v1/state/data/key", "/v1/state/report/key"}
+	paths := []string{"/v1/state", "/v1/state/metadata", "/v1/state/data", "/v1/state/report"}
 	for _, path := range paths {
 		req := httptest.NewRequest(http.MethodGet, path, nil)
 		rec := httptest.NewRecorder()
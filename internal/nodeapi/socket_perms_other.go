@@ -17,7 +17,7 @@ func connContextWithPeerCred(_ *slog.Logger) func(ctx context.Context, c net.Con
 	return nil
 }
 
-// wrapSecretAuth is a no-op on non-Linux platforms (no peer credential extraction).
-func wrapSecretAuth(next http.Handler, _ *slog.Logger) http.Handler {
-	return next
+// requestPeerCredentials returns nil on non-Linux platforms (no SO_PEERCRED).
+func requestPeerCredentials(_ *http.Request) *PeerCredentials {
+	return nil
 }
@@ -0,0 +1,89 @@
+package nodeapi
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+)
+
+// stateMagic prefixes state files encrypted by stateCipher. Plaintext state
+// files are JSON and never start with it.
+var stateMagic = []byte("PLXS1")
+
+// stateKeyInfo is the HKDF info string for the state cache key. It separates
+// the derived key from other uses of the node secret key.
+const stateKeyInfo = "plexd nodeapi state cache v1"
+
+// stateEncryptedMarker is the file, relative to the state directory, that
+// records that the plaintext state files were migrated. Once it exists,
+// Load no longer accepts plaintext files.
+const stateEncryptedMarker = "encrypted"
+
+// corruptStateSuffix is appended to state files that Load moves aside.
+const corruptStateSuffix = ".corrupt"
+
+// errStateDecrypt is returned when an encrypted state file cannot be
+// decrypted, e.g. because it was written with a different node secret key.
+var errStateDecrypt = errors.New("nodeapi: state decryption failed")
+
+// stateCipher encrypts state files with AES-256-GCM. Each file is stored as
+// stateMagic | nonce | ciphertext, with its path relative to the state
+// directory as additional data so files cannot be swapped.
+type stateCipher struct {
+	aead cipher.AEAD
+}
+
+// newStateCipher derives the state key from the node secret key.
+func newStateCipher(nsk []byte) (*stateCipher, error) {
+	if len(nsk) == 0 {
+		return nil, errors.New("nodeapi: state encryption: empty node secret key")
+	}
+	key, err := hkdf.Key(sha256.New, nsk, nil, stateKeyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &stateCipher{aead: aead}, nil
+}
+
+// seal encrypts plaintext for the state file at rel.
+func (c *stateCipher) seal(rel string, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(stateMagic)+len(nonce)+len(plaintext)+c.aead.Overhead())
+	out = append(out, stateMagic...)
+	out = append(out, nonce...)
+	return c.aead.Seal(out, nonce, plaintext, []byte(rel)), nil
+}
+
+// open decrypts the state file at rel. data must be encrypted.
+func (c *stateCipher) open(rel string, data []byte) ([]byte, error) {
+	data = data[len(stateMagic):]
+	if len(data) < c.aead.NonceSize() {
+		return nil, errStateDecrypt
+	}
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, []byte(rel))
+	if err != nil {
+		return nil, errStateDecrypt
+	}
+	return plaintext, nil
+}
+
+// isEncryptedState reports whether data is an encrypted state file.
+func isEncryptedState(data []byte) bool {
+	return bytes.HasPrefix(data, stateMagic)
+}
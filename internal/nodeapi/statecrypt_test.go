@@ -0,0 +1,209 @@
+package nodeapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+func newEncryptedCache(t *testing.T, dir string, nsk string) *StateCache {
+	t.Helper()
+	sc := NewStateCache(dir, discardLogger())
+	if err := sc.SetEncryptionKey([]byte(nsk)); err != nil {
+		t.Fatalf("SetEncryptionKey: %v", err)
+	}
+	if err := sc.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return sc
+}
+
+func TestStateCache_EncryptedAtRest(t *testing.T) {
+	dir := t.TempDir()
+	sc := newEncryptedCache(t, dir, "node-secret")
+
+	sc.UpdateMetadata(map[string]string{"region": "eu-west-1"})
+	sc.PutLabel("label.zone", "zone-a")
+	sc.UpdateData([]api.DataEntry{{Key: "cfg", ContentType: "application/json", Payload: json.RawMessage(`{"port":8080}`), Version: 1}})
+	sc.UpdateSecretIndex([]api.SecretRef{{Key: "db-password", Version: 1}})
+	if _, err := sc.PutReport("health", "application/json", json.RawMessage(`{"status":"healthy"}`), nil); err != nil {
+		t.Fatalf("PutReport: %v", err)
+	}
+
+	files := map[string]string{
+		"metadata.json":      "eu-west-1",
+		"labels.json":        "zone-a",
+		"data/cfg.json":      "8080",
+		"secrets.json":       "db-password",
+		"report/health.json": "healthy",
+	}
+	for rel, secret := range files {
+		raw, err := os.ReadFile(filepath.Join(dir, "state", rel))
+		if err != nil {
+			t.Fatalf("ReadFile(%s): %v", rel, err)
+		}
+		if !isEncryptedState(raw) {
+			t.Errorf("%s is not encrypted", rel)
+		}
+		if bytes.Contains(raw, []byte(secret)) {
+			t.Errorf("%s contains plaintext %q", rel, secret)
+		}
+	}
+
+	sc2 := newEncryptedCache(t, dir, "node-secret")
+	if v, _ := sc2.GetMetadataKey("region"); v != "eu-west-1" {
+		t.Errorf("region = %q, want eu-west-1", v)
+	}
+	if v, _ := sc2.GetMetadataKey("label.zone"); v != "zone-a" {
+		t.Errorf("label.zone = %q, want zone-a", v)
+	}
+	if _, ok := sc2.GetDataEntry("cfg"); !ok {
+		t.Error("data entry cfg not loaded")
+	}
+	if refs := sc2.GetSecretIndex(); len(refs) != 1 {
+		t.Errorf("secret index = %v, want 1 ref", refs)
+	}
+	if _, ok := sc2.GetReport("health"); !ok {
+		t.Error("report health not loaded")
+	}
+}
+
+func TestStateCache_MigratesPlaintext(t *testing.T) {
+	dir := t.TempDir()
+	plain := NewStateCache(dir, discardLogger())
+	if err := plain.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	plain.UpdateMetadata(map[string]string{"region": "eu-west-1"})
+	if _, err := plain.PutReport("health", "text/plain", json.RawMessage(`"ok"`), nil); err != nil {
+		t.Fatalf("PutReport: %v", err)
+	}
+
+	sc := newEncryptedCache(t, dir, "node-secret")
+	if v, _ := sc.GetMetadataKey("region"); v != "eu-west-1" {
+		t.Errorf("region = %q, want eu-west-1", v)
+	}
+	if _, ok := sc.GetReport("health"); !ok {
+		t.Error("report health not loaded")
+	}
+	for _, rel := range []string{"metadata.json", "report/health.json"} {
+		raw, err := os.ReadFile(filepath.Join(dir, "state", rel))
+		if err != nil {
+			t.Fatalf("ReadFile(%s): %v", rel, err)
+		}
+		if !isEncryptedState(raw) {
+			t.Errorf("%s was not migrated", rel)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "state", stateEncryptedMarker)); err != nil {
+		t.Errorf("migration marker: %v", err)
+	}
+}
+
+func TestStateCache_EncryptedWithoutKey(t *testing.T) {
+	dir := t.TempDir()
+	sc := newEncryptedCache(t, dir, "node-secret")
+	sc.UpdateMetadata(map[string]string{"region": "eu-west-1"})
+
+	plain := NewStateCache(dir, discardLogger())
+	if err := plain.Load(); err == nil {
+		t.Fatal("Load without key succeeded on encrypted state")
+	}
+}
+
+func TestStateCache_PlaintextAfterMigrationMovedAside(t *testing.T) {
+	dir := t.TempDir()
+	sc := newEncryptedCache(t, dir, "node-secret")
+	if _, err := os.Stat(filepath.Join(dir, "state", stateEncryptedMarker)); err != nil {
+		t.Fatalf("migration marker: %v", err)
+	}
+	sc.UpdateMetadata(map[string]string{"region": "eu-west-1"})
+
+	// Replace metadata.json with a plaintext file.
+	path := filepath.Join(dir, "state", "metadata.json")
+	if err := os.WriteFile(path, []byte(`{"region":"attacker"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sc2 := newEncryptedCache(t, dir, "node-secret")
+	if len(sc2.GetMetadata()) != 0 {
+		t.Errorf("metadata = %v, want empty", sc2.GetMetadata())
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("plaintext metadata.json still in place: %v", err)
+	}
+	if _, err := os.Stat(path + corruptStateSuffix); err != nil {
+		t.Errorf("plaintext metadata.json not moved aside: %v", err)
+	}
+}
+
+func TestStateCache_WrongKeyMovesFilesAside(t *testing.T) {
+	dir := t.TempDir()
+	sc := newEncryptedCache(t, dir, "old-secret")
+	sc.UpdateMetadata(map[string]string{"region": "eu-west-1"})
+	if _, err := sc.PutReport("health", "text/plain", json.RawMessage(`"ok"`), nil); err != nil {
+		t.Fatalf("PutReport: %v", err)
+	}
+
+	sc2 := newEncryptedCache(t, dir, "new-secret")
+	if len(sc2.GetMetadata()) != 0 {
+		t.Errorf("metadata = %v, want empty", sc2.GetMetadata())
+	}
+	if len(sc2.GetReports()) != 0 {
+		t.Errorf("reports = %v, want empty", sc2.GetReports())
+	}
+	for _, rel := range []string{"metadata.json", "report/health.json"} {
+		path := filepath.Join(dir, "state", rel)
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s still in place: %v", rel, err)
+		}
+		if _, err := os.Stat(path + corruptStateSuffix); err != nil {
+			t.Errorf("%s not moved aside: %v", rel, err)
+		}
+	}
+
+	// Files rewritten with the new key are readable again.
+	sc2.UpdateMetadata(map[string]string{"region": "eu-central-1"})
+	sc3 := newEncryptedCache(t, dir, "new-secret")
+	if v, _ := sc3.GetMetadataKey("region"); v != "eu-central-1" {
+		t.Errorf("region = %q, want eu-central-1", v)
+	}
+}
+
+func TestStateCache_SwappedFileRejected(t *testing.T) {
+	dir := t.TempDir()
+	sc := newEncryptedCache(t, dir, "node-secret")
+	payload := json.RawMessage(`"x"`)
+	for _, key := range []string{"a", "b"} {
+		if _, err := sc.PutReport(key, "text/plain", payload, nil); err != nil {
+			t.Fatalf("PutReport(%s): %v", key, err)
+		}
+	}
+
+	// Replace b.json with the ciphertext of a.json.
+	reportDir := filepath.Join(dir, "state", "report")
+	raw, err := os.ReadFile(filepath.Join(reportDir, "a.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(reportDir, "b.json"), raw, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sc2 := newEncryptedCache(t, dir, "node-secret")
+	reports := sc2.GetReports()
+	if _, ok := reports["a"]; !ok || len(reports) != 1 {
+		t.Errorf("reports = %v, want only a", reports)
+	}
+}
+
+func TestStateCache_SetEncryptionKeyEmpty(t *testing.T) {
+	sc := NewStateCache(t.TempDir(), discardLogger())
+	if err := sc.SetEncryptionKey(nil); err == nil {
+		t.Fatal("SetEncryptionKey(nil) succeeded")
+	}
+}
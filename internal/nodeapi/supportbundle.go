@@ -0,0 +1,56 @@
+package nodeapi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SupportBundler writes a diagnostics bundle of the running agent: a
+// gzipped tar archive with its goroutine stacks, recent logs, reconcile
+// history, and redacted config. *diagnostics.Collector satisfies this
+// interface.
+type SupportBundler interface {
+	WriteBundle(w io.Writer, reason string) error
+}
+
+// SetSupportBundle sets the source of GET /v1/debug/support-bundle, which is
+// served on the Unix socket only. If not set, the endpoint returns 503.
+func (h *Handler) SetSupportBundle(b SupportBundler) {
+	h.supportBundle = b
+}
+
+// handleGetSupportBundle returns a diagnostics bundle as an
+// application/gzip attachment. The bundle is built in memory first so that
+// a failure can still be reported as a JSON error.
+func (h *Handler) handleGetSupportBundle(w http.ResponseWriter, r *http.Request) {
+	if h.supportBundle == nil {
+		writeError(w, http.StatusServiceUnavailable, "support bundles not available")
+		return
+	}
+
+	var attrs []any
+	if c := ClientFromContext(r.Context()); c != nil {
+		attrs = append(attrs, "client", c.Name)
+	} else if cred := requestPeerCredentials(r); cred != nil {
+		attrs = append(attrs, "uid", cred.UID, "pid", cred.PID)
+	}
+
+	var buf bytes.Buffer
+	if err := h.supportBundle.WriteBundle(&buf, "support bundle requested via node API"); err != nil {
+		h.logger.Error("writing support bundle failed", append(attrs, "error", err)...)
+		writeError(w, http.StatusInternalServerError, "failed to write support bundle")
+		return
+	}
+	h.logger.Info("support bundle written via node API", append(attrs, "size", buf.Len())...)
+
+	name := fmt.Sprintf("plexd-support-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
@@ -0,0 +1,103 @@
+package nodeapi
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type mockSupportBundler struct {
+	reason string
+	err    error
+}
+
+func (m *mockSupportBundler) WriteBundle(w io.Writer, reason string) error {
+	m.reason = reason
+	if m.err != nil {
+		return m.err
+	}
+	_, err := io.WriteString(w, "bundle")
+	return err
+}
+
+// newSupportBundleTestServer serves h with requests marked as local, as on
+// the Unix socket, unless tcp is set.
+func newSupportBundleTestServer(t *testing.T, b SupportBundler, tcp bool) *httptest.Server {
+	t.Helper()
+	cache := NewStateCache(t.TempDir(), discardLogger())
+	if err := cache.Load(); err != nil {
+		t.Fatalf("cache.Load: %v", err)
+	}
+	h := NewHandler(cache, &mockSecretFetcher{}, "node-1", testKey(t), discardLogger())
+	if b != nil {
+		h.SetSupportBundle(b)
+	}
+	var handler http.Handler = h.Mux()
+	if !tcp {
+		handler = markLocal(handler)
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHandler_SupportBundle(t *testing.T) {
+	b := &mockSupportBundler{}
+	srv := newSupportBundleTestServer(t, b, false)
+
+	resp := mustGet(t, srv.URL+"/v1/debug/support-bundle")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/gzip" {
+		t.Errorf("Content-Type = %q, want application/gzip", ct)
+	}
+	if resp.Header.Get("Content-Disposition") == "" {
+		t.Error("Content-Disposition not set")
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "bundle" {
+		t.Errorf("body = %q, want bundle", body)
+	}
+	if b.reason == "" {
+		t.Error("bundle written without a reason")
+	}
+}
+
+func TestHandler_SupportBundle_NotOverTCP(t *testing.T) {
+	b := &mockSupportBundler{}
+	srv := newSupportBundleTestServer(t, b, true)
+
+	resp := mustGet(t, srv.URL+"/v1/debug/support-bundle")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", resp.StatusCode)
+	}
+	if b.reason != "" {
+		t.Error("bundle written for a TCP request")
+	}
+}
+
+func TestHandler_SupportBundle_Unavailable(t *testing.T) {
+	srv := newSupportBundleTestServer(t, nil, false)
+	resp := mustGet(t, srv.URL+"/v1/debug/support-bundle")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", resp.StatusCode)
+	}
+}
+
+func TestHandler_SupportBundle_Error(t *testing.T) {
+	srv := newSupportBundleTestServer(t, &mockSupportBundler{err: errors.New("disk full")}, false)
+	resp := mustGet(t, srv.URL+"/v1/debug/support-bundle")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+}
@@ -3,10 +3,14 @@ package nodeapi
 import (
 	"context"
 	"log/slog"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/metrics"
 )
 
 // ReportSyncClient is the interface for syncing reports to the control plane.
@@ -15,49 +19,109 @@ type ReportSyncClient interface {
 }
 
 // ReportSyncer buffers report changes and syncs them to the control plane
-// with debouncing to coalesce rapid updates.
+// in batches. Changes are coalesced per key and sent together once no new
+// change arrived for the debounce period, or at the latest MaxDelay after
+// the first unsynced change.
 type ReportSyncer struct {
 	client         ReportSyncClient
 	nodeID         string
 	debouncePeriod time.Duration
+	maxDelay       time.Duration
 	logger         *slog.Logger
 
-	mu       sync.Mutex
-	entries  []api.ReportEntry
-	deleted  []string
-	pending  bool
-	notifyCh chan struct{}
+	mu           sync.Mutex
+	entries      map[string]api.ReportEntry
+	deleted      map[string]struct{}
+	labels       map[string]string
+	pending      bool
+	pendingSince time.Time
+	stats        metrics.ReportSyncStats
+	notifyCh     chan struct{}
 }
 
-// NewReportSyncer creates a new ReportSyncer.
+// NewReportSyncer creates a new ReportSyncer. Without SetMaxDelay, changes
+// are flushed debouncePeriod after the first unsynced change.
 func NewReportSyncer(client ReportSyncClient, nodeID string, debouncePeriod time.Duration, logger *slog.Logger) *ReportSyncer {
 	return &ReportSyncer{
 		client:         client,
 		nodeID:         nodeID,
 		debouncePeriod: debouncePeriod,
 		logger:         logger,
+		entries:        make(map[string]api.ReportEntry),
+		deleted:        make(map[string]struct{}),
+		labels:         make(map[string]string),
 		notifyCh:       make(chan struct{}, 1),
 	}
 }
 
-// NotifyChange buffers report changes and signals the run loop.
-// Entries are appended; later entries for the same key overwrite earlier ones.
-// Deleted keys are appended.
+// SetMaxDelay extends the coalescing window: while changes keep arriving
+// within the debounce period the flush is postponed, but never beyond d
+// after the first unsynced change. A d not above the debounce period keeps
+// the fixed window. It must be called before Run.
+func (s *ReportSyncer) SetMaxDelay(d time.Duration) {
+	s.maxDelay = d
+}
+
+// NotifyChange buffers report changes and signals the run loop. A later
+// change for a key replaces an earlier one: an update after a delete
+// cancels the delete and vice versa.
 func (s *ReportSyncer) NotifyChange(entries []api.ReportEntry, deleted []string) {
 	s.mu.Lock()
-	s.entries = append(s.entries, entries...)
-	s.deleted = append(s.deleted, deleted...)
-	s.pending = true
+	for _, e := range entries {
+		s.entries[e.Key] = e
+		delete(s.deleted, e.Key)
+	}
+	for _, key := range deleted {
+		s.deleted[key] = struct{}{}
+		delete(s.entries, key)
+	}
+	s.markPending()
+	s.mu.Unlock()
+
+	s.signal()
+}
+
+// NotifyLabel buffers a locally written metadata key and signals the run
+// loop. A later value for the same key overwrites an earlier one.
+func (s *ReportSyncer) NotifyLabel(key, value string) {
+	s.mu.Lock()
+	s.labels[key] = value
+	s.markPending()
 	s.mu.Unlock()
 
-	// Non-blocking send to signal the run loop.
+	s.signal()
+}
+
+// markPending records that changes are buffered. The caller holds s.mu.
+func (s *ReportSyncer) markPending() {
+	if !s.pending {
+		s.pending = true
+		s.pendingSince = time.Now()
+	}
+}
+
+// signal wakes the run loop without blocking.
+func (s *ReportSyncer) signal() {
 	select {
 	case s.notifyCh <- struct{}{}:
 	default:
 	}
 }
 
-// Run loops, waiting for change notifications, debouncing, and flushing.
+// Stats returns the sync counters and the current lag: the age of the
+// oldest change not yet synced, or zero if everything is synced.
+func (s *ReportSyncer) Stats() metrics.ReportSyncStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stats
+	st.PendingChanges = len(s.entries) + len(s.deleted) + len(s.labels)
+	if s.pending {
+		st.LagSeconds = time.Since(s.pendingSince).Seconds()
+	}
+	return st
+}
+
+// Run loops, waiting for change notifications, coalescing, and flushing.
 // It returns ctx.Err() when the context is cancelled.
 func (s *ReportSyncer) Run(ctx context.Context) error {
 	for {
@@ -65,36 +129,66 @@ func (s *ReportSyncer) Run(ctx context.Context) error {
 		case <-ctx.Done():
 			return ctx.Err()
 		case <-s.notifyCh:
-			// Debounce: wait for the debounce period, collecting more changes.
-			timer := time.NewTimer(s.debouncePeriod)
-			select {
-			case <-ctx.Done():
-				timer.Stop()
-				return ctx.Err()
-			case <-timer.C:
+			if err := s.wait(ctx); err != nil {
+				return err
 			}
-			// Flush
 			s.flush(ctx)
 		}
 	}
 }
 
+// wait blocks for the coalescing window that starts with a notification.
+func (s *ReportSyncer) wait(ctx context.Context) error {
+	timer := time.NewTimer(s.debouncePeriod)
+	defer timer.Stop()
+	if s.maxDelay <= s.debouncePeriod {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return nil
+		}
+	}
+
+	deadline := time.NewTimer(s.maxDelay)
+	defer deadline.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return nil
+		case <-deadline.C:
+			return nil
+		case <-s.notifyCh:
+			// A new change restarts the quiet period.
+			timer.Reset(s.debouncePeriod)
+		}
+	}
+}
+
 func (s *ReportSyncer) flush(ctx context.Context) {
 	s.mu.Lock()
-	entries := s.entries
-	deleted := s.deleted
-	s.entries = nil
-	s.deleted = nil
+	entries, deleted, labels := s.entries, s.deleted, s.labels
+	since := s.pendingSince
+	s.entries = make(map[string]api.ReportEntry)
+	s.deleted = make(map[string]struct{})
+	s.labels = make(map[string]string)
 	s.pending = false
 	s.mu.Unlock()
 
-	if len(entries) == 0 && len(deleted) == 0 {
+	if len(entries) == 0 && len(deleted) == 0 && len(labels) == 0 {
 		return
 	}
 
 	req := api.ReportSyncRequest{
-		Entries: entries,
-		Deleted: deleted,
+		Entries: slices.SortedFunc(maps.Values(entries), func(a, b api.ReportEntry) int {
+			return strings.Compare(a.Key, b.Key)
+		}),
+		Deleted: slices.Sorted(maps.Keys(deleted)),
+	}
+	if len(labels) > 0 {
+		req.Labels = labels
 	}
 
 	if err := s.client.SyncReports(ctx, s.nodeID, req); err != nil {
@@ -106,24 +200,60 @@ func (s *ReportSyncer) flush(ctx context.Context) {
 			"error", err,
 			"entries_count", len(entries),
 			"deleted_count", len(deleted),
+			"labels_count", len(labels),
 		)
-		// Re-buffer on failure.
-		s.mu.Lock()
-		s.entries = append(entries, s.entries...)
-		s.deleted = append(deleted, s.deleted...)
-		s.pending = true
-		s.mu.Unlock()
+		s.rebuffer(entries, deleted, labels, since)
 		// Signal to retry.
-		select {
-		case s.notifyCh <- struct{}{}:
-		default:
-		}
+		s.signal()
 		return
 	}
 
+	s.mu.Lock()
+	s.stats.Syncs++
+	s.stats.ConsecutiveFailures = 0
+	s.stats.LastSuccess = time.Now()
+	s.stats.LastBatchSize = len(entries) + len(deleted) + len(labels)
+	s.mu.Unlock()
+
 	s.logger.Info("report sync completed",
 		"component", "nodeapi",
 		"entries_count", len(entries),
 		"deleted_count", len(deleted),
+		"labels_count", len(labels),
+		"lag", time.Since(since).Round(time.Millisecond),
 	)
 }
+
+// rebuffer merges the changes of a failed flush back into the buffer.
+// Changes made since the flush are newer and win.
+func (s *ReportSyncer) rebuffer(entries map[string]api.ReportEntry, deleted map[string]struct{}, labels map[string]string, since time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Failures++
+	s.stats.ConsecutiveFailures++
+	for k, e := range entries {
+		if !s.changed(k) {
+			s.entries[k] = e
+		}
+	}
+	for k := range deleted {
+		if !s.changed(k) {
+			s.deleted[k] = struct{}{}
+		}
+	}
+	for k, v := range labels {
+		if _, ok := s.labels[k]; !ok {
+			s.labels[k] = v
+		}
+	}
+	s.pending = true
+	s.pendingSince = since
+}
+
+// changed reports whether key has a buffered update or delete. The caller
+// holds s.mu.
+func (s *ReportSyncer) changed(key string) bool {
+	_, updated := s.entries[key]
+	_, removed := s.deleted[key]
+	return updated || removed
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"sync"
 	"testing"
@@ -177,6 +178,42 @@ func TestReportSync_RetryOnFailure(t *testing.T) {
 	<-done
 }
 
+func TestReportSync_LabelsSyncedAndRetried(t *testing.T) {
+	mock := &mockSyncClient{err: errSyncFailed}
+	syncer := NewReportSyncer(mock, "node-1", 20*time.Millisecond, slog.Default())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- syncer.Run(ctx) }()
+
+	syncer.NotifyLabel("label.pool", "cpu")
+	syncer.NotifyLabel("label.pool", "gpu")
+	syncer.NotifyLabel("label.zone", "a")
+
+	// Wait for the failed attempt, then set a newer value before the retry.
+	time.Sleep(60 * time.Millisecond)
+	syncer.NotifyLabel("label.zone", "b")
+	mock.setErr(nil)
+	time.Sleep(100 * time.Millisecond)
+
+	calls := mock.getCalls()
+	if len(calls) < 2 {
+		t.Fatalf("SyncReports called %d times, want >= 2", len(calls))
+	}
+	if got := calls[0].Labels; len(got) != 2 || got["label.pool"] != "gpu" {
+		t.Errorf("first call labels = %v, want label.pool=gpu and label.zone", got)
+	}
+	last := calls[len(calls)-1].Labels
+	if last["label.pool"] != "gpu" || last["label.zone"] != "b" {
+		t.Errorf("retry labels = %v, want label.pool=gpu label.zone=b", last)
+	}
+
+	cancel()
+	<-done
+}
+
 func TestReportSync_ContextCancellation(t *testing.T) {
 	mock := &mockSyncClient{}
 	syncer := NewReportSyncer(mock, "node-1", 20*time.Millisecond, slog.Default())
@@ -218,3 +255,144 @@ func TestReportSync_NoGoroutineLeaks(t *testing.T) {
 	cancel()
 	<-done
 }
+
+func TestReportSync_ChangesCoalescedPerKey(t *testing.T) {
+	mock := &mockSyncClient{}
+	syncer := NewReportSyncer(mock, "node-1", 30*time.Millisecond, slog.Default())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- syncer.Run(ctx) }()
+
+	v2 := testEntry("key-1")
+	v2.Version = 2
+	syncer.NotifyChange([]api.ReportEntry{testEntry("key-1"), testEntry("key-2")}, nil)
+	syncer.NotifyChange([]api.ReportEntry{v2}, []string{"key-2"})
+	syncer.NotifyChange(nil, []string{"key-3"})
+	syncer.NotifyChange([]api.ReportEntry{testEntry("key-3")}, nil)
+
+	time.Sleep(120 * time.Millisecond)
+
+	calls := mock.getCalls()
+	if len(calls) != 1 {
+		t.Fatalf("SyncReports called %d times, want 1", len(calls))
+	}
+	entries := calls[0].Entries
+	if len(entries) != 2 || entries[0].Key != "key-1" || entries[0].Version != 2 || entries[1].Key != "key-3" {
+		t.Errorf("entries = %+v, want key-1 v2 and key-3", entries)
+	}
+	if len(calls[0].Deleted) != 1 || calls[0].Deleted[0] != "key-2" {
+		t.Errorf("deleted = %v, want [key-2]", calls[0].Deleted)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestReportSync_MaxDelayExtendsWindow(t *testing.T) {
+	mock := &mockSyncClient{}
+	syncer := NewReportSyncer(mock, "node-1", 40*time.Millisecond, slog.Default())
+	syncer.SetMaxDelay(time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- syncer.Run(ctx) }()
+
+	// Changes 20ms apart keep restarting the quiet period.
+	for i := 0; i < 5; i++ {
+		syncer.NotifyChange([]api.ReportEntry{testEntry(fmt.Sprintf("key-%d", i))}, nil)
+		time.Sleep(20 * time.Millisecond)
+	}
+	if calls := mock.getCalls(); len(calls) != 0 {
+		t.Fatalf("SyncReports called %d times during burst, want 0", len(calls))
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	calls := mock.getCalls()
+	if len(calls) != 1 {
+		t.Fatalf("SyncReports called %d times, want 1", len(calls))
+	}
+	if len(calls[0].Entries) != 5 {
+		t.Errorf("entries count = %d, want 5", len(calls[0].Entries))
+	}
+
+	cancel()
+	<-done
+}
+
+func TestReportSync_MaxDelayBoundsWindow(t *testing.T) {
+	mock := &mockSyncClient{}
+	syncer := NewReportSyncer(mock, "node-1", 40*time.Millisecond, slog.Default())
+	syncer.SetMaxDelay(100 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- syncer.Run(ctx) }()
+
+	// A steady stream of changes is flushed once MaxDelay has passed.
+	stop := time.After(250 * time.Millisecond)
+loop:
+	for i := 0; ; i++ {
+		select {
+		case <-stop:
+			break loop
+		case <-time.After(10 * time.Millisecond):
+			syncer.NotifyChange([]api.ReportEntry{testEntry(fmt.Sprintf("key-%d", i))}, nil)
+		}
+	}
+	if calls := mock.getCalls(); len(calls) == 0 {
+		t.Fatal("SyncReports not called while changes kept arriving")
+	}
+
+	cancel()
+	<-done
+}
+
+func TestReportSync_Stats(t *testing.T) {
+	mock := &mockSyncClient{err: errSyncFailed}
+	syncer := NewReportSyncer(mock, "node-1", 20*time.Millisecond, slog.Default())
+
+	if st := syncer.Stats(); st.PendingChanges != 0 || st.LagSeconds != 0 {
+		t.Errorf("initial stats = %+v, want zero", st)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- syncer.Run(ctx) }()
+
+	syncer.NotifyChange([]api.ReportEntry{testEntry("key-1")}, []string{"key-2"})
+	syncer.NotifyLabel("label.zone", "a")
+
+	time.Sleep(60 * time.Millisecond)
+	st := syncer.Stats()
+	if st.PendingChanges != 3 {
+		t.Errorf("PendingChanges = %d, want 3", st.PendingChanges)
+	}
+	if st.Failures == 0 || st.ConsecutiveFailures == 0 {
+		t.Errorf("failures = %d/%d, want > 0", st.Failures, st.ConsecutiveFailures)
+	}
+	if st.LagSeconds < 0.05 {
+		t.Errorf("LagSeconds = %v, want >= 0.05", st.LagSeconds)
+	}
+
+	mock.setErr(nil)
+	time.Sleep(60 * time.Millisecond)
+	st = syncer.Stats()
+	if st.PendingChanges != 0 || st.LagSeconds != 0 {
+		t.Errorf("after success: pending = %d, lag = %v, want 0", st.PendingChanges, st.LagSeconds)
+	}
+	if st.Syncs != 1 || st.ConsecutiveFailures != 0 || st.LastBatchSize != 3 || st.LastSuccess.IsZero() {
+		t.Errorf("after success: stats = %+v", st)
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,35 @@
+// Package overrides applies an operator-managed break-glass file to the
+// desired state fetched from the control plane. The file can pin or
+// exclude peers, force a peer's endpoint, and add static routes through a
+// peer. Overrides take precedence over the control plane and are reported
+// back to it in heartbeats, so that a local deviation is never silent.
+package overrides
+
+import (
+	"errors"
+	"path/filepath"
+)
+
+// Config holds the configuration for local overrides.
+type Config struct {
+	// Path is the overrides file. A missing file means no overrides, so
+	// the file only needs to exist while an operator uses it.
+	// Default: /etc/plexd/overrides.yaml (macOS: /usr/local/etc/plexd,
+	// Windows: C:\ProgramData\plexd)
+	Path string
+}
+
+// ApplyDefaults sets default values for zero-valued fields.
+func (c *Config) ApplyDefaults() {
+	if c.Path == "" {
+		c.Path = DefaultPath
+	}
+}
+
+// Validate checks that required fields are set and values are acceptable.
+func (c *Config) Validate() error {
+	if !filepath.IsAbs(c.Path) {
+		return errors.New("overrides: config: Path must be absolute")
+	}
+	return nil
+}
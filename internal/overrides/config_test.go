@@ -0,0 +1,25 @@
+package overrides
+
+import "testing"
+
+func TestConfig_ApplyDefaults(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+
+	if cfg.Path != DefaultPath {
+		t.Errorf("Path = %q, want %q", cfg.Path, DefaultPath)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() with defaults = %v", err)
+	}
+
+	cfg.Path = "overrides.yaml"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() with relative path = nil, want error")
+	}
+}
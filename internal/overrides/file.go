@@ -0,0 +1,115 @@
+package overrides
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is the parsed overrides file.
+//
+//	peers:
+//	  - id: peer-a
+//	    pin: true
+//	    endpoint: 203.0.113.7:51820
+//	    routes: [192.168.50.0/24]
+//	  - id: peer-b
+//	    exclude: true
+type File struct {
+	Peers []PeerOverride `yaml:"peers"`
+}
+
+// PeerOverride overrides the control plane's configuration of one peer.
+type PeerOverride struct {
+	// ID is the peer ID the override applies to.
+	ID string `yaml:"id"`
+
+	// Pin keeps the peer with its last known configuration when the
+	// control plane no longer includes it.
+	Pin bool `yaml:"pin"`
+
+	// Exclude removes the peer from the desired state, so the node does not
+	// configure it. It cannot be combined with other fields.
+	Exclude bool `yaml:"exclude"`
+
+	// Endpoint replaces the peer's endpoint, as host:port.
+	Endpoint string `yaml:"endpoint"`
+
+	// Routes are CIDR prefixes routed through the peer. They are added to
+	// the peer's allowed IPs and routed through the mesh interface.
+	Routes []string `yaml:"routes"`
+}
+
+// Parse decodes and validates an overrides file. Unknown fields are
+// rejected, so that a misspelled override is not silently ignored. An
+// empty file has no overrides.
+func Parse(data []byte) (*File, error) {
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("overrides: parse: %w", err)
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Validate checks every override. A peer may be overridden only once, and
+// a route prefix may be routed through one peer only.
+func (f *File) Validate() error {
+	ids := make(map[string]bool, len(f.Peers))
+	routes := make(map[netip.Prefix]string)
+	for i, p := range f.Peers {
+		if p.ID == "" {
+			return fmt.Errorf("overrides: peers[%d]: id is required", i)
+		}
+		if ids[p.ID] {
+			return fmt.Errorf("overrides: peer %s: overridden more than once", p.ID)
+		}
+		ids[p.ID] = true
+
+		if p.Exclude && (p.Pin || p.Endpoint != "" || len(p.Routes) > 0) {
+			return fmt.Errorf("overrides: peer %s: exclude cannot be combined with other overrides", p.ID)
+		}
+		if !p.Exclude && !p.Pin && p.Endpoint == "" && len(p.Routes) == 0 {
+			return fmt.Errorf("overrides: peer %s: no override set", p.ID)
+		}
+		if p.Endpoint != "" {
+			if err := validateEndpoint(p.Endpoint); err != nil {
+				return fmt.Errorf("overrides: peer %s: %w", p.ID, err)
+			}
+		}
+		for _, r := range p.Routes {
+			prefix, err := netip.ParsePrefix(r)
+			if err != nil || prefix != prefix.Masked() {
+				return fmt.Errorf("overrides: peer %s: invalid route %q", p.ID, r)
+			}
+			if other, ok := routes[prefix]; ok {
+				return fmt.Errorf("overrides: peer %s: route %s is already routed through peer %s", p.ID, r, other)
+			}
+			routes[prefix] = p.ID
+		}
+	}
+	return nil
+}
+
+// validateEndpoint checks that endpoint is host:port with a port in 1-65535.
+func validateEndpoint(endpoint string) error {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil || host == "" {
+		return fmt.Errorf("invalid endpoint %q: must be host:port", endpoint)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid endpoint %q: port must be between 1 and 65535", endpoint)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package overrides
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	f, err := Parse([]byte(`
+peers:
+  - id: peer-a
+    pin: true
+    endpoint: 203.0.113.7:51820
+    routes: [192.168.50.0/24, "fd00:50::/64"]
+  - id: peer-b
+    exclude: true
+`))
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	if len(f.Peers) != 2 {
+		t.Fatalf("len(Peers) = %d, want 2", len(f.Peers))
+	}
+	a := f.Peers[0]
+	if a.ID != "peer-a" || !a.Pin || a.Endpoint != "203.0.113.7:51820" || len(a.Routes) != 2 {
+		t.Errorf("Peers[0] = %+v", a)
+	}
+	if !f.Peers[1].Exclude {
+		t.Errorf("Peers[1] = %+v, want exclude", f.Peers[1])
+	}
+}
+
+func TestParse_Empty(t *testing.T) {
+	f, err := Parse(nil)
+	if err != nil || len(f.Peers) != 0 {
+		t.Errorf("Parse(nil) = %+v, %v, want no overrides", f, err)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "unknown field", content: "peers:\n  - id: a\n    pinned: true\n", wantErr: "pinned"},
+		{name: "missing id", content: "peers:\n  - pin: true\n", wantErr: "id is required"},
+		{name: "duplicate", content: "peers:\n  - {id: a, pin: true}\n  - {id: a, endpoint: 'h:1'}\n", wantErr: "more than once"},
+		{name: "exclude combined", content: "peers:\n  - {id: a, exclude: true, pin: true}\n", wantErr: "cannot be combined"},
+		{name: "empty override", content: "peers:\n  - {id: a}\n", wantErr: "no override set"},
+		{name: "endpoint without port", content: "peers:\n  - {id: a, endpoint: 203.0.113.7}\n", wantErr: "host:port"},
+		{name: "endpoint bad port", content: "peers:\n  - {id: a, endpoint: '203.0.113.7:0'}\n", wantErr: "port must be"},
+		{name: "bad route", content: "peers:\n  - {id: a, routes: [10.0.0.1]}\n", wantErr: "invalid route"},
+		{name: "unmasked route", content: "peers:\n  - {id: a, routes: [10.0.0.1/24]}\n", wantErr: "invalid route"},
+		{name: "route twice", content: "peers:\n  - {id: a, routes: [10.1.0.0/16]}\n  - {id: b, routes: [10.1.0.0/16]}\n", wantErr: "already routed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package overrides
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/fsutil"
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+// stateFile is the name of the pinned peer state file in the state dir.
+const stateFile = "pinned.json"
+
+// RouteTable installs the kernel routes of route overrides. All methods
+// must be idempotent. cni.NewHostNetwork() satisfies this interface.
+type RouteTable interface {
+	// AddRoute routes the CIDR prefix through iface.
+	AddRoute(prefix, iface string) error
+	// RemoveRoute removes the route for the CIDR prefix through iface.
+	RemoveRoute(prefix, iface string) error
+}
+
+// Manager reads the overrides file and applies it to the desired state in
+// every reconcile cycle, so edits take effect with the next cycle. An
+// invalid file is logged and reported, and the overrides last loaded
+// successfully stay in effect. The last known configuration of pinned
+// peers is persisted in data_dir/overrides, so a pinned peer survives an
+// agent restart while the control plane omits it.
+type Manager struct {
+	cfg       Config
+	routes    RouteTable
+	meshIface string
+	stateDir  string
+	logger    *slog.Logger
+	now       func() time.Time
+
+	mu        sync.Mutex
+	file      *File
+	modTime   time.Time
+	size      int64
+	loadErr   string
+	pinned    map[string]api.Peer // peer ID → last known configuration
+	wanted    []string            // route prefixes of the last Override
+	installed []string            // route prefixes installed by syncRoutes
+	status    *api.LocalOverridesInfo
+}
+
+// NewManager creates a Manager that keeps its state in dataDir/overrides.
+// Config defaults are applied automatically.
+func NewManager(cfg Config, routes RouteTable, meshIface, dataDir string, logger *slog.Logger) *Manager {
+	cfg.ApplyDefaults()
+	return &Manager{
+		cfg:       cfg,
+		routes:    routes,
+		meshIface: meshIface,
+		stateDir:  filepath.Join(dataDir, "overrides"),
+		logger:    logger.With("component", "overrides"),
+		now:       time.Now,
+		pinned:    make(map[string]api.Peer),
+	}
+}
+
+// Load reads the persisted pinned peers and the overrides file. A missing
+// state file or overrides file is not an error. An invalid overrides file
+// is returned as an error, but the Manager stays usable and retries the
+// file in every cycle.
+func (m *Manager) Load() error {
+	data, err := os.ReadFile(filepath.Join(m.stateDir, stateFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("overrides: load pinned peers: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err == nil {
+		if err := json.Unmarshal(data, &m.pinned); err != nil {
+			return fmt.Errorf("overrides: load pinned peers: %w", err)
+		}
+	}
+	m.reload()
+	if m.loadErr != "" {
+		return errors.New(m.loadErr)
+	}
+	return nil
+}
+
+// reload re-reads the overrides file if it changed since the last read.
+// Callers must hold m.mu.
+func (m *Manager) reload() {
+	fi, err := os.Stat(m.cfg.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		if m.file != nil || m.loadErr != "" {
+			m.logger.Info("overrides file removed", "path", m.cfg.Path)
+		}
+		m.file, m.loadErr = nil, ""
+		m.modTime, m.size = time.Time{}, 0
+		return
+	}
+	if err == nil && fi.ModTime().Equal(m.modTime) && fi.Size() == m.size {
+		return
+	}
+
+	var f *File
+	if err == nil {
+		var data []byte
+		if data, err = os.ReadFile(m.cfg.Path); err == nil {
+			f, err = Parse(data)
+		}
+	}
+	if err != nil {
+		msg := err.Error()
+		if msg != m.loadErr {
+			m.logger.Error("overrides file invalid; keeping previous overrides", "path", m.cfg.Path, "error", err)
+		}
+		m.loadErr = msg
+		return
+	}
+	m.file, m.loadErr = f, ""
+	m.modTime, m.size = fi.ModTime(), fi.Size()
+	m.logger.Info("overrides file loaded", "path", m.cfg.Path, "peers", len(f.Peers))
+}
+
+// Override applies the overrides to desired. It satisfies
+// reconcile.StateOverride. In order of precedence:
+//
+//  1. exclude removes the peer;
+//  2. pin keeps the peer with its last known configuration when desired
+//     does not include it;
+//  3. endpoint replaces the endpoint of the remaining peer;
+//  4. routes are added to the allowed IPs of the remaining peer.
+//
+// Overrides of peers that are neither in desired nor pinned with a known
+// configuration are reported as unmatched.
+func (m *Manager) Override(desired *api.StateResponse) *api.StateResponse {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reload()
+
+	if m.file == nil || len(m.file.Peers) == 0 {
+		m.wanted = nil
+		m.clearPinned()
+		m.status = nil
+		if m.loadErr != "" {
+			m.status = &api.LocalOverridesInfo{Error: m.loadErr, UpdatedAt: m.now()}
+		}
+		return desired
+	}
+
+	byID := make(map[string]PeerOverride, len(m.file.Peers))
+	for _, o := range m.file.Peers {
+		byID[o.ID] = o
+	}
+	status := &api.LocalOverridesInfo{Error: m.loadErr, UpdatedAt: m.now()}
+	out := *desired
+	out.Peers = make([]api.Peer, 0, len(desired.Peers))
+	seen := make(map[string]bool, len(desired.Peers))
+	var wanted []string
+	pinnedChanged := false
+
+	apply := func(p api.Peer, o PeerOverride) {
+		if o.Endpoint != "" {
+			p.Endpoint = o.Endpoint
+			if status.Endpoints == nil {
+				status.Endpoints = make(map[string]string)
+			}
+			status.Endpoints[p.ID] = o.Endpoint
+		}
+		p.AllowedIPs = slices.Clone(p.AllowedIPs)
+		for _, r := range o.Routes {
+			if !slices.Contains(p.AllowedIPs, r) {
+				p.AllowedIPs = append(p.AllowedIPs, r)
+			}
+			wanted = append(wanted, r)
+			status.Routes = append(status.Routes, api.LocalRoute{Prefix: r, PeerID: p.ID})
+		}
+		out.Peers = append(out.Peers, p)
+	}
+
+	for _, p := range desired.Peers {
+		seen[p.ID] = true
+		o, ok := byID[p.ID]
+		switch {
+		case !ok:
+			out.Peers = append(out.Peers, p)
+		case o.Exclude:
+			status.ExcludedPeers = append(status.ExcludedPeers, p.ID)
+		default:
+			if o.Pin && !peerEqual(m.pinned[p.ID], p) {
+				m.pinned[p.ID] = clonePeer(p)
+				pinnedChanged = true
+			}
+			apply(p, o)
+		}
+	}
+	for _, o := range m.file.Peers {
+		if o.Pin {
+			status.PinnedPeers = append(status.PinnedPeers, o.ID)
+		}
+		if seen[o.ID] {
+			continue
+		}
+		if p, ok := m.pinned[o.ID]; ok && o.Pin {
+			status.HeldPeers = append(status.HeldPeers, o.ID)
+			apply(clonePeer(p), o)
+			continue
+		}
+		status.Unmatched = append(status.Unmatched, o.ID)
+	}
+	for id := range m.pinned {
+		if o, ok := byID[id]; !ok || !o.Pin {
+			delete(m.pinned, id)
+			pinnedChanged = true
+		}
+	}
+	if pinnedChanged {
+		if err := m.save(); err != nil {
+			m.logger.Warn("persist pinned peers failed", "error", err)
+		}
+	}
+
+	sort.Strings(wanted)
+	m.wanted = wanted
+	m.status = status
+	return &out
+}
+
+// clearPinned forgets all pinned peers. Callers must hold m.mu.
+func (m *Manager) clearPinned() {
+	if len(m.pinned) == 0 {
+		return
+	}
+	clear(m.pinned)
+	if err := m.save(); err != nil {
+		m.logger.Warn("persist pinned peers failed", "error", err)
+	}
+}
+
+// ReconcileHandler returns a reconcile.ReconcileHandler that installs the
+// kernel routes of the route overrides through the mesh interface and
+// removes routes of overrides that were removed. Register it after the
+// WireGuard handler, so that the peers' allowed IPs are in place first.
+func (m *Manager) ReconcileHandler() reconcile.ReconcileHandler {
+	return func(_ context.Context, _ *api.StateResponse, _ reconcile.StateDiff) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return m.syncRoutes()
+	}
+}
+
+// syncRoutes installs the wanted routes and removes installed routes that
+// are no longer wanted. Routes that fail are retried by the next cycle.
+// Callers must hold m.mu.
+func (m *Manager) syncRoutes() error {
+	var errs []error
+	var installed []string
+	for _, r := range m.installed {
+		if slices.Contains(m.wanted, r) {
+			continue
+		}
+		if err := m.routes.RemoveRoute(r, m.meshIface); err != nil {
+			errs = append(errs, fmt.Errorf("overrides: remove route %s: %w", r, err))
+			installed = append(installed, r)
+			continue
+		}
+		m.logger.Info("override route removed", "prefix", r)
+	}
+	for _, r := range m.wanted {
+		if slices.Contains(m.installed, r) {
+			installed = append(installed, r)
+			continue
+		}
+		if err := m.routes.AddRoute(r, m.meshIface); err != nil {
+			errs = append(errs, fmt.Errorf("overrides: add route %s: %w", r, err))
+			continue
+		}
+		installed = append(installed, r)
+		m.logger.Info("override route added", "prefix", r)
+	}
+	m.installed = installed
+	return errors.Join(errs...)
+}
+
+// LocalOverrides returns the overrides in effect as of the last cycle, or
+// nil if there are none. It satisfies agent.LocalOverridesSource.
+func (m *Manager) LocalOverrides() *api.LocalOverridesInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.status == nil {
+		return nil
+	}
+	s := *m.status
+	s.Endpoints = maps.Clone(s.Endpoints)
+	s.Routes = slices.Clone(s.Routes)
+	return &s
+}
+
+// save persists the pinned peers. Callers must hold m.mu.
+func (m *Manager) save() error {
+	data, err := json.MarshalIndent(m.pinned, "", "  ")
+	if err != nil {
+		return fmt.Errorf("overrides: save pinned peers: %w", err)
+	}
+	if err := os.MkdirAll(m.stateDir, 0o700); err != nil {
+		return fmt.Errorf("overrides: save pinned peers: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(m.stateDir, stateFile, data, 0o600); err != nil {
+		return fmt.Errorf("overrides: save pinned peers: %w", err)
+	}
+	return nil
+}
+
+// clonePeer returns a copy of p that shares no slices with it.
+func clonePeer(p api.Peer) api.Peer {
+	p.AllowedIPs = slices.Clone(p.AllowedIPs)
+	return p
+}
+
+// peerEqual reports whether a and b have the same configuration.
+func peerEqual(a, b api.Peer) bool {
+	return a.ID == b.ID && a.PublicKey == b.PublicKey && a.MeshIP == b.MeshIP &&
+		a.Endpoint == b.Endpoint && a.PSK == b.PSK && a.Hostname == b.Hostname &&
+		slices.Equal(a.AllowedIPs, b.AllowedIPs)
+}
@@ -0,0 +1,295 @@
+package overrides
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+type mockRoutes struct {
+	routes  []string
+	failAdd bool
+}
+
+func (r *mockRoutes) AddRoute(prefix, _ string) error {
+	if r.failAdd {
+		return errors.New("add failed")
+	}
+	r.routes = append(r.routes, prefix)
+	return nil
+}
+
+func (r *mockRoutes) RemoveRoute(prefix, _ string) error {
+	r.routes = slices.DeleteFunc(r.routes, func(p string) bool { return p == prefix })
+	return nil
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// testEnv is a Manager with its overrides file and data dir in temp dirs.
+type testEnv struct {
+	m       *Manager
+	routes  *mockRoutes
+	path    string
+	dataDir string
+	mtime   time.Time
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	dir := t.TempDir()
+	e := &testEnv{
+		routes:  &mockRoutes{},
+		path:    filepath.Join(dir, "overrides.yaml"),
+		dataDir: filepath.Join(dir, "data"),
+		mtime:   time.Now().Add(-time.Hour),
+	}
+	e.m = NewManager(Config{Path: e.path}, e.routes, "plexd0", e.dataDir, testLogger())
+	return e
+}
+
+// write replaces the overrides file with a distinct modification time, so
+// that the Manager notices the change regardless of timestamp resolution.
+func (e *testEnv) write(t *testing.T, content string) {
+	t.Helper()
+	if err := os.WriteFile(e.path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	e.mtime = e.mtime.Add(time.Second)
+	if err := os.Chtimes(e.path, e.mtime, e.mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testState() *api.StateResponse {
+	return &api.StateResponse{Peers: []api.Peer{
+		{ID: "peer-a", PublicKey: "key-a", Endpoint: "198.51.100.1:51820", AllowedIPs: []string{"10.99.0.2/32"}},
+		{ID: "peer-b", PublicKey: "key-b", AllowedIPs: []string{"10.99.0.3/32"}},
+		{ID: "peer-c", PublicKey: "key-c", AllowedIPs: []string{"10.99.0.4/32"}},
+	}}
+}
+
+func peerByID(s *api.StateResponse, id string) (api.Peer, bool) {
+	for _, p := range s.Peers {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return api.Peer{}, false
+}
+
+func TestManager_NoFile(t *testing.T) {
+	e := newTestEnv(t)
+	if err := e.m.Load(); err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	desired := testState()
+	if got := e.m.Override(desired); got != desired {
+		t.Error("Override() without a file must return desired unchanged")
+	}
+	if lo := e.m.LocalOverrides(); lo != nil {
+		t.Errorf("LocalOverrides() = %+v, want nil", lo)
+	}
+}
+
+func TestManager_Override(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, `
+peers:
+  - id: peer-a
+    endpoint: 203.0.113.7:51820
+    routes: [192.168.50.0/24]
+  - id: peer-b
+    exclude: true
+  - id: peer-x
+    pin: true
+`)
+	if err := e.m.Load(); err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+
+	desired := testState()
+	got := e.m.Override(desired)
+
+	if _, ok := peerByID(got, "peer-b"); ok {
+		t.Error("excluded peer-b is still in the state")
+	}
+	a, _ := peerByID(got, "peer-a")
+	if a.Endpoint != "203.0.113.7:51820" {
+		t.Errorf("peer-a Endpoint = %q, want forced endpoint", a.Endpoint)
+	}
+	if !slices.Equal(a.AllowedIPs, []string{"10.99.0.2/32", "192.168.50.0/24"}) {
+		t.Errorf("peer-a AllowedIPs = %v", a.AllowedIPs)
+	}
+	if _, ok := peerByID(got, "peer-c"); !ok {
+		t.Error("peer-c without override is missing")
+	}
+
+	// desired itself is unchanged.
+	if len(desired.Peers) != 3 || desired.Peers[0].Endpoint != "198.51.100.1:51820" || len(desired.Peers[0].AllowedIPs) != 1 {
+		t.Errorf("Override() modified desired: %+v", desired.Peers)
+	}
+
+	lo := e.m.LocalOverrides()
+	if lo == nil {
+		t.Fatal("LocalOverrides() = nil")
+	}
+	if !slices.Equal(lo.ExcludedPeers, []string{"peer-b"}) ||
+		lo.Endpoints["peer-a"] != "203.0.113.7:51820" ||
+		len(lo.Routes) != 1 || lo.Routes[0] != (api.LocalRoute{Prefix: "192.168.50.0/24", PeerID: "peer-a"}) ||
+		!slices.Equal(lo.PinnedPeers, []string{"peer-x"}) ||
+		!slices.Equal(lo.Unmatched, []string{"peer-x"}) {
+		t.Errorf("LocalOverrides() = %+v", lo)
+	}
+}
+
+func TestManager_PinHoldsRemovedPeer(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "peers:\n  - {id: peer-a, pin: true, routes: [192.168.50.0/24]}\n")
+	if err := e.m.Load(); err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	e.m.Override(testState())
+
+	// The control plane drops peer-a; the pin keeps it.
+	withoutA := &api.StateResponse{Peers: testState().Peers[1:]}
+	got := e.m.Override(withoutA)
+	a, ok := peerByID(got, "peer-a")
+	if !ok || a.PublicKey != "key-a" || !slices.Contains(a.AllowedIPs, "192.168.50.0/24") {
+		t.Fatalf("pinned peer-a = %+v, %v", a, ok)
+	}
+	if lo := e.m.LocalOverrides(); !slices.Equal(lo.HeldPeers, []string{"peer-a"}) {
+		t.Errorf("HeldPeers = %v, want [peer-a]", lo.HeldPeers)
+	}
+
+	// The pinned configuration survives a restart.
+	m2 := NewManager(Config{Path: e.path}, &mockRoutes{}, "plexd0", e.dataDir, testLogger())
+	if err := m2.Load(); err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if _, ok := peerByID(m2.Override(withoutA), "peer-a"); !ok {
+		t.Error("pinned peer-a lost after restart")
+	}
+
+	// Removing the pin forgets the peer.
+	e.write(t, "peers:\n  - {id: peer-c, exclude: true}\n")
+	if _, ok := peerByID(e.m.Override(withoutA), "peer-a"); ok {
+		t.Error("peer-a kept after its pin was removed")
+	}
+}
+
+func TestManager_InvalidFileKeepsPrevious(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "peers:\n  - {id: peer-b, exclude: true}\n")
+	if err := e.m.Load(); err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	e.m.Override(testState())
+
+	e.write(t, "peers:\n  - {id: peer-b, exclud: true}\n")
+	got := e.m.Override(testState())
+	if _, ok := peerByID(got, "peer-b"); ok {
+		t.Error("previous exclude of peer-b dropped after an invalid edit")
+	}
+	lo := e.m.LocalOverrides()
+	if lo == nil || lo.Error == "" {
+		t.Errorf("LocalOverrides() = %+v, want error reported", lo)
+	}
+
+	// Fixing the file clears the error.
+	e.write(t, "peers:\n  - {id: peer-c, exclude: true}\n")
+	got = e.m.Override(testState())
+	if _, ok := peerByID(got, "peer-b"); !ok {
+		t.Error("peer-b still excluded after the file was fixed")
+	}
+	if lo := e.m.LocalOverrides(); lo.Error != "" {
+		t.Errorf("Error = %q after fix, want empty", lo.Error)
+	}
+}
+
+func TestManager_InvalidFileAtLoad(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "peers: [")
+	if err := e.m.Load(); err == nil {
+		t.Fatal("Load() = nil, want error for an invalid file")
+	}
+	desired := testState()
+	if got := e.m.Override(desired); got != desired {
+		t.Error("Override() with no valid overrides must return desired unchanged")
+	}
+	if lo := e.m.LocalOverrides(); lo == nil || lo.Error == "" {
+		t.Errorf("LocalOverrides() = %+v, want error reported", lo)
+	}
+}
+
+func TestManager_FileRemoved(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "peers:\n  - {id: peer-b, exclude: true}\n")
+	if err := e.m.Load(); err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	e.m.Override(testState())
+
+	if err := os.Remove(e.path); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := peerByID(e.m.Override(testState()), "peer-b"); !ok {
+		t.Error("peer-b still excluded after the file was removed")
+	}
+	if lo := e.m.LocalOverrides(); lo != nil {
+		t.Errorf("LocalOverrides() = %+v, want nil", lo)
+	}
+}
+
+func TestManager_ReconcileHandlerRoutes(t *testing.T) {
+	e := newTestEnv(t)
+	e.write(t, "peers:\n  - {id: peer-a, routes: [192.168.50.0/24, 192.168.60.0/24]}\n")
+	if err := e.m.Load(); err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	handler := e.m.ReconcileHandler()
+	run := func() error {
+		desired := e.m.Override(testState())
+		return handler(context.Background(), desired, reconcile.StateDiff{})
+	}
+
+	if err := run(); err != nil {
+		t.Fatalf("handler = %v", err)
+	}
+	if !slices.Equal(e.routes.routes, []string{"192.168.50.0/24", "192.168.60.0/24"}) {
+		t.Errorf("routes = %v", e.routes.routes)
+	}
+
+	e.write(t, "peers:\n  - {id: peer-a, routes: [192.168.60.0/24]}\n")
+	if err := run(); err != nil {
+		t.Fatalf("handler = %v", err)
+	}
+	if !slices.Equal(e.routes.routes, []string{"192.168.60.0/24"}) {
+		t.Errorf("routes after removing an override = %v", e.routes.routes)
+	}
+
+	// Failed routes are reported and retried.
+	e.write(t, "peers:\n  - {id: peer-a, routes: [192.168.60.0/24, 192.168.70.0/24]}\n")
+	e.routes.failAdd = true
+	if err := run(); err == nil {
+		t.Error("handler = nil, want error for a failed route")
+	}
+	e.routes.failAdd = false
+	if err := run(); err != nil {
+		t.Fatalf("handler retry = %v", err)
+	}
+	if !slices.Equal(e.routes.routes, []string{"192.168.60.0/24", "192.168.70.0/24"}) {
+		t.Errorf("routes after retry = %v", e.routes.routes)
+	}
+}
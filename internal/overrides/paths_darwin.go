@@ -0,0 +1,6 @@
+//go:build darwin
+
+package overrides
+
+// DefaultPath is the default overrides file.
+const DefaultPath = "/usr/local/etc/plexd/overrides.yaml"
@@ -0,0 +1,6 @@
+//go:build !windows && !darwin
+
+package overrides
+
+// DefaultPath is the default overrides file.
+const DefaultPath = "/etc/plexd/overrides.yaml"
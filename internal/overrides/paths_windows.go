@@ -0,0 +1,6 @@
+//go:build windows
+
+package overrides
+
+// DefaultPath is the default overrides file.
+const DefaultPath = `C:\ProgramData\plexd\overrides.yaml`
@@ -0,0 +1,123 @@
+package packaging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/plexsphere/plexd/internal/privhelper"
+)
+
+// apparmorModule implements SecurityModule for AppArmor with
+// apparmor_parser.
+type apparmorModule struct {
+	run commandRunner
+}
+
+// NewAppArmorModule returns a SecurityModule that installs an enforcing
+// AppArmor profile for the plexd binary.
+func NewAppArmorModule() SecurityModule {
+	return &apparmorModule{run: runCommand}
+}
+
+func (m *apparmorModule) Name() string { return SecurityModuleAppArmor }
+
+// IsEnabled returns true if the AppArmor LSM is enabled and
+// apparmor_parser is installed.
+func (m *apparmorModule) IsEnabled() bool {
+	data, err := os.ReadFile("/sys/module/apparmor/parameters/enabled")
+	return err == nil && strings.TrimSpace(string(data)) == "Y" && commandExists("apparmor_parser")
+}
+
+// PolicyFile returns the profile, named after the binary path as AppArmor
+// conventions expect (/usr/local/bin/plexd becomes usr.local.bin.plexd).
+func (m *apparmorModule) PolicyFile(cfg InstallConfig) ServiceFile {
+	cfg.ApplyDefaults()
+	name := strings.ReplaceAll(strings.TrimPrefix(filepath.ToSlash(cfg.BinaryPath), "/"), "/", ".")
+	return ServiceFile{
+		Path:    filepath.Join(cfg.AppArmorProfileDir, name),
+		Content: GenerateAppArmorProfile(cfg),
+		Mode:    0o644,
+	}
+}
+
+// Load loads or replaces the profile and caches the compiled policy, so it
+// is applied again at boot.
+func (m *apparmorModule) Load(cfg InstallConfig) error {
+	return m.run("apparmor_parser", "-r", "-W", m.PolicyFile(cfg).Path)
+}
+
+// Unload removes the profile from the kernel.
+func (m *apparmorModule) Unload(cfg InstallConfig) error {
+	return m.run("apparmor_parser", "-R", m.PolicyFile(cfg).Path)
+}
+
+// GenerateAppArmorProfile produces an AppArmor profile for plexd. The agent
+// may administer network interfaces, netfilter, and WireGuard, and manage
+// the plexd directories. Hook scripts and the binaries they call inherit
+// the profile.
+// It calls cfg.ApplyDefaults() to fill in zero-valued fields before generating the output.
+func GenerateAppArmorProfile(cfg InstallConfig) string {
+	cfg.ApplyDefaults()
+
+	return fmt.Sprintf(`# Generated by plexd install.
+abi <abi/3.0>,
+
+include <tunables/global>
+
+profile plexd %[1]s flags=(attach_disconnected) {
+  include <abstractions/base>
+  include <abstractions/nameservice>
+  include <abstractions/ssl_certs>
+
+  capability net_admin,
+  capability net_raw,
+  capability net_bind_service,
+  capability setuid,
+  capability setgid,
+  capability chown,
+  capability fowner,
+  capability kill,
+  capability sys_module,
+
+  network inet,
+  network inet6,
+  network netlink raw,
+  network unix,
+
+  signal (send) peer=plexd,
+  signal (receive) peer=unconfined,
+
+  %[1]s mr,
+
+  @{PROC}/** r,
+  @{PROC}/sys/net/** rw,
+  @{sys}/** r,
+  /dev/net/tun rw,
+
+  %[2]s/ r,
+  %[2]s/** rwk,
+  %[3]s/** rix,
+  /{usr/,}{s,}bin/* rix,
+
+  %[4]s/ rw,
+  %[4]s/** rwk,
+  %[5]s/ rw,
+  %[5]s/** rwk,
+  %[6]s rw,
+
+  include if exists <local/plexd>
+}
+`, cfg.BinaryPath, cfg.ConfigDir, filepath.Join(cfg.ConfigDir, "hooks"), cfg.DataDir, apparmorRunPath(cfg.RunDir), privhelper.DefaultSocketPath)
+}
+
+// apparmorRunPath rewrites a path under /var/run to @{run}. AppArmor
+// matches resolved paths, and /var/run is a symlink to /run on most
+// distributions.
+func apparmorRunPath(path string) string {
+	if rest, ok := strings.CutPrefix(path, "/var/run/"); ok {
+		return "@{run}/" + rest
+	}
+	return path
+}
@@ -0,0 +1,199 @@
+package packaging
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// Bundle file names.
+const (
+	// BundleChecksumsFile lists the SHA-256 checksum of every other file
+	// in the bundle, in sha256sum format.
+	BundleChecksumsFile = "SHA256SUMS"
+
+	// BundleSignatureFile holds the Ed25519 signature of
+	// BundleChecksumsFile, raw or base64-encoded.
+	BundleSignatureFile = "SHA256SUMS.sig"
+
+	// bundleUnitDir holds optional service file templates.
+	bundleUnitDir = "units/"
+)
+
+// maxBundleFileSize bounds each file read from a bundle.
+const maxBundleFileSize = 512 << 20
+
+// ErrBundleInvalid is returned when a bundle is malformed, its signature
+// does not verify, or a file does not match its checksum.
+var ErrBundleInvalid = errors.New("packaging: bundle invalid")
+
+// Bundle is an offline install bundle whose signature and checksums have
+// been verified. It holds the plexd binaries for one or more platforms,
+// named plexd-<os>-<arch> (with .exe on Windows), and optional service file
+// templates under units/.
+type Bundle struct {
+	files map[string][]byte
+}
+
+// OpenBundle reads the tar archive at path and verifies it against
+// publicKey: the signature of SHA256SUMS must verify, every other file must
+// be listed in it, and every listed file must be present with a matching
+// checksum. OpenBundle performs no network access.
+func OpenBundle(path string, publicKey ed25519.PublicKey) (*Bundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("packaging: open bundle: %w", err)
+	}
+	defer f.Close()
+	return readBundle(f, publicKey)
+}
+
+// readBundle reads and verifies a bundle from r.
+func readBundle(r io.Reader, publicKey ed25519.PublicKey) (*Bundle, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("packaging: bundle public key must be %d bytes, got %d", ed25519.PublicKeySize, len(publicKey))
+	}
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: read archive: %v", ErrBundleInvalid, err)
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("%w: %s is not a regular file", ErrBundleInvalid, hdr.Name)
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if name == ".." || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			return nil, fmt.Errorf("%w: unsafe path %q", ErrBundleInvalid, hdr.Name)
+		}
+		if hdr.Size > maxBundleFileSize {
+			return nil, fmt.Errorf("%w: %s exceeds %d bytes", ErrBundleInvalid, name, maxBundleFileSize)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, maxBundleFileSize))
+		if err != nil {
+			return nil, fmt.Errorf("%w: read %s: %v", ErrBundleInvalid, name, err)
+		}
+		files[name] = data
+	}
+
+	sums, ok := files[BundleChecksumsFile]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s missing", ErrBundleInvalid, BundleChecksumsFile)
+	}
+	sig, ok := files[BundleSignatureFile]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s missing", ErrBundleInvalid, BundleSignatureFile)
+	}
+	if len(sig) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+		if err != nil {
+			return nil, fmt.Errorf("%w: decode signature: %v", ErrBundleInvalid, err)
+		}
+		sig = decoded
+	}
+	if !ed25519.Verify(publicKey, sums, sig) {
+		return nil, fmt.Errorf("%w: signature does not verify", ErrBundleInvalid)
+	}
+
+	listed, err := parseChecksums(sums)
+	if err != nil {
+		return nil, err
+	}
+	for name, want := range listed {
+		data, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s listed in %s but missing", ErrBundleInvalid, name, BundleChecksumsFile)
+		}
+		got := sha256.Sum256(data)
+		if hex.EncodeToString(got[:]) != want {
+			return nil, fmt.Errorf("%w: checksum mismatch for %s", ErrBundleInvalid, name)
+		}
+	}
+	for name := range files {
+		if _, ok := listed[name]; !ok && name != BundleChecksumsFile && name != BundleSignatureFile {
+			return nil, fmt.Errorf("%w: %s not listed in %s", ErrBundleInvalid, name, BundleChecksumsFile)
+		}
+	}
+	delete(files, BundleChecksumsFile)
+	delete(files, BundleSignatureFile)
+	return &Bundle{files: files}, nil
+}
+
+// parseChecksums parses sha256sum output: "<hex>  <name>" per line, with an
+// optional "*" before binary-mode names.
+func parseChecksums(data []byte) (map[string]string, error) {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, " ")
+		name = strings.TrimPrefix(strings.TrimSpace(name), "*")
+		if !ok || len(sum) != sha256.Size*2 || name == "" {
+			return nil, fmt.Errorf("%w: malformed %s line %q", ErrBundleInvalid, BundleChecksumsFile, line)
+		}
+		sums[path.Clean(strings.TrimPrefix(name, "./"))] = strings.ToLower(sum)
+	}
+	return sums, nil
+}
+
+// BinaryName returns the bundle file name of the plexd binary for a
+// platform, as published with each release.
+func BinaryName(goos, goarch string) string {
+	name := "plexd-" + goos + "-" + goarch
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Binary returns the plexd binary for a platform.
+func (b *Bundle) Binary(goos, goarch string) ([]byte, error) {
+	data, ok := b.files[BinaryName(goos, goarch)]
+	if !ok {
+		return nil, fmt.Errorf("packaging: bundle has no binary for %s/%s", goos, goarch)
+	}
+	return data, nil
+}
+
+// renderServiceFile replaces the content of svc with the bundle template
+// named after its file name, if the bundle has one. Templates use
+// text/template with the InstallConfig as data, e.g. {{.BinaryPath}}.
+func (b *Bundle) renderServiceFile(svc ServiceFile, cfg InstallConfig) (ServiceFile, bool, error) {
+	name := bundleUnitDir + path.Base(strings.ReplaceAll(svc.Path, `\`, "/"))
+	text, ok := b.files[name]
+	if !ok {
+		return svc, false, nil
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return svc, false, fmt.Errorf("packaging: bundle template %s: %w", name, err)
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, cfg); err != nil {
+		return svc, false, fmt.Errorf("packaging: bundle template %s: %w", name, err)
+	}
+	svc.Content = buf.String()
+	return svc, true, nil
+}
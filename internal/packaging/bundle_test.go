@@ -0,0 +1,135 @@
+package packaging
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// buildBundle returns a tar archive of files with a SHA256SUMS listing
+// those in listed, signed with priv.
+func buildBundle(t *testing.T, priv ed25519.PrivateKey, files map[string]string, listed []string) []byte {
+	t.Helper()
+	var sums strings.Builder
+	for _, name := range listed {
+		sum := sha256.Sum256([]byte(files[name]))
+		fmt.Fprintf(&sums, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+	}
+	all := map[string]string{
+		BundleChecksumsFile: sums.String(),
+		BundleSignatureFile: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(sums.String()))),
+	}
+	for name, content := range files {
+		all[name] = content
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range all {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadBundle_Verification(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	bin := BinaryName("linux", "amd64")
+	files := map[string]string{bin: "binary", "units/plexd.service": "unit"}
+
+	tests := []struct {
+		name    string
+		archive []byte
+		key     ed25519.PublicKey
+		wantErr string
+	}{
+		{"valid", buildBundle(t, priv, files, []string{bin, "units/plexd.service"}), pub, ""},
+		{"wrong key", buildBundle(t, priv, files, []string{bin, "units/plexd.service"}), otherPub, "signature does not verify"},
+		{"unlisted file", buildBundle(t, priv, files, []string{bin}), pub, "units/plexd.service not listed"},
+		{"listed but missing", buildBundle(t, priv, map[string]string{bin: "binary"}, []string{bin, "units/plexd.service"}), pub, "listed in SHA256SUMS but missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := readBundle(bytes.NewReader(tt.archive), tt.key)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("readBundle() = %v", err)
+				}
+				if data, err := b.Binary("linux", "amd64"); err != nil || string(data) != "binary" {
+					t.Errorf("Binary() = %q, %v", data, err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrBundleInvalid) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("readBundle() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadBundle_ChecksumMismatch(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	bin := BinaryName("linux", "arm64")
+	archive := buildBundle(t, priv, map[string]string{bin: "binary"}, []string{bin})
+	// Flip the last byte of the binary content inside the archive.
+	i := bytes.Index(archive, []byte("binary"))
+	archive[i+5] = 'X'
+
+	_, err := readBundle(bytes.NewReader(archive), pub)
+	if !errors.Is(err, ErrBundleInvalid) || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("readBundle() = %v, want checksum mismatch", err)
+	}
+}
+
+func TestInstall_FromBundle(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	bin := BinaryName(runtime.GOOS, runtime.GOARCH)
+	files := map[string]string{
+		bin:                   "bundled binary",
+		"units/plexd.service": "ExecStart={{.BinaryPath}} up\n",
+	}
+	path := filepath.Join(t.TempDir(), "plexd-bundle.tar")
+	if err := os.WriteFile(path, buildBundle(t, priv, files, []string{bin, "units/plexd.service"}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := OpenBundle(path, pub)
+	if err != nil {
+		t.Fatalf("OpenBundle() = %v", err)
+	}
+
+	ins, tmpDir := newTestInstaller(t, InstallConfig{}, &mockSystemdController{available: true}, &mockRootChecker{isRoot: true})
+	ins.SetBundle(bundle)
+	if err := ins.Install(); err != nil {
+		t.Fatalf("Install() = %v", err)
+	}
+
+	binPath := filepath.Join(tmpDir, "usr", "local", "bin", "plexd")
+	if data, err := os.ReadFile(binPath); err != nil || string(data) != "bundled binary" {
+		t.Errorf("binary = %q, %v; want bundle content", data, err)
+	}
+	unit, err := os.ReadFile(filepath.Join(tmpDir, "etc", "systemd", "system", "plexd.service"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ExecStart=" + binPath + " up\n"; string(unit) != want {
+		t.Errorf("unit = %q, want rendered template %q", unit, want)
+	}
+}
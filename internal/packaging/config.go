@@ -1,37 +1,68 @@
-// Package packaging implements systemd service packaging for bare-metal Linux servers.
+// Package packaging implements service packaging for bare-metal Linux servers
+// running systemd, OpenRC, or SysV init, for macOS hosts running launchd, and
+// for Windows hosts through the Service Control Manager.
 package packaging
 
 import (
 	"errors"
+	"fmt"
+
+	"github.com/plexsphere/plexd/internal/tokensource"
 )
 
-// InstallConfig holds the configuration for packaging and installing plexd as a systemd service.
+// InstallConfig holds the configuration for packaging and installing plexd as a system service.
 // InstallConfig is passed as a constructor argument — no file I/O in this package.
 type InstallConfig struct {
 	// BinaryPath is the path to install the plexd binary.
-	// Default: /usr/local/bin/plexd
+	// Default: /usr/local/bin/plexd (Windows: C:\Program Files\plexd\plexd.exe)
 	BinaryPath string
 
 	// ConfigDir is the configuration directory.
-	// Default: /etc/plexd
+	// Default: /etc/plexd (macOS: /usr/local/etc/plexd, Windows: C:\ProgramData\plexd)
 	ConfigDir string
 
 	// DataDir is the data directory.
-	// Default: /var/lib/plexd
+	// Default: /var/lib/plexd (macOS: /usr/local/var/lib/plexd, Windows: C:\ProgramData\plexd\data)
 	DataDir string
 
 	// RunDir is the runtime directory.
-	// Default: /var/run/plexd
+	// Default: /var/run/plexd (Windows: C:\ProgramData\plexd\run)
 	RunDir string
 
 	// UnitFilePath is the path for the systemd unit file.
 	// Default: /etc/systemd/system/plexd.service
 	UnitFilePath string
 
-	// ServiceName is the systemd service name.
+	// InitScriptDir is the directory for OpenRC and SysV init scripts.
+	// Default: /etc/init.d
+	InitScriptDir string
+
+	// LaunchDaemonDir is the directory for the launchd property list.
+	// Default: /Library/LaunchDaemons
+	LaunchDaemonDir string
+
+	// SELinuxPolicyDir is the directory for the SELinux policy module.
+	// Default: /usr/share/selinux/packages
+	SELinuxPolicyDir string
+
+	// AppArmorProfileDir is the directory for the AppArmor profile.
+	// Default: /etc/apparmor.d
+	AppArmorProfileDir string
+
+	// ServiceName is the service name used by the init system.
 	// Default: plexd
 	ServiceName string
 
+	// Rootless runs the agent as User without privileges and installs a
+	// socket-activated privileged helper for WireGuard operations.
+	// Requires systemd.
+	Rootless bool
+
+	// User is the account the agent runs as in rootless mode. Its
+	// same-named group is granted access to the helper socket.
+	// Default: plexd
+	User string
+
 	// APIBaseURL is the control plane API URL (optional).
 	APIBaseURL string
 
@@ -40,25 +71,52 @@ type InstallConfig struct {
 
 	// TokenFile is the path to the token file to copy from (optional).
 	TokenFile string
+
+	// TokenSource is a reference to the bootstrap token in a secret store,
+	// such as "vault:secret/plexd" (optional). It is written to
+	// registration.tokensource of the default config, so the agent reads
+	// the token when it registers and no token is written to disk. For a
+	// systemd credential ("systemd-creds:<id>") the unit loads the
+	// credential with LoadCredential=.
+	TokenSource string
+
+	// Hostname overrides the hostname the node registers with (optional).
+	// It is written to registration.hostname of the default config.
+	Hostname string
+
+	// Metadata are labels sent with the first registration (optional).
+	// They are written to registration.metadata of the default config.
+	Metadata map[string]string
+
+	// IngressPorts are public TCP ports this node will serve ingress rules
+	// on (optional). The preflight stage checks that they are free.
+	IngressPorts []int
 }
 
-// DefaultBinaryPath is the default path to install the plexd binary.
-const DefaultBinaryPath = "/usr/local/bin/plexd"
+// DefaultServiceName is the default service name.
+const DefaultServiceName = "plexd"
 
-// DefaultConfigDir is the default configuration directory.
-const DefaultConfigDir = "/etc/plexd"
+// DefaultUser is the default account for rootless mode.
+const DefaultUser = "plexd"
 
-// DefaultDataDir is the default data directory.
-const DefaultDataDir = "/var/lib/plexd"
+// DefaultUnitFilePath is the default path for the systemd unit file.
+const DefaultUnitFilePath = "/etc/systemd/system/plexd.service"
 
-// DefaultRunDir is the default runtime directory.
-const DefaultRunDir = "/var/run/plexd"
+// DefaultInitScriptDir is the default directory for OpenRC and SysV init scripts.
+const DefaultInitScriptDir = "/etc/init.d"
 
-// DefaultServiceName is the default systemd service name.
-const DefaultServiceName = "plexd"
+// DefaultLaunchDaemonDir is the default directory for launchd property lists.
+const DefaultLaunchDaemonDir = "/Library/LaunchDaemons"
 
-// DefaultUnitFilePath is the default path for the systemd unit file.
-const DefaultUnitFilePath = "/etc/systemd/system/plexd.service"
+// DefaultSELinuxPolicyDir is the default directory for the SELinux policy module.
+const DefaultSELinuxPolicyDir = "/usr/share/selinux/packages"
+
+// DefaultAppArmorProfileDir is the default directory for the AppArmor profile.
+const DefaultAppArmorProfileDir = "/etc/apparmor.d"
+
+// DefaultLogDir is the directory init scripts redirect service output to.
+// systemd captures output in the journal instead.
+const DefaultLogDir = "/var/log"
 
 // ApplyDefaults sets default values for zero-valued fields.
 func (c *InstallConfig) ApplyDefaults() {
@@ -80,6 +138,21 @@ func (c *InstallConfig) ApplyDefaults() {
 	if c.UnitFilePath == "" {
 		c.UnitFilePath = DefaultUnitFilePath
 	}
+	if c.InitScriptDir == "" {
+		c.InitScriptDir = DefaultInitScriptDir
+	}
+	if c.LaunchDaemonDir == "" {
+		c.LaunchDaemonDir = DefaultLaunchDaemonDir
+	}
+	if c.SELinuxPolicyDir == "" {
+		c.SELinuxPolicyDir = DefaultSELinuxPolicyDir
+	}
+	if c.AppArmorProfileDir == "" {
+		c.AppArmorProfileDir = DefaultAppArmorProfileDir
+	}
+	if c.User == "" {
+		c.User = DefaultUser
+	}
 }
 
 // Validate checks that required fields are set.
@@ -102,5 +175,35 @@ func (c *InstallConfig) Validate() error {
 	if c.UnitFilePath == "" {
 		return errors.New("packaging: config: UnitFilePath is required")
 	}
+	if c.InitScriptDir == "" {
+		return errors.New("packaging: config: InitScriptDir is required")
+	}
+	if c.LaunchDaemonDir == "" {
+		return errors.New("packaging: config: LaunchDaemonDir is required")
+	}
+	if c.User == "" {
+		return errors.New("packaging: config: User is required")
+	}
+	if c.SELinuxPolicyDir == "" {
+		return errors.New("packaging: config: SELinuxPolicyDir is required")
+	}
+	if c.AppArmorProfileDir == "" {
+		return errors.New("packaging: config: AppArmorProfileDir is required")
+	}
+	if c.TokenSource != "" {
+		if _, err := tokensource.ParseRef(c.TokenSource); err != nil {
+			return fmt.Errorf("packaging: config: TokenSource: %w", err)
+		}
+	}
+	for key := range c.Metadata {
+		if key == "" {
+			return errors.New("packaging: config: metadata keys must not be empty")
+		}
+	}
+	for _, port := range c.IngressPorts {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("packaging: config: ingress port %d out of range", port)
+		}
+	}
 	return nil
 }
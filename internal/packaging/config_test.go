@@ -1,6 +1,7 @@
 package packaging
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -26,6 +27,24 @@ func TestInstallConfig_ApplyDefaults(t *testing.T) {
 	if cfg.UnitFilePath != "/etc/systemd/system/plexd.service" {
 		t.Errorf("UnitFilePath = %q, want %q", cfg.UnitFilePath, "/etc/systemd/system/plexd.service")
 	}
+	if cfg.InitScriptDir != "/etc/init.d" {
+		t.Errorf("InitScriptDir = %q, want %q", cfg.InitScriptDir, "/etc/init.d")
+	}
+	if cfg.LaunchDaemonDir != "/Library/LaunchDaemons" {
+		t.Errorf("LaunchDaemonDir = %q, want %q", cfg.LaunchDaemonDir, "/Library/LaunchDaemons")
+	}
+	if cfg.User != "plexd" {
+		t.Errorf("User = %q, want %q", cfg.User, "plexd")
+	}
+	if cfg.SELinuxPolicyDir != "/usr/share/selinux/packages" {
+		t.Errorf("SELinuxPolicyDir = %q, want %q", cfg.SELinuxPolicyDir, "/usr/share/selinux/packages")
+	}
+	if cfg.AppArmorProfileDir != "/etc/apparmor.d" {
+		t.Errorf("AppArmorProfileDir = %q, want %q", cfg.AppArmorProfileDir, "/etc/apparmor.d")
+	}
+	if cfg.Rootless {
+		t.Error("Rootless = true, want false")
+	}
 	if cfg.APIBaseURL != "" {
 		t.Errorf("APIBaseURL = %q, want empty", cfg.APIBaseURL)
 	}
@@ -155,6 +174,76 @@ func TestInstallConfig_Validate_EmptyFields(t *testing.T) {
 			},
 			wantErr: "packaging: config: UnitFilePath is required",
 		},
+		{
+			name: "empty InitScriptDir",
+			cfg: InstallConfig{
+				BinaryPath:   "/usr/local/bin/plexd",
+				ConfigDir:    "/etc/plexd",
+				DataDir:      "/var/lib/plexd",
+				RunDir:       "/var/run/plexd",
+				ServiceName:  "plexd",
+				UnitFilePath: "/etc/systemd/system/plexd.service",
+			},
+			wantErr: "packaging: config: InitScriptDir is required",
+		},
+		{
+			name: "empty LaunchDaemonDir",
+			cfg: InstallConfig{
+				BinaryPath:    "/usr/local/bin/plexd",
+				ConfigDir:     "/etc/plexd",
+				DataDir:       "/var/lib/plexd",
+				RunDir:        "/var/run/plexd",
+				ServiceName:   "plexd",
+				UnitFilePath:  "/etc/systemd/system/plexd.service",
+				InitScriptDir: "/etc/init.d",
+			},
+			wantErr: "packaging: config: LaunchDaemonDir is required",
+		},
+		{
+			name: "empty User",
+			cfg: InstallConfig{
+				BinaryPath:      "/usr/local/bin/plexd",
+				ConfigDir:       "/etc/plexd",
+				DataDir:         "/var/lib/plexd",
+				RunDir:          "/var/run/plexd",
+				ServiceName:     "plexd",
+				UnitFilePath:    "/etc/systemd/system/plexd.service",
+				InitScriptDir:   "/etc/init.d",
+				LaunchDaemonDir: "/Library/LaunchDaemons",
+			},
+			wantErr: "packaging: config: User is required",
+		},
+		{
+			name: "empty SELinuxPolicyDir",
+			cfg: InstallConfig{
+				BinaryPath:      "/usr/local/bin/plexd",
+				ConfigDir:       "/etc/plexd",
+				DataDir:         "/var/lib/plexd",
+				RunDir:          "/var/run/plexd",
+				ServiceName:     "plexd",
+				UnitFilePath:    "/etc/systemd/system/plexd.service",
+				InitScriptDir:   "/etc/init.d",
+				LaunchDaemonDir: "/Library/LaunchDaemons",
+				User:            "plexd",
+			},
+			wantErr: "packaging: config: SELinuxPolicyDir is required",
+		},
+		{
+			name: "empty AppArmorProfileDir",
+			cfg: InstallConfig{
+				BinaryPath:       "/usr/local/bin/plexd",
+				ConfigDir:        "/etc/plexd",
+				DataDir:          "/var/lib/plexd",
+				RunDir:           "/var/run/plexd",
+				ServiceName:      "plexd",
+				UnitFilePath:     "/etc/systemd/system/plexd.service",
+				InitScriptDir:    "/etc/init.d",
+				LaunchDaemonDir:  "/Library/LaunchDaemons",
+				User:             "plexd",
+				SELinuxPolicyDir: "/usr/share/selinux/packages",
+			},
+			wantErr: "packaging: config: AppArmorProfileDir is required",
+		},
 	}
 
 	for _, tt := range tests {
@@ -169,3 +258,35 @@ func TestInstallConfig_Validate_EmptyFields(t *testing.T) {
 		})
 	}
 }
+
+func TestInstallConfig_Validate_EmptyMetadataKey(t *testing.T) {
+	cfg := InstallConfig{Metadata: map[string]string{"": "x"}}
+	cfg.ApplyDefaults()
+	err := cfg.Validate()
+	if err == nil || err.Error() != "packaging: config: metadata keys must not be empty" {
+		t.Errorf("Validate() = %v, want empty metadata key error", err)
+	}
+}
+
+func TestInstallConfig_Validate_TokenSource(t *testing.T) {
+	cfg := InstallConfig{TokenSource: "systemd-creds:plexd-token"}
+	cfg.ApplyDefaults()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+
+	cfg.TokenSource = "secret/plexd"
+	err := cfg.Validate()
+	if err == nil || !strings.HasPrefix(err.Error(), "packaging: config: TokenSource: ") {
+		t.Errorf("Validate() = %v, want TokenSource error", err)
+	}
+}
+
+func TestInstallConfig_Validate_IngressPortRange(t *testing.T) {
+	cfg := InstallConfig{IngressPorts: []int{443, 70000}}
+	cfg.ApplyDefaults()
+	err := cfg.Validate()
+	if err == nil || err.Error() != "packaging: config: ingress port 70000 out of range" {
+		t.Errorf("Validate() = %v, want ingress port range error", err)
+	}
+}
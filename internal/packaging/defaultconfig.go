@@ -1,21 +1,56 @@
 package packaging
 
-import "fmt"
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultConfigVersion is the config_version of the generated file. It must
+// match agent.CurrentConfigVersion so that fresh installs need no migration.
+const defaultConfigVersion = 1
 
 // GenerateDefaultConfig produces a minimal default config.yaml for plexd.
-// If apiBaseURL is empty, a placeholder comment is written instead.
-func GenerateDefaultConfig(apiBaseURL string) string {
-	apiLine := "# api_url: https://your-control-plane.example.com"
+// If apiBaseURL is empty, a placeholder comment is written instead. A
+// non-empty hostname, token source, and metadata are written to the
+// registration section, with metadata keys sorted.
+func GenerateDefaultConfig(apiBaseURL, hostname, tokenSource string, metadata map[string]string) string {
+	apiLine := "  # baseurl: https://your-control-plane.example.com"
 	if apiBaseURL != "" {
-		apiLine = fmt.Sprintf("api_url: %s", apiBaseURL)
+		apiLine = fmt.Sprintf("  baseurl: %s", apiBaseURL)
+	}
+
+	var registration strings.Builder
+	if hostname != "" {
+		fmt.Fprintf(&registration, "  hostname: %s\n", strconv.Quote(hostname))
+	}
+	if tokenSource != "" {
+		fmt.Fprintf(&registration, "  tokensource: %s\n", strconv.Quote(tokenSource))
+	}
+	if len(metadata) > 0 {
+		registration.WriteString("  metadata:\n")
+		keys := make([]string, 0, len(metadata))
+		for key := range metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			// Go quoting is a subset of YAML double-quoted scalars.
+			fmt.Fprintf(&registration, "    %s: %s\n", strconv.Quote(key), strconv.Quote(metadata[key]))
+		}
 	}
 
 	return fmt.Sprintf(`# plexd configuration
 # See documentation for all available options.
+config_version: %d
 
+api:
 %s
-data_dir: /var/lib/plexd
+data_dir: %s
 log_level: info
-token_file: /etc/plexd/bootstrap-token
-`, apiLine)
+registration:
+  tokenfile: %s
+%s`, defaultConfigVersion, apiLine, DefaultDataDir, filepath.Join(DefaultConfigDir, "bootstrap-token"), registration.String())
 }
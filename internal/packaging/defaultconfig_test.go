@@ -3,13 +3,15 @@ package packaging
 import (
 	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestGenerateDefaultConfig_WithAPIURL(t *testing.T) {
-	output := GenerateDefaultConfig("https://api.example.com")
+	output := GenerateDefaultConfig("https://api.example.com", "", "", nil)
 
-	if !strings.Contains(output, "api_url: https://api.example.com") {
-		t.Errorf("output missing api_url, got:\n%s", output)
+	if !strings.Contains(output, "  baseurl: https://api.example.com") {
+		t.Errorf("output missing api.baseurl, got:\n%s", output)
 	}
 	if !strings.Contains(output, "data_dir: /var/lib/plexd") {
 		t.Error("output missing data_dir")
@@ -17,22 +19,25 @@ func TestGenerateDefaultConfig_WithAPIURL(t *testing.T) {
 	if !strings.Contains(output, "log_level: info") {
 		t.Error("output missing log_level")
 	}
-	if !strings.Contains(output, "token_file: /etc/plexd/bootstrap-token") {
-		t.Error("output missing token_file")
+	if !strings.Contains(output, "  tokenfile: /etc/plexd/bootstrap-token") {
+		t.Error("output missing registration.tokenfile")
+	}
+	if !strings.Contains(output, "config_version: 1") {
+		t.Error("output missing config_version")
 	}
 }
 
 func TestGenerateDefaultConfig_WithoutAPIURL(t *testing.T) {
-	output := GenerateDefaultConfig("")
+	output := GenerateDefaultConfig("", "", "", nil)
 
-	if !strings.Contains(output, "# api_url:") {
-		t.Errorf("output missing commented api_url placeholder, got:\n%s", output)
+	if !strings.Contains(output, "# baseurl:") {
+		t.Errorf("output missing commented baseurl placeholder, got:\n%s", output)
 	}
-	// Should NOT contain an uncommented api_url line
+	// Should NOT contain an uncommented baseurl line
 	for _, line := range strings.Split(output, "\n") {
 		trimmed := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmed, "api_url:") {
-			t.Errorf("output contains uncommented api_url line: %q", line)
+		if strings.HasPrefix(trimmed, "baseurl:") {
+			t.Errorf("output contains uncommented baseurl line: %q", line)
 		}
 	}
 	if !strings.Contains(output, "data_dir: /var/lib/plexd") {
@@ -44,15 +49,58 @@ func TestGenerateDefaultConfig_WithoutAPIURL(t *testing.T) {
 }
 
 func TestGenerateDefaultConfig_YAMLValidity(t *testing.T) {
-	// Verify basic YAML structure: all non-comment, non-empty lines have key: value format
-	output := GenerateDefaultConfig("https://api.example.com")
-	for _, line := range strings.Split(output, "\n") {
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
-			continue
+	for _, apiURL := range []string{"https://api.example.com", ""} {
+		output := GenerateDefaultConfig(apiURL, "", "", nil)
+		var doc struct {
+			ConfigVersion int `yaml:"config_version"`
+			API           struct {
+				BaseURL string `yaml:"baseurl"`
+			} `yaml:"api"`
+			DataDir      string `yaml:"data_dir"`
+			LogLevel     string `yaml:"log_level"`
+			Registration struct {
+				TokenFile string `yaml:"tokenfile"`
+			} `yaml:"registration"`
+		}
+		dec := yaml.NewDecoder(strings.NewReader(output))
+		dec.KnownFields(true)
+		if err := dec.Decode(&doc); err != nil {
+			t.Fatalf("GenerateDefaultConfig(%q) is not valid YAML: %v\n%s", apiURL, err, output)
 		}
-		if !strings.Contains(trimmed, ": ") {
-			t.Errorf("non-comment line missing key-value format: %q", trimmed)
+		if doc.API.BaseURL != apiURL {
+			t.Errorf("api.baseurl = %q, want %q", doc.API.BaseURL, apiURL)
 		}
+		if doc.Registration.TokenFile != "/etc/plexd/bootstrap-token" {
+			t.Errorf("registration.tokenfile = %q", doc.Registration.TokenFile)
+		}
+	}
+}
+
+func TestGenerateDefaultConfig_HostnameAndMetadata(t *testing.T) {
+	output := GenerateDefaultConfig("", "web-1", "", map[string]string{"zone": "eu-1", "env": "prod"})
+
+	if !strings.Contains(output, "  hostname: \"web-1\"\n") {
+		t.Errorf("output missing registration.hostname, got:\n%s", output)
+	}
+	// Keys are sorted for a stable file.
+	if !strings.Contains(output, "  metadata:\n    \"env\": \"prod\"\n    \"zone\": \"eu-1\"\n") {
+		t.Errorf("output missing sorted registration.metadata, got:\n%s", output)
+	}
+
+	output = GenerateDefaultConfig("", "", "", nil)
+	if strings.Contains(output, "hostname:") || strings.Contains(output, "metadata:") {
+		t.Errorf("output has registration labels without any set, got:\n%s", output)
+	}
+}
+
+func TestGenerateDefaultConfig_TokenSource(t *testing.T) {
+	output := GenerateDefaultConfig("", "", "vault:secret/plexd?field=token", nil)
+	if !strings.Contains(output, "  tokensource: \"vault:secret/plexd?field=token\"\n") {
+		t.Errorf("output missing registration.tokensource, got:\n%s", output)
+	}
+
+	var parsed map[string]any
+	if err := yaml.Unmarshal([]byte(output), &parsed); err != nil {
+		t.Fatalf("output is not valid YAML: %v\n%s", err, output)
 	}
 }
@@ -0,0 +1,168 @@
+package packaging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// ErrPreflightFailed is returned by Install and DryRun when a preflight
+// check failed.
+var ErrPreflightFailed = errors.New("packaging: preflight checks failed")
+
+// PlannedChange is a change Install would make to the host.
+type PlannedChange struct {
+	// Action is one of "mkdir", "copy", "write", "keep", "chown",
+	// "register", "load", or "reload".
+	Action string `json:"action"`
+	// Path is the file or directory changed, the service name for
+	// "register", the security module for "load", and the init system for
+	// "reload".
+	Path string `json:"path"`
+	// Detail describes the change, such as the file mode.
+	Detail string `json:"detail,omitempty"`
+}
+
+// runPreflight runs the preflight checks, writes the report, and logs each
+// result. It returns an error if a check failed.
+func (ins *Installer) runPreflight() error {
+	if len(ins.preflight) == 0 {
+		return nil
+	}
+	report := runPreflight(ins.preflight)
+	ins.lastCheck = report
+	for _, res := range report {
+		ins.logger.Info("preflight check", "check", res.Name, "status", string(res.Status), "detail", res.Detail)
+	}
+	if err := report.Write(ins.report); err != nil {
+		return fmt.Errorf("packaging: write preflight report: %w", err)
+	}
+	if report.Failed() {
+		return ErrPreflightFailed
+	}
+	return nil
+}
+
+// Plan returns the changes Install would make, in order, without making
+// them. Existing directories and an existing config are reported as "keep".
+func (ins *Installer) Plan() ([]PlannedChange, error) {
+	if ins.cfg.Rootless {
+		if _, ok := ins.init.(AuxiliaryServiceFiler); !ok {
+			return nil, fmt.Errorf("packaging: rootless mode requires systemd, not %s", ins.init.Name())
+		}
+		if _, err := lookupServiceUser(ins.cfg.User); err != nil {
+			return nil, err
+		}
+	}
+
+	var plan []PlannedChange
+	dirs := []struct {
+		path string
+		perm os.FileMode
+	}{
+		{ins.cfg.ConfigDir, 0o755},
+		{ins.cfg.DataDir, 0o700},
+		{ins.cfg.RunDir, 0o755},
+	}
+	for _, d := range dirs {
+		plan = append(plan, plannedFile("mkdir", d.path, fmt.Sprintf("%04o", d.perm)))
+	}
+
+	if ins.bundle != nil {
+		if _, err := ins.bundle.Binary(runtime.GOOS, runtime.GOARCH); err != nil {
+			return nil, err
+		}
+		plan = append(plan, PlannedChange{Action: "copy", Path: ins.cfg.BinaryPath, Detail: "0755 from bundle " + BinaryName(runtime.GOOS, runtime.GOARCH)})
+	} else if srcPath, err := sourceBinaryPath(); err != nil {
+		return nil, err
+	} else if srcPath == ins.cfg.BinaryPath {
+		plan = append(plan, PlannedChange{Action: "keep", Path: ins.cfg.BinaryPath, Detail: "binary already at install path"})
+	} else {
+		plan = append(plan, PlannedChange{Action: "copy", Path: ins.cfg.BinaryPath, Detail: "0755 from " + srcPath})
+	}
+
+	plan = append(plan, plannedFile("write", filepath.Join(ins.cfg.ConfigDir, "config.yaml"), "0644 default config"))
+
+	tokenPath := filepath.Join(ins.cfg.ConfigDir, "bootstrap-token")
+	switch {
+	case ins.cfg.TokenValue != "":
+		plan = append(plan, PlannedChange{Action: "write", Path: tokenPath, Detail: "0600 bootstrap token from --token"})
+	case ins.cfg.TokenFile != "":
+		plan = append(plan, PlannedChange{Action: "write", Path: tokenPath, Detail: "0600 bootstrap token from " + ins.cfg.TokenFile})
+	}
+
+	if ins.cfg.Rootless {
+		for _, p := range []string{ins.cfg.DataDir, ins.cfg.RunDir, tokenPath} {
+			plan = append(plan, PlannedChange{Action: "chown", Path: p, Detail: "to " + ins.cfg.User})
+		}
+	}
+
+	if _, ok := ins.init.(ServiceRegistrar); ok {
+		plan = append(plan, PlannedChange{Action: "register", Path: ins.cfg.ServiceName, Detail: "with " + ins.init.Name()})
+	} else {
+		files := []ServiceFile{ins.init.ServiceFile(ins.cfg)}
+		if aux, ok := ins.init.(AuxiliaryServiceFiler); ok {
+			files = append(files, aux.AuxiliaryServiceFiles(ins.cfg)...)
+		}
+		for _, svc := range files {
+			detail := fmt.Sprintf("%04o service file", svc.Mode)
+			if ins.bundle != nil {
+				if _, ok, err := ins.bundle.renderServiceFile(svc, ins.cfg); err != nil {
+					return nil, err
+				} else if ok {
+					detail += " from bundle template"
+				}
+			}
+			plan = append(plan, PlannedChange{Action: "write", Path: svc.Path, Detail: detail})
+		}
+	}
+	for _, m := range ins.security {
+		policy := m.PolicyFile(ins.cfg)
+		plan = append(plan,
+			PlannedChange{Action: "write", Path: policy.Path, Detail: fmt.Sprintf("%04o %s policy", policy.Mode, m.Name())},
+			PlannedChange{Action: "load", Path: m.Name()},
+		)
+	}
+	plan = append(plan, PlannedChange{Action: "reload", Path: ins.init.Name()})
+	return plan, nil
+}
+
+// plannedFile returns action for path, or "keep" if path exists.
+func plannedFile(action, path, detail string) PlannedChange {
+	if _, err := os.Stat(path); err == nil {
+		return PlannedChange{Action: "keep", Path: path, Detail: "exists"}
+	}
+	return PlannedChange{Action: action, Path: path, Detail: detail}
+}
+
+// DryRun runs the preflight checks and writes their report and the planned
+// changes to the report writer without changing the host. Unlike Install,
+// it does not require root privileges. It returns an error if the init
+// system is unavailable, a preflight check failed, or the plan cannot be
+// computed.
+func (ins *Installer) DryRun() error {
+	if !ins.init.IsAvailable() {
+		return fmt.Errorf("packaging: %s is not available", ins.init.Name())
+	}
+	preflightErr := ins.runPreflight()
+
+	plan, err := ins.Plan()
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(ins.report, "Planned changes (%s):\n", ins.init.Name()); err != nil {
+		return fmt.Errorf("packaging: write plan: %w", err)
+	}
+	for _, c := range plan {
+		line := fmt.Sprintf("  %-8s %s", c.Action, c.Path)
+		if c.Detail != "" {
+			line += " (" + c.Detail + ")"
+		}
+		if _, err := fmt.Fprintln(ins.report, line); err != nil {
+			return fmt.Errorf("packaging: write plan: %w", err)
+		}
+	}
+	return preflightErr
+}
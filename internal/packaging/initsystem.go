@@ -0,0 +1,135 @@
+package packaging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Init system identifiers accepted by NewInitSystem.
+const (
+	InitSystemAuto    = "auto"
+	InitSystemSystemd = "systemd"
+	InitSystemOpenRC  = "openrc"
+	InitSystemSysV    = "sysv"
+	InitSystemSCM     = "scm"
+	InitSystemLaunchd = "launchd"
+)
+
+// ServiceFile describes the unit file or init script written by the Installer.
+type ServiceFile struct {
+	// Path is the absolute path of the file.
+	Path string
+
+	// Content is the complete file content.
+	Content string
+
+	// Mode is the file permission. Init scripts must be executable.
+	Mode os.FileMode
+}
+
+// NewInitSystem returns the init system selected by name. An empty name or
+// InitSystemAuto detects the running init system: the Windows Service
+// Control Manager on Windows, launchd on macOS, otherwise systemd, then
+// OpenRC, then SysV.
+func NewInitSystem(name string) (InitSystem, error) {
+	switch name {
+	case "", InitSystemAuto:
+		return DetectInitSystem(
+			NewSCMInitSystem(),
+			NewLaunchdInitSystem(),
+			NewSystemdInitSystem(NewSystemdController()),
+			NewOpenRCInitSystem(),
+			NewSysVInitSystem(),
+		)
+	case InitSystemSystemd:
+		return NewSystemdInitSystem(NewSystemdController()), nil
+	case InitSystemOpenRC:
+		return NewOpenRCInitSystem(), nil
+	case InitSystemSysV:
+		return NewSysVInitSystem(), nil
+	case InitSystemSCM:
+		return NewSCMInitSystem(), nil
+	case InitSystemLaunchd:
+		return NewLaunchdInitSystem(), nil
+	default:
+		return nil, fmt.Errorf("packaging: unknown init system %q (must be %q, %q, %q, %q, %q, or %q)",
+			name, InitSystemAuto, InitSystemSystemd, InitSystemOpenRC, InitSystemSysV, InitSystemSCM, InitSystemLaunchd)
+	}
+}
+
+// DetectInitSystem returns the first available candidate.
+func DetectInitSystem(candidates ...InitSystem) (InitSystem, error) {
+	for _, c := range candidates {
+		if c.IsAvailable() {
+			return c, nil
+		}
+	}
+	return nil, errors.New("packaging: no supported init system found (scm, launchd, systemd, openrc, sysv)")
+}
+
+// systemdInitSystem adapts a SystemdController to the InitSystem interface.
+type systemdInitSystem struct {
+	ctrl SystemdController
+}
+
+// NewSystemdInitSystem returns an InitSystem that installs a systemd unit
+// file at InstallConfig.UnitFilePath and manages it through ctrl.
+func NewSystemdInitSystem(ctrl SystemdController) InitSystem {
+	return &systemdInitSystem{ctrl: ctrl}
+}
+
+func (s *systemdInitSystem) Name() string      { return InitSystemSystemd }
+func (s *systemdInitSystem) IsAvailable() bool { return s.ctrl.IsAvailable() }
+
+func (s *systemdInitSystem) ServiceFile(cfg InstallConfig) ServiceFile {
+	cfg.ApplyDefaults()
+	return ServiceFile{Path: cfg.UnitFilePath, Content: GenerateUnitFile(cfg), Mode: 0o644}
+}
+
+// AuxiliaryServiceFiles returns the privileged helper socket and service
+// units for a rootless install, next to the main unit file.
+func (s *systemdInitSystem) AuxiliaryServiceFiles(cfg InstallConfig) []ServiceFile {
+	cfg.ApplyDefaults()
+	if !cfg.Rootless {
+		return nil
+	}
+	dir := filepath.Dir(cfg.UnitFilePath)
+	return []ServiceFile{
+		{Path: filepath.Join(dir, helperUnitName(cfg.ServiceName, "socket")), Content: GenerateHelperSocketUnit(cfg), Mode: 0o644},
+		{Path: filepath.Join(dir, helperUnitName(cfg.ServiceName, "service")), Content: GenerateHelperServiceUnit(cfg), Mode: 0o644},
+	}
+}
+
+func (s *systemdInitSystem) Reload() error                { return s.ctrl.DaemonReload() }
+func (s *systemdInitSystem) Enable(service string) error  { return s.ctrl.Enable(service) }
+func (s *systemdInitSystem) Disable(service string) error { return s.ctrl.Disable(service) }
+func (s *systemdInitSystem) Stop(service string) error    { return s.ctrl.Stop(service) }
+func (s *systemdInitSystem) Restart(service string) error { return s.ctrl.Restart(service) }
+
+// commandRunner executes an external command. It is replaced in tests.
+type commandRunner func(name string, args ...string) error
+
+// runCommand executes name with args and includes combined output in the error.
+func runCommand(name string, args ...string) error {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("packaging: %s %s: %s: %w", name, strings.Join(args, " "), strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// commandExists reports whether name is found in PATH.
+func commandExists(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// dirExists reports whether path exists and is a directory.
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
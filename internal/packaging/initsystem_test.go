@@ -0,0 +1,189 @@
+package packaging
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// commandRecorder records commands instead of executing them.
+type commandRecorder struct {
+	calls []string
+	err   error
+}
+
+func (r *commandRecorder) run(name string, args ...string) error {
+	r.calls = append(r.calls, strings.Join(append([]string{name}, args...), " "))
+	return r.err
+}
+
+// fakeInitSystem is an InitSystem with a fixed name and availability.
+type fakeInitSystem struct {
+	InitSystem
+	name      string
+	available bool
+}
+
+func (f *fakeInitSystem) Name() string      { return f.name }
+func (f *fakeInitSystem) IsAvailable() bool { return f.available }
+
+func TestNewInitSystem_Names(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{InitSystemSystemd, InitSystemSystemd},
+		{InitSystemOpenRC, InitSystemOpenRC},
+		{InitSystemSysV, InitSystemSysV},
+		{InitSystemSCM, InitSystemSCM},
+		{InitSystemLaunchd, InitSystemLaunchd},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewInitSystem(tt.name)
+			if err != nil {
+				t.Fatalf("NewInitSystem(%q) = %v", tt.name, err)
+			}
+			if got.Name() != tt.want {
+				t.Errorf("Name() = %q, want %q", got.Name(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNewInitSystem_Unknown(t *testing.T) {
+	_, err := NewInitSystem("upstart")
+	if err == nil {
+		t.Fatal("NewInitSystem(\"upstart\") = nil error, want error")
+	}
+	if !strings.Contains(err.Error(), "unknown init system") {
+		t.Errorf("error = %q, want message about unknown init system", err)
+	}
+}
+
+func TestDetectInitSystem_FirstAvailable(t *testing.T) {
+	got, err := DetectInitSystem(
+		&fakeInitSystem{name: "systemd", available: false},
+		&fakeInitSystem{name: "openrc", available: true},
+		&fakeInitSystem{name: "sysv", available: true},
+	)
+	if err != nil {
+		t.Fatalf("DetectInitSystem() = %v", err)
+	}
+	if got.Name() != "openrc" {
+		t.Errorf("detected %q, want openrc", got.Name())
+	}
+}
+
+func TestDetectInitSystem_NoneAvailable(t *testing.T) {
+	_, err := DetectInitSystem(&fakeInitSystem{name: "systemd"}, &fakeInitSystem{name: "sysv"})
+	if err == nil {
+		t.Fatal("DetectInitSystem() = nil error, want error")
+	}
+	if !strings.Contains(err.Error(), "no supported init system") {
+		t.Errorf("error = %q, want message about no supported init system", err)
+	}
+}
+
+func TestSystemdInitSystem_DelegatesToController(t *testing.T) {
+	ctrl := &mockSystemdController{available: true}
+	s := NewSystemdInitSystem(ctrl)
+
+	if !s.IsAvailable() {
+		t.Error("IsAvailable() = false, want true")
+	}
+	if err := s.Reload(); err != nil {
+		t.Fatalf("Reload() = %v", err)
+	}
+	_ = s.Enable("plexd")
+	_ = s.Disable("plexd")
+	_ = s.Stop("plexd")
+
+	if ctrl.daemonReloadCalls != 1 {
+		t.Errorf("DaemonReload calls = %d, want 1", ctrl.daemonReloadCalls)
+	}
+	if len(ctrl.enableCalls) != 1 || len(ctrl.disableCalls) != 1 || len(ctrl.stopCalls) != 1 {
+		t.Errorf("enable/disable/stop calls = %v/%v/%v, want one each", ctrl.enableCalls, ctrl.disableCalls, ctrl.stopCalls)
+	}
+
+	svc := s.ServiceFile(InstallConfig{})
+	if svc.Path != DefaultUnitFilePath {
+		t.Errorf("ServiceFile().Path = %q, want %q", svc.Path, DefaultUnitFilePath)
+	}
+	if svc.Mode != 0o644 {
+		t.Errorf("ServiceFile().Mode = %04o, want 0644", svc.Mode)
+	}
+	if !strings.Contains(svc.Content, "[Service]") {
+		t.Error("ServiceFile().Content is not a systemd unit")
+	}
+}
+
+func TestOpenRCInitSystem_Commands(t *testing.T) {
+	rec := &commandRecorder{}
+	s := &openrcInitSystem{run: rec.run}
+
+	if err := s.Reload(); err != nil {
+		t.Fatalf("Reload() = %v", err)
+	}
+	for _, fn := range []func(string) error{s.Enable, s.Disable, s.Stop} {
+		if err := fn("plexd"); err != nil {
+			t.Fatalf("command failed: %v", err)
+		}
+	}
+
+	want := []string{"rc-update add plexd default", "rc-update del plexd default", "rc-service plexd stop"}
+	if strings.Join(rec.calls, "|") != strings.Join(want, "|") {
+		t.Errorf("commands = %v, want %v", rec.calls, want)
+	}
+
+	svc := s.ServiceFile(InstallConfig{})
+	if svc.Path != "/etc/init.d/plexd" {
+		t.Errorf("ServiceFile().Path = %q, want /etc/init.d/plexd", svc.Path)
+	}
+	if svc.Mode != 0o755 {
+		t.Errorf("ServiceFile().Mode = %04o, want 0755", svc.Mode)
+	}
+}
+
+func TestSysVInitSystem_Commands(t *testing.T) {
+	tests := []struct {
+		name      string
+		available map[string]bool
+		want      []string
+	}{
+		{
+			name:      "update-rc.d",
+			available: map[string]bool{"update-rc.d": true, "chkconfig": true},
+			want:      []string{"update-rc.d plexd defaults", "update-rc.d -f plexd remove", "service plexd stop"},
+		},
+		{
+			name:      "chkconfig",
+			available: map[string]bool{"chkconfig": true},
+			want:      []string{"chkconfig --add plexd", "chkconfig --del plexd", "service plexd stop"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &commandRecorder{}
+			s := &sysvInitSystem{run: rec.run, hasCmd: func(name string) bool { return tt.available[name] }}
+
+			for _, fn := range []func(string) error{s.Enable, s.Disable, s.Stop} {
+				if err := fn("plexd"); err != nil {
+					t.Fatalf("command failed: %v", err)
+				}
+			}
+			if strings.Join(rec.calls, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("commands = %v, want %v", rec.calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestSysVInitSystem_CommandError(t *testing.T) {
+	rec := &commandRecorder{err: errors.New("exit status 1")}
+	s := &sysvInitSystem{run: rec.run, hasCmd: func(string) bool { return true }}
+
+	if err := s.Enable("plexd"); err == nil {
+		t.Fatal("Enable() = nil, want error")
+	}
+}
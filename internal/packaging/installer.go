@@ -6,44 +6,105 @@ import (
 	"io"
 	"log/slog"
 	"os"
+	"os/user"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 )
 
 const maxTokenLength = 512
 
-// Installer handles installing and uninstalling plexd as a systemd service.
+// Installer handles installing and uninstalling plexd as a system service.
 type Installer struct {
-	cfg     InstallConfig
-	systemd SystemdController
-	root    RootChecker
-	logger  *slog.Logger
+	cfg       InstallConfig
+	init      InitSystem
+	root      RootChecker
+	logger    *slog.Logger
+	preflight []PreflightCheck
+	security  []SecurityModule
+	bundle    *Bundle
+	report    io.Writer
+	lastCheck PreflightReport
 }
 
-// NewInstaller creates a new Installer with defaults applied.
-func NewInstaller(cfg InstallConfig, systemd SystemdController, root RootChecker, logger *slog.Logger) *Installer {
+// NewInstaller creates a new Installer with defaults applied. The init system
+// determines which unit file or init script is written; use NewInitSystem to
+// auto-detect it. Install runs DefaultPreflightChecks for cfg and installs
+// a policy for each security module DetectSecurityModules finds.
+func NewInstaller(cfg InstallConfig, init InitSystem, root RootChecker, logger *slog.Logger) *Installer {
 	cfg.ApplyDefaults()
 	return &Installer{
-		cfg:     cfg,
-		systemd: systemd,
-		root:    root,
-		logger:  logger.With("component", "packaging"),
+		cfg:       cfg,
+		init:      init,
+		root:      root,
+		logger:    logger.With("component", "packaging", "init_system", init.Name()),
+		preflight: DefaultPreflightChecks(cfg),
+		security:  DetectSecurityModules(),
+		report:    io.Discard,
 	}
 }
 
-// Install installs plexd as a systemd service.
+// SetPreflightChecks replaces the checks Install runs before changing the
+// host. nil disables the preflight stage.
+func (ins *Installer) SetPreflightChecks(checks []PreflightCheck) {
+	ins.preflight = checks
+}
+
+// SetSecurityModules replaces the security modules Install loads a policy
+// into and Uninstall removes it from. nil skips policy installation.
+func (ins *Installer) SetSecurityModules(modules []SecurityModule) {
+	ins.security = modules
+}
+
+// SetBundle makes Install take the binary and service file templates from
+// a verified offline bundle instead of the running binary and the built-in
+// templates.
+func (ins *Installer) SetBundle(b *Bundle) {
+	ins.bundle = b
+}
+
+// SetReportWriter sets where Install and DryRun write the preflight report
+// and DryRun writes the planned changes. Default: io.Discard.
+func (ins *Installer) SetReportWriter(w io.Writer) {
+	ins.report = w
+}
+
+// Preflight returns the report of the preflight checks run by the last
+// Install or DryRun, or nil if none ran.
+func (ins *Installer) Preflight() PreflightReport {
+	return ins.lastCheck
+}
+
+// Install installs plexd as a service of the configured init system.
 func (ins *Installer) Install() error {
 	// 1. Check root
 	if !ins.root.IsRoot() {
 		return errors.New("packaging: install requires root privileges")
 	}
 
-	// 2. Check systemd
-	if !ins.systemd.IsAvailable() {
-		return errors.New("packaging: systemd is not available")
+	// 2. Check init system
+	if !ins.init.IsAvailable() {
+		return fmt.Errorf("packaging: %s is not available", ins.init.Name())
+	}
+	var owner *serviceUser
+	if ins.cfg.Rootless {
+		if _, ok := ins.init.(AuxiliaryServiceFiler); !ok {
+			return fmt.Errorf("packaging: rootless mode requires systemd, not %s", ins.init.Name())
+		}
+		u, err := lookupServiceUser(ins.cfg.User)
+		if err != nil {
+			return err
+		}
+		owner = u
 	}
 
-	// 3. Create directories
+	// 3. Run preflight checks
+	if err := ins.runPreflight(); err != nil {
+		return err
+	}
+
+	// 4. Create directories
 	dirs := []struct {
 		path string
 		perm os.FileMode
@@ -59,92 +120,108 @@ func (ins *Installer) Install() error {
 		ins.logger.Info("directory created", "path", d.path, "perm", fmt.Sprintf("%04o", d.perm))
 	}
 
-	// 4. Copy binary
+	// 5. Copy binary
 	if err := ins.copyBinary(); err != nil {
 		return err
 	}
 
-	// 5. Write default config if absent
+	// 6. Write default config if absent
 	configPath := filepath.Join(ins.cfg.ConfigDir, "config.yaml")
 	if _, err := os.Stat(configPath); errors.Is(err, os.ErrNotExist) {
-		content := GenerateDefaultConfig(ins.cfg.APIBaseURL)
+		content := GenerateDefaultConfig(ins.cfg.APIBaseURL, ins.cfg.Hostname, ins.cfg.TokenSource, ins.cfg.Metadata)
 		if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
 			return fmt.Errorf("packaging: write config: %w", err)
 		}
 		ins.logger.Info("default config written", "path", configPath)
 	} else if err == nil {
 		ins.logger.Info("existing config preserved", "path", configPath)
+		if ins.cfg.Hostname != "" || ins.cfg.TokenSource != "" || len(ins.cfg.Metadata) > 0 {
+			ins.logger.Warn("hostname, token source, and metadata not written, edit the existing config instead", "path", configPath)
+		}
 	} else {
 		return fmt.Errorf("packaging: stat config: %w", err)
 	}
 
-	// 6. Write bootstrap token if provided
+	// 7. Write bootstrap token if provided
 	if err := ins.writeToken(); err != nil {
 		return err
 	}
 
-	// 7. Write unit file
-	unitContent := GenerateUnitFile(ins.cfg)
-	// Create parent directory for unit file if needed
-	unitDir := filepath.Dir(ins.cfg.UnitFilePath)
-	if err := os.MkdirAll(unitDir, 0o755); err != nil {
-		return fmt.Errorf("packaging: create unit file directory: %w", err)
+	// In rootless mode the agent user owns its state and the token.
+	if owner != nil {
+		if err := ins.chownToServiceUser(owner); err != nil {
+			return err
+		}
 	}
-	if err := os.WriteFile(ins.cfg.UnitFilePath, []byte(unitContent), 0o644); err != nil {
-		return fmt.Errorf("packaging: write unit file: %w", err)
+
+	// 8. Write unit file or init script, or register with the service manager
+	if err := ins.registerService(); err != nil {
+		return err
 	}
-	ins.logger.Info("unit file written", "path", ins.cfg.UnitFilePath)
 
-	// 8. Daemon reload
-	if err := ins.systemd.DaemonReload(); err != nil {
-		return fmt.Errorf("packaging: daemon-reload: %w", err)
+	// 9. Install SELinux policy module or AppArmor profile
+	if err := ins.installSecurityPolicies(); err != nil {
+		return err
+	}
+
+	// 10. Reload init system
+	if err := ins.init.Reload(); err != nil {
+		return fmt.Errorf("packaging: reload %s: %w", ins.init.Name(), err)
 	}
-	ins.logger.Info("systemd daemon reloaded")
+	ins.logger.Info("init system reloaded")
 
 	return nil
 }
 
-// Uninstall removes the plexd systemd service. If purge is true, data and config dirs are also removed.
+// Uninstall removes the plexd service. If purge is true, data and config dirs are also removed.
 func (ins *Installer) Uninstall(purge bool) error {
 	// 1. Check root
 	if !ins.root.IsRoot() {
 		return errors.New("packaging: uninstall requires root privileges")
 	}
 
-	// 2. Check if installed (unit file exists)
-	if _, err := os.Stat(ins.cfg.UnitFilePath); errors.Is(err, os.ErrNotExist) {
+	// 2. Check if installed (service file exists or service is registered)
+	installed, err := ins.serviceInstalled()
+	if err != nil {
+		return err
+	}
+	if !installed {
 		ins.logger.Info("plexd is not installed, nothing to do")
 		return nil
 	}
 
 	// 3. Stop service (ignore errors — service may not be running)
-	if err := ins.systemd.Stop(ins.cfg.ServiceName); err != nil {
+	if err := ins.init.Stop(ins.cfg.ServiceName); err != nil {
 		ins.logger.Info("stop service", "error", err)
 	}
 
 	// 4. Disable service
-	if err := ins.systemd.Disable(ins.cfg.ServiceName); err != nil {
+	if err := ins.init.Disable(ins.cfg.ServiceName); err != nil {
 		ins.logger.Info("disable service", "error", err)
 	}
 
-	// 5. Remove unit file
-	if err := os.Remove(ins.cfg.UnitFilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("packaging: remove unit file: %w", err)
+	// 5. Remove unit file or init script, or unregister the service
+	if err := ins.unregisterService(); err != nil {
+		return err
+	}
+
+	// 6. Reload init system
+	if err := ins.init.Reload(); err != nil {
+		return fmt.Errorf("packaging: reload %s: %w", ins.init.Name(), err)
 	}
-	ins.logger.Info("unit file removed", "path", ins.cfg.UnitFilePath)
 
-	// 6. Daemon reload
-	if err := ins.systemd.DaemonReload(); err != nil {
-		return fmt.Errorf("packaging: daemon-reload: %w", err)
+	// 7. Unload and remove security policies
+	if err := ins.removeSecurityPolicies(); err != nil {
+		return err
 	}
 
-	// 7. Remove binary
+	// 8. Remove binary
 	if err := os.Remove(ins.cfg.BinaryPath); err != nil && !errors.Is(err, os.ErrNotExist) {
 		return fmt.Errorf("packaging: remove binary: %w", err)
 	}
 	ins.logger.Info("binary removed", "path", ins.cfg.BinaryPath)
 
-	// 8. Purge directories if requested
+	// 9. Purge directories if requested
 	if purge {
 		for _, dir := range []string{ins.cfg.DataDir, ins.cfg.ConfigDir} {
 			if err := os.RemoveAll(dir); err != nil {
@@ -157,16 +234,137 @@ func (ins *Installer) Uninstall(purge bool) error {
 	return nil
 }
 
-func (ins *Installer) copyBinary() error {
+// registerService writes the init system's service file, or registers the
+// service through the init system's API when it implements ServiceRegistrar.
+func (ins *Installer) registerService() error {
+	if reg, ok := ins.init.(ServiceRegistrar); ok {
+		if err := reg.Register(ins.cfg); err != nil {
+			return fmt.Errorf("packaging: register service: %w", err)
+		}
+		ins.logger.Info("service registered", "service", ins.cfg.ServiceName)
+		return nil
+	}
+
+	files := []ServiceFile{ins.init.ServiceFile(ins.cfg)}
+	if aux, ok := ins.init.(AuxiliaryServiceFiler); ok {
+		files = append(files, aux.AuxiliaryServiceFiles(ins.cfg)...)
+	}
+	for _, svc := range files {
+		if ins.bundle != nil {
+			var err error
+			if svc, _, err = ins.bundle.renderServiceFile(svc, ins.cfg); err != nil {
+				return err
+			}
+		}
+		if err := ins.writeServiceFile(svc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ins *Installer) writeServiceFile(svc ServiceFile) error {
+	// Create parent directory for service file if needed
+	if err := os.MkdirAll(filepath.Dir(svc.Path), 0o755); err != nil {
+		return fmt.Errorf("packaging: create service file directory: %w", err)
+	}
+	if err := os.WriteFile(svc.Path, []byte(svc.Content), svc.Mode); err != nil {
+		return fmt.Errorf("packaging: write service file: %w", err)
+	}
+	// WriteFile does not change the mode of an existing file.
+	if err := os.Chmod(svc.Path, svc.Mode); err != nil {
+		return fmt.Errorf("packaging: chmod service file: %w", err)
+	}
+	ins.logger.Info("service file written", "path", svc.Path)
+	return nil
+}
+
+// serviceInstalled reports whether the service file exists or the service is
+// registered.
+func (ins *Installer) serviceInstalled() (bool, error) {
+	if reg, ok := ins.init.(ServiceRegistrar); ok {
+		return reg.IsRegistered(ins.cfg.ServiceName), nil
+	}
+	path := ins.init.ServiceFile(ins.cfg).Path
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("packaging: stat service file: %w", err)
+	}
+	return true, nil
+}
+
+// unregisterService removes the service file or unregisters the service.
+func (ins *Installer) unregisterService() error {
+	if reg, ok := ins.init.(ServiceRegistrar); ok {
+		if err := reg.Unregister(ins.cfg.ServiceName); err != nil {
+			return fmt.Errorf("packaging: unregister service: %w", err)
+		}
+		ins.logger.Info("service unregistered", "service", ins.cfg.ServiceName)
+		return nil
+	}
+
+	path := ins.init.ServiceFile(ins.cfg).Path
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("packaging: remove service file: %w", err)
+	}
+	ins.logger.Info("service file removed", "path", path)
+	return ins.removeAuxiliaryServiceFiles()
+}
+
+// removeAuxiliaryServiceFiles stops, disables, and removes any auxiliary
+// service files left by a rootless install. Uninstall does not know whether
+// the install was rootless, so it checks for the rootless files.
+func (ins *Installer) removeAuxiliaryServiceFiles() error {
+	aux, ok := ins.init.(AuxiliaryServiceFiler)
+	if !ok {
+		return nil
+	}
+	rootless := ins.cfg
+	rootless.Rootless = true
+	for _, svc := range aux.AuxiliaryServiceFiles(rootless) {
+		if _, err := os.Stat(svc.Path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		unit := filepath.Base(svc.Path)
+		if err := ins.init.Stop(unit); err != nil {
+			ins.logger.Info("stop service", "unit", unit, "error", err)
+		}
+		if err := ins.init.Disable(unit); err != nil {
+			ins.logger.Info("disable service", "unit", unit, "error", err)
+		}
+		if err := os.Remove(svc.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("packaging: remove service file: %w", err)
+		}
+		ins.logger.Info("service file removed", "path", svc.Path)
+	}
+	return nil
+}
+
+// sourceBinaryPath returns the path of the running binary with symlinks
+// resolved.
+func sourceBinaryPath() (string, error) {
 	srcPath, err := os.Executable()
 	if err != nil {
-		return fmt.Errorf("packaging: resolve executable path: %w", err)
+		return "", fmt.Errorf("packaging: resolve executable path: %w", err)
 	}
 
 	// Resolve symlinks
 	srcPath, err = filepath.EvalSymlinks(srcPath)
 	if err != nil {
-		return fmt.Errorf("packaging: resolve symlinks: %w", err)
+		return "", fmt.Errorf("packaging: resolve symlinks: %w", err)
+	}
+	return srcPath, nil
+}
+
+func (ins *Installer) copyBinary() error {
+	if ins.bundle != nil {
+		return ins.installBundleBinary()
+	}
+
+	srcPath, err := sourceBinaryPath()
+	if err != nil {
+		return err
 	}
 
 	dstPath := ins.cfg.BinaryPath
@@ -202,6 +400,26 @@ func (ins *Installer) copyBinary() error {
 	return nil
 }
 
+// installBundleBinary writes the bundle binary for this platform to
+// BinaryPath.
+func (ins *Installer) installBundleBinary() error {
+	data, err := ins.bundle.Binary(runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ins.cfg.BinaryPath), 0o755); err != nil {
+		return fmt.Errorf("packaging: create binary directory: %w", err)
+	}
+	if err := os.WriteFile(ins.cfg.BinaryPath, data, 0o755); err != nil {
+		return fmt.Errorf("packaging: write binary: %w", err)
+	}
+	if err := os.Chmod(ins.cfg.BinaryPath, 0o755); err != nil {
+		return fmt.Errorf("packaging: chmod binary: %w", err)
+	}
+	ins.logger.Info("binary installed from bundle", "name", BinaryName(runtime.GOOS, runtime.GOARCH), "dst", ins.cfg.BinaryPath)
+	return nil
+}
+
 func (ins *Installer) writeToken() error {
 	var tokenValue string
 
@@ -232,6 +450,50 @@ func (ins *Installer) writeToken() error {
 	return nil
 }
 
+// serviceUser is the resolved account of a rootless install.
+type serviceUser struct {
+	uid int
+	gid int
+}
+
+// lookupServiceUser resolves the rootless service account. The account must
+// exist; the installer does not create users.
+func lookupServiceUser(name string) (*serviceUser, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("packaging: rootless: lookup user %q (create it with 'useradd --system %s'): %w", name, name, err)
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return nil, fmt.Errorf("packaging: rootless: parse uid of %q: %w", name, err)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return nil, fmt.Errorf("packaging: rootless: parse gid of %q: %w", name, err)
+	}
+	return &serviceUser{uid: uid, gid: gid}, nil
+}
+
+// chownToServiceUser hands the data directory, runtime directory, and
+// bootstrap token to the rootless service account.
+func (ins *Installer) chownToServiceUser(owner *serviceUser) error {
+	paths := []string{
+		ins.cfg.DataDir,
+		ins.cfg.RunDir,
+		filepath.Join(ins.cfg.ConfigDir, "bootstrap-token"),
+	}
+	for _, p := range paths {
+		if err := os.Chown(p, owner.uid, owner.gid); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("packaging: rootless: chown %s: %w", p, err)
+		}
+		ins.logger.Info("ownership set", "path", p, "user", ins.cfg.User)
+	}
+	return nil
+}
+
 func validateInstallToken(token string) error {
 	if len(token) > maxTokenLength {
 		return fmt.Errorf("packaging: token exceeds maximum length of %d bytes", maxTokenLength)
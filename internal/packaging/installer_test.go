@@ -5,6 +5,7 @@ import (
 	"io"
 	"log/slog"
 	"os"
+	"os/user"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -19,11 +20,13 @@ type mockSystemdController struct {
 	enableErr       error
 	disableErr      error
 	stopErr         error
+	restartErr      error
 
 	daemonReloadCalls int
 	enableCalls       []string
 	disableCalls      []string
 	stopCalls         []string
+	restartCalls      []string
 }
 
 func (m *mockSystemdController) IsAvailable() bool { return m.available }
@@ -49,6 +52,11 @@ func (m *mockSystemdController) Stop(service string) error {
 	return m.stopErr
 }
 
+func (m *mockSystemdController) Restart(service string) error {
+	m.restartCalls = append(m.restartCalls, service)
+	return m.restartErr
+}
+
 // --- Mock RootChecker ---
 
 type mockRootChecker struct {
@@ -89,7 +97,12 @@ func newTestInstaller(t *testing.T, cfg InstallConfig, systemd *mockSystemdContr
 		cfg.ServiceName = "plexd"
 	}
 
-	return NewInstaller(cfg, systemd, root, testLogger()), tmpDir
+	ins := NewInstaller(cfg, NewSystemdInitSystem(systemd), root, testLogger())
+	// Preflight checks and security modules depend on the host; preflight_test.go
+	// and security_test.go cover them.
+	ins.SetPreflightChecks(nil)
+	ins.SetSecurityModules(nil)
+	return ins, tmpDir
 }
 
 // --- Install tests ---
@@ -638,3 +651,293 @@ func TestInstall_WithAPIBaseURL(t *testing.T) {
 		t.Errorf("default config missing API URL, got:\n%s", content)
 	}
 }
+
+func TestInstall_WritesHostnameAndMetadata(t *testing.T) {
+	systemd := &mockSystemdController{available: true}
+	root := &mockRootChecker{isRoot: true}
+	cfg := InstallConfig{
+		Hostname: "web-1",
+		Metadata: map[string]string{"env": "prod"},
+	}
+	ins, tmpDir := newTestInstaller(t, cfg, systemd, root)
+
+	if err := ins.Install(); err != nil {
+		t.Fatalf("Install() = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "etc", "plexd", "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	if !strings.Contains(content, `hostname: "web-1"`) || !strings.Contains(content, `"env": "prod"`) {
+		t.Errorf("default config missing hostname or metadata, got:\n%s", content)
+	}
+}
+
+// --- OpenRC installer tests ---
+
+// availableInitSystem overrides IsAvailable so real init systems can be
+// exercised by the Installer regardless of the test host.
+type availableInitSystem struct {
+	InitSystem
+}
+
+func (availableInitSystem) IsAvailable() bool { return true }
+
+func TestInstall_OpenRCWritesInitScript(t *testing.T) {
+	rec := &commandRecorder{}
+	initSys := availableInitSystem{&openrcInitSystem{run: rec.run}}
+	tmpDir := t.TempDir()
+	cfg := InstallConfig{
+		BinaryPath:    filepath.Join(tmpDir, "usr", "local", "bin", "plexd"),
+		ConfigDir:     filepath.Join(tmpDir, "etc", "plexd"),
+		DataDir:       filepath.Join(tmpDir, "var", "lib", "plexd"),
+		RunDir:        filepath.Join(tmpDir, "var", "run", "plexd"),
+		InitScriptDir: filepath.Join(tmpDir, "etc", "init.d"),
+	}
+	ins := NewInstaller(cfg, initSys, &mockRootChecker{isRoot: true}, testLogger())
+	ins.SetPreflightChecks(nil)
+	ins.SetSecurityModules(nil)
+
+	if err := ins.Install(); err != nil {
+		t.Fatalf("Install() = %v", err)
+	}
+
+	scriptPath := filepath.Join(tmpDir, "etc", "init.d", "plexd")
+	info, err := os.Stat(scriptPath)
+	if err != nil {
+		t.Fatalf("Stat(%q) = %v", scriptPath, err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o755 {
+		t.Errorf("init script perm = %04o, want 0755", perm)
+	}
+	data, err := os.ReadFile(scriptPath)
+	if err != nil {
+		t.Fatalf("ReadFile(%q) = %v", scriptPath, err)
+	}
+	if !strings.HasPrefix(string(data), "#!/sbin/openrc-run") {
+		t.Errorf("init script missing openrc-run shebang, got:\n%s", data)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "etc", "systemd")); err == nil {
+		t.Error("systemd unit directory created for OpenRC install")
+	}
+
+	if err := ins.Uninstall(false); err != nil {
+		t.Fatalf("Uninstall(false) = %v", err)
+	}
+	if _, err := os.Stat(scriptPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("init script still exists after uninstall: %v", err)
+	}
+	want := []string{"rc-service plexd stop", "rc-update del plexd default"}
+	if strings.Join(rec.calls, "|") != strings.Join(want, "|") {
+		t.Errorf("commands = %v, want %v", rec.calls, want)
+	}
+}
+
+func TestInstall_RejectsUnavailableInitSystem(t *testing.T) {
+	ins := NewInstaller(InstallConfig{}, &openrcInitSystem{run: (&commandRecorder{}).run}, &mockRootChecker{isRoot: true}, testLogger())
+	if ins.init.IsAvailable() {
+		t.Skip("OpenRC is available on this host")
+	}
+
+	err := ins.Install()
+	if err == nil {
+		t.Fatal("Install() = nil, want error for unavailable init system")
+	}
+	if !strings.Contains(err.Error(), "openrc is not available") {
+		t.Errorf("Install() error = %q, want message about openrc", err)
+	}
+}
+
+// --- Rootless installer tests ---
+
+func currentUserName(t *testing.T) string {
+	t.Helper()
+	u, err := user.Current()
+	if err != nil {
+		t.Skipf("lookup current user: %v", err)
+	}
+	return u.Username
+}
+
+func TestInstall_RootlessWritesHelperUnits(t *testing.T) {
+	systemd := &mockSystemdController{available: true}
+	root := &mockRootChecker{isRoot: true}
+	ins, tmpDir := newTestInstaller(t, InstallConfig{
+		Rootless:   true,
+		User:       currentUserName(t),
+		TokenValue: "test-token",
+	}, systemd, root)
+
+	if err := ins.Install(); err != nil {
+		t.Fatalf("Install() = %v", err)
+	}
+
+	unitDir := filepath.Join(tmpDir, "etc", "systemd", "system")
+	for _, name := range []string{"plexd.service", "plexd-helper.socket", "plexd-helper.service"} {
+		if _, err := os.Stat(filepath.Join(unitDir, name)); err != nil {
+			t.Errorf("Stat(%s) = %v", name, err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(unitDir, "plexd.service"))
+	if err != nil {
+		t.Fatalf("ReadFile(plexd.service) = %v", err)
+	}
+	if !strings.Contains(string(data), "User="+ins.cfg.User) {
+		t.Error("rootless unit does not run as the service user")
+	}
+
+	if err := ins.Uninstall(false); err != nil {
+		t.Fatalf("Uninstall(false) = %v", err)
+	}
+	for _, name := range []string{"plexd.service", "plexd-helper.socket", "plexd-helper.service"} {
+		if _, err := os.Stat(filepath.Join(unitDir, name)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s still exists after uninstall: %v", name, err)
+		}
+	}
+	wantStops := []string{"plexd", "plexd-helper.socket", "plexd-helper.service"}
+	if strings.Join(systemd.stopCalls, "|") != strings.Join(wantStops, "|") {
+		t.Errorf("Stop calls = %v, want %v", systemd.stopCalls, wantStops)
+	}
+}
+
+func TestInstall_RootlessUnknownUser(t *testing.T) {
+	systemd := &mockSystemdController{available: true}
+	root := &mockRootChecker{isRoot: true}
+	ins, tmpDir := newTestInstaller(t, InstallConfig{Rootless: true, User: "plexd-no-such-user"}, systemd, root)
+
+	err := ins.Install()
+	if err == nil {
+		t.Fatal("Install() = nil, want error for unknown user")
+	}
+	if !strings.Contains(err.Error(), "lookup user") {
+		t.Errorf("Install() error = %q, want lookup error", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "etc", "plexd")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("directories created before user lookup failed")
+	}
+}
+
+func TestInstall_RootlessRequiresSystemd(t *testing.T) {
+	initSys := availableInitSystem{&openrcInitSystem{run: (&commandRecorder{}).run}}
+	ins := NewInstaller(InstallConfig{Rootless: true}, initSys, &mockRootChecker{isRoot: true}, testLogger())
+
+	err := ins.Install()
+	if err == nil {
+		t.Fatal("Install() = nil, want error")
+	}
+	if !strings.Contains(err.Error(), "rootless mode requires systemd") {
+		t.Errorf("Install() error = %q", err)
+	}
+}
+
+// --- ServiceRegistrar installer tests ---
+
+// mockRegistrarInitSystem is an InitSystem that registers services through
+// an API, like the Windows Service Control Manager.
+type mockRegistrarInitSystem struct {
+	availableInitSystem
+	registered  map[string]bool
+	registerErr error
+	stopCalls   []string
+}
+
+func newMockRegistrarInitSystem() *mockRegistrarInitSystem {
+	return &mockRegistrarInitSystem{
+		availableInitSystem: availableInitSystem{NewSCMInitSystem()},
+		registered:          map[string]bool{},
+	}
+}
+
+func (m *mockRegistrarInitSystem) Reload() error        { return nil }
+func (m *mockRegistrarInitSystem) Disable(string) error { return nil }
+
+func (m *mockRegistrarInitSystem) Stop(service string) error {
+	m.stopCalls = append(m.stopCalls, service)
+	return nil
+}
+
+func (m *mockRegistrarInitSystem) Register(cfg InstallConfig) error {
+	if m.registerErr != nil {
+		return m.registerErr
+	}
+	m.registered[cfg.ServiceName] = true
+	return nil
+}
+
+func (m *mockRegistrarInitSystem) Unregister(service string) error {
+	delete(m.registered, service)
+	return nil
+}
+
+func (m *mockRegistrarInitSystem) IsRegistered(service string) bool {
+	return m.registered[service]
+}
+
+func TestInstall_RegistrarRegistersService(t *testing.T) {
+	initSys := newMockRegistrarInitSystem()
+	tmpDir := t.TempDir()
+	cfg := InstallConfig{
+		BinaryPath: filepath.Join(tmpDir, "bin", "plexd"),
+		ConfigDir:  filepath.Join(tmpDir, "config"),
+		DataDir:    filepath.Join(tmpDir, "data"),
+		RunDir:     filepath.Join(tmpDir, "run"),
+	}
+	ins := NewInstaller(cfg, initSys, &mockRootChecker{isRoot: true}, testLogger())
+	ins.SetPreflightChecks(nil)
+	ins.SetSecurityModules(nil)
+
+	if err := ins.Install(); err != nil {
+		t.Fatalf("Install() = %v", err)
+	}
+	if !initSys.registered["plexd"] {
+		t.Error("service not registered after Install()")
+	}
+
+	if err := ins.Uninstall(false); err != nil {
+		t.Fatalf("Uninstall(false) = %v", err)
+	}
+	if initSys.registered["plexd"] {
+		t.Error("service still registered after Uninstall()")
+	}
+	if len(initSys.stopCalls) != 1 {
+		t.Errorf("Stop calls = %v, want [plexd]", initSys.stopCalls)
+	}
+}
+
+func TestInstall_RegistrarError(t *testing.T) {
+	initSys := newMockRegistrarInitSystem()
+	initSys.registerErr = errors.New("access denied")
+	tmpDir := t.TempDir()
+	cfg := InstallConfig{
+		BinaryPath: filepath.Join(tmpDir, "bin", "plexd"),
+		ConfigDir:  filepath.Join(tmpDir, "config"),
+		DataDir:    filepath.Join(tmpDir, "data"),
+		RunDir:     filepath.Join(tmpDir, "run"),
+	}
+	ins := NewInstaller(cfg, initSys, &mockRootChecker{isRoot: true}, testLogger())
+	ins.SetPreflightChecks(nil)
+	ins.SetSecurityModules(nil)
+
+	err := ins.Install()
+	if err == nil {
+		t.Fatal("Install() = nil, want error")
+	}
+	if !strings.Contains(err.Error(), "register service") {
+		t.Errorf("Install() error = %q, want message about register service", err)
+	}
+}
+
+func TestUninstall_RegistrarNotRegistered(t *testing.T) {
+	initSys := newMockRegistrarInitSystem()
+	ins := NewInstaller(InstallConfig{BinaryPath: filepath.Join(t.TempDir(), "plexd")}, initSys, &mockRootChecker{isRoot: true}, testLogger())
+	ins.SetSecurityModules(nil)
+
+	if err := ins.Uninstall(false); err != nil {
+		t.Fatalf("Uninstall(false) = %v", err)
+	}
+	if len(initSys.stopCalls) != 0 {
+		t.Errorf("Stop called for unregistered service: %v", initSys.stopCalls)
+	}
+}
@@ -19,6 +19,9 @@ type SystemdController interface {
 	// Stop stops the named service. Returns nil if the service is not running.
 	Stop(service string) error
 
+	// Restart restarts the named service, starting it if it is not running.
+	Restart(service string) error
+
 	// IsActive returns true if the named service is currently running.
 	IsActive(service string) bool
 }
@@ -28,3 +31,80 @@ type RootChecker interface {
 	// IsRoot returns true if the current process has root privileges.
 	IsRoot() bool
 }
+
+// InitSystem abstracts the host service manager (systemd, OpenRC, or SysV
+// init) so the Installer can register plexd with whichever one is running.
+// Service registration commands must be idempotent.
+type InitSystem interface {
+	// Name returns the init system identifier ("systemd", "openrc", or "sysv").
+	Name() string
+
+	// IsAvailable returns true if the init system manages services on this host.
+	IsAvailable() bool
+
+	// ServiceFile returns the unit file or init script to install for cfg.
+	ServiceFile(cfg InstallConfig) ServiceFile
+
+	// Reload makes the init system pick up added or removed service files.
+	Reload() error
+
+	// Enable enables the named service to start on boot.
+	Enable(service string) error
+
+	// Disable disables the named service from starting on boot.
+	Disable(service string) error
+
+	// Stop stops the named service. Returns nil if the service is not running.
+	Stop(service string) error
+
+	// Restart restarts the named service, starting it if it is not running.
+	Restart(service string) error
+}
+
+// AuxiliaryServiceFiler is implemented by init systems that install
+// additional service files next to the main one, such as the privileged
+// helper units of a rootless install. The Installer writes them after the
+// main service file and removes them on uninstall.
+type AuxiliaryServiceFiler interface {
+	// AuxiliaryServiceFiles returns the additional files to install for cfg.
+	// It returns nil when cfg needs none.
+	AuxiliaryServiceFiles(cfg InstallConfig) []ServiceFile
+}
+
+// ServiceRegistrar is implemented by init systems that register services
+// through an API instead of a service file, such as the Windows Service
+// Control Manager. The Installer calls Register instead of writing the
+// service file and Unregister instead of removing it.
+type ServiceRegistrar interface {
+	// Register creates or updates the service described by cfg.
+	Register(cfg InstallConfig) error
+
+	// Unregister deletes the named service. Returns nil if it does not exist.
+	Unregister(service string) error
+
+	// IsRegistered returns true if the named service exists.
+	IsRegistered(service string) bool
+}
+
+// SecurityModule is a Linux security module, such as SELinux or AppArmor,
+// that the Installer loads a plexd policy into so the agent runs confined
+// instead of requiring permissive mode.
+type SecurityModule interface {
+	// Name returns the security module identifier ("selinux" or "apparmor").
+	Name() string
+
+	// IsEnabled returns true if the module is active and its tools are
+	// installed.
+	IsEnabled() bool
+
+	// PolicyFile returns the policy module or profile to install for cfg.
+	PolicyFile(cfg InstallConfig) ServiceFile
+
+	// Load loads the installed policy file and relabels the files of cfg
+	// where the module requires it.
+	Load(cfg InstallConfig) error
+
+	// Unload removes the policy from the kernel. The Installer calls it
+	// only while the policy file exists.
+	Unload(cfg InstallConfig) error
+}
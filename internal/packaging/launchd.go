@@ -0,0 +1,139 @@
+package packaging
+
+import (
+	"fmt"
+	"html"
+	"path/filepath"
+	"runtime"
+)
+
+// DefaultLaunchdLabelPrefix is prepended to the service name to form the
+// launchd job label.
+const DefaultLaunchdLabelPrefix = "io.plexsphere."
+
+// DefaultLaunchdLogDir is the directory the launchd job redirects service
+// output to.
+const DefaultLaunchdLogDir = "/Library/Logs"
+
+// launchdInitSystem implements InitSystem for macOS launchd using launchctl.
+type launchdInitSystem struct {
+	run commandRunner
+	dir string
+}
+
+// NewLaunchdInitSystem returns an InitSystem that installs a property list
+// in InstallConfig.LaunchDaemonDir and manages the job in the system domain.
+func NewLaunchdInitSystem() InitSystem {
+	return &launchdInitSystem{run: runCommand, dir: DefaultLaunchDaemonDir}
+}
+
+func (s *launchdInitSystem) Name() string { return InitSystemLaunchd }
+
+// IsAvailable returns true on macOS when launchctl is installed.
+func (s *launchdInitSystem) IsAvailable() bool {
+	return runtime.GOOS == "darwin" && commandExists("launchctl")
+}
+
+func (s *launchdInitSystem) ServiceFile(cfg InstallConfig) ServiceFile {
+	cfg.ApplyDefaults()
+	return ServiceFile{
+		Path:    launchdPlistPath(cfg.LaunchDaemonDir, cfg.ServiceName),
+		Content: GenerateLaunchdPlist(cfg),
+		Mode:    0o644,
+	}
+}
+
+// Reload is a no-op: launchd reads the property list on bootstrap.
+func (s *launchdInitSystem) Reload() error { return nil }
+
+// Enable marks the job enabled and loads its property list from
+// DefaultLaunchDaemonDir into the system domain. The job starts immediately
+// because the property list sets RunAtLoad.
+func (s *launchdInitSystem) Enable(service string) error {
+	if err := s.run("launchctl", "enable", launchdTarget(service)); err != nil {
+		return err
+	}
+	return s.run("launchctl", "bootstrap", "system", launchdPlistPath(s.dir, service))
+}
+
+func (s *launchdInitSystem) Disable(service string) error {
+	return s.run("launchctl", "disable", launchdTarget(service))
+}
+
+// Stop unloads the job from the system domain, which terminates the process.
+func (s *launchdInitSystem) Stop(service string) error {
+	return s.run("launchctl", "bootout", launchdTarget(service))
+}
+
+// Restart kills the running job and starts it again.
+func (s *launchdInitSystem) Restart(service string) error {
+	return s.run("launchctl", "kickstart", "-k", launchdTarget(service))
+}
+
+// LaunchdLabel returns the launchd job label for the given service name.
+func LaunchdLabel(service string) string {
+	return DefaultLaunchdLabelPrefix + service
+}
+
+func launchdTarget(service string) string {
+	return "system/" + LaunchdLabel(service)
+}
+
+func launchdPlistPath(dir, service string) string {
+	return filepath.Join(dir, LaunchdLabel(service)+".plist")
+}
+
+// GenerateLaunchdPlist produces a launchd property list for the plexd
+// service. launchd restarts the daemon whenever it exits, throttled to one
+// launch every 5 seconds. launchd cannot source an environment file, so
+// settings must live in config.yaml.
+// It calls cfg.ApplyDefaults() to fill in zero-valued fields before generating the output.
+func GenerateLaunchdPlist(cfg InstallConfig) string {
+	cfg.ApplyDefaults()
+
+	configPath := filepath.Join(cfg.ConfigDir, "config.yaml")
+	logPath := filepath.Join(DefaultLaunchdLogDir, cfg.ServiceName+".log")
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<!-- Generated by plexd install. -->
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%[1]s</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>%[2]s</string>
+		<string>up</string>
+		<string>--config</string>
+		<string>%[3]s</string>
+	</array>
+	<key>WorkingDirectory</key>
+	<string>%[4]s</string>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+	<key>ThrottleInterval</key>
+	<integer>5</integer>
+	<key>ExitTimeOut</key>
+	<integer>30</integer>
+	<key>SoftResourceLimits</key>
+	<dict>
+		<key>NumberOfFiles</key>
+		<integer>65536</integer>
+	</dict>
+	<key>StandardOutPath</key>
+	<string>%[5]s</string>
+	<key>StandardErrorPath</key>
+	<string>%[5]s</string>
+</dict>
+</plist>
+`,
+		html.EscapeString(LaunchdLabel(cfg.ServiceName)),
+		html.EscapeString(cfg.BinaryPath),
+		html.EscapeString(configPath),
+		html.EscapeString(cfg.DataDir),
+		html.EscapeString(logPath),
+	)
+}
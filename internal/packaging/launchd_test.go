@@ -0,0 +1,104 @@
+package packaging
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestGenerateLaunchdPlist_DefaultConfig(t *testing.T) {
+	output := GenerateLaunchdPlist(InstallConfig{
+		BinaryPath: "/usr/local/bin/plexd",
+		ConfigDir:  "/usr/local/etc/plexd",
+		DataDir:    "/usr/local/var/lib/plexd",
+	})
+
+	want := []string{
+		`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN"`,
+		"<string>io.plexsphere.plexd</string>",
+		"<string>/usr/local/bin/plexd</string>",
+		"<string>up</string>",
+		"<string>/usr/local/etc/plexd/config.yaml</string>",
+		"<key>WorkingDirectory</key>\n\t<string>/usr/local/var/lib/plexd</string>",
+		"<key>RunAtLoad</key>\n\t<true/>",
+		"<key>KeepAlive</key>\n\t<true/>",
+		"<key>ThrottleInterval</key>\n\t<integer>5</integer>",
+		"<key>NumberOfFiles</key>\n\t\t<integer>65536</integer>",
+		"<key>StandardOutPath</key>\n\t<string>/Library/Logs/plexd.log</string>",
+		"<key>StandardErrorPath</key>\n\t<string>/Library/Logs/plexd.log</string>",
+	}
+	for _, w := range want {
+		if !strings.Contains(output, w) {
+			t.Errorf("output missing %q", w)
+		}
+	}
+}
+
+func TestGenerateLaunchdPlist_EscapesAndParses(t *testing.T) {
+	output := GenerateLaunchdPlist(InstallConfig{
+		BinaryPath:  "/Applications/R&D/plexd",
+		ServiceName: "plexd-dev",
+	})
+
+	if !strings.Contains(output, "<string>/Applications/R&amp;D/plexd</string>") {
+		t.Error("binary path not XML-escaped")
+	}
+	if !strings.Contains(output, "<string>io.plexsphere.plexd-dev</string>") {
+		t.Error("label does not use the service name")
+	}
+
+	dec := xml.NewDecoder(strings.NewReader(output))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("plist is not well-formed XML: %v", err)
+		}
+	}
+}
+
+func TestLaunchdInitSystem_Commands(t *testing.T) {
+	rec := &commandRecorder{}
+	s := &launchdInitSystem{run: rec.run, dir: DefaultLaunchDaemonDir}
+
+	if err := s.Reload(); err != nil {
+		t.Fatalf("Reload() = %v", err)
+	}
+	if err := s.Enable("plexd"); err != nil {
+		t.Fatalf("Enable() = %v", err)
+	}
+	if err := s.Stop("plexd"); err != nil {
+		t.Fatalf("Stop() = %v", err)
+	}
+	if err := s.Disable("plexd"); err != nil {
+		t.Fatalf("Disable() = %v", err)
+	}
+
+	want := []string{
+		"launchctl enable system/io.plexsphere.plexd",
+		"launchctl bootstrap system /Library/LaunchDaemons/io.plexsphere.plexd.plist",
+		"launchctl bootout system/io.plexsphere.plexd",
+		"launchctl disable system/io.plexsphere.plexd",
+	}
+	if strings.Join(rec.calls, "|") != strings.Join(want, "|") {
+		t.Errorf("commands = %v, want %v", rec.calls, want)
+	}
+}
+
+func TestLaunchdInitSystem_ServiceFile(t *testing.T) {
+	s := &launchdInitSystem{run: (&commandRecorder{}).run, dir: DefaultLaunchDaemonDir}
+
+	svc := s.ServiceFile(InstallConfig{LaunchDaemonDir: "/tmp/LaunchDaemons", ServiceName: "plexd-dev"})
+	if svc.Path != "/tmp/LaunchDaemons/io.plexsphere.plexd-dev.plist" {
+		t.Errorf("ServiceFile().Path = %q", svc.Path)
+	}
+	if svc.Mode != 0o644 {
+		t.Errorf("ServiceFile().Mode = %04o, want 0644", svc.Mode)
+	}
+	if !strings.Contains(svc.Content, "<plist version=\"1.0\">") {
+		t.Error("ServiceFile().Content is not a property list")
+	}
+}
@@ -0,0 +1,99 @@
+package packaging
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// openrcInitSystem implements InitSystem for OpenRC (Alpine, Gentoo) using
+// rc-update and rc-service.
+type openrcInitSystem struct {
+	run commandRunner
+}
+
+// NewOpenRCInitSystem returns an InitSystem that installs an openrc-run
+// script in InstallConfig.InitScriptDir.
+func NewOpenRCInitSystem() InitSystem {
+	return &openrcInitSystem{run: runCommand}
+}
+
+func (s *openrcInitSystem) Name() string { return InitSystemOpenRC }
+
+// IsAvailable returns true if openrc-run is installed and OpenRC has
+// initialised its runtime state directory.
+func (s *openrcInitSystem) IsAvailable() bool {
+	return commandExists("openrc-run") && dirExists("/run/openrc")
+}
+
+func (s *openrcInitSystem) ServiceFile(cfg InstallConfig) ServiceFile {
+	cfg.ApplyDefaults()
+	return ServiceFile{
+		Path:    filepath.Join(cfg.InitScriptDir, cfg.ServiceName),
+		Content: GenerateOpenRCScript(cfg),
+		Mode:    0o755,
+	}
+}
+
+// Reload is a no-op: OpenRC reads init scripts on every invocation.
+func (s *openrcInitSystem) Reload() error { return nil }
+
+func (s *openrcInitSystem) Enable(service string) error {
+	return s.run("rc-update", "add", service, "default")
+}
+
+func (s *openrcInitSystem) Disable(service string) error {
+	return s.run("rc-update", "del", service, "default")
+}
+
+func (s *openrcInitSystem) Stop(service string) error {
+	return s.run("rc-service", service, "stop")
+}
+
+func (s *openrcInitSystem) Restart(service string) error {
+	return s.run("rc-service", service, "restart")
+}
+
+// GenerateOpenRCScript produces an openrc-run script for the plexd service.
+// The service runs under supervise-daemon, which restarts it on failure
+// with the same crash-loop limits as the systemd unit.
+// It calls cfg.ApplyDefaults() to fill in zero-valued fields before generating the output.
+func GenerateOpenRCScript(cfg InstallConfig) string {
+	cfg.ApplyDefaults()
+
+	configPath := filepath.Join(cfg.ConfigDir, "config.yaml")
+	envPath := filepath.Join(cfg.ConfigDir, "environment")
+	pidPath := filepath.Join(cfg.RunDir, cfg.ServiceName+".pid")
+	logPath := filepath.Join(DefaultLogDir, cfg.ServiceName+".log")
+
+	return fmt.Sprintf(`#!/sbin/openrc-run
+# Generated by plexd install.
+
+name="%[1]s"
+description="plexd node agent"
+command="%[2]s"
+command_args="up --config %[3]s"
+pidfile="%[4]s"
+supervisor=supervise-daemon
+respawn_delay=5
+respawn_max=5
+respawn_period=60
+rc_ulimit="-n 65536"
+output_log="%[5]s"
+error_log="%[5]s"
+
+depend() {
+	need net
+	after firewall
+}
+
+start_pre() {
+	checkpath --directory --mode 0755 "%[6]s"
+	checkpath --directory --mode 0700 "%[7]s"
+	if [ -f "%[8]s" ]; then
+		set -a
+		. "%[8]s"
+		set +a
+	fi
+}
+`, cfg.ServiceName, cfg.BinaryPath, configPath, pidPath, logPath, cfg.RunDir, cfg.DataDir, envPath)
+}
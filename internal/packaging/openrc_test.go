@@ -0,0 +1,53 @@
+package packaging
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateOpenRCScript_DefaultConfig(t *testing.T) {
+	output := GenerateOpenRCScript(InstallConfig{})
+
+	want := []string{
+		"#!/sbin/openrc-run",
+		`command="/usr/local/bin/plexd"`,
+		`command_args="up --config /etc/plexd/config.yaml"`,
+		`pidfile="/var/run/plexd/plexd.pid"`,
+		"supervisor=supervise-daemon",
+		"respawn_delay=5",
+		"respawn_max=5",
+		"respawn_period=60",
+		`rc_ulimit="-n 65536"`,
+		`output_log="/var/log/plexd.log"`,
+		"need net",
+		`checkpath --directory --mode 0700 "/var/lib/plexd"`,
+		`. "/etc/plexd/environment"`,
+	}
+	for _, w := range want {
+		if !strings.Contains(output, w) {
+			t.Errorf("output missing %q", w)
+		}
+	}
+}
+
+func TestGenerateOpenRCScript_CustomPaths(t *testing.T) {
+	output := GenerateOpenRCScript(InstallConfig{
+		BinaryPath:  "/opt/plexd/bin/plexd",
+		ConfigDir:   "/opt/plexd/etc",
+		RunDir:      "/run/plexd",
+		ServiceName: "plexd-edge",
+	})
+
+	want := []string{
+		`name="plexd-edge"`,
+		`command="/opt/plexd/bin/plexd"`,
+		`command_args="up --config /opt/plexd/etc/config.yaml"`,
+		`pidfile="/run/plexd/plexd-edge.pid"`,
+		`output_log="/var/log/plexd-edge.log"`,
+	}
+	for _, w := range want {
+		if !strings.Contains(output, w) {
+			t.Errorf("output missing %q", w)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build darwin
+
+package packaging
+
+// DefaultBinaryPath is the default path to install the plexd binary.
+const DefaultBinaryPath = "/usr/local/bin/plexd"
+
+// DefaultConfigDir is the default configuration directory.
+const DefaultConfigDir = "/usr/local/etc/plexd"
+
+// DefaultDataDir is the default data directory.
+const DefaultDataDir = "/usr/local/var/lib/plexd"
+
+// DefaultRunDir is the default runtime directory.
+const DefaultRunDir = "/var/run/plexd"
@@ -0,0 +1,15 @@
+//go:build !windows && !darwin
+
+package packaging
+
+// DefaultBinaryPath is the default path to install the plexd binary.
+const DefaultBinaryPath = "/usr/local/bin/plexd"
+
+// DefaultConfigDir is the default configuration directory.
+const DefaultConfigDir = "/etc/plexd"
+
+// DefaultDataDir is the default data directory.
+const DefaultDataDir = "/var/lib/plexd"
+
+// DefaultRunDir is the default runtime directory.
+const DefaultRunDir = "/var/run/plexd"
@@ -0,0 +1,15 @@
+//go:build windows
+
+package packaging
+
+// DefaultBinaryPath is the default path to install the plexd binary.
+const DefaultBinaryPath = `C:\Program Files\plexd\plexd.exe`
+
+// DefaultConfigDir is the default configuration directory.
+const DefaultConfigDir = `C:\ProgramData\plexd`
+
+// DefaultDataDir is the default data directory.
+const DefaultDataDir = `C:\ProgramData\plexd\data`
+
+// DefaultRunDir is the default runtime directory.
+const DefaultRunDir = `C:\ProgramData\plexd\run`
@@ -0,0 +1,281 @@
+package packaging
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// DefaultWireGuardPort is the WireGuard listen port the agent uses unless
+// the control plane assigns another one.
+const DefaultWireGuardPort = 51820
+
+// PreflightStatus is the outcome of a preflight check.
+type PreflightStatus string
+
+const (
+	// PreflightOK means the check passed.
+	PreflightOK PreflightStatus = "ok"
+	// PreflightWarn means the install can proceed, but the agent may not
+	// work as expected.
+	PreflightWarn PreflightStatus = "warn"
+	// PreflightFail means the agent cannot run on this host. Install aborts.
+	PreflightFail PreflightStatus = "fail"
+)
+
+// PreflightResult is the result of one preflight check.
+type PreflightResult struct {
+	Name   string          `json:"name"`
+	Status PreflightStatus `json:"status"`
+	Detail string          `json:"detail"`
+}
+
+// PreflightCheck inspects the host before anything is installed. Run must
+// not change the host.
+type PreflightCheck struct {
+	Name string
+	Run  func() PreflightResult
+}
+
+// PreflightReport holds the results of all preflight checks.
+type PreflightReport []PreflightResult
+
+// Failed reports whether any check failed.
+func (r PreflightReport) Failed() bool {
+	for _, res := range r {
+		if res.Status == PreflightFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes the report as one line per check.
+func (r PreflightReport) Write(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "Preflight checks:"); err != nil {
+		return err
+	}
+	for _, res := range r {
+		if _, err := fmt.Fprintf(w, "  %-6s %s: %s\n", "["+string(res.Status)+"]", res.Name, res.Detail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DefaultPreflightChecks returns the checks Install runs unless replaced
+// with Installer.SetPreflightChecks: the platform checks (kernel version,
+// WireGuard, SELinux, and AppArmor on Linux), the WireGuard UDP port, and
+// the TCP ports in cfg.IngressPorts.
+func DefaultPreflightChecks(cfg InstallConfig) []PreflightCheck {
+	checks := platformPreflightChecks()
+	checks = append(checks, PreflightCheck{
+		Name: fmt.Sprintf("port %d/udp", DefaultWireGuardPort),
+		Run:  func() PreflightResult { return checkPort("udp", DefaultWireGuardPort, "WireGuard") },
+	})
+	for _, port := range cfg.IngressPorts {
+		checks = append(checks, PreflightCheck{
+			Name: fmt.Sprintf("port %d/tcp", port),
+			Run:  func() PreflightResult { return checkPort("tcp", port, "ingress") },
+		})
+	}
+	return checks
+}
+
+// runPreflight runs checks in order.
+func runPreflight(checks []PreflightCheck) PreflightReport {
+	report := make(PreflightReport, 0, len(checks))
+	for _, c := range checks {
+		res := c.Run()
+		if res.Name == "" {
+			res.Name = c.Name
+		}
+		report = append(report, res)
+	}
+	return report
+}
+
+// checkPort binds port on all addresses to find a conflicting service.
+// A port in use is a warning, because it may be held by a running plexd
+// that is being reinstalled.
+func checkPort(network string, port int, use string) PreflightResult {
+	res := PreflightResult{Name: fmt.Sprintf("port %d/%s", port, network)}
+	addr := ":" + strconv.Itoa(port)
+	var closer io.Closer
+	var err error
+	if network == "udp" {
+		closer, err = net.ListenPacket(network, addr)
+	} else {
+		closer, err = net.Listen(network, addr)
+	}
+	switch {
+	case err == nil:
+		closer.Close()
+		res.Status = PreflightOK
+		res.Detail = "free"
+	case errors.Is(err, syscall.EADDRINUSE):
+		res.Status = PreflightWarn
+		res.Detail = fmt.Sprintf("in use by another process; the %s listener will fail unless it is plexd itself", use)
+	default:
+		res.Status = PreflightWarn
+		res.Detail = fmt.Sprintf("cannot bind: %v", err)
+	}
+	return res
+}
+
+// minKernel is the oldest kernel the WireGuard module supports.
+var minKernel = [2]int{3, 10}
+
+// wireGuardInTree is the first kernel with WireGuard in the mainline tree.
+var wireGuardInTree = [2]int{5, 6}
+
+// parseKernelRelease returns the major and minor version of a release such
+// as "6.1.0-18-amd64".
+func parseKernelRelease(release string) ([2]int, error) {
+	parts := strings.SplitN(strings.TrimSpace(release), ".", 3)
+	if len(parts) < 2 {
+		return [2]int{}, fmt.Errorf("unrecognized kernel release %q", release)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return [2]int{}, fmt.Errorf("unrecognized kernel release %q", release)
+	}
+	minor := parts[1]
+	if i := strings.IndexFunc(minor, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		minor = minor[:i]
+	}
+	m, err := strconv.Atoi(minor)
+	if err != nil {
+		return [2]int{}, fmt.Errorf("unrecognized kernel release %q", release)
+	}
+	return [2]int{major, m}, nil
+}
+
+// versionAtLeast reports whether v is at least min.
+func versionAtLeast(v, min [2]int) bool {
+	return v[0] > min[0] || v[0] == min[0] && v[1] >= min[1]
+}
+
+// kernelRelease reads the running kernel release from fsys rooted at /.
+func kernelRelease(fsys fs.FS) (string, error) {
+	data, err := fs.ReadFile(fsys, "proc/sys/kernel/osrelease")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// checkKernel checks that the kernel is recent enough for WireGuard.
+func checkKernel(fsys fs.FS) PreflightResult {
+	res := PreflightResult{Name: "kernel"}
+	release, err := kernelRelease(fsys)
+	if err != nil {
+		res.Status = PreflightWarn
+		res.Detail = fmt.Sprintf("cannot read kernel release: %v", err)
+		return res
+	}
+	v, err := parseKernelRelease(release)
+	if err != nil {
+		res.Status = PreflightWarn
+		res.Detail = err.Error()
+		return res
+	}
+	if !versionAtLeast(v, minKernel) {
+		res.Status = PreflightFail
+		res.Detail = fmt.Sprintf("%s is older than %d.%d, the oldest kernel WireGuard supports", release, minKernel[0], minKernel[1])
+		return res
+	}
+	res.Status = PreflightOK
+	res.Detail = release
+	return res
+}
+
+// checkWireGuard checks that the WireGuard kernel module is loaded, built
+// in, or installed.
+func checkWireGuard(fsys fs.FS) PreflightResult {
+	res := PreflightResult{Name: "wireguard", Status: PreflightOK}
+	if _, err := fs.Stat(fsys, "sys/module/wireguard"); err == nil {
+		res.Detail = "kernel module loaded"
+		return res
+	}
+	release, err := kernelRelease(fsys)
+	if err != nil {
+		res.Status = PreflightWarn
+		res.Detail = fmt.Sprintf("cannot read kernel release: %v", err)
+		return res
+	}
+	if moduleInstalled(fsys, release, "wireguard") {
+		res.Detail = "kernel module available"
+		return res
+	}
+	if v, err := parseKernelRelease(release); err == nil && versionAtLeast(v, wireGuardInTree) {
+		// Built-in modules are listed in modules.builtin, but not every
+		// distribution ships it; mainline kernels have WireGuard anyway.
+		res.Detail = "in-tree kernel module"
+		return res
+	}
+	res.Status = PreflightFail
+	res.Detail = "kernel module not found; install wireguard-dkms or a kernel with WireGuard support"
+	return res
+}
+
+// moduleInstalled reports whether modules.dep or modules.builtin of the
+// kernel release lists the module.
+func moduleInstalled(fsys fs.FS, release, module string) bool {
+	for _, list := range []string{"modules.dep", "modules.builtin"} {
+		f, err := fsys.Open("lib/modules/" + release + "/" + list)
+		if err != nil {
+			continue
+		}
+		found := false
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			path, _, _ := strings.Cut(scanner.Text(), ":")
+			name, _, _ := strings.Cut(path[strings.LastIndex(path, "/")+1:], ".")
+			if name == module {
+				found = true
+				break
+			}
+		}
+		f.Close()
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSELinux reports the SELinux mode. Enforcing mode is a warning, since
+// plexd ships no policy module and may be denied network administration.
+func checkSELinux(fsys fs.FS) PreflightResult {
+	res := PreflightResult{Name: "selinux", Status: PreflightOK}
+	data, err := fs.ReadFile(fsys, "sys/fs/selinux/enforce")
+	if err != nil {
+		res.Detail = "disabled"
+		return res
+	}
+	if strings.TrimSpace(string(data)) == "1" {
+		res.Status = PreflightWarn
+		res.Detail = "enforcing; check the audit log for denials of plexd"
+		return res
+	}
+	res.Detail = "permissive"
+	return res
+}
+
+// checkAppArmor reports whether AppArmor is enabled. No profile confines
+// plexd by default, so an enabled AppArmor is not a warning.
+func checkAppArmor(fsys fs.FS) PreflightResult {
+	res := PreflightResult{Name: "apparmor", Status: PreflightOK, Detail: "disabled"}
+	data, err := fs.ReadFile(fsys, "sys/module/apparmor/parameters/enabled")
+	if err == nil && strings.TrimSpace(string(data)) == "Y" {
+		res.Detail = "enabled; plexd is unconfined unless a local profile applies"
+	}
+	return res
+}
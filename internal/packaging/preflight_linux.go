@@ -0,0 +1,17 @@
+//go:build linux
+
+package packaging
+
+import "os"
+
+// platformPreflightChecks returns the kernel, WireGuard, SELinux, and
+// AppArmor checks.
+func platformPreflightChecks() []PreflightCheck {
+	root := os.DirFS("/")
+	return []PreflightCheck{
+		{Name: "kernel", Run: func() PreflightResult { return checkKernel(root) }},
+		{Name: "wireguard", Run: func() PreflightResult { return checkWireGuard(root) }},
+		{Name: "selinux", Run: func() PreflightResult { return checkSELinux(root) }},
+		{Name: "apparmor", Run: func() PreflightResult { return checkAppArmor(root) }},
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package packaging
+
+// platformPreflightChecks returns no checks. macOS runs WireGuard in
+// userspace, Windows through WireGuard for Windows, and neither has SELinux
+// or AppArmor.
+func platformPreflightChecks() []PreflightCheck {
+	return nil
+}
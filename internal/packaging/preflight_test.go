@@ -0,0 +1,157 @@
+package packaging
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCheckKernel(t *testing.T) {
+	tests := []struct {
+		release string
+		want    PreflightStatus
+	}{
+		{"6.1.0-18-amd64", PreflightOK},
+		{"3.10.0-1160.el7.x86_64", PreflightOK},
+		{"3.2.0-4-amd64", PreflightFail},
+		{"garbage", PreflightWarn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.release, func(t *testing.T) {
+			fsys := fstest.MapFS{"proc/sys/kernel/osrelease": {Data: []byte(tt.release + "\n")}}
+			if got := checkKernel(fsys); got.Status != tt.want {
+				t.Errorf("checkKernel(%q) = %+v, want %s", tt.release, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckWireGuard(t *testing.T) {
+	osrelease := func(r string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(r)} }
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		want PreflightStatus
+	}{
+		{"loaded", fstest.MapFS{
+			"proc/sys/kernel/osrelease": osrelease("4.19.0"),
+			"sys/module/wireguard":      {Mode: os.ModeDir},
+		}, PreflightOK},
+		{"dkms module", fstest.MapFS{
+			"proc/sys/kernel/osrelease":      osrelease("4.19.0"),
+			"lib/modules/4.19.0/modules.dep": {Data: []byte("updates/dkms/wireguard.ko.xz: kernel/net/ipv4/udp_tunnel.ko\n")},
+		}, PreflightOK},
+		{"in tree", fstest.MapFS{"proc/sys/kernel/osrelease": osrelease("5.10.0")}, PreflightOK},
+		{"missing", fstest.MapFS{
+			"proc/sys/kernel/osrelease":      osrelease("4.19.0"),
+			"lib/modules/4.19.0/modules.dep": {Data: []byte("kernel/net/wireless/cfg80211.ko:\n")},
+		}, PreflightFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkWireGuard(tt.fsys); got.Status != tt.want {
+				t.Errorf("checkWireGuard() = %+v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckSELinuxAndAppArmor(t *testing.T) {
+	enforcing := fstest.MapFS{
+		"sys/fs/selinux/enforce":                 {Data: []byte("1")},
+		"sys/module/apparmor/parameters/enabled": {Data: []byte("Y\n")},
+	}
+	if got := checkSELinux(enforcing); got.Status != PreflightWarn {
+		t.Errorf("checkSELinux(enforcing) = %+v, want warn", got)
+	}
+	if got := checkAppArmor(enforcing); got.Status != PreflightOK || !strings.Contains(got.Detail, "enabled") {
+		t.Errorf("checkAppArmor(enabled) = %+v, want ok and enabled", got)
+	}
+	if got := checkSELinux(fstest.MapFS{}); got.Status != PreflightOK || got.Detail != "disabled" {
+		t.Errorf("checkSELinux(none) = %+v, want ok and disabled", got)
+	}
+}
+
+func TestCheckPort_InUse(t *testing.T) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	if got := checkPort("tcp", port, "ingress"); got.Status != PreflightWarn {
+		t.Errorf("checkPort(in use) = %+v, want warn", got)
+	}
+	ln.Close()
+	if got := checkPort("tcp", port, "ingress"); got.Status != PreflightOK {
+		t.Errorf("checkPort(free) = %+v, want ok", got)
+	}
+}
+
+func TestInstall_PreflightFailureAborts(t *testing.T) {
+	ins, tmpDir := newTestInstaller(t, InstallConfig{}, &mockSystemdController{available: true}, &mockRootChecker{isRoot: true})
+	ins.SetPreflightChecks([]PreflightCheck{
+		{Name: "kernel", Run: func() PreflightResult { return PreflightResult{Status: PreflightOK, Detail: "6.1.0"} }},
+		{Name: "wireguard", Run: func() PreflightResult { return PreflightResult{Status: PreflightFail, Detail: "not found"} }},
+	})
+	var report bytes.Buffer
+	ins.SetReportWriter(&report)
+
+	if err := ins.Install(); !errors.Is(err, ErrPreflightFailed) {
+		t.Fatalf("Install() = %v, want ErrPreflightFailed", err)
+	}
+	if got := ins.Preflight(); len(got) != 2 || got[1].Name != "wireguard" || got[1].Status != PreflightFail {
+		t.Errorf("Preflight() = %+v, want kernel and failed wireguard", got)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "etc", "plexd")); !os.IsNotExist(err) {
+		t.Errorf("config dir created despite failed preflight: %v", err)
+	}
+	for _, want := range []string{"[ok]   kernel: 6.1.0", "[fail] wireguard: not found"} {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("report missing %q:\n%s", want, report.String())
+		}
+	}
+}
+
+func TestDryRun_ChangesNothing(t *testing.T) {
+	systemd := &mockSystemdController{available: true}
+	ins, tmpDir := newTestInstaller(t, InstallConfig{TokenValue: "tok"}, systemd, &mockRootChecker{isRoot: false})
+	ins.SetPreflightChecks([]PreflightCheck{
+		{Name: "selinux", Run: func() PreflightResult { return PreflightResult{Status: PreflightWarn, Detail: "enforcing"} }},
+	})
+	var report bytes.Buffer
+	ins.SetReportWriter(&report)
+
+	if err := ins.DryRun(); err != nil {
+		t.Fatalf("DryRun() = %v", err)
+	}
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("DryRun() created %v", entries)
+	}
+	if systemd.daemonReloadCalls != 0 {
+		t.Error("DryRun() reloaded systemd")
+	}
+
+	out := report.String()
+	for _, want := range []string{
+		"[warn] selinux: enforcing",
+		"mkdir    " + filepath.Join(tmpDir, "var", "lib", "plexd") + " (0700)",
+		"write    " + filepath.Join(tmpDir, "etc", "plexd", "bootstrap-token"),
+		"write    " + filepath.Join(tmpDir, "etc", "systemd", "system", "plexd.service") + " (0644 service file)",
+		"reload   systemd",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package packaging
+
+import "os"
+
+// realRootChecker implements RootChecker using os.Getuid.
+type realRootChecker struct{}
+
+// NewRootChecker returns a RootChecker that checks the real process UID.
+func NewRootChecker() RootChecker {
+	return &realRootChecker{}
+}
+
+func (c *realRootChecker) IsRoot() bool {
+	return os.Getuid() == 0
+}
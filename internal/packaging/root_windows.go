@@ -0,0 +1,18 @@
+//go:build windows
+
+package packaging
+
+import "golang.org/x/sys/windows"
+
+// realRootChecker implements RootChecker by checking whether the process
+// token is elevated (running as Administrator).
+type realRootChecker struct{}
+
+// NewRootChecker returns a RootChecker that checks the process token elevation.
+func NewRootChecker() RootChecker {
+	return &realRootChecker{}
+}
+
+func (c *realRootChecker) IsRoot() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+}
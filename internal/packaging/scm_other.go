@@ -0,0 +1,29 @@
+//go:build !windows
+
+package packaging
+
+import "errors"
+
+var errSCMUnsupported = errors.New("packaging: service control manager is only available on windows")
+
+// scmInitSystem is the non-Windows stub of the Service Control Manager
+// init system. It is never available.
+type scmInitSystem struct{}
+
+// NewSCMInitSystem returns an InitSystem that registers plexd with the
+// Windows Service Control Manager. On other platforms it is never available.
+func NewSCMInitSystem() InitSystem {
+	return &scmInitSystem{}
+}
+
+func (s *scmInitSystem) Name() string                            { return InitSystemSCM }
+func (s *scmInitSystem) IsAvailable() bool                       { return false }
+func (s *scmInitSystem) ServiceFile(_ InstallConfig) ServiceFile { return ServiceFile{} }
+func (s *scmInitSystem) Reload() error                           { return errSCMUnsupported }
+func (s *scmInitSystem) Enable(string) error                     { return errSCMUnsupported }
+func (s *scmInitSystem) Disable(string) error                    { return errSCMUnsupported }
+func (s *scmInitSystem) Stop(string) error                       { return errSCMUnsupported }
+func (s *scmInitSystem) Restart(string) error                    { return errSCMUnsupported }
+func (s *scmInitSystem) Register(InstallConfig) error            { return errSCMUnsupported }
+func (s *scmInitSystem) Unregister(string) error                 { return errSCMUnsupported }
+func (s *scmInitSystem) IsRegistered(string) bool                { return false }
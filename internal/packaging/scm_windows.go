@@ -0,0 +1,201 @@
+//go:build windows
+
+package packaging
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// scmStopTimeout bounds how long Stop waits for the service to stop.
+const scmStopTimeout = 30 * time.Second
+
+// scmInitSystem implements InitSystem and ServiceRegistrar using the Windows
+// Service Control Manager.
+type scmInitSystem struct{}
+
+// NewSCMInitSystem returns an InitSystem that registers plexd with the
+// Windows Service Control Manager.
+func NewSCMInitSystem() InitSystem {
+	return &scmInitSystem{}
+}
+
+func (s *scmInitSystem) Name() string { return InitSystemSCM }
+
+// IsAvailable returns true if the Service Control Manager accepts a connection.
+func (s *scmInitSystem) IsAvailable() bool {
+	m, err := mgr.Connect()
+	if err != nil {
+		return false
+	}
+	m.Disconnect()
+	return true
+}
+
+// ServiceFile returns an empty ServiceFile: services are registered through
+// the SCM API instead.
+func (s *scmInitSystem) ServiceFile(_ InstallConfig) ServiceFile { return ServiceFile{} }
+
+// Reload is a no-op: the SCM applies changes immediately.
+func (s *scmInitSystem) Reload() error { return nil }
+
+// Register creates the service, or updates its configuration if it already
+// exists. Failure recovery restarts the service after 5s, matching the
+// systemd unit's Restart=always.
+func (s *scmInitSystem) Register(cfg InstallConfig) error {
+	cfg.ApplyDefaults()
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("packaging: connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	svcCfg := mgr.Config{
+		DisplayName:  "plexd node agent",
+		Description:  "Plexsphere node agent",
+		StartType:    mgr.StartManual,
+		ErrorControl: mgr.ErrorNormal,
+	}
+	configPath := filepath.Join(cfg.ConfigDir, "config.yaml")
+
+	service, err := m.OpenService(cfg.ServiceName)
+	if err == nil {
+		current, err := service.Config()
+		if err != nil {
+			service.Close()
+			return fmt.Errorf("packaging: read service config: %w", err)
+		}
+		svcCfg.StartType = current.StartType
+		svcCfg.BinaryPathName = fmt.Sprintf("%s up --config %s", windows.EscapeArg(cfg.BinaryPath), windows.EscapeArg(configPath))
+		if err := service.UpdateConfig(svcCfg); err != nil {
+			service.Close()
+			return fmt.Errorf("packaging: update service: %w", err)
+		}
+	} else {
+		service, err = m.CreateService(cfg.ServiceName, cfg.BinaryPath, svcCfg, "up", "--config", configPath)
+		if err != nil {
+			return fmt.Errorf("packaging: create service: %w", err)
+		}
+	}
+	defer service.Close()
+
+	actions := []mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+	}
+	if err := service.SetRecoveryActions(actions, 60); err != nil {
+		return fmt.Errorf("packaging: set recovery actions: %w", err)
+	}
+	return nil
+}
+
+// Unregister deletes the named service. Returns nil if it does not exist.
+func (s *scmInitSystem) Unregister(service string) error {
+	return s.withService(service, func(svcHandle *mgr.Service) error {
+		if err := svcHandle.Delete(); err != nil && !errors.Is(err, windows.ERROR_SERVICE_MARKED_FOR_DELETE) {
+			return fmt.Errorf("packaging: delete service: %w", err)
+		}
+		return nil
+	})
+}
+
+// IsRegistered returns true if the named service exists.
+func (s *scmInitSystem) IsRegistered(service string) bool {
+	found := false
+	_ = s.withService(service, func(*mgr.Service) error {
+		found = true
+		return nil
+	})
+	return found
+}
+
+// Enable sets the service to start automatically at boot.
+func (s *scmInitSystem) Enable(service string) error {
+	return s.setStartType(service, mgr.StartAutomatic)
+}
+
+// Disable sets the service to start only on demand.
+func (s *scmInitSystem) Disable(service string) error {
+	return s.setStartType(service, mgr.StartManual)
+}
+
+// Stop requests the service to stop and waits until it has stopped.
+// Returns nil if the service is not running or does not exist.
+func (s *scmInitSystem) Stop(service string) error {
+	return s.withService(service, func(svcHandle *mgr.Service) error {
+		status, err := svcHandle.Control(svc.Stop)
+		if err != nil {
+			if errors.Is(err, windows.ERROR_SERVICE_NOT_ACTIVE) {
+				return nil
+			}
+			return fmt.Errorf("packaging: stop service: %w", err)
+		}
+		deadline := time.Now().Add(scmStopTimeout)
+		for status.State != svc.Stopped {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("packaging: stop service: timed out after %s", scmStopTimeout)
+			}
+			time.Sleep(250 * time.Millisecond)
+			if status, err = svcHandle.Query(); err != nil {
+				return fmt.Errorf("packaging: query service: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Restart stops the service, waiting until it has stopped, and starts it
+// again. The SCM has no restart control.
+func (s *scmInitSystem) Restart(service string) error {
+	if err := s.Stop(service); err != nil {
+		return err
+	}
+	return s.withService(service, func(svcHandle *mgr.Service) error {
+		if err := svcHandle.Start(); err != nil {
+			return fmt.Errorf("packaging: start service: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *scmInitSystem) setStartType(service string, startType uint32) error {
+	return s.withService(service, func(svcHandle *mgr.Service) error {
+		cfg, err := svcHandle.Config()
+		if err != nil {
+			return fmt.Errorf("packaging: read service config: %w", err)
+		}
+		cfg.StartType = startType
+		if err := svcHandle.UpdateConfig(cfg); err != nil {
+			return fmt.Errorf("packaging: update service: %w", err)
+		}
+		return nil
+	})
+}
+
+// withService opens the named service and calls fn. A missing service is
+// not an error: fn is not called and nil is returned.
+func (s *scmInitSystem) withService(service string, fn func(*mgr.Service) error) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("packaging: connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	svcHandle, err := m.OpenService(service)
+	if err != nil {
+		if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+			return nil
+		}
+		return fmt.Errorf("packaging: open service: %w", err)
+	}
+	defer svcHandle.Close()
+	return fn(svcHandle)
+}
@@ -0,0 +1,57 @@
+package packaging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DetectSecurityModules returns the security modules enabled on this host.
+func DetectSecurityModules() []SecurityModule {
+	var enabled []SecurityModule
+	for _, m := range []SecurityModule{NewSELinuxModule(), NewAppArmorModule()} {
+		if m.IsEnabled() {
+			enabled = append(enabled, m)
+		}
+	}
+	return enabled
+}
+
+// installSecurityPolicies writes and loads the policy of each security
+// module.
+func (ins *Installer) installSecurityPolicies() error {
+	for _, m := range ins.security {
+		policy := m.PolicyFile(ins.cfg)
+		if err := os.MkdirAll(filepath.Dir(policy.Path), 0o755); err != nil {
+			return fmt.Errorf("packaging: %s: create policy directory: %w", m.Name(), err)
+		}
+		if err := os.WriteFile(policy.Path, []byte(policy.Content), policy.Mode); err != nil {
+			return fmt.Errorf("packaging: %s: write policy: %w", m.Name(), err)
+		}
+		if err := m.Load(ins.cfg); err != nil {
+			return fmt.Errorf("packaging: %s: load policy: %w", m.Name(), err)
+		}
+		ins.logger.Info("security policy loaded", "module", m.Name(), "path", policy.Path)
+	}
+	return nil
+}
+
+// removeSecurityPolicies unloads and removes the policy of each security
+// module whose policy file exists.
+func (ins *Installer) removeSecurityPolicies() error {
+	for _, m := range ins.security {
+		path := m.PolicyFile(ins.cfg).Path
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err := m.Unload(ins.cfg); err != nil {
+			ins.logger.Info("unload security policy", "module", m.Name(), "error", err)
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("packaging: %s: remove policy: %w", m.Name(), err)
+		}
+		ins.logger.Info("security policy removed", "module", m.Name(), "path", path)
+	}
+	return nil
+}
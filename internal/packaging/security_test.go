@@ -0,0 +1,131 @@
+package packaging
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeSecurityModule records Load and Unload calls.
+type fakeSecurityModule struct {
+	dir     string
+	loads   int
+	unloads int
+}
+
+func (m *fakeSecurityModule) Name() string    { return "fake" }
+func (m *fakeSecurityModule) IsEnabled() bool { return true }
+
+func (m *fakeSecurityModule) PolicyFile(InstallConfig) ServiceFile {
+	return ServiceFile{Path: filepath.Join(m.dir, "policy", "plexd"), Content: "policy", Mode: 0o644}
+}
+
+func (m *fakeSecurityModule) Load(InstallConfig) error   { m.loads++; return nil }
+func (m *fakeSecurityModule) Unload(InstallConfig) error { m.unloads++; return nil }
+
+func TestInstall_LoadsAndUninstallRemovesSecurityPolicy(t *testing.T) {
+	ins, tmpDir := newTestInstaller(t, InstallConfig{}, &mockSystemdController{available: true}, &mockRootChecker{isRoot: true})
+	mod := &fakeSecurityModule{dir: tmpDir}
+	ins.SetSecurityModules([]SecurityModule{mod})
+
+	if err := ins.Install(); err != nil {
+		t.Fatalf("Install() = %v", err)
+	}
+	policyPath := mod.PolicyFile(InstallConfig{}).Path
+	if data, err := os.ReadFile(policyPath); err != nil || string(data) != "policy" {
+		t.Fatalf("policy file = %q, %v", data, err)
+	}
+	if mod.loads != 1 {
+		t.Errorf("Load calls = %d, want 1", mod.loads)
+	}
+
+	if err := ins.Uninstall(false); err != nil {
+		t.Fatalf("Uninstall() = %v", err)
+	}
+	if mod.unloads != 1 {
+		t.Errorf("Unload calls = %d, want 1", mod.unloads)
+	}
+	if _, err := os.Stat(policyPath); !os.IsNotExist(err) {
+		t.Errorf("policy file still present after Uninstall(): %v", err)
+	}
+}
+
+func TestSELinuxModule_Commands(t *testing.T) {
+	rec := &commandRecorder{}
+	m := &selinuxModule{run: rec.run}
+	cfg := InstallConfig{}
+
+	if got := m.PolicyFile(cfg).Path; got != "/usr/share/selinux/packages/plexd.cil" {
+		t.Errorf("PolicyFile().Path = %q", got)
+	}
+	if err := m.Load(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Unload(cfg); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"semodule -i /usr/share/selinux/packages/plexd.cil",
+		"restorecon -R /usr/local/bin/plexd /etc/plexd /var/lib/plexd /var/run/plexd",
+		"semodule -r plexd",
+	}
+	if !reflect.DeepEqual(rec.calls, want) {
+		t.Errorf("commands = %q, want %q", rec.calls, want)
+	}
+}
+
+func TestGenerateSELinuxPolicy_FileContexts(t *testing.T) {
+	policy := GenerateSELinuxPolicy(InstallConfig{ConfigDir: "/opt/plexd.d"})
+	for _, want := range []string{
+		`(filecon "/usr/local/bin/plexd" file (system_u object_r plexd_exec_t ((s0) (s0))))`,
+		`(filecon "/opt/plexd\.d(/.*)?" any (system_u object_r plexd_etc_t ((s0) (s0))))`,
+		`(filecon "/opt/plexd\.d/hooks(/.*)?" any (system_u object_r plexd_hook_exec_t ((s0) (s0))))`,
+		`(filecon "/run/plexd-helper\.sock" socket (system_u object_r plexd_var_run_t ((s0) (s0))))`,
+		"(typetransition init_t plexd_exec_t process plexd_t)",
+	} {
+		if !strings.Contains(policy, want) {
+			t.Errorf("policy missing %q", want)
+		}
+	}
+	if strings.Count(policy, "(") != strings.Count(policy, ")") {
+		t.Error("policy has unbalanced parentheses")
+	}
+}
+
+func TestAppArmorModule_Commands(t *testing.T) {
+	rec := &commandRecorder{}
+	m := &apparmorModule{run: rec.run}
+	cfg := InstallConfig{}
+
+	if err := m.Load(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Unload(cfg); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"apparmor_parser -r -W /etc/apparmor.d/usr.local.bin.plexd",
+		"apparmor_parser -R /etc/apparmor.d/usr.local.bin.plexd",
+	}
+	if !reflect.DeepEqual(rec.calls, want) {
+		t.Errorf("commands = %q, want %q", rec.calls, want)
+	}
+}
+
+func TestGenerateAppArmorProfile(t *testing.T) {
+	profile := GenerateAppArmorProfile(InstallConfig{})
+	for _, want := range []string{
+		"profile plexd /usr/local/bin/plexd flags=(attach_disconnected) {",
+		"  /etc/plexd/hooks/** rix,",
+		"  /var/lib/plexd/** rwk,",
+		"  @{run}/plexd/** rwk,",
+		"  /run/plexd-helper.sock rw,",
+		"  capability net_admin,",
+	} {
+		if !strings.Contains(profile, want) {
+			t.Errorf("profile missing %q:\n%s", want, profile)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package packaging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/plexsphere/plexd/internal/privhelper"
+)
+
+// Security module identifiers.
+const (
+	SecurityModuleSELinux  = "selinux"
+	SecurityModuleAppArmor = "apparmor"
+)
+
+// selinuxModuleName is the name of the SELinux policy module. semodule
+// derives it from the file name.
+const selinuxModuleName = "plexd"
+
+// selinuxModule implements SecurityModule for SELinux with semodule and
+// restorecon.
+type selinuxModule struct {
+	run commandRunner
+}
+
+// NewSELinuxModule returns a SecurityModule that installs a CIL policy
+// module confining plexd to the plexd_t domain.
+func NewSELinuxModule() SecurityModule {
+	return &selinuxModule{run: runCommand}
+}
+
+func (m *selinuxModule) Name() string { return SecurityModuleSELinux }
+
+// IsEnabled returns true if selinuxfs is mounted, in enforcing or
+// permissive mode, and semodule is installed.
+func (m *selinuxModule) IsEnabled() bool {
+	_, err := os.Stat("/sys/fs/selinux/enforce")
+	return err == nil && commandExists("semodule")
+}
+
+func (m *selinuxModule) PolicyFile(cfg InstallConfig) ServiceFile {
+	cfg.ApplyDefaults()
+	return ServiceFile{
+		Path:    filepath.Join(cfg.SELinuxPolicyDir, selinuxModuleName+".cil"),
+		Content: GenerateSELinuxPolicy(cfg),
+		Mode:    0o644,
+	}
+}
+
+// Load installs the policy module and relabels the installed files so the
+// file contexts of the module apply.
+func (m *selinuxModule) Load(cfg InstallConfig) error {
+	cfg.ApplyDefaults()
+	if err := m.run("semodule", "-i", m.PolicyFile(cfg).Path); err != nil {
+		return err
+	}
+	return m.run("restorecon", "-R", cfg.BinaryPath, cfg.ConfigDir, cfg.DataDir, cfg.RunDir)
+}
+
+// Unload removes the policy module.
+func (m *selinuxModule) Unload(_ InstallConfig) error {
+	return m.run("semodule", "-r", selinuxModuleName)
+}
+
+// GenerateSELinuxPolicy produces a CIL policy module for plexd. systemd
+// starts the agent in the plexd_t domain, which may administer network
+// interfaces, netfilter, and WireGuard, and manage the plexd directories.
+// Hook scripts are labeled plexd_hook_exec_t and run unconfined in
+// plexd_hook_t, since they are written by the administrator.
+// It calls cfg.ApplyDefaults() to fill in zero-valued fields before generating the output.
+func GenerateSELinuxPolicy(cfg InstallConfig) string {
+	cfg.ApplyDefaults()
+
+	filecon := func(path, kind, typ string) string {
+		// CIL strings have no escapes, so the regular expression is written as is.
+		return fmt.Sprintf("(filecon \"%s\" %s (system_u object_r %s ((s0) (s0))))\n", path, kind, typ)
+	}
+	tree := func(dir string) string { return regexp.QuoteMeta(dir) + "(/.*)?" }
+
+	var fc strings.Builder
+	fc.WriteString(filecon(regexp.QuoteMeta(cfg.BinaryPath), "file", "plexd_exec_t"))
+	fc.WriteString(filecon(tree(cfg.ConfigDir), "any", "plexd_etc_t"))
+	fc.WriteString(filecon(tree(filepath.Join(cfg.ConfigDir, "hooks")), "any", "plexd_hook_exec_t"))
+	fc.WriteString(filecon(tree(cfg.DataDir), "any", "plexd_var_lib_t"))
+	fc.WriteString(filecon(tree(cfg.RunDir), "any", "plexd_var_run_t"))
+	fc.WriteString(filecon(regexp.QuoteMeta(privhelper.DefaultSocketPath), "socket", "plexd_var_run_t"))
+
+	return `; Generated by plexd install.
+(type plexd_t)
+(type plexd_hook_t)
+(type plexd_exec_t)
+(type plexd_hook_exec_t)
+(type plexd_etc_t)
+(type plexd_var_lib_t)
+(type plexd_var_run_t)
+(roletype system_r plexd_t)
+(roletype system_r plexd_hook_t)
+(typeattributeset domain (plexd_t plexd_hook_t))
+(typeattributeset unconfined_domain_type (plexd_hook_t))
+(typeattributeset file_type (plexd_exec_t plexd_hook_exec_t plexd_etc_t plexd_var_lib_t plexd_var_run_t))
+(typeattributeset non_security_file_type (plexd_exec_t plexd_hook_exec_t plexd_etc_t plexd_var_lib_t plexd_var_run_t))
+(typeattributeset exec_type (plexd_exec_t plexd_hook_exec_t))
+
+; systemd starts the agent in plexd_t.
+(typetransition init_t plexd_exec_t process plexd_t)
+(allow init_t plexd_exec_t (file (getattr open read execute map)))
+(allow init_t plexd_t (process (transition signal sigkill)))
+(allow plexd_t plexd_exec_t (file (entrypoint getattr open read execute map)))
+(allow plexd_t init_t (fd (use)))
+(allow plexd_t init_t (process (sigchld)))
+(allow plexd_t init_t (unix_dgram_socket (sendto)))
+(allow plexd_t init_var_run_t (sock_file (getattr write)))
+
+; Hooks run unconfined in plexd_hook_t.
+(typetransition plexd_t plexd_hook_exec_t process plexd_hook_t)
+(allow plexd_t plexd_hook_exec_t (dir (getattr search open read)))
+(allow plexd_t plexd_hook_exec_t (file (getattr open read execute map)))
+(allow plexd_t plexd_hook_t (process (transition signal sigkill)))
+(allow plexd_hook_t plexd_hook_exec_t (file (entrypoint)))
+(allow plexd_hook_t plexd_t (fd (use)))
+(allow plexd_hook_t plexd_t (fifo_file (read write getattr ioctl)))
+(allow plexd_hook_t plexd_t (process (sigchld)))
+
+; Network administration: WireGuard, routes, netfilter, and the mesh ports.
+(allow plexd_t self (capability (net_admin net_raw net_bind_service setuid setgid chown fowner kill sys_module)))
+(allow plexd_t self (process (fork signal sigchld setrlimit getsched)))
+(allow plexd_t self (fifo_file (read write getattr ioctl)))
+(allow plexd_t self (udp_socket (create bind connect read write getattr setattr getopt setopt ioctl shutdown)))
+(allow plexd_t self (tcp_socket (create bind connect listen accept read write getattr setattr getopt setopt ioctl shutdown)))
+(allow plexd_t self (rawip_socket (create bind read write getattr setopt getopt)))
+(allow plexd_t self (unix_stream_socket (create bind connect listen accept read write getattr setattr getopt setopt shutdown)))
+(allow plexd_t self (unix_dgram_socket (create connect read write sendto getattr setopt)))
+(allow plexd_t self (netlink_route_socket (create bind read write getattr setattr getopt setopt nlmsg_read nlmsg_write)))
+(allow plexd_t self (netlink_generic_socket (create bind read write getattr setattr getopt setopt)))
+(allow plexd_t self (netlink_netfilter_socket (create bind read write getattr setattr getopt setopt)))
+(allow plexd_t self (tun_socket (create attach_queue)))
+(allow plexd_t port_type (udp_socket (name_bind)))
+(allow plexd_t port_type (tcp_socket (name_bind name_connect)))
+(allow plexd_t node_type (udp_socket (node_bind)))
+(allow plexd_t node_type (tcp_socket (node_bind)))
+(allow plexd_t tun_tun_device_t (chr_file (getattr open read write ioctl)))
+(allow plexd_t kernel_t (system (module_request)))
+
+; Kernel and system state.
+(allow plexd_t proc_t (file (getattr open read)))
+(allow plexd_t proc_net_t (file (getattr open read)))
+(allow plexd_t sysfs_t (dir (getattr search open read)))
+(allow plexd_t sysfs_t (file (getattr open read)))
+(allow plexd_t sysctl_t (dir (search)))
+(allow plexd_t sysctl_net_t (dir (search)))
+(allow plexd_t sysctl_net_t (file (getattr open read write)))
+(allow plexd_t etc_t (file (getattr open read)))
+(allow plexd_t net_conf_t (file (getattr open read)))
+(allow plexd_t cert_t (dir (getattr search open read)))
+(allow plexd_t cert_t (file (getattr open read)))
+(allow plexd_t cert_t (lnk_file (read)))
+
+; plexd directories and sockets.
+(allow plexd_t plexd_etc_t (dir (getattr search open read write add_name remove_name)))
+(allow plexd_t plexd_etc_t (file (getattr open read write create rename unlink lock setattr)))
+(allow plexd_t plexd_var_lib_t (dir (getattr search open read write add_name remove_name create rmdir setattr)))
+(allow plexd_t plexd_var_lib_t (file (getattr open read write append create rename unlink lock setattr)))
+(allow plexd_t plexd_var_run_t (dir (getattr search open read write add_name remove_name create rmdir setattr)))
+(allow plexd_t plexd_var_run_t (file (getattr open read write create rename unlink lock setattr)))
+(allow plexd_t plexd_var_run_t (sock_file (getattr create unlink write setattr)))
+
+; File contexts.
+` + fc.String()
+}
@@ -2,7 +2,6 @@ package packaging
 
 import (
 	"fmt"
-	"os"
 	"os/exec"
 	"strings"
 )
@@ -36,6 +35,12 @@ func (c *realSystemdController) Stop(service string) error {
 	return c.run("stop", service)
 }
 
+// Restart blocks until the old process has stopped and, for Type=notify
+// units, the new one has reported readiness.
+func (c *realSystemdController) Restart(service string) error {
+	return c.run("restart", service)
+}
+
 func (c *realSystemdController) IsActive(service string) bool {
 	err := exec.Command("systemctl", "is-active", "--quiet", service).Run()
 	return err == nil
@@ -49,15 +54,3 @@ func (c *realSystemdController) run(args ...string) error {
 	}
 	return nil
 }
-
-// realRootChecker implements RootChecker using os.Getuid.
-type realRootChecker struct{}
-
-// NewRootChecker returns a RootChecker that checks the real process UID.
-func NewRootChecker() RootChecker {
-	return &realRootChecker{}
-}
-
-func (c *realRootChecker) IsRoot() bool {
-	return os.Getuid() == 0
-}
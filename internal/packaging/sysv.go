@@ -0,0 +1,166 @@
+package packaging
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// sysvInitSystem implements InitSystem for SysV init using update-rc.d
+// (Debian family) or chkconfig (Red Hat family).
+type sysvInitSystem struct {
+	run    commandRunner
+	hasCmd func(name string) bool
+}
+
+// NewSysVInitSystem returns an InitSystem that installs an LSB init script
+// in InstallConfig.InitScriptDir.
+func NewSysVInitSystem() InitSystem {
+	return &sysvInitSystem{run: runCommand, hasCmd: commandExists}
+}
+
+func (s *sysvInitSystem) Name() string { return InitSystemSysV }
+
+// IsAvailable returns true if the default init script directory exists and
+// either update-rc.d or chkconfig is installed.
+func (s *sysvInitSystem) IsAvailable() bool {
+	return dirExists(DefaultInitScriptDir) && (s.hasCmd("update-rc.d") || s.hasCmd("chkconfig"))
+}
+
+func (s *sysvInitSystem) ServiceFile(cfg InstallConfig) ServiceFile {
+	cfg.ApplyDefaults()
+	return ServiceFile{
+		Path:    filepath.Join(cfg.InitScriptDir, cfg.ServiceName),
+		Content: GenerateSysVScript(cfg),
+		Mode:    0o755,
+	}
+}
+
+// Reload is a no-op: SysV init has no service manager state to refresh.
+func (s *sysvInitSystem) Reload() error { return nil }
+
+func (s *sysvInitSystem) Enable(service string) error {
+	if s.hasCmd("update-rc.d") {
+		return s.run("update-rc.d", service, "defaults")
+	}
+	return s.run("chkconfig", "--add", service)
+}
+
+func (s *sysvInitSystem) Disable(service string) error {
+	if s.hasCmd("update-rc.d") {
+		return s.run("update-rc.d", "-f", service, "remove")
+	}
+	return s.run("chkconfig", "--del", service)
+}
+
+func (s *sysvInitSystem) Stop(service string) error {
+	return s.run("service", service, "stop")
+}
+
+func (s *sysvInitSystem) Restart(service string) error {
+	return s.run("service", service, "restart")
+}
+
+// GenerateSysVScript produces an LSB-compliant SysV init script for the plexd
+// service. The script tracks the daemon through a pidfile; SysV init has no
+// supervisor, so the service is not restarted if it exits.
+// It calls cfg.ApplyDefaults() to fill in zero-valued fields before generating the output.
+func GenerateSysVScript(cfg InstallConfig) string {
+	cfg.ApplyDefaults()
+
+	configPath := filepath.Join(cfg.ConfigDir, "config.yaml")
+	envPath := filepath.Join(cfg.ConfigDir, "environment")
+	pidPath := filepath.Join(cfg.RunDir, cfg.ServiceName+".pid")
+	logPath := filepath.Join(DefaultLogDir, cfg.ServiceName+".log")
+
+	return fmt.Sprintf(`#!/bin/sh
+### BEGIN INIT INFO
+# Provides:          %[1]s
+# Required-Start:    $network $remote_fs $syslog
+# Required-Stop:     $network $remote_fs $syslog
+# Default-Start:     2 3 4 5
+# Default-Stop:      0 1 6
+# Short-Description: plexd node agent
+### END INIT INFO
+# chkconfig: 2345 90 10
+# description: plexd node agent
+# Generated by plexd install.
+
+NAME="%[1]s"
+DAEMON="%[2]s"
+DAEMON_ARGS="up --config %[3]s"
+PIDFILE="%[4]s"
+LOGFILE="%[5]s"
+RUNDIR="%[6]s"
+ENVFILE="%[7]s"
+
+[ -x "$DAEMON" ] || exit 0
+
+if [ -f "$ENVFILE" ]; then
+	set -a
+	. "$ENVFILE"
+	set +a
+fi
+
+is_running() {
+	[ -f "$PIDFILE" ] && kill -0 "$(cat "$PIDFILE")" 2>/dev/null
+}
+
+start() {
+	if is_running; then
+		echo "$NAME is already running"
+		return 0
+	fi
+	echo "Starting $NAME"
+	mkdir -p "$RUNDIR"
+	ulimit -n 65536
+	nohup "$DAEMON" $DAEMON_ARGS >>"$LOGFILE" 2>&1 &
+	echo $! >"$PIDFILE"
+}
+
+stop() {
+	if ! is_running; then
+		echo "$NAME is not running"
+		rm -f "$PIDFILE"
+		return 0
+	fi
+	echo "Stopping $NAME"
+	pid="$(cat "$PIDFILE")"
+	kill "$pid"
+	i=0
+	while kill -0 "$pid" 2>/dev/null; do
+		i=$((i + 1))
+		if [ "$i" -ge 30 ]; then
+			kill -9 "$pid" 2>/dev/null
+			break
+		fi
+		sleep 1
+	done
+	rm -f "$PIDFILE"
+}
+
+case "$1" in
+	start)
+		start
+		;;
+	stop)
+		stop
+		;;
+	restart)
+		stop
+		start
+		;;
+	status)
+		if is_running; then
+			echo "$NAME is running"
+		else
+			echo "$NAME is not running"
+			exit 3
+		fi
+		;;
+	*)
+		echo "Usage: $0 {start|stop|restart|status}"
+		exit 2
+		;;
+esac
+`, cfg.ServiceName, cfg.BinaryPath, configPath, pidPath, logPath, cfg.RunDir, envPath)
+}
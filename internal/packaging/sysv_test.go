@@ -0,0 +1,64 @@
+package packaging
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateSysVScript_DefaultConfig(t *testing.T) {
+	output := GenerateSysVScript(InstallConfig{})
+
+	want := []string{
+		"#!/bin/sh",
+		"### BEGIN INIT INFO",
+		"# Provides:          plexd",
+		"# Required-Start:    $network $remote_fs $syslog",
+		"# Default-Start:     2 3 4 5",
+		"### END INIT INFO",
+		"# chkconfig: 2345 90 10",
+		`DAEMON="/usr/local/bin/plexd"`,
+		`DAEMON_ARGS="up --config /etc/plexd/config.yaml"`,
+		`PIDFILE="/var/run/plexd/plexd.pid"`,
+		`LOGFILE="/var/log/plexd.log"`,
+		`ENVFILE="/etc/plexd/environment"`,
+		"ulimit -n 65536",
+		"start)",
+		"stop)",
+		"restart)",
+		"status)",
+	}
+	for _, w := range want {
+		if !strings.Contains(output, w) {
+			t.Errorf("output missing %q", w)
+		}
+	}
+}
+
+func TestGenerateSysVScript_CustomPaths(t *testing.T) {
+	output := GenerateSysVScript(InstallConfig{
+		BinaryPath:  "/opt/plexd/bin/plexd",
+		ConfigDir:   "/opt/plexd/etc",
+		RunDir:      "/run/plexd",
+		ServiceName: "plexd-edge",
+	})
+
+	want := []string{
+		"# Provides:          plexd-edge",
+		`DAEMON="/opt/plexd/bin/plexd"`,
+		`DAEMON_ARGS="up --config /opt/plexd/etc/config.yaml"`,
+		`PIDFILE="/run/plexd/plexd-edge.pid"`,
+		`RUNDIR="/run/plexd"`,
+	}
+	for _, w := range want {
+		if !strings.Contains(output, w) {
+			t.Errorf("output missing %q", w)
+		}
+	}
+}
+
+func TestGenerateSysVScript_ValidShell(t *testing.T) {
+	output := GenerateSysVScript(InstallConfig{})
+	if strings.Contains(output, "%!") {
+		t.Errorf("output contains formatting error:\n%s", output)
+	}
+}
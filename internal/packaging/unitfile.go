@@ -3,13 +3,24 @@ package packaging
 import (
 	"fmt"
 	"path/filepath"
+
+	"github.com/plexsphere/plexd/internal/privhelper"
+	"github.com/plexsphere/plexd/internal/tokensource"
 )
 
 // GenerateUnitFile produces a complete systemd unit file for the plexd service.
+// The agent reports readiness and watchdog keepalives via sd_notify; the
+// start timeout leaves room for registration retries.
+// In rootless mode the agent runs as cfg.User without capabilities and
+// depends on the privileged helper socket.
 // It calls cfg.ApplyDefaults() to fill in zero-valued fields before generating the output.
 func GenerateUnitFile(cfg InstallConfig) string {
 	cfg.ApplyDefaults()
 
+	if cfg.Rootless {
+		return generateRootlessUnitFile(cfg)
+	}
+
 	configPath := filepath.Join(cfg.ConfigDir, "config.yaml")
 	envPath := filepath.Join(cfg.ConfigDir, "environment")
 
@@ -21,13 +32,18 @@ StartLimitBurst=5
 StartLimitIntervalSec=60
 
 [Service]
-Type=simple
+Type=notify
+NotifyAccess=main
+WatchdogSec=60s
+TimeoutStartSec=10min
 ExecStart=%s up --config %s
+ExecReload=/bin/kill -HUP $MAINPID
 Restart=always
 RestartSec=5s
+RestartPreventExitStatus=7
 LimitNOFILE=65536
 EnvironmentFile=-%s
-AmbientCapabilities=CAP_NET_ADMIN CAP_NET_RAW
+%sAmbientCapabilities=CAP_NET_ADMIN CAP_NET_RAW
 CapabilityBoundingSet=CAP_NET_ADMIN CAP_NET_RAW
 ProtectSystem=full
 ProtectHome=true
@@ -35,5 +51,111 @@ ReadWritePaths=%s %s
 
 [Install]
 WantedBy=multi-user.target
-`, cfg.BinaryPath, configPath, envPath, cfg.DataDir, cfg.RunDir)
+`, cfg.BinaryPath, configPath, envPath, loadCredential(cfg), cfg.DataDir, cfg.RunDir)
+}
+
+func generateRootlessUnitFile(cfg InstallConfig) string {
+	configPath := filepath.Join(cfg.ConfigDir, "config.yaml")
+	envPath := filepath.Join(cfg.ConfigDir, "environment")
+	helperSocket := helperUnitName(cfg.ServiceName, "socket")
+
+	return fmt.Sprintf(`[Unit]
+Description=plexd node agent (rootless)
+After=network-online.target %[1]s
+Wants=network-online.target
+Requires=%[1]s
+StartLimitBurst=5
+StartLimitIntervalSec=60
+
+[Service]
+Type=notify
+NotifyAccess=main
+WatchdogSec=60s
+TimeoutStartSec=10min
+User=%[2]s
+Group=%[2]s
+ExecStart=%[3]s up --config %[4]s
+ExecReload=/bin/kill -HUP $MAINPID
+Restart=always
+RestartSec=5s
+RestartPreventExitStatus=7
+LimitNOFILE=65536
+EnvironmentFile=-%[5]s
+%[9]sRuntimeDirectory=%[6]s
+RuntimeDirectoryPreserve=restart
+CapabilityBoundingSet=
+NoNewPrivileges=true
+ProtectSystem=full
+ProtectHome=true
+ReadWritePaths=%[7]s %[8]s
+
+[Install]
+WantedBy=multi-user.target
+`, helperSocket, cfg.User, cfg.BinaryPath, configPath, envPath, filepath.Base(cfg.RunDir), cfg.DataDir, cfg.RunDir, loadCredential(cfg))
+}
+
+// loadCredential returns the LoadCredential= line for a bootstrap token
+// read from a systemd credential, or "" for any other token source.
+// Without a path, systemd looks the credential up in /etc/credstore and
+// the other credential store directories.
+func loadCredential(cfg InstallConfig) string {
+	ref, err := tokensource.ParseRef(cfg.TokenSource)
+	if err != nil || ref.Scheme != tokensource.SchemeSystemd {
+		return ""
+	}
+	return "LoadCredential=" + ref.Name + "\n"
+}
+
+// GenerateHelperSocketUnit produces the systemd socket unit for the
+// privileged helper of a rootless install. Only root and members of
+// cfg.User's group can connect.
+// It calls cfg.ApplyDefaults() to fill in zero-valued fields before generating the output.
+func GenerateHelperSocketUnit(cfg InstallConfig) string {
+	cfg.ApplyDefaults()
+
+	return fmt.Sprintf(`[Unit]
+Description=plexd privileged helper socket
+
+[Socket]
+ListenStream=%s
+SocketUser=root
+SocketGroup=%s
+SocketMode=0660
+
+[Install]
+WantedBy=sockets.target
+`, privhelper.DefaultSocketPath, cfg.User)
+}
+
+// GenerateHelperServiceUnit produces the systemd service unit for the
+// privileged helper of a rootless install. The helper is started on demand
+// by its socket unit and keeps only CAP_NET_ADMIN.
+// It calls cfg.ApplyDefaults() to fill in zero-valued fields before generating the output.
+func GenerateHelperServiceUnit(cfg InstallConfig) string {
+	cfg.ApplyDefaults()
+
+	return fmt.Sprintf(`[Unit]
+Description=plexd privileged helper
+Requires=%[1]s
+After=%[1]s
+
+[Service]
+Type=simple
+ExecStart=%[2]s helper --group %[3]s
+Restart=on-failure
+RestartSec=2s
+AmbientCapabilities=CAP_NET_ADMIN
+CapabilityBoundingSet=CAP_NET_ADMIN
+NoNewPrivileges=true
+ProtectSystem=strict
+ProtectHome=true
+PrivateTmp=true
+RestrictAddressFamilies=AF_UNIX AF_NETLINK AF_INET AF_INET6
+`, helperUnitName(cfg.ServiceName, "socket"), cfg.BinaryPath, cfg.User)
+}
+
+// helperUnitName returns the name of the helper unit of the given type
+// ("socket" or "service") for service.
+func helperUnitName(service, unitType string) string {
+	return service + "-helper." + unitType
 }
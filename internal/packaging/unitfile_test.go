@@ -21,8 +21,10 @@ func TestGenerateUnitFile_DefaultConfig(t *testing.T) {
 	}
 
 	// Check key directives
-	if !strings.Contains(output, "Type=simple") {
-		t.Error("output missing Type=simple")
+	for _, w := range []string{"Type=notify", "NotifyAccess=main", "WatchdogSec=60s"} {
+		if !strings.Contains(output, w) {
+			t.Errorf("output missing %s", w)
+		}
 	}
 	if !strings.Contains(output, "After=network-online.target") {
 		t.Error("output missing After=network-online.target")
@@ -33,6 +35,12 @@ func TestGenerateUnitFile_DefaultConfig(t *testing.T) {
 	if !strings.Contains(output, "RestartSec=5s") {
 		t.Error("output missing RestartSec=5s")
 	}
+	if !strings.Contains(output, "RestartPreventExitStatus=7") {
+		t.Error("output missing RestartPreventExitStatus=7")
+	}
+	if !strings.Contains(output, "ExecReload=/bin/kill -HUP $MAINPID") {
+		t.Error("output missing ExecReload")
+	}
 	if !strings.Contains(output, "WantedBy=multi-user.target") {
 		t.Error("output missing WantedBy=multi-user.target")
 	}
@@ -65,6 +73,22 @@ func TestGenerateUnitFile_EnvironmentFile(t *testing.T) {
 	}
 }
 
+func TestGenerateUnitFile_LoadCredential(t *testing.T) {
+	for _, rootless := range []bool{false, true} {
+		output := GenerateUnitFile(InstallConfig{Rootless: rootless, TokenSource: "systemd-creds:plexd-token"})
+		if !strings.Contains(output, "LoadCredential=plexd-token\n") {
+			t.Errorf("rootless=%v: output missing LoadCredential=plexd-token, got:\n%s", rootless, output)
+		}
+	}
+
+	for _, src := range []string{"", "vault:secret/plexd"} {
+		output := GenerateUnitFile(InstallConfig{TokenSource: src})
+		if strings.Contains(output, "LoadCredential") {
+			t.Errorf("TokenSource %q: unexpected LoadCredential, got:\n%s", src, output)
+		}
+	}
+}
+
 func TestGenerateUnitFile_CustomBinaryPath(t *testing.T) {
 	cfg := InstallConfig{
 		BinaryPath: "/opt/plexd/bin/plexd",
@@ -116,3 +140,64 @@ func TestGenerateUnitFile_CustomPaths(t *testing.T) {
 		t.Errorf("output missing custom ReadWritePaths, got:\n%s", output)
 	}
 }
+
+func TestGenerateUnitFile_Rootless(t *testing.T) {
+	output := GenerateUnitFile(InstallConfig{Rootless: true, User: "plexd-agent"})
+
+	want := []string{
+		"User=plexd-agent",
+		"Group=plexd-agent",
+		"Requires=plexd-helper.socket",
+		"After=network-online.target plexd-helper.socket",
+		"RuntimeDirectory=plexd",
+		"CapabilityBoundingSet=\n",
+		"NoNewPrivileges=true",
+		"ExecStart=/usr/local/bin/plexd up --config /etc/plexd/config.yaml",
+		"ExecReload=/bin/kill -HUP $MAINPID",
+		"Type=notify",
+		"WatchdogSec=60s",
+	}
+	for _, w := range want {
+		if !strings.Contains(output, w) {
+			t.Errorf("output missing %q", w)
+		}
+	}
+	if strings.Contains(output, "AmbientCapabilities") {
+		t.Error("rootless unit grants ambient capabilities")
+	}
+}
+
+func TestGenerateHelperUnits(t *testing.T) {
+	cfg := InstallConfig{Rootless: true}
+
+	socket := GenerateHelperSocketUnit(cfg)
+	for _, w := range []string{
+		"[Socket]",
+		"ListenStream=/run/plexd-helper.sock",
+		"SocketUser=root",
+		"SocketGroup=plexd",
+		"SocketMode=0660",
+		"WantedBy=sockets.target",
+	} {
+		if !strings.Contains(socket, w) {
+			t.Errorf("socket unit missing %q", w)
+		}
+	}
+
+	service := GenerateHelperServiceUnit(cfg)
+	for _, w := range []string{
+		"Requires=plexd-helper.socket",
+		"ExecStart=/usr/local/bin/plexd helper --group plexd",
+		"AmbientCapabilities=CAP_NET_ADMIN\n",
+		"CapabilityBoundingSet=CAP_NET_ADMIN\n",
+		"NoNewPrivileges=true",
+		"ProtectSystem=strict",
+	} {
+		if !strings.Contains(service, w) {
+			t.Errorf("service unit missing %q", w)
+		}
+	}
+	if strings.Contains(service, "[Install]") {
+		t.Error("helper service must be socket-activated, not installed")
+	}
+}
@@ -0,0 +1,272 @@
+package packaging
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/integrity"
+)
+
+// DefaultUpgradeHealthTimeout is the default time the upgraded service has
+// to become healthy before the upgrade is rolled back.
+const DefaultUpgradeHealthTimeout = 60 * time.Second
+
+// DefaultUpgradeHealthInterval is the default interval between health checks
+// while waiting for the upgraded service.
+const DefaultUpgradeHealthInterval = 2 * time.Second
+
+// ErrUpgradeRolledBack is returned when the upgraded service did not become
+// healthy and the previous binary was restored.
+var ErrUpgradeRolledBack = errors.New("packaging: upgrade rolled back")
+
+// UpgradeOptions controls Installer.Upgrade.
+type UpgradeOptions struct {
+	// Checksum is the expected hex-encoded SHA-256 checksum of the new
+	// binary. Required.
+	Checksum string
+
+	// HealthCheck reports whether the restarted service is healthy. It is
+	// called every HealthInterval until it returns nil or HealthTimeout
+	// elapses. Nil treats a successful restart as healthy.
+	HealthCheck func(ctx context.Context) error
+
+	// HealthTimeout bounds the wait for the service to become healthy.
+	// Default: 60s
+	HealthTimeout time.Duration
+
+	// HealthInterval is the interval between health checks.
+	// Default: 2s
+	HealthInterval time.Duration
+}
+
+// ApplyDefaults sets default values for zero-valued fields.
+func (o *UpgradeOptions) ApplyDefaults() {
+	if o.HealthTimeout == 0 {
+		o.HealthTimeout = DefaultUpgradeHealthTimeout
+	}
+	if o.HealthInterval == 0 {
+		o.HealthInterval = DefaultUpgradeHealthInterval
+	}
+}
+
+// Validate checks that the options are usable.
+func (o *UpgradeOptions) Validate() error {
+	sum := strings.TrimSpace(o.Checksum)
+	if sum == "" {
+		return errors.New("packaging: upgrade: Checksum is required")
+	}
+	if _, err := hex.DecodeString(sum); err != nil || len(sum) != sha256.Size*2 {
+		return errors.New("packaging: upgrade: Checksum must be a hex-encoded SHA-256 checksum")
+	}
+	if o.HealthTimeout < 0 || o.HealthInterval < 0 {
+		return errors.New("packaging: upgrade: HealthTimeout and HealthInterval must not be negative")
+	}
+	return nil
+}
+
+// Upgrade replaces the installed binary with the one read from src and
+// restarts the service. The config directory, identity, and state in the
+// data directory are left untouched.
+//
+// Steps:
+//  1. Check root privileges and that plexd is installed
+//  2. Write the new binary next to the installed one and verify its checksum
+//  3. Keep the installed binary as <BinaryPath>.prev and move the new one in place
+//  4. Update the integrity baseline, if the agent has recorded one
+//  5. Restart the service and wait until the health check passes
+//
+// If the service does not become healthy within HealthTimeout, the previous
+// binary and baseline are restored, the service is restarted, and an error
+// wrapping ErrUpgradeRolledBack is returned.
+func (ins *Installer) Upgrade(ctx context.Context, src io.Reader, opts UpgradeOptions) error {
+	opts.ApplyDefaults()
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	want := strings.ToLower(strings.TrimSpace(opts.Checksum))
+
+	// 1. Check root and the installation
+	if !ins.root.IsRoot() {
+		return errors.New("packaging: upgrade requires root privileges")
+	}
+	if !ins.init.IsAvailable() {
+		return fmt.Errorf("packaging: %s is not available", ins.init.Name())
+	}
+	binPath := ins.cfg.BinaryPath
+	info, err := os.Stat(binPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("packaging: upgrade: plexd is not installed at %s", binPath)
+	} else if err != nil {
+		return fmt.Errorf("packaging: upgrade: stat binary: %w", err)
+	}
+
+	// 2. Write and verify the new binary
+	newPath := binPath + ".new"
+	if err := writeVerifiedBinary(newPath, src, want, info.Mode().Perm()); err != nil {
+		os.Remove(newPath)
+		return err
+	}
+
+	// 3. Swap binaries
+	prevPath := binPath + ".prev"
+	if err := os.Rename(binPath, prevPath); err != nil {
+		os.Remove(newPath)
+		return fmt.Errorf("packaging: upgrade: keep previous binary: %w", err)
+	}
+	if err := os.Rename(newPath, binPath); err != nil {
+		os.Remove(newPath)
+		if rbErr := os.Rename(prevPath, binPath); rbErr != nil {
+			return fmt.Errorf("packaging: upgrade: install binary: %w (restore previous binary: %v)", err, rbErr)
+		}
+		return fmt.Errorf("packaging: upgrade: install binary: %w", err)
+	}
+	ins.logger.Info("binary replaced", "path", binPath, "checksum", want, "previous", prevPath)
+
+	// 4. Update the integrity baseline
+	prevSum, err := ins.updateBinaryBaseline(want)
+	if err != nil {
+		ins.restorePreviousBinary(prevPath, "")
+		return err
+	}
+
+	// 5. Restart and wait for the service to become healthy
+	if err := ins.restartAndWait(ctx, opts); err != nil {
+		ins.logger.Error("upgraded service not healthy, rolling back", "error", err)
+		if rbErr := ins.restorePreviousBinary(prevPath, prevSum); rbErr != nil {
+			return fmt.Errorf("packaging: upgrade failed: %v; rollback failed: %w", err, rbErr)
+		}
+		if rbErr := ins.restartAndWait(ctx, opts); rbErr != nil {
+			return fmt.Errorf("packaging: upgrade failed: %v; previous binary restored but not healthy: %w", err, rbErr)
+		}
+		return fmt.Errorf("%w: %v", ErrUpgradeRolledBack, err)
+	}
+
+	if err := os.Remove(prevPath); err != nil {
+		ins.logger.Warn("failed to remove previous binary", "path", prevPath, "error", err)
+	}
+	ins.logger.Info("upgrade complete", "path", binPath)
+	return nil
+}
+
+// writeVerifiedBinary copies src to path and fails unless its SHA-256
+// checksum equals want.
+func writeVerifiedBinary(path string, src io.Reader, want string, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("packaging: upgrade: create binary: %w", err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), src); err != nil {
+		f.Close()
+		return fmt.Errorf("packaging: upgrade: write binary: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("packaging: upgrade: sync binary: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("packaging: upgrade: close binary: %w", err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("packaging: upgrade: checksum mismatch: got %s, want %s", got, want)
+	}
+	// OpenFile applies the umask.
+	if err := os.Chmod(path, perm); err != nil {
+		return fmt.Errorf("packaging: upgrade: chmod binary: %w", err)
+	}
+	return nil
+}
+
+// updateBinaryBaseline records checksum as the known-good checksum of the
+// binary, so the agent does not report the upgrade as an integrity
+// violation. It returns the previous baseline. Without a recorded baseline
+// nothing is written; the agent records one at startup.
+func (ins *Installer) updateBinaryBaseline(checksum string) (string, error) {
+	store, err := integrity.NewStore(ins.cfg.DataDir)
+	if err != nil {
+		return "", fmt.Errorf("packaging: upgrade: %w", err)
+	}
+	prev := store.Get(ins.cfg.BinaryPath)
+	if prev == "" {
+		return "", nil
+	}
+	if err := ins.setBinaryBaseline(store, checksum); err != nil {
+		return "", err
+	}
+	ins.logger.Info("integrity baseline updated", "path", ins.cfg.BinaryPath, "checksum", checksum)
+	return prev, nil
+}
+
+// setBinaryBaseline writes checksum to store. In rootless mode the store
+// file is handed back to the service user, since it is rewritten as root.
+func (ins *Installer) setBinaryBaseline(store *integrity.Store, checksum string) error {
+	if err := store.Set(ins.cfg.BinaryPath, checksum); err != nil {
+		return fmt.Errorf("packaging: upgrade: %w", err)
+	}
+	if !ins.cfg.Rootless {
+		return nil
+	}
+	owner, err := lookupServiceUser(ins.cfg.User)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(ins.cfg.DataDir, "checksums.json")
+	if err := os.Chown(path, owner.uid, owner.gid); err != nil {
+		return fmt.Errorf("packaging: rootless: chown %s: %w", path, err)
+	}
+	return nil
+}
+
+// restorePreviousBinary moves the previous binary back in place and, if
+// prevSum is set, restores the integrity baseline.
+func (ins *Installer) restorePreviousBinary(prevPath, prevSum string) error {
+	if err := os.Rename(prevPath, ins.cfg.BinaryPath); err != nil {
+		return fmt.Errorf("packaging: restore previous binary: %w", err)
+	}
+	ins.logger.Info("previous binary restored", "path", ins.cfg.BinaryPath)
+	if prevSum == "" {
+		return nil
+	}
+	store, err := integrity.NewStore(ins.cfg.DataDir)
+	if err != nil {
+		return fmt.Errorf("packaging: restore baseline: %w", err)
+	}
+	return ins.setBinaryBaseline(store, prevSum)
+}
+
+// restartAndWait restarts the service and polls opts.HealthCheck until it
+// passes or opts.HealthTimeout elapses.
+func (ins *Installer) restartAndWait(ctx context.Context, opts UpgradeOptions) error {
+	if err := ins.init.Restart(ins.cfg.ServiceName); err != nil {
+		return fmt.Errorf("packaging: restart %s: %w", ins.cfg.ServiceName, err)
+	}
+	ins.logger.Info("service restarted", "service", ins.cfg.ServiceName)
+	if opts.HealthCheck == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.HealthTimeout)
+	defer cancel()
+	ticker := time.NewTicker(opts.HealthInterval)
+	defer ticker.Stop()
+	for {
+		err := opts.HealthCheck(ctx)
+		if err == nil {
+			ins.logger.Info("service healthy", "service", ins.cfg.ServiceName)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("packaging: service not healthy after %s: %w", opts.HealthTimeout, err)
+		case <-ticker.C:
+		}
+	}
+}
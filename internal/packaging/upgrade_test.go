@@ -0,0 +1,171 @@
+package packaging
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/integrity"
+)
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// newUpgradeInstaller returns an Installer with "old" installed at its
+// binary path and an integrity baseline recorded for it.
+func newUpgradeInstaller(t *testing.T, systemd *mockSystemdController) (*Installer, InstallConfig) {
+	t.Helper()
+	ins, _ := newTestInstaller(t, InstallConfig{}, systemd, &mockRootChecker{isRoot: true})
+	cfg := ins.cfg
+	if err := os.MkdirAll(filepath.Dir(cfg.BinaryPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfg.BinaryPath, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(cfg.DataDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	store, err := integrity.NewStore(cfg.DataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set(cfg.BinaryPath, sha256Hex("old")); err != nil {
+		t.Fatal(err)
+	}
+	return ins, cfg
+}
+
+func baseline(t *testing.T, cfg InstallConfig) string {
+	t.Helper()
+	store, err := integrity.NewStore(cfg.DataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store.Get(cfg.BinaryPath)
+}
+
+func TestUpgrade_ReplacesBinaryAndRestarts(t *testing.T) {
+	systemd := &mockSystemdController{available: true}
+	ins, cfg := newUpgradeInstaller(t, systemd)
+
+	checks := 0
+	err := ins.Upgrade(context.Background(), strings.NewReader("new"), UpgradeOptions{
+		Checksum: sha256Hex("new"),
+		HealthCheck: func(context.Context) error {
+			if checks++; checks < 3 {
+				return errors.New("starting")
+			}
+			return nil
+		},
+		HealthInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Upgrade() = %v", err)
+	}
+
+	if data, _ := os.ReadFile(cfg.BinaryPath); string(data) != "new" {
+		t.Errorf("binary = %q, want %q", data, "new")
+	}
+	if info, err := os.Stat(cfg.BinaryPath); err != nil || info.Mode().Perm() != 0o755 {
+		t.Errorf("binary mode = %v, %v, want 0755", info.Mode().Perm(), err)
+	}
+	if _, err := os.Stat(cfg.BinaryPath + ".prev"); !os.IsNotExist(err) {
+		t.Errorf("previous binary kept after successful upgrade: %v", err)
+	}
+	if got := baseline(t, cfg); got != sha256Hex("new") {
+		t.Errorf("baseline = %s, want checksum of new binary", got)
+	}
+	if len(systemd.restartCalls) != 1 || systemd.restartCalls[0] != "plexd" {
+		t.Errorf("restart calls = %v, want [plexd]", systemd.restartCalls)
+	}
+	if checks != 3 {
+		t.Errorf("health checks = %d, want 3", checks)
+	}
+}
+
+func TestUpgrade_ChecksumMismatchLeavesBinary(t *testing.T) {
+	systemd := &mockSystemdController{available: true}
+	ins, cfg := newUpgradeInstaller(t, systemd)
+
+	err := ins.Upgrade(context.Background(), strings.NewReader("tampered"), UpgradeOptions{Checksum: sha256Hex("new")})
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Upgrade() = %v, want checksum mismatch", err)
+	}
+	if data, _ := os.ReadFile(cfg.BinaryPath); string(data) != "old" {
+		t.Errorf("binary = %q, want %q", data, "old")
+	}
+	if _, err := os.Stat(cfg.BinaryPath + ".new"); !os.IsNotExist(err) {
+		t.Errorf("partial binary left behind: %v", err)
+	}
+	if len(systemd.restartCalls) != 0 {
+		t.Errorf("restart calls = %v, want none", systemd.restartCalls)
+	}
+}
+
+func TestUpgrade_RollsBackWhenUnhealthy(t *testing.T) {
+	systemd := &mockSystemdController{available: true}
+	ins, cfg := newUpgradeInstaller(t, systemd)
+
+	healthCheck := func(context.Context) error {
+		if data, _ := os.ReadFile(cfg.BinaryPath); string(data) == "new" {
+			return errors.New("crash loop")
+		}
+		return nil
+	}
+	err := ins.Upgrade(context.Background(), strings.NewReader("new"), UpgradeOptions{
+		Checksum:       sha256Hex("new"),
+		HealthCheck:    healthCheck,
+		HealthTimeout:  20 * time.Millisecond,
+		HealthInterval: time.Millisecond,
+	})
+	if !errors.Is(err, ErrUpgradeRolledBack) || !strings.Contains(err.Error(), "crash loop") {
+		t.Fatalf("Upgrade() = %v, want ErrUpgradeRolledBack with cause", err)
+	}
+
+	if data, _ := os.ReadFile(cfg.BinaryPath); string(data) != "old" {
+		t.Errorf("binary = %q, want previous binary restored", data)
+	}
+	if got := baseline(t, cfg); got != sha256Hex("old") {
+		t.Errorf("baseline = %s, want checksum of previous binary", got)
+	}
+	if len(systemd.restartCalls) != 2 {
+		t.Errorf("restart calls = %v, want upgrade and rollback restarts", systemd.restartCalls)
+	}
+}
+
+func TestUpgrade_Preconditions(t *testing.T) {
+	tests := []struct {
+		name    string
+		root    bool
+		install bool
+		sum     string
+		wantErr string
+	}{
+		{"no checksum", true, true, "", "Checksum is required"},
+		{"bad checksum", true, true, "abc", "hex-encoded SHA-256"},
+		{"not root", false, true, sha256Hex("new"), "root privileges"},
+		{"not installed", true, false, sha256Hex("new"), "not installed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ins, _ := newTestInstaller(t, InstallConfig{}, &mockSystemdController{available: true}, &mockRootChecker{isRoot: tt.root})
+			if tt.install {
+				os.MkdirAll(filepath.Dir(ins.cfg.BinaryPath), 0o755)
+				os.WriteFile(ins.cfg.BinaryPath, []byte("old"), 0o755)
+			}
+			err := ins.Upgrade(context.Background(), strings.NewReader("new"), UpgradeOptions{Checksum: tt.sum})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Upgrade() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
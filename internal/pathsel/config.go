@@ -0,0 +1,182 @@
+// Package pathsel selects the endpoint each WireGuard peer is reached at.
+// It probes every candidate endpoint of a peer — its LAN addresses, its
+// public endpoint, and its relay — programs the best one, and switches
+// when the active path degrades or a clearly better one appears. When no
+// candidate answers at all, a peer can fall back to a relay over the
+// control plane until a candidate recovers.
+package pathsel
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultInterval is the default time between probe rounds.
+const DefaultInterval = 10 * time.Second
+
+// DefaultTimeout is the default time to wait for a probe reply.
+const DefaultTimeout = time.Second
+
+// DefaultPort is the default UDP port of the underlay echo responder.
+const DefaultPort = 51831
+
+// DefaultWindow is the default number of probe results kept per candidate.
+const DefaultWindow = 5
+
+// DefaultMaxLoss is the default loss ratio above which a path is degraded.
+const DefaultMaxLoss = 0.5
+
+// DefaultRelayPenalty is the default score penalty of relay endpoints.
+const DefaultRelayPenalty = 30 * time.Millisecond
+
+// DefaultSwitchMargin is the default score improvement required to leave a
+// working path.
+const DefaultSwitchMargin = 10 * time.Millisecond
+
+// DefaultHoldDown is the default minimum time between two switches of a
+// peer away from a working path.
+const DefaultHoldDown = time.Minute
+
+// DefaultFallbackAfter is the default number of consecutive probe rounds
+// without an answer from any candidate before a peer uses the fallback
+// relay.
+const DefaultFallbackAfter = 3
+
+// ReportKey is the node API report key the selected paths are written to.
+const ReportKey = "mesh.paths"
+
+// Config holds the configuration for path selection.
+type Config struct {
+	// Enabled controls whether candidate endpoints are probed and selected.
+	// When disabled, the endpoint chosen by the control plane is used.
+	// Default: true (set by ApplyDefaults).
+	Enabled bool
+
+	// Interval is the time between probe rounds. Must be at least 1s.
+	// Default: 10s
+	Interval time.Duration
+
+	// Timeout is the time to wait for a probe reply. Must be positive and
+	// less than Interval.
+	// Default: 1s
+	Timeout time.Duration
+
+	// Port is the UDP port the underlay echo responder listens on and
+	// candidates are probed at. All nodes of a mesh must use the same port.
+	// Default: 51831
+	Port int
+
+	// Window is the number of recent probe results a candidate is scored
+	// on. A working path is only left for a candidate with a full window.
+	// Must be between 1 and 100.
+	// Default: 5
+	Window int
+
+	// MaxLoss is the loss ratio within the window above which the active
+	// path is considered degraded and replaced at once. Must be in (0, 1].
+	// Default: 0.5
+	MaxLoss float64
+
+	// RelayPenalty is added to the score of relay endpoints. Probes measure
+	// only the way to the relay, not on to the peer, and relays cost
+	// bandwidth, so a direct path is preferred unless it is much slower.
+	// Must not be negative.
+	// Default: 30ms
+	RelayPenalty time.Duration
+
+	// SwitchMargin is how much lower a candidate's score must be to replace
+	// a working active path. Must not be negative.
+	// Default: 10ms
+	SwitchMargin time.Duration
+
+	// HoldDown is the minimum time after a switch before a peer is moved
+	// away from a working path again. A degraded path is replaced
+	// regardless. Must not be negative.
+	// Default: 1m
+	HoldDown time.Duration
+
+	// FallbackAfter is the number of consecutive probe rounds in which no
+	// candidate of a peer answered before the peer is moved to the fallback
+	// relay, if one is set. Must be between 1 and Window.
+	// Default: 3
+	FallbackAfter int
+}
+
+// ApplyDefaults sets default values for zero-valued fields.
+// On a zero-valued Config, Enabled defaults to true.
+// To disable path selection, set Enabled=false before or after calling ApplyDefaults.
+func (c *Config) ApplyDefaults() {
+	// Enabled defaults to true for zero-valued Config. If any field is
+	// non-zero, the caller constructed the config explicitly and we respect
+	// Enabled as-is.
+	if c.Interval == 0 && c.Timeout == 0 && c.Port == 0 && c.Window == 0 &&
+		c.MaxLoss == 0 && c.RelayPenalty == 0 && c.SwitchMargin == 0 && c.HoldDown == 0 &&
+		c.FallbackAfter == 0 {
+		c.Enabled = true
+	}
+	if c.Interval == 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.Port == 0 {
+		c.Port = DefaultPort
+	}
+	if c.Window == 0 {
+		c.Window = DefaultWindow
+	}
+	if c.MaxLoss == 0 {
+		c.MaxLoss = DefaultMaxLoss
+	}
+	if c.RelayPenalty == 0 {
+		c.RelayPenalty = DefaultRelayPenalty
+	}
+	if c.SwitchMargin == 0 {
+		c.SwitchMargin = DefaultSwitchMargin
+	}
+	if c.HoldDown == 0 {
+		c.HoldDown = DefaultHoldDown
+	}
+	if c.FallbackAfter == 0 {
+		c.FallbackAfter = min(DefaultFallbackAfter, c.Window)
+	}
+}
+
+// Validate checks that configuration values are within acceptable ranges.
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Interval < time.Second {
+		return errors.New("pathsel: config: Interval must be at least 1s")
+	}
+	if c.Timeout <= 0 {
+		return errors.New("pathsel: config: Timeout must be positive")
+	}
+	if c.Timeout >= c.Interval {
+		return errors.New("pathsel: config: Timeout must be less than Interval")
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return errors.New("pathsel: config: Port must be between 1 and 65535")
+	}
+	if c.Window < 1 || c.Window > 100 {
+		return errors.New("pathsel: config: Window must be between 1 and 100")
+	}
+	if c.MaxLoss <= 0 || c.MaxLoss > 1 {
+		return errors.New("pathsel: config: MaxLoss must be greater than 0 and at most 1")
+	}
+	if c.RelayPenalty < 0 {
+		return errors.New("pathsel: config: RelayPenalty must not be negative")
+	}
+	if c.SwitchMargin < 0 {
+		return errors.New("pathsel: config: SwitchMargin must not be negative")
+	}
+	if c.HoldDown < 0 {
+		return errors.New("pathsel: config: HoldDown must not be negative")
+	}
+	if c.FallbackAfter < 1 || c.FallbackAfter > c.Window {
+		return errors.New("pathsel: config: FallbackAfter must be between 1 and Window")
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package pathsel
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfig_Defaults(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+
+	if !cfg.Enabled {
+		t.Error("Enabled = false, want true")
+	}
+	if cfg.Interval != DefaultInterval {
+		t.Errorf("Interval = %v, want %v", cfg.Interval, DefaultInterval)
+	}
+	if cfg.Timeout != DefaultTimeout {
+		t.Errorf("Timeout = %v, want %v", cfg.Timeout, DefaultTimeout)
+	}
+	if cfg.Port != DefaultPort {
+		t.Errorf("Port = %d, want %d", cfg.Port, DefaultPort)
+	}
+	if cfg.Window != DefaultWindow {
+		t.Errorf("Window = %d, want %d", cfg.Window, DefaultWindow)
+	}
+	if cfg.MaxLoss != DefaultMaxLoss {
+		t.Errorf("MaxLoss = %v, want %v", cfg.MaxLoss, DefaultMaxLoss)
+	}
+	if cfg.RelayPenalty != DefaultRelayPenalty {
+		t.Errorf("RelayPenalty = %v, want %v", cfg.RelayPenalty, DefaultRelayPenalty)
+	}
+	if cfg.SwitchMargin != DefaultSwitchMargin {
+		t.Errorf("SwitchMargin = %v, want %v", cfg.SwitchMargin, DefaultSwitchMargin)
+	}
+	if cfg.HoldDown != DefaultHoldDown {
+		t.Errorf("HoldDown = %v, want %v", cfg.HoldDown, DefaultHoldDown)
+	}
+	if cfg.FallbackAfter != DefaultFallbackAfter {
+		t.Errorf("FallbackAfter = %d, want %d", cfg.FallbackAfter, DefaultFallbackAfter)
+	}
+}
+
+func TestConfig_DefaultFallbackAfterFitsWindow(t *testing.T) {
+	cfg := Config{Window: 2}
+	cfg.ApplyDefaults()
+
+	if cfg.FallbackAfter != 2 {
+		t.Errorf("FallbackAfter = %d, want 2", cfg.FallbackAfter)
+	}
+}
+
+func TestConfig_DefaultsPreserveExplicitDisabled(t *testing.T) {
+	cfg := Config{Enabled: false, HoldDown: time.Minute}
+	cfg.ApplyDefaults()
+
+	if cfg.Enabled {
+		t.Error("Enabled = true, want false when explicitly configured with other non-zero fields")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := func(mod func(*Config)) Config {
+		cfg := Config{}
+		cfg.ApplyDefaults()
+		mod(&cfg)
+		return cfg
+	}
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"valid", valid(func(*Config) {}), ""},
+		{"interval too short", valid(func(c *Config) { c.Interval = 500 * time.Millisecond; c.Timeout = 100 * time.Millisecond }), "pathsel: config: Interval must be at least 1s"},
+		{"timeout not positive", valid(func(c *Config) { c.Timeout = -time.Second }), "pathsel: config: Timeout must be positive"},
+		{"timeout not below interval", valid(func(c *Config) { c.Timeout = c.Interval }), "pathsel: config: Timeout must be less than Interval"},
+		{"port out of range", valid(func(c *Config) { c.Port = 70000 }), "pathsel: config: Port must be between 1 and 65535"},
+		{"window too large", valid(func(c *Config) { c.Window = 101 }), "pathsel: config: Window must be between 1 and 100"},
+		{"max loss above one", valid(func(c *Config) { c.MaxLoss = 1.5 }), "pathsel: config: MaxLoss must be greater than 0 and at most 1"},
+		{"negative relay penalty", valid(func(c *Config) { c.RelayPenalty = -time.Millisecond }), "pathsel: config: RelayPenalty must not be negative"},
+		{"negative switch margin", valid(func(c *Config) { c.SwitchMargin = -time.Millisecond }), "pathsel: config: SwitchMargin must not be negative"},
+		{"negative hold down", valid(func(c *Config) { c.HoldDown = -time.Second }), "pathsel: config: HoldDown must not be negative"},
+		{"fallback after exceeds window", valid(func(c *Config) { c.FallbackAfter = c.Window + 1 }), "pathsel: config: FallbackAfter must be between 1 and Window"},
+		{"disabled skips checks", Config{Enabled: false, Port: -1}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Validate() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
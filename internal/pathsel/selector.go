@@ -0,0 +1,688 @@
+package pathsel
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/meshdiag"
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+// maxConcurrentProbes bounds the number of candidates probed in parallel.
+const maxConcurrentProbes = 16
+
+// maxDecisions is the number of recent decisions kept for the report.
+const maxDecisions = 64
+
+// lossPenalty is added to a candidate's score per unit of loss ratio, so
+// that a lossy path scores worse than a slower clean one.
+const lossPenalty = 500 * time.Millisecond
+
+// Kind is the kind of a candidate endpoint.
+type Kind string
+
+const (
+	KindLAN    Kind = "lan"
+	KindPublic Kind = "public"
+	KindRelay  Kind = "relay"
+	// KindFallback is the local endpoint of the fallback relay. It is never
+	// a candidate; a peer uses it only while no candidate answers probes.
+	KindFallback Kind = "fallback"
+)
+
+// rank orders kinds for breaking score ties: LAN before public before relay.
+func (k Kind) rank() int {
+	switch k {
+	case KindLAN:
+		return 0
+	case KindPublic:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Reasons recorded with a Decision.
+const (
+	// ReasonInitial is the first path of a peer, chosen before probes.
+	ReasonInitial = "initial"
+	// ReasonWithdrawn replaces an active endpoint that is no longer a
+	// candidate.
+	ReasonWithdrawn = "withdrawn"
+	// ReasonDegraded replaces an active path whose loss exceeds MaxLoss.
+	ReasonDegraded = "degraded"
+	// ReasonBetter replaces a working path with one that scores better by
+	// more than SwitchMargin.
+	ReasonBetter = "better"
+	// ReasonUnreachable moves a peer to the fallback relay after no
+	// candidate answered FallbackAfter probes in a row.
+	ReasonUnreachable = "unreachable"
+	// ReasonRecovered moves a peer off the fallback relay once a candidate
+	// is usable again.
+	ReasonRecovered = "recovered"
+)
+
+// Prober measures the round-trip time to an underlay host.
+// *meshdiag.UDPProber satisfies this interface.
+type Prober interface {
+	Probe(ctx context.Context, host string) (time.Duration, error)
+}
+
+// EndpointProgrammer points a WireGuard peer at an endpoint.
+// *wireguard.Manager satisfies this interface.
+type EndpointProgrammer interface {
+	SetPeerEndpoint(peerID, endpoint string) error
+}
+
+// FallbackRelay relays the WireGuard packets of a peer through the control
+// plane. *wsrelay.Client satisfies this interface.
+type FallbackRelay interface {
+	// Open starts relaying the packets of the peer and returns the local
+	// endpoint to program for it.
+	Open(peerID string) (string, error)
+	// Close stops relaying the packets of the peer.
+	Close(peerID string)
+}
+
+// ReportWriter stores a node API report entry and syncs it to the control
+// plane. *nodeapi.Server satisfies this interface.
+type ReportWriter interface {
+	WriteReport(key string, payload json.RawMessage) error
+}
+
+// Decision records a change of a peer's active endpoint.
+type Decision struct {
+	PeerID   string    `json:"peer_id"`
+	From     string    `json:"from,omitempty"`
+	FromKind Kind      `json:"from_kind,omitempty"`
+	To       string    `json:"to"`
+	ToKind   Kind      `json:"to_kind"`
+	Reason   string    `json:"reason"`
+	At       time.Time `json:"at"`
+}
+
+// CandidateStatus is the probe summary of one candidate endpoint.
+type CandidateStatus struct {
+	Endpoint string `json:"endpoint"`
+	Kind     Kind   `json:"kind"`
+	Samples  int    `json:"samples"`
+	// RTTNano is the mean round-trip time of the answered probes in the
+	// window, or -1 if none was answered.
+	RTTNano int64   `json:"rtt_nano"`
+	Loss    float64 `json:"loss"`
+	// ScoreNano is the candidate's score; lower is better. It is -1 while
+	// the candidate is unusable.
+	ScoreNano int64 `json:"score_nano"`
+}
+
+// PeerPath is the selected path of one peer and its candidates.
+type PeerPath struct {
+	PeerID     string            `json:"peer_id"`
+	Endpoint   string            `json:"endpoint"`
+	Kind       Kind              `json:"kind"`
+	SelectedAt time.Time         `json:"selected_at"`
+	Candidates []CandidateStatus `json:"candidates"`
+}
+
+// Report is the path selection state written under ReportKey.
+type Report struct {
+	NodeID    string     `json:"node_id"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Peers     []PeerPath `json:"peers"`
+	Decisions []Decision `json:"decisions"`
+}
+
+// candidate is one endpoint a peer may be reached at.
+type candidate struct {
+	endpoint string
+	kind     Kind
+	// samples holds the round-trip times of the last probes, oldest first;
+	// a lost probe is recorded as -1.
+	samples []time.Duration
+}
+
+func (c *candidate) record(rtt time.Duration, window int) {
+	c.samples = append(c.samples, rtt)
+	if len(c.samples) > window {
+		c.samples = slices.Delete(c.samples, 0, len(c.samples)-window)
+	}
+}
+
+// stats returns the mean round-trip time of the answered probes and the
+// loss ratio. ok is false if no probe was answered.
+func (c *candidate) stats() (rtt time.Duration, loss float64, ok bool) {
+	var sum time.Duration
+	var answered int
+	for _, s := range c.samples {
+		if s >= 0 {
+			sum += s
+			answered++
+		}
+	}
+	if len(c.samples) == 0 {
+		return 0, 0, false
+	}
+	loss = float64(len(c.samples)-answered) / float64(len(c.samples))
+	if answered == 0 {
+		return 0, loss, false
+	}
+	return sum / time.Duration(answered), loss, true
+}
+
+// lostStreak returns the number of most recent probes lost in a row.
+func (c *candidate) lostStreak() int {
+	n := 0
+	for i := len(c.samples) - 1; i >= 0 && c.samples[i] < 0; i-- {
+		n++
+	}
+	return n
+}
+
+// score returns the candidate's score, lower being better, and whether it
+// is usable: it has answered a probe and its loss is at most MaxLoss.
+func (c *candidate) score(cfg *Config) (time.Duration, bool) {
+	rtt, loss, ok := c.stats()
+	if !ok || loss > cfg.MaxLoss {
+		return 0, false
+	}
+	s := rtt + time.Duration(loss*float64(lossPenalty))
+	if c.kind == KindRelay {
+		s += cfg.RelayPenalty
+	}
+	return s, true
+}
+
+// peerState is the selection state of one peer.
+type peerState struct {
+	candidates []*candidate
+	active     string
+	activeKind Kind
+	selectedAt time.Time
+}
+
+func (p *peerState) candidate(endpoint string) *candidate {
+	for _, c := range p.candidates {
+		if c.endpoint == endpoint {
+			return c
+		}
+	}
+	return nil
+}
+
+// best returns the usable candidate with the lowest score, or nil.
+func (p *peerState) best(cfg *Config) (*candidate, time.Duration) {
+	var best *candidate
+	var bestScore time.Duration
+	for _, c := range p.candidates {
+		s, ok := c.score(cfg)
+		if !ok {
+			continue
+		}
+		if best == nil || s < bestScore || (s == bestScore && c.kind.rank() < best.kind.rank()) {
+			best, bestScore = c, s
+		}
+	}
+	return best, bestScore
+}
+
+// fallback returns the candidate to use before any probe answered: the
+// public endpoint the control plane offers, else the relay, else a LAN
+// address.
+func (p *peerState) fallback() *candidate {
+	for _, kind := range []Kind{KindPublic, KindRelay, KindLAN} {
+		for _, c := range p.candidates {
+			if c.kind == kind {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// Selector probes the candidate endpoints of every peer and keeps each peer
+// on the best one.
+type Selector struct {
+	cfg        Config
+	prober     Prober
+	programmer EndpointProgrammer
+	nodeID     string
+	report     ReportWriter
+	fallback   FallbackRelay
+	logger     *slog.Logger
+
+	mu        sync.Mutex
+	peers     map[string]*peerState
+	decisions []Decision
+	updatedAt time.Time
+}
+
+// NewSelector creates a new Selector. Config defaults are applied
+// automatically.
+func NewSelector(cfg Config, prober Prober, programmer EndpointProgrammer, nodeID string, logger *slog.Logger) *Selector {
+	cfg.ApplyDefaults()
+	return &Selector{
+		cfg:        cfg,
+		prober:     prober,
+		programmer: programmer,
+		nodeID:     nodeID,
+		logger:     logger.With("component", "pathsel"),
+		peers:      make(map[string]*peerState),
+	}
+}
+
+// SetReportWriter sets where the report is written after each probe round,
+// under ReportKey. It must be called before Run.
+func (s *Selector) SetReportWriter(w ReportWriter) {
+	s.report = w
+}
+
+// SetFallbackRelay sets the relay a peer is moved to when none of its
+// candidates answers FallbackAfter probes in a row. Without one, such a
+// peer stays on its last path. It must be called before Run.
+func (s *Selector) SetFallbackRelay(r FallbackRelay) {
+	s.fallback = r
+}
+
+// SetPeerEndpoints replaces the candidate endpoints of a peer: its LAN
+// addresses, its public endpoint, and its relay endpoint. Probe results of
+// endpoints that remain candidates are kept. A new peer, or one whose
+// active endpoint was withdrawn, is programmed at once. A peer on the
+// fallback relay stays there until a candidate is usable.
+func (s *Selector) SetPeerEndpoints(pe api.PeerEndpoint) {
+	s.mu.Lock()
+	st, ok := s.peers[pe.PeerID]
+	if !ok {
+		st = &peerState{}
+		s.peers[pe.PeerID] = st
+	}
+
+	var next []*candidate
+	add := func(endpoint string, kind Kind) {
+		if endpoint == "" || slices.ContainsFunc(next, func(c *candidate) bool { return c.endpoint == endpoint }) {
+			return
+		}
+		c := st.candidate(endpoint)
+		if c == nil || c.kind != kind {
+			c = &candidate{endpoint: endpoint, kind: kind}
+		}
+		next = append(next, c)
+	}
+	for _, ep := range pe.LANEndpoints {
+		add(ep, KindLAN)
+	}
+	add(pe.Endpoint, KindPublic)
+	add(pe.RelayEndpoint, KindRelay)
+	st.candidates = next
+
+	var d *Decision
+	if st.activeKind != KindFallback && st.candidate(st.active) == nil {
+		reason := ReasonInitial
+		if st.active != "" {
+			reason = ReasonWithdrawn
+		}
+		d = s.choose(pe.PeerID, st, reason)
+	}
+	s.mu.Unlock()
+
+	if d != nil {
+		s.apply(*d)
+	}
+}
+
+// RemovePeer stops selecting paths for the peer and closes its fallback
+// relay if it uses one.
+func (s *Selector) RemovePeer(peerID string) {
+	s.mu.Lock()
+	st, ok := s.peers[peerID]
+	delete(s.peers, peerID)
+	s.mu.Unlock()
+
+	if ok && st.activeKind == KindFallback {
+		s.fallback.Close(peerID)
+	}
+}
+
+// ReconcileHandler returns a handler that drops peers removed from the
+// desired state.
+func (s *Selector) ReconcileHandler() reconcile.ReconcileHandler {
+	return func(_ context.Context, _ *api.StateResponse, diff reconcile.StateDiff) error {
+		for _, id := range diff.PeersToRemove {
+			s.RemovePeer(id)
+		}
+		return nil
+	}
+}
+
+// Run probes all candidates immediately and then every Interval until ctx
+// is cancelled. Meanwhile it answers the probes of other nodes with an echo
+// responder on Port at all local addresses. It returns nil immediately when
+// path selection is disabled. Run always returns nil.
+func (s *Selector) Run(ctx context.Context) error {
+	if !s.cfg.Enabled {
+		s.logger.Info("path selection disabled")
+		return nil
+	}
+	s.logger.Info("path selection started", "interval", s.cfg.Interval.String())
+
+	responder := meshdiag.NewResponder("", s.cfg.Port, s.logger)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = responder.Run(ctx, s.cfg.Interval)
+	}()
+	defer func() { <-done }()
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		s.ProbeAll(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProbeAll probes every candidate once, re-evaluates every peer, programs
+// the paths that changed, and writes the report if a writer is set.
+func (s *Selector) ProbeAll(ctx context.Context) {
+	type target struct{ peerID, endpoint string }
+	s.mu.Lock()
+	var targets []target
+	for id, st := range s.peers {
+		for _, c := range st.candidates {
+			targets = append(targets, target{id, c.endpoint})
+		}
+	}
+	s.mu.Unlock()
+
+	type result struct {
+		target
+		rtt time.Duration
+	}
+	results := make(chan result, len(targets))
+	sem := make(chan struct{}, maxConcurrentProbes)
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			rtt := time.Duration(-1)
+			host, _, err := net.SplitHostPort(t.endpoint)
+			if err == nil {
+				var r time.Duration
+				if r, err = s.prober.Probe(ctx, host); err == nil {
+					rtt = r
+				}
+			}
+			if err != nil {
+				s.logger.Debug("path probe failed", "peer_id", t.peerID, "endpoint", t.endpoint, "error", err)
+			}
+			results <- result{target: t, rtt: rtt}
+		}()
+	}
+	wg.Wait()
+	close(results)
+	if ctx.Err() != nil {
+		return
+	}
+
+	s.mu.Lock()
+	for r := range results {
+		// Skip candidates removed while the probe was in flight.
+		st, ok := s.peers[r.peerID]
+		if !ok {
+			continue
+		}
+		if c := st.candidate(r.endpoint); c != nil {
+			c.record(r.rtt, s.cfg.Window)
+		}
+	}
+	var decisions []Decision
+	for id, st := range s.peers {
+		if d := s.evaluate(id, st, time.Now()); d != nil {
+			decisions = append(decisions, *d)
+		}
+	}
+	s.updatedAt = time.Now()
+	s.mu.Unlock()
+
+	slices.SortFunc(decisions, func(a, b Decision) int { return cmp.Compare(a.PeerID, b.PeerID) })
+	for _, d := range decisions {
+		s.apply(d)
+	}
+
+	if s.report == nil {
+		return
+	}
+	payload, err := json.Marshal(s.Report())
+	if err != nil {
+		s.logger.Error("path report marshal failed", "error", err)
+		return
+	}
+	if err := s.report.WriteReport(ReportKey, payload); err != nil {
+		s.logger.Warn("path report failed", "error", err)
+	}
+}
+
+// evaluate decides whether the peer should move to another candidate. It
+// must be called with s.mu held.
+func (s *Selector) evaluate(peerID string, st *peerState, now time.Time) *Decision {
+	best, bestScore := st.best(&s.cfg)
+	if st.activeKind == KindFallback {
+		if best == nil {
+			return nil
+		}
+		return s.decide(peerID, st, best, ReasonRecovered, now)
+	}
+
+	active := st.candidate(st.active)
+	if active == nil {
+		// The last attempt to program a path failed; try again.
+		return s.choose(peerID, st, ReasonInitial)
+	}
+	if best == nil {
+		if s.unreachable(st) {
+			return s.decideFallback(peerID, st, now)
+		}
+		return nil
+	}
+	if best == active {
+		return nil
+	}
+	activeScore, usable := active.score(&s.cfg)
+	if !usable {
+		if len(active.samples) == 0 {
+			return nil
+		}
+		return s.decide(peerID, st, best, ReasonDegraded, now)
+	}
+	if len(best.samples) < s.cfg.Window || bestScore+s.cfg.SwitchMargin >= activeScore {
+		return nil
+	}
+	if now.Sub(st.selectedAt) < s.cfg.HoldDown {
+		return nil
+	}
+	return s.decide(peerID, st, best, ReasonBetter, now)
+}
+
+// choose picks a path for a peer without a working active endpoint: the
+// best usable candidate, else the fallback. It must be called with s.mu
+// held.
+func (s *Selector) choose(peerID string, st *peerState, reason string) *Decision {
+	c, _ := st.best(&s.cfg)
+	if c == nil {
+		c = st.fallback()
+	}
+	if c == nil {
+		return nil
+	}
+	return s.decide(peerID, st, c, reason, time.Now())
+}
+
+// decide makes c the peer's active candidate and returns the decision to
+// apply. It must be called with s.mu held.
+func (s *Selector) decide(peerID string, st *peerState, c *candidate, reason string, now time.Time) *Decision {
+	d := &Decision{
+		PeerID:   peerID,
+		From:     st.active,
+		FromKind: st.activeKind,
+		To:       c.endpoint,
+		ToKind:   c.kind,
+		Reason:   reason,
+		At:       now,
+	}
+	st.active, st.activeKind, st.selectedAt = c.endpoint, c.kind, now
+	return d
+}
+
+// unreachable reports whether the peer should use the fallback relay: one
+// is set and no candidate answered the last FallbackAfter probes. It must
+// be called with s.mu held.
+func (s *Selector) unreachable(st *peerState) bool {
+	if s.fallback == nil || len(st.candidates) == 0 {
+		return false
+	}
+	for _, c := range st.candidates {
+		if c.lostStreak() < s.cfg.FallbackAfter {
+			return false
+		}
+	}
+	return true
+}
+
+// decideFallback moves the peer to the fallback relay and returns the
+// decision to apply. Its endpoint is only known once apply opens the relay.
+// It must be called with s.mu held.
+func (s *Selector) decideFallback(peerID string, st *peerState, now time.Time) *Decision {
+	d := &Decision{
+		PeerID:   peerID,
+		From:     st.active,
+		FromKind: st.activeKind,
+		ToKind:   KindFallback,
+		Reason:   ReasonUnreachable,
+		At:       now,
+	}
+	st.active, st.activeKind, st.selectedAt = "", KindFallback, now
+	return d
+}
+
+// apply programs a decision, opening the fallback relay when moving to it
+// and closing it when moving off it. If opening or programming fails, the
+// peer is reverted to its previous endpoint so the next round retries.
+func (s *Selector) apply(d Decision) {
+	if d.ToKind == KindFallback {
+		endpoint, err := s.fallback.Open(d.PeerID)
+		if err != nil {
+			s.logger.Warn("failed to open fallback relay", "peer_id", d.PeerID, "error", err)
+			s.revert(d)
+			return
+		}
+		d.To = endpoint
+		s.mu.Lock()
+		st, ok := s.peers[d.PeerID]
+		current := ok && st.active == "" && st.activeKind == KindFallback
+		if current {
+			st.active = endpoint
+		}
+		s.mu.Unlock()
+		if !current {
+			// The peer was removed while the relay was opened.
+			s.fallback.Close(d.PeerID)
+			return
+		}
+	}
+
+	if err := s.programmer.SetPeerEndpoint(d.PeerID, d.To); err != nil {
+		s.logger.Warn("failed to program peer path",
+			"peer_id", d.PeerID,
+			"endpoint", d.To,
+			"kind", d.ToKind,
+			"error", err,
+		)
+		s.revert(d)
+		if d.ToKind == KindFallback {
+			s.fallback.Close(d.PeerID)
+		}
+		return
+	}
+	if d.FromKind == KindFallback {
+		s.fallback.Close(d.PeerID)
+	}
+
+	s.mu.Lock()
+	s.decisions = append(s.decisions, d)
+	if len(s.decisions) > maxDecisions {
+		s.decisions = slices.Delete(s.decisions, 0, len(s.decisions)-maxDecisions)
+	}
+	s.mu.Unlock()
+
+	s.logger.Info("peer path selected",
+		"peer_id", d.PeerID,
+		"endpoint", d.To,
+		"kind", d.ToKind,
+		"previous", d.From,
+		"previous_kind", d.FromKind,
+		"reason", d.Reason,
+	)
+}
+
+// revert restores the peer's endpoint from before d unless it has changed
+// since.
+func (s *Selector) revert(d Decision) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st, ok := s.peers[d.PeerID]; ok && st.active == d.To && st.activeKind == d.ToKind {
+		st.active, st.activeKind = d.From, d.FromKind
+	}
+}
+
+// Report returns the selected path and candidates of every peer, sorted by
+// peer ID, and the most recent decisions, oldest first.
+func (s *Selector) Report() Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := Report{
+		NodeID:    s.nodeID,
+		UpdatedAt: s.updatedAt,
+		Peers:     make([]PeerPath, 0, len(s.peers)),
+		Decisions: slices.Clone(s.decisions),
+	}
+	if r.Decisions == nil {
+		r.Decisions = []Decision{}
+	}
+	for id, st := range s.peers {
+		p := PeerPath{
+			PeerID:     id,
+			Endpoint:   st.active,
+			Kind:       st.activeKind,
+			SelectedAt: st.selectedAt,
+			Candidates: make([]CandidateStatus, 0, len(st.candidates)),
+		}
+		for _, c := range st.candidates {
+			cs := CandidateStatus{Endpoint: c.endpoint, Kind: c.kind, Samples: len(c.samples), RTTNano: -1, ScoreNano: -1}
+			rtt, loss, ok := c.stats()
+			cs.Loss = loss
+			if ok {
+				cs.RTTNano = rtt.Nanoseconds()
+			}
+			if score, ok := c.score(&s.cfg); ok {
+				cs.ScoreNano = score.Nanoseconds()
+			}
+			p.Candidates = append(p.Candidates, cs)
+		}
+		r.Peers = append(r.Peers, p)
+	}
+	slices.SortFunc(r.Peers, func(a, b PeerPath) int { return cmp.Compare(a.PeerID, b.PeerID) })
+	return r
+}
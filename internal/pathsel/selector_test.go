@@ -0,0 +1,482 @@
+package pathsel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// mockProber answers probes to each host with a fixed round-trip time;
+// hosts without an entry fail.
+type mockProber struct {
+	mu  sync.Mutex
+	rtt map[string]time.Duration
+}
+
+func (m *mockProber) set(host string, rtt time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rtt == nil {
+		m.rtt = make(map[string]time.Duration)
+	}
+	m.rtt[host] = rtt
+}
+
+func (m *mockProber) fail(host string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.rtt, host)
+}
+
+func (m *mockProber) Probe(_ context.Context, host string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rtt, ok := m.rtt[host]
+	if !ok {
+		return 0, errors.New("no reply")
+	}
+	return rtt, nil
+}
+
+// mockProgrammer records programmed endpoints.
+type mockProgrammer struct {
+	mu    sync.Mutex
+	calls []string // "peerID endpoint"
+	err   error
+}
+
+func (m *mockProgrammer) SetPeerEndpoint(peerID, endpoint string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.calls = append(m.calls, peerID+" "+endpoint)
+	return nil
+}
+
+func (m *mockProgrammer) last() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.calls) == 0 {
+		return ""
+	}
+	return m.calls[len(m.calls)-1]
+}
+
+func (m *mockProgrammer) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.calls)
+}
+
+type mockReportWriter struct {
+	mu      sync.Mutex
+	key     string
+	payload json.RawMessage
+}
+
+func (m *mockReportWriter) WriteReport(key string, payload json.RawMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.key, m.payload = key, payload
+	return nil
+}
+
+// mockFallbackRelay hands out a fixed local endpoint and records which
+// peers it relays.
+type mockFallbackRelay struct {
+	mu      sync.Mutex
+	open    map[string]bool
+	opened  int
+	openErr error
+}
+
+func (m *mockFallbackRelay) Open(peerID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.openErr != nil {
+		return "", m.openErr
+	}
+	if m.open == nil {
+		m.open = make(map[string]bool)
+	}
+	m.open[peerID] = true
+	m.opened++
+	return "127.0.0.1:40000", nil
+}
+
+func (m *mockFallbackRelay) Close(peerID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.open, peerID)
+}
+
+func (m *mockFallbackRelay) isOpen(peerID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.open[peerID]
+}
+
+var testEndpoints = api.PeerEndpoint{
+	PeerID:        "peer-1",
+	Endpoint:      "203.0.113.5:51820",
+	RelayEndpoint: "198.51.100.10:51821",
+	LANEndpoints:  []string{"192.168.1.5:51820"},
+}
+
+func newTestSelector(cfg Config) (*Selector, *mockProber, *mockProgrammer) {
+	if cfg.Window == 0 {
+		cfg.Window = 2
+	}
+	if cfg.HoldDown == 0 {
+		cfg.HoldDown = time.Nanosecond
+	}
+	cfg.Enabled = true
+	prober := &mockProber{}
+	prog := &mockProgrammer{}
+	return NewSelector(cfg, prober, prog, "node-1", discardLogger()), prober, prog
+}
+
+func probeRounds(s *Selector, n int) {
+	for range n {
+		s.ProbeAll(context.Background())
+	}
+}
+
+func activePath(t *testing.T, s *Selector, peerID string) PeerPath {
+	t.Helper()
+	for _, p := range s.Report().Peers {
+		if p.PeerID == peerID {
+			return p
+		}
+	}
+	t.Fatalf("peer %s not in report", peerID)
+	return PeerPath{}
+}
+
+func TestSelector_InitialPath(t *testing.T) {
+	s, _, prog := newTestSelector(Config{})
+
+	s.SetPeerEndpoints(testEndpoints)
+
+	if got := prog.last(); got != "peer-1 203.0.113.5:51820" {
+		t.Errorf("programmed %q, want the public endpoint", got)
+	}
+	p := activePath(t, s, "peer-1")
+	if p.Kind != KindPublic || len(p.Candidates) != 3 {
+		t.Errorf("unexpected path: %+v", p)
+	}
+	d := s.Report().Decisions
+	if len(d) != 1 || d[0].Reason != ReasonInitial || d[0].From != "" {
+		t.Errorf("unexpected decisions: %+v", d)
+	}
+}
+
+func TestSelector_InitialPathRelayWithoutPublic(t *testing.T) {
+	s, _, prog := newTestSelector(Config{})
+
+	pe := testEndpoints
+	pe.Endpoint = ""
+	s.SetPeerEndpoints(pe)
+
+	if got := prog.last(); got != "peer-1 198.51.100.10:51821" {
+		t.Errorf("programmed %q, want the relay endpoint", got)
+	}
+}
+
+func TestSelector_SwitchesToBetterPath(t *testing.T) {
+	s, prober, prog := newTestSelector(Config{})
+	prober.set("192.168.1.5", time.Millisecond)
+	prober.set("203.0.113.5", 40*time.Millisecond)
+	prober.set("198.51.100.10", 10*time.Millisecond)
+	s.SetPeerEndpoints(testEndpoints)
+
+	// One round does not fill the window of the LAN candidate.
+	probeRounds(s, 1)
+	if got := prog.last(); got != "peer-1 203.0.113.5:51820" {
+		t.Fatalf("switched after one round to %q", got)
+	}
+
+	probeRounds(s, 1)
+	if got := prog.last(); got != "peer-1 192.168.1.5:51820" {
+		t.Fatalf("programmed %q, want the LAN endpoint", got)
+	}
+	d := s.Report().Decisions
+	last := d[len(d)-1]
+	if last.Reason != ReasonBetter || last.FromKind != KindPublic || last.ToKind != KindLAN {
+		t.Errorf("unexpected decision: %+v", last)
+	}
+}
+
+func TestSelector_RelayPenalty(t *testing.T) {
+	s, prober, prog := newTestSelector(Config{RelayPenalty: 50 * time.Millisecond})
+	prober.set("203.0.113.5", 40*time.Millisecond)
+	prober.set("198.51.100.10", 5*time.Millisecond)
+	pe := testEndpoints
+	pe.LANEndpoints = nil
+	s.SetPeerEndpoints(pe)
+
+	probeRounds(s, 3)
+
+	if got := prog.last(); got != "peer-1 203.0.113.5:51820" {
+		t.Errorf("programmed %q, want the direct path despite the faster relay", got)
+	}
+}
+
+func TestSelector_Hysteresis(t *testing.T) {
+	t.Run("margin", func(t *testing.T) {
+		s, prober, prog := newTestSelector(Config{SwitchMargin: 10 * time.Millisecond})
+		prober.set("192.168.1.5", 35*time.Millisecond)
+		prober.set("203.0.113.5", 40*time.Millisecond)
+		s.SetPeerEndpoints(testEndpoints)
+
+		probeRounds(s, 3)
+
+		if n := prog.count(); n != 1 {
+			t.Errorf("expected only the initial programming within the margin, got %d calls", n)
+		}
+	})
+	t.Run("hold down", func(t *testing.T) {
+		s, prober, prog := newTestSelector(Config{HoldDown: time.Hour})
+		prober.set("192.168.1.5", time.Millisecond)
+		prober.set("203.0.113.5", 40*time.Millisecond)
+		s.SetPeerEndpoints(testEndpoints)
+
+		probeRounds(s, 3)
+
+		if n := prog.count(); n != 1 {
+			t.Errorf("expected no switch during the hold-down, got %d calls", n)
+		}
+	})
+}
+
+func TestSelector_DegradedPathReplacedAtOnce(t *testing.T) {
+	s, prober, prog := newTestSelector(Config{HoldDown: time.Hour})
+	prober.set("198.51.100.10", 10*time.Millisecond)
+	s.SetPeerEndpoints(testEndpoints)
+
+	// The public endpoint does not answer; the hold-down does not apply.
+	probeRounds(s, 1)
+
+	if got := prog.last(); got != "peer-1 198.51.100.10:51821" {
+		t.Fatalf("programmed %q, want the relay endpoint", got)
+	}
+	d := s.Report().Decisions
+	if last := d[len(d)-1]; last.Reason != ReasonDegraded {
+		t.Errorf("reason = %q, want %q", last.Reason, ReasonDegraded)
+	}
+
+	// Without any usable candidate the active path is kept.
+	prober.fail("198.51.100.10")
+	probeRounds(s, 2)
+	if got := prog.last(); got != "peer-1 198.51.100.10:51821" {
+		t.Errorf("programmed %q without a usable candidate", got)
+	}
+}
+
+func TestSelector_FallbackRelay(t *testing.T) {
+	s, prober, prog := newTestSelector(Config{FallbackAfter: 2})
+	relay := &mockFallbackRelay{}
+	s.SetFallbackRelay(relay)
+	s.SetPeerEndpoints(testEndpoints)
+
+	probeRounds(s, 1)
+	if relay.isOpen("peer-1") {
+		t.Fatal("fallback relay opened before FallbackAfter rounds")
+	}
+
+	probeRounds(s, 1)
+	if !relay.isOpen("peer-1") {
+		t.Fatal("fallback relay not opened")
+	}
+	if got := prog.last(); got != "peer-1 127.0.0.1:40000" {
+		t.Fatalf("programmed %q, want the fallback endpoint", got)
+	}
+	p := activePath(t, s, "peer-1")
+	if p.Kind != KindFallback || p.Endpoint != "127.0.0.1:40000" {
+		t.Errorf("unexpected path: %+v", p)
+	}
+	d := s.Report().Decisions
+	if last := d[len(d)-1]; last.Reason != ReasonUnreachable || last.FromKind != KindPublic {
+		t.Errorf("unexpected decision: %+v", last)
+	}
+
+	// Updated candidates do not end the fallback while none answers.
+	s.SetPeerEndpoints(testEndpoints)
+	probeRounds(s, 1)
+	if got := prog.last(); got != "peer-1 127.0.0.1:40000" || relay.opened != 1 {
+		t.Fatalf("programmed %q after %d opens, want to stay on the fallback relay", got, relay.opened)
+	}
+
+	prober.set("192.168.1.5", time.Millisecond)
+	probeRounds(s, 1)
+	if got := prog.last(); got != "peer-1 192.168.1.5:51820" {
+		t.Fatalf("programmed %q, want the recovered LAN endpoint", got)
+	}
+	if relay.isOpen("peer-1") {
+		t.Error("fallback relay not closed after recovery")
+	}
+	d = s.Report().Decisions
+	if last := d[len(d)-1]; last.Reason != ReasonRecovered || last.FromKind != KindFallback || last.ToKind != KindLAN {
+		t.Errorf("unexpected decision: %+v", last)
+	}
+}
+
+func TestSelector_FallbackRelayOpenFailureRetried(t *testing.T) {
+	s, _, prog := newTestSelector(Config{FallbackAfter: 1})
+	relay := &mockFallbackRelay{openErr: errors.New("relay down")}
+	s.SetFallbackRelay(relay)
+	s.SetPeerEndpoints(testEndpoints)
+
+	probeRounds(s, 1)
+	if p := activePath(t, s, "peer-1"); p.Kind != KindPublic {
+		t.Fatalf("kind = %q after failed open, want the previous path", p.Kind)
+	}
+
+	relay.mu.Lock()
+	relay.openErr = nil
+	relay.mu.Unlock()
+	probeRounds(s, 1)
+	if got := prog.last(); got != "peer-1 127.0.0.1:40000" {
+		t.Errorf("programmed %q, want the fallback endpoint", got)
+	}
+}
+
+func TestSelector_RemovePeerClosesFallbackRelay(t *testing.T) {
+	s, _, _ := newTestSelector(Config{FallbackAfter: 1})
+	relay := &mockFallbackRelay{}
+	s.SetFallbackRelay(relay)
+	s.SetPeerEndpoints(testEndpoints)
+	probeRounds(s, 1)
+	if !relay.isOpen("peer-1") {
+		t.Fatal("fallback relay not opened")
+	}
+
+	s.RemovePeer("peer-1")
+
+	if relay.isOpen("peer-1") {
+		t.Error("fallback relay not closed on removal")
+	}
+}
+
+func TestSelector_WithdrawnEndpoint(t *testing.T) {
+	s, _, prog := newTestSelector(Config{})
+	s.SetPeerEndpoints(testEndpoints)
+
+	pe := testEndpoints
+	pe.Endpoint = "203.0.113.9:51820"
+	s.SetPeerEndpoints(pe)
+
+	if got := prog.last(); got != "peer-1 203.0.113.9:51820" {
+		t.Errorf("programmed %q, want the new public endpoint", got)
+	}
+	d := s.Report().Decisions
+	if last := d[len(d)-1]; last.Reason != ReasonWithdrawn || last.From != "203.0.113.5:51820" {
+		t.Errorf("unexpected decision: %+v", last)
+	}
+}
+
+func TestSelector_KeepsSamplesOfRemainingCandidates(t *testing.T) {
+	s, prober, _ := newTestSelector(Config{})
+	prober.set("192.168.1.5", time.Millisecond)
+	s.SetPeerEndpoints(testEndpoints)
+	probeRounds(s, 1)
+
+	s.SetPeerEndpoints(testEndpoints)
+
+	for _, c := range activePath(t, s, "peer-1").Candidates {
+		if c.Samples != 1 {
+			t.Errorf("candidate %s: samples = %d, want 1", c.Endpoint, c.Samples)
+		}
+	}
+}
+
+func TestSelector_ProgramFailureRetried(t *testing.T) {
+	s, _, prog := newTestSelector(Config{})
+	prog.err = errors.New("device busy")
+	s.SetPeerEndpoints(testEndpoints)
+
+	if p := activePath(t, s, "peer-1"); p.Endpoint != "" {
+		t.Errorf("active endpoint = %q after a failed programming, want none", p.Endpoint)
+	}
+	if d := s.Report().Decisions; len(d) != 0 {
+		t.Errorf("failed programming recorded as decision: %+v", d)
+	}
+
+	prog.mu.Lock()
+	prog.err = nil
+	prog.mu.Unlock()
+	probeRounds(s, 1)
+
+	if got := prog.last(); got != "peer-1 203.0.113.5:51820" {
+		t.Errorf("programmed %q on retry, want the public endpoint", got)
+	}
+}
+
+func TestSelector_Report(t *testing.T) {
+	s, prober, _ := newTestSelector(Config{})
+	w := &mockReportWriter{}
+	s.SetReportWriter(w)
+	prober.set("192.168.1.5", 2*time.Millisecond)
+	s.SetPeerEndpoints(testEndpoints)
+
+	probeRounds(s, 1)
+
+	if w.key != ReportKey {
+		t.Fatalf("report key = %q, want %q", w.key, ReportKey)
+	}
+	var r Report
+	if err := json.Unmarshal(w.payload, &r); err != nil {
+		t.Fatalf("unmarshal report: %v", err)
+	}
+	if r.NodeID != "node-1" || len(r.Peers) != 1 || r.UpdatedAt.IsZero() {
+		t.Fatalf("unexpected report: %+v", r)
+	}
+	byEndpoint := make(map[string]CandidateStatus)
+	for _, c := range r.Peers[0].Candidates {
+		byEndpoint[c.Endpoint] = c
+	}
+	lan := byEndpoint["192.168.1.5:51820"]
+	if lan.Kind != KindLAN || lan.RTTNano != (2*time.Millisecond).Nanoseconds() || lan.Loss != 0 || lan.ScoreNano != lan.RTTNano {
+		t.Errorf("unexpected LAN candidate: %+v", lan)
+	}
+	public := byEndpoint["203.0.113.5:51820"]
+	if public.RTTNano != -1 || public.Loss != 1 || public.ScoreNano != -1 {
+		t.Errorf("unexpected public candidate: %+v", public)
+	}
+}
+
+func TestSelector_ReconcileHandlerRemovesPeers(t *testing.T) {
+	s, _, _ := newTestSelector(Config{})
+	s.SetPeerEndpoints(testEndpoints)
+
+	h := s.ReconcileHandler()
+	if err := h(context.Background(), &api.StateResponse{}, reconcile.StateDiff{PeersToRemove: []string{"peer-1"}}); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+
+	if peers := s.Report().Peers; len(peers) != 0 {
+		t.Errorf("peer not removed: %+v", peers)
+	}
+}
+
+func TestSelector_RunDisabled(t *testing.T) {
+	cfg := Config{Enabled: false, Interval: time.Second}
+	s := NewSelector(cfg, &mockProber{}, &mockProgrammer{}, "node-1", discardLogger())
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+}
@@ -3,10 +3,13 @@ package peerexchange
 import (
 	"context"
 	"log/slog"
+	"sync"
 
 	"github.com/plexsphere/plexd/internal/api"
 	"github.com/plexsphere/plexd/internal/nat"
+	"github.com/plexsphere/plexd/internal/pathsel"
 	"github.com/plexsphere/plexd/internal/wireguard"
+	"github.com/plexsphere/plexd/internal/wsrelay"
 )
 
 // Exchanger orchestrates peer endpoint exchange: STUN discovery,
@@ -17,11 +20,19 @@ type Exchanger struct {
 	cpClient   *api.ControlPlane
 	cfg        Config
 	logger     *slog.Logger
+	selector   *pathsel.Selector
+	fallback   *wsrelay.Client
 }
 
 // NewExchanger creates a new Exchanger.
 func NewExchanger(discoverer *nat.Discoverer, wgManager *wireguard.Manager, cpClient *api.ControlPlane, cfg Config, logger *slog.Logger) *Exchanger {
 	cfg.ApplyDefaults()
+	if discoverer != nil && cfg.HolePunchAttempts > 0 {
+		discoverer.SetHolePuncher(&nat.UDPHolePuncher{
+			Attempts: cfg.HolePunchAttempts,
+			Interval: cfg.HolePunchInterval,
+		})
+	}
 	return &Exchanger{
 		discoverer: discoverer,
 		wgManager:  wgManager,
@@ -31,6 +42,22 @@ func NewExchanger(discoverer *nat.Discoverer, wgManager *wireguard.Manager, cpCl
 	}
 }
 
+// SetPathSelector hands the candidate endpoints of every peer to sel instead
+// of applying the endpoint chosen by the control plane, and advertises the
+// node's LAN addresses so that peers can select them too. The selector is
+// run by Run. It must be called before Run.
+func (e *Exchanger) SetPathSelector(sel *pathsel.Selector) {
+	e.selector = sel
+}
+
+// SetFallbackRelay lets the path selector move peers that no candidate
+// reaches to the control plane relay. The client is run by Run alongside
+// the selector; without a path selector it is not used. It must be called
+// before Run.
+func (e *Exchanger) SetFallbackRelay(client *wsrelay.Client) {
+	e.fallback = client
+}
+
 // RegisterHandlers registers SSE event handlers for peer endpoint changes.
 // Must be called before the SSEManager is started.
 // Handlers are registered regardless of whether NAT is enabled, because
@@ -52,7 +79,33 @@ func (e *Exchanger) Run(ctx context.Context, nodeID string) error {
 	e.logger.Info("starting endpoint exchange", "component", "exchange", "node_id", nodeID)
 
 	reporter := &controlPlaneReporter{client: e.cpClient}
-	return e.discoverer.Run(ctx, reporter, e.wgManager, nodeID)
+	if e.selector == nil {
+		return e.discoverer.Run(ctx, reporter, e.wgManager, nodeID)
+	}
+
+	e.discoverer.AdvertiseLAN(e.wgManager.InterfaceName())
+	var wg sync.WaitGroup
+	if e.fallback != nil {
+		e.selector.SetFallbackRelay(e.fallback)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = e.fallback.Run(ctx)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = e.selector.Run(ctx)
+	}()
+	defer wg.Wait()
+	return e.discoverer.Run(ctx, reporter, selectingUpdater{e.wgManager, e.selector}, nodeID)
+}
+
+// TriggerDiscovery requests an immediate STUN re-detection, for example after
+// a local network change.
+func (e *Exchanger) TriggerDiscovery() {
+	e.discoverer.TriggerDiscovery()
 }
 
 // LastResult returns the most recently discovered NAT info.
@@ -60,6 +113,13 @@ func (e *Exchanger) LastResult() *api.NATInfo {
 	return e.discoverer.LastResult()
 }
 
+// selectingUpdater is a nat.PeerUpdater with the nat.PathSelector
+// capability, so that peer candidates go to the path selector.
+type selectingUpdater struct {
+	*wireguard.Manager
+	*pathsel.Selector
+}
+
 // controlPlaneReporter adapts *api.ControlPlane to the nat.EndpointReporter interface.
 type controlPlaneReporter struct {
 	client *api.ControlPlane
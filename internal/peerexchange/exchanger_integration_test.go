@@ -14,6 +14,7 @@ import (
 
 	"github.com/plexsphere/plexd/internal/api"
 	"github.com/plexsphere/plexd/internal/nat"
+	"github.com/plexsphere/plexd/internal/netmon"
 	"github.com/plexsphere/plexd/internal/wireguard"
 )
 
@@ -424,6 +425,93 @@ func TestIntegration_ConcurrentSSEAndRefreshNoRace(t *testing.T) {
 	}
 }
 
+// chanWatcher is a netmon.Watcher whose events are sent by the test.
+type chanWatcher chan netmon.Event
+
+func (w chanWatcher) Watch(context.Context) (<-chan netmon.Event, error) {
+	return w, nil
+}
+
+// TestIntegration_NetworkChangeRoamsPeers verifies that a host network
+// change reported by netmon makes the Exchanger discover its endpoint again,
+// report it, and move peers to the endpoints the control plane returns,
+// without waiting for the refresh interval.
+func TestIntegration_NetworkChangeRoamsPeers(t *testing.T) {
+	before := nat.MappedAddress{IP: net.IPv4(203, 0, 113, 1), Port: 12345}
+	after := nat.MappedAddress{IP: net.IPv4(192, 0, 2, 7), Port: 40000}
+	// Each discovery binds to both servers.
+	stunClient := &sequenceSTUNClient{results: []mockBindResult{
+		{Addr: before}, {Addr: before}, {Addr: after},
+	}}
+
+	var mu sync.Mutex
+	var reported []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req api.EndpointReport
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		reported = append(reported, req.PublicEndpoint)
+		mu.Unlock()
+
+		resp := api.EndpointResponse{}
+		if req.PublicEndpoint == "192.0.2.7:40000" {
+			resp.PeerEndpoints = []api.PeerEndpoint{{PeerID: "peer-a", Endpoint: "198.51.100.9:51820"}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer ts.Close()
+
+	logger := discardLogger()
+	ctrl := &trackingWGController{}
+	wgMgr := wireguard.NewManager(ctrl, wireguard.Config{}, logger)
+	wgMgr.PeerIndex().Add("peer-a", peerKey())
+
+	natCfg := nat.Config{
+		Enabled:         true,
+		STUNServers:     []string{"stun1:3478", "stun2:3478"},
+		RefreshInterval: time.Hour,
+		Timeout:         5 * time.Second,
+	}
+	discoverer := nat.NewDiscoverer(stunClient, natCfg, 51820, logger)
+	exchanger := NewExchanger(discoverer, wgMgr, newTestControlPlane(t, ts), Config{Config: natCfg}, logger)
+
+	watcher := make(chanWatcher, 1)
+	mon := netmon.NewMonitor(watcher, netmon.Config{Enabled: true, Debounce: 100 * time.Millisecond}, logger)
+	mon.OnChange(exchanger.TriggerDiscovery)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); _ = exchanger.Run(ctx, "node-1") }()
+	go func() { defer wg.Done(); _ = mon.Run(ctx) }()
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	waitFor(t, 2*time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(reported) == 1
+	})
+
+	watcher <- netmon.Event{Kind: netmon.EventAddress, Interface: "wlan0", Detail: "192.168.1.20/24"}
+
+	waitFor(t, 2*time.Second, func() bool { return ctrl.addPeerCount() == 1 })
+	if got := ctrl.lastPeerEndpoint(); got != "198.51.100.9:51820" {
+		t.Errorf("peer endpoint = %q, want %q", got, "198.51.100.9:51820")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reported) != 2 || reported[1] != "192.0.2.7:40000" {
+		t.Errorf("reported endpoints = %v, want the new endpoint reported after the change", reported)
+	}
+}
+
 // waitFor polls condition until it returns true or timeout expires.
 func waitFor(t *testing.T, timeout time.Duration, condition func() bool) {
 	t.Helper()
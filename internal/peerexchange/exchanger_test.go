@@ -15,6 +15,7 @@ import (
 
 	"github.com/plexsphere/plexd/internal/api"
 	"github.com/plexsphere/plexd/internal/nat"
+	"github.com/plexsphere/plexd/internal/pathsel"
 	"github.com/plexsphere/plexd/internal/wireguard"
 )
 
@@ -129,6 +130,26 @@ func (m *mockWGController) RemovePeer(iface string, publicKey []byte) error {
 	return nil
 }
 
+func (m *mockWGController) SetPeerEndpoint(iface string, publicKey []byte, endpoint string) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockWGCall{Method: "SetPeerEndpoint", Args: []interface{}{iface, publicKey, endpoint}})
+	m.mu.Unlock()
+	return nil
+}
+
+// endpointsSet returns the endpoints passed to SetPeerEndpoint, in order.
+func (m *mockWGController) endpointsSet() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var eps []string
+	for _, c := range m.calls {
+		if c.Method == "SetPeerEndpoint" {
+			eps = append(eps, c.Args[2].(string))
+		}
+	}
+	return eps
+}
+
 func (m *mockWGController) addPeerCalls() int {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -259,6 +280,61 @@ func TestExchanger_Run_InitialDiscoveryAndReport(t *testing.T) {
 	}
 }
 
+// stubProber answers every probe after a fixed round-trip time.
+type stubProber struct{}
+
+func (stubProber) Probe(context.Context, string) (time.Duration, error) {
+	return time.Millisecond, nil
+}
+
+func TestExchanger_Run_PathSelector(t *testing.T) {
+	addr := nat.MappedAddress{IP: net.IPv4(203, 0, 113, 1), Port: 12345}
+	stunClient := &mockSTUNClient{
+		results: map[string]mockBindResult{
+			"stun1:3478": {Addr: addr},
+			"stun2:3478": {Addr: addr},
+		},
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(api.EndpointResponse{
+			PeerEndpoints: []api.PeerEndpoint{
+				{PeerID: "peer-1", Endpoint: "5.6.7.8:51820", LANEndpoints: []string{"192.168.1.5:51820"}},
+			},
+		})
+	}))
+	defer ts.Close()
+
+	ctrl := &mockWGController{}
+	e := newTestExchanger(t, stunClient, ts, ctrl)
+	e.wgManager.PeerIndex().Add("peer-1", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	sel := pathsel.NewSelector(pathsel.Config{
+		Enabled:  true,
+		Interval: time.Hour,
+		Port:     45831,
+	}, stubProber{}, e.wgManager, "node-1", discardLogger())
+	e.SetPathSelector(sel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- e.Run(ctx, "node-1") }()
+
+	// The public endpoint is programmed first, before any probe answered.
+	waitFor(t, 2*time.Second, func() bool { return len(ctrl.endpointsSet()) >= 1 })
+	if eps := ctrl.endpointsSet(); eps[0] != "5.6.7.8:51820" {
+		t.Errorf("first endpoint = %q, want the public endpoint", eps[0])
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run() = %v, want context.Canceled", err)
+	}
+	if n := ctrl.addPeerCalls(); n != 0 {
+		t.Errorf("expected no AddPeer calls with a path selector, got %d", n)
+	}
+}
+
 func TestExchanger_Run_ContextCancellation(t *testing.T) {
 	addr := nat.MappedAddress{IP: net.IPv4(203, 0, 113, 1), Port: 12345}
 	stunClient := &mockSTUNClient{
@@ -0,0 +1,126 @@
+// Package peerhealth detects mesh peers that stopped completing WireGuard
+// handshakes, reports them as inactive, and optionally withdraws their routes
+// until they recover, so that traffic to long-gone peers is not black-holed.
+package peerhealth
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultInterval is the default time between checks.
+const DefaultInterval = 30 * time.Second
+
+// DefaultThreshold is the default time without a handshake after which a
+// peer is inactive.
+const DefaultThreshold = 5 * time.Minute
+
+// DefaultKeepalive is the default persistent keepalive interval, in seconds.
+const DefaultKeepalive = 25
+
+// DefaultFlapWindow is the default window in which state changes are counted.
+const DefaultFlapWindow = 30 * time.Minute
+
+// DefaultFlapThreshold is the default number of state changes within
+// FlapWindow at which a peer is flapping.
+const DefaultFlapThreshold = 3
+
+// minThreshold bounds Threshold. WireGuard renews the session of a peer
+// every two minutes while packets flow, so a live peer with keepalives
+// completes a handshake at least that often.
+const minThreshold = 3 * time.Minute
+
+// Config holds the configuration for dead peer detection.
+type Config struct {
+	// Enabled controls whether peer handshakes are checked.
+	// Default: true (set by ApplyDefaults).
+	Enabled bool
+
+	// Interval is the time between checks. Must be at least 1s and less
+	// than Threshold.
+	// Default: 30s
+	Interval time.Duration
+
+	// Threshold is the time without a completed handshake after which a
+	// peer is inactive. Must be at least 3m.
+	// Default: 5m
+	Threshold time.Duration
+
+	// Keepalive is the persistent keepalive interval of mesh peers, in
+	// seconds. Keepalives make idle peers handshake, so that silence means
+	// the peer is gone. Must be between 1 and 65535 and less than Threshold.
+	// Default: 25
+	Keepalive int
+
+	// RemoveRoutes withdraws the routes of inactive peers from the mesh
+	// interface until they complete a handshake again. The peer's mesh IP
+	// stays routed, so that it can still be probed.
+	// Default: false
+	RemoveRoutes bool
+
+	// FlapWindow is the window in which changes between active and inactive
+	// are counted. Must be at least Threshold.
+	// Default: 30m
+	FlapWindow time.Duration
+
+	// FlapThreshold is the number of changes within FlapWindow at which a
+	// peer is flapping. Must be at least 2.
+	// Default: 3
+	FlapThreshold int
+}
+
+// ApplyDefaults sets default values for zero-valued fields.
+// On a zero-valued Config, Enabled defaults to true.
+// To disable detection, set Enabled=false before or after calling ApplyDefaults.
+func (c *Config) ApplyDefaults() {
+	// Enabled defaults to true for zero-valued Config. If any field is
+	// non-zero, the caller constructed the config explicitly and we respect
+	// Enabled as-is.
+	if c.Interval == 0 && c.Threshold == 0 && c.Keepalive == 0 && !c.RemoveRoutes && c.FlapWindow == 0 && c.FlapThreshold == 0 {
+		c.Enabled = true
+	}
+	if c.Interval == 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.Threshold == 0 {
+		c.Threshold = DefaultThreshold
+	}
+	if c.Keepalive == 0 {
+		c.Keepalive = DefaultKeepalive
+	}
+	if c.FlapWindow == 0 {
+		c.FlapWindow = DefaultFlapWindow
+	}
+	if c.FlapThreshold == 0 {
+		c.FlapThreshold = DefaultFlapThreshold
+	}
+}
+
+// Validate checks that configuration values are within acceptable ranges.
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Threshold < minThreshold {
+		return errors.New("peerhealth: config: Threshold must be at least 3m")
+	}
+	if c.Interval < time.Second {
+		return errors.New("peerhealth: config: Interval must be at least 1s")
+	}
+	if c.Interval >= c.Threshold {
+		return errors.New("peerhealth: config: Interval must be less than Threshold")
+	}
+	if c.Keepalive < 1 || c.Keepalive > 65535 {
+		return errors.New("peerhealth: config: Keepalive must be between 1 and 65535")
+	}
+	if time.Duration(c.Keepalive)*time.Second >= c.Threshold {
+		return errors.New("peerhealth: config: Keepalive must be less than Threshold")
+	}
+	if c.FlapWindow < c.Threshold {
+		return errors.New("peerhealth: config: FlapWindow must be at least Threshold")
+	}
+	if c.FlapThreshold < 2 {
+		return errors.New("peerhealth: config: FlapThreshold must be at least 2")
+	}
+	return nil
+}
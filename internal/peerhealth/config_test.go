@@ -0,0 +1,83 @@
+package peerhealth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfig_Defaults(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+
+	if !cfg.Enabled {
+		t.Error("Enabled = false, want true")
+	}
+	if cfg.Interval != DefaultInterval {
+		t.Errorf("Interval = %v, want %v", cfg.Interval, DefaultInterval)
+	}
+	if cfg.Threshold != DefaultThreshold {
+		t.Errorf("Threshold = %v, want %v", cfg.Threshold, DefaultThreshold)
+	}
+	if cfg.Keepalive != DefaultKeepalive {
+		t.Errorf("Keepalive = %d, want %d", cfg.Keepalive, DefaultKeepalive)
+	}
+	if cfg.RemoveRoutes {
+		t.Error("RemoveRoutes = true, want false")
+	}
+	if cfg.FlapWindow != DefaultFlapWindow {
+		t.Errorf("FlapWindow = %v, want %v", cfg.FlapWindow, DefaultFlapWindow)
+	}
+	if cfg.FlapThreshold != DefaultFlapThreshold {
+		t.Errorf("FlapThreshold = %d, want %d", cfg.FlapThreshold, DefaultFlapThreshold)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() on defaults = %v", err)
+	}
+}
+
+func TestConfig_DefaultsPreserveExplicitDisabled(t *testing.T) {
+	cfg := Config{Enabled: false, RemoveRoutes: true}
+	cfg.ApplyDefaults()
+
+	if cfg.Enabled {
+		t.Error("Enabled = true, want false when explicitly configured with other non-zero fields")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := func(mod func(*Config)) Config {
+		cfg := Config{Enabled: true}
+		cfg.ApplyDefaults()
+		mod(&cfg)
+		return cfg
+	}
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"valid", valid(func(*Config) {}), ""},
+		{"threshold too short", valid(func(c *Config) { c.Threshold = 2 * time.Minute }), "peerhealth: config: Threshold must be at least 3m"},
+		{"interval too short", valid(func(c *Config) { c.Interval = 500 * time.Millisecond }), "peerhealth: config: Interval must be at least 1s"},
+		{"interval not below threshold", valid(func(c *Config) { c.Interval = 5 * time.Minute }), "peerhealth: config: Interval must be less than Threshold"},
+		{"keepalive out of range", valid(func(c *Config) { c.Keepalive = 70000 }), "peerhealth: config: Keepalive must be between 1 and 65535"},
+		{"keepalive not below threshold", valid(func(c *Config) { c.Keepalive = 300 }), "peerhealth: config: Keepalive must be less than Threshold"},
+		{"flap window below threshold", valid(func(c *Config) { c.FlapWindow = time.Minute }), "peerhealth: config: FlapWindow must be at least Threshold"},
+		{"flap threshold too low", valid(func(c *Config) { c.FlapThreshold = 1 }), "peerhealth: config: FlapThreshold must be at least 2"},
+		{"disabled skips checks", Config{Enabled: false, Keepalive: -1}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Validate() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
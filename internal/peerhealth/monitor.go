@@ -0,0 +1,275 @@
+package peerhealth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/nodeapi"
+)
+
+// PeerSource reports the live WireGuard state of the mesh peers.
+// *wireguard.PeerStatsReader satisfies this interface.
+type PeerSource interface {
+	MeshPeers(ctx context.Context) ([]nodeapi.MeshPeer, error)
+}
+
+// ReconcileTrigger triggers an immediate reconciliation.
+// *reconcile.Reconciler satisfies this interface.
+type ReconcileTrigger interface {
+	TriggerReconcile()
+}
+
+// peerState is the handshake health of one peer.
+type peerState struct {
+	// firstSeen is when the peer was first checked. A peer is not
+	// inactive before Threshold has passed since then, so that a newly
+	// added peer has time for its first handshake.
+	firstSeen     time.Time
+	lastHandshake *time.Time
+	inactive      bool
+	since         time.Time
+	// changes holds the times of the state changes within FlapWindow.
+	changes  []time.Time
+	flapping bool
+}
+
+// Monitor checks the last handshake of every mesh peer periodically and
+// marks peers inactive that exceeded Threshold. With RemoveRoutes it is
+// also a reconcile.StateOverride that withdraws the routes of inactive
+// peers from the desired state. Monitor is concurrent-safe.
+type Monitor struct {
+	cfg     Config
+	peers   PeerSource
+	trigger ReconcileTrigger
+	logger  *slog.Logger
+	now     func() time.Time
+
+	mu        sync.Mutex
+	state     map[string]*peerState
+	withdrawn map[string]bool // peer IDs whose routes Override withdrew
+	updatedAt time.Time
+}
+
+// NewMonitor creates a new Monitor. Config defaults are applied
+// automatically.
+func NewMonitor(cfg Config, peers PeerSource, logger *slog.Logger) *Monitor {
+	cfg.ApplyDefaults()
+	return &Monitor{
+		cfg:       cfg,
+		peers:     peers,
+		logger:    logger.With("component", "peerhealth"),
+		now:       time.Now,
+		state:     make(map[string]*peerState),
+		withdrawn: make(map[string]bool),
+	}
+}
+
+// SetReconcileTrigger sets the trigger invoked when a peer becomes inactive
+// or recovers while RemoveRoutes is set, so that its routes are withdrawn or
+// restored without waiting for the next cycle. It must be called before Run.
+func (m *Monitor) SetReconcileTrigger(rt ReconcileTrigger) {
+	m.trigger = rt
+}
+
+// Run checks all peers immediately and then every Interval until ctx is
+// cancelled. It returns nil immediately when detection is disabled.
+// Run always returns nil.
+func (m *Monitor) Run(ctx context.Context) error {
+	if !m.cfg.Enabled {
+		m.logger.Info("dead peer detection disabled")
+		return nil
+	}
+	m.logger.Info("dead peer detection started",
+		"interval", m.cfg.Interval.String(),
+		"threshold", m.cfg.Threshold.String(),
+		"remove_routes", m.cfg.RemoveRoutes,
+	)
+
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if err := m.Check(ctx); err != nil && ctx.Err() == nil {
+			m.logger.Warn("peer health check failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check reads the last handshake of every peer once and updates its state.
+// Peers on the interface that are not part of the applied state are
+// ignored; peers no longer on the interface are dropped.
+func (m *Monitor) Check(ctx context.Context) error {
+	peers, err := m.peers.MeshPeers(ctx)
+	if err != nil {
+		return fmt.Errorf("peerhealth: read peers: %w", err)
+	}
+
+	now := m.now()
+	changed := false
+	m.mu.Lock()
+	seen := make(map[string]bool, len(peers))
+	for _, p := range peers {
+		if p.PeerID == "" {
+			continue
+		}
+		seen[p.PeerID] = true
+		st := m.state[p.PeerID]
+		if st == nil {
+			st = &peerState{firstSeen: now, since: now}
+			m.state[p.PeerID] = st
+		}
+		st.lastHandshake = p.LastHandshake
+
+		last := st.firstSeen
+		if p.LastHandshake != nil && p.LastHandshake.After(last) {
+			last = *p.LastHandshake
+		}
+		if inactive := now.Sub(last) > m.cfg.Threshold; inactive != st.inactive {
+			st.inactive = inactive
+			st.since = now
+			st.changes = append(st.changes, now)
+			changed = true
+			if inactive {
+				m.logger.Warn("peer inactive",
+					"peer_id", p.PeerID,
+					"last_handshake", p.LastHandshake,
+					"threshold", m.cfg.Threshold.String(),
+				)
+			} else {
+				m.logger.Info("peer recovered", "peer_id", p.PeerID)
+			}
+		}
+
+		st.changes = slices.DeleteFunc(st.changes, func(t time.Time) bool {
+			return now.Sub(t) > m.cfg.FlapWindow
+		})
+		flapping := len(st.changes) >= m.cfg.FlapThreshold
+		if flapping && !st.flapping {
+			m.logger.Warn("peer flapping",
+				"peer_id", p.PeerID,
+				"transitions", len(st.changes),
+				"window", m.cfg.FlapWindow.String(),
+			)
+		}
+		st.flapping = flapping
+	}
+	for id := range m.state {
+		if !seen[id] {
+			delete(m.state, id)
+		}
+	}
+	m.updatedAt = now
+	m.mu.Unlock()
+
+	if changed && m.cfg.RemoveRoutes && m.trigger != nil {
+		m.trigger.TriggerReconcile()
+	}
+	return nil
+}
+
+// Override implements reconcile.StateOverride. With RemoveRoutes, inactive
+// peers keep only the allowed IPs of their mesh IP, so that traffic to the
+// subnets they route fails fast or takes another route instead of being
+// sent to a peer that does not answer. Otherwise desired is returned
+// unchanged.
+func (m *Monitor) Override(desired *api.StateResponse) *api.StateResponse {
+	if !m.cfg.Enabled || !m.cfg.RemoveRoutes {
+		return desired
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out *api.StateResponse
+	withdrawn := make(map[string]bool)
+	for i, p := range desired.Peers {
+		if st := m.state[p.ID]; st == nil || !st.inactive {
+			continue
+		}
+		if out == nil {
+			cp := *desired
+			cp.Peers = slices.Clone(desired.Peers)
+			out = &cp
+		}
+		out.Peers[i].AllowedIPs = meshIPRoutes(p)
+		withdrawn[p.ID] = true
+		if !m.withdrawn[p.ID] {
+			m.logger.Warn("routes of inactive peer withdrawn",
+				"peer_id", p.ID,
+				"routes", len(p.AllowedIPs)-len(out.Peers[i].AllowedIPs),
+			)
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(m.withdrawn)) {
+		if !withdrawn[id] {
+			m.logger.Info("routes of peer restored", "peer_id", id)
+		}
+	}
+	m.withdrawn = withdrawn
+
+	if out == nil {
+		return desired
+	}
+	return out
+}
+
+// meshIPRoutes returns the allowed IPs of p that cover only its mesh IP.
+func meshIPRoutes(p api.Peer) []string {
+	meshIP, err := netip.ParseAddr(p.MeshIP)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, cidr := range p.AllowedIPs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err == nil && prefix.IsSingleIP() && prefix.Addr() == meshIP {
+			out = append(out, cidr)
+		}
+	}
+	return out
+}
+
+// PeerHealth returns the health of the peers as of the last check, listing
+// the inactive and flapping peers, or nil before the first check.
+func (m *Monitor) PeerHealth() *api.PeerHealthInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.updatedAt.IsZero() {
+		return nil
+	}
+	info := &api.PeerHealthInfo{
+		PeersTotal: len(m.state),
+		UpdatedAt:  m.updatedAt,
+	}
+	for _, id := range slices.Sorted(maps.Keys(m.state)) {
+		st := m.state[id]
+		if !st.inactive {
+			info.PeersActive++
+		}
+		if !st.inactive && !st.flapping {
+			continue
+		}
+		info.Peers = append(info.Peers, api.PeerHealthStatus{
+			PeerID:        id,
+			Inactive:      st.inactive,
+			Since:         st.since,
+			LastHandshake: st.lastHandshake,
+			Transitions:   len(st.changes),
+			Flapping:      st.flapping,
+			RoutesRemoved: m.withdrawn[id],
+		})
+	}
+	return info
+}
@@ -0,0 +1,274 @@
+package peerhealth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/nodeapi"
+)
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// fakeSource reports a fixed handshake time per peer ID; a zero time
+// reports no handshake.
+type fakeSource struct {
+	mu         sync.Mutex
+	handshakes map[string]time.Time
+	err        error
+}
+
+func (s *fakeSource) MeshPeers(_ context.Context) ([]nodeapi.MeshPeer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	var peers []nodeapi.MeshPeer
+	for id, hs := range s.handshakes {
+		p := nodeapi.MeshPeer{PeerID: id, PublicKey: "key-" + id}
+		if !hs.IsZero() {
+			p.LastHandshake = &hs
+		}
+		peers = append(peers, p)
+	}
+	// A peer configured outside the applied state is ignored.
+	peers = append(peers, nodeapi.MeshPeer{PublicKey: "unknown"})
+	return peers, nil
+}
+
+func (s *fakeSource) set(id string, hs time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handshakes[id] = hs
+}
+
+type fakeTrigger struct{ calls int }
+
+func (t *fakeTrigger) TriggerReconcile() { t.calls++ }
+
+// testMonitor returns a Monitor whose clock is advanced by the returned
+// function.
+func testMonitor(cfg Config, src *fakeSource) (*Monitor, func(time.Duration) time.Time) {
+	m := NewMonitor(cfg, src, discardLogger())
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	return m, func(d time.Duration) time.Time {
+		now = now.Add(d)
+		return now
+	}
+}
+
+func TestMonitor_Check(t *testing.T) {
+	src := &fakeSource{handshakes: map[string]time.Time{}}
+	m, advance := testMonitor(Config{}, src)
+	start := advance(0)
+	src.set("peer-a", start.Add(-time.Minute))
+	src.set("peer-b", start.Add(-10*time.Minute))
+	src.set("peer-c", time.Time{})
+
+	if got := m.PeerHealth(); got != nil {
+		t.Fatalf("PeerHealth() before first check = %+v, want nil", got)
+	}
+	if err := m.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// New peers get Threshold for their first handshake, even with an old one.
+	info := m.PeerHealth()
+	if info.PeersTotal != 3 || info.PeersActive != 3 || len(info.Peers) != 0 {
+		t.Fatalf("PeerHealth() = %+v, want 3 active peers", info)
+	}
+
+	now := advance(DefaultThreshold + time.Second)
+	src.set("peer-a", now.Add(-time.Minute))
+	if err := m.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	info = m.PeerHealth()
+	if info.PeersActive != 1 {
+		t.Errorf("PeersActive = %d, want 1", info.PeersActive)
+	}
+	var ids []string
+	for _, p := range info.Peers {
+		ids = append(ids, p.PeerID)
+		if !p.Inactive || !p.Since.Equal(now) || p.Transitions != 1 || p.Flapping {
+			t.Errorf("peer %s = %+v, want inactive since %v", p.PeerID, p, now)
+		}
+	}
+	if !slices.Equal(ids, []string{"peer-b", "peer-c"}) {
+		t.Errorf("Peers = %v, want [peer-b peer-c]", ids)
+	}
+	if info.Peers[1].LastHandshake != nil {
+		t.Errorf("peer-c LastHandshake = %v, want nil", info.Peers[1].LastHandshake)
+	}
+
+	// A handshake within Threshold recovers the peer.
+	now = advance(time.Minute)
+	src.set("peer-b", now)
+	if err := m.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	info = m.PeerHealth()
+	if info.PeersActive != 2 || len(info.Peers) != 1 || info.Peers[0].PeerID != "peer-c" {
+		t.Errorf("PeerHealth() after recovery = %+v, want only peer-c inactive", info)
+	}
+}
+
+func TestMonitor_CheckDropsRemovedPeers(t *testing.T) {
+	src := &fakeSource{handshakes: map[string]time.Time{"peer-a": {}, "peer-b": {}}}
+	m, _ := testMonitor(Config{}, src)
+	if err := m.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	delete(src.handshakes, "peer-b")
+	if err := m.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if info := m.PeerHealth(); info.PeersTotal != 1 {
+		t.Errorf("PeersTotal = %d, want 1", info.PeersTotal)
+	}
+}
+
+func TestMonitor_CheckError(t *testing.T) {
+	src := &fakeSource{err: errors.New("no device")}
+	m, _ := testMonitor(Config{}, src)
+	if err := m.Check(context.Background()); err == nil {
+		t.Fatal("Check() = nil, want error")
+	}
+	if info := m.PeerHealth(); info != nil {
+		t.Errorf("PeerHealth() = %+v, want nil", info)
+	}
+}
+
+func TestMonitor_Flapping(t *testing.T) {
+	src := &fakeSource{handshakes: map[string]time.Time{"peer-a": {}}}
+	m, advance := testMonitor(Config{}, src)
+	if err := m.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Down, up, down: three changes within the flap window.
+	for i := 0; i < 3; i++ {
+		now := advance(DefaultThreshold + time.Second)
+		if i == 1 {
+			src.set("peer-a", now)
+		}
+		if err := m.Check(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info := m.PeerHealth()
+	if len(info.Peers) != 1 || !info.Peers[0].Flapping || info.Peers[0].Transitions != 3 {
+		t.Fatalf("PeerHealth() = %+v, want peer-a flapping with 3 transitions", info)
+	}
+
+	// Changes older than the flap window no longer count.
+	advance(DefaultFlapWindow + time.Second)
+	if err := m.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	info = m.PeerHealth()
+	if info.Peers[0].Flapping || info.Peers[0].Transitions != 0 {
+		t.Errorf("PeerHealth() after flap window = %+v, want not flapping", info)
+	}
+}
+
+func TestMonitor_Override(t *testing.T) {
+	desired := &api.StateResponse{Peers: []api.Peer{
+		{ID: "peer-a", MeshIP: "10.0.0.2", AllowedIPs: []string{"10.0.0.2/32", "192.168.1.0/24"}},
+		{ID: "peer-b", MeshIP: "10.0.0.3", AllowedIPs: []string{"10.0.0.3/32", "192.168.2.0/24"}},
+	}}
+	src := &fakeSource{handshakes: map[string]time.Time{"peer-a": {}, "peer-b": {}}}
+	trigger := &fakeTrigger{}
+	m, advance := testMonitor(Config{RemoveRoutes: true, Enabled: true}, src)
+	m.SetReconcileTrigger(trigger)
+
+	if err := m.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Override(desired); got != desired {
+		t.Errorf("Override() with all peers active returned a copy")
+	}
+
+	now := advance(DefaultThreshold + time.Second)
+	src.set("peer-b", now)
+	if err := m.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if trigger.calls != 1 {
+		t.Errorf("TriggerReconcile calls = %d, want 1", trigger.calls)
+	}
+
+	got := m.Override(desired)
+	if !slices.Equal(got.Peers[0].AllowedIPs, []string{"10.0.0.2/32"}) {
+		t.Errorf("peer-a AllowedIPs = %v, want only the mesh IP", got.Peers[0].AllowedIPs)
+	}
+	if len(got.Peers[1].AllowedIPs) != 2 {
+		t.Errorf("peer-b AllowedIPs = %v, want unchanged", got.Peers[1].AllowedIPs)
+	}
+	if len(desired.Peers[0].AllowedIPs) != 2 {
+		t.Errorf("desired modified: %v", desired.Peers[0].AllowedIPs)
+	}
+	if info := m.PeerHealth(); len(info.Peers) != 1 || !info.Peers[0].RoutesRemoved {
+		t.Errorf("PeerHealth() = %+v, want peer-a with routes removed", info)
+	}
+
+	// Recovery restores the routes with the next cycle.
+	now = advance(time.Minute)
+	src.set("peer-a", now)
+	if err := m.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if trigger.calls != 2 {
+		t.Errorf("TriggerReconcile calls = %d, want 2", trigger.calls)
+	}
+	if got := m.Override(desired); got != desired {
+		t.Errorf("Override() after recovery = %+v, want desired unchanged", got.Peers)
+	}
+}
+
+func TestMonitor_OverrideWithoutRemoveRoutes(t *testing.T) {
+	desired := &api.StateResponse{Peers: []api.Peer{
+		{ID: "peer-a", MeshIP: "10.0.0.2", AllowedIPs: []string{"10.0.0.2/32", "192.168.1.0/24"}},
+	}}
+	src := &fakeSource{handshakes: map[string]time.Time{"peer-a": {}}}
+	trigger := &fakeTrigger{}
+	m, advance := testMonitor(Config{}, src)
+	m.SetReconcileTrigger(trigger)
+	for i := 0; i < 2; i++ {
+		if err := m.Check(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		advance(DefaultThreshold + time.Second)
+	}
+
+	if got := m.Override(desired); got != desired {
+		t.Errorf("Override() without RemoveRoutes changed the state")
+	}
+	if trigger.calls != 0 {
+		t.Errorf("TriggerReconcile calls = %d, want 0", trigger.calls)
+	}
+}
+
+func TestMonitor_RunDisabled(t *testing.T) {
+	m := NewMonitor(Config{Enabled: false, Interval: time.Second}, &fakeSource{}, discardLogger())
+	done := make(chan error, 1)
+	go func() { done <- m.Run(context.Background()) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run() = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run() did not return while disabled")
+	}
+}
@@ -0,0 +1,84 @@
+// Package pmtu sizes the MTU of the mesh WireGuard interface from the path
+// MTU towards the peers' endpoints, so that encapsulated packets fit links
+// with a reduced MTU, such as PPPoE or another tunnel, without relying on
+// ICMP "fragmentation needed" messages that some networks drop.
+package pmtu
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultInterval is the default time between discovery rounds.
+const DefaultInterval = 5 * time.Minute
+
+// DefaultMaxMTU is the default largest tunnel MTU: the MTU WireGuard uses
+// for an underlay MTU of 1500 over IPv6.
+const DefaultMaxMTU = 1420
+
+// MinMTU is the smallest tunnel MTU, the minimum IPv6 requires of a link.
+// When a path needs less, the tunnel stays at MinMTU and the underlay
+// fragments the encapsulated packets.
+const MinMTU = 1280
+
+// maxOverhead bounds Overhead.
+const maxOverhead = 512
+
+// Config holds the configuration for tunnel MTU discovery.
+type Config struct {
+	// Enabled controls whether the tunnel MTU is discovered. It has no
+	// effect while wireguard.mtu is set.
+	// Default: true (set by ApplyDefaults).
+	Enabled bool
+
+	// Overhead is the encapsulation, in bytes, that the path adds beyond
+	// what the node's routes show, such as 8 for PPPoE on an upstream
+	// router or the headers of a tunnel the node's traffic is carried in.
+	// Must be between 0 and 512.
+	// Default: 0
+	Overhead int
+
+	// Interval is the time between discovery rounds. Must be at least 10s.
+	// Default: 5m
+	Interval time.Duration
+
+	// MaxMTU is the largest tunnel MTU discovery sets. Must be between
+	// 1280 and 9000.
+	// Default: 1420
+	MaxMTU int
+}
+
+// ApplyDefaults sets default values for zero-valued fields.
+// On a zero-valued Config, Enabled defaults to true.
+// To disable discovery, set Enabled=false before or after calling ApplyDefaults.
+func (c *Config) ApplyDefaults() {
+	// Enabled defaults to true for zero-valued Config. If any field is
+	// non-zero, the caller constructed the config explicitly and we respect
+	// Enabled as-is.
+	if c.Overhead == 0 && c.Interval == 0 && c.MaxMTU == 0 {
+		c.Enabled = true
+	}
+	if c.Interval == 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.MaxMTU == 0 {
+		c.MaxMTU = DefaultMaxMTU
+	}
+}
+
+// Validate checks that configuration values are within acceptable ranges.
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Overhead < 0 || c.Overhead > maxOverhead {
+		return errors.New("pmtu: config: Overhead must be between 0 and 512")
+	}
+	if c.Interval < 10*time.Second {
+		return errors.New("pmtu: config: Interval must be at least 10s")
+	}
+	if c.MaxMTU < MinMTU || c.MaxMTU > 9000 {
+		return errors.New("pmtu: config: MaxMTU must be between 1280 and 9000")
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package pmtu
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfig_Defaults(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+
+	if !cfg.Enabled {
+		t.Error("Enabled = false, want true")
+	}
+	if cfg.Interval != DefaultInterval {
+		t.Errorf("Interval = %v, want %v", cfg.Interval, DefaultInterval)
+	}
+	if cfg.MaxMTU != DefaultMaxMTU {
+		t.Errorf("MaxMTU = %d, want %d", cfg.MaxMTU, DefaultMaxMTU)
+	}
+	if cfg.Overhead != 0 {
+		t.Errorf("Overhead = %d, want 0", cfg.Overhead)
+	}
+}
+
+func TestConfig_DefaultsPreserveExplicitDisabled(t *testing.T) {
+	cfg := Config{Enabled: false, Overhead: 8}
+	cfg.ApplyDefaults()
+
+	if cfg.Enabled {
+		t.Error("Enabled = true, want false when explicitly configured with other non-zero fields")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Config{Enabled: true, Interval: time.Minute, MaxMTU: 1420}
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{"valid", func(*Config) {}, ""},
+		{"PPPoE overhead", func(c *Config) { c.Overhead = 8 }, ""},
+		{"negative overhead", func(c *Config) { c.Overhead = -1 }, "pmtu: config: Overhead must be between 0 and 512"},
+		{"overhead too large", func(c *Config) { c.Overhead = 513 }, "pmtu: config: Overhead must be between 0 and 512"},
+		{"interval too short", func(c *Config) { c.Interval = time.Second }, "pmtu: config: Interval must be at least 10s"},
+		{"max MTU too small", func(c *Config) { c.MaxMTU = 1200 }, "pmtu: config: MaxMTU must be between 1280 and 9000"},
+		{"max MTU too large", func(c *Config) { c.MaxMTU = 9001 }, "pmtu: config: MaxMTU must be between 1280 and 9000"},
+		{"disabled skips checks", func(c *Config) { c.Enabled = false; c.Overhead = -1 }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Validate() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package pmtu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// Encapsulation overhead of WireGuard: the outer IP header, the UDP header,
+// and 32 bytes of WireGuard header and authentication tag.
+const (
+	OverheadIPv4 = 20 + 8 + 32
+	OverheadIPv6 = 40 + 8 + 32
+)
+
+// probePort is the port the sockets KernelProber connects use. Connecting
+// a UDP socket sends nothing, so any port will do.
+const probePort = 9
+
+// Prober looks up the path MTU towards an address.
+// KernelProber satisfies this interface.
+type Prober interface {
+	// PathMTU returns the MTU of the path to addr as known to the node:
+	// the MTU of the route, lowered by any ICMP "fragmentation needed"
+	// message received for addr.
+	PathMTU(addr netip.Addr) (int, error)
+}
+
+// Interface is the WireGuard interface whose MTU is discovered.
+// *wireguard.Manager satisfies this interface.
+type Interface interface {
+	// PeerEndpoints returns the current endpoints of the interface's
+	// peers as host:port strings.
+	PeerEndpoints() ([]string, error)
+
+	// SetInterfaceMTU sets the MTU of the interface.
+	SetInterfaceMTU(mtu int) error
+}
+
+// Status is the result of the last discovery round.
+type Status struct {
+	// MTU is the tunnel MTU set, or 0 before the first successful round.
+	MTU int `json:"mtu"`
+	// PathMTU is the smallest path MTU found and Endpoint the peer
+	// endpoint it was found for; both are zero when no path was probed.
+	PathMTU  int    `json:"path_mtu,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// TunnelMTU returns the tunnel MTU that makes packets encapsulated towards
+// addr fit a path MTU of pathMTU, with overhead bytes of encapsulation in
+// addition to WireGuard's.
+func TunnelMTU(pathMTU int, addr netip.Addr, overhead int) int {
+	wg := OverheadIPv4
+	if addr.Is6() && !addr.Is4In6() {
+		wg = OverheadIPv6
+	}
+	return pathMTU - wg - overhead
+}
+
+// Discoverer periodically sets the MTU of a WireGuard interface to the
+// largest that fits the path to every peer endpoint, capped at MaxMTU and
+// at least MinMTU. Discoverer is concurrent-safe.
+type Discoverer struct {
+	cfg    Config
+	prober Prober
+	iface  Interface
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	status Status
+}
+
+// NewDiscoverer creates a new Discoverer. Config defaults are applied
+// automatically.
+func NewDiscoverer(cfg Config, prober Prober, iface Interface, logger *slog.Logger) *Discoverer {
+	cfg.ApplyDefaults()
+	return &Discoverer{
+		cfg:    cfg,
+		prober: prober,
+		iface:  iface,
+		logger: logger.With("component", "pmtu"),
+	}
+}
+
+// Run discovers the tunnel MTU every Interval until ctx is cancelled. It
+// returns nil immediately when discovery is disabled. Run always returns nil.
+func (d *Discoverer) Run(ctx context.Context) error {
+	if !d.cfg.Enabled {
+		d.logger.Info("tunnel MTU discovery disabled")
+		return nil
+	}
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.Discover(); err != nil {
+			d.logger.Warn("tunnel MTU discovery failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Discover probes the path to every peer endpoint and sets the tunnel MTU
+// when it differs from the one set before. Loopback endpoints, used by
+// the fallback relay, are skipped. When no endpoint can be probed the MTU
+// is left unchanged. It returns the resulting status.
+func (d *Discoverer) Discover() (Status, error) {
+	endpoints, err := d.iface.PeerEndpoints()
+	if err != nil {
+		return d.Status(), fmt.Errorf("pmtu: list peer endpoints: %w", err)
+	}
+
+	// next is the status for the path that needs the smallest tunnel MTU.
+	var next Status
+	var probeErrs []error
+	for _, ep := range endpoints {
+		ap, err := netip.ParseAddrPort(ep)
+		if err != nil || ap.Addr().Unmap().IsLoopback() {
+			continue
+		}
+		addr := ap.Addr().Unmap()
+		pathMTU, err := d.prober.PathMTU(addr)
+		if err != nil {
+			probeErrs = append(probeErrs, fmt.Errorf("pmtu: probe %s: %w", ep, err))
+			continue
+		}
+		if mtu := TunnelMTU(pathMTU, addr, d.cfg.Overhead); next.PathMTU == 0 || mtu < next.MTU {
+			next = Status{MTU: mtu, PathMTU: pathMTU, Endpoint: ep}
+		}
+	}
+	if next.PathMTU == 0 {
+		return d.Status(), errors.Join(probeErrs...)
+	}
+	needed := next.MTU
+	next.MTU = max(min(next.MTU, d.cfg.MaxMTU), MinMTU)
+
+	d.mu.Lock()
+	prev := d.status.MTU
+	d.mu.Unlock()
+	if next.MTU != prev {
+		if needed < MinMTU {
+			d.logger.Warn("path MTU below tunnel minimum, underlay fragments packets",
+				"path_mtu", next.PathMTU,
+				"endpoint", next.Endpoint,
+				"min_mtu", MinMTU,
+			)
+		}
+		if err := d.iface.SetInterfaceMTU(next.MTU); err != nil {
+			return d.Status(), fmt.Errorf("pmtu: set MTU %d: %w", next.MTU, err)
+		}
+		d.logger.Info("tunnel MTU changed",
+			"mtu", next.MTU,
+			"previous_mtu", prev,
+			"path_mtu", next.PathMTU,
+			"endpoint", next.Endpoint,
+		)
+	}
+
+	d.mu.Lock()
+	d.status = next
+	d.mu.Unlock()
+	return next, nil
+}
+
+// Status returns the result of the last successful discovery round.
+func (d *Discoverer) Status() Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status
+}
@@ -0,0 +1,176 @@
+package pmtu
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// fakeProber returns the path MTU configured for each address.
+type fakeProber map[netip.Addr]int
+
+func (p fakeProber) PathMTU(addr netip.Addr) (int, error) {
+	mtu, ok := p[addr]
+	if !ok {
+		return 0, errors.New("no route")
+	}
+	return mtu, nil
+}
+
+// fakeInterface records the MTUs set.
+type fakeInterface struct {
+	endpoints []string
+	setErr    error
+	mtus      []int
+}
+
+func (f *fakeInterface) PeerEndpoints() ([]string, error) { return f.endpoints, nil }
+
+func (f *fakeInterface) SetInterfaceMTU(mtu int) error {
+	if f.setErr != nil {
+		return f.setErr
+	}
+	f.mtus = append(f.mtus, mtu)
+	return nil
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestTunnelMTU(t *testing.T) {
+	tests := []struct {
+		name     string
+		pathMTU  int
+		addr     string
+		overhead int
+		want     int
+	}{
+		{"IPv4 ethernet", 1500, "198.51.100.1", 0, 1440},
+		{"IPv6 ethernet", 1500, "2001:db8::1", 0, 1420},
+		{"IPv4 PPPoE link", 1492, "198.51.100.1", 0, 1432},
+		{"IPv4 PPPoE upstream", 1500, "198.51.100.1", 8, 1432},
+		{"IPv4-mapped IPv6", 1500, "::ffff:198.51.100.1", 0, 1440},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TunnelMTU(tt.pathMTU, netip.MustParseAddr(tt.addr), tt.overhead); got != tt.want {
+				t.Errorf("TunnelMTU() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscoverer_Discover(t *testing.T) {
+	prober := fakeProber{
+		netip.MustParseAddr("198.51.100.1"): 1500,
+		netip.MustParseAddr("198.51.100.2"): 1440,
+		netip.MustParseAddr("2001:db8::1"):  1500,
+	}
+	iface := &fakeInterface{endpoints: []string{
+		"198.51.100.1:51820",
+		"198.51.100.2:51820",
+		"[2001:db8::1]:51820",
+		"127.0.0.1:40000",
+		"198.51.100.9:51820",
+	}}
+	d := NewDiscoverer(Config{Enabled: true, Overhead: 8, Interval: time.Minute, MaxMTU: 1420}, prober, iface, discardLogger())
+
+	st, err := d.Discover()
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	// 1440 - 60 - 8 for the peer behind another tunnel is lower than
+	// 1500 - 80 - 8 for the IPv6 peer.
+	want := Status{MTU: 1372, PathMTU: 1440, Endpoint: "198.51.100.2:51820"}
+	if st != want {
+		t.Errorf("Status = %+v, want %+v", st, want)
+	}
+	if len(iface.mtus) != 1 || iface.mtus[0] != want.MTU {
+		t.Errorf("MTUs set = %v, want [%d]", iface.mtus, want.MTU)
+	}
+
+	// An unchanged MTU is not set again.
+	if _, err := d.Discover(); err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if len(iface.mtus) != 1 {
+		t.Errorf("MTUs set = %v, want one change", iface.mtus)
+	}
+}
+
+func TestDiscoverer_Discover_Bounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		pathMTU int
+		want    int
+	}{
+		{"capped at MaxMTU", 9000, 1420},
+		{"floored at MinMTU", 1280, MinMTU},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := netip.MustParseAddr("198.51.100.1")
+			iface := &fakeInterface{endpoints: []string{"198.51.100.1:51820"}}
+			d := NewDiscoverer(Config{}, fakeProber{addr: tt.pathMTU}, iface, discardLogger())
+			st, err := d.Discover()
+			if err != nil {
+				t.Fatalf("Discover: %v", err)
+			}
+			if st.MTU != tt.want || st.PathMTU != tt.pathMTU {
+				t.Errorf("Status = %+v, want MTU %d for path MTU %d", st, tt.want, tt.pathMTU)
+			}
+		})
+	}
+}
+
+func TestDiscoverer_Discover_NothingProbed(t *testing.T) {
+	iface := &fakeInterface{endpoints: []string{"198.51.100.9:51820", "127.0.0.1:40000"}}
+	d := NewDiscoverer(Config{}, fakeProber{}, iface, discardLogger())
+
+	st, err := d.Discover()
+	if err == nil {
+		t.Error("Discover: expected probe error")
+	}
+	if st != (Status{}) || len(iface.mtus) != 0 {
+		t.Errorf("Status = %+v, MTUs set = %v, want no change", st, iface.mtus)
+	}
+}
+
+func TestDiscoverer_Discover_SetError(t *testing.T) {
+	addr := netip.MustParseAddr("198.51.100.1")
+	iface := &fakeInterface{endpoints: []string{"198.51.100.1:51820"}, setErr: errors.New("netlink failed")}
+	d := NewDiscoverer(Config{}, fakeProber{addr: 1500}, iface, discardLogger())
+
+	if _, err := d.Discover(); err == nil {
+		t.Fatal("Discover: expected error")
+	}
+	if st := d.Status(); st != (Status{}) {
+		t.Errorf("Status = %+v, want zero after failed set", st)
+	}
+}
+
+func TestDiscoverer_RunDisabled(t *testing.T) {
+	iface := &fakeInterface{endpoints: []string{"198.51.100.1:51820"}}
+	d := NewDiscoverer(Config{Enabled: false, MaxMTU: 1420}, fakeProber{}, iface, discardLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := d.Run(ctx); err != nil {
+		t.Errorf("Run = %v, want nil", err)
+	}
+}
+
+func TestKernelProber_Loopback(t *testing.T) {
+	mtu, err := KernelProber{}.PathMTU(netip.MustParseAddr("127.0.0.1"))
+	if err != nil {
+		t.Skipf("loopback path MTU unavailable: %v", err)
+	}
+	if mtu < MinMTU {
+		t.Errorf("PathMTU = %d, want at least %d", mtu, MinMTU)
+	}
+}
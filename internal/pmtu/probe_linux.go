@@ -0,0 +1,53 @@
+//go:build linux
+
+package pmtu
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+
+	"golang.org/x/sys/unix"
+)
+
+// KernelProber looks up path MTUs in the kernel's routing cache. The
+// kernel knows the MTU of the route towards an address, which covers a
+// PPPoE link or a tunnel the node itself terminates, and lowers it when
+// an ICMP "fragmentation needed" message arrives for the address.
+type KernelProber struct{}
+
+// PathMTU returns the path MTU the kernel reports for a UDP socket
+// connected to addr with path MTU discovery enabled. No packet is sent.
+func (KernelProber) PathMTU(addr netip.Addr) (int, error) {
+	network := "udp4"
+	level, discover, discoverDo, opt := unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_DO, unix.IP_MTU
+	if addr.Is6() {
+		network = "udp6"
+		level, discover, discoverDo, opt = unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, unix.IPV6_PMTUDISC_DO, unix.IPV6_MTU
+	}
+
+	conn, err := net.DialUDP(network, nil, net.UDPAddrFromAddrPort(netip.AddrPortFrom(addr, probePort)))
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var mtu int
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		if sockErr = unix.SetsockoptInt(int(fd), level, discover, discoverDo); sockErr != nil {
+			return
+		}
+		mtu, sockErr = unix.GetsockoptInt(int(fd), level, opt)
+	}); err != nil {
+		return 0, err
+	}
+	if sockErr != nil {
+		return 0, fmt.Errorf("read path MTU: %w", sockErr)
+	}
+	return mtu, nil
+}
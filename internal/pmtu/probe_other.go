@@ -0,0 +1,45 @@
+//go:build !linux
+
+package pmtu
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// KernelProber looks up path MTUs from the node's routes: the MTU of the
+// interface the route towards an address uses, which covers a PPPoE link
+// or a tunnel the node itself terminates. ICMP feedback is not visible on
+// this platform.
+type KernelProber struct{}
+
+// PathMTU returns the MTU of the interface a UDP socket connected to addr
+// is bound to. No packet is sent.
+func (KernelProber) PathMTU(addr netip.Addr) (int, error) {
+	conn, err := net.DialUDP("udp", nil, net.UDPAddrFromAddrPort(netip.AddrPortFrom(addr, probePort)))
+	if err != nil {
+		return 0, err
+	}
+	local := conn.LocalAddr().(*net.UDPAddr).AddrPort().Addr().Unmap()
+	conn.Close()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return 0, err
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok {
+				if ip, ok := netip.AddrFromSlice(ipnet.IP); ok && ip.Unmap() == local {
+					return iface.MTU, nil
+				}
+			}
+		}
+	}
+	return 0, fmt.Errorf("no interface has the source address %s", local)
+}
@@ -0,0 +1,56 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrRulesRolledBack is returned when firewall rules were applied but
+// failed verification or the apply check, and the previous rules were
+// restored.
+var ErrRulesRolledBack = errors.New("policy: rules rolled back")
+
+// ApplyCheck reports whether the node still works after its firewall rules
+// changed, such as whether the control plane is reachable. Satisfied by
+// (*api.ControlPlane).Ping.
+type ApplyCheck func(ctx context.Context) error
+
+// applyChecker runs the ApplyCheck around a change of firewall rules.
+type applyChecker struct {
+	check    ApplyCheck
+	timeout  time.Duration
+	interval time.Duration
+}
+
+// before reports whether the check passes before the change. Only then can
+// a failing check after the change be blamed on the new rules.
+func (c *applyChecker) before(ctx context.Context) bool {
+	if c.check == nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.check(ctx) == nil
+}
+
+// after runs the check every interval until it passes or the timeout
+// elapses.
+func (c *applyChecker) after(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		err := c.check(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("apply check failing after %s: %w", c.timeout, err)
+		case <-ticker.C:
+		}
+	}
+}
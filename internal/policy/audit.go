@@ -0,0 +1,94 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/plexsphere/plexd/internal/auditfwd"
+)
+
+// MutationRecorder records host changes for the audit trail. Satisfied by
+// *auditfwd.Recorder.
+type MutationRecorder interface {
+	Record(ctx context.Context, m auditfwd.Mutation)
+}
+
+// auditedFirewall is a FirewallController that records every change it
+// passes on to the wrapped controller.
+type auditedFirewall struct {
+	fw  FirewallController
+	rec MutationRecorder
+}
+
+// NewAuditedFirewall returns a FirewallController that passes every change
+// to fw and records it with rec. ApplyRules is recorded with the full rule
+// set of the chain. When fw implements EgressController, so does the
+// returned controller, and ApplyEgressRules is recorded with the full rule
+// set.
+func NewAuditedFirewall(fw FirewallController, rec MutationRecorder) FirewallController {
+	a := &auditedFirewall{fw: fw, rec: rec}
+	if egress, ok := fw.(EgressController); ok {
+		return &auditedEgressFirewall{auditedFirewall: a, egress: egress}
+	}
+	return a
+}
+
+func (a *auditedFirewall) EnsureChain(chain string) error {
+	err := a.fw.EnsureChain(chain)
+	a.record("firewall_chain_ensure", chain, map[string]any{"chain": chain}, err)
+	return err
+}
+
+func (a *auditedFirewall) ApplyRules(chain string, rules []FirewallRule) error {
+	err := a.fw.ApplyRules(chain, rules)
+	a.record("firewall_rules_apply", chain, map[string]any{"chain": chain, "rules": rules}, err)
+	return err
+}
+
+func (a *auditedFirewall) FlushChain(chain string) error {
+	err := a.fw.FlushChain(chain)
+	a.record("firewall_chain_flush", chain, map[string]any{"chain": chain}, err)
+	return err
+}
+
+func (a *auditedFirewall) DeleteChain(chain string) error {
+	err := a.fw.DeleteChain(chain)
+	a.record("firewall_chain_delete", chain, map[string]any{"chain": chain}, err)
+	return err
+}
+
+// VerifyRules passes on to the wrapped controller when it implements
+// RuleVerifier; otherwise there is nothing to verify. Verification changes
+// nothing and is not recorded.
+func (a *auditedFirewall) VerifyRules(chain string, rules []FirewallRule) error {
+	if v, ok := a.fw.(RuleVerifier); ok {
+		return v.VerifyRules(chain, rules)
+	}
+	return nil
+}
+
+// auditedEgressFirewall is an auditedFirewall that also passes on and
+// records changes to the outbound allowlist.
+type auditedEgressFirewall struct {
+	*auditedFirewall
+	egress EgressController
+}
+
+func (a *auditedEgressFirewall) ApplyEgressRules(rules EgressRuleSet) error {
+	err := a.egress.ApplyEgressRules(rules)
+	a.record("firewall_egress_apply", "egress", map[string]any{"rules": rules}, err)
+	return err
+}
+
+func (a *auditedEgressFirewall) RemoveEgressRules() error {
+	err := a.egress.RemoveEgressRules()
+	a.record("firewall_egress_remove", "egress", nil, err)
+	return err
+}
+
+func (a *auditedEgressFirewall) EgressViolations() ([]EgressViolation, error) {
+	return a.egress.EgressViolations()
+}
+
+func (a *auditedFirewall) record(action, target string, object map[string]any, err error) {
+	a.rec.Record(context.Background(), auditfwd.Mutation{Action: action, Target: target, Object: object, Err: err})
+}
@@ -1,11 +1,32 @@
 // Package policy implements network policy enforcement for plexd mesh nodes.
 package policy
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 // DefaultChainName is the default iptables chain name for policy enforcement.
 const DefaultChainName = "plexd-mesh"
 
+// DefaultEgressRefreshInterval is the default interval at which egress
+// domains are resolved again and egress violations are collected.
+const DefaultEgressRefreshInterval = 30 * time.Second
+
+// DefaultDNSMinTTL and DefaultDNSMaxTTL bound how long the resolved
+// addresses of a hostname destination are used before it is resolved again.
+const (
+	DefaultDNSMinTTL = 5 * time.Second
+	DefaultDNSMaxTTL = time.Hour
+)
+
+// DefaultApplyCheckTimeout and DefaultApplyCheckInterval control how long
+// and how often the apply check is run after firewall rules change.
+const (
+	DefaultApplyCheckTimeout  = 10 * time.Second
+	DefaultApplyCheckInterval = time.Second
+)
+
 // Config holds the configuration for network policy enforcement.
 type Config struct {
 	// Enabled controls whether policy enforcement is active.
@@ -14,6 +35,35 @@ type Config struct {
 
 	// ChainName is the iptables chain name for firewall rules.
 	ChainName string
+
+	// EgressMonitorOnly applies egress policies in the monitor mode even
+	// when the control plane asks to enforce them.
+	// Default: false.
+	EgressMonitorOnly bool
+
+	// EgressRefreshInterval is how often the domains of egress rules are
+	// resolved again and egress violations are collected.
+	// Default: 30s. Minimum: 1s.
+	EgressRefreshInterval time.Duration
+
+	// DNSMinTTL is the shortest time a hostname destination is not
+	// resolved again for, whatever the TTL of its DNS answers.
+	// Default: 5s. Minimum: 1s.
+	DNSMinTTL time.Duration
+
+	// DNSMaxTTL is the longest time a hostname destination is not
+	// resolved again for, whatever the TTL of its DNS answers.
+	// Default: 1h. Must not be less than DNSMinTTL.
+	DNSMaxTTL time.Duration
+
+	// ApplyCheckTimeout is how long the apply check may take to pass
+	// after firewall rules changed before the previous rules are restored.
+	// Default: 10s. Minimum: 1s.
+	ApplyCheckTimeout time.Duration
+
+	// ApplyCheckInterval is the interval between apply checks.
+	// Default: 1s. Must not exceed ApplyCheckTimeout.
+	ApplyCheckInterval time.Duration
 }
 
 // ApplyDefaults sets default values for zero-valued fields.
@@ -27,6 +77,21 @@ func (c *Config) ApplyDefaults() {
 		c.Enabled = true
 		c.ChainName = DefaultChainName
 	}
+	if c.EgressRefreshInterval == 0 {
+		c.EgressRefreshInterval = DefaultEgressRefreshInterval
+	}
+	if c.DNSMinTTL == 0 {
+		c.DNSMinTTL = DefaultDNSMinTTL
+	}
+	if c.DNSMaxTTL == 0 {
+		c.DNSMaxTTL = DefaultDNSMaxTTL
+	}
+	if c.ApplyCheckTimeout == 0 {
+		c.ApplyCheckTimeout = DefaultApplyCheckTimeout
+	}
+	if c.ApplyCheckInterval == 0 {
+		c.ApplyCheckInterval = DefaultApplyCheckInterval
+	}
 }
 
 // Validate checks that configuration values are within acceptable ranges.
@@ -37,5 +102,20 @@ func (c *Config) Validate() error {
 	if c.ChainName == "" {
 		return errors.New("policy: config: ChainName must not be empty when enabled")
 	}
+	if c.EgressRefreshInterval != 0 && c.EgressRefreshInterval < time.Second {
+		return errors.New("policy: config: EgressRefreshInterval must be at least 1s")
+	}
+	if c.DNSMinTTL != 0 && c.DNSMinTTL < time.Second {
+		return errors.New("policy: config: DNSMinTTL must be at least 1s")
+	}
+	if c.DNSMaxTTL != 0 && c.DNSMaxTTL < c.DNSMinTTL {
+		return errors.New("policy: config: DNSMaxTTL must not be less than DNSMinTTL")
+	}
+	if c.ApplyCheckTimeout != 0 && c.ApplyCheckTimeout < time.Second {
+		return errors.New("policy: config: ApplyCheckTimeout must be at least 1s")
+	}
+	if c.ApplyCheckInterval < 0 || c.ApplyCheckTimeout != 0 && c.ApplyCheckInterval > c.ApplyCheckTimeout {
+		return errors.New("policy: config: ApplyCheckInterval must not be negative or exceed ApplyCheckTimeout")
+	}
 	return nil
 }
@@ -1,6 +1,9 @@
 package policy
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestConfig_Defaults(t *testing.T) {
 	cfg := Config{}
@@ -79,3 +82,84 @@ func TestConfig_ValidateAcceptsCustomValues(t *testing.T) {
 		t.Errorf("Validate() = %v, want nil", err)
 	}
 }
+
+func TestConfig_EgressRefreshInterval(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+	if cfg.EgressRefreshInterval != DefaultEgressRefreshInterval {
+		t.Errorf("EgressRefreshInterval = %v, want %v", cfg.EgressRefreshInterval, DefaultEgressRefreshInterval)
+	}
+
+	cfg.EgressRefreshInterval = 500 * time.Millisecond
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want error for EgressRefreshInterval below 1s")
+	}
+	want := "policy: config: EgressRefreshInterval must be at least 1s"
+	if err.Error() != want {
+		t.Errorf("Validate() error = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestConfig_ApplyCheck(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+	if cfg.ApplyCheckTimeout != DefaultApplyCheckTimeout {
+		t.Errorf("ApplyCheckTimeout = %v, want %v", cfg.ApplyCheckTimeout, DefaultApplyCheckTimeout)
+	}
+	if cfg.ApplyCheckInterval != DefaultApplyCheckInterval {
+		t.Errorf("ApplyCheckInterval = %v, want %v", cfg.ApplyCheckInterval, DefaultApplyCheckInterval)
+	}
+
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		interval time.Duration
+		wantErr  string
+	}{
+		{"timeout below 1s", 500 * time.Millisecond, 100 * time.Millisecond, "policy: config: ApplyCheckTimeout must be at least 1s"},
+		{"negative interval", 5 * time.Second, -time.Second, "policy: config: ApplyCheckInterval must not be negative or exceed ApplyCheckTimeout"},
+		{"interval above timeout", 5 * time.Second, 10 * time.Second, "policy: config: ApplyCheckInterval must not be negative or exceed ApplyCheckTimeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{ApplyCheckTimeout: tt.timeout, ApplyCheckInterval: tt.interval}
+			cfg.ApplyDefaults()
+			err := cfg.Validate()
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Validate() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_DNSTTL(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+	if cfg.DNSMinTTL != DefaultDNSMinTTL {
+		t.Errorf("DNSMinTTL = %v, want %v", cfg.DNSMinTTL, DefaultDNSMinTTL)
+	}
+	if cfg.DNSMaxTTL != DefaultDNSMaxTTL {
+		t.Errorf("DNSMaxTTL = %v, want %v", cfg.DNSMaxTTL, DefaultDNSMaxTTL)
+	}
+
+	tests := []struct {
+		name    string
+		min     time.Duration
+		max     time.Duration
+		wantErr string
+	}{
+		{"min below 1s", 500 * time.Millisecond, time.Minute, "policy: config: DNSMinTTL must be at least 1s"},
+		{"max below min", time.Minute, 30 * time.Second, "policy: config: DNSMaxTTL must not be less than DNSMinTTL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{DNSMinTTL: tt.min, DNSMaxTTL: tt.max}
+			cfg.ApplyDefaults()
+			err := cfg.Validate()
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Validate() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
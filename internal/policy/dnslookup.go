@@ -0,0 +1,269 @@
+package policy
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsQueryTimeout bounds a single DNS query to one nameserver.
+const dnsQueryTimeout = 2 * time.Second
+
+// dnsMaxCNAMEs bounds the CNAME chain followed from a hostname.
+const dnsMaxCNAMEs = 8
+
+// resolvConfPath is the resolver configuration nameservers are read from
+// when a DNSLookup has none. It is a variable so tests can replace it.
+var resolvConfPath = "/etc/resolv.conf"
+
+// DNSLookup resolves hostnames by querying nameservers for A and AAAA
+// records directly, which, unlike net.Resolver, reports the TTL of the
+// answers.
+type DNSLookup struct {
+	servers []string
+}
+
+// NewDNSLookup creates a DNSLookup that queries servers in order, each an IP
+// address with an optional port. Without servers, the nameservers of
+// /etc/resolv.conf are used, read again on every lookup.
+func NewDNSLookup(servers ...string) *DNSLookup {
+	return &DNSLookup{servers: servers}
+}
+
+// LookupHost returns the addresses of host and the lowest TTL of the
+// records, including CNAMEs, that led to them. A name without addresses
+// yields the TTL of the negative answer.
+func (l *DNSLookup) LookupHost(ctx context.Context, host string) ([]netip.Addr, time.Duration, error) {
+	servers := l.servers
+	if len(servers) == 0 {
+		servers = resolvConfServers(resolvConfPath)
+	}
+	if len(servers) == 0 {
+		return nil, 0, errors.New("policy: dns: no nameservers")
+	}
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, 0, fmt.Errorf("policy: dns: %s: %w", host, err)
+	}
+
+	var addrs []netip.Addr
+	ttl, negativeTTL := time.Duration(math.MaxInt64), time.Duration(math.MaxInt64)
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		found, qttl, err := lookupType(ctx, servers, name, qtype)
+		if err != nil {
+			return nil, 0, fmt.Errorf("policy: dns: %s: %w", host, err)
+		}
+		if len(found) == 0 {
+			negativeTTL = min(negativeTTL, qttl)
+			continue
+		}
+		ttl = min(ttl, qttl)
+		addrs = append(addrs, found...)
+	}
+	if len(addrs) == 0 {
+		return nil, negativeTTL, nil
+	}
+	return addrs, ttl, nil
+}
+
+// lookupType queries servers in order for the qtype records of name until
+// one answers. A server failure moves on to the next server.
+func lookupType(ctx context.Context, servers []string, name dnsmessage.Name, qtype dnsmessage.Type) ([]netip.Addr, time.Duration, error) {
+	var errs []error
+	for _, server := range servers {
+		addrs, ttl, err := queryServer(ctx, dnsServerAddr(server), name, qtype)
+		if err == nil {
+			return addrs, ttl, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", server, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, 0, errors.Join(errs...)
+}
+
+// queryServer queries server for the qtype records of name, over UDP and
+// again over TCP when the answer is truncated.
+func queryServer(ctx context.Context, server string, name dnsmessage.Name, qtype dnsmessage.Type) ([]netip.Addr, time.Duration, error) {
+	q := dnsmessage.Question{Name: name, Type: qtype, Class: dnsmessage.ClassINET}
+	id := uint16(rand.Uint32())
+	query, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{q},
+	}).Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var resp dnsmessage.Message
+	for _, network := range []string{"udp", "tcp"} {
+		raw, err := exchangeDNS(ctx, network, server, query)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := resp.Unpack(raw); err != nil {
+			return nil, 0, err
+		}
+		if !resp.Truncated {
+			break
+		}
+	}
+	if resp.ID != id || !resp.Response || len(resp.Questions) != 1 ||
+		!strings.EqualFold(resp.Questions[0].Name.String(), name.String()) || resp.Questions[0].Type != qtype {
+		return nil, 0, errors.New("answer does not match the query")
+	}
+	switch resp.RCode {
+	case dnsmessage.RCodeSuccess, dnsmessage.RCodeNameError:
+	default:
+		return nil, 0, fmt.Errorf("server answered %s", resp.RCode)
+	}
+	addrs, ttl := answerAddrs(&resp, name, qtype)
+	return addrs, ttl, nil
+}
+
+// answerAddrs follows the CNAME chain from name through the answers of resp
+// and returns the qtype addresses at its end and the lowest TTL of the
+// chain. Without addresses, the TTL is that of the SOA record of the
+// negative answer, or 0.
+func answerAddrs(resp *dnsmessage.Message, name dnsmessage.Name, qtype dnsmessage.Type) ([]netip.Addr, time.Duration) {
+	target := name.String()
+	chainTTL := uint32(math.MaxUint32)
+	for range dnsMaxCNAMEs {
+		var cname string
+		for _, rr := range resp.Answers {
+			if rr.Header.Type == dnsmessage.TypeCNAME && strings.EqualFold(rr.Header.Name.String(), target) {
+				cname = rr.Body.(*dnsmessage.CNAMEResource).CNAME.String()
+				chainTTL = min(chainTTL, rr.Header.TTL)
+				break
+			}
+		}
+		if cname == "" {
+			break
+		}
+		target = cname
+	}
+
+	var addrs []netip.Addr
+	ttl := chainTTL
+	for _, rr := range resp.Answers {
+		if rr.Header.Type != qtype || !strings.EqualFold(rr.Header.Name.String(), target) {
+			continue
+		}
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			addrs = append(addrs, netip.AddrFrom4(body.A))
+		case *dnsmessage.AAAAResource:
+			addrs = append(addrs, netip.AddrFrom16(body.AAAA))
+		}
+		ttl = min(ttl, rr.Header.TTL)
+	}
+	if len(addrs) > 0 {
+		return addrs, time.Duration(ttl) * time.Second
+	}
+
+	// A negative answer is cached for the lower of the SOA record's TTL
+	// and its minimum field (RFC 2308).
+	for _, rr := range resp.Authorities {
+		if soa, ok := rr.Body.(*dnsmessage.SOAResource); ok {
+			return nil, time.Duration(min(chainTTL, rr.Header.TTL, soa.MinTTL)) * time.Second
+		}
+	}
+	return nil, 0
+}
+
+// exchangeDNS sends query to server over network and returns the answer
+// with the same ID.
+func exchangeDNS(ctx context.Context, network, server string, query []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsQueryTimeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if network == "tcp" {
+		msg := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+		if _, err := conn.Write(append(msg, query...)); err != nil {
+			return nil, err
+		}
+		r := bufio.NewReader(conn)
+		var size uint16
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf, nil
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1232)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// Stray responses, such as late answers to an earlier query on a
+		// reused port, are skipped.
+		if n >= 2 && buf[0] == query[0] && buf[1] == query[1] {
+			return buf[:n], nil
+		}
+	}
+}
+
+// dnsServerAddr returns server as host:port, adding port 53 to a bare
+// address.
+func dnsServerAddr(server string) string {
+	if ap, err := netip.ParseAddrPort(server); err == nil {
+		return ap.String()
+	}
+	return net.JoinHostPort(server, "53")
+}
+
+// resolvConfServers returns the nameservers of the resolver configuration
+// at path.
+func resolvConfServers(path string) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+		addr, err := netip.ParseAddr(fields[1])
+		if err != nil || addr.Zone() != "" {
+			continue
+		}
+		servers = append(servers, addr.String())
+	}
+	return servers
+}
@@ -0,0 +1,160 @@
+package policy
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// serveTestDNS answers the queries it receives on a local UDP port with
+// the response answer builds and returns the address of the server.
+func serveTestDNS(t *testing.T, answer func(q dnsmessage.Question, resp *dnsmessage.Message)) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) != 1 {
+				continue
+			}
+			resp := &dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true},
+				Questions: query.Questions,
+			}
+			answer(query.Questions[0], resp)
+			out, err := resp.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(out, from)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func testRR(name string, typ dnsmessage.Type, ttl uint32, body dnsmessage.ResourceBody) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{
+			Name:  dnsmessage.MustNewName(name),
+			Type:  typ,
+			Class: dnsmessage.ClassINET,
+			TTL:   ttl,
+		},
+		Body: body,
+	}
+}
+
+func TestDNSLookup_FollowsCNAMEs(t *testing.T) {
+	server := serveTestDNS(t, func(q dnsmessage.Question, resp *dnsmessage.Message) {
+		resp.Answers = append(resp.Answers, testRR("api.example.com.", dnsmessage.TypeCNAME, 300, &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName("lb.example.net.")}))
+		switch q.Type {
+		case dnsmessage.TypeA:
+			resp.Answers = append(resp.Answers,
+				testRR("lb.example.net.", dnsmessage.TypeA, 60, &dnsmessage.AResource{A: [4]byte{203, 0, 113, 10}}),
+				testRR("other.example.net.", dnsmessage.TypeA, 5, &dnsmessage.AResource{A: [4]byte{203, 0, 113, 99}}),
+			)
+		case dnsmessage.TypeAAAA:
+			resp.Answers = append(resp.Answers,
+				testRR("lb.example.net.", dnsmessage.TypeAAAA, 120, &dnsmessage.AAAAResource{AAAA: netip.MustParseAddr("2001:db8::10").As16()}),
+			)
+		}
+	})
+
+	got, ttl, err := NewDNSLookup(server).LookupHost(context.Background(), "api.example.com")
+	if err != nil {
+		t.Fatalf("LookupHost() error = %v", err)
+	}
+	want := []netip.Addr{netip.MustParseAddr("203.0.113.10"), netip.MustParseAddr("2001:db8::10")}
+	if !slices.Equal(got, want) {
+		t.Errorf("LookupHost() addresses = %v, want %v", got, want)
+	}
+	if ttl != 60*time.Second {
+		t.Errorf("LookupHost() TTL = %v, want 60s", ttl)
+	}
+}
+
+func TestDNSLookup_NegativeAnswer(t *testing.T) {
+	server := serveTestDNS(t, func(q dnsmessage.Question, resp *dnsmessage.Message) {
+		resp.RCode = dnsmessage.RCodeNameError
+		resp.Authorities = []dnsmessage.Resource{testRR("example.com.", dnsmessage.TypeSOA, 3600, &dnsmessage.SOAResource{
+			NS:     dnsmessage.MustNewName("ns.example.com."),
+			MBox:   dnsmessage.MustNewName("hostmaster.example.com."),
+			MinTTL: 90,
+		})}
+	})
+
+	got, ttl, err := NewDNSLookup(server).LookupHost(context.Background(), "missing.example.com")
+	if err != nil {
+		t.Fatalf("LookupHost() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("LookupHost() addresses = %v, want none", got)
+	}
+	if ttl != 90*time.Second {
+		t.Errorf("LookupHost() TTL = %v, want 90s", ttl)
+	}
+}
+
+func TestDNSLookup_ServerFailureTriesNextServer(t *testing.T) {
+	failing := serveTestDNS(t, func(_ dnsmessage.Question, resp *dnsmessage.Message) {
+		resp.RCode = dnsmessage.RCodeServerFailure
+	})
+	working := serveTestDNS(t, func(q dnsmessage.Question, resp *dnsmessage.Message) {
+		if q.Type == dnsmessage.TypeA {
+			resp.Answers = []dnsmessage.Resource{testRR(q.Name.String(), dnsmessage.TypeA, 30, &dnsmessage.AResource{A: [4]byte{203, 0, 113, 10}})}
+		}
+	})
+
+	got, _, err := NewDNSLookup(failing, working).LookupHost(context.Background(), "api.example.com")
+	if err != nil {
+		t.Fatalf("LookupHost() error = %v", err)
+	}
+	if want := []netip.Addr{netip.MustParseAddr("203.0.113.10")}; !slices.Equal(got, want) {
+		t.Errorf("LookupHost() addresses = %v, want %v", got, want)
+	}
+
+	_, _, err = NewDNSLookup(failing).LookupHost(context.Background(), "api.example.com")
+	if err == nil {
+		t.Error("LookupHost() with only a failing server = nil, want error")
+	}
+}
+
+func TestDNSLookup_ResolvConf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	orig := resolvConfPath
+	resolvConfPath = path
+	t.Cleanup(func() { resolvConfPath = orig })
+
+	if err := os.WriteFile(path, []byte("# none\nsearch example.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := NewDNSLookup().LookupHost(context.Background(), "api.example.com"); err == nil {
+		t.Error("LookupHost() without nameservers = nil, want error")
+	}
+
+	content := "nameserver 192.0.2.53\nnameserver fe80::1%eth0\noptions ndots:1\nnameserver 2001:db8::53\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"192.0.2.53", "2001:db8::53"}
+	if got := resolvConfServers(path); !slices.Equal(got, want) {
+		t.Errorf("resolvConfServers() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,456 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/validation"
+)
+
+// Egress policy modes.
+const (
+	// EgressModeMonitor records outbound traffic the policy does not allow
+	// but lets it pass.
+	EgressModeMonitor = "monitor"
+	// EgressModeEnforce rejects outbound traffic the policy does not allow.
+	EgressModeEnforce = "enforce"
+)
+
+// maxPendingEgressAudits bounds the audit entries buffered between Collect
+// calls; the oldest are dropped first.
+const maxPendingEgressAudits = 256
+
+// egressAuditEventType is the event type of egress violation audit entries.
+const egressAuditEventType = "egress_violation"
+
+// egressResolveTimeout bounds the resolution of a single egress domain.
+const egressResolveTimeout = 10 * time.Second
+
+// EgressFilter allows outbound traffic to the addresses in Prefix.
+type EgressFilter struct {
+	Prefix netip.Prefix
+	// Protocol is "tcp" or "udp"; empty allows any protocol.
+	Protocol string
+	// Port is the destination port; 0 allows any port.
+	Port int
+}
+
+// EgressRuleSet is the outbound allowlist of the node. Traffic on the mesh
+// interface and WireGuard traffic from ListenPort are always allowed, as are
+// replies to connections the node accepted.
+type EgressRuleSet struct {
+	MeshInterface string
+	ListenPort    int
+	// Enforce rejects traffic no filter allows; otherwise it is only
+	// recorded as a violation.
+	Enforce bool
+	Filters []EgressFilter
+}
+
+// EgressViolation is outbound traffic to a destination no filter allowed.
+type EgressViolation struct {
+	Destination netip.Addr
+	// Protocol is "tcp", "udp", "icmp", "icmpv6", or the IP protocol
+	// number.
+	Protocol string
+	// Port is the destination port; 0 for protocols without ports.
+	Port int
+}
+
+// EgressController is implemented by firewall controllers that can restrict
+// the outbound traffic of the node. EgressEnforcer checks for it with a type
+// assertion.
+type EgressController interface {
+	// ApplyEgressRules replaces the outbound allowlist atomically.
+	ApplyEgressRules(rules EgressRuleSet) error
+	// RemoveEgressRules removes the outbound allowlist.
+	// Implementations must be idempotent.
+	RemoveEgressRules() error
+	// EgressViolations returns and clears the violations recorded since
+	// the last call.
+	EgressViolations() ([]EgressViolation, error)
+}
+
+// Resolver resolves the domains of egress rules. Satisfied by *net.Resolver.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// EgressEnforcer restricts the outbound traffic of the node on non-mesh
+// interfaces to the destinations an egress policy allows.
+type EgressEnforcer struct {
+	ctrl       EgressController
+	cfg        Config
+	meshIface  string
+	listenPort int
+	resolver   Resolver
+	checker    applyChecker
+	hosts      []string
+	hostname   string
+	logger     *slog.Logger
+
+	// mu serializes policy changes and refreshes.
+	mu       sync.Mutex
+	policy   *api.EgressPolicy
+	resolved map[string][]netip.Addr
+	applied  *EgressRuleSet
+	// rejected is the last rule set that was rolled back, and
+	// rejectedErr why.
+	rejected    *EgressRuleSet
+	rejectedErr error
+
+	auditMu sync.Mutex
+	audits  []api.AuditEntry
+}
+
+// NewEgressEnforcer creates an EgressEnforcer. Traffic on meshIface and
+// WireGuard traffic from listenPort are never restricted. Egress policies
+// are only applied when firewall implements EgressController.
+func NewEgressEnforcer(firewall FirewallController, cfg Config, meshIface string, listenPort int, logger *slog.Logger) *EgressEnforcer {
+	cfg.ApplyDefaults()
+	ctrl, _ := firewall.(EgressController)
+	hostname, _ := os.Hostname()
+	return &EgressEnforcer{
+		ctrl:       ctrl,
+		cfg:        cfg,
+		meshIface:  meshIface,
+		listenPort: listenPort,
+		resolver:   net.DefaultResolver,
+		checker:    applyChecker{timeout: cfg.ApplyCheckTimeout, interval: cfg.ApplyCheckInterval},
+		hostname:   hostname,
+		logger:     logger.With("component", "policy"),
+	}
+}
+
+// SetResolver replaces the resolver for the domains of egress rules.
+// Must be called before Apply or Run.
+func (e *EgressEnforcer) SetResolver(r Resolver) {
+	e.resolver = r
+}
+
+// SetApplyCheck sets the check that must keep passing after enforced
+// egress rules change; otherwise the previous rules are restored. Must be
+// called before Apply or Run.
+func (e *EgressEnforcer) SetApplyCheck(check ApplyCheck) {
+	e.checker.check = check
+}
+
+// AllowHosts allows outbound traffic to hosts, names or IP addresses,
+// whatever the policy, so that the node keeps reaching the control plane.
+// Must be called before Apply or Run.
+func (e *EgressEnforcer) AllowHosts(hosts ...string) {
+	e.hosts = append(e.hosts, hosts...)
+}
+
+// Apply applies an egress policy; nil removes the current one. A policy
+// with an invalid mode is rejected and the current one kept. Invalid rules
+// are skipped and returned as validation.Errors, which the reconciler
+// reports as drift. Apply is a no-op when policy enforcement is disabled or
+// the firewall cannot restrict outbound traffic.
+func (e *EgressEnforcer) Apply(ctx context.Context, p *api.EgressPolicy) error {
+	if !e.cfg.Enabled {
+		return nil
+	}
+	if e.ctrl == nil {
+		if p != nil {
+			e.logger.Warn("no egress firewall backend available, skipping egress policy")
+		}
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if p == nil {
+		if e.policy == nil {
+			return nil
+		}
+		if err := e.ctrl.RemoveEgressRules(); err != nil {
+			return fmt.Errorf("policy: egress: %w", err)
+		}
+		e.policy, e.resolved, e.applied, e.rejected = nil, nil, nil, nil
+		e.logger.Info("removed egress policy")
+		return nil
+	}
+
+	if invalid := validation.EgressMode(p.Mode); invalid != nil {
+		e.logger.Warn("egress policy rejected", "error", invalid)
+		return invalid
+	}
+
+	var invalid validation.Errors
+	valid := &api.EgressPolicy{Mode: p.Mode}
+	for i, rule := range p.Rules {
+		if errs := validation.EgressRule(i, rule); errs != nil {
+			for _, err := range errs {
+				e.logger.Warn("egress policy: invalid rule",
+					"destination", err.ID,
+					"field", err.Field,
+					"code", err.Code,
+					"error", err.Message,
+				)
+			}
+			invalid = append(invalid, errs...)
+			continue
+		}
+		valid.Rules = append(valid.Rules, rule)
+	}
+	if e.applied != nil && e.policy.Mode == valid.Mode && slices.Equal(e.policy.Rules, valid.Rules) {
+		// Unchanged; Run resolves the domains again.
+		return invalid.Err()
+	}
+	e.policy = valid
+
+	if err := e.refreshLocked(ctx); err != nil {
+		return errors.Join(err, invalid.Err())
+	}
+	return invalid.Err()
+}
+
+// Run resolves the domains of the egress policy again and collects egress
+// violations every EgressRefreshInterval until ctx is cancelled. Run always
+// returns nil.
+func (e *EgressEnforcer) Run(ctx context.Context) error {
+	if !e.cfg.Enabled || e.ctrl == nil {
+		return nil
+	}
+
+	ticker := time.NewTicker(e.cfg.EgressRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			e.mu.Lock()
+			err := e.refreshLocked(ctx)
+			e.mu.Unlock()
+			if err != nil {
+				e.logger.Error("egress policy refresh failed", "error", err)
+			}
+			e.collectViolations()
+		}
+	}
+}
+
+// Teardown removes the egress rules. It is safe to call when the firewall
+// cannot restrict outbound traffic.
+func (e *EgressEnforcer) Teardown() error {
+	if e.ctrl == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.ctrl.RemoveEgressRules(); err != nil {
+		return fmt.Errorf("policy: egress: teardown: %w", err)
+	}
+	e.policy, e.resolved, e.applied, e.rejected = nil, nil, nil, nil
+	return nil
+}
+
+// Collect returns and clears the audit entries recorded since the last
+// call: one per egress violation. It implements auditfwd.AuditSource.
+func (e *EgressEnforcer) Collect(_ context.Context) ([]api.AuditEntry, error) {
+	e.auditMu.Lock()
+	defer e.auditMu.Unlock()
+	entries := e.audits
+	e.audits = nil
+	return entries, nil
+}
+
+// refreshLocked builds the rule set of the current policy and applies it
+// when it changed. Enforced rules must keep the apply check passing if it
+// passed before; otherwise they are rolled back and not applied again until
+// they change. Caller must hold e.mu.
+func (e *EgressEnforcer) refreshLocked(ctx context.Context) error {
+	if e.policy == nil {
+		return nil
+	}
+
+	rules := EgressRuleSet{
+		MeshInterface: e.meshIface,
+		ListenPort:    e.listenPort,
+		Enforce:       e.policy.Mode == EgressModeEnforce && !e.cfg.EgressMonitorOnly,
+	}
+	resolved := make(map[string][]netip.Addr)
+	for _, host := range e.hosts {
+		for _, prefix := range e.prefixes(ctx, host, resolved) {
+			rules.Filters = append(rules.Filters, EgressFilter{Prefix: prefix})
+		}
+	}
+	for _, rule := range e.policy.Rules {
+		for _, prefix := range e.prefixes(ctx, rule.Destination, resolved) {
+			rules.Filters = append(rules.Filters, EgressFilter{Prefix: prefix, Protocol: rule.Protocol, Port: rule.Port})
+		}
+	}
+	e.resolved = resolved
+
+	if sameEgressRules(e.applied, rules) {
+		return nil
+	}
+	if sameEgressRules(e.rejected, rules) {
+		return fmt.Errorf("%w: unchanged egress rules: %v", ErrRulesRolledBack, e.rejectedErr)
+	}
+	checked := rules.Enforce && e.checker.before(ctx)
+	if err := e.ctrl.ApplyEgressRules(rules); err != nil {
+		return fmt.Errorf("policy: egress: %w", err)
+	}
+	if checked {
+		if err := e.checker.after(ctx); err != nil {
+			return e.rollbackLocked(rules, err)
+		}
+	}
+	e.applied, e.rejected, e.rejectedErr = &rules, nil, nil
+
+	mode := EgressModeMonitor
+	if rules.Enforce {
+		mode = EgressModeEnforce
+	}
+	e.logger.Info("applied egress policy", "mode", mode, "filters", len(rules.Filters))
+	return nil
+}
+
+// rollbackLocked restores the last applied rule set after rejected failed
+// the apply check with cause, or removes the egress rules when none was
+// applied. Caller must hold e.mu.
+func (e *EgressEnforcer) rollbackLocked(rejected EgressRuleSet, cause error) error {
+	e.logger.Error("egress rules failed the apply check, restoring the previous rules", "error", cause)
+	e.rejected, e.rejectedErr = &rejected, cause
+
+	var err error
+	if e.applied != nil {
+		err = e.ctrl.ApplyEgressRules(*e.applied)
+	} else {
+		err = e.ctrl.RemoveEgressRules()
+	}
+	if err != nil {
+		return fmt.Errorf("policy: egress: %v; rollback failed: %w", cause, err)
+	}
+	return fmt.Errorf("%w: %v", ErrRulesRolledBack, cause)
+}
+
+// sameEgressRules reports whether a is set and applies the same filters in
+// the same mode as b.
+func sameEgressRules(a *EgressRuleSet, b EgressRuleSet) bool {
+	return a != nil && a.Enforce == b.Enforce && slices.Equal(a.Filters, b.Filters)
+}
+
+// prefixes returns the prefixes of dest, a CIDR, an IP address, or a
+// domain. A domain is resolved and its addresses stored in resolved; when
+// it cannot be resolved, its last known addresses are used.
+func (e *EgressEnforcer) prefixes(ctx context.Context, dest string, resolved map[string][]netip.Addr) []netip.Prefix {
+	if prefix, err := netip.ParsePrefix(dest); err == nil {
+		return []netip.Prefix{prefix.Masked()}
+	}
+	if addr, err := netip.ParseAddr(dest); err == nil {
+		return []netip.Prefix{netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())}
+	}
+
+	addrs, ok := resolved[dest]
+	if !ok {
+		addrs = e.resolve(ctx, dest)
+		resolved[dest] = addrs
+	}
+	prefixes := make([]netip.Prefix, 0, len(addrs))
+	for _, addr := range addrs {
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes
+}
+
+// resolve returns the sorted addresses of name, or its last known
+// addresses when it cannot be resolved.
+func (e *EgressEnforcer) resolve(ctx context.Context, name string) []netip.Addr {
+	ctx, cancel := context.WithTimeout(ctx, egressResolveTimeout)
+	defer cancel()
+
+	found, err := e.resolver.LookupNetIP(ctx, "ip", name)
+	if err != nil {
+		e.logger.Warn("egress policy: resolve domain failed, keeping last known addresses",
+			"domain", name,
+			"addresses", len(e.resolved[name]),
+			"error", err,
+		)
+		return e.resolved[name]
+	}
+	addrs := make([]netip.Addr, 0, len(found))
+	for _, addr := range found {
+		addr = addr.Unmap()
+		if !slices.Contains(addrs, addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+	slices.SortFunc(addrs, netip.Addr.Compare)
+	return addrs
+}
+
+// collectViolations fetches the violations recorded by the firewall and
+// queues an audit entry for each.
+func (e *EgressEnforcer) collectViolations() {
+	e.mu.Lock()
+	applied := e.applied
+	e.mu.Unlock()
+	if applied == nil {
+		return
+	}
+
+	violations, err := e.ctrl.EgressViolations()
+	if err != nil {
+		e.logger.Error("egress policy: collect violations failed", "error", err)
+		return
+	}
+	if len(violations) == 0 {
+		return
+	}
+
+	result := "monitored"
+	if applied.Enforce {
+		result = "denied"
+	}
+	e.logger.Warn("egress policy violations", "count", len(violations), "result", result)
+
+	e.auditMu.Lock()
+	defer e.auditMu.Unlock()
+	for _, v := range violations {
+		e.audits = append(e.audits, egressViolationAudit(v, result, e.hostname))
+	}
+	if over := len(e.audits) - maxPendingEgressAudits; over > 0 {
+		e.audits = e.audits[over:]
+	}
+}
+
+// egressViolationAudit returns the audit entry for an egress violation.
+func egressViolationAudit(v EgressViolation, result, hostname string) api.AuditEntry {
+	subject, _ := json.Marshal(map[string]string{"hostname": hostname})
+	object, _ := json.Marshal(map[string]any{
+		"destination": v.Destination.String(),
+		"protocol":    v.Protocol,
+		"port":        v.Port,
+	})
+	dest := v.Destination.String()
+	if v.Port > 0 {
+		dest = netip.AddrPortFrom(v.Destination, uint16(v.Port)).String()
+	}
+	return api.AuditEntry{
+		Timestamp: time.Now().UTC(),
+		Source:    "plexd",
+		EventType: egressAuditEventType,
+		Subject:   subject,
+		Object:    object,
+		Action:    "egress",
+		Result:    result,
+		Hostname:  hostname,
+		Raw:       fmt.Sprintf("outbound %s traffic to %s not allowed by the egress policy (%s)", v.Protocol, dest, result),
+	}
+}
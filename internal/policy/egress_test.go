@@ -0,0 +1,423 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/reconcile"
+	"github.com/plexsphere/plexd/internal/validation"
+)
+
+// mockEgressController is a mockFirewallController that also implements
+// EgressController.
+type mockEgressController struct {
+	mockFirewallController
+
+	mu          sync.Mutex
+	applied     []EgressRuleSet
+	removeCalls int
+	violations  []EgressViolation
+	applyErr    error
+}
+
+func (m *mockEgressController) ApplyEgressRules(rules EgressRuleSet) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.applied = append(m.applied, rules)
+	return m.applyErr
+}
+
+func (m *mockEgressController) RemoveEgressRules() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removeCalls++
+	return nil
+}
+
+func (m *mockEgressController) EgressViolations() ([]EgressViolation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v := m.violations
+	m.violations = nil
+	return v, nil
+}
+
+func (m *mockEgressController) last() EgressRuleSet {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.applied[len(m.applied)-1]
+}
+
+// mockResolver resolves names from a map; missing names fail.
+type mockResolver struct {
+	mu    sync.Mutex
+	addrs map[string][]netip.Addr
+}
+
+func (r *mockResolver) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	addrs, ok := r.addrs[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return addrs, nil
+}
+
+func (r *mockResolver) set(host string, addrs ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if addrs == nil {
+		delete(r.addrs, host)
+		return
+	}
+	r.addrs[host] = nil
+	for _, a := range addrs {
+		r.addrs[host] = append(r.addrs[host], netip.MustParseAddr(a))
+	}
+}
+
+func newTestEgressEnforcer(cfg Config) (*EgressEnforcer, *mockEgressController, *mockResolver) {
+	ctrl := &mockEgressController{}
+	res := &mockResolver{addrs: make(map[string][]netip.Addr)}
+	e := NewEgressEnforcer(ctrl, cfg, "plexd0", 51820, testLogger())
+	e.SetResolver(res)
+	return e, ctrl, res
+}
+
+func TestEgressEnforcer_Apply(t *testing.T) {
+	e, ctrl, res := newTestEgressEnforcer(Config{})
+	res.set("updates.example.com", "2001:db8::2", "198.51.100.7", "::ffff:198.51.100.7")
+	res.set("cp.example.com", "192.0.2.10")
+	e.AllowHosts("cp.example.com")
+
+	err := e.Apply(context.Background(), &api.EgressPolicy{
+		Mode: EgressModeEnforce,
+		Rules: []api.EgressRule{
+			{Destination: "10.1.2.3/16"},
+			{Destination: "203.0.113.5", Port: 53, Protocol: "udp"},
+			{Destination: "updates.example.com", Port: 443, Protocol: "tcp"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	want := EgressRuleSet{
+		MeshInterface: "plexd0",
+		ListenPort:    51820,
+		Enforce:       true,
+		Filters: []EgressFilter{
+			{Prefix: netip.MustParsePrefix("192.0.2.10/32")},
+			{Prefix: netip.MustParsePrefix("10.1.0.0/16")},
+			{Prefix: netip.MustParsePrefix("203.0.113.5/32"), Protocol: "udp", Port: 53},
+			{Prefix: netip.MustParsePrefix("198.51.100.7/32"), Protocol: "tcp", Port: 443},
+			{Prefix: netip.MustParsePrefix("2001:db8::2/128"), Protocol: "tcp", Port: 443},
+		},
+	}
+	if len(ctrl.applied) != 1 || !reflect.DeepEqual(ctrl.applied[0], want) {
+		t.Fatalf("applied = %+v, want %+v", ctrl.applied, want)
+	}
+
+	// An unchanged policy is not applied again.
+	if err := e.Apply(context.Background(), &api.EgressPolicy{Mode: EgressModeEnforce, Rules: []api.EgressRule{
+		{Destination: "10.1.2.3/16"},
+		{Destination: "203.0.113.5", Port: 53, Protocol: "udp"},
+		{Destination: "updates.example.com", Port: 443, Protocol: "tcp"},
+	}}); err != nil {
+		t.Fatalf("Apply unchanged: %v", err)
+	}
+	if len(ctrl.applied) != 1 {
+		t.Errorf("ApplyEgressRules called %d times, want 1", len(ctrl.applied))
+	}
+
+	// Nil removes the rules.
+	if err := e.Apply(context.Background(), nil); err != nil {
+		t.Fatalf("Apply nil: %v", err)
+	}
+	if ctrl.removeCalls != 1 {
+		t.Errorf("RemoveEgressRules called %d times, want 1", ctrl.removeCalls)
+	}
+}
+
+func TestEgressEnforcer_MonitorOnly(t *testing.T) {
+	e, ctrl, _ := newTestEgressEnforcer(Config{EgressMonitorOnly: true})
+	err := e.Apply(context.Background(), &api.EgressPolicy{
+		Mode:  EgressModeEnforce,
+		Rules: []api.EgressRule{{Destination: "10.0.0.0/8"}},
+	})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if ctrl.last().Enforce {
+		t.Error("rules enforced despite EgressMonitorOnly")
+	}
+}
+
+func TestEgressEnforcer_InvalidPolicy(t *testing.T) {
+	e, ctrl, _ := newTestEgressEnforcer(Config{})
+
+	err := e.Apply(context.Background(), &api.EgressPolicy{
+		Mode: EgressModeMonitor,
+		Rules: []api.EgressRule{
+			{Destination: "10.0.0.0/8"},
+			{Destination: "not a host"},
+		},
+	})
+	var verrs validation.Errors
+	if !errors.As(err, &verrs) || len(verrs) != 1 || verrs[0].Code != validation.CodeInvalidDestination {
+		t.Fatalf("Apply error = %v, want an invalid destination", err)
+	}
+	if got := ctrl.last().Filters; len(got) != 1 || got[0].Prefix != netip.MustParsePrefix("10.0.0.0/8") {
+		t.Errorf("filters = %+v, want the valid rule only", got)
+	}
+
+	// A policy with an invalid mode is rejected and the current one kept.
+	err = e.Apply(context.Background(), &api.EgressPolicy{Mode: "block"})
+	if !errors.As(err, &verrs) || verrs[0].Code != validation.CodeInvalidMode {
+		t.Fatalf("Apply error = %v, want an invalid mode", err)
+	}
+	if len(ctrl.applied) != 1 || ctrl.removeCalls != 0 {
+		t.Errorf("rules changed by a rejected policy: applied %d, removed %d", len(ctrl.applied), ctrl.removeCalls)
+	}
+}
+
+func TestEgressEnforcer_KeepsLastKnownAddresses(t *testing.T) {
+	e, ctrl, res := newTestEgressEnforcer(Config{})
+	res.set("api.example.com", "198.51.100.7")
+	if err := e.Apply(context.Background(), &api.EgressPolicy{
+		Mode:  EgressModeMonitor,
+		Rules: []api.EgressRule{{Destination: "api.example.com"}},
+	}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	res.set("api.example.com")
+	e.mu.Lock()
+	err := e.refreshLocked(context.Background())
+	e.mu.Unlock()
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if len(ctrl.applied) != 1 {
+		t.Errorf("rules applied again after a failed resolution: %+v", ctrl.applied)
+	}
+
+	res.set("api.example.com", "198.51.100.8")
+	e.mu.Lock()
+	err = e.refreshLocked(context.Background())
+	e.mu.Unlock()
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if got := ctrl.last().Filters; len(got) != 1 || got[0].Prefix != netip.MustParsePrefix("198.51.100.8/32") {
+		t.Errorf("filters = %+v, want the new address", got)
+	}
+}
+
+func TestEgressEnforcer_ApplyError(t *testing.T) {
+	e, ctrl, _ := newTestEgressEnforcer(Config{})
+	ctrl.applyErr = errors.New("netlink: permission denied")
+	err := e.Apply(context.Background(), &api.EgressPolicy{Mode: EgressModeMonitor})
+	if err == nil || err.Error() != "policy: egress: netlink: permission denied" {
+		t.Errorf("Apply error = %v", err)
+	}
+}
+
+func TestEgressEnforcer_Violations(t *testing.T) {
+	e, ctrl, _ := newTestEgressEnforcer(Config{EgressRefreshInterval: 10 * time.Millisecond})
+	if err := e.Apply(context.Background(), &api.EgressPolicy{Mode: EgressModeEnforce}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	ctrl.mu.Lock()
+	ctrl.violations = []EgressViolation{{Destination: netip.MustParseAddr("203.0.113.9"), Protocol: "tcp", Port: 22}}
+	ctrl.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx)
+		close(done)
+	}()
+
+	var entries []api.AuditEntry
+	deadline := time.Now().Add(5 * time.Second)
+	for len(entries) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		entries, _ = e.Collect(context.Background())
+	}
+	cancel()
+	<-done
+
+	if len(entries) != 1 {
+		t.Fatalf("audit entries = %d, want 1", len(entries))
+	}
+	entry := entries[0]
+	if entry.EventType != egressAuditEventType || entry.Action != "egress" || entry.Result != "denied" {
+		t.Errorf("entry = %+v", entry)
+	}
+	var object map[string]any
+	if err := json.Unmarshal(entry.Object, &object); err != nil {
+		t.Fatalf("unmarshal object: %v", err)
+	}
+	if object["destination"] != "203.0.113.9" || object["protocol"] != "tcp" || object["port"] != float64(22) {
+		t.Errorf("object = %v", object)
+	}
+	if entries, _ := e.Collect(context.Background()); len(entries) != 0 {
+		t.Errorf("Collect returned %d entries again", len(entries))
+	}
+}
+
+func TestEgressEnforcer_AuditQueueBounded(t *testing.T) {
+	e, ctrl, _ := newTestEgressEnforcer(Config{})
+	if err := e.Apply(context.Background(), &api.EgressPolicy{Mode: EgressModeMonitor}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	for i := range maxPendingEgressAudits + 10 {
+		ctrl.violations = append(ctrl.violations, EgressViolation{Destination: netip.MustParseAddr("203.0.113.9"), Protocol: "udp", Port: i + 1})
+	}
+	e.collectViolations()
+
+	entries, _ := e.Collect(context.Background())
+	if len(entries) != maxPendingEgressAudits {
+		t.Fatalf("audit entries = %d, want %d", len(entries), maxPendingEgressAudits)
+	}
+	if entries[0].Result != "monitored" {
+		t.Errorf("Result = %q, want monitored", entries[0].Result)
+	}
+	var object map[string]any
+	json.Unmarshal(entries[0].Object, &object)
+	if object["port"] != float64(11) {
+		t.Errorf("oldest entry port = %v, want 11", object["port"])
+	}
+}
+
+func TestEgressEnforcer_NoEgressController(t *testing.T) {
+	e := NewEgressEnforcer(&mockFirewallController{}, Config{}, "plexd0", 51820, testLogger())
+	if err := e.Apply(context.Background(), &api.EgressPolicy{Mode: EgressModeEnforce}); err != nil {
+		t.Errorf("Apply: %v", err)
+	}
+	if err := e.Run(context.Background()); err != nil {
+		t.Errorf("Run: %v", err)
+	}
+	if err := e.Teardown(); err != nil {
+		t.Errorf("Teardown: %v", err)
+	}
+}
+
+func TestEgressEnforcer_Disabled(t *testing.T) {
+	e, ctrl, _ := newTestEgressEnforcer(Config{Enabled: false, ChainName: "TEST"})
+	if err := e.Apply(context.Background(), &api.EgressPolicy{Mode: EgressModeEnforce}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if len(ctrl.applied) != 0 {
+		t.Errorf("rules applied with policy enforcement disabled: %+v", ctrl.applied)
+	}
+}
+
+func TestEgressEnforcer_RollsBackOnFailedCheck(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+	cfg.ApplyCheckTimeout = 50 * time.Millisecond
+	cfg.ApplyCheckInterval = 10 * time.Millisecond
+	e, ctrl, _ := newTestEgressEnforcer(cfg)
+	// The check fails while the enforced rules allow only 10.0.0.0/8.
+	e.SetApplyCheck(func(context.Context) error {
+		ctrl.mu.Lock()
+		defer ctrl.mu.Unlock()
+		if n := len(ctrl.applied); n > 0 && ctrl.applied[n-1].Enforce {
+			return errors.New("control plane unreachable")
+		}
+		return nil
+	})
+	p := &api.EgressPolicy{Mode: EgressModeMonitor, Rules: []api.EgressRule{{Destination: "10.0.0.0/8"}}}
+	if err := e.Apply(context.Background(), p); err != nil {
+		t.Fatalf("Apply monitor: %v", err)
+	}
+	monitor := ctrl.last()
+
+	enforce := &api.EgressPolicy{Mode: EgressModeEnforce, Rules: p.Rules}
+	if err := e.Apply(context.Background(), enforce); !errors.Is(err, ErrRulesRolledBack) {
+		t.Fatalf("Apply enforce = %v, want ErrRulesRolledBack", err)
+	}
+	if got := ctrl.last(); !reflect.DeepEqual(got, monitor) {
+		t.Errorf("restored rules = %+v, want %+v", got, monitor)
+	}
+
+	// A refresh does not apply the rejected rules again.
+	applied := len(ctrl.applied)
+	e.mu.Lock()
+	err := e.refreshLocked(context.Background())
+	e.mu.Unlock()
+	if !errors.Is(err, ErrRulesRolledBack) {
+		t.Errorf("refresh = %v, want ErrRulesRolledBack", err)
+	}
+	if len(ctrl.applied) != applied {
+		t.Errorf("ApplyEgressRules called %d times for rejected rules, want 0", len(ctrl.applied)-applied)
+	}
+}
+
+func TestEgressEnforcer_RollbackWithoutPreviousRules(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+	cfg.ApplyCheckTimeout = 50 * time.Millisecond
+	cfg.ApplyCheckInterval = 10 * time.Millisecond
+	e, ctrl, _ := newTestEgressEnforcer(cfg)
+	e.SetApplyCheck(func(context.Context) error {
+		ctrl.mu.Lock()
+		defer ctrl.mu.Unlock()
+		if len(ctrl.applied) > 0 {
+			return errors.New("control plane unreachable")
+		}
+		return nil
+	})
+
+	p := &api.EgressPolicy{Mode: EgressModeEnforce, Rules: []api.EgressRule{{Destination: "10.0.0.0/8"}}}
+	if err := e.Apply(context.Background(), p); !errors.Is(err, ErrRulesRolledBack) {
+		t.Fatalf("Apply = %v, want ErrRulesRolledBack", err)
+	}
+	if ctrl.removeCalls != 1 {
+		t.Errorf("RemoveEgressRules called %d times, want 1", ctrl.removeCalls)
+	}
+}
+
+func TestNewAuditedFirewall_Egress(t *testing.T) {
+	if _, ok := NewAuditedFirewall(&mockFirewallController{}, nil).(EgressController); ok {
+		t.Error("audited firewall implements EgressController without an egress backend")
+	}
+	if _, ok := NewAuditedFirewall(&mockEgressController{}, nil).(EgressController); !ok {
+		t.Error("audited firewall hides the EgressController of its backend")
+	}
+}
+
+func TestEgressReconcileHandler(t *testing.T) {
+	e, ctrl, _ := newTestEgressEnforcer(Config{})
+	handler := EgressReconcileHandler(e)
+
+	desired := &api.StateResponse{EgressPolicy: &api.EgressPolicy{
+		Mode:  EgressModeMonitor,
+		Rules: []api.EgressRule{{Destination: "10.0.0.0/8"}, {Destination: "10.0.0.0/33"}},
+	}}
+	err := handler(context.Background(), desired, reconcile.StateDiff{})
+	var dr reconcile.DriftReporter
+	if !errors.As(err, &dr) || len(dr.DriftCorrections()) != 1 {
+		t.Fatalf("handler error = %v, want one drift correction", err)
+	}
+	if len(ctrl.applied) != 1 {
+		t.Fatalf("ApplyEgressRules called %d times, want 1", len(ctrl.applied))
+	}
+
+	if err := handler(context.Background(), &api.StateResponse{}, reconcile.StateDiff{}); err != nil {
+		t.Fatalf("handler without policy: %v", err)
+	}
+	if ctrl.removeCalls != 1 {
+		t.Errorf("RemoveEgressRules called %d times, want 1", ctrl.removeCalls)
+	}
+}
@@ -1,8 +1,10 @@
 package policy
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"slices"
 
 	"github.com/plexsphere/plexd/internal/api"
 )
@@ -12,8 +14,17 @@ import (
 type Enforcer struct {
 	engine   *PolicyEngine
 	firewall FirewallController
+	hosts    *HostResolver
+	checker  applyChecker
 	cfg      Config
 	logger   *slog.Logger
+
+	// applied is the last rule set that passed verification; nil until
+	// one did. rejected is the last rule set that was rolled back, and
+	// rejectedErr why.
+	applied     []FirewallRule
+	rejected    []FirewallRule
+	rejectedErr error
 }
 
 // NewEnforcer creates an Enforcer. The firewall parameter may be nil if no
@@ -23,11 +34,26 @@ func NewEnforcer(engine *PolicyEngine, firewall FirewallController, cfg Config,
 	return &Enforcer{
 		engine:   engine,
 		firewall: firewall,
+		checker:  applyChecker{timeout: cfg.ApplyCheckTimeout, interval: cfg.ApplyCheckInterval},
 		cfg:      cfg,
 		logger:   logger.With("component", "policy"),
 	}
 }
 
+// SetHostResolver sets the HostResolver that programs the addresses of
+// hostname destinations. Without one, rules with a hostname destination are
+// skipped. Must be called before ApplyFirewallRules.
+func (e *Enforcer) SetHostResolver(r *HostResolver) {
+	e.hosts = r
+}
+
+// SetApplyCheck sets the check that must keep passing after the firewall
+// rules change; otherwise the previous rules are restored. Must be called
+// before ApplyFirewallRules.
+func (e *Enforcer) SetApplyCheck(check ApplyCheck) {
+	e.checker.check = check
+}
+
 // FilterPeers returns the peers allowed by the configured policies.
 // If policy enforcement is disabled, all peers are returned unchanged.
 func (e *Enforcer) FilterPeers(peers []api.Peer, policies []api.Policy, localNodeID string) []api.Peer {
@@ -40,6 +66,12 @@ func (e *Enforcer) FilterPeers(peers []api.Peer, policies []api.Policy, localNod
 // ApplyFirewallRules builds firewall rules from the given policies and applies
 // them via the FirewallController. It is a no-op when enforcement is disabled
 // or no firewall backend is available.
+//
+// A changed rule set is verified when the firewall implements RuleVerifier,
+// and the apply check must pass within ApplyCheckTimeout if it passed before
+// the change. Otherwise the previous rules are restored, ErrRulesRolledBack
+// is returned, and the rejected rule set is not applied again until it
+// changes.
 func (e *Enforcer) ApplyFirewallRules(policies []api.Policy, localNodeID string, iface string, peersByID map[string]string) error {
 	if !e.cfg.Enabled {
 		return nil
@@ -51,12 +83,46 @@ func (e *Enforcer) ApplyFirewallRules(policies []api.Policy, localNodeID string,
 
 	rules := e.engine.BuildFirewallRules(policies, localNodeID, iface, peersByID)
 
+	hosts := ruleHosts(rules)
+	if len(hosts) > 0 && e.hosts == nil {
+		e.logger.Warn("no hostname resolver available, skipping rules with hostname destinations", "hosts", hosts)
+		rules = slices.DeleteFunc(rules, func(r FirewallRule) bool { return r.DstHost != "" })
+		hosts = nil
+	}
+	if e.rejected != nil && slices.Equal(rules, e.rejected) {
+		return fmt.Errorf("%w: unchanged rules: %v", ErrRulesRolledBack, e.rejectedErr)
+	}
+	if e.hosts != nil {
+		if err := e.hosts.Track(hosts); err != nil {
+			return fmt.Errorf("policy: enforce: %w", err)
+		}
+	}
+
+	changed := e.applied == nil || !slices.Equal(rules, e.applied)
+	checked := changed && e.checker.before(context.Background())
 	if err := e.firewall.EnsureChain(e.cfg.ChainName); err != nil {
 		return fmt.Errorf("policy: enforce: %w", err)
 	}
+	// ApplyRules is atomic: when it fails, the previous rules are still in
+	// place.
 	if err := e.firewall.ApplyRules(e.cfg.ChainName, rules); err != nil {
 		return fmt.Errorf("policy: enforce: %w", err)
 	}
+	if err := e.verify(rules, checked); err != nil {
+		if !changed {
+			return fmt.Errorf("policy: enforce: %w", err)
+		}
+		return e.rollback(rules, err)
+	}
+	e.applied, e.rejected, e.rejectedErr = rules, nil, nil
+
+	if e.hosts != nil {
+		// Hosts no rule references any more are only forgotten once the
+		// rules that matched their addresses are gone.
+		if err := e.hosts.Retain(hosts); err != nil {
+			return fmt.Errorf("policy: enforce: %w", err)
+		}
+	}
 
 	e.logger.Info("applied firewall rules", "count", len(rules), "chain", e.cfg.ChainName)
 	return nil
@@ -74,5 +140,67 @@ func (e *Enforcer) Teardown() error {
 	if err := e.firewall.DeleteChain(e.cfg.ChainName); err != nil {
 		return fmt.Errorf("policy: teardown: %w", err)
 	}
+	e.applied, e.rejected, e.rejectedErr = nil, nil, nil
+	if e.hosts != nil {
+		if err := e.hosts.Teardown(); err != nil {
+			return fmt.Errorf("policy: teardown: %w", err)
+		}
+	}
+	return nil
+}
+
+// verify checks that the chain holds rules, when the firewall implements
+// RuleVerifier, and, when check is set, that the apply check passes.
+func (e *Enforcer) verify(rules []FirewallRule, check bool) error {
+	if v, ok := e.firewall.(RuleVerifier); ok {
+		if err := v.VerifyRules(e.cfg.ChainName, rules); err != nil {
+			return err
+		}
+	}
+	if check {
+		return e.checker.after(context.Background())
+	}
 	return nil
 }
+
+// rollback restores the last verified rule set after rejected failed with
+// cause, or removes all rules when none was verified yet, so that a bad
+// rule set cannot lock the node out.
+func (e *Enforcer) rollback(rejected []FirewallRule, cause error) error {
+	e.logger.Error("firewall rules failed verification, restoring the previous rules",
+		"chain", e.cfg.ChainName,
+		"error", cause,
+	)
+	e.rejected, e.rejectedErr = rejected, cause
+
+	var err error
+	if e.applied != nil {
+		err = e.firewall.ApplyRules(e.cfg.ChainName, e.applied)
+	} else {
+		err = e.firewall.FlushChain(e.cfg.ChainName)
+	}
+	if err == nil {
+		err = e.verify(e.applied, false)
+	}
+	if err != nil {
+		return fmt.Errorf("policy: enforce: %v; rollback failed: %w", cause, err)
+	}
+	if e.hosts != nil {
+		if err := e.hosts.Retain(ruleHosts(e.applied)); err != nil {
+			e.logger.Warn("failed to remove hostname destinations of the rejected rules", "error", err)
+		}
+	}
+	e.logger.Info("restored previous firewall rules", "count", len(e.applied), "chain", e.cfg.ChainName)
+	return fmt.Errorf("%w: %v", ErrRulesRolledBack, cause)
+}
+
+// ruleHosts returns the hostname destinations of rules.
+func ruleHosts(rules []FirewallRule) []string {
+	var hosts []string
+	for _, r := range rules {
+		if r.DstHost != "" && !slices.Contains(hosts, r.DstHost) {
+			hosts = append(hosts, r.DstHost)
+		}
+	}
+	return hosts
+}
@@ -1,8 +1,13 @@
 package policy
 
 import (
+	"context"
 	"errors"
+	"net/netip"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/plexsphere/plexd/internal/api"
 )
@@ -269,3 +274,230 @@ func TestEnforcer_TeardownFlushError(t *testing.T) {
 		t.Errorf("DeleteChain called %d times, want 0 (should not be called after flush error)", len(mock.deleteChainCalls))
 	}
 }
+
+func TestEnforcer_ApplyFirewallRulesHostnameDestination(t *testing.T) {
+	eng := NewPolicyEngine(testLogger())
+	mock := &mockFirewallController{}
+	cfg := Config{Enabled: true, ChainName: "TEST-CHAIN"}
+	enf := NewEnforcer(eng, mock, cfg, testLogger())
+	hostCtrl := newMockHostController()
+	res := NewHostResolver(hostCtrl, cfg, testLogger())
+	res.SetLookup(&mockHostLookup{answers: map[string][]netip.Addr{
+		"api.example.com": {netip.MustParseAddr("203.0.113.10")},
+	}, ttl: time.Minute})
+	enf.SetHostResolver(res)
+
+	policies := []api.Policy{
+		{
+			ID: "pol-1",
+			Rules: []api.PolicyRule{
+				{Src: "node-a", Dst: "api.example.com", Port: 443, Protocol: "tcp", Action: "allow"},
+			},
+		},
+	}
+	peersByID := map[string]string{"node-a": "10.0.0.1"}
+
+	if err := enf.ApplyFirewallRules(policies, "node-a", "wg0", peersByID); err != nil {
+		t.Fatalf("ApplyFirewallRules() error = %v, want nil", err)
+	}
+	want := []netip.Addr{netip.MustParseAddr("203.0.113.10")}
+	if got := hostCtrl.addrs["api.example.com"]; !slices.Equal(got, want) {
+		t.Errorf("host addresses = %v, want %v", got, want)
+	}
+	if got := mock.applyRulesCalls[0].Rules[0].DstHost; got != "api.example.com" {
+		t.Errorf("ApplyRules DstHost = %q, want %q", got, "api.example.com")
+	}
+
+	// Once no rule references the host, its addresses are removed.
+	if err := enf.ApplyFirewallRules(nil, "node-a", "wg0", peersByID); err != nil {
+		t.Fatalf("ApplyFirewallRules() without policies error = %v, want nil", err)
+	}
+	if !slices.Equal(hostCtrl.removed, []string{"api.example.com"}) {
+		t.Errorf("removed hosts = %v, want [api.example.com]", hostCtrl.removed)
+	}
+}
+
+func TestEnforcer_ApplyFirewallRulesHostnameWithoutResolver(t *testing.T) {
+	eng := NewPolicyEngine(testLogger())
+	mock := &mockFirewallController{}
+	cfg := Config{Enabled: true, ChainName: "TEST-CHAIN"}
+	enf := NewEnforcer(eng, mock, cfg, testLogger())
+
+	policies := []api.Policy{
+		{
+			ID: "pol-1",
+			Rules: []api.PolicyRule{
+				{Src: "node-a", Dst: "api.example.com", Action: "allow"},
+			},
+		},
+	}
+	if err := enf.ApplyFirewallRules(policies, "node-a", "wg0", map[string]string{"node-a": "10.0.0.1"}); err != nil {
+		t.Fatalf("ApplyFirewallRules() error = %v, want nil", err)
+	}
+	// Only the default-deny rule is left.
+	if rules := mock.applyRulesCalls[0].Rules; len(rules) != 1 || rules[0].Action != "deny" {
+		t.Errorf("ApplyRules rules = %+v, want only the default deny", rules)
+	}
+}
+
+// mockVerifyingFirewall is a mockFirewallController that implements
+// RuleVerifier, failing the verification of rule sets with verifyErr.
+type mockVerifyingFirewall struct {
+	*mockFirewallController
+	verifyCalls int
+	verifyErr   func(rules []FirewallRule) error
+}
+
+func (m *mockVerifyingFirewall) VerifyRules(_ string, rules []FirewallRule) error {
+	m.verifyCalls++
+	if m.verifyErr != nil {
+		return m.verifyErr(rules)
+	}
+	return nil
+}
+
+func rollbackTestConfig() Config {
+	cfg := Config{Enabled: true, ChainName: "TEST-CHAIN"}
+	cfg.ApplyDefaults()
+	cfg.ApplyCheckTimeout = 50 * time.Millisecond
+	cfg.ApplyCheckInterval = 10 * time.Millisecond
+	return cfg
+}
+
+var (
+	allowPeerB    = []api.Policy{{ID: "pol-1", Rules: []api.PolicyRule{{Src: "node-a", Dst: "peer-b", Action: "allow"}}}}
+	allowPeerC    = []api.Policy{{ID: "pol-2", Rules: []api.PolicyRule{{Src: "node-a", Dst: "peer-c", Action: "allow"}}}}
+	rollbackPeers = map[string]string{"node-a": "10.0.0.1", "peer-b": "10.0.0.2", "peer-c": "10.0.0.3"}
+)
+
+func TestEnforcer_ApplyFirewallRulesRollsBackOnFailedCheck(t *testing.T) {
+	mock := &mockFirewallController{}
+	enf := NewEnforcer(NewPolicyEngine(testLogger()), mock, rollbackTestConfig(), testLogger())
+	// The check fails while the rules allowing peer-c are in place.
+	enf.SetApplyCheck(func(context.Context) error {
+		if n := len(mock.applyRulesCalls); n > 0 && mock.applyRulesCalls[n-1].Rules[0].DstIP == "10.0.0.3" {
+			return errors.New("control plane unreachable")
+		}
+		return nil
+	})
+
+	if err := enf.ApplyFirewallRules(allowPeerB, "node-a", "wg0", rollbackPeers); err != nil {
+		t.Fatalf("ApplyFirewallRules(peer-b) error = %v", err)
+	}
+	good := mock.applyRulesCalls[len(mock.applyRulesCalls)-1].Rules
+
+	err := enf.ApplyFirewallRules(allowPeerC, "node-a", "wg0", rollbackPeers)
+	if !errors.Is(err, ErrRulesRolledBack) {
+		t.Fatalf("ApplyFirewallRules(peer-c) error = %v, want ErrRulesRolledBack", err)
+	}
+	if !strings.Contains(err.Error(), "control plane unreachable") {
+		t.Errorf("error = %q, want the cause", err)
+	}
+	restored := mock.applyRulesCalls[len(mock.applyRulesCalls)-1].Rules
+	if !slices.Equal(restored, good) {
+		t.Errorf("restored rules = %+v, want %+v", restored, good)
+	}
+
+	// The rejected rules are not applied again until they change.
+	calls := len(mock.applyRulesCalls)
+	if err := enf.ApplyFirewallRules(allowPeerC, "node-a", "wg0", rollbackPeers); !errors.Is(err, ErrRulesRolledBack) {
+		t.Errorf("ApplyFirewallRules(peer-c) again error = %v, want ErrRulesRolledBack", err)
+	}
+	if len(mock.applyRulesCalls) != calls {
+		t.Errorf("ApplyRules called %d times for rejected rules, want 0", len(mock.applyRulesCalls)-calls)
+	}
+	if err := enf.ApplyFirewallRules(allowPeerB, "node-a", "wg0", rollbackPeers); err != nil {
+		t.Errorf("ApplyFirewallRules(peer-b) again error = %v", err)
+	}
+}
+
+func TestEnforcer_ApplyFirewallRulesRollsBackOnFailedVerification(t *testing.T) {
+	fw := &mockVerifyingFirewall{mockFirewallController: &mockFirewallController{}}
+	enf := NewEnforcer(NewPolicyEngine(testLogger()), fw, rollbackTestConfig(), testLogger())
+	fw.verifyErr = func(rules []FirewallRule) error {
+		if len(rules) > 0 && rules[0].DstIP == "10.0.0.3" {
+			return errors.New("rule 0 does not match")
+		}
+		return nil
+	}
+
+	// Without rules verified before, a rollback removes all rules.
+	err := enf.ApplyFirewallRules(allowPeerC, "node-a", "wg0", rollbackPeers)
+	if !errors.Is(err, ErrRulesRolledBack) {
+		t.Fatalf("ApplyFirewallRules() error = %v, want ErrRulesRolledBack", err)
+	}
+	if !slices.Equal(fw.flushChainCalls, []string{"TEST-CHAIN"}) {
+		t.Errorf("FlushChain calls = %v, want [TEST-CHAIN]", fw.flushChainCalls)
+	}
+	// The new rules and the empty chain after the rollback.
+	if fw.verifyCalls != 2 {
+		t.Errorf("VerifyRules called %d times, want 2", fw.verifyCalls)
+	}
+}
+
+func TestEnforcer_ApplyFirewallRulesRollbackFails(t *testing.T) {
+	mock := &mockFirewallController{flushChainErr: errors.New("netlink: busy")}
+	enf := NewEnforcer(NewPolicyEngine(testLogger()), mock, rollbackTestConfig(), testLogger())
+	enf.SetApplyCheck(func(context.Context) error {
+		if len(mock.applyRulesCalls) > 0 {
+			return errors.New("control plane unreachable")
+		}
+		return nil
+	})
+
+	err := enf.ApplyFirewallRules(allowPeerB, "node-a", "wg0", rollbackPeers)
+	if err == nil || errors.Is(err, ErrRulesRolledBack) {
+		t.Fatalf("ApplyFirewallRules() error = %v, want rollback failure", err)
+	}
+	if !strings.Contains(err.Error(), "rollback failed") || !strings.Contains(err.Error(), "netlink: busy") {
+		t.Errorf("error = %q, want rollback failure with its cause", err)
+	}
+}
+
+func TestEnforcer_ApplyFirewallRulesCheckFailingBeforeIsIgnored(t *testing.T) {
+	mock := &mockFirewallController{}
+	enf := NewEnforcer(NewPolicyEngine(testLogger()), mock, rollbackTestConfig(), testLogger())
+	checks := 0
+	enf.SetApplyCheck(func(context.Context) error {
+		checks++
+		return errors.New("control plane down")
+	})
+
+	// A check that already fails cannot blame the new rules.
+	if err := enf.ApplyFirewallRules(allowPeerB, "node-a", "wg0", rollbackPeers); err != nil {
+		t.Fatalf("ApplyFirewallRules() error = %v, want nil", err)
+	}
+	if checks != 1 {
+		t.Errorf("apply check called %d times, want 1", checks)
+	}
+	if len(mock.flushChainCalls) != 0 {
+		t.Errorf("FlushChain called %d times, want 0", len(mock.flushChainCalls))
+	}
+
+	// Unchanged rules are not checked.
+	if err := enf.ApplyFirewallRules(allowPeerB, "node-a", "wg0", rollbackPeers); err != nil {
+		t.Fatalf("ApplyFirewallRules() unchanged error = %v, want nil", err)
+	}
+	if checks != 1 {
+		t.Errorf("apply check called %d times for unchanged rules, want 1", checks)
+	}
+}
+
+func TestNewAuditedFirewall_VerifyRules(t *testing.T) {
+	fw := &mockVerifyingFirewall{mockFirewallController: &mockFirewallController{}}
+	fw.verifyErr = func([]FirewallRule) error { return errors.New("rules differ") }
+	audited, ok := NewAuditedFirewall(fw, nil).(RuleVerifier)
+	if !ok {
+		t.Fatal("audited firewall does not implement RuleVerifier")
+	}
+	if err := audited.VerifyRules("TEST-CHAIN", nil); err == nil {
+		t.Error("VerifyRules() = nil, want the error of the wrapped firewall")
+	}
+	if fw.verifyCalls != 1 {
+		t.Errorf("VerifyRules called %d times on the wrapped firewall, want 1", fw.verifyCalls)
+	}
+
+	if err := NewAuditedFirewall(&mockFirewallController{}, nil).(RuleVerifier).VerifyRules("TEST-CHAIN", nil); err != nil {
+		t.Errorf("VerifyRules() without a verifying firewall = %v, want nil", err)
+	}
+}
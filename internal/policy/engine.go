@@ -2,8 +2,10 @@ package policy
 
 import (
 	"log/slog"
+	"strings"
 
 	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/validation"
 )
 
 // PolicyEngine evaluates network policies to determine peer visibility
@@ -73,7 +75,9 @@ func (e *PolicyEngine) peerAllowed(peerID, localNodeID string, policies []api.Po
 
 // BuildFirewallRules converts policy rules into concrete FirewallRule entries
 // for the local node. peersByID maps peer IDs to their mesh IPs. Only rules
-// that reference localNodeID (or the wildcard "*") are included.
+// that reference localNodeID (or the wildcard "*") are included. A Dst that
+// is not a peer ID but a hostname (see hostDestination) becomes the DstHost
+// of the rule.
 func (e *PolicyEngine) BuildFirewallRules(policies []api.Policy, localNodeID string, iface string, peersByID map[string]string) []FirewallRule {
 	localIP := peersByID[localNodeID]
 	var rules []FirewallRule
@@ -95,12 +99,16 @@ func (e *PolicyEngine) BuildFirewallRules(policies []api.Policy, localNodeID str
 				continue
 			}
 
-			var srcIP, dstIP string
+			var srcIP, dstIP, dstHost string
 
 			if srcMatchesLocal && !dstMatchesLocal {
-				// Outbound: local → peer
+				// Outbound: local → peer or hostname
 				srcIP = localIP
-				dstIP = e.resolveIP(r.Dst, peersByID)
+				if host, ok := hostDestination(r.Dst, peersByID); ok {
+					dstHost = host
+				} else {
+					dstIP = e.resolveIP(r.Dst, peersByID)
+				}
 			} else if dstMatchesLocal && !srcMatchesLocal {
 				// Inbound: peer → local
 				srcIP = e.resolveIP(r.Src, peersByID)
@@ -115,6 +123,7 @@ func (e *PolicyEngine) BuildFirewallRules(policies []api.Policy, localNodeID str
 				Interface: iface,
 				SrcIP:     srcIP,
 				DstIP:     dstIP,
+				DstHost:   dstHost,
 				Port:      r.Port,
 				Protocol:  r.Protocol,
 				Action:    r.Action,
@@ -153,6 +162,20 @@ func (e *PolicyEngine) resolveIP(id string, peersByID map[string]string) string
 	return peersByID[id]
 }
 
+// hostDestination reports whether dst is a hostname rather than a peer ID:
+// a DNS name with at least one dot that is not a key of peersByID. It
+// returns the name in lowercase, without a trailing dot.
+func hostDestination(dst string, peersByID map[string]string) (string, bool) {
+	if _, ok := peersByID[dst]; ok {
+		return "", false
+	}
+	host := strings.ToLower(strings.TrimSuffix(dst, "."))
+	if !strings.Contains(host, ".") || validation.DomainName(host) != nil {
+		return "", false
+	}
+	return host, true
+}
+
 // resolveWildcard returns "0.0.0.0/0" for "*", otherwise the given fallback IP.
 func (e *PolicyEngine) resolveWildcard(id, fallback string) string {
 	if id == "*" {
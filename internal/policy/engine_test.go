@@ -312,3 +312,36 @@ func TestBuildFirewallRules_BothWildcards(t *testing.T) {
 		t.Errorf("rule[0].DstIP = %q, want %q", got[0].DstIP, "0.0.0.0/0")
 	}
 }
+
+func TestBuildFirewallRules_HostnameDestination(t *testing.T) {
+	eng := NewPolicyEngine(testLogger())
+	policies := []api.Policy{
+		{
+			ID: "pol-1",
+			Rules: []api.PolicyRule{
+				{Src: "node-a", Dst: "API.Example.com.", Port: 443, Protocol: "tcp", Action: "allow"},
+				{Src: "node-a", Dst: "peer-b", Action: "allow"},
+				{Src: "node-a", Dst: "unknown-peer", Action: "allow"},
+			},
+		},
+	}
+	peersByID := map[string]string{
+		"node-a": "10.0.0.1",
+		"peer-b": "10.0.0.2",
+	}
+
+	got := eng.BuildFirewallRules(policies, "node-a", "wg0", peersByID)
+	if len(got) != 4 {
+		t.Fatalf("BuildFirewallRules() returned %d rules, want 4", len(got))
+	}
+	if got[0].DstHost != "api.example.com" || got[0].DstIP != "" {
+		t.Errorf("hostname rule DstHost = %q, DstIP = %q, want %q and empty", got[0].DstHost, got[0].DstIP, "api.example.com")
+	}
+	if got[1].DstHost != "" || got[1].DstIP != "10.0.0.2" {
+		t.Errorf("peer rule DstHost = %q, DstIP = %q, want empty and %q", got[1].DstHost, got[1].DstIP, "10.0.0.2")
+	}
+	// A peer ID without a dot is never taken for a hostname.
+	if got[2].DstHost != "" {
+		t.Errorf("unknown peer rule DstHost = %q, want empty", got[2].DstHost)
+	}
+}
@@ -1,12 +1,16 @@
 package policy
 
-import "fmt"
+import (
+	"fmt"
+	"net/netip"
+)
 
 // FirewallRule describes a single iptables-style packet filter rule.
 type FirewallRule struct {
 	Interface string // network interface name
 	SrcIP     string // source IP (CIDR or single IP)
 	DstIP     string // destination IP (CIDR or single IP)
+	DstHost   string // destination hostname, matched by its resolved addresses instead of DstIP
 	Port      int    // destination port (0 = any)
 	Protocol  string // "tcp", "udp", or "" (any)
 	Action    string // "allow" or "deny"
@@ -27,6 +31,9 @@ func (r *FirewallRule) Validate() error {
 	if r.Port > 0 && r.Protocol == "" {
 		return fmt.Errorf("policy: firewall rule: port %d requires a protocol", r.Port)
 	}
+	if r.DstHost != "" && r.DstIP != "" {
+		return fmt.Errorf("policy: firewall rule: DstHost %q and DstIP %q are mutually exclusive", r.DstHost, r.DstIP)
+	}
 	return nil
 }
 
@@ -42,3 +49,25 @@ type FirewallController interface {
 	// Implementations must be idempotent: deleting a non-existent chain must return nil.
 	DeleteChain(chain string) error
 }
+
+// RuleVerifier is implemented by firewall controllers that can read back the
+// rules of a chain. The Enforcer checks for it with a type assertion and
+// verifies every rule set it applies.
+type RuleVerifier interface {
+	// VerifyRules returns an error unless chain holds exactly rules, in
+	// order.
+	VerifyRules(chain string, rules []FirewallRule) error
+}
+
+// HostAddressController is implemented by firewall controllers that can
+// match rules against the resolved addresses of a hostname
+// (FirewallRule.DstHost). HostResolver programs the addresses through it,
+// and the Enforcer checks for it with a type assertion.
+type HostAddressController interface {
+	// SetHostAddresses replaces the addresses of host atomically.
+	SetHostAddresses(host string, addrs []netip.Addr) error
+	// RemoveHost removes the addresses of host. It is called once no rule
+	// references host any more.
+	// Implementations must be idempotent.
+	RemoveHost(host string) error
+}
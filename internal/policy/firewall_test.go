@@ -10,6 +10,7 @@ func TestFirewallRule_ValidateAcceptsValid(t *testing.T) {
 		{Action: "deny", Port: 53, Protocol: "udp"},
 		{Action: "allow", Protocol: "tcp"},
 		{Action: "allow", SrcIP: "10.0.0.0/8", DstIP: "192.168.1.1", Interface: "eth0"},
+		{Action: "allow", SrcIP: "10.0.0.1", DstHost: "api.example.com", Port: 443, Protocol: "tcp"},
 	}
 	for _, r := range valid {
 		if err := r.Validate(); err != nil {
@@ -51,3 +52,10 @@ func TestFirewallRule_ValidateRejectsPortWithoutProtocol(t *testing.T) {
 		t.Error("Validate() accepted port > 0 with empty protocol")
 	}
 }
+
+func TestFirewallRule_ValidateRejectsDstHostWithDstIP(t *testing.T) {
+	r := FirewallRule{Action: "allow", DstIP: "192.168.1.1", DstHost: "api.example.com"}
+	if err := r.Validate(); err == nil {
+		t.Error("Validate() accepted both DstIP and DstHost")
+	}
+}
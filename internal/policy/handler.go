@@ -98,6 +98,18 @@ func hasPolicyOrPeerChanges(diff reconcile.StateDiff) bool {
 		len(diff.PeersToUpdate) > 0
 }
 
+// EgressReconcileHandler returns a reconcile.ReconcileHandler that applies
+// the desired egress policy (see EgressEnforcer.Apply). Invalid rules are
+// returned as validation.Errors, which the reconciler reports as drift.
+func EgressReconcileHandler(enforcer *EgressEnforcer) reconcile.ReconcileHandler {
+	return func(ctx context.Context, desired *api.StateResponse, _ reconcile.StateDiff) error {
+		if desired == nil {
+			return nil
+		}
+		return enforcer.Apply(ctx, desired.EgressPolicy)
+	}
+}
+
 // HandlePolicyUpdated returns an api.EventHandler that triggers reconciliation
 // when a policy_updated SSE event is received.
 func HandlePolicyUpdated(trigger ReconcileTrigger) api.EventHandler {
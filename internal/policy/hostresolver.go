@@ -0,0 +1,233 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+)
+
+// hostAddrRetention is how long an address stays in the set of a hostname
+// after the TTL of the last answer that contained it expired, so that
+// clients that cached an older answer keep reaching the host.
+const hostAddrRetention = 5 * time.Minute
+
+// hostResolveTimeout bounds the resolution of a single hostname.
+const hostResolveTimeout = 10 * time.Second
+
+// HostLookup resolves the hostnames of policy rules.
+type HostLookup interface {
+	// LookupHost returns the IPv4 and IPv6 addresses of host and the TTL
+	// of the answer. A name without addresses is not an error; it yields
+	// no addresses.
+	LookupHost(ctx context.Context, host string) ([]netip.Addr, time.Duration, error)
+}
+
+// trackedHost is the resolution state of one hostname.
+type trackedHost struct {
+	// expires holds each known address and when it is dropped.
+	expires map[netip.Addr]time.Time
+	// refresh is when the host is resolved again.
+	refresh time.Time
+	// programmed is the address set last passed to the controller; nil
+	// until the controller accepted one.
+	programmed []netip.Addr
+}
+
+// HostResolver keeps the address sets of hostname policy destinations
+// current. Each host is resolved again when the TTL of its last answer
+// expires, clamped to DNSMinTTL and DNSMaxTTL.
+type HostResolver struct {
+	ctrl   HostAddressController
+	lookup HostLookup
+	minTTL time.Duration
+	maxTTL time.Duration
+	logger *slog.Logger
+	now    func() time.Time
+	wake   chan struct{}
+
+	// mu serializes resolutions and changes to the tracked hosts.
+	mu    sync.Mutex
+	hosts map[string]*trackedHost
+}
+
+// NewHostResolver creates a HostResolver that programs the addresses of
+// hostnames into ctrl. Hostnames are resolved with a DNSLookup using the
+// nameservers of /etc/resolv.conf.
+func NewHostResolver(ctrl HostAddressController, cfg Config, logger *slog.Logger) *HostResolver {
+	cfg.ApplyDefaults()
+	return &HostResolver{
+		ctrl:   ctrl,
+		lookup: NewDNSLookup(),
+		minTTL: cfg.DNSMinTTL,
+		maxTTL: cfg.DNSMaxTTL,
+		logger: logger.With("component", "policy"),
+		now:    time.Now,
+		wake:   make(chan struct{}, 1),
+		hosts:  make(map[string]*trackedHost),
+	}
+}
+
+// SetLookup replaces the DNSLookup. Must be called before Track or Run.
+func (r *HostResolver) SetLookup(l HostLookup) {
+	r.lookup = l
+}
+
+// Track starts tracking hosts. Hosts that are not tracked yet are resolved
+// and their addresses programmed before Track returns, so that rules
+// matching them can be applied right after; so are tracked hosts whose
+// addresses could not be programmed yet. A host that cannot be resolved is
+// programmed without addresses and resolved again after DNSMinTTL.
+func (r *HostResolver) Track(hosts []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	added := false
+	for _, host := range hosts {
+		th, ok := r.hosts[host]
+		if ok && th.programmed != nil {
+			continue
+		}
+		if !ok {
+			th = &trackedHost{expires: make(map[netip.Addr]time.Time)}
+			r.hosts[host] = th
+			added = true
+		}
+		if err := r.resolveLocked(context.Background(), host, th); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if added {
+		select {
+		case r.wake <- struct{}{}:
+		default:
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Retain stops tracking all hosts but hosts and removes their addresses.
+func (r *HostResolver) Retain(hosts []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	for host := range r.hosts {
+		if slices.Contains(hosts, host) {
+			continue
+		}
+		if err := r.ctrl.RemoveHost(host); err != nil {
+			errs = append(errs, fmt.Errorf("policy: dns: %s: %w", host, err))
+			continue
+		}
+		delete(r.hosts, host)
+	}
+	return errors.Join(errs...)
+}
+
+// Teardown stops tracking all hosts and removes their addresses.
+func (r *HostResolver) Teardown() error {
+	return r.Retain(nil)
+}
+
+// Addresses returns the addresses programmed for host.
+func (r *HostResolver) Addresses(host string) []netip.Addr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	th, ok := r.hosts[host]
+	if !ok {
+		return nil
+	}
+	return slices.Clone(th.programmed)
+}
+
+// Run resolves each tracked host again when its TTL expires until ctx is
+// cancelled. Run always returns nil.
+func (r *HostResolver) Run(ctx context.Context) error {
+	timer := time.NewTimer(r.nextRefresh())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-r.wake:
+		case <-timer.C:
+			r.refresh(ctx)
+		}
+		timer.Stop()
+		timer.Reset(r.nextRefresh())
+	}
+}
+
+// nextRefresh returns the time until the next host is due. Without tracked
+// hosts it returns DNSMaxTTL; Track wakes Run when hosts are added.
+func (r *HostResolver) nextRefresh() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	next := r.maxTTL
+	now := r.now()
+	for _, th := range r.hosts {
+		next = min(next, max(th.refresh.Sub(now), 0))
+	}
+	return next
+}
+
+// refresh resolves the hosts that are due.
+func (r *HostResolver) refresh(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	for host, th := range r.hosts {
+		if th.refresh.After(now) {
+			continue
+		}
+		if err := r.resolveLocked(ctx, host, th); err != nil {
+			r.logger.Error("hostname policy destination refresh failed", "host", host, "error", err)
+		}
+	}
+}
+
+// resolveLocked resolves host and programs its addresses when they
+// changed. When the lookup fails, the known addresses are kept. Caller must
+// hold r.mu.
+func (r *HostResolver) resolveLocked(ctx context.Context, host string, th *trackedHost) error {
+	ctx, cancel := context.WithTimeout(ctx, hostResolveTimeout)
+	addrs, ttl, err := r.lookup.LookupHost(ctx, host)
+	cancel()
+
+	now := r.now()
+	if err != nil {
+		r.logger.Warn("hostname policy destination could not be resolved", "host", host, "error", err)
+		th.refresh = now.Add(r.minTTL)
+	} else {
+		ttl = min(max(ttl, r.minTTL), r.maxTTL)
+		th.refresh = now.Add(ttl)
+		for _, addr := range addrs {
+			th.expires[addr.Unmap()] = now.Add(ttl + hostAddrRetention)
+		}
+		maps.DeleteFunc(th.expires, func(_ netip.Addr, expiry time.Time) bool {
+			return !expiry.After(now)
+		})
+	}
+
+	current := slices.SortedFunc(maps.Keys(th.expires), netip.Addr.Compare)
+	if current == nil {
+		current = []netip.Addr{}
+	}
+	if th.programmed != nil && slices.Equal(current, th.programmed) {
+		return nil
+	}
+	if err := r.ctrl.SetHostAddresses(host, current); err != nil {
+		return fmt.Errorf("policy: dns: %s: %w", host, err)
+	}
+	th.programmed = current
+	r.logger.Debug("hostname policy destination resolved", "host", host, "addresses", len(current), "ttl", ttl)
+	return nil
+}
@@ -0,0 +1,263 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockHostLookup answers with fixed addresses and TTL per host.
+type mockHostLookup struct {
+	mu      sync.Mutex
+	answers map[string][]netip.Addr
+	ttl     time.Duration
+	err     error
+	calls   int
+}
+
+func (m *mockHostLookup) LookupHost(_ context.Context, host string) ([]netip.Addr, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	if m.err != nil {
+		return nil, 0, m.err
+	}
+	return m.answers[host], m.ttl, nil
+}
+
+// mockHostController records the programmed address sets.
+type mockHostController struct {
+	mu      sync.Mutex
+	addrs   map[string][]netip.Addr
+	sets    int
+	removed []string
+	setErr  error
+}
+
+func newMockHostController() *mockHostController {
+	return &mockHostController{addrs: make(map[string][]netip.Addr)}
+}
+
+func (m *mockHostController) SetHostAddresses(host string, addrs []netip.Addr) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.setErr != nil {
+		return m.setErr
+	}
+	m.sets++
+	m.addrs[host] = addrs
+	return nil
+}
+
+func (m *mockHostController) RemoveHost(host string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.addrs, host)
+	m.removed = append(m.removed, host)
+	return nil
+}
+
+func addrs(s ...string) []netip.Addr {
+	var out []netip.Addr
+	for _, a := range s {
+		out = append(out, netip.MustParseAddr(a))
+	}
+	return out
+}
+
+func TestHostResolver_TrackProgramsAddresses(t *testing.T) {
+	ctrl := newMockHostController()
+	r := NewHostResolver(ctrl, Config{}, testLogger())
+	r.SetLookup(&mockHostLookup{answers: map[string][]netip.Addr{
+		"api.example.com": addrs("203.0.113.20", "203.0.113.10", "2001:db8::1"),
+	}, ttl: time.Minute})
+
+	if err := r.Track([]string{"api.example.com", "none.example.com"}); err != nil {
+		t.Fatalf("Track() error = %v", err)
+	}
+	want := addrs("203.0.113.10", "203.0.113.20", "2001:db8::1")
+	if got := ctrl.addrs["api.example.com"]; !slices.Equal(got, want) {
+		t.Errorf("addresses = %v, want %v", got, want)
+	}
+	if got := r.Addresses("api.example.com"); !slices.Equal(got, want) {
+		t.Errorf("Addresses() = %v, want %v", got, want)
+	}
+	// A host without addresses still gets an empty set for its rules.
+	if got, ok := ctrl.addrs["none.example.com"]; !ok || len(got) != 0 {
+		t.Errorf("addresses of host without answers = %v, %v, want empty set", got, ok)
+	}
+
+	// Tracking a host again does not resolve it again.
+	sets := ctrl.sets
+	if err := r.Track([]string{"api.example.com"}); err != nil {
+		t.Fatalf("Track() again error = %v", err)
+	}
+	if ctrl.sets != sets {
+		t.Errorf("SetHostAddresses called %d times on Track again, want 0", ctrl.sets-sets)
+	}
+}
+
+func TestHostResolver_RefreshOnTTLExpiry(t *testing.T) {
+	ctrl := newMockHostController()
+	lookup := &mockHostLookup{answers: map[string][]netip.Addr{
+		"api.example.com": addrs("203.0.113.10"),
+	}, ttl: 30 * time.Second}
+	now := time.Now()
+	r := NewHostResolver(ctrl, Config{}, testLogger())
+	r.SetLookup(lookup)
+	r.now = func() time.Time { return now }
+
+	if err := r.Track([]string{"api.example.com"}); err != nil {
+		t.Fatalf("Track() error = %v", err)
+	}
+	if got := r.nextRefresh(); got != 30*time.Second {
+		t.Errorf("nextRefresh() = %v, want 30s", got)
+	}
+
+	// Not due yet.
+	lookup.answers["api.example.com"] = addrs("203.0.113.11")
+	now = now.Add(10 * time.Second)
+	r.refresh(context.Background())
+	if lookup.calls != 1 {
+		t.Errorf("lookups before TTL expiry = %d, want 1", lookup.calls)
+	}
+
+	// Due: the new address is added, the old one retained.
+	now = now.Add(20 * time.Second)
+	r.refresh(context.Background())
+	want := addrs("203.0.113.10", "203.0.113.11")
+	if got := ctrl.addrs["api.example.com"]; !slices.Equal(got, want) {
+		t.Errorf("addresses after refresh = %v, want %v", got, want)
+	}
+
+	// Past its retention, the old address is dropped.
+	now = now.Add(hostAddrRetention + 30*time.Second)
+	r.refresh(context.Background())
+	want = addrs("203.0.113.11")
+	if got := ctrl.addrs["api.example.com"]; !slices.Equal(got, want) {
+		t.Errorf("addresses after retention = %v, want %v", got, want)
+	}
+}
+
+func TestHostResolver_TTLClamped(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		want time.Duration
+	}{
+		{"below minimum", 0, 10 * time.Second},
+		{"above maximum", 24 * time.Hour, 10 * time.Minute},
+		{"within bounds", time.Minute, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			r := NewHostResolver(newMockHostController(), Config{DNSMinTTL: 10 * time.Second, DNSMaxTTL: 10 * time.Minute}, testLogger())
+			r.SetLookup(&mockHostLookup{answers: map[string][]netip.Addr{
+				"api.example.com": addrs("203.0.113.10"),
+			}, ttl: tt.ttl})
+			r.now = func() time.Time { return now }
+			if err := r.Track([]string{"api.example.com"}); err != nil {
+				t.Fatalf("Track() error = %v", err)
+			}
+			if got := r.nextRefresh(); got != tt.want {
+				t.Errorf("nextRefresh() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostResolver_LookupFailureKeepsAddresses(t *testing.T) {
+	ctrl := newMockHostController()
+	lookup := &mockHostLookup{answers: map[string][]netip.Addr{
+		"api.example.com": addrs("203.0.113.10"),
+	}, ttl: time.Minute}
+	now := time.Now()
+	r := NewHostResolver(ctrl, Config{}, testLogger())
+	r.SetLookup(lookup)
+	r.now = func() time.Time { return now }
+
+	if err := r.Track([]string{"api.example.com"}); err != nil {
+		t.Fatalf("Track() error = %v", err)
+	}
+	lookup.err = errors.New("server failure")
+	now = now.Add(time.Hour)
+	r.refresh(context.Background())
+
+	want := addrs("203.0.113.10")
+	if got := ctrl.addrs["api.example.com"]; !slices.Equal(got, want) {
+		t.Errorf("addresses after failed lookup = %v, want %v", got, want)
+	}
+	if got := r.nextRefresh(); got != DefaultDNSMinTTL {
+		t.Errorf("nextRefresh() after failed lookup = %v, want %v", got, DefaultDNSMinTTL)
+	}
+}
+
+func TestHostResolver_TrackRetriesUnprogrammedHost(t *testing.T) {
+	ctrl := newMockHostController()
+	ctrl.setErr = errors.New("nftables unavailable")
+	r := NewHostResolver(ctrl, Config{}, testLogger())
+	r.SetLookup(&mockHostLookup{answers: map[string][]netip.Addr{
+		"api.example.com": addrs("203.0.113.10"),
+	}, ttl: time.Minute})
+
+	if err := r.Track([]string{"api.example.com"}); err == nil {
+		t.Fatal("Track() = nil, want error when the addresses cannot be programmed")
+	}
+	ctrl.setErr = nil
+	if err := r.Track([]string{"api.example.com"}); err != nil {
+		t.Fatalf("Track() retry error = %v", err)
+	}
+	if got := ctrl.addrs["api.example.com"]; len(got) != 1 {
+		t.Errorf("addresses after retry = %v, want one", got)
+	}
+}
+
+func TestHostResolver_RetainRemovesOtherHosts(t *testing.T) {
+	ctrl := newMockHostController()
+	r := NewHostResolver(ctrl, Config{}, testLogger())
+	r.SetLookup(&mockHostLookup{answers: map[string][]netip.Addr{
+		"a.example.com": addrs("203.0.113.10"),
+		"b.example.com": addrs("203.0.113.20"),
+	}, ttl: time.Minute})
+
+	if err := r.Track([]string{"a.example.com", "b.example.com"}); err != nil {
+		t.Fatalf("Track() error = %v", err)
+	}
+	if err := r.Retain([]string{"a.example.com"}); err != nil {
+		t.Fatalf("Retain() error = %v", err)
+	}
+	if !slices.Equal(ctrl.removed, []string{"b.example.com"}) {
+		t.Errorf("removed = %v, want [b.example.com]", ctrl.removed)
+	}
+	if got := r.Addresses("b.example.com"); got != nil {
+		t.Errorf("Addresses() of removed host = %v, want nil", got)
+	}
+
+	if err := r.Teardown(); err != nil {
+		t.Fatalf("Teardown() error = %v", err)
+	}
+	if len(ctrl.addrs) != 0 {
+		t.Errorf("addresses after Teardown = %v, want none", ctrl.addrs)
+	}
+}
+
+func TestHostResolver_RunStopsOnCancel(t *testing.T) {
+	r := NewHostResolver(newMockHostController(), Config{}, testLogger())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx) }()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run() = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
@@ -0,0 +1,444 @@
+//go:build linux
+
+package policy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// egressTableName is the nftables table that holds the outbound
+	// allowlist. It is an inet table, so it covers IPv4 and IPv6.
+	egressTableName = "plexd-egress"
+	// egressChainName is the output base chain of the egress table.
+	egressChainName = "output"
+
+	// egressViolationsSet4 and egressViolationsSet6 record the
+	// destinations of disallowed traffic as address . protocol . port.
+	egressViolationsSet4 = "violations4"
+	egressViolationsSet6 = "violations6"
+	// egressViolationTimeout is how long a recorded violation stays in its
+	// set, so that violations are dropped if they are never collected.
+	egressViolationTimeout = 10 * time.Minute
+	// egressViolationSetSize bounds the violations recorded per family.
+	egressViolationSetSize = 4096
+)
+
+// ICMPv6 types of neighbor discovery (RFC 4861): router solicitation and
+// advertisement, and neighbor solicitation and advertisement.
+const (
+	ipv6NDRouterSolicit  = 133
+	ipv6NDNeighborAdvert = 136
+)
+
+// nftReg32_00 is the first 32-bit register; concatenated set keys are
+// built in consecutive ones.
+const nftReg32_00 = 8
+
+// linkLocalEgress are the destinations that never leave the link, such as
+// those of neighbor discovery, MLD, and DHCP, which are always allowed.
+var linkLocalEgress = []EgressFilter{
+	{Prefix: netip.MustParsePrefix("224.0.0.0/24")},
+	{Prefix: netip.MustParsePrefix("255.255.255.255/32")},
+	{Prefix: netip.MustParsePrefix("fe80::/10")},
+	{Prefix: netip.MustParsePrefix("ff02::/16")},
+}
+
+var (
+	egressViolationsType4 = nftables.MustConcatSetType(nftables.TypeIPAddr, nftables.TypeInetProto, nftables.TypeInetService)
+	egressViolationsType6 = nftables.MustConcatSetType(nftables.TypeIP6Addr, nftables.TypeInetProto, nftables.TypeInetService)
+)
+
+// ApplyEgressRules replaces the outbound allowlist atomically. It creates
+// the plexd-egress inet table with an output chain that accepts traffic on
+// the loopback and mesh interfaces, replies to accepted connections,
+// WireGuard traffic from the listen port, link-local traffic and neighbor
+// discovery, and traffic the filters allow.
+// Any other new traffic is recorded as a violation and, when rules.Enforce
+// is set, rejected.
+func (c *NftablesController) ApplyEgressRules(rules EgressRuleSet) error {
+	conn, err := nftables.New()
+	if err != nil {
+		return fmt.Errorf("policy: nftables: apply egress rules: %w", err)
+	}
+
+	table := conn.AddTable(&nftables.Table{
+		Family: nftables.TableFamilyINet,
+		Name:   egressTableName,
+	})
+	sets := []*nftables.Set{
+		egressViolationsSet(table, egressViolationsSet4, egressViolationsType4),
+		egressViolationsSet(table, egressViolationsSet6, egressViolationsType6),
+	}
+	for _, set := range sets {
+		if err := conn.AddSet(set, nil); err != nil {
+			return fmt.Errorf("policy: nftables: apply egress rules: add set %q: %w", set.Name, err)
+		}
+	}
+	chain := conn.AddChain(&nftables.Chain{
+		Name:     egressChainName,
+		Table:    table,
+		Type:     nftables.ChainTypeFilter,
+		Hooknum:  nftables.ChainHookOutput,
+		Priority: nftables.ChainPriorityFilter,
+	})
+
+	// Flush existing rules in the chain before adding new ones.
+	conn.FlushChain(chain)
+
+	ruleExprs, err := buildEgressRuleExprs(rules, sets[0], sets[1])
+	if err != nil {
+		return fmt.Errorf("policy: nftables: apply egress rules: build expressions: %w", err)
+	}
+	for _, exprs := range ruleExprs {
+		conn.AddRule(&nftables.Rule{
+			Table: table,
+			Chain: chain,
+			Exprs: exprs,
+		})
+	}
+
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("policy: nftables: apply egress rules: %w", err)
+	}
+
+	c.logger.Debug("nftables egress rules applied",
+		"component", "policy",
+		"table", egressTableName,
+		"filters", len(rules.Filters),
+		"enforce", rules.Enforce,
+	)
+	return nil
+}
+
+// RemoveEgressRules deletes the egress table. It is idempotent: removing a
+// non-existent table returns nil.
+func (c *NftablesController) RemoveEgressRules() error {
+	conn, err := nftables.New()
+	if err != nil {
+		return fmt.Errorf("policy: nftables: remove egress rules: %w", err)
+	}
+
+	table, err := egressTable(conn)
+	if err != nil {
+		return fmt.Errorf("policy: nftables: remove egress rules: %w", err)
+	}
+	if table == nil {
+		return nil
+	}
+	conn.DelTable(table)
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("policy: nftables: remove egress rules: %w", err)
+	}
+
+	c.logger.Debug("nftables egress rules removed",
+		"component", "policy",
+		"table", egressTableName,
+	)
+	return nil
+}
+
+// EgressViolations returns the violations recorded in the violation sets
+// and deletes them from the sets. It returns nil when the egress table does
+// not exist.
+func (c *NftablesController) EgressViolations() ([]EgressViolation, error) {
+	conn, err := nftables.New()
+	if err != nil {
+		return nil, fmt.Errorf("policy: nftables: egress violations: %w", err)
+	}
+
+	table, err := egressTable(conn)
+	if err != nil {
+		return nil, fmt.Errorf("policy: nftables: egress violations: %w", err)
+	}
+	if table == nil {
+		return nil, nil
+	}
+
+	var violations []EgressViolation
+	for _, name := range []string{egressViolationsSet4, egressViolationsSet6} {
+		set, err := conn.GetSetByName(table, name)
+		if err != nil {
+			return nil, fmt.Errorf("policy: nftables: egress violations: get set %q: %w", name, err)
+		}
+		elems, err := conn.GetSetElements(set)
+		if err != nil {
+			return nil, fmt.Errorf("policy: nftables: egress violations: list set %q: %w", name, err)
+		}
+		if len(elems) == 0 {
+			continue
+		}
+		keys := make([]nftables.SetElement, 0, len(elems))
+		for _, elem := range elems {
+			if v, ok := parseEgressViolation(elem.Key); ok {
+				violations = append(violations, v)
+			}
+			keys = append(keys, nftables.SetElement{Key: elem.Key})
+		}
+		if err := conn.SetDeleteElements(set, keys); err != nil {
+			return nil, fmt.Errorf("policy: nftables: egress violations: delete from set %q: %w", name, err)
+		}
+	}
+
+	if err := conn.Flush(); err != nil {
+		return nil, fmt.Errorf("policy: nftables: egress violations: %w", err)
+	}
+	return violations, nil
+}
+
+// egressTable returns the egress table, or nil if it does not exist.
+func egressTable(conn *nftables.Conn) (*nftables.Table, error) {
+	tables, err := conn.ListTablesOfFamily(nftables.TableFamilyINet)
+	if err != nil {
+		return nil, fmt.Errorf("list tables: %w", err)
+	}
+	for _, t := range tables {
+		if t.Name == egressTableName {
+			return t, nil
+		}
+	}
+	return nil, nil
+}
+
+// egressViolationsSet returns a violation set of the given key type.
+func egressViolationsSet(table *nftables.Table, name string, keyType nftables.SetDatatype) *nftables.Set {
+	return &nftables.Set{
+		Table:         table,
+		Name:          name,
+		KeyType:       keyType,
+		Concatenation: true,
+		Dynamic:       true,
+		HasTimeout:    true,
+		Timeout:       egressViolationTimeout,
+		Size:          egressViolationSetSize,
+	}
+}
+
+// buildEgressRuleExprs converts an EgressRuleSet into the rules of the
+// egress chain, in order.
+func buildEgressRuleExprs(rules EgressRuleSet, set4, set6 *nftables.Set) ([][]expr.Any, error) {
+	accept := &expr.Verdict{Kind: expr.VerdictAccept}
+	var out [][]expr.Any
+
+	// Traffic on the loopback and mesh interfaces.
+	for _, iface := range []string{"lo", rules.MeshInterface} {
+		if iface == "" {
+			continue
+		}
+		out = append(out, []expr.Any{
+			&expr.Meta{Key: expr.MetaKeyOIFNAME, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: ifaceNameBytes(iface)},
+			accept,
+		})
+	}
+
+	// Replies and related traffic of accepted connections.
+	out = append(out, []expr.Any{
+		&expr.Ct{Register: 1, Key: expr.CtKeySTATE},
+		&expr.Bitwise{
+			SourceRegister: 1,
+			DestRegister:   1,
+			Len:            4,
+			Mask:           binaryutil.NativeEndian.PutUint32(expr.CtStateBitESTABLISHED | expr.CtStateBitRELATED),
+			Xor:            binaryutil.NativeEndian.PutUint32(0),
+		},
+		&expr.Cmp{Op: expr.CmpOpNeq, Register: 1, Data: binaryutil.NativeEndian.PutUint32(0)},
+		accept,
+	})
+
+	// Encrypted WireGuard traffic of the mesh.
+	if rules.ListenPort > 0 {
+		out = append(out, []expr.Any{
+			&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_UDP}},
+			&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 0, Len: 2},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: portBytes(uint16(rules.ListenPort))},
+			accept,
+		})
+	}
+
+	// Neighbor discovery to the unicast addresses of neighbors.
+	out = append(out, []expr.Any{
+		&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_ICMPV6}},
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 0, Len: 1},
+		&expr.Cmp{Op: expr.CmpOpGte, Register: 1, Data: []byte{ipv6NDRouterSolicit}},
+		&expr.Cmp{Op: expr.CmpOpLte, Register: 1, Data: []byte{ipv6NDNeighborAdvert}},
+		accept,
+	})
+
+	for _, f := range slices.Concat(linkLocalEgress, rules.Filters) {
+		exprs, err := buildEgressFilterExprs(f)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, exprs)
+	}
+
+	out = append(out, buildViolationExprs(unix.NFPROTO_IPV4, 16, 4, set4)...)
+	out = append(out, buildViolationExprs(unix.NFPROTO_IPV6, 24, 16, set6)...)
+
+	if rules.Enforce {
+		out = append(out, []expr.Any{
+			&expr.Counter{},
+			&expr.Reject{Type: unix.NFT_REJECT_ICMPX_UNREACH, Code: unix.NFT_REJECT_ICMPX_ADMIN_PROHIBITED},
+		})
+	}
+	return out, nil
+}
+
+// buildEgressFilterExprs matches the family and destination prefix of f,
+// and its protocol and destination port if set, and accepts.
+func buildEgressFilterExprs(f EgressFilter) ([]expr.Any, error) {
+	if !f.Prefix.IsValid() {
+		return nil, fmt.Errorf("invalid prefix %v", f.Prefix)
+	}
+	addr := f.Prefix.Addr()
+	family, offset := byte(unix.NFPROTO_IPV4), uint32(16) // IPv4 dst offset
+	if addr.Is6() {
+		family, offset = unix.NFPROTO_IPV6, 24 // IPv6 dst offset
+	}
+
+	exprs := []expr.Any{
+		&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{family}},
+	}
+	if f.Prefix.Bits() > 0 {
+		length := uint32(addr.BitLen() / 8)
+		exprs = append(exprs, &expr.Payload{
+			DestRegister: 1,
+			Base:         expr.PayloadBaseNetworkHeader,
+			Offset:       offset,
+			Len:          length,
+		})
+		if f.Prefix.Bits() < addr.BitLen() {
+			exprs = append(exprs, &expr.Bitwise{
+				SourceRegister: 1,
+				DestRegister:   1,
+				Len:            length,
+				Mask:           prefixMask(f.Prefix.Bits(), int(length)),
+				Xor:            make([]byte, length),
+			})
+		}
+		exprs = append(exprs, &expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: f.Prefix.Masked().Addr().AsSlice()})
+	}
+
+	if f.Protocol != "" {
+		proto, err := protocolNumber(f.Protocol)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs,
+			&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{proto}},
+		)
+	}
+	if f.Port > 0 {
+		exprs = append(exprs,
+			&expr.Payload{
+				DestRegister: 1,
+				Base:         expr.PayloadBaseTransportHeader,
+				Offset:       2, // TCP/UDP destination port offset
+				Len:          2,
+			},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: portBytes(uint16(f.Port))},
+		)
+	}
+
+	return append(exprs, &expr.Counter{}, &expr.Verdict{Kind: expr.VerdictAccept}), nil
+}
+
+// buildViolationExprs returns the rules that add the destination of new
+// traffic of family to set: one for TCP and UDP with the destination port,
+// and one for other protocols with port 0. The key is built in consecutive
+// 32-bit registers: the address, the protocol, and the port.
+func buildViolationExprs(family byte, offset, addrLen uint32, set *nftables.Set) [][]expr.Any {
+	protoReg := uint32(nftReg32_00) + addrLen/4
+	portReg := protoReg + 1
+	key := func(portExprs ...expr.Any) []expr.Any {
+		exprs := []expr.Any{
+			&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{family}},
+			&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: protoReg},
+		}
+		exprs = append(exprs, portExprs...)
+		return append(exprs,
+			&expr.Payload{DestRegister: nftReg32_00, Base: expr.PayloadBaseNetworkHeader, Offset: offset, Len: addrLen},
+			&expr.Dynset{
+				SrcRegKey: nftReg32_00,
+				SetName:   set.Name,
+				SetID:     set.ID,
+				Operation: uint32(unix.NFT_DYNSET_OP_ADD),
+				Timeout:   egressViolationTimeout,
+			},
+		)
+	}
+
+	var out [][]expr.Any
+	for _, proto := range []byte{unix.IPPROTO_TCP, unix.IPPROTO_UDP} {
+		out = append(out, key(
+			&expr.Cmp{Op: expr.CmpOpEq, Register: protoReg, Data: []byte{proto}},
+			&expr.Payload{DestRegister: portReg, Base: expr.PayloadBaseTransportHeader, Offset: 2, Len: 2},
+		))
+	}
+	out = append(out, key(
+		&expr.Cmp{Op: expr.CmpOpNeq, Register: protoReg, Data: []byte{unix.IPPROTO_TCP}},
+		&expr.Cmp{Op: expr.CmpOpNeq, Register: protoReg, Data: []byte{unix.IPPROTO_UDP}},
+		&expr.Immediate{Register: portReg, Data: []byte{0, 0, 0, 0}},
+	))
+	return out
+}
+
+// parseEgressViolation parses the key of a violation set element.
+func parseEgressViolation(key []byte) (EgressViolation, bool) {
+	var addrLen int
+	switch len(key) {
+	case int(egressViolationsType4.Bytes):
+		addrLen = 4
+	case int(egressViolationsType6.Bytes):
+		addrLen = 16
+	default:
+		return EgressViolation{}, false
+	}
+	addr, _ := netip.AddrFromSlice(key[:addrLen])
+	return EgressViolation{
+		Destination: addr,
+		Protocol:    protocolName(key[addrLen]),
+		Port:        int(binary.BigEndian.Uint16(key[addrLen+4:])),
+	}, true
+}
+
+// protocolName returns the name of an IP protocol number, or the number.
+func protocolName(proto byte) string {
+	switch proto {
+	case unix.IPPROTO_TCP:
+		return "tcp"
+	case unix.IPPROTO_UDP:
+		return "udp"
+	case unix.IPPROTO_ICMP:
+		return "icmp"
+	case unix.IPPROTO_ICMPV6:
+		return "icmpv6"
+	default:
+		return strconv.Itoa(int(proto))
+	}
+}
+
+// prefixMask returns a network mask of bits ones in length bytes.
+func prefixMask(bits, length int) []byte {
+	mask := make([]byte, length)
+	for i := 0; i < bits; i++ {
+		mask[i/8] |= 0x80 >> (i % 8)
+	}
+	return mask
+}
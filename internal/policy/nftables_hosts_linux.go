@@ -0,0 +1,123 @@
+//go:build linux
+
+package policy
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/netip"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+)
+
+// SetHostAddresses replaces the addresses of host in its address set in the
+// plexd table, creating the set if needed. Rules with DstHost host look up
+// the destination address in the set. The plexd table filters IPv4 only, so
+// IPv6 addresses are ignored.
+func (c *NftablesController) SetHostAddresses(host string, addrs []netip.Addr) error {
+	conn, err := nftables.New()
+	if err != nil {
+		return fmt.Errorf("policy: nftables: set host addresses: %w", err)
+	}
+
+	set := &nftables.Set{
+		Table:   c.ensureTable(conn),
+		Name:    hostSetName(host),
+		KeyType: nftables.TypeIPAddr,
+		Comment: host,
+	}
+	if err := conn.AddSet(set, nil); err != nil {
+		return fmt.Errorf("policy: nftables: set host addresses: %w", err)
+	}
+	conn.FlushSet(set)
+
+	var elements []nftables.SetElement
+	for _, addr := range addrs {
+		if addr = addr.Unmap(); addr.Is4() {
+			elements = append(elements, nftables.SetElement{Key: addr.AsSlice()})
+		}
+	}
+	if len(elements) > 0 {
+		if err := conn.SetAddElements(set, elements); err != nil {
+			return fmt.Errorf("policy: nftables: set host addresses: %w", err)
+		}
+	}
+
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("policy: nftables: set host addresses of %q: %w", host, err)
+	}
+
+	c.logger.Debug("nftables host addresses set",
+		"component", "policy",
+		"host", host,
+		"set", set.Name,
+		"count", len(elements),
+	)
+	return nil
+}
+
+// RemoveHost deletes the address set of host. It is idempotent: removing a
+// host without a set returns nil.
+func (c *NftablesController) RemoveHost(host string) error {
+	conn, err := nftables.New()
+	if err != nil {
+		return fmt.Errorf("policy: nftables: remove host: %w", err)
+	}
+
+	tables, err := conn.ListTablesOfFamily(nftables.TableFamilyIPv4)
+	if err != nil {
+		return fmt.Errorf("policy: nftables: remove host: list tables: %w", err)
+	}
+	for _, table := range tables {
+		if table.Name != tableName {
+			continue
+		}
+		sets, err := conn.GetSets(table)
+		if err != nil {
+			return fmt.Errorf("policy: nftables: remove host: list sets: %w", err)
+		}
+		for _, set := range sets {
+			if set.Name != hostSetName(host) {
+				continue
+			}
+			conn.DelSet(set)
+			if err := conn.Flush(); err != nil {
+				return fmt.Errorf("policy: nftables: remove host %q: %w", host, err)
+			}
+			c.logger.Debug("nftables host addresses removed",
+				"component", "policy",
+				"host", host,
+				"set", set.Name,
+			)
+			return nil
+		}
+	}
+	return nil
+}
+
+// hostSetName returns the name of the address set of host. Set names are
+// limited in length, so the name is derived from a hash of host; the host
+// itself is kept in the set comment.
+func hostSetName(host string) string {
+	h := fnv.New64a()
+	h.Write([]byte(host))
+	return fmt.Sprintf("host-%016x", h.Sum64())
+}
+
+// buildHostMatchExprs creates payload + lookup expressions that match the
+// IPv4 destination address against the address set of host.
+func buildHostMatchExprs(host string) []expr.Any {
+	return []expr.Any{
+		&expr.Payload{
+			DestRegister: 1,
+			Base:         expr.PayloadBaseNetworkHeader,
+			Offset:       16, // IPv4 dst offset
+			Len:          4,
+		},
+		&expr.Lookup{
+			SourceRegister: 1,
+			SetName:        hostSetName(host),
+		},
+	}
+}
@@ -4,11 +4,13 @@ package policy
 
 import (
 	"fmt"
+	"hash/fnv"
 	"log/slog"
 	"net"
 
 	"github.com/google/nftables"
 	"github.com/google/nftables/expr"
+	"github.com/google/nftables/userdata"
 	"golang.org/x/sys/unix"
 )
 
@@ -83,9 +85,10 @@ func (c *NftablesController) ApplyRules(chain string, rules []FirewallRule) erro
 			return fmt.Errorf("policy: nftables: apply rules: build expressions: %w", err)
 		}
 		conn.AddRule(&nftables.Rule{
-			Table: table,
-			Chain: nftChain,
-			Exprs: exprs,
+			Table:    table,
+			Chain:    nftChain,
+			Exprs:    exprs,
+			UserData: userdata.AppendString(nil, userdata.TypeComment, ruleComment(rule)),
 		})
 	}
 
@@ -101,6 +104,45 @@ func (c *NftablesController) ApplyRules(chain string, rules []FirewallRule) erro
 	return nil
 }
 
+// VerifyRules reads back the rules of the named chain and returns an error
+// unless they are exactly rules, in order. Each rule is identified by the
+// comment ApplyRules gave it and its number of expressions.
+func (c *NftablesController) VerifyRules(chain string, rules []FirewallRule) error {
+	conn, err := nftables.New()
+	if err != nil {
+		return fmt.Errorf("policy: nftables: verify rules: %w", err)
+	}
+
+	table := &nftables.Table{
+		Family: nftables.TableFamilyIPv4,
+		Name:   tableName,
+	}
+	got, err := conn.GetRules(table, &nftables.Chain{Name: chain, Table: table})
+	if err != nil {
+		return fmt.Errorf("policy: nftables: verify rules of chain %q: %w", chain, err)
+	}
+	if len(got) != len(rules) {
+		return fmt.Errorf("policy: nftables: verify rules of chain %q: %d rules, want %d", chain, len(got), len(rules))
+	}
+	for i, rule := range rules {
+		exprs, err := buildRuleExprs(rule)
+		if err != nil {
+			return fmt.Errorf("policy: nftables: verify rules: build expressions: %w", err)
+		}
+		comment, _ := userdata.GetString(got[i].UserData, userdata.TypeComment)
+		if comment != ruleComment(rule) || len(got[i].Exprs) != len(exprs) {
+			return fmt.Errorf("policy: nftables: verify rules of chain %q: rule %d does not match", chain, i)
+		}
+	}
+
+	c.logger.Debug("nftables rules verified",
+		"component", "policy",
+		"chain", chain,
+		"count", len(rules),
+	)
+	return nil
+}
+
 // FlushChain removes all rules from the named chain.
 func (c *NftablesController) FlushChain(chain string) error {
 	conn, err := nftables.New()
@@ -177,6 +219,14 @@ func (c *NftablesController) ensureTable(conn *nftables.Conn) *nftables.Table {
 	})
 }
 
+// ruleComment returns the comment that identifies rule in the chain: a
+// hash of its fields, which VerifyRules compares.
+func ruleComment(rule FirewallRule) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%+v", rule)
+	return fmt.Sprintf("plexd:%016x", h.Sum64())
+}
+
 // buildRuleExprs converts a FirewallRule into nftables match expressions and a verdict.
 func buildRuleExprs(rule FirewallRule) ([]expr.Any, error) {
 	var exprs []expr.Any
@@ -212,6 +262,11 @@ func buildRuleExprs(rule FirewallRule) ([]expr.Any, error) {
 		exprs = append(exprs, dstExprs...)
 	}
 
+	// Match destination hostname against its address set.
+	if rule.DstHost != "" {
+		exprs = append(exprs, buildHostMatchExprs(rule.DstHost)...)
+	}
+
 	// Match protocol if specified.
 	if rule.Protocol != "" {
 		proto, err := protocolNumber(rule.Protocol)
@@ -3,11 +3,14 @@
 package policy
 
 import (
+	"bytes"
 	"io"
 	"log/slog"
+	"net/netip"
 	"strings"
 	"testing"
 
+	"github.com/google/nftables"
 	"github.com/google/nftables/expr"
 )
 
@@ -18,6 +21,12 @@ func discardLoggerNft() *slog.Logger {
 // Compile-time check that NftablesController implements FirewallController.
 var _ FirewallController = (*NftablesController)(nil)
 
+// Compile-time check that NftablesController implements EgressController.
+var _ EgressController = (*NftablesController)(nil)
+
+// Compile-time check that NftablesController implements HostAddressController.
+var _ HostAddressController = (*NftablesController)(nil)
+
 func TestNewNftablesController(t *testing.T) {
 	ctrl := NewNftablesController(discardLoggerNft())
 	if ctrl == nil {
@@ -208,6 +217,44 @@ func TestBuildRuleExprsCIDRSubnet(t *testing.T) {
 	}
 }
 
+func TestBuildRuleExprsDstHost(t *testing.T) {
+	rule := FirewallRule{
+		DstHost:  "api.example.com",
+		Port:     443,
+		Protocol: "tcp",
+		Action:   "allow",
+	}
+
+	exprs, err := buildRuleExprs(rule)
+	if err != nil {
+		t.Fatalf("buildRuleExprs returned error: %v", err)
+	}
+	payload, ok := exprs[0].(*expr.Payload)
+	if !ok || payload.Base != expr.PayloadBaseNetworkHeader || payload.Offset != 16 || payload.Len != 4 {
+		t.Fatalf("first expression = %#v, want IPv4 destination payload", exprs[0])
+	}
+	lookup, ok := exprs[1].(*expr.Lookup)
+	if !ok {
+		t.Fatalf("second expression = %T, want *expr.Lookup", exprs[1])
+	}
+	if lookup.SetName != hostSetName("api.example.com") || lookup.SourceRegister != payload.DestRegister {
+		t.Errorf("lookup = %+v, want set %q from register %d", lookup, hostSetName("api.example.com"), payload.DestRegister)
+	}
+}
+
+func TestHostSetName(t *testing.T) {
+	name := hostSetName("api.example.com")
+	if !strings.HasPrefix(name, "host-") || len(name) != len("host-")+16 {
+		t.Errorf("hostSetName() = %q, want host- and 16 hex digits", name)
+	}
+	if name == hostSetName("www.example.com") {
+		t.Error("hostSetName() is equal for different hosts")
+	}
+	if name != hostSetName("api.example.com") {
+		t.Error("hostSetName() is not stable")
+	}
+}
+
 func TestBuildRuleExprsWildcardSkipped(t *testing.T) {
 	// Wildcard "0.0.0.0/0" should not generate match expressions.
 	rule := FirewallRule{
@@ -436,3 +483,129 @@ func TestApplyAndFlushRoundTrip(t *testing.T) {
 		t.Fatalf("FlushChain failed: %v", err)
 	}
 }
+
+func TestBuildEgressFilterExprsIPv6Subnet(t *testing.T) {
+	f := EgressFilter{Prefix: netip.MustParsePrefix("2001:db8::/33"), Protocol: "tcp", Port: 443}
+
+	exprs, err := buildEgressFilterExprs(f)
+	if err != nil {
+		t.Fatalf("buildEgressFilterExprs returned error: %v", err)
+	}
+	var bitwise *expr.Bitwise
+	var addr *expr.Cmp
+	for _, e := range exprs {
+		switch e := e.(type) {
+		case *expr.Bitwise:
+			bitwise = e
+		case *expr.Cmp:
+			if len(e.Data) == 16 {
+				addr = e
+			}
+		}
+	}
+	if bitwise == nil || bitwise.Len != 16 || !bytes.Equal(bitwise.Mask[:5], []byte{0xff, 0xff, 0xff, 0xff, 0x80}) {
+		t.Errorf("bitwise = %+v, want a /33 mask over 16 bytes", bitwise)
+	}
+	if addr == nil || !bytes.Equal(addr.Data, netip.MustParseAddr("2001:db8::").AsSlice()) {
+		t.Errorf("address cmp = %+v, want 2001:db8::", addr)
+	}
+	if v, ok := exprs[len(exprs)-1].(*expr.Verdict); !ok || v.Kind != expr.VerdictAccept {
+		t.Errorf("last expression = %+v, want accept", exprs[len(exprs)-1])
+	}
+}
+
+func TestBuildEgressFilterExprsAnyDestination(t *testing.T) {
+	exprs, err := buildEgressFilterExprs(EgressFilter{Prefix: netip.MustParsePrefix("0.0.0.0/0")})
+	if err != nil {
+		t.Fatalf("buildEgressFilterExprs returned error: %v", err)
+	}
+	// Only the family match, counter, and verdict.
+	if len(exprs) != 4 {
+		t.Errorf("expected 4 expressions for a default route filter, got %d", len(exprs))
+	}
+}
+
+func TestBuildEgressRuleExprsEnforce(t *testing.T) {
+	rules := EgressRuleSet{MeshInterface: "plexd0", ListenPort: 51820}
+	monitor, err := buildEgressRuleExprs(rules, &nftables.Set{Name: "v4"}, &nftables.Set{Name: "v6"})
+	if err != nil {
+		t.Fatalf("buildEgressRuleExprs returned error: %v", err)
+	}
+	rules.Enforce = true
+	enforce, err := buildEgressRuleExprs(rules, &nftables.Set{Name: "v4"}, &nftables.Set{Name: "v6"})
+	if err != nil {
+		t.Fatalf("buildEgressRuleExprs returned error: %v", err)
+	}
+	if len(enforce) != len(monitor)+1 {
+		t.Fatalf("enforce rules = %d, want one more than the %d monitor rules", len(enforce), len(monitor))
+	}
+	last := enforce[len(enforce)-1]
+	if _, ok := last[len(last)-1].(*expr.Reject); !ok {
+		t.Errorf("last rule = %+v, want a reject", last)
+	}
+}
+
+func TestParseEgressViolation(t *testing.T) {
+	tests := []struct {
+		name string
+		key  []byte
+		want EgressViolation
+		ok   bool
+	}{
+		{
+			name: "IPv4 TCP",
+			key:  []byte{203, 0, 113, 9, 6, 0, 0, 0, 0x01, 0xbb, 0, 0},
+			want: EgressViolation{Destination: netip.MustParseAddr("203.0.113.9"), Protocol: "tcp", Port: 443},
+			ok:   true,
+		},
+		{
+			name: "IPv6 ICMPv6",
+			key:  append(netip.MustParseAddr("2001:db8::1").AsSlice(), 58, 0, 0, 0, 0, 0, 0, 0),
+			want: EgressViolation{Destination: netip.MustParseAddr("2001:db8::1"), Protocol: "icmpv6"},
+			ok:   true,
+		},
+		{
+			name: "other protocol",
+			key:  []byte{203, 0, 113, 9, 47, 0, 0, 0, 0, 0, 0, 0},
+			want: EgressViolation{Destination: netip.MustParseAddr("203.0.113.9"), Protocol: "47"},
+			ok:   true,
+		},
+		{
+			name: "short key",
+			key:  []byte{203, 0, 113, 9},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseEgressViolation(tt.key)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("parseEgressViolation = %+v, %v, want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRemoveEgressRulesNonExistent(t *testing.T) {
+	ctrl := NewNftablesController(discardLoggerNft())
+
+	// Removing absent egress rules should be idempotent and return nil.
+	// This requires CAP_NET_ADMIN; skip if we get a permission error.
+	if err := ctrl.RemoveEgressRules(); err != nil {
+		t.Skipf("skipping: requires elevated privileges: %v", err)
+	}
+	violations, err := ctrl.EgressViolations()
+	if err != nil || violations != nil {
+		t.Errorf("EgressViolations without rules = %v, %v, want nil", violations, err)
+	}
+}
+
+func TestRemoveHostNonExistent(t *testing.T) {
+	ctrl := NewNftablesController(discardLoggerNft())
+
+	// Removing a host without an address set should be idempotent and
+	// return nil. This requires CAP_NET_ADMIN; skip if we get a
+	// permission error.
+	if err := ctrl.RemoveHost("missing.example.com"); err != nil {
+		t.Skipf("skipping: requires elevated privileges: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package privhelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"github.com/plexsphere/plexd/internal/wireguard"
+)
+
+// Client implements wireguard.WGController by forwarding every operation to
+// the privileged helper. Each operation uses its own connection, so the
+// client recovers transparently when the helper restarts.
+type Client struct {
+	cfg Config
+}
+
+var (
+	_ wireguard.WGController = (*Client)(nil)
+	_ wireguard.PeerBatcher  = (*Client)(nil)
+)
+
+// NewClient returns a Client for the helper at cfg.SocketPath. Defaults are
+// applied to cfg.
+func NewClient(cfg Config) *Client {
+	cfg.ApplyDefaults()
+	return &Client{cfg: cfg}
+}
+
+// Ping checks that the helper is reachable and answering requests.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.call(ctx, request{Op: opPing})
+}
+
+func (c *Client) CreateInterface(name string, privateKey []byte, listenPort int) error {
+	return c.call(context.Background(), request{Op: opCreateInterface, Interface: name, PrivateKey: privateKey, ListenPort: listenPort})
+}
+
+func (c *Client) DeleteInterface(name string) error {
+	return c.call(context.Background(), request{Op: opDeleteInterface, Interface: name})
+}
+
+func (c *Client) ConfigureAddress(name string, address string) error {
+	return c.call(context.Background(), request{Op: opConfigureAddress, Interface: name, Address: address})
+}
+
+func (c *Client) SetInterfaceUp(name string) error {
+	return c.call(context.Background(), request{Op: opSetInterfaceUp, Interface: name})
+}
+
+func (c *Client) SetMTU(name string, mtu int) error {
+	return c.call(context.Background(), request{Op: opSetMTU, Interface: name, MTU: mtu})
+}
+
+func (c *Client) AddPeer(iface string, cfg wireguard.PeerConfig) error {
+	return c.call(context.Background(), request{Op: opAddPeer, Interface: iface, Peer: &cfg})
+}
+
+func (c *Client) RemovePeer(iface string, publicKey []byte) error {
+	return c.call(context.Background(), request{Op: opRemovePeer, Interface: iface, PublicKey: publicKey})
+}
+
+// ApplyPeers forwards a batch of peer changes in one request. A helper that
+// does not know the operation fails the request, and the Manager falls back
+// to per-peer operations.
+func (c *Client) ApplyPeers(iface string, remove [][]byte, upsert []wireguard.PeerConfig) error {
+	return c.call(context.Background(), request{Op: opApplyPeers, Interface: iface, PublicKeys: remove, Peers: upsert})
+}
+
+// call sends req to the helper and waits for its response.
+func (c *Client) call(ctx context.Context, req request) error {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", c.cfg.SocketPath)
+	if err != nil {
+		return fmt.Errorf("privhelper: %s: dial helper: %w", req.Op, err)
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return fmt.Errorf("privhelper: %s: send request: %w", req.Op, err)
+	}
+	var resp response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return fmt.Errorf("privhelper: %s: read response: %w", req.Op, err)
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("privhelper: %s: %s", req.Op, resp.Error)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Package privhelper lets plexd run without root. A small privileged helper
+// process performs WireGuard interface operations on behalf of the
+// unprivileged agent, which reaches it over a Unix domain socket.
+package privhelper
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Modes accepted by Config.Mode.
+const (
+	// ModeAuto performs operations directly when the agent holds
+	// CAP_NET_ADMIN (or runs as root) and through the helper otherwise.
+	ModeAuto = "auto"
+
+	// ModeDirect always performs operations in the agent process.
+	ModeDirect = "direct"
+
+	// ModeHelper always delegates operations to the helper.
+	ModeHelper = "helper"
+)
+
+// DefaultSocketPath is the default path of the helper's Unix domain socket.
+// It lives outside the agent's runtime directory so that it survives agent
+// restarts under socket activation.
+const DefaultSocketPath = "/run/plexd-helper.sock"
+
+// DefaultInterfacePrefix is the default prefix of interface names the helper
+// is allowed to manage.
+const DefaultInterfacePrefix = "plexd"
+
+// DefaultGroup is the default group granted access to the helper socket.
+const DefaultGroup = "plexd"
+
+// DefaultTimeout is the default timeout for a single helper request.
+const DefaultTimeout = 10 * time.Second
+
+// Config holds the configuration for privileged operation delegation.
+// Config is passed as a constructor argument — no file I/O in this package.
+type Config struct {
+	// Mode selects how privileged operations are performed: "auto",
+	// "direct", or "helper".
+	// Default: "auto"
+	Mode string
+
+	// SocketPath is the path of the helper's Unix domain socket.
+	// Default: /run/plexd-helper.sock
+	SocketPath string
+
+	// InterfacePrefix restricts the helper to interfaces whose name starts
+	// with this prefix.
+	// Default: "plexd"
+	InterfacePrefix string
+
+	// Group is the group that owns the helper socket when the helper creates
+	// it itself. Under systemd socket activation the socket unit sets it.
+	// Default: "plexd"
+	Group string
+
+	// Timeout bounds a single helper request, including the dial.
+	// Default: 10s
+	Timeout time.Duration
+}
+
+// ApplyDefaults sets default values for zero-valued fields.
+func (c *Config) ApplyDefaults() {
+	if c.Mode == "" {
+		c.Mode = ModeAuto
+	}
+	if c.SocketPath == "" {
+		c.SocketPath = DefaultSocketPath
+	}
+	if c.InterfacePrefix == "" {
+		c.InterfacePrefix = DefaultInterfacePrefix
+	}
+	if c.Group == "" {
+		c.Group = DefaultGroup
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DefaultTimeout
+	}
+}
+
+// Validate checks that configuration values are acceptable.
+func (c *Config) Validate() error {
+	switch c.Mode {
+	case ModeAuto, ModeDirect, ModeHelper:
+	default:
+		return fmt.Errorf("privhelper: config: invalid Mode %q (must be %q, %q, or %q)", c.Mode, ModeAuto, ModeDirect, ModeHelper)
+	}
+	if c.SocketPath == "" {
+		return errors.New("privhelper: config: SocketPath is required")
+	}
+	if c.InterfacePrefix == "" {
+		return errors.New("privhelper: config: InterfacePrefix is required")
+	}
+	if c.Timeout <= 0 {
+		return errors.New("privhelper: config: Timeout must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package privhelper
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfig_ApplyDefaults(t *testing.T) {
+	var cfg Config
+	cfg.ApplyDefaults()
+
+	if cfg.Mode != ModeAuto {
+		t.Errorf("Mode = %q, want %q", cfg.Mode, ModeAuto)
+	}
+	if cfg.SocketPath != "/run/plexd-helper.sock" {
+		t.Errorf("SocketPath = %q, want %q", cfg.SocketPath, "/run/plexd-helper.sock")
+	}
+	if cfg.InterfacePrefix != "plexd" {
+		t.Errorf("InterfacePrefix = %q, want %q", cfg.InterfacePrefix, "plexd")
+	}
+	if cfg.Group != "plexd" {
+		t.Errorf("Group = %q, want %q", cfg.Group, "plexd")
+	}
+	if cfg.Timeout != 10*time.Second {
+		t.Errorf("Timeout = %v, want 10s", cfg.Timeout)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() after ApplyDefaults = %v", err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Config{Mode: ModeHelper, SocketPath: "/run/h.sock", InterfacePrefix: "plexd", Timeout: time.Second}
+
+	tests := []struct {
+		name    string
+		mutate  func(*Config)
+		wantErr string
+	}{
+		{"invalid mode", func(c *Config) { c.Mode = "sudo" }, "invalid Mode"},
+		{"empty socket path", func(c *Config) { c.SocketPath = "" }, "SocketPath is required"},
+		{"empty prefix", func(c *Config) { c.InterfacePrefix = "" }, "InterfacePrefix is required"},
+		{"zero timeout", func(c *Config) { c.Timeout = 0 }, "Timeout must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.mutate(&cfg)
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+//go:build !windows
+
+package privhelper
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket
+// activation (SD_LISTEN_FDS_START).
+const listenFDsStart = 3
+
+// Listen returns the helper's listener. Under systemd socket activation it
+// uses the inherited socket; otherwise it creates the Unix domain socket at
+// cfg.SocketPath, owned by root and cfg.Group with mode 0660.
+func Listen(cfg Config) (net.Listener, error) {
+	cfg.ApplyDefaults()
+
+	if ln, ok, err := activationListener(); ok || err != nil {
+		return ln, err
+	}
+
+	os.Remove(cfg.SocketPath)
+	if err := os.MkdirAll(filepath.Dir(cfg.SocketPath), 0o755); err != nil {
+		return nil, fmt.Errorf("privhelper: create socket directory: %w", err)
+	}
+	ln, err := net.Listen("unix", cfg.SocketPath)
+	if err != nil {
+		return nil, fmt.Errorf("privhelper: listen: %w", err)
+	}
+
+	grp, err := user.LookupGroup(cfg.Group)
+	if err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("privhelper: lookup group %q: %w", cfg.Group, err)
+	}
+	gid, err := strconv.Atoi(grp.Gid)
+	if err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("privhelper: parse gid: %w", err)
+	}
+	if err := os.Chown(cfg.SocketPath, 0, gid); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("privhelper: chown socket: %w", err)
+	}
+	if err := os.Chmod(cfg.SocketPath, 0o660); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("privhelper: chmod socket: %w", err)
+	}
+	return ln, nil
+}
+
+// activationListener returns the first socket passed by systemd, if any.
+// ok is false when the process was not socket-activated.
+func activationListener() (ln net.Listener, ok bool, err error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, false, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, false, nil
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	f := os.NewFile(uintptr(listenFDsStart), "systemd-socket")
+	defer f.Close()
+	ln, err = net.FileListener(f)
+	if err != nil {
+		return nil, true, fmt.Errorf("privhelper: use activated socket: %w", err)
+	}
+	return ln, true, nil
+}
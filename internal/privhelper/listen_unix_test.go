@@ -0,0 +1,46 @@
+//go:build !windows
+
+package privhelper
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestListen_CreatesSocketWithGroupPermissions(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("chown to root requires root")
+	}
+	grp, err := user.LookupGroupId(strconv.Itoa(os.Getgid()))
+	if err != nil {
+		t.Skipf("lookup current group: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "run", "helper.sock")
+	ln, err := Listen(Config{SocketPath: path, Group: grp.Name})
+	if err != nil {
+		t.Fatalf("Listen() = %v", err)
+	}
+	defer ln.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() = %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o660 {
+		t.Errorf("socket perm = %04o, want 0660", perm)
+	}
+}
+
+func TestListen_UnknownGroup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "helper.sock")
+	if _, err := Listen(Config{SocketPath: path, Group: "plexd-no-such-group"}); err == nil {
+		t.Fatal("Listen() = nil error, want error for unknown group")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket left behind after failed Listen: %v", err)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package privhelper
+
+import (
+	"errors"
+	"net"
+)
+
+// Listen returns an error: on Windows plexd runs as a LocalSystem service
+// and performs privileged operations directly.
+func Listen(_ Config) (net.Listener, error) {
+	return nil, errors.New("privhelper: the privileged helper is not supported on windows")
+}
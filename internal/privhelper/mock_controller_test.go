@@ -0,0 +1,74 @@
+package privhelper
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/plexsphere/plexd/internal/wireguard"
+)
+
+// mockController is a test double for wireguard.WGController that records
+// each call as "Method iface".
+type mockController struct {
+	mu    sync.Mutex
+	calls []string
+	peers []wireguard.PeerConfig
+	err   error
+}
+
+func (m *mockController) record(method, iface string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, fmt.Sprintf("%s %s", method, iface))
+	return m.err
+}
+
+func (m *mockController) CreateInterface(name string, _ []byte, _ int) error {
+	return m.record("CreateInterface", name)
+}
+
+func (m *mockController) DeleteInterface(name string) error {
+	return m.record("DeleteInterface", name)
+}
+
+func (m *mockController) ConfigureAddress(name string, _ string) error {
+	return m.record("ConfigureAddress", name)
+}
+
+func (m *mockController) SetInterfaceUp(name string) error {
+	return m.record("SetInterfaceUp", name)
+}
+
+func (m *mockController) SetMTU(name string, _ int) error {
+	return m.record("SetMTU", name)
+}
+
+func (m *mockController) AddPeer(iface string, cfg wireguard.PeerConfig) error {
+	m.mu.Lock()
+	m.peers = append(m.peers, cfg)
+	m.mu.Unlock()
+	return m.record("AddPeer", iface)
+}
+
+func (m *mockController) RemovePeer(iface string, _ []byte) error {
+	return m.record("RemovePeer", iface)
+}
+
+func (m *mockController) getCalls() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.calls...)
+}
+
+// mockBatcher is a mockController that also implements
+// wireguard.PeerBatcher.
+type mockBatcher struct {
+	mockController
+}
+
+func (m *mockBatcher) ApplyPeers(iface string, remove [][]byte, upsert []wireguard.PeerConfig) error {
+	m.mu.Lock()
+	m.peers = append(m.peers, upsert...)
+	m.mu.Unlock()
+	return m.record(fmt.Sprintf("ApplyPeers(-%d,+%d)", len(remove), len(upsert)), iface)
+}
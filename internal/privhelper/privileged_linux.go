@@ -0,0 +1,23 @@
+//go:build linux
+
+package privhelper
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// HasNetAdmin reports whether the process can manage network interfaces
+// itself: it runs as root or holds CAP_NET_ADMIN in its effective set.
+func HasNetAdmin() bool {
+	if os.Geteuid() == 0 {
+		return true
+	}
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return false
+	}
+	return data[0].Effective&(1<<unix.CAP_NET_ADMIN) != 0
+}
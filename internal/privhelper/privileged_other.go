@@ -0,0 +1,11 @@
+//go:build !linux && !windows
+
+package privhelper
+
+import "os"
+
+// HasNetAdmin reports whether the process can manage network interfaces
+// itself, which requires root outside Linux.
+func HasNetAdmin() bool {
+	return os.Geteuid() == 0
+}
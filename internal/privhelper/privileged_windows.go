@@ -0,0 +1,11 @@
+//go:build windows
+
+package privhelper
+
+import "golang.org/x/sys/windows"
+
+// HasNetAdmin reports whether the process can manage network interfaces
+// itself, which requires an elevated token on Windows.
+func HasNetAdmin() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+}
@@ -0,0 +1,36 @@
+package privhelper
+
+import "github.com/plexsphere/plexd/internal/wireguard"
+
+// Operations understood by the helper. Each connection carries exactly one
+// JSON-encoded request followed by one JSON-encoded response.
+const (
+	opPing             = "ping"
+	opCreateInterface  = "create_interface"
+	opDeleteInterface  = "delete_interface"
+	opConfigureAddress = "configure_address"
+	opSetInterfaceUp   = "set_interface_up"
+	opSetMTU           = "set_mtu"
+	opAddPeer          = "add_peer"
+	opRemovePeer       = "remove_peer"
+	opApplyPeers       = "apply_peers"
+)
+
+// request is a single helper operation.
+type request struct {
+	Op         string                 `json:"op"`
+	Interface  string                 `json:"interface,omitempty"`
+	PrivateKey []byte                 `json:"private_key,omitempty"`
+	ListenPort int                    `json:"listen_port,omitempty"`
+	Address    string                 `json:"address,omitempty"`
+	MTU        int                    `json:"mtu,omitempty"`
+	Peer       *wireguard.PeerConfig  `json:"peer,omitempty"`
+	PublicKey  []byte                 `json:"public_key,omitempty"`
+	PublicKeys [][]byte               `json:"public_keys,omitempty"`
+	Peers      []wireguard.PeerConfig `json:"peers,omitempty"`
+}
+
+// response reports the outcome of a request. Error is empty on success.
+type response struct {
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,85 @@
+package privhelper
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/plexsphere/plexd/internal/wireguard"
+)
+
+// Privilege levels reported by Resolve.
+const (
+	// PrivilegeDirect means the agent performs privileged operations itself.
+	PrivilegeDirect = "direct"
+
+	// PrivilegeHelper means privileged operations go through the helper.
+	PrivilegeHelper = "helper"
+
+	// PrivilegeNone means the agent is unprivileged and no helper is
+	// reachable: mesh networking is unavailable.
+	PrivilegeNone = "unprivileged"
+)
+
+// ErrUnprivileged is returned by every operation of the controller Resolve
+// returns when neither direct access nor the helper is available.
+var ErrUnprivileged = errors.New("privhelper: operation requires CAP_NET_ADMIN or the privileged helper")
+
+// Resolution is the outcome of Resolve.
+type Resolution struct {
+	// Privilege is PrivilegeDirect, PrivilegeHelper, or PrivilegeNone.
+	Privilege string
+
+	// Controller performs WireGuard operations at the resolved privilege
+	// level. It is never nil; with PrivilegeNone every operation returns
+	// ErrUnprivileged.
+	Controller wireguard.WGController
+}
+
+// Degraded reports whether privileged operations are unavailable.
+func (r Resolution) Degraded() bool {
+	return r.Privilege == PrivilegeNone
+}
+
+// Resolve decides how the agent performs privileged operations. privileged
+// reports whether the agent may act directly (see HasNetAdmin); direct
+// constructs the in-process controller. In ModeAuto an unprivileged agent
+// uses the helper when it answers a ping and degrades to PrivilegeNone
+// otherwise, so that the rest of the agent keeps running.
+func Resolve(ctx context.Context, cfg Config, privileged bool, direct func() (wireguard.WGController, error), logger *slog.Logger) Resolution {
+	cfg.ApplyDefaults()
+	logger = logger.With("component", "privhelper")
+
+	if cfg.Mode == ModeDirect || (cfg.Mode == ModeAuto && privileged) {
+		ctrl, err := direct()
+		if err == nil {
+			logger.Info("performing privileged operations directly")
+			return Resolution{Privilege: PrivilegeDirect, Controller: ctrl}
+		}
+		logger.Warn("privileged operations unavailable", "error", err)
+		return Resolution{Privilege: PrivilegeNone, Controller: unprivilegedController{}}
+	}
+
+	client := NewClient(cfg)
+	if err := client.Ping(ctx); err != nil {
+		logger.Warn("running unprivileged without a reachable helper, mesh networking disabled",
+			"socket", cfg.SocketPath,
+			"error", err,
+		)
+		return Resolution{Privilege: PrivilegeNone, Controller: unprivilegedController{}}
+	}
+
+	logger.Info("delegating privileged operations to helper", "socket", cfg.SocketPath)
+	return Resolution{Privilege: PrivilegeHelper, Controller: client}
+}
+
+// unprivilegedController is the WGController of a degraded agent.
+type unprivilegedController struct{}
+
+func (unprivilegedController) CreateInterface(string, []byte, int) error  { return ErrUnprivileged }
+func (unprivilegedController) DeleteInterface(string) error               { return ErrUnprivileged }
+func (unprivilegedController) ConfigureAddress(string, string) error      { return ErrUnprivileged }
+func (unprivilegedController) SetInterfaceUp(string) error                { return ErrUnprivileged }
+func (unprivilegedController) SetMTU(string, int) error                   { return ErrUnprivileged }
+func (unprivilegedController) AddPeer(string, wireguard.PeerConfig) error { return ErrUnprivileged }
+func (unprivilegedController) RemovePeer(string, []byte) error            { return ErrUnprivileged }
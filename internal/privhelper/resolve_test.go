@@ -0,0 +1,81 @@
+package privhelper
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/wireguard"
+)
+
+func TestResolve_PrivilegedUsesDirect(t *testing.T) {
+	direct := &mockController{}
+	res := Resolve(context.Background(), Config{}, true, func() (wireguard.WGController, error) { return direct, nil }, testLogger())
+
+	if res.Privilege != PrivilegeDirect {
+		t.Errorf("Privilege = %q, want %q", res.Privilege, PrivilegeDirect)
+	}
+	if res.Controller != direct {
+		t.Error("Controller is not the direct controller")
+	}
+	if res.Degraded() {
+		t.Error("Degraded() = true, want false")
+	}
+}
+
+func TestResolve_UnprivilegedUsesHelper(t *testing.T) {
+	client := startServer(t, &mockController{})
+	directCalled := false
+	res := Resolve(context.Background(), client.cfg, false, func() (wireguard.WGController, error) {
+		directCalled = true
+		return nil, nil
+	}, testLogger())
+
+	if res.Privilege != PrivilegeHelper {
+		t.Errorf("Privilege = %q, want %q", res.Privilege, PrivilegeHelper)
+	}
+	if _, ok := res.Controller.(*Client); !ok {
+		t.Errorf("Controller = %T, want *Client", res.Controller)
+	}
+	if directCalled {
+		t.Error("direct controller constructed in unprivileged mode")
+	}
+}
+
+func TestResolve_UnprivilegedWithoutHelperDegrades(t *testing.T) {
+	cfg := Config{SocketPath: filepath.Join(t.TempDir(), "missing.sock"), Timeout: time.Second}
+	res := Resolve(context.Background(), cfg, false, nil, testLogger())
+
+	if res.Privilege != PrivilegeNone {
+		t.Errorf("Privilege = %q, want %q", res.Privilege, PrivilegeNone)
+	}
+	if !res.Degraded() {
+		t.Error("Degraded() = false, want true")
+	}
+	if err := res.Controller.CreateInterface("plexd0", nil, 51820); !errors.Is(err, ErrUnprivileged) {
+		t.Errorf("CreateInterface() = %v, want ErrUnprivileged", err)
+	}
+}
+
+func TestResolve_HelperModeIgnoresPrivilege(t *testing.T) {
+	client := startServer(t, &mockController{})
+	cfg := client.cfg
+	cfg.Mode = ModeHelper
+
+	res := Resolve(context.Background(), cfg, true, nil, testLogger())
+	if res.Privilege != PrivilegeHelper {
+		t.Errorf("Privilege = %q, want %q", res.Privilege, PrivilegeHelper)
+	}
+}
+
+func TestResolve_DirectControllerErrorDegrades(t *testing.T) {
+	res := Resolve(context.Background(), Config{Mode: ModeDirect}, false, func() (wireguard.WGController, error) {
+		return nil, errors.New("no controller")
+	}, testLogger())
+
+	if res.Privilege != PrivilegeNone {
+		t.Errorf("Privilege = %q, want %q", res.Privilege, PrivilegeNone)
+	}
+}
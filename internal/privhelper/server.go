@@ -0,0 +1,141 @@
+package privhelper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/wireguard"
+)
+
+// Server is the privileged side of the helper. It performs WireGuard
+// operations requested by the agent through a WGController, restricted to
+// interfaces matching Config.InterfacePrefix.
+type Server struct {
+	ctrl   wireguard.WGController
+	cfg    Config
+	logger *slog.Logger
+}
+
+// NewServer returns a Server that applies requests to ctrl. Defaults are
+// applied to cfg.
+func NewServer(ctrl wireguard.WGController, cfg Config, logger *slog.Logger) *Server {
+	cfg.ApplyDefaults()
+	return &Server{
+		ctrl:   ctrl,
+		cfg:    cfg,
+		logger: logger.With("component", "privhelper"),
+	}
+}
+
+// Serve accepts connections on ln until ctx is cancelled or ln fails. It
+// closes ln before returning and waits for in-flight requests to finish.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	s.logger.Info("privileged helper listening", "addr", ln.Addr().String())
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("privhelper: accept: %w", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// serveConn reads one request from conn, applies it, and writes the response.
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(s.cfg.Timeout))
+
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		s.logger.Warn("invalid request", "error", err)
+		_ = json.NewEncoder(conn).Encode(response{Error: "invalid request: " + err.Error()})
+		return
+	}
+
+	var resp response
+	if err := s.handle(req); err != nil {
+		s.logger.Warn("request failed", "op", req.Op, "interface", req.Interface, "error", err)
+		resp.Error = err.Error()
+	} else if req.Op != opPing {
+		s.logger.Debug("request applied", "op", req.Op, "interface", req.Interface)
+	}
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		s.logger.Warn("write response failed", "op", req.Op, "error", err)
+	}
+}
+
+// handle validates req and dispatches it to the controller.
+func (s *Server) handle(req request) error {
+	if req.Op == opPing {
+		return nil
+	}
+	if !strings.HasPrefix(req.Interface, s.cfg.InterfacePrefix) {
+		return fmt.Errorf("interface %q is not managed by plexd (prefix %q)", req.Interface, s.cfg.InterfacePrefix)
+	}
+
+	switch req.Op {
+	case opCreateInterface:
+		return s.ctrl.CreateInterface(req.Interface, req.PrivateKey, req.ListenPort)
+	case opDeleteInterface:
+		return s.ctrl.DeleteInterface(req.Interface)
+	case opConfigureAddress:
+		return s.ctrl.ConfigureAddress(req.Interface, req.Address)
+	case opSetInterfaceUp:
+		return s.ctrl.SetInterfaceUp(req.Interface)
+	case opSetMTU:
+		return s.ctrl.SetMTU(req.Interface, req.MTU)
+	case opAddPeer:
+		if req.Peer == nil {
+			return errors.New("add_peer: missing peer")
+		}
+		return s.ctrl.AddPeer(req.Interface, *req.Peer)
+	case opRemovePeer:
+		return s.ctrl.RemovePeer(req.Interface, req.PublicKey)
+	case opApplyPeers:
+		return s.applyPeers(req)
+	default:
+		return fmt.Errorf("unknown operation %q", req.Op)
+	}
+}
+
+// applyPeers applies an apply_peers request, in one device configuration
+// when the controller supports it and peer by peer otherwise.
+func (s *Server) applyPeers(req request) error {
+	if batcher, ok := s.ctrl.(wireguard.PeerBatcher); ok {
+		return batcher.ApplyPeers(req.Interface, req.PublicKeys, req.Peers)
+	}
+	for _, key := range req.PublicKeys {
+		if err := s.ctrl.RemovePeer(req.Interface, key); err != nil {
+			return err
+		}
+	}
+	for _, peer := range req.Peers {
+		if err := s.ctrl.AddPeer(req.Interface, peer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
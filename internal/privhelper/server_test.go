@@ -0,0 +1,173 @@
+package privhelper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/wireguard"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// startServer serves ctrl on a Unix socket in a temp directory and returns a
+// Client connected to it.
+func startServer(t *testing.T, ctrl wireguard.WGController) *Client {
+	t.Helper()
+	cfg := Config{SocketPath: filepath.Join(t.TempDir(), "helper.sock"), Timeout: 2 * time.Second}
+	ln, err := net.Listen("unix", cfg.SocketPath)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewServer(ctrl, cfg, testLogger()).Serve(ctx, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Serve() = %v", err)
+		}
+	})
+
+	return NewClient(cfg)
+}
+
+func TestClientServer_ForwardsOperations(t *testing.T) {
+	ctrl := &mockController{}
+	client := startServer(t, ctrl)
+
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping() = %v", err)
+	}
+
+	peer := wireguard.PeerConfig{
+		PublicKey:  make([]byte, 32),
+		Endpoint:   "203.0.113.1:51820",
+		AllowedIPs: []string{"10.0.0.2/32"},
+	}
+	steps := []func() error{
+		func() error { return client.CreateInterface("plexd0", make([]byte, 32), 51820) },
+		func() error { return client.ConfigureAddress("plexd0", "10.0.0.1/24") },
+		func() error { return client.SetMTU("plexd0", 1420) },
+		func() error { return client.SetInterfaceUp("plexd0") },
+		func() error { return client.AddPeer("plexd0", peer) },
+		func() error { return client.RemovePeer("plexd0", peer.PublicKey) },
+		func() error { return client.DeleteInterface("plexd0") },
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+
+	want := []string{
+		"CreateInterface plexd0",
+		"ConfigureAddress plexd0",
+		"SetMTU plexd0",
+		"SetInterfaceUp plexd0",
+		"AddPeer plexd0",
+		"RemovePeer plexd0",
+		"DeleteInterface plexd0",
+	}
+	if got := ctrl.getCalls(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+	if len(ctrl.peers) != 1 || ctrl.peers[0].Endpoint != peer.Endpoint || ctrl.peers[0].AllowedIPs[0] != "10.0.0.2/32" {
+		t.Errorf("peer not forwarded intact: %+v", ctrl.peers)
+	}
+}
+
+func TestClientServer_ApplyPeers(t *testing.T) {
+	remove := [][]byte{make([]byte, 32)}
+	upsert := []wireguard.PeerConfig{
+		{PublicKey: make([]byte, 32), AllowedIPs: []string{"10.0.0.2/32"}},
+		{PublicKey: make([]byte, 32), AllowedIPs: []string{"10.0.0.3/32"}},
+	}
+
+	t.Run("batched", func(t *testing.T) {
+		ctrl := &mockBatcher{}
+		client := startServer(t, ctrl)
+		if err := client.ApplyPeers("plexd0", remove, upsert); err != nil {
+			t.Fatalf("ApplyPeers() = %v", err)
+		}
+		if got := ctrl.getCalls(); strings.Join(got, "|") != "ApplyPeers(-1,+2) plexd0" {
+			t.Errorf("calls = %v", got)
+		}
+		if len(ctrl.peers) != 2 || ctrl.peers[1].AllowedIPs[0] != "10.0.0.3/32" {
+			t.Errorf("peers not forwarded intact: %+v", ctrl.peers)
+		}
+	})
+
+	t.Run("peer by peer", func(t *testing.T) {
+		ctrl := &mockController{}
+		client := startServer(t, ctrl)
+		if err := client.ApplyPeers("plexd0", remove, upsert); err != nil {
+			t.Fatalf("ApplyPeers() = %v", err)
+		}
+		want := "RemovePeer plexd0|AddPeer plexd0|AddPeer plexd0"
+		if got := ctrl.getCalls(); strings.Join(got, "|") != want {
+			t.Errorf("calls = %v, want %s", got, want)
+		}
+	})
+
+	t.Run("foreign interface", func(t *testing.T) {
+		ctrl := &mockBatcher{}
+		client := startServer(t, ctrl)
+		if err := client.ApplyPeers("eth0", remove, upsert); err == nil {
+			t.Fatal("ApplyPeers(eth0) = nil, want error")
+		}
+		if calls := ctrl.getCalls(); len(calls) != 0 {
+			t.Errorf("controller called for foreign interface: %v", calls)
+		}
+	})
+}
+
+func TestServer_RejectsForeignInterface(t *testing.T) {
+	ctrl := &mockController{}
+	client := startServer(t, ctrl)
+
+	err := client.DeleteInterface("eth0")
+	if err == nil {
+		t.Fatal("DeleteInterface(eth0) = nil, want error")
+	}
+	if !strings.Contains(err.Error(), "not managed by plexd") {
+		t.Errorf("error = %q, want message about unmanaged interface", err)
+	}
+	if calls := ctrl.getCalls(); len(calls) != 0 {
+		t.Errorf("controller called for foreign interface: %v", calls)
+	}
+}
+
+func TestServer_ReturnsControllerError(t *testing.T) {
+	ctrl := &mockController{err: errors.New("operation not permitted")}
+	client := startServer(t, ctrl)
+
+	err := client.SetInterfaceUp("plexd0")
+	if err == nil {
+		t.Fatal("SetInterfaceUp() = nil, want error")
+	}
+	if !strings.Contains(err.Error(), "privhelper: set_interface_up: operation not permitted") {
+		t.Errorf("error = %q", err)
+	}
+}
+
+func TestClient_HelperNotRunning(t *testing.T) {
+	client := NewClient(Config{SocketPath: filepath.Join(t.TempDir(), "missing.sock"), Timeout: time.Second})
+
+	err := client.Ping(context.Background())
+	if err == nil {
+		t.Fatal("Ping() = nil, want error")
+	}
+	if !strings.Contains(err.Error(), "dial helper") {
+		t.Errorf("error = %q, want dial error", err)
+	}
+}
@@ -11,16 +11,83 @@ type Config struct {
 	// Interval is the time between reconciliation cycles.
 	// Default: 60s
 	Interval time.Duration
+
+	// BackoffBase is the initial delay before a failed handler is retried.
+	// The delay doubles with each consecutive failure.
+	// Default: 5s
+	BackoffBase time.Duration
+
+	// BackoffMax caps the exponential backoff delay of a failing handler.
+	// Default: 5m
+	BackoffMax time.Duration
+
+	// CircuitBreakerThreshold is the number of consecutive failures after
+	// which a handler's circuit opens and it is skipped for CircuitOpenDuration.
+	// Default: 5
+	CircuitBreakerThreshold int
+
+	// CircuitOpenDuration is how long an open circuit skips its handler
+	// before a single half-open attempt is made.
+	// Default: 10m
+	CircuitOpenDuration time.Duration
+
+	// HistorySize is the number of cycles with drift or fetch errors kept
+	// for History. Must not be negative.
+	// Default: 100
+	HistorySize int
+
+	// DriftCheckInterval is the time between runs of the drift checkers,
+	// which compare the actual system state with the applied state. Must
+	// be at least 10s.
+	// Default: 5m
+	DriftCheckInterval time.Duration
 }
 
 // DefaultInterval is the default reconciliation interval.
 const DefaultInterval = 60 * time.Second
 
+// DefaultBackoffBase is the default initial handler retry delay.
+const DefaultBackoffBase = 5 * time.Second
+
+// DefaultBackoffMax is the default maximum handler retry delay.
+const DefaultBackoffMax = 5 * time.Minute
+
+// DefaultCircuitBreakerThreshold is the default number of consecutive
+// handler failures that opens the circuit.
+const DefaultCircuitBreakerThreshold = 5
+
+// DefaultCircuitOpenDuration is the default time an open circuit stays open.
+const DefaultCircuitOpenDuration = 10 * time.Minute
+
+// DefaultHistorySize is the default number of recorded cycles.
+const DefaultHistorySize = 100
+
+// DefaultDriftCheckInterval is the default time between drift checks.
+const DefaultDriftCheckInterval = 5 * time.Minute
+
 // ApplyDefaults sets default values for zero-valued fields.
 func (c *Config) ApplyDefaults() {
 	if c.Interval == 0 {
 		c.Interval = DefaultInterval
 	}
+	if c.BackoffBase == 0 {
+		c.BackoffBase = DefaultBackoffBase
+	}
+	if c.BackoffMax == 0 {
+		c.BackoffMax = DefaultBackoffMax
+	}
+	if c.CircuitBreakerThreshold == 0 {
+		c.CircuitBreakerThreshold = DefaultCircuitBreakerThreshold
+	}
+	if c.CircuitOpenDuration == 0 {
+		c.CircuitOpenDuration = DefaultCircuitOpenDuration
+	}
+	if c.HistorySize == 0 {
+		c.HistorySize = DefaultHistorySize
+	}
+	if c.DriftCheckInterval == 0 {
+		c.DriftCheckInterval = DefaultDriftCheckInterval
+	}
 }
 
 // Validate checks that configuration values are acceptable.
@@ -31,5 +98,23 @@ func (c *Config) Validate() error {
 	if c.Interval < time.Second {
 		return errors.New("reconcile: config: Interval must be at least 1s")
 	}
+	if c.BackoffBase < 0 {
+		return errors.New("reconcile: config: BackoffBase must not be negative")
+	}
+	if c.BackoffMax < c.BackoffBase {
+		return errors.New("reconcile: config: BackoffMax must be at least BackoffBase")
+	}
+	if c.CircuitBreakerThreshold < 0 {
+		return errors.New("reconcile: config: CircuitBreakerThreshold must not be negative")
+	}
+	if c.CircuitOpenDuration < 0 {
+		return errors.New("reconcile: config: CircuitOpenDuration must not be negative")
+	}
+	if c.HistorySize < 0 {
+		return errors.New("reconcile: config: HistorySize must not be negative")
+	}
+	if c.DriftCheckInterval != 0 && c.DriftCheckInterval < 10*time.Second {
+		return errors.New("reconcile: config: DriftCheckInterval must be at least 10s")
+	}
 	return nil
 }
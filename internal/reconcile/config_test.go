@@ -55,3 +55,54 @@ func TestConfig_ValidateAcceptsDefaultInterval(t *testing.T) {
 		t.Errorf("Validate() = %v, want nil", err)
 	}
 }
+
+func TestConfig_BackoffDefaults(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+
+	if cfg.BackoffBase != DefaultBackoffBase {
+		t.Errorf("BackoffBase = %v, want %v", cfg.BackoffBase, DefaultBackoffBase)
+	}
+	if cfg.BackoffMax != DefaultBackoffMax {
+		t.Errorf("BackoffMax = %v, want %v", cfg.BackoffMax, DefaultBackoffMax)
+	}
+	if cfg.CircuitBreakerThreshold != DefaultCircuitBreakerThreshold {
+		t.Errorf("CircuitBreakerThreshold = %d, want %d", cfg.CircuitBreakerThreshold, DefaultCircuitBreakerThreshold)
+	}
+	if cfg.CircuitOpenDuration != DefaultCircuitOpenDuration {
+		t.Errorf("CircuitOpenDuration = %v, want %v", cfg.CircuitOpenDuration, DefaultCircuitOpenDuration)
+	}
+}
+
+func TestConfig_ValidateRejectsBackoffMaxBelowBase(t *testing.T) {
+	cfg := Config{Interval: time.Minute, BackoffBase: time.Minute, BackoffMax: time.Second}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("Validate() = nil, want error for BackoffMax < BackoffBase")
+	}
+}
+
+func TestConfig_HistorySize(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+	if cfg.HistorySize != DefaultHistorySize {
+		t.Errorf("HistorySize = %d, want %d", cfg.HistorySize, DefaultHistorySize)
+	}
+
+	cfg.HistorySize = -1
+	if err := cfg.Validate(); err == nil || err.Error() != "reconcile: config: HistorySize must not be negative" {
+		t.Errorf("Validate() = %v, want HistorySize error", err)
+	}
+}
+
+func TestConfig_DriftCheckInterval(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+	if cfg.DriftCheckInterval != DefaultDriftCheckInterval {
+		t.Errorf("DriftCheckInterval = %v, want %v", cfg.DriftCheckInterval, DefaultDriftCheckInterval)
+	}
+
+	cfg.DriftCheckInterval = 5 * time.Second
+	if err := cfg.Validate(); err == nil || err.Error() != "reconcile: config: DriftCheckInterval must be at least 10s" {
+		t.Errorf("Validate() = %v, want DriftCheckInterval error", err)
+	}
+}
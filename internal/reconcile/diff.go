@@ -2,6 +2,7 @@ package reconcile
 
 import (
 	"maps"
+	"slices"
 	"sort"
 
 	"github.com/plexsphere/plexd/internal/api"
@@ -92,17 +93,22 @@ func peerChanged(desired, current api.Peer) bool {
 	if desired.PublicKey != current.PublicKey ||
 		desired.MeshIP != current.MeshIP ||
 		desired.Endpoint != current.Endpoint ||
-		desired.PSK != current.PSK {
+		desired.PSK != current.PSK ||
+		desired.Hostname != current.Hostname {
 		return true
 	}
 	return !sortedStringsEqual(desired.AllowedIPs, current.AllowedIPs)
 }
 
 // sortedStringsEqual compares two string slices after sorting copies.
+// Slices that are equal in order, the common case, are not copied.
 func sortedStringsEqual(a, b []string) bool {
 	if len(a) != len(b) {
 		return false
 	}
+	if slices.Equal(a, b) {
+		return true
+	}
 	sa := make([]string, len(a))
 	copy(sa, a)
 	sort.Strings(sa)
@@ -120,6 +126,9 @@ func sortedStringsEqual(a, b []string) bool {
 }
 
 func diffPolicies(desired, current []api.Policy, diff *StateDiff) {
+	if slices.EqualFunc(desired, current, func(d, c api.Policy) bool { return d.ID == c.ID }) {
+		return
+	}
 	currentByID := make(map[string]struct{}, len(current))
 	for _, p := range current {
 		currentByID[p.ID] = struct{}{}
@@ -162,6 +171,11 @@ func diffMetadata(desired, current map[string]string, diff *StateDiff) {
 }
 
 func diffData(desired, current []api.DataEntry, diff *StateDiff) {
+	if slices.EqualFunc(desired, current, func(d, c api.DataEntry) bool {
+		return d.Key == c.Key && d.Version == c.Version
+	}) {
+		return
+	}
 	type kv struct{ version int }
 	currentMap := make(map[string]kv, len(current))
 	for _, e := range current {
@@ -189,6 +203,11 @@ func diffData(desired, current []api.DataEntry, diff *StateDiff) {
 }
 
 func diffSecretRefs(desired, current []api.SecretRef, diff *StateDiff) {
+	if slices.EqualFunc(desired, current, func(d, c api.SecretRef) bool {
+		return d.Key == c.Key && d.Version == c.Version
+	}) {
+		return
+	}
 	currentMap := make(map[string]int, len(current))
 	for _, s := range current {
 		currentMap[s.Key] = s.Version
@@ -0,0 +1,109 @@
+package reconcile
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// benchMeshSizes are the peer counts the diff benchmarks run at.
+var benchMeshSizes = []int{1_000, 10_000, 50_000}
+
+// benchState returns a state with n peers, each with two allowed IPs.
+func benchState(n int) *api.StateResponse {
+	s := &api.StateResponse{
+		Peers:      make([]api.Peer, n),
+		Policies:   []api.Policy{{ID: "pol-1"}, {ID: "pol-2"}},
+		Metadata:   map[string]string{"env": "prod"},
+		Data:       []api.DataEntry{{Key: "config.yaml", Version: 1}},
+		SecretRefs: []api.SecretRef{{Key: "db-password", Version: 3}},
+	}
+	for i := range s.Peers {
+		s.Peers[i] = api.Peer{
+			ID:         fmt.Sprintf("peer-%06d", i),
+			PublicKey:  fmt.Sprintf("pk-%06d-AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", i),
+			MeshIP:     fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff),
+			Endpoint:   fmt.Sprintf("198.51.100.%d:51820", i%250),
+			AllowedIPs: []string{fmt.Sprintf("10.%d.%d.%d/32", i>>16&0xff, i>>8&0xff, i&0xff), "192.168.0.0/24"},
+			PSK:        "psk",
+		}
+	}
+	return s
+}
+
+// benchScenarios derive a desired state from a copy of the current state.
+var benchScenarios = []struct {
+	name   string
+	mutate func(s *api.StateResponse)
+}{
+	{"unchanged", func(*api.StateResponse) {}},
+	{"one_updated", func(s *api.StateResponse) {
+		s.Peers[len(s.Peers)/2].Endpoint = "203.0.113.1:51820"
+	}},
+	{"churn_1pct", func(s *api.StateResponse) {
+		n := len(s.Peers) / 100
+		for i := range n {
+			s.Peers[i].Endpoint = "203.0.113.1:51820"
+		}
+		s.Peers = s.Peers[:len(s.Peers)-n]
+		for i := range n {
+			s.Peers = append(s.Peers, api.Peer{ID: fmt.Sprintf("new-%06d", i), PublicKey: "pk-new"})
+		}
+	}},
+}
+
+func benchDesired(current *api.StateResponse, mutate func(*api.StateResponse)) *api.StateResponse {
+	desired := *current
+	desired.Peers = copyPeers(current.Peers)
+	mutate(&desired)
+	return &desired
+}
+
+func BenchmarkComputeDiff(b *testing.B) {
+	for _, n := range benchMeshSizes {
+		current := benchState(n)
+		for _, sc := range benchScenarios {
+			desired := benchDesired(current, sc.mutate)
+			b.Run(fmt.Sprintf("peers=%d/%s", n, sc.name), func(b *testing.B) {
+				b.ReportAllocs()
+				for b.Loop() {
+					ComputeDiff(desired, current)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkStateSnapshot_Diff measures the diff of one reconcile cycle.
+func BenchmarkStateSnapshot_Diff(b *testing.B) {
+	for _, n := range benchMeshSizes {
+		current := benchState(n)
+		snap := NewStateSnapshot()
+		snap.Update(current)
+		for _, sc := range benchScenarios {
+			desired := benchDesired(current, sc.mutate)
+			b.Run(fmt.Sprintf("peers=%d/%s", n, sc.name), func(b *testing.B) {
+				b.ReportAllocs()
+				for b.Loop() {
+					snap.Diff(desired)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkStateSnapshot_Update measures the snapshot update after a cycle
+// with drift, including the rebuild of the peer index.
+func BenchmarkStateSnapshot_Update(b *testing.B) {
+	for _, n := range benchMeshSizes {
+		state := benchState(n)
+		snap := NewStateSnapshot()
+		b.Run(fmt.Sprintf("peers=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				snap.Update(state)
+			}
+		})
+	}
+}
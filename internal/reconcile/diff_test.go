@@ -116,6 +116,21 @@ func TestComputeDiff_PeersUpdatedAllowedIPs(t *testing.T) {
 	})
 }
 
+func TestComputeDiff_PeersUpdatedHostname(t *testing.T) {
+	desired := &api.StateResponse{
+		Peers: []api.Peer{{ID: "p1", PublicKey: "pk1", Hostname: "db-1"}},
+	}
+	current := &api.StateResponse{
+		Peers: []api.Peer{{ID: "p1", PublicKey: "pk1", Hostname: "db-0"}},
+	}
+
+	diff := ComputeDiff(desired, current)
+
+	if len(diff.PeersToUpdate) != 1 {
+		t.Fatalf("changed Hostname should produce update, got %d updates", len(diff.PeersToUpdate))
+	}
+}
+
 func TestComputeDiff_PoliciesAddedAndRemoved(t *testing.T) {
 	desired := &api.StateResponse{
 		Policies: []api.Policy{
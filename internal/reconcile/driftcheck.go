@@ -0,0 +1,124 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// DriftChecker compares the actual system state, such as WireGuard peers or
+// kernel routes, with the desired state, repairs differences made outside
+// plexd, and returns one DriftCorrection per repair. desired is the state
+// most recently applied by all handlers. A checker that finds nothing to
+// repair returns nil.
+type DriftChecker func(ctx context.Context, desired *api.StateResponse) ([]api.DriftCorrection, error)
+
+// namedChecker pairs a DriftChecker with the name used in logs and history.
+type namedChecker struct {
+	name  string
+	check DriftChecker
+}
+
+// RegisterDriftChecker adds a checker run every cfg.DriftCheckInterval.
+// Checkers are called in registration order. RegisterDriftChecker must be
+// called before Run; it is not safe for concurrent use.
+func (r *Reconciler) RegisterDriftChecker(name string, checker DriftChecker) {
+	r.checkers = append(r.checkers, namedChecker{name: name, check: checker})
+}
+
+// runDriftCheck runs all drift checkers against the applied state and
+// reports their repairs. It is skipped while paused and while the applied
+// state is unknown or stale: before the first successful cycle and after a
+// cycle whose handlers did not all succeed. Checks that repair something or
+// fail are recorded in the history.
+func (r *Reconciler) runDriftCheck(ctx context.Context, nodeID string) {
+	if !r.PausedUntil().IsZero() {
+		return
+	}
+	applied := r.applied.Load()
+	if applied == nil {
+		r.logger.Debug("drift check skipped: applied state unknown",
+			"component", "reconcile",
+			"node_id", nodeID,
+		)
+		return
+	}
+
+	// Repairs made by the checkers are attributed to the drift check.
+	ctx, done := r.withCause(ctx, api.Cause{Type: api.CauseDriftCheck})
+	defer done()
+
+	start := time.Now()
+	var corrections []api.DriftCorrection
+	results := make([]HandlerResult, 0, len(r.checkers))
+	anyFailed := false
+	for _, c := range r.checkers {
+		found, err := r.safeCheck(ctx, c.check, applied)
+		corrections = append(corrections, found...)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			r.logger.Warn("drift checker failed",
+				"component", "reconcile",
+				"checker", c.name,
+				"error", err,
+			)
+			anyFailed = true
+			msg, _, _ := strings.Cut(err.Error(), "\n")
+			results = append(results, HandlerResult{Name: c.name, Status: HandlerFailed, Error: msg})
+			continue
+		}
+		results = append(results, HandlerResult{Name: c.name, Status: HandlerOK})
+	}
+
+	if len(corrections) == 0 && !anyFailed {
+		r.logger.Debug("no external drift detected",
+			"component", "reconcile",
+			"node_id", nodeID,
+			"duration", time.Since(start),
+		)
+		return
+	}
+
+	if len(corrections) > 0 {
+		report := api.DriftReport{Timestamp: time.Now().UTC(), Corrections: corrections}
+		if err := r.client.ReportDrift(ctx, nodeID, report); err != nil && ctx.Err() == nil {
+			r.logger.Warn("ReportDrift failed",
+				"component", "reconcile",
+				"node_id", nodeID,
+				"error", err,
+			)
+		}
+	}
+
+	r.history.add(CycleRecord{
+		Start:        start,
+		DurationNano: time.Since(start).Nanoseconds(),
+		Trigger:      TriggerDriftCheck,
+		Handlers:     results,
+		Corrections:  corrections,
+	})
+
+	r.logger.Info("external drift repaired",
+		"component", "reconcile",
+		"node_id", nodeID,
+		"drift_count", len(corrections),
+		"duration", time.Since(start),
+		"checker_failed", anyFailed,
+	)
+}
+
+// safeCheck calls a checker with panic recovery.
+func (r *Reconciler) safeCheck(ctx context.Context, checker DriftChecker, applied *api.StateResponse) (corrections []api.DriftCorrection, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("drift checker panicked: %v\n%s", v, debug.Stack())
+		}
+	}()
+	return checker(ctx, applied)
+}
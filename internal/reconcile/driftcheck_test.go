@@ -0,0 +1,123 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+func TestReconciler_DriftCheckSkippedUntilApplied(t *testing.T) {
+	r := NewReconciler(&mockFetcher{}, Config{}, discardLogger())
+	calls := 0
+	r.RegisterDriftChecker("wireguard", func(context.Context, *api.StateResponse) ([]api.DriftCorrection, error) {
+		calls++
+		return nil, nil
+	})
+
+	r.runDriftCheck(context.Background(), "node-1")
+	if calls != 0 {
+		t.Errorf("checker called %d times before the first cycle, want 0", calls)
+	}
+}
+
+func TestReconciler_DriftCheckRepairsReported(t *testing.T) {
+	desired := &api.StateResponse{Peers: []api.Peer{{ID: "peer-1"}}}
+	fetcher := &mockFetcher{
+		fetchFunc: func(context.Context, string) (*api.StateResponse, error) { return desired, nil },
+	}
+	r := NewReconciler(fetcher, Config{}, discardLogger())
+	r.RegisterNamedHandler("wireguard", func(context.Context, *api.StateResponse, StateDiff) error { return nil })
+
+	var got *api.StateResponse
+	r.RegisterDriftChecker("wireguard", func(_ context.Context, applied *api.StateResponse) ([]api.DriftCorrection, error) {
+		got = applied
+		return []api.DriftCorrection{{Type: "wg_peer_restored", Detail: "peer peer-1"}}, nil
+	})
+	r.RegisterDriftChecker("bridge", func(context.Context, *api.StateResponse) ([]api.DriftCorrection, error) {
+		return nil, errors.New("netlink: permission denied")
+	})
+
+	r.runCycle(context.Background(), "node-1", TriggerInitial)
+	driftBefore := fetcher.getDriftCount()
+	r.runDriftCheck(context.Background(), "node-1")
+
+	if got == nil || len(got.Peers) != 1 || got.Peers[0].ID != "peer-1" {
+		t.Fatalf("checker got applied state %+v, want the desired state", got)
+	}
+	if fetcher.getDriftCount() != driftBefore+1 {
+		t.Fatalf("ReportDrift calls = %d, want %d", fetcher.getDriftCount(), driftBefore+1)
+	}
+	if last := fetcher.getLastDrift(); len(last.Corrections) != 1 || last.Corrections[0].Type != "wg_peer_restored" {
+		t.Errorf("reported corrections = %+v", last.Corrections)
+	}
+
+	h := r.History()
+	rec := h[len(h)-1]
+	if rec.Trigger != TriggerDriftCheck || rec.Diff != nil || len(rec.Corrections) != 1 {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+	if len(rec.Handlers) != 2 || rec.Handlers[0].Status != HandlerOK || rec.Handlers[1].Status != HandlerFailed {
+		t.Errorf("Handlers = %+v", rec.Handlers)
+	}
+}
+
+func TestReconciler_DriftCheckSkippedAfterHandlerFailure(t *testing.T) {
+	fetcher := &mockFetcher{
+		fetchFunc: func(context.Context, string) (*api.StateResponse, error) {
+			return &api.StateResponse{Peers: []api.Peer{{ID: "peer-1"}}}, nil
+		},
+	}
+	r := NewReconciler(fetcher, Config{}, discardLogger())
+	r.RegisterNamedHandler("wireguard", func(context.Context, *api.StateResponse, StateDiff) error {
+		return errors.New("boom")
+	})
+	calls := 0
+	r.RegisterDriftChecker("wireguard", func(context.Context, *api.StateResponse) ([]api.DriftCorrection, error) {
+		calls++
+		return nil, nil
+	})
+
+	r.runCycle(context.Background(), "node-1", TriggerInitial)
+	r.runDriftCheck(context.Background(), "node-1")
+	if calls != 0 {
+		t.Errorf("checker called %d times with a stale snapshot, want 0", calls)
+	}
+}
+
+func TestReconciler_DriftCheckNothingFound(t *testing.T) {
+	fetcher := &mockFetcher{}
+	r := NewReconciler(fetcher, Config{}, discardLogger())
+	r.RegisterDriftChecker("wireguard", func(context.Context, *api.StateResponse) ([]api.DriftCorrection, error) {
+		return nil, nil
+	})
+
+	r.runCycle(context.Background(), "node-1", TriggerInitial)
+	r.runDriftCheck(context.Background(), "node-1")
+
+	if fetcher.getDriftCount() != 0 {
+		t.Errorf("ReportDrift calls = %d, want 0", fetcher.getDriftCount())
+	}
+	if len(r.History()) != 0 {
+		t.Errorf("History() = %+v, want empty", r.History())
+	}
+}
+
+func TestReconciler_DriftCheckPaused(t *testing.T) {
+	r := NewReconciler(&mockFetcher{}, Config{}, discardLogger())
+	calls := 0
+	r.RegisterDriftChecker("wireguard", func(context.Context, *api.StateResponse) ([]api.DriftCorrection, error) {
+		calls++
+		return nil, nil
+	})
+	r.runCycle(context.Background(), "node-1", TriggerInitial)
+
+	r.Pause(time.Hour)
+	defer r.Resume()
+	r.runDriftCheck(context.Background(), "node-1")
+	if calls != 0 {
+		t.Errorf("checker called %d times while paused, want 0", calls)
+	}
+}
@@ -0,0 +1,56 @@
+package reconcile
+
+import (
+	"hash/maphash"
+	"math/bits"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// peerFingerprint is a 128-bit hash of a peer's ID and of the fields that
+// ComputeDiff compares. Peers with equal fingerprints are treated as equal;
+// at 128 bits an accidental collision is not a practical concern.
+type peerFingerprint [2]uint64
+
+// add returns the component-wise sum of f and g. Sums of fingerprints do
+// not depend on the order of the peers, so a whole peer list can be
+// compared by one sum.
+func (f peerFingerprint) add(g peerFingerprint) peerFingerprint {
+	return peerFingerprint{f[0] + g[0], f[1] + g[1]}
+}
+
+// fingerprinter hashes peers with two independent random seeds. Seeds are
+// per process, so fingerprints must never be persisted.
+type fingerprinter struct {
+	seeds [2]maphash.Seed
+}
+
+func newFingerprinter() fingerprinter {
+	return fingerprinter{seeds: [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()}}
+}
+
+// peer returns the fingerprint of p. AllowedIPs are hashed independently of
+// their order, matching the order-independent comparison of ComputeDiff.
+func (f fingerprinter) peer(p *api.Peer) peerFingerprint {
+	var fp peerFingerprint
+	for i, seed := range f.seeds {
+		// Each field is hashed on its own, so field boundaries cannot
+		// shift, and the field hashes are mixed in a fixed order.
+		h := uint64(len(p.AllowedIPs))
+		for _, s := range [...]string{p.ID, p.PublicKey, p.MeshIP, p.Endpoint, p.PSK, p.Hostname} {
+			h = mix(h, maphash.String(seed, s))
+		}
+		var ips uint64
+		for _, ip := range p.AllowedIPs {
+			ips += maphash.String(seed, ip)
+		}
+		fp[i] = mix(h, ips)
+	}
+	return fp
+}
+
+// mix combines the running hash h with v.
+func mix(h, v uint64) uint64 {
+	h ^= v + 0x9e3779b97f4a7c15 + h<<6 + h>>2
+	return bits.RotateLeft64(h*0xff51afd7ed558ccd, 31)
+}
@@ -0,0 +1,124 @@
+package reconcile
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// HandlerHealth describes the failure state of a single reconcile handler.
+type HandlerHealth struct {
+	Name                string    `json:"name"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastFailure         time.Time `json:"last_failure,omitempty"`
+	NextAttempt         time.Time `json:"next_attempt,omitempty"`
+	CircuitOpen         bool      `json:"circuit_open"`
+}
+
+// handlerState tracks failures and backoff for one registered handler.
+type handlerState struct {
+	name        string
+	failures    int
+	lastErr     string
+	lastFailure time.Time
+	nextAttempt time.Time
+	circuitOpen bool
+}
+
+// healthTracker records per-handler failures and computes exponential
+// backoff and circuit breaker state. It is safe for concurrent use.
+type healthTracker struct {
+	mu     sync.Mutex
+	cfg    Config
+	states map[string]*handlerState
+}
+
+func newHealthTracker(cfg Config) *healthTracker {
+	return &healthTracker{
+		cfg:    cfg,
+		states: make(map[string]*handlerState),
+	}
+}
+
+// allow reports whether the named handler may be invoked at now.
+// A handler in backoff or with an open circuit is skipped until its next
+// attempt time; once that passes, a single half-open attempt is allowed.
+func (t *healthTracker) allow(name string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st, ok := t.states[name]
+	if !ok {
+		return true
+	}
+	return !now.Before(st.nextAttempt)
+}
+
+// recordSuccess resets the failure state of the named handler.
+func (t *healthTracker) recordSuccess(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.states, name)
+}
+
+// recordFailure increments the failure count of the named handler and
+// schedules its next attempt. It returns the updated health.
+func (t *healthTracker) recordFailure(name string, err error, now time.Time) HandlerHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st, ok := t.states[name]
+	if !ok {
+		st = &handlerState{name: name}
+		t.states[name] = st
+	}
+	st.failures++
+	st.lastErr = err.Error()
+	st.lastFailure = now
+
+	if st.failures >= t.cfg.CircuitBreakerThreshold {
+		st.circuitOpen = true
+		st.nextAttempt = now.Add(t.cfg.CircuitOpenDuration)
+	} else {
+		st.nextAttempt = now.Add(t.backoff(st.failures))
+	}
+	return st.health()
+}
+
+// backoff returns the exponential backoff delay after n consecutive failures.
+func (t *healthTracker) backoff(n int) time.Duration {
+	d := t.cfg.BackoffBase
+	for i := 1; i < n; i++ {
+		d *= 2
+		if d >= t.cfg.BackoffMax {
+			return t.cfg.BackoffMax
+		}
+	}
+	if d > t.cfg.BackoffMax {
+		return t.cfg.BackoffMax
+	}
+	return d
+}
+
+// degraded returns the health of all handlers with at least one
+// consecutive failure, sorted by name.
+func (t *healthTracker) degraded() []HandlerHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]HandlerHealth, 0, len(t.states))
+	for _, st := range t.states {
+		out = append(out, st.health())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (st *handlerState) health() HandlerHealth {
+	return HandlerHealth{
+		Name:                st.name,
+		ConsecutiveFailures: st.failures,
+		LastError:           st.lastErr,
+		LastFailure:         st.lastFailure,
+		NextAttempt:         st.nextAttempt,
+		CircuitOpen:         st.circuitOpen,
+	}
+}
@@ -0,0 +1,58 @@
+package reconcile
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHealthTracker_ExponentialBackoff(t *testing.T) {
+	cfg := Config{BackoffBase: time.Second, BackoffMax: 5 * time.Second, CircuitBreakerThreshold: 10, CircuitOpenDuration: time.Minute}
+	tr := newHealthTracker(cfg)
+	now := time.Unix(1000, 0)
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		hh := tr.recordFailure("h", errors.New("fail"), now)
+		if got := hh.NextAttempt.Sub(now); got != w {
+			t.Errorf("failure %d: backoff = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestHealthTracker_AllowRespectsNextAttempt(t *testing.T) {
+	cfg := Config{BackoffBase: time.Second, BackoffMax: time.Minute, CircuitBreakerThreshold: 5, CircuitOpenDuration: time.Minute}
+	tr := newHealthTracker(cfg)
+	now := time.Unix(1000, 0)
+
+	if !tr.allow("h", now) {
+		t.Fatal("allow() = false for unknown handler, want true")
+	}
+	tr.recordFailure("h", errors.New("fail"), now)
+	if tr.allow("h", now.Add(500*time.Millisecond)) {
+		t.Error("allow() = true during backoff, want false")
+	}
+	if !tr.allow("h", now.Add(time.Second)) {
+		t.Error("allow() = false after backoff elapsed, want true")
+	}
+}
+
+func TestHealthTracker_CircuitOpensAndResets(t *testing.T) {
+	cfg := Config{BackoffBase: time.Second, BackoffMax: time.Minute, CircuitBreakerThreshold: 2, CircuitOpenDuration: 10 * time.Minute}
+	tr := newHealthTracker(cfg)
+	now := time.Unix(1000, 0)
+
+	tr.recordFailure("h", errors.New("fail"), now)
+	hh := tr.recordFailure("h", errors.New("fail"), now)
+	if !hh.CircuitOpen {
+		t.Fatal("CircuitOpen = false after threshold, want true")
+	}
+	if got := hh.NextAttempt.Sub(now); got != 10*time.Minute {
+		t.Errorf("open duration = %v, want 10m", got)
+	}
+
+	tr.recordSuccess("h")
+	if d := tr.degraded(); len(d) != 0 {
+		t.Errorf("degraded() = %+v, want empty after success", d)
+	}
+}
@@ -0,0 +1,125 @@
+package reconcile
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// Cycle triggers recorded in CycleRecord.Trigger.
+const (
+	// TriggerInitial is the first cycle, run when Run starts.
+	TriggerInitial = "initial"
+	// TriggerInterval is a periodic cycle.
+	TriggerInterval = "interval"
+	// TriggerManual is a cycle requested with TriggerReconcile.
+	TriggerManual = "triggered"
+	// TriggerResume is the cycle run when a pause ends.
+	TriggerResume = "resumed"
+	// TriggerDriftCheck is a run of the drift checkers. Its record has no
+	// diff; Handlers holds the checkers' outcomes.
+	TriggerDriftCheck = "drift_check"
+)
+
+// Handler outcomes recorded in HandlerResult.Status.
+const (
+	HandlerOK      = "ok"
+	HandlerFailed  = "failed"
+	HandlerSkipped = "skipped"
+)
+
+// DiffSummary counts the drift found in a cycle.
+type DiffSummary struct {
+	PeersAdded         int  `json:"peers_added"`
+	PeersRemoved       int  `json:"peers_removed"`
+	PeersUpdated       int  `json:"peers_updated"`
+	PoliciesAdded      int  `json:"policies_added"`
+	PoliciesRemoved    int  `json:"policies_removed"`
+	SigningKeysChanged bool `json:"signing_keys_changed"`
+	MetadataChanged    bool `json:"metadata_changed"`
+	DataChanged        bool `json:"data_changed"`
+	SecretRefsChanged  bool `json:"secret_refs_changed"`
+}
+
+// summarizeDiff returns the counts of diff.
+func summarizeDiff(diff StateDiff) DiffSummary {
+	return DiffSummary{
+		PeersAdded:         len(diff.PeersToAdd),
+		PeersRemoved:       len(diff.PeersToRemove),
+		PeersUpdated:       len(diff.PeersToUpdate),
+		PoliciesAdded:      len(diff.PoliciesToAdd),
+		PoliciesRemoved:    len(diff.PoliciesToRemove),
+		SigningKeysChanged: diff.SigningKeysChanged,
+		MetadataChanged:    diff.MetadataChanged,
+		DataChanged:        diff.DataChanged,
+		SecretRefsChanged:  diff.SecretRefsChanged,
+	}
+}
+
+// HandlerResult is the outcome of one handler in a cycle.
+type HandlerResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// CycleRecord describes a reconciliation cycle that found drift or could
+// not fetch the desired state, or a drift check that repaired or failed
+// something. Cycles without drift are not recorded.
+type CycleRecord struct {
+	Start        time.Time `json:"start"`
+	DurationNano int64     `json:"duration_nano"`
+	Trigger      string    `json:"trigger"`
+
+	// Error is set when the desired state could not be fetched; the cycle
+	// then has no diff.
+	Error string `json:"error,omitempty"`
+
+	Diff        *DiffSummary          `json:"diff,omitempty"`
+	Handlers    []HandlerResult       `json:"handlers,omitempty"`
+	Corrections []api.DriftCorrection `json:"corrections,omitempty"`
+
+	// SnapshotUpdated is false when a handler failed or was skipped, so
+	// the same drift is handled again in the next cycle.
+	SnapshotUpdated bool `json:"snapshot_updated"`
+}
+
+// cycleHistory is a bounded ring of recent cycle records. It is safe for
+// concurrent use.
+type cycleHistory struct {
+	mu     sync.Mutex
+	size   int
+	cycles []CycleRecord
+}
+
+func newCycleHistory(size int) *cycleHistory {
+	return &cycleHistory{size: size}
+}
+
+// add appends rec, dropping the oldest record when the ring is full.
+func (h *cycleHistory) add(rec CycleRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cycles = append(h.cycles, rec)
+	if len(h.cycles) > h.size {
+		h.cycles = slices.Delete(h.cycles, 0, len(h.cycles)-h.size)
+	}
+}
+
+// list returns a copy of the records, oldest first.
+func (h *cycleHistory) list() []CycleRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]CycleRecord, len(h.cycles))
+	copy(out, h.cycles)
+	return out
+}
+
+// History returns the most recent cycles that found drift or failed to
+// fetch state, oldest first, at most Config.HistorySize. Safe for
+// concurrent use.
+func (r *Reconciler) History() []CycleRecord {
+	return r.history.list()
+}
@@ -0,0 +1,112 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+func TestReconciler_HistoryRecordsDrift(t *testing.T) {
+	fetcher := &mockFetcher{
+		fetchFunc: func(_ context.Context, _ string) (*api.StateResponse, error) {
+			return &api.StateResponse{
+				Peers: []api.Peer{{ID: "peer-1"}, {ID: "peer-2"}},
+			}, nil
+		},
+	}
+	r := NewReconciler(fetcher, Config{}, discardLogger())
+	r.RegisterNamedHandler("wireguard", func(context.Context, *api.StateResponse, StateDiff) error { return nil })
+	r.RegisterNamedHandler("policy", func(context.Context, *api.StateResponse, StateDiff) error {
+		return errors.New("nft: busy")
+	})
+
+	r.runCycle(context.Background(), "node-1", TriggerInitial)
+
+	h := r.History()
+	if len(h) != 1 {
+		t.Fatalf("History() has %d records, want 1", len(h))
+	}
+	rec := h[0]
+	if rec.Trigger != TriggerInitial || rec.Start.IsZero() || rec.Error != "" {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+	if rec.Diff == nil || rec.Diff.PeersAdded != 2 {
+		t.Errorf("Diff = %+v, want 2 peers added", rec.Diff)
+	}
+	if len(rec.Corrections) != 2 || rec.Corrections[0].Type != "peer_added" {
+		t.Errorf("Corrections = %+v", rec.Corrections)
+	}
+	want := []HandlerResult{
+		{Name: "wireguard", Status: HandlerOK},
+		{Name: "policy", Status: HandlerFailed, Error: "nft: busy"},
+	}
+	if fmt.Sprint(rec.Handlers) != fmt.Sprint(want) {
+		t.Errorf("Handlers = %+v, want %+v", rec.Handlers, want)
+	}
+	if rec.SnapshotUpdated {
+		t.Error("SnapshotUpdated = true, want false after a handler failure")
+	}
+
+	// The failed handler is in backoff: the same drift is recorded again
+	// with the handler skipped.
+	r.runCycle(context.Background(), "node-1", TriggerManual)
+	h = r.History()
+	if len(h) != 2 || h[1].Trigger != TriggerManual || h[1].Handlers[1].Status != HandlerSkipped {
+		t.Errorf("unexpected second record: %+v", h[len(h)-1])
+	}
+}
+
+func TestReconciler_HistorySkipsCyclesWithoutDrift(t *testing.T) {
+	r := NewReconciler(&mockFetcher{}, Config{}, discardLogger())
+
+	r.runCycle(context.Background(), "node-1", TriggerInterval)
+
+	if h := r.History(); len(h) != 0 {
+		t.Errorf("History() = %+v, want empty", h)
+	}
+}
+
+func TestReconciler_HistoryRecordsFetchError(t *testing.T) {
+	fetcher := &mockFetcher{
+		fetchFunc: func(_ context.Context, _ string) (*api.StateResponse, error) {
+			return nil, errors.New("connection refused")
+		},
+	}
+	r := NewReconciler(fetcher, Config{}, discardLogger())
+
+	r.runCycle(context.Background(), "node-1", TriggerInterval)
+
+	h := r.History()
+	if len(h) != 1 || h[0].Error != "connection refused" || h[0].Diff != nil {
+		t.Errorf("History() = %+v, want one fetch error record", h)
+	}
+}
+
+func TestReconciler_HistoryBounded(t *testing.T) {
+	n := 0
+	fetcher := &mockFetcher{
+		fetchFunc: func(_ context.Context, _ string) (*api.StateResponse, error) {
+			n++
+			return &api.StateResponse{Peers: []api.Peer{{ID: fmt.Sprintf("peer-%d", n)}}}, nil
+		},
+	}
+	r := NewReconciler(fetcher, Config{HistorySize: 3}, discardLogger())
+
+	for range 5 {
+		r.runCycle(context.Background(), "node-1", TriggerInterval)
+	}
+
+	h := r.History()
+	if len(h) != 3 {
+		t.Fatalf("History() has %d records, want 3", len(h))
+	}
+	if got := h[0].Corrections[0].Detail; got != "peer peer-3" {
+		t.Errorf("oldest record = %q, want peer peer-3", got)
+	}
+	if got := h[2].Corrections[0].Detail; got != "peer peer-5" {
+		t.Errorf("newest record = %q, want peer peer-5", got)
+	}
+}
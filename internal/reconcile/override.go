@@ -0,0 +1,32 @@
+package reconcile
+
+import "github.com/plexsphere/plexd/internal/api"
+
+// StateOverride adjusts the desired state fetched from the control plane
+// before it is diffed, for example with operator-managed local overrides.
+// Handlers, the snapshot, and drift checkers all see the adjusted state, so
+// an override is not reverted by the next cycle or drift check.
+type StateOverride interface {
+	// Override returns the state to reconcile towards. It must not modify
+	// desired but may return it unchanged.
+	Override(desired *api.StateResponse) *api.StateResponse
+}
+
+// SetStateOverride sets the override applied to every fetched desired
+// state. SetStateOverride must be called before Run; it is not safe for
+// concurrent use.
+func (r *Reconciler) SetStateOverride(o StateOverride) {
+	r.override = o
+}
+
+// StateOverrides applies several overrides in order, each to the state
+// returned by the one before.
+type StateOverrides []StateOverride
+
+// Override implements StateOverride.
+func (o StateOverrides) Override(desired *api.StateResponse) *api.StateResponse {
+	for _, so := range o {
+		desired = so.Override(desired)
+	}
+	return desired
+}
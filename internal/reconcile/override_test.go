@@ -0,0 +1,67 @@
+package reconcile
+
+import (
+	"context"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+type dropPeerOverride struct{ id string }
+
+func (o dropPeerOverride) Override(desired *api.StateResponse) *api.StateResponse {
+	out := *desired
+	out.Peers = nil
+	for _, p := range desired.Peers {
+		if p.ID != o.id {
+			out.Peers = append(out.Peers, p)
+		}
+	}
+	return &out
+}
+
+func TestReconciler_StateOverride(t *testing.T) {
+	fetcher := &mockFetcher{
+		fetchFunc: func(_ context.Context, _ string) (*api.StateResponse, error) {
+			return &api.StateResponse{Peers: []api.Peer{{ID: "peer-a"}, {ID: "peer-b"}}}, nil
+		},
+	}
+	r := NewReconciler(fetcher, Config{}, discardLogger())
+	r.SetStateOverride(dropPeerOverride{id: "peer-b"})
+
+	var added []string
+	r.RegisterHandler(func(_ context.Context, desired *api.StateResponse, diff StateDiff) error {
+		for _, p := range diff.PeersToAdd {
+			added = append(added, p.ID)
+		}
+		return nil
+	})
+
+	r.runCycle(context.Background(), "node-1", TriggerManual)
+
+	if len(added) != 1 || added[0] != "peer-a" {
+		t.Errorf("PeersToAdd = %v, want [peer-a]", added)
+	}
+	if applied := r.Applied(); applied == nil || len(applied.Peers) != 1 {
+		t.Errorf("Applied() = %+v, want the overridden state", applied)
+	}
+
+	// The overridden state is in the snapshot, so the next cycle finds no drift.
+	added = nil
+	r.runCycle(context.Background(), "node-1", TriggerManual)
+	if len(added) != 0 {
+		t.Errorf("second cycle PeersToAdd = %v, want none", added)
+	}
+}
+
+func TestStateOverrides(t *testing.T) {
+	desired := &api.StateResponse{Peers: []api.Peer{{ID: "peer-a"}, {ID: "peer-b"}, {ID: "peer-c"}}}
+	got := StateOverrides{dropPeerOverride{id: "peer-a"}, dropPeerOverride{id: "peer-c"}}.Override(desired)
+
+	if len(got.Peers) != 1 || got.Peers[0].ID != "peer-b" {
+		t.Errorf("Peers = %+v, want [peer-b]", got.Peers)
+	}
+	if len(desired.Peers) != 3 {
+		t.Errorf("desired modified: %+v", desired.Peers)
+	}
+}
@@ -0,0 +1,100 @@
+package reconcile
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+func TestReconciler_PauseSkipsCycles(t *testing.T) {
+	fetcher := &mockFetcher{
+		fetchFunc: func(_ context.Context, _ string) (*api.StateResponse, error) {
+			return &api.StateResponse{}, nil
+		},
+	}
+	r := NewReconciler(fetcher, Config{Interval: 10 * time.Second}, discardLogger())
+
+	until := r.Pause(time.Hour)
+	if got := r.PausedUntil(); !got.Equal(until) || time.Until(until) < 59*time.Minute {
+		t.Fatalf("PausedUntil() = %v, Pause returned %v", got, until)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Run(ctx, "node-1")
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	r.TriggerReconcile()
+	time.Sleep(50 * time.Millisecond)
+	if n := fetcher.getFetchCount(); n != 0 {
+		t.Errorf("FetchState called %d times while paused, want 0", n)
+	}
+	if r.LastCycle().IsZero() {
+		t.Error("LastCycle() is zero; paused cycles should still count as ticks")
+	}
+
+	if !r.Resume() {
+		t.Error("Resume() = false, want true")
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	if n := fetcher.getFetchCount(); n != 1 {
+		t.Errorf("FetchState called %d times after resume, want 1", n)
+	}
+	if !r.PausedUntil().IsZero() {
+		t.Errorf("PausedUntil() = %v after Resume, want zero", r.PausedUntil())
+	}
+	if r.Resume() {
+		t.Error("second Resume() = true, want false")
+	}
+}
+
+func TestReconciler_PauseExpires(t *testing.T) {
+	fetcher := &mockFetcher{
+		fetchFunc: func(_ context.Context, _ string) (*api.StateResponse, error) {
+			return &api.StateResponse{}, nil
+		},
+	}
+	r := NewReconciler(fetcher, Config{Interval: 10 * time.Second}, discardLogger())
+	r.Pause(100 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Run(ctx, "node-1")
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if n := fetcher.getFetchCount(); n != 0 {
+		t.Errorf("FetchState called %d times while paused, want 0", n)
+	}
+	time.Sleep(150 * time.Millisecond)
+	cancel()
+	<-done
+
+	if n := fetcher.getFetchCount(); n != 1 {
+		t.Errorf("FetchState called %d times after the pause expired, want 1", n)
+	}
+	if !r.PausedUntil().IsZero() {
+		t.Errorf("PausedUntil() = %v after expiry, want zero", r.PausedUntil())
+	}
+}
+
+func TestReconciler_PauseReplacesDeadline(t *testing.T) {
+	r := NewReconciler(&mockFetcher{}, Config{}, discardLogger())
+
+	r.Pause(50 * time.Millisecond)
+	until := r.Pause(time.Hour)
+	time.Sleep(100 * time.Millisecond)
+
+	if got := r.PausedUntil(); !got.Equal(until) {
+		t.Errorf("PausedUntil() = %v, want %v; the first pause's timer must not end the second", got, until)
+	}
+	r.Resume()
+}
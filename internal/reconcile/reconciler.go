@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"log/slog"
 	"runtime/debug"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/plexsphere/plexd/internal/api"
@@ -20,35 +22,118 @@ type StateFetcher interface {
 // ReconcileHandler is a function invoked when drift is detected.
 type ReconcileHandler func(ctx context.Context, desired *api.StateResponse, diff StateDiff) error
 
+// DriftReporter is implemented by handler errors that describe the parts of
+// the desired state a handler rejected, such as validation failures. The
+// reconciler adds their corrections to the drift report of the cycle, so the
+// control plane learns why the state was not applied.
+type DriftReporter interface {
+	DriftCorrections() []api.DriftCorrection
+}
+
+// namedHandler pairs a ReconcileHandler with the name used for health tracking.
+type namedHandler struct {
+	name    string
+	handler ReconcileHandler
+}
+
 // Reconciler periodically compares desired state against a local snapshot
-// and invokes registered handlers to correct drift.
+// and invokes registered handlers to correct drift. Handlers that fail
+// repeatedly are retried with exponential backoff and, after
+// cfg.CircuitBreakerThreshold consecutive failures, skipped by a circuit
+// breaker until cfg.CircuitOpenDuration has elapsed.
 type Reconciler struct {
 	client    StateFetcher
 	cfg       Config
 	logger    *slog.Logger
 	snapshot  *stateSnapshot
-	handlers  []ReconcileHandler
+	handlers  []namedHandler
+	checkers  []namedChecker
+	override  StateOverride
+	health    *healthTracker
+	history   *cycleHistory
 	triggerCh chan struct{}
+	tracker   api.CauseTracker
+	now       func() time.Time
+
+	// interval holds the current cycle interval; SetInterval signals
+	// intervalCh so that Run resets its ticker.
+	interval   atomic.Int64
+	intervalCh chan struct{}
+
+	// lastCycle holds the start of the most recent cycle in unix nanoseconds.
+	lastCycle atomic.Int64
+
+	// initialDone is set once the initial cycle of Run has finished.
+	initialDone atomic.Bool
+
+	// applied is the desired state of the most recent cycle that left the
+	// snapshot in sync, or nil if that state is unknown. Drift checkers
+	// compare the system against it.
+	applied atomic.Pointer[api.StateResponse]
+
+	// pause is set by Pause; the end of a pause signals resumeCh so that
+	// Run starts a cycle.
+	pause    pauseState
+	resumeCh chan struct{}
 }
 
 // NewReconciler creates a new Reconciler with the given configuration.
 // Config defaults are applied automatically.
 func NewReconciler(client StateFetcher, cfg Config, logger *slog.Logger) *Reconciler {
 	cfg.ApplyDefaults()
-	return &Reconciler{
-		client:    client,
-		cfg:       cfg,
-		logger:    logger,
-		snapshot:  NewStateSnapshot(),
-		triggerCh: make(chan struct{}, 1),
+	r := &Reconciler{
+		client:     client,
+		cfg:        cfg,
+		logger:     logger,
+		snapshot:   NewStateSnapshot(),
+		health:     newHealthTracker(cfg),
+		history:    newCycleHistory(cfg.HistorySize),
+		triggerCh:  make(chan struct{}, 1),
+		now:        time.Now,
+		intervalCh: make(chan struct{}, 1),
+		resumeCh:   make(chan struct{}, 1),
 	}
+	r.interval.Store(int64(cfg.Interval))
+	return r
 }
 
 // RegisterHandler adds a reconciliation handler invoked on drift detection.
+// The handler is named "handler-<index>" for health reporting; use
+// RegisterNamedHandler to give it a descriptive name.
 // Handlers are called in registration order.
 // RegisterHandler must be called before Run; it is not safe for concurrent use.
 func (r *Reconciler) RegisterHandler(handler ReconcileHandler) {
-	r.handlers = append(r.handlers, handler)
+	r.RegisterNamedHandler(fmt.Sprintf("handler-%d", len(r.handlers)), handler)
+}
+
+// RegisterNamedHandler adds a named reconciliation handler invoked on drift
+// detection. The name identifies the handler in logs and DegradedHandlers.
+// RegisterNamedHandler must be called before Run; it is not safe for concurrent use.
+func (r *Reconciler) RegisterNamedHandler(name string, handler ReconcileHandler) {
+	r.handlers = append(r.handlers, namedHandler{name: name, handler: handler})
+}
+
+// DegradedHandlers returns the health of every handler that is currently
+// failing, in backoff, or has an open circuit. It returns an empty slice
+// when all handlers are healthy. Safe for concurrent use.
+func (r *Reconciler) DegradedHandlers() []HandlerHealth {
+	return r.health.degraded()
+}
+
+// SetCauseTracker sets the tracker told about every cycle and drift check
+// while its handlers run. Must be called before Run.
+func (r *Reconciler) SetCauseTracker(t api.CauseTracker) {
+	r.tracker = t
+}
+
+// withCause returns ctx carrying c, and tells the tracker about c until the
+// returned function is called.
+func (r *Reconciler) withCause(ctx context.Context, c api.Cause) (context.Context, func()) {
+	done := func() {}
+	if r.tracker != nil {
+		done = r.tracker.Track(c)
+	}
+	return api.WithCause(ctx, c), done
 }
 
 // TriggerReconcile requests an immediate reconciliation cycle.
@@ -61,9 +146,23 @@ func (r *Reconciler) TriggerReconcile() {
 	}
 }
 
+// SetInterval changes the cycle interval of a running reconciler, for example
+// after a configuration reload. The next periodic cycle runs one interval
+// after the change. Non-positive values are ignored. Safe for concurrent use.
+func (r *Reconciler) SetInterval(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	r.interval.Store(int64(d))
+	select {
+	case r.intervalCh <- struct{}{}:
+	default:
+	}
+}
+
 // Run starts the reconciliation loop. It blocks until ctx is cancelled.
 // The first cycle runs immediately; subsequent cycles run at cfg.Interval
-// or when TriggerReconcile is called.
+// (or the interval set by SetInterval) or when TriggerReconcile is called.
 func (r *Reconciler) Run(ctx context.Context, nodeID string) error {
 	if r.client == nil {
 		return errors.New("reconcile: client is nil")
@@ -75,15 +174,24 @@ func (r *Reconciler) Run(ctx context.Context, nodeID string) error {
 	r.logger.Info("reconciler started",
 		"component", "reconcile",
 		"node_id", nodeID,
-		"interval", r.cfg.Interval,
+		"interval", r.currentInterval(),
 	)
 
 	// First cycle runs immediately.
-	r.runCycle(ctx, nodeID)
+	r.runCycle(ctx, nodeID, TriggerInitial)
+	r.initialDone.Store(true)
 
-	ticker := time.NewTicker(r.cfg.Interval)
+	ticker := time.NewTicker(r.currentInterval())
 	defer ticker.Stop()
 
+	// Drift checks run on their own ticker, only if checkers are registered.
+	var driftTick <-chan time.Time
+	if len(r.checkers) > 0 {
+		driftTicker := time.NewTicker(r.cfg.DriftCheckInterval)
+		defer driftTicker.Stop()
+		driftTick = driftTicker.C
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -94,37 +202,102 @@ func (r *Reconciler) Run(ctx context.Context, nodeID string) error {
 			return ctx.Err()
 
 		case <-ticker.C:
-			r.runCycle(ctx, nodeID)
+			r.runCycle(ctx, nodeID, TriggerInterval)
+
+		case <-r.intervalCh:
+			ticker.Reset(r.currentInterval())
 
 		case <-r.triggerCh:
-			r.runCycle(ctx, nodeID)
+			r.runCycle(ctx, nodeID, TriggerManual)
 			// Reset the ticker after a triggered cycle.
-			ticker.Reset(r.cfg.Interval)
+			ticker.Reset(r.currentInterval())
+
+		case <-r.resumeCh:
+			r.runCycle(ctx, nodeID, TriggerResume)
+			ticker.Reset(r.currentInterval())
+
+		case <-driftTick:
+			r.runDriftCheck(ctx, nodeID)
 		}
 	}
 }
 
+func (r *Reconciler) currentInterval() time.Duration {
+	return time.Duration(r.interval.Load())
+}
+
+// Interval returns the current cycle interval. Safe for concurrent use.
+func (r *Reconciler) Interval() time.Duration {
+	return r.currentInterval()
+}
+
+// LastCycle returns when the most recent reconciliation cycle started, or
+// the zero time before the first cycle. A cycle starts even when fetching
+// state fails, so LastCycle tracks whether the loop is ticking rather than
+// whether the control plane is reachable. Safe for concurrent use.
+func (r *Reconciler) LastCycle() time.Time {
+	ns := r.lastCycle.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// InitialCycleDone reports whether the first cycle of Run has finished,
+// whether or not it could fetch state. Safe for concurrent use.
+func (r *Reconciler) InitialCycleDone() bool {
+	return r.initialDone.Load()
+}
+
+// Applied returns the desired state of the most recent cycle that left the
+// snapshot in sync, or nil if no cycle has. Safe for concurrent use.
+func (r *Reconciler) Applied() *api.StateResponse {
+	return r.applied.Load()
+}
+
 // runCycle performs a single reconciliation cycle: fetch → diff → handle → report → update snapshot.
-func (r *Reconciler) runCycle(ctx context.Context, nodeID string) {
+// Cycles that fail to fetch state or find drift are recorded in the history.
+func (r *Reconciler) runCycle(ctx context.Context, nodeID, trigger string) {
 	start := time.Now()
+	r.lastCycle.Store(start.UnixNano())
+
+	// A paused cycle still counts as a tick for LastCycle.
+	if until := r.PausedUntil(); !until.IsZero() {
+		r.logger.Debug("reconcile cycle skipped: paused",
+			"component", "reconcile",
+			"node_id", nodeID,
+			"trigger", trigger,
+			"until", until,
+		)
+		return
+	}
 
 	desired, err := r.client.FetchState(ctx, nodeID)
+	if err == nil && r.override != nil {
+		desired = r.override.Override(desired)
+	}
 	if err != nil {
-		// Don't log if the context was cancelled (graceful shutdown).
+		// Don't log or record if the context was cancelled (graceful shutdown).
 		if ctx.Err() == nil {
 			r.logger.Warn("FetchState failed",
 				"component", "reconcile",
 				"node_id", nodeID,
 				"error", err,
 			)
+			r.history.add(CycleRecord{
+				Start:        start,
+				DurationNano: time.Since(start).Nanoseconds(),
+				Trigger:      trigger,
+				Error:        err.Error(),
+			})
 		}
 		return
 	}
 
-	current := r.snapshot.Get()
-	diff := ComputeDiff(desired, &current)
+	diff := r.snapshot.Diff(desired)
 
 	if diff.IsEmpty() {
+		r.applied.Store(desired)
 		r.logger.Debug("no drift detected",
 			"component", "reconcile",
 			"node_id", nodeID,
@@ -133,11 +306,15 @@ func (r *Reconciler) runCycle(ctx context.Context, nodeID string) {
 		return
 	}
 
-	// Invoke all handlers, tracking which had errors.
-	handlerFailed := r.invokeHandlers(ctx, desired, diff)
+	// Invoke all handlers, tracking which had errors. Their changes are
+	// attributed to this cycle.
+	hctx, done := r.withCause(ctx, api.Cause{Type: api.CauseReconcile, ID: trigger})
+	handlerFailed, results, rejected := r.invokeHandlers(hctx, desired, diff)
+	done()
 
 	// Build and report drift.
 	report := BuildDriftReport(diff)
+	report.Corrections = append(report.Corrections, rejected...)
 	if err := r.client.ReportDrift(ctx, nodeID, report); err != nil {
 		if ctx.Err() == nil {
 			r.logger.Warn("ReportDrift failed",
@@ -151,8 +328,22 @@ func (r *Reconciler) runCycle(ctx context.Context, nodeID string) {
 	// Update snapshot only if no handler failed.
 	if !handlerFailed {
 		r.snapshot.Update(desired)
+		r.applied.Store(desired)
+	} else {
+		r.applied.Store(nil)
 	}
 
+	summary := summarizeDiff(diff)
+	r.history.add(CycleRecord{
+		Start:           start,
+		DurationNano:    time.Since(start).Nanoseconds(),
+		Trigger:         trigger,
+		Diff:            &summary,
+		Handlers:        results,
+		Corrections:     report.Corrections,
+		SnapshotUpdated: !handlerFailed,
+	})
+
 	r.logger.Info("reconciliation cycle completed",
 		"component", "reconcile",
 		"node_id", nodeID,
@@ -163,20 +354,50 @@ func (r *Reconciler) runCycle(ctx context.Context, nodeID string) {
 }
 
 // invokeHandlers calls each registered handler with panic recovery.
-// Returns true if any handler returned an error or panicked.
-func (r *Reconciler) invokeHandlers(ctx context.Context, desired *api.StateResponse, diff StateDiff) bool {
+// Handlers in backoff or with an open circuit are skipped.
+// Returns true if any handler returned an error, panicked, or was skipped,
+// the outcome of every handler, and the corrections of failed handlers whose
+// error is a DriftReporter.
+func (r *Reconciler) invokeHandlers(ctx context.Context, desired *api.StateResponse, diff StateDiff) (bool, []HandlerResult, []api.DriftCorrection) {
 	anyFailed := false
-	for i, handler := range r.handlers {
-		if err := r.safeInvoke(ctx, handler, desired, diff); err != nil {
+	results := make([]HandlerResult, 0, len(r.handlers))
+	var rejected []api.DriftCorrection
+	for i, h := range r.handlers {
+		if !r.health.allow(h.name, r.now()) {
+			r.logger.Debug("handler skipped (backoff)",
+				"component", "reconcile",
+				"handler", h.name,
+				"handler_index", i,
+			)
+			anyFailed = true
+			results = append(results, HandlerResult{Name: h.name, Status: HandlerSkipped})
+			continue
+		}
+		if err := r.safeInvoke(ctx, h.handler, desired, diff); err != nil {
+			hh := r.health.recordFailure(h.name, err, r.now())
 			r.logger.Error("handler failed",
 				"component", "reconcile",
+				"handler", h.name,
 				"handler_index", i,
+				"consecutive_failures", hh.ConsecutiveFailures,
+				"next_attempt", hh.NextAttempt,
+				"circuit_open", hh.CircuitOpen,
 				"error", err,
 			)
 			anyFailed = true
+			var dr DriftReporter
+			if errors.As(err, &dr) {
+				rejected = append(rejected, dr.DriftCorrections()...)
+			}
+			// Keep the first line only: a panic's error carries its stack.
+			msg, _, _ := strings.Cut(err.Error(), "\n")
+			results = append(results, HandlerResult{Name: h.name, Status: HandlerFailed, Error: msg})
+			continue
 		}
+		r.health.recordSuccess(h.name)
+		results = append(results, HandlerResult{Name: h.name, Status: HandlerOK})
 	}
-	return anyFailed
+	return anyFailed, results, rejected
 }
 
 // safeInvoke calls a handler with panic recovery.
@@ -3,6 +3,7 @@ package reconcile
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"sync"
 	"sync/atomic"
@@ -235,7 +236,7 @@ func TestReconciler_HandlerPanicRecovered(t *testing.T) {
 		},
 	}
 
-	r := NewReconciler(fetcher, Config{Interval: 20 * time.Millisecond}, discardLogger())
+	r := NewReconciler(fetcher, Config{Interval: 20 * time.Millisecond, BackoffBase: time.Millisecond, BackoffMax: time.Millisecond}, discardLogger())
 
 	var panicHandlerCalls, safeHandlerCalls atomic.Int64
 
@@ -312,6 +313,49 @@ func TestReconciler_DriftReported(t *testing.T) {
 	}
 }
 
+// rejectedError is a handler error that is a DriftReporter.
+type rejectedError struct{}
+
+func (rejectedError) Error() string { return "rejected" }
+
+func (rejectedError) DriftCorrections() []api.DriftCorrection {
+	return []api.DriftCorrection{{Type: "validation_failed", Detail: "tunnel t-1"}}
+}
+
+func TestReconciler_HandlerDriftCorrectionsReported(t *testing.T) {
+	fetcher := &mockFetcher{
+		fetchFunc: func(_ context.Context, _ string) (*api.StateResponse, error) {
+			return &api.StateResponse{
+				Peers: []api.Peer{{ID: "p1", MeshIP: "10.0.0.1"}},
+			}, nil
+		},
+	}
+
+	r := NewReconciler(fetcher, Config{Interval: time.Hour}, discardLogger())
+	r.RegisterHandler(func(_ context.Context, _ *api.StateResponse, _ StateDiff) error {
+		return fmt.Errorf("apply: %w", rejectedError{})
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Run(ctx, "node-1")
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	<-done
+
+	report := fetcher.getLastDrift()
+	if report == nil {
+		t.Fatal("no drift report received")
+	}
+	n := len(report.Corrections)
+	if n < 2 || report.Corrections[n-1].Type != "validation_failed" || report.Corrections[n-1].Detail != "tunnel t-1" {
+		t.Errorf("corrections = %v, want peer_added followed by validation_failed", report.Corrections)
+	}
+}
+
 func TestReconciler_NoDriftNoReport(t *testing.T) {
 	fetcher := &mockFetcher{
 		fetchFunc: func(_ context.Context, _ string) (*api.StateResponse, error) {
@@ -412,6 +456,83 @@ func TestReconciler_TriggerReconcile(t *testing.T) {
 	}
 }
 
+func TestReconciler_SetInterval(t *testing.T) {
+	fetchCh := make(chan struct{}, 10)
+	fetcher := &mockFetcher{
+		fetchFunc: func(_ context.Context, _ string) (*api.StateResponse, error) {
+			fetchCh <- struct{}{}
+			return &api.StateResponse{}, nil
+		},
+	}
+
+	r := NewReconciler(fetcher, Config{Interval: time.Hour}, discardLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Run(ctx, "node-1")
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Wait for the initial fetch.
+	<-fetchCh
+
+	// Shortening the interval must take effect without waiting an hour.
+	r.SetInterval(20 * time.Millisecond)
+	select {
+	case <-fetchCh:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no cycle after SetInterval")
+	}
+}
+
+func TestReconciler_LastCycle(t *testing.T) {
+	fetcher := &mockFetcher{
+		fetchFunc: func(_ context.Context, _ string) (*api.StateResponse, error) {
+			return nil, errors.New("control plane unreachable")
+		},
+	}
+	r := NewReconciler(fetcher, Config{Interval: time.Hour}, discardLogger())
+	if !r.LastCycle().IsZero() {
+		t.Fatal("LastCycle() before Run is not zero")
+	}
+	if r.InitialCycleDone() {
+		t.Fatal("InitialCycleDone() before Run = true")
+	}
+	if r.Interval() != time.Hour {
+		t.Errorf("Interval() = %v, want 1h", r.Interval())
+	}
+
+	before := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Run(ctx, "node-1")
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// A failed fetch still counts as a cycle.
+	deadline := time.Now().Add(2 * time.Second)
+	for r.LastCycle().IsZero() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if last := r.LastCycle(); last.Before(before) {
+		t.Errorf("LastCycle() = %v, want >= %v", last, before)
+	}
+	for !r.InitialCycleDone() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !r.InitialCycleDone() {
+		t.Error("InitialCycleDone() = false after a failed initial cycle")
+	}
+}
+
 func TestReconciler_TriggerCoalesced(t *testing.T) {
 	var fetchCh = make(chan struct{}, 10)
 	fetcher := &mockFetcher{
@@ -496,7 +617,7 @@ func TestReconciler_SnapshotNotUpdatedOnHandlerError(t *testing.T) {
 		},
 	}
 
-	r := NewReconciler(fetcher, Config{Interval: 20 * time.Millisecond}, discardLogger())
+	r := NewReconciler(fetcher, Config{Interval: 20 * time.Millisecond, BackoffBase: time.Millisecond, BackoffMax: time.Millisecond}, discardLogger())
 
 	var handlerCalls atomic.Int64
 	r.RegisterHandler(func(_ context.Context, _ *api.StateResponse, _ StateDiff) error {
@@ -663,3 +784,180 @@ func TestReconciler_RunRejectsNilClient(t *testing.T) {
 		t.Fatal("Run() = nil, want error for nil client")
 	}
 }
+
+func TestReconciler_FailingHandlerBacksOff(t *testing.T) {
+	fetcher := &mockFetcher{
+		fetchFunc: func(_ context.Context, _ string) (*api.StateResponse, error) {
+			return &api.StateResponse{
+				Peers: []api.Peer{{ID: "p1", MeshIP: "10.0.0.1"}},
+			}, nil
+		},
+	}
+
+	r := NewReconciler(fetcher, Config{Interval: 10 * time.Millisecond, BackoffBase: time.Hour}, discardLogger())
+
+	var failing, healthy atomic.Int64
+	r.RegisterNamedHandler("routes", func(_ context.Context, _ *api.StateResponse, _ StateDiff) error {
+		failing.Add(1)
+		return errors.New("route programming failed")
+	})
+	r.RegisterNamedHandler("nodeapi", func(_ context.Context, _ *api.StateResponse, _ StateDiff) error {
+		healthy.Add(1)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Run(ctx, "node-1")
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	<-done
+
+	if got := failing.Load(); got != 1 {
+		t.Errorf("failing handler called %d times, want 1 (backoff should skip retries)", got)
+	}
+	if got := healthy.Load(); got < 3 {
+		t.Errorf("healthy handler called %d times, want >= 3", got)
+	}
+
+	degraded := r.DegradedHandlers()
+	if len(degraded) != 1 {
+		t.Fatalf("DegradedHandlers() len = %d, want 1", len(degraded))
+	}
+	if degraded[0].Name != "routes" {
+		t.Errorf("degraded handler name = %q, want %q", degraded[0].Name, "routes")
+	}
+	if degraded[0].LastError != "route programming failed" {
+		t.Errorf("LastError = %q, want %q", degraded[0].LastError, "route programming failed")
+	}
+}
+
+func TestReconciler_CircuitOpensAfterThreshold(t *testing.T) {
+	fetcher := &mockFetcher{
+		fetchFunc: func(_ context.Context, _ string) (*api.StateResponse, error) {
+			return &api.StateResponse{
+				Peers: []api.Peer{{ID: "p1", MeshIP: "10.0.0.1"}},
+			}, nil
+		},
+	}
+
+	cfg := Config{
+		Interval:                10 * time.Millisecond,
+		BackoffBase:             time.Millisecond,
+		BackoffMax:              time.Millisecond,
+		CircuitBreakerThreshold: 3,
+		CircuitOpenDuration:     time.Hour,
+	}
+	r := NewReconciler(fetcher, cfg, discardLogger())
+
+	var calls atomic.Int64
+	r.RegisterHandler(func(_ context.Context, _ *api.StateResponse, _ StateDiff) error {
+		calls.Add(1)
+		return errors.New("boom")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Run(ctx, "node-1")
+	}()
+
+	time.Sleep(150 * time.Millisecond)
+	cancel()
+	<-done
+
+	if got := calls.Load(); got != 3 {
+		t.Errorf("handler called %d times, want 3 (circuit should open)", got)
+	}
+	degraded := r.DegradedHandlers()
+	if len(degraded) != 1 {
+		t.Fatalf("DegradedHandlers() len = %d, want 1", len(degraded))
+	}
+	if !degraded[0].CircuitOpen {
+		t.Error("CircuitOpen = false, want true")
+	}
+	if degraded[0].Name != "handler-0" {
+		t.Errorf("Name = %q, want %q", degraded[0].Name, "handler-0")
+	}
+}
+
+func TestReconciler_HandlerRecoveryClearsHealth(t *testing.T) {
+	fetcher := &mockFetcher{
+		fetchFunc: func(_ context.Context, _ string) (*api.StateResponse, error) {
+			return &api.StateResponse{
+				Peers: []api.Peer{{ID: "p1", MeshIP: "10.0.0.1"}},
+			}, nil
+		},
+	}
+
+	cfg := Config{
+		Interval:    10 * time.Millisecond,
+		BackoffBase: time.Millisecond,
+		BackoffMax:  time.Millisecond,
+	}
+	r := NewReconciler(fetcher, cfg, discardLogger())
+
+	var calls atomic.Int64
+	r.RegisterNamedHandler("flaky", func(_ context.Context, _ *api.StateResponse, _ StateDiff) error {
+		if calls.Add(1) == 1 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Run(ctx, "node-1")
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	<-done
+
+	if calls.Load() < 2 {
+		t.Fatalf("handler called %d times, want >= 2", calls.Load())
+	}
+	if degraded := r.DegradedHandlers(); len(degraded) != 0 {
+		t.Errorf("DegradedHandlers() = %+v, want empty after recovery", degraded)
+	}
+}
+
+func TestReconciler_HandlerCause(t *testing.T) {
+	fetcher := &mockFetcher{
+		fetchFunc: func(_ context.Context, _ string) (*api.StateResponse, error) {
+			return &api.StateResponse{Peers: []api.Peer{{ID: "p1", MeshIP: "10.0.0.1"}}}, nil
+		},
+	}
+	r := NewReconciler(fetcher, Config{Interval: time.Hour}, discardLogger())
+
+	var mu sync.Mutex
+	var got api.Cause
+	var ok bool
+	r.RegisterHandler(func(ctx context.Context, _ *api.StateResponse, _ StateDiff) error {
+		mu.Lock()
+		got, ok = api.CauseFromContext(ctx)
+		mu.Unlock()
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Run(ctx, "node-1")
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := api.Cause{Type: api.CauseReconcile, ID: TriggerInitial}
+	if !ok || got != want {
+		t.Errorf("handler cause = %+v (%v), want %+v", got, ok, want)
+	}
+}
@@ -18,11 +18,30 @@ type stateSnapshot struct {
 	metadata   map[string]string
 	data       []api.DataEntry
 	secretRefs []api.SecretRef
+
+	// The peer index lets Diff compare desired peers without copying the
+	// snapshot. It is rebuilt whenever peers are replaced, reusing its map.
+	fp          fingerprinter
+	peerIndex   map[string]peerEntry
+	peersSum    peerFingerprint   // sum of all peer fingerprints
+	peersUnique bool              // no two peers share an ID
+	diffGen     uint64            // generation of the latest Diff
+	prints      []peerFingerprint // scratch buffer reused by Diff
+}
+
+// peerEntry is the peer index entry of one snapshot peer.
+type peerEntry struct {
+	fp   peerFingerprint
+	seen uint64 // diffGen of the latest Diff that matched the peer
 }
 
 // NewStateSnapshot returns a new, empty snapshot.
 func NewStateSnapshot() *stateSnapshot {
-	return &stateSnapshot{}
+	return &stateSnapshot{
+		fp:          newFingerprinter(),
+		peerIndex:   make(map[string]peerEntry),
+		peersUnique: true,
+	}
 }
 
 // Get returns a deep copy of the current snapshot as an api.StateResponse.
@@ -48,6 +67,7 @@ func (s *stateSnapshot) Update(desired *api.StateResponse) {
 	defer s.mu.Unlock()
 
 	s.peers = copyPeers(desired.Peers)
+	s.indexPeers()
 	s.policies = copyPolicies(desired.Policies)
 	s.signingKeys = copySigningKeys(desired.SigningKeys)
 	s.metadata = copyMetadata(desired.Metadata)
@@ -66,6 +86,7 @@ func (s *stateSnapshot) UpdatePartial(desired *api.StateResponse, categories ...
 		switch cat {
 		case "peers":
 			s.peers = copyPeers(desired.Peers)
+			s.indexPeers()
 		case "policies":
 			s.policies = copyPolicies(desired.Policies)
 		case "signing_keys":
@@ -80,6 +101,90 @@ func (s *stateSnapshot) UpdatePartial(desired *api.StateResponse, categories ...
 	}
 }
 
+// indexPeers rebuilds the peer index from s.peers. When two peers share an
+// ID the last one is indexed, as in ComputeDiff. Callers must hold s.mu.
+func (s *stateSnapshot) indexPeers() {
+	clear(s.peerIndex)
+	s.peersSum = peerFingerprint{}
+	for i := range s.peers {
+		fp := s.fp.peer(&s.peers[i])
+		s.peerIndex[s.peers[i].ID] = peerEntry{fp: fp}
+		s.peersSum = s.peersSum.add(fp)
+	}
+	s.peersUnique = len(s.peerIndex) == len(s.peers)
+}
+
+// Diff returns the drift between desired and the snapshot. The result is
+// the same as ComputeDiff(desired, &current) with current = s.Get(), but
+// the snapshot is not copied, and peers are compared by fingerprint using
+// the index built by Update: an unchanged peer costs one hash and one map
+// lookup, and an unchanged peer list costs no lookups at all.
+func (s *stateSnapshot) Diff(desired *api.StateResponse) StateDiff {
+	var diff StateDiff
+	if desired == nil {
+		return diff
+	}
+
+	// Diff writes the scratch buffer and the seen marks of the peer index.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.diffPeers(desired.Peers, &diff)
+	diffPolicies(desired.Policies, s.policies, &diff)
+	diffSigningKeys(desired.SigningKeys, s.signingKeys, &diff)
+	diffMetadata(desired.Metadata, s.metadata, &diff)
+	diffData(desired.Data, s.data, &diff)
+	diffSecretRefs(desired.SecretRefs, s.secretRefs, &diff)
+	return diff
+}
+
+// diffPeers is diffPeers of ComputeDiff against the peer index. Callers
+// must hold s.mu.
+func (s *stateSnapshot) diffPeers(desired []api.Peer, diff *StateDiff) {
+	prints := s.prints[:0]
+	var sum peerFingerprint
+	for i := range desired {
+		fp := s.fp.peer(&desired[i])
+		prints = append(prints, fp)
+		sum = sum.add(fp)
+	}
+	s.prints = prints
+
+	// Fingerprints include the peer ID, so an equal sum over the same
+	// number of peers means the peer list is unchanged.
+	if s.peersUnique && len(desired) == len(s.peers) && sum == s.peersSum {
+		return
+	}
+
+	s.diffGen++
+	matched := 0
+	for i := range desired {
+		dp := &desired[i]
+		e, ok := s.peerIndex[dp.ID]
+		if !ok {
+			diff.PeersToAdd = append(diff.PeersToAdd, *dp)
+			continue
+		}
+		if e.seen != s.diffGen {
+			e.seen = s.diffGen
+			s.peerIndex[dp.ID] = e
+			matched++
+		}
+		if e.fp != prints[i] {
+			diff.PeersToUpdate = append(diff.PeersToUpdate, *dp)
+		}
+	}
+
+	if matched == len(s.peerIndex) {
+		return
+	}
+	for i := range s.peers {
+		if s.peerIndex[s.peers[i].ID].seen != s.diffGen {
+			diff.PeersToRemove = append(diff.PeersToRemove, s.peers[i].ID)
+		}
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Deep-copy helpers
 // ---------------------------------------------------------------------------
@@ -2,6 +2,7 @@ package reconcile
 
 import (
 	"encoding/json"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -263,3 +264,119 @@ func TestStateSnapshot_ConcurrentAccess(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestStateSnapshot_DiffMatchesComputeDiff(t *testing.T) {
+	base := func() []api.Peer {
+		return []api.Peer{
+			{ID: "p1", PublicKey: "pk1", Endpoint: "1.2.3.4:51820", AllowedIPs: []string{"10.0.0.1/32", "10.1.0.0/24"}},
+			{ID: "p2", PublicKey: "pk2", MeshIP: "10.0.0.2", PSK: "psk2"},
+			{ID: "p3", PublicKey: "pk3", AllowedIPs: []string{"10.0.0.3/32"}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		current []api.Peer
+		desired func([]api.Peer) []api.Peer
+	}{
+		{"unchanged", base(), func(p []api.Peer) []api.Peer { return p }},
+		{"reordered peers", base(), func(p []api.Peer) []api.Peer { return []api.Peer{p[2], p[0], p[1]} }},
+		{"reordered AllowedIPs", base(), func(p []api.Peer) []api.Peer {
+			p[0].AllowedIPs = []string{"10.1.0.0/24", "10.0.0.1/32"}
+			return p
+		}},
+		{"nil and empty AllowedIPs", base(), func(p []api.Peer) []api.Peer {
+			p[1].AllowedIPs = []string{}
+			return p
+		}},
+		{"updated", base(), func(p []api.Peer) []api.Peer {
+			p[0].Endpoint = "5.6.7.8:51820"
+			p[1].PSK = "psk2-rotated"
+			p[2].AllowedIPs = []string{"10.0.0.3/32", "10.0.0.3/32"}
+			return p
+		}},
+		{"fields not swapped", base(), func(p []api.Peer) []api.Peer {
+			p[1].MeshIP, p[1].PSK = p[1].PSK, p[1].MeshIP
+			return p
+		}},
+		{"added and removed", base(), func(p []api.Peer) []api.Peer {
+			return []api.Peer{p[0], {ID: "p4", PublicKey: "pk4"}}
+		}},
+		{"removed only", base(), func(p []api.Peer) []api.Peer { return p[1:] }},
+		{"all removed", base(), func([]api.Peer) []api.Peer { return nil }},
+		{"from empty", nil, func(p []api.Peer) []api.Peer { return p }},
+		{"duplicate desired IDs", base(), func(p []api.Peer) []api.Peer {
+			dup := p[0]
+			dup.Endpoint = "5.6.7.8:51820"
+			return []api.Peer{p[0], dup, p[0]}
+		}},
+		{"duplicate current IDs", append(base(), api.Peer{ID: "p1", PublicKey: "pk1-old"}), func(p []api.Peer) []api.Peer {
+			return p[:3]
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := &api.StateResponse{Peers: tt.current, Metadata: map[string]string{"env": "prod"}}
+			desired := &api.StateResponse{Peers: tt.desired(base()), Metadata: map[string]string{"env": "staging"}}
+
+			snap := NewStateSnapshot()
+			snap.Update(current)
+			want := ComputeDiff(desired, current)
+			// Diff twice: the second call must not see marks of the first.
+			for range 2 {
+				if got := snap.Diff(desired); !reflect.DeepEqual(got, want) {
+					t.Fatalf("Diff() = %+v\nComputeDiff() = %+v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStateSnapshot_DiffAfterUpdate(t *testing.T) {
+	snap := NewStateSnapshot()
+	state := sampleState()
+	snap.Update(state)
+	if diff := snap.Diff(state); !diff.IsEmpty() {
+		t.Fatalf("Diff() against own state = %+v, want empty", diff)
+	}
+
+	changed := sampleState()
+	changed.Peers[0].Endpoint = "5.6.7.8:51820"
+	if diff := snap.Diff(changed); len(diff.PeersToUpdate) != 1 {
+		t.Fatalf("PeersToUpdate = %d, want 1", len(diff.PeersToUpdate))
+	}
+
+	snap.UpdatePartial(changed, "peers")
+	if diff := snap.Diff(changed); !diff.IsEmpty() {
+		t.Fatalf("Diff() after UpdatePartial = %+v, want empty", diff)
+	}
+
+	if diff := snap.Diff(nil); !diff.IsEmpty() {
+		t.Fatalf("Diff(nil) = %+v, want empty", diff)
+	}
+}
+
+// FuzzStateSnapshot_Diff decodes two state responses as the control plane
+// sends them and checks that Diff of the snapshot agrees with ComputeDiff.
+func FuzzStateSnapshot_Diff(f *testing.F) {
+	f.Add([]byte(`{"peers":[{"id":"p1","public_key":"pk1","allowed_ips":["10.0.0.1/32"]}],"metadata":{"env":"prod"}}`),
+		[]byte(`{"peers":[{"id":"p1","public_key":"pk2","allowed_ips":["10.0.0.1/32"]},{"id":"p2"}],"policies":[{"id":"pol1"}]}`))
+	f.Add([]byte(`{"peers":[{"id":"p1"},{"id":"p1","endpoint":"1.2.3.4:51820"}]}`),
+		[]byte(`{"peers":[{"id":"p1","allowed_ips":["b","a","a"]}],"data":[{"key":"k","version":2}],"secret_refs":[{"key":"s"}]}`))
+	f.Add([]byte(`{}`), []byte(`{"signing_keys":{"current":"k"},"peers":null}`))
+	f.Fuzz(func(t *testing.T, currentJSON, desiredJSON []byte) {
+		var current, desired api.StateResponse
+		if json.Unmarshal(currentJSON, &current) != nil || json.Unmarshal(desiredJSON, &desired) != nil {
+			return
+		}
+		snap := NewStateSnapshot()
+		snap.Update(&current)
+		want := ComputeDiff(&desired, &current)
+		if got := snap.Diff(&desired); !reflect.DeepEqual(got, want) {
+			t.Fatalf("Diff() = %+v\nComputeDiff() = %+v", got, want)
+		}
+		if diff := snap.Diff(&current); len(diff.PeersToAdd) != 0 || len(diff.PeersToRemove) != 0 || len(diff.PoliciesToAdd) != 0 || len(diff.PoliciesToRemove) != 0 {
+			t.Fatalf("Diff() against own state = %+v, want no additions or removals", diff)
+		}
+	})
+}
@@ -0,0 +1,184 @@
+package registration
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"filippo.io/edwards25519"
+	"filippo.io/edwards25519/field"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// AttestationSchemeXEdDSA is the scheme of key attestations: an XEdDSA
+// signature, which is made with a Curve25519 private key such as a
+// WireGuard key and verified with its public key.
+const AttestationSchemeXEdDSA = "xeddsa-25519"
+
+// attestationContext separates attestation signatures from any other use
+// of the key.
+const attestationContext = "plexd key attestation v1"
+
+// QuoteProvider produces a TPM quote over the PCRs of the node, with nonce
+// as the qualifying data, so that the control plane can tie the registered
+// key to the measured boot state of the node.
+type QuoteProvider interface {
+	Quote(ctx context.Context, nonce []byte) (*api.TPMQuote, error)
+}
+
+// AttestationMessage returns the message a key attestation signs: the
+// bootstrap token and the hostname of the registration request, length
+// prefixed and preceded by a fixed context string.
+func AttestationMessage(token, hostname string) []byte {
+	msg := make([]byte, 0, len(attestationContext)+8+len(token)+len(hostname))
+	msg = append(msg, attestationContext...)
+	msg = binary.BigEndian.AppendUint32(msg, uint32(len(token)))
+	msg = append(msg, token...)
+	msg = binary.BigEndian.AppendUint32(msg, uint32(len(hostname)))
+	msg = append(msg, hostname...)
+	return msg
+}
+
+// QuoteNonce returns the qualifying data of the TPM quote of a
+// registration: the SHA-256 of the attestation message and the public key,
+// which binds the quote to both.
+func QuoteNonce(publicKey []byte, token, hostname string) []byte {
+	h := sha256.New()
+	h.Write(AttestationMessage(token, hostname))
+	h.Write(publicKey)
+	return h.Sum(nil)
+}
+
+// Attest signs the attestation message of token and hostname with the
+// private key of k, proving possession of the key being registered.
+func (k *Keypair) Attest(token, hostname string) (*api.KeyAttestation, error) {
+	random := make([]byte, 64)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("registration: attest key: %w", err)
+	}
+	sig, err := xeddsaSign(k.PrivateKey, AttestationMessage(token, hostname), random)
+	if err != nil {
+		return nil, fmt.Errorf("registration: attest key: %w", err)
+	}
+	return &api.KeyAttestation{
+		Scheme:    AttestationSchemeXEdDSA,
+		Signature: base64.StdEncoding.EncodeToString(sig),
+	}, nil
+}
+
+// VerifyAttestation checks that att is a valid attestation of token and
+// hostname by the private key of publicKey, a base64 Curve25519 public key
+// as sent in RegisterRequest.PublicKey. It does not check the TPM quote.
+func VerifyAttestation(publicKey string, att *api.KeyAttestation, token, hostname string) error {
+	if att == nil {
+		return errors.New("registration: verify attestation: missing attestation")
+	}
+	if att.Scheme != AttestationSchemeXEdDSA {
+		return fmt.Errorf("registration: verify attestation: unknown scheme %q", att.Scheme)
+	}
+	pub, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(pub) != 32 {
+		return errors.New("registration: verify attestation: invalid public key")
+	}
+	sig, err := base64.StdEncoding.DecodeString(att.Signature)
+	if err != nil || len(sig) != 64 {
+		return errors.New("registration: verify attestation: invalid signature encoding")
+	}
+	if !xeddsaVerify(pub, AttestationMessage(token, hostname), sig) {
+		return errors.New("registration: verify attestation: signature mismatch")
+	}
+	return nil
+}
+
+// xeddsaSign signs msg with the Curve25519 private key k as specified by
+// XEdDSA, using 64 bytes of random data.
+func xeddsaSign(k, msg, random []byte) ([]byte, error) {
+	a, err := new(edwards25519.Scalar).SetBytesWithClamping(k)
+	if err != nil {
+		return nil, err
+	}
+	// The Edwards public key has its sign bit cleared, so that it is
+	// determined by the Montgomery public key. Negate a if needed.
+	A := new(edwards25519.Point).ScalarBaseMult(a)
+	if A.Bytes()[31]&0x80 != 0 {
+		a.Negate(a)
+		A.Negate(A)
+	}
+	pubA := A.Bytes()
+
+	// r = hash1(a || M || Z), where hash1 prefixes 0xFE and 31 bytes 0xFF.
+	h := sha512.New()
+	prefix := make([]byte, 32)
+	for i := range prefix {
+		prefix[i] = 0xFF
+	}
+	prefix[0] = 0xFE
+	h.Write(prefix)
+	h.Write(a.Bytes())
+	h.Write(msg)
+	h.Write(random)
+	r, err := new(edwards25519.Scalar).SetUniformBytes(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	R := new(edwards25519.Point).ScalarBaseMult(r).Bytes()
+
+	hram, err := challenge(R, pubA, msg)
+	if err != nil {
+		return nil, err
+	}
+	s := new(edwards25519.Scalar).MultiplyAdd(hram, a, r)
+	return append(R, s.Bytes()...), nil
+}
+
+// xeddsaVerify reports whether sig is a valid XEdDSA signature of msg by the
+// Curve25519 public key u.
+func xeddsaVerify(u, msg, sig []byte) bool {
+	// Convert the Montgomery u-coordinate to the Edwards y-coordinate,
+	// y = (u - 1) / (u + 1), with the sign bit cleared.
+	uBytes := make([]byte, 32)
+	copy(uBytes, u)
+	uBytes[31] &= 0x7F
+	fu, err := new(field.Element).SetBytes(uBytes)
+	if err != nil || fu.Equal(new(field.Element).Negate(new(field.Element).One())) == 1 {
+		return false
+	}
+	one := new(field.Element).One()
+	num := new(field.Element).Subtract(fu, one)
+	den := new(field.Element).Add(fu, one)
+	y := new(field.Element).Multiply(num, new(field.Element).Invert(den))
+	pubA := y.Bytes()
+	A, err := new(edwards25519.Point).SetBytes(pubA)
+	if err != nil {
+		return false
+	}
+
+	R := sig[:32]
+	s, err := new(edwards25519.Scalar).SetCanonicalBytes(sig[32:])
+	if err != nil {
+		return false
+	}
+	hram, err := challenge(R, pubA, msg)
+	if err != nil {
+		return false
+	}
+	// R == sB - hA
+	minusA := new(edwards25519.Point).Negate(A)
+	check := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(hram, minusA, s)
+	return string(check.Bytes()) == string(R)
+}
+
+// challenge returns SHA-512(R || A || M) as a scalar.
+func challenge(R, A, msg []byte) (*edwards25519.Scalar, error) {
+	h := sha512.New()
+	h.Write(R)
+	h.Write(A)
+	h.Write(msg)
+	return new(edwards25519.Scalar).SetUniformBytes(h.Sum(nil))
+}
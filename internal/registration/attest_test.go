@@ -0,0 +1,161 @@
+package registration
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/curve25519"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+func TestKeypair_Attest(t *testing.T) {
+	// Both signs of the Edwards public key occur among random keys.
+	for i := 0; i < 32; i++ {
+		kp, err := GenerateKeypair()
+		if err != nil {
+			t.Fatalf("GenerateKeypair: %v", err)
+		}
+		att, err := kp.Attest("boot-token", "node-1")
+		if err != nil {
+			t.Fatalf("Attest: %v", err)
+		}
+		if att.Scheme != AttestationSchemeXEdDSA {
+			t.Errorf("Scheme = %q, want %q", att.Scheme, AttestationSchemeXEdDSA)
+		}
+		if err := VerifyAttestation(kp.EncodePublicKey(), att, "boot-token", "node-1"); err != nil {
+			t.Fatalf("VerifyAttestation: %v", err)
+		}
+	}
+}
+
+// xeddsaVectors are known answers computed outside this package with an
+// implementation of the pseudocode of the XEdDSA specification over the
+// RFC 8032 Edwards arithmetic, with u cross-checked by the RFC 7748 ladder.
+// pub is the Edwards public key A; the last vector's key is negated.
+var xeddsaVectors = []struct {
+	priv, msg, random, u, pub, sig string
+}{
+	{
+		priv:   "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		msg:    "",
+		random: "00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000",
+		u:      "8f40c5adb68f25624ae5b214ea767a6ec94d829d3d7b5e1ad1ba6f3e2138285f",
+		pub:    "1ac105ea144728da5ebea01e5ee75d70584f1f3cd448b1ec7c2bddda3fbd1f0e",
+		sig:    "677da9db799cf0fff09fa07e027a63741a0b36308c5ce86515a8d2a384757b09e5fc90156d6692ca509b9b7358c1e096ce574587f85ccf792b5ecb1265ed3100",
+	},
+	{
+		priv:   "b8102b153c8d02fa4c3fceb7cfdba1c645332bc587bc55560f4da2abdeacfa32",
+		msg:    "626f6f742d746f6b656e006e6f64652d31",
+		random: "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f",
+		u:      "d77856a4db5c086451629b81fb7139e34965bd3eea6158f684ffc6ec2d1b8505",
+		pub:    "fe6b12cb8788ea3823d406c41f4d7a69e26d9ca6374049e3ed7ad78f1131274e",
+		sig:    "7ec98542b69d39c9f44f81439514a2b16acafa4754d6c79eb1192933f179c5f8f42d3474a78b88fe6e992185c7fd5d93c13fddb103112c58db49dc2f88a11803",
+	},
+	{
+		priv:   "ae384c1f4fafb4d1a75a860d4408ec775cc29ee71c5d2cf72c1b474ec017c952",
+		msg:    "54686520717569636b2062726f776e20666f78206a756d7073206f76657220746865206c617a7920646f67",
+		random: "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		u:      "1871e2edd5f89aeee76e27958854e25c54511b9395abcad5265c3aa03c560614",
+		pub:    "fab7b614933bcf58cc816f4cb4b00373ee3a9c90c16879da05926e2e929b7746",
+		sig:    "b5df069b5b6fd3e4069d0bd4a980ba4c32c1317254c89d71a62c6f7f3c01c2dce852c3a8f92ba271103ed389ef2d187dcfafb7a254cee69ade23aceff117f202",
+	},
+}
+
+func TestXEdDSA_KnownAnswers(t *testing.T) {
+	unhex := func(s string) []byte {
+		t.Helper()
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatalf("decode %q: %v", s, err)
+		}
+		return b
+	}
+	for i, v := range xeddsaVectors {
+		priv, msg, random := unhex(v.priv), unhex(v.msg), unhex(v.random)
+		u, pub, want := unhex(v.u), unhex(v.pub), unhex(v.sig)
+
+		got, err := curve25519.X25519(priv, curve25519.Basepoint)
+		if err != nil || !bytes.Equal(got, u) {
+			t.Fatalf("vector %d: X25519 public key = %x, %v; want %x", i, got, err, u)
+		}
+		sig, err := xeddsaSign(priv, msg, random)
+		if err != nil {
+			t.Fatalf("vector %d: xeddsaSign: %v", i, err)
+		}
+		if !bytes.Equal(sig, want) {
+			t.Errorf("vector %d: xeddsaSign = %x, want %x", i, sig, want)
+		}
+		if !xeddsaVerify(u, msg, want) {
+			t.Errorf("vector %d: xeddsaVerify rejected the known signature", i)
+		}
+		// An XEdDSA signature is an Ed25519 signature by the Edwards key.
+		if !ed25519.Verify(ed25519.PublicKey(pub), msg, want) {
+			t.Errorf("vector %d: ed25519.Verify rejected the known signature", i)
+		}
+
+		tampered := bytes.Clone(want)
+		tampered[63] ^= 0x01
+		if xeddsaVerify(u, msg, tampered) {
+			t.Errorf("vector %d: xeddsaVerify accepted a tampered signature", i)
+		}
+	}
+}
+
+func TestVerifyAttestation_Rejects(t *testing.T) {
+	kp, err := GenerateKeypair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := GenerateKeypair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	att, err := kp.Attest("boot-token", "node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, _ := base64.StdEncoding.DecodeString(att.Signature)
+	sig[0] ^= 1
+	tampered := &api.KeyAttestation{Scheme: att.Scheme, Signature: base64.StdEncoding.EncodeToString(sig)}
+
+	tests := []struct {
+		name     string
+		pub      string
+		att      *api.KeyAttestation
+		token    string
+		hostname string
+		want     string
+	}{
+		{"other token", kp.EncodePublicKey(), att, "other-token", "node-1", "registration: verify attestation: signature mismatch"},
+		{"other hostname", kp.EncodePublicKey(), att, "boot-token", "node-2", "registration: verify attestation: signature mismatch"},
+		{"other key", other.EncodePublicKey(), att, "boot-token", "node-1", "registration: verify attestation: signature mismatch"},
+		{"tampered signature", kp.EncodePublicKey(), tampered, "boot-token", "node-1", "registration: verify attestation: signature mismatch"},
+		{"missing", kp.EncodePublicKey(), nil, "boot-token", "node-1", "registration: verify attestation: missing attestation"},
+		{"unknown scheme", kp.EncodePublicKey(), &api.KeyAttestation{Scheme: "rsa", Signature: att.Signature}, "boot-token", "node-1", `registration: verify attestation: unknown scheme "rsa"`},
+		{"invalid key", "short", att, "boot-token", "node-1", "registration: verify attestation: invalid public key"},
+		{"invalid signature", kp.EncodePublicKey(), &api.KeyAttestation{Scheme: att.Scheme, Signature: "c2ln"}, "boot-token", "node-1", "registration: verify attestation: invalid signature encoding"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyAttestation(tt.pub, tt.att, tt.token, tt.hostname)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("VerifyAttestation() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttestationMessage_LengthPrefixed(t *testing.T) {
+	// Moving bytes between the token and the hostname changes the message.
+	if string(AttestationMessage("ab", "c")) == string(AttestationMessage("a", "bc")) {
+		t.Error("AttestationMessage is ambiguous")
+	}
+	pub := []byte("0123456789abcdef0123456789abcdef")
+	if nonce := QuoteNonce(pub, "t", "h"); len(nonce) != 32 || string(nonce) == string(QuoteNonce(pub, "t", "x")) {
+		t.Errorf("QuoteNonce = %x, want a SHA-256 binding the hostname", nonce)
+	}
+}
@@ -2,7 +2,10 @@ package registration
 
 import (
 	"errors"
+	"fmt"
 	"time"
+
+	"github.com/plexsphere/plexd/internal/tokensource"
 )
 
 // Config holds the configuration for the agent registration process.
@@ -11,6 +14,13 @@ type Config struct {
 	// DataDir is the path to the data directory (required).
 	DataDir string
 
+	// IdentityDir is a directory holding a pre-provisioned node identity in
+	// the layout SaveIdentity writes, such as a mounted container secret.
+	// When it holds a valid identity, the node uses it instead of
+	// registering. plexd never writes to or wipes IdentityDir.
+	// Default: "" (identity is read from DataDir only)
+	IdentityDir string
+
 	// TokenFile is the path to the bootstrap token file.
 	// Default: /etc/plexd/bootstrap-token
 	TokenFile string
@@ -22,6 +32,21 @@ type Config struct {
 	// TokenValue is a direct token value override.
 	TokenValue string
 
+	// TokenSource is a reference to the bootstrap token in a secret store,
+	// such as "vault:secret/plexd" or "systemd-creds:plexd-bootstrap-token"
+	// (see package tokensource). It is read when the node registers, so
+	// the token is never written to disk, and takes precedence over
+	// TokenFile, TokenEnv, and the metadata service.
+	// Default: "" (no secret store)
+	TokenSource string
+
+	// StandingTokenFile is the path to a registration token that stays
+	// valid after the first registration. It is only used by Reregister,
+	// when the control plane no longer accepts the node identity, and
+	// unlike TokenFile it is never deleted.
+	// Default: "" (the node cannot re-register)
+	StandingTokenFile string
+
 	// UseMetadata enables cloud metadata service for registration.
 	// Default: false
 	UseMetadata bool
@@ -48,9 +73,6 @@ type Config struct {
 	MaxRetryDuration time.Duration
 }
 
-// DefaultTokenFile is the default path to the bootstrap token file.
-const DefaultTokenFile = "/etc/plexd/bootstrap-token"
-
 // DefaultTokenEnv is the default environment variable name for the bootstrap token.
 const DefaultTokenEnv = "PLEXD_BOOTSTRAP_TOKEN"
 
@@ -87,5 +109,10 @@ func (c *Config) Validate() error {
 	if c.DataDir == "" {
 		return errors.New("registration: config: DataDir is required")
 	}
+	if c.TokenSource != "" {
+		if _, err := tokensource.ParseRef(c.TokenSource); err != nil {
+			return fmt.Errorf("registration: config: TokenSource: %w", err)
+		}
+	}
 	return nil
 }
@@ -1,6 +1,7 @@
 package registration
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -80,3 +81,14 @@ func TestConfig_ValidateAcceptsValidConfig(t *testing.T) {
 		t.Errorf("Validate() = %v, want nil", err)
 	}
 }
+
+func TestConfig_ValidateTokenSource(t *testing.T) {
+	cfg := Config{DataDir: "/var/lib/plexd", TokenSource: "vault:secret/plexd"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+	cfg.TokenSource = "plain-token"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "registration: config: TokenSource") {
+		t.Errorf("Validate() = %v, want TokenSource error", err)
+	}
+}
@@ -117,3 +117,20 @@ func LoadIdentity(dataDir string) (*NodeIdentity, error) {
 	return &id, nil
 }
 
+// identityFiles are the files SaveIdentity writes to data_dir.
+var identityFiles = []string{"identity.json", "private_key", "node_secret_key", "signing_public_key"}
+
+// WipeIdentity securely erases the node identity from dataDir. Each file is
+// overwritten before it is removed; missing files are ignored.
+func WipeIdentity(dataDir string) error {
+	var errs []error
+	for _, name := range identityFiles {
+		if err := fsutil.WipeFile(filepath.Join(dataDir, name)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("registration: wipe identity: %w", err)
+	}
+	return nil
+}
@@ -249,3 +249,42 @@ func TestSaveIdentity_Permissions(t *testing.T) {
 		}
 	}
 }
+
+func TestWipeIdentity(t *testing.T) {
+	dir := t.TempDir()
+
+	id := &NodeIdentity{
+		NodeID:           "node-1",
+		MeshIP:           "100.64.0.1",
+		SigningPublicKey: "spk",
+		PrivateKey:       []byte("01234567890123456789012345678901"),
+		NodeSecretKey:    "nsk",
+	}
+	if err := SaveIdentity(dir, id); err != nil {
+		t.Fatalf("SaveIdentity: %v", err)
+	}
+	other := filepath.Join(dir, "unrelated")
+	if err := os.WriteFile(other, []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WipeIdentity(dir); err != nil {
+		t.Fatalf("WipeIdentity: %v", err)
+	}
+	if _, err := LoadIdentity(dir); !errors.Is(err, ErrNotRegistered) {
+		t.Errorf("LoadIdentity after wipe: err = %v, want ErrNotRegistered", err)
+	}
+	for _, name := range identityFiles {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after wipe", name)
+		}
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("unrelated file removed: %v", err)
+	}
+
+	// A second wipe is a no-op.
+	if err := WipeIdentity(dir); err != nil {
+		t.Errorf("second WipeIdentity: %v", err)
+	}
+}
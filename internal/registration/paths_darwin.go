@@ -0,0 +1,6 @@
+//go:build darwin
+
+package registration
+
+// DefaultTokenFile is the default path to the bootstrap token file.
+const DefaultTokenFile = "/usr/local/etc/plexd/bootstrap-token"
@@ -0,0 +1,6 @@
+//go:build !windows && !darwin
+
+package registration
+
+// DefaultTokenFile is the default path to the bootstrap token file.
+const DefaultTokenFile = "/etc/plexd/bootstrap-token"
@@ -0,0 +1,6 @@
+//go:build windows
+
+package registration
+
+// DefaultTokenFile is the default path to the bootstrap token file.
+const DefaultTokenFile = `C:\ProgramData\plexd\bootstrap-token`
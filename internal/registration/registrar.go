@@ -8,9 +8,11 @@ import (
 	"math"
 	"math/rand"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/tokensource"
 )
 
 // defaultClock implements api.Clock using real time.
@@ -27,6 +29,13 @@ type Registrar struct {
 	metadata MetadataProvider
 	caps     *api.CapabilitiesPayload
 	clock    api.Clock
+	quotes   QuoteProvider
+	sources  *tokensource.Resolver
+
+	// ephemeralTTL is non-zero for an ephemeral node, whose identity is
+	// kept in memory only.
+	ephemeralTTL time.Duration
+	identity     *NodeIdentity
 }
 
 // NewRegistrar creates a new Registrar with the given client, config, and logger.
@@ -46,14 +55,28 @@ func (r *Registrar) SetMetadataProvider(mp MetadataProvider) { r.metadata = mp }
 // SetCapabilities sets the optional capabilities payload for registration.
 func (r *Registrar) SetCapabilities(caps *api.CapabilitiesPayload) { r.caps = caps }
 
+// SetQuoteProvider sets an optional TPM quote provider. When set, the key
+// attestation of the registration request includes a TPM quote.
+func (r *Registrar) SetQuoteProvider(qp QuoteProvider) { r.quotes = qp }
+
+// SetTokenSources sets the secret stores Config.TokenSource is read from,
+// for example to add a store beyond the built-in ones.
+func (r *Registrar) SetTokenSources(s *tokensource.Resolver) { r.sources = s }
+
 // SetClock sets a custom clock for testing.
 func (r *Registrar) SetClock(c api.Clock) { r.clock = c }
 
+// SetEphemeral registers the node as ephemeral with the given heartbeat TTL.
+// The identity is kept in memory only: it is neither read from nor written
+// to DataDir, so every agent start registers a new node.
+func (r *Registrar) SetEphemeral(ttl time.Duration) { r.ephemeralTTL = ttl }
+
 // Register orchestrates the full registration flow. If a valid identity already
-// exists on disk, it is returned without contacting the control plane.
+// exists on disk, or in memory for an ephemeral node, it is returned without
+// contacting the control plane.
 func (r *Registrar) Register(ctx context.Context) (*NodeIdentity, error) {
 	// 1. Check existing identity.
-	identity, err := LoadIdentity(r.cfg.DataDir)
+	identity, err := r.existingIdentity()
 	if err == nil {
 		r.client.SetAuthToken(identity.NodeSecretKey)
 		r.logger.Info("existing identity loaded", "node_id", identity.NodeID, "mesh_ip", identity.MeshIP)
@@ -64,11 +87,51 @@ func (r *Registrar) Register(ctx context.Context) (*NodeIdentity, error) {
 	}
 
 	// 2. Resolve bootstrap token.
-	tokenResult, err := NewTokenResolver(&r.cfg, r.metadata).Resolve(ctx)
+	resolver := NewTokenResolver(&r.cfg, r.metadata)
+	resolver.SetSources(r.sources)
+	tokenResult, err := resolver.Resolve(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("registration: resolve token: %w", err)
 	}
+	return r.register(ctx, tokenResult)
+}
+
+// Reregister registers the node anew with the token in StandingTokenFile,
+// after the control plane stopped accepting the node identity, for example
+// because the node was deleted server-side. The new identity replaces the
+// old one in DataDir once registration succeeds. A pre-provisioned identity
+// in IdentityDir is never replaced, and ephemeral nodes cannot re-register:
+// they register anew at every start.
+func (r *Registrar) Reregister(ctx context.Context) (*NodeIdentity, error) {
+	if r.ephemeralTTL > 0 {
+		return nil, errors.New("registration: reregister: ephemeral nodes register anew at every start")
+	}
+	if r.cfg.IdentityDir != "" {
+		if _, err := LoadIdentity(r.cfg.IdentityDir); err == nil {
+			return nil, fmt.Errorf("registration: reregister: identity is pre-provisioned in %s", r.cfg.IdentityDir)
+		}
+	}
+	if r.cfg.StandingTokenFile == "" {
+		return nil, errors.New("registration: reregister: no standing token file configured")
+	}
+	data, err := os.ReadFile(r.cfg.StandingTokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("registration: reregister: read standing token: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return nil, fmt.Errorf("registration: reregister: standing token file %q is empty", r.cfg.StandingTokenFile)
+	}
+	if err := validateToken(token); err != nil {
+		return nil, err
+	}
+	r.logger.Warn("re-registering with standing token", "path", r.cfg.StandingTokenFile)
+	return r.register(ctx, &TokenResult{Value: token})
+}
 
+// register registers a new node with the resolved token and persists its
+// identity.
+func (r *Registrar) register(ctx context.Context, tokenResult *TokenResult) (*NodeIdentity, error) {
 	// 3. Generate keypair.
 	keypair, err := GenerateKeypair()
 	if err != nil {
@@ -95,15 +158,32 @@ func (r *Registrar) Register(ctx context.Context) (*NodeIdentity, error) {
 		Metadata:     r.cfg.Metadata,
 		Capabilities: r.caps,
 	}
+	if r.ephemeralTTL > 0 {
+		req.Ephemeral = true
+		req.EphemeralTTL = r.ephemeralTTL.String()
+	}
 
-	// 7. Register with retry.
+	// 7. Attest possession of the private key.
+	req.Attestation, err = keypair.Attest(req.Token, req.Hostname)
+	if err != nil {
+		return nil, err
+	}
+	if r.quotes != nil {
+		quote, err := r.quotes.Quote(ctx, QuoteNonce(keypair.PublicKey, req.Token, req.Hostname))
+		if err != nil {
+			return nil, fmt.Errorf("registration: tpm quote: %w", err)
+		}
+		req.Attestation.TPMQuote = quote
+	}
+
+	// 8. Register with retry.
 	resp, err := r.registerWithRetry(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("registration: register: %w", err)
 	}
 
-	// 8. Build identity from response.
-	identity = &NodeIdentity{
+	// 9. Build identity from response.
+	identity := &NodeIdentity{
 		NodeID:          resp.NodeID,
 		MeshIP:          resp.MeshIP,
 		SigningPublicKey: resp.SigningPublicKey,
@@ -111,31 +191,56 @@ func (r *Registrar) Register(ctx context.Context) (*NodeIdentity, error) {
 		PrivateKey:      keypair.PrivateKey,
 	}
 
-	// 9. Persist identity.
-	if err := SaveIdentity(r.cfg.DataDir, identity); err != nil {
+	// 10. Persist identity, or keep it in memory for an ephemeral node.
+	if r.ephemeralTTL > 0 {
+		r.identity = identity
+	} else if err := SaveIdentity(r.cfg.DataDir, identity); err != nil {
 		return nil, fmt.Errorf("registration: save identity: %w", err)
 	}
 
-	// 10. Delete token file if applicable.
+	// 11. Delete token file if applicable.
 	if tokenResult.FilePath != "" {
 		if err := os.Remove(tokenResult.FilePath); err != nil {
 			r.logger.Warn("failed to delete token file", "path", tokenResult.FilePath, "error", err)
 		}
 	}
 
-	// 11. Set node_secret_key as auth token.
+	// 12. Set node_secret_key as auth token.
 	r.client.SetAuthToken(resp.NodeSecretKey)
 
-	r.logger.Info("registration successful", "node_id", identity.NodeID, "mesh_ip", identity.MeshIP)
+	r.logger.Info("registration successful", "node_id", identity.NodeID, "mesh_ip", identity.MeshIP, "ephemeral", r.ephemeralTTL > 0)
 	return identity, nil
 }
 
-// IsRegistered returns true if a valid identity exists on disk.
+// IsRegistered returns true if a valid identity exists on disk, or in memory
+// for an ephemeral node.
 func (r *Registrar) IsRegistered() bool {
-	_, err := LoadIdentity(r.cfg.DataDir)
+	_, err := r.existingIdentity()
 	return err == nil
 }
 
+// existingIdentity returns the pre-provisioned identity in IdentityDir, if
+// any, or the identity from a previous registration. An ephemeral node
+// ignores identity files in the data directory.
+func (r *Registrar) existingIdentity() (*NodeIdentity, error) {
+	if r.cfg.IdentityDir != "" {
+		id, err := LoadIdentity(r.cfg.IdentityDir)
+		if err == nil {
+			return id, nil
+		}
+		if !errors.Is(err, ErrNotRegistered) {
+			return nil, err
+		}
+	}
+	if r.ephemeralTTL == 0 {
+		return LoadIdentity(r.cfg.DataDir)
+	}
+	if r.identity == nil {
+		return nil, ErrNotRegistered
+	}
+	return r.identity, nil
+}
+
 // registerWithRetry calls Register with exponential backoff retry.
 func (r *Registrar) registerWithRetry(ctx context.Context, req api.RegisterRequest) (*api.RegisterResponse, error) {
 	start := r.clock.Now()
@@ -16,7 +16,10 @@ import (
 	"testing"
 	"time"
 
+	"golang.org/x/crypto/curve25519"
+
 	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/api/apitest"
 )
 
 // testServer creates an httptest.Server and a ControlPlane client connected to it.
@@ -151,6 +154,12 @@ func TestRegistrar_SuccessfulRegistration(t *testing.T) {
 	if capturedReq.PublicKey == "" {
 		t.Error("request public_key is empty")
 	}
+	if err := VerifyAttestation(capturedReq.PublicKey, capturedReq.Attestation, "boot-token-123", "test-host"); err != nil {
+		t.Errorf("request attestation: %v", err)
+	}
+	if capturedReq.Attestation != nil && capturedReq.Attestation.TPMQuote != nil {
+		t.Error("request tpm_quote set without a quote provider")
+	}
 
 	// Verify auth header used bootstrap token.
 	if capturedAuth != "Bearer boot-token-123" {
@@ -171,6 +180,66 @@ func TestRegistrar_SuccessfulRegistration(t *testing.T) {
 	}
 }
 
+// fakeQuoteProvider returns a fixed quote and records the nonce.
+type fakeQuoteProvider struct {
+	nonce []byte
+	err   error
+}
+
+func (f *fakeQuoteProvider) Quote(_ context.Context, nonce []byte) (*api.TPMQuote, error) {
+	f.nonce = nonce
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &api.TPMQuote{Quote: "cXVvdGU=", Signature: "c2ln", AKPublic: "YWs="}, nil
+}
+
+func TestRegistrar_TPMQuote(t *testing.T) {
+	var capturedReq api.RegisterRequest
+	_, client := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&capturedReq)
+		successHandler(t)(w, r)
+	})
+
+	qp := &fakeQuoteProvider{}
+	reg := NewRegistrar(client, Config{
+		DataDir:    t.TempDir(),
+		TokenValue: "boot-token-123",
+		Hostname:   "test-host",
+	}, discardLogger())
+	reg.SetQuoteProvider(qp)
+
+	identity, err := reg.Register(context.Background())
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	att := capturedReq.Attestation
+	if att == nil || att.TPMQuote == nil || att.TPMQuote.Quote != "cXVvdGU=" {
+		t.Fatalf("request attestation = %+v, want the TPM quote", att)
+	}
+	pub, err := curve25519.X25519(identity.PrivateKey, curve25519.Basepoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := QuoteNonce(pub, "boot-token-123", "test-host"); string(qp.nonce) != string(want) {
+		t.Errorf("quote nonce = %x, want %x", qp.nonce, want)
+	}
+}
+
+func TestRegistrar_TPMQuoteError(t *testing.T) {
+	_, client := testServer(t, successHandler(t))
+	reg := NewRegistrar(client, Config{
+		DataDir:    t.TempDir(),
+		TokenValue: "boot-token-123",
+	}, discardLogger())
+	reg.SetQuoteProvider(&fakeQuoteProvider{err: errors.New("no tpm")})
+
+	_, err := reg.Register(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "registration: tpm quote: no tpm") {
+		t.Errorf("Register() error = %v, want tpm quote error", err)
+	}
+}
+
 func TestRegistrar_SkipsRegistrationIfIdentityExists(t *testing.T) {
 	var reqCount atomic.Int32
 	_, client := testServer(t, func(w http.ResponseWriter, r *http.Request) {
@@ -695,3 +764,180 @@ func TestRegistrar_FullFlow(t *testing.T) {
 		t.Errorf("second call NodeID = %q, want %q", identity2.NodeID, "node-full")
 	}
 }
+
+func TestRegistrar_FakeControlPlane(t *testing.T) {
+	cp := apitest.NewServer(t)
+	cp.AddBootstrapToken("boot-token-123")
+	client := cp.Client(t)
+
+	reg := NewRegistrar(client, Config{
+		DataDir:    t.TempDir(),
+		TokenValue: "boot-token-123",
+		Hostname:   "test-host",
+	}, discardLogger())
+
+	identity, err := reg.Register(context.Background())
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if req, ok := cp.Registration(identity.NodeID); !ok || req.Hostname != "test-host" || req.PublicKey == "" {
+		t.Errorf("Registration(%q) = %+v, %v", identity.NodeID, req, ok)
+	}
+
+	// The client now authenticates with the node secret key.
+	if _, err := client.FetchState(context.Background(), identity.NodeID); err != nil {
+		t.Errorf("FetchState after registration: %v", err)
+	}
+}
+
+func TestRegistrar_EphemeralKeepsIdentityInMemory(t *testing.T) {
+	cp := apitest.NewServer(t)
+	cp.AddBootstrapToken("boot-token-123")
+	client := cp.Client(t)
+
+	// An identity left on disk is ignored.
+	dataDir := t.TempDir()
+	stale := &NodeIdentity{NodeID: "stale-node", MeshIP: "100.64.0.9", PrivateKey: []byte("k"), NodeSecretKey: "s"}
+	if err := SaveIdentity(dataDir, stale); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.ReadFile(filepath.Join(dataDir, "identity.json"))
+
+	reg := NewRegistrar(client, Config{
+		DataDir:    dataDir,
+		TokenValue: "boot-token-123",
+		Hostname:   "ci-runner",
+	}, discardLogger())
+	reg.SetEphemeral(5 * time.Minute)
+
+	identity, err := reg.Register(context.Background())
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if identity.NodeID == "stale-node" {
+		t.Fatal("ephemeral registration reused the identity on disk")
+	}
+	req, ok := cp.Registration(identity.NodeID)
+	if !ok || !req.Ephemeral || req.EphemeralTTL != "5m0s" {
+		t.Errorf("Registration(%q) = %+v, %v, want ephemeral with TTL 5m0s", identity.NodeID, req, ok)
+	}
+	if after, _ := os.ReadFile(filepath.Join(dataDir, "identity.json")); string(after) != string(before) {
+		t.Error("ephemeral registration wrote identity.json")
+	}
+
+	if !reg.IsRegistered() {
+		t.Error("IsRegistered() = false after ephemeral registration")
+	}
+	again, err := reg.Register(context.Background())
+	if err != nil || again.NodeID != identity.NodeID {
+		t.Errorf("second Register() = %+v, %v, want in-memory identity %s", again, err, identity.NodeID)
+	}
+}
+
+func TestRegistrar_UsesMountedIdentity(t *testing.T) {
+	cp := apitest.NewServer(t)
+	client := cp.Client(t)
+
+	identityDir := t.TempDir()
+	mounted := &NodeIdentity{NodeID: "mounted-node", MeshIP: "100.64.0.7", PrivateKey: []byte("k"), NodeSecretKey: "s"}
+	if err := SaveIdentity(identityDir, mounted); err != nil {
+		t.Fatal(err)
+	}
+
+	dataDir := t.TempDir()
+	reg := NewRegistrar(client, Config{DataDir: dataDir, IdentityDir: identityDir}, discardLogger())
+	identity, err := reg.Register(context.Background())
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if identity.NodeID != "mounted-node" {
+		t.Errorf("NodeID = %q, want mounted-node", identity.NodeID)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "identity.json")); !os.IsNotExist(err) {
+		t.Errorf("mounted identity copied to data_dir: %v", err)
+	}
+	if !reg.IsRegistered() {
+		t.Error("IsRegistered() = false with mounted identity")
+	}
+}
+
+func TestRegistrar_Reregister(t *testing.T) {
+	cp := apitest.NewServer(t)
+	cp.AddBootstrapToken("standing-token")
+	client := cp.Client(t)
+
+	dataDir := t.TempDir()
+	old := &NodeIdentity{NodeID: "deleted-node", MeshIP: "100.64.0.9", PrivateKey: []byte("k"), NodeSecretKey: "s"}
+	if err := SaveIdentity(dataDir, old); err != nil {
+		t.Fatal(err)
+	}
+	standing := filepath.Join(t.TempDir(), "standing-token")
+	if err := os.WriteFile(standing, []byte("standing-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := NewRegistrar(client, Config{DataDir: dataDir, StandingTokenFile: standing, Hostname: "node-1"}, discardLogger())
+	identity, err := reg.Reregister(context.Background())
+	if err != nil {
+		t.Fatalf("Reregister: %v", err)
+	}
+	if identity.NodeID == "deleted-node" {
+		t.Fatal("Reregister returned the old identity")
+	}
+	if _, ok := cp.Registration(identity.NodeID); !ok {
+		t.Errorf("node %s not registered with the control plane", identity.NodeID)
+	}
+	loaded, err := LoadIdentity(dataDir)
+	if err != nil || loaded.NodeID != identity.NodeID {
+		t.Errorf("LoadIdentity() = %+v, %v, want %s", loaded, err, identity.NodeID)
+	}
+	if _, err := os.Stat(standing); err != nil {
+		t.Errorf("standing token file removed: %v", err)
+	}
+}
+
+func TestRegistrar_ReregisterRefused(t *testing.T) {
+	cp := apitest.NewServer(t)
+	client := cp.Client(t)
+
+	standing := filepath.Join(t.TempDir(), "standing-token")
+	if err := os.WriteFile(standing, []byte("standing-token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	identityDir := t.TempDir()
+	if err := SaveIdentity(identityDir, &NodeIdentity{NodeID: "mounted-node", MeshIP: "100.64.0.7", PrivateKey: []byte("k"), NodeSecretKey: "s"}); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		cfg       Config
+		ephemeral bool
+		want      string
+	}{
+		{"no standing token", Config{}, false, "registration: reregister: no standing token file configured"},
+		{"empty standing token", Config{StandingTokenFile: empty}, false, "is empty"},
+		{"missing standing token", Config{StandingTokenFile: filepath.Join(t.TempDir(), "missing")}, false, "registration: reregister: read standing token"},
+		{"mounted identity", Config{StandingTokenFile: standing, IdentityDir: identityDir}, false, "identity is pre-provisioned"},
+		{"ephemeral", Config{StandingTokenFile: standing}, true, "ephemeral nodes register anew"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.DataDir = t.TempDir()
+			reg := NewRegistrar(client, tt.cfg, discardLogger())
+			if tt.ephemeral {
+				reg.SetEphemeral(5 * time.Minute)
+			}
+			if _, err := reg.Reregister(context.Background()); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Reregister() = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+	if len(cp.Requests()) != 0 {
+		t.Errorf("requests = %d, want none", len(cp.Requests()))
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"strings"
+
+	"github.com/plexsphere/plexd/internal/tokensource"
 )
 
 const maxTokenLength = 512
@@ -24,7 +26,8 @@ type MetadataProvider interface {
 // TokenResolver resolves the bootstrap token from multiple sources.
 type TokenResolver struct {
 	cfg      *Config
-	metadata MetadataProvider // nil = skip metadata source
+	metadata MetadataProvider      // nil = skip metadata source
+	sources  *tokensource.Resolver // nil = built-in secret stores
 }
 
 // NewTokenResolver creates a new TokenResolver.
@@ -32,8 +35,14 @@ func NewTokenResolver(cfg *Config, metadata MetadataProvider) *TokenResolver {
 	return &TokenResolver{cfg: cfg, metadata: metadata}
 }
 
+// SetSources sets the secret stores Config.TokenSource is read from. By
+// default the built-in stores of tokensource.NewResolver are used.
+func (r *TokenResolver) SetSources(s *tokensource.Resolver) {
+	r.sources = s
+}
+
 // Resolve locates a bootstrap token by checking sources in priority order:
-// direct value, file, environment variable, metadata service.
+// direct value, secret store, file, environment variable, metadata service.
 func (r *TokenResolver) Resolve(ctx context.Context) (*TokenResult, error) {
 	// 1a. Direct value.
 	if v := strings.TrimSpace(r.cfg.TokenValue); v != "" {
@@ -43,7 +52,24 @@ func (r *TokenResolver) Resolve(ctx context.Context) (*TokenResult, error) {
 		return &TokenResult{Value: v}, nil
 	}
 
-	// 1b. File.
+	// 1b. Secret store. A configured store that fails is an error rather
+	// than a reason to fall back to the other sources.
+	if r.cfg.TokenSource != "" {
+		sources := r.sources
+		if sources == nil {
+			sources = tokensource.NewResolver()
+		}
+		v, err := sources.Read(ctx, r.cfg.TokenSource)
+		if err != nil {
+			return nil, fmt.Errorf("registration: read token source: %w", err)
+		}
+		if err := validateToken(v); err != nil {
+			return nil, err
+		}
+		return &TokenResult{Value: v}, nil
+	}
+
+	// 1c. File.
 	if r.cfg.TokenFile != "" {
 		data, err := os.ReadFile(r.cfg.TokenFile)
 		if err != nil && !errors.Is(err, os.ErrNotExist) {
@@ -59,7 +85,7 @@ func (r *TokenResolver) Resolve(ctx context.Context) (*TokenResult, error) {
 		}
 	}
 
-	// 1c. Environment variable.
+	// 1d. Environment variable.
 	if r.cfg.TokenEnv != "" {
 		if v := strings.TrimSpace(os.Getenv(r.cfg.TokenEnv)); v != "" {
 			if err := validateToken(v); err != nil {
@@ -69,7 +95,7 @@ func (r *TokenResolver) Resolve(ctx context.Context) (*TokenResult, error) {
 		}
 	}
 
-	// 1d. Metadata service.
+	// 1e. Metadata service.
 	if r.cfg.UseMetadata && r.metadata != nil {
 		token, err := r.metadata.ReadToken(ctx)
 		if err == nil {
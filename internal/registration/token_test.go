@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/plexsphere/plexd/internal/tokensource"
 )
 
 type mockMetadataProvider struct {
@@ -280,3 +282,47 @@ func TestTokenResolver_MetadataError(t *testing.T) {
 		t.Fatalf("error should mention 'no bootstrap token found', got: %s", err.Error())
 	}
 }
+
+type mockTokenSource struct {
+	token string
+	err   error
+}
+
+func (m *mockTokenSource) Read(context.Context, tokensource.Ref) (string, error) {
+	return m.token, m.err
+}
+
+func TestTokenResolver_FromTokenSource(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sources := tokensource.NewResolver()
+	sources.Register("test", &mockTokenSource{token: " store-token\n"})
+
+	r := NewTokenResolver(&Config{TokenSource: "test:plexd", TokenFile: tokenFile}, nil)
+	r.SetSources(sources)
+	result, err := r.Resolve(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Value != "store-token" || result.FilePath != "" {
+		t.Fatalf("got %+v, want store-token without FilePath", result)
+	}
+}
+
+func TestTokenResolver_TokenSourceError(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sources := tokensource.NewResolver()
+	sources.Register("test", &mockTokenSource{err: errors.New("access denied")})
+
+	r := NewTokenResolver(&Config{TokenSource: "test:plexd", TokenFile: tokenFile}, nil)
+	r.SetSources(sources)
+	_, err := r.Resolve(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "registration: read token source: access denied") {
+		t.Fatalf("got %v, want token source error without falling back to the file", err)
+	}
+}
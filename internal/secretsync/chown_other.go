@@ -0,0 +1,10 @@
+//go:build !windows
+
+package secretsync
+
+import "os"
+
+// chownGroup sets the group of path to gid, keeping root as the owner.
+func chownGroup(path string, gid int) error {
+	return os.Chown(path, 0, gid)
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package secretsync
+
+// chownGroup is a no-op on Windows; access to Dir follows its ACL.
+func chownGroup(string, int) error {
+	return nil
+}
@@ -0,0 +1,77 @@
+// Package secretsync writes selected control plane secrets, decrypted, into
+// a host directory so that pods and other local consumers on a mesh node can
+// read them as files without access to the control plane or the node API.
+package secretsync
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultResyncInterval is the default interval between full syncs, which
+// retry failed fetches and restore files removed from the directory.
+const DefaultResyncInterval = 5 * time.Minute
+
+// AllKeys selects every secret in the node's secret index.
+const AllKeys = "*"
+
+// Config holds the configuration for secret file sync.
+type Config struct {
+	// Enabled controls whether secrets are written to Dir.
+	// Default: false
+	Enabled bool
+
+	// Dir is the directory secrets are written to, one file per key. plexd
+	// owns it: files that are not selected secrets are removed.
+	// Default: /var/run/plexd/secrets (Windows: C:\ProgramData\plexd\secrets)
+	Dir string
+
+	// Keys lists the secret keys to write. "*" selects every secret.
+	// Required when Enabled.
+	Keys []string
+
+	// GID is the group that owns Dir and the secret files. Files are
+	// readable by the owner (root) and this group only.
+	// Default: 0
+	GID int
+
+	// ResyncInterval is the interval between full syncs.
+	// Default: 5m
+	ResyncInterval time.Duration
+}
+
+// ApplyDefaults sets default values for zero-valued fields.
+func (c *Config) ApplyDefaults() {
+	if c.Dir == "" {
+		c.Dir = DefaultDir
+	}
+	if c.ResyncInterval == 0 {
+		c.ResyncInterval = DefaultResyncInterval
+	}
+}
+
+// Validate checks that required fields are set and values are acceptable.
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Dir == "" {
+		return errors.New("secretsync: config: Dir is required")
+	}
+	if len(c.Keys) == 0 {
+		return errors.New("secretsync: config: Keys is required")
+	}
+	for _, key := range c.Keys {
+		if key != AllKeys && !validKey(key) {
+			return fmt.Errorf("secretsync: config: invalid key %q", key)
+		}
+	}
+	if c.GID < 0 {
+		return errors.New("secretsync: config: GID must not be negative")
+	}
+	if c.ResyncInterval < time.Second {
+		return errors.New("secretsync: config: ResyncInterval must be at least 1s")
+	}
+	return nil
+}
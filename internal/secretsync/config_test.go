@@ -0,0 +1,62 @@
+package secretsync
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfig_ApplyDefaults(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+
+	if cfg.Enabled {
+		t.Error("Enabled = true, want false")
+	}
+	if cfg.Dir != DefaultDir {
+		t.Errorf("Dir = %q, want %q", cfg.Dir, DefaultDir)
+	}
+	if cfg.ResyncInterval != DefaultResyncInterval {
+		t.Errorf("ResyncInterval = %v, want %v", cfg.ResyncInterval, DefaultResyncInterval)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := func() Config {
+		cfg := Config{Enabled: true, Keys: []string{"db-password"}}
+		cfg.ApplyDefaults()
+		return cfg
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(*Config)
+		wantErr string
+	}{
+		{name: "valid", mutate: func(*Config) {}},
+		{name: "all keys", mutate: func(c *Config) { c.Keys = []string{AllKeys} }},
+		{name: "disabled skips checks", mutate: func(c *Config) { c.Enabled = false; c.Keys = nil }},
+		{name: "missing dir", mutate: func(c *Config) { c.Dir = "" }, wantErr: "Dir is required"},
+		{name: "missing keys", mutate: func(c *Config) { c.Keys = nil }, wantErr: "Keys is required"},
+		{name: "path key", mutate: func(c *Config) { c.Keys = []string{"../etc/passwd"} }, wantErr: "invalid key"},
+		{name: "dot key", mutate: func(c *Config) { c.Keys = []string{".hidden"} }, wantErr: "invalid key"},
+		{name: "negative gid", mutate: func(c *Config) { c.GID = -1 }, wantErr: "GID"},
+		{name: "short resync", mutate: func(c *Config) { c.ResyncInterval = time.Millisecond }, wantErr: "ResyncInterval"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.mutate(&cfg)
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
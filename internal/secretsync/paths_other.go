@@ -0,0 +1,7 @@
+//go:build !windows
+
+package secretsync
+
+// DefaultDir is the default directory secrets are written to. It is on
+// tmpfs on most Linux hosts, so secrets are not persisted to disk.
+const DefaultDir = "/var/run/plexd/secrets"
@@ -0,0 +1,6 @@
+//go:build windows
+
+package secretsync
+
+// DefaultDir is the default directory secrets are written to.
+const DefaultDir = `C:\ProgramData\plexd\secrets`
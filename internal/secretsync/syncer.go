@@ -0,0 +1,274 @@
+package secretsync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/auditfwd"
+	"github.com/plexsphere/plexd/internal/fsutil"
+	"github.com/plexsphere/plexd/internal/nodeapi"
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+// Syncer mirrors the selected secrets of the node's secret index into
+// Config.Dir. A secret is fetched from the control plane and decrypted with
+// the node secret key only when its version changes, and its file is
+// replaced atomically, so readers never see a partial value. When a fetch
+// fails the previous file is kept until the next sync.
+type Syncer struct {
+	cfg     Config
+	fetcher nodeapi.SecretFetcher
+	nodeID  string
+	nsk     []byte
+	logger  *slog.Logger
+	trigger chan struct{}
+	audit   MutationRecorder
+
+	mu      sync.Mutex
+	refs    []api.SecretRef
+	known   bool
+	written map[string]int
+
+	// cause is the cause of the latest update of refs, to which the
+	// changes of the next sync are attributed.
+	cause *api.Cause
+}
+
+// MutationRecorder records host changes for the audit trail. Satisfied by
+// *auditfwd.Recorder.
+type MutationRecorder interface {
+	Record(ctx context.Context, m auditfwd.Mutation)
+}
+
+// NewSyncer creates a Syncer. Config defaults are applied automatically.
+func NewSyncer(cfg Config, fetcher nodeapi.SecretFetcher, nodeID string, nsk []byte, logger *slog.Logger) *Syncer {
+	cfg.ApplyDefaults()
+	return &Syncer{
+		cfg:     cfg,
+		fetcher: fetcher,
+		nodeID:  nodeID,
+		nsk:     nsk,
+		logger:  logger.With("component", "secretsync"),
+		trigger: make(chan struct{}, 1),
+		written: make(map[string]int),
+	}
+}
+
+// SetAuditRecorder sets the recorder every secret file written or removed
+// is recorded with, as a "secret_write" or "secret_remove" host change.
+// Must be called before Run.
+func (s *Syncer) SetAuditRecorder(rec MutationRecorder) {
+	s.audit = rec
+}
+
+// Update replaces the secret index and schedules a sync. Safe for
+// concurrent use.
+func (s *Syncer) Update(refs []api.SecretRef) {
+	s.update(context.Background(), refs)
+}
+
+// update is Update, attributing the changes of the sync to the cause
+// carried by ctx.
+func (s *Syncer) update(ctx context.Context, refs []api.SecretRef) {
+	s.mu.Lock()
+	s.refs = slices.Clone(refs)
+	s.known = true
+	s.cause = nil
+	if c, ok := api.CauseFromContext(ctx); ok {
+		s.cause = &c
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// ReconcileHandler returns a reconcile.ReconcileHandler that passes the
+// desired secret index to Update when it changed or has not been seen yet.
+func (s *Syncer) ReconcileHandler() reconcile.ReconcileHandler {
+	return func(ctx context.Context, desired *api.StateResponse, diff reconcile.StateDiff) error {
+		s.mu.Lock()
+		known := s.known
+		s.mu.Unlock()
+		if diff.SecretRefsChanged || !known {
+			s.update(ctx, desired.SecretRefs)
+		}
+		return nil
+	}
+}
+
+// HandleSecretsUpdated returns an api.EventHandler for node_secrets_updated
+// events that passes the new secret index to Update.
+func (s *Syncer) HandleSecretsUpdated() api.EventHandler {
+	return func(ctx context.Context, env api.SignedEnvelope) error {
+		var payload nodeapi.NodeSecretsUpdatePayload
+		if err := json.Unmarshal(env.Payload, &payload); err != nil {
+			return fmt.Errorf("secretsync: parse node_secrets_updated: %w", err)
+		}
+		s.update(ctx, payload.SecretRefs)
+		return nil
+	}
+}
+
+// Run syncs after every Update and every ResyncInterval until ctx is
+// cancelled. Written files are left in place on return, so consumers keep
+// working while the agent restarts. It returns nil immediately when sync is
+// disabled.
+func (s *Syncer) Run(ctx context.Context) error {
+	if !s.cfg.Enabled {
+		return nil
+	}
+	if err := s.prepareDir(); err != nil {
+		return err
+	}
+	s.logger.Info("secret sync started", "dir", s.cfg.Dir, "keys", s.cfg.Keys)
+
+	ticker := time.NewTicker(s.cfg.ResyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.trigger:
+		case <-ticker.C:
+		}
+		s.sync(ctx)
+	}
+}
+
+// prepareDir creates Dir, owned by root and Config.GID with mode 0750.
+func (s *Syncer) prepareDir() error {
+	if err := os.MkdirAll(s.cfg.Dir, 0o750); err != nil {
+		return fmt.Errorf("secretsync: create %s: %w", s.cfg.Dir, err)
+	}
+	if err := os.Chmod(s.cfg.Dir, 0o750); err != nil {
+		return fmt.Errorf("secretsync: chmod %s: %w", s.cfg.Dir, err)
+	}
+	if err := chownGroup(s.cfg.Dir, s.cfg.GID); err != nil {
+		return fmt.Errorf("secretsync: chown %s: %w", s.cfg.Dir, err)
+	}
+	return nil
+}
+
+// sync writes every selected secret whose version changed or whose file is
+// missing, and removes all other files from Dir. It does nothing until the
+// secret index is known, so that a restart does not remove files before
+// the first reconcile.
+func (s *Syncer) sync(ctx context.Context) {
+	s.mu.Lock()
+	refs, known, cause := s.refs, s.known, s.cause
+	s.cause = nil
+	s.mu.Unlock()
+	if !known {
+		return
+	}
+	if cause != nil {
+		ctx = api.WithCause(ctx, *cause)
+	}
+
+	want := make(map[string]bool)
+	for _, ref := range refs {
+		if !s.selected(ref.Key) {
+			continue
+		}
+		if !validKey(ref.Key) {
+			s.logger.Warn("secret key is not a valid file name, skipping", "key", ref.Key)
+			continue
+		}
+		want[ref.Key] = true
+		if s.written[ref.Key] == ref.Version && s.exists(ref.Key) {
+			continue
+		}
+		err := s.write(ctx, ref.Key)
+		s.record(ctx, "secret_write", ref.Key, ref.Version, err)
+		if err != nil {
+			s.logger.Warn("secret not synced", "key", ref.Key, "error", err)
+			continue
+		}
+		s.written[ref.Key] = ref.Version
+		s.logger.Info("secret synced", "key", ref.Key, "version", ref.Version)
+	}
+
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		s.logger.Warn("list secret dir", "dir", s.cfg.Dir, "error", err)
+		return
+	}
+	for _, e := range entries {
+		if want[e.Name()] || !e.Type().IsRegular() {
+			continue
+		}
+		err := fsutil.WipeFile(filepath.Join(s.cfg.Dir, e.Name()))
+		s.record(ctx, "secret_remove", e.Name(), 0, err)
+		if err != nil {
+			s.logger.Warn("remove secret", "key", e.Name(), "error", err)
+			continue
+		}
+		delete(s.written, e.Name())
+		s.logger.Info("secret removed", "key", e.Name())
+	}
+}
+
+// write fetches, decrypts, and writes the secret key.
+func (s *Syncer) write(ctx context.Context, key string) error {
+	resp, err := s.fetcher.FetchSecret(ctx, s.nodeID, key)
+	if err != nil {
+		return fmt.Errorf("fetch: %w", err)
+	}
+	plaintext, err := nodeapi.DecryptSecret(s.nsk, resp.Ciphertext, resp.Nonce)
+	if err != nil {
+		return fmt.Errorf("decrypt: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(s.cfg.Dir, key, []byte(plaintext), 0o440); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	path := filepath.Join(s.cfg.Dir, key)
+	if err := os.Chmod(path, 0o440); err != nil {
+		return fmt.Errorf("chmod: %w", err)
+	}
+	if err := chownGroup(path, s.cfg.GID); err != nil {
+		return fmt.Errorf("chown: %w", err)
+	}
+	return nil
+}
+
+// record records a change of the secret file key with the audit recorder,
+// if one is set. The secret value is never recorded.
+func (s *Syncer) record(ctx context.Context, action, key string, version int, err error) {
+	if s.audit == nil {
+		return
+	}
+	path := filepath.Join(s.cfg.Dir, key)
+	object := map[string]any{"key": key, "path": path}
+	if version != 0 {
+		object["version"] = version
+	}
+	s.audit.Record(ctx, auditfwd.Mutation{Action: action, Target: path, Object: object, Err: err})
+}
+
+func (s *Syncer) selected(key string) bool {
+	return slices.Contains(s.cfg.Keys, AllKeys) || slices.Contains(s.cfg.Keys, key)
+}
+
+func (s *Syncer) exists(key string) bool {
+	_, err := os.Stat(filepath.Join(s.cfg.Dir, key))
+	return !errors.Is(err, os.ErrNotExist)
+}
+
+// validKey reports whether key can be used as a file name in Dir. Names
+// starting with a dot are reserved for temporary files.
+func validKey(key string) bool {
+	return key != "" && !strings.HasPrefix(key, ".") && !strings.ContainsAny(key, `/\`)
+}
@@ -0,0 +1,299 @@
+package secretsync
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/reconcile"
+)
+
+// fakeFetcher serves secrets encrypted with nsk and counts fetches per key.
+type fakeFetcher struct {
+	t      *testing.T
+	nsk    []byte
+	mu     sync.Mutex
+	values map[string]string
+	fail   map[string]bool
+	calls  map[string]int
+}
+
+func newFakeFetcher(t *testing.T, nsk []byte, values map[string]string) *fakeFetcher {
+	return &fakeFetcher{t: t, nsk: nsk, values: values, fail: map[string]bool{}, calls: map[string]int{}}
+}
+
+func (f *fakeFetcher) FetchSecret(_ context.Context, _, key string) (*api.SecretResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[key]++
+	if f.fail[key] {
+		return nil, errors.New("unavailable")
+	}
+	value, ok := f.values[key]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	ct, nonce := testEncrypt(f.t, f.nsk, value)
+	return &api.SecretResponse{Key: key, Ciphertext: ct, Nonce: nonce}, nil
+}
+
+func testEncrypt(t *testing.T, key []byte, plaintext string) (ciphertext, nonce string) {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonceBytes := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonceBytes); err != nil {
+		t.Fatal(err)
+	}
+	ciphertextBytes := gcm.Seal(nil, nonceBytes, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(ciphertextBytes), base64.StdEncoding.EncodeToString(nonceBytes)
+}
+
+func newTestSyncer(t *testing.T, keys []string, values map[string]string) (*Syncer, *fakeFetcher) {
+	t.Helper()
+	nsk := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, nsk); err != nil {
+		t.Fatal(err)
+	}
+	fetcher := newFakeFetcher(t, nsk, values)
+	cfg := Config{Enabled: true, Dir: filepath.Join(t.TempDir(), "secrets"), Keys: keys}
+	s := NewSyncer(cfg, fetcher, "node-1", nsk, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := s.prepareDir(); err != nil {
+		t.Fatalf("prepareDir() = %v", err)
+	}
+	return s, fetcher
+}
+
+func readSecret(t *testing.T, s *Syncer, key string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(s.cfg.Dir, key))
+	if err != nil {
+		t.Fatalf("read %s: %v", key, err)
+	}
+	return string(data)
+}
+
+func TestSyncer_WritesSelectedSecrets(t *testing.T) {
+	s, _ := newTestSyncer(t, []string{"db", "api"}, map[string]string{
+		"db": "db-pass", "api": "api-token", "other": "not-selected",
+	})
+	s.Update([]api.SecretRef{{Key: "db", Version: 1}, {Key: "api", Version: 1}, {Key: "other", Version: 1}})
+	s.sync(context.Background())
+
+	if got := readSecret(t, s, "db"); got != "db-pass" {
+		t.Errorf("db = %q, want %q", got, "db-pass")
+	}
+	if got := readSecret(t, s, "api"); got != "api-token" {
+		t.Errorf("api = %q, want %q", got, "api-token")
+	}
+	if _, err := os.Stat(filepath.Join(s.cfg.Dir, "other")); !os.IsNotExist(err) {
+		t.Errorf("other: stat error = %v, want not exist", err)
+	}
+	info, err := os.Stat(filepath.Join(s.cfg.Dir, "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o440 {
+		t.Errorf("db mode = %o, want 440", perm)
+	}
+}
+
+func TestSyncer_AllKeys(t *testing.T) {
+	s, _ := newTestSyncer(t, []string{AllKeys}, map[string]string{"a": "1", "b": "2", "../x": "bad"})
+	s.Update([]api.SecretRef{{Key: "a"}, {Key: "b"}, {Key: "../x"}})
+	s.sync(context.Background())
+
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d files, want 2 (invalid key skipped)", len(entries))
+	}
+}
+
+func TestSyncer_RefetchesOnlyChangedVersions(t *testing.T) {
+	s, f := newTestSyncer(t, []string{AllKeys}, map[string]string{"a": "v1", "b": "v1"})
+	s.Update([]api.SecretRef{{Key: "a", Version: 1}, {Key: "b", Version: 1}})
+	s.sync(context.Background())
+
+	f.values["a"] = "v2"
+	s.Update([]api.SecretRef{{Key: "a", Version: 2}, {Key: "b", Version: 1}})
+	s.sync(context.Background())
+
+	if got := readSecret(t, s, "a"); got != "v2" {
+		t.Errorf("a = %q, want %q", got, "v2")
+	}
+	if f.calls["a"] != 2 || f.calls["b"] != 1 {
+		t.Errorf("calls = %v, want a=2 b=1", f.calls)
+	}
+}
+
+func TestSyncer_RestoresDeletedFile(t *testing.T) {
+	s, f := newTestSyncer(t, []string{"a"}, map[string]string{"a": "v1"})
+	s.Update([]api.SecretRef{{Key: "a", Version: 1}})
+	s.sync(context.Background())
+
+	if err := os.Remove(filepath.Join(s.cfg.Dir, "a")); err != nil {
+		t.Fatal(err)
+	}
+	s.sync(context.Background())
+
+	if got := readSecret(t, s, "a"); got != "v1" {
+		t.Errorf("a = %q, want %q", got, "v1")
+	}
+	if f.calls["a"] != 2 {
+		t.Errorf("calls = %d, want 2", f.calls["a"])
+	}
+}
+
+func TestSyncer_KeepsFileOnFetchError(t *testing.T) {
+	s, f := newTestSyncer(t, []string{"a"}, map[string]string{"a": "v1"})
+	s.Update([]api.SecretRef{{Key: "a", Version: 1}})
+	s.sync(context.Background())
+
+	f.fail["a"] = true
+	s.Update([]api.SecretRef{{Key: "a", Version: 2}})
+	s.sync(context.Background())
+
+	if got := readSecret(t, s, "a"); got != "v1" {
+		t.Errorf("a = %q, want previous value %q", got, "v1")
+	}
+
+	f.fail["a"] = false
+	f.values["a"] = "v2"
+	s.sync(context.Background())
+	if got := readSecret(t, s, "a"); got != "v2" {
+		t.Errorf("a = %q after retry, want %q", got, "v2")
+	}
+}
+
+func TestSyncer_RemovesStaleFiles(t *testing.T) {
+	s, _ := newTestSyncer(t, []string{AllKeys}, map[string]string{"a": "1", "b": "2"})
+	if err := os.WriteFile(filepath.Join(s.cfg.Dir, ".tmp-leftover"), []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s.Update([]api.SecretRef{{Key: "a"}, {Key: "b"}})
+	s.sync(context.Background())
+
+	s.Update([]api.SecretRef{{Key: "a"}})
+	s.sync(context.Background())
+
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "a" {
+		names := make([]string, 0, len(entries))
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("files = %v, want [a]", names)
+	}
+}
+
+func TestSyncer_NoIndexKeepsFiles(t *testing.T) {
+	s, _ := newTestSyncer(t, []string{AllKeys}, nil)
+	if err := os.WriteFile(filepath.Join(s.cfg.Dir, "a"), []byte("old"), 0o440); err != nil {
+		t.Fatal(err)
+	}
+	s.sync(context.Background())
+
+	if got := readSecret(t, s, "a"); got != "old" {
+		t.Errorf("a = %q, want %q before the first index", got, "old")
+	}
+}
+
+func TestSyncer_ReconcileHandler(t *testing.T) {
+	s, _ := newTestSyncer(t, []string{AllKeys}, nil)
+	h := s.ReconcileHandler()
+	desired := &api.StateResponse{SecretRefs: []api.SecretRef{{Key: "a", Version: 1}}}
+
+	// The first reconcile passes the index even without changes.
+	if err := h(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.refs) != 1 {
+		t.Fatalf("refs = %v, want 1 entry", s.refs)
+	}
+
+	desired.SecretRefs = nil
+	if err := h(context.Background(), desired, reconcile.StateDiff{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.refs) != 1 {
+		t.Errorf("refs replaced without SecretRefsChanged")
+	}
+
+	if err := h(context.Background(), desired, reconcile.StateDiff{SecretRefsChanged: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.refs) != 0 {
+		t.Errorf("refs = %v, want empty", s.refs)
+	}
+}
+
+func TestSyncer_HandleSecretsUpdated(t *testing.T) {
+	s, _ := newTestSyncer(t, []string{AllKeys}, nil)
+	payload, _ := json.Marshal(map[string]any{"secret_refs": []api.SecretRef{{Key: "a", Version: 3}}})
+
+	if err := s.HandleSecretsUpdated()(context.Background(), api.SignedEnvelope{Payload: payload}); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.refs) != 1 || s.refs[0].Version != 3 {
+		t.Errorf("refs = %v, want [a@3]", s.refs)
+	}
+
+	if err := s.HandleSecretsUpdated()(context.Background(), api.SignedEnvelope{Payload: []byte("{")}); err == nil {
+		t.Error("invalid payload: error = nil, want error")
+	}
+}
+
+func TestSyncer_Run(t *testing.T) {
+	s, _ := newTestSyncer(t, []string{"a"}, map[string]string{"a": "v1"})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	s.Update([]api.SecretRef{{Key: "a", Version: 1}})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(filepath.Join(s.cfg.Dir, "a")); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("secret not written")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() = %v, want context.Canceled", err)
+	}
+}
+
+func TestSyncer_RunDisabled(t *testing.T) {
+	s := NewSyncer(Config{}, nil, "node-1", nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := s.Run(context.Background()); err != nil {
+		t.Errorf("Run() = %v, want nil", err)
+	}
+}
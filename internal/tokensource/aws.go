@@ -0,0 +1,269 @@
+package tokensource
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultAWSIMDSURL is the base URL of the EC2 instance metadata service.
+const DefaultAWSIMDSURL = "http://169.254.169.254"
+
+// AWSSource reads secrets from AWS Secrets Manager. The name of a
+// reference is the secret ID, its name or ARN. Params:
+//
+//   - region: the region of the secret (default $AWS_REGION,
+//     $AWS_DEFAULT_REGION, or the region of the EC2 instance)
+//   - key: for a secret holding a JSON object, the key of the token
+//   - stage: the version stage (default AWSCURRENT)
+//
+// Credentials are taken from $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY,
+// and $AWS_SESSION_TOKEN, or else from the instance profile of the EC2
+// instance.
+type AWSSource struct {
+	// Endpoint is the base URL of the Secrets Manager API.
+	// Default: https://secretsmanager.<region>.amazonaws.com
+	Endpoint string
+
+	// IMDSURL is the base URL of the instance metadata service.
+	// Default: DefaultAWSIMDSURL
+	IMDSURL string
+
+	// Client sends the requests.
+	// Default: http.DefaultClient
+	Client *http.Client
+
+	// now returns the signing time; tests replace it.
+	now func() time.Time
+}
+
+// awsCredentials are the credentials requests are signed with.
+type awsCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"Token"`
+}
+
+// Read returns the secret string of the secret ref.Name, or its key
+// ref.Params["key"].
+func (s *AWSSource) Read(ctx context.Context, ref Ref) (string, error) {
+	if ref.Name == "" {
+		return "", errors.New("tokensource: aws-sm: secret ID is empty")
+	}
+	imds := &awsIMDS{baseURL: strings.TrimRight(firstNonEmpty(s.IMDSURL, DefaultAWSIMDSURL), "/"), client: httpClient(s.Client)}
+
+	region := firstNonEmpty(ref.Params.Get("region"), os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+	if region == "" {
+		r, err := imds.get(ctx, "/latest/meta-data/placement/region")
+		if err != nil {
+			return "", fmt.Errorf("tokensource: aws-sm: no region (set the region parameter or AWS_REGION): %w", err)
+		}
+		region = r
+	}
+	creds := awsCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		c, err := imds.credentials(ctx)
+		if err != nil {
+			return "", fmt.Errorf("tokensource: aws-sm: %w (set AWS_ACCESS_KEY_ID or attach an instance profile): %w", errNoCredentials, err)
+		}
+		creds = *c
+	}
+
+	input := map[string]string{"SecretId": ref.Name}
+	if stage := ref.Params.Get("stage"); stage != "" {
+		input["VersionStage"] = stage
+	}
+	payload, err := json.Marshal(input)
+	if err != nil {
+		return "", fmt.Errorf("tokensource: aws-sm: %w", err)
+	}
+	endpoint := firstNonEmpty(s.Endpoint, "https://secretsmanager."+region+".amazonaws.com")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(endpoint, "/")+"/", bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("tokensource: aws-sm: create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	signV4(req, payload, creds, region, "secretsmanager", now())
+
+	resp, err := httpClient(s.Client).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("tokensource: aws-sm: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := readResponse(resp, SchemeAWS)
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		SecretString *string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return "", fmt.Errorf("tokensource: aws-sm: decode response: %w", err)
+	}
+	if out.SecretString == nil {
+		return "", fmt.Errorf("tokensource: aws-sm: secret %q has no secret string", ref.Name)
+	}
+	key := ref.Params.Get("key")
+	if key == "" {
+		return *out.SecretString, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(*out.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("tokensource: aws-sm: secret %q is not a JSON object", ref.Name)
+	}
+	var value string
+	if err := json.Unmarshal(fields[key], &value); err != nil {
+		return "", fmt.Errorf("tokensource: aws-sm: secret %q has no string key %q", ref.Name, key)
+	}
+	return value, nil
+}
+
+// awsIMDS reads from the EC2 instance metadata service with IMDSv2.
+type awsIMDS struct {
+	baseURL string
+	client  *http.Client
+	session string
+}
+
+// get returns the metadata at path.
+func (m *awsIMDS) get(ctx context.Context, path string) (string, error) {
+	if m.session == "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, m.baseURL+"/latest/api/token", nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "300")
+		resp, err := m.client.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("instance metadata: %w", err)
+		}
+		body, err := readResponse(resp, "instance metadata")
+		resp.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		m.session = strings.TrimSpace(string(body))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.baseURL+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", m.session)
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("instance metadata: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := readResponse(resp, "instance metadata")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// credentials returns the credentials of the instance profile.
+func (m *awsIMDS) credentials(ctx context.Context) (*awsCredentials, error) {
+	const path = "/latest/meta-data/iam/security-credentials/"
+	roles, err := m.get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	role, _, _ := strings.Cut(roles, "\n")
+	if role == "" {
+		return nil, errors.New("instance metadata: no instance profile")
+	}
+	data, err := m.get(ctx, path+role)
+	if err != nil {
+		return nil, err
+	}
+	var creds awsCredentials
+	if err := json.Unmarshal([]byte(data), &creds); err != nil || creds.AccessKeyID == "" {
+		return nil, fmt.Errorf("instance metadata: invalid credentials of role %q", role)
+	}
+	return &creds, nil
+}
+
+// signV4 signs req with AWS Signature Version 4. Every header set on req
+// is signed, together with Host and the X-Amz-Date and
+// X-Amz-Security-Token headers signV4 adds.
+func signV4(req *http.Request, payload []byte, creds awsCredentials, region, service string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(payload)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+creds.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	io.WriteString(h, data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,90 @@
+package tokensource
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignV4(t *testing.T) {
+	// The get-vanilla case of the AWS Signature Version 4 test suite.
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	creds := awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signV4(req, nil, creds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAWSSource(t *testing.T) {
+	imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && r.URL.Path == "/latest/api/token" {
+			w.Write([]byte("imds-session"))
+			return
+		}
+		if r.Header.Get("X-aws-ec2-metadata-token") != "imds-session" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/latest/meta-data/placement/region":
+			w.Write([]byte("eu-central-1"))
+		case "/latest/meta-data/iam/security-credentials/":
+			w.Write([]byte("plexd-node\n"))
+		case "/latest/meta-data/iam/security-credentials/plexd-node":
+			w.Write([]byte(`{"Code":"Success","AccessKeyId":"ASIATEST","SecretAccessKey":"secret","Token":"session"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer imds.Close()
+
+	var gotReq map[string]string
+	sm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=ASIATEST/20250102/eu-central-1/secretsmanager/aws4_request, ") ||
+			r.Header.Get("X-Amz-Security-Token") != "session" ||
+			r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&gotReq)
+		if gotReq["SecretId"] == "json-secret" {
+			w.Write([]byte(`{"SecretString":"{\"token\":\"aws-json-token\"}"}`))
+			return
+		}
+		w.Write([]byte(`{"ARN":"arn","Name":"plexd","SecretString":"aws-token"}`))
+	}))
+	defer sm.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	src := &AWSSource{Endpoint: sm.URL, IMDSURL: imds.URL, now: func() time.Time {
+		return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	}}
+
+	got, err := src.Read(context.Background(), Ref{Scheme: SchemeAWS, Name: "plexd", Params: url.Values{"stage": {"AWSPENDING"}}})
+	if err != nil || got != "aws-token" {
+		t.Fatalf("Read() = %q, %v, want aws-token", got, err)
+	}
+	if gotReq["SecretId"] != "plexd" || gotReq["VersionStage"] != "AWSPENDING" {
+		t.Errorf("request = %v", gotReq)
+	}
+
+	got, err = src.Read(context.Background(), Ref{Scheme: SchemeAWS, Name: "json-secret", Params: url.Values{"key": {"token"}}})
+	if err != nil || got != "aws-json-token" {
+		t.Errorf("Read(key=token) = %q, %v, want aws-json-token", got, err)
+	}
+	if _, err := src.Read(context.Background(), Ref{Scheme: SchemeAWS, Name: "json-secret", Params: url.Values{"key": {"missing"}}}); err == nil {
+		t.Error("Read(key=missing) = nil error")
+	}
+}
@@ -0,0 +1,41 @@
+package tokensource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CredentialSource reads systemd service credentials, which systemd passes
+// to a service with LoadCredential= or LoadCredentialEncrypted= in a
+// private directory. The name of a reference is the credential ID.
+type CredentialSource struct {
+	// Dir is the credentials directory.
+	// Default: $CREDENTIALS_DIRECTORY
+	Dir string
+}
+
+// Read returns the content of the credential ref.Name.
+func (s *CredentialSource) Read(_ context.Context, ref Ref) (string, error) {
+	dir := s.Dir
+	if dir == "" {
+		dir = os.Getenv("CREDENTIALS_DIRECTORY")
+	}
+	if dir == "" {
+		return "", errors.New("tokensource: systemd-creds: CREDENTIALS_DIRECTORY is not set (add LoadCredential= to the service)")
+	}
+	if ref.Name == "" || ref.Name == "." || ref.Name == ".." || strings.ContainsAny(ref.Name, `/\`) {
+		return "", fmt.Errorf("tokensource: systemd-creds: invalid credential ID %q", ref.Name)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ref.Name))
+	if err != nil {
+		return "", fmt.Errorf("tokensource: systemd-creds: %w", err)
+	}
+	if len(data) > maxSecretSize {
+		return "", fmt.Errorf("tokensource: systemd-creds: credential exceeds %d bytes", maxSecretSize)
+	}
+	return string(data), nil
+}
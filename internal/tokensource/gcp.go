@@ -0,0 +1,107 @@
+package tokensource
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Default endpoints of GCPSource.
+const (
+	DefaultGCPMetadataURL = "http://metadata.google.internal"
+	DefaultGCPAPIURL      = "https://secretmanager.googleapis.com"
+)
+
+// GCPSource reads secrets from GCP Secret Manager with the access token of
+// the instance's service account, taken from the metadata server. The name
+// of a reference is the resource name of the secret, optionally with a
+// version, e.g. "projects/my-project/secrets/plexd-token"; the latest
+// version is read by default.
+type GCPSource struct {
+	// MetadataURL is the base URL of the metadata server.
+	// Default: DefaultGCPMetadataURL
+	MetadataURL string
+
+	// APIURL is the base URL of the Secret Manager API.
+	// Default: DefaultGCPAPIURL
+	APIURL string
+
+	// Client sends the requests.
+	// Default: http.DefaultClient
+	Client *http.Client
+}
+
+// Read returns the payload of the secret version ref.Name.
+func (s *GCPSource) Read(ctx context.Context, ref Ref) (string, error) {
+	parts := strings.Split(strings.Trim(ref.Name, "/"), "/")
+	if (len(parts) != 4 && len(parts) != 6) || parts[0] != "projects" || parts[2] != "secrets" || (len(parts) == 6 && parts[4] != "versions") {
+		return "", fmt.Errorf("tokensource: gcp-sm: invalid secret %q (want projects/<project>/secrets/<secret>[/versions/<version>])", ref.Name)
+	}
+	name := strings.Join(parts, "/")
+	if len(parts) == 4 {
+		name += "/versions/latest"
+	}
+
+	token, err := s.accessToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(firstNonEmpty(s.APIURL, DefaultGCPAPIURL), "/")+"/v1/"+name+":access", nil)
+	if err != nil {
+		return "", fmt.Errorf("tokensource: gcp-sm: create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := httpClient(s.Client).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("tokensource: gcp-sm: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := readResponse(resp, SchemeGCP)
+	if err != nil {
+		return "", err
+	}
+
+	var version struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &version); err != nil {
+		return "", fmt.Errorf("tokensource: gcp-sm: decode response: %w", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(version.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("tokensource: gcp-sm: decode payload: %w", err)
+	}
+	return string(data), nil
+}
+
+// accessToken returns an OAuth access token of the instance's default
+// service account.
+func (s *GCPSource) accessToken(ctx context.Context) (string, error) {
+	url := strings.TrimRight(firstNonEmpty(s.MetadataURL, DefaultGCPMetadataURL), "/") + "/computeMetadata/v1/instance/service-accounts/default/token"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("tokensource: gcp-sm: create request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := httpClient(s.Client).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("tokensource: gcp-sm: metadata server: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := readResponse(resp, SchemeGCP)
+	if err != nil {
+		return "", err
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("tokensource: gcp-sm: metadata server: %w", errNoCredentials)
+	}
+	return token.AccessToken, nil
+}
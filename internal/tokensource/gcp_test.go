@@ -0,0 +1,46 @@
+package tokensource
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGCPSource(t *testing.T) {
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" || r.URL.Path != "/computeMetadata/v1/instance/service-accounts/default/token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"access_token":"ya29.test","expires_in":3599,"token_type":"Bearer"}`))
+	}))
+	defer metadata.Close()
+
+	var paths []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ya29.test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		paths = append(paths, r.URL.Path)
+		// "gcp-token" base64-encoded.
+		w.Write([]byte(`{"name":"x","payload":{"data":"Z2NwLXRva2Vu"}}`))
+	}))
+	defer api.Close()
+
+	src := &GCPSource{MetadataURL: metadata.URL, APIURL: api.URL}
+	for _, name := range []string{"projects/p/secrets/plexd", "projects/p/secrets/plexd/versions/2"} {
+		got, err := src.Read(context.Background(), Ref{Scheme: SchemeGCP, Name: name})
+		if err != nil || got != "gcp-token" {
+			t.Errorf("Read(%s) = %q, %v, want gcp-token", name, got, err)
+		}
+	}
+	want := []string{"/v1/projects/p/secrets/plexd/versions/latest:access", "/v1/projects/p/secrets/plexd/versions/2:access"}
+	if len(paths) != 2 || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+	if _, err := src.Read(context.Background(), Ref{Scheme: SchemeGCP, Name: "plexd"}); err == nil {
+		t.Error("Read(plexd) = nil error, want invalid secret")
+	}
+}
@@ -0,0 +1,179 @@
+// Package tokensource reads bootstrap tokens from secret stores, such as
+// AWS Secrets Manager, GCP Secret Manager, HashiCorp Vault, or systemd
+// credentials, so that tokens need not be baked into images or config files.
+package tokensource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Schemes of the built-in sources.
+const (
+	SchemeAWS     = "aws-sm"
+	SchemeGCP     = "gcp-sm"
+	SchemeVault   = "vault"
+	SchemeSystemd = "systemd-creds"
+)
+
+// DefaultTimeout bounds every request of the built-in sources.
+const DefaultTimeout = 10 * time.Second
+
+// maxSecretSize bounds the responses read from a secret store.
+const maxSecretSize = 64 << 10
+
+// Ref names a secret in a secret store. Its text form is
+// "<scheme>:<name>[?<param>=<value>&...]", for example
+// "vault:secret/plexd?field=token".
+type Ref struct {
+	// Scheme selects the Source, e.g. "vault".
+	Scheme string
+
+	// Name identifies the secret within the store.
+	Name string
+
+	// Params holds source-specific options, e.g. the region of an AWS
+	// secret.
+	Params url.Values
+}
+
+// ParseRef parses the text form of a Ref.
+func ParseRef(s string) (Ref, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return Ref{}, fmt.Errorf("tokensource: invalid reference %q: %w", s, err)
+	}
+	if u.Scheme == "" || u.Opaque == "" {
+		return Ref{}, fmt.Errorf("tokensource: invalid reference %q (want <scheme>:<name>)", s)
+	}
+	name, err := url.PathUnescape(u.Opaque)
+	if err != nil {
+		return Ref{}, fmt.Errorf("tokensource: invalid reference %q: %w", s, err)
+	}
+	return Ref{Scheme: u.Scheme, Name: name, Params: u.Query()}, nil
+}
+
+// String returns the text form of r.
+func (r Ref) String() string {
+	s := r.Scheme + ":" + r.Name
+	if len(r.Params) > 0 {
+		s += "?" + r.Params.Encode()
+	}
+	return s
+}
+
+// Source reads secrets from one kind of secret store.
+type Source interface {
+	Read(ctx context.Context, ref Ref) (string, error)
+}
+
+// Resolver reads secrets by reference, dispatching on the scheme to the
+// registered Source. It is safe for concurrent use.
+type Resolver struct {
+	mu      sync.Mutex
+	sources map[string]Source
+}
+
+// NewResolver returns a Resolver with the built-in sources, which take
+// their endpoints and credentials from the environment.
+func NewResolver() *Resolver {
+	client := &http.Client{Timeout: DefaultTimeout}
+	return &Resolver{sources: map[string]Source{
+		SchemeAWS:     &AWSSource{Client: client},
+		SchemeGCP:     &GCPSource{Client: client},
+		SchemeVault:   &VaultSource{Client: client},
+		SchemeSystemd: &CredentialSource{},
+	}}
+}
+
+// Register sets the Source for scheme, replacing a built-in one.
+func (r *Resolver) Register(scheme string, src Source) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sources[scheme] = src
+}
+
+// Schemes returns the registered schemes, sorted.
+func (r *Resolver) Schemes() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	schemes := make([]string, 0, len(r.sources))
+	for s := range r.sources {
+		schemes = append(schemes, s)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Check parses ref and reports an error if its scheme has no Source.
+func (r *Resolver) Check(ref string) error {
+	parsed, err := ParseRef(ref)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	_, ok := r.sources[parsed.Scheme]
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("tokensource: unknown scheme %q (known: %s)", parsed.Scheme, strings.Join(r.Schemes(), ", "))
+	}
+	return nil
+}
+
+// Read returns the secret named by ref with surrounding whitespace
+// trimmed. An empty secret is an error.
+func (r *Resolver) Read(ctx context.Context, ref string) (string, error) {
+	if err := r.Check(ref); err != nil {
+		return "", err
+	}
+	parsed, _ := ParseRef(ref)
+	r.mu.Lock()
+	src := r.sources[parsed.Scheme]
+	r.mu.Unlock()
+
+	secret, err := src.Read(ctx, parsed)
+	if err != nil {
+		return "", err
+	}
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return "", fmt.Errorf("tokensource: %s: secret is empty", parsed.Scheme)
+	}
+	return secret, nil
+}
+
+// readResponse reads the body of a successful response, limited to
+// maxSecretSize.
+func readResponse(resp *http.Response, scheme string) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSecretSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("tokensource: %s: read response: %w", scheme, err)
+	}
+	if len(body) > maxSecretSize {
+		return nil, fmt.Errorf("tokensource: %s: response exceeds %d bytes", scheme, maxSecretSize)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tokensource: %s: unexpected status %d: %s", scheme, resp.StatusCode, firstLine(body))
+	}
+	return body, nil
+}
+
+// firstLine returns the first line of an error response, shortened.
+func firstLine(body []byte) string {
+	s, _, _ := strings.Cut(strings.TrimSpace(string(body)), "\n")
+	if len(s) > 200 {
+		s = s[:200]
+	}
+	return s
+}
+
+// errNoCredentials is returned by sources that find no credentials.
+var errNoCredentials = errors.New("no credentials")
@@ -0,0 +1,91 @@
+package tokensource
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		in     string
+		scheme string
+		name   string
+		param  string
+		err    bool
+	}{
+		{in: "vault:secret/plexd?field=bootstrap", scheme: "vault", name: "secret/plexd", param: "bootstrap"},
+		{in: "aws-sm:arn:aws:secretsmanager:eu-central-1:123456789012:secret:plexd-AbCdEf", scheme: "aws-sm", name: "arn:aws:secretsmanager:eu-central-1:123456789012:secret:plexd-AbCdEf"},
+		{in: "gcp-sm:projects/p/secrets/plexd-token/versions/3", scheme: "gcp-sm", name: "projects/p/secrets/plexd-token/versions/3"},
+		{in: "systemd-creds:plexd-bootstrap-token", scheme: "systemd-creds", name: "plexd-bootstrap-token"},
+		{in: "vault://secret/plexd", err: true},
+		{in: "plain-token", err: true},
+		{in: "vault:", err: true},
+	}
+	for _, tt := range tests {
+		ref, err := ParseRef(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseRef(%q) = %+v, want error", tt.in, ref)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRef(%q): %v", tt.in, err)
+			continue
+		}
+		if ref.Scheme != tt.scheme || ref.Name != tt.name || ref.Params.Get("field") != tt.param {
+			t.Errorf("ParseRef(%q) = %+v", tt.in, ref)
+		}
+		if ref.String() != tt.in {
+			t.Errorf("String() = %q, want %q", ref.String(), tt.in)
+		}
+	}
+}
+
+type staticSource string
+
+func (s staticSource) Read(context.Context, Ref) (string, error) { return string(s), nil }
+
+func TestResolver_Read(t *testing.T) {
+	r := NewResolver()
+	r.Register("test", staticSource("  token-123\n"))
+	r.Register("empty", staticSource(" \n"))
+
+	got, err := r.Read(context.Background(), "test:anything")
+	if err != nil || got != "token-123" {
+		t.Errorf("Read(test) = %q, %v, want token-123", got, err)
+	}
+	if _, err := r.Read(context.Background(), "empty:anything"); err == nil || !strings.Contains(err.Error(), "secret is empty") {
+		t.Errorf("Read(empty) = %v, want empty secret error", err)
+	}
+	if err := r.Check("nope:x"); err == nil || !strings.Contains(err.Error(), `unknown scheme "nope"`) || !strings.Contains(err.Error(), "aws-sm, empty, gcp-sm, systemd-creds, test, vault") {
+		t.Errorf("Check(nope) = %v, want unknown scheme error listing the schemes", err)
+	}
+}
+
+func TestCredentialSource(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "plexd-token"), []byte("cred-token\n"), 0o400); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+
+	r := NewResolver()
+	got, err := r.Read(context.Background(), "systemd-creds:plexd-token")
+	if err != nil || got != "cred-token" {
+		t.Errorf("Read() = %q, %v, want cred-token", got, err)
+	}
+	for _, ref := range []string{"systemd-creds:../plexd-token", "systemd-creds:missing"} {
+		if _, err := r.Read(context.Background(), ref); err == nil {
+			t.Errorf("Read(%q) = nil error", ref)
+		}
+	}
+
+	t.Setenv("CREDENTIALS_DIRECTORY", "")
+	if _, err := r.Read(context.Background(), "systemd-creds:plexd-token"); err == nil || !strings.Contains(err.Error(), "LoadCredential") {
+		t.Errorf("Read() without CREDENTIALS_DIRECTORY = %v, want LoadCredential hint", err)
+	}
+}
@@ -0,0 +1,130 @@
+package tokensource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// VaultSource reads secrets from a HashiCorp Vault KV secrets engine. The
+// name of a reference is "<mount>/<path>", e.g. "secret/plexd". Params:
+//
+//   - field: the key within the secret (default "token")
+//   - kv: the KV engine version, "1" or "2" (default "2")
+type VaultSource struct {
+	// Addr is the Vault address.
+	// Default: $VAULT_ADDR
+	Addr string
+
+	// Token authenticates to Vault.
+	// Default: $VAULT_TOKEN, else the content of $VAULT_TOKEN_FILE
+	Token string
+
+	// Namespace is the Vault Enterprise namespace.
+	// Default: $VAULT_NAMESPACE
+	Namespace string
+
+	// Client sends the requests.
+	// Default: http.DefaultClient
+	Client *http.Client
+}
+
+// Read returns the field of the secret ref.Name.
+func (s *VaultSource) Read(ctx context.Context, ref Ref) (string, error) {
+	addr := firstNonEmpty(s.Addr, os.Getenv("VAULT_ADDR"))
+	if addr == "" {
+		return "", errors.New("tokensource: vault: VAULT_ADDR is not set")
+	}
+	token, err := s.token()
+	if err != nil {
+		return "", err
+	}
+	mount, path, ok := strings.Cut(strings.Trim(ref.Name, "/"), "/")
+	if !ok || mount == "" || path == "" {
+		return "", fmt.Errorf("tokensource: vault: invalid secret %q (want <mount>/<path>)", ref.Name)
+	}
+	field := firstNonEmpty(ref.Params.Get("field"), "token")
+
+	url := strings.TrimRight(addr, "/") + "/v1/" + mount + "/data/" + path
+	switch ref.Params.Get("kv") {
+	case "", "2":
+	case "1":
+		url = strings.TrimRight(addr, "/") + "/v1/" + mount + "/" + path
+	default:
+		return "", fmt.Errorf("tokensource: vault: invalid kv version %q (must be 1 or 2)", ref.Params.Get("kv"))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("tokensource: vault: create request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := firstNonEmpty(s.Namespace, os.Getenv("VAULT_NAMESPACE")); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	resp, err := httpClient(s.Client).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("tokensource: vault: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := readResponse(resp, SchemeVault)
+	if err != nil {
+		return "", err
+	}
+
+	var secret struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", fmt.Errorf("tokensource: vault: decode response: %w", err)
+	}
+	data := secret.Data
+	if ref.Params.Get("kv") != "1" {
+		data = nil
+		if err := json.Unmarshal(secret.Data["data"], &data); err != nil {
+			return "", fmt.Errorf("tokensource: vault: decode response: %w", err)
+		}
+	}
+	var value string
+	if err := json.Unmarshal(data[field], &value); err != nil {
+		return "", fmt.Errorf("tokensource: vault: secret %q has no string field %q", ref.Name, field)
+	}
+	return value, nil
+}
+
+// token returns the Vault token.
+func (s *VaultSource) token() (string, error) {
+	if token := firstNonEmpty(s.Token, os.Getenv("VAULT_TOKEN")); token != "" {
+		return token, nil
+	}
+	if path := os.Getenv("VAULT_TOKEN_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("tokensource: vault: read token file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return "", fmt.Errorf("tokensource: vault: %w (set VAULT_TOKEN or VAULT_TOKEN_FILE)", errNoCredentials)
+}
+
+// httpClient returns c, or http.DefaultClient if c is nil.
+func httpClient(c *http.Client) *http.Client {
+	if c == nil {
+		return http.DefaultClient
+	}
+	return c
+}
+
+// firstNonEmpty returns the first of values that is not empty.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package tokensource
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestVaultSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" || r.Header.Get("X-Vault-Namespace") != "team" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/plexd":
+			w.Write([]byte(`{"data":{"data":{"token":"kv2-token","other":"x"},"metadata":{"version":3}}}`))
+		case "/v1/kv/plexd":
+			w.Write([]byte(`{"data":{"bootstrap":"kv1-token"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer srv.Close()
+
+	src := &VaultSource{Addr: srv.URL, Token: "vault-token", Namespace: "team"}
+	tests := []struct {
+		name   string
+		params url.Values
+		want   string
+		err    bool
+	}{
+		{name: "secret/plexd", want: "kv2-token"},
+		{name: "kv/plexd", params: url.Values{"kv": {"1"}, "field": {"bootstrap"}}, want: "kv1-token"},
+		{name: "secret/plexd", params: url.Values{"field": {"missing"}}, err: true},
+		{name: "secret/absent", err: true},
+		{name: "secret", err: true},
+		{name: "secret/plexd", params: url.Values{"kv": {"3"}}, err: true},
+	}
+	for _, tt := range tests {
+		got, err := src.Read(context.Background(), Ref{Scheme: SchemeVault, Name: tt.name, Params: tt.params})
+		if tt.err {
+			if err == nil {
+				t.Errorf("Read(%s %v) = %q, want error", tt.name, tt.params, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Read(%s %v) = %q, %v, want %q", tt.name, tt.params, got, err, tt.want)
+		}
+	}
+
+	t.Setenv("VAULT_TOKEN", "")
+	t.Setenv("VAULT_TOKEN_FILE", "")
+	if _, err := (&VaultSource{Addr: srv.URL}).Read(context.Background(), Ref{Name: "secret/plexd"}); err == nil {
+		t.Error("Read() without a token = nil error")
+	}
+}
@@ -0,0 +1,124 @@
+package tunnel
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// ACL restricts a port-forward session: which mesh peers may connect to its
+// listener and, for SOCKS5 sessions, which destinations they may reach.
+type ACL struct {
+	sources      []netip.Prefix
+	destinations []destinationRule
+}
+
+// destinationRule allows connections to prefix on port, or on any port if
+// port is 0.
+type destinationRule struct {
+	prefix netip.Prefix
+	port   int
+}
+
+// ParseACL parses the allowed sources (IPs or CIDRs) and destinations (IPs
+// or CIDRs with an optional port, IPv6 in brackets when a port is given).
+func ParseACL(sources, destinations []string) (*ACL, error) {
+	acl := &ACL{}
+	for _, s := range sources {
+		p, err := parsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("tunnel: acl: invalid source %q: %w", s, err)
+		}
+		acl.sources = append(acl.sources, p)
+	}
+	for _, d := range destinations {
+		rule, err := parseDestinationRule(d)
+		if err != nil {
+			return nil, fmt.Errorf("tunnel: acl: invalid destination %q: %w", d, err)
+		}
+		acl.destinations = append(acl.destinations, rule)
+	}
+	return acl, nil
+}
+
+// AllowSource reports whether a client at addr may connect. An ACL without
+// sources allows every client.
+func (a *ACL) AllowSource(addr net.Addr) bool {
+	if len(a.sources) == 0 {
+		return true
+	}
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	ip := tcp.AddrPort().Addr().Unmap()
+	for _, p := range a.sources {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowDestination reports whether a connection to ip:port is allowed. An
+// ACL without destinations allows none.
+func (a *ACL) AllowDestination(ip netip.Addr, port int) bool {
+	ip = ip.Unmap()
+	for _, r := range a.destinations {
+		if r.prefix.Contains(ip) && (r.port == 0 || r.port == port) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDestinationRule parses "prefix", "prefix:port", or "[prefix]:port".
+func parseDestinationRule(s string) (destinationRule, error) {
+	host, portStr := s, ""
+	switch {
+	case strings.HasPrefix(s, "["):
+		end := strings.Index(s, "]")
+		if end < 0 {
+			return destinationRule{}, fmt.Errorf("missing ]")
+		}
+		host, portStr = s[1:end], strings.TrimPrefix(s[end+1:], ":")
+		if portStr == "" && end+1 != len(s) {
+			return destinationRule{}, fmt.Errorf("unexpected %q after ]", s[end+1:])
+		}
+	case strings.Count(s, ":") == 1:
+		host, portStr, _ = strings.Cut(s, ":")
+	}
+
+	p, err := parsePrefix(host)
+	if err != nil {
+		return destinationRule{}, err
+	}
+	rule := destinationRule{prefix: p}
+	if portStr != "" {
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port < 1 || port > 65535 {
+			return destinationRule{}, fmt.Errorf("invalid port %q", portStr)
+		}
+		rule.port = port
+	}
+	return rule, nil
+}
+
+// parsePrefix parses a CIDR or a single IP.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return p.Masked(), nil
+	}
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	ip = ip.Unmap()
+	return netip.PrefixFrom(ip, ip.BitLen()), nil
+}
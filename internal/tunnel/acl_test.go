@@ -0,0 +1,90 @@
+package tunnel
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestParseACL_Destinations(t *testing.T) {
+	acl, err := ParseACL(nil, []string{
+		"10.0.0.0/24",
+		"10.1.0.5:5432",
+		"192.168.0.0/16:443",
+		"fd00::/64",
+		"[fd01::/64]:22",
+		"[fd02::1]",
+	})
+	if err != nil {
+		t.Fatalf("ParseACL: %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		port int
+		want bool
+	}{
+		{"10.0.0.7", 80, true},
+		{"10.0.1.7", 80, false},
+		{"10.1.0.5", 5432, true},
+		{"10.1.0.5", 5433, false},
+		{"192.168.3.4", 443, true},
+		{"192.168.3.4", 80, false},
+		{"fd00::5", 8080, true},
+		{"fd01::5", 22, true},
+		{"fd01::5", 23, false},
+		{"fd02::1", 1, true},
+		{"::ffff:10.0.0.7", 80, true},
+		{"8.8.8.8", 53, false},
+	}
+	for _, tt := range tests {
+		if got := acl.AllowDestination(netip.MustParseAddr(tt.ip), tt.port); got != tt.want {
+			t.Errorf("AllowDestination(%s, %d) = %v, want %v", tt.ip, tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestParseACL_Sources(t *testing.T) {
+	acl, err := ParseACL([]string{"10.99.0.0/16", "10.100.0.1"}, nil)
+	if err != nil {
+		t.Fatalf("ParseACL: %v", err)
+	}
+	tests := map[string]bool{
+		"10.99.4.5":  true,
+		"10.100.0.1": true,
+		"10.100.0.2": false,
+		"192.0.2.1":  false,
+	}
+	for ip, want := range tests {
+		addr := &net.TCPAddr{IP: net.ParseIP(ip), Port: 40000}
+		if got := acl.AllowSource(addr); got != want {
+			t.Errorf("AllowSource(%s) = %v, want %v", ip, got, want)
+		}
+	}
+
+	open, _ := ParseACL(nil, nil)
+	if !open.AllowSource(&net.TCPAddr{IP: net.ParseIP("192.0.2.1")}) {
+		t.Error("ACL without sources rejected a client")
+	}
+	if open.AllowDestination(netip.MustParseAddr("10.0.0.1"), 80) {
+		t.Error("ACL without destinations allowed a destination")
+	}
+}
+
+func TestParseACL_Invalid(t *testing.T) {
+	for _, tt := range []struct {
+		sources, destinations []string
+	}{
+		{[]string{"not-an-ip"}, nil},
+		{[]string{"10.0.0.0/33"}, nil},
+		{nil, []string{"example.com:443"}},
+		{nil, []string{"10.0.0.1:0"}},
+		{nil, []string{"10.0.0.1:70000"}},
+		{nil, []string{"[fd00::1"}},
+		{nil, []string{"[fd00::1]x"}},
+	} {
+		if _, err := ParseACL(tt.sources, tt.destinations); err == nil {
+			t.Errorf("ParseACL(%q, %q) = nil error, want error", tt.sources, tt.destinations)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package tunnel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// maxPendingSessionAudits bounds the audit entries buffered between Collect
+// calls; the oldest are dropped first.
+const maxPendingSessionAudits = 256
+
+// auditEventType is the event type of tunnel session audit entries.
+const auditEventType = "tunnel_session"
+
+// Collect returns and clears the audit entries recorded since the last call:
+// one per session start and one per session stop. It implements
+// auditfwd.AuditSource.
+func (m *SessionManager) Collect(_ context.Context) ([]api.AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := m.audits
+	m.audits = nil
+	return entries, nil
+}
+
+// queueAudit buffers entry. The caller holds m.mu.
+func (m *SessionManager) queueAudit(entry api.AuditEntry) {
+	m.audits = append(m.audits, entry)
+	if over := len(m.audits) - maxPendingSessionAudits; over > 0 {
+		m.audits = m.audits[over:]
+	}
+}
+
+// sessionStartAudit returns the audit entry for a started session.
+func sessionStartAudit(s *Session, listenAddr, hostname string) api.AuditEntry {
+	object, _ := json.Marshal(map[string]any{
+		"session_id":  s.SessionID,
+		"kind":        s.kind,
+		"target":      s.target(),
+		"listen_addr": listenAddr,
+		"expires_at":  s.expiresAt.UTC(),
+		"recorded":    s.recorder != nil,
+	})
+	return sessionAudit(s, "start", object, hostname,
+		fmt.Sprintf("tunnel session %s started by %s", s.SessionID, describeTrigger(s.triggeredBy)))
+}
+
+// sessionStopAudit returns the audit entry for a closed session.
+func sessionStopAudit(s *Session, reason string, duration time.Duration, recorded bool, hostname string) api.AuditEntry {
+	object, _ := json.Marshal(map[string]any{
+		"session_id": s.SessionID,
+		"kind":       s.kind,
+		"target":     s.target(),
+		"reason":     reason,
+		"duration":   duration.Round(time.Millisecond).String(),
+		"recorded":   recorded,
+	})
+	return sessionAudit(s, "stop", object, hostname,
+		fmt.Sprintf("tunnel session %s started by %s stopped: %s", s.SessionID, describeTrigger(s.triggeredBy), reason))
+}
+
+func sessionAudit(s *Session, action string, object []byte, hostname, raw string) api.AuditEntry {
+	subject, _ := json.Marshal(s.triggeredBy)
+	return api.AuditEntry{
+		Timestamp: time.Now().UTC(),
+		Source:    "plexd",
+		EventType: auditEventType,
+		Subject:   subject,
+		Object:    object,
+		Action:    action,
+		Result:    "success",
+		Hostname:  hostname,
+		Raw:       raw,
+	}
+}
+
+// describeTrigger returns a short description of who triggered a session.
+func describeTrigger(t *api.TriggeredBy) string {
+	switch {
+	case t == nil:
+		return "unknown"
+	case t.Email != "":
+		return t.Email
+	case t.UserID != "":
+		return t.UserID
+	case t.Type != "":
+		return t.Type
+	default:
+		return "unknown"
+	}
+}
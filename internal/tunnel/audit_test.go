@@ -0,0 +1,86 @@
+package tunnel
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+func TestSessionManager_CollectAudits(t *testing.T) {
+	echoAddr := startEchoServer(t)
+	mgr := newTestManager(t, Config{})
+
+	setup := validSetup("aud1", echoAddr)
+	setup.TriggeredBy = &api.TriggeredBy{Type: "user", UserID: "u-1", Email: "ops@example.com"}
+	if _, err := mgr.CreateSession(context.Background(), setup); err != nil {
+		t.Fatalf("CreateSession() error: %v", err)
+	}
+	mgr.CloseSession("aud1", "revoked")
+
+	entries, err := mgr.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	for i, want := range []string{"start", "stop"} {
+		e := entries[i]
+		if e.EventType != "tunnel_session" || e.Action != want || e.Source != "plexd" {
+			t.Errorf("entry %d = %+v, want tunnel_session %s", i, e, want)
+		}
+		var subject api.TriggeredBy
+		if err := json.Unmarshal(e.Subject, &subject); err != nil || subject.Email != "ops@example.com" {
+			t.Errorf("entry %d subject = %s, want triggered_by", i, e.Subject)
+		}
+		if !strings.Contains(e.Raw, "ops@example.com") {
+			t.Errorf("entry %d raw = %q", i, e.Raw)
+		}
+	}
+	var stop map[string]any
+	if err := json.Unmarshal(entries[1].Object, &stop); err != nil {
+		t.Fatal(err)
+	}
+	if stop["reason"] != "revoked" || stop["session_id"] != "aud1" {
+		t.Errorf("stop object = %v", stop)
+	}
+
+	if again, _ := mgr.Collect(context.Background()); len(again) != 0 {
+		t.Errorf("second Collect returned %d entries, want 0", len(again))
+	}
+}
+
+func TestSessionManager_AuditBufferBounded(t *testing.T) {
+	mgr := newTestManager(t, Config{})
+	mgr.mu.Lock()
+	for i := 0; i < maxPendingSessionAudits+10; i++ {
+		mgr.queueAudit(api.AuditEntry{Raw: "x"})
+	}
+	mgr.mu.Unlock()
+
+	entries, _ := mgr.Collect(context.Background())
+	if len(entries) != maxPendingSessionAudits {
+		t.Errorf("got %d entries, want %d", len(entries), maxPendingSessionAudits)
+	}
+}
+
+func TestDescribeTrigger(t *testing.T) {
+	tests := []struct {
+		in   *api.TriggeredBy
+		want string
+	}{
+		{nil, "unknown"},
+		{&api.TriggeredBy{}, "unknown"},
+		{&api.TriggeredBy{Type: "automation"}, "automation"},
+		{&api.TriggeredBy{Type: "user", UserID: "u-1"}, "u-1"},
+		{&api.TriggeredBy{Type: "user", UserID: "u-1", Email: "a@example.com"}, "a@example.com"},
+	}
+	for _, tt := range tests {
+		if got := describeTrigger(tt.in); got != tt.want {
+			t.Errorf("describeTrigger(%+v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package tunnel
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/plexsphere/plexd/internal/fsutil"
+)
+
+// authorizedKeyMarker prefixes the comment of every authorized_keys line
+// written by plexd; the session ID follows it.
+const authorizedKeyMarker = "plexd-session:"
+
+// authorizedKeyOptions restricts a provisioned key to an interactive login:
+// no port, agent, or X11 forwarding.
+const authorizedKeyOptions = "restrict,pty"
+
+// AuthorizedKeys provisions the temporary keys of tunnel sessions into an
+// OpenSSH authorized_keys file, so that the client of a session can log in
+// to sshd on the target. Each key is written with an expiry-time option
+// matching the session expiry, so that sshd refuses it even if plexd stops
+// before removing it. Lines not written by plexd are left untouched.
+type AuthorizedKeys struct {
+	path string
+
+	mu sync.Mutex
+}
+
+// NewAuthorizedKeys returns an AuthorizedKeys that manages path.
+func NewAuthorizedKeys(path string) *AuthorizedKeys {
+	return &AuthorizedKeys{path: path}
+}
+
+// Path returns the managed authorized_keys file.
+func (a *AuthorizedKeys) Path() string {
+	return a.path
+}
+
+// Add provisions authorizedKey (in authorized_keys format, without options)
+// for sessionID until expiresAt. A key already provisioned for sessionID is
+// replaced. sessionID must be a valid session ID, so that it cannot end the
+// line it is written to.
+func (a *AuthorizedKeys) Add(sessionID, authorizedKey string, expiresAt time.Time) error {
+	if err := validateSessionID(sessionID); err != nil {
+		return err
+	}
+	pub, _, options, rest, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+	if err != nil {
+		return fmt.Errorf("tunnel: authorized key: parse: %w", err)
+	}
+	if len(options) > 0 || len(strings.TrimSpace(string(rest))) > 0 {
+		return errors.New("tunnel: authorized key: must be a single key without options")
+	}
+
+	line := fmt.Sprintf("expiry-time=%q,%s %s %s%s",
+		expiresAt.UTC().Format("200601021504Z"),
+		authorizedKeyOptions,
+		strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))),
+		authorizedKeyMarker,
+		sessionID,
+	)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.rewrite(func(l string) bool { return isSessionKey(l, sessionID) }, line)
+}
+
+// Remove deletes the key provisioned for sessionID. Removing a key that is
+// not provisioned is not an error.
+func (a *AuthorizedKeys) Remove(sessionID string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.rewrite(func(l string) bool { return isSessionKey(l, sessionID) }, "")
+}
+
+// RemoveAll deletes every key provisioned by plexd.
+func (a *AuthorizedKeys) RemoveAll() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.rewrite(func(l string) bool { return isSessionKey(l, "") }, "")
+}
+
+// rewrite drops the lines matching drop, appends add if non-empty, and
+// writes the file atomically. The file is not created just to remove lines.
+func (a *AuthorizedKeys) rewrite(drop func(line string) bool, add string) error {
+	data, err := os.ReadFile(a.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("tunnel: authorized key: read %s: %w", a.path, err)
+	}
+	if os.IsNotExist(err) && add == "" {
+		return nil
+	}
+
+	var lines []string
+	changed := false
+	for _, l := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if l == "" && len(lines) == 0 {
+			continue
+		}
+		if drop(l) {
+			changed = true
+			continue
+		}
+		lines = append(lines, l)
+	}
+	if add != "" {
+		lines = append(lines, add)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	out := strings.Join(lines, "\n")
+	if out != "" {
+		out += "\n"
+	}
+	dir := filepath.Dir(a.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("tunnel: authorized key: create dir: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(dir, filepath.Base(a.path), []byte(out), 0o600); err != nil {
+		return fmt.Errorf("tunnel: authorized key: write %s: %w", a.path, err)
+	}
+	return nil
+}
+
+// isSessionKey reports whether line was written by plexd for sessionID, or
+// for any session if sessionID is empty.
+func isSessionKey(line, sessionID string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false
+	}
+	comment := fields[len(fields)-1]
+	if sessionID == "" {
+		return strings.HasPrefix(comment, authorizedKeyMarker)
+	}
+	return comment == authorizedKeyMarker+sessionID
+}
@@ -0,0 +1,138 @@
+package tunnel
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testAuthorizedKey returns a fresh ed25519 public key in authorized_keys format.
+func testAuthorizedKey(t *testing.T) string {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("ssh public key: %v", err)
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub))) + " user@laptop"
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestAuthorizedKeys_AddRejectsInjectedSessionID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	keys := NewAuthorizedKeys(path)
+	expires := time.Now().Add(time.Hour)
+
+	for _, id := range []string{
+		"s1\n" + testAuthorizedKey(t),
+		"s1 " + testAuthorizedKey(t),
+		"",
+	} {
+		if err := keys.Add(id, testAuthorizedKey(t), expires); err == nil {
+			t.Errorf("Add(%q) succeeded", id)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("authorized_keys written: %v", err)
+	}
+}
+
+func TestAuthorizedKeys_AddRemove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ssh", "authorized_keys")
+	keys := NewAuthorizedKeys(path)
+	expires := time.Date(2026, 3, 4, 5, 6, 0, 0, time.UTC)
+
+	if err := keys.Add("s1", testAuthorizedKey(t), expires); err != nil {
+		t.Fatalf("Add(s1): %v", err)
+	}
+	if err := keys.Add("s2", testAuthorizedKey(t), expires); err != nil {
+		t.Fatalf("Add(s2): %v", err)
+	}
+
+	got := readFile(t, path)
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("file has %d lines, want 2:\n%s", len(lines), got)
+	}
+	if !strings.HasPrefix(lines[0], `expiry-time="202603040506Z",restrict,pty ssh-ed25519 `) {
+		t.Errorf("line = %q, want expiry-time and restrict options", lines[0])
+	}
+	if !strings.HasSuffix(lines[0], " plexd-session:s1") || strings.Contains(got, "user@laptop") {
+		t.Errorf("line = %q, want session marker in place of the key comment", lines[0])
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if err := keys.Remove("s1"); err != nil {
+		t.Fatalf("Remove(s1): %v", err)
+	}
+	got = readFile(t, path)
+	if strings.Contains(got, "plexd-session:s1") || !strings.Contains(got, "plexd-session:s2") {
+		t.Errorf("after Remove(s1):\n%s", got)
+	}
+	if err := keys.Remove("s1"); err != nil {
+		t.Errorf("Remove of absent key: %v", err)
+	}
+}
+
+func TestAuthorizedKeys_PreservesForeignLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	foreign := "# managed by hand\n" + testAuthorizedKey(t) + "\n"
+	if err := os.WriteFile(path, []byte(foreign), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys := NewAuthorizedKeys(path)
+
+	if err := keys.Add("s1", testAuthorizedKey(t), time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := keys.Add("s2", testAuthorizedKey(t), time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := keys.RemoveAll(); err != nil {
+		t.Fatalf("RemoveAll: %v", err)
+	}
+	if got := readFile(t, path); got != foreign {
+		t.Errorf("file = %q, want foreign lines only %q", got, foreign)
+	}
+}
+
+func TestAuthorizedKeys_RejectsInvalidKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	keys := NewAuthorizedKeys(path)
+
+	for _, key := range []string{
+		"not a key",
+		`command="/bin/sh" ` + testAuthorizedKey(t),
+		testAuthorizedKey(t) + "\n" + testAuthorizedKey(t),
+	} {
+		if err := keys.Add("s1", key, time.Now().Add(time.Hour)); err == nil {
+			t.Errorf("Add(%q) = nil error, want error", key)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file created for rejected keys: %v", err)
+	}
+}
@@ -12,6 +12,10 @@ const DefaultMaxSessions = 10
 // DefaultTimeout is the default session timeout.
 const DefaultTimeout = 30 * time.Minute
 
+// DefaultMaxForwardConnections is the default maximum number of concurrent
+// connections per port-forward or SOCKS5 session.
+const DefaultMaxForwardConnections = 16
+
 // Config holds the configuration for secure access tunneling.
 type Config struct {
 	// Enabled controls whether tunneling is active.
@@ -26,6 +30,11 @@ type Config struct {
 	// Default: 30m
 	DefaultTimeout time.Duration
 
+	// MaxForwardConnections is the maximum number of concurrent connections
+	// per port-forward or SOCKS5 session. SSH sessions allow one.
+	// Default: 16
+	MaxForwardConnections int
+
 	// SSHListenAddr is the address for the SSH mesh server to listen on.
 	// If empty, the SSH server is not started.
 	SSHListenAddr string
@@ -33,6 +42,26 @@ type Config struct {
 	// HostKeyDir is the directory for storing the SSH host key.
 	// If empty, a transient key is generated (not persisted).
 	HostKeyDir string
+
+	// AuthorizedKeysFile is the OpenSSH authorized_keys file into which the
+	// temporary key of each session is provisioned, with an expiry-time
+	// matching the session. sshd on the node must read this file (see
+	// AuthorizedKeysFile in sshd_config). If empty, keys are not provisioned.
+	AuthorizedKeysFile string
+
+	// RecordSessions enables recording the input and output of each session
+	// in asciicast v2 format. Recordings are uploaded to the control plane
+	// when the session closes.
+	RecordSessions bool
+
+	// RecordingDir is the directory recordings are written to. plexd up
+	// defaults it to {data_dir}/recordings.
+	RecordingDir string
+
+	// RecordingMaxBytes is the size limit of a single recording; events past
+	// it are dropped and the recording is marked truncated.
+	// Default: 10 MiB
+	RecordingMaxBytes int64
 }
 
 // ApplyDefaults sets default values for zero-valued fields.
@@ -50,6 +79,12 @@ func (c *Config) ApplyDefaults() {
 	if c.DefaultTimeout == 0 {
 		c.DefaultTimeout = DefaultTimeout
 	}
+	if c.MaxForwardConnections == 0 {
+		c.MaxForwardConnections = DefaultMaxForwardConnections
+	}
+	if c.RecordingMaxBytes == 0 {
+		c.RecordingMaxBytes = DefaultRecordingMaxBytes
+	}
 }
 
 // Validate checks that configuration values are within acceptable ranges.
@@ -63,5 +98,11 @@ func (c *Config) Validate() error {
 	if c.DefaultTimeout < time.Minute {
 		return errors.New("tunnel: config: DefaultTimeout must be at least 1m when enabled")
 	}
+	if c.MaxForwardConnections < 0 {
+		return errors.New("tunnel: config: MaxForwardConnections must not be negative")
+	}
+	if c.RecordingMaxBytes < 0 {
+		return errors.New("tunnel: config: RecordingMaxBytes must not be negative")
+	}
 	return nil
 }
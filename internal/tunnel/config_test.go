@@ -18,6 +18,12 @@ func TestConfig_Defaults(t *testing.T) {
 	if cfg.DefaultTimeout != DefaultTimeout {
 		t.Errorf("DefaultTimeout = %v, want %v", cfg.DefaultTimeout, DefaultTimeout)
 	}
+	if cfg.MaxForwardConnections != DefaultMaxForwardConnections {
+		t.Errorf("MaxForwardConnections = %d, want %d", cfg.MaxForwardConnections, DefaultMaxForwardConnections)
+	}
+	if cfg.RecordingMaxBytes != DefaultRecordingMaxBytes {
+		t.Errorf("RecordingMaxBytes = %d, want %d", cfg.RecordingMaxBytes, DefaultRecordingMaxBytes)
+	}
 }
 
 func TestConfig_DefaultsPreserveExplicitDisabled(t *testing.T) {
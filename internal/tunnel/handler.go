@@ -39,6 +39,30 @@ func HandleSSHSessionSetup(mgr *SessionManager, reporter TunnelReporter) api.Eve
 	}
 }
 
+// HandlePortForwardSetup returns an api.EventHandler for port_forward_setup
+// events. It parses the SSE payload, creates a port-forward or SOCKS5 session
+// via the SessionManager, and reports readiness via the TunnelReporter.
+func HandlePortForwardSetup(mgr *SessionManager, reporter TunnelReporter) api.EventHandler {
+	return func(ctx context.Context, envelope api.SignedEnvelope) error {
+		var setup api.PortForwardSetup
+		if err := json.Unmarshal(envelope.Payload, &setup); err != nil {
+			mgr.logger.Error("port_forward_setup: parse payload failed",
+				"event_id", envelope.EventID,
+				"error", err,
+			)
+			return fmt.Errorf("tunnel: port_forward_setup: parse payload: %w", err)
+		}
+
+		addr, err := mgr.CreateForward(ctx, setup)
+		if err != nil {
+			return fmt.Errorf("tunnel: port_forward_setup: %w", err)
+		}
+
+		reporter.ReportReady(ctx, setup.SessionID, addr)
+		return nil
+	}
+}
+
 // HandleSessionRevoked returns an api.EventHandler for session_revoked events.
 // It looks up the session by ID and closes it with reason "revoked".
 // Revoking a non-existent session is a no-op.
@@ -118,6 +118,37 @@ func TestSSEHandler_SSHSessionSetup_MalformedPayload(t *testing.T) {
 	}
 }
 
+func TestSSEHandler_PortForwardSetup(t *testing.T) {
+	mgr := newTestManager(t, Config{})
+	reporter := &mockReporter{}
+
+	handler := HandlePortForwardSetup(mgr, reporter)
+
+	setup := api.PortForwardSetup{
+		SessionID:           "fwd-setup-1",
+		Mode:                api.PortForwardSOCKS5,
+		AllowedDestinations: []string{"10.0.0.0/8:5432"},
+		ExpiresAt:           time.Now().Add(5 * time.Minute),
+	}
+	if err := handler(context.Background(), testEnvelope(api.EventPortForwardSetup, setup)); err != nil {
+		t.Fatalf("HandlePortForwardSetup() error: %v", err)
+	}
+	if mgr.ActiveCount() != 1 {
+		t.Errorf("expected ActiveCount()=1, got %d", mgr.ActiveCount())
+	}
+
+	bad := api.SignedEnvelope{EventType: api.EventPortForwardSetup, EventID: "evt-bad", Payload: json.RawMessage("not json")}
+	if err := handler(context.Background(), bad); err == nil {
+		t.Error("expected error for malformed payload")
+	}
+
+	reporter.mu.Lock()
+	defer reporter.mu.Unlock()
+	if len(reporter.readyCalls) != 1 || reporter.readyCalls[0].SessionID != "fwd-setup-1" || reporter.readyCalls[0].ListenAddr == "" {
+		t.Errorf("ReportReady calls = %+v, want one for fwd-setup-1", reporter.readyCalls)
+	}
+}
+
 func TestSSEHandler_SessionRevoked(t *testing.T) {
 	echoAddr := startEchoServer(t)
 	host, portStr, _ := net.SplitHostPort(echoAddr)
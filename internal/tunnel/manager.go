@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"os"
 	"sync"
 	"time"
 
@@ -12,26 +13,70 @@ import (
 
 // SessionManager manages the lifecycle of tunnel sessions.
 type SessionManager struct {
-	cfg    Config
-	meshIP string
-	logger *slog.Logger
+	cfg      Config
+	meshIP   string
+	logger   *slog.Logger
+	keys     *AuthorizedKeys
+	hostname string
 
 	mu       sync.Mutex
 	sessions map[string]*Session
+	reporter TunnelReporter
+	uploader RecordingUploader
+	audits   []api.AuditEntry
 }
 
+// RecordingUploader uploads session recordings when sessions close.
+type RecordingUploader interface {
+	UploadRecording(ctx context.Context, sessionID string, rec api.TunnelRecording) error
+}
+
+// recordingUploadTimeout bounds the upload of a single recording.
+const recordingUploadTimeout = 30 * time.Second
+
 // NewSessionManager creates a new SessionManager with default config applied.
 func NewSessionManager(cfg Config, meshIP string, logger *slog.Logger) *SessionManager {
 	cfg.ApplyDefaults()
-	return &SessionManager{
+	hostname, _ := os.Hostname()
+	m := &SessionManager{
 		cfg:      cfg,
 		meshIP:   meshIP,
 		logger:   logger.With("component", "tunnel"),
+		hostname: hostname,
 		sessions: make(map[string]*Session),
 	}
+	if cfg.AuthorizedKeysFile != "" {
+		m.keys = NewAuthorizedKeys(cfg.AuthorizedKeysFile)
+	}
+	if cfg.RecordSessions && cfg.RecordingDir == "" {
+		m.logger.Warn("session recording enabled without a recording directory, sessions are not recorded")
+		m.cfg.RecordSessions = false
+	}
+	return m
 }
 
-// CreateSession creates and starts a new tunnel session.
+// SetRecordingUploader sets the uploader for session recordings. Without
+// one, recordings are kept in the recording directory.
+func (m *SessionManager) SetRecordingUploader(u RecordingUploader) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.uploader = u
+}
+
+// SetReporter sets the reporter notified when the manager itself closes a
+// session, i.e. on expiry. Closes requested through the event handlers are
+// reported by the handlers.
+func (m *SessionManager) SetReporter(r TunnelReporter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reporter = r
+}
+
+// CreateSession creates and starts a new tunnel session. If an authorized
+// keys file is configured and setup carries an authorized key, the key is
+// provisioned until the session expires and removed when it closes. If
+// recording is enabled, the session's input and output are recorded. The
+// session outlives ctx; it ends on expiry, CloseSession, or Shutdown.
 func (m *SessionManager) CreateSession(ctx context.Context, setup api.SSHSessionSetup) (string, error) {
 	if !m.cfg.Enabled {
 		return "", fmt.Errorf("tunnel: tunneling is disabled")
@@ -40,55 +85,142 @@ func (m *SessionManager) CreateSession(ctx context.Context, setup api.SSHSession
 	if setup.SessionID == "" || setup.TargetHost == "" || setup.TargetPort <= 0 || setup.TargetPort > 65535 {
 		return "", fmt.Errorf("tunnel: invalid session setup: session_id, target_host, and valid target_port (1-65535) are required")
 	}
+	if err := validateSessionID(setup.SessionID); err != nil {
+		return "", err
+	}
 
-	now := time.Now()
-	if setup.ExpiresAt.Before(now) {
-		return "", fmt.Errorf("tunnel: session already expired")
+	expiresAt, err := m.capExpiry(setup.ExpiresAt)
+	if err != nil {
+		return "", err
 	}
 
-	// Cap ExpiresAt at DefaultTimeout from now.
-	maxExpiry := now.Add(m.cfg.DefaultTimeout)
-	expiresAt := setup.ExpiresAt
-	if expiresAt.After(maxExpiry) {
+	session := NewSession(setup.SessionID, setup.TargetHost, setup.TargetPort, m.meshIP, expiresAt, m.logger)
+	session.triggeredBy = setup.TriggeredBy
+	return m.startSession(ctx, session, setup.AuthorizedKey)
+}
+
+// CreateForward creates and starts a port-forward session: a TCP forward to
+// a fixed target, or a SOCKS5 listener limited to the allowed destinations.
+// Both kinds share the lifecycle, limits, and session ID namespace of SSH
+// sessions; CloseSession and Shutdown close them alike.
+func (m *SessionManager) CreateForward(ctx context.Context, setup api.PortForwardSetup) (string, error) {
+	if !m.cfg.Enabled {
+		return "", fmt.Errorf("tunnel: tunneling is disabled")
+	}
+	if setup.SessionID == "" {
+		return "", fmt.Errorf("tunnel: invalid forward setup: session_id is required")
+	}
+	if err := validateSessionID(setup.SessionID); err != nil {
+		return "", err
+	}
+
+	var kind string
+	switch setup.Mode {
+	case api.PortForwardTCP:
+		if setup.TargetHost == "" || setup.TargetPort <= 0 || setup.TargetPort > 65535 {
+			return "", fmt.Errorf("tunnel: invalid forward setup: target_host and valid target_port (1-65535) are required for mode %s", setup.Mode)
+		}
+		kind = KindPortForward
+	case api.PortForwardSOCKS5:
+		if len(setup.AllowedDestinations) == 0 {
+			return "", fmt.Errorf("tunnel: invalid forward setup: allowed_destinations is required for mode %s", setup.Mode)
+		}
+		kind = KindSOCKS5
+	default:
+		return "", fmt.Errorf("tunnel: invalid forward setup: unknown mode %q", setup.Mode)
+	}
+
+	acl, err := ParseACL(setup.AllowedSources, setup.AllowedDestinations)
+	if err != nil {
+		return "", err
+	}
+	expiresAt, err := m.capExpiry(setup.ExpiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	session := NewSession(setup.SessionID, setup.TargetHost, setup.TargetPort, m.meshIP, expiresAt, m.logger)
+	session.triggeredBy = setup.TriggeredBy
+	session.kind = kind
+	session.acl = acl
+	session.maxConns = m.cfg.MaxForwardConnections
+	return m.startSession(ctx, session, "")
+}
+
+// capExpiry rejects an expiry in the past and caps it at DefaultTimeout
+// from now.
+func (m *SessionManager) capExpiry(expiresAt time.Time) (time.Time, error) {
+	now := time.Now()
+	if expiresAt.Before(now) {
+		return time.Time{}, fmt.Errorf("tunnel: session already expired")
+	}
+	if maxExpiry := now.Add(m.cfg.DefaultTimeout); expiresAt.After(maxExpiry) {
 		expiresAt = maxExpiry
 	}
+	return expiresAt, nil
+}
+
+// startSession registers and starts session, provisioning authorizedKey if
+// set, and schedules its expiry.
+func (m *SessionManager) startSession(ctx context.Context, session *Session, authorizedKey string) (string, error) {
+	id := session.SessionID
 
 	m.mu.Lock()
-	if _, exists := m.sessions[setup.SessionID]; exists {
+	if _, exists := m.sessions[id]; exists {
 		m.mu.Unlock()
-		return "", fmt.Errorf("tunnel: duplicate session ID: %s", setup.SessionID)
+		return "", fmt.Errorf("tunnel: duplicate session ID: %s", id)
 	}
 	if len(m.sessions) >= m.cfg.MaxSessions {
 		m.mu.Unlock()
 		return "", fmt.Errorf("tunnel: max sessions reached (%d)", m.cfg.MaxSessions)
 	}
 
-	sessionCtx, cancel := context.WithCancel(ctx)
-	session := NewSession(setup.SessionID, setup.TargetHost, setup.TargetPort, m.meshIP, expiresAt, m.logger)
+	provisioned := false
+	if m.keys != nil && authorizedKey != "" {
+		if err := m.keys.Add(id, authorizedKey, session.expiresAt); err != nil {
+			m.mu.Unlock()
+			return "", err
+		}
+		provisioned = true
+	}
+
+	// The event handler's context ends with the SSE stream, not the session.
+	sessionCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
 	session.cancel = cancel
 
-	addr, err := session.Start(sessionCtx)
+	var err error
+	if m.cfg.RecordSessions {
+		session.recorder, err = NewRecorder(m.cfg.RecordingDir, id, m.cfg.RecordingMaxBytes)
+	}
+	var addr string
+	if err == nil {
+		addr, err = session.Start(sessionCtx)
+	}
 	if err != nil {
 		cancel()
 		m.mu.Unlock()
+		if session.recorder != nil {
+			info, _ := session.recorder.Close()
+			os.Remove(info.Path)
+		}
+		if provisioned {
+			m.removeKey(id)
+		}
 		return "", err
 	}
 
-	m.sessions[setup.SessionID] = session
+	session.expiry = time.AfterFunc(time.Until(session.expiresAt), func() {
+		m.expire(id)
+	})
+	m.sessions[id] = session
+	m.queueAudit(sessionStartAudit(session, addr, m.hostname))
 	m.mu.Unlock()
 
-	// Start expiry timer.
-	ttl := time.Until(expiresAt)
-	if ttl > 0 {
-		time.AfterFunc(ttl, func() {
-			m.CloseSession(setup.SessionID, "expired")
-		})
-	}
-
 	m.logger.Info("session created",
-		"session_id", setup.SessionID,
+		"session_id", id,
+		"kind", session.kind,
 		"listen_addr", addr,
-		"expires_at", expiresAt.String(),
+		"expires_at", session.expiresAt.String(),
 	)
 
 	return addr, nil
@@ -108,13 +240,22 @@ func (m *SessionManager) CloseSession(sessionID, reason string) *ClosedSessionIn
 		return nil
 	}
 
+	if session.expiry != nil {
+		session.expiry.Stop()
+	}
 	session.Close()
+	m.removeKey(sessionID)
 	duration := time.Since(session.startTime)
 	m.logger.Info("session closed",
 		"session_id", sessionID,
 		"reason", reason,
 		"duration", duration.String(),
 	)
+	recorded := m.finishRecording(session, duration)
+
+	m.mu.Lock()
+	m.queueAudit(sessionStopAudit(session, reason, duration, recorded, m.hostname))
+	m.mu.Unlock()
 	return &ClosedSessionInfo{Duration: duration}
 }
 
@@ -137,6 +278,96 @@ func (m *SessionManager) Shutdown() {
 	m.logger.Info("all tunnel sessions closed")
 }
 
+// finishRecording closes the recorder of session and uploads the recording.
+// The local file is removed after a successful upload and kept otherwise.
+// It reports whether the session was recorded.
+func (m *SessionManager) finishRecording(session *Session, duration time.Duration) bool {
+	if session.recorder == nil {
+		return false
+	}
+	info, err := session.recorder.Close()
+	if err != nil {
+		m.logger.Warn("session recording incomplete",
+			"session_id", session.SessionID,
+			"path", info.Path,
+			"error", err,
+		)
+	}
+
+	m.mu.Lock()
+	u := m.uploader
+	m.mu.Unlock()
+	if u == nil {
+		return true
+	}
+
+	sum, data, err := fileSHA256(info.Path)
+	if err != nil {
+		m.logger.Warn("read session recording failed",
+			"session_id", session.SessionID,
+			"path", info.Path,
+			"error", err,
+		)
+		return true
+	}
+	rec := api.TunnelRecording{
+		Format:      api.TunnelRecordingFormat,
+		Data:        string(data),
+		SHA256:      sum,
+		Truncated:   info.Truncated,
+		StartedAt:   info.StartedAt.UTC(),
+		Duration:    duration.Round(time.Millisecond).String(),
+		TriggeredBy: session.triggeredBy,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), recordingUploadTimeout)
+	defer cancel()
+	if err := u.UploadRecording(ctx, session.SessionID, rec); err != nil {
+		m.logger.Warn("upload session recording failed, keeping local copy",
+			"session_id", session.SessionID,
+			"path", info.Path,
+			"error", err,
+		)
+		return true
+	}
+	if err := os.Remove(info.Path); err != nil {
+		m.logger.Debug("remove uploaded recording failed", "path", info.Path, "error", err)
+	}
+	m.logger.Info("session recording uploaded",
+		"session_id", session.SessionID,
+		"bytes", info.Bytes,
+		"truncated", info.Truncated,
+	)
+	return true
+}
+
+// expire closes an expired session and reports it as closed.
+func (m *SessionManager) expire(sessionID string) {
+	info := m.CloseSession(sessionID, "expired")
+	if info == nil {
+		return
+	}
+	m.mu.Lock()
+	r := m.reporter
+	m.mu.Unlock()
+	if r != nil {
+		r.ReportClosed(context.Background(), sessionID, "expired", info.Duration)
+	}
+}
+
+// removeKey removes the authorized key of sessionID, if keys are provisioned.
+// A failure is logged; the key still lapses at its expiry-time.
+func (m *SessionManager) removeKey(sessionID string) {
+	if m.keys == nil {
+		return
+	}
+	if err := m.keys.Remove(sessionID); err != nil {
+		m.logger.Warn("remove authorized key failed",
+			"session_id", sessionID,
+			"error", err,
+		)
+	}
+}
+
 // removeSession removes and returns the session for the given ID, or nil if not found.
 func (m *SessionManager) removeSession(sessionID string) *Session {
 	m.mu.Lock()
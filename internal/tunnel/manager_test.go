@@ -2,9 +2,15 @@ package tunnel
 
 import (
 	"context"
+	"errors"
+	"io"
 	"log/slog"
 	"net"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -165,6 +171,31 @@ func TestSessionManager_DefaultTimeoutCapsExpiry(t *testing.T) {
 	}
 }
 
+func TestSessionManager_InvalidSessionIDRejected(t *testing.T) {
+	echoAddr := startEchoServer(t)
+	keysPath := filepath.Join(t.TempDir(), "authorized_keys")
+	mgr := newTestManager(t, Config{AuthorizedKeysFile: keysPath})
+
+	for _, id := range []string{
+		"s1\nssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIFake attacker",
+		"s1 extra",
+		"../s1",
+		strings.Repeat("a", 65),
+	} {
+		setup := validSetup(id, echoAddr)
+		setup.AuthorizedKey = testAuthorizedKey(t)
+		if _, err := mgr.CreateSession(context.Background(), setup); err == nil || !strings.Contains(err.Error(), "invalid session_id") {
+			t.Errorf("CreateSession(%q) = %v, want invalid session_id error", id, err)
+		}
+	}
+	if mgr.ActiveCount() != 0 {
+		t.Errorf("expected ActiveCount()=0, got %d", mgr.ActiveCount())
+	}
+	if _, err := os.Stat(keysPath); !os.IsNotExist(err) {
+		t.Errorf("authorized_keys written for an invalid session: %v", err)
+	}
+}
+
 func TestSessionManager_InvalidPortRejected(t *testing.T) {
 	mgr := newTestManager(t, Config{})
 
@@ -240,3 +271,304 @@ func TestSessionManager_DisabledRejectsAll(t *testing.T) {
 		t.Fatal("expected error when tunneling is disabled")
 	}
 }
+
+func TestSessionManager_ExpiryReportsClosed(t *testing.T) {
+	echoAddr := startEchoServer(t)
+	mgr := newTestManager(t, Config{})
+	reporter := &mockReporter{}
+	mgr.SetReporter(reporter)
+
+	setup := validSetup("exp1", echoAddr)
+	setup.ExpiresAt = time.Now().Add(200 * time.Millisecond)
+	if _, err := mgr.CreateSession(context.Background(), setup); err != nil {
+		t.Fatalf("CreateSession() error: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		reporter.mu.Lock()
+		calls := append([]tunnelClosedCall(nil), reporter.closedCalls...)
+		reporter.mu.Unlock()
+		if len(calls) > 0 {
+			if calls[0] != (tunnelClosedCall{SessionID: "exp1", Reason: "expired"}) {
+				t.Errorf("closed call = %+v, want exp1 expired", calls[0])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired session was not reported closed")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if mgr.ActiveCount() != 0 {
+		t.Errorf("expected ActiveCount()=0 after expiry, got %d", mgr.ActiveCount())
+	}
+}
+
+func TestSessionManager_CloseDoesNotReportExpiry(t *testing.T) {
+	echoAddr := startEchoServer(t)
+	mgr := newTestManager(t, Config{})
+	reporter := &mockReporter{}
+	mgr.SetReporter(reporter)
+
+	setup := validSetup("rev1", echoAddr)
+	setup.ExpiresAt = time.Now().Add(200 * time.Millisecond)
+	if _, err := mgr.CreateSession(context.Background(), setup); err != nil {
+		t.Fatalf("CreateSession() error: %v", err)
+	}
+	mgr.CloseSession("rev1", "revoked")
+
+	time.Sleep(400 * time.Millisecond)
+	reporter.mu.Lock()
+	defer reporter.mu.Unlock()
+	if len(reporter.closedCalls) != 0 {
+		t.Errorf("closed calls = %+v, want none after explicit close", reporter.closedCalls)
+	}
+}
+
+func TestSessionManager_OutlivesSetupContext(t *testing.T) {
+	echoAddr := startEchoServer(t)
+	mgr := newTestManager(t, Config{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	addr, err := mgr.CreateSession(ctx, validSetup("ctx1", echoAddr))
+	if err != nil {
+		t.Fatalf("CreateSession() error: %v", err)
+	}
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Fatalf("dial session after setup context ended: %v", err)
+	}
+	conn.Close()
+}
+
+func TestSessionManager_ProvisionsAuthorizedKey(t *testing.T) {
+	echoAddr := startEchoServer(t)
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	mgr := newTestManager(t, Config{AuthorizedKeysFile: path})
+
+	setup := validSetup("key1", echoAddr)
+	setup.AuthorizedKey = testAuthorizedKey(t)
+	if _, err := mgr.CreateSession(context.Background(), setup); err != nil {
+		t.Fatalf("CreateSession() error: %v", err)
+	}
+	if got := readFile(t, path); !strings.Contains(got, "plexd-session:key1") {
+		t.Fatalf("authorized_keys = %q, want session key", got)
+	}
+
+	mgr.CloseSession("key1", "revoked")
+	if got := readFile(t, path); strings.Contains(got, "plexd-session:key1") {
+		t.Errorf("authorized_keys = %q, want session key removed", got)
+	}
+
+	bad := validSetup("key2", echoAddr)
+	bad.AuthorizedKey = "not a key"
+	if _, err := mgr.CreateSession(context.Background(), bad); err == nil {
+		t.Error("CreateSession() with invalid key = nil error, want error")
+	}
+	if mgr.ActiveCount() != 0 {
+		t.Errorf("expected ActiveCount()=0, got %d", mgr.ActiveCount())
+	}
+}
+
+type fakeUploader struct {
+	mu   sync.Mutex
+	err  error
+	recs map[string]api.TunnelRecording
+}
+
+func (u *fakeUploader) UploadRecording(_ context.Context, sessionID string, rec api.TunnelRecording) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.recs == nil {
+		u.recs = make(map[string]api.TunnelRecording)
+	}
+	u.recs[sessionID] = rec
+	return u.err
+}
+
+func TestSessionManager_RecordsAndUploads(t *testing.T) {
+	echoAddr := startEchoServer(t)
+	dir := t.TempDir()
+	mgr := newTestManager(t, Config{RecordSessions: true, RecordingDir: dir})
+	uploader := &fakeUploader{}
+	mgr.SetRecordingUploader(uploader)
+
+	setup := validSetup("rec1", echoAddr)
+	setup.TriggeredBy = &api.TriggeredBy{Type: "user", Email: "ops@example.com"}
+	addr, err := mgr.CreateSession(context.Background(), setup)
+	if err != nil {
+		t.Fatalf("CreateSession() error: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	mgr.CloseSession("rec1", "revoked")
+
+	rec, ok := uploader.recs["rec1"]
+	if !ok {
+		t.Fatal("recording was not uploaded")
+	}
+	if rec.Format != api.TunnelRecordingFormat || rec.SHA256 == "" || rec.TriggeredBy == nil {
+		t.Errorf("recording = %+v", rec)
+	}
+	if !strings.Contains(rec.Data, `"i","hello"`) || !strings.Contains(rec.Data, `"o","hello"`) {
+		t.Errorf("recording data missing events:\n%s", rec.Data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "rec1.cast")); !os.IsNotExist(err) {
+		t.Errorf("uploaded recording still on disk: %v", err)
+	}
+}
+
+func TestSessionManager_KeepsRecordingOnUploadFailure(t *testing.T) {
+	echoAddr := startEchoServer(t)
+	dir := t.TempDir()
+	mgr := newTestManager(t, Config{RecordSessions: true, RecordingDir: dir})
+	mgr.SetRecordingUploader(&fakeUploader{err: errors.New("unavailable")})
+
+	if _, err := mgr.CreateSession(context.Background(), validSetup("rec2", echoAddr)); err != nil {
+		t.Fatalf("CreateSession() error: %v", err)
+	}
+	mgr.CloseSession("rec2", "revoked")
+
+	if _, err := os.Stat(filepath.Join(dir, "rec2.cast")); err != nil {
+		t.Errorf("recording not kept after failed upload: %v", err)
+	}
+}
+
+func TestSessionManager_CreateForwardTCP(t *testing.T) {
+	echoAddr := startEchoServer(t)
+	host, portStr, _ := net.SplitHostPort(echoAddr)
+	port, _ := strconv.Atoi(portStr)
+	mgr := newTestManager(t, Config{})
+
+	addr, err := mgr.CreateForward(context.Background(), api.PortForwardSetup{
+		SessionID:  "fwd1",
+		Mode:       api.PortForwardTCP,
+		TargetHost: host,
+		TargetPort: port,
+		ExpiresAt:  time.Now().Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("CreateForward() error: %v", err)
+	}
+
+	// Unlike SSH sessions, forwards serve concurrent connections.
+	var conns []net.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	for i, conn := range conns {
+		_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+		msg := []byte{byte('a' + i)}
+		if _, err := conn.Write(msg); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 1)
+		if _, err := io.ReadFull(conn, buf); err != nil || buf[0] != msg[0] {
+			t.Errorf("conn %d echo = %q, %v", i, buf, err)
+		}
+	}
+
+	if info := mgr.CloseSession("fwd1", "revoked"); info == nil {
+		t.Fatal("CloseSession(fwd1) = nil, want session")
+	}
+}
+
+func TestSessionManager_CreateForwardSourceACL(t *testing.T) {
+	echoAddr := startEchoServer(t)
+	host, portStr, _ := net.SplitHostPort(echoAddr)
+	port, _ := strconv.Atoi(portStr)
+	mgr := newTestManager(t, Config{})
+
+	addr, err := mgr.CreateForward(context.Background(), api.PortForwardSetup{
+		SessionID:      "fwd-acl",
+		Mode:           api.PortForwardTCP,
+		TargetHost:     host,
+		TargetPort:     port,
+		AllowedSources: []string{"10.99.0.0/16"},
+		ExpiresAt:      time.Now().Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("CreateForward() error: %v", err)
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+	_, _ = conn.Write([]byte("x"))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("connection from a source outside the ACL was forwarded")
+	}
+}
+
+func TestSessionManager_CreateForwardInvalid(t *testing.T) {
+	mgr := newTestManager(t, Config{})
+	expires := time.Now().Add(time.Minute)
+
+	tests := []struct {
+		name  string
+		setup api.PortForwardSetup
+		want  string
+	}{
+		{"missing id", api.PortForwardSetup{Mode: api.PortForwardTCP, TargetHost: "127.0.0.1", TargetPort: 80, ExpiresAt: expires}, "session_id is required"},
+		{"unknown mode", api.PortForwardSetup{SessionID: "f", Mode: "udp", ExpiresAt: expires}, `unknown mode "udp"`},
+		{"tcp without target", api.PortForwardSetup{SessionID: "f", Mode: api.PortForwardTCP, ExpiresAt: expires}, "target_host"},
+		{"socks without destinations", api.PortForwardSetup{SessionID: "f", Mode: api.PortForwardSOCKS5, ExpiresAt: expires}, "allowed_destinations is required"},
+		{"newline in id", api.PortForwardSetup{SessionID: "f\nssh-ed25519 AAAA", Mode: api.PortForwardTCP, TargetHost: "127.0.0.1", TargetPort: 80, ExpiresAt: expires}, "invalid session_id"},
+		{"space in id", api.PortForwardSetup{SessionID: "f x", Mode: api.PortForwardTCP, TargetHost: "127.0.0.1", TargetPort: 80, ExpiresAt: expires}, "invalid session_id"},
+		{"invalid acl", api.PortForwardSetup{SessionID: "f", Mode: api.PortForwardSOCKS5, AllowedDestinations: []string{"example.com"}, ExpiresAt: expires}, "tunnel: acl"},
+		{"expired", api.PortForwardSetup{SessionID: "f", Mode: api.PortForwardSOCKS5, AllowedDestinations: []string{"10.0.0.0/8"}, ExpiresAt: time.Now().Add(-time.Minute)}, "already expired"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := mgr.CreateForward(context.Background(), tt.setup)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("CreateForward() = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+	if mgr.ActiveCount() != 0 {
+		t.Errorf("expected ActiveCount()=0, got %d", mgr.ActiveCount())
+	}
+}
+
+func TestSessionManager_ForwardSharesSessionIDs(t *testing.T) {
+	echoAddr := startEchoServer(t)
+	mgr := newTestManager(t, Config{})
+
+	if _, err := mgr.CreateSession(context.Background(), validSetup("shared", echoAddr)); err != nil {
+		t.Fatalf("CreateSession() error: %v", err)
+	}
+	_, err := mgr.CreateForward(context.Background(), api.PortForwardSetup{
+		SessionID:           "shared",
+		Mode:                api.PortForwardSOCKS5,
+		AllowedDestinations: []string{"10.0.0.0/8"},
+		ExpiresAt:           time.Now().Add(time.Minute),
+	})
+	if err == nil || !strings.Contains(err.Error(), "duplicate session ID") {
+		t.Errorf("CreateForward() = %v, want duplicate session ID error", err)
+	}
+}
@@ -0,0 +1,171 @@
+package tunnel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultRecordingMaxBytes is the default size limit of a session recording.
+const DefaultRecordingMaxBytes = 10 << 20
+
+// Recording terminal size written to the asciicast header. The tunnel
+// forwards a byte stream and does not see terminal size changes.
+const (
+	recordingWidth  = 80
+	recordingHeight = 24
+)
+
+// Recorder writes the input and output of a tunnel session to a file in
+// asciicast v2 format: a JSON header line followed by one
+// [elapsed, "i"|"o", data] event line per read. Once the file reaches its
+// size limit further events are dropped and the recording is marked
+// truncated. Recording never fails the session: write errors stop the
+// recording and are reported by Close.
+type Recorder struct {
+	path      string
+	start     time.Time
+	maxBytes  int64
+	sessionID string
+
+	mu        sync.Mutex
+	f         *os.File
+	written   int64
+	truncated bool
+	err       error
+}
+
+// RecordingInfo describes a finished recording.
+type RecordingInfo struct {
+	Path      string
+	StartedAt time.Time
+	Bytes     int64
+	Truncated bool
+}
+
+// NewRecorder creates dir/{sessionID}.cast and writes the asciicast header.
+// sessionID must be a valid session ID, so that the file cannot be created
+// outside dir.
+func NewRecorder(dir, sessionID string, maxBytes int64) (*Recorder, error) {
+	if err := validateSessionID(sessionID); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("tunnel: recording: create dir: %w", err)
+	}
+	path := filepath.Join(dir, sessionID+".cast")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("tunnel: recording: %w", err)
+	}
+
+	r := &Recorder{
+		path:      path,
+		start:     time.Now(),
+		maxBytes:  maxBytes,
+		sessionID: sessionID,
+		f:         f,
+	}
+	header, _ := json.Marshal(map[string]any{
+		"version":   2,
+		"width":     recordingWidth,
+		"height":    recordingHeight,
+		"timestamp": r.start.Unix(),
+		"title":     "plexd session " + sessionID,
+	})
+	r.write(header)
+	if r.err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, r.err
+	}
+	return r, nil
+}
+
+// Input returns a writer that records what it is given as input events.
+func (r *Recorder) Input() io.Writer {
+	return recorderStream{r: r, kind: "i"}
+}
+
+// Output returns a writer that records what it is given as output events.
+func (r *Recorder) Output() io.Writer {
+	return recorderStream{r: r, kind: "o"}
+}
+
+// Close finishes the recording. It returns the recording info together with
+// the first write error, if any.
+func (r *Recorder) Close() (RecordingInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info := RecordingInfo{
+		Path:      r.path,
+		StartedAt: r.start,
+		Bytes:     r.written,
+		Truncated: r.truncated,
+	}
+	if r.f == nil {
+		return info, r.err
+	}
+	if err := r.f.Close(); err != nil && r.err == nil {
+		r.err = fmt.Errorf("tunnel: recording: close: %w", err)
+	}
+	r.f = nil
+	return info, r.err
+}
+
+// record appends an event of kind with data.
+func (r *Recorder) record(kind string, data []byte) {
+	elapsed := time.Since(r.start).Round(time.Microsecond).Seconds()
+	line, _ := json.Marshal([]any{elapsed, kind, string(data)})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.write(line)
+}
+
+// write appends line and a newline unless the size limit is reached. The
+// caller holds r.mu, except during construction.
+func (r *Recorder) write(line []byte) {
+	if r.f == nil || r.err != nil || r.truncated {
+		return
+	}
+	if r.maxBytes > 0 && r.written+int64(len(line))+1 > r.maxBytes {
+		r.truncated = true
+		return
+	}
+	n, err := r.f.Write(append(line, '\n'))
+	r.written += int64(n)
+	if err != nil {
+		r.err = fmt.Errorf("tunnel: recording: write: %w", err)
+	}
+}
+
+// recorderStream adapts a Recorder to io.Writer for one direction. Write
+// always succeeds so that recording never interrupts forwarding.
+type recorderStream struct {
+	r    *Recorder
+	kind string
+}
+
+func (s recorderStream) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		s.r.record(s.kind, p)
+	}
+	return len(p), nil
+}
+
+// fileSHA256 returns the hex SHA-256 digest and contents of path.
+func fileSHA256(path string) (string, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), data, nil
+}
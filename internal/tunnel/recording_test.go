@@ -0,0 +1,117 @@
+package tunnel
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readCast(t *testing.T, path string) (map[string]any, [][]any) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open recording: %v", err)
+	}
+	defer f.Close()
+
+	var header map[string]any
+	var events [][]any
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if header == nil {
+			if err := json.Unmarshal(sc.Bytes(), &header); err != nil {
+				t.Fatalf("header %q: %v", sc.Text(), err)
+			}
+			continue
+		}
+		var ev []any
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			t.Fatalf("event %q: %v", sc.Text(), err)
+		}
+		events = append(events, ev)
+	}
+	return header, events
+}
+
+func TestRecorder_Asciicast(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "recordings")
+	rec, err := NewRecorder(dir, "s1", DefaultRecordingMaxBytes)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	_, _ = rec.Input().Write([]byte("ls\n"))
+	_, _ = rec.Output().Write([]byte("file.txt\n"))
+	_, _ = rec.Output().Write(nil)
+
+	info, err := rec.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if info.Path != filepath.Join(dir, "s1.cast") || info.Truncated || info.Bytes == 0 {
+		t.Errorf("info = %+v", info)
+	}
+	st, err := os.Stat(info.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", st.Mode().Perm())
+	}
+
+	header, events := readCast(t, info.Path)
+	if header["version"] != float64(2) || header["width"] != float64(80) || !strings.Contains(header["title"].(string), "s1") {
+		t.Errorf("header = %v", header)
+	}
+	if len(events) != 2 {
+		t.Fatalf("events = %v, want 2", events)
+	}
+	if events[0][1] != "i" || events[0][2] != "ls\n" || events[1][1] != "o" || events[1][2] != "file.txt\n" {
+		t.Errorf("events = %v", events)
+	}
+	if events[1][0].(float64) < events[0][0].(float64) {
+		t.Errorf("event times not monotonic: %v", events)
+	}
+}
+
+func TestRecorder_Truncates(t *testing.T) {
+	rec, err := NewRecorder(t.TempDir(), "s1", 200)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		_, _ = rec.Output().Write([]byte("0123456789"))
+	}
+	info, err := rec.Close()
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !info.Truncated || info.Bytes > 200 {
+		t.Errorf("info = %+v, want truncated at 200 bytes", info)
+	}
+	if _, events := readCast(t, info.Path); len(events) == 0 || len(events) >= 20 {
+		t.Errorf("got %d events, want some but not all", len(events))
+	}
+}
+
+func TestRecorder_RejectsPathTraversal(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "recordings")
+
+	for _, id := range []string{
+		"../../etc/cron.d/x",
+		"../escaped",
+		"sub/s1",
+		"",
+	} {
+		if rec, err := NewRecorder(dir, id, DefaultRecordingMaxBytes); err == nil {
+			rec.Close()
+			t.Errorf("NewRecorder(%q) succeeded", id)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "escaped.cast")); !os.IsNotExist(err) {
+		t.Errorf("recording written outside dir: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package tunnel
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// TunnelClient is the subset of the control plane client used to report
+// tunnel lifecycle events. It is satisfied by *api.ControlPlane.
+type TunnelClient interface {
+	TunnelReady(ctx context.Context, nodeID, sessionID string, req api.TunnelReadyRequest) error
+	TunnelClosed(ctx context.Context, nodeID, sessionID string, req api.TunnelClosedRequest) error
+	UploadTunnelRecording(ctx context.Context, nodeID, sessionID string, req api.TunnelRecording) error
+}
+
+// ControlPlaneReporter is a TunnelReporter and RecordingUploader that posts
+// lifecycle events and recordings to the control plane. Reporting is best
+// effort: failures are logged and do not affect the session.
+type ControlPlaneReporter struct {
+	client TunnelClient
+	nodeID string
+	logger *slog.Logger
+}
+
+// NewControlPlaneReporter returns a ControlPlaneReporter for nodeID.
+func NewControlPlaneReporter(client TunnelClient, nodeID string, logger *slog.Logger) *ControlPlaneReporter {
+	return &ControlPlaneReporter{
+		client: client,
+		nodeID: nodeID,
+		logger: logger.With("component", "tunnel"),
+	}
+}
+
+// ReportReady reports that the listener of sessionID accepts connections on
+// listenAddr.
+func (r *ControlPlaneReporter) ReportReady(ctx context.Context, sessionID, listenAddr string) {
+	req := api.TunnelReadyRequest{
+		ListenAddr: listenAddr,
+		Timestamp:  time.Now().UTC(),
+	}
+	if err := r.client.TunnelReady(ctx, r.nodeID, sessionID, req); err != nil {
+		r.logger.Warn("report tunnel ready failed",
+			"session_id", sessionID,
+			"error", err,
+		)
+	}
+}
+
+// ReportClosed reports that sessionID was closed for reason after duration.
+func (r *ControlPlaneReporter) ReportClosed(ctx context.Context, sessionID, reason string, duration time.Duration) {
+	req := api.TunnelClosedRequest{
+		Reason:    reason,
+		Duration:  duration.Round(time.Millisecond).String(),
+		Timestamp: time.Now().UTC(),
+	}
+	if err := r.client.TunnelClosed(ctx, r.nodeID, sessionID, req); err != nil {
+		r.logger.Warn("report tunnel closed failed",
+			"session_id", sessionID,
+			"reason", reason,
+			"error", err,
+		)
+	}
+}
+
+// UploadRecording uploads the recording of sessionID.
+func (r *ControlPlaneReporter) UploadRecording(ctx context.Context, sessionID string, rec api.TunnelRecording) error {
+	return r.client.UploadTunnelRecording(ctx, r.nodeID, sessionID, rec)
+}
@@ -0,0 +1,86 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+type fakeTunnelClient struct {
+	err    error
+	nodeID string
+	ready  map[string]api.TunnelReadyRequest
+	closed map[string]api.TunnelClosedRequest
+	rec    map[string]api.TunnelRecording
+}
+
+func (c *fakeTunnelClient) TunnelReady(_ context.Context, nodeID, sessionID string, req api.TunnelReadyRequest) error {
+	c.nodeID = nodeID
+	c.ready[sessionID] = req
+	return c.err
+}
+
+func (c *fakeTunnelClient) TunnelClosed(_ context.Context, nodeID, sessionID string, req api.TunnelClosedRequest) error {
+	c.nodeID = nodeID
+	c.closed[sessionID] = req
+	return c.err
+}
+
+func (c *fakeTunnelClient) UploadTunnelRecording(_ context.Context, nodeID, sessionID string, req api.TunnelRecording) error {
+	c.nodeID = nodeID
+	c.rec[sessionID] = req
+	return c.err
+}
+
+func newFakeTunnelClient() *fakeTunnelClient {
+	return &fakeTunnelClient{
+		ready:  make(map[string]api.TunnelReadyRequest),
+		closed: make(map[string]api.TunnelClosedRequest),
+		rec:    make(map[string]api.TunnelRecording),
+	}
+}
+
+func TestControlPlaneReporter(t *testing.T) {
+	client := newFakeTunnelClient()
+	r := NewControlPlaneReporter(client, "node-1", slog.Default())
+
+	r.ReportReady(context.Background(), "s1", "10.0.0.1:40000")
+	r.ReportClosed(context.Background(), "s1", "expired", 90*time.Second+1234*time.Microsecond)
+
+	if client.nodeID != "node-1" {
+		t.Errorf("nodeID = %q, want node-1", client.nodeID)
+	}
+	ready := client.ready["s1"]
+	if ready.ListenAddr != "10.0.0.1:40000" || ready.Timestamp.IsZero() {
+		t.Errorf("ready request = %+v", ready)
+	}
+	closed := client.closed["s1"]
+	if closed.Reason != "expired" || closed.Duration != "1m30.001s" || closed.Timestamp.IsZero() {
+		t.Errorf("closed request = %+v", closed)
+	}
+
+	if err := r.UploadRecording(context.Background(), "s1", api.TunnelRecording{Format: api.TunnelRecordingFormat}); err != nil {
+		t.Fatalf("UploadRecording: %v", err)
+	}
+	if client.rec["s1"].Format != api.TunnelRecordingFormat {
+		t.Errorf("recording = %+v", client.rec["s1"])
+	}
+}
+
+func TestControlPlaneReporter_ErrorsAreLogged(t *testing.T) {
+	client := newFakeTunnelClient()
+	client.err = errors.New("unavailable")
+	r := NewControlPlaneReporter(client, "node-1", slog.Default())
+
+	// Must not panic or block; failures are only logged.
+	r.ReportReady(context.Background(), "s1", "10.0.0.1:40000")
+	r.ReportClosed(context.Background(), "s1", "revoked", time.Second)
+
+	if _, ok := client.closed["s1"]; !ok {
+		t.Error("TunnelClosed was not called")
+	}
+}
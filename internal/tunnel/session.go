@@ -6,11 +6,40 @@ import (
 	"io"
 	"log/slog"
 	"net"
+	"regexp"
 	"strconv"
 	"sync"
 	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// Session kinds.
+const (
+	// KindSSH forwards a single connection to the target, typically sshd.
+	KindSSH = "ssh"
+
+	// KindPortForward forwards connections to a fixed target.
+	KindPortForward = "port_forward"
+
+	// KindSOCKS5 serves SOCKS5 CONNECT requests to destinations allowed by
+	// the session ACL.
+	KindSOCKS5 = "socks5"
 )
 
+// sessionIDPattern matches valid session IDs. Session IDs are written into
+// authorized_keys lines and recording file names, so they are limited to
+// characters that cannot end a line, separate fields, or form a path.
+var sessionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// validateSessionID returns an error if id does not match sessionIDPattern.
+func validateSessionID(id string) error {
+	if !sessionIDPattern.MatchString(id) {
+		return fmt.Errorf("tunnel: invalid session_id %q: must be 1-64 characters of A-Z, a-z, 0-9, _ and -", id)
+	}
+	return nil
+}
+
 // Session represents an active tunnel session with a local TCP listener
 // that forwards connections to a target host through the mesh.
 type Session struct {
@@ -21,11 +50,21 @@ type Session struct {
 
 	listener  net.Listener
 	cancel    context.CancelFunc
+	expiry    *time.Timer
+	recorder  *Recorder
 	startTime time.Time
 	expiresAt time.Time
 
+	// triggeredBy identifies who requested the session, for audit entries.
+	triggeredBy *api.TriggeredBy
+
+	kind     string
+	acl      *ACL // nil allows every client
+	maxConns int
+	resolver socksResolver
+
 	mu     sync.Mutex
-	conn   net.Conn // active connection (at most one)
+	conns  map[net.Conn]struct{} // active client connections (at most maxConns)
 	closed bool
 
 	logger *slog.Logger
@@ -40,6 +79,10 @@ func NewSession(sessionID, targetHost string, targetPort int, meshIP string, exp
 		MeshIP:     meshIP,
 		expiresAt:  expiresAt,
 		startTime:  time.Now(),
+		kind:       KindSSH,
+		maxConns:   1,
+		resolver:   net.DefaultResolver,
+		conns:      make(map[net.Conn]struct{}),
 		logger:     logger.With("session_id", sessionID),
 	}
 }
@@ -56,8 +99,9 @@ func (s *Session) Start(ctx context.Context) (string, error) {
 	s.listener = ln
 
 	s.logger.Info("session started",
+		"kind", s.kind,
 		"listen_addr", ln.Addr().String(),
-		"target", net.JoinHostPort(s.TargetHost, strconv.Itoa(s.TargetPort)),
+		"target", s.target(),
 	)
 
 	go s.acceptLoop(ctx)
@@ -81,63 +125,100 @@ func (s *Session) acceptLoop(ctx context.Context) {
 			continue
 		}
 
-		s.forward(ctx, conn)
+		go s.forward(ctx, conn)
 	}
 }
 
-// tryAccept checks whether a new connection can be accepted (only one active
-// connection at a time). Returns true if accepted, false if rejected (conn is
-// closed on rejection).
+// tryAccept checks the source ACL and reserves a connection slot. Returns
+// true if accepted, false if rejected (conn is closed on rejection).
 func (s *Session) tryAccept(conn net.Conn) bool {
+	if s.acl != nil && !s.acl.AllowSource(conn.RemoteAddr()) {
+		conn.Close()
+		s.logger.Warn("rejected connection: source not allowed", "remote_addr", conn.RemoteAddr().String())
+		return false
+	}
+
 	s.mu.Lock()
-	busy := s.conn != nil
+	busy := s.closed || len(s.conns) >= s.maxConns
+	if !busy {
+		s.conns[conn] = struct{}{}
+	}
 	s.mu.Unlock()
 
 	if busy {
 		conn.Close()
-		s.logger.Debug("rejected connection: session already has active connection")
+		s.logger.Debug("rejected connection: session connection limit reached", "max", s.maxConns)
 		return false
 	}
 	return true
 }
 
-func (s *Session) forward(ctx context.Context, clientConn net.Conn) {
-	targetAddr := net.JoinHostPort(s.TargetHost, strconv.Itoa(s.TargetPort))
+// target returns the fixed forwarding target, or "socks5" for SOCKS sessions.
+func (s *Session) target() string {
+	if s.kind == KindSOCKS5 {
+		return KindSOCKS5
+	}
+	return net.JoinHostPort(s.TargetHost, strconv.Itoa(s.TargetPort))
+}
+
+// dialTarget connects to the target of clientConn: the fixed target, or the
+// destination requested over SOCKS5.
+func (s *Session) dialTarget(ctx context.Context, clientConn net.Conn) (net.Conn, error) {
+	if s.kind == KindSOCKS5 {
+		targetConn, dest, err := socksConnect(ctx, clientConn, s.acl, s.resolver)
+		if err != nil {
+			return nil, err
+		}
+		s.logger.Info("socks connection opened",
+			"remote_addr", clientConn.RemoteAddr().String(),
+			"dest", dest,
+		)
+		return targetConn, nil
+	}
 	var d net.Dialer
-	targetConn, err := d.DialContext(ctx, "tcp", targetAddr)
+	return d.DialContext(ctx, "tcp", s.target())
+}
+
+func (s *Session) forward(ctx context.Context, clientConn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, clientConn)
+		s.mu.Unlock()
+	}()
+
+	targetConn, err := s.dialTarget(ctx, clientConn)
 	if err != nil {
 		clientConn.Close()
-		s.logger.Error("failed to dial target", "target", targetAddr, "error", err)
+		s.logger.Error("failed to dial target", "target", s.target(), "error", err)
 		return
 	}
 
-	s.mu.Lock()
-	s.conn = clientConn
-	s.mu.Unlock()
-
 	var once sync.Once
 	cleanup := func() {
 		once.Do(func() {
 			clientConn.Close()
 			targetConn.Close()
-			s.mu.Lock()
-			s.conn = nil
-			s.mu.Unlock()
 		})
 	}
 
+	var fromClient, fromTarget io.Reader = clientConn, targetConn
+	if s.recorder != nil {
+		fromClient = io.TeeReader(clientConn, s.recorder.Input())
+		fromTarget = io.TeeReader(targetConn, s.recorder.Output())
+	}
+
 	var wg sync.WaitGroup
 	wg.Add(2)
 
 	go func() {
 		defer wg.Done()
-		_, _ = io.Copy(targetConn, clientConn)
+		_, _ = io.Copy(targetConn, fromClient)
 		cleanup()
 	}()
 
 	go func() {
 		defer wg.Done()
-		_, _ = io.Copy(clientConn, targetConn)
+		_, _ = io.Copy(clientConn, fromTarget)
 		cleanup()
 	}()
 
@@ -146,7 +227,7 @@ func (s *Session) forward(ctx context.Context, clientConn net.Conn) {
 
 // Close shuts down the session idempotently.
 func (s *Session) Close() error {
-	conn, alreadyClosed := s.markClosed()
+	conns, alreadyClosed := s.markClosed()
 	if alreadyClosed {
 		return nil
 	}
@@ -157,7 +238,7 @@ func (s *Session) Close() error {
 	if s.listener != nil {
 		s.listener.Close()
 	}
-	if conn != nil {
+	for _, conn := range conns {
 		conn.Close()
 	}
 
@@ -166,15 +247,18 @@ func (s *Session) Close() error {
 }
 
 // markClosed atomically marks the session as closed and returns the active
-// connection (if any) along with whether the session was already closed.
-func (s *Session) markClosed() (activeConn net.Conn, alreadyClosed bool) {
+// connections along with whether the session was already closed.
+func (s *Session) markClosed() (active []net.Conn, alreadyClosed bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if s.closed {
 		return nil, true
 	}
 	s.closed = true
-	return s.conn, false
+	for conn := range s.conns {
+		active = append(active, conn)
+	}
+	return active, false
 }
 
 // ListenAddr returns the listener address or empty string if not started.
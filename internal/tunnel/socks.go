@@ -0,0 +1,191 @@
+package tunnel
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+// socksHandshakeTimeout bounds the SOCKS5 negotiation of a connection.
+const socksHandshakeTimeout = 10 * time.Second
+
+// SOCKS5 protocol constants (RFC 1928).
+const (
+	socksVersion      = 0x05
+	socksMethodNoAuth = 0x00
+	socksMethodNone   = 0xff
+	socksCmdConnect   = 0x01
+
+	socksAtypIPv4   = 0x01
+	socksAtypDomain = 0x03
+	socksAtypIPv6   = 0x04
+
+	socksReplySucceeded        = 0x00
+	socksReplyNotAllowed       = 0x02
+	socksReplyHostUnreachable  = 0x04
+	socksReplyConnRefused      = 0x05
+	socksReplyCmdNotSupported  = 0x07
+	socksReplyAtypNotSupported = 0x08
+)
+
+// socksResolver resolves SOCKS5 domain destinations. It is satisfied by
+// *net.Resolver.
+type socksResolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// socksConnect negotiates a SOCKS5 CONNECT on conn, checks the requested
+// destination against acl, and dials it. Only the no-authentication method
+// is offered: clients are authorized by the session's source ACL. Domain
+// destinations are resolved here and the resolved address is checked and
+// dialed, so a name cannot be used to bypass the ACL. On failure the client
+// receives the matching reply and an error is returned.
+func socksConnect(ctx context.Context, conn net.Conn, acl *ACL, resolver socksResolver) (net.Conn, string, error) {
+	_ = conn.SetDeadline(time.Now().Add(socksHandshakeTimeout))
+	defer func() { _ = conn.SetDeadline(time.Time{}) }()
+
+	if err := socksNegotiate(conn); err != nil {
+		return nil, "", err
+	}
+
+	var hdr [4]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return nil, "", fmt.Errorf("tunnel: socks: read request: %w", err)
+	}
+	if hdr[0] != socksVersion {
+		return nil, "", fmt.Errorf("tunnel: socks: unsupported version %d", hdr[0])
+	}
+	if hdr[1] != socksCmdConnect {
+		socksReply(conn, socksReplyCmdNotSupported, nil)
+		return nil, "", fmt.Errorf("tunnel: socks: unsupported command %d", hdr[1])
+	}
+
+	host, err := socksReadAddr(conn, hdr[3])
+	if err != nil {
+		if errors.Is(err, errSocksAtyp) {
+			socksReply(conn, socksReplyAtypNotSupported, nil)
+		}
+		return nil, "", err
+	}
+	var portBuf [2]byte
+	if _, err := io.ReadFull(conn, portBuf[:]); err != nil {
+		return nil, "", fmt.Errorf("tunnel: socks: read port: %w", err)
+	}
+	port := int(binary.BigEndian.Uint16(portBuf[:]))
+	dest := net.JoinHostPort(host, strconv.Itoa(port))
+
+	var candidates []netip.Addr
+	if ip, err := netip.ParseAddr(host); err == nil {
+		candidates = []netip.Addr{ip}
+	} else {
+		candidates, err = resolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			socksReply(conn, socksReplyHostUnreachable, nil)
+			return nil, dest, fmt.Errorf("tunnel: socks: resolve %s: %w", host, err)
+		}
+	}
+
+	var allowed []netip.Addr
+	for _, ip := range candidates {
+		if acl.AllowDestination(ip, port) {
+			allowed = append(allowed, ip)
+		}
+	}
+	if len(allowed) == 0 {
+		socksReply(conn, socksReplyNotAllowed, nil)
+		return nil, dest, fmt.Errorf("tunnel: socks: destination %s not allowed", dest)
+	}
+
+	var d net.Dialer
+	var dialErr error
+	for _, ip := range allowed {
+		target, err := d.DialContext(ctx, "tcp", netip.AddrPortFrom(ip, uint16(port)).String())
+		if err != nil {
+			dialErr = err
+			continue
+		}
+		socksReply(conn, socksReplySucceeded, target.LocalAddr())
+		return target, dest, nil
+	}
+	socksReply(conn, socksReplyConnRefused, nil)
+	return nil, dest, fmt.Errorf("tunnel: socks: dial %s: %w", dest, dialErr)
+}
+
+// socksNegotiate reads the client's method selection and accepts the
+// no-authentication method.
+func socksNegotiate(conn net.Conn) error {
+	var hdr [2]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return fmt.Errorf("tunnel: socks: read greeting: %w", err)
+	}
+	if hdr[0] != socksVersion {
+		return fmt.Errorf("tunnel: socks: unsupported version %d", hdr[0])
+	}
+	methods := make([]byte, hdr[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return fmt.Errorf("tunnel: socks: read methods: %w", err)
+	}
+	for _, m := range methods {
+		if m == socksMethodNoAuth {
+			_, err := conn.Write([]byte{socksVersion, socksMethodNoAuth})
+			return err
+		}
+	}
+	_, _ = conn.Write([]byte{socksVersion, socksMethodNone})
+	return errors.New("tunnel: socks: client offers no supported auth method")
+}
+
+var errSocksAtyp = errors.New("tunnel: socks: unsupported address type")
+
+// socksReadAddr reads a destination address of type atyp.
+func socksReadAddr(r io.Reader, atyp byte) (string, error) {
+	switch atyp {
+	case socksAtypIPv4, socksAtypIPv6:
+		size := 4
+		if atyp == socksAtypIPv6 {
+			size = 16
+		}
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", fmt.Errorf("tunnel: socks: read address: %w", err)
+		}
+		ip, _ := netip.AddrFromSlice(buf)
+		return ip.String(), nil
+	case socksAtypDomain:
+		var n [1]byte
+		if _, err := io.ReadFull(r, n[:]); err != nil {
+			return "", fmt.Errorf("tunnel: socks: read address: %w", err)
+		}
+		buf := make([]byte, n[0])
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", fmt.Errorf("tunnel: socks: read address: %w", err)
+		}
+		return string(buf), nil
+	default:
+		return "", errSocksAtyp
+	}
+}
+
+// socksReply writes a reply with code and the bound address (zero if nil).
+func socksReply(w io.Writer, code byte, bound net.Addr) {
+	reply := []byte{socksVersion, code, 0x00}
+	ap := netip.AddrPortFrom(netip.IPv4Unspecified(), 0)
+	if tcp, ok := bound.(*net.TCPAddr); ok {
+		ap = tcp.AddrPort()
+	}
+	if ip := ap.Addr().Unmap(); ip.Is4() {
+		reply = append(reply, socksAtypIPv4)
+		reply = append(reply, ip.AsSlice()...)
+	} else {
+		reply = append(reply, socksAtypIPv6)
+		reply = append(reply, ip.AsSlice()...)
+	}
+	reply = binary.BigEndian.AppendUint16(reply, ap.Port())
+	_, _ = w.Write(reply)
+}
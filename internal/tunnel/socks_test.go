@@ -0,0 +1,177 @@
+package tunnel
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type fakeResolver map[string][]netip.Addr
+
+func (r fakeResolver) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	if ips, ok := r[host]; ok {
+		return ips, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+// socksDial connects to a SOCKS5 server at addr and requests host:port. It
+// returns the connection and the reply code.
+func socksDial(t *testing.T, addr, host string, port int) (net.Conn, byte) {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
+	if err != nil {
+		t.Fatalf("dial socks: %v", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := conn.Write([]byte{socksVersion, 1, socksMethodNoAuth}); err != nil {
+		t.Fatal(err)
+	}
+	var method [2]byte
+	if _, err := io.ReadFull(conn, method[:]); err != nil {
+		t.Fatalf("read method: %v", err)
+	}
+	if method[1] != socksMethodNoAuth {
+		t.Fatalf("method = %d, want no-auth", method[1])
+	}
+
+	req := []byte{socksVersion, socksCmdConnect, 0}
+	if ip, err := netip.ParseAddr(host); err == nil && ip.Is4() {
+		req = append(req, socksAtypIPv4)
+		req = append(req, ip.AsSlice()...)
+	} else {
+		req = append(req, socksAtypDomain, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		t.Fatal(err)
+	}
+	var reply [10]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		t.Fatalf("read reply: %v", err)
+	}
+	return conn, reply[1]
+}
+
+func startSOCKSSession(t *testing.T, destinations []string, resolver socksResolver) *Session {
+	t.Helper()
+	acl, err := ParseACL(nil, destinations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSession("socks-1", "", 0, "127.0.0.1", time.Now().Add(time.Minute), slog.Default())
+	s.kind = KindSOCKS5
+	s.acl = acl
+	s.maxConns = 4
+	if resolver != nil {
+		s.resolver = resolver
+	}
+	t.Cleanup(func() { s.Close() })
+	if _, err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return s
+}
+
+func TestSOCKS_ConnectAllowed(t *testing.T) {
+	echoAddr := startEchoServer(t)
+	host, portStr, _ := net.SplitHostPort(echoAddr)
+	port, _ := strconv.Atoi(portStr)
+	s := startSOCKSSession(t, []string{"127.0.0.1:" + portStr}, nil)
+
+	conn, code := socksDial(t, s.ListenAddr(), host, port)
+	defer conn.Close()
+	if code != socksReplySucceeded {
+		t.Fatalf("reply = %d, want success", code)
+	}
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Errorf("echo = %q, %v", buf, err)
+	}
+}
+
+func TestSOCKS_DomainResolvedAndChecked(t *testing.T) {
+	echoAddr := startEchoServer(t)
+	_, portStr, _ := net.SplitHostPort(echoAddr)
+	port, _ := strconv.Atoi(portStr)
+	resolver := fakeResolver{
+		"db.internal":   {netip.MustParseAddr("127.0.0.1")},
+		"evil.internal": {netip.MustParseAddr("127.0.0.2")},
+	}
+	s := startSOCKSSession(t, []string{"127.0.0.1"}, resolver)
+
+	conn, code := socksDial(t, s.ListenAddr(), "db.internal", port)
+	conn.Close()
+	if code != socksReplySucceeded {
+		t.Errorf("allowed domain: reply = %d, want success", code)
+	}
+
+	conn, code = socksDial(t, s.ListenAddr(), "evil.internal", port)
+	conn.Close()
+	if code != socksReplyNotAllowed {
+		t.Errorf("disallowed domain: reply = %d, want not allowed", code)
+	}
+
+	conn, code = socksDial(t, s.ListenAddr(), "missing.internal", port)
+	conn.Close()
+	if code != socksReplyHostUnreachable {
+		t.Errorf("unresolvable domain: reply = %d, want host unreachable", code)
+	}
+}
+
+func TestSOCKS_DestinationNotAllowed(t *testing.T) {
+	echoAddr := startEchoServer(t)
+	host, portStr, _ := net.SplitHostPort(echoAddr)
+	port, _ := strconv.Atoi(portStr)
+	s := startSOCKSSession(t, []string{"10.0.0.0/8"}, nil)
+
+	conn, code := socksDial(t, s.ListenAddr(), host, port)
+	defer conn.Close()
+	if code != socksReplyNotAllowed {
+		t.Errorf("reply = %d, want not allowed", code)
+	}
+}
+
+func TestSOCKS_RejectsUnsupportedRequests(t *testing.T) {
+	s := startSOCKSSession(t, []string{"127.0.0.1"}, nil)
+
+	// No acceptable auth method.
+	conn, err := net.Dial("tcp", s.ListenAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+	_, _ = conn.Write([]byte{socksVersion, 1, 0x02})
+	var method [2]byte
+	if _, err := io.ReadFull(conn, method[:]); err != nil || method[1] != socksMethodNone {
+		t.Errorf("auth reply = %v, %v; want no acceptable methods", method, err)
+	}
+	conn.Close()
+
+	// BIND is not supported.
+	conn, err = net.Dial("tcp", s.ListenAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+	_, _ = conn.Write([]byte{socksVersion, 1, socksMethodNoAuth})
+	_, _ = io.ReadFull(conn, method[:])
+	_, _ = conn.Write([]byte{socksVersion, 0x02, 0, socksAtypIPv4, 127, 0, 0, 1, 0, 80})
+	var reply [10]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil || reply[1] != socksReplyCmdNotSupported {
+		t.Errorf("bind reply = %v, %v; want command not supported", reply, err)
+	}
+}
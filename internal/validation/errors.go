@@ -0,0 +1,130 @@
+// Package validation checks site-to-site tunnels, ingress rules, routes, and
+// egress rules before the bridge managers and the policy package apply
+// them, so that a definition the kernel would reject, or that would break
+// another tunnel, listener, or the node's own connectivity, fails with a
+// precise reason instead of a partially applied change.
+package validation
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// Kinds of validated objects, used in Error.Kind.
+const (
+	KindSiteToSiteTunnel = "site_to_site_tunnel"
+	KindIngressRule      = "ingress_rule"
+	KindAccessSubnet     = "access_subnet"
+	KindEgressPolicy     = "egress_policy"
+	KindEgressRule       = "egress_rule"
+)
+
+// Codes of validation failures, used in Error.Code.
+const (
+	// CodeMissingID is an object without an ID.
+	CodeMissingID = "missing_id"
+	// CodeInvalidCIDR is a subnet that is not a valid CIDR.
+	CodeInvalidCIDR = "invalid_cidr"
+	// CodeSubnetOverlap is a subnet that overlaps a subnet of the same or
+	// another tunnel.
+	CodeSubnetOverlap = "subnet_overlap"
+	// CodeInvalidPort is a port outside 1–65535.
+	CodeInvalidPort = "invalid_port"
+	// CodePortConflict is a port already used by another rule, tunnel, or
+	// WireGuard interface.
+	CodePortConflict = "port_conflict"
+	// CodeInvalidInterfaceName is an interface name the kernel rejects.
+	CodeInvalidInterfaceName = "invalid_interface_name"
+	// CodeInvalidTunnelType is a tunnel type other than wireguard or
+	// ipsec.
+	CodeInvalidTunnelType = "invalid_tunnel_type"
+	// CodeInvalidEndpoint is a remote endpoint that is not a host or
+	// host:port.
+	CodeInvalidEndpoint = "invalid_endpoint"
+	// CodeMissingPSK is an ipsec tunnel without a pre-shared key.
+	CodeMissingPSK = "missing_psk"
+	// CodeInvalidL2 is a layer-2 extension with an invalid VNI or VTEP
+	// address, or a VNI used by another tunnel.
+	CodeInvalidL2 = "invalid_l2"
+	// CodeRouteBlackhole is a subnet whose route would replace the default
+	// route or capture the traffic to a protected address, such as the
+	// control plane.
+	CodeRouteBlackhole = "route_blackhole"
+	// CodeInvalidMode is an egress policy mode other than monitor or
+	// enforce.
+	CodeInvalidMode = "invalid_mode"
+	// CodeInvalidDestination is an egress rule destination that is not a
+	// CIDR, an IP address, or a domain name.
+	CodeInvalidDestination = "invalid_destination"
+	// CodeInvalidProtocol is a protocol other than tcp or udp, or a port
+	// without a protocol.
+	CodeInvalidProtocol = "invalid_protocol"
+)
+
+// DriftValidationFailed is the drift correction type of a validation failure.
+const DriftValidationFailed = "validation_failed"
+
+// Error is a single validation failure of a tunnel or rule.
+type Error struct {
+	Kind    string `json:"kind"`
+	ID      string `json:"id"`
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("validation: %s %s: %s: %s", e.Kind, e.ID, e.Field, e.Message)
+}
+
+// Errors lists the validation failures of one or more objects, in the order
+// the objects were checked.
+type Errors []*Error
+
+func (es Errors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Err returns es as an error, or nil if es is empty.
+func (es Errors) Err() error {
+	if len(es) == 0 {
+		return nil
+	}
+	return es
+}
+
+// Invalid reports whether the object with id has a failure.
+func (es Errors) Invalid(id string) bool {
+	return slices.ContainsFunc(es, func(e *Error) bool { return e.ID == id })
+}
+
+// DriftCorrections returns one correction of type DriftValidationFailed per
+// failure. The reconciler adds them to the drift report of the cycle.
+func (es Errors) DriftCorrections() []api.DriftCorrection {
+	corrections := make([]api.DriftCorrection, len(es))
+	for i, e := range es {
+		corrections[i] = api.DriftCorrection{
+			Type:   DriftValidationFailed,
+			Detail: e.Error(),
+		}
+	}
+	return corrections
+}
+
+// add appends a failure to es.
+func (es *Errors) add(kind, id, field, code, format string, args ...any) {
+	*es = append(*es, &Error{
+		Kind:    kind,
+		ID:      id,
+		Field:   field,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
@@ -0,0 +1,410 @@
+package validation
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// MaxInterfaceNameLen is the longest interface name Linux accepts (IFNAMSIZ
+// less the terminating NUL).
+const MaxInterfaceNameLen = 15
+
+// ReservedPort is a UDP port in use by a WireGuard interface or another
+// listener of the node, which tunnels and UDP ingress rules must not take.
+type ReservedPort struct {
+	Port int
+	// Owner names the user of the port in error messages, e.g. "relay".
+	Owner string
+}
+
+// ProtectedAddr is an address the node must keep reaching over its current
+// route, such as the control plane or a default gateway.
+type ProtectedAddr struct {
+	Addr netip.Addr
+	// Owner names the address in error messages, e.g. "control plane".
+	Owner string
+	// Iface is the interface the address is reached over, if known. A route
+	// via that interface does not take the address away from it.
+	Iface string
+}
+
+// Route checks that routing subnet via iface leaves the node's own
+// connectivity alone: subnet must not be a default route, and must not
+// contain a protected address reached over another interface.
+func Route(subnet netip.Prefix, iface string, protected []ProtectedAddr) error {
+	if subnet.Bits() == 0 {
+		return fmt.Errorf("%s would replace the default route", subnet)
+	}
+	for _, p := range protected {
+		if p.Iface != "" && p.Iface == iface {
+			continue
+		}
+		if subnet.Contains(p.Addr) {
+			return fmt.Errorf("%s would capture %s of the %s", subnet, p.Addr, p.Owner)
+		}
+	}
+	return nil
+}
+
+// AccessSubnetRoutes checks the routes of bridge access subnets via iface
+// (see Route). Subnets that are not valid CIDRs are skipped. The ID of a
+// failure is the subnet.
+func AccessSubnetRoutes(subnets []string, iface string, protected []ProtectedAddr) Errors {
+	var errs Errors
+	for i, s := range subnets {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			continue
+		}
+		if err := Route(p, iface, protected); err != nil {
+			errs.add(KindAccessSubnet, s, fmt.Sprintf("access_subnets[%d]", i), CodeRouteBlackhole, "%v", err)
+		}
+	}
+	return errs
+}
+
+// SiteToSiteRoutes checks the routes of the remote subnets of each tunnel
+// via its interface (see Route). Subnets that are not valid CIDRs are left
+// to SiteToSiteTunnel.
+func SiteToSiteRoutes(tunnels []api.SiteToSiteTunnel, protected []ProtectedAddr) Errors {
+	var errs Errors
+	for _, t := range tunnels {
+		for i, s := range t.RemoteSubnets {
+			p, err := netip.ParsePrefix(s)
+			if err != nil {
+				continue
+			}
+			if err := Route(p, t.InterfaceName, protected); err != nil {
+				errs.add(KindSiteToSiteTunnel, t.TunnelID, fmt.Sprintf("remote_subnets[%d]", i), CodeRouteBlackhole, "%v", err)
+			}
+		}
+	}
+	return errs
+}
+
+// InterfaceName checks that name is a valid Linux interface name: 1 to
+// MaxInterfaceNameLen characters of letters, digits, '-', and '_'.
+func InterfaceName(name string) error {
+	if name == "" {
+		return fmt.Errorf("empty")
+	}
+	if len(name) > MaxInterfaceNameLen {
+		return fmt.Errorf("%q is longer than %d characters", name, MaxInterfaceNameLen)
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return fmt.Errorf("%q contains %q; only letters, digits, '-', and '_' are allowed", name, c)
+		}
+	}
+	return nil
+}
+
+// SplitEndpoint splits the remote endpoint of an ipsec tunnel into host and
+// port. The port is optional and 0 when omitted, so that the IKE default
+// applies.
+func SplitEndpoint(endpoint string) (string, int, error) {
+	if endpoint == "" {
+		return "", 0, fmt.Errorf("remote endpoint is empty")
+	}
+	host, portStr, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		// No port: a bare host or IPv6 address.
+		host = strings.TrimSuffix(strings.TrimPrefix(endpoint, "["), "]")
+		if strings.ContainsAny(host, "[]/ ") {
+			return "", 0, fmt.Errorf("invalid remote endpoint %q", endpoint)
+		}
+		return host, 0, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 || host == "" {
+		return "", 0, fmt.Errorf("invalid remote endpoint %q", endpoint)
+	}
+	return host, port, nil
+}
+
+// SiteToSiteTunnels checks a set of tunnels, each on its own and against the
+// tunnels before it, so that of two conflicting tunnels the later one fails.
+func SiteToSiteTunnels(tunnels []api.SiteToSiteTunnel, reserved []ReservedPort) Errors {
+	var errs Errors
+	for i, t := range tunnels {
+		errs = append(errs, SiteToSiteTunnel(t, tunnels[:i], reserved)...)
+	}
+	return errs
+}
+
+// SiteToSiteTunnel checks tunnel on its own and against the existing
+// tunnels: subnets must be valid CIDRs, remote subnets must not overlap each
+// other or those of an existing tunnel, the interface name must be valid and
+// unused, and the listen port must be in range and not used by an existing
+// tunnel or a reserved port. Port 0 lets the kernel pick a port and
+// conflicts with nothing. An ipsec tunnel needs a remote endpoint and a PSK,
+// and no listen port, since charon owns the IKE ports. A layer-2 extension
+// needs a VNI no existing tunnel uses, a valid bridge name, and VTEP
+// addresses within the local and remote subnets. An existing tunnel with the
+// same ID is ignored, so an update can be checked against the other tunnels.
+func SiteToSiteTunnel(tunnel api.SiteToSiteTunnel, existing []api.SiteToSiteTunnel, reserved []ReservedPort) Errors {
+	var errs Errors
+	fail := func(field, code, format string, args ...any) {
+		errs.add(KindSiteToSiteTunnel, tunnel.TunnelID, field, code, format, args...)
+	}
+
+	if tunnel.TunnelID == "" {
+		fail("tunnel_id", CodeMissingID, "tunnel ID is empty")
+	}
+	if err := InterfaceName(tunnel.InterfaceName); err != nil {
+		fail("interface_name", CodeInvalidInterfaceName, "interface name %v", err)
+	}
+	if tunnel.ListenPort < 0 || tunnel.ListenPort > 65535 {
+		fail("listen_port", CodeInvalidPort, "listen port %d is out of range", tunnel.ListenPort)
+	}
+	switch tunnel.Type {
+	case "", api.TunnelTypeWireGuard:
+	case api.TunnelTypeIPsec:
+		if tunnel.ListenPort != 0 {
+			fail("listen_port", CodeInvalidPort, "ipsec tunnels have no listen port")
+		}
+		if _, _, err := SplitEndpoint(tunnel.RemoteEndpoint); err != nil {
+			fail("remote_endpoint", CodeInvalidEndpoint, "%v", err)
+		}
+		if tunnel.PSK == "" {
+			fail("psk", CodeMissingPSK, "ipsec tunnels require a pre-shared key")
+		}
+	default:
+		fail("type", CodeInvalidTunnelType, "unknown tunnel type %q", tunnel.Type)
+	}
+	var local []netip.Prefix
+	for i, s := range tunnel.LocalSubnets {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			fail(fmt.Sprintf("local_subnets[%d]", i), CodeInvalidCIDR, "invalid CIDR %q", s)
+			continue
+		}
+		local = append(local, p)
+	}
+
+	var remote []netip.Prefix
+	for i, s := range tunnel.RemoteSubnets {
+		field := fmt.Sprintf("remote_subnets[%d]", i)
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			fail(field, CodeInvalidCIDR, "invalid CIDR %q", s)
+			continue
+		}
+		for _, q := range remote {
+			if p.Overlaps(q) {
+				fail(field, CodeSubnetOverlap, "%s overlaps %s of the same tunnel", p, q)
+			}
+		}
+		remote = append(remote, p)
+	}
+	if l2 := tunnel.L2; l2 != nil {
+		siteToSiteL2(*l2, tunnel.NATMap != nil, local, remote, fail)
+	}
+
+	for _, other := range existing {
+		if other.TunnelID == tunnel.TunnelID {
+			continue
+		}
+		if other.InterfaceName == tunnel.InterfaceName {
+			fail("interface_name", CodeInvalidInterfaceName, "interface %s is used by tunnel %s", tunnel.InterfaceName, other.TunnelID)
+		}
+		if tunnel.ListenPort != 0 && other.ListenPort == tunnel.ListenPort {
+			fail("listen_port", CodePortConflict, "listen port %d is used by tunnel %s", tunnel.ListenPort, other.TunnelID)
+		}
+		if tunnel.L2 != nil && other.L2 != nil && tunnel.L2.VNI == other.L2.VNI {
+			fail("l2.vni", CodeInvalidL2, "VNI %d is used by tunnel %s", tunnel.L2.VNI, other.TunnelID)
+		}
+		for i, p := range remote {
+			for _, s := range other.RemoteSubnets {
+				if q, err := netip.ParsePrefix(s); err == nil && p.Overlaps(q) {
+					fail(fmt.Sprintf("remote_subnets[%d]", i), CodeSubnetOverlap, "%s overlaps %s of tunnel %s", p, q, other.TunnelID)
+				}
+			}
+		}
+	}
+	if tunnel.ListenPort != 0 {
+		for _, r := range reserved {
+			if r.Port == tunnel.ListenPort {
+				fail("listen_port", CodePortConflict, "listen port %d is used by %s", tunnel.ListenPort, r.Owner)
+			}
+		}
+	}
+	return errs
+}
+
+// MaxVNI is the largest VXLAN network identifier.
+const MaxVNI = 1<<24 - 1
+
+// siteToSiteL2 checks the layer-2 extension of a tunnel with the given
+// local and remote subnets. The VTEP addresses must lie within them, so that
+// the VXLAN is routed through the tunnel, and must not be translated by a
+// NAT map.
+func siteToSiteL2(l2 api.SiteToSiteL2, natMap bool, local, remote []netip.Prefix, fail func(field, code, format string, args ...any)) {
+	if l2.VNI < 1 || l2.VNI > MaxVNI {
+		fail("l2.vni", CodeInvalidL2, "VNI %d is out of range", l2.VNI)
+	}
+	if err := InterfaceName(l2.Bridge); err != nil {
+		fail("l2.bridge", CodeInvalidInterfaceName, "bridge name %v", err)
+	}
+	if l2.MaxMACs < 0 {
+		fail("l2.max_macs", CodeInvalidL2, "MAC limit %d is negative", l2.MaxMACs)
+	}
+	if natMap {
+		fail("l2", CodeInvalidL2, "a layer-2 extension cannot be combined with a NAT map")
+	}
+	localVTEP, localOK := vtep("l2.local_vtep", l2.LocalVTEP, local, "local", fail)
+	remoteVTEP, remoteOK := vtep("l2.remote_vtep", l2.RemoteVTEP, remote, "remote", fail)
+	if localOK && remoteOK && localVTEP.Is4() != remoteVTEP.Is4() {
+		fail("l2.remote_vtep", CodeInvalidL2, "VTEP addresses %s and %s differ in address family", localVTEP, remoteVTEP)
+	}
+}
+
+// vtep parses the VTEP address s and checks that it lies within one of
+// subnets.
+func vtep(field, s string, subnets []netip.Prefix, side string, fail func(field, code, format string, args ...any)) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		fail(field, CodeInvalidL2, "invalid VTEP address %q", s)
+		return netip.Addr{}, false
+	}
+	for _, p := range subnets {
+		if p.Contains(addr) {
+			return addr, true
+		}
+	}
+	fail(field, CodeInvalidL2, "VTEP address %s is not within the %s subnets", addr, side)
+	return netip.Addr{}, false
+}
+
+// IngressRules checks a set of rules, each on its own and against the rules
+// before it, so that of two conflicting rules the later one fails.
+func IngressRules(rules []api.IngressRule, reserved []ReservedPort) Errors {
+	var errs Errors
+	for i, r := range rules {
+		errs = append(errs, IngressRule(r, rules[:i], reserved)...)
+	}
+	return errs
+}
+
+// IngressRule checks rule on its own and against the existing rules: the
+// listen port must be in range and not used by an existing rule of the same
+// protocol, and a rule in udp mode must not take a reserved port, since
+// WireGuard interfaces listen on UDP. Port 0 lets the kernel pick a port and
+// conflicts with nothing. An existing rule with the same ID is ignored.
+func IngressRule(rule api.IngressRule, existing []api.IngressRule, reserved []ReservedPort) Errors {
+	var errs Errors
+	fail := func(field, code, format string, args ...any) {
+		errs.add(KindIngressRule, rule.RuleID, field, code, format, args...)
+	}
+
+	if rule.RuleID == "" {
+		fail("rule_id", CodeMissingID, "rule ID is empty")
+	}
+	if rule.ListenPort < 0 || rule.ListenPort > 65535 {
+		fail("listen_port", CodeInvalidPort, "listen port %d is out of range", rule.ListenPort)
+		return errs
+	}
+	if rule.ListenPort == 0 {
+		return errs
+	}
+	udp := rule.Mode == "udp"
+	for _, other := range existing {
+		if other.RuleID == rule.RuleID {
+			continue
+		}
+		if other.ListenPort == rule.ListenPort && (other.Mode == "udp") == udp {
+			fail("listen_port", CodePortConflict, "listen port %d is used by rule %s", rule.ListenPort, other.RuleID)
+		}
+	}
+	if udp {
+		for _, r := range reserved {
+			if r.Port == rule.ListenPort {
+				fail("listen_port", CodePortConflict, "udp listen port %d is used by %s", rule.ListenPort, r.Owner)
+			}
+		}
+	}
+	return errs
+}
+
+// EgressMode checks that the mode of an egress policy is monitor or
+// enforce.
+func EgressMode(mode string) Errors {
+	var errs Errors
+	if mode != "monitor" && mode != "enforce" {
+		errs.add(KindEgressPolicy, "", "mode", CodeInvalidMode, "mode %q is not monitor or enforce", mode)
+	}
+	return errs
+}
+
+// EgressRule checks the rule at index i of an egress policy: the
+// destination must be a CIDR, an IP address, or a domain name, the port in
+// range, and the protocol tcp, udp, or empty, but not empty with a port.
+// The ID of a failure is the destination.
+func EgressRule(i int, rule api.EgressRule) Errors {
+	var errs Errors
+	fail := func(field, code, format string, args ...any) {
+		errs.add(KindEgressRule, rule.Destination, fmt.Sprintf("rules[%d].%s", i, field), code, format, args...)
+	}
+
+	if !egressDestination(rule.Destination) {
+		fail("destination", CodeInvalidDestination, "%q is not a CIDR, an IP address, or a domain name", rule.Destination)
+	}
+	if rule.Port < 0 || rule.Port > 65535 {
+		fail("port", CodeInvalidPort, "port %d is out of range", rule.Port)
+	}
+	switch {
+	case rule.Protocol != "" && rule.Protocol != "tcp" && rule.Protocol != "udp":
+		fail("protocol", CodeInvalidProtocol, "protocol %q is not tcp or udp", rule.Protocol)
+	case rule.Port > 0 && rule.Protocol == "":
+		fail("protocol", CodeInvalidProtocol, "port %d requires a protocol", rule.Port)
+	}
+	return errs
+}
+
+// egressDestination reports whether d is a CIDR, an IP address, or a
+// domain name (see DomainName).
+func egressDestination(d string) bool {
+	if _, err := netip.ParsePrefix(d); err == nil {
+		return true
+	}
+	if _, err := netip.ParseAddr(d); err == nil {
+		return true
+	}
+	return DomainName(d) == nil
+}
+
+// DomainName checks that name is a DNS name of at most 253 characters, with
+// an optional trailing dot: labels of 1 to 63 letters, digits, and '-' that
+// neither start nor end with '-'. The last label must not be numeric, so
+// that a mistyped IP address is not taken for a name.
+func DomainName(name string) error {
+	if name == "" {
+		return fmt.Errorf("empty")
+	}
+	if len(name) > 253 {
+		return fmt.Errorf("%q is longer than 253 characters", name)
+	}
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	if _, err := strconv.Atoi(labels[len(labels)-1]); err == nil {
+		return fmt.Errorf("%q ends in a numeric label", name)
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("%q has a label of %d characters", name, len(label))
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("%q has a label starting or ending with '-'", name)
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return fmt.Errorf("%q contains %q; only letters, digits, '-', and '.' are allowed", name, c)
+			}
+		}
+	}
+	return nil
+}
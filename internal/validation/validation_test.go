@@ -0,0 +1,442 @@
+package validation
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+func testTunnel(id, iface string, port int, remote ...string) api.SiteToSiteTunnel {
+	return api.SiteToSiteTunnel{
+		TunnelID:      id,
+		LocalSubnets:  []string{"10.0.0.0/24"},
+		RemoteSubnets: remote,
+		InterfaceName: iface,
+		ListenPort:    port,
+	}
+}
+
+func testIPsecTunnel(id, endpoint string) api.SiteToSiteTunnel {
+	tun := testTunnel(id, "xfrm-s2s-2", 0, "10.2.0.0/24")
+	tun.Type = api.TunnelTypeIPsec
+	tun.RemoteEndpoint = endpoint
+	tun.PSK = "legacy-firewall-secret"
+	return tun
+}
+
+// codes returns the "id/field/code" of each failure.
+func codes(errs Errors) []string {
+	var out []string
+	for _, e := range errs {
+		out = append(out, e.ID+"/"+e.Field+"/"+e.Code)
+	}
+	return out
+}
+
+func TestInterfaceName(t *testing.T) {
+	for _, name := range []string{"wg-s2s-0", "wg_access", "a", "abcdefghijklmno"} {
+		if err := InterfaceName(name); err != nil {
+			t.Errorf("InterfaceName(%q) = %v, want nil", name, err)
+		}
+	}
+	for _, name := range []string{"", "abcdefghijklmnop", "wg/0", "wg.0", "wg 0", "wg:0"} {
+		if err := InterfaceName(name); err == nil {
+			t.Errorf("InterfaceName(%q) = nil, want error", name)
+		}
+	}
+}
+
+func TestDomainName(t *testing.T) {
+	for _, name := range []string{"example.com", "api.example.com.", "proxy", "xn--bcher-kva.example", "1password.com"} {
+		if err := DomainName(name); err != nil {
+			t.Errorf("DomainName(%q) = %v, want nil", name, err)
+		}
+	}
+	for _, name := range []string{"", ".", "a..b", "-a.example", "a-.example", "203.0.113.256", "a_b.example", "*.example.com", strings.Repeat("a", 64) + ".example"} {
+		if err := DomainName(name); err == nil {
+			t.Errorf("DomainName(%q) = nil, want error", name)
+		}
+	}
+}
+
+func TestSiteToSiteTunnel(t *testing.T) {
+	existing := []api.SiteToSiteTunnel{
+		testTunnel("t-1", "wg-s2s-1", 51823, "10.1.0.0/16"),
+	}
+	reserved := []ReservedPort{{Port: 51821, Owner: "relay"}}
+
+	tests := []struct {
+		name   string
+		tunnel api.SiteToSiteTunnel
+		want   []string
+	}{
+		{
+			name:   "valid",
+			tunnel: testTunnel("t-2", "wg-s2s-2", 51824, "10.2.0.0/24", "10.3.0.0/24"),
+		},
+		{
+			name:   "update of an existing tunnel",
+			tunnel: testTunnel("t-1", "wg-s2s-1", 51823, "10.1.0.0/24"),
+		},
+		{
+			name:   "kernel-assigned port",
+			tunnel: testTunnel("t-2", "wg-s2s-2", 0, "10.2.0.0/24"),
+		},
+		{
+			name:   "invalid CIDR",
+			tunnel: testTunnel("t-2", "wg-s2s-2", 51824, "10.2.0.0/33"),
+			want:   []string{"t-2/remote_subnets[0]/invalid_cidr"},
+		},
+		{
+			name: "invalid local CIDR",
+			tunnel: func() api.SiteToSiteTunnel {
+				tun := testTunnel("t-2", "wg-s2s-2", 51824, "10.2.0.0/24")
+				tun.LocalSubnets = []string{"10.0.0.0"}
+				return tun
+			}(),
+			want: []string{"t-2/local_subnets[0]/invalid_cidr"},
+		},
+		{
+			name:   "overlap within the tunnel",
+			tunnel: testTunnel("t-2", "wg-s2s-2", 51824, "10.2.0.0/16", "10.2.1.0/24"),
+			want:   []string{"t-2/remote_subnets[1]/subnet_overlap"},
+		},
+		{
+			name:   "overlap with another tunnel",
+			tunnel: testTunnel("t-2", "wg-s2s-2", 51824, "10.1.5.0/24"),
+			want:   []string{"t-2/remote_subnets[0]/subnet_overlap"},
+		},
+		{
+			name:   "port of another tunnel",
+			tunnel: testTunnel("t-2", "wg-s2s-2", 51823, "10.2.0.0/24"),
+			want:   []string{"t-2/listen_port/port_conflict"},
+		},
+		{
+			name:   "reserved port",
+			tunnel: testTunnel("t-2", "wg-s2s-2", 51821, "10.2.0.0/24"),
+			want:   []string{"t-2/listen_port/port_conflict"},
+		},
+		{
+			name:   "port out of range",
+			tunnel: testTunnel("t-2", "wg-s2s-2", 70000, "10.2.0.0/24"),
+			want:   []string{"t-2/listen_port/invalid_port"},
+		},
+		{
+			name:   "interface of another tunnel",
+			tunnel: testTunnel("t-2", "wg-s2s-1", 51824, "10.2.0.0/24"),
+			want:   []string{"t-2/interface_name/invalid_interface_name"},
+		},
+		{
+			name:   "interface name too long",
+			tunnel: testTunnel("t-2", "wg-site-to-site-2", 51824, "10.2.0.0/24"),
+			want:   []string{"t-2/interface_name/invalid_interface_name"},
+		},
+		{
+			name:   "missing ID",
+			tunnel: testTunnel("", "wg-s2s-2", 51824, "10.2.0.0/24"),
+			want:   []string{"/tunnel_id/missing_id"},
+		},
+		{
+			name:   "ipsec",
+			tunnel: testIPsecTunnel("t-2", "203.0.113.1"),
+		},
+		{
+			name: "ipsec with listen port, without PSK",
+			tunnel: func() api.SiteToSiteTunnel {
+				tun := testIPsecTunnel("t-2", "203.0.113.1:4500")
+				tun.ListenPort = 51824
+				tun.PSK = ""
+				return tun
+			}(),
+			want: []string{"t-2/listen_port/invalid_port", "t-2/psk/missing_psk"},
+		},
+		{
+			name:   "ipsec without endpoint",
+			tunnel: testIPsecTunnel("t-2", ""),
+			want:   []string{"t-2/remote_endpoint/invalid_endpoint"},
+		},
+		{
+			name: "unknown type",
+			tunnel: func() api.SiteToSiteTunnel {
+				tun := testTunnel("t-2", "wg-s2s-2", 51824, "10.2.0.0/24")
+				tun.Type = "gre"
+				return tun
+			}(),
+			want: []string{"t-2/type/invalid_tunnel_type"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := codes(SiteToSiteTunnel(tt.tunnel, existing, reserved))
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("SiteToSiteTunnel = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSiteToSiteTunnel_L2(t *testing.T) {
+	l2Tunnel := func(id string, vni uint32, remote string) api.SiteToSiteTunnel {
+		tun := testTunnel(id, "wg-s2s-"+id, 0, remote)
+		tun.L2 = &api.SiteToSiteL2{VNI: vni, Bridge: "br-l2", LocalVTEP: "10.0.0.1", RemoteVTEP: strings.TrimSuffix(remote, "0/24") + "1"}
+		return tun
+	}
+	existing := []api.SiteToSiteTunnel{l2Tunnel("1", 100, "10.1.0.0/24")}
+
+	tests := []struct {
+		name   string
+		modify func(*api.SiteToSiteTunnel)
+		want   []string
+	}{
+		{name: "valid"},
+		{
+			name:   "VNI of another tunnel",
+			modify: func(tun *api.SiteToSiteTunnel) { tun.L2.VNI = 100 },
+			want:   []string{"2/l2.vni/invalid_l2"},
+		},
+		{
+			name:   "VNI out of range",
+			modify: func(tun *api.SiteToSiteTunnel) { tun.L2.VNI = MaxVNI + 1 },
+			want:   []string{"2/l2.vni/invalid_l2"},
+		},
+		{
+			name:   "invalid bridge",
+			modify: func(tun *api.SiteToSiteTunnel) { tun.L2.Bridge = "" },
+			want:   []string{"2/l2.bridge/invalid_interface_name"},
+		},
+		{
+			name: "VTEPs outside the subnets",
+			modify: func(tun *api.SiteToSiteTunnel) {
+				tun.L2.LocalVTEP = "10.9.0.1"
+				tun.L2.RemoteVTEP = "10.1.0.1"
+			},
+			want: []string{"2/l2.local_vtep/invalid_l2", "2/l2.remote_vtep/invalid_l2"},
+		},
+		{
+			name: "VTEPs of different families",
+			modify: func(tun *api.SiteToSiteTunnel) {
+				tun.RemoteSubnets = append(tun.RemoteSubnets, "fd00:2::/64")
+				tun.L2.RemoteVTEP = "fd00:2::1"
+			},
+			want: []string{"2/l2.remote_vtep/invalid_l2"},
+		},
+		{
+			name: "NAT map and negative MAC limit",
+			modify: func(tun *api.SiteToSiteTunnel) {
+				tun.NATMap = &api.SiteToSiteNATMap{Local: "10.0.0.0/24", As: "10.200.0.0/24"}
+				tun.L2.MaxMACs = -1
+			},
+			want: []string{"2/l2.max_macs/invalid_l2", "2/l2/invalid_l2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tun := l2Tunnel("2", 200, "10.2.0.0/24")
+			if tt.modify != nil {
+				tt.modify(&tun)
+			}
+			got := codes(SiteToSiteTunnel(tun, existing, nil))
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("SiteToSiteTunnel = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		host     string
+		port     int
+		ok       bool
+	}{
+		{"203.0.113.1", "203.0.113.1", 0, true},
+		{"203.0.113.1:4500", "203.0.113.1", 4500, true},
+		{"vpn.example.com", "vpn.example.com", 0, true},
+		{"2001:db8::1", "2001:db8::1", 0, true},
+		{"[2001:db8::1]", "2001:db8::1", 0, true},
+		{"[2001:db8::1]:500", "2001:db8::1", 500, true},
+		{"", "", 0, false},
+		{"203.0.113.1:0", "", 0, false},
+		{"203.0.113.1:ike", "", 0, false},
+		{":500", "", 0, false},
+		{"10.0.0.0/8", "", 0, false},
+	}
+	for _, tt := range tests {
+		host, port, err := SplitEndpoint(tt.endpoint)
+		if (err == nil) != tt.ok || host != tt.host || port != tt.port {
+			t.Errorf("SplitEndpoint(%q) = %q, %d, %v; want %q, %d, ok=%v", tt.endpoint, host, port, err, tt.host, tt.port, tt.ok)
+		}
+	}
+}
+
+func TestSiteToSiteTunnels_LaterFails(t *testing.T) {
+	errs := SiteToSiteTunnels([]api.SiteToSiteTunnel{
+		testTunnel("t-1", "wg-s2s-1", 51823, "10.1.0.0/24"),
+		testTunnel("t-2", "wg-s2s-2", 51824, "10.1.0.0/24"),
+		testTunnel("t-3", "wg-s2s-3", 51825, "10.3.0.0/24"),
+	}, nil)
+	if errs.Invalid("t-1") || !errs.Invalid("t-2") || errs.Invalid("t-3") {
+		t.Errorf("SiteToSiteTunnels = %v, want only t-2 invalid", codes(errs))
+	}
+}
+
+func TestRoute(t *testing.T) {
+	protected := []ProtectedAddr{
+		{Addr: netip.MustParseAddr("198.51.100.10"), Owner: "control plane"},
+		{Addr: netip.MustParseAddr("192.168.1.1"), Owner: "default gateway", Iface: "eth1"},
+	}
+	tests := []struct {
+		subnet string
+		iface  string
+		want   string
+	}{
+		{"10.0.0.0/8", "eth1", ""},
+		{"0.0.0.0/0", "eth1", "0.0.0.0/0 would replace the default route"},
+		{"::/0", "eth1", "::/0 would replace the default route"},
+		{"198.51.100.0/24", "eth1", "198.51.100.0/24 would capture 198.51.100.10 of the control plane"},
+		{"128.0.0.0/1", "wg-s2s-0", "128.0.0.0/1 would capture 198.51.100.10 of the control plane"},
+		{"192.168.1.0/24", "wg-s2s-0", "192.168.1.0/24 would capture 192.168.1.1 of the default gateway"},
+		{"192.168.1.0/24", "eth1", ""},
+	}
+	for _, tt := range tests {
+		err := Route(netip.MustParsePrefix(tt.subnet), tt.iface, protected)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("Route(%s via %s) = %v, want nil", tt.subnet, tt.iface, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Route(%s via %s) = %v, want %q", tt.subnet, tt.iface, err, tt.want)
+		}
+	}
+}
+
+func TestAccessSubnetRoutes(t *testing.T) {
+	protected := []ProtectedAddr{{Addr: netip.MustParseAddr("198.51.100.10"), Owner: "control plane"}}
+
+	errs := AccessSubnetRoutes([]string{"10.0.0.0/24", "bad", "198.51.100.0/24"}, "eth1", protected)
+
+	want := []string{"198.51.100.0/24/access_subnets[2]/route_blackhole"}
+	if got := codes(errs); !slices.Equal(got, want) {
+		t.Errorf("codes = %v, want %v", got, want)
+	}
+	if errs[0].Kind != KindAccessSubnet {
+		t.Errorf("kind = %q, want %q", errs[0].Kind, KindAccessSubnet)
+	}
+}
+
+func TestSiteToSiteRoutes(t *testing.T) {
+	protected := []ProtectedAddr{{Addr: netip.MustParseAddr("198.51.100.10"), Owner: "control plane"}}
+	tunnels := []api.SiteToSiteTunnel{
+		testTunnel("t-1", "wg-s2s-1", 51823, "10.1.0.0/16"),
+		testTunnel("t-2", "wg-s2s-2", 51824, "10.2.0.0/16", "0.0.0.0/0", "198.51.100.0/24"),
+	}
+
+	want := []string{
+		"t-2/remote_subnets[1]/route_blackhole",
+		"t-2/remote_subnets[2]/route_blackhole",
+	}
+	if got := codes(SiteToSiteRoutes(tunnels, protected)); !slices.Equal(got, want) {
+		t.Errorf("codes = %v, want %v", got, want)
+	}
+}
+
+func TestIngressRule(t *testing.T) {
+	existing := []api.IngressRule{
+		{RuleID: "r-1", ListenPort: 8443, Mode: "passthrough"},
+		{RuleID: "r-2", ListenPort: 5353, Mode: "udp"},
+	}
+	reserved := []ReservedPort{{Port: 51822, Owner: "user access interface"}}
+
+	tests := []struct {
+		name string
+		rule api.IngressRule
+		want []string
+	}{
+		{"valid", api.IngressRule{RuleID: "r-3", ListenPort: 9443, Mode: "passthrough"}, nil},
+		{"same port other protocol", api.IngressRule{RuleID: "r-3", ListenPort: 8443, Mode: "udp"}, nil},
+		{"tcp on WireGuard port", api.IngressRule{RuleID: "r-3", ListenPort: 51822, Mode: "passthrough"}, nil},
+		{"kernel-assigned port", api.IngressRule{RuleID: "r-3", ListenPort: 0, Mode: "udp"}, nil},
+		{"update of an existing rule", api.IngressRule{RuleID: "r-1", ListenPort: 8443, Mode: "terminate"}, nil},
+		{"port of another rule", api.IngressRule{RuleID: "r-3", ListenPort: 8443, Mode: "terminate"}, []string{"r-3/listen_port/port_conflict"}},
+		{"udp port of another rule", api.IngressRule{RuleID: "r-3", ListenPort: 5353, Mode: "udp"}, []string{"r-3/listen_port/port_conflict"}},
+		{"udp on WireGuard port", api.IngressRule{RuleID: "r-3", ListenPort: 51822, Mode: "udp"}, []string{"r-3/listen_port/port_conflict"}},
+		{"port out of range", api.IngressRule{RuleID: "r-3", ListenPort: -1}, []string{"r-3/listen_port/invalid_port"}},
+		{"missing ID", api.IngressRule{ListenPort: 9443}, []string{"/rule_id/missing_id"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := codes(IngressRule(tt.rule, existing, reserved))
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("IngressRule = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEgressRule(t *testing.T) {
+	tests := []struct {
+		name string
+		rule api.EgressRule
+		want []string
+	}{
+		{"CIDR", api.EgressRule{Destination: "203.0.113.0/24"}, nil},
+		{"IPv6 address", api.EgressRule{Destination: "2001:db8::1", Port: 443, Protocol: "tcp"}, nil},
+		{"domain", api.EgressRule{Destination: "updates.example.com", Port: 443, Protocol: "tcp"}, nil},
+		{"single label", api.EgressRule{Destination: "proxy", Port: 3128, Protocol: "tcp"}, nil},
+		{"invalid CIDR", api.EgressRule{Destination: "10.0.0.0/33"}, []string{"10.0.0.0/33/rules[2].destination/invalid_destination"}},
+		{"mistyped address", api.EgressRule{Destination: "203.0.113.256"}, []string{"203.0.113.256/rules[2].destination/invalid_destination"}},
+		{"wildcard", api.EgressRule{Destination: "*.example.com"}, []string{"*.example.com/rules[2].destination/invalid_destination"}},
+		{"empty", api.EgressRule{}, []string{"/rules[2].destination/invalid_destination"}},
+		{"port out of range", api.EgressRule{Destination: "example.com", Port: 70000, Protocol: "tcp"}, []string{"example.com/rules[2].port/invalid_port"}},
+		{"port without protocol", api.EgressRule{Destination: "example.com", Port: 443}, []string{"example.com/rules[2].protocol/invalid_protocol"}},
+		{"unknown protocol", api.EgressRule{Destination: "example.com", Protocol: "icmp"}, []string{"example.com/rules[2].protocol/invalid_protocol"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := codes(EgressRule(2, tt.rule))
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("EgressRule = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := codes(EgressMode("audit")); strings.Join(got, " ") != "/mode/invalid_mode" {
+		t.Errorf("EgressMode(audit) = %v, want an invalid mode", got)
+	}
+	for _, mode := range []string{"monitor", "enforce"} {
+		if errs := EgressMode(mode); errs != nil {
+			t.Errorf("EgressMode(%s) = %v, want nil", mode, errs)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var none Errors
+	if none.Err() != nil {
+		t.Errorf("Err() of no failures = %v, want nil", none.Err())
+	}
+
+	errs := SiteToSiteTunnels([]api.SiteToSiteTunnel{
+		testTunnel("t-1", "wg-s2s-1", 51823, "10.1.0.0/24"),
+		testTunnel("t-2", "wg-s2s-1", 51824, "bad"),
+	}, nil)
+	if len(errs) != 2 {
+		t.Fatalf("errs = %v, want 2 failures", codes(errs))
+	}
+	want := `validation: site_to_site_tunnel t-2: remote_subnets[0]: invalid CIDR "bad"; ` +
+		`validation: site_to_site_tunnel t-2: interface_name: interface wg-s2s-1 is used by tunnel t-1`
+	if got := errs.Err().Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+
+	corrections := errs.DriftCorrections()
+	if len(corrections) != 2 || corrections[0].Type != DriftValidationFailed || corrections[0].Detail != errs[0].Error() {
+		t.Errorf("DriftCorrections() = %+v", corrections)
+	}
+}
@@ -0,0 +1,207 @@
+package vici
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// DefaultSocketPath is the Unix socket charon listens on for VICI clients.
+const DefaultSocketPath = "/var/run/charon.vici"
+
+// DefaultTimeout bounds a command, including dialing the socket.
+const DefaultTimeout = 10 * time.Second
+
+// maxPacketSize is the largest packet charon sends or accepts.
+const maxPacketSize = 512 * 1024
+
+// Packet types.
+const (
+	pktCmdRequest      = 0
+	pktCmdResponse     = 1
+	pktCmdUnknown      = 2
+	pktEventRegister   = 3
+	pktEventUnregister = 4
+	pktEventConfirm    = 5
+	pktEventUnknown    = 6
+	pktEvent           = 7
+)
+
+// CommandError is a command that charon executed and reported as failed,
+// with success set to "no".
+type CommandError struct {
+	Command string
+	Message string
+}
+
+func (e *CommandError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("vici: %s failed", e.Command)
+	}
+	return fmt.Sprintf("vici: %s: %s", e.Command, e.Message)
+}
+
+// Client sends VICI commands to charon. Each command uses its own
+// connection, so the client recovers transparently when charon restarts.
+// It is safe for concurrent use.
+type Client struct {
+	path    string
+	timeout time.Duration
+}
+
+// NewClient returns a Client for the socket at path. An empty path selects
+// DefaultSocketPath, a zero timeout DefaultTimeout.
+func NewClient(path string, timeout time.Duration) *Client {
+	if path == "" {
+		path = DefaultSocketPath
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Client{path: path, timeout: timeout}
+}
+
+// Call sends the command cmd with the message req, which may be nil, and
+// returns the response. A response with success set to "no" is returned
+// along with a *CommandError.
+func (c *Client) Call(ctx context.Context, cmd string, req *Message) (*Message, error) {
+	_, resp, err := c.Stream(ctx, cmd, "", req)
+	return resp, err
+}
+
+// Stream is like Call for commands that stream their results as events,
+// such as list-sas: it registers for event before sending the command and
+// returns the events received until the response. An empty event sends the
+// command without registering.
+func (c *Client) Stream(ctx context.Context, cmd, event string, req *Message) ([]*Message, *Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", c.path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("vici: %s: dial %s: %w", cmd, c.path, err)
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	if event != "" {
+		if err := writePacket(conn, pktEventRegister, event, nil); err != nil {
+			return nil, nil, fmt.Errorf("vici: %s: register %s: %w", cmd, event, err)
+		}
+		for {
+			typ, _, _, err := readPacket(conn)
+			if err != nil {
+				return nil, nil, fmt.Errorf("vici: %s: register %s: %w", cmd, event, err)
+			}
+			if typ == pktEventConfirm {
+				break
+			}
+			if typ == pktEventUnknown {
+				return nil, nil, fmt.Errorf("vici: %s: unknown event %s", cmd, event)
+			}
+		}
+	}
+
+	if req == nil {
+		req = NewMessage()
+	}
+	if err := writePacket(conn, pktCmdRequest, cmd, req); err != nil {
+		return nil, nil, fmt.Errorf("vici: %s: send request: %w", cmd, err)
+	}
+
+	var events []*Message
+	for {
+		typ, name, msg, err := readPacket(conn)
+		if err != nil {
+			return nil, nil, fmt.Errorf("vici: %s: read response: %w", cmd, err)
+		}
+		switch typ {
+		case pktEvent:
+			if name == event {
+				events = append(events, msg)
+			}
+		case pktCmdResponse:
+			if msg.Get("success") == "no" {
+				return events, msg, &CommandError{Command: cmd, Message: msg.Get("errmsg")}
+			}
+			return events, msg, nil
+		case pktCmdUnknown:
+			return nil, nil, fmt.Errorf("vici: %s: unknown command", cmd)
+		default:
+			return nil, nil, fmt.Errorf("vici: %s: unexpected packet type %d", cmd, typ)
+		}
+	}
+}
+
+// named reports whether packets of type typ carry a name.
+func named(typ byte) bool {
+	switch typ {
+	case pktCmdRequest, pktEventRegister, pktEventUnregister, pktEvent:
+		return true
+	}
+	return false
+}
+
+// writePacket writes a packet of type typ. name is written for named types,
+// msg may be nil.
+func writePacket(w io.Writer, typ byte, name string, msg *Message) error {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 4))
+	buf.WriteByte(typ)
+	if named(typ) {
+		if len(name) > 255 {
+			return fmt.Errorf("vici: name of %d bytes is longer than 255 bytes", len(name))
+		}
+		writeName(&buf, name)
+	}
+	if msg != nil {
+		if err := msg.encode(&buf); err != nil {
+			return err
+		}
+	}
+	b := buf.Bytes()
+	if len(b)-4 > maxPacketSize {
+		return fmt.Errorf("vici: packet of %d bytes exceeds %d bytes", len(b)-4, maxPacketSize)
+	}
+	binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+	_, err := w.Write(b)
+	return err
+}
+
+// readPacket reads a packet and decodes its message.
+func readPacket(r io.Reader) (typ byte, name string, msg *Message, err error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, "", nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n == 0 {
+		return 0, "", nil, errors.New("vici: empty packet")
+	}
+	if n > maxPacketSize {
+		return 0, "", nil, fmt.Errorf("vici: packet of %d bytes exceeds %d bytes", n, maxPacketSize)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, "", nil, err
+	}
+	typ, data = data[0], data[1:]
+	if named(typ) {
+		if len(data) < 1 || len(data) < 1+int(data[0]) {
+			return 0, "", nil, errTruncated
+		}
+		name, data = string(data[1:1+int(data[0])]), data[1+int(data[0]):]
+	}
+	msg, err = decodeMessage(data)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	return typ, name, msg, nil
+}
@@ -0,0 +1,121 @@
+package vici_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/vici"
+	"github.com/plexsphere/plexd/internal/vici/vicitest"
+)
+
+func TestClient_Call(t *testing.T) {
+	srv := vicitest.NewServer(t)
+	c := vici.NewClient(srv.Path(), time.Second)
+	ctx := context.Background()
+
+	conn := vici.NewMessage()
+	conn.SetList("remote_addrs", []string{"203.0.113.1"})
+	req := vici.NewMessage()
+	req.SetSection("site-a", conn)
+	if _, err := c.Call(ctx, "load-conn", req); err != nil {
+		t.Fatalf("load-conn: %v", err)
+	}
+	resp, err := c.Call(ctx, "get-conns", nil)
+	if err != nil {
+		t.Fatalf("get-conns: %v", err)
+	}
+	if got := resp.List("conns"); !slices.Equal(got, []string{"site-a"}) {
+		t.Errorf("conns = %v, want [site-a]", got)
+	}
+}
+
+func TestClient_CommandError(t *testing.T) {
+	srv := vicitest.NewServer(t)
+	c := vici.NewClient(srv.Path(), time.Second)
+
+	req := vici.NewMessage()
+	req.Set("name", "missing")
+	resp, err := c.Call(context.Background(), "unload-conn", req)
+	var cerr *vici.CommandError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("unload-conn error = %v, want a *CommandError", err)
+	}
+	if cerr.Command != "unload-conn" || cerr.Message != "unload: connection 'missing' not found" {
+		t.Errorf("CommandError = %+v", cerr)
+	}
+	if resp == nil || resp.Get("success") != "no" {
+		t.Errorf("response = %+v, want the failed response", resp)
+	}
+
+	if _, err := c.Call(context.Background(), "no-such-command", nil); err == nil || errors.As(err, &cerr) {
+		t.Errorf("unknown command error = %v, want a protocol error", err)
+	}
+}
+
+func TestClient_Stream(t *testing.T) {
+	srv := vicitest.NewServer(t)
+	for _, name := range []string{"site-a", "site-b"} {
+		sa := vici.NewMessage()
+		sa.Set("state", "ESTABLISHED")
+		srv.SetSA(name, sa)
+	}
+	c := vici.NewClient(srv.Path(), time.Second)
+
+	req := vici.NewMessage()
+	req.Set("ike", "site-b")
+	events, _, err := c.Stream(context.Background(), "list-sas", "list-sa", req)
+	if err != nil {
+		t.Fatalf("list-sas: %v", err)
+	}
+	if len(events) != 1 || events[0].Section("site-b").Get("state") != "ESTABLISHED" {
+		t.Errorf("events = %+v, want the SA of site-b", events)
+	}
+
+	if _, _, err := c.Stream(context.Background(), "list-sas", "no-such-event", nil); err == nil {
+		t.Error("Stream with an unknown event succeeded, want error")
+	}
+}
+
+func TestClient_DialError(t *testing.T) {
+	c := vici.NewClient(filepath.Join(t.TempDir(), "missing.vici"), time.Second)
+	if _, err := c.Call(context.Background(), "version", nil); err == nil {
+		t.Fatal("Call without charon succeeded, want error")
+	}
+}
+
+func TestClient_Timeout(t *testing.T) {
+	dir, err := os.MkdirTemp("", "vici")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ln, err := net.Listen("unix", filepath.Join(dir, "charon.vici"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// Accept, but never answer.
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			_, _ = conn.Read(make([]byte, 1024))
+			time.Sleep(200 * time.Millisecond)
+		}
+	}()
+
+	c := vici.NewClient(ln.Addr().String(), 50*time.Millisecond)
+	start := time.Now()
+	if _, err := c.Call(context.Background(), "version", nil); err == nil {
+		t.Fatal("Call succeeded, want timeout")
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("Call returned after %v, want about 50ms", d)
+	}
+}
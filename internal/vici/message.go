@@ -0,0 +1,247 @@
+// Package vici is a minimal client for VICI, the Versatile IKE Configuration
+// Interface of the strongSwan IKE daemon charon. It covers what plexd needs
+// to load IKEv2 connections and pre-shared keys, and to read the state of
+// their SAs.
+package vici
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Message element types.
+const (
+	elemSectionStart = 1
+	elemSectionEnd   = 2
+	elemKeyValue     = 3
+	elemListStart    = 4
+	elemListItem     = 5
+	elemListEnd      = 6
+)
+
+// Message is a VICI message: an ordered set of keys, each holding a string,
+// a list of strings, or a nested section.
+type Message struct {
+	keys   []string
+	values map[string]any
+}
+
+// NewMessage returns an empty message.
+func NewMessage() *Message {
+	return &Message{values: make(map[string]any)}
+}
+
+func (m *Message) set(key string, v any) {
+	if m.values == nil {
+		m.values = make(map[string]any)
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = v
+}
+
+// Set sets key to the string value.
+func (m *Message) Set(key, value string) {
+	m.set(key, value)
+}
+
+// SetList sets key to a list of strings.
+func (m *Message) SetList(key string, items []string) {
+	m.set(key, append([]string(nil), items...))
+}
+
+// SetSection sets key to the section s.
+func (m *Message) SetSection(key string, s *Message) {
+	m.set(key, s)
+}
+
+// Keys returns the keys of m in the order they were set or decoded.
+func (m *Message) Keys() []string {
+	return append([]string(nil), m.keys...)
+}
+
+// Get returns the string value of key, or "" if key is missing or not a
+// string.
+func (m *Message) Get(key string) string {
+	s, _ := m.values[key].(string)
+	return s
+}
+
+// List returns the list value of key, or nil if key is missing or not a
+// list.
+func (m *Message) List(key string) []string {
+	l, _ := m.values[key].([]string)
+	return l
+}
+
+// Section returns the section value of key, or nil if key is missing or not
+// a section.
+func (m *Message) Section(key string) *Message {
+	s, _ := m.values[key].(*Message)
+	return s
+}
+
+// MarshalBinary encodes m in the VICI message format.
+func (m *Message) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := m.encode(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes data in the VICI message format into m,
+// replacing its contents.
+func (m *Message) UnmarshalBinary(data []byte) error {
+	d, err := decodeMessage(data)
+	if err != nil {
+		return err
+	}
+	*m = *d
+	return nil
+}
+
+// encode appends the elements of m to buf.
+func (m *Message) encode(buf *bytes.Buffer) error {
+	for _, k := range m.keys {
+		if len(k) > 255 {
+			return fmt.Errorf("vici: key of %d bytes is longer than 255 bytes", len(k))
+		}
+		switch v := m.values[k].(type) {
+		case string:
+			if len(v) > 65535 {
+				return fmt.Errorf("vici: value of %q is longer than 65535 bytes", k)
+			}
+			buf.WriteByte(elemKeyValue)
+			writeName(buf, k)
+			writeValue(buf, v)
+		case []string:
+			buf.WriteByte(elemListStart)
+			writeName(buf, k)
+			for _, item := range v {
+				if len(item) > 65535 {
+					return fmt.Errorf("vici: item of %q is longer than 65535 bytes", k)
+				}
+				buf.WriteByte(elemListItem)
+				writeValue(buf, item)
+			}
+			buf.WriteByte(elemListEnd)
+		case *Message:
+			buf.WriteByte(elemSectionStart)
+			writeName(buf, k)
+			if err := v.encode(buf); err != nil {
+				return err
+			}
+			buf.WriteByte(elemSectionEnd)
+		}
+	}
+	return nil
+}
+
+func writeName(buf *bytes.Buffer, name string) {
+	buf.WriteByte(byte(len(name)))
+	buf.WriteString(name)
+}
+
+func writeValue(buf *bytes.Buffer, v string) {
+	buf.Write(binary.BigEndian.AppendUint16(nil, uint16(len(v))))
+	buf.WriteString(v)
+}
+
+var errTruncated = errors.New("vici: truncated message")
+
+// decodeMessage decodes the elements in data. Sections are tracked on a
+// stack, so deeply nested input cannot exhaust the goroutine stack.
+func decodeMessage(data []byte) (*Message, error) {
+	root := NewMessage()
+	stack := []*Message{root}
+	var list []string
+	var listKey string
+	inList := false
+
+	readName := func() (string, error) {
+		if len(data) < 1 || len(data) < 1+int(data[0]) {
+			return "", errTruncated
+		}
+		n := int(data[0])
+		name := string(data[1 : 1+n])
+		data = data[1+n:]
+		return name, nil
+	}
+	readValue := func() (string, error) {
+		if len(data) < 2 {
+			return "", errTruncated
+		}
+		n := int(binary.BigEndian.Uint16(data))
+		if len(data) < 2+n {
+			return "", errTruncated
+		}
+		v := string(data[2 : 2+n])
+		data = data[2+n:]
+		return v, nil
+	}
+
+	for len(data) > 0 {
+		typ := data[0]
+		data = data[1:]
+		cur := stack[len(stack)-1]
+		if inList && typ != elemListItem && typ != elemListEnd {
+			return nil, fmt.Errorf("vici: element %d inside list %q", typ, listKey)
+		}
+		switch typ {
+		case elemSectionStart:
+			name, err := readName()
+			if err != nil {
+				return nil, err
+			}
+			s := NewMessage()
+			cur.SetSection(name, s)
+			stack = append(stack, s)
+		case elemSectionEnd:
+			if len(stack) == 1 {
+				return nil, errors.New("vici: unbalanced section end")
+			}
+			stack = stack[:len(stack)-1]
+		case elemKeyValue:
+			name, err := readName()
+			if err != nil {
+				return nil, err
+			}
+			v, err := readValue()
+			if err != nil {
+				return nil, err
+			}
+			cur.Set(name, v)
+		case elemListStart:
+			name, err := readName()
+			if err != nil {
+				return nil, err
+			}
+			listKey, list, inList = name, []string{}, true
+		case elemListItem:
+			if !inList {
+				return nil, errors.New("vici: list item outside a list")
+			}
+			v, err := readValue()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		case elemListEnd:
+			if !inList {
+				return nil, errors.New("vici: list end outside a list")
+			}
+			cur.set(listKey, list)
+			inList = false
+		default:
+			return nil, fmt.Errorf("vici: unknown element type %d", typ)
+		}
+	}
+	if inList || len(stack) != 1 {
+		return nil, errTruncated
+	}
+	return root, nil
+}
@@ -0,0 +1,108 @@
+package vici
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestMessage_RoundTrip(t *testing.T) {
+	child := NewMessage()
+	child.SetList("local_ts", []string{"10.0.0.0/24", "10.0.1.0/24"})
+	child.Set("if_id_in", "7")
+	children := NewMessage()
+	children.SetSection("site-a", child)
+	conn := NewMessage()
+	conn.Set("version", "2")
+	conn.SetList("remote_addrs", []string{"203.0.113.1"})
+	conn.SetList("empty", nil)
+	conn.SetSection("children", children)
+	m := NewMessage()
+	m.SetSection("site-a", conn)
+
+	var buf bytes.Buffer
+	if err := m.encode(&buf); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	got, err := decodeMessage(buf.Bytes())
+	if err != nil {
+		t.Fatalf("decodeMessage: %v", err)
+	}
+	c := got.Section("site-a")
+	if c == nil {
+		t.Fatal("section site-a missing")
+	}
+	if !slices.Equal(c.Keys(), []string{"version", "remote_addrs", "empty", "children"}) {
+		t.Errorf("keys = %v", c.Keys())
+	}
+	if c.Get("version") != "2" || !slices.Equal(c.List("remote_addrs"), []string{"203.0.113.1"}) {
+		t.Errorf("conn = %+v", c)
+	}
+	if l := c.List("empty"); l == nil || len(l) != 0 {
+		t.Errorf("empty list = %#v, want an empty list", l)
+	}
+	ch := c.Section("children").Section("site-a")
+	if ch.Get("if_id_in") != "7" || !slices.Equal(ch.List("local_ts"), []string{"10.0.0.0/24", "10.0.1.0/24"}) {
+		t.Errorf("child = %+v", ch)
+	}
+	// Getters of the wrong type return the zero value.
+	if c.Get("children") != "" || c.List("version") != nil || c.Section("version") != nil {
+		t.Error("getter of the wrong type returned a value")
+	}
+}
+
+func TestDecodeMessage_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated key", []byte{elemKeyValue, 5, 'a'}},
+		{"truncated value", []byte{elemKeyValue, 1, 'a', 0, 9, 'x'}},
+		{"unbalanced section end", []byte{elemSectionEnd}},
+		{"open section", []byte{elemSectionStart, 1, 's'}},
+		{"open list", []byte{elemListStart, 1, 'l', elemListItem, 0, 1, 'x'}},
+		{"item outside list", []byte{elemListItem, 0, 0}},
+		{"key in list", []byte{elemListStart, 1, 'l', elemKeyValue, 1, 'a', 0, 0}},
+		{"unknown element", []byte{9}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeMessage(tt.data); err == nil {
+				t.Error("decodeMessage succeeded, want error")
+			}
+		})
+	}
+}
+
+func FuzzDecodeMessage(f *testing.F) {
+	m := NewMessage()
+	m.Set("success", "yes")
+	m.SetList("conns", []string{"a", "b"})
+	s := NewMessage()
+	s.Set("state", "ESTABLISHED")
+	m.SetSection("sa", s)
+	var buf bytes.Buffer
+	if err := m.encode(&buf); err != nil {
+		f.Fatal(err)
+	}
+	f.Add(buf.Bytes())
+	f.Add([]byte{elemSectionStart, 0, elemSectionStart, 0, elemSectionEnd, elemSectionEnd})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		got, err := decodeMessage(data)
+		if err != nil {
+			return
+		}
+		// A decoded message encodes and decodes to the same keys.
+		var out bytes.Buffer
+		if err := got.encode(&out); err != nil {
+			t.Fatalf("encode decoded message: %v", err)
+		}
+		again, err := decodeMessage(out.Bytes())
+		if err != nil {
+			t.Fatalf("decode re-encoded message: %v", err)
+		}
+		if !slices.Equal(got.Keys(), again.Keys()) {
+			t.Fatalf("keys = %v after round trip, want %v", again.Keys(), got.Keys())
+		}
+	})
+}
@@ -0,0 +1,360 @@
+// Package vicitest provides an in-memory fake of the VICI interface of the
+// strongSwan IKE daemon charon for tests. It keeps loaded connections and
+// shared keys, establishes an SA for every initiated connection, and records
+// every command, so that tests can exercise a VICI client without charon.
+package vicitest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/vici"
+)
+
+// Packet types of the VICI protocol.
+const (
+	pktCmdRequest      = 0
+	pktCmdResponse     = 1
+	pktCmdUnknown      = 2
+	pktEventRegister   = 3
+	pktEventUnregister = 4
+	pktEventConfirm    = 5
+	pktEventUnknown    = 6
+	pktEvent           = 7
+)
+
+// Server is a fake charon listening on a Unix socket.
+type Server struct {
+	ln   net.Listener
+	path string
+
+	mu       sync.Mutex
+	commands []string
+	conns    map[string]*vici.Message
+	shared   map[string]*vici.Message
+	sas      map[string]*vici.Message // IKE SAs keyed by connection name
+	fail     map[string]string        // command → errmsg
+}
+
+// NewServer starts a Server. It is closed when the test ends.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	// Unix socket paths are limited to about 100 bytes, which the
+	// directories of t.TempDir can exceed.
+	dir, err := os.MkdirTemp("", "vici")
+	if err != nil {
+		t.Fatalf("vicitest: %v", err)
+	}
+	path := filepath.Join(dir, "charon.vici")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("vicitest: listen: %v", err)
+	}
+	s := &Server{
+		ln:     ln,
+		path:   path,
+		conns:  make(map[string]*vici.Message),
+		shared: make(map[string]*vici.Message),
+		sas:    make(map[string]*vici.Message),
+		fail:   make(map[string]string),
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.serve(&wg)
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		wg.Wait()
+		os.RemoveAll(dir)
+	})
+	return s
+}
+
+// Path returns the socket path of s.
+func (s *Server) Path() string {
+	return s.path
+}
+
+// Commands returns the commands received so far, in order.
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.commands)
+}
+
+// Conn returns the loaded connection name, or nil.
+func (s *Server) Conn(name string) *vici.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns[name]
+}
+
+// Shared returns the loaded shared key with the given ID, or nil.
+func (s *Server) Shared(id string) *vici.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shared[id]
+}
+
+// SA returns the IKE SA of connection name, or nil.
+func (s *Server) SA(name string) *vici.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sas[name]
+}
+
+// SetSA sets the IKE SA of connection name, as reported by list-sas.
+func (s *Server) SetSA(name string, sa *vici.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sas[name] = sa
+}
+
+// Fail makes every following cmd fail with errmsg. An empty errmsg clears
+// the failure.
+func (s *Server) Fail(cmd, errmsg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if errmsg == "" {
+		delete(s.fail, cmd)
+		return
+	}
+	s.fail[cmd] = errmsg
+}
+
+func (s *Server) serve(wg *sync.WaitGroup) {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+// handle serves the packets of one client connection.
+func (s *Server) handle(conn net.Conn) {
+	events := map[string]bool{}
+	for {
+		typ, name, msg, err := readPacket(conn)
+		if err != nil {
+			return
+		}
+		switch typ {
+		case pktEventRegister:
+			if name != "list-sa" {
+				_ = writePacket(conn, pktEventUnknown, "", nil)
+				continue
+			}
+			events[name] = true
+			_ = writePacket(conn, pktEventConfirm, "", nil)
+		case pktCmdRequest:
+			evs, resp, ok := s.command(name, msg)
+			if !ok {
+				_ = writePacket(conn, pktCmdUnknown, "", nil)
+				continue
+			}
+			for _, ev := range evs {
+				if events["list-sa"] {
+					_ = writePacket(conn, pktEvent, "list-sa", ev)
+				}
+			}
+			_ = writePacket(conn, pktCmdResponse, "", resp)
+		default:
+			return
+		}
+	}
+}
+
+func reply(errmsg string) *vici.Message {
+	m := vici.NewMessage()
+	if errmsg != "" {
+		m.Set("success", "no")
+		m.Set("errmsg", errmsg)
+		return m
+	}
+	m.Set("success", "yes")
+	return m
+}
+
+// command executes cmd and returns the list-sa events and the response,
+// or false for an unknown command.
+func (s *Server) command(cmd string, req *vici.Message) ([]*vici.Message, *vici.Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands = append(s.commands, cmd)
+	if errmsg, ok := s.fail[cmd]; ok {
+		return nil, reply(errmsg), true
+	}
+
+	switch cmd {
+	case "load-conn":
+		for _, name := range req.Keys() {
+			if c := req.Section(name); c != nil {
+				s.conns[name] = c
+			}
+		}
+		return nil, reply(""), true
+	case "unload-conn":
+		name := req.Get("name")
+		if _, ok := s.conns[name]; !ok {
+			return nil, reply(fmt.Sprintf("unload: connection '%s' not found", name)), true
+		}
+		delete(s.conns, name)
+		return nil, reply(""), true
+	case "get-conns":
+		m := vici.NewMessage()
+		m.SetList("conns", sortedKeys(s.conns))
+		return nil, m, true
+	case "load-shared":
+		s.shared[req.Get("id")] = req
+		return nil, reply(""), true
+	case "unload-shared":
+		id := req.Get("id")
+		if _, ok := s.shared[id]; !ok {
+			return nil, reply(fmt.Sprintf("credential '%s' not found", id)), true
+		}
+		delete(s.shared, id)
+		return nil, reply(""), true
+	case "get-shared":
+		m := vici.NewMessage()
+		m.SetList("keys", sortedKeys(s.shared))
+		return nil, m, true
+	case "initiate":
+		name := req.Get("ike")
+		c, ok := s.conns[name]
+		if !ok {
+			return nil, reply(fmt.Sprintf("IKE_SA config '%s' not found", name)), true
+		}
+		s.sas[name] = establishedSA(c)
+		return nil, reply(""), true
+	case "terminate":
+		name := req.Get("ike")
+		if _, ok := s.sas[name]; !ok {
+			m := reply("no matching SAs to terminate found")
+			m.Set("matches", "0")
+			return nil, m, true
+		}
+		delete(s.sas, name)
+		m := reply("")
+		m.Set("matches", "1")
+		return nil, m, true
+	case "list-sas":
+		var evs []*vici.Message
+		for _, name := range sortedKeys(s.sas) {
+			if ike := req.Get("ike"); ike != "" && ike != name {
+				continue
+			}
+			ev := vici.NewMessage()
+			ev.SetSection(name, s.sas[name])
+			evs = append(evs, ev)
+		}
+		return evs, vici.NewMessage(), true
+	}
+	return nil, nil, false
+}
+
+// establishedSA returns an established IKE SA of conn with a CHILD_SA for
+// each of its children.
+func establishedSA(conn *vici.Message) *vici.Message {
+	sa := vici.NewMessage()
+	sa.Set("uniqueid", "1")
+	sa.Set("state", "ESTABLISHED")
+	sa.Set("established", "0")
+	if addrs := conn.List("remote_addrs"); len(addrs) > 0 {
+		sa.Set("remote-host", addrs[0])
+	}
+	port := conn.Get("remote_port")
+	if port == "" {
+		port = "500"
+	}
+	sa.Set("remote-port", port)
+	children := vici.NewMessage()
+	if cs := conn.Section("children"); cs != nil {
+		for i, child := range cs.Keys() {
+			c := vici.NewMessage()
+			c.Set("name", child)
+			c.Set("state", "INSTALLED")
+			c.Set("bytes-in", "0")
+			c.Set("bytes-out", "0")
+			children.SetSection(child+"-"+strconv.Itoa(i+1), c)
+		}
+	}
+	sa.SetSection("child-sas", children)
+	return sa
+}
+
+func sortedKeys(m map[string]*vici.Message) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// named reports whether packets of type typ carry a name.
+func named(typ byte) bool {
+	return typ == pktCmdRequest || typ == pktEventRegister || typ == pktEventUnregister || typ == pktEvent
+}
+
+func writePacket(w io.Writer, typ byte, name string, msg *vici.Message) error {
+	body := []byte{typ}
+	if named(typ) {
+		body = append(body, byte(len(name)))
+		body = append(body, name...)
+	}
+	if msg != nil {
+		b, err := msg.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		body = append(body, b...)
+	}
+	_, err := w.Write(append(binary.BigEndian.AppendUint32(nil, uint32(len(body))), body...))
+	return err
+}
+
+func readPacket(r io.Reader) (byte, string, *vici.Message, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, "", nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(hdr[:]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, "", nil, err
+	}
+	if len(data) == 0 {
+		return 0, "", nil, errors.New("vicitest: empty packet")
+	}
+	typ, data := data[0], data[1:]
+	var name string
+	if named(typ) {
+		if len(data) < 1 || len(data) < 1+int(data[0]) {
+			return 0, "", nil, errors.New("vicitest: truncated name")
+		}
+		name, data = string(data[1:1+int(data[0])]), data[1+int(data[0]):]
+	}
+	msg := vici.NewMessage()
+	if err := msg.UnmarshalBinary(data); err != nil {
+		return 0, "", nil, err
+	}
+	return typ, name, msg, nil
+}
@@ -0,0 +1,146 @@
+package wireguard
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/plexsphere/plexd/internal/auditfwd"
+)
+
+// MutationRecorder records host changes for the audit trail. Satisfied by
+// *auditfwd.Recorder.
+type MutationRecorder interface {
+	Record(ctx context.Context, m auditfwd.Mutation)
+}
+
+// auditedController is a WGController that records every change it passes
+// on to the wrapped controller.
+type auditedController struct {
+	ctrl WGController
+	rec  MutationRecorder
+}
+
+// NewAuditedController returns a WGController that passes every operation
+// to ctrl and records each change with rec. The result always implements
+// EndpointSetter, PeerBatcher, and PeerLister: ApplyPeers falls back to
+// single peer operations, and the others fail with errors.ErrUnsupported,
+// when ctrl lacks the capability.
+func NewAuditedController(ctrl WGController, rec MutationRecorder) WGController {
+	return &auditedController{ctrl: ctrl, rec: rec}
+}
+
+var (
+	_ EndpointSetter = (*auditedController)(nil)
+	_ PeerBatcher    = (*auditedController)(nil)
+	_ PeerLister     = (*auditedController)(nil)
+)
+
+func (a *auditedController) record(action, target string, object map[string]any, err error) error {
+	a.rec.Record(context.Background(), auditfwd.Mutation{Action: action, Target: target, Object: object, Err: err})
+	return err
+}
+
+func (a *auditedController) CreateInterface(name string, privateKey []byte, listenPort int) error {
+	err := a.ctrl.CreateInterface(name, privateKey, listenPort)
+	return a.record("interface_create", name, map[string]any{"interface": name, "listen_port": listenPort}, err)
+}
+
+func (a *auditedController) DeleteInterface(name string) error {
+	err := a.ctrl.DeleteInterface(name)
+	return a.record("interface_delete", name, map[string]any{"interface": name}, err)
+}
+
+func (a *auditedController) ConfigureAddress(name string, address string) error {
+	err := a.ctrl.ConfigureAddress(name, address)
+	return a.record("address_add", address+" dev "+name, map[string]any{"interface": name, "address": address}, err)
+}
+
+func (a *auditedController) SetInterfaceUp(name string) error {
+	err := a.ctrl.SetInterfaceUp(name)
+	return a.record("interface_up", name, map[string]any{"interface": name}, err)
+}
+
+func (a *auditedController) SetMTU(name string, mtu int) error {
+	err := a.ctrl.SetMTU(name, mtu)
+	return a.record("interface_mtu", name+" mtu "+strconv.Itoa(mtu), map[string]any{"interface": name, "mtu": mtu}, err)
+}
+
+func (a *auditedController) AddPeer(iface string, cfg PeerConfig) error {
+	err := a.ctrl.AddPeer(iface, cfg)
+	return a.record("peer_add", peerTarget(iface, cfg.PublicKey), peerObject(iface, cfg), err)
+}
+
+func (a *auditedController) RemovePeer(iface string, publicKey []byte) error {
+	err := a.ctrl.RemovePeer(iface, publicKey)
+	return a.record("peer_remove", peerTarget(iface, publicKey), map[string]any{
+		"interface":  iface,
+		"public_key": base64.StdEncoding.EncodeToString(publicKey),
+	}, err)
+}
+
+func (a *auditedController) SetPeerEndpoint(iface string, publicKey []byte, endpoint string) error {
+	setter, ok := a.ctrl.(EndpointSetter)
+	if !ok {
+		return fmt.Errorf("wireguard: set peer endpoint: %w", errors.ErrUnsupported)
+	}
+	err := setter.SetPeerEndpoint(iface, publicKey, endpoint)
+	return a.record("peer_endpoint", peerTarget(iface, publicKey), map[string]any{
+		"interface":  iface,
+		"public_key": base64.StdEncoding.EncodeToString(publicKey),
+		"endpoint":   endpoint,
+	}, err)
+}
+
+// ApplyPeers records one change per peer; a failed batch is recorded as
+// failed for every peer in it, since it may have applied some of them.
+func (a *auditedController) ApplyPeers(iface string, remove [][]byte, upsert []PeerConfig) error {
+	batcher, ok := a.ctrl.(PeerBatcher)
+	if !ok {
+		var errs []error
+		for _, key := range remove {
+			errs = append(errs, a.RemovePeer(iface, key))
+		}
+		for _, cfg := range upsert {
+			errs = append(errs, a.AddPeer(iface, cfg))
+		}
+		return errors.Join(errs...)
+	}
+	err := batcher.ApplyPeers(iface, remove, upsert)
+	for _, key := range remove {
+		a.record("peer_remove", peerTarget(iface, key), map[string]any{
+			"interface":  iface,
+			"public_key": base64.StdEncoding.EncodeToString(key),
+		}, err)
+	}
+	for _, cfg := range upsert {
+		a.record("peer_add", peerTarget(iface, cfg.PublicKey), peerObject(iface, cfg), err)
+	}
+	return err
+}
+
+// ListPeers only reads, so nothing is recorded.
+func (a *auditedController) ListPeers(iface string) ([]PeerConfig, error) {
+	lister, ok := a.ctrl.(PeerLister)
+	if !ok {
+		return nil, fmt.Errorf("wireguard: list peers: %w", errors.ErrUnsupported)
+	}
+	return lister.ListPeers(iface)
+}
+
+func peerTarget(iface string, publicKey []byte) string {
+	return "peer " + base64.StdEncoding.EncodeToString(publicKey) + " on " + iface
+}
+
+// peerObject describes a peer configuration without its preshared key.
+func peerObject(iface string, cfg PeerConfig) map[string]any {
+	return map[string]any{
+		"interface":   iface,
+		"public_key":  base64.StdEncoding.EncodeToString(cfg.PublicKey),
+		"endpoint":    cfg.Endpoint,
+		"allowed_ips": cfg.AllowedIPs,
+		"psk":         cfg.PSK != nil,
+	}
+}
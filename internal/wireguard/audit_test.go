@@ -0,0 +1,119 @@
+package wireguard
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/auditfwd"
+)
+
+// mockRecorder collects recorded mutations.
+type mockRecorder struct {
+	mu        sync.Mutex
+	mutations []auditfwd.Mutation
+}
+
+func (r *mockRecorder) Record(_ context.Context, m auditfwd.Mutation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mutations = append(r.mutations, m)
+}
+
+func (r *mockRecorder) actions() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]string, len(r.mutations))
+	for i, m := range r.mutations {
+		out[i] = m.Action
+	}
+	return out
+}
+
+func TestAuditedController_RecordsChanges(t *testing.T) {
+	mock := &mockController{setMTUErr: errors.New("mtu too large")}
+	rec := &mockRecorder{}
+	ctrl := NewAuditedController(mock, rec)
+
+	if err := ctrl.CreateInterface("plexd0", []byte("key"), 51820); err != nil {
+		t.Fatalf("CreateInterface: %v", err)
+	}
+	if err := ctrl.SetMTU("plexd0", 9000); err == nil {
+		t.Fatal("SetMTU: expected error")
+	}
+	psk := []byte("preshared")
+	if err := ctrl.AddPeer("plexd0", PeerConfig{PublicKey: []byte("peer"), PSK: psk}); err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+
+	want := []string{"interface_create", "interface_mtu", "peer_add"}
+	got := rec.actions()
+	if len(got) != len(want) {
+		t.Fatalf("actions = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("actions[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+	if rec.mutations[1].Err == nil {
+		t.Error("failed SetMTU recorded without error")
+	}
+	if v, ok := rec.mutations[2].Object["psk"].(bool); !ok || !v {
+		t.Errorf("peer_add psk = %v, want true", rec.mutations[2].Object["psk"])
+	}
+	if len(mock.callsFor("CreateInterface")) != 1 || len(mock.callsFor("AddPeer")) != 1 {
+		t.Error("calls not passed to the wrapped controller")
+	}
+}
+
+func TestAuditedController_ApplyPeersFallback(t *testing.T) {
+	mock := &mockController{}
+	rec := &mockRecorder{}
+	ctrl := NewAuditedController(mock, rec)
+
+	err := ctrl.(PeerBatcher).ApplyPeers("plexd0", [][]byte{[]byte("old")}, []PeerConfig{{PublicKey: []byte("new")}})
+	if err != nil {
+		t.Fatalf("ApplyPeers: %v", err)
+	}
+	if len(mock.callsFor("RemovePeer")) != 1 || len(mock.callsFor("AddPeer")) != 1 {
+		t.Errorf("calls = %+v, want one RemovePeer and one AddPeer", mock.calls)
+	}
+	if got := rec.actions(); len(got) != 2 || got[0] != "peer_remove" || got[1] != "peer_add" {
+		t.Errorf("actions = %v", got)
+	}
+}
+
+func TestAuditedController_ApplyPeersBatch(t *testing.T) {
+	mock := &mockBatchController{applyPeersErr: errors.New("netlink: busy")}
+	rec := &mockRecorder{}
+	ctrl := NewAuditedController(mock, rec)
+
+	err := ctrl.(PeerBatcher).ApplyPeers("plexd0", [][]byte{[]byte("old")}, []PeerConfig{{PublicKey: []byte("a")}, {PublicKey: []byte("b")}})
+	if err == nil {
+		t.Fatal("ApplyPeers: expected error")
+	}
+	if len(mock.callsFor("ApplyPeers")) != 1 {
+		t.Errorf("ApplyPeers calls = %d, want 1", len(mock.callsFor("ApplyPeers")))
+	}
+	if len(rec.mutations) != 3 {
+		t.Fatalf("recorded %d mutations, want 3", len(rec.mutations))
+	}
+	for _, m := range rec.mutations {
+		if m.Err == nil {
+			t.Errorf("%s recorded without the batch error", m.Action)
+		}
+	}
+}
+
+func TestAuditedController_Unsupported(t *testing.T) {
+	ctrl := NewAuditedController(&mockController{}, &mockRecorder{})
+
+	if err := ctrl.(EndpointSetter).SetPeerEndpoint("plexd0", []byte("peer"), "1.2.3.4:51820"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("SetPeerEndpoint error = %v, want ErrUnsupported", err)
+	}
+	if _, err := ctrl.(PeerLister).ListPeers("plexd0"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("ListPeers error = %v, want ErrUnsupported", err)
+	}
+}
@@ -15,6 +15,11 @@ type Config struct {
 
 	// MTU is the interface MTU. 0 means system default.
 	MTU int
+
+	// PeerBatchSize is the maximum number of peer changes applied in one
+	// device configuration when the controller supports batching.
+	// Default: 256
+	PeerBatchSize int
 }
 
 // DefaultInterfaceName is the default WireGuard interface name.
@@ -23,6 +28,10 @@ const DefaultInterfaceName = "plexd0"
 // DefaultListenPort is the default WireGuard UDP listen port.
 const DefaultListenPort = 51820
 
+// DefaultPeerBatchSize is the default number of peer changes per device
+// configuration.
+const DefaultPeerBatchSize = 256
+
 // ApplyDefaults sets default values for zero-valued fields.
 func (c *Config) ApplyDefaults() {
 	if c.InterfaceName == "" {
@@ -31,6 +40,9 @@ func (c *Config) ApplyDefaults() {
 	if c.ListenPort == 0 {
 		c.ListenPort = DefaultListenPort
 	}
+	if c.PeerBatchSize == 0 {
+		c.PeerBatchSize = DefaultPeerBatchSize
+	}
 }
 
 // Validate checks that configuration values are within acceptable ranges.
@@ -41,5 +53,8 @@ func (c *Config) Validate() error {
 	if c.MTU < 0 {
 		return errors.New("wireguard: config: MTU must not be negative")
 	}
+	if c.PeerBatchSize < 0 {
+		return errors.New("wireguard: config: PeerBatchSize must not be negative")
+	}
 	return nil
 }
@@ -68,3 +68,16 @@ func TestConfig_ValidateAcceptsCustomValues(t *testing.T) {
 		t.Errorf("Validate() = %v, want nil", err)
 	}
 }
+
+func TestConfig_PeerBatchSize(t *testing.T) {
+	cfg := Config{}
+	cfg.ApplyDefaults()
+	if cfg.PeerBatchSize != DefaultPeerBatchSize {
+		t.Errorf("PeerBatchSize = %d, want %d", cfg.PeerBatchSize, DefaultPeerBatchSize)
+	}
+
+	cfg = Config{ListenPort: 51820, PeerBatchSize: -1}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() = nil, want error for negative PeerBatchSize")
+	}
+}
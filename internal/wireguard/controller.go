@@ -20,6 +20,32 @@ type WGController interface {
 	RemovePeer(iface string, publicKey []byte) error
 }
 
+// EndpointSetter is an optional WGController capability: it changes only the
+// endpoint of an existing peer, leaving its allowed IPs and keys as they are.
+type EndpointSetter interface {
+	SetPeerEndpoint(iface string, publicKey []byte, endpoint string) error
+}
+
+// PeerBatcher is an optional WGController capability: it applies many peer
+// changes to an interface in one device configuration instead of one
+// configuration per peer.
+type PeerBatcher interface {
+	// ApplyPeers removes the peers with the public keys in remove, then
+	// adds or updates the peers in upsert. A failed call may have applied
+	// some of the changes.
+	ApplyPeers(iface string, remove [][]byte, upsert []PeerConfig) error
+}
+
+// PeerLister is an optional WGController capability: it reads back the peers
+// configured on an interface, so that changes made outside plexd, such as a
+// manual `wg set`, can be detected.
+type PeerLister interface {
+	// ListPeers returns the peers of the named interface. Endpoint,
+	// AllowedIPs (in canonical CIDR form) and PSK (nil if unset) reflect
+	// the kernel's current configuration.
+	ListPeers(iface string) ([]PeerConfig, error)
+}
+
 // PeerConfig holds the WireGuard-native configuration for a single peer.
 type PeerConfig struct {
 	PublicKey           []byte
@@ -0,0 +1,371 @@
+//go:build darwin
+
+package wireguard
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.zx2c4.com/wireguard/conn"
+	"golang.zx2c4.com/wireguard/device"
+	"golang.zx2c4.com/wireguard/ipc"
+	"golang.zx2c4.com/wireguard/tun"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// utunSocketDir is the directory wireguard-go creates its UAPI sockets in.
+// wgctrl and wg(8) discover userspace devices there.
+const utunSocketDir = "/var/run/wireguard"
+
+// utunDevice is a running userspace WireGuard device bound to a utun interface.
+type utunDevice struct {
+	utun string
+	dev  *device.Device
+	uapi net.Listener
+}
+
+// UtunController implements WGController on macOS. macOS has no in-kernel
+// WireGuard, so each interface runs the wireguard-go userspace
+// implementation on a kernel-assigned utunN interface. The logical name
+// (e.g. plexd0) is the UAPI socket name, which lets wgctrl and wg(8) address
+// the device by it; addresses and MTU are applied to the utun interface with
+// ifconfig and route.
+type UtunController struct {
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	devices map[string]*utunDevice
+}
+
+// NewUtunController returns a new UtunController.
+func NewUtunController(logger *slog.Logger) *UtunController {
+	return &UtunController{logger: logger, devices: make(map[string]*utunDevice)}
+}
+
+// NewDefaultController returns the platform WGController: a UtunController
+// on macOS.
+func NewDefaultController(logger *slog.Logger) (WGController, error) {
+	return NewUtunController(logger), nil
+}
+
+// CreateInterface creates a utun interface, starts a userspace WireGuard
+// device on it, and configures the private key and listen port.
+func (c *UtunController) CreateInterface(name string, privateKey []byte, listenPort int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.devices[name]; ok {
+		return fmt.Errorf("wireguard: create interface: %s already exists", name)
+	}
+
+	tdev, err := tun.CreateTUN("utun", device.DefaultMTU)
+	if err != nil {
+		return fmt.Errorf("wireguard: create interface: create utun: %w", err)
+	}
+	utunName, err := tdev.Name()
+	if err != nil {
+		tdev.Close()
+		return fmt.Errorf("wireguard: create interface: utun name: %w", err)
+	}
+
+	d := &utunDevice{
+		utun: utunName,
+		dev:  device.NewDevice(tdev, conn.NewDefaultBind(), c.deviceLogger(name)),
+	}
+
+	if err := c.listenUAPI(name, d); err != nil {
+		d.dev.Close()
+		return fmt.Errorf("wireguard: create interface: %w", err)
+	}
+
+	key, err := wgtypes.NewKey(privateKey)
+	if err != nil {
+		c.closeDevice(name, d)
+		return fmt.Errorf("wireguard: create interface: parse private key: %w", err)
+	}
+	if err := configureDevice(name, wgtypes.Config{PrivateKey: &key, ListenPort: &listenPort}); err != nil {
+		c.closeDevice(name, d)
+		return fmt.Errorf("wireguard: create interface: %w", err)
+	}
+
+	c.devices[name] = d
+
+	c.logger.Info("wireguard interface created",
+		"component", "wireguard",
+		"interface", name,
+		"utun", utunName,
+		"listen_port", listenPort,
+	)
+
+	return nil
+}
+
+// DeleteInterface stops the userspace device, which destroys its utun
+// interface. It is idempotent: deleting a non-existent interface returns nil.
+func (c *UtunController) DeleteInterface(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d, ok := c.devices[name]
+	if !ok {
+		return nil
+	}
+	delete(c.devices, name)
+	c.closeDevice(name, d)
+
+	c.logger.Info("wireguard interface deleted",
+		"component", "wireguard",
+		"interface", name,
+		"utun", d.utun,
+	)
+
+	return nil
+}
+
+// ConfigureAddress adds a CIDR address to the utun interface. utun is a
+// point-to-point interface, so the route for the address's subnet is added
+// explicitly.
+func (c *UtunController) ConfigureAddress(name string, address string) error {
+	utunName, err := c.utunName(name)
+	if err != nil {
+		return fmt.Errorf("wireguard: configure address: %w", err)
+	}
+
+	ip, ipNet, err := net.ParseCIDR(address)
+	if err != nil {
+		return fmt.Errorf("wireguard: configure address: parse %q: %w", address, err)
+	}
+
+	if ip.To4() != nil {
+		err = c.run("ifconfig", utunName, "inet", address, ip.String(), "alias")
+	} else {
+		err = c.run("ifconfig", utunName, "inet6", address, "alias")
+	}
+	if err != nil {
+		return fmt.Errorf("wireguard: configure address: %w", err)
+	}
+
+	if ones, bits := ipNet.Mask.Size(); ones < bits {
+		family := "-inet"
+		if ip.To4() == nil {
+			family = "-inet6"
+		}
+		if err := c.run("route", "-q", "-n", "add", family, ipNet.String(), "-interface", utunName); err != nil {
+			return fmt.Errorf("wireguard: configure address: add route: %w", err)
+		}
+	}
+
+	c.logger.Debug("address configured",
+		"component", "wireguard",
+		"interface", name,
+		"utun", utunName,
+		"address", address,
+	)
+
+	return nil
+}
+
+// SetInterfaceUp brings the utun interface up.
+func (c *UtunController) SetInterfaceUp(name string) error {
+	utunName, err := c.utunName(name)
+	if err != nil {
+		return fmt.Errorf("wireguard: set interface up: %w", err)
+	}
+	if err := c.run("ifconfig", utunName, "up"); err != nil {
+		return fmt.Errorf("wireguard: set interface up: %w", err)
+	}
+
+	c.logger.Debug("interface up",
+		"component", "wireguard",
+		"interface", name,
+		"utun", utunName,
+	)
+
+	return nil
+}
+
+// SetMTU sets the MTU on the utun interface.
+func (c *UtunController) SetMTU(name string, mtu int) error {
+	utunName, err := c.utunName(name)
+	if err != nil {
+		return fmt.Errorf("wireguard: set mtu: %w", err)
+	}
+	if err := c.run("ifconfig", utunName, "mtu", fmt.Sprint(mtu)); err != nil {
+		return fmt.Errorf("wireguard: set mtu: %w", err)
+	}
+
+	c.logger.Debug("mtu configured",
+		"component", "wireguard",
+		"interface", name,
+		"utun", utunName,
+		"mtu", mtu,
+	)
+
+	return nil
+}
+
+// AddPeer adds or updates a peer on the named WireGuard interface.
+func (c *UtunController) AddPeer(iface string, cfg PeerConfig) error {
+	peerCfg, err := toWGPeerConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("wireguard: add peer: %w", err)
+	}
+
+	if err := configureDevice(iface, wgtypes.Config{Peers: []wgtypes.PeerConfig{peerCfg}}); err != nil {
+		return fmt.Errorf("wireguard: add peer: %w", err)
+	}
+
+	c.logger.Debug("peer added",
+		"component", "wireguard",
+		"interface", iface,
+	)
+
+	return nil
+}
+
+// ListPeers returns the peers configured on the named WireGuard interface.
+func (c *UtunController) ListPeers(iface string) ([]PeerConfig, error) {
+	peers, err := listPeers(iface)
+	if err != nil {
+		return nil, fmt.Errorf("wireguard: list peers: %w", err)
+	}
+	return peers, nil
+}
+
+// SetPeerEndpoint changes the endpoint of an existing peer on the named
+// WireGuard interface.
+func (c *UtunController) SetPeerEndpoint(iface string, publicKey []byte, endpoint string) error {
+	if err := setPeerEndpoint(iface, publicKey, endpoint); err != nil {
+		return fmt.Errorf("wireguard: set peer endpoint: %w", err)
+	}
+	return nil
+}
+
+// ApplyPeers removes, adds, and updates many peers on the named WireGuard
+// interface in one device configuration.
+func (c *UtunController) ApplyPeers(iface string, remove [][]byte, upsert []PeerConfig) error {
+	if err := applyPeers(iface, remove, upsert); err != nil {
+		return fmt.Errorf("wireguard: apply peers: %w", err)
+	}
+
+	c.logger.Debug("peers applied",
+		"component", "wireguard",
+		"interface", iface,
+		"removed", len(remove),
+		"upserted", len(upsert),
+	)
+
+	return nil
+}
+
+// RemovePeer removes a peer from the named WireGuard interface by public key.
+func (c *UtunController) RemovePeer(iface string, publicKey []byte) error {
+	pubKey, err := wgtypes.NewKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("wireguard: remove peer: parse public key: %w", err)
+	}
+
+	err = configureDevice(iface, wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{{PublicKey: pubKey, Remove: true}},
+	})
+	if err != nil {
+		return fmt.Errorf("wireguard: remove peer: %w", err)
+	}
+
+	c.logger.Debug("peer removed",
+		"component", "wireguard",
+		"interface", iface,
+	)
+
+	return nil
+}
+
+// listenUAPI opens the UAPI socket for name and serves configuration
+// requests on it. It also writes the <name>.name file that wg(8) uses to map
+// the logical name to the utun interface.
+func (c *UtunController) listenUAPI(name string, d *utunDevice) error {
+	file, err := ipc.UAPIOpen(name)
+	if err != nil {
+		return fmt.Errorf("open uapi socket: %w", err)
+	}
+	uapi, err := ipc.UAPIListen(name, file)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("listen on uapi socket: %w", err)
+	}
+	d.uapi = uapi
+
+	if err := os.WriteFile(filepath.Join(utunSocketDir, name+".name"), []byte(d.utun+"\n"), 0o644); err != nil {
+		c.logger.Warn("failed to write interface name file",
+			"component", "wireguard",
+			"interface", name,
+			"error", err,
+		)
+	}
+
+	go func() {
+		for {
+			conn, err := uapi.Accept()
+			if err != nil {
+				return
+			}
+			go d.dev.IpcHandle(conn)
+		}
+	}()
+
+	return nil
+}
+
+// closeDevice stops the device and removes its UAPI socket and name file.
+func (c *UtunController) closeDevice(name string, d *utunDevice) {
+	if d.uapi != nil {
+		d.uapi.Close()
+	}
+	d.dev.Close()
+	for _, suffix := range []string{".sock", ".name"} {
+		if err := os.Remove(filepath.Join(utunSocketDir, name+suffix)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			c.logger.Warn("failed to remove uapi file",
+				"component", "wireguard",
+				"interface", name,
+				"error", err,
+			)
+		}
+	}
+}
+
+// deviceLogger forwards wireguard-go log output to the controller's logger.
+func (c *UtunController) deviceLogger(name string) *device.Logger {
+	return &device.Logger{
+		Verbosef: func(format string, args ...any) {
+			c.logger.Debug(fmt.Sprintf(format, args...), "component", "wireguard", "interface", name)
+		},
+		Errorf: func(format string, args ...any) {
+			c.logger.Error(fmt.Sprintf(format, args...), "component", "wireguard", "interface", name)
+		},
+	}
+}
+
+func (c *UtunController) utunName(name string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d, ok := c.devices[name]
+	if !ok {
+		return "", fmt.Errorf("interface %s not found", name)
+	}
+	return d.utun, nil
+}
+
+func (c *UtunController) run(name string, args ...string) error {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %s: %w", name, strings.Join(args, " "), strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
@@ -3,24 +3,37 @@
 package wireguard
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
-	"net"
-	"time"
+	"sync"
 
 	"github.com/vishvananda/netlink"
 	"golang.zx2c4.com/wireguard/wgctrl"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"golang.org/x/sys/unix"
 )
 
 // NetlinkController implements WGController using Linux netlink and wgctrl.
+// Where the kernel has no WireGuard support, as in many containers, it
+// falls back to the wireguard-go userspace implementation on a TUN
+// interface (see userspace_linux.go).
 type NetlinkController struct {
 	logger *slog.Logger
+
+	mu        sync.Mutex
+	userspace map[string]*userspaceDevice
 }
 
 // NewNetlinkController returns a new NetlinkController.
 func NewNetlinkController(logger *slog.Logger) *NetlinkController {
-	return &NetlinkController{logger: logger}
+	return &NetlinkController{logger: logger, userspace: make(map[string]*userspaceDevice)}
+}
+
+// NewDefaultController returns the platform WGController: a
+// NetlinkController on Linux.
+func NewDefaultController(logger *slog.Logger) (WGController, error) {
+	return NewNetlinkController(logger), nil
 }
 
 // CreateInterface creates a WireGuard interface with the given name,
@@ -30,7 +43,15 @@ func (c *NetlinkController) CreateInterface(name string, privateKey []byte, list
 	la.Name = name
 	link := &netlink.GenericLink{LinkAttrs: la, LinkType: "wireguard"}
 
-	if err := netlink.LinkAdd(link); err != nil {
+	err := netlink.LinkAdd(link)
+	if errors.Is(err, unix.EOPNOTSUPP) {
+		c.logger.Warn("kernel has no wireguard support, using userspace implementation",
+			"component", "wireguard",
+			"interface", name,
+		)
+		err = c.createUserspace(name)
+	}
+	if err != nil {
 		return fmt.Errorf("wireguard: create interface: %w", err)
 	}
 
@@ -70,6 +91,14 @@ func (c *NetlinkController) CreateInterface(name string, privateKey []byte, list
 // DeleteInterface deletes the named WireGuard interface.
 // It is idempotent: deleting a non-existent interface returns nil.
 func (c *NetlinkController) DeleteInterface(name string) error {
+	if c.deleteUserspace(name) {
+		c.logger.Info("wireguard interface deleted",
+			"component", "wireguard",
+			"interface", name,
+		)
+		return nil
+	}
+
 	link, err := netlink.LinkByName(name)
 	if err != nil {
 		// Interface does not exist — idempotent success.
@@ -165,55 +194,56 @@ func (c *NetlinkController) AddPeer(iface string, cfg PeerConfig) error {
 	}
 	defer client.Close()
 
-	pubKey, err := wgtypes.NewKey(cfg.PublicKey)
+	peerCfg, err := toWGPeerConfig(cfg)
 	if err != nil {
-		return fmt.Errorf("wireguard: add peer: parse public key: %w", err)
+		return fmt.Errorf("wireguard: add peer: %w", err)
 	}
 
-	peerCfg := wgtypes.PeerConfig{
-		PublicKey:  pubKey,
-		ReplaceAllowedIPs: true,
+	err = client.ConfigureDevice(iface, wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{peerCfg},
+	})
+	if err != nil {
+		return fmt.Errorf("wireguard: add peer: configure device: %w", err)
 	}
 
-	if cfg.Endpoint != "" {
-		udpAddr, err := net.ResolveUDPAddr("udp", cfg.Endpoint)
-		if err != nil {
-			return fmt.Errorf("wireguard: add peer: resolve endpoint: %w", err)
-		}
-		peerCfg.Endpoint = udpAddr
-	}
+	c.logger.Debug("peer added",
+		"component", "wireguard",
+		"interface", iface,
+	)
 
-	for _, cidr := range cfg.AllowedIPs {
-		_, ipNet, err := net.ParseCIDR(cidr)
-		if err != nil {
-			return fmt.Errorf("wireguard: add peer: parse allowed IP %q: %w", cidr, err)
-		}
-		peerCfg.AllowedIPs = append(peerCfg.AllowedIPs, *ipNet)
-	}
+	return nil
+}
 
-	if len(cfg.PSK) > 0 {
-		psk, err := wgtypes.NewKey(cfg.PSK)
-		if err != nil {
-			return fmt.Errorf("wireguard: add peer: parse psk: %w", err)
-		}
-		peerCfg.PresharedKey = &psk
+// ListPeers returns the peers configured on the named WireGuard interface.
+func (c *NetlinkController) ListPeers(iface string) ([]PeerConfig, error) {
+	peers, err := listPeers(iface)
+	if err != nil {
+		return nil, fmt.Errorf("wireguard: list peers: %w", err)
 	}
+	return peers, nil
+}
 
-	if cfg.PersistentKeepalive > 0 {
-		keepalive := time.Duration(cfg.PersistentKeepalive) * time.Second
-		peerCfg.PersistentKeepaliveInterval = &keepalive
+// SetPeerEndpoint changes the endpoint of an existing peer on the named
+// WireGuard interface.
+func (c *NetlinkController) SetPeerEndpoint(iface string, publicKey []byte, endpoint string) error {
+	if err := setPeerEndpoint(iface, publicKey, endpoint); err != nil {
+		return fmt.Errorf("wireguard: set peer endpoint: %w", err)
 	}
+	return nil
+}
 
-	err = client.ConfigureDevice(iface, wgtypes.Config{
-		Peers: []wgtypes.PeerConfig{peerCfg},
-	})
-	if err != nil {
-		return fmt.Errorf("wireguard: add peer: configure device: %w", err)
+// ApplyPeers removes, adds, and updates many peers on the named WireGuard
+// interface in one device configuration.
+func (c *NetlinkController) ApplyPeers(iface string, remove [][]byte, upsert []PeerConfig) error {
+	if err := applyPeers(iface, remove, upsert); err != nil {
+		return fmt.Errorf("wireguard: apply peers: %w", err)
 	}
 
-	c.logger.Debug("peer added",
+	c.logger.Debug("peers applied",
 		"component", "wireguard",
 		"interface", iface,
+		"removed", len(remove),
+		"upserted", len(upsert),
 	)
 
 	return nil
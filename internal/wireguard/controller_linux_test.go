@@ -18,6 +18,9 @@ func discardLoggerLinux() *slog.Logger {
 // Compile-time check that NetlinkController implements WGController.
 var _ WGController = (*NetlinkController)(nil)
 
+// Compile-time check that NetlinkController implements PeerLister.
+var _ PeerLister = (*NetlinkController)(nil)
+
 func TestNewNetlinkController(t *testing.T) {
 	ctrl := NewNetlinkController(discardLoggerLinux())
 	if ctrl == nil {
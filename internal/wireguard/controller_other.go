@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !windows
+
+package wireguard
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+)
+
+// NewDefaultController returns an error: there is no WGController for this
+// platform.
+func NewDefaultController(_ *slog.Logger) (WGController, error) {
+	return nil, fmt.Errorf("wireguard: no controller available on %s", runtime.GOOS)
+}
@@ -0,0 +1,283 @@
+//go:build windows
+
+package wireguard
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc/mgr"
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// DefaultWireGuardExe is the default path of the WireGuard for Windows executable.
+const DefaultWireGuardExe = `C:\Program Files\WireGuard\wireguard.exe`
+
+// DefaultTunnelConfigDir is the default directory for tunnel configuration files.
+const DefaultTunnelConfigDir = `C:\ProgramData\plexd\wireguard`
+
+// tunnelStartTimeout bounds how long CreateInterface waits for the tunnel
+// service to bring up its adapter.
+const tunnelStartTimeout = 15 * time.Second
+
+// TunnelServiceController implements WGController on Windows by installing
+// each interface as a WireGuard for Windows tunnel service. Addresses and
+// MTU are applied with netsh; peers are configured through wgctrl, which
+// talks to the WireGuardNT driver.
+type TunnelServiceController struct {
+	wireguardExe string
+	configDir    string
+	logger       *slog.Logger
+}
+
+// NewTunnelServiceController returns a TunnelServiceController. Empty
+// arguments select DefaultWireGuardExe and DefaultTunnelConfigDir.
+func NewTunnelServiceController(wireguardExe, configDir string, logger *slog.Logger) *TunnelServiceController {
+	if wireguardExe == "" {
+		wireguardExe = DefaultWireGuardExe
+	}
+	if configDir == "" {
+		configDir = DefaultTunnelConfigDir
+	}
+	return &TunnelServiceController{wireguardExe: wireguardExe, configDir: configDir, logger: logger}
+}
+
+// NewDefaultController returns the platform WGController: a
+// TunnelServiceController with default paths on Windows.
+func NewDefaultController(logger *slog.Logger) (WGController, error) {
+	return NewTunnelServiceController("", "", logger), nil
+}
+
+// CreateInterface writes the tunnel configuration, installs it as a tunnel
+// service, and waits until the WireGuard device is available.
+func (c *TunnelServiceController) CreateInterface(name string, privateKey []byte, listenPort int) error {
+	if err := os.MkdirAll(c.configDir, 0o700); err != nil {
+		return fmt.Errorf("wireguard: create interface: create config dir: %w", err)
+	}
+	confPath := c.configPath(name)
+	if err := os.WriteFile(confPath, []byte(GenerateTunnelConfig(privateKey, listenPort)), 0o600); err != nil {
+		return fmt.Errorf("wireguard: create interface: write config: %w", err)
+	}
+
+	if err := c.run(c.wireguardExe, "/installtunnelservice", confPath); err != nil {
+		return fmt.Errorf("wireguard: create interface: %w", err)
+	}
+
+	if err := c.waitForDevice(name); err != nil {
+		return fmt.Errorf("wireguard: create interface: %w", err)
+	}
+
+	c.logger.Info("wireguard interface created",
+		"component", "wireguard",
+		"interface", name,
+		"listen_port", listenPort,
+		"service", TunnelServiceName(name),
+	)
+
+	return nil
+}
+
+// DeleteInterface uninstalls the tunnel service and removes its configuration.
+// It is idempotent: deleting a non-existent tunnel returns nil.
+func (c *TunnelServiceController) DeleteInterface(name string) error {
+	exists, err := tunnelServiceExists(name)
+	if err != nil {
+		return fmt.Errorf("wireguard: delete interface: %w", err)
+	}
+	if exists {
+		if err := c.run(c.wireguardExe, "/uninstalltunnelservice", name); err != nil {
+			return fmt.Errorf("wireguard: delete interface: %w", err)
+		}
+	}
+	if err := os.Remove(c.configPath(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("wireguard: delete interface: remove config: %w", err)
+	}
+
+	c.logger.Info("wireguard interface deleted",
+		"component", "wireguard",
+		"interface", name,
+	)
+
+	return nil
+}
+
+// ConfigureAddress adds a CIDR address to the named interface.
+func (c *TunnelServiceController) ConfigureAddress(name string, address string) error {
+	ip, _, err := net.ParseCIDR(address)
+	if err != nil {
+		return fmt.Errorf("wireguard: configure address: parse %q: %w", address, err)
+	}
+	family := "ipv4"
+	if ip.To4() == nil {
+		family = "ipv6"
+	}
+
+	if err := c.run("netsh", "interface", family, "add", "address", "name="+name, "address="+address, "store=active"); err != nil {
+		return fmt.Errorf("wireguard: configure address: %w", err)
+	}
+
+	c.logger.Debug("address configured",
+		"component", "wireguard",
+		"interface", name,
+		"address", address,
+	)
+
+	return nil
+}
+
+// SetInterfaceUp is a no-op: the tunnel service brings the adapter up.
+func (c *TunnelServiceController) SetInterfaceUp(_ string) error {
+	return nil
+}
+
+// SetMTU sets the IPv4 and IPv6 MTU on the named interface.
+func (c *TunnelServiceController) SetMTU(name string, mtu int) error {
+	for _, family := range []string{"ipv4", "ipv6"} {
+		if err := c.run("netsh", "interface", family, "set", "subinterface", name, fmt.Sprintf("mtu=%d", mtu), "store=active"); err != nil {
+			return fmt.Errorf("wireguard: set mtu: %w", err)
+		}
+	}
+
+	c.logger.Debug("mtu configured",
+		"component", "wireguard",
+		"interface", name,
+		"mtu", mtu,
+	)
+
+	return nil
+}
+
+// AddPeer adds or updates a peer on the named WireGuard interface.
+func (c *TunnelServiceController) AddPeer(iface string, cfg PeerConfig) error {
+	peerCfg, err := toWGPeerConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("wireguard: add peer: %w", err)
+	}
+
+	if err := configureDevice(iface, wgtypes.Config{Peers: []wgtypes.PeerConfig{peerCfg}}); err != nil {
+		return fmt.Errorf("wireguard: add peer: %w", err)
+	}
+
+	c.logger.Debug("peer added",
+		"component", "wireguard",
+		"interface", iface,
+	)
+
+	return nil
+}
+
+// ListPeers returns the peers configured on the named WireGuard interface.
+func (c *TunnelServiceController) ListPeers(iface string) ([]PeerConfig, error) {
+	peers, err := listPeers(iface)
+	if err != nil {
+		return nil, fmt.Errorf("wireguard: list peers: %w", err)
+	}
+	return peers, nil
+}
+
+// SetPeerEndpoint changes the endpoint of an existing peer on the named
+// WireGuard interface.
+func (c *TunnelServiceController) SetPeerEndpoint(iface string, publicKey []byte, endpoint string) error {
+	if err := setPeerEndpoint(iface, publicKey, endpoint); err != nil {
+		return fmt.Errorf("wireguard: set peer endpoint: %w", err)
+	}
+	return nil
+}
+
+// ApplyPeers removes, adds, and updates many peers on the named WireGuard
+// interface in one device configuration.
+func (c *TunnelServiceController) ApplyPeers(iface string, remove [][]byte, upsert []PeerConfig) error {
+	if err := applyPeers(iface, remove, upsert); err != nil {
+		return fmt.Errorf("wireguard: apply peers: %w", err)
+	}
+
+	c.logger.Debug("peers applied",
+		"component", "wireguard",
+		"interface", iface,
+		"removed", len(remove),
+		"upserted", len(upsert),
+	)
+
+	return nil
+}
+
+// RemovePeer removes a peer from the named WireGuard interface by public key.
+func (c *TunnelServiceController) RemovePeer(iface string, publicKey []byte) error {
+	pubKey, err := wgtypes.NewKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("wireguard: remove peer: parse public key: %w", err)
+	}
+
+	err = configureDevice(iface, wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{{PublicKey: pubKey, Remove: true}},
+	})
+	if err != nil {
+		return fmt.Errorf("wireguard: remove peer: %w", err)
+	}
+
+	c.logger.Debug("peer removed",
+		"component", "wireguard",
+		"interface", iface,
+	)
+
+	return nil
+}
+
+func (c *TunnelServiceController) configPath(name string) string {
+	return filepath.Join(c.configDir, name+".conf")
+}
+
+// waitForDevice polls wgctrl until the tunnel's device is visible.
+func (c *TunnelServiceController) waitForDevice(name string) error {
+	deadline := time.Now().Add(tunnelStartTimeout)
+	for {
+		client, err := wgctrl.New()
+		if err == nil {
+			_, err = client.Device(name)
+			client.Close()
+			if err == nil {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("tunnel %s not ready after %s: %w", name, tunnelStartTimeout, err)
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+func (c *TunnelServiceController) run(name string, args ...string) error {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %s: %w", filepath.Base(name), args[0], strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// tunnelServiceExists reports whether the tunnel service for name is installed.
+func tunnelServiceExists(name string) (bool, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return false, fmt.Errorf("connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(TunnelServiceName(name))
+	if err != nil {
+		if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+			return false, nil
+		}
+		return false, fmt.Errorf("open service: %w", err)
+	}
+	s.Close()
+	return true, nil
+}
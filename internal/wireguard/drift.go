@@ -0,0 +1,126 @@
+package wireguard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// Drift correction types reported by CheckDrift.
+const (
+	// DriftPeerRestored is a desired peer that was missing from the interface.
+	DriftPeerRestored = "wg_peer_restored"
+	// DriftPeerRepaired is a peer whose allowed IPs or PSK had been changed.
+	DriftPeerRepaired = "wg_peer_repaired"
+	// DriftPeerRemoved is a peer on the interface that is not desired.
+	DriftPeerRemoved = "wg_peer_removed"
+)
+
+// CheckDrift compares the peers configured on the interface with peers and
+// repairs the differences: missing peers are added, peers whose allowed IPs
+// or PSK differ are reconfigured, and peers that are not desired are
+// removed. Endpoints are not compared, because NAT traversal and path
+// selection change them at runtime; a reconfigured peer keeps its current
+// endpoint. It returns one correction per repair. The controller must
+// implement PeerLister.
+func (m *Manager) CheckDrift(peers []api.Peer) ([]api.DriftCorrection, error) {
+	lister, ok := m.ctrl.(PeerLister)
+	if !ok {
+		return nil, errors.New("wireguard: check drift: not supported by controller")
+	}
+	actualPeers, err := lister.ListPeers(m.cfg.InterfaceName)
+	if err != nil {
+		return nil, fmt.Errorf("wireguard: check drift: %w", err)
+	}
+	actual := make(map[string]PeerConfig, len(actualPeers))
+	for _, p := range actualPeers {
+		actual[base64.StdEncoding.EncodeToString(p.PublicKey)] = p
+	}
+
+	var corrections []api.DriftCorrection
+	var errs []error
+	desired := make(map[string]struct{}, len(peers))
+	for _, peer := range peers {
+		want, err := m.peerConfigFromAPI(peer)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("wireguard: check drift: peer %s: %w", peer.ID, err))
+			continue
+		}
+		key := base64.StdEncoding.EncodeToString(want.PublicKey)
+		desired[key] = struct{}{}
+
+		typ := DriftPeerRestored
+		if have, ok := actual[key]; ok {
+			if peerConfigMatches(want, have) {
+				continue
+			}
+			typ = DriftPeerRepaired
+			if have.Endpoint != "" {
+				want.Endpoint = have.Endpoint
+			}
+		}
+		if err := m.ctrl.AddPeer(m.cfg.InterfaceName, want); err != nil {
+			errs = append(errs, fmt.Errorf("wireguard: check drift: peer %s: %w", peer.ID, err))
+			continue
+		}
+		m.logger.Warn("external peer change repaired",
+			"component", "wireguard",
+			"peer_id", peer.ID,
+			"correction", typ,
+		)
+		corrections = append(corrections, api.DriftCorrection{
+			Type:   typ,
+			Detail: fmt.Sprintf("peer %s", peer.ID),
+		})
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(actual)) {
+		if _, ok := desired[key]; ok {
+			continue
+		}
+		have := actual[key]
+		if err := m.ctrl.RemovePeer(m.cfg.InterfaceName, have.PublicKey); err != nil {
+			errs = append(errs, fmt.Errorf("wireguard: check drift: remove unknown peer %s: %w", key, err))
+			continue
+		}
+		m.logger.Warn("unknown peer removed",
+			"component", "wireguard",
+			"public_key", key,
+		)
+		corrections = append(corrections, api.DriftCorrection{
+			Type:   DriftPeerRemoved,
+			Detail: fmt.Sprintf("public key %s", key),
+		})
+	}
+
+	return corrections, errors.Join(errs...)
+}
+
+// peerConfigMatches reports whether have has the allowed IPs and PSK of
+// want. Allowed IPs are compared as sets in canonical CIDR form.
+func peerConfigMatches(want, have PeerConfig) bool {
+	if !bytes.Equal(want.PSK, have.PSK) {
+		return false
+	}
+	return slices.Equal(canonicalCIDRs(want.AllowedIPs), canonicalCIDRs(have.AllowedIPs))
+}
+
+// canonicalCIDRs returns cidrs in canonical form, sorted and deduplicated.
+// Entries that do not parse are kept as they are.
+func canonicalCIDRs(cidrs []string) []string {
+	out := make([]string, 0, len(cidrs))
+	for _, c := range cidrs {
+		if _, ipNet, err := net.ParseCIDR(c); err == nil {
+			c = ipNet.String()
+		}
+		out = append(out, c)
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
@@ -0,0 +1,138 @@
+package wireguard
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// listingController is a mockController with the PeerLister capability.
+type listingController struct {
+	mockController
+	peers   []PeerConfig
+	listErr error
+}
+
+func (m *listingController) ListPeers(iface string) ([]PeerConfig, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, mockCall{Method: "ListPeers", Args: []interface{}{iface}})
+	return m.peers, m.listErr
+}
+
+func testKeyPeer(id string, b byte, allowedIPs ...string) (api.Peer, PeerConfig) {
+	key := make([]byte, 32)
+	key[0] = b
+	peer := api.Peer{
+		ID:         id,
+		PublicKey:  base64.StdEncoding.EncodeToString(key),
+		Endpoint:   "1.2.3.4:51820",
+		AllowedIPs: allowedIPs,
+	}
+	return peer, PeerConfig{PublicKey: key, Endpoint: "5.6.7.8:51820", AllowedIPs: allowedIPs}
+}
+
+func TestManager_CheckDrift_InSync(t *testing.T) {
+	peer, actual := testKeyPeer("peer-1", 1, "10.0.0.2/32")
+	ctrl := &listingController{peers: []PeerConfig{actual}}
+	mgr := NewManager(ctrl, Config{}, discardLogger())
+
+	corrections, err := mgr.CheckDrift([]api.Peer{peer})
+	if err != nil {
+		t.Fatalf("CheckDrift() returned error: %v", err)
+	}
+	if len(corrections) != 0 {
+		t.Errorf("corrections = %+v, want none", corrections)
+	}
+	if n := len(ctrl.callsFor("AddPeer")) + len(ctrl.callsFor("RemovePeer")); n != 0 {
+		t.Errorf("got %d AddPeer/RemovePeer calls, want 0", n)
+	}
+}
+
+func TestManager_CheckDrift_Repairs(t *testing.T) {
+	missing, _ := testKeyPeer("peer-missing", 1, "10.0.0.2/32")
+	changed, changedActual := testKeyPeer("peer-changed", 2, "10.0.0.3/32", "192.168.1.0/24")
+	changedActual.AllowedIPs = []string{"10.0.0.3/32"}
+	_, unknown := testKeyPeer("", 3, "10.0.0.9/32")
+
+	ctrl := &listingController{peers: []PeerConfig{changedActual, unknown}}
+	mgr := NewManager(ctrl, Config{}, discardLogger())
+
+	corrections, err := mgr.CheckDrift([]api.Peer{missing, changed})
+	if err != nil {
+		t.Fatalf("CheckDrift() returned error: %v", err)
+	}
+	want := []string{DriftPeerRestored, DriftPeerRepaired, DriftPeerRemoved}
+	if len(corrections) != len(want) {
+		t.Fatalf("corrections = %+v, want types %v", corrections, want)
+	}
+	for i, c := range corrections {
+		if c.Type != want[i] {
+			t.Errorf("corrections[%d].Type = %q, want %q", i, c.Type, want[i])
+		}
+	}
+
+	adds := ctrl.callsFor("AddPeer")
+	if len(adds) != 2 {
+		t.Fatalf("expected 2 AddPeer calls, got %d", len(adds))
+	}
+	if cfg := adds[0].Args[1].(PeerConfig); cfg.Endpoint != "1.2.3.4:51820" {
+		t.Errorf("restored peer endpoint = %q, want the desired endpoint", cfg.Endpoint)
+	}
+	if cfg := adds[1].Args[1].(PeerConfig); cfg.Endpoint != "5.6.7.8:51820" || len(cfg.AllowedIPs) != 2 {
+		t.Errorf("repaired peer config = %+v, want current endpoint and desired allowed IPs", cfg)
+	}
+	if removes := ctrl.callsFor("RemovePeer"); len(removes) != 1 {
+		t.Errorf("expected 1 RemovePeer call, got %d", len(removes))
+	}
+}
+
+func TestManager_CheckDrift_Unsupported(t *testing.T) {
+	mgr := NewManager(&mockController{}, Config{}, discardLogger())
+
+	if _, err := mgr.CheckDrift(nil); err == nil {
+		t.Error("CheckDrift() = nil error, want not supported")
+	}
+}
+
+func TestManager_CheckDrift_ListError(t *testing.T) {
+	ctrl := &listingController{listErr: errors.New("no such device")}
+	mgr := NewManager(ctrl, Config{}, discardLogger())
+
+	if _, err := mgr.CheckDrift(nil); err == nil {
+		t.Error("CheckDrift() = nil error, want list error")
+	}
+	if n := len(ctrl.callsFor("RemovePeer")); n != 0 {
+		t.Errorf("got %d RemovePeer calls after a list error, want 0", n)
+	}
+}
+
+func TestCanonicalCIDRs(t *testing.T) {
+	got := canonicalCIDRs([]string{"10.0.0.5/24", "10.0.0.0/24", "fd00::1/128"})
+	want := []string{"10.0.0.0/24", "fd00::1/128"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("canonicalCIDRs() = %v, want %v", got, want)
+	}
+}
+
+func TestManager_PeerEndpoints(t *testing.T) {
+	_, withEndpoint := testKeyPeer("peer-1", 1, "10.0.0.2/32")
+	_, withoutEndpoint := testKeyPeer("peer-2", 2, "10.0.0.3/32")
+	withoutEndpoint.Endpoint = ""
+	ctrl := &listingController{peers: []PeerConfig{withEndpoint, withoutEndpoint}}
+	mgr := NewManager(ctrl, Config{}, discardLogger())
+
+	endpoints, err := mgr.PeerEndpoints()
+	if err != nil {
+		t.Fatalf("PeerEndpoints() returned error: %v", err)
+	}
+	if len(endpoints) != 1 || endpoints[0] != "5.6.7.8:51820" {
+		t.Errorf("endpoints = %v, want [5.6.7.8:51820]", endpoints)
+	}
+
+	if _, err := NewManager(&mockController{}, Config{}, discardLogger()).PeerEndpoints(); err == nil {
+		t.Error("PeerEndpoints() = nil error, want not supported")
+	}
+}
@@ -3,7 +3,6 @@ package wireguard
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 
 	"github.com/plexsphere/plexd/internal/api"
@@ -11,50 +10,13 @@ import (
 )
 
 // ReconcileHandler returns a reconcile.ReconcileHandler that applies peer
-// changes from the StateDiff to the WireGuard interface via the Manager.
+// changes from the StateDiff to the WireGuard interface via
+// Manager.ApplyPeers, batched when the controller supports it.
 // Order: removes first, then updates, then adds.
 // Individual failures are logged and collected; an aggregated error is returned.
 func ReconcileHandler(mgr *Manager) reconcile.ReconcileHandler {
 	return func(ctx context.Context, desired *api.StateResponse, diff reconcile.StateDiff) error {
-		var errs []error
-
-		// 1. Remove peers
-		for _, peerID := range diff.PeersToRemove {
-			if err := mgr.RemovePeerByID(peerID); err != nil {
-				mgr.logger.Error("reconcile: remove peer failed",
-					"component", "wireguard",
-					"peer_id", peerID,
-					"error", err,
-				)
-				errs = append(errs, err)
-			}
-		}
-
-		// 2. Update peers
-		for _, peer := range diff.PeersToUpdate {
-			if err := mgr.UpdatePeer(peer); err != nil {
-				mgr.logger.Error("reconcile: update peer failed",
-					"component", "wireguard",
-					"peer_id", peer.ID,
-					"error", err,
-				)
-				errs = append(errs, err)
-			}
-		}
-
-		// 3. Add peers
-		for _, peer := range diff.PeersToAdd {
-			if err := mgr.AddPeer(peer); err != nil {
-				mgr.logger.Error("reconcile: add peer failed",
-					"component", "wireguard",
-					"peer_id", peer.ID,
-					"error", err,
-				)
-				errs = append(errs, err)
-			}
-		}
-
-		return errors.Join(errs...)
+		return mgr.ApplyPeers(ctx, diff.PeersToRemove, diff.PeersToUpdate, diff.PeersToAdd)
 	}
 }
 
@@ -150,3 +112,12 @@ func HandlePeerEndpointChanged(mgr *Manager) api.EventHandler {
 		return nil
 	}
 }
+
+// DriftChecker returns a reconcile.DriftChecker that repairs WireGuard peers
+// changed outside plexd, comparing the interface with the applied peers via
+// Manager.CheckDrift.
+func DriftChecker(mgr *Manager) reconcile.DriftChecker {
+	return func(_ context.Context, desired *api.StateResponse) ([]api.DriftCorrection, error) {
+		return mgr.CheckDrift(desired.Peers)
+	}
+}
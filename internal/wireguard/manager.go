@@ -3,6 +3,7 @@ package wireguard
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"log/slog"
 
@@ -12,10 +13,11 @@ import (
 
 // Manager manages the WireGuard interface and peer configuration.
 type Manager struct {
-	ctrl   WGController
-	cfg    Config
-	logger *slog.Logger
-	peers  *PeerIndex
+	ctrl      WGController
+	cfg       Config
+	logger    *slog.Logger
+	peers     *PeerIndex
+	keepalive int // persistent keepalive of peers, in seconds; 0 disables
 }
 
 // NewManager creates a new Manager. Config defaults are applied automatically.
@@ -69,7 +71,7 @@ func (m *Manager) Teardown() error {
 
 // AddPeer adds a peer to the WireGuard interface and updates the peer index.
 func (m *Manager) AddPeer(peer api.Peer) error {
-	peerCfg, err := PeerConfigFromAPI(peer)
+	peerCfg, err := m.peerConfigFromAPI(peer)
 	if err != nil {
 		return fmt.Errorf("wireguard: add peer: %w", err)
 	}
@@ -124,7 +126,7 @@ func (m *Manager) RemovePeerByID(peerID string) error {
 
 // UpdatePeer updates a peer configuration. WireGuard AddPeer is idempotent (upsert).
 func (m *Manager) UpdatePeer(peer api.Peer) error {
-	peerCfg, err := PeerConfigFromAPI(peer)
+	peerCfg, err := m.peerConfigFromAPI(peer)
 	if err != nil {
 		return fmt.Errorf("wireguard: update peer: %w", err)
 	}
@@ -143,44 +145,275 @@ func (m *Manager) UpdatePeer(peer api.Peer) error {
 	return nil
 }
 
-// ConfigurePeers bulk-configures all peers. Individual errors are logged but not returned.
+// SetPeerEndpoint points the peer with the given ID at endpoint without
+// touching its allowed IPs or keys. The controller must implement
+// EndpointSetter.
+func (m *Manager) SetPeerEndpoint(peerID, endpoint string) error {
+	setter, ok := m.ctrl.(EndpointSetter)
+	if !ok {
+		return errors.New("wireguard: set peer endpoint: not supported by controller")
+	}
+	pubKeyB64, ok := m.peers.Lookup(peerID)
+	if !ok {
+		return fmt.Errorf("wireguard: unknown peer ID: %s", peerID)
+	}
+	pubKeyBytes, err := base64.StdEncoding.DecodeString(pubKeyB64)
+	if err != nil {
+		return fmt.Errorf("wireguard: decode public key: %w", err)
+	}
+	if err := setter.SetPeerEndpoint(m.cfg.InterfaceName, pubKeyBytes, endpoint); err != nil {
+		return err
+	}
+
+	m.logger.Debug("peer endpoint set",
+		"component", "wireguard",
+		"peer_id", peerID,
+		"endpoint", endpoint,
+	)
+
+	return nil
+}
+
+// ConfigurePeers bulk-configures all peers. Peers are applied in batches
+// when the controller implements PeerBatcher (see ApplyPeers). Individual
+// errors are logged but not returned.
 func (m *Manager) ConfigurePeers(ctx context.Context, peers []api.Peer) error {
 	m.peers.LoadFromPeers(peers)
 
-	for _, peer := range peers {
-		if err := ctx.Err(); err != nil {
-			return fmt.Errorf("wireguard: configure peers: %w", err)
+	changes := make([]peerChange, len(peers))
+	for i, peer := range peers {
+		changes[i] = peerChange{op: opAdd, peer: peer}
+	}
+	// Failures are logged per peer by applyChanges.
+	_ = m.applyChanges(ctx, changes)
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("wireguard: configure peers: %w", err)
+	}
+
+	m.logger.Info("peers configured",
+		"component", "wireguard",
+		"count", len(peers),
+	)
+
+	return nil
+}
+
+// ApplyPeers removes, updates, and adds peers, in that order. When the
+// controller implements PeerBatcher, the changes are applied in batches of
+// Config.PeerBatchSize per device configuration, and a batch that fails is
+// retried peer by peer, so that one bad peer does not fail its whole batch.
+// Otherwise each peer is applied on its own. Failures are logged and
+// returned joined; the peer index reflects every change that was applied.
+func (m *Manager) ApplyPeers(ctx context.Context, remove []string, update, add []api.Peer) error {
+	changes := make([]peerChange, 0, len(remove)+len(update)+len(add))
+	for _, id := range remove {
+		changes = append(changes, peerChange{op: opRemove, peer: api.Peer{ID: id}})
+	}
+	for _, peer := range update {
+		changes = append(changes, peerChange{op: opUpdate, peer: peer})
+	}
+	for _, peer := range add {
+		changes = append(changes, peerChange{op: opAdd, peer: peer})
+	}
+	return m.applyChanges(ctx, changes)
+}
+
+// Operations of a peerChange.
+const (
+	opRemove = "remove"
+	opUpdate = "update"
+	opAdd    = "add"
+)
+
+// peerChange is one peer change applied by applyChanges. For removals only
+// peer.ID is set.
+type peerChange struct {
+	op   string
+	peer api.Peer
+}
+
+// applyChanges applies changes in order, in batches if the controller
+// supports it, and stops between batches or peers when ctx is done.
+func (m *Manager) applyChanges(ctx context.Context, changes []peerChange) error {
+	var errs []error
+	fail := func(c peerChange, err error) {
+		m.logger.Error(c.op+" peer failed",
+			"component", "wireguard",
+			"peer_id", c.peer.ID,
+			"error", err,
+		)
+		errs = append(errs, err)
+	}
+	canceled := func(err error) error {
+		return errors.Join(append(errs, fmt.Errorf("wireguard: apply peers: %w", err))...)
+	}
+
+	batcher, ok := m.ctrl.(PeerBatcher)
+	if !ok {
+		for _, c := range changes {
+			if err := ctx.Err(); err != nil {
+				return canceled(err)
+			}
+			if err := m.applyChange(c); err != nil {
+				fail(c, err)
+			}
 		}
+		return errors.Join(errs...)
+	}
 
-		peerCfg, err := PeerConfigFromAPI(peer)
-		if err != nil {
-			m.logger.Error("failed to convert peer config",
-				"component", "wireguard",
-				"peer_id", peer.ID,
-				"error", err,
-			)
+	for start := 0; start < len(changes); start += m.cfg.PeerBatchSize {
+		if err := ctx.Err(); err != nil {
+			return canceled(err)
+		}
+		batch := changes[start:min(start+m.cfg.PeerBatchSize, len(changes))]
+
+		var remove [][]byte
+		var upsert []PeerConfig
+		valid := make([]peerChange, 0, len(batch))
+		for _, c := range batch {
+			peerCfg, err := m.peerConfig(c)
+			if err != nil {
+				fail(c, err)
+				continue
+			}
+			if c.op == opRemove {
+				remove = append(remove, peerCfg.PublicKey)
+			} else {
+				upsert = append(upsert, peerCfg)
+			}
+			valid = append(valid, c)
+		}
+		if len(valid) == 0 {
 			continue
 		}
 
-		if err := m.ctrl.AddPeer(m.cfg.InterfaceName, peerCfg); err != nil {
-			m.logger.Error("failed to add peer",
+		if err := batcher.ApplyPeers(m.cfg.InterfaceName, remove, upsert); err != nil {
+			m.logger.Warn("peer batch failed; applying peers one by one",
 				"component", "wireguard",
-				"peer_id", peer.ID,
+				"peers", len(valid),
 				"error", err,
 			)
+			for _, c := range valid {
+				if err := m.applyChange(c); err != nil {
+					fail(c, err)
+				}
+			}
 			continue
 		}
+		for _, c := range valid {
+			m.indexChange(c)
+		}
 	}
 
-	m.logger.Info("peers configured",
+	m.logger.Debug("peer changes applied",
 		"component", "wireguard",
-		"count", len(peers),
+		"changes", len(changes),
+		"failed", len(errs),
 	)
 
-	return nil
+	return errors.Join(errs...)
+}
+
+// peerConfig returns the PeerConfig of c for a batch. For removals only
+// PublicKey is set, looked up in the peer index.
+func (m *Manager) peerConfig(c peerChange) (PeerConfig, error) {
+	if c.op != opRemove {
+		peerCfg, err := m.peerConfigFromAPI(c.peer)
+		if err != nil {
+			return PeerConfig{}, fmt.Errorf("wireguard: %s peer: %w", c.op, err)
+		}
+		return peerCfg, nil
+	}
+	pubKeyB64, ok := m.peers.Lookup(c.peer.ID)
+	if !ok {
+		return PeerConfig{}, fmt.Errorf("wireguard: unknown peer ID: %s", c.peer.ID)
+	}
+	pubKeyBytes, err := base64.StdEncoding.DecodeString(pubKeyB64)
+	if err != nil {
+		return PeerConfig{}, fmt.Errorf("wireguard: decode public key: %w", err)
+	}
+	return PeerConfig{PublicKey: pubKeyBytes}, nil
+}
+
+// applyChange applies c on its own.
+func (m *Manager) applyChange(c peerChange) error {
+	switch c.op {
+	case opRemove:
+		return m.RemovePeerByID(c.peer.ID)
+	case opUpdate:
+		return m.UpdatePeer(c.peer)
+	default:
+		return m.AddPeer(c.peer)
+	}
+}
+
+// peerConfigFromAPI translates peer with PeerConfigFromAPI and sets the
+// persistent keepalive.
+func (m *Manager) peerConfigFromAPI(peer api.Peer) (PeerConfig, error) {
+	peerCfg, err := PeerConfigFromAPI(peer)
+	if err != nil {
+		return PeerConfig{}, err
+	}
+	peerCfg.PersistentKeepalive = m.keepalive
+	return peerCfg, nil
+}
+
+// indexChange records c, applied as part of a batch, in the peer index.
+func (m *Manager) indexChange(c peerChange) {
+	switch c.op {
+	case opRemove:
+		m.peers.Remove(c.peer.ID)
+	case opUpdate:
+		m.peers.Update(c.peer.ID, c.peer.PublicKey)
+	default:
+		m.peers.Add(c.peer.ID, c.peer.PublicKey)
+	}
 }
 
 // PeerIndex returns the peer index.
 func (m *Manager) PeerIndex() *PeerIndex {
 	return m.peers
 }
+
+// InterfaceName returns the name of the managed WireGuard interface.
+func (m *Manager) InterfaceName() string {
+	return m.cfg.InterfaceName
+}
+
+// PeerEndpoints returns the endpoints currently configured for the peers of
+// the interface, skipping peers without one. The controller must implement
+// PeerLister.
+func (m *Manager) PeerEndpoints() ([]string, error) {
+	lister, ok := m.ctrl.(PeerLister)
+	if !ok {
+		return nil, errors.New("wireguard: peer endpoints: not supported by controller")
+	}
+	peers, err := lister.ListPeers(m.cfg.InterfaceName)
+	if err != nil {
+		return nil, fmt.Errorf("wireguard: peer endpoints: %w", err)
+	}
+	var endpoints []string
+	for _, p := range peers {
+		if p.Endpoint != "" {
+			endpoints = append(endpoints, p.Endpoint)
+		}
+	}
+	return endpoints, nil
+}
+
+// SetInterfaceMTU sets the MTU of the interface. pmtu.Discoverer uses it to
+// size the interface while Config.MTU is unset.
+func (m *Manager) SetInterfaceMTU(mtu int) error {
+	if err := m.ctrl.SetMTU(m.cfg.InterfaceName, mtu); err != nil {
+		return fmt.Errorf("wireguard: set MTU: %w", err)
+	}
+	return nil
+}
+
+// SetPersistentKeepalive sets the persistent keepalive interval, in seconds,
+// of the peers added or updated afterwards; 0 disables keepalives. Keepalives
+// make idle peers handshake periodically, which peerhealth.Monitor relies on.
+// It must be called before peers are added.
+func (m *Manager) SetPersistentKeepalive(seconds int) {
+	m.keepalive = seconds
+}
@@ -297,6 +297,60 @@ func TestManager_RemovePeerByID_UnknownID(t *testing.T) {
 	}
 }
 
+// endpointController is a mockController with the EndpointSetter capability.
+type endpointController struct {
+	mockController
+}
+
+func (m *endpointController) SetPeerEndpoint(iface string, publicKey []byte, endpoint string) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockCall{Method: "SetPeerEndpoint", Args: []interface{}{iface, publicKey, endpoint}})
+	m.mu.Unlock()
+	return nil
+}
+
+func TestManager_SetPeerEndpoint(t *testing.T) {
+	ctrl := &endpointController{}
+	mgr := NewManager(ctrl, Config{}, discardLogger())
+
+	peer := testPeer("peer-1")
+	if err := mgr.AddPeer(peer); err != nil {
+		t.Fatalf("AddPeer() returned error: %v", err)
+	}
+	if err := mgr.SetPeerEndpoint("peer-1", "192.168.1.5:51820"); err != nil {
+		t.Fatalf("SetPeerEndpoint() returned error: %v", err)
+	}
+
+	calls := ctrl.callsFor("SetPeerEndpoint")
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 SetPeerEndpoint call, got %d", len(calls))
+	}
+	if calls[0].Args[0] != DefaultInterfaceName || calls[0].Args[2] != "192.168.1.5:51820" {
+		t.Errorf("unexpected SetPeerEndpoint args: %v", calls[0].Args)
+	}
+	// The peer must not be re-added, which would replace its allowed IPs.
+	if ap := ctrl.callsFor("AddPeer"); len(ap) != 1 {
+		t.Errorf("expected only the initial AddPeer call, got %d", len(ap))
+	}
+
+	if err := mgr.SetPeerEndpoint("nonexistent", "192.168.1.5:51820"); err == nil || !strings.Contains(err.Error(), "unknown peer ID") {
+		t.Errorf("SetPeerEndpoint(unknown) = %v, want unknown peer ID error", err)
+	}
+}
+
+func TestManager_SetPeerEndpoint_Unsupported(t *testing.T) {
+	ctrl := &mockController{}
+	mgr := NewManager(ctrl, Config{}, discardLogger())
+	if err := mgr.AddPeer(testPeer("peer-1")); err != nil {
+		t.Fatalf("AddPeer() returned error: %v", err)
+	}
+
+	err := mgr.SetPeerEndpoint("peer-1", "192.168.1.5:51820")
+	if err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("SetPeerEndpoint() = %v, want not supported error", err)
+	}
+}
+
 func TestManager_UpdatePeer(t *testing.T) {
 	ctrl := &mockController{}
 	mgr := NewManager(ctrl, Config{}, discardLogger())
@@ -374,3 +428,137 @@ func TestManager_ConfigurePeers_ContextCancellation(t *testing.T) {
 		t.Errorf("error %q does not contain 'context canceled'", err.Error())
 	}
 }
+
+func TestManager_ApplyPeers_Batched(t *testing.T) {
+	ctrl := &mockBatchController{}
+	mgr := NewManager(ctrl, Config{PeerBatchSize: 2}, discardLogger())
+	mgr.PeerIndex().Add("peer-del", testPeer("peer-del").PublicKey)
+
+	err := mgr.ApplyPeers(context.Background(),
+		[]string{"peer-del"},
+		[]api.Peer{testPeer("peer-upd")},
+		[]api.Peer{testPeer("peer-add-1"), testPeer("peer-add-2")},
+	)
+	if err != nil {
+		t.Fatalf("ApplyPeers() = %v", err)
+	}
+
+	// Batches keep the order removes, updates, adds.
+	batches := ctrl.callsFor("ApplyPeers")
+	if len(batches) != 2 {
+		t.Fatalf("expected 2 ApplyPeers calls, got %d", len(batches))
+	}
+	if got := batches[0].Args; got[1] != 1 || got[2] != 1 {
+		t.Errorf("batch 1 = %v, want 1 removal and 1 upsert", got)
+	}
+	if got := batches[1].Args; got[1] != 0 || got[2] != 2 {
+		t.Errorf("batch 2 = %v, want 2 upserts", got)
+	}
+	if n := len(ctrl.callsFor("AddPeer")) + len(ctrl.callsFor("RemovePeer")); n != 0 {
+		t.Errorf("expected no per-peer calls, got %d", n)
+	}
+
+	if _, ok := mgr.PeerIndex().Lookup("peer-del"); ok {
+		t.Error("removed peer still in index")
+	}
+	for _, id := range []string{"peer-upd", "peer-add-1", "peer-add-2"} {
+		if _, ok := mgr.PeerIndex().Lookup(id); !ok {
+			t.Errorf("peer %s not found in peer index", id)
+		}
+	}
+}
+
+func TestManager_ApplyPeers_BatchFailureFallsBack(t *testing.T) {
+	ctrl := &mockBatchController{applyPeersErr: errors.New("batch rejected")}
+	mgr := NewManager(ctrl, Config{}, discardLogger())
+
+	err := mgr.ApplyPeers(context.Background(), nil, nil,
+		[]api.Peer{testPeer("peer-1"), testPeer("peer-2")})
+	if err != nil {
+		t.Fatalf("ApplyPeers() = %v, want nil after per-peer fallback", err)
+	}
+	if n := len(ctrl.callsFor("AddPeer")); n != 2 {
+		t.Errorf("expected 2 AddPeer calls after fallback, got %d", n)
+	}
+	if _, ok := mgr.PeerIndex().Lookup("peer-2"); !ok {
+		t.Error("peer-2 not found in peer index")
+	}
+}
+
+func TestManager_ApplyPeers_InvalidPeer(t *testing.T) {
+	ctrl := &mockBatchController{}
+	mgr := NewManager(ctrl, Config{}, discardLogger())
+
+	bad := testPeer("peer-bad")
+	bad.PublicKey = "not base64!"
+	err := mgr.ApplyPeers(context.Background(), []string{"peer-unknown"}, nil,
+		[]api.Peer{testPeer("peer-1"), bad})
+	if err == nil {
+		t.Fatal("ApplyPeers() = nil, want error")
+	}
+	for _, want := range []string{"unknown peer ID: peer-unknown", "add peer", "decode public key"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+
+	// The valid peer is still applied in the batch.
+	batches := ctrl.callsFor("ApplyPeers")
+	if len(batches) != 1 || batches[0].Args[2] != 1 {
+		t.Fatalf("ApplyPeers calls = %v, want one batch with 1 upsert", batches)
+	}
+	if _, ok := mgr.PeerIndex().Lookup("peer-bad"); ok {
+		t.Error("invalid peer added to index")
+	}
+}
+
+func TestManager_ConfigurePeers_Batched(t *testing.T) {
+	ctrl := &mockBatchController{}
+	mgr := NewManager(ctrl, Config{PeerBatchSize: 2}, discardLogger())
+
+	peers := []api.Peer{testPeer("peer-1"), testPeer("peer-2"), testPeer("peer-3")}
+	if err := mgr.ConfigurePeers(context.Background(), peers); err != nil {
+		t.Fatalf("ConfigurePeers() = %v", err)
+	}
+	if n := len(ctrl.callsFor("ApplyPeers")); n != 2 {
+		t.Errorf("expected 2 ApplyPeers calls, got %d", n)
+	}
+	if n := len(ctrl.callsFor("AddPeer")); n != 0 {
+		t.Errorf("expected no AddPeer calls, got %d", n)
+	}
+}
+
+func TestManager_SetInterfaceMTU(t *testing.T) {
+	ctrl := &mockController{}
+	mgr := NewManager(ctrl, Config{}, discardLogger())
+
+	if err := mgr.SetInterfaceMTU(1372); err != nil {
+		t.Fatalf("SetInterfaceMTU() returned error: %v", err)
+	}
+	calls := ctrl.callsFor("SetMTU")
+	if len(calls) != 1 || calls[0].Args[0] != DefaultInterfaceName || calls[0].Args[1] != 1372 {
+		t.Errorf("SetMTU calls = %v, want [%s 1372]", calls, DefaultInterfaceName)
+	}
+}
+
+func TestManager_SetPersistentKeepalive(t *testing.T) {
+	ctrl := &mockController{}
+	mgr := NewManager(ctrl, Config{}, discardLogger())
+	mgr.SetPersistentKeepalive(25)
+
+	if err := mgr.AddPeer(testPeer("peer-1")); err != nil {
+		t.Fatalf("AddPeer() returned error: %v", err)
+	}
+	if err := mgr.UpdatePeer(testPeer("peer-1")); err != nil {
+		t.Fatalf("UpdatePeer() returned error: %v", err)
+	}
+	calls := ctrl.callsFor("AddPeer")
+	if len(calls) != 2 {
+		t.Fatalf("AddPeer calls = %d, want 2", len(calls))
+	}
+	for _, c := range calls {
+		if cfg := c.Args[1].(PeerConfig); cfg.PersistentKeepalive != 25 {
+			t.Errorf("PersistentKeepalive = %d, want 25", cfg.PersistentKeepalive)
+		}
+	}
+}
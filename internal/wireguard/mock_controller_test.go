@@ -98,6 +98,22 @@ func (m *mockController) callsFor(method string) []mockCall {
 	return result
 }
 
+// mockBatchController is a mockController that also implements PeerBatcher.
+// Each ApplyPeers call is recorded with the number of removed and upserted
+// peers as arguments.
+type mockBatchController struct {
+	mockController
+	applyPeersErr error
+}
+
+func (m *mockBatchController) ApplyPeers(iface string, remove [][]byte, upsert []PeerConfig) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, mockCall{Method: "ApplyPeers", Args: []interface{}{iface, len(remove), len(upsert)}})
+	err := m.applyPeersErr
+	m.mu.Unlock()
+	return err
+}
+
 type nopWriter struct{}
 
 func (nopWriter) Write(p []byte) (int, error) { return len(p), nil }
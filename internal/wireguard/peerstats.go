@@ -0,0 +1,80 @@
+package wireguard
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+
+	"github.com/plexsphere/plexd/internal/api"
+	"github.com/plexsphere/plexd/internal/nodeapi"
+)
+
+// PeerStatsReader reads the live handshake and transfer statistics of the
+// peers on the mesh interface for GET /v1/mesh/peers. Peers are identified
+// by matching their public keys against the applied state.
+type PeerStatsReader struct {
+	iface   string
+	applied func() *api.StateResponse
+	read    func(iface string) ([]wgtypes.Peer, error)
+}
+
+// NewPeerStatsReader creates a PeerStatsReader for iface. applied returns
+// the most recently applied state, or nil if there is none; pass
+// (*reconcile.Reconciler).Applied.
+func NewPeerStatsReader(iface string, applied func() *api.StateResponse) *PeerStatsReader {
+	return &PeerStatsReader{
+		iface:   iface,
+		applied: applied,
+		read:    readDevicePeers,
+	}
+}
+
+// MeshPeers returns the peers on the interface, sorted by peer ID. Peers
+// missing from the applied state sort last, by public key.
+func (r *PeerStatsReader) MeshPeers(_ context.Context) ([]nodeapi.MeshPeer, error) {
+	devPeers, err := r.read(r.iface)
+	if err != nil {
+		return nil, fmt.Errorf("wireguard: read peer stats: %w", err)
+	}
+
+	known := make(map[string]api.Peer)
+	if state := r.applied(); state != nil {
+		for _, p := range state.Peers {
+			known[p.PublicKey] = p
+		}
+	}
+
+	peers := make([]nodeapi.MeshPeer, 0, len(devPeers))
+	for _, dp := range devPeers {
+		mp := nodeapi.MeshPeer{
+			PublicKey: dp.PublicKey.String(),
+			RxBytes:   dp.ReceiveBytes,
+			TxBytes:   dp.TransmitBytes,
+		}
+		if p, ok := known[mp.PublicKey]; ok {
+			mp.PeerID = p.ID
+			mp.MeshIP = p.MeshIP
+		}
+		if dp.Endpoint != nil {
+			mp.Endpoint = dp.Endpoint.String()
+		}
+		if !dp.LastHandshakeTime.IsZero() {
+			t := dp.LastHandshakeTime
+			mp.LastHandshake = &t
+		}
+		peers = append(peers, mp)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		a, b := peers[i], peers[j]
+		if (a.PeerID == "") != (b.PeerID == "") {
+			return b.PeerID == ""
+		}
+		if a.PeerID != b.PeerID {
+			return a.PeerID < b.PeerID
+		}
+		return a.PublicKey < b.PublicKey
+	})
+	return peers, nil
+}
@@ -0,0 +1,82 @@
+package wireguard
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+func TestPeerStatsReader_MeshPeers(t *testing.T) {
+	keyA := wgtypes.Key{1}
+	keyB := wgtypes.Key{2}
+	keyX := wgtypes.Key{3}
+	handshake := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	state := &api.StateResponse{Peers: []api.Peer{
+		{ID: "peer-b", PublicKey: keyB.String(), MeshIP: "10.99.0.3"},
+		{ID: "peer-a", PublicKey: keyA.String(), MeshIP: "10.99.0.2"},
+	}}
+	r := NewPeerStatsReader("plexd0", func() *api.StateResponse { return state })
+	r.read = func(iface string) ([]wgtypes.Peer, error) {
+		if iface != "plexd0" {
+			t.Errorf("iface = %q, want plexd0", iface)
+		}
+		return []wgtypes.Peer{
+			{PublicKey: keyX},
+			{PublicKey: keyB, ReceiveBytes: 5, TransmitBytes: 6},
+			{
+				PublicKey:         keyA,
+				Endpoint:          &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 51820},
+				LastHandshakeTime: handshake,
+				ReceiveBytes:      100,
+				TransmitBytes:     200,
+			},
+		}, nil
+	}
+
+	peers, err := r.MeshPeers(context.Background())
+	if err != nil {
+		t.Fatalf("MeshPeers() = %v", err)
+	}
+	if len(peers) != 3 {
+		t.Fatalf("len(peers) = %d, want 3", len(peers))
+	}
+	a := peers[0]
+	if a.PeerID != "peer-a" || a.MeshIP != "10.99.0.2" || a.Endpoint != "192.0.2.1:51820" || a.RxBytes != 100 || a.TxBytes != 200 {
+		t.Errorf("peers[0] = %+v", a)
+	}
+	if a.LastHandshake == nil || !a.LastHandshake.Equal(handshake) {
+		t.Errorf("peers[0].LastHandshake = %v, want %v", a.LastHandshake, handshake)
+	}
+	if peers[1].PeerID != "peer-b" || peers[1].LastHandshake != nil {
+		t.Errorf("peers[1] = %+v", peers[1])
+	}
+	if peers[2].PeerID != "" || peers[2].PublicKey != keyX.String() {
+		t.Errorf("peers[2] = %+v, want unknown peer last", peers[2])
+	}
+}
+
+func TestPeerStatsReader_NoAppliedState(t *testing.T) {
+	r := NewPeerStatsReader("plexd0", func() *api.StateResponse { return nil })
+	r.read = func(string) ([]wgtypes.Peer, error) {
+		return []wgtypes.Peer{{PublicKey: wgtypes.Key{1}}}, nil
+	}
+	peers, err := r.MeshPeers(context.Background())
+	if err != nil || len(peers) != 1 || peers[0].PeerID != "" {
+		t.Errorf("MeshPeers() = %+v, %v", peers, err)
+	}
+}
+
+func TestPeerStatsReader_ReadError(t *testing.T) {
+	r := NewPeerStatsReader("plexd0", func() *api.StateResponse { return nil })
+	r.read = func(string) ([]wgtypes.Peer, error) { return nil, errors.New("no such device") }
+	if _, err := r.MeshPeers(context.Background()); err == nil {
+		t.Error("MeshPeers() = nil, want error")
+	}
+}
@@ -0,0 +1,23 @@
+package wireguard
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// TunnelServiceName returns the Windows service name that WireGuard for
+// Windows registers for the tunnel named iface.
+func TunnelServiceName(iface string) string {
+	return "WireGuardTunnel$" + iface
+}
+
+// GenerateTunnelConfig renders the configuration file installed as a
+// WireGuard for Windows tunnel service. Only the [Interface] section is
+// written: addresses, MTU, and peers are configured after the tunnel is up,
+// the same way the Linux controller configures a bare interface.
+func GenerateTunnelConfig(privateKey []byte, listenPort int) string {
+	return fmt.Sprintf(`[Interface]
+PrivateKey = %s
+ListenPort = %d
+`, base64.StdEncoding.EncodeToString(privateKey), listenPort)
+}
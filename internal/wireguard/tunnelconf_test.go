@@ -0,0 +1,32 @@
+package wireguard
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestGenerateTunnelConfig(t *testing.T) {
+	key := make([]byte, 32)
+	key[0] = 1
+	out := GenerateTunnelConfig(key, 51820)
+
+	if !strings.HasPrefix(out, "[Interface]\n") {
+		t.Errorf("config does not start with [Interface]:\n%s", out)
+	}
+	if want := "PrivateKey = " + base64.StdEncoding.EncodeToString(key) + "\n"; !strings.Contains(out, want) {
+		t.Errorf("config missing %q:\n%s", want, out)
+	}
+	if !strings.Contains(out, "ListenPort = 51820\n") {
+		t.Errorf("config missing ListenPort:\n%s", out)
+	}
+	if strings.Contains(out, "[Peer]") {
+		t.Errorf("config must not contain peers:\n%s", out)
+	}
+}
+
+func TestTunnelServiceName(t *testing.T) {
+	if got := TunnelServiceName("plexd0"); got != "WireGuardTunnel$plexd0" {
+		t.Errorf("TunnelServiceName() = %q, want %q", got, "WireGuardTunnel$plexd0")
+	}
+}
@@ -0,0 +1,92 @@
+//go:build linux
+
+package wireguard
+
+import (
+	"fmt"
+	"net"
+
+	"golang.zx2c4.com/wireguard/conn"
+	"golang.zx2c4.com/wireguard/device"
+	"golang.zx2c4.com/wireguard/ipc"
+	"golang.zx2c4.com/wireguard/tun"
+)
+
+// userspaceDevice is a wireguard-go device bound to a TUN interface.
+type userspaceDevice struct {
+	dev  *device.Device
+	uapi net.Listener
+}
+
+// createUserspace creates the TUN interface name through /dev/net/tun and
+// runs a wireguard-go device on it. The device serves the UAPI socket that
+// wgctrl and wg(8) use, so peers are configured as for a kernel interface,
+// and addresses, MTU, and link state are set through netlink on the TUN
+// interface. This only needs CAP_NET_ADMIN and access to /dev/net/tun.
+func (c *NetlinkController) createUserspace(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.userspace[name]; ok {
+		return fmt.Errorf("%s already exists", name)
+	}
+
+	tdev, err := tun.CreateTUN(name, device.DefaultMTU)
+	if err != nil {
+		return fmt.Errorf("create tun: %w", err)
+	}
+	d := &userspaceDevice{dev: device.NewDevice(tdev, conn.NewDefaultBind(), c.deviceLogger(name))}
+
+	file, err := ipc.UAPIOpen(name)
+	if err != nil {
+		d.dev.Close()
+		return fmt.Errorf("open uapi socket: %w", err)
+	}
+	d.uapi, err = ipc.UAPIListen(name, file)
+	if err != nil {
+		file.Close()
+		d.dev.Close()
+		return fmt.Errorf("listen on uapi socket: %w", err)
+	}
+	go func() {
+		for {
+			conn, err := d.uapi.Accept()
+			if err != nil {
+				return
+			}
+			go d.dev.IpcHandle(conn)
+		}
+	}()
+
+	c.userspace[name] = d
+	return nil
+}
+
+// deleteUserspace stops the userspace device name, which removes its TUN
+// interface and UAPI socket. It returns false if name is not a userspace
+// device.
+func (c *NetlinkController) deleteUserspace(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d, ok := c.userspace[name]
+	if !ok {
+		return false
+	}
+	delete(c.userspace, name)
+	d.uapi.Close()
+	d.dev.Close()
+	return true
+}
+
+// deviceLogger forwards wireguard-go log output to the controller's logger.
+func (c *NetlinkController) deviceLogger(name string) *device.Logger {
+	return &device.Logger{
+		Verbosef: func(format string, args ...any) {
+			c.logger.Debug(fmt.Sprintf(format, args...), "component", "wireguard", "interface", name)
+		},
+		Errorf: func(format string, args ...any) {
+			c.logger.Error(fmt.Sprintf(format, args...), "component", "wireguard", "interface", name)
+		},
+	}
+}
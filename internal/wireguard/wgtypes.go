@@ -0,0 +1,160 @@
+package wireguard
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// toWGPeerConfig converts a PeerConfig to the wgctrl representation used by
+// the platform controllers. Allowed IPs replace the peer's existing set.
+func toWGPeerConfig(cfg PeerConfig) (wgtypes.PeerConfig, error) {
+	pubKey, err := wgtypes.NewKey(cfg.PublicKey)
+	if err != nil {
+		return wgtypes.PeerConfig{}, fmt.Errorf("parse public key: %w", err)
+	}
+
+	peerCfg := wgtypes.PeerConfig{
+		PublicKey:         pubKey,
+		ReplaceAllowedIPs: true,
+	}
+
+	if cfg.Endpoint != "" {
+		udpAddr, err := net.ResolveUDPAddr("udp", cfg.Endpoint)
+		if err != nil {
+			return wgtypes.PeerConfig{}, fmt.Errorf("resolve endpoint: %w", err)
+		}
+		peerCfg.Endpoint = udpAddr
+	}
+
+	for _, cidr := range cfg.AllowedIPs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return wgtypes.PeerConfig{}, fmt.Errorf("parse allowed IP %q: %w", cidr, err)
+		}
+		peerCfg.AllowedIPs = append(peerCfg.AllowedIPs, *ipNet)
+	}
+
+	if len(cfg.PSK) > 0 {
+		psk, err := wgtypes.NewKey(cfg.PSK)
+		if err != nil {
+			return wgtypes.PeerConfig{}, fmt.Errorf("parse psk: %w", err)
+		}
+		peerCfg.PresharedKey = &psk
+	}
+
+	if cfg.PersistentKeepalive > 0 {
+		keepalive := time.Duration(cfg.PersistentKeepalive) * time.Second
+		peerCfg.PersistentKeepaliveInterval = &keepalive
+	}
+
+	return peerCfg, nil
+}
+
+// configureDevice applies cfg to the named device through wgctrl.
+func configureDevice(iface string, cfg wgtypes.Config) error {
+	client, err := wgctrl.New()
+	if err != nil {
+		return fmt.Errorf("open wgctrl: %w", err)
+	}
+	defer client.Close()
+
+	if err := client.ConfigureDevice(iface, cfg); err != nil {
+		return fmt.Errorf("configure device: %w", err)
+	}
+	return nil
+}
+
+// applyPeers removes the peers with the public keys in remove and adds or
+// updates the peers in upsert with a single device configuration. wgctrl
+// splits large configurations into as many netlink messages as needed.
+func applyPeers(iface string, remove [][]byte, upsert []PeerConfig) error {
+	peers := make([]wgtypes.PeerConfig, 0, len(remove)+len(upsert))
+	for _, key := range remove {
+		pubKey, err := wgtypes.NewKey(key)
+		if err != nil {
+			return fmt.Errorf("parse public key: %w", err)
+		}
+		peers = append(peers, wgtypes.PeerConfig{PublicKey: pubKey, Remove: true})
+	}
+	for _, cfg := range upsert {
+		peerCfg, err := toWGPeerConfig(cfg)
+		if err != nil {
+			return err
+		}
+		peers = append(peers, peerCfg)
+	}
+	return configureDevice(iface, wgtypes.Config{Peers: peers})
+}
+
+// setPeerEndpoint points the existing peer with publicKey at endpoint. The
+// update is skipped by the kernel if the peer does not exist.
+func setPeerEndpoint(iface string, publicKey []byte, endpoint string) error {
+	pubKey, err := wgtypes.NewKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("parse public key: %w", err)
+	}
+	udpAddr, err := net.ResolveUDPAddr("udp", endpoint)
+	if err != nil {
+		return fmt.Errorf("resolve endpoint: %w", err)
+	}
+	return configureDevice(iface, wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{{
+			PublicKey:  pubKey,
+			UpdateOnly: true,
+			Endpoint:   udpAddr,
+		}},
+	})
+}
+
+// listPeers reads the peers of the named device through wgctrl.
+func listPeers(iface string) ([]PeerConfig, error) {
+	client, err := wgctrl.New()
+	if err != nil {
+		return nil, fmt.Errorf("open wgctrl: %w", err)
+	}
+	defer client.Close()
+
+	dev, err := client.Device(iface)
+	if err != nil {
+		return nil, fmt.Errorf("read device: %w", err)
+	}
+
+	peers := make([]PeerConfig, 0, len(dev.Peers))
+	for _, p := range dev.Peers {
+		cfg := PeerConfig{
+			PublicKey:           p.PublicKey[:],
+			PersistentKeepalive: int(p.PersistentKeepaliveInterval / time.Second),
+		}
+		if p.Endpoint != nil {
+			cfg.Endpoint = p.Endpoint.String()
+		}
+		for _, ipNet := range p.AllowedIPs {
+			cfg.AllowedIPs = append(cfg.AllowedIPs, ipNet.String())
+		}
+		if p.PresharedKey != (wgtypes.Key{}) {
+			cfg.PSK = p.PresharedKey[:]
+		}
+		peers = append(peers, cfg)
+	}
+	return peers, nil
+}
+
+// readDevicePeers reads the peers of the named device, with their handshake
+// and transfer statistics, through wgctrl.
+func readDevicePeers(iface string) ([]wgtypes.Peer, error) {
+	client, err := wgctrl.New()
+	if err != nil {
+		return nil, fmt.Errorf("open wgctrl: %w", err)
+	}
+	defer client.Close()
+
+	dev, err := client.Device(iface)
+	if err != nil {
+		return nil, fmt.Errorf("read device: %w", err)
+	}
+	return dev.Peers, nil
+}
@@ -0,0 +1,61 @@
+package wireguard
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToWGPeerConfig(t *testing.T) {
+	pub := make([]byte, 32)
+	psk := make([]byte, 32)
+	psk[0] = 7
+
+	got, err := toWGPeerConfig(PeerConfig{
+		PublicKey:           pub,
+		Endpoint:            "192.0.2.1:51820",
+		AllowedIPs:          []string{"10.0.0.2/32", "fd00::2/128"},
+		PSK:                 psk,
+		PersistentKeepalive: 25,
+	})
+	if err != nil {
+		t.Fatalf("toWGPeerConfig() = %v", err)
+	}
+	if !got.ReplaceAllowedIPs {
+		t.Error("ReplaceAllowedIPs = false, want true")
+	}
+	if got.Endpoint == nil || got.Endpoint.String() != "192.0.2.1:51820" {
+		t.Errorf("Endpoint = %v, want 192.0.2.1:51820", got.Endpoint)
+	}
+	if len(got.AllowedIPs) != 2 {
+		t.Errorf("AllowedIPs = %v, want 2 entries", got.AllowedIPs)
+	}
+	if got.PresharedKey == nil || got.PresharedKey[0] != 7 {
+		t.Error("PresharedKey not set")
+	}
+	if got.PersistentKeepaliveInterval == nil || *got.PersistentKeepaliveInterval != 25*time.Second {
+		t.Errorf("PersistentKeepaliveInterval = %v, want 25s", got.PersistentKeepaliveInterval)
+	}
+}
+
+func TestToWGPeerConfig_Errors(t *testing.T) {
+	valid := make([]byte, 32)
+	tests := []struct {
+		name    string
+		cfg     PeerConfig
+		wantErr string
+	}{
+		{"bad public key", PeerConfig{PublicKey: []byte{1}}, "parse public key"},
+		{"bad endpoint", PeerConfig{PublicKey: valid, Endpoint: "not-an-endpoint"}, "resolve endpoint"},
+		{"bad allowed IP", PeerConfig{PublicKey: valid, AllowedIPs: []string{"10.0.0.1"}}, "parse allowed IP"},
+		{"bad psk", PeerConfig{PublicKey: valid, PSK: []byte{1}}, "parse psk"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := toWGPeerConfig(tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("toWGPeerConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,349 @@
+package wsrelay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/plexsphere/plexd/internal/api"
+)
+
+// maxPeerIDLen is the longest peer ID a frame can carry.
+const maxPeerIDLen = 255
+
+// maxPacketSize is the largest WireGuard packet read from a peer socket.
+const maxPacketSize = 65535
+
+// Conn is a message-oriented connection to the relay.
+// *api.WebSocketConn satisfies this interface.
+type Conn interface {
+	ReadMessage() ([]byte, error)
+	WriteMessage(payload []byte) error
+	Close() error
+}
+
+// Dialer opens a connection to the relay.
+type Dialer interface {
+	Dial(ctx context.Context) (Conn, error)
+}
+
+// ControlPlaneDialer dials the relay hosted by the control plane for a node.
+type ControlPlaneDialer struct {
+	Client *api.ControlPlane
+	NodeID string
+}
+
+// Dial opens the node's relay WebSocket.
+func (d ControlPlaneDialer) Dial(ctx context.Context) (Conn, error) {
+	conn, err := d.Client.ConnectRelayWebSocket(ctx, d.NodeID)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Status is a snapshot of the relay client.
+type Status struct {
+	Connected       bool     `json:"connected"`
+	Peers           []string `json:"peers"`
+	PacketsSent     uint64   `json:"packets_sent"`
+	PacketsReceived uint64   `json:"packets_received"`
+	PacketsDropped  uint64   `json:"packets_dropped"`
+}
+
+// peerConn is the local socket WireGuard reaches a relayed peer at.
+type peerConn struct {
+	id   string
+	sock *net.UDPConn
+}
+
+// Client relays the WireGuard packets of selected peers over the control
+// plane. Each relayed peer gets a loopback UDP socket that WireGuard is
+// pointed at; packets WireGuard sends to it are forwarded over the
+// WebSocket, and packets the relay delivers from the peer are written from
+// it to the local WireGuard port.
+//
+// Every message on the WebSocket is one packet, prefixed with the length
+// of the peer ID as a single byte and the peer ID: the destination on
+// messages sent, the source on messages received.
+type Client struct {
+	cfg    Config
+	dialer Dialer
+	wgAddr *net.UDPAddr
+	logger *slog.Logger
+
+	// wake is signalled when a peer starts using the relay.
+	wake chan struct{}
+
+	mu       sync.Mutex
+	peers    map[string]*peerConn
+	conn     Conn
+	sent     uint64
+	received uint64
+	dropped  uint64
+}
+
+// NewClient creates a new Client that delivers relayed packets to the
+// WireGuard interface listening on wgPort. Config defaults are applied
+// automatically.
+func NewClient(cfg Config, dialer Dialer, wgPort int, logger *slog.Logger) *Client {
+	cfg.ApplyDefaults()
+	return &Client{
+		cfg:    cfg,
+		dialer: dialer,
+		wgAddr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: wgPort},
+		logger: logger.With("component", "wsrelay"),
+		wake:   make(chan struct{}, 1),
+		peers:  make(map[string]*peerConn),
+	}
+}
+
+// Open starts relaying the packets of the peer and returns the local
+// endpoint WireGuard must use for it. Opening a peer that is already
+// relayed returns its existing endpoint.
+func (c *Client) Open(peerID string) (string, error) {
+	if !c.cfg.Enabled {
+		return "", errors.New("wsrelay: relay disabled")
+	}
+	if peerID == "" || len(peerID) > maxPeerIDLen {
+		return "", fmt.Errorf("wsrelay: invalid peer ID %q", peerID)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pc, ok := c.peers[peerID]; ok {
+		return pc.sock.LocalAddr().String(), nil
+	}
+	sock, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return "", fmt.Errorf("wsrelay: listen for peer %s: %w", peerID, err)
+	}
+	pc := &peerConn{id: peerID, sock: sock}
+	c.peers[peerID] = pc
+	go c.readPeer(pc)
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+	c.logger.Info("peer relayed over control plane", "peer_id", peerID, "endpoint", sock.LocalAddr().String())
+	return sock.LocalAddr().String(), nil
+}
+
+// Close stops relaying the packets of the peer. The connection to the
+// relay is closed once no peer uses it.
+func (c *Client) Close(peerID string) {
+	c.mu.Lock()
+	pc, ok := c.peers[peerID]
+	delete(c.peers, peerID)
+	var idle Conn
+	if len(c.peers) == 0 {
+		idle = c.conn
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	pc.sock.Close()
+	if idle != nil {
+		idle.Close()
+	}
+	c.logger.Info("peer no longer relayed over control plane", "peer_id", peerID)
+}
+
+// Status returns the relayed peers, sorted, and the packet counters.
+func (c *Client) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := Status{
+		Connected:       c.conn != nil,
+		Peers:           make([]string, 0, len(c.peers)),
+		PacketsSent:     c.sent,
+		PacketsReceived: c.received,
+		PacketsDropped:  c.dropped,
+	}
+	for id := range c.peers {
+		s.Peers = append(s.Peers, id)
+	}
+	slices.Sort(s.Peers)
+	return s
+}
+
+// Run keeps a connection to the relay open while at least one peer uses it,
+// reconnecting every ReconnectInterval after a failure, until ctx is
+// cancelled. It then closes the sockets of all relayed peers. It returns
+// nil immediately when the relay is disabled. Run always returns nil.
+func (c *Client) Run(ctx context.Context) error {
+	if !c.cfg.Enabled {
+		c.logger.Info("fallback relay disabled")
+		return nil
+	}
+	defer c.closeAll()
+
+	for {
+		if !c.inUse() {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-c.wake:
+				continue
+			}
+		}
+
+		conn, err := c.dialer.Dial(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			c.logger.Warn("fallback relay connect failed", "error", err)
+		} else {
+			c.serve(ctx, conn)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if !c.inUse() {
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(c.cfg.ReconnectInterval):
+		}
+	}
+}
+
+// serve delivers the packets received on conn until it fails, ctx is
+// cancelled, or the last peer stops using the relay.
+func (c *Client) serve(ctx context.Context, conn Conn) {
+	c.mu.Lock()
+	if len(c.peers) == 0 {
+		c.mu.Unlock()
+		conn.Close()
+		return
+	}
+	c.conn = conn
+	c.mu.Unlock()
+	c.logger.Info("fallback relay connected")
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	for {
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		peerID, packet, err := decodeFrame(msg)
+		if err != nil {
+			c.logger.Debug("fallback relay message dropped", "error", err)
+			c.countDropped()
+			continue
+		}
+		c.mu.Lock()
+		pc, ok := c.peers[peerID]
+		if ok {
+			c.received++
+		} else {
+			c.dropped++
+		}
+		c.mu.Unlock()
+		if ok {
+			if _, err := pc.sock.WriteToUDP(packet, c.wgAddr); err != nil {
+				c.logger.Debug("relayed packet delivery failed", "peer_id", peerID, "error", err)
+			}
+		}
+	}
+
+	c.mu.Lock()
+	if c.conn == conn {
+		c.conn = nil
+	}
+	c.mu.Unlock()
+	conn.Close()
+	c.logger.Info("fallback relay disconnected")
+}
+
+// readPeer forwards the packets WireGuard sends to the peer's socket over
+// the relay until the socket is closed. Packets from any other source, and
+// packets read while the relay is disconnected, are dropped.
+func (c *Client) readPeer(pc *peerConn) {
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, from, err := pc.sock.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if !from.IP.IsLoopback() || from.Port != c.wgAddr.Port {
+			c.countDropped()
+			continue
+		}
+
+		c.mu.Lock()
+		conn := c.conn
+		c.mu.Unlock()
+		if conn == nil {
+			c.countDropped()
+			continue
+		}
+		if err := conn.WriteMessage(encodeFrame(pc.id, buf[:n])); err != nil {
+			c.logger.Debug("relayed packet send failed", "peer_id", pc.id, "error", err)
+			c.countDropped()
+			continue
+		}
+		c.mu.Lock()
+		c.sent++
+		c.mu.Unlock()
+	}
+}
+
+// inUse reports whether any peer is relayed.
+func (c *Client) inUse() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.peers) > 0
+}
+
+func (c *Client) countDropped() {
+	c.mu.Lock()
+	c.dropped++
+	c.mu.Unlock()
+}
+
+// closeAll closes the sockets of all relayed peers.
+func (c *Client) closeAll() {
+	c.mu.Lock()
+	peers := c.peers
+	c.peers = make(map[string]*peerConn)
+	c.mu.Unlock()
+	for _, pc := range peers {
+		pc.sock.Close()
+	}
+}
+
+// encodeFrame prefixes packet with the length of peerID and peerID.
+func encodeFrame(peerID string, packet []byte) []byte {
+	msg := make([]byte, 0, 1+len(peerID)+len(packet))
+	msg = append(msg, byte(len(peerID)))
+	msg = append(msg, peerID...)
+	return append(msg, packet...)
+}
+
+// decodeFrame splits a relay message into its peer ID and packet.
+func decodeFrame(msg []byte) (string, []byte, error) {
+	if len(msg) == 0 {
+		return "", nil, errors.New("wsrelay: empty message")
+	}
+	n := int(msg[0])
+	if n == 0 || len(msg) < 1+n {
+		return "", nil, errors.New("wsrelay: malformed peer ID")
+	}
+	return string(msg[1 : 1+n]), msg[1+n:], nil
+}
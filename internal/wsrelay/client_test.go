@@ -0,0 +1,223 @@
+package wsrelay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// fakeConn is an in-memory relay connection.
+type fakeConn struct {
+	in     chan []byte
+	out    chan []byte
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{in: make(chan []byte, 16), out: make(chan []byte, 16), closed: make(chan struct{})}
+}
+
+func (c *fakeConn) ReadMessage() ([]byte, error) {
+	select {
+	case msg := <-c.in:
+		return msg, nil
+	case <-c.closed:
+		return nil, io.EOF
+	}
+}
+
+func (c *fakeConn) WriteMessage(payload []byte) error {
+	select {
+	case <-c.closed:
+		return net.ErrClosed
+	case c.out <- bytes.Clone(payload):
+		return nil
+	}
+}
+
+func (c *fakeConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+// fakeDialer hands out a new fakeConn per dial, or fails while err is set.
+type fakeDialer struct {
+	mu    sync.Mutex
+	err   error
+	conns chan *fakeConn
+}
+
+func (d *fakeDialer) Dial(context.Context) (Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return nil, d.err
+	}
+	c := newFakeConn()
+	d.conns <- c
+	return c, nil
+}
+
+func waitConn(t *testing.T, d *fakeDialer) *fakeConn {
+	t.Helper()
+	select {
+	case c := <-d.conns:
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("relay not dialed")
+		return nil
+	}
+}
+
+// startClient runs a Client delivering to a UDP socket standing in for
+// WireGuard.
+func startClient(t *testing.T, d *fakeDialer) (*Client, *net.UDPConn) {
+	t.Helper()
+	wg, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { wg.Close() })
+
+	c := NewClient(Config{Enabled: true, ReconnectInterval: time.Second}, d, wg.LocalAddr().(*net.UDPAddr).Port, discardLogger())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = c.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return c, wg
+}
+
+func TestClient_RelaysPackets(t *testing.T) {
+	d := &fakeDialer{conns: make(chan *fakeConn, 4)}
+	c, wg := startClient(t, d)
+
+	ep, err := c.Open("peer-1")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	conn := waitConn(t, d)
+	epAddr, err := net.ResolveUDPAddr("udp4", ep)
+	if err != nil {
+		t.Fatalf("resolve %q: %v", ep, err)
+	}
+
+	// The relay reports connected once the connection is served.
+	deadline := time.Now().Add(5 * time.Second)
+	for !c.Status().Connected {
+		if time.Now().After(deadline) {
+			t.Fatal("relay not connected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := wg.WriteToUDP([]byte("outbound"), epAddr); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	select {
+	case msg := <-conn.out:
+		if !bytes.Equal(msg, encodeFrame("peer-1", []byte("outbound"))) {
+			t.Errorf("sent %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("packet not sent over the relay")
+	}
+
+	conn.in <- encodeFrame("peer-2", []byte("unknown"))
+	conn.in <- encodeFrame("peer-1", []byte("inbound"))
+	buf := make([]byte, 64)
+	wg.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, from, err := wg.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(buf[:n]) != "inbound" || from.String() != ep {
+		t.Errorf("received %q from %s, want %q from %s", buf[:n], from, "inbound", ep)
+	}
+
+	st := c.Status()
+	if len(st.Peers) != 1 || st.Peers[0] != "peer-1" || st.PacketsSent != 1 || st.PacketsReceived != 1 || st.PacketsDropped != 1 {
+		t.Errorf("unexpected status: %+v", st)
+	}
+
+	// Closing the last peer closes the connection.
+	c.Close("peer-1")
+	select {
+	case <-conn.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not closed after the last peer")
+	}
+}
+
+func TestClient_OpenIsIdempotent(t *testing.T) {
+	d := &fakeDialer{conns: make(chan *fakeConn, 4)}
+	c, _ := startClient(t, d)
+
+	ep1, err := c.Open("peer-1")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	ep2, err := c.Open("peer-1")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if ep1 != ep2 {
+		t.Errorf("second Open = %q, want %q", ep2, ep1)
+	}
+	c.Close("peer-1")
+}
+
+func TestClient_Reconnects(t *testing.T) {
+	d := &fakeDialer{conns: make(chan *fakeConn, 4), err: errors.New("unavailable")}
+	c, _ := startClient(t, d)
+
+	if _, err := c.Open("peer-1"); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	d.mu.Lock()
+	d.err = nil
+	d.mu.Unlock()
+	first := waitConn(t, d)
+
+	first.Close()
+	waitConn(t, d)
+	c.Close("peer-1")
+}
+
+func TestClient_OpenDisabled(t *testing.T) {
+	c := NewClient(Config{Enabled: false, ReconnectInterval: time.Second}, &fakeDialer{}, 51820, discardLogger())
+	if _, err := c.Open("peer-1"); err == nil {
+		t.Error("Open succeeded with the relay disabled")
+	}
+	if err := c.Run(context.Background()); err != nil {
+		t.Errorf("Run() = %v, want nil", err)
+	}
+}
+
+func TestDecodeFrame(t *testing.T) {
+	id, packet, err := decodeFrame(encodeFrame("peer-1", []byte{1, 2, 3}))
+	if err != nil || id != "peer-1" || !bytes.Equal(packet, []byte{1, 2, 3}) {
+		t.Errorf("decodeFrame = %q, %v, %v", id, packet, err)
+	}
+	for _, msg := range [][]byte{nil, {0, 1}, {5, 'a'}} {
+		if _, _, err := decodeFrame(msg); err == nil {
+			t.Errorf("decodeFrame(%v) succeeded", msg)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Package wsrelay relays WireGuard packets to peers over a WebSocket
+// connection to the control plane. It is the path of last resort, used
+// while neither a direct path nor an assigned UDP relay reaches a peer.
+package wsrelay
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultReconnectInterval is the default time between connection attempts
+// while peers use the relay.
+const DefaultReconnectInterval = 5 * time.Second
+
+// Config holds the configuration for the fallback relay.
+type Config struct {
+	// Enabled controls whether peers can fall back to the relay.
+	// Default: true (set by ApplyDefaults).
+	Enabled bool
+
+	// ReconnectInterval is the time between connection attempts while at
+	// least one peer uses the relay. Must be at least 1s.
+	// Default: 5s
+	ReconnectInterval time.Duration
+}
+
+// ApplyDefaults sets default values for zero-valued fields.
+// On a zero-valued Config, Enabled defaults to true.
+// To disable the relay, set Enabled=false before or after calling ApplyDefaults.
+func (c *Config) ApplyDefaults() {
+	// Enabled defaults to true for zero-valued Config. If any field is
+	// non-zero, the caller constructed the config explicitly and we respect
+	// Enabled as-is.
+	if c.ReconnectInterval == 0 {
+		c.Enabled = true
+	}
+	if c.ReconnectInterval == 0 {
+		c.ReconnectInterval = DefaultReconnectInterval
+	}
+}
+
+// Validate checks that configuration values are within acceptable ranges.
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.ReconnectInterval < time.Second {
+		return errors.New("wsrelay: config: ReconnectInterval must be at least 1s")
+	}
+	return nil
+}